@@ -6,21 +6,36 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/leafsii/leafsii-backend/internal/api"
+	"github.com/leafsii/leafsii-backend/internal/apikeys"
+	"github.com/leafsii/leafsii-backend/internal/candles"
 	"github.com/leafsii/leafsii-backend/internal/config"
 	"github.com/leafsii/leafsii-backend/internal/crosschain"
 	gdb "github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/health"
 	"github.com/leafsii/leafsii-backend/internal/jobs"
 	"github.com/leafsii/leafsii-backend/internal/log"
 	"github.com/leafsii/leafsii-backend/internal/markets"
 	"github.com/leafsii/leafsii-backend/internal/metrics"
 	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/prices"
 	"github.com/leafsii/leafsii-backend/internal/prices/binance"
+	"github.com/leafsii/leafsii-backend/internal/prices/providers"
 	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/leafsii/leafsii-backend/internal/watchlist"
+	"github.com/leafsii/leafsii-backend/internal/webhooks"
 	"github.com/leafsii/leafsii-backend/internal/ws"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	_ "github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	_ "github.com/leafsii/leafsii-backend/pkg/kv/redis"
+	"github.com/pattonkan/sui-go/suisigner"
+	"github.com/pattonkan/sui-go/suisigner/suicrypto"
+	"github.com/shopspring/decimal"
 )
 
 func main() {
@@ -52,8 +67,13 @@ func main() {
 	}
 
 	// Initialize database via abstraction (defaults to in-memory with USE_IN_MEMORY=true)
-	// Factory reads env: DB_TYPE, DB_DSN, USE_IN_MEMORY
-	db := gdb.MustNewDatabase(nil)
+	// Factory reads env: DB_TYPE, DB_DSN, DB_REPLICA_DSNS, USE_IN_MEMORY
+	// Repository calls are timed in fx_db_query_duration_seconds
+	db := gdb.Instrument(gdb.MustNewDatabase(nil), metricsObj, gdb.SlowQueryLog{
+		Logger:     logger,
+		Threshold:  cfg.Database.SlowQueryThreshold,
+		SampleRate: cfg.Database.SlowQuerySampleRate,
+	})
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := gdb.ConnectAndMigrate(ctx, db, gdb.AllSchemas()); err != nil {
@@ -99,13 +119,43 @@ func main() {
 	if err != nil {
 		logger.Fatalw("Invalid admin cap ID", "error", err)
 	}
+	adminAddress, err := cfg.Sui.GetAdminAddress()
+	if err != nil {
+		logger.Fatalw("Invalid admin address", "error", err)
+	}
+	pythFeedId, err := cfg.Oracle.GetPythPriceInfoObjectId()
+	if err != nil {
+		logger.Fatalw("Invalid Pyth price info object ID", "error", err)
+	}
 
 	// Setup price provider for chain client
 	var priceProvider *binance.Provider
-	if cfg.Prices.Provider == "binance" {
+	if slices.Contains(cfg.Prices.GetProviders(), providers.Binance) {
 		priceProvider = binance.NewProvider(logger)
 	}
 
+	// Aggregate of several exchanges pricing oracle updates, quotes and the
+	// bridge in place of a single one
+	var priceAggregator *prices.Aggregator
+	if names := cfg.Prices.GetAggregateProviders(); len(names) > 0 {
+		priceAggregator, err = providers.NewAggregator(logger, names,
+			prices.WithMaxDeviationBps(cfg.Prices.AggregateMaxDeviationBps),
+			prices.WithMinSources(cfg.Prices.AggregateMinSources),
+			prices.WithTWAPWindow(cfg.Prices.AggregateTWAPWindow),
+			prices.WithAggregateTTL(cfg.Prices.AggregateInterval),
+		)
+		if err != nil {
+			logger.Fatalw("Invalid price aggregate providers", "error", err)
+		}
+		logger.Infow("Aggregating prices", "providers", names, "maxDeviationBps", cfg.Prices.AggregateMaxDeviationBps, "minSources", cfg.Prices.AggregateMinSources)
+	}
+
+	// Sui RPC calls fail over between the configured endpoints
+	rpcPool, err := onchain.NewRPCPool(cfg.Sui.GetRPCURLs(), metricsObj)
+	if err != nil {
+		logger.Fatalw("Failed to create Sui RPC pool", "error", err)
+	}
+
 	// Setup Sui chain client
 	chainClient := onchain.NewClientWithOptions(
 		cfg.Sui.RPCURL,
@@ -120,9 +170,62 @@ func main() {
 			XtokenPackageId:  xtokenPackageId,
 			LeafsiiPackageId: packageId,
 			Provider:         priceProvider,
+			Aggregator:       priceAggregator,
+			RPC:              rpcPool,
 		},
 	)
 
+	txBuilderOpts := []onchain.TransactionBuilderOption{
+		onchain.WithRPCPool(rpcPool),
+		onchain.WithGasSafetyMargin(cfg.Sui.GasSafetyMargin),
+		onchain.WithAdminAddress(adminAddress),
+	}
+	var priceFeed onchain.PriceFeed
+	if pythFeedId != nil {
+		priceFeed = onchain.NewPythPriceFeed(rpcPool, pythFeedId, cfg.Oracle.PythMaxAge, cfg.Oracle.PythMaxConfidenceBps)
+		txBuilderOpts = append(txBuilderOpts, onchain.WithPriceFeed(priceFeed))
+		logger.Infow("Oracle updates priced by Pyth", "price_info_object", pythFeedId)
+	}
+	if cfg.Sui.ObjectCacheTTL > 0 {
+		// Kept in Redis so that replicas share them, in memory while it is
+		// down
+		objectStore, err := kv.NewStoreFromConfig(kv.Config{
+			Backend:         kv.BackendRedis,
+			RedisURL:        cfg.Cache.RedisAddr,
+			FailoverEnabled: true,
+			Logger:          logger.Warnw,
+		})
+		if err != nil {
+			logger.Fatalw("Failed to create object cache store", "error", err)
+		}
+		defer objectStore.Close()
+		txBuilderOpts = append(txBuilderOpts, onchain.WithObjectCache(onchain.NewObjectCache(objectStore, cfg.Sui.ObjectCacheTTL)))
+	}
+	if cfg.Sui.SponsorMnemonic != "" {
+		sponsorSigner, err := suisigner.NewSignerWithMnemonic(cfg.Sui.SponsorMnemonic, suicrypto.KeySchemeFlagEd25519)
+		if err != nil {
+			logger.Fatalw("Invalid sponsor mnemonic", "error", err)
+		}
+		// Sponsored transactions and quotas are kept in Redis so that
+		// replicas share them, in memory while it is down
+		sponsorStore, err := kv.NewStoreFromConfig(kv.Config{
+			Backend:         kv.BackendRedis,
+			RedisURL:        cfg.Cache.RedisAddr,
+			FailoverEnabled: true,
+			Logger:          logger.Warnw,
+		})
+		if err != nil {
+			logger.Fatalw("Failed to create sponsor store", "error", err)
+		}
+		defer sponsorStore.Close()
+		sponsor := onchain.NewSponsor(sponsorSigner, onchain.SponsorQuota{
+			MaxTransactions: cfg.Sui.SponsorMaxTransactions,
+			Window:          cfg.Sui.SponsorQuotaWindow,
+			MaxGasBudget:    cfg.Sui.SponsorMaxGasBudget,
+		}, sponsorStore)
+		txBuilderOpts = append(txBuilderOpts, onchain.WithSponsor(sponsor))
+		logger.Infow("Gas sponsorship enabled", "sponsor", sponsor.Address())
+	}
 	txBuilder := onchain.NewTransactionBuilder(
 		cfg.Sui.RPCURL,
 		cfg.Sui.Network,
@@ -132,33 +235,177 @@ func main() {
 		adminCapId,
 		ftokenPackageId,
 		xtokenPackageId,
+		txBuilderOpts...,
 	)
 
 	// Setup services
-	protocolSvc := onchain.NewProtocolService(chainClient, cache, cfg, logger)
-	quoteSvc := onchain.NewQuoteService(chainClient, cache, protocolSvc, cfg, logger)
-	userSvc := onchain.NewUserService(chainClient, cache, logger)
+	protocolSvc := onchain.NewProtocolService(chainClient, cache, cfg, logger,
+		onchain.WithProtocolEventStore(db.Repository(entities.EventSchema)),
+		onchain.WithProtocolSampleStore(db.Repository(entities.ProtocolSampleSchema)),
+	)
+	// Changes to the fee schedule are kept in Redis so that every replica quotes alike
+	feeStore, err := kv.NewStoreFromConfig(kv.Config{
+		Backend:         kv.BackendRedis,
+		RedisURL:        cfg.Cache.RedisAddr,
+		FailoverEnabled: true,
+		Logger:          logger.Warnw,
+	})
+	if err != nil {
+		logger.Fatalw("Failed to create fee schedule store", "error", err)
+	}
+	defer feeStore.Close()
+	quoteSvc := onchain.NewQuoteService(chainClient, cache, protocolSvc, cfg, logger,
+		onchain.WithFeeSchedule(onchain.FeeSchedule{
+			MintFBps:   cfg.Fees.MintFBps,
+			RedeemFBps: cfg.Fees.RedeemFBps,
+			MintXBps:   cfg.Fees.MintXBps,
+			RedeemXBps: cfg.Fees.RedeemXBps,
+			MinCR:      decimal.NewFromFloat(cfg.Fees.QuoteMinCR),
+		}, feeStore),
+	)
+	userSvc := onchain.NewUserService(chainClient, cache, logger,
+		onchain.WithEventStore(db.Repository(entities.EventSchema)),
+		onchain.WithSampleStore(db.Repository(entities.ProtocolSampleSchema)),
+		onchain.WithZkLogin(rpcPool, cfg.Sui.ZkLoginMaxEpochs),
+	)
 	spSvc := onchain.NewStabilityPoolService(chainClient, cache, logger)
-	crosschainSvc := crosschain.NewService(logger)
+	attestation, err := crosschain.NewAttestationConfigFromEnv()
+	if err != nil {
+		logger.Fatalw("Invalid checkpoint attestation config", "error", err)
+	}
+	vouchers, err := crosschain.NewVoucherConfigFromEnv()
+	if err != nil {
+		logger.Fatalw("Invalid voucher config", "error", err)
+	}
+	walrusReader := crosschain.NewWalrusReaderFromEnv()
+	crosschainSvc := crosschain.NewService(logger,
+		crosschain.WithDatabase(db),
+		crosschain.WithWalrusReader(walrusReader),
+		crosschain.WithAttestation(attestation),
+		crosschain.WithVouchers(vouchers),
+	)
+	if err := crosschainSvc.Load(ctx); err != nil {
+		logger.Fatalw("Failed to load cross-chain state", "error", err)
+	}
 	bridgeOpts := []crosschain.BridgeWorkerOption{}
 
+	// Bridge prices come from the aggregate, or Binance, then the price
+	// publisher's ticks
+	priceSource, err := crosschain.NewPriceSourceFromEnv(priceAggregator, cache, logger)
+	if err != nil {
+		logger.Fatalw("Invalid bridge price config", "error", err)
+	}
+	bridgeOpts = append(bridgeOpts, crosschain.WithPriceSource(priceSource), crosschain.WithFeeRecorder(metricsObj))
+
 	if minter, err := crosschain.NewSuiBridgeMinterFromEnv(logger); err != nil {
 		logger.Warnw("Bridge mint handler disabled", "error", err)
 	} else if minter != nil {
 		bridgeOpts = append(bridgeOpts, crosschain.WithMintHandler(minter))
 	}
+	if batching, err := crosschain.MintBatchingFromEnv(); err != nil {
+		logger.Warnw("Bridge mint batching disabled", "error", err)
+	} else if batching != nil {
+		bridgeOpts = append(bridgeOpts, batching)
+	}
+	if limits, err := crosschain.OwnerRateLimitsFromEnv(); err != nil {
+		logger.Fatalw("Invalid bridge rate limit config", "error", err)
+	} else if limits != nil {
+		// Counted in Redis so that replicas share them, in memory while it is down
+		limitStore, err := kv.NewStoreFromConfig(kv.Config{
+			Backend:         kv.BackendRedis,
+			RedisURL:        cfg.Cache.RedisAddr,
+			FailoverEnabled: true,
+			Logger:          logger.Warnw,
+		})
+		if err != nil {
+			logger.Fatalw("Failed to create bridge rate limit store", "error", err)
+		}
+		defer limitStore.Close()
+		bridgeOpts = append(bridgeOpts, crosschain.WithOwnerRateLimits(*limits, limitStore))
+	}
+	// Accepted submissions are tracked in Redis so that every replica reports them
+	submissionStore, err := kv.NewStoreFromConfig(kv.Config{
+		Backend:         kv.BackendRedis,
+		RedisURL:        cfg.Cache.RedisAddr,
+		FailoverEnabled: true,
+		Logger:          logger.Warnw,
+	})
+	if err != nil {
+		logger.Fatalw("Failed to create bridge submission store", "error", err)
+	}
+	defer submissionStore.Close()
+	bridgeOpts = append(bridgeOpts, crosschain.WithSubmissionStore(submissionStore))
 	if listener, err := crosschain.NewSuiBridgeRedeemListenerFromEnv(logger); err != nil {
 		logger.Warnw("Bridge redeem listener disabled", "error", err)
 	} else if listener != nil {
 		bridgeOpts = append(bridgeOpts, crosschain.WithRedeemListener(listener))
 	}
+	if listeners, err := crosschain.NewEthDepositListenersFromEnv(logger); err != nil {
+		logger.Warnw("Bridge deposit listeners disabled", "error", err)
+	} else {
+		for _, listener := range listeners {
+			bridgeOpts = append(bridgeOpts, crosschain.WithDepositListener(listener))
+		}
+	}
+	if payer, err := crosschain.NewEvmPayoutHandlerFromEnv(logger, crosschainSvc); err != nil {
+		logger.Warnw("Bridge payout handler disabled", "error", err)
+	} else if payer != nil {
+		bridgeOpts = append(bridgeOpts, crosschain.WithPayoutHandler(payer))
+	}
+
+	var walrusPublisher crosschain.WalrusPublisher
+	if publisher, err := crosschain.NewHTTPWalrusPublisherFromEnv(walrusReader, metricsObj); err != nil {
+		logger.Warnw("Walrus publishing disabled", "error", err)
+	} else if publisher != nil {
+		walrusPublisher = publisher
+		bridgeOpts = append(bridgeOpts, crosschain.WithWalrusPublisher(publisher))
+	}
 
 	bridgeWorker := crosschain.NewBridgeWorker(crosschainSvc, logger, bridgeOpts...)
+	checkpointer, err := crosschain.NewCheckpointerFromEnv(crosschainSvc, walrusPublisher, metricsObj, logger)
+	if err != nil {
+		logger.Warnw("Scheduled checkpoints disabled", "error", err)
+	}
+	reconciler, err := crosschain.NewReconcilerFromEnv(crosschainSvc, metricsObj, logger)
+	if err != nil {
+		logger.Warnw("Bridge reconciliation disabled", "error", err)
+	}
 	marketsSvc := markets.NewService()
 
 	// Setup WebSocket hub and SSE handler
-	wsHub := ws.NewHub(cache, logger, metricsObj)
-	sseHandler := ws.NewSSEHandler(cache, logger)
+	priceSymbols := prices.NewRegistry().GetProviderSymbols()
+	// Stream events are numbered and kept in Redis, so that clients resume
+	// on any replica
+	sseStore, err := kv.NewStoreFromConfig(kv.Config{
+		Backend:         kv.BackendRedis,
+		RedisURL:        cfg.Cache.RedisAddr,
+		FailoverEnabled: true,
+		Logger:          logger.Warnw,
+	})
+	if err != nil {
+		logger.Fatalw("Failed to create SSE replay store", "error", err)
+	}
+	defer sseStore.Close()
+	trustedProxies, err := ws.ParseTrustedProxies(cfg.Stream.WSTrustedProxies)
+	if err != nil {
+		logger.Fatalw("Invalid WebSocket trusted proxies", "error", err)
+	}
+	// New WebSocket subscribers get the last updates the SSE handlers keep
+	wsHub := ws.NewHub(cache, logger, metricsObj,
+		ws.WithPriceSymbols(priceSymbols...),
+		ws.WithSendBuffer(cfg.Stream.WSSendBuffer, ws.SlowClientPolicy(cfg.Stream.WSSlowClientPolicy)),
+		ws.WithWriteTimeout(cfg.Stream.WSWriteTimeout),
+		ws.WithConnectionLimits(cfg.Stream.WSMaxConnections, cfg.Stream.WSMaxConnectionsPerIP, ws.LimitAction(cfg.Stream.WSLimitAction)),
+		ws.WithTrustedProxies(trustedProxies...),
+		ws.WithRetained(ws.NewReplayBuffer(sseStore, cfg.Stream.SSEReplaySize)),
+		ws.WithReceiptOwners(crosschainSvc.ReceiptOwner),
+	)
+	sseHandler := ws.NewSSEHandler(cache, logger,
+		ws.WithReplay(sseStore, cfg.Stream.SSEReplaySize),
+		ws.WithHeartbeat(cfg.Stream.SSEHeartbeat, cfg.Stream.SSERetry),
+		ws.WithSSEPriceSymbols(priceSymbols...),
+		ws.WithSSEReceiptOwners(crosschainSvc.ReceiptOwner),
+	)
 
 	// Create context for background services
 	hubCtx, hubCancel := context.WithCancel(context.Background())
@@ -166,11 +413,124 @@ func main() {
 
 	// Start WebSocket hub in background
 	go wsHub.Run(hubCtx)
+	go sseHandler.Run(hubCtx)
+	rpcPool.Start(hubCtx, cfg.Sui.RPCHealthInterval)
+
+	// Index protocol events for the transaction and protocol history
+	if cfg.Sui.IndexerPollInterval > 0 {
+		indexer, err := onchain.NewEventIndexer(rpcPool, db.Repository(entities.EventSchema), db.Repository(entities.IndexerCursorSchema), packageId, logger)
+		if err != nil {
+			logger.Fatalw("Failed to create event indexer", "error", err)
+		}
+		indexer.Start(hubCtx, cfg.Sui.IndexerPollInterval)
+	}
+
+	// Periodic jobs that operators can pause, resume and trigger under
+	// /v1/admin/jobs
+	scheduler := jobs.NewScheduler(cache, logger)
+
+	// Sample the protocol state for the protocol history
+	if cfg.Sui.HistorySampleInterval > 0 {
+		sampler := onchain.NewProtocolSampler(chainClient, db.Repository(entities.ProtocolSampleSchema), cfg.Sui.HistorySampleInterval, logger)
+		scheduler.Schedule(hubCtx, "protocol-sampler", "Samples the protocol state for the protocol history", cfg.Sui.HistorySampleInterval, func(ctx context.Context) error {
+			return sampler.RunOnce(ctx, time.Now())
+		})
+	}
+
+	// Push bridge receipt changes to the subscribers of each receipt, its
+	// owner, as they are committed
+	receiptChanges, err := db.Watch(hubCtx, entities.BridgeReceiptSchema, nil)
+	if err != nil {
+		logger.Fatalw("Failed to watch bridge receipts", "error", err)
+	}
+	go wsHub.ForwardChanges(hubCtx, ws.BridgeTopicOf, receiptChanges)
+	stageChanges, err := db.Watch(hubCtx, entities.ReceiptTransitionSchema, nil)
+	if err != nil {
+		logger.Fatalw("Failed to watch receipt transitions", "error", err)
+	}
+	go wsHub.ForwardChanges(hubCtx, ws.BridgeTopicOf, stageChanges)
+
+	// Post protocol events, bridge stages and alerts to webhook subscriptions
+	webhookSvc := webhooks.NewService(db, logger, webhooks.WithLock(cache), webhooks.WithPolicy(webhooks.Policy{
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		BaseDelay:   cfg.Webhooks.RetryBaseDelay,
+		MaxDelay:    cfg.Webhooks.RetryMaxDelay,
+		Timeout:     cfg.Webhooks.Timeout,
+	}))
+	webhookSvc.Start(hubCtx)
+	eventChanges, err := db.Watch(hubCtx, entities.EventSchema, nil)
+	if err != nil {
+		logger.Fatalw("Failed to watch protocol events", "error", err)
+	}
+	go webhookSvc.ForwardChanges(hubCtx, eventChanges)
+	webhookStageChanges, err := db.Watch(hubCtx, entities.ReceiptTransitionSchema, nil)
+	if err != nil {
+		logger.Fatalw("Failed to watch receipt transitions", "error", err)
+	}
+	go webhookSvc.ForwardChanges(hubCtx, webhookStageChanges)
+
 	bridgeWorker.Start(hubCtx)
+	if checkpointer != nil {
+		scheduler.Schedule(hubCtx, "bridge-checkpointer", "Checkpoints and publishes the bridge state to Walrus", checkpointer.Interval(), func(ctx context.Context) error {
+			checkpointer.RunOnce(ctx, time.Now())
+			return nil
+		})
+	}
+	if collector := crosschain.NewAttestationCollector(crosschainSvc, logger); collector != nil {
+		scheduler.Schedule(hubCtx, "attestation-collector", "Collects operator signatures of pending checkpoints", collector.Interval(), func(ctx context.Context) error {
+			collector.RunOnce(ctx)
+			return nil
+		})
+	}
+	if reconciler != nil {
+		scheduler.Schedule(hubCtx, "bridge-reconciler", "Checks vault balances, checkpoints and minted supply for drift", reconciler.Interval(), func(ctx context.Context) error {
+			reconciler.RunOnce(ctx, time.Now())
+			return nil
+		})
+	}
+
+	// Alert on collateral ratio breaches and mode changes
+	if cfg.Monitor.Interval > 0 {
+		alerters := []onchain.ProtocolAlerter{onchain.CacheAlerter{Cache: cache}, webhookSvc}
+		if cfg.Monitor.WebhookURL != "" {
+			alerters = append(alerters, &onchain.WebhookAlerter{URL: cfg.Monitor.WebhookURL, Client: &http.Client{Timeout: 10 * time.Second}})
+		}
+		var actionBuilder onchain.ProtectiveActionBuilder
+		if cfg.Monitor.BuildActions {
+			actionBuilder = txBuilder
+		}
+		monitor := onchain.NewProtocolMonitor(chainClient, actionBuilder, onchain.MonitorConfig{
+			Interval:          cfg.Monitor.Interval,
+			AlertCR:           decimal.NewFromFloat(cfg.Monitor.AlertCR),
+			PauseCR:           decimal.NewFromFloat(cfg.Monitor.PauseCR),
+			RebalanceTargetCR: decimal.NewFromFloat(cfg.Monitor.RebalanceTargetCR),
+		}, logger, alerters...)
+		scheduler.Schedule(hubCtx, "protocol-monitor", "Alerts on collateral ratio breaches and mode changes", cfg.Monitor.Interval, func(ctx context.Context) error {
+			_, err := monitor.RunOnce(ctx, time.Now())
+			return err
+		})
+	}
+
+	// Alert users on the balances, bridge receipts and peg they watch
+	watchlistSvc := watchlist.NewService(db, logger,
+		watchlist.WithBalances(userSvc),
+		watchlist.WithReceipts(crosschainSvc),
+		watchlist.WithProtocol(chainClient),
+		watchlist.WithNotifiers(watchlist.CacheNotifier{Cache: cache}, watchlist.WebhookNotifier{Webhooks: webhookSvc}),
+		watchlist.WithLock(cache),
+	)
+	if cfg.Watchlist.Interval > 0 {
+		scheduler.Schedule(hubCtx, "watchlist-evaluator", "Alerts users on changes of the items they watch", cfg.Watchlist.Interval, func(ctx context.Context) error {
+			_, err := watchlistSvc.RunOnce(ctx, time.Now())
+			return err
+		})
+	}
 
 	// Setup and start price publisher with config
 	pricePublisherConfig := jobs.PricePublisherConfig{
 		ProviderType:   cfg.Prices.Provider,
+		IngestMode:     cfg.Prices.IngestMode,
+		PollInterval:   cfg.Prices.PollInterval,
 		RetryInterval:  cfg.Prices.RetryInterval,
 		MaxTicksPerSym: 10000, // Keep fixed for now
 		TTL:            5 * time.Second,
@@ -178,10 +538,31 @@ func main() {
 		MockBasePrice:  cfg.Prices.MockBasePrice,
 	}
 
-	pricePublisher := jobs.NewPricePublisher(cache, logger, pricePublisherConfig)
+	// Roll ticks into candles kept in the database, backfilled from the
+	// provider's history
+	var candleSvc *candles.Service
+	publisherOpts := []jobs.PricePublisherOption{jobs.WithIngestRecorder(metricsObj)}
+	if priceAggregator != nil {
+		publisherOpts = append(publisherOpts, jobs.WithAggregator(priceAggregator, cfg.Prices.AggregateInterval))
+	}
+	if cfg.Prices.CandleFlushInterval > 0 {
+		candleSvc = candles.NewService(db.Repository(entities.PriceCandleSchema), logger, candles.WithBackfillLimit(cfg.Prices.CandleBackfill))
+		publisherOpts = append(publisherOpts, jobs.WithTickRecorder(candleSvc))
+		scheduler.Schedule(hubCtx, "candle-aggregator", "Writes the candles of published ticks", cfg.Prices.CandleFlushInterval, candleSvc.Flush)
+		if backfillProvider, err := providers.New(logger, cfg.Prices.GetProviders()...); err == nil && cfg.Prices.CandleBackfill > 0 {
+			go func() {
+				if err := candleSvc.Backfill(hubCtx, backfillProvider, prices.NewRegistry().GetProviderSymbols()); err != nil && hubCtx.Err() == nil {
+					logger.Warnw("Candle backfill failed", "error", err)
+				}
+			}()
+		}
+	}
+
+	pricePublisher := jobs.NewPricePublisher(cache, logger, pricePublisherConfig, publisherOpts...)
 	go func() {
 		logger.Infow("Starting price publisher",
 			"provider", cfg.Prices.Provider,
+			"mode", cfg.Prices.IngestMode,
 			"retryInterval", cfg.Prices.RetryInterval,
 		)
 		if err := pricePublisher.Start(hubCtx); err != nil && err != context.Canceled {
@@ -189,9 +570,65 @@ func main() {
 		}
 	}()
 
+	if cfg.Oracle.UpdaterMnemonic != "" {
+		updaterSigner, err := suisigner.NewSignerWithMnemonic(cfg.Oracle.UpdaterMnemonic, suicrypto.KeySchemeFlagEd25519)
+		if err != nil {
+			logger.Fatalw("Invalid oracle updater mnemonic", "error", err)
+		}
+		// Updates follow the Pyth feed when it prices them, the TWAP of the
+		// published aggregates or the published ticks otherwise
+		var updaterSource jobs.OraclePriceSource = jobs.TickPriceSource{Cache: cache, Symbol: "SUIUSDT"}
+		if priceFeed != nil {
+			updaterSource = jobs.FeedPriceSource{Feed: priceFeed}
+		} else if priceAggregator != nil {
+			updaterSource = jobs.AggregatePriceSource{Cache: cache, Symbol: "SUIUSDT"}
+		}
+		oracleUpdater := jobs.NewOracleUpdater(chainClient, txBuilder, updaterSource, updaterSigner, cache, logger, jobs.OracleUpdaterConfig{
+			Interval:     cfg.Oracle.UpdaterInterval,
+			DeviationBps: cfg.Oracle.UpdaterDeviationBps,
+			Heartbeat:    cfg.Oracle.UpdaterHeartbeat,
+			FeedPriced:   priceFeed != nil,
+		})
+		oracleUpdater.Start(hubCtx)
+		logger.Infow("Oracle updater started",
+			"signer", updaterSigner.Address,
+			"deviationBps", cfg.Oracle.UpdaterDeviationBps,
+			"heartbeat", cfg.Oracle.UpdaterHeartbeat,
+		)
+	}
+
+	// Dependency checks of /readyz
+	readiness := health.NewChecker(cfg.Ready.Timeout, cfg.Ready.Critical)
+	readiness.Register(config.ReadyCheckCache, cache.Ping)
+	readiness.Register(config.ReadyCheckDatabase, func(ctx context.Context) error {
+		if !db.IsHealthy(ctx) {
+			return fmt.Errorf("database unreachable")
+		}
+		return nil
+	})
+	readiness.Register(config.ReadyCheckSuiRPC, rpcPool.Ping)
+	if evmChecker, err := crosschain.NewEvmRPCCheckerFromEnv(); err != nil {
+		logger.Warnw("EVM RPC readiness check disabled", "error", err)
+	} else if evmChecker != nil {
+		readiness.Register(config.ReadyCheckEVMRPC, evmChecker.Ping)
+	}
+	readiness.Register(config.ReadyCheckPrices, func(ctx context.Context) error {
+		age, err := jobs.TickPriceSource{Cache: cache, Symbol: "SUIUSDT"}.Age(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if age > cfg.Ready.PriceMaxAge {
+			return fmt.Errorf("latest price is %s old", age.Truncate(time.Second))
+		}
+		return nil
+	})
+
 	// Setup API handler and middleware
-	handler := api.NewHandler(protocolSvc, quoteSvc, userSvc, spSvc, crosschainSvc, bridgeWorker, marketsSvc, wsHub, sseHandler, cache, cfg, logger, metricsObj, txBuilder, txBuilder)
-	middleware := api.NewMiddleware(logger, metricsObj)
+	handler := api.NewHandler(protocolSvc, quoteSvc, userSvc, spSvc, crosschainSvc, bridgeWorker, marketsSvc, wsHub, sseHandler, cache, cfg, logger, metricsObj, txBuilder, txBuilder, onchain.NewTransactionStatusService(rpcPool, cache, logger), apikeys.NewService(db), webhookSvc, scheduler, readiness, candleSvc, watchlistSvc)
+	middleware := api.NewMiddleware(logger, metricsObj,
+		api.WithMaxBodyBytes(cfg.Security.MaxBodyBytes),
+		api.WithCompressMinBytes(cfg.Security.CompressMinBytes),
+	)
 
 	// Create router with middleware and routes - pass security config to Routes
 	router := handler.Routes(middleware, cfg.Security.CORSAllowedOrigins, cfg.Security.RateLimitRPM)
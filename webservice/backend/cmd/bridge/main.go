@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	gdb "github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/log"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/leafsii/leafsii-backend/internal/prices/providers"
+)
+
+const usage = `Usage: bridge COMMAND [options]
+
+Commands:
+  replay  submit the vault deposits of a block range that the bridge missed
+
+Run "bridge COMMAND -h" for the options of a command.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "replay":
+		replay(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// replay scans a chain's vaults from a block height and submits the deposits
+// without a minted or pending receipt through the bridge worker. Run it while
+// the API server is stopped, as the server keeps bridge state in memory.
+func replay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	chain := flags.String("chain", string(crosschain.ChainIDEthereum), "chain whose vaults are scanned")
+	fromBlock := flags.Uint64("from-block", 0, "first block scanned")
+	toBlock := flags.Uint64("to-block", 0, "last block scanned; defaults to the latest confirmed block")
+	flags.Parse(args)
+
+	if *fromBlock == 0 {
+		fmt.Fprintln(os.Stderr, "replay requires -from-block")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	logger, err := log.NewSugar(cfg.Env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db := gdb.MustNewDatabase(nil)
+	initCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := gdb.ConnectAndMigrate(initCtx, db, gdb.AllSchemas()); err != nil {
+		logger.Fatalw("Failed to initialize database", "error", err)
+	}
+	defer db.Disconnect(context.Background())
+
+	attestation, err := crosschain.NewAttestationConfigFromEnv()
+	if err != nil {
+		logger.Fatalw("Invalid checkpoint attestation config", "error", err)
+	}
+	svc := crosschain.NewService(logger, crosschain.WithDatabase(db), crosschain.WithAttestation(attestation))
+	if err := svc.Load(initCtx); err != nil {
+		logger.Fatalw("Failed to load cross-chain state", "error", err)
+	}
+
+	// Deposits are minted one at a time, so that none is left in a batch
+	// when the replay exits
+	var priceAggregator *prices.Aggregator
+	if names := cfg.Prices.GetAggregateProviders(); len(names) > 0 {
+		priceAggregator, err = providers.NewAggregator(logger, names,
+			prices.WithMaxDeviationBps(cfg.Prices.AggregateMaxDeviationBps),
+			prices.WithMinSources(cfg.Prices.AggregateMinSources),
+			prices.WithAggregateTTL(cfg.Prices.AggregateInterval),
+		)
+		if err != nil {
+			logger.Fatalw("Invalid price aggregate providers", "error", err)
+		}
+	}
+	priceSource, err := crosschain.NewPriceSourceFromEnv(priceAggregator, nil, logger)
+	if err != nil {
+		logger.Fatalw("Invalid bridge price config", "error", err)
+	}
+	opts := []crosschain.BridgeWorkerOption{crosschain.WithPriceSource(priceSource)}
+	if minter, err := crosschain.NewSuiBridgeMinterFromEnv(logger); err != nil {
+		logger.Fatalw("Invalid bridge mint config", "error", err)
+	} else if minter != nil {
+		opts = append(opts, crosschain.WithMintHandler(minter))
+	}
+	if publisher, err := crosschain.NewHTTPWalrusPublisherFromEnv(crosschain.NewWalrusReaderFromEnv(), nil); err != nil {
+		logger.Warnw("Walrus publishing disabled", "error", err)
+	} else if publisher != nil {
+		opts = append(opts, crosschain.WithWalrusPublisher(publisher))
+	}
+
+	listener, err := crosschain.NewEthDepositListenerFromEnv(crosschain.ChainID(strings.ToLower(*chain)), logger)
+	if err != nil {
+		logger.Fatalw("Invalid deposit listener config", "error", err)
+	}
+
+	worker := crosschain.NewBridgeWorker(svc, logger, opts...)
+	worker.Start(ctx)
+
+	stats, err := worker.ReplayDeposits(ctx, listener, *fromBlock, *toBlock)
+	if stats != nil {
+		out, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Println(string(out))
+	}
+	if err != nil {
+		if stats != nil {
+			logger.Fatalw("Replay stopped", "error", err, "resumeFromBlock", stats.NextBlock)
+		}
+		logger.Fatalw("Replay failed", "error", err)
+	}
+}
@@ -159,6 +159,30 @@ func main() {
 		}
 	}
 
+	fmt.Println("\n--- Relations Example ---")
+
+	// Typed repositories map rows, including loaded relations, to structs
+	type author struct {
+		Name  string          `db:"name"`
+		Posts []entities.Post `db:"posts"`
+	}
+	authors, err := db.MustNewTypedRepository[author](database, entities.UserSchema).FindMany(ctx, &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{
+			{Field: "name", Direction: "asc"},
+		},
+		Include: []string{"posts"},
+	})
+	if err != nil {
+		log.Fatalf("Failed to load users with posts: %v", err)
+	}
+
+	for _, user := range authors.Data {
+		fmt.Printf("%s has %d posts\n", user.Name, len(user.Posts))
+		for _, post := range user.Posts {
+			fmt.Printf("  - %s\n", post.Title)
+		}
+	}
+
 	fmt.Println("\n--- Transaction Example ---")
 
 	// Successful transaction
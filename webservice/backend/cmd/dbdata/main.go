@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	gdb "github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+var (
+	flags     = flag.NewFlagSet("dbdata", flag.ExitOnError)
+	dbType    = flags.String("type", "", "database type: memory, postgres or sqlite (default $DB_TYPE)")
+	dsn       = flags.String("dsn", "", "database DSN (default $DB_DSN)")
+	file      = flags.String("file", "-", "NDJSON file to write or read, - for stdout/stdin")
+	tables    = flags.String("tables", "", "comma-separated tables to export or import (default all)")
+	batchSize = flags.Int("batch", 0, "records per query or insert (default 500)")
+)
+
+func main() {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: dbdata export|import [options]\n\nCopies records between databases as newline-delimited JSON, referenced tables first.\nImport migrates the schemas and creates every record in one transaction.\n\n")
+		flags.PrintDefaults()
+	}
+	if len(os.Args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flags.Parse(os.Args[2:])
+
+	schemas, err := selectSchemas(*tables)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := gdb.NewDatabase(&gdb.Config{Type: *dbType, DSN: *dsn})
+	if err != nil {
+		log.Fatalf("Failed to create database: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch command {
+	case "export":
+		if err := db.Connect(ctx); err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer db.Disconnect(ctx)
+
+		out, closeOut, err := openOutput(*file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *file, err)
+		}
+		w := bufio.NewWriter(out)
+		if err := db.Export(ctx, w, interfaces.ExportOptions{Schemas: schemas, BatchSize: *batchSize}); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			log.Fatalf("Failed to write %s: %v", *file, err)
+		}
+		if err := closeOut(); err != nil {
+			log.Fatalf("Failed to write %s: %v", *file, err)
+		}
+		log.Printf("Exported %d tables", len(schemas))
+	case "import":
+		if err := gdb.ConnectAndMigrate(ctx, db, gdb.AllSchemas()); err != nil {
+			log.Fatalf("Failed to initialize database: %v", err)
+		}
+		defer db.Disconnect(ctx)
+
+		in := io.Reader(os.Stdin)
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				log.Fatalf("Failed to open %s: %v", *file, err)
+			}
+			defer f.Close()
+			in = f
+		}
+		if err := db.Import(ctx, bufio.NewReader(in), interfaces.ImportOptions{Schemas: schemas, BatchSize: *batchSize}); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		log.Printf("Imported %d tables", len(schemas))
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// selectSchemas returns the schemas of the named tables, or all of them
+func selectSchemas(names string) ([]*interfaces.Schema, error) {
+	all := gdb.AllSchemas()
+	if names == "" {
+		return all, nil
+	}
+
+	byTable := make(map[string]*interfaces.Schema, len(all))
+	for _, schema := range all {
+		byTable[schema.TableName] = schema
+	}
+	var schemas []*interfaces.Schema
+	for _, name := range strings.Split(names, ",") {
+		schema, exists := byTable[strings.TrimSpace(name)]
+		if !exists {
+			return nil, fmt.Errorf("unknown table %q", name)
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// openOutput opens the export destination
+func openOutput(path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
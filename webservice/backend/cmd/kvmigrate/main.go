@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"github.com/leafsii/leafsii-backend/pkg/kv/redis"
+)
+
+var (
+	flags     = flag.NewFlagSet("kvmigrate", flag.ExitOnError)
+	srcFlag   = flags.String("src", "", "source: redis://host:port/db or memory:/path/to/snapshot.json")
+	dstFlag   = flags.String("dst", "", "destination: redis://host:port/db or memory:/path/to/snapshot.json")
+	pattern   = flags.String("pattern", "*", "glob pattern selecting keys to copy")
+	batchSize = flags.Int64("batch", 100, "keys scanned per batch")
+	cursor    = flags.Uint64("cursor", 0, "resume from a cursor reported by a previous run")
+	overwrite = flags.Bool("overwrite", false, "replace keys that already exist in the destination")
+)
+
+const memoryScheme = "memory:"
+
+func main() {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: kvmigrate -src SOURCE -dst DEST [options]\n\nCopies keys between kv backends, preserving types and TTLs.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if *srcFlag == "" || *dstFlag == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	src, err := openStore(*srcFlag, true)
+	if err != nil {
+		log.Fatalf("Failed to open source: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := openStore(*dstFlag, false)
+	if err != nil {
+		log.Fatalf("Failed to open destination: %v", err)
+	}
+	defer dst.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	progress, err := kv.Migrate(ctx, src, dst, kv.MigrateOptions{
+		Pattern:   *pattern,
+		BatchSize: *batchSize,
+		Cursor:    *cursor,
+		Overwrite: *overwrite,
+		Progress: func(p kv.MigrateProgress) {
+			log.Printf("scanned=%d copied=%d skipped=%d cursor=%d", p.Scanned, p.Copied, p.Skipped, p.Cursor)
+		},
+	})
+	if err != nil {
+		log.Fatalf("Migration stopped: %v (resume with -cursor %d)", err, progress.Cursor)
+	}
+
+	if err := saveStore(*dstFlag, dst); err != nil {
+		log.Fatalf("Failed to write destination snapshot: %v", err)
+	}
+
+	log.Printf("Migration complete: scanned=%d copied=%d skipped=%d", progress.Scanned, progress.Copied, progress.Skipped)
+}
+
+// openStore opens a Redis store or a memory store backed by a snapshot file.
+// A missing snapshot file is only accepted for the destination.
+func openStore(target string, isSource bool) (kv.Store, error) {
+	path, isMemory := strings.CutPrefix(target, memoryScheme)
+	if !isMemory {
+		return redis.New(target)
+	}
+
+	store := memory.New(0)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && !isSource {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := store.ReadSnapshot(f); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// saveStore persists a memory destination back to its snapshot file
+func saveStore(target string, store kv.Store) error {
+	path, isMemory := strings.CutPrefix(target, memoryScheme)
+	if !isMemory {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := store.(*memory.Store).WriteSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
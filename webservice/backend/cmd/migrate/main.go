@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/leafsii/leafsii-backend/internal/config"
+	gdb "github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/backends/postgres"
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
 	"github.com/pressly/goose/v3"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -14,14 +23,24 @@ import (
 var (
 	flags = flag.NewFlagSet("migrate", flag.ExitOnError)
 	dir   = flags.String("dir", "sql", "directory with migration files")
+	name  = flags.String("name", "schema_diff", "name of the migration file written by apply")
 )
 
+const usage = `Usage: migrate [options] COMMAND
+
+Commands:
+  up      apply pending migrations
+  down    roll back the last migration
+  status  list migrations and whether they are applied
+  plan    print the migration bringing the database in line with the entity schemas
+  apply   write that migration to the migration directory and apply it`
+
 func main() {
-	flags.Parse(flag.Args())
+	flags.Parse(os.Args[1:])
 	args := flags.Args()
 
 	if len(args) < 1 {
-		log.Fatal("Usage: migrate COMMAND\n\nCommands:\n  up\n  down\n  status")
+		log.Fatal(usage)
 	}
 
 	cfg, err := config.Load()
@@ -53,7 +72,89 @@ func main() {
 		if err := goose.Status(db, *dir); err != nil {
 			log.Fatalf("Migration status failed: %v", err)
 		}
+	case "plan":
+		m, err := plan(cfg.Database.PostgresDSN)
+		if err != nil {
+			log.Fatalf("Migration plan failed: %v", err)
+		}
+		fmt.Print(migrationFile(m))
+	case "apply":
+		version, err := nextVersion(db, *dir)
+		if err != nil {
+			log.Fatalf("Migration apply failed: %v", err)
+		}
+		m, err := plan(cfg.Database.PostgresDSN)
+		if err != nil {
+			log.Fatalf("Migration plan failed: %v", err)
+		}
+		if m.Empty() {
+			log.Print("Database already matches the entity schemas")
+			return
+		}
+
+		path := filepath.Join(*dir, fmt.Sprintf("%03d_%s.sql", version, *name))
+		if err := os.WriteFile(path, []byte(migrationFile(m)), 0o644); err != nil {
+			log.Fatalf("Failed to write migration: %v", err)
+		}
+		log.Printf("Wrote %s", path)
+		if err := goose.Up(db, *dir); err != nil {
+			log.Fatalf("Migration up failed: %v", err)
+		}
 	default:
-		log.Fatalf("Unknown command: %s", command)
+		log.Fatalf("Unknown command: %s\n\n%s", command, usage)
+	}
+}
+
+// plan diffs the database at dsn against the entity schemas
+func plan(dsn string) (*sqlgen.Migration, error) {
+	ctx := context.Background()
+	database := postgres.NewDatabase(dsn, postgres.Options{})
+	if err := database.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer database.Disconnect(ctx)
+
+	return database.Plan(ctx, gdb.AllSchemas())
+}
+
+// nextVersion returns the version of the next migration file in dir. The
+// database must be at the latest version already, as the plan is made
+// against its current tables.
+func nextVersion(db *sql.DB, dir string) (int64, error) {
+	migrations, err := goose.CollectMigrations(dir, 0, goose.MaxVersion)
+	if err != nil {
+		return 0, err
+	}
+	last, err := migrations.Last()
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		return 1, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	current, err := goose.GetDBVersion(db)
+	if err != nil {
+		return 0, err
+	}
+	if current != last.Version {
+		return 0, fmt.Errorf("database is at version %d but %s has migrations up to %d; run migrate up first", current, dir, last.Version)
+	}
+	return last.Version + 1, nil
+}
+
+// migrationFile renders m as a goose SQL migration
+func migrationFile(m *sqlgen.Migration) string {
+	var b strings.Builder
+	b.WriteString("-- +goose Up\n")
+	for _, note := range m.Notes {
+		b.WriteString("-- " + note + "\n")
+	}
+	for _, stmt := range m.Up {
+		b.WriteString(stmt + ";\n")
+	}
+	b.WriteString("\n-- +goose Down\n")
+	for _, stmt := range m.Down {
+		b.WriteString(stmt + ";\n")
 	}
+	return b.String()
 }
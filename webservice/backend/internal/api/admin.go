@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/jobs"
+)
+
+// GetAdminConfig returns the effective configuration, secrets redacted
+func (h *Handler) GetAdminConfig(w http.ResponseWriter, r *http.Request) {
+	if h.config == nil {
+		h.writeError(w, http.StatusInternalServerError, "CONFIG_ERROR", "Configuration not loaded")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, AdminConfigResponse{Config: h.config.Sanitized()})
+}
+
+// FlushCache deletes the cache keys under a prefix, so that the next reads
+// go to the chain and database. Prefixes start with fx:, which keeps the
+// flush to this service's keys.
+func (h *Handler) FlushCache(w http.ResponseWriter, r *http.Request) {
+	var req CacheFlushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid cache flush payload")
+		return
+	}
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+	if !strings.HasPrefix(req.Prefix, "fx:") || strings.ContainsAny(req.Prefix, "*?[]\\") {
+		h.writeError(w, http.StatusBadRequest, "INVALID_CACHE_PREFIX", "Cache prefixes start with fx: and contain no glob characters")
+		return
+	}
+	if h.cache == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "CACHE_UNAVAILABLE", "Cache is not configured")
+		return
+	}
+
+	deleted, err := h.cache.DeletePrefix(r.Context(), req.Prefix)
+	if err != nil {
+		h.writeErrorFor(w, err, "CACHE_FLUSH_ERROR")
+		return
+	}
+
+	h.logger.Warnw("Cache flushed", "prefix", req.Prefix, "deleted", deleted)
+	h.writeJSON(w, http.StatusOK, CacheFlushResponse{Prefix: req.Prefix, Deleted: deleted})
+}
+
+// ListJobs lists the periodic jobs with their state and last run
+func (h *Handler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsEnabled(w) {
+		return
+	}
+	h.writeJSON(w, http.StatusOK, JobListResponse{Jobs: h.scheduler.Jobs(r.Context())})
+}
+
+// PauseJob makes a job skip its runs, on every replica, until resumed
+func (h *Handler) PauseJob(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsEnabled(w) {
+		return
+	}
+	job, err := h.scheduler.Pause(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		h.writeJobError(w, err)
+		return
+	}
+
+	h.logger.Warnw("Job paused", "job", job.Name)
+	h.writeJSON(w, http.StatusOK, JobResponse{Job: job})
+}
+
+// ResumeJob lets a paused job run again
+func (h *Handler) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsEnabled(w) {
+		return
+	}
+	job, err := h.scheduler.Resume(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		h.writeJobError(w, err)
+		return
+	}
+
+	h.logger.Warnw("Job resumed", "job", job.Name)
+	h.writeJSON(w, http.StatusOK, JobResponse{Job: job})
+}
+
+// TriggerJob runs a job on this replica now, even when paused
+func (h *Handler) TriggerJob(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsEnabled(w) {
+		return
+	}
+	job, err := h.scheduler.Trigger(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		h.writeJobError(w, err)
+		return
+	}
+
+	h.logger.Infow("Job triggered", "job", job.Name)
+	h.writeJSON(w, http.StatusAccepted, JobResponse{Job: job})
+}
+
+// GetConnectionStats counts the WebSocket clients and SSE streams connected
+// to this replica
+func (h *Handler) GetConnectionStats(w http.ResponseWriter, r *http.Request) {
+	var stats ConnectionStatsResponse
+	if h.wsHub != nil {
+		stats.WebSocketClients = h.wsHub.ClientCount()
+	}
+	if h.sseHandler != nil {
+		stats.SSEStreams = h.sseHandler.Streams()
+	}
+	h.writeJSON(w, http.StatusOK, stats)
+}
+
+func (h *Handler) jobsEnabled(w http.ResponseWriter) bool {
+	if h.scheduler == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "JOBS_UNAVAILABLE", "Job control is not configured")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) writeJobError(w http.ResponseWriter, err error) {
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		h.writeError(w, http.StatusNotFound, "JOB_NOT_FOUND", err.Error())
+		return
+	}
+	h.writeErrorFor(w, err, "JOB_ERROR")
+}
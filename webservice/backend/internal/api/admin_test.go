@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/jobs"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAdminOperations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := zap.NewNop().Sugar()
+	cache, err := store.NewCache("invalid:6379", logger, nil)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	scheduler := jobs.NewScheduler(cache, logger)
+	scheduler.Schedule(ctx, "protocol-sampler", "Samples", time.Hour, func(context.Context) error { return nil })
+	cfg := &config.Config{
+		Env:      "test",
+		Database: config.DBConfig{PostgresDSN: "postgres://leafsii:hunter2@db:5432/leafsii?sslmode=disable"},
+		Security: config.SecurityConfig{AdminToken: "admin-secret"},
+		Webhooks: config.WebhookConfig{Timeout: 10 * time.Second},
+	}
+	h := &Handler{logger: logger, metrics: &MockMetrics{}, cache: cache, config: cfg, scheduler: scheduler}
+	admin := chi.NewRouter()
+	admin.Get("/config", h.GetAdminConfig)
+	admin.Post("/cache/flush", h.FlushCache)
+	admin.Get("/jobs", h.ListJobs)
+	admin.Post("/jobs/{name}/pause", h.PauseJob)
+	admin.Post("/jobs/{name}/trigger", h.TriggerJob)
+	admin.Get("/connections", h.GetConnectionStats)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/config", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "hunter2")
+	assert.NotContains(t, rec.Body.String(), "admin-secret")
+	var settings AdminConfigResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &settings))
+	assert.Equal(t, "test", settings.Config["LFS_ENV"])
+	assert.Equal(t, "postgres://db:5432/leafsii", settings.Config["LFS_POSTGRES_DSN"])
+	assert.Equal(t, "[REDACTED]", settings.Config["LFS_ADMIN_TOKEN"])
+	assert.Equal(t, "", settings.Config["LFS_SUI_SPONSOR_MNEMONIC"])
+	assert.Equal(t, "10s", settings.Config["LFS_WEBHOOK_TIMEOUT"])
+
+	for _, body := range []string{`{}`, `{"prefix":"other:"}`, `{"prefix":"fx:*"}`} {
+		rec := serve(http.MethodPost, "/cache/flush", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), "INVALID_CACHE_PREFIX", body)
+	}
+	require.NoError(t, cache.Set(ctx, "fx:response:a", 1, time.Minute))
+	require.NoError(t, cache.Set(ctx, store.KeyProtocolState, 1, time.Minute))
+	rec = serve(http.MethodPost, "/cache/flush", `{"prefix":"fx:response:"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"prefix":"fx:response:","deleted":1}`, rec.Body.String())
+	exists, err := cache.Exists(ctx, store.KeyProtocolState)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	rec = serve(http.MethodPost, "/jobs/protocol-sampler/pause", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(http.MethodGet, "/jobs", "")
+	var list JobListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Jobs, 1)
+	assert.True(t, list.Jobs[0].Paused)
+	rec = serve(http.MethodPost, "/jobs/protocol-sampler/trigger", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = serve(http.MethodPost, "/jobs/unknown/trigger", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "JOB_NOT_FOUND")
+
+	rec = serve(http.MethodGet, "/connections", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"websocketClients":0,"sseStreams":0}`, rec.Body.String())
+
+	// Without a scheduler job control is unavailable
+	rec = httptest.NewRecorder()
+	(&Handler{logger: logger, metrics: &MockMetrics{}}).ListJobs(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/apikeys"
+)
+
+// DefaultAPIKeyRateLimitRPM is the rate of keys without their own when the
+// config sets none
+const DefaultAPIKeyRateLimitRPM = 600
+
+// ListAPIKeys lists the API keys, revoked ones included
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysEnabled(w) {
+		return
+	}
+	keys, err := h.apiKeys.List(r.Context())
+	if err != nil {
+		h.writeErrorFor(w, err, "API_KEY_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, APIKeyListResponse{Keys: keys})
+}
+
+// CreateAPIKey issues a key; the response is the only one showing it
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysEnabled(w) {
+		return
+	}
+	var req APIKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid API key payload")
+		return
+	}
+
+	key, secret, err := h.apiKeys.Create(r.Context(), req.Name, req.Scopes, req.RateLimitRPM)
+	if err != nil {
+		h.writeAPIKeyError(w, err)
+		return
+	}
+
+	h.logger.Infow("API key created", "id", key.ID, "name", key.Name, "scopes", key.Scopes)
+	h.writeJSON(w, http.StatusCreated, APIKeyResponse{Key: key, Secret: secret})
+}
+
+// RotateAPIKey replaces the secret of a key; the previous one stops working
+func (h *Handler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysEnabled(w) {
+		return
+	}
+	key, secret, err := h.apiKeys.Rotate(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeAPIKeyError(w, err)
+		return
+	}
+
+	h.logger.Infow("API key rotated", "id", key.ID, "name", key.Name)
+	h.writeJSON(w, http.StatusOK, APIKeyResponse{Key: key, Secret: secret})
+}
+
+// RevokeAPIKey disables a key for good
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysEnabled(w) {
+		return
+	}
+	key, err := h.apiKeys.Revoke(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeAPIKeyError(w, err)
+		return
+	}
+
+	h.logger.Warnw("API key revoked", "id", key.ID, "name", key.Name)
+	h.writeJSON(w, http.StatusOK, APIKeyResponse{Key: key})
+}
+
+func (h *Handler) apiKeysEnabled(w http.ResponseWriter) bool {
+	if h.apiKeys == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "API_KEYS_UNAVAILABLE", "API keys are not configured")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) writeAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, apikeys.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "API_KEY_NOT_FOUND", err.Error())
+	case errors.Is(err, apikeys.ErrInvalidRequest):
+		h.writeError(w, http.StatusBadRequest, "INVALID_API_KEY_REQUEST", err.Error())
+	case errors.Is(err, apikeys.ErrInvalidKey):
+		h.writeError(w, http.StatusConflict, "API_KEY_REVOKED", err.Error())
+	default:
+		h.writeErrorFor(w, err, "API_KEY_ERROR")
+	}
+}
+
+// apiKeyRateLimitRPM returns the rate of keys without their own
+func (h *Handler) apiKeyRateLimitRPM() int {
+	if h.config != nil && h.config.Security.APIKeyRateLimitRPM > 0 {
+		return h.config.Security.APIKeyRateLimitRPM
+	}
+	return DefaultAPIKeyRateLimitRPM
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/apikeys"
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+
+	logger := zap.NewNop().Sugar()
+	h := &Handler{logger: logger, metrics: &MockMetrics{}, apiKeys: apikeys.NewService(database)}
+	admin := chi.NewRouter()
+	admin.Post("/api-keys", h.CreateAPIKey)
+	admin.Post("/api-keys/{id}/rotate", h.RotateAPIKey)
+	admin.Post("/api-keys/{id}/revoke", h.RevokeAPIKey)
+	create := func(body string) APIKeyResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewBufferString(body)))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var resp APIKeyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+	reader := create(`{"name":"dashboard","scopes":["read"]}`)
+	// 6 rpm allows a burst of one request
+	builder := create(`{"name":"market maker","scopes":["tx:build"],"rateLimitRpm":6}`)
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewBufferString(`{"name":"x","scopes":["admin"]}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// The shared limit of 6 rpm only applies to requests without a key
+	m := NewMiddleware(logger, nil)
+	var seen []string
+	handler := m.APIKeyAuth(h.apiKeys, 600)(m.RateLimit(6)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := apiKeyFrom(r.Context()); key != nil {
+			seen = append(seen, key.Name)
+		}
+	})))
+	for _, tt := range []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		status int
+	}{
+		{name: "no key", method: http.MethodGet, status: http.StatusOK},
+		{name: "unknown key", method: http.MethodGet, key: "lfs_nope_nope", status: http.StatusUnauthorized},
+		{name: "read", method: http.MethodGet, key: reader.Secret, status: http.StatusOK},
+		{name: "read builds", method: http.MethodPost, key: reader.Secret, status: http.StatusForbidden},
+		// POST routes that only read take read keys
+		{name: "read simulates", method: http.MethodPost, path: "/v1/simulate", key: reader.Secret, status: http.StatusOK},
+		{name: "read calls JSON-RPC", method: http.MethodPost, path: "/v1/jsonrpc", key: reader.Secret, status: http.StatusOK},
+		{name: "read again", method: http.MethodGet, key: reader.Secret, status: http.StatusOK},
+		{name: "build", method: http.MethodPost, key: builder.Secret, status: http.StatusOK},
+		{name: "build over its limit", method: http.MethodPost, key: builder.Secret, status: http.StatusTooManyRequests},
+		{name: "no key over the shared limit", method: http.MethodGet, status: http.StatusTooManyRequests},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if path == "" {
+				path = "/"
+			}
+			req := httptest.NewRequest(tt.method, path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	assert.Equal(t, []string{"dashboard", "dashboard", "dashboard", "dashboard", "market maker"}, seen)
+
+	// JSON-RPC methods that build or submit need tx:build
+	for _, tt := range []struct {
+		key       *apikeys.Key
+		method    string
+		forbidden bool
+	}{
+		{key: reader.Key, method: "getUnsignedTransaction", forbidden: true},
+		{key: reader.Key, method: "submitSignedTransaction", forbidden: true},
+		{key: reader.Key, method: "getQuote"},
+		{key: reader.Key, method: "getProtocolState"},
+		{key: builder.Key, method: "getUnsignedTransaction"},
+	} {
+		keyed := context.WithValue(ctx, apiKeyContextKey{}, tt.key)
+		resp := h.callJSONRPC(keyed, &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: tt.method})
+		forbidden := resp.Error != nil && resp.Error.Code == JSONRPCForbidden
+		assert.Equal(t, tt.forbidden, forbidden, "%s with %v: %+v", tt.method, tt.key.Scopes, resp.Error)
+	}
+
+	// Rotated and revoked secrets stop working
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api-keys/"+reader.Key.ID+"/rotate", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var rotated APIKeyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rotated))
+	assert.NotEqual(t, reader.Secret, rotated.Secret)
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api-keys/"+reader.Key.ID+"/revoke", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	for _, secret := range []string{reader.Secret, rotated.Secret} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", secret)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api-keys/missing/revoke", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
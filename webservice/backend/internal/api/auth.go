@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/leafsii/leafsii-backend/internal/ws"
+)
+
+// Sign-In-With-Sui: a wallet proves it holds the key of an address by
+// signing a one-time challenge with signPersonalMessage, and gets a
+// session token that WalletAuth checks user and bridge routes against.
+
+const (
+	DefaultAuthChallengeTTL = 5 * time.Minute
+	DefaultAuthSessionTTL   = 24 * time.Hour
+)
+
+// signInMessage is the challenge text, after EIP-4361: the host, address,
+// nonce, issue and expiration times
+const signInMessage = `%s wants you to sign in with your Sui account:
+%s
+
+Sign in to Leafsii.
+
+Nonce: %s
+Issued At: %s
+Expiration Time: %s`
+
+// errNoSession is returned for unknown or expired session tokens
+var errNoSession = errors.New("unknown or expired session")
+
+// sessionAddressKey is the context key of the address of the session
+type sessionAddressKey struct{}
+
+type authChallenge struct {
+	Message string `json:"message"`
+}
+
+type authSession struct {
+	Address   string    `json:"address"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CreateAuthChallenge issues the sign-in message of an address. A new
+// challenge replaces the pending one.
+func (h *Handler) CreateAuthChallenge(w http.ResponseWriter, r *http.Request) {
+	if h.cache == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", "sessions need the cache")
+		return
+	}
+	var req AuthChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid challenge payload")
+		return
+	}
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+	address, err := onchain.NormalizeAddress(req.Address)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_ADDRESS", "invalid address format")
+		return
+	}
+
+	ttl := h.authChallengeTTL()
+	now := time.Now().UTC().Truncate(time.Second)
+	nonce := randomToken(16)
+	message := fmt.Sprintf(signInMessage, r.Host, address, nonce,
+		now.Format(time.RFC3339), now.Add(ttl).Format(time.RFC3339))
+	if err := h.cache.SetAuthChallenge(r.Context(), address, authChallenge{Message: message}, ttl); err != nil {
+		h.writeError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, AuthChallengeResponse{
+		Address:   address,
+		Message:   message,
+		Nonce:     nonce,
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+}
+
+// VerifyAuthChallenge checks the signature of the pending challenge of an
+// address and opens a session for it. Challenges are answered once,
+// whether the signature holds or not.
+func (h *Handler) VerifyAuthChallenge(w http.ResponseWriter, r *http.Request) {
+	if h.cache == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", "sessions need the cache")
+		return
+	}
+	var req AuthVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid verify payload")
+		return
+	}
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+	address, err := onchain.NormalizeAddress(req.Address)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_ADDRESS", "invalid address format")
+		return
+	}
+
+	var challenge authChallenge
+	if err := h.cache.TakeAuthChallenge(r.Context(), address, &challenge); err != nil {
+		if errors.Is(err, store.ErrCacheMiss) {
+			h.writeError(w, http.StatusUnauthorized, "CHALLENGE_EXPIRED", "no pending challenge for address")
+			return
+		}
+		h.writeError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", err.Error())
+		return
+	}
+	signer, err := onchain.VerifyPersonalMessage([]byte(challenge.Message), req.Signature)
+	if err != nil {
+		h.writeError(w, http.StatusUnauthorized, "INVALID_SIGNATURE", err.Error())
+		return
+	}
+	if signer != address {
+		h.writeError(w, http.StatusUnauthorized, "INVALID_SIGNATURE", "challenge was signed by "+signer)
+		return
+	}
+
+	ttl := h.authSessionTTL()
+	token := randomToken(32)
+	session := authSession{Address: address, ExpiresAt: time.Now().Add(ttl)}
+	if err := h.cache.SetAuthSession(r.Context(), hashToken(token), session, ttl); err != nil {
+		h.writeError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", err.Error())
+		return
+	}
+
+	h.logger.Infow("Wallet signed in", "address", address)
+	h.writeJSON(w, http.StatusOK, AuthSessionResponse{
+		Token:     token,
+		Address:   address,
+		ExpiresAt: session.ExpiresAt.Unix(),
+	})
+}
+
+// sessionAddress returns the address of the session of token
+func (h *Handler) sessionAddress(ctx context.Context, token string) (string, error) {
+	if h.cache == nil {
+		return "", errNoSession
+	}
+	var session authSession
+	if err := h.cache.GetAuthSession(ctx, hashToken(token), &session); err != nil {
+		if errors.Is(err, store.ErrCacheMiss) {
+			return "", errNoSession
+		}
+		return "", err
+	}
+	return session.Address, nil
+}
+
+// streamSession resolves the session token of a live update connection,
+// whose user topic it may then subscribe to. Browsers cannot set headers
+// on WebSocket and EventSource requests, so the token may be passed as the
+// token query parameter instead of a bearer token.
+func (h *Handler) streamSession(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return r, true
+	}
+	address, err := h.sessionAddress(r.Context(), token)
+	if errors.Is(err, errNoSession) {
+		h.writeError(w, http.StatusUnauthorized, "INVALID_SESSION", "Unknown or expired session")
+		return nil, false
+	}
+	if err != nil {
+		h.writeError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", "Sessions are unavailable")
+		return nil, false
+	}
+	return r.WithContext(ws.WithSession(r.Context(), address)), true
+}
+
+// authorizeOwner checks that the session of r, if any, is that of owner,
+// as bridge submissions name theirs in the body. WalletAuth has rejected
+// requests without one when sessions are required.
+func (h *Handler) authorizeOwner(w http.ResponseWriter, r *http.Request, owner string) bool {
+	address, ok := r.Context().Value(sessionAddressKey{}).(string)
+	if !ok {
+		return true
+	}
+	if normalized, err := onchain.NormalizeAddress(owner); err != nil || normalized != address {
+		h.writeError(w, http.StatusForbidden, "FORBIDDEN", "suiOwner is not the signed-in address")
+		return false
+	}
+	return true
+}
+
+// authRequired reports whether user, build and bridge routes need a
+// session. They do unless the configuration opts out, as dev may.
+func (h *Handler) authRequired() bool {
+	return h.config == nil || h.config.Security.AuthRequired
+}
+
+func (h *Handler) authChallengeTTL() time.Duration {
+	if h.config != nil && h.config.Security.AuthChallengeTTL > 0 {
+		return h.config.Security.AuthChallengeTTL
+	}
+	return DefaultAuthChallengeTTL
+}
+
+func (h *Handler) authSessionTTL() time.Duration {
+	if h.config != nil && h.config.Security.AuthSessionTTL > 0 {
+		return h.config.Security.AuthSessionTTL
+	}
+	return DefaultAuthSessionTTL
+}
+
+func randomToken(size int) string {
+	bytes := make([]byte, size)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// hashToken keys sessions, so that the cache does not hold usable tokens
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/leafsii/leafsii-backend/internal/ws"
+	"github.com/pattonkan/sui-go/suisigner"
+	"github.com/pattonkan/sui-go/suisigner/suicrypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func signPersonalMessage(t *testing.T, signer *suisigner.Signer, message string) string {
+	t.Helper()
+	encoded, err := bcs.Marshal([]byte(message))
+	require.NoError(t, err)
+	sig, err := signer.SignDigest(encoded, suisigner.IntentPersonalMessage())
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(sig.Bytes())
+}
+
+func TestWalletSignIn(t *testing.T) {
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	logger := zap.NewNop().Sugar()
+	h := &Handler{
+		cache:   cache,
+		config:  &config.Config{Security: config.SecurityConfig{AuthRequired: true}},
+		logger:  logger,
+		metrics: &MockMetrics{},
+	}
+	router := chi.NewRouter()
+	router.Post("/v1/auth/challenge", h.CreateAuthChallenge)
+	router.Post("/v1/auth/verify", h.VerifyAuthChallenge)
+	signer := suisigner.NewSigner(suisigner.TEST_SEED, suicrypto.KeySchemeFlagEd25519)
+	other := suisigner.NewSigner(suisigner.TEST_SEED, suicrypto.KeySchemeFlagSecp256k1)
+	address := signer.Address.String()
+
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload)))
+		return rec
+	}
+	challenge := func() AuthChallengeResponse {
+		rec := post("/v1/auth/challenge", AuthChallengeRequest{Address: address})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp AuthChallengeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// Signed by another key
+	ch := challenge()
+	assert.Contains(t, ch.Message, address)
+	assert.Contains(t, ch.Message, "Nonce: "+ch.Nonce)
+	rec := post("/v1/auth/verify", AuthVerifyRequest{Address: address, Signature: signPersonalMessage(t, other, ch.Message)})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "INVALID_SIGNATURE")
+
+	ch = challenge()
+	sig := signPersonalMessage(t, signer, ch.Message)
+	rec = post("/v1/auth/verify", AuthVerifyRequest{Address: address, Signature: sig})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var session AuthSessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	assert.Equal(t, address, session.Address)
+	assert.NotEmpty(t, session.Token)
+
+	// Challenges are answered once
+	rec = post("/v1/auth/verify", AuthVerifyRequest{Address: address, Signature: sig})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "CHALLENGE_EXPIRED")
+
+	// Sessions guard the address of user routes and the owner of bridge
+	// submissions
+	guarded := chi.NewRouter()
+	guarded.Route("/users/{address}", func(r chi.Router) {
+		r.Use(NewMiddleware(logger, nil).WalletAuth(h.sessionAddress, true))
+		r.Get("/positions", func(w http.ResponseWriter, r *http.Request) {})
+		r.Post("/bridge", func(w http.ResponseWriter, r *http.Request) {
+			var req BridgeDepositRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			h.authorizeOwner(w, r, req.SuiOwner)
+		})
+	})
+	for _, tt := range []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{name: "no session", method: http.MethodGet, path: "/users/" + address + "/positions", status: http.StatusUnauthorized},
+		{name: "unknown session", method: http.MethodGet, path: "/users/" + address + "/positions", token: "nope", status: http.StatusUnauthorized},
+		{name: "own address", method: http.MethodGet, path: "/users/" + address + "/positions", token: session.Token, status: http.StatusOK},
+		{name: "other address", method: http.MethodGet, path: "/users/" + other.Address.String() + "/positions", token: session.Token, status: http.StatusForbidden},
+		{name: "own owner", method: http.MethodPost, path: "/users/" + address + "/bridge", token: session.Token, body: `{"suiOwner":"` + address + `"}`, status: http.StatusOK},
+		{name: "other owner", method: http.MethodPost, path: "/users/" + address + "/bridge", token: session.Token, body: `{"suiOwner":"` + other.Address.String() + `"}`, status: http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			guarded.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+
+	// Live update connections pass the token as a query parameter
+	rec = httptest.NewRecorder()
+	_, ok := h.streamSession(rec, httptest.NewRequest(http.MethodGet, "/v1/ws?token=nope", nil))
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	streamReq, ok := h.streamSession(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/ws?token="+session.Token, nil))
+	require.True(t, ok)
+	assert.Equal(t, address, ws.SessionAddress(streamReq.Context()))
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dependency names reported in ErrorResponse.DegradedDependencies and the
+// X-Degraded-Dependencies header
+const (
+	DependencySuiRPC = "sui_rpc"
+	DependencyCache  = "cache"
+)
+
+// Backoff response headers. Retry-After carries whole seconds (rounded up) as
+// defined by RFC 9110; X-Retry-After-Ms carries the exact delay.
+const (
+	HeaderRetryAfter           = "Retry-After"
+	HeaderRetryAfterMs         = "X-Retry-After-Ms"
+	HeaderDegradedDependencies = "X-Degraded-Dependencies"
+)
+
+// defaultDependencyRetryAfter is suggested to clients when an upstream
+// dependency fails and no better estimate is available
+const defaultDependencyRetryAfter = 2 * time.Second
+
+// BackoffHint tells clients how long to wait before retrying and which
+// dependencies are currently degraded
+type BackoffHint struct {
+	RetryAfter           time.Duration
+	DegradedDependencies []string
+}
+
+// apply copies the hint into the error body and response headers.
+// It must be called before the status code is written.
+func (hint BackoffHint) apply(w http.ResponseWriter, resp *ErrorResponse) {
+	if hint.RetryAfter > 0 {
+		ms := hint.RetryAfter.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+		seconds := (ms + 999) / 1000
+		resp.RetryAfterMs = ms
+		w.Header().Set(HeaderRetryAfter, strconv.FormatInt(seconds, 10))
+		w.Header().Set(HeaderRetryAfterMs, strconv.FormatInt(ms, 10))
+	}
+	if len(hint.DegradedDependencies) > 0 {
+		resp.DegradedDependencies = hint.DegradedDependencies
+		w.Header().Set(HeaderDegradedDependencies, strings.Join(hint.DegradedDependencies, ","))
+	}
+}
+
+// writeBackoffError writes a problem detail carrying backoff hints. It is shared
+// by handlers and middleware, which have no Handler to log through.
+func writeBackoffError(w http.ResponseWriter, status int, code, message string, hint BackoffHint) {
+	resp := newProblem(w, status, code, message)
+	hint.apply(w, &resp)
+	writeProblem(w, resp)
+}
+
+// writeDependencyError reports a failed upstream dependency as 503 with a
+// retry hint, listing the failed dependency alongside any already degraded
+func (h *Handler) writeDependencyError(w http.ResponseWriter, dependency, code, message string) {
+	h.logger.Errorw("API dependency error",
+		"code", code,
+		"message", message,
+		"dependency", dependency,
+	)
+
+	degraded := h.degradedDependencies()
+	if !containsString(degraded, dependency) {
+		degraded = append(degraded, dependency)
+	}
+
+	writeBackoffError(w, http.StatusServiceUnavailable, code, message, BackoffHint{
+		RetryAfter:           defaultDependencyRetryAfter,
+		DegradedDependencies: degraded,
+	})
+}
+
+// degradedDependencies lists dependencies currently running in a degraded mode
+func (h *Handler) degradedDependencies() []string {
+	var degraded []string
+	if h.cache != nil && h.cache.UsingFallback() {
+		degraded = append(degraded, DependencyCache)
+	}
+	return degraded
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
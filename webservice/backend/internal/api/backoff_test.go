@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWriteDependencyError_IncludesBackoffHints(t *testing.T) {
+	handler, _ := createTestHandler()
+
+	w := httptest.NewRecorder()
+	handler.writeDependencyError(w, DependencySuiRPC, "PROTOCOL_STATE_ERROR", "rpc unavailable")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get(HeaderRetryAfter))
+	assert.Equal(t, "2000", w.Header().Get(HeaderRetryAfterMs))
+	assert.Equal(t, DependencySuiRPC, w.Header().Get(HeaderDegradedDependencies))
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "PROTOCOL_STATE_ERROR", resp.Code)
+	assert.Equal(t, int64(2000), resp.RetryAfterMs)
+	assert.Equal(t, []string{DependencySuiRPC}, resp.DegradedDependencies)
+}
+
+func TestBackoffHint_RoundsRetryAfterUp(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeBackoffError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Rate limit exceeded", BackoffHint{
+		RetryAfter: 1500 * time.Millisecond,
+	})
+
+	assert.Equal(t, "2", w.Header().Get(HeaderRetryAfter))
+	assert.Equal(t, "1500", w.Header().Get(HeaderRetryAfterMs))
+	assert.Empty(t, w.Header().Get(HeaderDegradedDependencies))
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(1500), resp.RetryAfterMs)
+	assert.Nil(t, resp.DegradedDependencies)
+}
+
+func TestRateLimit_ReturnsBackoffHint(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	m := NewMiddleware(logger.Sugar(), nil)
+
+	// 6 rpm allows a burst of one request, then one token every 10s
+	handler := m.RateLimit(6)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "RATE_LIMITED", resp.Code)
+	assert.Greater(t, resp.RetryAfterMs, int64(9000))
+	assert.LessOrEqual(t, resp.RetryAfterMs, int64(10000))
+	assert.Equal(t, "10", w.Header().Get(HeaderRetryAfter))
+}
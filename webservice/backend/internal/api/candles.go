@@ -2,12 +2,15 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/candles"
 	"github.com/leafsii/leafsii-backend/internal/prices"
 	"github.com/leafsii/leafsii-backend/internal/prices/binance"
 	"github.com/leafsii/leafsii-backend/internal/prices/mock"
@@ -64,7 +67,7 @@ func (h *Handler) GetCandles(w http.ResponseWriter, r *http.Request) {
 	// Try to get candles from provider
 	candles, mocked, err := h.fetchCandlesWithFallback(r.Context(), providerSymbol, intervalDuration, limit)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "CANDLES_ERROR", err.Error())
+		h.writeErrorFor(w, err, "CANDLES_ERROR")
 		return
 	}
 
@@ -147,3 +150,79 @@ func (h *Handler) createMockProvider(symbol string) prices.Provider {
 
 	return mock.NewGenerator(h.logger, basePrice, 0.002) // 0.2% volatility
 }
+
+// defaultCandlesLimit is the page size of candles when none is given
+const defaultCandlesLimit = 500
+
+// GetMarketCandles lists the stored candles of a symbol, oldest first,
+// from from to to. The symbol is a provider symbol such as SUIUSDT or a
+// pair such as SUI-USD. from and to are unix seconds or RFC 3339 times;
+// to defaults to now and from to a page of candles before it.
+func (h *Handler) GetMarketCandles(w http.ResponseWriter, r *http.Request) {
+	if h.candles == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "CANDLES_UNAVAILABLE", "Candle history is not enabled")
+		return
+	}
+
+	var req MarketCandlesRequest
+	decodeQuery(r, &req)
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+	if req.Interval == "" {
+		req.Interval = "1h"
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultCandlesLimit
+	}
+
+	symbol, ok := candleSymbol(chi.URLParam(r, "symbol"))
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "MARKET_NOT_FOUND", fmt.Sprintf("no candles for %s", chi.URLParam(r, "symbol")))
+		return
+	}
+
+	interval := prices.ParseInterval(req.Interval)
+	from, to, ok := h.historyRange(w, r, interval*time.Duration(req.Limit))
+	if !ok {
+		return
+	}
+
+	page, err := h.candles.Candles(r.Context(), symbol, interval, from, to, req.Limit, req.Cursor)
+	switch {
+	case errors.Is(err, candles.ErrInvalidRange):
+		h.writeError(w, http.StatusBadRequest, "INVALID_RANGE", err.Error())
+		return
+	case errors.Is(err, candles.ErrInvalidCursor):
+		h.writeError(w, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+		return
+	case err != nil:
+		h.writeErrorFor(w, err, "CANDLES_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, MarketCandlesDTO{
+		Symbol:     symbol,
+		Interval:   req.Interval,
+		From:       from.Unix(),
+		To:         to.Unix(),
+		Candles:    page.Candles,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// candleSymbol returns the provider symbol of a path symbol, which names
+// either the provider symbol or a UI pair with a dash for its slash
+func candleSymbol(symbol string) (string, bool) {
+	registry := prices.NewRegistry()
+	if providerSymbol, err := registry.GetProviderSymbol(strings.ReplaceAll(symbol, "-", "/")); err == nil {
+		return providerSymbol, true
+	}
+	symbol = strings.ToUpper(symbol)
+	for _, known := range registry.GetProviderSymbols() {
+		if known == symbol {
+			return symbol, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/candles"
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetMarketCandles(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+
+	logger := zap.NewNop().Sugar()
+	svc := candles.NewService(database.Repository(entities.PriceCandleSchema), logger)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, price := range []float64{1.5, 1.6, 1.4} {
+		svc.Record(prices.Tick{Symbol: "SUIUSDT", Price: price, TsMs: start.Add(time.Duration(i) * time.Minute).UnixMilli()})
+	}
+	require.NoError(t, svc.Flush(ctx))
+
+	h := &Handler{logger: logger, metrics: &MockMetrics{}, candles: svc}
+	r := chi.NewRouter()
+	r.Get("/markets/{symbol}/candles", h.GetMarketCandles)
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	hour := "from=1704067200&to=1704070800"
+	rec := serve("/markets/SUI-USD/candles?interval=1m&limit=2&" + hour)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var page MarketCandlesDTO
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Equal(t, "SUIUSDT", page.Symbol)
+	require.Len(t, page.Candles, 2)
+	assert.Equal(t, 1.5, page.Candles[0].Open)
+	require.NotEmpty(t, page.NextCursor)
+
+	rec = serve("/markets/suiusdt/candles?interval=1m&limit=2&from=2024-01-01T00:00:00Z&to=2024-01-01T01:00:00Z&cursor=" + page.NextCursor)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Candles, 1)
+	assert.Equal(t, 1.4, page.Candles[0].Close)
+	assert.Empty(t, page.NextCursor)
+
+	rec = serve("/markets/SUIUSDT/candles?interval=1h&" + hour)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Candles, 1)
+	assert.Equal(t, prices.Candle{Time: start.Unix(), Open: 1.5, High: 1.6, Low: 1.4, Close: 1.4}, page.Candles[0])
+
+	for target, code := range map[string]string{
+		"/markets/SUIUSDT/candles?interval=2m":                   "INVALID_INTERVAL",
+		"/markets/SUIUSDT/candles?limit=5000":                    "INVALID_PARAMETER",
+		"/markets/SUIUSDT/candles?from=1704070800&to=1704067200": "INVALID_RANGE",
+		"/markets/SUIUSDT/candles?cursor=bogus":                  "INVALID_CURSOR",
+		"/markets/DOGEUSDT/candles":                              "MARKET_NOT_FOUND",
+	} {
+		rec := serve(target)
+		assert.Contains(t, rec.Body.String(), code, target)
+	}
+
+	// Without the service candles are unavailable
+	h.candles = nil
+	rec = serve("/markets/SUIUSDT/candles")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "CANDLES_UNAVAILABLE")
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DefaultCompressMinBytes is the size from which responses are compressed
+// when the config sets no LFS_COMPRESS_MIN_BYTES
+const DefaultCompressMinBytes = 1024
+
+// Compress compresses JSON and text responses of at least compressMinBytes
+// with the encoding the client prefers among br and gzip. Smaller
+// responses, event streams and upgraded connections pass as they are.
+func (m *Middleware) Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding, minBytes: m.compressMinBytes}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding returns the encoding of acceptEncoding with the highest
+// weight among br and gzip, preferring br on ties, or "" for none
+func negotiateEncoding(acceptEncoding string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		weights[strings.ToLower(strings.TrimSpace(coding))] = weight
+	}
+
+	best, bestWeight := "", 0.0
+	for _, coding := range []string{"br", "gzip"} {
+		weight, ok := weights[coding]
+		if !ok {
+			weight, ok = weights["*"]
+		}
+		if ok && weight > bestWeight {
+			best, bestWeight = coding, weight
+		}
+	}
+	return best
+}
+
+func shouldCompress(contentType string) bool {
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	return strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "text/") ||
+		strings.Contains(contentType, "application/javascript")
+}
+
+// compressResponseWriter holds the start of a response until it reaches
+// minBytes, then compresses it. Responses that end smaller are written as
+// they are.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minBytes int
+
+	status      int
+	wroteHeader bool // The status was passed on, compressed or not
+	eligible    bool // Compressible, once the status is known
+	buf         bytes.Buffer
+	encoder     io.WriteCloser
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	h := w.Header()
+	w.eligible = status != http.StatusNoContent && status != http.StatusNotModified && status >= http.StatusOK &&
+		h.Get("Content-Encoding") == "" && shouldCompress(h.Get("Content-Type"))
+	if w.eligible {
+		h.Add("Vary", "Accept-Encoding")
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case w.encoder != nil:
+		return w.encoder.Write(p)
+	case !w.eligible:
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf.Write(p)
+	if w.buf.Len() >= w.minBytes {
+		if err := w.startEncoding(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// startEncoding passes on the status with the encoding headers, and
+// compresses what was held
+func (w *compressResponseWriter) startEncoding() error {
+	h := w.Header()
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if w.encoding == "br" {
+		w.encoder = brotli.NewWriterLevel(w.ResponseWriter, brotli.DefaultCompression)
+	} else {
+		w.encoder = gzip.NewWriter(w.ResponseWriter)
+	}
+	_, err := w.encoder.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Flush compresses what is held, so that flushed streams are compressed
+// from then on
+func (w *compressResponseWriter) Flush() {
+	if w.eligible && w.encoder == nil {
+		w.startEncoding()
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// close ends the compressed stream, or writes a response too small to
+// compress as it is
+func (w *compressResponseWriter) close() {
+	switch {
+	case w.encoder != nil:
+		w.encoder.Close()
+	case w.eligible && !w.wroteHeader:
+		w.wroteHeader = true
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
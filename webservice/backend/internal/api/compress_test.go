@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                         "",
+		"identity":                 "",
+		"gzip":                     "gzip",
+		"gzip, deflate, br":        "br",
+		"br;q=0.5, gzip":           "gzip",
+		"br;q=0, gzip;q=0":         "",
+		"*":                        "br",
+		"*;q=0.1, gzip;q=0.2":      "gzip",
+		"GZIP;q=0.8, deflate;q=1":  "gzip",
+		"br;q=bogus, gzip;q=0.001": "gzip",
+	} {
+		assert.Equal(t, want, negotiateEncoding(header), header)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	m := NewMiddleware(zap.NewNop().Sugar(), nil, WithCompressMinBytes(64))
+	large := strings.Repeat(`{"open":"1.00","close":"1.01"},`, 100)
+	handler := m.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, large[:len(large)/2])
+			io.WriteString(w, large[len(large)/2:])
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{}`)
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, large)
+		}
+	}))
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/large", "gzip")
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, large, string(body))
+
+	rec = get("/large", "gzip, br")
+	assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	assert.Less(t, rec.Body.Len(), len(large))
+	body, err = io.ReadAll(brotli.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Equal(t, large, string(body))
+
+	for _, tt := range []struct{ path, acceptEncoding string }{
+		{path: "/large", acceptEncoding: ""},
+		{path: "/small", acceptEncoding: "gzip, br"},
+		{path: "/image", acceptEncoding: "gzip, br"},
+	} {
+		rec := get(tt.path, tt.acceptEncoding)
+		assert.Equal(t, http.StatusOK, rec.Code, tt.path)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"), tt.path)
+		if tt.path == "/small" {
+			assert.Equal(t, `{}`, rec.Body.String())
+		}
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	m := NewMiddleware(zap.NewNop().Sugar(), nil, WithMaxBodyBytes(8))
+	handler := m.LimitBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345678")))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "12345678", rec.Body.String())
+
+	// Bodies over the limit are refused whether or not they declare their
+	// length
+	for _, declared := range []bool{true, false} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("123456789"))
+		if !declared {
+			req.Body = io.NopCloser(bytes.NewBufferString("123456789"))
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, ContentTypeProblem, rec.Header().Get("Content-Type"))
+		var problem ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, "REQUEST_TOO_LARGE", problem.Code)
+	}
+}
@@ -3,10 +3,13 @@ package api
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/leafsii/leafsii-backend/internal/crosschain"
 	"github.com/shopspring/decimal"
 )
@@ -25,7 +28,7 @@ func (h *Handler) GetLatestCheckpoint(w http.ResponseWriter, r *http.Request) {
 			h.writeError(w, http.StatusNotFound, "CHECKPOINT_NOT_FOUND", "no checkpoint available")
 			return
 		}
-		h.writeError(w, http.StatusInternalServerError, "CHECKPOINT_ERROR", err.Error())
+		h.writeErrorFor(w, err, "CHECKPOINT_ERROR")
 		return
 	}
 
@@ -48,6 +51,138 @@ func (h *Handler) GetLatestCheckpoint(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, WalrusCheckpointResponse{Checkpoint: &dto})
 }
 
+// VerifyCheckpoint reads checkpoint {id} back from Walrus and compares it
+// with the local copy. A mismatch is reported with verified=false.
+func (h *Handler) VerifyCheckpoint(w http.ResponseWriter, r *http.Request) {
+	updateID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "id must be a checkpoint update ID")
+		return
+	}
+
+	result, err := h.crosschainSvc.VerifyCheckpoint(r.Context(), updateID)
+	if err != nil {
+		switch {
+		case errors.Is(err, crosschain.ErrNotFound):
+			h.writeError(w, http.StatusNotFound, "CHECKPOINT_NOT_FOUND", "checkpoint or its Walrus blob not found")
+		case errors.Is(err, crosschain.ErrWalrusUnavailable):
+			h.writeError(w, http.StatusBadGateway, "WALRUS_UNAVAILABLE", err.Error())
+		default:
+			h.writeErrorFor(w, err, "VERIFY_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, CheckpointVerificationResponse{Verification: CheckpointVerificationDTO{
+		UpdateID:           result.UpdateID,
+		WalrusBlobID:       result.WalrusBlobID,
+		LocalHash:          result.LocalHash,
+		RemoteHash:         result.RemoteHash,
+		BalancesRoot:       result.BalancesRoot,
+		RemoteBalancesRoot: result.RemoteBalancesRoot,
+		Verified:           result.Verified,
+		Reason:             result.Reason,
+	}})
+}
+
+func attestationStatusDTO(status *crosschain.AttestationStatus) AttestationStatusDTO {
+	dto := AttestationStatusDTO{
+		UpdateID:     status.UpdateID,
+		Digest:       status.Digest,
+		Threshold:    status.Threshold,
+		Signers:      status.Signers,
+		Attestations: make([]CheckpointAttestationDTO, 0, len(status.Attestations)),
+		Status:       string(status.Status),
+	}
+	for _, att := range status.Attestations {
+		dto.Attestations = append(dto.Attestations, CheckpointAttestationDTO{
+			UpdateID:  att.UpdateID,
+			Signer:    att.Signer,
+			Signature: att.Signature,
+			CreatedAt: att.CreatedAt.Unix(),
+		})
+	}
+	return dto
+}
+
+func (h *Handler) GetCheckpointAttestations(w http.ResponseWriter, r *http.Request) {
+	updateID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "id must be a checkpoint update ID")
+		return
+	}
+
+	status, err := h.crosschainSvc.GetAttestations(r.Context(), updateID)
+	if err != nil {
+		if errors.Is(err, crosschain.ErrNotFound) {
+			h.writeError(w, http.StatusNotFound, "CHECKPOINT_NOT_FOUND", "checkpoint not found")
+			return
+		}
+		h.writeErrorFor(w, err, "ATTESTATION_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, AttestationStatusResponse{Attestation: attestationStatusDTO(status)})
+}
+
+func (h *Handler) AddCheckpointAttestation(w http.ResponseWriter, r *http.Request) {
+	updateID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "id must be a checkpoint update ID")
+		return
+	}
+	var req AddAttestationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Signature == "" {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "signature is required")
+		return
+	}
+
+	status, err := h.crosschainSvc.AddAttestation(r.Context(), updateID, req.Signature)
+	if err != nil {
+		switch {
+		case errors.Is(err, crosschain.ErrNotFound):
+			h.writeError(w, http.StatusNotFound, "CHECKPOINT_NOT_FOUND", "checkpoint not found")
+		case errors.Is(err, crosschain.ErrInvalidRequest):
+			h.writeError(w, http.StatusBadRequest, "INVALID_ATTESTATION", err.Error())
+		default:
+			h.writeErrorFor(w, err, "ATTESTATION_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, AttestationStatusResponse{Attestation: attestationStatusDTO(status)})
+}
+
+// SignCheckpointAttestation signs a checkpoint for a peer operator node.
+// The body is the checkpoint as published to Walrus.
+func (h *Handler) SignCheckpointAttestation(w http.ResponseWriter, r *http.Request) {
+	var cp crosschain.WalrusCheckpoint
+	if err := json.NewDecoder(r.Body).Decode(&cp); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid checkpoint payload")
+		return
+	}
+
+	att, err := h.crosschainSvc.SignCheckpoint(r.Context(), cp)
+	if err != nil {
+		switch {
+		case errors.Is(err, crosschain.ErrCheckpointMismatch):
+			h.writeError(w, http.StatusConflict, "CHECKPOINT_MISMATCH", err.Error())
+		case errors.Is(err, crosschain.ErrInvalidRequest):
+			h.writeError(w, http.StatusNotFound, "NOT_A_SIGNER", err.Error())
+		default:
+			h.writeErrorFor(w, err, "ATTESTATION_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, CheckpointAttestationDTO{
+		UpdateID:  att.UpdateID,
+		Signer:    att.Signer,
+		Signature: att.Signature,
+		CreatedAt: att.CreatedAt.Unix(),
+	})
+}
+
 func (h *Handler) SubmitCheckpoint(w http.ResponseWriter, r *http.Request) {
 	var req SubmitCheckpointRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -93,7 +228,7 @@ func (h *Handler) SubmitCheckpoint(w http.ResponseWriter, r *http.Request) {
 
 	created, err := h.crosschainSvc.SubmitCheckpoint(r.Context(), cp)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "CHECKPOINT_ERROR", err.Error())
+		h.writeErrorFor(w, err, "CHECKPOINT_ERROR")
 		return
 	}
 
@@ -127,27 +262,42 @@ func (h *Handler) SubmitCrossChainDeposit(w http.ResponseWriter, r *http.Request
 		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid deposit payload")
 		return
 	}
+	if !h.authorizeOwner(w, r, req.SuiOwner) {
+		return
+	}
 
-	amount, err := decimal.NewFromString(req.Amount)
-	if err != nil || !amount.GreaterThan(decimal.Zero) {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "amount must be a positive decimal string")
+	if !h.validateRequest(w, &req, "") {
 		return
 	}
+	amount := decimal.RequireFromString(req.Amount)
 
 	receipt, err := h.bridgeWorker.Submit(r.Context(), crosschain.DepositSubmission{
 		TxHash:   req.TxHash,
+		LogIndex: req.LogIndex,
 		SuiOwner: req.SuiOwner,
 		ChainID:  crosschain.ChainID(req.ChainID),
 		Asset:    req.Asset,
 		Amount:   amount,
 	})
 	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "BRIDGE_ERROR", err.Error())
+		switch {
+		case errors.Is(err, crosschain.ErrDepositInProgress):
+			h.writeError(w, http.StatusConflict, "DEPOSIT_IN_PROGRESS", "deposit is already being processed")
+		case errors.Is(err, crosschain.ErrBridgePaused):
+			h.writeError(w, http.StatusServiceUnavailable, "BRIDGE_PAUSED", err.Error())
+		case errors.Is(err, crosschain.ErrLimitExceeded):
+			h.writeError(w, http.StatusUnprocessableEntity, "LIMIT_EXCEEDED", err.Error())
+		case errors.Is(err, crosschain.ErrRateLimited):
+			writeRateLimitError(w, err)
+		default:
+			h.writeErrorFor(w, err, "BRIDGE_ERROR")
+		}
 		return
 	}
 
 	h.logger.Infow("Bridge deposit processed",
 		"txHash", req.TxHash,
+		"logIndex", req.LogIndex,
 		"suiOwner", req.SuiOwner,
 		"chainId", req.ChainID,
 		"asset", req.Asset,
@@ -155,18 +305,70 @@ func (h *Handler) SubmitCrossChainDeposit(w http.ResponseWriter, r *http.Request
 		"receiptId", receipt.ReceiptID,
 	)
 
+	h.writeJSON(w, http.StatusCreated, BridgeReceiptResponse{Receipt: bridgeReceiptDTO(receipt)})
+}
+
+func (h *Handler) GetCrossChainDeposits(w http.ResponseWriter, r *http.Request) {
+	txHash := r.URL.Query().Get("txHash")
+	if txHash == "" {
+		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "txHash is required")
+		return
+	}
+
+	receipts, err := h.crosschainSvc.GetDeposits(r.Context(), crosschain.ChainID(r.URL.Query().Get("chainId")), txHash)
+	if err != nil {
+		h.writeErrorFor(w, err, "DEPOSIT_ERROR")
+		return
+	}
+	if len(receipts) == 0 {
+		h.writeError(w, http.StatusNotFound, "DEPOSIT_NOT_FOUND", "no deposit recorded for txHash")
+		return
+	}
+
+	resp := BridgeReceiptListResponse{Receipts: make([]BridgeReceiptDTO, 0, len(receipts))}
+	for _, receipt := range receipts {
+		resp.Receipts = append(resp.Receipts, bridgeReceiptDTO(receipt))
+	}
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+func priceDTO(quote *crosschain.PriceQuote) *PriceDTO {
+	if quote == nil {
+		return nil
+	}
+	return &PriceDTO{
+		Source:     quote.Source,
+		Symbol:     quote.Symbol,
+		Price:      quote.Price.String(),
+		ObservedAt: quote.ObservedAt.Unix(),
+	}
+}
+
+func bridgeReceiptDTO(receipt *crosschain.BridgeReceipt) BridgeReceiptDTO {
 	dto := BridgeReceiptDTO{
 		ReceiptID:    receipt.ReceiptID,
 		TxHash:       receipt.TxHash,
+		LogIndex:     receipt.LogIndex,
+		BlockNumber:  receipt.BlockNumber,
 		SuiOwner:     receipt.SuiOwner,
 		ChainID:      string(receipt.ChainID),
 		Asset:        receipt.Asset,
 		Minted:       receipt.Minted,
+		Fee:          receipt.Fee.String(),
+		Price:        priceDTO(receipt.Price),
+		Status:       string(receipt.Status),
+		Stage:        string(receipt.Stage),
 		CreatedAt:    receipt.CreatedAt.Unix(),
 		SuiTxDigests: receipt.SuiTxDigests,
 	}
-
-	h.writeJSON(w, http.StatusCreated, BridgeReceiptResponse{Receipt: dto})
+	if !receipt.Amount.IsZero() {
+		dto.Amount = receipt.Amount.String()
+	}
+	if !receipt.Shares.IsZero() {
+		dto.Shares = receipt.Shares.String()
+	}
+	return dto
 }
 
 func (h *Handler) SubmitCrossChainRedeem(w http.ResponseWriter, r *http.Request) {
@@ -180,12 +382,14 @@ func (h *Handler) SubmitCrossChainRedeem(w http.ResponseWriter, r *http.Request)
 		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid redeem payload")
 		return
 	}
+	if !h.authorizeOwner(w, r, req.SuiOwner) {
+		return
+	}
 
-	amount, err := decimal.NewFromString(req.Amount)
-	if err != nil || !amount.GreaterThan(decimal.Zero) {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "amount must be a positive decimal string")
+	if !h.validateRequest(w, &req, "") {
 		return
 	}
+	amount := decimal.RequireFromString(req.Amount)
 
 	token := strings.ToLower(strings.TrimSpace(req.Token))
 	if token != "f" && token != "x" {
@@ -203,7 +407,14 @@ func (h *Handler) SubmitCrossChainRedeem(w http.ResponseWriter, r *http.Request)
 		Amount:       amount,
 	})
 	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "BRIDGE_ERROR", err.Error())
+		switch {
+		case errors.Is(err, crosschain.ErrBridgePaused):
+			h.writeError(w, http.StatusServiceUnavailable, "BRIDGE_PAUSED", err.Error())
+		case errors.Is(err, crosschain.ErrRateLimited):
+			writeRateLimitError(w, err)
+		default:
+			h.writeErrorFor(w, err, "BRIDGE_ERROR")
+		}
 		return
 	}
 
@@ -218,7 +429,11 @@ func (h *Handler) SubmitCrossChainRedeem(w http.ResponseWriter, r *http.Request)
 		"receiptId", receipt.ReceiptID,
 	)
 
-	dto := RedeemReceiptDTO{
+	h.writeJSON(w, http.StatusCreated, RedeemReceiptResponse{Receipt: redeemReceiptDTO(receipt)})
+}
+
+func redeemReceiptDTO(receipt *crosschain.RedeemReceipt) RedeemReceiptDTO {
+	return RedeemReceiptDTO{
 		ReceiptID:      receipt.ReceiptID,
 		SuiTxDigest:    receipt.SuiTxDigest,
 		SuiOwner:       receipt.SuiOwner,
@@ -228,13 +443,161 @@ func (h *Handler) SubmitCrossChainRedeem(w http.ResponseWriter, r *http.Request)
 		Token:          receipt.Token,
 		Burned:         receipt.Burned,
 		PayoutEth:      receipt.PayoutEth,
+		Fee:            receipt.Fee,
+		Price:          priceDTO(receipt.Price),
 		WalrusUpdateID: receipt.WalrusUpdateID,
 		WalrusBlobID:   receipt.WalrusBlobID,
 		PayoutTxHash:   receipt.PayoutTxHash,
+		Status:         string(receipt.Status()),
+		Stage:          string(receipt.Stage),
 		CreatedAt:      receipt.CreatedAt.Unix(),
 	}
+}
+
+func receiptDTO(receipt *crosschain.Receipt) ReceiptDTO {
+	dto := ReceiptDTO{
+		Kind:      string(receipt.Kind),
+		ReceiptID: receipt.ID(),
+		Status:    receipt.Status(),
+		Stage:     string(receipt.Stage()),
+		CreatedAt: receipt.CreatedAt().Unix(),
+	}
+	if receipt.Deposit != nil {
+		deposit := bridgeReceiptDTO(receipt.Deposit)
+		dto.Deposit = &deposit
+	}
+	if receipt.Redeem != nil {
+		redeem := redeemReceiptDTO(receipt.Redeem)
+		dto.Redeem = &redeem
+	}
+	return dto
+}
+
+// ListReceipts lists deposit and redeem receipts, newest first, filtered
+// by kind, suiOwner, chainId, asset, status, stage and a from/to range of
+// Unix times, a page of limit at a time.
+func (h *Handler) ListReceipts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := crosschain.ReceiptFilter{
+		Kind:     crosschain.ReceiptKind(query.Get("kind")),
+		SuiOwner: query.Get("suiOwner"),
+		ChainID:  crosschain.ChainID(query.Get("chainId")),
+		Asset:    query.Get("asset"),
+		Status:   query.Get("status"),
+		Stage:    crosschain.ReceiptStage(query.Get("stage")),
+		Cursor:   query.Get("cursor"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "limit must be a positive integer")
+			return
+		}
+		filter.Limit = n
+	}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := query.Get(bound.name); v != "" {
+			unix, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", bound.name+" must be a Unix time")
+				return
+			}
+			*bound.t = time.Unix(unix, 0)
+		}
+	}
+
+	receipts, nextCursor, err := h.crosschainSvc.ListReceipts(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, crosschain.ErrInvalidRequest) {
+			h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", err.Error())
+			return
+		}
+		h.writeErrorFor(w, err, "RECEIPT_ERROR")
+		return
+	}
+
+	resp := ReceiptListResponse{Receipts: make([]ReceiptDTO, 0, len(receipts)), NextCursor: nextCursor}
+	for _, receipt := range receipts {
+		resp.Receipts = append(resp.Receipts, receiptDTO(receipt))
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetReceipt returns a deposit or redeem receipt with its Sui digests,
+// payout transaction and the stages it went through.
+func (h *Handler) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	receipt, err := h.crosschainSvc.GetReceipt(r.Context(), chi.URLParam(r, "receiptId"))
+	if err != nil {
+		if errors.Is(err, crosschain.ErrNotFound) {
+			h.writeError(w, http.StatusNotFound, "RECEIPT_NOT_FOUND", "receipt not found")
+			return
+		}
+		h.writeErrorFor(w, err, "RECEIPT_ERROR")
+		return
+	}
+	dto := receiptDTO(receipt)
+	if dto.Transitions, err = h.receiptTransitions(r, receipt.ID()); err != nil {
+		h.writeErrorFor(w, err, "RECEIPT_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, ReceiptResponse{Receipt: dto})
+}
+
+// receiptTransitions returns the stages receiptID went through
+func (h *Handler) receiptTransitions(r *http.Request, receiptID string) ([]ReceiptTransitionDTO, error) {
+	transitions, err := h.crosschainSvc.GetTransitions(r.Context(), receiptID)
+	if err != nil {
+		return nil, err
+	}
+	var dtos []ReceiptTransitionDTO
+	for _, t := range transitions {
+		dtos = append(dtos, ReceiptTransitionDTO{
+			From:      string(t.From),
+			To:        string(t.To),
+			Reason:    t.Reason,
+			CreatedAt: t.CreatedAt.Unix(),
+		})
+	}
+	return dtos, nil
+}
+
+// RecomputeReceipt derives the amounts of a receipt again from the price it
+// recorded, so that audits can check the split of a deposit or the payout
+// of a redeem.
+func (h *Handler) RecomputeReceipt(w http.ResponseWriter, r *http.Request) {
+	rc, err := h.crosschainSvc.RecomputeReceipt(r.Context(), chi.URLParam(r, "receiptId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, crosschain.ErrNotFound):
+			h.writeError(w, http.StatusNotFound, "RECEIPT_NOT_FOUND", "receipt not found")
+		case errors.Is(err, crosschain.ErrInvalidRequest):
+			h.writeError(w, http.StatusUnprocessableEntity, "RECEIPT_NOT_RECOMPUTABLE", err.Error())
+		default:
+			h.writeErrorFor(w, err, "RECEIPT_ERROR")
+		}
+		return
+	}
 
-	h.writeJSON(w, http.StatusCreated, RedeemReceiptResponse{Receipt: dto})
+	dto := ReceiptRecomputationDTO{
+		ReceiptID:      rc.ReceiptID,
+		Kind:           string(rc.Kind),
+		Price:          *priceDTO(&rc.Price),
+		MintF:          rc.MintF,
+		MintX:          rc.MintX,
+		ExpectedMintF:  rc.ExpectedMintF,
+		ExpectedMintX:  rc.ExpectedMintX,
+		Payout:         rc.Payout,
+		ExpectedPayout: rc.ExpectedPayout,
+		Matches:        rc.Matches,
+	}
+	if rc.Kind == crosschain.ReceiptKindDeposit {
+		dto.Shares = rc.Shares.String()
+		dto.ExpectedShares = rc.ExpectedShares.String()
+	}
+	h.writeJSON(w, http.StatusOK, ReceiptRecomputationResponse{Recomputation: dto})
 }
 
 func (h *Handler) GetCrossChainBalance(w http.ResponseWriter, r *http.Request) {
@@ -248,7 +611,7 @@ func (h *Handler) GetCrossChainBalance(w http.ResponseWriter, r *http.Request) {
 
 	balance, err := h.crosschainSvc.GetBalance(r.Context(), suiOwner, crosschain.ChainID(chainID), asset)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "BALANCE_ERROR", err.Error())
+		h.writeErrorFor(w, err, "BALANCE_ERROR")
 		return
 	}
 
@@ -267,12 +630,70 @@ func (h *Handler) GetCrossChainBalance(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, CrossChainBalanceResponse{Balance: dto})
 }
 
+// GetCrossChainBalanceProof returns the Merkle inclusion proof of a Sui
+// owner's balance. The root matches the BalancesRoot of checkpoint updateId.
+func (h *Handler) GetCrossChainBalanceProof(w http.ResponseWriter, r *http.Request) {
+	suiOwner := r.URL.Query().Get("suiOwner")
+	chainID := r.URL.Query().Get("chainId")
+	asset := r.URL.Query().Get("asset")
+	if suiOwner == "" || chainID == "" || asset == "" {
+		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "suiOwner, chainId, and asset are required")
+		return
+	}
+
+	proof, err := h.crosschainSvc.GetBalanceProof(r.Context(), suiOwner, crosschain.ChainID(chainID), asset)
+	if err != nil {
+		if err == crosschain.ErrNotFound {
+			h.writeError(w, http.StatusNotFound, "BALANCE_NOT_FOUND", "owner has no shares")
+			return
+		}
+		h.writeErrorFor(w, err, "PROOF_ERROR")
+		return
+	}
+
+	dto := BalanceProofDTO{
+		SuiOwner:  proof.SuiOwner,
+		ChainID:   string(proof.ChainID),
+		Asset:     proof.Asset,
+		Shares:    proof.Shares.String(),
+		LeafIndex: proof.LeafIndex,
+		LeafCount: proof.LeafCount,
+		Siblings:  proof.Siblings,
+		Root:      proof.Root,
+		UpdateID:  proof.UpdateID,
+	}
+
+	h.writeJSON(w, http.StatusOK, BalanceProofResponse{Proof: dto})
+}
+
+func voucherDTO(v *crosschain.WithdrawalVoucher) VoucherDTO {
+	return VoucherDTO{
+		VoucherID: v.VoucherID,
+		SuiOwner:  v.SuiOwner,
+		ChainID:   string(v.ChainID),
+		Asset:     v.Asset,
+		Shares:    v.Shares.String(),
+		Nonce:     v.Nonce,
+		Expiry:    v.Expiry.Unix(),
+		UpdateID:  v.UpdateID,
+		Redeemer:  v.Redeemer,
+		Digest:    v.Digest,
+		Signature: v.Signature,
+		Status:    string(v.Status),
+		TxHash:    v.TxHash,
+		CreatedAt: v.CreatedAt.Unix(),
+	}
+}
+
 func (h *Handler) CreateVoucher(w http.ResponseWriter, r *http.Request) {
 	var req CreateVoucherRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid voucher payload")
 		return
 	}
+	if !h.authorizeOwner(w, r, req.SuiOwner) {
+		return
+	}
 
 	shares, err := decimal.NewFromString(req.Shares)
 	if err != nil || !shares.GreaterThan(decimal.Zero) {
@@ -280,66 +701,69 @@ func (h *Handler) CreateVoucher(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expiry := time.Unix(req.Expiry, 0)
-	voucher, err := h.crosschainSvc.CreateVoucher(r.Context(), crosschain.WithdrawalVoucher{
-		SuiOwner:  req.SuiOwner,
-		ChainID:   crosschain.ChainID(req.ChainID),
-		Asset:     req.Asset,
-		Shares:    shares,
-		Expiry:    expiry,
-		Status:    crosschain.VoucherStatusPending,
-		CreatedAt: time.Now(),
-	})
+	issue := crosschain.VoucherRequest{
+		SuiOwner: req.SuiOwner,
+		ChainID:  crosschain.ChainID(req.ChainID),
+		Asset:    req.Asset,
+		Shares:   shares,
+		Redeemer: req.Redeemer,
+	}
+	if req.Expiry != 0 {
+		issue.Expiry = time.Unix(req.Expiry, 0)
+	}
+	voucher, err := h.crosschainSvc.IssueVoucher(r.Context(), issue)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "VOUCHER_ERROR", err.Error())
+		if errors.Is(err, crosschain.ErrInvalidRequest) {
+			h.writeError(w, http.StatusBadRequest, "INVALID_VOUCHER", err.Error())
+			return
+		}
+		h.writeErrorFor(w, err, "VOUCHER_ERROR")
 		return
 	}
 
-	dto := VoucherDTO{
-		VoucherID: voucher.VoucherID,
-		SuiOwner:  voucher.SuiOwner,
-		ChainID:   string(voucher.ChainID),
-		Asset:     voucher.Asset,
-		Shares:    voucher.Shares.String(),
-		Nonce:     voucher.Nonce,
-		Expiry:    voucher.Expiry.Unix(),
-		Status:    string(voucher.Status),
-		TxHash:    voucher.TxHash,
-		CreatedAt: voucher.CreatedAt.Unix(),
-	}
-
+	dto := voucherDTO(voucher)
 	h.writeJSON(w, http.StatusCreated, VoucherResponse{Voucher: &dto})
 }
 
+// ListVouchers lists the vouchers of a Sui owner, optionally only those with
+// a status.
 func (h *Handler) ListVouchers(w http.ResponseWriter, r *http.Request) {
 	suiOwner := r.URL.Query().Get("suiOwner")
 	if suiOwner == "" {
 		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "suiOwner is required")
 		return
 	}
+	h.listVouchers(w, r, suiOwner, crosschain.VoucherStatus(r.URL.Query().Get("status")))
+}
+
+// ListPendingVouchers lists the vouchers of every owner that are still
+// pending, or those with the status query parameter.
+func (h *Handler) ListPendingVouchers(w http.ResponseWriter, r *http.Request) {
+	status := crosschain.VoucherStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = crosschain.VoucherStatusPending
+	}
+	h.listVouchers(w, r, "", status)
+}
+
+func (h *Handler) listVouchers(w http.ResponseWriter, r *http.Request, suiOwner string, status crosschain.VoucherStatus) {
+	switch status {
+	case "", crosschain.VoucherStatusPending, crosschain.VoucherStatusSpent, crosschain.VoucherStatusSettled, crosschain.VoucherStatusCancelled:
+	default:
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "status must be pending, spent, settled or cancelled")
+		return
+	}
 
-	vouchers, err := h.crosschainSvc.ListVouchers(r.Context(), suiOwner)
+	vouchers, err := h.crosschainSvc.ListVouchers(r.Context(), suiOwner, status)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "VOUCHER_ERROR", err.Error())
+		h.writeErrorFor(w, err, "VOUCHER_ERROR")
 		return
 	}
 
 	resp := VoucherListResponse{Vouchers: make([]VoucherDTO, 0, len(vouchers))}
 	for _, v := range vouchers {
-		resp.Vouchers = append(resp.Vouchers, VoucherDTO{
-			VoucherID: v.VoucherID,
-			SuiOwner:  v.SuiOwner,
-			ChainID:   string(v.ChainID),
-			Asset:     v.Asset,
-			Shares:    v.Shares.String(),
-			Nonce:     v.Nonce,
-			Expiry:    v.Expiry.Unix(),
-			Status:    string(v.Status),
-			TxHash:    v.TxHash,
-			CreatedAt: v.CreatedAt.Unix(),
-		})
+		resp.Vouchers = append(resp.Vouchers, voucherDTO(v))
 	}
-
 	h.writeJSON(w, http.StatusOK, resp)
 }
 
@@ -356,23 +780,33 @@ func (h *Handler) GetVoucher(w http.ResponseWriter, r *http.Request) {
 			h.writeError(w, http.StatusNotFound, "VOUCHER_NOT_FOUND", "voucher not found")
 			return
 		}
-		h.writeError(w, http.StatusInternalServerError, "VOUCHER_ERROR", err.Error())
+		h.writeErrorFor(w, err, "VOUCHER_ERROR")
 		return
 	}
 
-	dto := VoucherDTO{
-		VoucherID: voucher.VoucherID,
-		SuiOwner:  voucher.SuiOwner,
-		ChainID:   string(voucher.ChainID),
-		Asset:     voucher.Asset,
-		Shares:    voucher.Shares.String(),
-		Nonce:     voucher.Nonce,
-		Expiry:    voucher.Expiry.Unix(),
-		Status:    string(voucher.Status),
-		TxHash:    voucher.TxHash,
-		CreatedAt: voucher.CreatedAt.Unix(),
+	dto := voucherDTO(voucher)
+	h.writeJSON(w, http.StatusOK, VoucherResponse{Voucher: &dto})
+}
+
+// CancelVoucher cancels a pending voucher past its expiry.
+func (h *Handler) CancelVoucher(w http.ResponseWriter, r *http.Request) {
+	voucherID := chi.URLParam(r, "voucherId")
+	voucher, err := h.crosschainSvc.CancelVoucher(r.Context(), voucherID, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, crosschain.ErrNotFound):
+			h.writeError(w, http.StatusNotFound, "VOUCHER_NOT_FOUND", "voucher not found")
+		case errors.Is(err, crosschain.ErrInvalidRequest):
+			h.writeError(w, http.StatusConflict, "VOUCHER_NOT_CANCELLABLE", err.Error())
+		default:
+			h.writeErrorFor(w, err, "VOUCHER_ERROR")
+		}
+		return
 	}
 
+	h.logger.Warnw("Voucher cancelled", "voucherId", voucherID, "suiOwner", voucher.SuiOwner)
+
+	dto := voucherDTO(voucher)
 	h.writeJSON(w, http.StatusOK, VoucherResponse{Voucher: &dto})
 }
 
@@ -390,7 +824,7 @@ func (h *Handler) GetCollateralParams(w http.ResponseWriter, r *http.Request) {
 			h.writeError(w, http.StatusNotFound, "PARAMS_NOT_FOUND", "collateral params not found")
 			return
 		}
-		h.writeError(w, http.StatusInternalServerError, "PARAMS_ERROR", err.Error())
+		h.writeErrorFor(w, err, "PARAMS_ERROR")
 		return
 	}
 
@@ -424,7 +858,7 @@ func (h *Handler) GetVaultInfo(w http.ResponseWriter, r *http.Request) {
 			h.writeError(w, http.StatusNotFound, "VAULT_NOT_FOUND", "vault not found")
 			return
 		}
-		h.writeError(w, http.StatusInternalServerError, "VAULT_ERROR", err.Error())
+		h.writeErrorFor(w, err, "VAULT_ERROR")
 		return
 	}
 
@@ -432,6 +866,8 @@ func (h *Handler) GetVaultInfo(w http.ResponseWriter, r *http.Request) {
 		ChainID:           string(vault.ChainID),
 		Asset:             vault.Asset,
 		VaultAddress:      vault.VaultAddress,
+		TokenAddress:      vault.TokenAddress,
+		Decimals:          vault.Decimals,
 		DepositMemoFormat: vault.DepositMemoFormat,
 		FeedURL:           vault.FeedURL,
 		ProofCID:          vault.ProofCID,
@@ -441,6 +877,216 @@ func (h *Handler) GetVaultInfo(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, VaultInfoResponse{Vault: &dto})
 }
 
+func bridgeRetryDTO(retry *crosschain.BridgeRetry) BridgeRetryDTO {
+	return BridgeRetryDTO{
+		ID:            retry.ID,
+		Kind:          string(retry.Kind),
+		ReceiptID:     retry.ReceiptID,
+		Status:        string(retry.Status),
+		Attempts:      retry.Attempts,
+		LastError:     retry.LastError,
+		NextAttemptAt: retry.NextAttemptAt.Unix(),
+		CreatedAt:     retry.CreatedAt.Unix(),
+		UpdatedAt:     retry.UpdatedAt.Unix(),
+	}
+}
+
+// ListBridgeRetries lists queued bridge retries, the stuck ones by default.
+func (h *Handler) ListBridgeRetries(w http.ResponseWriter, r *http.Request) {
+	status := crosschain.RetryStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = crosschain.RetryStatusStuck
+	case crosschain.RetryStatusPending, crosschain.RetryStatusStuck, crosschain.RetryStatusDone:
+	default:
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "status must be pending, stuck or done")
+		return
+	}
+
+	retries, err := h.crosschainSvc.ListRetries(r.Context(), status)
+	if err != nil {
+		h.writeErrorFor(w, err, "RETRY_ERROR")
+		return
+	}
+
+	resp := BridgeRetryListResponse{Retries: make([]BridgeRetryDTO, 0, len(retries))}
+	for _, retry := range retries {
+		resp.Retries = append(resp.Retries, bridgeRetryDTO(retry))
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// RequeueBridgeRetry makes a stuck bridge retry pending again.
+func (h *Handler) RequeueBridgeRetry(w http.ResponseWriter, r *http.Request) {
+	retry, err := h.crosschainSvc.RequeueRetry(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, crosschain.ErrNotFound):
+			h.writeError(w, http.StatusNotFound, "RETRY_NOT_FOUND", "retry not found")
+		case errors.Is(err, crosschain.ErrInvalidRequest):
+			h.writeError(w, http.StatusConflict, "RETRY_DONE", err.Error())
+		default:
+			h.writeErrorFor(w, err, "RETRY_ERROR")
+		}
+		return
+	}
+
+	dto := bridgeRetryDTO(retry)
+	h.writeJSON(w, http.StatusOK, BridgeRetryResponse{Retry: &dto})
+}
+
+func bridgeControlDTO(control *crosschain.BridgeControl) BridgeControlDTO {
+	return BridgeControlDTO{
+		ChainID:           string(control.ChainID),
+		Asset:             control.Asset,
+		DepositsPaused:    control.DepositsPaused,
+		RedemptionsPaused: control.RedemptionsPaused,
+		MaxDeposit:        control.MaxDeposit.String(),
+		DailyDepositLimit: control.DailyDepositLimit.String(),
+		DailyVolume:       control.DailyVolume.String(),
+		VolumeDay:         control.VolumeDay,
+		MintFeeBps:        control.MintFeeBps,
+		RedeemFeeBps:      control.RedeemFeeBps,
+		UpdatedAt:         control.UpdatedAt.Unix(),
+	}
+}
+
+// ListBridgeControls lists the pauses and limits set by operators.
+func (h *Handler) ListBridgeControls(w http.ResponseWriter, r *http.Request) {
+	controls := h.crosschainSvc.ListBridgeControls(r.Context())
+	resp := BridgeControlListResponse{Controls: make([]BridgeControlDTO, 0, len(controls))}
+	for _, control := range controls {
+		resp.Controls = append(resp.Controls, bridgeControlDTO(control))
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// PauseBridge stops deposits and/or redemptions of a scope.
+func (h *Handler) PauseBridge(w http.ResponseWriter, r *http.Request) {
+	h.setBridgePaused(w, r, true)
+}
+
+// ResumeBridge lifts a pause set by PauseBridge.
+func (h *Handler) ResumeBridge(w http.ResponseWriter, r *http.Request) {
+	h.setBridgePaused(w, r, false)
+}
+
+func (h *Handler) setBridgePaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	var req BridgePauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid pause payload")
+		return
+	}
+
+	control, err := h.crosschainSvc.SetPaused(r.Context(), crosschain.ChainID(req.ChainID), req.Asset, crosschain.BridgeOperation(req.Operation), paused)
+	if err != nil {
+		if errors.Is(err, crosschain.ErrInvalidRequest) {
+			h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", err.Error())
+			return
+		}
+		h.writeErrorFor(w, err, "CONTROL_ERROR")
+		return
+	}
+
+	h.logger.Warnw("Bridge pause changed",
+		"chainId", req.ChainID,
+		"asset", req.Asset,
+		"operation", req.Operation,
+		"paused", paused,
+	)
+
+	dto := bridgeControlDTO(control)
+	h.writeJSON(w, http.StatusOK, BridgeControlResponse{Control: &dto})
+}
+
+// SetBridgeLimits sets the deposit limits of an asset.
+func (h *Handler) SetBridgeLimits(w http.ResponseWriter, r *http.Request) {
+	var req BridgeLimitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid limits payload")
+		return
+	}
+
+	limits := make([]decimal.Decimal, 2)
+	for i, value := range []string{req.MaxDeposit, req.DailyDepositLimit} {
+		if value == "" {
+			continue
+		}
+		d, err := decimal.NewFromString(value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "limits must be decimal strings")
+			return
+		}
+		limits[i] = d
+	}
+
+	control, err := h.crosschainSvc.SetDepositLimits(r.Context(), crosschain.ChainID(req.ChainID), req.Asset, limits[0], limits[1])
+	if err != nil {
+		if errors.Is(err, crosschain.ErrInvalidRequest) {
+			h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", err.Error())
+			return
+		}
+		h.writeErrorFor(w, err, "CONTROL_ERROR")
+		return
+	}
+
+	h.logger.Warnw("Bridge limits changed",
+		"chainId", req.ChainID,
+		"asset", req.Asset,
+		"maxDeposit", limits[0].String(),
+		"dailyDepositLimit", limits[1].String(),
+	)
+
+	dto := bridgeControlDTO(control)
+	h.writeJSON(w, http.StatusOK, BridgeControlResponse{Control: &dto})
+}
+
+// ListBridgeFees lists the fees of each asset and what they have collected.
+func (h *Handler) ListBridgeFees(w http.ResponseWriter, r *http.Request) {
+	fees := h.crosschainSvc.ListBridgeFees(r.Context())
+	resp := BridgeFeesListResponse{Fees: make([]BridgeFeesDTO, 0, len(fees))}
+	for _, f := range fees {
+		resp.Fees = append(resp.Fees, BridgeFeesDTO{
+			ChainID:      string(f.ChainID),
+			Asset:        f.Asset,
+			MintFeeBps:   f.MintFeeBps,
+			RedeemFeeBps: f.RedeemFeeBps,
+			MintFees:     f.MintFees.String(),
+			RedeemFees:   f.RedeemFees.String(),
+		})
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// SetBridgeFees sets the mint and redeem fees of an asset.
+func (h *Handler) SetBridgeFees(w http.ResponseWriter, r *http.Request) {
+	var req BridgeFeesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid fees payload")
+		return
+	}
+
+	control, err := h.crosschainSvc.SetFees(r.Context(), crosschain.ChainID(req.ChainID), req.Asset, req.MintFeeBps, req.RedeemFeeBps)
+	if err != nil {
+		if errors.Is(err, crosschain.ErrInvalidRequest) {
+			h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", err.Error())
+			return
+		}
+		h.writeErrorFor(w, err, "CONTROL_ERROR")
+		return
+	}
+
+	h.logger.Warnw("Bridge fees changed",
+		"chainId", req.ChainID,
+		"asset", req.Asset,
+		"mintFeeBps", req.MintFeeBps,
+		"redeemFeeBps", req.RedeemFeeBps,
+	)
+
+	dto := bridgeControlDTO(control)
+	h.writeJSON(w, http.StatusOK, BridgeControlResponse{Control: &dto})
+}
+
 func (h *Handler) ListMarkets(w http.ResponseWriter, _ *http.Request) {
 	if h.marketsSvc == nil {
 		h.writeError(w, http.StatusInternalServerError, "MARKETS_ERROR", "markets service unavailable")
@@ -448,3 +1094,111 @@ func (h *Handler) ListMarkets(w http.ResponseWriter, _ *http.Request) {
 	}
 	h.writeJSON(w, http.StatusOK, h.marketsSvc.List())
 }
+
+func bridgeRefundDTO(refund *crosschain.BridgeRefund) BridgeRefundDTO {
+	return BridgeRefundDTO{
+		ReceiptID:    refund.ID,
+		ChainID:      string(refund.ChainID),
+		Asset:        refund.Asset,
+		SuiOwner:     refund.SuiOwner,
+		Token:        refund.Token,
+		Amount:       refund.Amount.String(),
+		Fee:          refund.Fee.String(),
+		Status:       string(refund.Status),
+		Reason:       refund.Reason,
+		Credited:     refund.Credited,
+		SuiTxDigests: refund.SuiTxDigests,
+		LastError:    refund.LastError,
+		CreatedAt:    refund.CreatedAt.Unix(),
+		UpdatedAt:    refund.UpdatedAt.Unix(),
+	}
+}
+
+// ListBridgeRefunds lists refunds of failed redeems, the pending ones by
+// default.
+func (h *Handler) ListBridgeRefunds(w http.ResponseWriter, r *http.Request) {
+	status := crosschain.RefundStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = crosschain.RefundStatusPending
+	case crosschain.RefundStatusPending, crosschain.RefundStatusRefunded, crosschain.RefundStatusRejected:
+	default:
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "status must be pending, refunded or rejected")
+		return
+	}
+
+	refunds, err := h.crosschainSvc.ListRefunds(r.Context(), status)
+	if err != nil {
+		h.writeErrorFor(w, err, "REFUND_ERROR")
+		return
+	}
+
+	resp := BridgeRefundListResponse{Refunds: make([]BridgeRefundDTO, 0, len(refunds))}
+	for _, refund := range refunds {
+		resp.Refunds = append(resp.Refunds, bridgeRefundDTO(refund))
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// ApproveBridgeRefund mints the burned tokens of a failed redeem back on Sui.
+func (h *Handler) ApproveBridgeRefund(w http.ResponseWriter, r *http.Request) {
+	if h.bridgeWorker == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "BRIDGE_UNAVAILABLE", "bridge worker not configured")
+		return
+	}
+
+	receiptID := chi.URLParam(r, "receiptId")
+	refund, err := h.bridgeWorker.ApproveRefund(r.Context(), receiptID)
+	if err != nil {
+		h.writeRefundError(w, err)
+		return
+	}
+
+	h.logger.Warnw("Bridge refund approved", "receiptId", receiptID, "suiTxDigests", refund.SuiTxDigests)
+
+	dto := bridgeRefundDTO(refund)
+	h.writeJSON(w, http.StatusOK, BridgeRefundResponse{Refund: &dto})
+}
+
+// RejectBridgeRefund settles a pending refund without minting.
+func (h *Handler) RejectBridgeRefund(w http.ResponseWriter, r *http.Request) {
+	var req BridgeRefundRejectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reason == "" {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "a rejection needs a reason")
+		return
+	}
+
+	receiptID := chi.URLParam(r, "receiptId")
+	refund, err := h.crosschainSvc.RejectRefund(r.Context(), receiptID, req.Reason)
+	if err != nil {
+		h.writeRefundError(w, err)
+		return
+	}
+
+	h.logger.Warnw("Bridge refund rejected", "receiptId", receiptID, "reason", req.Reason)
+
+	dto := bridgeRefundDTO(refund)
+	h.writeJSON(w, http.StatusOK, BridgeRefundResponse{Refund: &dto})
+}
+
+func (h *Handler) writeRefundError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, crosschain.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "REFUND_NOT_FOUND", "refund not found")
+	case errors.Is(err, crosschain.ErrInvalidRequest):
+		h.writeError(w, http.StatusConflict, "REFUND_SETTLED", err.Error())
+	default:
+		h.writeErrorFor(w, err, "REFUND_ERROR")
+	}
+}
+
+// writeRateLimitError answers a submission over its owner's rate limits
+// with 429 and the time until it would fit
+func writeRateLimitError(w http.ResponseWriter, err error) {
+	var hint BackoffHint
+	var limitErr *crosschain.RateLimitError
+	if errors.As(err, &limitErr) {
+		hint.RetryAfter = limitErr.RetryAfter
+	}
+	writeBackoffError(w, http.StatusTooManyRequests, "RATE_LIMITED", err.Error(), hint)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	"github.com/shopspring/decimal"
+)
+
+// AcceptCrossChainDeposit accepts a deposit for processing in the
+// background and answers 202 with the URL of its status, rather than
+// waiting for the mint like SubmitCrossChainDeposit
+func (h *Handler) AcceptCrossChainDeposit(w http.ResponseWriter, r *http.Request) {
+	if h.bridgeWorker == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "BRIDGE_UNAVAILABLE", "bridge worker not configured")
+		return
+	}
+
+	var req BridgeDepositRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid deposit payload")
+		return
+	}
+	if !h.authorizeOwner(w, r, req.SuiOwner) {
+		return
+	}
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+	amount := decimal.RequireFromString(req.Amount)
+
+	submission, err := h.bridgeWorker.Accept(r.Context(), crosschain.DepositSubmission{
+		TxHash:   req.TxHash,
+		LogIndex: req.LogIndex,
+		SuiOwner: req.SuiOwner,
+		ChainID:  crosschain.ChainID(req.ChainID),
+		Asset:    req.Asset,
+		Amount:   amount,
+	})
+	if err != nil {
+		h.writeBridgeSubmissionError(w, err)
+		return
+	}
+
+	h.logger.Infow("Bridge deposit accepted",
+		"txHash", req.TxHash,
+		"logIndex", req.LogIndex,
+		"suiOwner", req.SuiOwner,
+		"chainId", req.ChainID,
+		"asset", req.Asset,
+		"amount", amount.String(),
+		"receiptId", submission.ReceiptID,
+	)
+	h.writeAccepted(w, r, submission)
+}
+
+// AcceptCrossChainRedeem accepts a redeem for processing in the background
+// and answers 202 with the URL of its status
+func (h *Handler) AcceptCrossChainRedeem(w http.ResponseWriter, r *http.Request) {
+	if h.bridgeWorker == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "BRIDGE_UNAVAILABLE", "bridge worker not configured")
+		return
+	}
+
+	var req BridgeRedeemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid redeem payload")
+		return
+	}
+	if !h.authorizeOwner(w, r, req.SuiOwner) {
+		return
+	}
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+	amount := decimal.RequireFromString(req.Amount)
+
+	token := strings.ToLower(strings.TrimSpace(req.Token))
+	if token != "f" && token != "x" {
+		h.writeError(w, http.StatusBadRequest, "INVALID_TOKEN", "token must be 'f' or 'x'")
+		return
+	}
+
+	submission, err := h.bridgeWorker.AcceptRedeem(r.Context(), crosschain.RedeemSubmission{
+		SuiTxDigest:  req.SuiTxDigest,
+		SuiOwner:     req.SuiOwner,
+		EthRecipient: req.EthRecipient,
+		ChainID:      crosschain.ChainID(req.ChainID),
+		Asset:        req.Asset,
+		Token:        token,
+		Amount:       amount,
+	})
+	if err != nil {
+		h.writeBridgeSubmissionError(w, err)
+		return
+	}
+
+	h.logger.Infow("Bridge redeem accepted",
+		"suiTxDigest", req.SuiTxDigest,
+		"suiOwner", req.SuiOwner,
+		"chainId", req.ChainID,
+		"asset", req.Asset,
+		"token", token,
+		"amount", amount.String(),
+		"receiptId", submission.ReceiptID,
+	)
+	h.writeAccepted(w, r, submission)
+}
+
+// GetCrossChainDepositStatus returns the receipt of an accepted deposit
+// with its transitions, or its submission until the receipt is recorded
+func (h *Handler) GetCrossChainDepositStatus(w http.ResponseWriter, r *http.Request) {
+	h.writeSubmissionStatus(w, r, crosschain.ReceiptKindDeposit)
+}
+
+// GetCrossChainRedeemStatus returns the receipt of an accepted redeem like
+// GetCrossChainDepositStatus
+func (h *Handler) GetCrossChainRedeemStatus(w http.ResponseWriter, r *http.Request) {
+	h.writeSubmissionStatus(w, r, crosschain.ReceiptKindRedeem)
+}
+
+// writeAccepted answers 202 for submission, pointing Location at its status
+func (h *Handler) writeAccepted(w http.ResponseWriter, r *http.Request, submission *crosschain.Submission) {
+	statusURL := strings.TrimSuffix(r.URL.Path, "/") + "/" + submission.ReceiptID
+	w.Header().Set("Location", statusURL)
+	h.writeJSON(w, http.StatusAccepted, BridgeSubmissionResponse{
+		ReceiptID: submission.ReceiptID,
+		Kind:      string(submission.Kind),
+		Status:    string(submission.State),
+		StatusURL: statusURL,
+	})
+}
+
+// writeSubmissionStatus writes the receipt of kind with the receiptId of
+// r. Until the worker records it, the accepted submission stands in for
+// it, with the stages it went through so far.
+func (h *Handler) writeSubmissionStatus(w http.ResponseWriter, r *http.Request, kind crosschain.ReceiptKind) {
+	receiptID := chi.URLParam(r, "receiptId")
+	notFound := func() {
+		h.writeError(w, http.StatusNotFound, "RECEIPT_NOT_FOUND", "receipt not found")
+	}
+
+	var dto ReceiptDTO
+	receipt, err := h.crosschainSvc.GetReceipt(r.Context(), receiptID)
+	switch {
+	case err == nil:
+		if receipt.Kind != kind {
+			notFound()
+			return
+		}
+		dto = receiptDTO(receipt)
+	case errors.Is(err, crosschain.ErrNotFound):
+		if h.bridgeWorker == nil {
+			notFound()
+			return
+		}
+		submission, err := h.bridgeWorker.Submission(r.Context(), receiptID)
+		if errors.Is(err, crosschain.ErrNotFound) || (err == nil && submission.Kind != kind) {
+			notFound()
+			return
+		}
+		if err != nil {
+			h.writeErrorFor(w, err, "RECEIPT_ERROR")
+			return
+		}
+		dto = ReceiptDTO{
+			Kind:      string(submission.Kind),
+			ReceiptID: submission.ReceiptID,
+			Status:    string(submission.State),
+			CreatedAt: submission.AcceptedAt.Unix(),
+			Error:     submission.Error,
+		}
+	default:
+		h.writeErrorFor(w, err, "RECEIPT_ERROR")
+		return
+	}
+
+	if dto.Transitions, err = h.receiptTransitions(r, receiptID); err != nil {
+		h.writeErrorFor(w, err, "RECEIPT_ERROR")
+		return
+	}
+	if dto.Stage == "" && len(dto.Transitions) > 0 {
+		dto.Stage = dto.Transitions[len(dto.Transitions)-1].To
+	}
+	h.writeJSON(w, http.StatusOK, ReceiptResponse{Receipt: dto})
+}
+
+// writeBridgeSubmissionError maps the errors of accepting a submission
+func (h *Handler) writeBridgeSubmissionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, crosschain.ErrInvalidRequest):
+		h.writeError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	case errors.Is(err, crosschain.ErrDepositInProgress):
+		h.writeError(w, http.StatusConflict, "DEPOSIT_IN_PROGRESS", "deposit is already being processed")
+	case errors.Is(err, crosschain.ErrBridgePaused):
+		h.writeError(w, http.StatusServiceUnavailable, "BRIDGE_PAUSED", err.Error())
+	case errors.Is(err, crosschain.ErrLimitExceeded):
+		h.writeError(w, http.StatusUnprocessableEntity, "LIMIT_EXCEEDED", err.Error())
+	case errors.Is(err, crosschain.ErrRateLimited):
+		writeRateLimitError(w, err)
+	default:
+		h.writeErrorFor(w, err, "BRIDGE_ERROR")
+	}
+}
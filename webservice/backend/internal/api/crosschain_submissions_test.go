@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fixedBridgePrice struct{}
+
+func (fixedBridgePrice) USDPrice(_ context.Context, symbol string) (crosschain.PriceQuote, error) {
+	return crosschain.PriceQuote{Source: "fixed", Symbol: symbol, Price: decimal.NewFromInt(2000), ObservedAt: time.Now()}, nil
+}
+
+func TestBridgeSubmissionEndpoints(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := zap.NewNop().Sugar()
+	svc := crosschain.NewService(logger)
+	require.NoError(t, svc.Load(ctx))
+	worker := crosschain.NewBridgeWorker(svc, logger, crosschain.WithPriceSource(fixedBridgePrice{}))
+	worker.Start(ctx)
+
+	h := &Handler{logger: logger, metrics: &MockMetrics{}, crosschainSvc: svc, bridgeWorker: worker}
+	r := chi.NewRouter()
+	r.Post("/v1/crosschain/deposits", h.AcceptCrossChainDeposit)
+	r.Get("/v1/crosschain/deposits/{receiptId}", h.GetCrossChainDepositStatus)
+	r.Post("/v1/crosschain/redeems", h.AcceptCrossChainRedeem)
+	r.Get("/v1/crosschain/redeems/{receiptId}", h.GetCrossChainRedeemStatus)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		return rec
+	}
+	status := func(url string) ReceiptDTO {
+		rec := serve(http.MethodGet, url, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp ReceiptResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Receipt
+	}
+
+	rec := serve(http.MethodPost, "/v1/crosschain/deposits", `{"txHash":"0xabc","logIndex":0,"suiOwner":"0xa11","chainId":"ethereum","asset":"ETH","amount":"0.5"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var accepted BridgeSubmissionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &accepted))
+	assert.Equal(t, "deposit", accepted.Kind)
+	assert.Equal(t, "queued", accepted.Status)
+	assert.Equal(t, "/v1/crosschain/deposits/"+accepted.ReceiptID, accepted.StatusURL)
+	assert.Equal(t, accepted.StatusURL, rec.Header().Get("Location"))
+
+	// The status follows the receipt through its stages
+	require.Eventually(t, func() bool {
+		return status(accepted.StatusURL).Status == string(crosschain.DepositStatusMinted)
+	}, 5*time.Second, 10*time.Millisecond)
+	receipt := status(accepted.StatusURL)
+	require.NotNil(t, receipt.Deposit)
+	assert.Equal(t, "0.5", receipt.Deposit.Amount)
+	require.NotEmpty(t, receipt.Transitions)
+	assert.Equal(t, string(crosschain.StageDetected), receipt.Transitions[0].To)
+
+	// Receipts of the other kind are not found
+	rec = serve(http.MethodGet, "/v1/crosschain/redeems/"+accepted.ReceiptID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// A redeem without shares to burn fails in the background
+	rec = serve(http.MethodPost, "/v1/crosschain/redeems", `{"suiTxDigest":"digest","suiOwner":"0xb0b","ethRecipient":"0x000000000000000000000000000000000000b0b0","chainId":"ethereum","asset":"ETH","token":"x","amount":"1"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &accepted))
+	require.Eventually(t, func() bool {
+		return status(accepted.StatusURL).Status == string(crosschain.SubmissionFailed)
+	}, 5*time.Second, 10*time.Millisecond)
+	receipt = status(accepted.StatusURL)
+	assert.NotEmpty(t, receipt.Error)
+	assert.Equal(t, string(crosschain.StageFailed), receipt.Stage)
+
+	for body, code := range map[string]string{
+		`{"suiOwner":"0xa11","chainId":"ethereum","asset":"DOGE","amount":"1"}`: "INVALID_REQUEST",
+		`{"suiOwner":"0xa11","chainId":"ethereum","asset":"ETH","amount":"-1"}`: "INVALID_AMOUNT",
+	} {
+		rec := serve(http.MethodPost, "/v1/crosschain/deposits", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), code, body)
+	}
+	rec = serve(http.MethodGet, "/v1/crosschain/deposits/bridge_999", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -21,6 +21,45 @@ type WalrusCheckpointResponse struct {
 	Checkpoint *WalrusCheckpointDTO `json:"checkpoint,omitempty"`
 }
 
+type CheckpointVerificationDTO struct {
+	UpdateID           uint64 `json:"updateId"`
+	WalrusBlobID       string `json:"walrusBlobId"`
+	LocalHash          string `json:"localHash"`
+	RemoteHash         string `json:"remoteHash,omitempty"`
+	BalancesRoot       string `json:"balancesRoot"`
+	RemoteBalancesRoot string `json:"remoteBalancesRoot,omitempty"`
+	Verified           bool   `json:"verified"`
+	Reason             string `json:"reason,omitempty"`
+}
+
+type CheckpointVerificationResponse struct {
+	Verification CheckpointVerificationDTO `json:"verification"`
+}
+
+type CheckpointAttestationDTO struct {
+	UpdateID  uint64 `json:"updateId"`
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+type AttestationStatusDTO struct {
+	UpdateID     uint64                     `json:"updateId"`
+	Digest       string                     `json:"digest"`
+	Threshold    int                        `json:"threshold"`
+	Signers      []string                   `json:"signers"`
+	Attestations []CheckpointAttestationDTO `json:"attestations"`
+	Status       string                     `json:"status"`
+}
+
+type AttestationStatusResponse struct {
+	Attestation AttestationStatusDTO `json:"attestation"`
+}
+
+type AddAttestationRequest struct {
+	Signature string `json:"signature"` // 0x-prefixed r || s || v over the digest
+}
+
 type SubmitCheckpointRequest struct {
 	ChainID      string `json:"chainId"`
 	Asset        string `json:"asset"`
@@ -51,37 +90,67 @@ type CrossChainBalanceResponse struct {
 	Balance CrossChainBalanceDTO `json:"balance"`
 }
 
+type BalanceProofDTO struct {
+	SuiOwner  string   `json:"suiOwner"`
+	ChainID   string   `json:"chainId"`
+	Asset     string   `json:"asset"`
+	Shares    string   `json:"shares"`
+	LeafIndex int      `json:"leafIndex"`
+	LeafCount int      `json:"leafCount"`
+	Siblings  []string `json:"siblings"`
+	Root      string   `json:"root"`
+	UpdateID  uint64   `json:"updateId,omitempty"`
+}
+
+type BalanceProofResponse struct {
+	Proof BalanceProofDTO `json:"proof"`
+}
+
 type CreateVoucherRequest struct {
 	SuiOwner string `json:"suiOwner"`
 	ChainID  string `json:"chainId"`
 	Asset    string `json:"asset"`
 	Shares   string `json:"shares"`
-	Expiry   int64  `json:"expiry"`
+	Redeemer string `json:"redeemer,omitempty"` // Defaults to the bridge signer
+	Expiry   int64  `json:"expiry,omitempty"`   // Unix seconds; defaults to the voucher TTL
 }
 
 type BridgeDepositRequest struct {
 	TxHash   string `json:"txHash"`
+	LogIndex uint64 `json:"logIndex"`
 	SuiOwner string `json:"suiOwner"`
 	ChainID  string `json:"chainId"`
 	Asset    string `json:"asset"`
-	Amount   string `json:"amount"`
+	Amount   string `json:"amount" validate:"required,amount" code:"INVALID_AMOUNT"`
 }
 
 type BridgeReceiptDTO struct {
-	ReceiptID    string   `json:"receiptId"`
-	TxHash       string   `json:"txHash,omitempty"`
-	SuiOwner     string   `json:"suiOwner"`
-	ChainID      string   `json:"chainId"`
-	Asset        string   `json:"asset"`
-	Minted       string   `json:"minted"`
-	CreatedAt    int64    `json:"createdAt"`
-	SuiTxDigests []string `json:"suiTxDigests,omitempty"`
+	ReceiptID    string    `json:"receiptId"`
+	TxHash       string    `json:"txHash,omitempty"`
+	LogIndex     uint64    `json:"logIndex"`
+	BlockNumber  uint64    `json:"blockNumber,omitempty"`
+	SuiOwner     string    `json:"suiOwner"`
+	ChainID      string    `json:"chainId"`
+	Asset        string    `json:"asset"`
+	Minted       string    `json:"minted"`
+	Amount       string    `json:"amount,omitempty"`
+	Shares       string    `json:"shares,omitempty"`
+	Fee          string    `json:"fee"`
+	Price        *PriceDTO `json:"price,omitempty"`
+	Status       string    `json:"status"`
+	Stage        string    `json:"stage,omitempty"`
+	CreatedAt    int64     `json:"createdAt"`
+	SuiTxDigests []string  `json:"suiTxDigests,omitempty"`
 }
 
 type BridgeReceiptResponse struct {
 	Receipt BridgeReceiptDTO `json:"receipt"`
 }
 
+type BridgeReceiptListResponse struct {
+	Receipts []BridgeReceiptDTO `json:"receipts"`
+}
+
 type BridgeRedeemRequest struct {
 	SuiTxDigest  string `json:"suiTxDigest"`
 	SuiOwner     string `json:"suiOwner"`
@@ -89,29 +158,104 @@ type BridgeRedeemRequest struct {
 	ChainID      string `json:"chainId"`
 	Asset        string `json:"asset"`
 	Token        string `json:"token"`
-	Amount       string `json:"amount"`
+	Amount       string `json:"amount" validate:"required,amount" code:"INVALID_AMOUNT"`
 }
 
 type RedeemReceiptDTO struct {
-	ReceiptID      string `json:"receiptId"`
-	SuiTxDigest    string `json:"suiTxDigest"`
-	SuiOwner       string `json:"suiOwner"`
-	EthRecipient   string `json:"ethRecipient"`
-	ChainID        string `json:"chainId"`
-	Asset          string `json:"asset"`
-	Token          string `json:"token"`
-	Burned         string `json:"burned"`
-	PayoutEth      string `json:"payoutEth"`
-	WalrusUpdateID uint64 `json:"walrusUpdateId,omitempty"`
-	WalrusBlobID   string `json:"walrusBlobId,omitempty"`
-	PayoutTxHash   string `json:"payoutTxHash,omitempty"`
-	CreatedAt      int64  `json:"createdAt"`
+	ReceiptID      string    `json:"receiptId"`
+	SuiTxDigest    string    `json:"suiTxDigest"`
+	SuiOwner       string    `json:"suiOwner"`
+	EthRecipient   string    `json:"ethRecipient"`
+	ChainID        string    `json:"chainId"`
+	Asset          string    `json:"asset"`
+	Token          string    `json:"token"`
+	Burned         string    `json:"burned"`
+	PayoutEth      string    `json:"payoutEth"`
+	Fee            string    `json:"fee,omitempty"`
+	Price          *PriceDTO `json:"price,omitempty"`
+	WalrusUpdateID uint64    `json:"walrusUpdateId,omitempty"`
+	WalrusBlobID   string    `json:"walrusBlobId,omitempty"`
+	PayoutTxHash   string    `json:"payoutTxHash,omitempty"`
+	Status         string    `json:"status"`
+	Stage          string    `json:"stage,omitempty"`
+	CreatedAt      int64     `json:"createdAt"`
 }
 
 type RedeemReceiptResponse struct {
 	Receipt RedeemReceiptDTO `json:"receipt"`
 }
 
+// BridgeSubmissionResponse acknowledges a deposit or redeem accepted for
+// processing. StatusURL reports its receipt as it moves through its stages.
+type BridgeSubmissionResponse struct {
+	ReceiptID string `json:"receiptId"`
+	Kind      string `json:"kind"`
+	Status    string `json:"status"` // queued, or processed for a deposit minted before
+	StatusURL string `json:"statusUrl"`
+}
+
+// PriceDTO is the USD quote a receipt was priced with
+type PriceDTO struct {
+	Source     string `json:"source"`
+	Symbol     string `json:"symbol"`
+	Price      string `json:"price"`
+	ObservedAt int64  `json:"observedAt"`
+}
+
+// ReceiptRecomputationDTO compares the amounts of a receipt with those
+// derived again from its price; deposits set the mint fields and redeems
+// the payout fields.
+type ReceiptRecomputationDTO struct {
+	ReceiptID      string   `json:"receiptId"`
+	Kind           string   `json:"kind"`
+	Price          PriceDTO `json:"price"`
+	MintF          string   `json:"mintF,omitempty"`
+	MintX          string   `json:"mintX,omitempty"`
+	Shares         string   `json:"shares,omitempty"`
+	ExpectedMintF  string   `json:"expectedMintF,omitempty"`
+	ExpectedMintX  string   `json:"expectedMintX,omitempty"`
+	ExpectedShares string   `json:"expectedShares,omitempty"`
+	Payout         string   `json:"payout,omitempty"`
+	ExpectedPayout string   `json:"expectedPayout,omitempty"`
+	Matches        bool     `json:"matches"`
+}
+
+type ReceiptRecomputationResponse struct {
+	Recomputation ReceiptRecomputationDTO `json:"recomputation"`
+}
+
+// ReceiptDTO is a deposit or redeem receipt; the one matching Kind is set.
+// Transitions are only returned for a single receipt. An accepted
+// submission not recorded yet has neither, its Status being queued or
+// failed with Error.
+type ReceiptDTO struct {
+	Kind        string                 `json:"kind"`
+	ReceiptID   string                 `json:"receiptId"`
+	Status      string                 `json:"status"`
+	Stage       string                 `json:"stage,omitempty"`
+	CreatedAt   int64                  `json:"createdAt"`
+	Deposit     *BridgeReceiptDTO      `json:"deposit,omitempty"`
+	Redeem      *RedeemReceiptDTO      `json:"redeem,omitempty"`
+	Transitions []ReceiptTransitionDTO `json:"transitions,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+type ReceiptTransitionDTO struct {
+	From      string `json:"from,omitempty"`
+	To        string `json:"to"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+type ReceiptResponse struct {
+	Receipt ReceiptDTO `json:"receipt"`
+}
+
+type ReceiptListResponse struct {
+	Receipts   []ReceiptDTO `json:"receipts"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
 type VoucherDTO struct {
 	VoucherID string `json:"voucherId"`
 	SuiOwner  string `json:"suiOwner"`
@@ -120,6 +264,10 @@ type VoucherDTO struct {
 	Shares    string `json:"shares"`
 	Nonce     uint64 `json:"nonce"`
 	Expiry    int64  `json:"expiry"`
+	UpdateID  uint64 `json:"updateId"`
+	Redeemer  string `json:"redeemer,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	Signature string `json:"signature,omitempty"`
 	Status    string `json:"status"`
 	TxHash    string `json:"txHash,omitempty"`
 	CreatedAt int64  `json:"createdAt"`
@@ -154,6 +302,8 @@ type VaultInfoDTO struct {
 	ChainID           string `json:"chainId"`
 	Asset             string `json:"asset"`
 	VaultAddress      string `json:"vaultAddress"`
+	TokenAddress      string `json:"tokenAddress,omitempty"`
+	Decimals          int32  `json:"decimals"`
 	DepositMemoFormat string `json:"depositMemoFormat"`
 	FeedURL           string `json:"feedUrl,omitempty"`
 	ProofCID          string `json:"proofCid,omitempty"`
@@ -163,3 +313,118 @@ type VaultInfoDTO struct {
 type VaultInfoResponse struct {
 	Vault *VaultInfoDTO `json:"vault,omitempty"`
 }
+
+type BridgeRetryDTO struct {
+	ID            string `json:"id"`
+	Kind          string `json:"kind"`
+	ReceiptID     string `json:"receiptId"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	LastError     string `json:"lastError,omitempty"`
+	NextAttemptAt int64  `json:"nextAttemptAt"`
+	CreatedAt     int64  `json:"createdAt"`
+	UpdatedAt     int64  `json:"updatedAt"`
+}
+
+type BridgeRetryListResponse struct {
+	Retries []BridgeRetryDTO `json:"retries"`
+}
+
+type BridgeRetryResponse struct {
+	Retry *BridgeRetryDTO `json:"retry,omitempty"`
+}
+
+// BridgePauseRequest selects the scope of a pause: every chain without
+// chainId, every asset of the chain without asset. Operation is "deposit",
+// "redeem", or empty for both.
+type BridgePauseRequest struct {
+	ChainID   string `json:"chainId"`
+	Asset     string `json:"asset"`
+	Operation string `json:"operation"`
+}
+
+// BridgeLimitsRequest sets the deposit limits of an asset, in asset units.
+// Empty or "0" removes a limit.
+type BridgeLimitsRequest struct {
+	ChainID           string `json:"chainId"`
+	Asset             string `json:"asset"`
+	MaxDeposit        string `json:"maxDeposit"`
+	DailyDepositLimit string `json:"dailyDepositLimit"`
+}
+
+type BridgeControlDTO struct {
+	ChainID           string `json:"chainId,omitempty"`
+	Asset             string `json:"asset,omitempty"`
+	DepositsPaused    bool   `json:"depositsPaused"`
+	RedemptionsPaused bool   `json:"redemptionsPaused"`
+	MaxDeposit        string `json:"maxDeposit"`
+	DailyDepositLimit string `json:"dailyDepositLimit"`
+	DailyVolume       string `json:"dailyVolume"`
+	VolumeDay         string `json:"volumeDay,omitempty"`
+	MintFeeBps        int    `json:"mintFeeBps"`
+	RedeemFeeBps      int    `json:"redeemFeeBps"`
+	UpdatedAt         int64  `json:"updatedAt"`
+}
+
+type BridgeControlListResponse struct {
+	Controls []BridgeControlDTO `json:"controls"`
+}
+
+type BridgeControlResponse struct {
+	Control *BridgeControlDTO `json:"control,omitempty"`
+}
+
+// BridgeFeesRequest sets the fees of an asset in basis points; 0 removes a
+// fee.
+type BridgeFeesRequest struct {
+	ChainID      string `json:"chainId"`
+	Asset        string `json:"asset"`
+	MintFeeBps   int    `json:"mintFeeBps"`
+	RedeemFeeBps int    `json:"redeemFeeBps"`
+}
+
+// BridgeFeesDTO is the fee configuration of an asset and the fees it has
+// collected, in asset units
+type BridgeFeesDTO struct {
+	ChainID      string `json:"chainId"`
+	Asset        string `json:"asset"`
+	MintFeeBps   int    `json:"mintFeeBps"`
+	RedeemFeeBps int    `json:"redeemFeeBps"`
+	MintFees     string `json:"mintFees"`
+	RedeemFees   string `json:"redeemFees"`
+}
+
+type BridgeFeesListResponse struct {
+	Fees []BridgeFeesDTO `json:"fees"`
+}
+
+// BridgeRefundRejectRequest gives the reason a refund was settled without
+// minting.
+type BridgeRefundRejectRequest struct {
+	Reason string `json:"reason"`
+}
+
+type BridgeRefundDTO struct {
+	ReceiptID    string   `json:"receiptId"`
+	ChainID      string   `json:"chainId"`
+	Asset        string   `json:"asset"`
+	SuiOwner     string   `json:"suiOwner"`
+	Token        string   `json:"token"`
+	Amount       string   `json:"amount"`
+	Fee          string   `json:"fee"`
+	Status       string   `json:"status"`
+	Reason       string   `json:"reason,omitempty"`
+	Credited     bool     `json:"credited"`
+	SuiTxDigests []string `json:"suiTxDigests,omitempty"`
+	LastError    string   `json:"lastError,omitempty"`
+	CreatedAt    int64    `json:"createdAt"`
+	UpdatedAt    int64    `json:"updatedAt"`
+}
+
+type BridgeRefundListResponse struct {
+	Refunds []BridgeRefundDTO `json:"refunds"`
+}
+
+type BridgeRefundResponse struct {
+	Refund *BridgeRefundDTO `json:"refund,omitempty"`
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/apikeys"
+	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/jobs"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/watchlist"
+	"github.com/leafsii/leafsii-backend/internal/webhooks"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+)
+
+// ContentTypeProblem is the media type of error responses, RFC 7807
+// problem details
+const ContentTypeProblem = "application/problem+json"
+
+// HeaderRequestID carries the ID RequestID gives each request
+const HeaderRequestID = "X-Request-ID"
+
+// errorDocsPath serves the error catalog; the problem type of a code links
+// to its entry under it
+const errorDocsPath = "/v1/errors"
+
+// ErrorCode is an entry of the error catalog. Status is the one the code
+// is usually answered with. Message is the detail of internal errors mapped
+// to the code, where %s is the error.
+type ErrorCode struct {
+	Code    string `json:"code"`
+	Status  int    `json:"status"`
+	Title   string `json:"title"`
+	Message string `json:"message,omitempty"`
+	Docs    string `json:"docs"`
+}
+
+// errorCatalog lists the codes of error responses. TestErrorCatalog keeps
+// it in sync with the handlers.
+var errorCatalog = newErrorCatalog([]ErrorCode{
+	// Generic errors internal ones are mapped to
+	{Code: "NOT_FOUND", Status: http.StatusNotFound, Title: "Not found", Message: "Not found: %s"},
+	{Code: "INVALID_REQUEST", Status: http.StatusBadRequest, Title: "Invalid request", Message: "Invalid request: %s"},
+	{Code: "MOVE_ABORT", Status: http.StatusUnprocessableEntity, Title: "Transaction aborted in Move"},
+	{Code: "INTERNAL_ERROR", Status: http.StatusInternalServerError, Title: "Internal error", Message: "Internal server error"},
+	{Code: "SERVICE_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Service unavailable"},
+	{Code: "RATE_LIMITED", Status: http.StatusTooManyRequests, Title: "Rate limit exceeded"},
+
+	// Requests
+	{Code: "INVALID_JSON", Status: http.StatusBadRequest, Title: "Malformed JSON body"},
+	{Code: "INVALID_BODY", Status: http.StatusBadRequest, Title: "Unreadable request body"},
+	{Code: "REQUEST_TOO_LARGE", Status: http.StatusRequestEntityTooLarge, Title: "Request body too large"},
+	{Code: "MISSING_PARAMETER", Status: http.StatusBadRequest, Title: "Missing parameter"},
+	{Code: "INVALID_PARAMETER", Status: http.StatusBadRequest, Title: "Invalid parameter"},
+	{Code: "INVALID_AMOUNT", Status: http.StatusBadRequest, Title: "Invalid amount"},
+	{Code: "INVALID_ADDRESS", Status: http.StatusBadRequest, Title: "Invalid address"},
+	{Code: "INVALID_USER_ADDRESS", Status: http.StatusBadRequest, Title: "Invalid user address"},
+	{Code: "MISSING_USER_ADDRESS", Status: http.StatusBadRequest, Title: "Missing user address"},
+	{Code: "INVALID_SENDER", Status: http.StatusBadRequest, Title: "Invalid sender"},
+	{Code: "INVALID_ACTION", Status: http.StatusBadRequest, Title: "Unknown action"},
+	{Code: "INVALID_TOKEN_TYPE", Status: http.StatusBadRequest, Title: "Unknown token type"},
+	{Code: "INVALID_MODE", Status: http.StatusBadRequest, Title: "Unknown mode"},
+	{Code: "INVALID_PAIR", Status: http.StatusBadRequest, Title: "Unknown pair"},
+	{Code: "INVALID_INTERVAL", Status: http.StatusBadRequest, Title: "Unknown interval"},
+	{Code: "INVALID_METRIC", Status: http.StatusBadRequest, Title: "Unknown metric"},
+	{Code: "INVALID_RANGE", Status: http.StatusBadRequest, Title: "Invalid time range"},
+	{Code: "INVALID_CURSOR", Status: http.StatusBadRequest, Title: "Invalid cursor"},
+	{Code: "INVALID_INDEX", Status: http.StatusBadRequest, Title: "Invalid index"},
+	{Code: "INVALID_DIGEST", Status: http.StatusBadRequest, Title: "Invalid transaction digest"},
+	{Code: "INVALID_SIGNATURE", Status: http.StatusBadRequest, Title: "Invalid signature"},
+	{Code: "LIMIT_EXCEEDED", Status: http.StatusUnprocessableEntity, Title: "Limit exceeded"},
+
+	// Authentication
+	{Code: "UNAUTHORIZED", Status: http.StatusUnauthorized, Title: "Authentication required"},
+	{Code: "FORBIDDEN", Status: http.StatusForbidden, Title: "Not allowed"},
+	{Code: "AUTH_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Wallet sessions unavailable"},
+	{Code: "CHALLENGE_EXPIRED", Status: http.StatusUnauthorized, Title: "Sign-in challenge expired"},
+	{Code: "INVALID_SESSION", Status: http.StatusUnauthorized, Title: "Invalid wallet session"},
+	{Code: "INVALID_API_KEY", Status: http.StatusUnauthorized, Title: "Invalid API key"},
+	{Code: "INSUFFICIENT_SCOPE", Status: http.StatusForbidden, Title: "API key lacks the scope"},
+	{Code: "API_KEYS_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "API keys unavailable"},
+	{Code: "API_KEY_NOT_FOUND", Status: http.StatusNotFound, Title: "API key not found"},
+	{Code: "API_KEY_REVOKED", Status: http.StatusConflict, Title: "API key revoked"},
+	{Code: "INVALID_API_KEY_REQUEST", Status: http.StatusBadRequest, Title: "Invalid API key request"},
+	{Code: "API_KEY_ERROR", Status: http.StatusInternalServerError, Title: "API key error"},
+	{Code: "INVALID_IDEMPOTENCY_KEY", Status: http.StatusBadRequest, Title: "Invalid Idempotency-Key"},
+	{Code: "IDEMPOTENCY_IN_PROGRESS", Status: http.StatusConflict, Title: "Idempotency-Key in use"},
+	{Code: "IDEMPOTENCY_KEY_REUSED", Status: http.StatusConflict, Title: "Idempotency-Key reused"},
+	{Code: "IDEMPOTENCY_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Idempotency keys unavailable"},
+	{Code: "ZKLOGIN_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "zkLogin unavailable"},
+	{Code: "ZKLOGIN_NONCE_ERROR", Status: http.StatusServiceUnavailable, Title: "zkLogin nonce unavailable"},
+
+	// Protocol and markets
+	{Code: "PROTOCOL_STATE_ERROR", Status: http.StatusServiceUnavailable, Title: "Protocol state unavailable"},
+	{Code: "PROTOCOL_EVENTS_ERROR", Status: http.StatusInternalServerError, Title: "Protocol events unavailable"},
+	{Code: "PROTOCOL_HISTORY_ERROR", Status: http.StatusInternalServerError, Title: "Protocol history unavailable"},
+	{Code: "HEALTH_CHECK_ERROR", Status: http.StatusServiceUnavailable, Title: "Health check failed"},
+	{Code: "ORACLE_PRICE_REJECTED", Status: http.StatusServiceUnavailable, Title: "Oracle price rejected"},
+	{Code: "MARKETS_ERROR", Status: http.StatusInternalServerError, Title: "Markets unavailable"},
+	{Code: "MARKET_NOT_FOUND", Status: http.StatusNotFound, Title: "Market not found"},
+	{Code: "PARAMS_ERROR", Status: http.StatusInternalServerError, Title: "Parameters unavailable"},
+	{Code: "PARAMS_NOT_FOUND", Status: http.StatusNotFound, Title: "Parameters not found"},
+	{Code: "CANDLES_ERROR", Status: http.StatusInternalServerError, Title: "Candles unavailable"},
+	{Code: "CANDLES_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Candle history unavailable"},
+	{Code: "CONFIG_ERROR", Status: http.StatusInternalServerError, Title: "Misconfigured server"},
+
+	// Quotes and transactions
+	{Code: "QUOTE_ERROR", Status: http.StatusBadRequest, Title: "Quote failed"},
+	{Code: "QUOTE_EXPIRED", Status: http.StatusGone, Title: "Quote expired"},
+	{Code: "QUOTE_MISMATCH", Status: http.StatusBadRequest, Title: "Quote does not match the request"},
+	{Code: "QUOTE_MOVED", Status: http.StatusConflict, Title: "Quote moved past the slippage"},
+	{Code: "INSUFFICIENT_BALANCE", Status: http.StatusBadRequest, Title: "Insufficient balance"},
+	{Code: "COINS_FRAGMENTED", Status: http.StatusUnprocessableEntity, Title: "Coins too fragmented"},
+	{Code: "SPONSORSHIP_UNAVAILABLE", Status: http.StatusBadRequest, Title: "Sponsorship unavailable"},
+	{Code: "SPONSOR_QUOTA_EXCEEDED", Status: http.StatusTooManyRequests, Title: "Sponsor quota exceeded"},
+	{Code: "NO_SP_POSITION", Status: http.StatusNotFound, Title: "No stability pool position"},
+	{Code: "TRANSACTION_BUILD_ERROR", Status: http.StatusInternalServerError, Title: "Transaction build failed"},
+	{Code: "TRANSACTION_WOULD_FAIL", Status: http.StatusUnprocessableEntity, Title: "Transaction would fail"},
+	{Code: "TRANSACTION_STATUS_ERROR", Status: http.StatusServiceUnavailable, Title: "Transaction status unavailable"},
+	{Code: "VALIDATION_ERROR", Status: http.StatusInternalServerError, Title: "Transaction validation failed"},
+	{Code: "SUBMISSION_ERROR", Status: http.StatusBadRequest, Title: "Transaction submission failed"},
+
+	// Users
+	{Code: "BALANCE_ERROR", Status: http.StatusInternalServerError, Title: "Balance unavailable"},
+	{Code: "BALANCE_NOT_FOUND", Status: http.StatusNotFound, Title: "Balance not found"},
+	{Code: "USER_BALANCES_ERROR", Status: http.StatusInternalServerError, Title: "Balances unavailable"},
+	{Code: "USER_PORTFOLIO_ERROR", Status: http.StatusInternalServerError, Title: "Portfolio unavailable"},
+	{Code: "USER_POSITIONS_ERROR", Status: http.StatusInternalServerError, Title: "Positions unavailable"},
+	{Code: "USER_TRANSACTIONS_ERROR", Status: http.StatusInternalServerError, Title: "Transactions unavailable"},
+	{Code: "SP_INDEX_ERROR", Status: http.StatusServiceUnavailable, Title: "Stability pool index unavailable"},
+	{Code: "SP_USER_ERROR", Status: http.StatusInternalServerError, Title: "Stability pool position unavailable"},
+
+	// Bridge
+	{Code: "BRIDGE_ERROR", Status: http.StatusBadRequest, Title: "Bridge request failed"},
+	{Code: "BRIDGE_PAUSED", Status: http.StatusServiceUnavailable, Title: "Bridge paused"},
+	{Code: "BRIDGE_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Bridge unavailable"},
+	{Code: "WALRUS_UNAVAILABLE", Status: http.StatusBadGateway, Title: "Walrus unavailable"},
+	{Code: "CONTROL_ERROR", Status: http.StatusInternalServerError, Title: "Bridge control failed"},
+	{Code: "VAULT_ERROR", Status: http.StatusInternalServerError, Title: "Vault unavailable"},
+	{Code: "VAULT_NOT_FOUND", Status: http.StatusNotFound, Title: "Vault not found"},
+	{Code: "CHECKPOINT_ERROR", Status: http.StatusInternalServerError, Title: "Checkpoint unavailable"},
+	{Code: "CHECKPOINT_MISMATCH", Status: http.StatusConflict, Title: "Checkpoint mismatch"},
+	{Code: "CHECKPOINT_NOT_FOUND", Status: http.StatusNotFound, Title: "Checkpoint not found"},
+	{Code: "INVALID_PROOF_BLOB", Status: http.StatusBadRequest, Title: "Invalid proof blob"},
+	{Code: "PROOF_ERROR", Status: http.StatusInternalServerError, Title: "Proof unavailable"},
+	{Code: "VERIFY_ERROR", Status: http.StatusInternalServerError, Title: "Verification failed"},
+	{Code: "DEPOSIT_ERROR", Status: http.StatusInternalServerError, Title: "Deposit failed"},
+	{Code: "DEPOSIT_IN_PROGRESS", Status: http.StatusConflict, Title: "Deposit in progress"},
+	{Code: "DEPOSIT_NOT_FOUND", Status: http.StatusNotFound, Title: "Deposit not found"},
+	{Code: "INVALID_VOUCHER", Status: http.StatusBadRequest, Title: "Invalid voucher"},
+	{Code: "VOUCHER_ERROR", Status: http.StatusInternalServerError, Title: "Voucher failed"},
+	{Code: "VOUCHER_NOT_FOUND", Status: http.StatusNotFound, Title: "Voucher not found"},
+	{Code: "VOUCHER_NOT_CANCELLABLE", Status: http.StatusConflict, Title: "Voucher not cancellable"},
+	{Code: "RECEIPT_ERROR", Status: http.StatusInternalServerError, Title: "Receipt unavailable"},
+	{Code: "RECEIPT_NOT_FOUND", Status: http.StatusNotFound, Title: "Receipt not found"},
+	{Code: "RECEIPT_NOT_RECOMPUTABLE", Status: http.StatusUnprocessableEntity, Title: "Receipt not recomputable"},
+	{Code: "RETRY_ERROR", Status: http.StatusInternalServerError, Title: "Retry failed"},
+	{Code: "RETRY_NOT_FOUND", Status: http.StatusNotFound, Title: "Retry not found"},
+	{Code: "RETRY_DONE", Status: http.StatusConflict, Title: "Retry already done"},
+	{Code: "REFUND_ERROR", Status: http.StatusInternalServerError, Title: "Refund failed"},
+	{Code: "REFUND_NOT_FOUND", Status: http.StatusNotFound, Title: "Refund not found"},
+	{Code: "REFUND_SETTLED", Status: http.StatusConflict, Title: "Refund already settled"},
+	{Code: "ATTESTATION_ERROR", Status: http.StatusInternalServerError, Title: "Attestation failed"},
+	{Code: "INVALID_ATTESTATION", Status: http.StatusBadRequest, Title: "Invalid attestation"},
+	{Code: "NOT_A_SIGNER", Status: http.StatusNotFound, Title: "Not an attestation signer"},
+	{Code: "INVALID_SHARES", Status: http.StatusBadRequest, Title: "Invalid shares"},
+	{Code: "INVALID_TOTAL_SHARES", Status: http.StatusBadRequest, Title: "Invalid total shares"},
+	{Code: "INVALID_TOKEN", Status: http.StatusBadRequest, Title: "Invalid token"},
+
+	// Webhooks
+	{Code: "WEBHOOKS_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Webhooks unavailable"},
+	{Code: "WEBHOOK_NOT_FOUND", Status: http.StatusNotFound, Title: "Webhook not found"},
+	{Code: "INVALID_WEBHOOK_REQUEST", Status: http.StatusBadRequest, Title: "Invalid webhook request"},
+	{Code: "WEBHOOK_ERROR", Status: http.StatusInternalServerError, Title: "Webhook error"},
+
+	// Watchlists
+	{Code: "WATCHLIST_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Watchlists unavailable"},
+	{Code: "WATCHLIST_ITEM_NOT_FOUND", Status: http.StatusNotFound, Title: "Watchlist item not found"},
+	{Code: "INVALID_WATCHLIST_REQUEST", Status: http.StatusBadRequest, Title: "Invalid watchlist request"},
+	{Code: "WATCHLIST_ERROR", Status: http.StatusInternalServerError, Title: "Watchlist error"},
+
+	// Operations
+	{Code: "INVALID_CACHE_PREFIX", Status: http.StatusBadRequest, Title: "Invalid cache prefix"},
+	{Code: "CACHE_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Cache unavailable"},
+	{Code: "CACHE_FLUSH_ERROR", Status: http.StatusInternalServerError, Title: "Cache flush failed"},
+	{Code: "JOBS_UNAVAILABLE", Status: http.StatusServiceUnavailable, Title: "Job control unavailable"},
+	{Code: "JOB_NOT_FOUND", Status: http.StatusNotFound, Title: "Job not found"},
+	{Code: "JOB_ERROR", Status: http.StatusInternalServerError, Title: "Job control error"},
+})
+
+// newErrorCatalog indexes codes by code, linking each to its docs
+func newErrorCatalog(codes []ErrorCode) map[string]ErrorCode {
+	catalog := make(map[string]ErrorCode, len(codes))
+	for _, c := range codes {
+		if _, dup := catalog[c.Code]; dup {
+			panic("api: duplicate error code " + c.Code)
+		}
+		c.Docs = errorDocsPath + "/" + c.Code
+		catalog[c.Code] = c
+	}
+	return catalog
+}
+
+// newProblem returns the problem detail answering code with status. Codes
+// missing from the catalog get the generic about:blank type. The request ID
+// is the one RequestID set on w.
+func newProblem(w http.ResponseWriter, status int, code, detail string) ErrorResponse {
+	problem := ErrorResponse{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		RequestID: w.Header().Get(HeaderRequestID),
+		Code:      code,
+		Message:   detail,
+	}
+	if entry, ok := errorCatalog[code]; ok {
+		problem.Type = entry.Docs
+		problem.Title = entry.Title
+	}
+	return problem
+}
+
+// writeProblem writes problem as problem+json, with its status
+func writeProblem(w http.ResponseWriter, problem ErrorResponse) {
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// errorCodeFor maps internal errors to the catalog: missing records to
+// NOT_FOUND, rejected requests to INVALID_REQUEST and Move aborts to
+// MOVE_ABORT. Other errors map to fallback, or INTERNAL_ERROR without one.
+func errorCodeFor(err error, fallback string) ErrorCode {
+	var abort *onchain.MoveAbortError
+	switch {
+	case errors.As(err, &abort):
+		return errorCatalog["MOVE_ABORT"]
+	case errors.Is(err, kv.ErrNotFound), errors.Is(err, interfaces.ErrNotFound),
+		errors.Is(err, crosschain.ErrNotFound), errors.Is(err, apikeys.ErrNotFound), errors.Is(err, webhooks.ErrNotFound),
+		errors.Is(err, jobs.ErrJobNotFound), errors.Is(err, watchlist.ErrNotFound):
+		return errorCatalog["NOT_FOUND"]
+	case errors.Is(err, crosschain.ErrInvalidRequest), errors.Is(err, apikeys.ErrInvalidRequest),
+		errors.Is(err, webhooks.ErrInvalidRequest), errors.Is(err, watchlist.ErrInvalidRequest),
+		errors.Is(err, onchain.ErrInvalidSimulation), errors.Is(err, onchain.ErrInvalidFeeSchedule):
+		return errorCatalog["INVALID_REQUEST"]
+	}
+	if entry, ok := errorCatalog[fallback]; ok {
+		return entry
+	}
+	return errorCatalog["INTERNAL_ERROR"]
+}
+
+// writeErrorFor answers err with its catalog entry, see errorCodeFor.
+// Errors answered with a 5xx are logged, not detailed.
+func (h *Handler) writeErrorFor(w http.ResponseWriter, err error, fallback string) {
+	var abort *onchain.MoveAbortError
+	if errors.As(err, &abort) {
+		h.writeMoveAbort(w, abort, "")
+		return
+	}
+	entry := errorCodeFor(err, fallback)
+	detail := err.Error()
+	switch {
+	case entry.Status >= http.StatusInternalServerError:
+		h.logger.Errorw("Internal API error", "code", entry.Code, "error", err)
+		detail = entry.Title
+		if entry.Message != "" {
+			detail = entry.Message
+		}
+	case strings.Contains(entry.Message, "%s"):
+		detail = fmt.Sprintf(entry.Message, err)
+	}
+	h.writeError(w, entry.Status, entry.Code, detail)
+}
+
+// ListErrorCodes serves the error catalog, sorted by code
+func (h *Handler) ListErrorCodes(w http.ResponseWriter, r *http.Request) {
+	codes := make([]ErrorCode, 0, len(errorCatalog))
+	for _, c := range errorCatalog {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].Code < codes[j].Code })
+	h.writeJSON(w, http.StatusOK, ErrorCatalogResponse{Errors: codes})
+}
+
+// GetErrorCode serves the catalog entry the problem type of a code links to
+func (h *Handler) GetErrorCode(w http.ResponseWriter, r *http.Request) {
+	entry, ok := errorCatalog[chi.URLParam(r, "code")]
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "NOT_FOUND", "unknown error code")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, entry)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// errorCodePattern matches the codes handlers answer with
+var errorCodePattern = regexp.MustCompile(`(?:writeError|writeErrorWithLog|writeBackoffError|writeDependencyError|writeErrorFor|newProblem)\(\s*w,[^"]*?"([A-Z][A-Z0-9_]+)"`)
+
+func TestErrorCatalog(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	seen := 0
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		require.NoError(t, err)
+		for _, m := range errorCodePattern.FindAllStringSubmatch(string(src), -1) {
+			seen++
+			assert.Contains(t, errorCatalog, m[1], "%s answers %s, missing from errorCatalog", file, m[1])
+		}
+	}
+	assert.Greater(t, seen, 100)
+
+	for code, entry := range errorCatalog {
+		assert.Equal(t, code, entry.Code)
+		assert.NotEmpty(t, entry.Title, code)
+		assert.NotZero(t, http.StatusText(entry.Status), code)
+		assert.Equal(t, "/v1/errors/"+code, entry.Docs)
+	}
+}
+
+func TestWriteErrorProblemJSON(t *testing.T) {
+	h := &Handler{logger: zap.NewNop().Sugar(), metrics: &MockMetrics{}}
+	m := NewMiddleware(zap.NewNop().Sugar(), nil)
+
+	answer := func(write func(w http.ResponseWriter)) (*httptest.ResponseRecorder, ErrorResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		m.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { write(w) })).ServeHTTP(rec, req)
+		var problem ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		return rec, problem
+	}
+
+	rec, problem := answer(func(w http.ResponseWriter) {
+		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "amount must be positive")
+	})
+	assert.Equal(t, ContentTypeProblem, rec.Header().Get("Content-Type"))
+	assert.Equal(t, ErrorResponse{
+		Type:      "/v1/errors/INVALID_AMOUNT",
+		Title:     "Invalid amount",
+		Status:    http.StatusBadRequest,
+		Detail:    "amount must be positive",
+		RequestID: rec.Header().Get(HeaderRequestID),
+		Code:      "INVALID_AMOUNT",
+		Message:   "amount must be positive",
+	}, problem)
+	assert.NotEmpty(t, problem.RequestID)
+
+	// Internal errors map to the same entries wherever they come from
+	for _, tt := range []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{name: "kv", err: fmt.Errorf("load: %w", kv.ErrNotFound), status: http.StatusNotFound, code: "NOT_FOUND", detail: "Not found: load: not found"},
+		{name: "crosschain", err: fmt.Errorf("%w: fees must be positive", crosschain.ErrInvalidRequest), status: http.StatusBadRequest, code: "INVALID_REQUEST", detail: "Invalid request: invalid request: fees must be positive"},
+		{name: "move abort", err: fmt.Errorf("build: %w", &onchain.MoveAbortError{Module: "balance", Function: "split", Code: 2, Reason: "Insufficient balance"}), status: http.StatusUnprocessableEntity, code: "MOVE_ABORT", detail: "Insufficient balance"},
+		{name: "fallback", err: errors.New("dial tcp: refused"), status: http.StatusInternalServerError, code: "VAULT_ERROR", detail: "Vault unavailable"},
+		{name: "unknown fallback", err: errors.New("dial tcp: refused"), status: http.StatusInternalServerError, code: "INTERNAL_ERROR", detail: "Internal server error"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := "VAULT_ERROR"
+			if tt.code == "INTERNAL_ERROR" {
+				fallback = "NO_SUCH_CODE"
+			}
+			rec, problem := answer(func(w http.ResponseWriter) { h.writeErrorFor(w, tt.err, fallback) })
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, tt.detail, problem.Detail)
+			assert.Equal(t, "/v1/errors/"+tt.code, problem.Type)
+			assert.NotEmpty(t, problem.RequestID)
+		})
+	}
+
+	// Codes missing from the catalog are still problems
+	_, problem = answer(func(w http.ResponseWriter) {
+		writeBackoffError(w, http.StatusTeapot, "TEAPOT", "short and stout", BackoffHint{})
+	})
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Equal(t, http.StatusText(http.StatusTeapot), problem.Title)
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+)
+
+// Export formats of user transactions, besides the JSON pages
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+)
+
+// exportFlushRows is the number of rows written between flushes of an
+// export, so that clients receive it as it is read
+const exportFlushRows = 100
+
+// exportPathPattern matches the routes that stream exports
+var exportPathPattern = regexp.MustCompile(`^/v\d+/users/[^/]+/transactions$`)
+
+// exportColumns are the CSV columns of transaction exports, in the order
+// csvExportWriter writes them
+var exportColumns = []string{
+	"timestamp", "type", "tx_digest", "checkpoint", "sequence", "token", "amount",
+	"token_price_usd", "value_usd", "reserve_price_usd",
+}
+
+// exportFormat returns the export format requested by the format query
+// parameter, or else by the Accept header, or "" for JSON pages. ok is false
+// for unknown format parameters.
+func exportFormat(r *http.Request) (format string, ok bool) {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "":
+	case "json":
+		return "", true
+	case exportFormatCSV:
+		return exportFormatCSV, true
+	case exportFormatNDJSON:
+		return exportFormatNDJSON, true
+	default:
+		return "", false
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return exportFormatCSV, true
+		case "application/x-ndjson", "application/ndjson":
+			return exportFormatNDJSON, true
+		}
+	}
+	return "", true
+}
+
+// isExportRequest reports whether r asks a route for a streamed export
+func isExportRequest(r *http.Request) bool {
+	if !exportPathPattern.MatchString(r.URL.Path) {
+		return false
+	}
+	format, _ := exportFormat(r)
+	return format != ""
+}
+
+// exportUserTransactions streams the transactions of address matching
+// filter in format, newest first, flushing as it goes. Failures before the
+// first row are answered as errors; later ones end the export early.
+func (h *Handler) exportUserTransactions(w http.ResponseWriter, r *http.Request, address string, filter onchain.TransactionFilter, format string) {
+	var rows exportWriter
+	if format == exportFormatCSV {
+		rows = &csvExportWriter{w: csv.NewWriter(w)}
+	} else {
+		rows = &ndjsonExportWriter{enc: json.NewEncoder(w)}
+	}
+	flusher, _ := w.(http.Flusher)
+
+	started := false
+	start := func() error {
+		started = true
+		contentType, ext := "application/x-ndjson", "ndjson"
+		if format == exportFormatCSV {
+			contentType, ext = "text/csv; charset=utf-8", "csv"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions-%s.%s"`, address, ext))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		return rows.begin()
+	}
+
+	written := 0
+	err := h.userSvc.ExportTransactions(r.Context(), address, filter, func(event onchain.ValuedEvent) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := rows.write(newTransactionExportRow(event)); err != nil {
+			return err
+		}
+		if written++; written%exportFlushRows == 0 {
+			if err := rows.flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil && !started {
+		h.writeErrorFor(w, err, "USER_TRANSACTIONS_ERROR")
+		return
+	}
+	if err != nil {
+		h.logger.Warnw("Transaction export ended early", "address", address, "rows", written, "error", err)
+		return
+	}
+	if !started && start() != nil {
+		return
+	}
+	if rows.flush() == nil && flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func newTransactionExportRow(event onchain.ValuedEvent) TransactionExportRow {
+	row := TransactionExportRow{
+		Timestamp:  event.Timestamp.UTC().Format(time.RFC3339),
+		Type:       event.Type,
+		TxDigest:   event.TxDigest,
+		Checkpoint: event.Checkpoint,
+		Sequence:   event.SequenceNumber,
+		Token:      event.Token,
+		Amount:     event.Amount,
+	}
+	if event.Valued {
+		row.TokenPriceUSD = usdString(event.TokenPrice)
+		row.ValueUSD = usdString(event.Value)
+		row.ReservePriceUSD = usdString(event.ReservePrice)
+	}
+	return row
+}
+
+// exportWriter writes the rows of an export in one format
+type exportWriter interface {
+	begin() error
+	write(row TransactionExportRow) error
+	flush() error
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvExportWriter) begin() error {
+	return c.w.Write(exportColumns)
+}
+
+func (c *csvExportWriter) write(row TransactionExportRow) error {
+	return c.w.Write([]string{
+		row.Timestamp, row.Type, row.TxDigest,
+		strconv.FormatUint(row.Checkpoint, 10), strconv.FormatUint(row.Sequence, 10),
+		row.Token, row.Amount, row.TokenPriceUSD, row.ValueUSD, row.ReservePriceUSD,
+	})
+}
+
+func (c *csvExportWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonExportWriter writes one JSON object per line; the encoder writes
+// through, so there is nothing to flush
+type ndjsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonExportWriter) begin() error { return nil }
+
+func (n *ndjsonExportWriter) write(row TransactionExportRow) error {
+	return n.enc.Encode(row)
+}
+
+func (n *ndjsonExportWriter) flush() error { return nil }
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestExportUserTransactions(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+
+	address, err := onchain.NormalizeAddress("0x1")
+	require.NoError(t, err)
+	events := database.Repository(entities.EventSchema)
+	samples := database.Repository(entities.ProtocolSampleSchema)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, eventType := range []string{onchain.EventTypeMint, onchain.EventTypeStake, onchain.EventTypeMint} {
+		_, err := events.Create(ctx, map[string]interface{}{
+			"checkpoint":      int64(i),
+			"sequence_number": int64(0),
+			"timestamp":       start.Add(time.Duration(i) * time.Hour),
+			"type":            eventType,
+			"tx_digest":       fmt.Sprintf("digest-%d", i),
+			"sender":          address,
+			"token":           "fToken",
+			"amount":          "1500000000",
+		})
+		require.NoError(t, err)
+	}
+	_, err = samples.Create(ctx, map[string]interface{}{
+		"timestamp":     start.Add(30 * time.Minute),
+		"cr":            2.0,
+		"reserves_r":    10e9,
+		"supply_f":      10e9,
+		"supply_x":      5e9,
+		"peg_deviation": 0.0,
+		"reserve_price": 2.0,
+	})
+	require.NoError(t, err)
+
+	logger := zap.NewNop().Sugar()
+	userSvc := onchain.NewUserService(nil, nil, logger, onchain.WithEventStore(events), onchain.WithSampleStore(samples))
+	h := &Handler{logger: logger, metrics: &MockMetrics{}, userSvc: userSvc}
+	m := NewMiddleware(logger, nil)
+	r := chi.NewRouter()
+	r.Use(m.Timeout(time.Second))
+	r.Get("/v1/users/{address}/transactions", h.GetUserTransactions)
+	serve := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	path := "/v1/users/" + address + "/transactions"
+
+	rec := serve(path+"?format=csv", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+	// Exports are streamed rather than buffered by the timeout
+	assert.True(t, rec.Flushed)
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, exportColumns, records[0])
+	assert.Equal(t, []string{"2024-01-01T02:00:00Z", "MINT", "digest-2", "2", "0", "fToken", "1500000000", "1", "1.5", "2"}, records[1])
+	// Events before the first protocol sample are not valued
+	assert.Equal(t, []string{"2024-01-01T00:00:00Z", "MINT", "digest-0", "0", "0", "fToken", "1500000000", "", "", ""}, records[3])
+
+	rec = serve(path+"?type=stake", "application/x-ndjson")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	var rows []TransactionExportRow
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var row TransactionExportRow
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	require.Len(t, rows, 1)
+	assert.Equal(t, "digest-1", rows[0].TxDigest)
+	assert.Equal(t, "1.5", rows[0].ValueUSD)
+
+	// Empty exports carry the CSV header
+	rec = serve(path+"?format=csv&from=2025-01-01T00:00:00Z", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, strings.Join(exportColumns, ",")+"\n", rec.Body.String())
+
+	// JSON pages take the same filters
+	rec = serve(path+"?to="+fmt.Sprint(start.Add(90*time.Minute).Unix()), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page UserTransactionsDTO
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "digest-1", page.Items[0].Hash)
+
+	for target, code := range map[string]string{
+		path + "?format=xml":                    "INVALID_PARAMETER",
+		path + "?type=swap":                     "INVALID_PARAMETER",
+		path + "?from=yesterday":                "INVALID_PARAMETER",
+		path + "?from=1704070800&to=1704067200": "INVALID_RANGE",
+	} {
+		rec := serve(target, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		assert.Contains(t, rec.Body.String(), code, target)
+	}
+}
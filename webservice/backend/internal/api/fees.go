@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/shopspring/decimal"
+)
+
+// GetProtocolFees returns every fee the protocol charges: the schedule
+// quotes are priced with, the fees of the protocol object on chain, the
+// bridge fees of each asset, and the collateral ratio thresholds of each mode
+func (h *Handler) GetProtocolFees(w http.ResponseWriter, r *http.Request) {
+	state, err := h.protocolSvc.GetState(r.Context())
+	if err != nil {
+		h.writeErrorFor(w, err, "PROTOCOL_STATE_ERROR")
+		return
+	}
+	schedule, err := h.quoteSvc.FeeSchedule(r.Context())
+	if err != nil {
+		h.writeErrorFor(w, err, "PROTOCOL_STATE_ERROR")
+		return
+	}
+
+	stability, userRebalance, protocolRebalance := onchain.ProtocolModeThresholds()
+	resp := ProtocolFeesResponse{
+		Quotes: feeScheduleDTO(schedule),
+		Bridge: []BridgeFeeRateDTO{},
+		Thresholds: FeeThresholdsDTO{
+			StabilityCR:         stability.String(),
+			UserRebalanceCR:     userRebalance.String(),
+			ProtocolRebalanceCR: protocolRebalance.String(),
+		},
+		CR:   state.CR.String(),
+		Mode: state.Mode,
+		AsOf: state.AsOf.Unix(),
+	}
+	if fees := state.Fees; fees != nil {
+		resp.Onchain = &OnchainFeesDTO{
+			MintFBps:          fees.MintFBps,
+			MintXBps:          fees.MintXBps,
+			RedeemFBps:        fees.RedeemFBps,
+			RedeemXBps:        fees.RedeemXBps,
+			L1RedeemXBps:      fees.L1RedeemXBps,
+			StabilityBonusBps: fees.StabilityBonusBps,
+			FeeRecipient:      fees.FeeRecipient,
+		}
+	}
+	if h.crosschainSvc != nil {
+		for _, f := range h.crosschainSvc.ListBridgeFees(r.Context()) {
+			resp.Bridge = append(resp.Bridge, BridgeFeeRateDTO{
+				ChainID:      string(f.ChainID),
+				Asset:        f.Asset,
+				MintFeeBps:   f.MintFeeBps,
+				RedeemFeeBps: f.RedeemFeeBps,
+			})
+		}
+	}
+	if h.config != nil {
+		resp.Thresholds.OracleMaxAgeSec = int64(h.config.Oracle.MaxAge.Seconds())
+	}
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// SetFeeSchedule changes the fee schedule quotes are priced with. The
+// contract's own fees change on chain only.
+func (h *Handler) SetFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	requestID := w.Header().Get(HeaderRequestID)
+	var req FeeScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid fee schedule payload")
+		return
+	}
+	if !h.validateRequest(w, &req, requestID) {
+		return
+	}
+
+	schedule, err := h.quoteSvc.FeeSchedule(r.Context())
+	if err != nil {
+		h.writeErrorFor(w, err, "CONTROL_ERROR")
+		return
+	}
+	for _, field := range []struct {
+		value *int64
+		into  *int64
+	}{
+		{req.MintFBps, &schedule.MintFBps},
+		{req.RedeemFBps, &schedule.RedeemFBps},
+		{req.MintXBps, &schedule.MintXBps},
+		{req.RedeemXBps, &schedule.RedeemXBps},
+	} {
+		if field.value != nil {
+			*field.into = *field.value
+		}
+	}
+	if req.MinCR != "" {
+		schedule.MinCR = decimal.RequireFromString(req.MinCR)
+	}
+
+	change, err := h.quoteSvc.SetFeeSchedule(r.Context(), schedule, onchain.FeeChange{
+		Reason:     req.Reason,
+		RequestID:  requestID,
+		RemoteAddr: r.RemoteAddr,
+	})
+	if err != nil {
+		h.writeErrorFor(w, err, "CONTROL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, FeeScheduleResponse{
+		Schedule: feeScheduleDTO(change.After),
+		Change:   feeChangeDTO(*change),
+	})
+}
+
+// ListFeeScheduleChanges lists the audit trail of the fee schedule, newest
+// first
+func (h *Handler) ListFeeScheduleChanges(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 200 {
+			limit = parsedLimit
+		}
+	}
+
+	changes, err := h.quoteSvc.FeeChanges(r.Context(), limit)
+	if err != nil {
+		h.writeErrorFor(w, err, "CONTROL_ERROR")
+		return
+	}
+	resp := FeeChangeListResponse{Changes: make([]FeeChangeDTO, 0, len(changes))}
+	for _, change := range changes {
+		resp.Changes = append(resp.Changes, feeChangeDTO(change))
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+func feeScheduleDTO(schedule onchain.FeeSchedule) FeeScheduleDTO {
+	dto := FeeScheduleDTO{
+		MintFBps:   schedule.MintFBps,
+		RedeemFBps: schedule.RedeemFBps,
+		MintXBps:   schedule.MintXBps,
+		RedeemXBps: schedule.RedeemXBps,
+		MinCR:      schedule.MinCR.String(),
+	}
+	if !schedule.UpdatedAt.IsZero() {
+		dto.UpdatedAt = schedule.UpdatedAt.Unix()
+	}
+	return dto
+}
+
+func feeChangeDTO(change onchain.FeeChange) FeeChangeDTO {
+	return FeeChangeDTO{
+		Before:     feeScheduleDTO(change.Before),
+		After:      feeScheduleDTO(change.After),
+		Reason:     change.Reason,
+		RequestID:  change.RequestID,
+		RemoteAddr: change.RemoteAddr,
+		At:         change.At.Unix(),
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestProtocolFees(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, cache.SetProtocolState(ctx, onchain.ProtocolState{
+		CR:        decimal.RequireFromString("1.5"),
+		Mode:      onchain.ProtocolModeNormal,
+		ReservesR: decimal.NewFromInt(1_000_000),
+		Fees:      &onchain.ProtocolFees{MintFBps: 10, L1RedeemXBps: 700},
+		AsOf:      time.Now(),
+	}))
+	cfg := &config.Config{Oracle: config.OracleConfig{MaxAge: time.Minute}}
+	protocolSvc := onchain.NewProtocolService(nil, cache, cfg, logger)
+	h := &Handler{
+		logger:      logger,
+		metrics:     &MockMetrics{},
+		config:      cfg,
+		protocolSvc: protocolSvc,
+		quoteSvc:    onchain.NewQuoteService(nil, cache, protocolSvc, cfg, logger),
+	}
+	r := chi.NewRouter()
+	r.Get("/v1/protocol/fees", h.GetProtocolFees)
+	r.Put("/v1/admin/protocol/fees", h.SetFeeSchedule)
+	r.Get("/v1/admin/protocol/fees/changes", h.ListFeeScheduleChanges)
+	getFees := func() ProtocolFeesResponse {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/protocol/fees", nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp ProtocolFeesResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+	setFees := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/v1/admin/protocol/fees", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	fees := getFees()
+	assert.Equal(t, int64(200), fees.Quotes.MintXBps)
+	assert.Equal(t, "1.1", fees.Quotes.MinCR)
+	assert.Zero(t, fees.Quotes.UpdatedAt)
+	require.NotNil(t, fees.Onchain)
+	assert.Equal(t, uint64(700), fees.Onchain.L1RedeemXBps)
+	assert.Empty(t, fees.Bridge)
+	assert.Equal(t, "1.306", fees.Thresholds.StabilityCR)
+	assert.Equal(t, int64(60), fees.Thresholds.OracleMaxAgeSec)
+	assert.Equal(t, "1.5", fees.CR)
+	assert.Equal(t, onchain.ProtocolModeNormal, fees.Mode)
+
+	// Fields left out keep their value
+	rec := setFees(`{"mintXBps":300,"minCR":"1.2","reason":"volatility"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var set FeeScheduleResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+	assert.Equal(t, int64(300), set.Schedule.MintXBps)
+	assert.Equal(t, int64(30), set.Schedule.MintFBps)
+	assert.Equal(t, int64(200), set.Change.Before.MintXBps)
+	assert.Equal(t, "volatility", set.Change.Reason)
+	fees = getFees()
+	assert.Equal(t, int64(300), fees.Quotes.MintXBps)
+	assert.Equal(t, "1.2", fees.Quotes.MinCR)
+	assert.NotZero(t, fees.Quotes.UpdatedAt)
+
+	for body, code := range map[string]string{
+		`{"mintXBps":300}`:                "MISSING_PARAMETER",
+		`{"mintXBps":10001,"reason":"x"}`: "INVALID_REQUEST",
+		`{"minCR":"0.9","reason":"x"}`:    "INVALID_REQUEST",
+		`{"minCR":"lots","reason":"x"}`:   "INVALID_PARAMETER",
+		`{"mintXBps":"300","reason":"x"}`: "INVALID_JSON",
+	} {
+		rec := setFees(body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), code, body)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/protocol/fees/changes", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var changes FeeChangeListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &changes))
+	require.Len(t, changes.Changes, 1)
+	assert.Equal(t, "volatility", changes.Changes[0].Reason)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -15,12 +16,18 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/leafsii/leafsii-backend/internal/calc"
+	"github.com/leafsii/leafsii-backend/internal/apikeys"
+	"github.com/leafsii/leafsii-backend/internal/candles"
 	"github.com/leafsii/leafsii-backend/internal/config"
 	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	"github.com/leafsii/leafsii-backend/internal/health"
+	"github.com/leafsii/leafsii-backend/internal/jobs"
 	"github.com/leafsii/leafsii-backend/internal/markets"
 	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/prices"
 	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/leafsii/leafsii-backend/internal/watchlist"
+	"github.com/leafsii/leafsii-backend/internal/webhooks"
 	"github.com/leafsii/leafsii-backend/internal/ws"
 	"github.com/pattonkan/sui-go/sui"
 	"github.com/pattonkan/sui-go/utils/unit"
@@ -49,6 +56,13 @@ type Handler struct {
 	metrics       MetricsInterface
 	txBuilder     onchain.TransactionBuilderInterface
 	txSubmitter   onchain.TransactionSubmitterInterface
+	txStatusSvc   *onchain.TransactionStatusService
+	apiKeys       *apikeys.Service
+	webhooks      *webhooks.Service
+	scheduler     *jobs.Scheduler
+	readiness     *health.Checker
+	candles       *candles.Service
+	watchlist     *watchlist.Service
 }
 
 func NewHandler(
@@ -67,6 +81,13 @@ func NewHandler(
 	metrics MetricsInterface,
 	txBuilder onchain.TransactionBuilderInterface,
 	txSubmitter onchain.TransactionSubmitterInterface,
+	txStatusSvc *onchain.TransactionStatusService,
+	apiKeys *apikeys.Service,
+	webhooks *webhooks.Service,
+	scheduler *jobs.Scheduler,
+	readiness *health.Checker,
+	candles *candles.Service,
+	watchlist *watchlist.Service,
 ) *Handler {
 	return &Handler{
 		protocolSvc:   protocolSvc,
@@ -84,6 +105,13 @@ func NewHandler(
 		metrics:       metrics,
 		txBuilder:     txBuilder,
 		txSubmitter:   txSubmitter,
+		txStatusSvc:   txStatusSvc,
+		apiKeys:       apiKeys,
+		webhooks:      webhooks,
+		scheduler:     scheduler,
+		readiness:     readiness,
+		candles:       candles,
+		watchlist:     watchlist,
 	}
 }
 
@@ -96,11 +124,15 @@ func (h *Handler) GetProtocolState(w http.ResponseWriter, r *http.Request) {
 
 	state, err := h.protocolSvc.GetState(r.Context())
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "PROTOCOL_STATE_ERROR", err.Error())
+		h.writeDependencyError(w, DependencySuiRPC, "PROTOCOL_STATE_ERROR", err.Error())
 		return
 	}
 
-	dto := ProtocolStateDTO{
+	h.writeJSON(w, http.StatusOK, newProtocolStateDTO(state))
+}
+
+func newProtocolStateDTO(state *onchain.ProtocolState) ProtocolStateDTO {
+	return ProtocolStateDTO{
 		CR:           state.CR.String(),
 		CRTarget:     state.CRTarget.String(),
 		ReservesR:    state.ReservesR.String(),
@@ -112,14 +144,12 @@ func (h *Handler) GetProtocolState(w http.ResponseWriter, r *http.Request) {
 		Mode:         state.Mode,
 		AsOf:         state.AsOf.Unix(),
 	}
-
-	h.writeJSON(w, http.StatusOK, dto)
 }
 
 func (h *Handler) GetProtocolHealth(w http.ResponseWriter, r *http.Request) {
 	health, err := h.protocolSvc.GetHealth(r.Context())
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "HEALTH_CHECK_ERROR", err.Error())
+		h.writeDependencyError(w, DependencySuiRPC, "HEALTH_CHECK_ERROR", err.Error())
 		return
 	}
 
@@ -255,6 +285,158 @@ func getEvmChainId(network string) string {
 	}
 }
 
+// GetProtocolEvents lists the indexed protocol events, newest first,
+// optionally of one type
+func (h *Handler) GetProtocolEvents(w http.ResponseWriter, r *http.Request) {
+	limit := 20 // default
+	cursor := r.URL.Query().Get("cursor")
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			limit = parsedLimit
+		}
+	}
+	eventType := strings.ToUpper(r.URL.Query().Get("type"))
+	if !validEventType(eventType) {
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "unknown event type")
+		return
+	}
+
+	events, nextCursor, err := h.protocolSvc.GetEvents(r.Context(), eventType, limit, cursor)
+	if errors.Is(err, onchain.ErrInvalidCursor) {
+		h.writeError(w, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+		return
+	}
+	if err != nil {
+		h.writeErrorFor(w, err, "PROTOCOL_EVENTS_ERROR")
+		return
+	}
+
+	items := make([]ProtocolEventItem, 0, len(events))
+	for _, event := range events {
+		items = append(items, ProtocolEventItem{
+			Hash:       event.TxDigest,
+			Sequence:   event.SequenceNumber,
+			Checkpoint: event.Checkpoint,
+			Type:       event.Type,
+			Sender:     event.Sender,
+			Amount:     event.Amount,
+			Token:      event.Token,
+			Fields:     event.Fields,
+			Timestamp:  event.Timestamp.Unix(),
+		})
+	}
+
+	h.writeJSON(w, http.StatusOK, ProtocolEventsDTO{
+		Items:      items,
+		NextCursor: nextCursor,
+		UpdatedAt:  time.Now().Unix(),
+	})
+}
+
+// validEventType reports whether eventType, uppercase, is empty or one of
+// the indexed event types
+func validEventType(eventType string) bool {
+	switch eventType {
+	case "", onchain.EventTypeMint, onchain.EventTypeRedeem, onchain.EventTypeStake,
+		onchain.EventTypeUnstake, onchain.EventTypeClaim, onchain.EventTypeRebalance:
+		return true
+	}
+	return false
+}
+
+// historyIntervals are the intervals the protocol history is downsampled
+// to, as for candles
+var historyIntervals = map[string]bool{"1m": true, "5m": true, "15m": true, "1h": true, "4h": true, "1d": true}
+
+// GetProtocolHistory returns the sampled series of a protocol metric,
+// downsampled to interval, from from to to. from and to are unix seconds
+// or RFC 3339 times and default to the last 24 hours.
+func (h *Handler) GetProtocolHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	metric := q.Get("metric")
+	if metric == "" {
+		metric = "cr"
+	}
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "1h"
+	}
+	if !historyIntervals[interval] {
+		h.writeError(w, http.StatusBadRequest, "INVALID_INTERVAL", "interval must be one of 1m, 5m, 15m, 1h, 4h, 1d")
+		return
+	}
+
+	from, to, ok := h.historyRange(w, r, 24*time.Hour)
+	if !ok {
+		return
+	}
+
+	points, err := h.protocolSvc.GetHistory(r.Context(), metric, prices.ParseInterval(interval), from, to)
+	switch {
+	case errors.Is(err, onchain.ErrUnknownMetric):
+		h.writeError(w, http.StatusBadRequest, "INVALID_METRIC", "metric must be one of cr, reserves, supply_f, supply_x, peg_deviation, price")
+		return
+	case errors.Is(err, onchain.ErrInvalidRange):
+		h.writeError(w, http.StatusBadRequest, "INVALID_RANGE", err.Error())
+		return
+	case err != nil:
+		h.writeErrorFor(w, err, "PROTOCOL_HISTORY_ERROR")
+		return
+	}
+
+	dto := ProtocolHistoryDTO{
+		Metric:   metric,
+		Interval: interval,
+		From:     from.Unix(),
+		To:       to.Unix(),
+		Points:   make([]ProtocolHistoryPoint, 0, len(points)),
+	}
+	for _, p := range points {
+		dto.Points = append(dto.Points, ProtocolHistoryPoint{
+			Time:    p.Time.Unix(),
+			Avg:     p.Avg,
+			Min:     p.Min,
+			Max:     p.Max,
+			Last:    p.Last,
+			Samples: p.Samples,
+		})
+	}
+	h.writeJSON(w, http.StatusOK, dto)
+}
+
+// historyRange returns the from and to query parameters, which default to
+// the span up to now
+func (h *Handler) historyRange(w http.ResponseWriter, r *http.Request, span time.Duration) (time.Time, time.Time, bool) {
+	q := r.URL.Query()
+	to := time.Now().UTC()
+	if v := q.Get("to"); v != "" {
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "to must be unix seconds or an RFC 3339 time")
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	from := to.Add(-span)
+	if v := q.Get("from"); v != "" {
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "from must be unix seconds or an RFC 3339 time")
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	return from, to, true
+}
+
+// parseHistoryTime parses unix seconds or an RFC 3339 time
+func parseHistoryTime(v string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
 func (h *Handler) GetProtocolMetrics(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	defer func() {
@@ -320,22 +502,12 @@ func (h *Handler) GetQuoteMintF(w http.ResponseWriter, r *http.Request) {
 		h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusOK, time.Since(start))
 	}()
 
-	amountRStr := r.URL.Query().Get("amountR")
-	if amountRStr == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "amountR is required")
-		return
-	}
-
-	amountR, err := decimal.NewFromString(amountRStr)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "invalid amountR format")
-		return
-	}
-
-	if err := calc.ValidateAmount(amountR, "mint"); err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", err.Error())
+	var req QuoteMintRequest
+	decodeQuery(r, &req)
+	if !h.validateRequest(w, &req, "") {
 		return
 	}
+	amountR := decimal.RequireFromString(req.AmountR)
 
 	quote, err := h.quoteSvc.GetMintQuote(r.Context(), amountR)
 	if err != nil {
@@ -361,22 +533,12 @@ func (h *Handler) GetQuoteRedeemF(w http.ResponseWriter, r *http.Request) {
 		h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusOK, time.Since(start))
 	}()
 
-	amountFStr := r.URL.Query().Get("amountF")
-	if amountFStr == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "amountF is required")
-		return
-	}
-
-	amountF, err := decimal.NewFromString(amountFStr)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "invalid amountF format")
-		return
-	}
-
-	if err := calc.ValidateAmount(amountF, "redeem"); err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", err.Error())
+	var req QuoteRedeemRequest
+	decodeQuery(r, &req)
+	if !h.validateRequest(w, &req, "") {
 		return
 	}
+	amountF := decimal.RequireFromString(req.AmountF)
 
 	quote, err := h.quoteSvc.GetRedeemQuote(r.Context(), amountF)
 	if err != nil {
@@ -402,22 +564,12 @@ func (h *Handler) GetQuoteMintX(w http.ResponseWriter, r *http.Request) {
 		h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusOK, time.Since(start))
 	}()
 
-	amountRStr := r.URL.Query().Get("amountR")
-	if amountRStr == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "amountR is required")
-		return
-	}
-
-	amountR, err := decimal.NewFromString(amountRStr)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "invalid amountR format")
-		return
-	}
-
-	if err := calc.ValidateAmount(amountR, "mint"); err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", err.Error())
+	var req QuoteMintRequest
+	decodeQuery(r, &req)
+	if !h.validateRequest(w, &req, "") {
 		return
 	}
+	amountR := decimal.RequireFromString(req.AmountR)
 
 	quote, err := h.quoteSvc.GetMintXQuote(r.Context(), amountR)
 	if err != nil {
@@ -443,22 +595,12 @@ func (h *Handler) GetQuoteRedeemX(w http.ResponseWriter, r *http.Request) {
 		h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusOK, time.Since(start))
 	}()
 
-	amountXStr := r.URL.Query().Get("amountX")
-	if amountXStr == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "amountX is required")
-		return
-	}
-
-	amountX, err := decimal.NewFromString(amountXStr)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "invalid amountX format")
-		return
-	}
-
-	if err := calc.ValidateAmount(amountX, "redeem"); err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", err.Error())
+	var req QuoteRedeemXRequest
+	decodeQuery(r, &req)
+	if !h.validateRequest(w, &req, "") {
 		return
 	}
+	amountX := decimal.RequireFromString(req.AmountX)
 
 	quote, err := h.quoteSvc.GetRedeemXQuote(r.Context(), amountX)
 	if err != nil {
@@ -482,7 +624,7 @@ func (h *Handler) GetQuoteRedeemX(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetSPIndex(w http.ResponseWriter, r *http.Request) {
 	index, err := h.spSvc.GetIndex(r.Context())
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "SP_INDEX_ERROR", err.Error())
+		h.writeDependencyError(w, DependencySuiRPC, "SP_INDEX_ERROR", err.Error())
 		return
 	}
 
@@ -497,15 +639,14 @@ func (h *Handler) GetSPIndex(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetSPUser(w http.ResponseWriter, r *http.Request) {
-	address := chi.URLParam(r, "address")
-	if address == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "address is required")
+	address, ok := h.addressParam(w, r)
+	if !ok {
 		return
 	}
 
 	userSP, err := h.spSvc.GetUserPosition(r.Context(), address)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "SP_USER_ERROR", err.Error())
+		h.writeErrorFor(w, err, "SP_USER_ERROR")
 		return
 	}
 
@@ -522,15 +663,14 @@ func (h *Handler) GetSPUser(w http.ResponseWriter, r *http.Request) {
 
 // User endpoints
 func (h *Handler) GetUserPositions(w http.ResponseWriter, r *http.Request) {
-	address := chi.URLParam(r, "address")
-	if address == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "address is required")
+	address, ok := h.addressParam(w, r)
+	if !ok {
 		return
 	}
 
 	positions, err := h.userSvc.GetPositions(r.Context(), address)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "USER_POSITIONS_ERROR", err.Error())
+		h.writeErrorFor(w, err, "USER_POSITIONS_ERROR")
 		return
 	}
 
@@ -562,26 +702,20 @@ func (h *Handler) GetUserPositions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetUserBalances(w http.ResponseWriter, r *http.Request) {
-	address := chi.URLParam(r, "address")
-	if address == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "address is required")
+	address, ok := h.addressParam(w, r)
+	if !ok {
 		return
 	}
 
 	balances, err := h.userSvc.GetBalances(r.Context(), address)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "USER_BALANCES_ERROR", err.Error())
+		h.writeErrorFor(w, err, "USER_BALANCES_ERROR")
 		return
 	}
 
-	addr, err := sui.AddressFromHex(address)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_ADDRESS", "invalid address format")
-		return
-	}
 
 	dto := UserBalancesDTO{
-		Address: addr,
+		Address: sui.MustAddressFromHex(address),
 		Balances: map[string]string{
 			"f": balances.F.String(),
 			"x": balances.X.String(),
@@ -594,9 +728,8 @@ func (h *Handler) GetUserBalances(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetUserTransactions(w http.ResponseWriter, r *http.Request) {
-	address := chi.URLParam(r, "address")
-	if address == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "address is required")
+	address, ok := h.addressParam(w, r)
+	if !ok {
 		return
 	}
 
@@ -609,36 +742,48 @@ func (h *Handler) GetUserTransactions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	events, nextCursor, err := h.userSvc.GetTransactions(r.Context(), address, limit, cursor)
+	filter, ok := h.transactionFilter(w, r)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "format must be one of json, csv, ndjson")
+		return
+	}
+	if format != "" {
+		h.exportUserTransactions(w, r, address, filter, format)
+		return
+	}
+
+	events, nextCursor, err := h.userSvc.GetTransactions(r.Context(), address, filter, limit, cursor)
+	if errors.Is(err, onchain.ErrInvalidCursor) {
+		h.writeError(w, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+		return
+	}
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "USER_TRANSACTIONS_ERROR", err.Error())
+		h.writeErrorFor(w, err, "USER_TRANSACTIONS_ERROR")
 		return
 	}
 
-	// Convert events to TransactionItems
+	// Convert events to TransactionItems. Events are only emitted by
+	// transactions that succeeded.
 	items := make([]TransactionItem, 0, len(events))
 	for _, event := range events {
-		// For now, create minimal transaction items from events
-		// In a real implementation, this would parse event data properly
 		item := TransactionItem{
 			Hash:      event.TxDigest,
 			Type:      event.Type,
-			Amount:    "0",      // Would parse from event data
-			Token:     "fToken", // Would parse from event data
+			Amount:    event.Amount,
+			Token:     event.Token,
 			Timestamp: event.Timestamp.Unix(),
-			Status:    "success", // Would determine from event data
+			Status:    "success",
 		}
 		items = append(items, item)
 	}
 
-	addr, err := sui.AddressFromHex(address)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "INVALID_ADDRESS", "invalid address format")
-		return
-	}
 
 	dto := UserTransactionsDTO{
-		Address:    addr,
+		Address:    sui.MustAddressFromHex(address),
 		Items:      items,
 		NextCursor: nextCursor,
 		UpdatedAt:  time.Now().Unix(),
@@ -647,28 +792,185 @@ func (h *Handler) GetUserTransactions(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, dto)
 }
 
+// transactionFilter returns the type, from and to query parameters of the
+// transactions of a user
+func (h *Handler) transactionFilter(w http.ResponseWriter, r *http.Request) (onchain.TransactionFilter, bool) {
+	q := r.URL.Query()
+	filter := onchain.TransactionFilter{Type: strings.ToUpper(q.Get("type"))}
+	if !validEventType(filter.Type) {
+		h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "unknown event type")
+		return filter, false
+	}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		v := q.Get(bound.name)
+		if v == "" {
+			continue
+		}
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", bound.name+" must be unix seconds or an RFC 3339 time")
+			return filter, false
+		}
+		*bound.t = t
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		h.writeError(w, http.StatusBadRequest, "INVALID_RANGE", "from must be before to")
+		return filter, false
+	}
+	return filter, true
+}
+
+// GetUserPortfolio returns the cost basis and P&L of the positions an
+// address minted, in USD, and its value at the end of each interval from
+// from to to. from and to default to the last 30 days.
+func (h *Handler) GetUserPortfolio(w http.ResponseWriter, r *http.Request) {
+	address, ok := h.addressParam(w, r)
+	if !ok {
+		return
+	}
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "1d"
+	}
+	if !historyIntervals[interval] {
+		h.writeError(w, http.StatusBadRequest, "INVALID_INTERVAL", "interval must be one of 1m, 5m, 15m, 1h, 4h, 1d")
+		return
+	}
+	from, to, ok := h.historyRange(w, r, 30*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	portfolio, err := h.userSvc.GetPortfolio(r.Context(), address, prices.ParseInterval(interval), from, to)
+	if errors.Is(err, onchain.ErrInvalidRange) {
+		h.writeError(w, http.StatusBadRequest, "INVALID_RANGE", err.Error())
+		return
+	}
+	if err != nil {
+		h.writeErrorFor(w, err, "USER_PORTFOLIO_ERROR")
+		return
+	}
+
+	dto := UserPortfolioDTO{
+		Address:       sui.MustAddressFromHex(address),
+		Positions:     make([]PortfolioPositionDTO, 0, len(portfolio.Positions)),
+		CostBasis:     usdString(portfolio.CostBasis),
+		Value:         usdString(portfolio.Value),
+		RealizedPnL:   usdString(portfolio.RealizedPnL),
+		UnrealizedPnL: usdString(portfolio.UnrealizedPnL),
+		Interval:      interval,
+		History:       make([]PortfolioPointDTO, 0, len(portfolio.History)),
+		UpdatedAt:     portfolio.AsOf.Unix(),
+	}
+	for _, p := range portfolio.Positions {
+		dto.Positions = append(dto.Positions, PortfolioPositionDTO{
+			Token:         p.Token,
+			Quantity:      p.Quantity.String(),
+			CostBasis:     usdString(p.CostBasis),
+			Price:         usdString(p.Price),
+			Value:         usdString(p.Value),
+			RealizedPnL:   usdString(p.RealizedPnL),
+			UnrealizedPnL: usdString(p.UnrealizedPnL),
+		})
+	}
+	for _, p := range portfolio.History {
+		dto.History = append(dto.History, PortfolioPointDTO{
+			Time:      p.Time.Unix(),
+			Value:     usdString(p.Value),
+			CostBasis: usdString(p.CostBasis),
+		})
+	}
+	h.writeJSON(w, http.StatusOK, dto)
+}
+
+// usdString formats a USD amount to the micro-dollar the oracle prices in
+func usdString(d decimal.Decimal) string {
+	return d.Round(6).String()
+}
+
+// addressParam returns the address URL parameter normalized, so that
+// addresses with leading zeros trimmed, as some zkLogin wallets show them,
+// match the senders and owners Sui reports
+func (h *Handler) addressParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	address := chi.URLParam(r, "address")
+	if address == "" {
+		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "address is required")
+		return "", false
+	}
+	normalized, err := onchain.NormalizeAddress(address)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_ADDRESS", "invalid address format")
+		return "", false
+	}
+	return normalized, true
+}
+
+// GetZkLoginNonce hands out the epoch and randomness a zkLogin session
+// derives its nonce from. The frontend computes the nonce with its
+// ephemeral public key, which never leaves it.
+func (h *Handler) GetZkLoginNonce(w http.ResponseWriter, r *http.Request) {
+	nonce, err := h.userSvc.ZkLoginNonce(r.Context())
+	if errors.Is(err, onchain.ErrZkLoginUnavailable) {
+		h.writeError(w, http.StatusServiceUnavailable, "ZKLOGIN_UNAVAILABLE", err.Error())
+		return
+	}
+	if err != nil {
+		h.writeDependencyError(w, DependencySuiRPC, "ZKLOGIN_NONCE_ERROR", err.Error())
+		return
+	}
+
+	dto := ZkLoginNonceResponse{
+		Epoch:      nonce.Epoch,
+		MaxEpoch:   nonce.MaxEpoch,
+		Randomness: nonce.Randomness,
+	}
+	if !nonce.EpochEndsAt.IsZero() {
+		dto.EpochEndsAt = nonce.EpochEndsAt.Unix()
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, http.StatusOK, dto)
+}
+
 // Health and ops endpoints
 func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
+// Readyz checks the dependencies, answering 503 when a critical one fails
+// so that load balancers route around this replica
 func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
-	// TODO: Add readiness checks (DB connection, Redis, etc.)
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("READY"))
+	if h.readiness == nil {
+		h.writeJSON(w, http.StatusOK, &health.Report{Status: health.StatusReady, CheckedAt: time.Now().UTC(), Checks: []health.CheckResult{}})
+		return
+	}
+	report := h.readiness.Check(r.Context())
+	w.Header().Set("Cache-Control", "no-store")
+	if !report.Ready() {
+		h.logger.Warnw("Readiness check failed", "checks", report.Checks)
+		h.writeJSON(w, http.StatusServiceUnavailable, report)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, report)
 }
 
 // WebSocket endpoint
 func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	h.wsHub.HandleWebSocket(w, r)
+	if r, ok := h.streamSession(w, r); ok {
+		h.wsHub.HandleWebSocket(w, r)
+	}
 }
 
 // Chart data endpoints are now in candles.go
 
 // SSE endpoint
 func (h *Handler) HandleSSE(w http.ResponseWriter, r *http.Request) {
-	h.sseHandler.HandleSSE(w, r)
+	if r, ok := h.streamSession(w, r); ok {
+		h.sseHandler.HandleSSE(w, r)
+	}
 }
 
 // Utility methods
@@ -704,14 +1006,7 @@ func (h *Handler) writeJSONWithLog(w http.ResponseWriter, status int, data any,
 func (h *Handler) writeError(w http.ResponseWriter, status int, code, message string) {
 	h.logger.Errorw("API error", "code", code, "message", message, "status", status)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-
-	err := ErrorResponse{
-		Code:    code,
-		Message: message,
-	}
-	json.NewEncoder(w).Encode(err)
+	writeProblem(w, newProblem(w, status, code, message))
 }
 
 func (h *Handler) writeErrorWithLog(w http.ResponseWriter, status int, code, message, requestID string) {
@@ -722,9 +1017,9 @@ func (h *Handler) writeErrorWithLog(w http.ResponseWriter, status int, code, mes
 		"status", status,
 	)
 
-	err := ErrorResponse{
-		Code:    code,
-		Message: message,
+	problem := newProblem(w, status, code, message)
+	if requestID != "" {
+		problem.RequestID = requestID
 	}
 
 	// Log the error response being sent
@@ -735,9 +1030,7 @@ func (h *Handler) writeErrorWithLog(w http.ResponseWriter, status int, code, mes
 		"error_message", message,
 	)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(err)
+	writeProblem(w, problem)
 }
 
 func generateQuoteID() string {
@@ -814,68 +1107,13 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 		"amount", req.Amount,
 	)
 
-	// Validate action
-	if req.Action != "mint" && req.Action != "redeem" {
-		h.logger.Errorw("Invalid action in transaction build request",
-			"request_id", requestID,
-			"action", req.Action,
-		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_ACTION", "action must be 'mint' or 'redeem'", requestID)
+	if !h.validateRequest(w, &req, requestID) {
 		return
 	}
+	amount := decimal.RequireFromString(req.Amount)
 
-	// Validate token type
-	if req.TokenType != "xtoken" && req.TokenType != "ftoken" {
-		h.logger.Errorw("Invalid token type in transaction build request",
-			"request_id", requestID,
-			"token_type", req.TokenType,
-		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_TOKEN_TYPE", "tokenType must be 'xtoken' or 'ftoken'", requestID)
-		return
-	}
-
-	// Parse amount
-	amount, err := decimal.NewFromString(req.Amount)
-	if err != nil {
-		h.logger.Errorw("Invalid amount format in transaction build request",
-			"request_id", requestID,
-			"amount", req.Amount,
-			"error", err,
-		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_AMOUNT", "Invalid amount format", requestID)
-		return
-	}
-
-	if amount.IsZero() || amount.IsNegative() {
-		h.logger.Errorw("Invalid amount value in transaction build request",
-			"request_id", requestID,
-			"amount", req.Amount,
-		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_AMOUNT", "Amount must be positive", requestID)
-		return
-	}
-
-	// Get user address from request headers or query params
-	userAddressStr := r.Header.Get("X-User-Address")
-	if userAddressStr == "" {
-		userAddressStr = r.URL.Query().Get("userAddress")
-	}
-	if userAddressStr == "" {
-		h.logger.Errorw("Missing user address in transaction build request",
-			"request_id", requestID,
-		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "MISSING_USER_ADDRESS", "User address is required in X-User-Address header or userAddress query parameter", requestID)
-		return
-	}
-
-	userAddress, err := sui.AddressFromHex(userAddressStr)
-	if err != nil {
-		h.logger.Errorw("Invalid user address format in transaction build request",
-			"request_id", requestID,
-			"user_address", userAddressStr,
-			"error", err,
-		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_USER_ADDRESS", "Invalid user address format", requestID)
+	userAddress, ok := h.userAddress(w, r, requestID)
+	if !ok {
 		return
 	}
 
@@ -904,6 +1142,21 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 		hasMarket = true
 	}
 
+	// Hold the transaction to its quote, if any
+	var quote *onchain.BoundQuote
+	if req.QuoteID != "" {
+		quote, err = h.quoteSvc.BindQuote(r.Context(), req.QuoteID, req.Action, req.TokenType, amount, req.SlippageBps)
+		if err != nil {
+			h.logger.Warnw("Quote rejected for transaction build",
+				"request_id", requestID,
+				"quote_id", req.QuoteID,
+				"error", err,
+			)
+			h.writeQuoteError(w, err, requestID)
+			return
+		}
+	}
+
 	switch req.Action {
 	case "mint":
 		unsignedTx, err = h.txBuilder.BuildMintTransaction(r.Context(), onchain.MintTxRequest{
@@ -911,6 +1164,7 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 			Amount:       amount,
 			UserAddress:  userAddress,
 			Mode:         mode,
+			Sponsored:    req.Sponsored,
 		})
 	case "redeem":
 		unsignedTx, err = h.txBuilder.BuildRedeemTransaction(r.Context(), onchain.RedeemTxRequest{
@@ -918,6 +1172,7 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 			Amount:      amount,
 			UserAddress: userAddress,
 			Mode:        mode,
+			Sponsored:   req.Sponsored,
 		})
 	}
 
@@ -928,9 +1183,13 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 			"action", req.Action,
 			"token_type", req.TokenType,
 			"amount", req.Amount,
-			"user_address", userAddressStr,
+			"user_address", userAddress.String(),
 		)
-		h.writeErrorWithLog(w, http.StatusInternalServerError, "TRANSACTION_BUILD_ERROR", "Failed to build unsigned transaction", requestID)
+		h.writeBuildError(w, err, requestID)
+		return
+	}
+	validation, ok := h.validateBuiltTransaction(w, r, userAddress, unsignedTx, mode, requestID)
+	if !ok {
 		return
 	}
 
@@ -939,6 +1198,21 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 		unsignedTx.Metadata = map[string]string{}
 	}
 
+	// Generate quote ID for tracking, unless built against a quote, which
+	// is used up unless only simulated
+	quoteID := generateQuoteID()
+	if quote != nil {
+		if mode == onchain.TxBuildModeExecution {
+			if err := h.quoteSvc.UseQuote(r.Context(), quote); err != nil {
+				h.writeQuoteError(w, err, requestID)
+				return
+			}
+		}
+		quoteID = quote.QuoteID
+		unsignedTx.Metadata["quotedOut"] = quote.Out.String()
+		unsignedTx.Metadata["minOut"] = quote.MinOut.String()
+	}
+
 	if hasMarket {
 		unsignedTx.Metadata["marketId"] = selectedMarket.ID
 		unsignedTx.Metadata["marketMode"] = selectedMarket.Mode
@@ -953,9 +1227,6 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	// Generate quote ID for tracking
-	quoteID := generateQuoteID()
-
 	h.logger.Infow("Transaction build successful",
 		"request_id", requestID,
 		"quote_id", quoteID,
@@ -963,6 +1234,7 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 		"token_type", req.TokenType,
 		"amount", req.Amount,
 		"gas_estimate", unsignedTx.GasEstimate,
+		"gas_budget", unsignedTx.GasBudget,
 		"tx_bytes_length", len(unsignedTx.TransactionBlockBytes),
 		"duration", time.Since(start),
 	)
@@ -971,13 +1243,93 @@ func (h *Handler) BuildUnsignedTransaction(w http.ResponseWriter, r *http.Reques
 	response := UnsignedTransactionResponse{
 		TransactionBlockBytes: unsignedTx.TransactionBlockBytes,
 		GasEstimate:           fmt.Sprintf("%d", unsignedTx.GasEstimate),
+		GasBudget:             fmt.Sprintf("%d", unsignedTx.GasBudget),
 		QuoteID:               quoteID,
 		Metadata:              unsignedTx.Metadata,
+		Validation:            validation,
 	}
 
 	h.writeJSONWithLog(w, http.StatusOK, response, requestID)
 }
 
+// validateBuiltTransaction simulates tx when the request asks for it with
+// validate=true, and returns the outcome. Transactions that would fail are
+// answered with 422 and false.
+func (h *Handler) validateBuiltTransaction(w http.ResponseWriter, r *http.Request, sender *sui.Address, tx *onchain.UnsignedTransaction, mode onchain.TxBuildMode, requestID string) (*ValidationDTO, bool) {
+	if r.URL.Query().Get("validate") != "true" {
+		return nil, true
+	}
+	validation, err := h.txBuilder.ValidateTransaction(r.Context(), sender, tx.TransactionBlockBytes, mode)
+	if err != nil {
+		h.logger.Errorw("Failed to validate transaction", "request_id", requestID, "error", err)
+		h.writeErrorWithLog(w, http.StatusInternalServerError, "VALIDATION_ERROR", "Failed to validate transaction", requestID)
+		return nil, false
+	}
+	switch {
+	case validation.Abort != nil:
+		h.writeMoveAbort(w, validation.Abort, requestID)
+		return nil, false
+	case !validation.Success:
+		h.writeErrorWithLog(w, http.StatusUnprocessableEntity, "TRANSACTION_WOULD_FAIL", validation.Error, requestID)
+		return nil, false
+	}
+	return &ValidationDTO{
+		GasUsed: fmt.Sprintf("%d", validation.GasUsed),
+		Effects: validation.Effects,
+	}, true
+}
+
+// writeMoveAbort answers with the reason of a Move abort, detailing where
+// it happened
+func (h *Handler) writeMoveAbort(w http.ResponseWriter, abort *onchain.MoveAbortError, requestID string) {
+	h.logger.Infow("Sending error response", "request_id", requestID, "error_code", "MOVE_ABORT", "abort", abort.Error())
+
+	problem := newProblem(w, http.StatusUnprocessableEntity, "MOVE_ABORT", abort.Reason)
+	problem.Details = abort.Error()
+	if requestID != "" {
+		problem.RequestID = requestID
+	}
+	writeProblem(w, problem)
+}
+
+// writeBuildError maps the errors of building user transactions to
+// responses
+func (h *Handler) writeBuildError(w http.ResponseWriter, err error, requestID string) {
+	var abort *onchain.MoveAbortError
+	if errors.As(err, &abort) {
+		h.writeMoveAbort(w, abort, requestID)
+		return
+	}
+	switch {
+	case errors.Is(err, onchain.ErrInsufficientBalance):
+		h.writeErrorWithLog(w, http.StatusBadRequest, "INSUFFICIENT_BALANCE", err.Error(), requestID)
+	case errors.Is(err, onchain.ErrCoinsFragmented):
+		h.writeErrorWithLog(w, http.StatusUnprocessableEntity, "COINS_FRAGMENTED", err.Error()+"; consolidate them with /v1/transactions/consolidate/build", requestID)
+	case errors.Is(err, onchain.ErrSponsorUnavailable):
+		h.writeErrorWithLog(w, http.StatusBadRequest, "SPONSORSHIP_UNAVAILABLE", err.Error(), requestID)
+	case errors.Is(err, onchain.ErrSponsorQuotaExceeded):
+		h.writeErrorWithLog(w, http.StatusTooManyRequests, "SPONSOR_QUOTA_EXCEEDED", err.Error(), requestID)
+	case errors.Is(err, onchain.ErrNoSPPosition):
+		h.writeErrorWithLog(w, http.StatusNotFound, "NO_SP_POSITION", err.Error(), requestID)
+	default:
+		h.writeErrorWithLog(w, http.StatusInternalServerError, "TRANSACTION_BUILD_ERROR", "Failed to build unsigned transaction", requestID)
+	}
+}
+
+// writeQuoteError maps the errors of quote-bound building to responses
+func (h *Handler) writeQuoteError(w http.ResponseWriter, err error, requestID string) {
+	switch {
+	case errors.Is(err, onchain.ErrQuoteNotFound):
+		h.writeErrorWithLog(w, http.StatusGone, "QUOTE_EXPIRED", err.Error(), requestID)
+	case errors.Is(err, onchain.ErrQuoteMismatch):
+		h.writeErrorWithLog(w, http.StatusBadRequest, "QUOTE_MISMATCH", err.Error(), requestID)
+	case errors.Is(err, onchain.ErrQuoteMoved):
+		h.writeErrorWithLog(w, http.StatusConflict, "QUOTE_MOVED", err.Error(), requestID)
+	default:
+		h.writeErrorWithLog(w, http.StatusInternalServerError, "QUOTE_ERROR", err.Error(), requestID)
+	}
+}
+
 // SubmitSignedTransaction handles submission of signed transactions
 func (h *Handler) SubmitSignedTransaction(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
@@ -1057,26 +1409,25 @@ func (h *Handler) SubmitSignedTransaction(w http.ResponseWriter, r *http.Request
 		"signature_length", len(req.Signature),
 	)
 
-	// Validate required fields
-	if req.TxBytes == "" {
-		h.logger.Errorw("Transaction submission missing required field",
-			"request_id", requestID,
-			"missing_field", "tx_bytes",
-		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "MISSING_PARAMETER", "tx_bytes is required", requestID)
+	if !h.validateRequest(w, &req, requestID) {
 		return
 	}
-	if req.Signature == "" {
+	signatures := req.allSignatures()
+	if len(signatures) == 0 {
 		h.logger.Errorw("Transaction submission missing required field",
 			"request_id", requestID,
 			"missing_field", "signature",
 		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "MISSING_PARAMETER", "signature is required", requestID)
+		h.writeErrorWithLog(w, http.StatusBadRequest, "MISSING_PARAMETER", "signature or signatures is required", requestID)
 		return
 	}
+	schemes := make([]string, len(signatures))
+	for i, signature := range signatures {
+		schemes[i] = onchain.SignatureScheme(signature)
+	}
 
 	// Submit the signed transaction
-	result, err := h.txSubmitter.SubmitSignedTransaction(r.Context(), req.TxBytes, req.Signature)
+	result, err := h.txSubmitter.SubmitSignedTransaction(r.Context(), req.TxBytes, signatures...)
 	if err != nil {
 		h.logger.Errorw("Transaction submission failed",
 			"request_id", requestID,
@@ -1084,29 +1435,115 @@ func (h *Handler) SubmitSignedTransaction(w http.ResponseWriter, r *http.Request
 			"error", err.Error(),
 			"tx_bytes_length", len(req.TxBytes),
 			"signature_length", len(req.Signature),
+			"signature_schemes", schemes,
 			"remote_addr", r.RemoteAddr,
 		)
-		h.writeErrorWithLog(w, http.StatusBadRequest, "SUBMISSION_ERROR", err.Error(), requestID)
+		switch {
+		case errors.Is(err, onchain.ErrSponsorQuotaExceeded):
+			h.writeErrorWithLog(w, http.StatusTooManyRequests, "SPONSOR_QUOTA_EXCEEDED", err.Error(), requestID)
+		case errors.Is(err, onchain.ErrInvalidSignature):
+			h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_SIGNATURE", err.Error(), requestID)
+		default:
+			h.writeErrorWithLog(w, http.StatusBadRequest, "SUBMISSION_ERROR", err.Error(), requestID)
+		}
 		return
 	}
 
-	h.logger.Infow("Transaction submission successful",
+	h.logger.Infow("Transaction submitted",
 		"request_id", requestID,
 		"quote_id", req.QuoteID,
 		"transaction_digest", result.TransactionDigest,
 		"status", result.Status,
+		"execution_error", result.Error,
+		"signatures", len(signatures),
+		"signature_schemes", schemes,
 		"duration", time.Since(start),
 	)
 
+	// Push the status to clients subscribed to the transaction once final
+	h.watchTransaction(r.Context(), result.TransactionDigest)
+
 	// Create response
 	response := SignedTransactionResponse{
 		TransactionDigest: result.TransactionDigest,
 		Status:            result.Status,
+		Error:             result.Error,
 	}
 
 	h.writeJSONWithLog(w, http.StatusOK, response, requestID)
 }
 
+// GetTransactionStatus returns the state of a transaction on chain. With
+// watch=true the status is pushed on the WebSocket topic tx:<digest>
+// once the transaction is final.
+func (h *Handler) GetTransactionStatus(w http.ResponseWriter, r *http.Request) {
+	if h.txStatusSvc == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "transaction status is not available")
+		return
+	}
+	digest := chi.URLParam(r, "digest")
+
+	status, err := h.txStatusSvc.GetStatus(r.Context(), digest)
+	if errors.Is(err, onchain.ErrInvalidDigest) {
+		h.writeError(w, http.StatusBadRequest, "INVALID_DIGEST", err.Error())
+		return
+	}
+	if err != nil {
+		h.writeDependencyError(w, DependencySuiRPC, "TRANSACTION_STATUS_ERROR", err.Error())
+		return
+	}
+
+	if !status.Finalized {
+		if watch, _ := strconv.ParseBool(r.URL.Query().Get("watch")); watch {
+			h.watchTransaction(r.Context(), digest)
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, newTransactionStatusDTO(status))
+}
+
+// watchTransaction pushes the status of digest to the subscribers of
+// tx:<digest> once it is final
+func (h *Handler) watchTransaction(ctx context.Context, digest string) {
+	if h.txStatusSvc == nil || h.wsHub == nil {
+		return
+	}
+	h.txStatusSvc.Watch(context.WithoutCancel(ctx), digest, func(ctx context.Context, status *onchain.TransactionStatus) {
+		if err := h.wsHub.Push(ctx, ws.TransactionTopic(digest), newTransactionStatusDTO(status)); err != nil {
+			h.logger.Warnw("Failed to push transaction status", "digest", digest, "error", err)
+		}
+	})
+}
+
+func newTransactionStatusDTO(status *onchain.TransactionStatus) TransactionStatusDTO {
+	dto := TransactionStatusDTO{
+		TransactionDigest: status.Digest,
+		Status:            status.Status,
+		Finalized:         status.Finalized,
+		Checkpoint:        status.Checkpoint,
+		Error:             status.Error,
+		BalanceChanges:    make([]BalanceChangeDTO, 0, len(status.BalanceChanges)),
+	}
+	if !status.Timestamp.IsZero() {
+		dto.Timestamp = status.Timestamp.Unix()
+	}
+	if status.GasUsed != nil {
+		dto.GasUsed = &GasUsedDTO{
+			ComputationCost: status.GasUsed.ComputationCost,
+			StorageCost:     status.GasUsed.StorageCost,
+			StorageRebate:   status.GasUsed.StorageRebate,
+		}
+	}
+	for _, change := range status.BalanceChanges {
+		dto.BalanceChanges = append(dto.BalanceChanges, BalanceChangeDTO{
+			Owner:    change.Owner,
+			CoinType: change.CoinType,
+			Amount:   change.Amount,
+		})
+	}
+	return dto
+}
+
 // TransactionMonitor endpoint for frontend to report transaction attempts
 func (h *Handler) ReportTransactionAttempt(w http.ResponseWriter, r *http.Request) {
 	requestID := r.Header.Get("X-Request-ID")
@@ -1235,6 +1672,154 @@ func toSuiBalanceScale(d decimal.Decimal) decimal.Decimal {
 	return d.Div(decimal.NewFromBigInt(big.NewInt(1), unit.SuiDecimal))
 }
 
+// BuildConsolidateCoinsTransaction builds an unsigned transaction merging
+// the user's coins of a token type
+func (h *Handler) BuildConsolidateCoinsTransaction(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusOK, time.Since(start))
+	}()
+
+	var req ConsolidateCoinsBuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+	mode := onchain.TxBuildModeExecution
+	if req.Mode == "devinspect" {
+		mode = onchain.TxBuildModeDevInspect
+	}
+
+	userAddress, ok := h.userAddress(w, r, "")
+	if !ok {
+		return
+	}
+
+	unsignedTx, err := h.txBuilder.BuildConsolidateCoinsTransaction(r.Context(), onchain.ConsolidateCoinsTxRequest{
+		TokenType:   req.TokenType,
+		UserAddress: userAddress,
+		Mode:        mode,
+	})
+	if err != nil {
+		h.writeErrorFor(w, err, "TRANSACTION_BUILD_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, UnsignedTransactionResponse{
+		TransactionBlockBytes: unsignedTx.TransactionBlockBytes,
+		GasEstimate:           fmt.Sprintf("%d", unsignedTx.GasEstimate),
+		GasBudget:             fmt.Sprintf("%d", unsignedTx.GasBudget),
+		Metadata:              unsignedTx.Metadata,
+	})
+}
+
+// BuildSPDepositTransaction builds an unsigned deposit of fTokens into the
+// stability pool
+func (h *Handler) BuildSPDepositTransaction(w http.ResponseWriter, r *http.Request) {
+	h.buildSPTransaction(w, r, "deposit")
+}
+
+// BuildSPWithdrawTransaction builds an unsigned withdrawal of fTokens from
+// the stability pool
+func (h *Handler) BuildSPWithdrawTransaction(w http.ResponseWriter, r *http.Request) {
+	h.buildSPTransaction(w, r, "withdraw")
+}
+
+// BuildSPClaimTransaction builds an unsigned claim of stability pool
+// rewards
+func (h *Handler) BuildSPClaimTransaction(w http.ResponseWriter, r *http.Request) {
+	h.buildSPTransaction(w, r, "claim")
+}
+
+// buildSPTransaction builds the stability pool transaction for action.
+// Signed transactions are submitted to /v1/transactions/submit.
+func (h *Handler) buildSPTransaction(w http.ResponseWriter, r *http.Request, action string) {
+	start := time.Now()
+	defer func() {
+		h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusOK, time.Since(start))
+	}()
+	requestID := r.Header.Get("X-Request-ID")
+
+	var req SPTransactionBuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body", requestID)
+		return
+	}
+
+	if !h.validateRequest(w, &req, requestID) {
+		return
+	}
+	mode := onchain.TxBuildModeExecution
+	if req.Mode == "devinspect" {
+		mode = onchain.TxBuildModeDevInspect
+	}
+
+	var amount decimal.Decimal
+	if action != "claim" {
+		if req.Amount == "" {
+			h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_AMOUNT", "amount is required", requestID)
+			return
+		}
+		amount = decimal.RequireFromString(req.Amount)
+	}
+
+	userAddress, ok := h.userAddress(w, r, requestID)
+	if !ok {
+		return
+	}
+
+	var unsignedTx *onchain.UnsignedTransaction
+	var err error
+	switch action {
+	case "deposit":
+		unsignedTx, err = h.txBuilder.BuildSPDepositTransaction(r.Context(), onchain.SPDepositTxRequest{
+			Amount:      amount,
+			UserAddress: userAddress,
+			Mode:        mode,
+			Sponsored:   req.Sponsored,
+		})
+	case "withdraw":
+		unsignedTx, err = h.txBuilder.BuildSPWithdrawTransaction(r.Context(), onchain.SPWithdrawTxRequest{
+			Amount:      amount,
+			UserAddress: userAddress,
+			Mode:        mode,
+			Sponsored:   req.Sponsored,
+		})
+	case "claim":
+		unsignedTx, err = h.txBuilder.BuildSPClaimTransaction(r.Context(), onchain.SPClaimTxRequest{
+			UserAddress: userAddress,
+			Mode:        mode,
+			Sponsored:   req.Sponsored,
+		})
+	}
+	if err != nil {
+		h.logger.Errorw("Failed to build stability pool transaction",
+			"request_id", requestID,
+			"error", err,
+			"action", action,
+			"amount", req.Amount,
+			"user_address", userAddress.String(),
+		)
+		h.writeBuildError(w, err, requestID)
+		return
+	}
+	validation, ok := h.validateBuiltTransaction(w, r, userAddress, unsignedTx, mode, requestID)
+	if !ok {
+		return
+	}
+
+	h.writeJSONWithLog(w, http.StatusOK, UnsignedTransactionResponse{
+		TransactionBlockBytes: unsignedTx.TransactionBlockBytes,
+		GasEstimate:           fmt.Sprintf("%d", unsignedTx.GasEstimate),
+		GasBudget:             fmt.Sprintf("%d", unsignedTx.GasBudget),
+		Metadata:              unsignedTx.Metadata,
+		Validation:            validation,
+	}, requestID)
+}
+
 // BuildUpdateOracleTransaction builds unsigned transaction for oracle updates
 func (h *Handler) BuildUpdateOracleTransaction(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
@@ -1248,31 +1833,40 @@ func (h *Handler) BuildUpdateOracleTransaction(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Convert mode string to TxBuildMode type
-	var mode onchain.TxBuildMode
-	switch req.Mode {
-	case "execution":
-		mode = onchain.TxBuildModeExecution
-	case "devinspect":
-		mode = onchain.TxBuildModeDevInspect
-	default:
-		h.writeError(w, http.StatusBadRequest, "INVALID_MODE", "mode must be 'execution' or 'devinspect'")
+	if !h.validateRequest(w, &req, "") {
 		return
 	}
+	mode := onchain.TxBuildModeExecution
+	if req.Mode == "devinspect" {
+		mode = onchain.TxBuildModeDevInspect
+	}
 
 	txReq := onchain.UpdateOracleTxRequest{
 		NewPrice: req.Price,
 		Mode:     mode,
 	}
+	if req.Sender != "" {
+		sender, err := sui.AddressFromHex(req.Sender)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "INVALID_SENDER", "Invalid sender address format")
+			return
+		}
+		txReq.SignerAddress = sender
+	}
 	unsignedTx, err := h.txBuilder.BuildUpdateOracleTransaction(r.Context(), txReq)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "TRANSACTION_BUILD_ERROR", err.Error())
+		if errors.Is(err, onchain.ErrOracleStale) || errors.Is(err, onchain.ErrOracleUncertain) {
+			h.writeError(w, http.StatusServiceUnavailable, "ORACLE_PRICE_REJECTED", err.Error())
+			return
+		}
+		h.writeErrorFor(w, err, "TRANSACTION_BUILD_ERROR")
 		return
 	}
 
 	response := UpdateOracleBuildResponse{
 		TransactionBlockBytes: unsignedTx.TransactionBlockBytes,
 		GasEstimate:           fmt.Sprintf("%d", unsignedTx.GasEstimate),
+		GasBudget:             fmt.Sprintf("%d", unsignedTx.GasBudget),
 		Metadata:              unsignedTx.Metadata,
 	}
 
@@ -1292,17 +1886,21 @@ func (h *Handler) SubmitUpdateOracleTransaction(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if req.TxBytes == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "tx_bytes is required")
+	if !h.validateRequest(w, &req, "") {
 		return
 	}
-	if req.Signature == "" {
-		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "signature is required")
+	signatures := joinSignatures(req.Signature, req.Signatures)
+	if len(signatures) == 0 {
+		h.writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "signature or signatures is required")
 		return
 	}
 
-	result, err := h.txSubmitter.SubmitSignedTransaction(r.Context(), req.TxBytes, req.Signature)
+	result, err := h.txSubmitter.SubmitSignedTransaction(r.Context(), req.TxBytes, signatures...)
 	if err != nil {
+		if errors.Is(err, onchain.ErrInvalidSignature) {
+			h.writeError(w, http.StatusBadRequest, "INVALID_SIGNATURE", err.Error())
+			return
+		}
 		h.writeError(w, http.StatusBadRequest, "SUBMISSION_ERROR", err.Error())
 		return
 	}
@@ -1310,6 +1908,7 @@ func (h *Handler) SubmitUpdateOracleTransaction(w http.ResponseWriter, r *http.R
 	response := UpdateOracleSubmitResponse{
 		TransactionDigest: result.TransactionDigest,
 		Status:            result.Status,
+		Error:             result.Error,
 	}
 
 	h.writeJSON(w, http.StatusOK, response)
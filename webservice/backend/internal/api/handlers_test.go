@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/leafsii/leafsii-backend/internal/health"
 	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/pattonkan/sui-go/sui"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -46,6 +49,46 @@ func (m *MockTransactionBuilder) BuildUpdateOracleTransaction(ctx context.Contex
 	return args.Get(0).(*onchain.UnsignedTransaction), args.Error(1)
 }
 
+func (m *MockTransactionBuilder) BuildConsolidateCoinsTransaction(ctx context.Context, req onchain.ConsolidateCoinsTxRequest) (*onchain.UnsignedTransaction, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*onchain.UnsignedTransaction), args.Error(1)
+}
+
+func (m *MockTransactionBuilder) BuildSPDepositTransaction(ctx context.Context, req onchain.SPDepositTxRequest) (*onchain.UnsignedTransaction, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*onchain.UnsignedTransaction), args.Error(1)
+}
+
+func (m *MockTransactionBuilder) BuildSPWithdrawTransaction(ctx context.Context, req onchain.SPWithdrawTxRequest) (*onchain.UnsignedTransaction, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*onchain.UnsignedTransaction), args.Error(1)
+}
+
+func (m *MockTransactionBuilder) BuildSPClaimTransaction(ctx context.Context, req onchain.SPClaimTxRequest) (*onchain.UnsignedTransaction, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*onchain.UnsignedTransaction), args.Error(1)
+}
+
+func (m *MockTransactionBuilder) ValidateTransaction(ctx context.Context, sender *sui.Address, txBytes []byte, mode onchain.TxBuildMode) (*onchain.TransactionValidation, error) {
+	args := m.Called(ctx, sender, txBytes, mode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*onchain.TransactionValidation), args.Error(1)
+}
+
 // Ensure MockTransactionBuilder implements the interface
 var _ onchain.TransactionBuilderInterface = (*MockTransactionBuilder)(nil)
 
@@ -464,3 +507,26 @@ func TestBuildUnsignedTransaction_EdgeCases(t *testing.T) {
 		mockTxBuilder.AssertExpectations(t)
 	})
 }
+
+func TestReadyz(t *testing.T) {
+	readiness := health.NewChecker(time.Second, []string{"database"})
+	readiness.Register("database", func(context.Context) error { return nil })
+	readiness.Register("prices", func(context.Context) error { return errors.New("no recent SUIUSDT price") })
+	h := &Handler{logger: zap.NewNop().Sugar(), metrics: &MockMetrics{}, readiness: readiness}
+
+	rec := httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var report health.Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, health.StatusDegraded, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, health.StatusDown, report.Checks[1].Status)
+	assert.False(t, report.Checks[1].Critical)
+
+	readiness.Register("database", func(context.Context) error { return errors.New("connection refused") })
+	rec = httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"unavailable"`)
+}
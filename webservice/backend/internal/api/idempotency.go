@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/leafsii/leafsii-backend/internal/store"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	// DefaultIdempotencyTTL is how long responses are replayed when the
+	// config sets no LFS_IDEMPOTENCY_TTL
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyLockTTL bounds how long a first request holds its key,
+	// past the request timeout
+	idempotencyLockTTL = 30 * time.Second
+)
+
+// idempotentResponse is the first response to a request with an
+// Idempotency-Key, and the hash of the body it answered
+type idempotentResponse struct {
+	BodyHash    string `json:"bodyHash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// Idempotency replays the first response to requests repeating the
+// Idempotency-Key of an earlier one on the same route by the same caller,
+// for ttl, so that retries do not submit twice. A key reused with another
+// body is rejected with 409, as is one whose first request is still
+// running. Server errors and rate limiting are not kept, so that they can
+// be retried. Requests without the header, or without a cache, pass.
+//
+// Before replaying, authorize, if any, is given the suiOwner of the body,
+// as the handler would be, so that a replay answers no one the first
+// request would not have.
+func (m *Middleware) Idempotency(cache *store.Cache, ttl time.Duration, authorize func(w http.ResponseWriter, r *http.Request, owner string) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderIdempotencyKey)
+			if key == "" || cache == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeBackoffError(w, http.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY", "Idempotency-Key must be at most 255 characters", BackoffHint{})
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeBackoffError(w, http.StatusBadRequest, "INVALID_BODY", "Failed to read request body", BackoffHint{})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			bodyHash := sha256.Sum256(body)
+
+			if authorize != nil {
+				var owned struct {
+					SuiOwner string `json:"suiOwner"`
+				}
+				if json.Unmarshal(body, &owned) == nil && owned.SuiOwner != "" && !authorize(w, r, owned.SuiOwner) {
+					return
+				}
+			}
+
+			cacheKey := idempotencyCacheKey(r.Method, r.URL.Path, idempotencyCaller(r), key)
+			kept := idempotentRequest{cache: cache, key: cacheKey, bodyHash: hex.EncodeToString(bodyHash[:])}
+
+			ctx := r.Context()
+			if kept.replay(ctx, w) {
+				return
+			}
+			lockKey := "fx:idempotency:lock:" + cacheKey
+			owner := randomToken(8)
+			held, err := cache.AcquireLock(ctx, lockKey, owner, idempotencyLockTTL)
+			if err != nil {
+				writeBackoffError(w, http.StatusServiceUnavailable, "IDEMPOTENCY_UNAVAILABLE", "Idempotency keys are unavailable", BackoffHint{RetryAfter: time.Second})
+				return
+			}
+			if !held {
+				writeBackoffError(w, http.StatusConflict, "IDEMPOTENCY_IN_PROGRESS", "A request with this Idempotency-Key is in progress", BackoffHint{RetryAfter: time.Second})
+				return
+			}
+			defer cache.ReleaseLock(context.WithoutCancel(ctx), lockKey, owner)
+			// The first request may have finished between the lookup and
+			// the lock
+			if kept.replay(ctx, w) {
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			var buf bytes.Buffer
+			ww.Tee(&buf)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+				return
+			}
+			resp := idempotentResponse{
+				BodyHash:    kept.bodyHash,
+				Status:      status,
+				ContentType: ww.Header().Get("Content-Type"),
+				Body:        buf.Bytes(),
+			}
+			if err := cache.SetIdempotentResponse(context.WithoutCancel(ctx), cacheKey, resp, ttl); err != nil {
+				m.logger.Warnw("Failed to keep idempotent response", "path", r.URL.Path, "error", err)
+			}
+		})
+	}
+}
+
+// idempotencyCacheKey scopes key to the route and the caller, and hashes
+// it to bound its length
+func idempotencyCacheKey(method, path, caller, key string) string {
+	hash := sha256.Sum256([]byte(method + " " + path + "\n" + caller + "\n" + key))
+	return hex.EncodeToString(hash[:])
+}
+
+// idempotencyCaller identifies who made r: the address of its wallet
+// session, else its API key, else no one
+func idempotencyCaller(r *http.Request) string {
+	if address, ok := r.Context().Value(sessionAddressKey{}).(string); ok {
+		return "session:" + address
+	}
+	if key := apiKeyFrom(r.Context()); key != nil {
+		return "apikey:" + key.ID
+	}
+	return ""
+}
+
+// idempotentRequest looks up the kept response of a request
+type idempotentRequest struct {
+	cache    *store.Cache
+	key      string
+	bodyHash string
+}
+
+// replay answers with the kept response, or with 409 when it answered
+// another body, and reports whether it did. Requests are not run while
+// the cache cannot tell whether they were.
+func (req idempotentRequest) replay(ctx context.Context, w http.ResponseWriter) bool {
+	var kept idempotentResponse
+	err := req.cache.GetIdempotentResponse(ctx, req.key, &kept)
+	if errors.Is(err, store.ErrCacheMiss) {
+		return false
+	}
+	if err != nil {
+		writeBackoffError(w, http.StatusServiceUnavailable, "IDEMPOTENCY_UNAVAILABLE", "Idempotency keys are unavailable", BackoffHint{RetryAfter: time.Second})
+		return true
+	}
+	if kept.BodyHash != req.bodyHash {
+		writeBackoffError(w, http.StatusConflict, "IDEMPOTENCY_KEY_REUSED", "Idempotency-Key was used with another request body", BackoffHint{})
+		return true
+	}
+
+	if kept.ContentType != "" {
+		w.Header().Set("Content-Type", kept.ContentType)
+	}
+	w.Header().Set(HeaderIdempotentReplayed, "true")
+	w.WriteHeader(kept.Status)
+	w.Write(kept.Body)
+	return true
+}
+
+// idempotencyTTL returns how long responses to Idempotency-Keys are kept
+func (h *Handler) idempotencyTTL() time.Duration {
+	if h.config != nil && h.config.Security.IdempotencyTTL > 0 {
+		return h.config.Security.IdempotencyTTL
+	}
+	return DefaultIdempotencyTTL
+}
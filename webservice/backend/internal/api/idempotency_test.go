@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIdempotency(t *testing.T) {
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	m := NewMiddleware(zap.NewNop().Sugar(), nil)
+
+	calls := 0
+	status := http.StatusCreated
+	handler := m.Idempotency(cache, time.Minute, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d}`, calls)
+	}))
+	send := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("/submit", "k1", `{"tx":"a"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, `{"call":1}`, first.Body.String())
+	assert.Empty(t, first.Header().Get(HeaderIdempotentReplayed))
+
+	// Retries replay the first response
+	retry := send("/submit", "k1", `{"tx":"a"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, `{"call":1}`, retry.Body.String())
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, "true", retry.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, 1, calls)
+
+	mismatch := send("/submit", "k1", `{"tx":"b"}`)
+	assert.Equal(t, http.StatusConflict, mismatch.Code)
+	assert.Contains(t, mismatch.Body.String(), "IDEMPOTENCY_KEY_REUSED")
+
+	// Keys are scoped to the route, and optional
+	assert.Equal(t, `{"call":2}`, send("/redeem", "k1", `{"tx":"a"}`).Body.String())
+	assert.Equal(t, `{"call":3}`, send("/submit", "", `{"tx":"a"}`).Body.String())
+	assert.Equal(t, `{"call":4}`, send("/submit", "", `{"tx":"a"}`).Body.String())
+
+	// Server errors are not kept, so that they can be retried
+	status = http.StatusBadGateway
+	assert.Equal(t, http.StatusBadGateway, send("/submit", "k2", `{}`).Code)
+	status = http.StatusOK
+	assert.Equal(t, `{"call":6}`, send("/submit", "k2", `{}`).Body.String())
+	assert.Equal(t, `{"call":6}`, send("/submit", "k2", `{}`).Body.String())
+
+	// A key held by a running request is busy
+	held, err := cache.AcquireLock(context.Background(), "fx:idempotency:lock:"+idempotencyCacheKey("POST", "/submit", "", "k3"), "other", time.Minute)
+	require.NoError(t, err)
+	require.True(t, held)
+	busy := send("/submit", "k3", `{}`)
+	assert.Equal(t, http.StatusConflict, busy.Code)
+	assert.Contains(t, busy.Body.String(), "IDEMPOTENCY_IN_PROGRESS")
+}
+
+func TestIdempotencySessions(t *testing.T) {
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	logger := zap.NewNop().Sugar()
+	m := NewMiddleware(logger, nil)
+	h := &Handler{logger: logger, metrics: &MockMetrics{}}
+	alice := sui.MustAddressFromHex("0xa11ce").String()
+	bob := sui.MustAddressFromHex("0xb0b").String()
+
+	calls := 0
+	// Session tokens are the addresses they are for
+	sessions := func(ctx context.Context, token string) (string, error) { return token, nil }
+	handler := m.WalletAuth(sessions, true)(m.Idempotency(cache, time.Minute, h.authorizeOwner)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, `{"call":%d}`, calls)
+	})))
+	send := func(session, owner string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/deposits", bytes.NewBufferString(`{"suiOwner":"`+owner+`"}`))
+		req.Header.Set("Authorization", "Bearer "+session)
+		req.Header.Set(HeaderIdempotencyKey, "k1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send(alice, alice)
+	assert.Equal(t, http.StatusAccepted, first.Code)
+	assert.Equal(t, `{"call":1}`, first.Body.String())
+
+	// Another session repeating the key and body is not answered the first
+	// response, since it may not act for its owner
+	stolen := send(bob, alice)
+	assert.Equal(t, http.StatusForbidden, stolen.Code)
+	assert.Empty(t, stolen.Header().Get(HeaderIdempotentReplayed))
+
+	// Keys are scoped to the session
+	own := send(bob, bob)
+	assert.Equal(t, http.StatusAccepted, own.Code)
+	assert.Equal(t, `{"call":2}`, own.Body.String())
+	assert.Empty(t, own.Header().Get(HeaderIdempotentReplayed))
+
+	retry := send(alice, alice)
+	assert.Equal(t, `{"call":1}`, retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, 2, calls)
+}
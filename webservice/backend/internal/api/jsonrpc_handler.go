@@ -1,93 +1,193 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sync"
 
+	"github.com/leafsii/leafsii-backend/internal/apikeys"
 	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/validation"
 	"github.com/pattonkan/sui-go/sui"
 	"github.com/shopspring/decimal"
 )
 
-// HandleJSONRPC handles JSON-RPC 2.0 requests
+// DefaultJSONRPCBatchParallelism is how many entries of a batch run at once
+// when the config sets none
+const DefaultJSONRPCBatchParallelism = 4
+
+// maxJSONRPCBatch bounds the entries of one batch
+const maxJSONRPCBatch = 100
+
+// HandleJSONRPC handles JSON-RPC 2.0 requests, single or batched. The
+// entries of a batch run concurrently and are answered in their order.
 func (h *Handler) HandleJSONRPC(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Parse JSON-RPC request
-	var req JSONRPCRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
 		h.sendJSONRPCError(w, r, nil, JSONRPCParseError, "Parse error", err.Error())
 		return
 	}
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		h.handleJSONRPCBatch(w, r, trimmed)
+		return
+	}
 
-	// Validate JSON-RPC version
-	if req.JSONRPC != "2.0" {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidRequest, "Invalid Request", "jsonrpc must be '2.0'")
+	// Parse JSON-RPC request
+	var req JSONRPCRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		h.sendJSONRPCError(w, r, nil, JSONRPCParseError, "Parse error", err.Error())
 		return
 	}
 
-	// Handle method
-	switch req.Method {
-	case "getUnsignedTransaction":
-		h.handleGetUnsignedTransaction(w, r, &req)
-	default:
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCMethodNotFound, "Method not found", fmt.Sprintf("Method '%s' not found", req.Method))
+	resp := h.callJSONRPC(r.Context(), &req)
+	status := http.StatusOK
+	if resp.Error != nil {
+		status = http.StatusBadRequest
 	}
+	w.WriteHeader(http.StatusOK) // JSON-RPC errors are sent with HTTP 200
+	json.NewEncoder(w).Encode(resp)
+
+	// Log metrics using the same pattern as REST handler
+	h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, status, 0)
 }
 
-func (h *Handler) handleGetUnsignedTransaction(w http.ResponseWriter, r *http.Request, req *JSONRPCRequest) {
-	// Parse parameters
-	paramsBytes, err := json.Marshal(req.Params)
-	if err != nil {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid params", "Failed to parse parameters")
+// handleJSONRPCBatch answers a batch with one response per entry, in the
+// order of the entries. Entries that are not requests get an Invalid
+// Request error of their own.
+func (h *Handler) handleJSONRPCBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(body, &entries); err != nil {
+		h.sendJSONRPCError(w, r, nil, JSONRPCParseError, "Parse error", err.Error())
 		return
 	}
-
-	var params GetUnsignedTransactionParams
-	if err := json.Unmarshal(paramsBytes, &params); err != nil {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
+	if len(entries) == 0 {
+		h.sendJSONRPCError(w, r, nil, JSONRPCInvalidRequest, "Invalid Request", "batch must not be empty")
 		return
 	}
-
-	// Validate parameters manually (same logic as REST handler)
-	if params.Operation != "mint" && params.Operation != "redeem" {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid operation", "operation must be 'mint' or 'redeem'")
+	if len(entries) > maxJSONRPCBatch {
+		h.sendJSONRPCError(w, r, nil, JSONRPCInvalidRequest, "Invalid Request", fmt.Sprintf("batch must have at most %d entries", maxJSONRPCBatch))
 		return
 	}
 
-	if params.Token != "xtoken" && params.Token != "ftoken" {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid token", "token must be 'xtoken' or 'ftoken'")
-		return
+	responses := make([]JSONRPCResponse, len(entries))
+	slots := make(chan struct{}, h.jsonRPCBatchParallelism())
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		var req JSONRPCRequest
+		if err := json.Unmarshal(entry, &req); err != nil {
+			responses[i] = jsonRPCErrorResponse(nil, JSONRPCInvalidRequest, "Invalid Request", err.Error())
+			continue
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, req JSONRPCRequest) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			responses[i] = h.callJSONRPC(r.Context(), &req)
+		}(i, req)
 	}
+	wg.Wait()
 
-	if params.Amount == "" {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid amount", "amount is required")
-		return
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responses)
+
+	h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusOK, 0)
+}
+
+func (h *Handler) jsonRPCBatchParallelism() int {
+	if h.config != nil && h.config.Security.JSONRPCBatchParallelism > 0 {
+		return h.config.Security.JSONRPCBatchParallelism
 	}
+	return DefaultJSONRPCBatchParallelism
+}
 
-	if params.UserAddress == "" {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid userAddress", "userAddress is required")
-		return
+// jsonRPCTxMethods are the methods that build or submit transactions,
+// which API keys need the tx:build scope for
+var jsonRPCTxMethods = map[string]bool{
+	"getUnsignedTransaction":  true,
+	"submitSignedTransaction": true,
+}
+
+// callJSONRPC runs one request and returns its response
+func (h *Handler) callJSONRPC(ctx context.Context, req *JSONRPCRequest) JSONRPCResponse {
+	// Validate JSON-RPC version
+	if req.JSONRPC != "2.0" {
+		return jsonRPCErrorResponse(req.ID, JSONRPCInvalidRequest, "Invalid Request", "jsonrpc must be '2.0'")
 	}
 
-	// Parse amount
-	amount, err := decimal.NewFromString(params.Amount)
-	if err != nil {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid amount", "Amount must be a valid decimal number")
-		return
+	// Read API keys may call the methods that only read
+	if key := apiKeyFrom(ctx); key != nil && jsonRPCTxMethods[req.Method] && !key.Allows(apikeys.ScopeTxBuild) {
+		return jsonRPCErrorResponse(req.ID, JSONRPCForbidden, "Forbidden", "API key lacks the "+apikeys.ScopeTxBuild+" scope")
 	}
 
-	if amount.LessThanOrEqual(decimal.Zero) {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid amount", "Amount must be greater than zero")
-		return
+	// Handle method
+	var result interface{}
+	var rpcErr *JSONRPCError
+	switch req.Method {
+	case "getUnsignedTransaction":
+		result, rpcErr = h.getUnsignedTransaction(ctx, req)
+	case "getQuote":
+		result, rpcErr = h.getQuote(ctx, req)
+	case "getProtocolState":
+		result, rpcErr = h.getProtocolState(ctx)
+	case "submitSignedTransaction":
+		result, rpcErr = h.submitSignedTransaction(ctx, req)
+	case "getTransactionStatus":
+		result, rpcErr = h.getTransactionStatus(ctx, req)
+	default:
+		rpcErr = &JSONRPCError{Code: JSONRPCMethodNotFound, Message: "Method not found", Data: fmt.Sprintf("Method '%s' not found", req.Method)}
+	}
+	if rpcErr != nil {
+		return JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
 	}
+	return JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
 
-	// Parse user address
-	userAddr, err := sui.AddressFromHex(params.UserAddress)
+func invalidParams(message string, data interface{}) *JSONRPCError {
+	return &JSONRPCError{Code: JSONRPCInvalidParams, Message: message, Data: data}
+}
+
+// decodeParams decodes the params of req into params and checks them
+// against their validate tags, as REST requests are. Failures are named
+// after the first failing field, with the failing fields as data.
+func decodeParams(req *JSONRPCRequest, params interface{}) *JSONRPCError {
+	paramsBytes, err := json.Marshal(req.Params)
 	if err != nil {
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid user address", "User address must be a valid Sui address")
-		return
+		return invalidParams("Invalid params", "Failed to parse parameters")
+	}
+	if err := json.Unmarshal(paramsBytes, params); err != nil {
+		return invalidParams("Invalid params", err.Error())
+	}
+	if err := validation.Struct(params); err != nil {
+		var errs validation.Errors
+		if !errors.As(err, &errs) {
+			return invalidParams("Invalid params", err.Error())
+		}
+		return invalidParams("Invalid "+errs[0].Field, errs)
+	}
+	return nil
+}
+
+func (h *Handler) getUnsignedTransaction(ctx context.Context, req *JSONRPCRequest) (interface{}, *JSONRPCError) {
+	// Parse parameters
+	var params GetUnsignedTransactionParams
+	if rpcErr := decodeParams(req, &params); rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	amount := decimal.RequireFromString(params.Amount)
+	userAddr := sui.MustAddressFromHex(params.UserAddress)
+	if rpcErr := h.authorizeSender(ctx, userAddr); rpcErr != nil {
+		return nil, rpcErr
 	}
 
 	// Determine mode from params (defaulting to execution mode)
@@ -96,7 +196,7 @@ func (h *Handler) handleGetUnsignedTransaction(w http.ResponseWriter, r *http.Re
 
 	// Build transaction based on operation
 	var unsignedTx *onchain.UnsignedTransaction
-	ctx := r.Context()
+	var err error
 
 	switch params.Operation {
 	case "mint":
@@ -114,37 +214,160 @@ func (h *Handler) handleGetUnsignedTransaction(w http.ResponseWriter, r *http.Re
 			Mode:        mode,
 		})
 	default:
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInvalidParams, "Invalid operation", "Operation must be 'mint' or 'redeem'")
-		return
+		return nil, invalidParams("Invalid operation", "Operation must be 'mint' or 'redeem'")
 	}
 
 	if err != nil {
 		h.logger.Errorw("Failed to build transaction", "error", err, "operation", params.Operation, "token", params.Token, "amount", params.Amount)
-		h.sendJSONRPCError(w, r, req.ID, JSONRPCInternalError, "Internal error", "Failed to build transaction")
-		return
+		return nil, &JSONRPCError{Code: JSONRPCInternalError, Message: "Internal error", Data: "Failed to build transaction"}
 	}
 
-	// Create response
-	result := GetUnsignedTransactionResult{
+	return GetUnsignedTransactionResult{
 		TxBytes: unsignedTx.TransactionBlockBytes,
+	}, nil
+}
+
+// authorizeSender checks the session of ctx against the sender of a build,
+// as WalletAuth and userAddress do for REST builds: one is needed unless
+// sessions are opted out of or the request has an API key, and must then
+// be for sender
+func (h *Handler) authorizeSender(ctx context.Context, sender *sui.Address) *JSONRPCError {
+	address, ok := ctx.Value(sessionAddressKey{}).(string)
+	if !ok {
+		if h.authRequired() && apiKeyFrom(ctx) == nil {
+			return &JSONRPCError{Code: JSONRPCUnauthorized, Message: "Unauthorized", Data: "Sign in with your wallet at /v1/auth/challenge"}
+		}
+		return nil
+	}
+	if sender.String() != address {
+		return &JSONRPCError{Code: JSONRPCForbidden, Message: "Forbidden", Data: "userAddress is not the signed-in address"}
 	}
+	return nil
+}
 
-	response := JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      req.ID,
-		Result:  result,
+// getQuote quotes a mint or redeem as the /v1/quotes endpoints do
+func (h *Handler) getQuote(ctx context.Context, req *JSONRPCRequest) (interface{}, *JSONRPCError) {
+	var params GetQuoteParams
+	if rpcErr := decodeParams(req, &params); rpcErr != nil {
+		return nil, rpcErr
+	}
+	amount := decimal.RequireFromString(params.Amount)
+	if h.quoteSvc == nil {
+		return nil, &JSONRPCError{Code: JSONRPCUnavailable, Message: "Service unavailable", Data: "quotes are not available"}
 	}
 
-	// Send response
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	var result GetQuoteResult
+	switch params.Operation + " " + params.Token {
+	case "mint ftoken":
+		quote, err := h.quoteSvc.GetMintQuote(ctx, amount)
+		if err != nil {
+			return nil, quoteError(err)
+		}
+		result = GetQuoteResult{AmountOut: quote.FOut.String(), Fee: quote.Fee.String(), PostCR: quote.PostCR.String(), TTL: quote.TTLSec, ID: quote.QuoteID, AsOf: quote.AsOf.Unix()}
+	case "mint xtoken":
+		quote, err := h.quoteSvc.GetMintXQuote(ctx, amount)
+		if err != nil {
+			return nil, quoteError(err)
+		}
+		result = GetQuoteResult{AmountOut: quote.XOut.String(), Fee: quote.Fee.String(), PostCR: quote.PostCR.String(), TTL: quote.TTLSec, ID: quote.QuoteID, AsOf: quote.AsOf.Unix()}
+	case "redeem ftoken":
+		quote, err := h.quoteSvc.GetRedeemQuote(ctx, amount)
+		if err != nil {
+			return nil, quoteError(err)
+		}
+		result = GetQuoteResult{AmountOut: quote.ROut.String(), Fee: quote.Fee.String(), PostCR: quote.PostCR.String(), TTL: quote.TTLSec, ID: quote.QuoteID, AsOf: quote.AsOf.Unix()}
+	case "redeem xtoken":
+		quote, err := h.quoteSvc.GetRedeemXQuote(ctx, amount)
+		if err != nil {
+			return nil, quoteError(err)
+		}
+		result = GetQuoteResult{AmountOut: quote.ROut.String(), Fee: quote.Fee.String(), PostCR: quote.PostCR.String(), TTL: quote.TTLSec, ID: quote.QuoteID, AsOf: quote.AsOf.Unix()}
+	}
+	return result, nil
+}
 
-	// Log metrics using the same pattern as REST handler
-	h.metrics.RecordHTTPRequest(ctx, r.Method, r.URL.Path, http.StatusOK, 0)
+func quoteError(err error) *JSONRPCError {
+	return &JSONRPCError{Code: JSONRPCServerError, Message: "Quote error", Data: err.Error()}
 }
 
-func (h *Handler) sendJSONRPCError(w http.ResponseWriter, r *http.Request, id interface{}, code int, message string, data interface{}) {
-	errorResp := JSONRPCResponse{
+func (h *Handler) getProtocolState(ctx context.Context) (interface{}, *JSONRPCError) {
+	if h.protocolSvc == nil {
+		return nil, &JSONRPCError{Code: JSONRPCUnavailable, Message: "Service unavailable", Data: "protocol state is not available"}
+	}
+	state, err := h.protocolSvc.GetState(ctx)
+	if err != nil {
+		return nil, &JSONRPCError{Code: JSONRPCServerError, Message: "Protocol state error", Data: err.Error()}
+	}
+	return newProtocolStateDTO(state), nil
+}
+
+// submitSignedTransaction executes a signed transaction as
+// /v1/transactions/submit does
+func (h *Handler) submitSignedTransaction(ctx context.Context, req *JSONRPCRequest) (interface{}, *JSONRPCError) {
+	var params SubmitSignedTransactionParams
+	if rpcErr := decodeParams(req, &params); rpcErr != nil {
+		return nil, rpcErr
+	}
+	signatures := joinSignatures(params.Signature, params.Signatures)
+	if len(signatures) == 0 {
+		return nil, invalidParams("Invalid signature", "signature or signatures is required")
+	}
+	if h.txSubmitter == nil {
+		return nil, &JSONRPCError{Code: JSONRPCUnavailable, Message: "Service unavailable", Data: "transaction submission is not available"}
+	}
+	schemes := make([]string, len(signatures))
+	for i, signature := range signatures {
+		schemes[i] = onchain.SignatureScheme(signature)
+	}
+
+	result, err := h.txSubmitter.SubmitSignedTransaction(ctx, params.TxBytes, signatures...)
+	if err != nil {
+		h.logger.Errorw("Transaction submission failed", "quote_id", params.QuoteID, "error", err.Error(), "tx_bytes_length", len(params.TxBytes), "signature_schemes", schemes)
+		switch {
+		case errors.Is(err, onchain.ErrSponsorQuotaExceeded):
+			return nil, &JSONRPCError{Code: JSONRPCServerError, Message: "Sponsor quota exceeded", Data: err.Error()}
+		case errors.Is(err, onchain.ErrInvalidSignature):
+			return nil, invalidParams("Invalid signature", err.Error())
+		default:
+			return nil, &JSONRPCError{Code: JSONRPCServerError, Message: "Submission error", Data: err.Error()}
+		}
+	}
+	h.logger.Infow("Transaction submitted", "quote_id", params.QuoteID, "transaction_digest", result.TransactionDigest, "status", result.Status, "signature_schemes", schemes)
+
+	// Push the status to clients subscribed to the transaction once final
+	h.watchTransaction(ctx, result.TransactionDigest)
+
+	return SignedTransactionResponse{
+		TransactionDigest: result.TransactionDigest,
+		Status:            result.Status,
+		Error:             result.Error,
+	}, nil
+}
+
+func (h *Handler) getTransactionStatus(ctx context.Context, req *JSONRPCRequest) (interface{}, *JSONRPCError) {
+	var params GetTransactionStatusParams
+	if rpcErr := decodeParams(req, &params); rpcErr != nil {
+		return nil, rpcErr
+	}
+	if h.txStatusSvc == nil {
+		return nil, &JSONRPCError{Code: JSONRPCUnavailable, Message: "Service unavailable", Data: "transaction status is not available"}
+	}
+
+	status, err := h.txStatusSvc.GetStatus(ctx, params.Digest)
+	if errors.Is(err, onchain.ErrInvalidDigest) {
+		return nil, invalidParams("Invalid digest", err.Error())
+	}
+	if err != nil {
+		return nil, &JSONRPCError{Code: JSONRPCServerError, Message: "Transaction status error", Data: err.Error()}
+	}
+	if !status.Finalized && params.Watch {
+		h.watchTransaction(ctx, params.Digest)
+	}
+	return newTransactionStatusDTO(status), nil
+}
+
+func jsonRPCErrorResponse(id interface{}, code int, message string, data interface{}) JSONRPCResponse {
+	return JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Error: &JSONRPCError{
@@ -153,9 +376,11 @@ func (h *Handler) sendJSONRPCError(w http.ResponseWriter, r *http.Request, id in
 			Data:    data,
 		},
 	}
+}
 
+func (h *Handler) sendJSONRPCError(w http.ResponseWriter, r *http.Request, id interface{}, code int, message string, data interface{}) {
 	w.WriteHeader(http.StatusOK) // JSON-RPC errors are sent with HTTP 200
-	json.NewEncoder(w).Encode(errorResp)
+	json.NewEncoder(w).Encode(jsonRPCErrorResponse(id, code, message, data))
 
 	// Log error metrics using similar pattern
 	h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusBadRequest, 0)
@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/leafsii/leafsii-backend/internal/config"
 	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/store"
 	"github.com/pattonkan/sui-go/sui"
 	"github.com/pattonkan/sui-go/suiclient"
 	"github.com/shopspring/decimal"
@@ -110,6 +113,8 @@ func TestJSONRPCHandler_Success(t *testing.T) {
 
 			req := httptest.NewRequest(http.MethodPost, "/v1/jsonrpc", bytes.NewReader(reqBody))
 			req.Header.Set("Content-Type", "application/json")
+			// Signed in as the sender
+			req = req.WithContext(context.WithValue(req.Context(), sessionAddressKey{}, userAddr.String()))
 
 			w := httptest.NewRecorder()
 			handler.HandleJSONRPC(w, req)
@@ -294,7 +299,7 @@ func TestJSONRPCHandler_Errors(t *testing.T) {
 				}
 			}`,
 			expectedCode: JSONRPCInvalidParams,
-			expectedMsg:  "Invalid user address",
+			expectedMsg:  "Invalid userAddress",
 		},
 	}
 
@@ -369,6 +374,149 @@ func ExampleJSON_RPC() {
 }
 
 // TestJSONRPCHandler_WithDevInspect verifies transaction bytes using suiclient.DevInspectTransactionBlock
+func TestJSONRPCHandler_Batch(t *testing.T) {
+	mockTxBuilder := &MockTransactionBuilder{}
+	handler := &Handler{
+		logger:    zap.NewNop().Sugar(),
+		metrics:   &MockJSONRPCMetrics{},
+		txBuilder: mockTxBuilder,
+		config:    &config.Config{Security: config.SecurityConfig{JSONRPCBatchParallelism: 2}},
+	}
+
+	// Builds track how many run at once
+	var inFlight, maxInFlight int32
+	mockTxBuilder.On("BuildMintTransaction", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}).Return(&onchain.UnsignedTransaction{TransactionBlockBytes: []byte("mint")}, nil)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/jsonrpc", bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		handler.HandleJSONRPC(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+	mint := func(id int) string {
+		return fmt.Sprintf(`{"jsonrpc": "2.0", "id": %d, "method": "getUnsignedTransaction", "params": {"operation": "mint", "token": "ftoken", "amount": "1", "userAddress": "0x9876543210fedcba9876543210fedcba98765432"}}`, id)
+	}
+
+	w := post(`[` + mint(1) + `, {"jsonrpc": "2.0", "id": "b", "method": "unknownMethod"}, 5, ` + mint(3) + `, ` + mint(4) + `, ` + mint(5) + `]`)
+	var responses []JSONRPCResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responses))
+	require.Len(t, responses, 6)
+	for i, id := range []interface{}{float64(1), "b", nil, float64(3), float64(4), float64(5)} {
+		assert.Equal(t, id, responses[i].ID, "response %d", i)
+	}
+	assert.Nil(t, responses[0].Error)
+	assert.NotNil(t, responses[0].Result)
+	require.NotNil(t, responses[1].Error)
+	assert.Equal(t, JSONRPCMethodNotFound, responses[1].Error.Code)
+	require.NotNil(t, responses[2].Error)
+	assert.Equal(t, JSONRPCInvalidRequest, responses[2].Error.Code)
+	assert.Nil(t, responses[5].Error)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+
+	// Batches that are empty or not arrays get a single error
+	for body, code := range map[string]int{`[]`: JSONRPCInvalidRequest, `[1,`: JSONRPCParseError} {
+		var response JSONRPCResponse
+		require.NoError(t, json.Unmarshal(post(body).Body.Bytes(), &response))
+		require.NotNil(t, response.Error, body)
+		assert.Equal(t, code, response.Error.Code, body)
+	}
+}
+
+// fakeSubmitter executes every transaction successfully under one digest
+type fakeSubmitter struct {
+	signatures []string
+}
+
+func (f *fakeSubmitter) SubmitSignedTransaction(_ context.Context, _ string, signatures ...string) (*onchain.TransactionResult, error) {
+	f.signatures = signatures
+	return &onchain.TransactionResult{TransactionDigest: "digest", Status: "success"}, nil
+}
+
+func TestJSONRPCHandler_Methods(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	// Nothing listens on port 1, so the cache runs in memory
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, cache.SetProtocolState(ctx, onchain.ProtocolState{
+		CR:        decimal.RequireFromString("1.5"),
+		ReservesR: decimal.NewFromInt(1_000_000),
+		SupplyX:   decimal.NewFromInt(100_000),
+		Mode:      "normal",
+		AsOf:      time.Now(),
+	}))
+	cfg := &config.Config{Oracle: config.OracleConfig{MaxAge: time.Minute}}
+	protocolSvc := onchain.NewProtocolService(nil, cache, cfg, logger)
+	submitter := &fakeSubmitter{}
+	handler := &Handler{
+		protocolSvc: protocolSvc,
+		quoteSvc:    onchain.NewQuoteService(nil, cache, protocolSvc, cfg, logger),
+		logger:      logger,
+		metrics:     &MockJSONRPCMetrics{},
+		txSubmitter: submitter,
+	}
+
+	call := func(method string, params interface{}) JSONRPCResponse {
+		t.Helper()
+		body, err := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		handler.HandleJSONRPC(w, httptest.NewRequest(http.MethodPost, "/v1/jsonrpc", bytes.NewReader(body)))
+		var response JSONRPCResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	decode := func(response JSONRPCResponse, result interface{}) {
+		t.Helper()
+		require.Nil(t, response.Error)
+		b, err := json.Marshal(response.Result)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(b, result))
+	}
+
+	var state ProtocolStateDTO
+	decode(call("getProtocolState", nil), &state)
+	assert.Equal(t, "1.5", state.CR)
+	assert.Equal(t, "normal", state.Mode)
+
+	var quote GetQuoteResult
+	decode(call("getQuote", GetQuoteParams{Operation: "mint", Token: "xtoken", Amount: "100"}), &quote)
+	assert.NotEmpty(t, quote.ID)
+	assert.NotEmpty(t, quote.AmountOut)
+
+	response := call("getQuote", GetQuoteParams{Operation: "mint", Token: "xtoken", Amount: "-1"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, JSONRPCInvalidParams, response.Error.Code)
+	response = call("getQuote", GetQuoteParams{Operation: "swap", Token: "xtoken", Amount: "1"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, JSONRPCInvalidParams, response.Error.Code)
+
+	var submitted SignedTransactionResponse
+	decode(call("submitSignedTransaction", SubmitSignedTransactionParams{TxBytes: "AAAA", Signature: "sender", Signatures: []string{"sponsor"}}), &submitted)
+	assert.Equal(t, "digest", submitted.TransactionDigest)
+	assert.Equal(t, []string{"sender", "sponsor"}, submitter.signatures)
+
+	response = call("submitSignedTransaction", SubmitSignedTransactionParams{TxBytes: "AAAA"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, JSONRPCInvalidParams, response.Error.Code)
+
+	// The server has no transaction status service
+	response = call("getTransactionStatus", GetTransactionStatusParams{Digest: "digest"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, JSONRPCUnavailable, response.Error.Code)
+}
+
 func TestJSONRPCHandler_WithDevInspect(t *testing.T) {
 	// Setup with real transaction builder (not mock)
 	logger, _ := zap.NewDevelopment()
@@ -25,10 +25,10 @@ type JSONRPCError struct {
 
 // getUnsignedTransaction method parameters
 type GetUnsignedTransactionParams struct {
-	Operation   string `json:"operation"`
-	Token       string `json:"token"`
-	Amount      string `json:"amount"`
-	UserAddress string `json:"userAddress"`
+	Operation   string `json:"operation" validate:"required,oneof=mint redeem"`
+	Token       string `json:"token" validate:"required,oneof=xtoken ftoken"`
+	Amount      string `json:"amount" validate:"required,amount"`
+	UserAddress string `json:"userAddress" validate:"required,suiaddress"`
 }
 
 // getUnsignedTransaction method result
@@ -36,6 +36,39 @@ type GetUnsignedTransactionResult struct {
 	TxBytes []byte `json:"txBytes"`
 }
 
+// getQuote method parameters
+type GetQuoteParams struct {
+	Operation string `json:"operation" validate:"required,oneof=mint redeem"`
+	Token     string `json:"token" validate:"required,oneof=xtoken ftoken"`
+	Amount    string `json:"amount" validate:"required,amount"` // Reserve to mint with, or tokens to redeem
+}
+
+// getQuote method result
+type GetQuoteResult struct {
+	AmountOut string `json:"amountOut"`
+	Fee       string `json:"fee"`
+	PostCR    string `json:"postCR"`
+	TTL       int    `json:"ttlSec"`
+	ID        string `json:"quoteId"`
+	AsOf      int64  `json:"asOf"`
+}
+
+// submitSignedTransaction method parameters. The result is a
+// SignedTransactionResponse.
+type SubmitSignedTransactionParams struct {
+	TxBytes    string   `json:"txBytes" validate:"required"`
+	Signature  string   `json:"signature,omitempty"`
+	Signatures []string `json:"signatures,omitempty"` // Of multi-signer transactions, after Signature
+	QuoteID    string   `json:"quoteId,omitempty"`
+}
+
+// getTransactionStatus method parameters. The result is a
+// TransactionStatusDTO.
+type GetTransactionStatusParams struct {
+	Digest string `json:"digest" validate:"required"`
+	Watch  bool   `json:"watch"` // Push the status on tx:<digest> once final
+}
+
 // JSON-RPC error codes (following standard)
 const (
 	JSONRPCParseError     = -32700
@@ -43,4 +76,10 @@ const (
 	JSONRPCMethodNotFound = -32601
 	JSONRPCInvalidParams  = -32602
 	JSONRPCInternalError  = -32603
+
+	// Server errors, from the range the standard leaves to implementations
+	JSONRPCServerError  = -32000 // The Sui RPC or another dependency failed
+	JSONRPCUnavailable  = -32001 // The method is not configured on this server
+	JSONRPCUnauthorized = -32002 // The method needs a wallet session
+	JSONRPCForbidden    = -32003 // The session or API key may not make the call
 )
@@ -1,17 +1,25 @@
 package api
 
 import (
-	"compress/gzip"
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/leafsii/leafsii-backend/internal/apikeys"
 	"github.com/leafsii/leafsii-backend/internal/metrics"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
 )
@@ -19,15 +27,46 @@ import (
 type Middleware struct {
 	logger  *zap.SugaredLogger
 	metrics *metrics.Metrics
+
+	maxBodyBytes     int64 // Larger request bodies are rejected by LimitBody
+	compressMinBytes int   // Smaller responses are not compressed by Compress
+}
+
+// MiddlewareOption configures a Middleware
+type MiddlewareOption func(*Middleware)
+
+// WithMaxBodyBytes bounds the request bodies LimitBody accepts
+func WithMaxBodyBytes(n int64) MiddlewareOption {
+	return func(m *Middleware) {
+		if n > 0 {
+			m.maxBodyBytes = n
+		}
+	}
 }
 
-func NewMiddleware(logger *zap.SugaredLogger, metrics *metrics.Metrics) *Middleware {
-	return &Middleware{
-		logger:  logger,
-		metrics: metrics,
+// WithCompressMinBytes sets the size from which Compress compresses
+// responses
+func WithCompressMinBytes(n int) MiddlewareOption {
+	return func(m *Middleware) {
+		if n >= 0 {
+			m.compressMinBytes = n
+		}
 	}
 }
 
+func NewMiddleware(logger *zap.SugaredLogger, metrics *metrics.Metrics, opts ...MiddlewareOption) *Middleware {
+	m := &Middleware{
+		logger:           logger,
+		metrics:          metrics,
+		maxBodyBytes:     DefaultMaxBodyBytes,
+		compressMinBytes: DefaultCompressMinBytes,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
 // CORS middleware
 func (m *Middleware) CORS(allowedOrigins []string) func(http.Handler) http.Handler {
 	// Allow the configured origins and also mirror the request Origin when it's not present in the list.
@@ -37,7 +76,7 @@ func (m *Middleware) CORS(allowedOrigins []string) func(http.Handler) http.Handl
 			AllowedOrigins:   allowedOrigins,
 			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"*"},
-			ExposedHeaders:   []string{"Link"},
+			ExposedHeaders:   []string{"Link", HeaderRetryAfter, HeaderRetryAfterMs, HeaderDegradedDependencies, HeaderIdempotentReplayed, "ETag", HeaderCache},
 			AllowCredentials: true,
 			MaxAge:           300,
 		})
@@ -68,8 +107,140 @@ func (m *Middleware) RateLimit(rpm int) func(http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !limiter.Allow() {
-				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			// API keys have limits of their own
+			if apiKeyFrom(r.Context()) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowRequest(w, limiter) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowRequest takes a token of limiter, or answers 429 with when one will
+// be available
+func allowRequest(w http.ResponseWriter, limiter *rate.Limiter) bool {
+	now := time.Now()
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+		// Return the token and tell the client when one will be available
+		reservation.CancelAt(now)
+		if !reservation.OK() {
+			delay = time.Minute
+		}
+		writeBackoffError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Rate limit exceeded", BackoffHint{
+			RetryAfter: delay,
+		})
+		return false
+	}
+	return true
+}
+
+// keyLimiter is the limiter of an API key at its rate when created
+type keyLimiter struct {
+	rpm     int
+	limiter *rate.Limiter
+}
+
+// APIKeyAuth identifies server-to-server consumers by the key in the
+// X-API-Key header. Keys need the read scope for GET routes and tx:build
+// for the others, and are limited to their own rate, defaultRPM unless
+// they set one, rather than the shared one of RateLimit. Requests without
+// a key pass.
+func (m *Middleware) APIKeyAuth(keys *apikeys.Service, defaultRPM int) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	limiters := make(map[string]*keyLimiter)
+	limiterOf := func(key *apikeys.Key) *rate.Limiter {
+		rpm := key.RateLimitRPM
+		if rpm == 0 {
+			rpm = defaultRPM
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		l, ok := limiters[key.ID]
+		if !ok || l.rpm != rpm {
+			l = &keyLimiter{rpm: rpm, limiter: rate.NewLimiter(rate.Limit(float64(rpm)/60.0), max(rpm/6, 1))}
+			limiters[key.ID] = l
+		}
+		return l.limiter
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.Header.Get("X-API-Key")
+			if raw == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if keys == nil {
+				writeBackoffError(w, http.StatusUnauthorized, "INVALID_API_KEY", "API keys are not enabled", BackoffHint{})
+				return
+			}
+			key, err := keys.Authenticate(r.Context(), raw)
+			if errors.Is(err, apikeys.ErrInvalidKey) {
+				writeBackoffError(w, http.StatusUnauthorized, "INVALID_API_KEY", "Unknown or revoked API key", BackoffHint{})
+				return
+			}
+			if err != nil {
+				m.logger.Errorw("API key lookup failed", "error", err)
+				writeBackoffError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", "API keys are unavailable", BackoffHint{RetryAfter: time.Second})
+				return
+			}
+
+			scope := requiredScope(r)
+			if !key.Allows(scope) {
+				writeBackoffError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", "API key lacks the "+scope+" scope", BackoffHint{})
+				return
+			}
+			if !allowRequest(w, limiterOf(key)) {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+		})
+	}
+}
+
+// readPostPattern matches the POST routes that only read. JSON-RPC methods
+// that build or submit transactions check the tx:build scope themselves.
+var readPostPattern = regexp.MustCompile(`^/v\d+/(jsonrpc|simulate)$`)
+
+// requiredScope returns the scope an API key needs for r: reads need read,
+// other requests tx:build
+func requiredScope(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return apikeys.ScopeRead
+	}
+	if readPostPattern.MatchString(r.URL.Path) {
+		return apikeys.ScopeRead
+	}
+	return apikeys.ScopeTxBuild
+}
+
+// apiKeyContextKey is the context key of the API key of a request
+type apiKeyContextKey struct{}
+
+// apiKeyFrom returns the API key APIKeyAuth identified, if any
+func apiKeyFrom(ctx context.Context) *apikeys.Key {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*apikeys.Key)
+	return key
+}
+
+// AdminAuth guards operator routes with a static bearer token. Without a
+// configured token the routes are disabled.
+func (m *Middleware) AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeBackoffError(w, http.StatusNotFound, "NOT_FOUND", "Admin API is disabled", BackoffHint{})
+				return
+			}
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeBackoffError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Missing or invalid admin token", BackoffHint{})
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -77,6 +248,55 @@ func (m *Middleware) RateLimit(rpm int) func(http.Handler) http.Handler {
 	}
 }
 
+// WalletAuth resolves the bearer session token of /v1/auth/verify into
+// the address of the request. Requests without a token pass unless
+// required and not made with an API key, whose consumer APIKeyAuth has
+// identified; on /users/{address} routes the session must be that of
+// address.
+func (m *Middleware) WalletAuth(sessions func(ctx context.Context, token string) (string, error), required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				if required && apiKeyFrom(r.Context()) == nil {
+					writeBackoffError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Sign in with your wallet at /v1/auth/challenge", BackoffHint{})
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			address, err := sessions(r.Context(), token)
+			if errors.Is(err, errNoSession) {
+				writeBackoffError(w, http.StatusUnauthorized, "INVALID_SESSION", "Unknown or expired session", BackoffHint{})
+				return
+			}
+			if err != nil {
+				writeBackoffError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", "Sessions are unavailable", BackoffHint{RetryAfter: time.Second})
+				return
+			}
+			if param := chi.URLParam(r, "address"); param != "" {
+				// Malformed addresses are left to the handler to reject
+				if normalized, err := onchain.NormalizeAddress(param); err == nil && normalized != address {
+					writeBackoffError(w, http.StatusForbidden, "FORBIDDEN", "Session is not for this address", BackoffHint{})
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionAddressKey{}, address)))
+		})
+	}
+}
+
+// redactQuery returns the query of u with the session token of live update
+// connections masked, so that logs do not hold usable tokens
+func redactQuery(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("token") {
+		return u.RawQuery
+	}
+	query.Set("token", "REDACTED")
+	return query.Encode()
+}
+
 // Request logging middleware
 func (m *Middleware) RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -90,7 +310,7 @@ func (m *Middleware) RequestLogger(next http.Handler) http.Handler {
 			m.logger.Infow("Transaction endpoint request detected",
 				"method", r.Method,
 				"path", r.URL.Path,
-				"query", r.URL.RawQuery,
+				"query", redactQuery(r.URL),
 				"remote_addr", r.RemoteAddr,
 				"user_agent", r.UserAgent(),
 				"content_length", r.ContentLength,
@@ -104,7 +324,7 @@ func (m *Middleware) RequestLogger(next http.Handler) http.Handler {
 			m.logger.Infow("HTTP request",
 				"method", r.Method,
 				"path", r.URL.Path,
-				"query", r.URL.RawQuery,
+				"query", redactQuery(r.URL),
 				"status", ww.Status(),
 				"size", ww.BytesWritten(),
 				"duration", duration,
@@ -158,48 +378,6 @@ func (m *Middleware) SecurityHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// Compression middleware
-func (m *Middleware) Compress(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check if client accepts gzip
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		// Check content type (only compress JSON and text)
-		contentType := w.Header().Get("Content-Type")
-		if !shouldCompress(contentType) {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		w.Header().Set("Content-Encoding", "gzip")
-		w.Header().Set("Vary", "Accept-Encoding")
-
-		gz := gzip.NewWriter(w)
-		defer gz.Close()
-
-		gzw := &gzipResponseWriter{Writer: gz, ResponseWriter: w}
-		next.ServeHTTP(gzw, r)
-	})
-}
-
-type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
-}
-
-func (w *gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
-}
-
-func shouldCompress(contentType string) bool {
-	return strings.Contains(contentType, "application/json") ||
-		strings.Contains(contentType, "text/") ||
-		strings.Contains(contentType, "application/javascript")
-}
-
 // Recovery middleware with structured logging
 func (m *Middleware) Recoverer(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -212,7 +390,7 @@ func (m *Middleware) Recoverer(next http.Handler) http.Handler {
 					"remote_addr", r.RemoteAddr,
 				)
 
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				writeBackoffError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", BackoffHint{})
 			}
 		}()
 
@@ -229,16 +407,61 @@ func (m *Middleware) RequestID(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), middleware.RequestIDKey, requestID)
-		w.Header().Set("X-Request-ID", requestID)
+		w.Header().Set(HeaderRequestID, requestID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// DefaultMaxBodyBytes bounds request bodies when the config sets no
+// LFS_MAX_BODY_BYTES
+const DefaultMaxBodyBytes = 1 << 20
+
+// LimitBody rejects request bodies larger than maxBodyBytes with 413, and
+// hands handlers the body read in full
+func (m *Middleware) LimitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tooLarge := fmt.Sprintf("Request body must be at most %d bytes", m.maxBodyBytes)
+		if r.ContentLength > m.maxBodyBytes {
+			writeBackoffError(w, http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE", tooLarge, BackoffHint{})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, m.maxBodyBytes+1))
+		if err != nil {
+			writeBackoffError(w, http.StatusBadRequest, "INVALID_BODY", "Failed to read request body", BackoffHint{})
+			return
+		}
+		if int64(len(body)) > m.maxBodyBytes {
+			writeBackoffError(w, http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE", tooLarge, BackoffHint{})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// streamPathPattern matches the SSE and WebSocket routes, which stay open
+var streamPathPattern = regexp.MustCompile(`^/v\d+/(stream|ws)$`)
+
 // Timeout middleware
 func (m *Middleware) Timeout(timeout time.Duration) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return http.TimeoutHandler(next, timeout, "Request timeout")
+		bounded := http.TimeoutHandler(next, timeout, "Request timeout")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// TimeoutHandler holds the response until the handler returns,
+			// so exports would be buffered whole rather than streamed. Its
+			// writer can neither flush nor hijack, and streams outlive any
+			// timeout, so SSE and WebSocket connections skip it too.
+			if isExportRequest(r) || streamPathPattern.MatchString(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			bounded.ServeHTTP(w, r)
+		})
 	}
 }
 
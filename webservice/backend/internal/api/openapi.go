@@ -0,0 +1,493 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	"github.com/leafsii/leafsii-backend/internal/health"
+	"github.com/leafsii/leafsii-backend/internal/markets"
+)
+
+// apiParam is a query parameter of an operation
+type apiParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// apiOperation documents a route of Routes. Body and Response are values
+// of the types the handler decodes and writes; nil has none. Routes that
+// stream or answer in plain text set ContentType, routes behind
+// WalletAuth set Session, and SessionRequired when it always requires one,
+// and those behind Idempotency set Idempotent.
+type apiOperation struct {
+	Method          string
+	Path            string
+	Tag             string
+	Summary         string
+	Query           []apiParam
+	Body            interface{}
+	Response        interface{}
+	ContentType     string
+	Session         bool
+	SessionRequired bool
+	Idempotent      bool
+}
+
+var (
+	cursorParam      = apiParam{Name: "cursor", Description: "Cursor of the next page, as returned by the previous one"}
+	limitParam       = apiParam{Name: "limit", Description: "Page size"}
+	chainIDParam     = apiParam{Name: "chainId", Description: "Source chain, e.g. ethereum"}
+	assetParam       = apiParam{Name: "asset", Description: "Collateral asset, e.g. ETH"}
+	suiOwnerParam    = apiParam{Name: "suiOwner", Description: "Sui address of the owner"}
+	statusParam      = apiParam{Name: "status", Description: "Only entries in this status"}
+	intervalParam    = apiParam{Name: "interval", Description: "One of 1m, 5m, 15m, 1h, 4h, 1d"}
+	fromParam        = apiParam{Name: "from", Description: "Start, as unix seconds or an RFC 3339 time"}
+	toParam          = apiParam{Name: "to", Description: "End, as unix seconds or an RFC 3339 time"}
+	userAddressParam = apiParam{Name: "userAddress", Description: "Sender, unless set in the X-User-Address header"}
+)
+
+// apiOperations are the /v1 routes of Routes, in their order.
+// TestOpenAPIMatchesRoutes keeps them in sync.
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/healthz", Tag: "ops", Summary: "Liveness check", ContentType: "text/plain"},
+	{Method: "GET", Path: "/readyz", Tag: "ops", Summary: "Readiness check of the dependencies, 503 when a critical one fails", Response: health.Report{}},
+
+	{Method: "GET", Path: "/v1/openapi.json", Tag: "ops", Summary: "This OpenAPI document", Response: map[string]interface{}{}},
+	{Method: "GET", Path: "/v1/docs", Tag: "ops", Summary: "Swagger UI of this document", ContentType: "text/html"},
+	{Method: "GET", Path: "/v1/errors", Tag: "ops", Summary: "Catalog of error codes", Response: ErrorCatalogResponse{}},
+	{Method: "GET", Path: "/v1/errors/{code}", Tag: "ops", Summary: "Error code a problem type links to", Response: ErrorCode{}},
+	{Method: "POST", Path: "/v1/jsonrpc", Tag: "jsonrpc", Summary: "JSON-RPC 2.0 request or batch", Body: JSONRPCRequest{}, Response: JSONRPCResponse{}},
+	{Method: "GET", Path: "/v1/markets", Tag: "markets", Summary: "Markets and their protocol state", Response: []markets.Market{}},
+	{Method: "GET", Path: "/v1/markets/{symbol}/candles", Tag: "markets", Summary: "Stored price candles of a symbol, oldest first", Query: []apiParam{
+		intervalParam, fromParam, toParam, cursorParam, limitParam,
+	}, Response: MarketCandlesDTO{}},
+
+	{Method: "GET", Path: "/v1/protocol/state", Tag: "protocol", Summary: "Current protocol state", Response: ProtocolStateDTO{}},
+	{Method: "GET", Path: "/v1/protocol/health", Tag: "protocol", Summary: "Protocol health", Response: HealthDTO{}},
+	{Method: "GET", Path: "/v1/protocol/build-info", Tag: "protocol", Summary: "Object IDs transactions are built against", Response: TransactionBuildInfoResponse{}},
+	{Method: "GET", Path: "/v1/protocol/metrics", Tag: "protocol", Summary: "Protocol metrics", Response: ProtocolMetricsDTO{}},
+	{Method: "GET", Path: "/v1/protocol/events", Tag: "protocol", Summary: "Indexed protocol events, newest first", Query: []apiParam{
+		{Name: "type", Description: "Only events of this type, e.g. MINT"}, cursorParam, limitParam,
+	}, Response: ProtocolEventsDTO{}},
+	{Method: "GET", Path: "/v1/protocol/history", Tag: "protocol", Summary: "Sampled protocol metric, downsampled", Query: []apiParam{
+		{Name: "metric", Description: "One of cr, reserves, supply_f, supply_x, peg_deviation, price"}, intervalParam, fromParam, toParam,
+	}, Response: ProtocolHistoryDTO{}},
+	{Method: "GET", Path: "/v1/protocol/fees", Tag: "protocol", Summary: "Fees charged and the collateral ratios they depend on", Response: ProtocolFeesResponse{}},
+
+	{Method: "GET", Path: "/v1/quotes/mintF", Tag: "quotes", Summary: "Quote a mint of fTokens", Query: []apiParam{
+		{Name: "amountR", Description: "Reserve to mint with", Required: true},
+	}, Response: QuoteMintDTO{}},
+	{Method: "GET", Path: "/v1/quotes/redeemF", Tag: "quotes", Summary: "Quote a redeem of fTokens", Query: []apiParam{
+		{Name: "amountF", Description: "fTokens to redeem", Required: true},
+	}, Response: QuoteRedeemDTO{}},
+	{Method: "GET", Path: "/v1/quotes/mintX", Tag: "quotes", Summary: "Quote a mint of xTokens", Query: []apiParam{
+		{Name: "amountR", Description: "Reserve to mint with", Required: true},
+	}, Response: QuoteMintXDTO{}},
+	{Method: "GET", Path: "/v1/quotes/redeemX", Tag: "quotes", Summary: "Quote a redeem of xTokens", Query: []apiParam{
+		{Name: "amountX", Description: "xTokens to redeem", Required: true},
+	}, Response: QuoteRedeemXDTO{}},
+	{Method: "POST", Path: "/v1/simulate", Tag: "quotes", Summary: "Project the protocol state and balances after hypothetical actions", Body: SimulateRequest{}, Response: SimulationResponse{}},
+
+	{Method: "POST", Path: "/v1/transactions/build", Tag: "transactions", Summary: "Build an unsigned mint or redeem", Query: []apiParam{
+		userAddressParam, {Name: "mode", Description: "execution or devinspect"},
+	}, Body: UnsignedTransactionRequest{}, Response: UnsignedTransactionResponse{}, Session: true},
+	{Method: "POST", Path: "/v1/transactions/consolidate/build", Tag: "transactions", Summary: "Build a merge of the sender's coins", Query: []apiParam{
+		userAddressParam,
+	}, Body: ConsolidateCoinsBuildRequest{}, Response: UnsignedTransactionResponse{}, Session: true},
+	{Method: "POST", Path: "/v1/transactions/submit", Tag: "transactions", Summary: "Execute a signed transaction", Body: SignedTransactionRequest{}, Response: SignedTransactionResponse{}, Idempotent: true},
+	{Method: "GET", Path: "/v1/transactions/{digest}/status", Tag: "transactions", Summary: "Status of a transaction", Query: []apiParam{
+		{Name: "watch", Description: "Push the status on tx:<digest> once final"},
+	}, Response: TransactionStatusDTO{}},
+	{Method: "POST", Path: "/v1/transactions/monitor", Tag: "transactions", Summary: "Report a transaction attempt of the frontend", Body: map[string]interface{}{}},
+
+	{Method: "GET", Path: "/v1/sp/index", Tag: "stability-pool", Summary: "Stability pool index, TVL and APR", Response: SPIndexDTO{}},
+	{Method: "GET", Path: "/v1/sp/user/{address}", Tag: "stability-pool", Summary: "Stability pool position of a user", Response: SPUserDTO{}},
+	{Method: "POST", Path: "/v1/sp/deposit/build", Tag: "stability-pool", Summary: "Build a stability pool deposit", Query: []apiParam{
+		userAddressParam,
+	}, Body: SPTransactionBuildRequest{}, Response: UnsignedTransactionResponse{}, Session: true},
+	{Method: "POST", Path: "/v1/sp/withdraw/build", Tag: "stability-pool", Summary: "Build a stability pool withdrawal", Query: []apiParam{
+		userAddressParam,
+	}, Body: SPTransactionBuildRequest{}, Response: UnsignedTransactionResponse{}, Session: true},
+	{Method: "POST", Path: "/v1/sp/claim/build", Tag: "stability-pool", Summary: "Build a stability pool reward claim", Query: []apiParam{
+		userAddressParam,
+	}, Body: SPTransactionBuildRequest{}, Response: UnsignedTransactionResponse{}, Session: true},
+
+	{Method: "GET", Path: "/v1/users/{address}/positions", Tag: "users", Summary: "Balances and positions of a user", Response: UserPositionsDTO{}, Session: true},
+	{Method: "GET", Path: "/v1/users/{address}/balances", Tag: "users", Summary: "Token balances of a user", Response: UserBalancesDTO{}, Session: true},
+	{Method: "GET", Path: "/v1/users/{address}/transactions", Tag: "users", Summary: "Protocol transactions of a user, newest first", Query: []apiParam{
+		cursorParam, limitParam,
+		{Name: "type", Description: "Only events of this type, e.g. MINT"}, fromParam, toParam,
+		{Name: "format", Description: "json, or csv or ndjson to stream the whole history; also negotiated by Accept"},
+	}, Response: UserTransactionsDTO{}, Session: true},
+	{Method: "GET", Path: "/v1/users/{address}/portfolio", Tag: "users", Summary: "Cost basis, P&L and value history of a user", Query: []apiParam{
+		intervalParam, fromParam, toParam,
+	}, Response: UserPortfolioDTO{}, Session: true},
+
+	{Method: "GET", Path: "/v1/watchlist", Tag: "watchlist", Summary: "Watchlist of the signed-in address", Response: WatchlistResponse{}, Session: true, SessionRequired: true},
+	{Method: "POST", Path: "/v1/watchlist", Tag: "watchlist", Summary: "Watch a balance, bridge receipt or the peg", Body: WatchlistItemRequest{}, Response: WatchlistItemResponse{}, Session: true, SessionRequired: true},
+	{Method: "GET", Path: "/v1/watchlist/{id}", Tag: "watchlist", Summary: "Watchlist item", Response: WatchlistItemResponse{}, Session: true, SessionRequired: true},
+	{Method: "PATCH", Path: "/v1/watchlist/{id}", Tag: "watchlist", Summary: "Change, pause or resume a watchlist item", Body: WatchlistItemUpdateRequest{}, Response: WatchlistItemResponse{}, Session: true, SessionRequired: true},
+	{Method: "DELETE", Path: "/v1/watchlist/{id}", Tag: "watchlist", Summary: "Stop watching an item", Session: true, SessionRequired: true},
+
+	{Method: "POST", Path: "/v1/auth/challenge", Tag: "auth", Summary: "Sign-in message for a wallet to sign", Body: AuthChallengeRequest{}, Response: AuthChallengeResponse{}},
+	{Method: "POST", Path: "/v1/auth/verify", Tag: "auth", Summary: "Open a wallet session with the signed sign-in message", Body: AuthVerifyRequest{}, Response: AuthSessionResponse{}},
+	{Method: "GET", Path: "/v1/auth/zklogin/nonce", Tag: "auth", Summary: "Inputs of a zkLogin nonce", Response: ZkLoginNonceResponse{}},
+	{Method: "GET", Path: "/v1/candles", Tag: "markets", Summary: "Price candles", Query: []apiParam{
+		{Name: "pair", Description: "Trading pair"}, intervalParam, limitParam,
+	}, Response: CandleResponse{}},
+
+	{Method: "POST", Path: "/v1/oracle/update/build", Tag: "oracle", Summary: "Build an oracle price update", Body: UpdateOracleBuildRequest{}, Response: UpdateOracleBuildResponse{}},
+	{Method: "POST", Path: "/v1/oracle/update/submit", Tag: "oracle", Summary: "Execute a signed oracle price update", Body: UpdateOracleSubmitRequest{}, Response: UpdateOracleSubmitResponse{}},
+
+	{Method: "GET", Path: "/v1/stream", Tag: "live", Summary: "Server-Sent Events stream", ContentType: "text/event-stream"},
+	{Method: "GET", Path: "/v1/ws", Tag: "live", Summary: "WebSocket upgrade for live updates", ContentType: "text/plain"},
+
+	{Method: "GET", Path: "/v1/crosschain/checkpoint", Tag: "crosschain", Summary: "Latest Walrus checkpoint", Query: []apiParam{
+		chainIDParam, assetParam,
+	}, Response: WalrusCheckpointResponse{}},
+	{Method: "POST", Path: "/v1/crosschain/checkpoint", Tag: "crosschain", Summary: "Submit a Walrus checkpoint", Body: SubmitCheckpointRequest{}, Response: WalrusCheckpointResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/checkpoints/{id}/verify", Tag: "crosschain", Summary: "Verify a checkpoint against Walrus", Response: CheckpointVerificationResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/checkpoints/{id}/attestations", Tag: "crosschain", Summary: "Attestations of a checkpoint", Response: AttestationStatusResponse{}},
+	{Method: "POST", Path: "/v1/crosschain/checkpoints/{id}/attestations", Tag: "crosschain", Summary: "Add an attestation to a checkpoint", Body: AddAttestationRequest{}, Response: AttestationStatusResponse{}},
+	{Method: "POST", Path: "/v1/crosschain/attestations/sign", Tag: "crosschain", Summary: "Sign a checkpoint as an attester", Body: crosschain.WalrusCheckpoint{}, Response: CheckpointAttestationDTO{}},
+	{Method: "POST", Path: "/v1/crosschain/deposit", Tag: "crosschain", Summary: "Report a deposit on the source chain", Body: BridgeDepositRequest{}, Response: BridgeReceiptResponse{}, Session: true, Idempotent: true},
+	{Method: "GET", Path: "/v1/crosschain/deposit", Tag: "crosschain", Summary: "Receipts of a deposit", Query: []apiParam{
+		{Name: "txHash", Description: "Deposit transaction on the source chain", Required: true}, chainIDParam,
+	}, Response: BridgeReceiptListResponse{}},
+	{Method: "POST", Path: "/v1/crosschain/redeem", Tag: "crosschain", Summary: "Redeem to the source chain", Body: BridgeRedeemRequest{}, Response: RedeemReceiptResponse{}, Session: true, Idempotent: true},
+	{Method: "POST", Path: "/v1/crosschain/deposits", Tag: "crosschain", Summary: "Accept a deposit on the source chain for processing, 202 with its status URL", Body: BridgeDepositRequest{}, Response: BridgeSubmissionResponse{}, Session: true, Idempotent: true},
+	{Method: "GET", Path: "/v1/crosschain/deposits/{receiptId}", Tag: "crosschain", Summary: "Status of an accepted deposit", Response: ReceiptResponse{}},
+	{Method: "POST", Path: "/v1/crosschain/redeems", Tag: "crosschain", Summary: "Accept a redeem to the source chain for processing, 202 with its status URL", Body: BridgeRedeemRequest{}, Response: BridgeSubmissionResponse{}, Session: true, Idempotent: true},
+	{Method: "GET", Path: "/v1/crosschain/redeems/{receiptId}", Tag: "crosschain", Summary: "Status of an accepted redeem", Response: ReceiptResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/receipts", Tag: "crosschain", Summary: "Bridge receipts", Query: []apiParam{
+		{Name: "kind", Description: "deposit or redeem"}, suiOwnerParam, chainIDParam, assetParam, statusParam,
+		{Name: "stage", Description: "Only receipts at this stage"},
+		{Name: "from", Description: "Created at or after, unix seconds"}, {Name: "to", Description: "Created before, unix seconds"},
+		cursorParam, limitParam,
+	}, Response: ReceiptListResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/receipts/{receiptId}", Tag: "crosschain", Summary: "A bridge receipt and its transitions", Response: ReceiptResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/receipts/{receiptId}/recompute", Tag: "crosschain", Summary: "Recompute the amounts of a receipt", Response: ReceiptRecomputationResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/balance", Tag: "crosschain", Summary: "Bridged balance of an owner", Query: []apiParam{
+		suiOwnerParam, chainIDParam, assetParam,
+	}, Response: CrossChainBalanceResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/balance/proof", Tag: "crosschain", Summary: "Proof of the bridged balance of an owner", Query: []apiParam{
+		suiOwnerParam, chainIDParam, assetParam,
+	}, Response: BalanceProofResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/voucher", Tag: "crosschain", Summary: "A withdrawal voucher", Query: []apiParam{
+		{Name: "voucherId", Description: "Voucher", Required: true},
+	}, Response: VoucherResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/vouchers", Tag: "crosschain", Summary: "Withdrawal vouchers of an owner", Query: []apiParam{
+		suiOwnerParam, statusParam,
+	}, Response: VoucherListResponse{}},
+	{Method: "POST", Path: "/v1/crosschain/voucher", Tag: "crosschain", Summary: "Create a withdrawal voucher", Body: CreateVoucherRequest{}, Response: VoucherResponse{}, Session: true, Idempotent: true},
+	{Method: "GET", Path: "/v1/crosschain/params", Tag: "crosschain", Summary: "Collateral parameters", Query: []apiParam{
+		chainIDParam, assetParam,
+	}, Response: CollateralParamsResponse{}},
+	{Method: "GET", Path: "/v1/crosschain/vault", Tag: "crosschain", Summary: "Vault of the source chain", Query: []apiParam{
+		chainIDParam, assetParam,
+	}, Response: VaultInfoResponse{}},
+
+	{Method: "GET", Path: "/v1/admin/bridge/retries", Tag: "admin", Summary: "Bridge operations awaiting retry", Query: []apiParam{statusParam}, Response: BridgeRetryListResponse{}},
+	{Method: "POST", Path: "/v1/admin/bridge/retries/{id}/requeue", Tag: "admin", Summary: "Requeue a bridge retry", Response: BridgeRetryResponse{}},
+	{Method: "GET", Path: "/v1/admin/bridge/controls", Tag: "admin", Summary: "Bridge pauses and limits", Response: BridgeControlListResponse{}},
+	{Method: "POST", Path: "/v1/admin/bridge/pause", Tag: "admin", Summary: "Pause bridging", Body: BridgePauseRequest{}, Response: BridgeControlResponse{}},
+	{Method: "POST", Path: "/v1/admin/bridge/resume", Tag: "admin", Summary: "Resume bridging", Body: BridgePauseRequest{}, Response: BridgeControlResponse{}},
+	{Method: "PUT", Path: "/v1/admin/bridge/limits", Tag: "admin", Summary: "Set bridge limits", Body: BridgeLimitsRequest{}, Response: BridgeControlResponse{}},
+	{Method: "GET", Path: "/v1/admin/bridge/fees", Tag: "admin", Summary: "Bridge fees", Response: BridgeFeesListResponse{}},
+	{Method: "PUT", Path: "/v1/admin/bridge/fees", Tag: "admin", Summary: "Set bridge fees", Body: BridgeFeesRequest{}, Response: BridgeControlResponse{}},
+	{Method: "PUT", Path: "/v1/admin/protocol/fees", Tag: "admin", Summary: "Change the fee schedule of quotes", Body: FeeScheduleRequest{}, Response: FeeScheduleResponse{}},
+	{Method: "GET", Path: "/v1/admin/protocol/fees/changes", Tag: "admin", Summary: "Fee schedule changes, newest first", Query: []apiParam{limitParam}, Response: FeeChangeListResponse{}},
+	{Method: "GET", Path: "/v1/admin/bridge/refunds", Tag: "admin", Summary: "Bridge refunds", Query: []apiParam{statusParam}, Response: BridgeRefundListResponse{}},
+	{Method: "POST", Path: "/v1/admin/bridge/refunds/{receiptId}/approve", Tag: "admin", Summary: "Approve a refund", Response: BridgeRefundResponse{}},
+	{Method: "POST", Path: "/v1/admin/bridge/refunds/{receiptId}/reject", Tag: "admin", Summary: "Reject a refund", Body: BridgeRefundRejectRequest{}, Response: BridgeRefundResponse{}},
+	{Method: "GET", Path: "/v1/admin/bridge/vouchers", Tag: "admin", Summary: "Vouchers awaiting payout", Query: []apiParam{statusParam}, Response: VoucherListResponse{}},
+	{Method: "POST", Path: "/v1/admin/bridge/vouchers/{voucherId}/cancel", Tag: "admin", Summary: "Cancel a voucher", Response: VoucherResponse{}},
+	{Method: "GET", Path: "/v1/admin/api-keys", Tag: "admin", Summary: "API keys of server-to-server consumers", Response: APIKeyListResponse{}},
+	{Method: "POST", Path: "/v1/admin/api-keys", Tag: "admin", Summary: "Issue an API key", Body: APIKeyCreateRequest{}, Response: APIKeyResponse{}},
+	{Method: "POST", Path: "/v1/admin/api-keys/{id}/rotate", Tag: "admin", Summary: "Replace the secret of an API key", Response: APIKeyResponse{}},
+	{Method: "POST", Path: "/v1/admin/api-keys/{id}/revoke", Tag: "admin", Summary: "Revoke an API key", Response: APIKeyResponse{}},
+	{Method: "GET", Path: "/v1/admin/webhooks", Tag: "admin", Summary: "Webhook subscriptions", Response: WebhookListResponse{}},
+	{Method: "POST", Path: "/v1/admin/webhooks", Tag: "admin", Summary: "Subscribe a webhook endpoint", Body: WebhookCreateRequest{}, Response: WebhookResponse{}},
+	{Method: "GET", Path: "/v1/admin/webhooks/deliveries/{deliveryId}", Tag: "admin", Summary: "Webhook delivery", Response: WebhookDeliveryResponse{}},
+	{Method: "POST", Path: "/v1/admin/webhooks/deliveries/{deliveryId}/redeliver", Tag: "admin", Summary: "Redeliver a webhook delivery", Response: WebhookDeliveryResponse{}},
+	{Method: "GET", Path: "/v1/admin/webhooks/{id}", Tag: "admin", Summary: "Webhook subscription", Response: WebhookResponse{}},
+	{Method: "DELETE", Path: "/v1/admin/webhooks/{id}", Tag: "admin", Summary: "Unsubscribe a webhook endpoint"},
+	{Method: "GET", Path: "/v1/admin/webhooks/{id}/deliveries", Tag: "admin", Summary: "Latest deliveries of a webhook", Query: []apiParam{statusParam, limitParam}, Response: WebhookDeliveryListResponse{}},
+	{Method: "GET", Path: "/v1/admin/config", Tag: "admin", Summary: "Effective configuration, secrets redacted", Response: AdminConfigResponse{}},
+	{Method: "POST", Path: "/v1/admin/cache/flush", Tag: "admin", Summary: "Delete the cache keys under a prefix", Body: CacheFlushRequest{}, Response: CacheFlushResponse{}},
+	{Method: "GET", Path: "/v1/admin/jobs", Tag: "admin", Summary: "Periodic jobs", Response: JobListResponse{}},
+	{Method: "POST", Path: "/v1/admin/jobs/{name}/pause", Tag: "admin", Summary: "Pause a job", Response: JobResponse{}},
+	{Method: "POST", Path: "/v1/admin/jobs/{name}/resume", Tag: "admin", Summary: "Resume a job", Response: JobResponse{}},
+	{Method: "POST", Path: "/v1/admin/jobs/{name}/trigger", Tag: "admin", Summary: "Run a job now", Response: JobResponse{}},
+	{Method: "GET", Path: "/v1/admin/connections", Tag: "admin", Summary: "Live update connections of this replica", Response: ConnectionStatsResponse{}},
+}
+
+// versionedOperations returns apiOperations followed by the operations of
+// the later versions. /v2 serves the v1 handler set, so its operations are
+// the /v1 ones.
+func versionedOperations() []apiOperation {
+	ops := append([]apiOperation(nil), apiOperations...)
+	for _, version := range apiVersions[1:] {
+		for _, op := range apiOperations {
+			if rest, ok := strings.CutPrefix(op.Path, "/"+APIVersion1+"/"); ok {
+				op.Path = "/" + version + "/" + rest
+				ops = append(ops, op)
+			}
+		}
+	}
+	return ops
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// OpenAPISpec returns the OpenAPI 3.1 document of the API, with the
+// schemas of the request and response types
+func OpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	errorSchema := schemaOf(reflect.TypeOf(ErrorResponse{}), schemas)
+	paths := map[string]map[string]interface{}{}
+	for _, op := range versionedOperations() {
+		var params []map[string]interface{}
+		for _, m := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, p := range op.Query {
+			param := map[string]interface{}{"name": p.Name, "in": "query", "schema": map[string]interface{}{"type": "string"}}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			if p.Required {
+				param["required"] = true
+			}
+			params = append(params, param)
+		}
+		if op.Idempotent {
+			params = append(params, map[string]interface{}{
+				"name": HeaderIdempotencyKey, "in": "header", "schema": map[string]interface{}{"type": "string", "maxLength": maxIdempotencyKeyLength},
+				"description": "Retries with the same key and body replay the first response",
+			})
+		}
+
+		ok := map[string]interface{}{"description": "OK"}
+		switch {
+		case op.ContentType != "":
+			ok["content"] = map[string]interface{}{op.ContentType: map[string]interface{}{}}
+		case op.Response != nil:
+			ok["content"] = jsonContent(schemaOf(reflect.TypeOf(op.Response), schemas))
+		}
+		operation := map[string]interface{}{
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"tags":        []string{op.Tag},
+			"responses": map[string]interface{}{
+				"200":     ok,
+				"default": map[string]interface{}{"description": "Error", "content": map[string]interface{}{ContentTypeProblem: map[string]interface{}{"schema": errorSchema}}},
+			},
+		}
+		if params != nil {
+			operation["parameters"] = params
+		}
+		if op.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaOf(reflect.TypeOf(op.Body), schemas)),
+			}
+		}
+		if strings.Contains(op.Path, "/admin/") {
+			operation["security"] = []map[string][]string{{"adminToken": {}}}
+		}
+		if op.Session {
+			// Optional when LFS_AUTH_REQUIRED opts out
+			operation["security"] = []map[string][]string{{"walletSession": {}}, {}}
+		}
+		if op.SessionRequired {
+			operation["security"] = []map[string][]string{{"walletSession": {}}}
+		}
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "Leafsii API",
+			"version":     apiVersions[len(apiVersions)-1],
+			"description": "REST and JSON-RPC API of the Leafsii protocol backend",
+		},
+		"paths": paths,
+		// Server-to-server consumers may send an API key anywhere
+		"security": []map[string][]string{{"apiKey": {}}, {}},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"adminToken":    map[string]interface{}{"type": "http", "scheme": "bearer", "description": "LFS_ADMIN_TOKEN"},
+				"walletSession": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "Token of /v1/auth/verify"},
+				"apiKey":        map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key", "description": "Key of /v1/admin/api-keys"},
+			},
+		},
+	}
+}
+
+// operationID derives an ID from the method and the path, e.g.
+// getV1UsersAddressPortfolio
+func operationID(op apiOperation) string {
+	id := strings.ToLower(op.Method)
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '.'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaOf returns the JSON Schema of values of t as encoding/json writes
+// them. Named structs are added to schemas and referenced.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType),
+		t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		// Decimals, addresses and digests marshal to strings
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; !ok {
+			// Placeholder for recursive types
+			schemas[t.Name()] = map[string]interface{}{}
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return ref
+	default:
+		// interface{} holds any value
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			if embedded, ok := schemaOf(field.Type, schemas)["$ref"].(string); ok {
+				for k, v := range schemas[strings.TrimPrefix(embedded, "#/components/schemas/")].(map[string]interface{})["properties"].(map[string]interface{}) {
+					properties[k] = v
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := schemaOf(field.Type, schemas)
+		validate := field.Tag.Get("validate")
+		if _, oneOf, ok := strings.Cut(validate, "oneof="); ok {
+			enum, _, _ := strings.Cut(oneOf, ",")
+			schema = map[string]interface{}{"type": "string", "enum": strings.Fields(enum)}
+		}
+		properties[name] = schema
+		if strings.HasPrefix(validate, "required") && !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+)
+
+// GetOpenAPISpec serves the OpenAPI document
+func (h *Handler) GetOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIJSON, _ = json.Marshal(OpenAPISpec())
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPIJSON)
+}
+
+// swaggerUIPage loads Swagger UI from its CDN and points it at the document
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Leafsii API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// GetSwaggerUI serves Swagger UI for the OpenAPI document
+func (h *Handler) GetSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(swaggerUIPage))
+}
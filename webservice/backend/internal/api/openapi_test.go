@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	h := &Handler{logger: zap.NewNop().Sugar()}
+	router := h.Routes(NewMiddleware(zap.NewNop().Sugar(), nil), nil, 0)
+
+	var routes []string
+	require.NoError(t, chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+route)
+		return nil
+	}))
+	var documented []string
+	for _, op := range versionedOperations() {
+		documented = append(documented, op.Method+" "+op.Path)
+	}
+	sort.Strings(routes)
+	sort.Strings(documented)
+	assert.Equal(t, routes, documented, "apiOperations must document every route of Routes")
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	h := &Handler{logger: zap.NewNop().Sugar()}
+	w := httptest.NewRecorder()
+	h.GetOpenAPISpec(w, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var spec struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "3.1.0", spec.OpenAPI)
+	assert.Contains(t, spec.Paths["/v1/users/{address}/portfolio"], "get")
+
+	// Every reference resolves
+	var refs []string
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				refs = append(refs, ref)
+			}
+			for _, child := range v {
+				collect(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				collect(child)
+			}
+		}
+	}
+	var raw interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	collect(raw)
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		assert.Contains(t, spec.Components.Schemas, strings.TrimPrefix(ref, "#/components/schemas/"))
+	}
+
+	// Field schemas follow the json and validate tags
+	request := spec.Components.Schemas["UnsignedTransactionRequest"]
+	assert.ElementsMatch(t, []interface{}{"action", "tokenType", "amount"}, request["required"])
+	action := request["properties"].(map[string]interface{})["action"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"mint", "redeem"}, action["enum"])
+	state := spec.Components.Schemas["ProtocolStateDTO"]["properties"].(map[string]interface{})
+	assert.Equal(t, "integer", state["px"].(map[string]interface{})["type"])
+	assert.Equal(t, "string", state["cr"].(map[string]interface{})["type"])
+}
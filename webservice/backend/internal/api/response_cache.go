@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+)
+
+// HeaderCache reports whether a response came from the response cache
+const HeaderCache = "X-Cache"
+
+// Default response cache TTLs, used when the config sets none
+const (
+	DefaultProtocolStateCacheTTL = 2 * time.Second
+	DefaultSPIndexCacheTTL       = 5 * time.Second
+	DefaultCandlesCacheTTL       = 10 * time.Second
+)
+
+// cachedResponse is a rendered 200 response of a cached route
+type cachedResponse struct {
+	ContentType  string    `json:"contentType,omitempty"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"lastModified"`
+	Body         []byte    `json:"body"`
+}
+
+// ResponseCache serves GET requests from the response rendered for the
+// same route and query in the last ttl, so that pollers do not recompute
+// it. Responses carry an ETag and Last-Modified, and conditional requests
+// matching them are answered with 304. Only 200 responses are kept; a zero
+// ttl or a nil cache disables the middleware.
+func (m *Middleware) ResponseCache(cache *store.Cache, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if cache == nil || ttl <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			key := responseCacheKey(r)
+
+			var kept cachedResponse
+			err := cache.GetCachedResponse(ctx, key, &kept)
+			if err == nil {
+				kept.write(w, r, "HIT")
+				return
+			}
+			// The cache only saves work: render when it is down
+			if !errors.Is(err, store.ErrCacheMiss) {
+				m.logger.Warnw("Failed to read cached response", "path", r.URL.Path, "error", err)
+			}
+
+			buf := &bufferedResponse{ResponseWriter: w}
+			next.ServeHTTP(buf, r)
+			if buf.status != http.StatusOK {
+				buf.flush()
+				return
+			}
+
+			hash := sha256.Sum256(buf.body.Bytes())
+			kept = cachedResponse{
+				ContentType: w.Header().Get("Content-Type"),
+				// Weak, as Compress changes the bytes sent
+				ETag:         `W/"` + hex.EncodeToString(hash[:16]) + `"`,
+				LastModified: time.Now().UTC().Truncate(time.Second),
+				Body:         buf.body.Bytes(),
+			}
+			if err := cache.SetCachedResponse(context.WithoutCancel(ctx), key, kept, ttl); err != nil {
+				m.logger.Warnw("Failed to cache response", "path", r.URL.Path, "error", err)
+			}
+			kept.write(w, r, "MISS")
+		})
+	}
+}
+
+// responseCacheKey scopes the cached response to the route and its query,
+// in a canonical order
+func responseCacheKey(r *http.Request) string {
+	hash := sha256.Sum256([]byte(r.URL.Path + "?" + r.URL.Query().Encode()))
+	return hex.EncodeToString(hash[:])
+}
+
+// write answers r with resp, or with 304 when r already has it
+func (resp cachedResponse) write(w http.ResponseWriter, r *http.Request, source string) {
+	h := w.Header()
+	h.Set("ETag", resp.ETag)
+	h.Set("Last-Modified", resp.LastModified.Format(http.TimeFormat))
+	h.Set("Cache-Control", "no-cache")
+	h.Set(HeaderCache, source)
+	if notModified(r, resp.ETag, resp.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if resp.ContentType != "" {
+		h.Set("Content-Type", resp.ContentType)
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(resp.Body)
+	}
+}
+
+// notModified reports whether the conditional headers of r match etag or
+// modified. If-None-Match takes precedence over If-Modified-Since, as in
+// RFC 9110.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
+
+// bufferedResponse holds the status and body of a response until it is
+// known whether it can be cached. Headers go to the underlying writer.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// flush writes the held response as it is
+func (b *bufferedResponse) flush() {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	b.ResponseWriter.WriteHeader(b.status)
+	b.ResponseWriter.Write(b.body.Bytes())
+}
+
+// responseCacheTTLs returns how long responses of the protocol state, the
+// stability pool index and candles are cached
+func (h *Handler) responseCacheTTLs() (state, spIndex, candles time.Duration) {
+	if h.config == nil {
+		return DefaultProtocolStateCacheTTL, DefaultSPIndexCacheTTL, DefaultCandlesCacheTTL
+	}
+	c := h.config.Cache
+	return c.ProtocolStateTTL, c.SPIndexTTL, c.CandlesTTL
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestResponseCache(t *testing.T) {
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	m := NewMiddleware(zap.NewNop().Sugar(), nil)
+
+	calls := 0
+	status := http.StatusOK
+	render := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d}`, calls)
+	})
+	handler := m.ResponseCache(cache, time.Minute)(render)
+	get := func(target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get("/candles?pair=FTOKEN/USD&interval=1m")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, `{"call":1}`, first.Body.String())
+	assert.Equal(t, "MISS", first.Header().Get(HeaderCache))
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	lastModified := first.Header().Get("Last-Modified")
+	require.NotEmpty(t, lastModified)
+
+	// Pollers get the rendered response, whatever the order of the query
+	again := get("/candles?interval=1m&pair=FTOKEN/USD")
+	assert.Equal(t, `{"call":1}`, again.Body.String())
+	assert.Equal(t, "application/json", again.Header().Get("Content-Type"))
+	assert.Equal(t, "HIT", again.Header().Get(HeaderCache))
+	assert.Equal(t, etag, again.Header().Get("ETag"))
+	assert.Equal(t, `{"call":2}`, get("/candles?pair=FTOKEN/USD&interval=5m").Body.String())
+
+	// Conditional requests
+	for _, tt := range []struct {
+		name   string
+		header []string
+		status int
+	}{
+		{name: "matching etag", header: []string{"If-None-Match", etag}, status: http.StatusNotModified},
+		{name: "one of the etags", header: []string{"If-None-Match", `"other", ` + etag}, status: http.StatusNotModified},
+		{name: "strong etag", header: []string{"If-None-Match", etag[2:]}, status: http.StatusNotModified},
+		{name: "other etag", header: []string{"If-None-Match", `"other"`}, status: http.StatusOK},
+		{name: "etag over date", header: []string{"If-None-Match", `"other"`, "If-Modified-Since", lastModified}, status: http.StatusOK},
+		{name: "not modified since", header: []string{"If-Modified-Since", lastModified}, status: http.StatusNotModified},
+		{name: "modified since", header: []string{"If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}, status: http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get("/candles?pair=FTOKEN/USD&interval=1m", tt.header...)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			if tt.status == http.StatusNotModified {
+				assert.Empty(t, rec.Body.String())
+			}
+		})
+	}
+	assert.Equal(t, 2, calls)
+
+	// Errors are not cached
+	status = http.StatusServiceUnavailable
+	failed := get("/protocol/state")
+	assert.Equal(t, http.StatusServiceUnavailable, failed.Code)
+	assert.Empty(t, failed.Header().Get("ETag"))
+	status = http.StatusOK
+	assert.Equal(t, `{"call":4}`, get("/protocol/state").Body.String())
+
+	// A zero TTL disables caching
+	uncached := m.ResponseCache(cache, 0)(render)
+	rec := httptest.NewRecorder()
+	uncached.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/protocol/state", nil))
+	assert.Equal(t, `{"call":5}`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("ETag"))
+}
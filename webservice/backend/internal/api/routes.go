@@ -14,34 +14,75 @@ func (h *Handler) Routes(m *Middleware, corsOrigins []string, rateLimitRPM int)
 	r.Use(m.RequestID)
 	r.Use(m.RequestLogger)
 	r.Use(m.Recoverer)
+	r.Use(m.LimitBody)
 	r.Use(m.SecurityHeaders)
 	r.Use(m.Compress)
 	r.Use(m.Timeout(15 * time.Second))
 	r.Use(middleware.Heartbeat("/ping"))
 
-	// CORS and rate limiting - configured from main
+	// CORS and rate limiting - configured from main. Requests with an API
+	// key are limited to the rate of their key.
 	r.Use(m.CORS(corsOrigins))
+	r.Use(m.APIKeyAuth(h.apiKeys, h.apiKeyRateLimitRPM()))
 	r.Use(m.RateLimit(rateLimitRPM))
 
 	// Health endpoints
 	r.Get("/healthz", h.Healthz)
 	r.Get("/readyz", h.Readyz)
 
-	// v1 API routes
-	r.Route("/v1", func(r chi.Router) {
-		// JSON-RPC endpoint
-		r.Post("/jsonrpc", h.HandleJSONRPC)
+	// Versioned API routes, each version with its handler set. Deprecated
+	// versions announce their sunset and successor on every response.
+	routes := map[string]func(chi.Router){
+		APIVersion1: h.v1Routes(m),
+		APIVersion2: h.v2Routes(m),
+	}
+	for _, policy := range h.versionPolicies() {
+		mount := routes[policy.Version]
+		r.Route("/"+policy.Version, func(r chi.Router) {
+			r.Use(m.APIVersion(policy))
+			mount(r)
+		})
+	}
+
+	return r
+}
+
+// v1Routes registers the handlers of /v1
+func (h *Handler) v1Routes(m *Middleware) func(chi.Router) {
+	return func(r chi.Router) {
+		// Wallet sessions, required on user, build and bridge routes unless
+		// LFS_AUTH_REQUIRED opts out
+		walletAuth := m.WalletAuth(h.sessionAddress, h.authRequired())
+		// Retries of submissions with an Idempotency-Key replay the first
+		// response to the same caller, once its owner is authorized
+		idempotent := m.Idempotency(h.cache, h.idempotencyTTL(), h.authorizeOwner)
+		// Read-heavy routes answer pollers from rendered responses, with
+		// ETags for conditional requests
+		stateTTL, spIndexTTL, candlesTTL := h.responseCacheTTLs()
+
+		// API documentation
+		r.Get("/openapi.json", h.GetOpenAPISpec)
+		r.Get("/docs", h.GetSwaggerUI)
+		r.Get("/errors", h.ListErrorCodes)
+		r.Get("/errors/{code}", h.GetErrorCode)
+
+		// JSON-RPC endpoint. Sessions are optional here, as most methods
+		// read; getUnsignedTransaction checks its own like REST builds.
+		r.With(m.WalletAuth(h.sessionAddress, false)).Post("/jsonrpc", h.HandleJSONRPC)
 
 		// Markets
 		r.Get("/markets", h.ListMarkets)
+		r.With(m.ResponseCache(h.cache, candlesTTL)).Get("/markets/{symbol}/candles", h.GetMarketCandles)
 
 		// Protocol & Metrics
 		r.Route("/protocol", func(r chi.Router) {
-			r.Get("/state", h.GetProtocolState)
+			r.With(m.ResponseCache(h.cache, stateTTL)).Get("/state", h.GetProtocolState)
 			r.Get("/health", h.GetProtocolHealth)
 			r.Get("/build-info", h.GetTransactionBuildInfo)
 			r.Get("/metrics", h.GetProtocolMetrics)
-			// TODO: Add rebalances endpoint
+			r.Get("/events", h.GetProtocolEvents)
+			r.Get("/history", h.GetProtocolHistory)
+			r.Get("/fees", h.GetProtocolFees)
 		})
 
 		// Quotes & Previews
@@ -52,29 +93,52 @@ func (h *Handler) Routes(m *Middleware, corsOrigins []string, rateLimitRPM int)
 			r.Get("/redeemX", h.GetQuoteRedeemX)
 			// TODO: Add stake quote endpoint
 		})
+		r.Post("/simulate", h.Simulate)
 
 		// Transaction Building
 		r.Route("/transactions", func(r chi.Router) {
-			r.Post("/build", h.BuildUnsignedTransaction)
-			r.Post("/submit", h.SubmitSignedTransaction)
+			r.With(walletAuth).Post("/build", h.BuildUnsignedTransaction)
+			r.With(walletAuth).Post("/consolidate/build", h.BuildConsolidateCoinsTransaction)
+			r.With(idempotent).Post("/submit", h.SubmitSignedTransaction)
+			r.Get("/{digest}/status", h.GetTransactionStatus)
 			r.Post("/monitor", h.ReportTransactionAttempt)
 		})
 
 		// Stability Pool
 		r.Route("/sp", func(r chi.Router) {
-			r.Get("/index", h.GetSPIndex)
+			r.With(m.ResponseCache(h.cache, spIndexTTL)).Get("/index", h.GetSPIndex)
 			r.Get("/user/{address}", h.GetSPUser)
+			r.With(walletAuth).Post("/deposit/build", h.BuildSPDepositTransaction)
+			r.With(walletAuth).Post("/withdraw/build", h.BuildSPWithdrawTransaction)
+			r.With(walletAuth).Post("/claim/build", h.BuildSPClaimTransaction)
 		})
 
 		// User Portfolio
-		r.Route("/users", func(r chi.Router) {
-			r.Get("/{address}/positions", h.GetUserPositions)
-			r.Get("/{address}/balances", h.GetUserBalances)
-			r.Get("/{address}/transactions", h.GetUserTransactions)
+		r.Route("/users/{address}", func(r chi.Router) {
+			r.Use(walletAuth)
+			r.Get("/positions", h.GetUserPositions)
+			r.Get("/balances", h.GetUserBalances)
+			r.Get("/transactions", h.GetUserTransactions)
+			r.Get("/portfolio", h.GetUserPortfolio)
+		})
+
+		// Watchlist of the signed-in address, which always needs a session
+		r.Group(func(r chi.Router) {
+			r.Use(m.WalletAuth(h.sessionAddress, true))
+			r.Get("/watchlist", h.ListWatchlist)
+			r.Post("/watchlist", h.CreateWatchlistItem)
+			r.Get("/watchlist/{id}", h.GetWatchlistItem)
+			r.Patch("/watchlist/{id}", h.UpdateWatchlistItem)
+			r.Delete("/watchlist/{id}", h.DeleteWatchlistItem)
 		})
 
+		// Wallet sign-in and zkLogin wallets
+		r.Post("/auth/challenge", h.CreateAuthChallenge)
+		r.Post("/auth/verify", h.VerifyAuthChallenge)
+		r.Get("/auth/zklogin/nonce", h.GetZkLoginNonce)
+
 		// Chart data
-		r.Get("/candles", h.GetCandles)
+		r.With(m.ResponseCache(h.cache, candlesTTL)).Get("/candles", h.GetCandles)
 
 		// Oracle management
 		r.Route("/oracle", func(r chi.Router) {
@@ -90,16 +154,79 @@ func (h *Handler) Routes(m *Middleware, corsOrigins []string, rateLimitRPM int)
 		r.Route("/crosschain", func(r chi.Router) {
 			r.Get("/checkpoint", h.GetLatestCheckpoint)
 			r.Post("/checkpoint", h.SubmitCheckpoint)
-			r.Post("/deposit", h.SubmitCrossChainDeposit)
-			r.Post("/redeem", h.SubmitCrossChainRedeem)
+			r.Get("/checkpoints/{id}/verify", h.VerifyCheckpoint)
+			r.Get("/checkpoints/{id}/attestations", h.GetCheckpointAttestations)
+			r.Post("/checkpoints/{id}/attestations", h.AddCheckpointAttestation)
+			r.Post("/attestations/sign", h.SignCheckpointAttestation)
+			r.With(walletAuth, idempotent).Post("/deposit", h.SubmitCrossChainDeposit)
+			r.Get("/deposit", h.GetCrossChainDeposits)
+			r.With(walletAuth, idempotent).Post("/redeem", h.SubmitCrossChainRedeem)
+			r.With(walletAuth, idempotent).Post("/deposits", h.AcceptCrossChainDeposit)
+			r.Get("/deposits/{receiptId}", h.GetCrossChainDepositStatus)
+			r.With(walletAuth, idempotent).Post("/redeems", h.AcceptCrossChainRedeem)
+			r.Get("/redeems/{receiptId}", h.GetCrossChainRedeemStatus)
+			r.Get("/receipts", h.ListReceipts)
+			r.Get("/receipts/{receiptId}", h.GetReceipt)
+			r.Get("/receipts/{receiptId}/recompute", h.RecomputeReceipt)
 			r.Get("/balance", h.GetCrossChainBalance)
+			r.Get("/balance/proof", h.GetCrossChainBalanceProof)
 			r.Get("/voucher", h.GetVoucher)
 			r.Get("/vouchers", h.ListVouchers)
-			r.Post("/voucher", h.CreateVoucher)
+			r.With(walletAuth, idempotent).Post("/voucher", h.CreateVoucher)
 			r.Get("/params", h.GetCollateralParams)
 			r.Get("/vault", h.GetVaultInfo)
 		})
-	})
 
-	return r
+		// Operator endpoints, guarded by LFS_ADMIN_TOKEN
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(m.AdminAuth(h.adminToken()))
+			r.Get("/bridge/retries", h.ListBridgeRetries)
+			r.Post("/bridge/retries/{id}/requeue", h.RequeueBridgeRetry)
+			r.Get("/bridge/controls", h.ListBridgeControls)
+			r.Post("/bridge/pause", h.PauseBridge)
+			r.Post("/bridge/resume", h.ResumeBridge)
+			r.Put("/bridge/limits", h.SetBridgeLimits)
+			r.Get("/bridge/fees", h.ListBridgeFees)
+			r.Put("/bridge/fees", h.SetBridgeFees)
+			r.Put("/protocol/fees", h.SetFeeSchedule)
+			r.Get("/protocol/fees/changes", h.ListFeeScheduleChanges)
+			r.Get("/bridge/refunds", h.ListBridgeRefunds)
+			r.Post("/bridge/refunds/{receiptId}/approve", h.ApproveBridgeRefund)
+			r.Post("/bridge/refunds/{receiptId}/reject", h.RejectBridgeRefund)
+			r.Get("/bridge/vouchers", h.ListPendingVouchers)
+			r.Post("/bridge/vouchers/{voucherId}/cancel", h.CancelVoucher)
+			r.Get("/api-keys", h.ListAPIKeys)
+			r.Post("/api-keys", h.CreateAPIKey)
+			r.Post("/api-keys/{id}/rotate", h.RotateAPIKey)
+			r.Post("/api-keys/{id}/revoke", h.RevokeAPIKey)
+			r.Get("/webhooks", h.ListWebhooks)
+			r.Post("/webhooks", h.CreateWebhook)
+			r.Get("/webhooks/deliveries/{deliveryId}", h.GetWebhookDelivery)
+			r.Post("/webhooks/deliveries/{deliveryId}/redeliver", h.RedeliverWebhook)
+			r.Get("/webhooks/{id}", h.GetWebhook)
+			r.Delete("/webhooks/{id}", h.DeleteWebhook)
+			r.Get("/webhooks/{id}/deliveries", h.ListWebhookDeliveries)
+			r.Get("/config", h.GetAdminConfig)
+			r.Post("/cache/flush", h.FlushCache)
+			r.Get("/jobs", h.ListJobs)
+			r.Post("/jobs/{name}/pause", h.PauseJob)
+			r.Post("/jobs/{name}/resume", h.ResumeJob)
+			r.Post("/jobs/{name}/trigger", h.TriggerJob)
+			r.Get("/connections", h.GetConnectionStats)
+		})
+	}
+}
+
+// v2Routes registers the handlers of /v2. It starts as the v1 set; handlers
+// whose DTOs break compatibility are registered here as they land.
+func (h *Handler) v2Routes(m *Middleware) func(chi.Router) {
+	return h.v1Routes(m)
+}
+
+// adminToken returns the configured admin token; none disables /v1/admin
+func (h *Handler) adminToken() string {
+	if h.config == nil {
+		return ""
+	}
+	return h.config.Security.AdminToken
 }
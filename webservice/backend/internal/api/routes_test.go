@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/metrics"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/leafsii/leafsii-backend/internal/ws"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRoutesRequireSessionByDefault(t *testing.T) {
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	address := sui.MustAddressFromHex("0xa11ce").String()
+	other := sui.MustAddressFromHex("0xb0b").String()
+	require.NoError(t, cache.SetAuthSession(context.Background(), hashToken("other-session"), authSession{Address: other, ExpiresAt: time.Now().Add(time.Hour)}, time.Hour))
+
+	logger := zap.NewNop().Sugar()
+	m := NewMiddleware(logger, nil)
+	h := &Handler{cache: cache, logger: logger, metrics: &MockMetrics{}}
+	router := chi.NewRouter()
+	router.Route("/v1", h.v1Routes(m))
+	devHandler := &Handler{
+		cache:   cache,
+		config:  &config.Config{Env: "dev", Security: config.SecurityConfig{AuthRequired: false}},
+		logger:  logger,
+		metrics: &MockMetrics{},
+	}
+	devRouter := chi.NewRouter()
+	devRouter.Route("/v1", devHandler.v1Routes(m))
+	rpcBuild := `{"jsonrpc":"2.0","id":1,"method":"getUnsignedTransaction","params":{"operation":"mint","token":"ftoken","amount":"1","userAddress":"` + address + `"}}`
+
+	for _, tt := range []struct {
+		name   string
+		router http.Handler
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "build without session", router: router, method: http.MethodPost, path: "/v1/transactions/build?userAddress=" + address, status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "stability pool build without session", router: router, method: http.MethodPost, path: "/v1/sp/deposit/build?userAddress=" + address, status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "user route without session", router: router, method: http.MethodGet, path: "/v1/users/" + address + "/positions", status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "user route of another session", router: router, method: http.MethodGet, path: "/v1/users/" + address + "/positions", token: "other-session", status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "build for another session", router: router, method: http.MethodPost, path: "/v1/transactions/consolidate/build?userAddress=" + address, token: "other-session", body: "{}", status: http.StatusForbidden, code: "FORBIDDEN"},
+		// JSON-RPC builds check the session like REST ones, reads need none
+		{name: "JSON-RPC build without session", router: router, method: http.MethodPost, path: "/v1/jsonrpc", body: rpcBuild, status: http.StatusOK, code: `"code":-32002`},
+		{name: "JSON-RPC build for another session", router: router, method: http.MethodPost, path: "/v1/jsonrpc", token: "other-session", body: rpcBuild, status: http.StatusOK, code: `"code":-32003`},
+		{name: "JSON-RPC read without session", router: router, method: http.MethodPost, path: "/v1/jsonrpc", body: `{"jsonrpc":"2.0","id":1,"method":"getProtocolState"}`, status: http.StatusOK, code: `"code":-32001`},
+		// Dev may opt out, leaving the handler to answer
+		{name: "build opted out", router: devRouter, method: http.MethodPost, path: "/v1/transactions/build?userAddress=" + address, status: http.StatusBadRequest, code: "INVALID_JSON"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			tt.router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.Contains(t, rec.Body.String(), tt.code)
+		})
+	}
+}
+
+func TestRoutesServeStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("api-streams-test")
+	require.NoError(t, err)
+	logger := zap.NewNop().Sugar()
+	hub := ws.NewHub(cache, logger, metricsObj)
+	go hub.Run(ctx)
+	h := &Handler{cache: cache, logger: logger, metrics: &MockMetrics{}, wsHub: hub, sseHandler: ws.NewSSEHandler(cache, logger)}
+	server := httptest.NewServer(h.Routes(NewMiddleware(logger, metricsObj), nil, 600))
+	defer server.Close()
+
+	// Streams get past the request timeout: SSE headers are flushed while
+	// the stream is open, and WebSocket handshakes are upgraded
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(server.URL + "/v1/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/ws", nil)
+	require.NoError(t, err)
+	conn.Close()
+}
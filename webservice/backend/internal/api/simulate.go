@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/shopspring/decimal"
+)
+
+// Simulate projects the collateral ratio, fees, peg deviation and balances
+// after a batch of hypothetical actions, applied in order to the current
+// protocol state, without building a transaction. A failing action ends the
+// simulation with its error. With devInspect, each action is also dry run
+// from the current chain state, not the projected one.
+func (h *Handler) Simulate(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		h.metrics.RecordHTTPRequest(r.Context(), r.Method, r.URL.Path, http.StatusOK, time.Since(start))
+	}()
+	requestID := r.Header.Get("X-Request-ID")
+
+	var req SimulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorWithLog(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body", requestID)
+		return
+	}
+	if !h.validateRequest(w, &req, requestID) {
+		return
+	}
+	if req.DevInspect && req.Address == "" {
+		h.writeErrorWithLog(w, http.StatusBadRequest, "MISSING_PARAMETER", "devInspect needs an address", requestID)
+		return
+	}
+
+	actions := make([]onchain.SimulationAction, len(req.Actions))
+	for i := range req.Actions {
+		action := &req.Actions[i]
+		if !h.validateRequest(w, action, requestID) {
+			return
+		}
+		actions[i] = onchain.SimulationAction{Action: action.Action, TokenType: action.TokenType}
+		if action.Amount != "" {
+			actions[i].Amount = decimal.RequireFromString(action.Amount)
+		}
+	}
+
+	index, err := h.spSvc.GetIndex(r.Context())
+	if err != nil {
+		h.writeErrorFor(w, err, "SP_INDEX_ERROR")
+		return
+	}
+	input := onchain.SimulationRequest{Actions: actions, SPTVLF: index.TVLF}
+	if req.Address != "" {
+		balances, err := h.userSvc.GetBalances(r.Context(), req.Address)
+		if err != nil {
+			h.writeErrorFor(w, err, "USER_BALANCES_ERROR")
+			return
+		}
+		stake, err := h.spSvc.GetUserPosition(r.Context(), req.Address)
+		if err != nil {
+			h.writeErrorFor(w, err, "SP_USER_ERROR")
+			return
+		}
+		input.Position = &onchain.SimulationPosition{Balances: *balances, StakeF: stake.StakeF, ClaimableR: stake.ClaimableR}
+	}
+
+	sim, err := h.quoteSvc.Simulate(r.Context(), input)
+	if err != nil {
+		h.writeErrorFor(w, err, "PROTOCOL_STATE_ERROR")
+		return
+	}
+
+	resp := SimulationResponse{
+		Initial:   simulatedStateDTO(sim.Initial),
+		Steps:     make([]SimulationStepDTO, len(sim.Steps)),
+		Final:     simulatedStateDTO(sim.Final),
+		Position:  simulatedPositionDTO(sim.Position),
+		Completed: sim.Completed,
+		AsOf:      sim.AsOf.Unix(),
+	}
+	for i, step := range sim.Steps {
+		resp.Steps[i] = SimulationStepDTO{
+			Action:    step.Action.Action,
+			TokenType: step.Action.TokenType,
+			Amount:    req.Actions[i].Amount,
+			AmountOut: step.AmountOut.String(),
+			Fee:       step.Fee.String(),
+			State:     simulatedStateDTO(step.State),
+			Position:  simulatedPositionDTO(step.Position),
+			Error:     step.Error,
+		}
+	}
+
+	if req.DevInspect {
+		sender := sui.MustAddressFromHex(req.Address)
+		for i := range resp.Steps {
+			tx, err := h.devInspectAction(r, sender, actions[i])
+			if err != nil {
+				resp.Steps[i].DevInspectError = err.Error()
+				continue
+			}
+			resp.Steps[i].GasEstimate = fmt.Sprintf("%d", tx.GasEstimate)
+		}
+	}
+
+	h.logger.Infow("Simulation complete",
+		"request_id", requestID,
+		"actions", len(actions),
+		"completed", sim.Completed,
+		"dev_inspect", req.DevInspect,
+		"duration", time.Since(start),
+	)
+	h.writeJSONWithLog(w, http.StatusOK, resp, requestID)
+}
+
+// devInspectAction dry runs action as sent by sender
+func (h *Handler) devInspectAction(r *http.Request, sender *sui.Address, action onchain.SimulationAction) (*onchain.UnsignedTransaction, error) {
+	mode := onchain.TxBuildModeDevInspect
+	switch action.Action {
+	case onchain.SimulateMint:
+		return h.txBuilder.BuildMintTransaction(r.Context(), onchain.MintTxRequest{OutTokenType: action.TokenType, Amount: action.Amount, UserAddress: sender, Mode: mode})
+	case onchain.SimulateRedeem:
+		return h.txBuilder.BuildRedeemTransaction(r.Context(), onchain.RedeemTxRequest{InTokenType: action.TokenType, Amount: action.Amount, UserAddress: sender, Mode: mode})
+	case onchain.SimulateSPDeposit:
+		return h.txBuilder.BuildSPDepositTransaction(r.Context(), onchain.SPDepositTxRequest{Amount: action.Amount, UserAddress: sender, Mode: mode})
+	case onchain.SimulateSPWithdraw:
+		return h.txBuilder.BuildSPWithdrawTransaction(r.Context(), onchain.SPWithdrawTxRequest{Amount: action.Amount, UserAddress: sender, Mode: mode})
+	default:
+		return h.txBuilder.BuildSPClaimTransaction(r.Context(), onchain.SPClaimTxRequest{UserAddress: sender, Mode: mode})
+	}
+}
+
+func simulatedStateDTO(state onchain.SimulatedState) SimulatedStateDTO {
+	return SimulatedStateDTO{
+		CR:           state.CR.String(),
+		PegDeviation: state.PegDeviation.String(),
+		Mode:         state.Mode,
+		ReservesR:    state.ReservesR.String(),
+		SupplyF:      state.SupplyF.String(),
+		SupplyX:      state.SupplyX.String(),
+		SPTVLF:       state.SPTVLF.String(),
+	}
+}
+
+func simulatedPositionDTO(position *onchain.SimulationPosition) *SimulatedPositionDTO {
+	if position == nil {
+		return nil
+	}
+	return &SimulatedPositionDTO{
+		F:          position.F.String(),
+		X:          position.X.String(),
+		R:          position.R.String(),
+		StakeF:     position.StakeF.String(),
+		ClaimableR: position.ClaimableR.String(),
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fixedBalances serves fixed balances; its other reads are not implemented
+type fixedBalances struct {
+	onchain.ChainReader
+	balances onchain.Balances
+}
+
+func (f *fixedBalances) GetAllBalances(context.Context, *sui.Address) (*onchain.Balances, error) {
+	return &f.balances, nil
+}
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, cache.SetProtocolState(ctx, onchain.ProtocolState{
+		CR:        decimal.RequireFromString("1.5"),
+		ReservesR: decimal.NewFromInt(1_000_000),
+		SupplyX:   decimal.NewFromInt(100_000),
+		AsOf:      time.Now(),
+	}))
+	require.NoError(t, cache.SetSPIndex(ctx, onchain.SPIndexInfo{TVLF: decimal.NewFromInt(500)}))
+	address := "0x0000000000000000000000000000000000000000000000000000000000000a11"
+	require.NoError(t, cache.SetUserPosition(ctx, address, onchain.SPUserPosition{Address: address, StakeF: decimal.NewFromInt(5)}))
+
+	cfg := &config.Config{Oracle: config.OracleConfig{MaxAge: time.Minute}}
+	protocolSvc := onchain.NewProtocolService(nil, cache, cfg, logger)
+	chain := &fixedBalances{balances: onchain.Balances{R: decimal.NewFromInt(100), F: decimal.Zero, X: decimal.Zero}}
+	builder := &MockTransactionBuilder{}
+	h := &Handler{
+		logger:    logger,
+		metrics:   &MockMetrics{},
+		quoteSvc:  onchain.NewQuoteService(nil, cache, protocolSvc, cfg, logger),
+		userSvc:   onchain.NewUserService(chain, cache, logger),
+		spSvc:     onchain.NewStabilityPoolService(chain, cache, logger),
+		txBuilder: builder,
+	}
+	r := chi.NewRouter()
+	r.Post("/v1/simulate", h.Simulate)
+	simulate := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/simulate", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	rec := simulate(`{"actions":[{"action":"mint","tokenType":"xtoken","amount":"50"},{"action":"sp_withdraw","amount":"5"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp SimulationResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.Completed)
+	require.Len(t, resp.Steps, 2)
+	assert.Equal(t, "49", resp.Steps[0].AmountOut)
+	assert.Equal(t, "1", resp.Steps[0].Fee)
+	assert.Equal(t, "1000050", resp.Final.ReservesR)
+	assert.Equal(t, "495", resp.Final.SPTVLF)
+	assert.Nil(t, resp.Position)
+
+	// With an address, its position is projected and each action dry run
+	builder.On("BuildMintTransaction", mock.Anything, mock.MatchedBy(func(req onchain.MintTxRequest) bool {
+		return req.Mode == onchain.TxBuildModeDevInspect && req.UserAddress.String() == address
+	})).Return(&onchain.UnsignedTransaction{GasEstimate: 1000}, nil)
+	builder.On("BuildSPWithdrawTransaction", mock.Anything, mock.Anything).Return(nil, errors.New("abort"))
+	rec = simulate(`{"address":"` + address + `","devInspect":true,"actions":[{"action":"mint","tokenType":"xtoken","amount":"50"},{"action":"sp_withdraw","amount":"5"},{"action":"sp_withdraw","amount":"1"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	resp = SimulationResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.False(t, resp.Completed)
+	require.Len(t, resp.Steps, 3)
+	assert.Equal(t, "1000", resp.Steps[0].GasEstimate)
+	assert.Equal(t, "abort", resp.Steps[1].DevInspectError)
+	assert.Contains(t, resp.Steps[2].Error, "insufficient stability pool balance")
+	require.NotNil(t, resp.Position)
+	assert.Equal(t, "50", resp.Position.R)
+	assert.Equal(t, "49", resp.Position.X)
+	assert.Equal(t, "5", resp.Position.F)
+	assert.Equal(t, "0", resp.Position.StakeF)
+
+	for body, code := range map[string]string{
+		`{"actions":[]}`: "INVALID_ACTION",
+		`{"actions":[{"action":"stake","amount":"1"}]}`:                      "INVALID_ACTION",
+		`{"actions":[{"action":"mint","tokenType":"ztoken","amount":"1"}]}`:  "INVALID_TOKEN_TYPE",
+		`{"actions":[{"action":"sp_deposit"}]}`:                              "INVALID_REQUEST",
+		`{"devInspect":true,"actions":[{"action":"sp_claim"}]}`:              "MISSING_PARAMETER",
+		`{"address":"0xnope","actions":[{"action":"sp_claim"}]}`:             "INVALID_ADDRESS",
+		`{"actions":[{"action":"mint","tokenType":"xtoken","amount":"-1"}]}`: "INVALID_AMOUNT",
+	} {
+		rec := simulate(body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), code, body)
+	}
+}
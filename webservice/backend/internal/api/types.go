@@ -3,6 +3,12 @@ package api
 import (
 	"encoding/json"
 
+	"github.com/leafsii/leafsii-backend/internal/apikeys"
+	"github.com/leafsii/leafsii-backend/internal/jobs"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/leafsii/leafsii-backend/internal/validation"
+	"github.com/leafsii/leafsii-backend/internal/watchlist"
+	"github.com/leafsii/leafsii-backend/internal/webhooks"
 	"github.com/pattonkan/sui-go/sui"
 )
 
@@ -32,6 +38,45 @@ type ProtocolMetricsDTO struct {
 	AsOf         int64  `json:"asOf"`
 }
 
+// ProtocolEventItem is an indexed protocol event. Amount is in base units
+// of Token; Fields holds the decoded Move event.
+type ProtocolEventItem struct {
+	Hash       string                 `json:"hash"`
+	Sequence   uint64                 `json:"sequence"`
+	Checkpoint uint64                 `json:"checkpoint"`
+	Type       string                 `json:"type"`
+	Sender     string                 `json:"sender"`
+	Amount     string                 `json:"amount"`
+	Token      string                 `json:"token"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Timestamp  int64                  `json:"timestamp"`
+}
+
+type ProtocolEventsDTO struct {
+	Items      []ProtocolEventItem `json:"items"`
+	NextCursor string              `json:"nextCursor"`
+	UpdatedAt  int64               `json:"updatedAt"`
+}
+
+// ProtocolHistoryPoint summarizes the samples of a metric in the interval
+// starting at Time
+type ProtocolHistoryPoint struct {
+	Time    int64   `json:"t"`
+	Avg     float64 `json:"avg"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Last    float64 `json:"last"`
+	Samples int     `json:"samples"`
+}
+
+type ProtocolHistoryDTO struct {
+	Metric   string                 `json:"metric"`
+	Interval string                 `json:"interval"`
+	From     int64                  `json:"from"`
+	To       int64                  `json:"to"`
+	Points   []ProtocolHistoryPoint `json:"points"`
+}
+
 type HealthDTO struct {
 	Status  string   `json:"status"`
 	Reasons []string `json:"reasons"`
@@ -123,27 +168,54 @@ type PaginatedResponse struct {
 	HasMore bool        `json:"hasMore"`
 }
 
+// ErrorResponse is the body of every error response, an RFC 7807 problem
+// detail served as application/problem+json. Type links to the entry of
+// Code in the error catalog, and Message repeats Detail for earlier clients.
+//
+// Backoff contract: when the server sheds load (429) or a dependency is
+// degraded (503), RetryAfterMs holds the minimum delay in milliseconds before
+// the client should retry, mirrored in the Retry-After (seconds, rounded up)
+// and X-Retry-After-Ms headers. DegradedDependencies names the upstream
+// dependencies currently impaired (see the Dependency* constants) and is
+// mirrored in the comma-separated X-Degraded-Dependencies header. Clients
+// should wait at least RetryAfterMs, add jitter, and back off exponentially
+// on repeated failures. Both fields are omitted when no hint applies.
+//
+// Requests failing validation list each failing field in Errors.
 type ErrorResponse struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+	Type                 string   `json:"type"`
+	Title                string   `json:"title"`
+	Status               int      `json:"status"`
+	Detail               string   `json:"detail,omitempty"`
+	RequestID            string   `json:"request_id,omitempty"`
+	Code                 string   `json:"code"`
+	Message              string   `json:"message"`
+	Details              string   `json:"details,omitempty"`
+	RetryAfterMs         int64    `json:"retryAfterMs,omitempty"`
+	DegradedDependencies []string `json:"degradedDependencies,omitempty"`
+
+	Errors []validation.FieldError `json:"errors,omitempty"`
 }
 
 // Query parameters for endpoints
 type QuoteMintRequest struct {
-	AmountR  string `form:"amountR" validate:"required"`
+	AmountR  string `form:"amountR" validate:"required,amount" code:"INVALID_AMOUNT,required=MISSING_PARAMETER"`
 	MinFOut  string `form:"minFOut"`
 	Slippage string `form:"slippage"`
 }
 
 type QuoteRedeemRequest struct {
-	AmountF  string `form:"amountF" validate:"required"`
+	AmountF  string `form:"amountF" validate:"required,amount" code:"INVALID_AMOUNT,required=MISSING_PARAMETER"`
 	MinROut  string `form:"minROut"`
 	Slippage string `form:"slippage"`
 }
 
+type QuoteRedeemXRequest struct {
+	AmountX string `form:"amountX" validate:"required,amount" code:"INVALID_AMOUNT,required=MISSING_PARAMETER"`
+}
+
 type QuoteStakeRequest struct {
-	AmountF string `form:"amountF" validate:"required"`
+	AmountF string `form:"amountF" validate:"required,amount" code:"INVALID_AMOUNT,required=MISSING_PARAMETER"`
 }
 
 type PaginationRequest struct {
@@ -173,30 +245,410 @@ type CandlesRequest struct {
 	Limit    int    `form:"limit"`
 }
 
+// MarketCandlesRequest pages the stored candles of a symbol
+type MarketCandlesRequest struct {
+	Interval string `form:"interval" validate:"omitempty,oneof=1m 5m 15m 1h 4h 1d" code:"INVALID_INTERVAL"`
+	Limit    int    `form:"limit" validate:"min=0,max=1000"`
+	Cursor   string `form:"cursor"`
+}
+
+type MarketCandlesDTO struct {
+	Symbol     string          `json:"symbol"`
+	Interval   string          `json:"interval"`
+	From       int64           `json:"from"`
+	To         int64           `json:"to"`
+	Candles    []prices.Candle `json:"candles"`
+	NextCursor string          `json:"nextCursor"`
+}
+
 // Transaction building types
 type UnsignedTransactionRequest struct {
-	Action    string `json:"action" validate:"required,oneof=mint redeem"`
-	TokenType string `json:"tokenType" validate:"required,oneof=xtoken ftoken"`
-	Amount    string `json:"amount" validate:"required"`
+	Action    string `json:"action" validate:"required,oneof=mint redeem" code:"INVALID_ACTION"`
+	TokenType string `json:"tokenType" validate:"required,oneof=xtoken ftoken" code:"INVALID_TOKEN_TYPE"`
+	Amount    string `json:"amount" validate:"required,amount" code:"INVALID_AMOUNT"`
 	MarketID  string `json:"marketId,omitempty"`
+	Sponsored bool   `json:"sponsored,omitempty"` // Gas paid by the backend's sponsor account
+	// Quote the transaction must honor, used once, and the output it may
+	// fall short of the quote by, in bps (default 50)
+	QuoteID     string `json:"quoteId,omitempty"`
+	SlippageBps int    `json:"slippageBps,omitempty" validate:"min=0,max=10000"`
 }
 
 type UnsignedTransactionResponse struct {
 	TransactionBlockBytes []byte            `json:"transactionBlockBytes"`
-	GasEstimate           string            `json:"gasEstimate"`
+	GasEstimate           string            `json:"gasEstimate"` // Gas the dry run used, in MIST
+	GasBudget             string            `json:"gasBudget"`   // Budget set on the transaction, in MIST
 	QuoteID               string            `json:"quoteId,omitempty"`
 	Metadata              map[string]string `json:"metadata"`
+	Validation            *ValidationDTO    `json:"validation,omitempty"` // With validate=true
+}
+
+// ValidationDTO is the simulated outcome of a built transaction, which
+// succeeded. Effects are the amounts of the protocol events it emitted,
+// e.g. x_minted, in base units.
+type ValidationDTO struct {
+	GasUsed string            `json:"gasUsed"` // In MIST
+	Effects map[string]string `json:"effects"`
+}
+
+// ConsolidateCoinsBuildRequest asks for a transaction merging the user's
+// coins of a token type: sui (default), ftoken or xtoken
+type ConsolidateCoinsBuildRequest struct {
+	Mode      string `json:"mode,omitempty" validate:"omitempty,oneof=execution devinspect" code:"INVALID_MODE"`
+	TokenType string `json:"tokenType,omitempty" validate:"omitempty,oneof=sui ftoken xtoken" code:"INVALID_TOKEN_TYPE"`
+}
+
+// SimulateRequest is a batch of hypothetical actions to project from the
+// current protocol state. With an address, its balances are projected too;
+// with devInspect, each action is also dry run from the current chain state
+// as sent by the address.
+type SimulateRequest struct {
+	Actions    []SimulateActionRequest `json:"actions" validate:"required" code:"INVALID_ACTION"`
+	Address    string                  `json:"address,omitempty" validate:"omitempty,suiaddress" code:"INVALID_ADDRESS"`
+	DevInspect bool                    `json:"devInspect,omitempty"`
+}
+
+// SimulateActionRequest is a mint or redeem of tokenType, or a stability
+// pool deposit, withdrawal or claim. Amount is what goes in, unused by
+// claims.
+type SimulateActionRequest struct {
+	Action    string `json:"action" validate:"required,oneof=mint redeem sp_deposit sp_withdraw sp_claim" code:"INVALID_ACTION"`
+	TokenType string `json:"tokenType,omitempty" validate:"omitempty,oneof=xtoken ftoken" code:"INVALID_TOKEN_TYPE"`
+	Amount    string `json:"amount,omitempty" validate:"omitempty,amount" code:"INVALID_AMOUNT"`
+}
+
+type SimulationResponse struct {
+	Initial   SimulatedStateDTO     `json:"initial"`
+	Steps     []SimulationStepDTO   `json:"steps"`
+	Final     SimulatedStateDTO     `json:"final"`
+	Position  *SimulatedPositionDTO `json:"position,omitempty"`
+	Completed bool                  `json:"completed"` // False when a step failed, ending the simulation
+	AsOf      int64                 `json:"asOf"`
 }
 
+type SimulatedStateDTO struct {
+	CR           string `json:"cr"`
+	PegDeviation string `json:"pegDeviation"`
+	Mode         string `json:"mode"`
+	ReservesR    string `json:"reservesR"`
+	SupplyF      string `json:"supplyF"`
+	SupplyX      string `json:"supplyX"`
+	SPTVLF       string `json:"spTvlF"`
+}
+
+type SimulatedPositionDTO struct {
+	F          string `json:"f"`
+	X          string `json:"x"`
+	R          string `json:"r"`
+	StakeF     string `json:"stakeF"`
+	ClaimableR string `json:"claimableR"`
+}
+
+// SimulationStepDTO is the projected outcome of an action. GasEstimate or
+// DevInspectError report its dry run, with devInspect.
+type SimulationStepDTO struct {
+	Action          string                `json:"action"`
+	TokenType       string                `json:"tokenType,omitempty"`
+	Amount          string                `json:"amount,omitempty"`
+	AmountOut       string                `json:"amountOut"`
+	Fee             string                `json:"fee"`
+	State           SimulatedStateDTO     `json:"state"`
+	Position        *SimulatedPositionDTO `json:"position,omitempty"`
+	Error           string                `json:"error,omitempty"`
+	GasEstimate     string                `json:"gasEstimate,omitempty"` // In MIST
+	DevInspectError string                `json:"devInspectError,omitempty"`
+}
+
+// ProtocolFeesResponse is every fee the protocol charges: the schedule
+// quotes are priced with, the contract's own fees, the bridge fees of each
+// asset, and the collateral ratios the fees and modes depend on
+type ProtocolFeesResponse struct {
+	Quotes     FeeScheduleDTO     `json:"quotes"`
+	Onchain    *OnchainFeesDTO    `json:"onchain,omitempty"` // Absent when the protocol object carries none
+	Bridge     []BridgeFeeRateDTO `json:"bridge"`
+	Thresholds FeeThresholdsDTO   `json:"thresholds"`
+	CR         string             `json:"cr"`
+	Mode       string             `json:"mode"`
+	AsOf       int64              `json:"asOf"`
+}
+
+// FeeScheduleDTO is the configurable fee schedule quotes are priced with
+type FeeScheduleDTO struct {
+	MintFBps   int64  `json:"mintFBps"`
+	RedeemFBps int64  `json:"redeemFBps"`
+	MintXBps   int64  `json:"mintXBps"`
+	RedeemXBps int64  `json:"redeemXBps"`
+	MinCR      string `json:"minCR"`               // Quotes leaving the protocol below it are refused
+	UpdatedAt  int64  `json:"updatedAt,omitempty"` // Absent while the configured defaults apply
+}
+
+type OnchainFeesDTO struct {
+	MintFBps          uint64 `json:"mintFBps"`
+	MintXBps          uint64 `json:"mintXBps"`
+	RedeemFBps        uint64 `json:"redeemFBps"`
+	RedeemXBps        uint64 `json:"redeemXBps"`
+	L1RedeemXBps      uint64 `json:"l1RedeemXBps"`
+	StabilityBonusBps uint64 `json:"stabilityBonusBps"`
+	FeeRecipient      string `json:"feeRecipient,omitempty"`
+}
+
+type BridgeFeeRateDTO struct {
+	ChainID      string `json:"chainId"`
+	Asset        string `json:"asset"`
+	MintFeeBps   int    `json:"mintFeeBps"`
+	RedeemFeeBps int    `json:"redeemFeeBps"`
+}
+
+// FeeThresholdsDTO are the collateral ratios below which the protocol
+// enters each mode, and the oldest oracle price it accepts
+type FeeThresholdsDTO struct {
+	StabilityCR         string `json:"stabilityCR"`
+	UserRebalanceCR     string `json:"userRebalanceCR"`
+	ProtocolRebalanceCR string `json:"protocolRebalanceCR"`
+	OracleMaxAgeSec     int64  `json:"oracleMaxAgeSec,omitempty"`
+}
+
+// FeeScheduleRequest changes the fee schedule of quotes; fields left out
+// keep their value. Reason is kept in the audit trail.
+type FeeScheduleRequest struct {
+	MintFBps   *int64 `json:"mintFBps,omitempty"`
+	RedeemFBps *int64 `json:"redeemFBps,omitempty"`
+	MintXBps   *int64 `json:"mintXBps,omitempty"`
+	RedeemXBps *int64 `json:"redeemXBps,omitempty"`
+	MinCR      string `json:"minCR,omitempty" validate:"omitempty,amount" code:"INVALID_PARAMETER"`
+	Reason     string `json:"reason" validate:"required" code:"MISSING_PARAMETER"`
+}
+
+type FeeScheduleResponse struct {
+	Schedule FeeScheduleDTO `json:"schedule"`
+	Change   FeeChangeDTO   `json:"change"`
+}
+
+type FeeChangeDTO struct {
+	Before     FeeScheduleDTO `json:"before"`
+	After      FeeScheduleDTO `json:"after"`
+	Reason     string         `json:"reason,omitempty"`
+	RequestID  string         `json:"requestId,omitempty"`
+	RemoteAddr string         `json:"remoteAddr,omitempty"`
+	At         int64          `json:"at"`
+}
+
+type FeeChangeListResponse struct {
+	Changes []FeeChangeDTO `json:"changes"`
+}
+
+// SPTransactionBuildRequest asks for a stability pool deposit, withdrawal
+// or claim. Amount is in fTokens and unused by claims.
+type SPTransactionBuildRequest struct {
+	Amount    string `json:"amount,omitempty" validate:"omitempty,amount" code:"INVALID_AMOUNT"`
+	Mode      string `json:"mode,omitempty" validate:"omitempty,oneof=execution devinspect" code:"INVALID_MODE"`
+	Sponsored bool   `json:"sponsored,omitempty"` // Gas paid by the backend's sponsor account
+}
+
+// SignedTransactionRequest carries base64 serialized Sui signatures:
+// Signature for a single signer, or Signatures for several
 type SignedTransactionRequest struct {
-	TxBytes   string `json:"tx_bytes" validate:"required"`
-	Signature string `json:"signature" validate:"required"`
-	QuoteID   string `json:"quoteId,omitempty"`
+	TxBytes    string   `json:"tx_bytes" validate:"required"`
+	Signature  string   `json:"signature,omitempty"`
+	Signatures []string `json:"signatures,omitempty"`
+	QuoteID    string   `json:"quoteId,omitempty"`
+}
+
+// allSignatures returns Signature followed by Signatures
+func (r SignedTransactionRequest) allSignatures() []string {
+	return joinSignatures(r.Signature, r.Signatures)
+}
+
+func joinSignatures(signature string, signatures []string) []string {
+	all := make([]string, 0, len(signatures)+1)
+	if signature != "" {
+		all = append(all, signature)
+	}
+	return append(all, signatures...)
 }
 
 type SignedTransactionResponse struct {
 	TransactionDigest string `json:"transactionDigest"`
-	Status            string `json:"status"`
+	Status            string `json:"status"`          // Effects status, "success" or "failure"
+	Error             string `json:"error,omitempty"` // Execution error when the status is "failure"
+}
+
+// ZkLoginNonceResponse carries the inputs of the nonce of a zkLogin OpenID
+// request, which the frontend hashes with its ephemeral public key
+type ZkLoginNonceResponse struct {
+	Epoch       uint64 `json:"epoch"`
+	MaxEpoch    uint64 `json:"maxEpoch"`   // Last epoch the ephemeral key can sign in
+	Randomness  string `json:"randomness"` // Decimal
+	EpochEndsAt int64  `json:"epochEndsAt,omitempty"`
+}
+
+// AuthChallengeRequest asks for a sign-in message for address
+type AuthChallengeRequest struct {
+	Address string `json:"address" validate:"required,suiaddress" code:"INVALID_ADDRESS"`
+}
+
+// AuthChallengeResponse is the sign-in message the wallet signs with
+// signPersonalMessage, as its UTF-8 bytes, before ExpiresAt
+type AuthChallengeResponse struct {
+	Address   string `json:"address"`
+	Message   string `json:"message"`
+	Nonce     string `json:"nonce"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// AuthVerifyRequest answers the challenge of address with the base64
+// serialized signature of its message
+type AuthVerifyRequest struct {
+	Address   string `json:"address" validate:"required,suiaddress" code:"INVALID_ADDRESS"`
+	Signature string `json:"signature" validate:"required"`
+}
+
+// AuthSessionResponse carries the bearer token of a wallet session
+type AuthSessionResponse struct {
+	Token     string `json:"token"`
+	Address   string `json:"address"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// APIKeyCreateRequest issues a key. Scopes are "read" and "tx:build"; a
+// rate limit of 0 takes LFS_API_KEY_RATE_LIMIT_RPM.
+type APIKeyCreateRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	Scopes       []string `json:"scopes" validate:"required"`
+	RateLimitRPM int      `json:"rateLimitRpm,omitempty"`
+}
+
+// APIKeyResponse carries a key and, when it was created or rotated, the
+// key to send in X-API-Key, which is not shown again
+type APIKeyResponse struct {
+	Key    *apikeys.Key `json:"key"`
+	Secret string       `json:"secret,omitempty"`
+}
+
+type APIKeyListResponse struct {
+	Keys []*apikeys.Key `json:"keys"`
+}
+
+// WebhookCreateRequest subscribes URL to the events matching Events, e.g.
+// "protocol.*" or "bridge.deposit.minted"; none subscribes to every event.
+// An empty secret has one issued.
+type WebhookCreateRequest struct {
+	URL    string   `json:"url" validate:"required" code:"INVALID_WEBHOOK_REQUEST"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+// WebhookResponse carries a subscription and, when it was created, the
+// secret deliveries are signed with, which is not shown again
+type WebhookResponse struct {
+	Webhook *webhooks.Subscription `json:"webhook"`
+	Secret  string                 `json:"secret,omitempty"`
+}
+
+type WebhookListResponse struct {
+	Webhooks []*webhooks.Subscription `json:"webhooks"`
+}
+
+// WebhookDeliveriesRequest filters the deliveries of a subscription
+type WebhookDeliveriesRequest struct {
+	Status string `form:"status" validate:"omitempty,oneof=pending delivered dead"`
+	Limit  int    `form:"limit" validate:"min=0,max=500"`
+}
+
+type WebhookDeliveryResponse struct {
+	Delivery *webhooks.Delivery `json:"delivery"`
+}
+
+type WebhookDeliveryListResponse struct {
+	Deliveries []*webhooks.Delivery `json:"deliveries"`
+}
+
+// WatchlistItemRequest adds an item to the watchlist of the signed-in
+// address. Balance items watch Target, the address by default, and alert
+// on moves above Threshold tokens; receipt items watch the receipt Target;
+// peg items alert when the peg deviation crosses Threshold, e.g. 0.01.
+type WatchlistItemRequest struct {
+	Kind      string  `json:"kind" validate:"required,oneof=balance receipt peg" code:"INVALID_WATCHLIST_REQUEST"`
+	Target    string  `json:"target,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Label     string  `json:"label,omitempty"`
+}
+
+// WatchlistItemUpdateRequest changes the fields of an item that are set
+type WatchlistItemUpdateRequest struct {
+	Threshold *float64 `json:"threshold,omitempty"`
+	Label     *string  `json:"label,omitempty"`
+	Active    *bool    `json:"active,omitempty"`
+}
+
+type WatchlistItemResponse struct {
+	Item *watchlist.Item `json:"item"`
+}
+
+type WatchlistResponse struct {
+	Items []*watchlist.Item `json:"items"`
+}
+
+// AdminConfigResponse carries the effective settings, secrets redacted
+type AdminConfigResponse struct {
+	Config map[string]interface{} `json:"config"`
+}
+
+// CacheFlushRequest names the prefix of the cache keys to delete
+type CacheFlushRequest struct {
+	Prefix string `json:"prefix" validate:"required" code:"INVALID_CACHE_PREFIX"`
+}
+
+type CacheFlushResponse struct {
+	Prefix  string `json:"prefix"`
+	Deleted int64  `json:"deleted"`
+}
+
+type JobResponse struct {
+	Job *jobs.JobStatus `json:"job"`
+}
+
+type JobListResponse struct {
+	Jobs []jobs.JobStatus `json:"jobs"`
+}
+
+// ConnectionStatsResponse counts the live update connections of this
+// replica
+type ConnectionStatsResponse struct {
+	WebSocketClients int   `json:"websocketClients"`
+	SSEStreams       int64 `json:"sseStreams"`
+}
+
+// ErrorCatalogResponse lists the codes of error responses
+type ErrorCatalogResponse struct {
+	Errors []ErrorCode `json:"errors"`
+}
+
+// TransactionStatusDTO is the state of a submitted transaction. Status is
+// "pending" until it is executed, then "success" or "failure"; it is final
+// once in a checkpoint.
+type TransactionStatusDTO struct {
+	TransactionDigest string             `json:"transactionDigest"`
+	Status            string             `json:"status"`
+	Finalized         bool               `json:"finalized"`
+	Checkpoint        uint64             `json:"checkpoint,omitempty"`
+	Timestamp         int64              `json:"timestamp,omitempty"`
+	Error             string             `json:"error,omitempty"`
+	GasUsed           *GasUsedDTO        `json:"gasUsed,omitempty"`
+	BalanceChanges    []BalanceChangeDTO `json:"balanceChanges"`
+}
+
+// GasUsedDTO is the gas charged to a transaction, in MIST
+type GasUsedDTO struct {
+	ComputationCost string `json:"computationCost"`
+	StorageCost     string `json:"storageCost"`
+	StorageRebate   string `json:"storageRebate"`
+}
+
+type BalanceChangeDTO struct {
+	Owner    string `json:"owner"`
+	CoinType string `json:"coinType"`
+	Amount   string `json:"amount"` // Negative for coins sent
 }
 
 // User transactions types
@@ -216,6 +668,52 @@ type UserTransactionsDTO struct {
 	UpdatedAt  int64             `json:"updatedAt"`
 }
 
+// PortfolioPositionDTO is a token position at average cost. Quantity is in
+// base units, the rest in USD.
+type PortfolioPositionDTO struct {
+	Token         string `json:"token"`
+	Quantity      string `json:"quantity"`
+	CostBasis     string `json:"costBasisUsd"`
+	Price         string `json:"priceUsd"`
+	Value         string `json:"valueUsd"`
+	RealizedPnL   string `json:"realizedPnlUsd"`
+	UnrealizedPnL string `json:"unrealizedPnlUsd"`
+}
+
+type PortfolioPointDTO struct {
+	Time      int64  `json:"t"`
+	Value     string `json:"valueUsd"`
+	CostBasis string `json:"costBasisUsd"`
+}
+
+type UserPortfolioDTO struct {
+	Address       *sui.Address           `json:"address"`
+	Positions     []PortfolioPositionDTO `json:"positions"`
+	CostBasis     string                 `json:"costBasisUsd"`
+	Value         string                 `json:"valueUsd"`
+	RealizedPnL   string                 `json:"realizedPnlUsd"`
+	UnrealizedPnL string                 `json:"unrealizedPnlUsd"`
+	Interval      string                 `json:"interval"`
+	History       []PortfolioPointDTO    `json:"history"`
+	UpdatedAt     int64                  `json:"updatedAt"`
+}
+
+// TransactionExportRow is a transaction of a CSV or NDJSON export. Amount
+// is in base units; the USD fields are empty when no protocol sample
+// values the transaction.
+type TransactionExportRow struct {
+	Timestamp       string `json:"timestamp"`
+	Type            string `json:"type"`
+	TxDigest        string `json:"txDigest"`
+	Checkpoint      uint64 `json:"checkpoint"`
+	Sequence        uint64 `json:"sequence"`
+	Token           string `json:"token,omitempty"`
+	Amount          string `json:"amount,omitempty"`
+	TokenPriceUSD   string `json:"tokenPriceUsd,omitempty"`
+	ValueUSD        string `json:"valueUsd,omitempty"`
+	ReservePriceUSD string `json:"reservePriceUsd,omitempty"`
+}
+
 type UserTransactionsRequest struct {
 	Address *sui.Address `json:"address"`
 	Limit   int          `form:"limit"`
@@ -224,24 +722,28 @@ type UserTransactionsRequest struct {
 
 // Oracle Update API types
 type UpdateOracleBuildRequest struct {
-	Mode  string `json:"mode" validate:"required,oneof=execution devinspect"`
-	Price uint64 `json:"price" validate:"required"`
+	Mode   string `json:"mode" validate:"required,oneof=execution devinspect" code:"INVALID_MODE"`
+	Sender string `json:"sender,omitempty"` // AdminCap owner; defaults to LFS_SUI_ADMIN_ADDRESS
+	Price  uint64 `json:"price,omitempty"`  // In 1e6 USD; omitted when prices come from a feed
 }
 
 type UpdateOracleBuildResponse struct {
 	TransactionBlockBytes []byte            `json:"transactionBlockBytes"`
 	GasEstimate           string            `json:"gasEstimate"`
+	GasBudget             string            `json:"gasBudget"`
 	Metadata              map[string]string `json:"metadata"`
 }
 
 type UpdateOracleSubmitRequest struct {
-	TxBytes   string `json:"tx_bytes" validate:"required"`
-	Signature string `json:"signature" validate:"required"`
+	TxBytes    string   `json:"tx_bytes" validate:"required"`
+	Signature  string   `json:"signature,omitempty"`
+	Signatures []string `json:"signatures,omitempty"` // For multiple signers
 }
 
 type UpdateOracleSubmitResponse struct {
 	TransactionDigest string `json:"transactionDigest"`
 	Status            string `json:"status"`
+	Error             string `json:"error,omitempty"`
 }
 
 // Transaction building info endpoint types
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/leafsii/leafsii-backend/internal/validation"
+	"github.com/pattonkan/sui-go/sui"
+)
+
+// userAddressRequest is the sender of transaction builds, from the
+// X-User-Address header or the userAddress query parameter
+type userAddressRequest struct {
+	UserAddress string `form:"userAddress" validate:"required,suiaddress" code:"INVALID_USER_ADDRESS,required=MISSING_USER_ADDRESS"`
+}
+
+// userAddress returns the sender of a transaction build, answering 400 when
+// it is missing or not a Sui address and 403 when it is not the address of
+// the request's session
+func (h *Handler) userAddress(w http.ResponseWriter, r *http.Request, requestID string) (*sui.Address, bool) {
+	var req userAddressRequest
+	decodeQuery(r, &req)
+	if header := r.Header.Get("X-User-Address"); header != "" {
+		req.UserAddress = header
+	}
+	if !h.validateRequest(w, &req, requestID) {
+		return nil, false
+	}
+	address := sui.MustAddressFromHex(req.UserAddress)
+	if session, ok := r.Context().Value(sessionAddressKey{}).(string); ok && address.String() != session {
+		h.writeErrorWithLog(w, http.StatusForbidden, "FORBIDDEN", "userAddress is not the signed-in address", requestID)
+		return nil, false
+	}
+	return address, true
+}
+
+// validateRequest checks req against its validate tags, answering 400 with
+// the failing fields when it fails. The code is the one of the first failing
+// field, MISSING_PARAMETER or INVALID_PARAMETER when it names none.
+func (h *Handler) validateRequest(w http.ResponseWriter, req interface{}, requestID string) bool {
+	err := validation.Struct(req)
+	if err == nil {
+		return true
+	}
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		h.writeErrorFor(w, err, "INTERNAL_ERROR")
+		return false
+	}
+
+	code := errs[0].Code
+	if code == "" {
+		code = "INVALID_PARAMETER"
+		if errs[0].Rule == "required" {
+			code = "MISSING_PARAMETER"
+		}
+	}
+	h.logger.Infow("Request failed validation", "request_id", requestID, "code", code, "errors", errs.Error())
+
+	problem := newProblem(w, http.StatusBadRequest, code, errs.Error())
+	if requestID != "" {
+		problem.RequestID = requestID
+	}
+	problem.Errors = errs
+	writeProblem(w, problem)
+	return false
+}
+
+// decodeQuery sets the string and integer fields of the struct dst points
+// to from the query parameters named by their form tags. Integers that do
+// not parse are left zero, for validation to report them.
+func decodeQuery(r *http.Request, dst interface{}) {
+	query := r.URL.Query()
+	v := reflect.ValueOf(dst).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("form")
+		if name == "" || !query.Has(name) {
+			continue
+		}
+		value := query.Get(name)
+		switch f := v.Field(i); f.Kind() {
+		case reflect.String:
+			f.SetString(value)
+		case reflect.Int, reflect.Int64:
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				f.SetInt(n)
+			}
+		}
+	}
+}
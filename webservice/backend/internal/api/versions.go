@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// API versions, each mounted at /<version> with its own handler set
+const (
+	APIVersion1 = "v1"
+	APIVersion2 = "v2"
+)
+
+// apiVersions are the mounted versions, oldest first
+var apiVersions = []string{APIVersion1, APIVersion2}
+
+// HeaderAPIVersion names the version that answered a request
+const HeaderAPIVersion = "API-Version"
+
+// VersionPolicy is the lifecycle of an API version. Responses of deprecated
+// versions carry the Deprecation (RFC 9745) and Sunset (RFC 8594) headers
+// and link the same route of their successor.
+type VersionPolicy struct {
+	Version    string
+	Deprecated time.Time // Zero while the version is supported
+	Sunset     time.Time // Zero without a planned removal
+	Successor  string    // Version replacing it
+}
+
+// APIVersion tags responses with the version of policy, announces its
+// deprecation and counts its requests, so that migration can be tracked
+func (m *Middleware) APIVersion(policy VersionPolicy) func(http.Handler) http.Handler {
+	deprecated := !policy.Deprecated.IsZero()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderAPIVersion, policy.Version)
+			if deprecated {
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", policy.Deprecated.Unix()))
+				if !policy.Sunset.IsZero() {
+					w.Header().Set("Sunset", policy.Sunset.UTC().Format(http.TimeFormat))
+				}
+				if policy.Successor != "" {
+					successor := "/" + policy.Successor + strings.TrimPrefix(r.URL.Path, "/"+policy.Version)
+					w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+				}
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			if m.metrics != nil {
+				m.metrics.RecordAPIVersionRequest(r.Context(), policy.Version, deprecated, ww.Status())
+			}
+		})
+	}
+}
+
+// versionPolicies returns the policies of the mounted versions, deprecating
+// /v1 in favor of /v2 from LFS_API_V1_DEPRECATED_AT
+func (h *Handler) versionPolicies() []VersionPolicy {
+	policies := make([]VersionPolicy, 0, len(apiVersions))
+	for _, version := range apiVersions {
+		policies = append(policies, VersionPolicy{Version: version})
+	}
+	if h.config == nil || h.config.API.V1DeprecatedAt == "" {
+		return policies
+	}
+
+	// Both dates were checked when the config loaded
+	policies[0].Deprecated, _ = time.Parse(time.RFC3339, h.config.API.V1DeprecatedAt)
+	if h.config.API.V1Sunset != "" {
+		policies[0].Sunset, _ = time.Parse(time.RFC3339, h.config.API.V1Sunset)
+	}
+	policies[0].Successor = APIVersion2
+	return policies
+}
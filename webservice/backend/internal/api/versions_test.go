@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestAPIVersions(t *testing.T) {
+	m := NewMiddleware(zap.NewNop().Sugar(), nil)
+	serve := func(h *Handler, target string) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		for _, policy := range h.versionPolicies() {
+			r.With(m.APIVersion(policy)).Get("/"+policy.Version+"/errors/{code}", h.GetErrorCode)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	supported := &Handler{logger: zap.NewNop().Sugar()}
+	for _, version := range apiVersions {
+		rec := serve(supported, "/"+version+"/errors/NOT_FOUND")
+		assert.Equal(t, http.StatusOK, rec.Code, version)
+		assert.Equal(t, version, rec.Header().Get(HeaderAPIVersion))
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+	}
+
+	deprecated := &Handler{logger: zap.NewNop().Sugar(), config: &config.Config{API: config.APIConfig{
+		V1DeprecatedAt: "2026-01-01T00:00:00Z",
+		V1Sunset:       "2026-07-01T00:00:00Z",
+	}}}
+	rec := serve(deprecated, "/v1/errors/NOT_FOUND")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "@1767225600", rec.Header().Get("Deprecation"))
+	assert.Equal(t, "Wed, 01 Jul 2026 00:00:00 GMT", rec.Header().Get("Sunset"))
+	assert.Equal(t, `</v2/errors/NOT_FOUND>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	rec = serve(deprecated, "/v2/errors/NOT_FOUND")
+	assert.Equal(t, APIVersion2, rec.Header().Get(HeaderAPIVersion))
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+	assert.Empty(t, rec.Header().Get("Sunset"))
+}
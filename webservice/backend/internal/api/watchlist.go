@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/watchlist"
+)
+
+// ListWatchlist lists the watchlist items of the signed-in address
+func (h *Handler) ListWatchlist(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.watchlistOwner(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.watchlist.List(r.Context(), owner)
+	if err != nil {
+		h.writeWatchlistError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, WatchlistResponse{Items: items})
+}
+
+// CreateWatchlistItem adds an item to the watchlist of the signed-in
+// address. Alerts on it reach the fx:user:<address> WebSocket topic and
+// watchlist.alert webhook subscriptions.
+func (h *Handler) CreateWatchlistItem(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.watchlistOwner(w, r)
+	if !ok {
+		return
+	}
+	var req WatchlistItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid watchlist payload")
+		return
+	}
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+
+	item, err := h.watchlist.Create(r.Context(), owner, watchlist.ItemRequest{
+		Kind:      req.Kind,
+		Target:    req.Target,
+		Threshold: req.Threshold,
+		Label:     req.Label,
+	})
+	if err != nil {
+		h.writeWatchlistError(w, err)
+		return
+	}
+
+	h.logger.Infow("Watchlist item created", "id", item.ID, "owner", owner, "kind", item.Kind)
+	h.writeJSON(w, http.StatusCreated, WatchlistItemResponse{Item: item})
+}
+
+// GetWatchlistItem returns an item of the signed-in address
+func (h *Handler) GetWatchlistItem(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.watchlistOwner(w, r)
+	if !ok {
+		return
+	}
+	item, err := h.watchlist.Get(r.Context(), owner, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeWatchlistError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, WatchlistItemResponse{Item: item})
+}
+
+// UpdateWatchlistItem changes the threshold or label of an item, or pauses
+// and resumes it
+func (h *Handler) UpdateWatchlistItem(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.watchlistOwner(w, r)
+	if !ok {
+		return
+	}
+	var req WatchlistItemUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid watchlist payload")
+		return
+	}
+
+	item, err := h.watchlist.Update(r.Context(), owner, chi.URLParam(r, "id"), watchlist.ItemUpdate{
+		Threshold: req.Threshold,
+		Label:     req.Label,
+		Active:    req.Active,
+	})
+	if err != nil {
+		h.writeWatchlistError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, WatchlistItemResponse{Item: item})
+}
+
+// DeleteWatchlistItem removes an item of the signed-in address
+func (h *Handler) DeleteWatchlistItem(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.watchlistOwner(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := h.watchlist.Delete(r.Context(), owner, id); err != nil {
+		h.writeWatchlistError(w, err)
+		return
+	}
+
+	h.logger.Infow("Watchlist item deleted", "id", id, "owner", owner)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// watchlistOwner returns the signed-in address, which WalletAuth requires
+// on watchlist routes
+func (h *Handler) watchlistOwner(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if h.watchlist == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "WATCHLIST_UNAVAILABLE", "Watchlists are not configured")
+		return "", false
+	}
+	owner, ok := r.Context().Value(sessionAddressKey{}).(string)
+	if !ok {
+		h.writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Sign in with your wallet at /v1/auth/challenge")
+		return "", false
+	}
+	return owner, true
+}
+
+func (h *Handler) writeWatchlistError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, watchlist.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "WATCHLIST_ITEM_NOT_FOUND", err.Error())
+	case errors.Is(err, watchlist.ErrInvalidRequest):
+		h.writeError(w, http.StatusBadRequest, "INVALID_WATCHLIST_REQUEST", err.Error())
+	case errors.Is(err, watchlist.ErrLimitExceeded):
+		h.writeError(w, http.StatusUnprocessableEntity, "LIMIT_EXCEEDED", err.Error())
+	default:
+		h.writeErrorFor(w, err, "WATCHLIST_ERROR")
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/watchlist"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type stubProtocolState struct{}
+
+func (stubProtocolState) ProtocolState(context.Context) (*onchain.ProtocolState, error) {
+	return &onchain.ProtocolState{PegDeviation: decimal.Zero}, nil
+}
+
+func TestWatchlistEndpoints(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+
+	alice, err := onchain.NormalizeAddress("0xa11")
+	require.NoError(t, err)
+	bob, err := onchain.NormalizeAddress("0xb0b")
+	require.NoError(t, err)
+	sessions := func(_ context.Context, token string) (string, error) {
+		switch token {
+		case "alice":
+			return alice, nil
+		case "bob":
+			return bob, nil
+		}
+		return "", errNoSession
+	}
+
+	logger := zap.NewNop().Sugar()
+	h := &Handler{logger: logger, metrics: &MockMetrics{}, watchlist: watchlist.NewService(database, logger, watchlist.WithProtocol(stubProtocolState{}))}
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(NewMiddleware(logger, nil).WalletAuth(sessions, true))
+		r.Get("/v1/watchlist", h.ListWatchlist)
+		r.Post("/v1/watchlist", h.CreateWatchlistItem)
+		r.Get("/v1/watchlist/{id}", h.GetWatchlistItem)
+		r.Patch("/v1/watchlist/{id}", h.UpdateWatchlistItem)
+		r.Delete("/v1/watchlist/{id}", h.DeleteWatchlistItem)
+	})
+	serve := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Sessions are required
+	rec := serve(http.MethodGet, "/v1/watchlist", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = serve(http.MethodPost, "/v1/watchlist", "alice", `{"kind":"peg","threshold":0.01,"label":"depeg"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created WatchlistItemResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, watchlist.KindPeg, created.Item.Kind)
+	assert.True(t, created.Item.Active)
+	path := "/v1/watchlist/" + created.Item.ID
+
+	rec = serve(http.MethodGet, "/v1/watchlist", "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list WatchlistResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "depeg", list.Items[0].Label)
+
+	rec = serve(http.MethodPatch, path, "alice", `{"active":false,"threshold":0.02}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var updated WatchlistItemResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.False(t, updated.Item.Active)
+	assert.Equal(t, 0.02, updated.Item.Threshold)
+
+	// Items of other addresses are not found
+	rec = serve(http.MethodGet, path, "bob", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "WATCHLIST_ITEM_NOT_FOUND")
+	rec = serve(http.MethodGet, "/v1/watchlist", "bob", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"items":[]}`, rec.Body.String())
+
+	for body, code := range map[string]string{
+		`{"kind":"price"}`:               "INVALID_WATCHLIST_REQUEST",
+		`{"kind":"peg","threshold":2}`:   "INVALID_WATCHLIST_REQUEST",
+		`{"kind":"balance"}`:             "INVALID_WATCHLIST_REQUEST", // No balance reader
+		`{"kind":"peg","threshold":"x"}`: "INVALID_JSON",
+	} {
+		rec := serve(http.MethodPost, "/v1/watchlist", "alice", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), code, body)
+	}
+
+	rec = serve(http.MethodDelete, path, "alice", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = serve(http.MethodDelete, path, "alice", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Without the service, watchlists are unavailable
+	h.watchlist = nil
+	rec = serve(http.MethodGet, "/v1/watchlist", "alice", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "WATCHLIST_UNAVAILABLE")
+}
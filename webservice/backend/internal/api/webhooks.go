@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/webhooks"
+)
+
+// ListWebhooks lists the webhook subscriptions
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+	subscriptions, err := h.webhooks.Subscriptions(r.Context())
+	if err != nil {
+		h.writeErrorFor(w, err, "WEBHOOK_ERROR")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, WebhookListResponse{Webhooks: subscriptions})
+}
+
+// CreateWebhook subscribes an endpoint; the response is the only one
+// showing its signing secret
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+	var req WebhookCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid webhook payload")
+		return
+	}
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+
+	subscription, secret, err := h.webhooks.Subscribe(r.Context(), req.URL, req.Secret, req.Events)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	h.logger.Infow("Webhook subscribed", "id", subscription.ID, "url", subscription.URL, "events", subscription.Events)
+	h.writeJSON(w, http.StatusCreated, WebhookResponse{Webhook: subscription, Secret: secret})
+}
+
+// GetWebhook returns a webhook subscription
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+	subscription, err := h.webhooks.Subscription(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, WebhookResponse{Webhook: subscription})
+}
+
+// DeleteWebhook unsubscribes an endpoint, dropping its deliveries
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := h.webhooks.Unsubscribe(r.Context(), id); err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	h.logger.Warnw("Webhook unsubscribed", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lists the latest deliveries of a subscription,
+// optionally only those in a status: pending, delivered or dead
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+	var req WebhookDeliveriesRequest
+	decodeQuery(r, &req)
+	if !h.validateRequest(w, &req, "") {
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, err := h.webhooks.Subscription(r.Context(), id); err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	deliveries, err := h.webhooks.Deliveries(r.Context(), id, req.Status, req.Limit)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, WebhookDeliveryListResponse{Deliveries: deliveries})
+}
+
+// GetWebhookDelivery returns a delivery with its payload and last outcome
+func (h *Handler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+	delivery, err := h.webhooks.Delivery(r.Context(), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, WebhookDeliveryResponse{Delivery: delivery})
+}
+
+// RedeliverWebhook queues a delivery, typically a dead letter, again
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+	delivery, err := h.webhooks.Redeliver(r.Context(), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	h.logger.Infow("Webhook redelivery queued", "id", delivery.ID, "subscription", delivery.SubscriptionID, "event", delivery.EventType)
+	h.writeJSON(w, http.StatusAccepted, WebhookDeliveryResponse{Delivery: delivery})
+}
+
+func (h *Handler) webhooksEnabled(w http.ResponseWriter) bool {
+	if h.webhooks == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "WEBHOOKS_UNAVAILABLE", "Webhooks are not configured")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhooks.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "WEBHOOK_NOT_FOUND", err.Error())
+	case errors.Is(err, webhooks.ErrInvalidRequest):
+		h.writeError(w, http.StatusBadRequest, "INVALID_WEBHOOK_REQUEST", err.Error())
+	default:
+		h.writeErrorFor(w, err, "WEBHOOK_ERROR")
+	}
+}
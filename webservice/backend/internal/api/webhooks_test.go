@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+
+	logger := zap.NewNop().Sugar()
+	svc := webhooks.NewService(database, logger)
+	h := &Handler{logger: logger, metrics: &MockMetrics{}, webhooks: svc}
+	admin := chi.NewRouter()
+	admin.Post("/webhooks", h.CreateWebhook)
+	admin.Get("/webhooks/deliveries/{deliveryId}", h.GetWebhookDelivery)
+	admin.Post("/webhooks/deliveries/{deliveryId}/redeliver", h.RedeliverWebhook)
+	admin.Get("/webhooks/{id}", h.GetWebhook)
+	admin.Delete("/webhooks/{id}", h.DeleteWebhook)
+	admin.Get("/webhooks/{id}/deliveries", h.ListWebhookDeliveries)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		return rec
+	}
+
+	for _, body := range []string{`{}`, `{"url":"ftp://example.com"}`, `{"url":"https://example.com","events":["Bad"]}`} {
+		rec := serve(http.MethodPost, "/webhooks", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), "INVALID_WEBHOOK_REQUEST", body)
+	}
+
+	rec := serve(http.MethodPost, "/webhooks", `{"url":"https://example.com/hook","events":["protocol.*"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created WebhookResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Secret)
+
+	rec = serve(http.MethodGet, "/webhooks/"+created.Webhook.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), created.Secret)
+
+	require.NoError(t, svc.Publish(ctx, "protocol.mint", "events:1", nil))
+	rec = serve(http.MethodGet, "/webhooks/"+created.Webhook.ID+"/deliveries?status=pending", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list WebhookDeliveryListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Deliveries, 1)
+	assert.Equal(t, "protocol.mint", list.Deliveries[0].EventType)
+
+	rec = serve(http.MethodGet, "/webhooks/"+created.Webhook.ID+"/deliveries?status=lost", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(http.MethodPost, "/webhooks/deliveries/"+list.Deliveries[0].ID+"/redeliver", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	rec = serve(http.MethodGet, "/webhooks/deliveries/unknown", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "WEBHOOK_NOT_FOUND")
+
+	rec = serve(http.MethodDelete, "/webhooks/"+created.Webhook.ID, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = serve(http.MethodGet, "/webhooks/"+created.Webhook.ID+"/deliveries", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Without the service the endpoints are unavailable
+	rec = httptest.NewRecorder()
+	(&Handler{logger: logger, metrics: &MockMetrics{}}).ListWebhooks(rec, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
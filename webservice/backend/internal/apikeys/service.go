@@ -0,0 +1,232 @@
+// Package apikeys manages the API keys of server-to-server consumers, such
+// as market makers. Keys carry scopes and a rate limit of their own; the
+// database holds the SHA-256 hash of their secret only.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gdb "github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// Scopes of keys
+const (
+	ScopeRead    = "read"     // Read-only routes
+	ScopeTxBuild = "tx:build" // Routes building and submitting transactions
+)
+
+// keyPrefix starts every key, followed by its ID and secret:
+// lfs_<id>_<secret>
+const keyPrefix = "lfs_"
+
+var (
+	// ErrNotFound is returned for unknown key IDs
+	ErrNotFound = errors.New("api key not found")
+
+	// ErrInvalidKey is returned for malformed, unknown or revoked keys
+	ErrInvalidKey = errors.New("invalid api key")
+
+	// ErrInvalidRequest is returned for keys without a name or scope, or
+	// with an unknown scope or a negative rate limit
+	ErrInvalidRequest = errors.New("invalid api key request")
+)
+
+// Key is an API key, without its secret
+type Key struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Scopes       []string   `json:"scopes"`
+	RateLimitRPM int        `json:"rateLimitRpm"` // 0 takes the default of keys
+	CreatedAt    time.Time  `json:"createdAt"`
+	RotatedAt    *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Allows reports whether the key grants scope. Keys building transactions
+// also read.
+func (k *Key) Allows(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || (s == ScopeTxBuild && scope == ScopeRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// Service creates, rotates, revokes and authenticates keys
+type Service struct {
+	keys *gdb.TypedRepository[entities.APIKey]
+}
+
+func NewService(database interfaces.Database) *Service {
+	return &Service{keys: gdb.MustNewTypedRepository[entities.APIKey](database, entities.APIKeySchema)}
+}
+
+// Create issues a key and returns it with its secret, which is not shown
+// again
+func (s *Service) Create(ctx context.Context, name string, scopes []string, rateLimitRPM int) (*Key, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidRequest)
+	}
+	if rateLimitRPM < 0 {
+		return nil, "", fmt.Errorf("%w: rate limit must not be negative", ErrInvalidRequest)
+	}
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+
+	id := randomHex(6)
+	secret := randomHex(32)
+	entity, err := s.keys.Create(ctx, &entities.APIKey{
+		ID:           id,
+		Name:         name,
+		KeyHash:      hashSecret(secret),
+		Scopes:       strings.Join(scopes, " "),
+		RateLimitRPM: int64(rateLimitRPM),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("create api key: %w", err)
+	}
+	return keyFromEntity(entity), formatKey(id, secret), nil
+}
+
+// Rotate replaces the secret of a key, keeping its ID, scopes and limit.
+// The previous secret stops working at once.
+func (s *Service) Rotate(ctx context.Context, id string) (*Key, string, error) {
+	entity, err := s.get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if entity.RevokedAt != nil {
+		return nil, "", fmt.Errorf("%w: %s is revoked", ErrInvalidKey, id)
+	}
+
+	secret := randomHex(32)
+	now := time.Now().UTC()
+	entity.KeyHash = hashSecret(secret)
+	entity.RotatedAt = &now
+	entity, err = s.keys.Update(ctx, entity)
+	if err != nil {
+		return nil, "", fmt.Errorf("rotate api key: %w", err)
+	}
+	return keyFromEntity(entity), formatKey(id, secret), nil
+}
+
+// Revoke disables a key for good. Revoking a revoked key is a no-op.
+func (s *Service) Revoke(ctx context.Context, id string) (*Key, error) {
+	entity, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entity.RevokedAt != nil {
+		return keyFromEntity(entity), nil
+	}
+
+	now := time.Now().UTC()
+	entity.RevokedAt = &now
+	entity, err = s.keys.Update(ctx, entity)
+	if err != nil {
+		return nil, fmt.Errorf("revoke api key: %w", err)
+	}
+	return keyFromEntity(entity), nil
+}
+
+// List returns every key, oldest first
+func (s *Service) List(ctx context.Context) ([]*Key, error) {
+	page, err := s.keys.FindMany(ctx, &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{{Field: "created_at", Direction: "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	keys := make([]*Key, 0, len(page.Data))
+	for i := range page.Data {
+		keys = append(keys, keyFromEntity(&page.Data[i]))
+	}
+	return keys, nil
+}
+
+// Authenticate returns the key of raw, a key as Create and Rotate return
+// it. It fails with ErrInvalidKey unless the key is known and live.
+func (s *Service) Authenticate(ctx context.Context, raw string) (*Key, error) {
+	rest, ok := strings.CutPrefix(raw, keyPrefix)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrInvalidKey
+	}
+
+	entity, err := s.get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(entity.KeyHash)) != 1 || entity.RevokedAt != nil {
+		return nil, ErrInvalidKey
+	}
+	return keyFromEntity(entity), nil
+}
+
+func (s *Service) get(ctx context.Context, id string) (*entities.APIKey, error) {
+	entity, err := s.keys.GetByID(ctx, interfaces.StringID(id))
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	return entity, nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidRequest)
+	}
+	for _, scope := range scopes {
+		if scope != ScopeRead && scope != ScopeTxBuild {
+			return fmt.Errorf("%w: unknown scope %q", ErrInvalidRequest, scope)
+		}
+	}
+	return nil
+}
+
+func keyFromEntity(e *entities.APIKey) *Key {
+	return &Key{
+		ID:           e.ID,
+		Name:         e.Name,
+		Scopes:       strings.Fields(e.Scopes),
+		RateLimitRPM: int(e.RateLimitRPM),
+		CreatedAt:    e.CreatedAt,
+		RotatedAt:    e.RotatedAt,
+		RevokedAt:    e.RevokedAt,
+	}
+}
+
+func formatKey(id, secret string) string {
+	return keyPrefix + id + "_" + secret
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(size int) string {
+	bytes := make([]byte, size)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
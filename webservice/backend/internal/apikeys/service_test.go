@@ -0,0 +1,69 @@
+package apikeys
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+	svc := NewService(database)
+
+	_, _, err := svc.Create(ctx, "mm", []string{"admin"}, 0)
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	_, _, err = svc.Create(ctx, "mm", nil, 0)
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	_, _, err = svc.Create(ctx, "", []string{ScopeRead}, 0)
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	key, raw, err := svc.Create(ctx, "market maker", []string{ScopeRead}, 600)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, "lfs_"+key.ID+"_"))
+	assert.Equal(t, 600, key.RateLimitRPM)
+
+	got, err := svc.Authenticate(ctx, raw)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+	assert.True(t, got.Allows(ScopeRead))
+	assert.False(t, got.Allows(ScopeTxBuild))
+	assert.True(t, (&Key{Scopes: []string{ScopeTxBuild}}).Allows(ScopeRead))
+
+	for _, bad := range []string{"", "lfs_", "nope", "lfs_" + key.ID + "_wrong", "lfs_unknown_" + raw[len(raw)-64:]} {
+		_, err := svc.Authenticate(ctx, bad)
+		assert.ErrorIs(t, err, ErrInvalidKey, bad)
+	}
+
+	// Rotation retires the previous secret
+	rotated, newRaw, err := svc.Rotate(ctx, key.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, rotated.RotatedAt)
+	assert.NotEqual(t, raw, newRaw)
+	_, err = svc.Authenticate(ctx, raw)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = svc.Authenticate(ctx, newRaw)
+	require.NoError(t, err)
+
+	revoked, err := svc.Revoke(ctx, key.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+	_, err = svc.Authenticate(ctx, newRaw)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, _, err = svc.Rotate(ctx, key.ID)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = svc.Revoke(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	keys, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, []string{ScopeRead}, keys[0].Scopes)
+}
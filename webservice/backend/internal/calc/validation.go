@@ -25,15 +25,16 @@ func ValidateMinReceived(actualOutput, minReceived decimal.Decimal, operation st
 	return nil
 }
 
+// MaxAmount bounds the amounts of requests, to prevent overflow issues
+var MaxAmount = decimal.New(1, 30)
+
 // ValidateAmount checks if an amount is positive and within reasonable bounds
 func ValidateAmount(amount decimal.Decimal, operation string) error {
 	if amount.LessThanOrEqual(decimal.Zero) {
 		return fmt.Errorf("invalid %s amount: must be positive", operation)
 	}
 
-	// Check for reasonable upper bounds (prevent overflow issues)
-	maxAmount := decimal.New(1, 30) // 10^30
-	if amount.GreaterThan(maxAmount) {
+	if amount.GreaterThan(MaxAmount) {
 		return fmt.Errorf("invalid %s amount: too large", operation)
 	}
 
@@ -0,0 +1,316 @@
+// Package candles rolls price ticks into OHLCV candles of several
+// intervals and keeps them in the database, so that charts outlive the
+// provider's history window and restarts of the API. Candles are
+// backfilled from the provider's REST history on startup.
+package candles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"go.uber.org/zap"
+)
+
+// SourceTicks is the source of candles aggregated from live ticks
+const SourceTicks = "ticks"
+
+// DefaultBackfillLimit is the number of candles per interval backfilled
+// when the service is given none
+const DefaultBackfillLimit = 500
+
+// MaxPageSize bounds the candles of one page
+const MaxPageSize = 1000
+
+// Intervals are the intervals candles are aggregated to
+var Intervals = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	4 * time.Hour,
+	24 * time.Hour,
+}
+
+var (
+	// ErrUnknownInterval is returned for intervals candles are not
+	// aggregated to
+	ErrUnknownInterval = errors.New("unknown interval")
+	// ErrInvalidRange is returned for queries whose range is empty
+	ErrInvalidRange = errors.New("invalid range")
+	// ErrInvalidCursor is returned when a candles cursor cannot be used
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Page is a page of candles, oldest first. NextCursor is empty on the last
+// page.
+type Page struct {
+	Candles    []prices.Candle
+	NextCursor string
+}
+
+// Service aggregates ticks into candles and keeps them in a repository, as
+// described by entities.PriceCandleSchema. Ticks update candles in memory;
+// Flush writes the changed ones, merged with the stored candle of their
+// interval so that replicas and restarts widen a candle rather than
+// replace it.
+type Service struct {
+	repo          interfaces.Repository
+	logger        *zap.SugaredLogger
+	backfillLimit int
+
+	mu    sync.Mutex
+	open  map[candleKey]*prices.Candle // Newest candle of each series
+	dirty map[candleKey]map[int64]prices.Candle
+}
+
+// candleKey names a series of candles
+type candleKey struct {
+	symbol   string
+	interval time.Duration
+}
+
+type ServiceOption func(*Service)
+
+// WithBackfillLimit sets the number of candles per interval Backfill reads
+// from a provider
+func WithBackfillLimit(limit int) ServiceOption {
+	return func(s *Service) {
+		if limit > 0 {
+			s.backfillLimit = limit
+		}
+	}
+}
+
+func NewService(repo interfaces.Repository, logger *zap.SugaredLogger, opts ...ServiceOption) *Service {
+	s := &Service{
+		repo:          repo,
+		logger:        logger,
+		backfillLimit: DefaultBackfillLimit,
+		open:          make(map[candleKey]*prices.Candle),
+		dirty:         make(map[candleKey]map[int64]prices.Candle),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ValidInterval reports whether candles are aggregated to interval
+func ValidInterval(interval time.Duration) bool {
+	for _, iv := range Intervals {
+		if iv == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// Record adds a tick to the candles of its symbol. Ticks older than the
+// open candle of an interval are dropped for it.
+func (s *Service) Record(tick prices.Tick) {
+	if tick.Price <= 0 {
+		return
+	}
+	at := time.UnixMilli(tick.TsMs)
+	symbol := strings.ToUpper(tick.Symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, interval := range Intervals {
+		key := candleKey{symbol: symbol, interval: interval}
+		bucket := prices.AlignTime(at, interval).Unix()
+		candle := s.open[key]
+		switch {
+		case candle == nil || bucket > candle.Time:
+			candle = &prices.Candle{Time: bucket, Open: tick.Price, High: tick.Price, Low: tick.Price, Close: tick.Price}
+			s.open[key] = candle
+		case bucket < candle.Time:
+			continue
+		default:
+			candle.High = max(candle.High, tick.Price)
+			candle.Low = min(candle.Low, tick.Price)
+			candle.Close = tick.Price
+		}
+		if s.dirty[key] == nil {
+			s.dirty[key] = make(map[int64]prices.Candle)
+		}
+		s.dirty[key][candle.Time] = *candle
+	}
+}
+
+// Flush writes the candles ticks changed since the last flush. Candles
+// that fail to be written are kept for the next one.
+func (s *Service) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	dirty := s.dirty
+	s.dirty = make(map[candleKey]map[int64]prices.Candle)
+	s.mu.Unlock()
+
+	var failed int
+	var lastErr error
+	for key, candles := range dirty {
+		for _, candle := range candles {
+			if err := s.merge(ctx, key, candle); err != nil {
+				failed++
+				lastErr = err
+				s.requeue(key, candle)
+			}
+		}
+	}
+	if lastErr != nil {
+		return fmt.Errorf("failed to write %d candles: %w", failed, lastErr)
+	}
+	return nil
+}
+
+// requeue marks candle for the next flush, unless ticks changed it since
+func (s *Service) requeue(key candleKey, candle prices.Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirty[key] == nil {
+		s.dirty[key] = make(map[int64]prices.Candle)
+	}
+	if _, exists := s.dirty[key][candle.Time]; !exists {
+		s.dirty[key][candle.Time] = candle
+	}
+}
+
+// merge writes candle, widened by the stored candle of its interval. The
+// stored open is kept, as is the larger volume, since ticks carry none.
+func (s *Service) merge(ctx context.Context, key candleKey, candle prices.Candle) error {
+	unique := map[string]interface{}{
+		"symbol":   key.symbol,
+		"interval": prices.IntervalString(key.interval),
+		"time":     time.Unix(candle.Time, 0).UTC(),
+	}
+	source := SourceTicks
+	stored, err := s.repo.FindOne(ctx, &interfaces.Query{Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+		{Field: "symbol", Value: unique["symbol"]},
+		{Field: "interval", Value: unique["interval"]},
+		{Field: "time", Value: unique["time"]},
+	}}})
+	switch {
+	case err == nil:
+		existing := fromRecord(stored)
+		candle.Open = existing.Open
+		candle.High = max(candle.High, existing.High)
+		candle.Low = min(candle.Low, existing.Low)
+		candle.Volume = max(candle.Volume, existing.Volume)
+		if src, ok := stored["source"].(string); ok && src != "" {
+			source = src
+		}
+	case !errors.Is(err, interfaces.ErrNotFound):
+		return fmt.Errorf("read candle: %w", err)
+	}
+	return s.save(ctx, unique, candle, source)
+}
+
+func (s *Service) save(ctx context.Context, unique map[string]interface{}, candle prices.Candle, source string) error {
+	_, err := s.repo.Upsert(ctx, unique, map[string]interface{}{
+		"open":   candle.Open,
+		"high":   candle.High,
+		"low":    candle.Low,
+		"close":  candle.Close,
+		"volume": candle.Volume,
+		"source": source,
+	})
+	if err != nil {
+		return fmt.Errorf("save candle: %w", err)
+	}
+	return nil
+}
+
+// Backfill writes the recent history of symbols in every interval from
+// provider, replacing the stored candles it covers. Failures of a series
+// are logged and skipped, so that one unavailable symbol does not hold
+// back the others.
+func (s *Service) Backfill(ctx context.Context, provider prices.Provider, symbols []string) error {
+	var written int
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		for _, interval := range Intervals {
+			history, err := provider.FetchHistory(ctx, symbol, interval, s.backfillLimit)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				s.logger.Warnw("Failed to backfill candles", "symbol", symbol, "interval", prices.IntervalString(interval), "provider", provider.Name(), "error", err)
+				continue
+			}
+			for _, candle := range history {
+				unique := map[string]interface{}{
+					"symbol":   symbol,
+					"interval": prices.IntervalString(interval),
+					"time":     time.Unix(candle.Time, 0).UTC(),
+				}
+				if err := s.save(ctx, unique, candle, provider.Name()); err != nil {
+					return err
+				}
+				written++
+			}
+		}
+	}
+	s.logger.Infow("Candles backfilled", "provider", provider.Name(), "symbols", symbols, "candles", written)
+	return nil
+}
+
+// Candles returns the candles of symbol in interval from from to to, oldest
+// first. cursor is empty for the first page or the next cursor returned
+// with the previous page.
+func (s *Service) Candles(ctx context.Context, symbol string, interval time.Duration, from, to time.Time, limit int, cursor string) (*Page, error) {
+	if !ValidInterval(interval) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownInterval, interval)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRange)
+	}
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	page, err := s.repo.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{
+				{Field: "symbol", Value: strings.ToUpper(symbol)},
+				{Field: "interval", Value: prices.IntervalString(interval)},
+				{Field: "time", Operator: &interfaces.FilterOperator{Gte: from.UTC()}},
+				{Field: "time", Operator: &interfaces.FilterOperator{Lt: to.UTC()}},
+			},
+		},
+		OrderBy: []interfaces.OrderBy{{Field: "time", Direction: "asc"}},
+		Limit:   &limit,
+		After:   cursor,
+	})
+	if err != nil {
+		if cursor != "" && errors.Is(err, interfaces.ErrInvalidQuery) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		s.logger.Errorw("Failed to read candles", "symbol", symbol, "interval", interval, "error", err)
+		return nil, fmt.Errorf("failed to read candles: %w", err)
+	}
+
+	candles := make([]prices.Candle, 0, len(page.Data))
+	for _, record := range page.Data {
+		candles = append(candles, fromRecord(record))
+	}
+	return &Page{Candles: candles, NextCursor: page.NextCursor}, nil
+}
+
+func fromRecord(record map[string]interface{}) prices.Candle {
+	candle := prices.Candle{}
+	if at, ok := record["time"].(time.Time); ok {
+		candle.Time = at.Unix()
+	}
+	candle.Open, _ = record["open"].(float64)
+	candle.High, _ = record["high"].(float64)
+	candle.Low, _ = record["low"].(float64)
+	candle.Close, _ = record["close"].(float64)
+	candle.Volume, _ = record["volume"].(float64)
+	return candle
+}
@@ -0,0 +1,153 @@
+package candles
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestRepo(t *testing.T) interfaces.Repository {
+	t.Helper()
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	t.Cleanup(func() { database.Disconnect(ctx) })
+	return database.Repository(entities.PriceCandleSchema)
+}
+
+func tick(at time.Time, price float64) prices.Tick {
+	return prices.Tick{Symbol: "suiusdt", Price: price, TsMs: at.UnixMilli()}
+}
+
+func TestRecordAndFlush(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(newTestRepo(t), zap.NewNop().Sugar())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	svc.Record(tick(start.Add(10*time.Second), 1.50))
+	svc.Record(tick(start.Add(20*time.Second), 1.60))
+	svc.Record(tick(start.Add(30*time.Second), 1.40))
+	svc.Record(tick(start.Add(70*time.Second), 1.45))
+	// Late ticks are dropped for the intervals that moved on
+	svc.Record(tick(start.Add(50*time.Second), 9.99))
+	require.NoError(t, svc.Flush(ctx))
+
+	page, err := svc.Candles(ctx, "SUIUSDT", time.Minute, start, start.Add(time.Hour), 0, "")
+	require.NoError(t, err)
+	require.Len(t, page.Candles, 2)
+	assert.Equal(t, prices.Candle{Time: start.Unix(), Open: 1.50, High: 1.60, Low: 1.40, Close: 1.40}, page.Candles[0])
+	assert.Equal(t, prices.Candle{Time: start.Add(time.Minute).Unix(), Open: 1.45, High: 1.45, Low: 1.45, Close: 1.45}, page.Candles[1])
+
+	page, err = svc.Candles(ctx, "SUIUSDT", time.Hour, start, start.Add(time.Hour), 0, "")
+	require.NoError(t, err)
+	require.Len(t, page.Candles, 1)
+	assert.Equal(t, prices.Candle{Time: start.Unix(), Open: 1.50, High: 9.99, Low: 1.40, Close: 9.99}, page.Candles[0])
+
+	// A restarted service widens the stored candle rather than replacing it
+	restarted := NewService(svc.repo, zap.NewNop().Sugar())
+	restarted.Record(tick(start.Add(80*time.Second), 1.20))
+	require.NoError(t, restarted.Flush(ctx))
+	page, err = restarted.Candles(ctx, "SUIUSDT", time.Minute, start.Add(time.Minute), start.Add(2*time.Minute), 0, "")
+	require.NoError(t, err)
+	require.Len(t, page.Candles, 1)
+	assert.Equal(t, prices.Candle{Time: start.Add(time.Minute).Unix(), Open: 1.45, High: 1.45, Low: 1.20, Close: 1.20}, page.Candles[0])
+
+	// Flushing without new ticks writes nothing
+	require.NoError(t, restarted.Flush(ctx))
+	count, err := svc.repo.Count(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2+len(Intervals)-1), count)
+}
+
+type fakeProvider struct {
+	history map[time.Duration][]prices.Candle
+	err     error
+}
+
+func (p *fakeProvider) FetchHistory(ctx context.Context, symbol string, interval time.Duration, limit int) ([]prices.Candle, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	history := p.history[interval]
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history, nil
+}
+
+func (p *fakeProvider) SubscribeLive(ctx context.Context, symbol string, out chan<- prices.Tick) error {
+	return nil
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Health() prices.ProviderHealth { return prices.ProviderHealth{Healthy: true} }
+
+func TestBackfillAndPaging(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(newTestRepo(t), zap.NewNop().Sugar(), WithBackfillLimit(5))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var history []prices.Candle
+	for i := 0; i < 6; i++ {
+		price := 1 + float64(i)/10
+		history = append(history, prices.Candle{
+			Time: start.Add(time.Duration(i) * time.Minute).Unix(),
+			Open: price, High: price + 0.05, Low: price - 0.05, Close: price, Volume: 100,
+		})
+	}
+	provider := &fakeProvider{history: map[time.Duration][]prices.Candle{time.Minute: history}}
+	require.NoError(t, svc.Backfill(ctx, provider, []string{"suiusdt"}))
+
+	// Ticks widen backfilled candles and keep their volume
+	svc.Record(tick(start.Add(5*time.Minute+time.Second), 2.0))
+	require.NoError(t, svc.Flush(ctx))
+
+	var all []prices.Candle
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5)
+		page, err := svc.Candles(ctx, "SUIUSDT", time.Minute, start, start.Add(time.Hour), 2, cursor)
+		require.NoError(t, err)
+		all = append(all, page.Candles...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	require.Len(t, all, 5)
+	assert.Equal(t, history[1], all[0])
+	assert.Equal(t, prices.Candle{Time: history[5].Time, Open: 1.5, High: 2.0, Low: 1.45, Close: 2.0, Volume: 100}, all[4])
+
+	stored, err := svc.repo.FindOne(ctx, &interfaces.Query{Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+		{Field: "time", Value: time.Unix(history[5].Time, 0).UTC()},
+		{Field: "interval", Value: "1m"},
+	}}})
+	require.NoError(t, err)
+	assert.Equal(t, "fake", stored["source"])
+
+	// Failing series are skipped
+	require.NoError(t, svc.Backfill(ctx, &fakeProvider{err: errors.New("unavailable")}, []string{"SUIUSDT"}))
+}
+
+func TestCandlesValidation(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(newTestRepo(t), zap.NewNop().Sugar())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := svc.Candles(ctx, "SUIUSDT", 2*time.Minute, start, start.Add(time.Hour), 0, "")
+	assert.ErrorIs(t, err, ErrUnknownInterval)
+	_, err = svc.Candles(ctx, "SUIUSDT", time.Minute, start, start, 0, "")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = svc.Candles(ctx, "SUIUSDT", time.Minute, start, start.Add(time.Hour), 0, "not-a-cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets in sanitized configs
+const redacted = "[REDACTED]"
+
+// Sanitized returns the effective settings keyed by their environment
+// variables, safe to show operators. Settings tagged redact:"secret" are
+// replaced when set; those tagged redact:"url" keep scheme, host and path
+// but lose credentials and query strings.
+func (c *Config) Sanitized() map[string]interface{} {
+	settings := make(map[string]interface{})
+	sanitizeInto(settings, reflect.ValueOf(c).Elem())
+	return settings
+}
+
+func sanitizeInto(settings map[string]interface{}, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("mapstructure")
+		if name == ",squash" {
+			sanitizeInto(settings, v.Field(i))
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		settings[name] = sanitizeValue(v.Field(i).Interface(), field.Tag.Get("redact"))
+	}
+}
+
+func sanitizeValue(value interface{}, redact string) interface{} {
+	switch redact {
+	case "secret":
+		if reflect.ValueOf(value).IsZero() {
+			return value
+		}
+		return redacted
+	case "url":
+		switch value := value.(type) {
+		case string:
+			// Comma-separated lists are sanitized entry by entry
+			parts := strings.Split(value, ",")
+			for i, part := range parts {
+				parts[i] = sanitizeURL(part)
+			}
+			return strings.Join(parts, ",")
+		case []string:
+			urls := make([]string, len(value))
+			for i, raw := range value {
+				urls[i] = sanitizeURL(raw)
+			}
+			return urls
+		}
+	}
+	if d, ok := value.(time.Duration); ok {
+		return d.String()
+	}
+	return value
+}
+
+// sanitizeURL strips credentials and the query string from raw, which are
+// where DSNs and RPC providers carry secrets
+func sanitizeURL(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return raw
+	}
+	u, err := url.Parse(trimmed)
+	if err != nil || u.Host == "" {
+		// Unparseable values might be secrets themselves
+		return redacted
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	return u.String()
+}
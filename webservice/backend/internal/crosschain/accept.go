@@ -0,0 +1,198 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// submissionTTL is how long accepted submissions are tracked, well past
+	// the moment their receipts are recorded
+	submissionTTL = 24 * time.Hour
+
+	// acceptedTimeout bounds the background processing of a submission
+	acceptedTimeout = 10 * time.Minute
+)
+
+// SubmissionState is the progress of a deposit or redeem accepted for
+// processing in the background
+type SubmissionState string
+
+const (
+	// SubmissionQueued marks a submission being processed
+	SubmissionQueued SubmissionState = "queued"
+
+	// SubmissionProcessed marks a submission whose receipt is recorded
+	SubmissionProcessed SubmissionState = "processed"
+
+	// SubmissionFailed marks a submission that failed, before its receipt
+	// recorded the failure or without one
+	SubmissionFailed SubmissionState = "failed"
+)
+
+// Submission is a deposit or redeem accepted by Accept or AcceptRedeem.
+// Once it is processed, the receipt with ReceiptID tells its stage.
+type Submission struct {
+	ReceiptID  string          `json:"receiptId"`
+	Kind       ReceiptKind     `json:"kind"`
+	SuiOwner   string          `json:"suiOwner"`
+	State      SubmissionState `json:"state"`
+	Error      string          `json:"error,omitempty"`
+	AcceptedAt time.Time       `json:"acceptedAt"`
+}
+
+// WithSubmissionStore configures the worker to track accepted submissions
+// in store, so that every replica can report them. They are tracked in
+// memory otherwise.
+func WithSubmissionStore(store kv.Store) BridgeWorkerOption {
+	return func(w *BridgeWorker) {
+		w.submissions = store
+	}
+}
+
+// Accept checks a deposit like Submit and processes it in the background,
+// returning at once with the receipt ID it will be recorded under. A
+// deposit already minted is not processed again; its submission is
+// returned processed. A deposit being processed fails with
+// ErrDepositInProgress.
+func (w *BridgeWorker) Accept(ctx context.Context, sub DepositSubmission) (*Submission, error) {
+	if sub.SuiOwner == "" || sub.Asset == "" || sub.ChainID == "" || !sub.Amount.GreaterThan(decimal.Zero) {
+		return nil, ErrInvalidRequest
+	}
+	if _, err := w.svc.GetVault(ctx, sub.ChainID, sub.Asset); err != nil {
+		return nil, fmt.Errorf("%w: no vault for %s:%s", ErrInvalidRequest, sub.ChainID, sub.Asset)
+	}
+	if err := w.svc.CheckDeposit(ctx, sub.ChainID, sub.Asset, sub.Amount); err != nil {
+		return nil, err
+	}
+
+	// Deposits submitted before keep their receipt ID
+	if strings.TrimSpace(sub.TxHash) != "" {
+		deposits, err := w.svc.GetDeposits(ctx, sub.ChainID, sub.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("get deposits: %w", err)
+		}
+		for _, existing := range deposits {
+			if existing.LogIndex != sub.LogIndex {
+				continue
+			}
+			switch existing.Status {
+			case DepositStatusPending:
+				return nil, ErrDepositInProgress
+			case DepositStatusMinted:
+				return &Submission{
+					ReceiptID:  existing.ReceiptID,
+					Kind:       ReceiptKindDeposit,
+					SuiOwner:   existing.SuiOwner,
+					State:      SubmissionProcessed,
+					AcceptedAt: existing.CreatedAt,
+				}, nil
+			}
+			sub.ReceiptID = existing.ReceiptID
+		}
+	}
+
+	if err := w.checkOwnerLimits(ctx, BridgeOpDeposit, sub.SuiOwner, sub.ChainID, sub.Asset, sub.Amount); err != nil {
+		return nil, err
+	}
+	if sub.ReceiptID == "" {
+		sub.ReceiptID = w.svc.NextReceiptID("bridge")
+	}
+	return w.accept(ctx, ReceiptKindDeposit, sub.ReceiptID, sub.SuiOwner, func(ctx context.Context) error {
+		_, err := w.submit(ctx, sub)
+		return err
+	})
+}
+
+// AcceptRedeem checks a redeem like Redeem and processes it in the
+// background, returning at once with the receipt ID it will be recorded
+// under.
+func (w *BridgeWorker) AcceptRedeem(ctx context.Context, sub RedeemSubmission) (*Submission, error) {
+	token := strings.ToLower(strings.TrimSpace(sub.Token))
+	if sub.SuiOwner == "" || sub.Asset == "" || sub.ChainID == "" || sub.EthRecipient == "" || !sub.Amount.GreaterThan(decimal.Zero) || (token != "f" && token != "x") {
+		return nil, ErrInvalidRequest
+	}
+	if err := w.svc.CheckRedeem(ctx, sub.ChainID, sub.Asset); err != nil {
+		return nil, err
+	}
+	if err := w.checkOwnerLimits(ctx, BridgeOpRedeem, sub.SuiOwner, sub.ChainID, sub.Asset, sub.Amount); err != nil {
+		return nil, err
+	}
+
+	sub.ReceiptID = w.svc.NextReceiptID("redeem")
+	return w.accept(ctx, ReceiptKindRedeem, sub.ReceiptID, sub.SuiOwner, func(ctx context.Context) error {
+		_, err := w.redeem(ctx, sub)
+		return err
+	})
+}
+
+// accept tracks a submission as queued and runs process in the background,
+// detached from ctx, tracking its outcome
+func (w *BridgeWorker) accept(ctx context.Context, kind ReceiptKind, receiptID, owner string, process func(context.Context) error) (*Submission, error) {
+	submission := &Submission{
+		ReceiptID:  receiptID,
+		Kind:       kind,
+		SuiOwner:   owner,
+		State:      SubmissionQueued,
+		AcceptedAt: time.Now(),
+	}
+	if err := w.saveSubmission(ctx, submission); err != nil {
+		return nil, err
+	}
+
+	accepted := *submission
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), acceptedTimeout)
+		defer cancel()
+
+		accepted.State = SubmissionProcessed
+		if err := process(ctx); err != nil {
+			w.logger.Warnw("Accepted bridge submission failed", "receiptId", receiptID, "kind", kind, "error", err)
+			accepted.State = SubmissionFailed
+			accepted.Error = err.Error()
+		}
+		if err := w.saveSubmission(ctx, &accepted); err != nil {
+			w.logger.Errorw("Failed to record bridge submission", "receiptId", receiptID, "error", err)
+		}
+	}()
+	return submission, nil
+}
+
+// Submission returns the submission accepted under receiptID, or
+// ErrNotFound once it is no longer tracked
+func (w *BridgeWorker) Submission(ctx context.Context, receiptID string) (*Submission, error) {
+	raw, err := w.submissions.Get(ctx, submissionKey(receiptID))
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get submission: %w", err)
+	}
+	var submission Submission
+	if err := json.Unmarshal(raw, &submission); err != nil {
+		return nil, fmt.Errorf("decode submission: %w", err)
+	}
+	return &submission, nil
+}
+
+func (w *BridgeWorker) saveSubmission(ctx context.Context, submission *Submission) error {
+	raw, err := json.Marshal(submission)
+	if err != nil {
+		return fmt.Errorf("encode submission: %w", err)
+	}
+	if err := w.submissions.Set(ctx, submissionKey(submission.ReceiptID), raw, submissionTTL); err != nil {
+		return fmt.Errorf("save submission: %w", err)
+	}
+	return nil
+}
+
+func submissionKey(receiptID string) string {
+	return "bridge:submission:" + receiptID
+}
@@ -0,0 +1,128 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// waitSubmission polls the submission of receiptID until it leaves the queue
+func waitSubmission(t *testing.T, worker *BridgeWorker, receiptID string) *Submission {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		submission, err := worker.Submission(context.Background(), receiptID)
+		if err != nil {
+			t.Fatalf("Submission failed: %v", err)
+		}
+		if submission.State != SubmissionQueued {
+			return submission
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Submission %s still queued", receiptID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBridgeWorkerAcceptsDeposits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := zap.NewNop().Sugar()
+	svc := NewService(logger)
+	if err := svc.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	worker := NewBridgeWorker(svc, logger, WithPriceSource(fixedPrice(decimal.RequireFromString("2000"))))
+	worker.Start(ctx)
+
+	deposit := DepositSubmission{
+		TxHash:   "0xabc",
+		LogIndex: 1,
+		SuiOwner: "0xalice",
+		ChainID:  ChainIDEthereum,
+		Asset:    "ETH",
+		Amount:   decimal.RequireFromString("0.5"),
+	}
+	accepted, err := worker.Accept(ctx, deposit)
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	if accepted.State != SubmissionQueued || accepted.Kind != ReceiptKindDeposit || accepted.ReceiptID == "" {
+		t.Fatalf("Unexpected submission: %+v", accepted)
+	}
+
+	if submission := waitSubmission(t, worker, accepted.ReceiptID); submission.State != SubmissionProcessed {
+		t.Fatalf("Expected the deposit processed, got %+v", submission)
+	}
+	receipt, err := svc.GetReceipt(ctx, accepted.ReceiptID)
+	if err != nil {
+		t.Fatalf("GetReceipt failed: %v", err)
+	}
+	if receipt.Deposit == nil || receipt.Deposit.Status != DepositStatusMinted {
+		t.Errorf("Expected the deposit minted under the accepted receipt ID, got %+v", receipt.Deposit)
+	}
+
+	// A minted deposit is not processed again
+	again, err := worker.Accept(ctx, deposit)
+	if err != nil {
+		t.Fatalf("Accept again failed: %v", err)
+	}
+	if again.ReceiptID != accepted.ReceiptID || again.State != SubmissionProcessed {
+		t.Errorf("Expected the minted receipt back, got %+v", again)
+	}
+
+	unknown := deposit
+	unknown.TxHash, unknown.Asset = "0xdef", "DOGE"
+	if _, err := worker.Accept(ctx, unknown); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Expected a deposit without a vault rejected, got %v", err)
+	}
+	if _, err := worker.Submission(ctx, "bridge_999"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an unknown submission not found, got %v", err)
+	}
+}
+
+func TestBridgeWorkerAcceptedRedeemFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := zap.NewNop().Sugar()
+	svc := NewService(logger)
+	if err := svc.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	worker := NewBridgeWorker(svc, logger, WithPriceSource(fixedPrice(decimal.RequireFromString("2000"))))
+	worker.Start(ctx)
+
+	// The owner has no shares to burn, which only processing finds out
+	accepted, err := worker.AcceptRedeem(ctx, RedeemSubmission{
+		SuiTxDigest:  "digest",
+		SuiOwner:     "0xbob",
+		EthRecipient: "0x000000000000000000000000000000000000b0b0",
+		ChainID:      ChainIDEthereum,
+		Asset:        "ETH",
+		Token:        "x",
+		Amount:       decimal.RequireFromString("1"),
+	})
+	if err != nil {
+		t.Fatalf("AcceptRedeem failed: %v", err)
+	}
+	submission := waitSubmission(t, worker, accepted.ReceiptID)
+	if submission.State != SubmissionFailed || submission.Error == "" || submission.Kind != ReceiptKindRedeem {
+		t.Fatalf("Expected the redeem failed, got %+v", submission)
+	}
+	transitions, err := svc.GetTransitions(ctx, accepted.ReceiptID)
+	if err != nil {
+		t.Fatalf("GetTransitions failed: %v", err)
+	}
+	if n := len(transitions); n == 0 || transitions[n-1].To != StageFailed {
+		t.Errorf("Expected the redeem receipt to fail, got %+v", transitions)
+	}
+
+	if _, err := worker.AcceptRedeem(ctx, RedeemSubmission{SuiOwner: "0xbob", ChainID: ChainIDEthereum, Asset: "ETH", Token: "y", Amount: decimal.NewFromInt(1)}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Expected an invalid redeem rejected, got %v", err)
+	}
+}
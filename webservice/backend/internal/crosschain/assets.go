@@ -0,0 +1,61 @@
+package crosschain
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// nativeDecimals is the number of decimals of ETH amounts in wei
+const nativeDecimals = 18
+
+// tokenVaultsFromEnv returns the ERC-20 vaults listed in LFS_BRIDGE_TOKENS,
+// e.g. "USDC,WBTC". Each token is configured by
+//
+//	LFS_BRIDGE_<TOKEN>_TOKEN_ADDRESS  ERC-20 contract address
+//	LFS_BRIDGE_<TOKEN>_VAULT_ADDRESS  vault holding deposits of the token
+//	LFS_BRIDGE_<TOKEN>_DECIMALS       token decimals, e.g. 6 for USDC
+//	LFS_BRIDGE_<TOKEN>_PRICE_SYMBOL   Binance ticker, e.g. USDCUSDT
+func tokenVaultsFromEnv() ([]VaultInfo, error) {
+	var vaults []VaultInfo
+	for _, token := range strings.Split(envOrDefault("", "LFS_BRIDGE_TOKENS"), ",") {
+		token = strings.ToUpper(strings.TrimSpace(token))
+		if token == "" {
+			continue
+		}
+		prefix := "LFS_BRIDGE_" + token + "_"
+
+		vault := VaultInfo{
+			ChainID:      ChainIDEthereum,
+			Asset:        token,
+			TokenAddress: envOrDefault("", prefix+"TOKEN_ADDRESS"),
+			VaultAddress: envOrDefault("", prefix+"VAULT_ADDRESS"),
+			PriceSymbol:  envOrDefault("", prefix+"PRICE_SYMBOL"),
+		}
+		if vault.TokenAddress == "" || vault.VaultAddress == "" || vault.PriceSymbol == "" {
+			return nil, fmt.Errorf("bridge token %s requires %sTOKEN_ADDRESS, %sVAULT_ADDRESS and %sPRICE_SYMBOL", token, prefix, prefix, prefix)
+		}
+		decimals, err := strconv.ParseInt(envOrDefault("", prefix+"DECIMALS"), 10, 32)
+		if err != nil || decimals < 0 || decimals > 36 {
+			return nil, fmt.Errorf("invalid %sDECIMALS for bridge token %s", prefix, token)
+		}
+		vault.Decimals = int32(decimals)
+		vaults = append(vaults, vault)
+	}
+	return vaults, nil
+}
+
+// fromBaseUnits converts an on-chain integer amount to whole units of an
+// asset with the given decimals
+func fromBaseUnits(amount *big.Int, decimals int32) decimal.Decimal {
+	return decimal.NewFromBigInt(amount, -decimals)
+}
+
+// toBaseUnits converts whole units of an asset to its on-chain integer
+// amount, truncating below the smallest unit
+func toBaseUnits(amount decimal.Decimal, decimals int32) *big.Int {
+	return amount.Shift(decimals).BigInt()
+}
@@ -0,0 +1,493 @@
+package crosschain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AttestationSignPath is where operator nodes ask each other to sign a
+// checkpoint
+const AttestationSignPath = "/v1/crosschain/attestations/sign"
+
+// checkpointDigestDomain separates checkpoint digests from other messages
+// the operator keys sign
+const checkpointDigestDomain = "leafsii:checkpoint:v1"
+
+// defaultAttestationInterval is how often the collector asks peers for
+// signatures when LFS_ATTESTATION_INTERVAL is unset
+const defaultAttestationInterval = 15 * time.Second
+
+// ErrCheckpointMismatch is returned when asked to sign a checkpoint this
+// node does not hold
+var ErrCheckpointMismatch = errors.New("checkpoint does not match local state")
+
+// CheckpointAttestation is an operator's signature over the digest of a
+// checkpoint.
+type CheckpointAttestation struct {
+	UpdateID  uint64    `json:"updateId"`
+	Signer    string    `json:"signer"`    // Lower-case 0x address
+	Signature string    `json:"signature"` // 0x-prefixed r || s || v
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AttestationStatus reports the signatures collected for a checkpoint.
+type AttestationStatus struct {
+	UpdateID     uint64                   `json:"updateId"`
+	Digest       string                   `json:"digest"`
+	Threshold    int                      `json:"threshold"`
+	Signers      []string                 `json:"signers"` // Every configured signer
+	Attestations []*CheckpointAttestation `json:"attestations"`
+	Status       CheckpointStatus         `json:"status"`
+}
+
+// AttestationConfig lists the operators whose signatures verify
+// checkpoints. Checkpoints stay pending until Threshold of Signers have
+// signed their digest.
+type AttestationConfig struct {
+	Signers   []string  // Lower-case 0x addresses
+	Threshold int       // Defaults to a majority of Signers
+	Signer    EvmSigner // This node's key; nil when it does not sign
+	Peers     []string  // Base URLs of the other operator nodes
+	Client    *http.Client
+	Interval  time.Duration // How often pending checkpoints are collected
+}
+
+// NewAttestationConfigFromEnv reads the comma-separated signer addresses
+// of LFS_ATTESTATION_SIGNERS, LFS_ATTESTATION_THRESHOLD, this node's
+// LFS_ATTESTATION_PRIVATE_KEY and the peer URLs of LFS_ATTESTATION_PEERS.
+// It returns nil when no signers are set, which verifies checkpoints as
+// they are submitted.
+func NewAttestationConfigFromEnv() (*AttestationConfig, error) {
+	signers := splitList(envOrDefault("", "LFS_ATTESTATION_SIGNERS"))
+	if len(signers) == 0 {
+		return nil, nil
+	}
+
+	cfg := &AttestationConfig{
+		Signers: signers,
+		Peers:   splitList(envOrDefault("", "LFS_ATTESTATION_PEERS")),
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	if v := envOrDefault("", "LFS_ATTESTATION_THRESHOLD"); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LFS_ATTESTATION_THRESHOLD %q: %w", v, err)
+		}
+		cfg.Threshold = threshold
+	}
+	if v := envOrDefault("", "LFS_ATTESTATION_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LFS_ATTESTATION_INTERVAL %q: %w", v, err)
+		}
+		cfg.Interval = d
+	}
+	if key := envOrDefault("", "LFS_ATTESTATION_PRIVATE_KEY"); key != "" {
+		signer, err := NewPrivateKeySigner(key)
+		if err != nil {
+			return nil, fmt.Errorf("LFS_ATTESTATION_PRIVATE_KEY: %w", err)
+		}
+		cfg.Signer = signer
+	}
+	if err := cfg.normalize(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// normalize lower-cases the signers, fills in defaults and checks that
+// the threshold can be met
+func (c *AttestationConfig) normalize() error {
+	seen := make(map[string]bool, len(c.Signers))
+	signers := make([]string, 0, len(c.Signers))
+	for _, signer := range c.Signers {
+		signer = strings.ToLower(strings.TrimSpace(signer))
+		if !strings.HasPrefix(signer, "0x") || len(signer) != 42 {
+			return fmt.Errorf("invalid attestation signer %q", signer)
+		}
+		if !seen[signer] {
+			seen[signer] = true
+			signers = append(signers, signer)
+		}
+	}
+	c.Signers = signers
+
+	if c.Threshold == 0 {
+		c.Threshold = len(c.Signers)/2 + 1
+	}
+	if c.Threshold < 1 || c.Threshold > len(c.Signers) {
+		return fmt.Errorf("attestation threshold %d must be between 1 and %d", c.Threshold, len(c.Signers))
+	}
+	if c.Signer != nil && !seen[strings.ToLower(c.Signer.Address())] {
+		return fmt.Errorf("attestation key %s is not a configured signer", c.Signer.Address())
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultAttestationInterval
+	}
+	return nil
+}
+
+func (c *AttestationConfig) isSigner(address string) bool {
+	for _, signer := range c.Signers {
+		if signer == address {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// WithAttestation keeps submitted checkpoints pending until cfg.Threshold
+// of cfg.Signers have signed them. A nil cfg verifies checkpoints as they
+// are submitted.
+func WithAttestation(cfg *AttestationConfig) ServiceOption {
+	return func(s *Service) {
+		s.attestation = cfg
+	}
+}
+
+// CheckpointDigest returns the 32-byte digest operators sign to attest cp.
+// It covers the fields of CheckpointHash, so nodes that observed the same
+// chain state sign the same digest whatever their update IDs.
+func CheckpointDigest(cp WalrusCheckpoint) []byte {
+	hash, _ := hex.DecodeString(strings.TrimPrefix(CheckpointHash(cp), "0x"))
+	return keccak256([]byte(checkpointDigestDomain), hash)
+}
+
+// initialCheckpointStatus is the status of a new checkpoint
+func (s *Service) initialCheckpointStatus() CheckpointStatus {
+	if s.attestation != nil {
+		return CheckpointStatusPending
+	}
+	return CheckpointStatusVerified
+}
+
+// checkpointByIDLocked returns the checkpoint with updateID, or nil
+func (s *Service) checkpointByIDLocked(updateID uint64) *WalrusCheckpoint {
+	for _, cps := range s.checkpoints {
+		for _, cp := range cps {
+			if cp.UpdateID == updateID {
+				return cp
+			}
+		}
+	}
+	return nil
+}
+
+// GetAttestations returns the signatures collected for a checkpoint.
+func (s *Service) GetAttestations(_ context.Context, updateID uint64) (*AttestationStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cp := s.checkpointByIDLocked(updateID)
+	if cp == nil {
+		return nil, fmt.Errorf("%w: checkpoint %d", ErrNotFound, updateID)
+	}
+	return s.attestationStatusLocked(cp), nil
+}
+
+func (s *Service) attestationStatusLocked(cp *WalrusCheckpoint) *AttestationStatus {
+	status := &AttestationStatus{
+		UpdateID:     cp.UpdateID,
+		Digest:       "0x" + hex.EncodeToString(CheckpointDigest(*cp)),
+		Signers:      []string{},
+		Attestations: []*CheckpointAttestation{},
+		Status:       cp.Status,
+	}
+	if s.attestation != nil {
+		status.Threshold = s.attestation.Threshold
+		status.Signers = append(status.Signers, s.attestation.Signers...)
+	}
+	for _, att := range s.attestations[cp.UpdateID] {
+		copied := *att
+		status.Attestations = append(status.Attestations, &copied)
+	}
+	sort.Slice(status.Attestations, func(i, j int) bool {
+		return status.Attestations[i].Signer < status.Attestations[j].Signer
+	})
+	return status
+}
+
+// AddAttestation records a signature over the digest of a checkpoint. The
+// signer must be configured; a second signature of the same signer is
+// ignored. The checkpoint is verified once the threshold is met, which
+// finalizes the receipts it counts.
+func (s *Service) AddAttestation(ctx context.Context, updateID uint64, signature string) (*AttestationStatus, error) {
+	status, verified, err := s.addAttestation(ctx, updateID, signature)
+	if verified {
+		s.finalizeReceipts(ctx, updateID)
+	}
+	return status, err
+}
+
+// addAttestation records a signature like AddAttestation and reports
+// whether it verified the checkpoint
+func (s *Service) addAttestation(ctx context.Context, updateID uint64, signature string) (*AttestationStatus, bool, error) {
+	if s.attestation == nil {
+		return nil, false, fmt.Errorf("%w: checkpoint attestation is not configured", ErrInvalidRequest)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: signature must be hex", ErrInvalidRequest)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := s.checkpointByIDLocked(updateID)
+	if cp == nil {
+		return nil, false, fmt.Errorf("%w: checkpoint %d", ErrNotFound, updateID)
+	}
+	signer, err := recoverEvmAddress(CheckpointDigest(*cp), sig)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if !s.attestation.isSigner(signer) {
+		return nil, false, fmt.Errorf("%w: %s is not a checkpoint signer", ErrInvalidRequest, signer)
+	}
+	if _, ok := s.attestations[updateID][signer]; ok {
+		return s.attestationStatusLocked(cp), false, nil
+	}
+	if cp.Status == CheckpointStatusRejected {
+		return nil, false, fmt.Errorf("%w: checkpoint %d was rejected", ErrInvalidRequest, updateID)
+	}
+
+	att := &CheckpointAttestation{
+		UpdateID:  updateID,
+		Signer:    signer,
+		Signature: "0x" + hex.EncodeToString(sig),
+		CreatedAt: time.Now(),
+	}
+	var verified *WalrusCheckpoint
+	if cp.Status == CheckpointStatusPending && len(s.attestations[updateID])+1 >= s.attestation.Threshold {
+		next := *cp
+		next.Status = CheckpointStatusVerified
+		verified = &next
+	}
+
+	if s.store != nil {
+		if err := s.store.saveAttestation(ctx, att, verified); err != nil {
+			return nil, false, err
+		}
+	}
+	if s.attestations[updateID] == nil {
+		s.attestations[updateID] = make(map[string]*CheckpointAttestation)
+	}
+	s.attestations[updateID][signer] = att
+	if verified != nil {
+		cp.Status = CheckpointStatusVerified
+		s.logger.Infow("Checkpoint attested",
+			"walrusUpdateId", updateID,
+			"chainId", cp.ChainID,
+			"asset", cp.Asset,
+			"signatures", len(s.attestations[updateID]),
+		)
+	}
+	return s.attestationStatusLocked(cp), verified != nil, nil
+}
+
+// SignCheckpoint signs cp with this node's key when the node holds a
+// checkpoint of the same state, and returns ErrCheckpointMismatch when it
+// does not. cp may come from another node, whose update IDs differ.
+func (s *Service) SignCheckpoint(ctx context.Context, cp WalrusCheckpoint) (*CheckpointAttestation, error) {
+	if s.attestation == nil || s.attestation.Signer == nil {
+		return nil, fmt.Errorf("%w: this node does not sign checkpoints", ErrInvalidRequest)
+	}
+
+	hash := CheckpointHash(cp)
+	s.mu.RLock()
+	matched := false
+	for _, local := range s.checkpoints[s.mapKey(cp.ChainID, cp.Asset)] {
+		if local.Status != CheckpointStatusRejected && CheckpointHash(*local) == hash {
+			matched = true
+			break
+		}
+	}
+	s.mu.RUnlock()
+	if !matched {
+		return nil, fmt.Errorf("%w: %s:%s at block %d", ErrCheckpointMismatch, cp.ChainID, cp.Asset, cp.BlockNumber)
+	}
+
+	sig, err := s.attestation.Signer.SignDigest(ctx, CheckpointDigest(cp))
+	if err != nil {
+		return nil, fmt.Errorf("sign checkpoint: %w", err)
+	}
+	return &CheckpointAttestation{
+		UpdateID:  cp.UpdateID,
+		Signer:    strings.ToLower(s.attestation.Signer.Address()),
+		Signature: "0x" + hex.EncodeToString(sig),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// pendingAttestations returns the pending checkpoints and the signers
+// that attested each
+func (s *Service) pendingAttestations() ([]WalrusCheckpoint, []map[string]bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		pending []WalrusCheckpoint
+		signed  []map[string]bool
+	)
+	for _, cps := range s.checkpoints {
+		for _, cp := range cps {
+			if cp.Status != CheckpointStatusPending {
+				continue
+			}
+			signers := make(map[string]bool)
+			for signer := range s.attestations[cp.UpdateID] {
+				signers[signer] = true
+			}
+			pending = append(pending, *cp)
+			signed = append(signed, signers)
+		}
+	}
+	return pending, signed
+}
+
+// AttestationCollector signs pending checkpoints with this node's key and
+// asks the peer operator nodes for their signatures until each reaches
+// the threshold.
+type AttestationCollector struct {
+	svc    *Service
+	logger *zap.SugaredLogger
+}
+
+// NewAttestationCollector returns a collector for svc, or nil when svc
+// was created without WithAttestation.
+func NewAttestationCollector(svc *Service, logger *zap.SugaredLogger) *AttestationCollector {
+	if svc.attestation == nil {
+		return nil
+	}
+	return &AttestationCollector{svc: svc, logger: logger}
+}
+
+// Interval returns the time between collector runs.
+func (c *AttestationCollector) Interval() time.Duration {
+	return c.svc.attestation.Interval
+}
+
+// Start runs the collector until ctx is done.
+func (c *AttestationCollector) Start(ctx context.Context) {
+	cfg := c.svc.attestation
+	c.logger.Infow("Attestation collector starting",
+		"threshold", cfg.Threshold,
+		"signers", len(cfg.Signers),
+		"peers", len(cfg.Peers),
+		"interval", cfg.Interval,
+	)
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				c.logger.Infow("Attestation collector stopped")
+				return
+			case <-ticker.C:
+				c.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce collects signatures for every pending checkpoint.
+func (c *AttestationCollector) RunOnce(ctx context.Context) {
+	cfg := c.svc.attestation
+	pending, signed := c.svc.pendingAttestations()
+	for i, cp := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		if cfg.Signer != nil && !signed[i][strings.ToLower(cfg.Signer.Address())] {
+			att, err := c.svc.SignCheckpoint(ctx, cp)
+			if err == nil {
+				_, err = c.svc.AddAttestation(ctx, cp.UpdateID, att.Signature)
+			}
+			if err != nil {
+				c.logger.Errorw("Checkpoint signing failed", "walrusUpdateId", cp.UpdateID, "error", err)
+				continue
+			}
+		}
+
+		for _, peer := range cfg.Peers {
+			status, err := c.svc.GetAttestations(ctx, cp.UpdateID)
+			if err != nil || status.Status != CheckpointStatusPending {
+				break
+			}
+			signer, signature, err := c.requestSignature(ctx, peer, cp)
+			if err == nil && !signed[i][signer] {
+				_, err = c.svc.AddAttestation(ctx, cp.UpdateID, signature)
+			}
+			if err != nil {
+				c.logger.Warnw("Peer attestation failed",
+					"peer", peer,
+					"walrusUpdateId", cp.UpdateID,
+					"chainId", cp.ChainID,
+					"asset", cp.Asset,
+					"error", err,
+				)
+			}
+		}
+	}
+}
+
+// requestSignature asks peer to sign cp and returns the signer it claims
+// and the signature
+func (c *AttestationCollector) requestSignature(ctx context.Context, peer string, cp WalrusCheckpoint) (string, string, error) {
+	client := c.svc.attestation.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, err := json.Marshal(cp)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal checkpoint: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(peer, "/")+AttestationSignPath, bytes.NewReader(body))
+	if err != nil {
+		return "", "", fmt.Errorf("build attestation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("attestation request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", "", fmt.Errorf("attestation request: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var att struct {
+		Signer    string `json:"signer"`
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&att); err != nil {
+		return "", "", fmt.Errorf("decode attestation: %w", err)
+	}
+	return strings.ToLower(att.Signer), att.Signature, nil
+}
@@ -0,0 +1,150 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func mustSigner(t *testing.T, key string) *PrivateKeySigner {
+	t.Helper()
+	signer, err := NewPrivateKeySigner(key)
+	if err != nil {
+		t.Fatalf("NewPrivateKeySigner failed: %v", err)
+	}
+	return signer
+}
+
+func TestCheckpointAttestationThreshold(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+
+	alice := mustSigner(t, "0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	bob := mustSigner(t, "0x59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d")
+	mallory := mustSigner(t, "0x5de4111afa1a4b94908f83103eb1f1706367c2e68ca870fc3fb9a804cdab365a")
+	signers := []string{alice.Address(), bob.Address()}
+
+	// Bob's node answers signature requests over HTTP
+	peer := NewService(logger, WithAttestation(&AttestationConfig{Signers: signers, Threshold: 2, Signer: bob}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cp WalrusCheckpoint
+		if r.URL.Path != AttestationSignPath || json.NewDecoder(r.Body).Decode(&cp) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		att, err := peer.SignCheckpoint(r.Context(), cp)
+		if errors.Is(err, ErrCheckpointMismatch) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(att)
+	}))
+	defer srv.Close()
+
+	cfg := &AttestationConfig{Signers: signers, Threshold: 2, Signer: alice, Peers: []string{srv.URL}}
+	if err := cfg.normalize(); err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	svc, database := newTestService(t, WithAttestation(cfg))
+	if err := svc.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	collector := NewAttestationCollector(svc, logger)
+
+	seeded, err := svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+	if err != nil {
+		t.Fatalf("GetLatestCheckpoint failed: %v", err)
+	}
+	next := *seeded
+	next.BlockNumber++
+	next.TotalShares = next.TotalShares.Add(decimal.RequireFromString("1"))
+
+	// Both nodes observed the same state
+	cp, err := svc.SubmitCheckpoint(ctx, next)
+	if err != nil {
+		t.Fatalf("SubmitCheckpoint failed: %v", err)
+	}
+	if cp.Status != CheckpointStatusPending {
+		t.Fatalf("Expected a pending checkpoint, got %s", cp.Status)
+	}
+	if _, err := peer.SubmitCheckpoint(ctx, next); err != nil {
+		t.Fatalf("SubmitCheckpoint on peer failed: %v", err)
+	}
+
+	// Signatures of unknown keys are refused
+	sig, err := mallory.SignDigest(ctx, CheckpointDigest(*cp))
+	if err != nil {
+		t.Fatalf("SignDigest failed: %v", err)
+	}
+	if _, err := svc.AddAttestation(ctx, cp.UpdateID, "0x"+hex.EncodeToString(sig)); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Expected an unknown signer to be rejected, got %v", err)
+	}
+
+	collector.RunOnce(ctx)
+	status, err := svc.GetAttestations(ctx, cp.UpdateID)
+	if err != nil {
+		t.Fatalf("GetAttestations failed: %v", err)
+	}
+	if status.Status != CheckpointStatusVerified || len(status.Attestations) != 2 {
+		t.Fatalf("Expected two signatures to verify the checkpoint, got %+v", status)
+	}
+
+	// A checkpoint the peer does not hold keeps only the local signature
+	diverged := next
+	diverged.BlockNumber++
+	lonely, err := svc.SubmitCheckpoint(ctx, diverged)
+	if err != nil {
+		t.Fatalf("SubmitCheckpoint failed: %v", err)
+	}
+	collector.RunOnce(ctx)
+	if status, _ = svc.GetAttestations(ctx, lonely.UpdateID); status.Status != CheckpointStatusPending || len(status.Attestations) != 1 {
+		t.Errorf("Expected a mismatched checkpoint to stay pending, got %+v", status)
+	}
+
+	// Attestations survive a restart
+	restarted := NewService(logger, WithDatabase(database), WithAttestation(cfg))
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if status, _ = restarted.GetAttestations(ctx, cp.UpdateID); status.Status != CheckpointStatusVerified || len(status.Attestations) != 2 {
+		t.Errorf("Expected the verified checkpoint to be restored, got %+v", status)
+	}
+	if status, _ = restarted.GetAttestations(ctx, lonely.UpdateID); status.Status != CheckpointStatusPending || len(status.Attestations) != 1 {
+		t.Errorf("Expected the pending checkpoint to be restored, got %+v", status)
+	}
+}
+
+func TestNewAttestationConfigFromEnv(t *testing.T) {
+	t.Setenv("LFS_ATTESTATION_SIGNERS", "")
+	if cfg, err := NewAttestationConfigFromEnv(); err != nil || cfg != nil {
+		t.Errorf("Expected no signers to disable attestation, got %+v (%v)", cfg, err)
+	}
+
+	t.Setenv("LFS_ATTESTATION_SIGNERS", "0xF39FD6E51AAD88F6F4CE6AB8827279CFFFB92266, 0x70997970c51812dc3a010c7d01b50e0d17dc79c8,0x3c44cdddb6a900fa2b585dd299e03d12fa4293bc")
+	t.Setenv("LFS_ATTESTATION_THRESHOLD", "")
+	cfg, err := NewAttestationConfigFromEnv()
+	if err != nil || cfg.Threshold != 2 || cfg.Signers[0] != "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266" {
+		t.Errorf("Expected a majority threshold of lower-cased signers, got %+v (%v)", cfg, err)
+	}
+
+	t.Setenv("LFS_ATTESTATION_THRESHOLD", "4")
+	if _, err := NewAttestationConfigFromEnv(); err == nil {
+		t.Error("Expected a threshold above the signer count to fail")
+	}
+
+	t.Setenv("LFS_ATTESTATION_THRESHOLD", "")
+	t.Setenv("LFS_ATTESTATION_PRIVATE_KEY", "0x8b3a350cf5c34c9194ca85829a2df0ec3153be0318b5e2d3348e872092edffba")
+	if _, err := NewAttestationConfigFromEnv(); err == nil {
+		t.Error("Expected a key outside the signers to fail")
+	}
+}
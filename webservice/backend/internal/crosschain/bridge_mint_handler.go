@@ -89,6 +89,57 @@ func NewSuiBridgeMinterFromEnv(logger *zap.SugaredLogger) (*SuiBridgeMinter, err
 }
 
 func (m *SuiBridgeMinter) Mint(ctx context.Context, payload BridgeMintContext) (*MintResult, error) {
+	calls, err := m.mintCalls(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := []string{}
+	for _, call := range calls {
+		if digest, err := m.executeMints(ctx, []bridgeMintCall{call}); err != nil {
+			return nil, fmt.Errorf("%s mint: %w", call.module, err)
+		} else if digest != "" {
+			digests = append(digests, digest)
+		}
+	}
+
+	return &MintResult{TxDigests: digests}, nil
+}
+
+// MintBatch mints several deposits in one programmable transaction, with a
+// bridge_mint call per token of each deposit. Every deposit gets the digest
+// of that transaction; if it fails, none is minted.
+func (m *SuiBridgeMinter) MintBatch(ctx context.Context, payloads []BridgeMintContext) ([]*MintResult, error) {
+	var calls []bridgeMintCall
+	for _, payload := range payloads {
+		deposit, err := m.mintCalls(payload)
+		if err != nil {
+			return nil, fmt.Errorf("deposit %s: %w", payload.Submission.TxHash, err)
+		}
+		calls = append(calls, deposit...)
+	}
+
+	digest, err := m.executeMints(ctx, calls)
+	if err != nil {
+		return nil, fmt.Errorf("batch mint: %w", err)
+	}
+
+	results := make([]*MintResult, len(payloads))
+	for i := range results {
+		results[i] = &MintResult{TxDigests: []string{digest}}
+	}
+	return results, nil
+}
+
+// bridgeMintCall is one bridge_mint call of the ftoken or xtoken module
+type bridgeMintCall struct {
+	module    string
+	amount    uint64
+	recipient sui.Address
+}
+
+// mintCalls returns the bridge_mint calls minting a deposit
+func (m *SuiBridgeMinter) mintCalls(payload BridgeMintContext) ([]bridgeMintCall, error) {
 	recipient, err := sui.AddressFromHex(payload.Submission.SuiOwner)
 	if err != nil {
 		return nil, fmt.Errorf("invalid Sui owner: %w", err)
@@ -107,55 +158,76 @@ func (m *SuiBridgeMinter) Mint(ctx context.Context, payload BridgeMintContext) (
 		return nil, fmt.Errorf("derived zero mint amount from %s", payload.NewShares.String())
 	}
 
-	fPkg := parsePkg(m.cfg.fTokenType)
-	xPkg := parsePkg(m.cfg.xTokenType)
-	if fPkg == "" || xPkg == "" {
-		return nil, fmt.Errorf("unable to parse package ids from f/x token types")
-	}
-
-	digests := []string{}
-
+	var calls []bridgeMintCall
 	if mintF > 0 {
-		if digest, err := m.mintPackage(ctx, fPkg, "ftoken", m.cfg.fTreasuryCap, m.cfg.fMintAuth, mintF, *recipient); err != nil {
-			return nil, fmt.Errorf("ftoken mint: %w", err)
-		} else if digest != "" {
-			digests = append(digests, digest)
-		}
+		calls = append(calls, bridgeMintCall{module: "ftoken", amount: mintF, recipient: *recipient})
 	}
 	if mintX > 0 {
-		if digest, err := m.mintPackage(ctx, xPkg, "xtoken", m.cfg.xTreasuryCap, m.cfg.xMintAuth, mintX, *recipient); err != nil {
-			return nil, fmt.Errorf("xtoken mint: %w", err)
-		} else if digest != "" {
-			digests = append(digests, digest)
-		}
+		calls = append(calls, bridgeMintCall{module: "xtoken", amount: mintX, recipient: *recipient})
 	}
-
-	return &MintResult{TxDigests: digests}, nil
+	return calls, nil
 }
 
-func (m *SuiBridgeMinter) mintPackage(ctx context.Context, pkgHex, module, treasuryCap, authority string, amount uint64, recipient sui.Address) (string, error) {
-	txCtx, cancel := context.WithTimeout(ctx, 40*time.Second)
-	defer cancel()
+// bridgeMintTarget is the package and objects a token module mints with
+type bridgeMintTarget struct {
+	pkg       *sui.PackageId
+	treasury  *sui.ObjectRef
+	authority suiptb.ObjectArg
+}
 
-	pkg := sui.MustPackageIdFromHex(pkgHex)
+// mintTarget loads the treasury cap and mint authority of module
+func (m *SuiBridgeMinter) mintTarget(ctx context.Context, module string) (*bridgeMintTarget, error) {
+	coinType, treasuryCap, authority := m.cfg.fTokenType, m.cfg.fTreasuryCap, m.cfg.fMintAuth
+	if module == "xtoken" {
+		coinType, treasuryCap, authority = m.cfg.xTokenType, m.cfg.xTreasuryCap, m.cfg.xMintAuth
+	}
+	pkgHex := parsePkg(coinType)
+	if pkgHex == "" {
+		return nil, fmt.Errorf("unable to parse package id from %s", coinType)
+	}
 
-	treasuryObj, err := m.client.GetObject(txCtx, &suiclient.GetObjectRequest{
+	treasuryObj, err := m.client.GetObject(ctx, &suiclient.GetObjectRequest{
 		ObjectId: sui.MustObjectIdFromHex(treasuryCap),
 		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
 	})
 	if err != nil {
-		return "", fmt.Errorf("fetch treasury cap: %w", err)
+		return nil, fmt.Errorf("fetch treasury cap: %w", err)
 	}
 	if treasuryObj == nil || treasuryObj.Data == nil || treasuryObj.Data.Ref() == nil {
-		return "", fmt.Errorf("treasury cap not found: %s", treasuryCap)
+		return nil, fmt.Errorf("treasury cap not found: %s", treasuryCap)
 	}
 	ownerAddr := ownedAddress(treasuryObj.Data.Owner)
 	if ownerAddr == nil || m.signer.Address == nil || *ownerAddr != *m.signer.Address {
-		return "", fmt.Errorf("treasury cap must be owned by signer %s", m.signer.Address.String())
+		return nil, fmt.Errorf("treasury cap must be owned by signer %s", m.signer.Address.String())
 	}
-	authArg, err := m.sharedArg(txCtx, authority, false)
+	authArg, err := m.sharedArg(ctx, authority, false)
 	if err != nil {
-		return "", fmt.Errorf("authority shared ref: %w", err)
+		return nil, fmt.Errorf("authority shared ref: %w", err)
+	}
+
+	return &bridgeMintTarget{
+		pkg:       sui.MustPackageIdFromHex(pkgHex),
+		treasury:  treasuryObj.Data.Ref(),
+		authority: authArg,
+	}, nil
+}
+
+// executeMints runs calls in one programmable transaction and returns its
+// digest. The treasury cap and authority of a module are shared by its calls.
+func (m *SuiBridgeMinter) executeMints(ctx context.Context, calls []bridgeMintCall) (string, error) {
+	txCtx, cancel := context.WithTimeout(ctx, 40*time.Second)
+	defer cancel()
+
+	targets := map[string]*bridgeMintTarget{}
+	for _, call := range calls {
+		if _, ok := targets[call.module]; ok {
+			continue
+		}
+		target, err := m.mintTarget(txCtx, call.module)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", call.module, err)
+		}
+		targets[call.module] = target
 	}
 
 	coins, err := m.client.GetCoins(txCtx, &suiclient.GetCoinsRequest{Owner: m.signer.Address})
@@ -167,26 +239,31 @@ func (m *SuiBridgeMinter) mintPackage(ctx context.Context, pkgHex, module, treas
 	}
 
 	ptb := suiptb.NewTransactionDataTransactionBuilder()
-	ptb.Command(suiptb.Command{
-		MoveCall: &suiptb.ProgrammableMoveCall{
-			Package:  pkg,
-			Module:   module,
-			Function: "bridge_mint",
-			Arguments: []suiptb.Argument{
-				ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: treasuryObj.Data.Ref()}),
-				ptb.MustObj(authArg),
-				ptb.MustPure(amount),
-				ptb.MustPure(recipient),
+	for _, call := range calls {
+		target := targets[call.module]
+		ptb.Command(suiptb.Command{
+			MoveCall: &suiptb.ProgrammableMoveCall{
+				Package:  target.pkg,
+				Module:   call.module,
+				Function: "bridge_mint",
+				Arguments: []suiptb.Argument{
+					ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: target.treasury}),
+					ptb.MustObj(target.authority),
+					ptb.MustPure(call.amount),
+					ptb.MustPure(call.recipient),
+				},
 			},
-		},
-	})
+		})
+	}
 
+	// Each call past the first adds to the budget of a single mint
+	gasBudget := (9 + uint64(len(calls))) * suiclient.DefaultGasBudget
 	pt := ptb.Finish()
 	tx := suiptb.NewTransactionData(
 		m.signer.Address,
 		pt,
 		[]*sui.ObjectRef{coins.Data[0].Ref()},
-		10*suiclient.DefaultGasBudget,
+		gasBudget,
 		suiclient.DefaultGasPrice,
 	)
 
@@ -208,12 +285,14 @@ func (m *SuiBridgeMinter) mintPackage(ctx context.Context, pkgHex, module, treas
 		return "", fmt.Errorf("bridge mint transaction failed: %v", resp.Errors)
 	}
 
-	m.logger.Infow("Bridge mint succeeded",
-		"module", module,
-		"digest", resp.Digest,
-		"recipient", recipient.String(),
-		"amount", amount,
-	)
+	for _, call := range calls {
+		m.logger.Infow("Bridge mint succeeded",
+			"module", call.module,
+			"digest", resp.Digest,
+			"recipient", call.recipient.String(),
+			"amount", call.amount,
+		)
+	}
 
 	return resp.Digest.String(), nil
 }
@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pattonkan/sui-go/sui"
 	suiclient "github.com/pattonkan/sui-go/suiclient"
@@ -21,16 +23,60 @@ type RedeemListener interface {
 	Start(ctx context.Context, handle func(context.Context, RedeemSubmission)) error
 }
 
-// SuiBridgeRedeemListener subscribes to BridgeRedeemEvent events for f/x tokens.
+const (
+	// defaultRedeemPollInterval is the time between queryEvents polls
+	defaultRedeemPollInterval = 10 * time.Second
+
+	// redeemPollPageSize is the number of events read per queryEvents call
+	redeemPollPageSize = 50
+
+	// redeemSeenLimit bounds the event IDs remembered for deduplication
+	redeemSeenLimit = 4096
+)
+
+// suiEventSource is the part of the Sui client the redeem listener uses
+type suiEventSource interface {
+	QueryEvents(ctx context.Context, req *suiclient.QueryEventsRequest) (*suiclient.EventPage, error)
+	SubscribeEvent(ctx context.Context, filter *suiclient.EventFilter, resultCh chan suiclient.Event) error
+}
+
+// SuiBridgeRedeemListener follows the BridgeRedeemEvent events of the f/x
+// tokens. Events arrive over the Sui websocket when one is connected, and
+// suix_queryEvents is polled in any case, so that events the subscription
+// misses or delivers while it is down are still handled. Events are
+// deduplicated by their ID, the transaction digest and event sequence.
+// Only events emitted after Start are handled.
 type SuiBridgeRedeemListener struct {
-	client     *suiclient.ClientImpl
-	fEventType *sui.StructTag
-	xEventType *sui.StructTag
-	logger     *zap.SugaredLogger
+	client       suiEventSource
+	websocket    bool // Whether client has a websocket connection
+	fEventType   *sui.StructTag
+	xEventType   *sui.StructTag
+	pollInterval time.Duration
+	logger       *zap.SugaredLogger
+
+	mu      sync.Mutex
+	cursors map[string]*suiclient.EventId // By event type
+	seen    map[string]bool               // By event ID
+	order   []string                      // Event IDs in seen, oldest first
+}
+
+func newSuiBridgeRedeemListener(client suiEventSource, websocket bool, fEvent, xEvent *sui.StructTag, logger *zap.SugaredLogger) *SuiBridgeRedeemListener {
+	return &SuiBridgeRedeemListener{
+		client:       client,
+		websocket:    websocket,
+		fEventType:   fEvent,
+		xEventType:   xEvent,
+		pollInterval: defaultRedeemPollInterval,
+		logger:       logger,
+		cursors:      make(map[string]*suiclient.EventId),
+		seen:         make(map[string]bool),
+	}
 }
 
 // NewSuiBridgeRedeemListenerFromEnv enables the listener when LFS_ENABLE_BRIDGE_REDEEM=1
-// and the required Sui env vars are present.
+// and the required Sui env vars are present. The websocket is LFS_SUI_WS_URL,
+// or derived from LFS_SUI_RPC_URL; without one the listener only polls, every
+// LFS_SUI_REDEEM_POLL_INTERVAL (default 10s).
 func NewSuiBridgeRedeemListenerFromEnv(logger *zap.SugaredLogger) (*SuiBridgeRedeemListener, error) {
 	if !isTruthy(strings.TrimSpace(os.Getenv("LFS_ENABLE_BRIDGE_REDEEM"))) {
 		return nil, nil
@@ -58,33 +104,45 @@ func NewSuiBridgeRedeemListenerFromEnv(logger *zap.SugaredLogger) (*SuiBridgeRed
 		return nil, fmt.Errorf("parse xToken redeem event type: %w", err)
 	}
 
+	pollInterval := defaultRedeemPollInterval
+	if v := envOrDefault("", "LFS_SUI_REDEEM_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid LFS_SUI_REDEEM_POLL_INTERVAL %q", v)
+		}
+		pollInterval = d
+	}
+
 	client := suiclient.NewClient(rpc)
 	wsURL := strings.TrimSpace(os.Getenv("LFS_SUI_WS_URL"))
 	if wsURL == "" {
 		wsURL = inferSuiWebsocketURL(rpc, os.Getenv("LFS_NETWORK"))
 	}
-	if wsURL == "" {
-		return nil, fmt.Errorf("redeem listener enabled but missing websocket URL; set LFS_SUI_WS_URL or use a supported LFS_SUI_RPC_URL")
-	}
-	if err := initSuiWebsocket(client, wsURL); err != nil {
-		return nil, err
+	websocket := false
+	if wsURL != "" {
+		if err := initSuiWebsocket(client, wsURL); err != nil {
+			logger.Warnw("Sui websocket unavailable; bridge redeems are polled only", "error", err)
+		} else {
+			websocket = true
+		}
 	}
 	logger.Infow("Bridge redeem listener enabled",
 		"suiRpc", rpc,
 		"suiWs", wsURL,
+		"websocket", websocket,
+		"pollInterval", pollInterval,
 		"fEventType", fEvent.String(),
 		"xEventType", xEvent.String(),
 	)
 
-	return &SuiBridgeRedeemListener{
-		client:     client,
-		fEventType: fEvent,
-		xEventType: xEvent,
-		logger:     logger,
-	}, nil
+	l := newSuiBridgeRedeemListener(client, websocket, fEvent, xEvent, logger)
+	l.pollInterval = pollInterval
+	return l, nil
 }
 
-// Start subscribes to both f/x BridgeRedeemEvent streams.
+// Start subscribes to both f/x BridgeRedeemEvent streams and starts
+// polling. Polling resumes after the latest event of each type at the
+// time of the call.
 func (l *SuiBridgeRedeemListener) Start(ctx context.Context, handle func(context.Context, RedeemSubmission)) error {
 	if l == nil || handle == nil {
 		return nil
@@ -93,25 +151,129 @@ func (l *SuiBridgeRedeemListener) Start(ctx context.Context, handle func(context
 		return fmt.Errorf("redeem listener missing sui client")
 	}
 
-	filter := l.eventFilter()
-	resultCh := make(chan suiclient.Event, 32)
-	if err := l.client.SubscribeEvent(ctx, filter, resultCh); err != nil {
-		return fmt.Errorf("subscribe event: %w", err)
+	for _, eventType := range l.eventTypes() {
+		cursor, err := l.latestEvent(ctx, eventType)
+		if err != nil {
+			return fmt.Errorf("query latest %s: %w", eventType, err)
+		}
+		l.cursors[eventType.String()] = cursor
+	}
+
+	if l.websocket {
+		resultCh := make(chan suiclient.Event, 32)
+		if err := l.client.SubscribeEvent(ctx, l.eventFilter(), resultCh); err != nil {
+			l.logger.Warnw("Bridge redeem subscription failed; polling only", "error", err)
+		} else {
+			go func() {
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case evt := <-resultCh:
+						l.processEvent(ctx, evt, handle)
+					}
+				}
+			}()
+		}
 	}
 
 	go func() {
+		ticker := time.NewTicker(l.pollInterval)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case evt := <-resultCh:
-				l.processEvent(ctx, evt, handle)
+			case <-ticker.C:
+				if err := l.poll(ctx, handle); err != nil && ctx.Err() == nil {
+					l.logger.Warnw("Bridge redeem poll failed; retrying", "error", err)
+				}
 			}
 		}
 	}()
 	return nil
 }
 
+func (l *SuiBridgeRedeemListener) eventTypes() []*sui.StructTag {
+	var types []*sui.StructTag
+	for _, t := range []*sui.StructTag{l.fEventType, l.xEventType} {
+		if t != nil {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// latestEvent returns the ID of the newest event of eventType, or nil
+// when there is none
+func (l *SuiBridgeRedeemListener) latestEvent(ctx context.Context, eventType *sui.StructTag) (*suiclient.EventId, error) {
+	limit := uint(1)
+	page, err := l.client.QueryEvents(ctx, &suiclient.QueryEventsRequest{
+		Query:           &suiclient.EventFilter{MoveEventType: eventType},
+		Limit:           &limit,
+		DescendingOrder: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Data) == 0 {
+		return nil, nil
+	}
+	id := page.Data[0].Id
+	return &id, nil
+}
+
+// poll handles the events of each type after its cursor. Types are queried
+// one by one, as full nodes do not accept Any filters for queryEvents.
+func (l *SuiBridgeRedeemListener) poll(ctx context.Context, handle func(context.Context, RedeemSubmission)) error {
+	limit := uint(redeemPollPageSize)
+	for _, eventType := range l.eventTypes() {
+		key := eventType.String()
+		for {
+			page, err := l.client.QueryEvents(ctx, &suiclient.QueryEventsRequest{
+				Query:  &suiclient.EventFilter{MoveEventType: eventType},
+				Cursor: l.cursors[key],
+				Limit:  &limit,
+			})
+			if err != nil {
+				return fmt.Errorf("query %s: %w", key, err)
+			}
+			for _, evt := range page.Data {
+				l.processEvent(ctx, evt, handle)
+			}
+			if page.NextCursor != nil {
+				l.cursors[key] = page.NextCursor
+			}
+			if !page.HasNextPage || len(page.Data) == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// markSeen records the event ID and reports whether it is new
+func (l *SuiBridgeRedeemListener) markSeen(evt suiclient.Event) bool {
+	seq := "0"
+	if evt.Id.EventSeq != nil && evt.Id.EventSeq.Int != nil {
+		seq = evt.Id.EventSeq.String()
+	}
+	id := evt.Id.TxDigest.String() + ":" + seq
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen[id] {
+		return false
+	}
+	l.seen[id] = true
+	l.order = append(l.order, id)
+	if len(l.order) > redeemSeenLimit {
+		delete(l.seen, l.order[0])
+		l.order = l.order[1:]
+	}
+	return true
+}
+
 func (l *SuiBridgeRedeemListener) eventFilter() *suiclient.EventFilter {
 	all := []suiclient.EventFilter{}
 	if l.fEventType != nil {
@@ -132,6 +294,9 @@ func (l *SuiBridgeRedeemListener) processEvent(ctx context.Context, evt suiclien
 		l.logger.Debugw("Ignoring event from unknown module", "eventType", evt.Type)
 		return
 	}
+	if !l.markSeen(evt) {
+		return
+	}
 
 	// ParsedJson should hold {redeemer, eth_recipient, amount}
 	var payload map[string]any
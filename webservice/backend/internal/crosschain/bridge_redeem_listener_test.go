@@ -0,0 +1,126 @@
+package crosschain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// fakeSuiEvents serves queryEvents from a list of events per type
+type fakeSuiEvents struct {
+	events map[string][]suiclient.Event // By event type, oldest first
+}
+
+func (f *fakeSuiEvents) QueryEvents(_ context.Context, req *suiclient.QueryEventsRequest) (*suiclient.EventPage, error) {
+	events := f.events[req.Query.MoveEventType.String()]
+	if req.DescendingOrder {
+		page := &suiclient.EventPage{}
+		if len(events) > 0 {
+			page.Data = []suiclient.Event{events[len(events)-1]}
+		}
+		return page, nil
+	}
+
+	start := 0
+	if req.Cursor != nil {
+		for i, evt := range events {
+			if evt.Id.TxDigest.String() == req.Cursor.TxDigest.String() && evt.Id.EventSeq.Cmp(req.Cursor.EventSeq.Int) == 0 {
+				start = i + 1
+			}
+		}
+	}
+	end := start + int(*req.Limit)
+	if end > len(events) {
+		end = len(events)
+	}
+	page := &suiclient.EventPage{Data: events[start:end], HasNextPage: end < len(events)}
+	if end > start {
+		page.NextCursor = &events[end-1].Id
+	}
+	return page, nil
+}
+
+func (f *fakeSuiEvents) SubscribeEvent(context.Context, *suiclient.EventFilter, chan suiclient.Event) error {
+	return nil
+}
+
+func redeemEvent(t *testing.T, eventType *sui.StructTag, digest string, seq uint64, amount string) suiclient.Event {
+	t.Helper()
+	return suiclient.Event{
+		Id:   suiclient.EventId{TxDigest: *sui.MustNewDigest(digest), EventSeq: sui.NewBigInt(seq)},
+		Type: eventType,
+		ParsedJson: map[string]any{
+			"redeemer":      "0xabc",
+			"eth_recipient": []any{float64(0xde), float64(0xad)},
+			"amount":        amount,
+		},
+	}
+}
+
+func TestSuiBridgeRedeemListenerPollsAndDedupes(t *testing.T) {
+	ctx := context.Background()
+	fEvent, err := sui.StructTagFromString("0x2::ftoken::BridgeRedeemEvent")
+	if err != nil {
+		t.Fatalf("StructTagFromString failed: %v", err)
+	}
+	xEvent, err := sui.StructTagFromString("0x2::xtoken::BridgeRedeemEvent")
+	if err != nil {
+		t.Fatalf("StructTagFromString failed: %v", err)
+	}
+
+	old := redeemEvent(t, fEvent, "11111111111111111111111111111111", 0, "1000000000")
+	source := &fakeSuiEvents{events: map[string][]suiclient.Event{fEvent.String(): {old}}}
+	listener := newSuiBridgeRedeemListener(source, false, fEvent, xEvent, zap.NewNop().Sugar())
+
+	var got []RedeemSubmission
+	handle := func(_ context.Context, sub RedeemSubmission) { got = append(got, sub) }
+	listener.cursors[fEvent.String()], _ = listener.latestEvent(ctx, fEvent)
+	listener.cursors[xEvent.String()], _ = listener.latestEvent(ctx, xEvent)
+
+	// Events from before the start are not replayed
+	if err := listener.poll(ctx, handle); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("Expected no redeems, got %+v", got)
+	}
+
+	// New events of both types are handled, across pages
+	fresh := []suiclient.Event{
+		redeemEvent(t, fEvent, "22222222222222222222222222222222", 0, "2500000000"),
+		redeemEvent(t, fEvent, "22222222222222222222222222222222", 1, "500000000"),
+	}
+	for i := 0; i < redeemPollPageSize; i++ {
+		fresh = append(fresh, redeemEvent(t, fEvent, "33333333333333333333333333333333", uint64(i), "1"))
+	}
+	source.events[fEvent.String()] = append(source.events[fEvent.String()], fresh...)
+	source.events[xEvent.String()] = []suiclient.Event{redeemEvent(t, xEvent, "44444444444444444444444444444444", 0, "7000000000")}
+
+	// One arrives over the websocket first and is not handled twice
+	listener.processEvent(ctx, fresh[0], handle)
+	if err := listener.poll(ctx, handle); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(got) != len(fresh)+1 {
+		t.Fatalf("Expected %d redeems, got %d", len(fresh)+1, len(got))
+	}
+	first, last := got[0], got[len(got)-1]
+	if first.Token != "f" || !first.Amount.Equal(decimal.RequireFromString("2.5")) || first.EthRecipient != "0xdead" || first.SuiOwner != "0xabc" {
+		t.Errorf("Unexpected fToken redeem: %+v", first)
+	}
+	if last.Token != "x" || !last.Amount.Equal(decimal.RequireFromString("7")) || last.ChainID != ChainIDEthereum {
+		t.Errorf("Unexpected xToken redeem: %+v", last)
+	}
+
+	// Polling again finds nothing new
+	if err := listener.poll(ctx, handle); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(got) != len(fresh)+1 {
+		t.Errorf("Expected no more redeems, got %d", len(got))
+	}
+}
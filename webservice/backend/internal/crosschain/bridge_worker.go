@@ -1,18 +1,15 @@
 package crosschain
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"net/url"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"time"
 
 	"github.com/leafsii/leafsii-backend/internal/prices/binance"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
@@ -20,22 +17,42 @@ import (
 // DepositSubmission represents a user-submitted EVM deposit that should be bridged to Sui.
 type DepositSubmission struct {
 	TxHash   string
+	LogIndex uint64 // Position of the deposit event in the transaction
 	SuiOwner string
 	ChainID  ChainID
 	Asset    string
 	Amount   decimal.Decimal
+
+	// Block holding the deposit, when observed on chain; used to roll the
+	// deposit back if the block is reorganized away
+	BlockNumber uint64
+	BlockHash   string
+
+	// Receipt ID assigned up front by Accept; the worker assigns one
+	// otherwise
+	ReceiptID string
 }
 
 // BridgeReceipt is returned after a deposit has been processed by the bridge worker.
 type BridgeReceipt struct {
-	ReceiptID    string    `json:"receiptId"`
-	TxHash       string    `json:"txHash"`
-	SuiOwner     string    `json:"suiOwner"`
-	ChainID      ChainID   `json:"chainId"`
-	Asset        string    `json:"asset"`
-	Minted       string    `json:"minted"`
-	CreatedAt    time.Time `json:"createdAt"`
-	SuiTxDigests []string  `json:"suiTxDigests,omitempty"`
+	ReceiptID      string          `json:"receiptId"`
+	TxHash         string          `json:"txHash"`
+	LogIndex       uint64          `json:"logIndex"`
+	BlockNumber    uint64          `json:"blockNumber,omitempty"`
+	BlockHash      string          `json:"blockHash,omitempty"`
+	SuiOwner       string          `json:"suiOwner"`
+	ChainID        ChainID         `json:"chainId"`
+	Asset          string          `json:"asset"`
+	Minted         string          `json:"minted"`
+	Amount         decimal.Decimal `json:"amount"`                   // Deposited, in asset units
+	Shares         decimal.Decimal `json:"shares"`                   // Shares credited to SuiOwner
+	Fee            decimal.Decimal `json:"fee"`                      // Kept from the deposit, in asset units
+	Price          *PriceQuote     `json:"price,omitempty"`          // Quote the mint was split with
+	WalrusUpdateID uint64          `json:"walrusUpdateId,omitempty"` // Checkpoint that first counted Shares
+	Status         DepositStatus   `json:"status"`
+	Stage          ReceiptStage    `json:"stage,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	SuiTxDigests   []string        `json:"suiTxDigests,omitempty"`
 }
 
 // RedeemSubmission represents a burn on Sui requesting an EVM payout.
@@ -47,23 +64,27 @@ type RedeemSubmission struct {
 	Asset        string
 	Token        string // "f" or "x"
 	Amount       decimal.Decimal
+	ReceiptID    string // Assigned up front by AcceptRedeem, else by the worker
 }
 
 // RedeemReceipt is returned after a redeem has been processed by the bridge worker.
 type RedeemReceipt struct {
-	ReceiptID      string    `json:"receiptId"`
-	SuiTxDigest    string    `json:"suiTxDigest"`
-	SuiOwner       string    `json:"suiOwner"`
-	EthRecipient   string    `json:"ethRecipient"`
-	ChainID        ChainID   `json:"chainId"`
-	Asset          string    `json:"asset"`
-	Token          string    `json:"token"`
-	Burned         string    `json:"burned"`
-	PayoutEth      string    `json:"payoutEth"`
-	WalrusUpdateID uint64    `json:"walrusUpdateId,omitempty"`
-	WalrusBlobID   string    `json:"walrusBlobId,omitempty"`
-	PayoutTxHash   string    `json:"payoutTxHash,omitempty"`
-	CreatedAt      time.Time `json:"createdAt"`
+	ReceiptID      string       `json:"receiptId"`
+	SuiTxDigest    string       `json:"suiTxDigest"`
+	SuiOwner       string       `json:"suiOwner"`
+	EthRecipient   string       `json:"ethRecipient"`
+	ChainID        ChainID      `json:"chainId"`
+	Asset          string       `json:"asset"`
+	Token          string       `json:"token"`
+	Burned         string       `json:"burned"`
+	PayoutEth      string       `json:"payoutEth"` // After the fee
+	Fee            string       `json:"fee,omitempty"`
+	Price          *PriceQuote  `json:"price,omitempty"` // Quote the payout was computed with
+	WalrusUpdateID uint64       `json:"walrusUpdateId,omitempty"`
+	WalrusBlobID   string       `json:"walrusBlobId,omitempty"`
+	PayoutTxHash   string       `json:"payoutTxHash,omitempty"`
+	Stage          ReceiptStage `json:"stage,omitempty"`
+	CreatedAt      time.Time    `json:"createdAt"`
 }
 
 type bridgeJob struct {
@@ -104,6 +125,7 @@ type PayoutHandler interface {
 
 // RedeemPayoutContext carries computed payout details for a redemption.
 type RedeemPayoutContext struct {
+	SuiTxDigest  string
 	SuiOwner     string
 	EthRecipient string
 	ChainID      ChainID
@@ -112,6 +134,9 @@ type RedeemPayoutContext struct {
 	BurnAmount   decimal.Decimal
 	PayoutEth    decimal.Decimal
 	PriceUSD     decimal.Decimal
+
+	// WalrusUpdateID is the latest checkpoint when the burn was processed
+	WalrusUpdateID uint64
 }
 
 // WalrusPublisher persists checkpoints to Walrus DA and returns the blob ID.
@@ -143,6 +168,37 @@ func WithWalrusPublisher(p WalrusPublisher) BridgeWorkerOption {
 	}
 }
 
+// WithDepositListener configures the worker to submit deposits observed on an
+// origin chain. It may be given once per chain.
+func WithDepositListener(l DepositListener) BridgeWorkerOption {
+	return func(w *BridgeWorker) {
+		w.depositListeners = append(w.depositListeners, l)
+	}
+}
+
+// WithRetryPolicy configures how failed mints and payouts are retried.
+// Zero fields keep their defaults.
+func WithRetryPolicy(p RetryPolicy) BridgeWorkerOption {
+	return func(w *BridgeWorker) {
+		w.retryPolicy = p.withDefaults()
+	}
+}
+
+// WithPriceSource configures where the worker gets the USD prices deposits
+// and redeems are valued at. The source decides how fresh quotes must be.
+func WithPriceSource(src PriceSource) BridgeWorkerOption {
+	return func(w *BridgeWorker) {
+		w.priceSource = src
+	}
+}
+
+// WithFeeRecorder configures the worker to report the fees it collects.
+func WithFeeRecorder(r FeeRecorder) BridgeWorkerOption {
+	return func(w *BridgeWorker) {
+		w.feeRecorder = r
+	}
+}
+
 // WithRedeemListener configures the worker to listen for bridge_redeem events.
 func WithRedeemListener(l RedeemListener) BridgeWorkerOption {
 	return func(w *BridgeWorker) {
@@ -152,14 +208,27 @@ func WithRedeemListener(l RedeemListener) BridgeWorkerOption {
 
 // BridgeWorker consumes deposit submissions and mints balances on Sui (via the crosschain Service).
 type BridgeWorker struct {
-	svc             *Service
-	logger          *zap.SugaredLogger
-	jobs            chan bridgeJob
-	counter         uint64
-	mintHandler     MintHandler
-	payoutHandler   PayoutHandler
-	redeemListener  RedeemListener
-	walrusPublisher WalrusPublisher
+	svc              *Service
+	logger           *zap.SugaredLogger
+	jobs             chan bridgeJob
+	mintHandler      MintHandler
+	payoutHandler    PayoutHandler
+	redeemListener   RedeemListener
+	depositListeners []DepositListener
+	walrusPublisher  WalrusPublisher
+	priceSource      PriceSource
+	feeRecorder      FeeRecorder
+	retryPolicy      RetryPolicy
+	ownerLimiter     *ownerLimiter // Nil leaves owners unlimited
+	submissions      kv.Store      // Submissions accepted until their receipts are recorded
+
+	// Credited deposits wait in mintQueue for their batch while batching is on
+	batchWindow time.Duration
+	batchSize   int
+	mintQueue   chan pendingMint
+
+	// depositMu serializes deposit processing with reorg rollbacks
+	depositMu sync.Mutex
 }
 
 func NewBridgeWorker(svc *Service, logger *zap.SugaredLogger, opts ...BridgeWorkerOption) *BridgeWorker {
@@ -167,10 +236,22 @@ func NewBridgeWorker(svc *Service, logger *zap.SugaredLogger, opts ...BridgeWork
 		svc:    svc,
 		logger: logger,
 		jobs:   make(chan bridgeJob, 64),
+
+		retryPolicy: RetryPolicy{}.withDefaults(),
 	}
 	for _, opt := range opts {
 		opt(w)
 	}
+	if w.submissions == nil {
+		w.submissions = memkv.NewStore()
+	}
+	if w.priceSource == nil {
+		quoter := NewQuoterPriceSource("binance", binance.NewProvider(logger), memkv.NewStore(), defaultPriceCacheTTL)
+		w.priceSource = NewFallbackPriceSource(defaultPriceMaxAge, logger, quoter)
+	}
+	if w.batchMinter() != nil {
+		w.mintQueue = make(chan pendingMint, 2*w.batchSize)
+	}
 	return w
 }
 
@@ -181,7 +262,7 @@ func (w *BridgeWorker) Start(ctx context.Context) {
 	if w.redeemListener != nil {
 		if err := w.redeemListener.Start(ctx, func(evCtx context.Context, sub RedeemSubmission) {
 			go func() {
-				if _, err := w.Redeem(evCtx, sub); err != nil {
+				if _, err := w.redeem(evCtx, sub); err != nil {
 					w.logger.Warnw("Bridge redeem failed", "error", err, "suiTxDigest", sub.SuiTxDigest)
 				}
 			}()
@@ -190,6 +271,24 @@ func (w *BridgeWorker) Start(ctx context.Context) {
 		}
 	}
 
+	for _, listener := range w.depositListeners {
+		if err := listener.Start(ctx, func(evCtx context.Context, sub DepositSubmission) error {
+			_, err := w.submit(evCtx, sub)
+			return err
+		}, func(evCtx context.Context, ev ReorgEvent) error {
+			_, err := w.Revert(evCtx, ev)
+			return err
+		}); err != nil {
+			w.logger.Warnw("Bridge deposit listener failed to start", "error", err)
+		}
+	}
+
+	go w.runRetries(ctx)
+	go w.runVoucherExpiry(ctx)
+	if w.mintQueue != nil {
+		go w.runMintBatches(ctx)
+	}
+
 	go func() {
 		defer w.logger.Infow("Bridge worker stopped")
 		for {
@@ -205,10 +304,26 @@ func (w *BridgeWorker) Start(ctx context.Context) {
 }
 
 // Submit enqueues a deposit for processing and waits for the bridge receipt.
+// A deposit over a rate limit of its owner fails with a *RateLimitError.
 func (w *BridgeWorker) Submit(ctx context.Context, sub DepositSubmission) (*BridgeReceipt, error) {
 	if sub.SuiOwner == "" || sub.Asset == "" || sub.ChainID == "" || !sub.Amount.GreaterThan(decimal.Zero) {
 		return nil, ErrInvalidRequest
 	}
+	if err := w.checkOwnerLimits(ctx, BridgeOpDeposit, sub.SuiOwner, sub.ChainID, sub.Asset, sub.Amount); err != nil {
+		return nil, err
+	}
+	return w.submit(ctx, sub)
+}
+
+// submit enqueues a deposit without the owner rate limits, as deposits seen
+// on chain are
+func (w *BridgeWorker) submit(ctx context.Context, sub DepositSubmission) (*BridgeReceipt, error) {
+	if sub.SuiOwner == "" || sub.Asset == "" || sub.ChainID == "" || !sub.Amount.GreaterThan(decimal.Zero) {
+		return nil, ErrInvalidRequest
+	}
+	if _, err := w.svc.GetVault(ctx, sub.ChainID, sub.Asset); err != nil {
+		return nil, fmt.Errorf("%w: no vault for %s:%s", ErrInvalidRequest, sub.ChainID, sub.Asset)
+	}
 
 	w.logger.Infow("Bridge worker received deposit submission",
 		"txHash", sub.TxHash,
@@ -237,17 +352,34 @@ func (w *BridgeWorker) Submit(ctx context.Context, sub DepositSubmission) (*Brid
 	}
 }
 
-// Redeem processes a burn on Sui and initiates an origin-chain payout.
+// Redeem processes a burn on Sui and initiates an origin-chain payout. A
+// redeem over a rate limit of its owner fails with a *RateLimitError.
 func (w *BridgeWorker) Redeem(ctx context.Context, sub RedeemSubmission) (*RedeemReceipt, error) {
+	if sub.SuiOwner == "" || sub.Asset == "" || sub.ChainID == "" || !sub.Amount.GreaterThan(decimal.Zero) {
+		return nil, ErrInvalidRequest
+	}
+	if err := w.checkOwnerLimits(ctx, BridgeOpRedeem, sub.SuiOwner, sub.ChainID, sub.Asset, sub.Amount); err != nil {
+		return nil, err
+	}
+	return w.redeem(ctx, sub)
+}
+
+// redeem processes a burn without the owner rate limits, as burns seen on
+// Sui are
+func (w *BridgeWorker) redeem(ctx context.Context, sub RedeemSubmission) (*RedeemReceipt, error) {
 	token := strings.ToLower(strings.TrimSpace(sub.Token))
 	if sub.SuiOwner == "" || sub.Asset == "" || sub.ChainID == "" || sub.EthRecipient == "" || !sub.Amount.GreaterThan(decimal.Zero) || (token != "f" && token != "x") {
 		return nil, ErrInvalidRequest
 	}
+	if err := w.svc.CheckRedeem(ctx, sub.ChainID, sub.Asset); err != nil {
+		return nil, err
+	}
 
-	priceUSD, err := w.fetchUSDPrice(ctx, sub.ChainID, sub.Asset)
+	quote, err := w.fetchPrice(ctx, sub.ChainID, sub.Asset)
 	if err != nil {
 		return nil, fmt.Errorf("fetch price: %w", err)
 	}
+	priceUSD := quote.Price
 
 	var (
 		payoutEth  decimal.Decimal
@@ -261,18 +393,18 @@ func (w *BridgeWorker) Redeem(ctx context.Context, sub RedeemSubmission) (*Redee
 		payoutEth = sub.Amount
 	}
 
+	fee := w.svc.FeeFor(sub.ChainID, sub.Asset, BridgeOpRedeem, payoutEth)
+	payoutEth = payoutEth.Sub(fee)
 	if !payoutEth.GreaterThan(decimal.Zero) {
 		return nil, fmt.Errorf("invalid payout computed from %s %s", sub.Amount.String(), token)
 	}
 
-	cp, bal, err := w.updateWalrusCheckpointForRedeem(ctx, sub, burnShares)
-	if err != nil {
-		return nil, fmt.Errorf("update walrus: %w", err)
+	receiptID := sub.ReceiptID
+	if receiptID == "" {
+		receiptID = w.svc.NextReceiptID("redeem")
 	}
-
-	id := atomic.AddUint64(&w.counter, 1)
 	receipt := &RedeemReceipt{
-		ReceiptID:    fmt.Sprintf("redeem_%d", id),
+		ReceiptID:    receiptID,
 		SuiTxDigest:  sub.SuiTxDigest,
 		SuiOwner:     sub.SuiOwner,
 		EthRecipient: sub.EthRecipient,
@@ -281,27 +413,52 @@ func (w *BridgeWorker) Redeem(ctx context.Context, sub RedeemSubmission) (*Redee
 		Token:        token,
 		Burned:       sub.Amount.String(),
 		PayoutEth:    payoutEth.String(),
+		Fee:          fee.String(),
+		Price:        &quote,
 		CreatedAt:    time.Now(),
 	}
+	w.advanceRedeem(ctx, receipt, StageDetected, "")
+
+	cp, bal, err := w.updateWalrusCheckpointForRedeem(ctx, sub, burnShares)
+	if err != nil {
+		w.advanceRedeem(ctx, receipt, StageFailed, err.Error())
+		return nil, fmt.Errorf("update walrus: %w", err)
+	}
 	if cp != nil {
 		receipt.WalrusUpdateID = cp.UpdateID
 		receipt.WalrusBlobID = cp.WalrusBlobID
 	}
+	w.collectFee(ctx, receipt.ReceiptID, sub.ChainID, sub.Asset, BridgeOpRedeem, fee)
+	w.advanceRedeem(ctx, receipt, StageCheckpointed, "")
 
 	if w.payoutHandler != nil {
-		if txHash, err := w.payoutHandler.Payout(ctx, RedeemPayoutContext{
-			SuiOwner:     sub.SuiOwner,
-			EthRecipient: sub.EthRecipient,
-			ChainID:      sub.ChainID,
-			Asset:        sub.Asset,
-			Token:        token,
-			BurnAmount:   sub.Amount,
-			PayoutEth:    payoutEth,
-			PriceUSD:     priceUSD,
-		}); err != nil {
-			return nil, fmt.Errorf("payout handler: %w", err)
+		payout := RedeemPayoutContext{
+			SuiTxDigest:    sub.SuiTxDigest,
+			SuiOwner:       sub.SuiOwner,
+			EthRecipient:   sub.EthRecipient,
+			ChainID:        sub.ChainID,
+			Asset:          sub.Asset,
+			Token:          token,
+			BurnAmount:     sub.Amount,
+			PayoutEth:      payoutEth,
+			PriceUSD:       priceUSD,
+			WalrusUpdateID: receipt.WalrusUpdateID,
+		}
+		if txHash, err := w.payoutHandler.Payout(ctx, payout); err != nil {
+			// The shares are debited already, so the payout is queued
+			// rather than failing the redeem, or else refunded
+			if qerr := w.queuePayout(ctx, receipt, payout, err); qerr != nil {
+				w.logger.Errorw("Failed to queue bridge payout retry", "receiptId", receipt.ReceiptID, "error", qerr)
+				if rerr := w.requestRefund(ctx, receipt, err); rerr != nil {
+					w.logger.Errorw("Failed to request bridge refund", "receiptId", receipt.ReceiptID, "error", rerr)
+					return nil, fmt.Errorf("payout handler: %w", err)
+				}
+			} else {
+				w.logger.Warnw("Bridge payout failed; queued for retry", "receiptId", receipt.ReceiptID, "error", err)
+			}
 		} else {
 			receipt.PayoutTxHash = txHash
+			w.advanceRedeem(ctx, receipt, StagePaid, "")
 		}
 	}
 
@@ -312,6 +469,7 @@ func (w *BridgeWorker) Redeem(ctx context.Context, sub RedeemSubmission) (*Redee
 		"token", token,
 		"burnAmount", sub.Amount.String(),
 		"payoutEth", payoutEth.String(),
+		"fee", fee.String(),
 		"priceUSD", priceUSD.String(),
 		"walrusUpdateId", receipt.WalrusUpdateID,
 		"walrusBlobId", receipt.WalrusBlobID,
@@ -319,18 +477,77 @@ func (w *BridgeWorker) Redeem(ctx context.Context, sub RedeemSubmission) (*Redee
 		"value", bal.Value.String(),
 	)
 
+	// The shares have been debited, so a receipt that fails to save is only logged
+	if err := w.svc.RecordRedeemReceipt(ctx, receipt); err != nil {
+		w.logger.Errorw("Failed to record bridge redeem receipt", "receiptId", receipt.ReceiptID, "error", err)
+	}
+
 	return receipt, nil
 }
 
 func (w *BridgeWorker) handle(ctx context.Context, sub DepositSubmission) (*BridgeReceipt, error) {
-	priceUSD, err := w.fetchUSDPrice(ctx, sub.ChainID, sub.Asset)
+	w.depositMu.Lock()
+	defer w.depositMu.Unlock()
+
+	receiptID := sub.ReceiptID
+	if receiptID == "" {
+		receiptID = w.svc.NextReceiptID("bridge")
+	}
+	receipt, claimed, err := w.svc.ClaimDeposit(ctx, &BridgeReceipt{
+		ReceiptID:   receiptID,
+		TxHash:      sub.TxHash,
+		LogIndex:    sub.LogIndex,
+		BlockNumber: sub.BlockNumber,
+		BlockHash:   sub.BlockHash,
+		SuiOwner:    sub.SuiOwner,
+		ChainID:     sub.ChainID,
+		Asset:       sub.Asset,
+		CreatedAt:   time.Now(),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("fetch price: %w", err)
+		return nil, fmt.Errorf("claim deposit: %w", err)
+	}
+	if !claimed {
+		w.logger.Infow("Bridge deposit already processed",
+			"receiptId", receipt.ReceiptID,
+			"txHash", sub.TxHash,
+			"logIndex", sub.LogIndex,
+		)
+		return receipt, nil
+	}
+
+	w.advanceDeposit(ctx, receipt, StageDetected, "")
+
+	// Until the balance is credited nothing has changed, so a failed
+	// deposit may be submitted again
+	release := func(cause error) error {
+		w.advanceDeposit(ctx, receipt, StageFailed, cause.Error())
+		if err := w.svc.ReleaseDeposit(ctx, receipt); err != nil {
+			w.logger.Errorw("Failed to release bridge deposit", "receiptId", receipt.ReceiptID, "error", err)
+		}
+		return cause
+	}
+
+	if receipt.Minted != "" {
+		return w.recredit(ctx, sub, receipt, release)
 	}
 
-	mintF, mintX, mintShares, err := splitMintAmounts(sub.Amount, priceUSD)
+	if err := w.svc.CheckDeposit(ctx, sub.ChainID, sub.Asset, sub.Amount); err != nil {
+		return nil, release(err)
+	}
+	w.advanceDeposit(ctx, receipt, StageConfirmed, "")
+
+	quote, err := w.fetchPrice(ctx, sub.ChainID, sub.Asset)
 	if err != nil {
-		return nil, fmt.Errorf("mint split: %w", err)
+		return nil, release(fmt.Errorf("fetch price: %w", err))
+	}
+	priceUSD := quote.Price
+
+	// The fee stays in the vault; the rest is minted
+	fee := w.svc.FeeFor(sub.ChainID, sub.Asset, BridgeOpDeposit, sub.Amount)
+	mintF, mintX, mintShares, err := splitMintAmounts(sub.Amount.Sub(fee), priceUSD)
+	if err != nil {
+		return nil, release(fmt.Errorf("mint split: %w", err))
 	}
 
 	subForMint := sub
@@ -338,30 +555,33 @@ func (w *BridgeWorker) handle(ctx context.Context, sub DepositSubmission) (*Brid
 
 	cp, bal, err := w.updateWalrusCheckpoint(ctx, subForMint)
 	if err != nil {
-		return nil, fmt.Errorf("update walrus: %w", err)
+		return nil, release(fmt.Errorf("update walrus: %w", err))
 	}
-
-	id := atomic.AddUint64(&w.counter, 1)
-	receipt := &BridgeReceipt{
-		ReceiptID: fmt.Sprintf("bridge_%d", id),
-		TxHash:    sub.TxHash,
-		SuiOwner:  sub.SuiOwner,
-		ChainID:   sub.ChainID,
-		Asset:     sub.Asset,
-		Minted:    fmt.Sprintf("f=%s,x=%s", mintF.StringFixed(9), mintX.StringFixed(9)),
-		CreatedAt: time.Now(),
+	if err := w.svc.recordDepositVolume(ctx, sub.ChainID, sub.Asset, sub.Amount); err != nil {
+		w.logger.Errorw("Failed to record bridge deposit volume", "receiptId", receipt.ReceiptID, "error", err)
 	}
 
+	receipt.Minted = fmt.Sprintf("f=%s,x=%s", mintF.StringFixed(9), mintX.StringFixed(9))
+	receipt.Amount = sub.Amount
+	receipt.Shares = mintShares
+	receipt.Fee = fee
+	receipt.Price = &quote
+	receipt.WalrusUpdateID = cp.UpdateID
+	w.collectFee(ctx, receipt.ReceiptID, sub.ChainID, sub.Asset, BridgeOpDeposit, fee)
+	w.advanceDeposit(ctx, receipt, StageCheckpointed, "")
+
 	w.logger.Infow("Bridge deposit minted",
 		"receiptId", receipt.ReceiptID,
 		"suiOwner", sub.SuiOwner,
 		"asset", sub.Asset,
 		"chainId", sub.ChainID,
 		"amountEth", sub.Amount.String(),
+		"fee", fee.String(),
 		"priceUSD", priceUSD.String(),
 		"fMinted", mintF.StringFixed(9),
 		"xMinted", mintX.StringFixed(9),
 		"txHash", sub.TxHash,
+		"logIndex", sub.LogIndex,
 		"newShares", bal.Shares.String(),
 		"value", bal.Value.String(),
 		"walrusUpdateId", cp.UpdateID,
@@ -371,7 +591,7 @@ func (w *BridgeWorker) handle(ctx context.Context, sub DepositSubmission) (*Brid
 	)
 
 	if w.mintHandler != nil {
-		mintResult, err := w.mintHandler.Mint(ctx, BridgeMintContext{
+		mint := BridgeMintContext{
 			Submission: subForMint,
 			Checkpoint: cp,
 			Balance:    bal,
@@ -379,61 +599,158 @@ func (w *BridgeWorker) handle(ctx context.Context, sub DepositSubmission) (*Brid
 			MintF:      toUint(mintF),
 			MintX:      toUint(mintX),
 			PriceUSD:   priceUSD,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("mint handler: %w", err)
 		}
-		if mintResult != nil && len(mintResult.TxDigests) > 0 {
-			receipt.SuiTxDigests = append([]string{}, mintResult.TxDigests...)
+		if w.mintQueue != nil {
+			// The batch loop completes the receipt once its batch is minted
+			if err := w.enqueueMint(ctx, receipt, mint); err != nil {
+				return nil, fmt.Errorf("queue mint: %w", err)
+			}
+			return receipt, nil
 		}
+		mintResult, err := w.mintHandler.Mint(ctx, mint)
+		if err != nil {
+			// The shares are credited already, so the claim is kept pending
+			// and the mint queued rather than released to be credited again
+			if qerr := w.queueMint(ctx, receipt, mint, err); qerr != nil {
+				w.advanceDeposit(ctx, receipt, StageFailed, err.Error())
+				w.logger.Errorw("Bridge deposit credited but not minted; claim kept pending",
+					"receiptId", receipt.ReceiptID,
+					"txHash", sub.TxHash,
+					"logIndex", sub.LogIndex,
+					"error", err,
+					"queueError", qerr,
+				)
+				return nil, fmt.Errorf("mint handler: %w", err)
+			}
+			w.logger.Warnw("Bridge mint failed; queued for retry",
+				"receiptId", receipt.ReceiptID,
+				"txHash", sub.TxHash,
+				"logIndex", sub.LogIndex,
+				"error", err,
+			)
+			return receipt, nil
+		}
+		w.completeMint(ctx, receipt, mintResult, "")
+		return receipt, nil
 	}
+	w.completeMint(ctx, receipt, nil, "")
 
 	return receipt, nil
 }
 
-// fetchUSDPrice pulls the latest USD price for the given chain/asset from Binance.
-func (w *BridgeWorker) fetchUSDPrice(ctx context.Context, chainID ChainID, asset string) (decimal.Decimal, error) {
-	asset = strings.ToUpper(strings.TrimSpace(asset))
-	symbol := ""
+// recredit credits a reverted deposit that the origin chain included again
+// after a reorg. Its tokens were minted on Sui at the first submission, so
+// only the shares are credited again.
+func (w *BridgeWorker) recredit(ctx context.Context, sub DepositSubmission, receipt *BridgeReceipt, release func(error) error) (*BridgeReceipt, error) {
+	w.advanceDeposit(ctx, receipt, StageConfirmed, "included again after a reorg")
+	sub.Amount = receipt.Shares
+	cp, bal, err := w.updateWalrusCheckpoint(ctx, sub)
+	if err != nil {
+		return nil, release(fmt.Errorf("update walrus: %w", err))
+	}
+	receipt.WalrusUpdateID = cp.UpdateID
+	if err := w.svc.collectFees(ctx, sub.ChainID, sub.Asset, BridgeOpDeposit, receipt.Fee); err != nil {
+		w.logger.Errorw("Failed to record bridge fee", "receiptId", receipt.ReceiptID, "error", err)
+	}
+	w.advanceDeposit(ctx, receipt, StageCheckpointed, "")
+	w.advanceDeposit(ctx, receipt, StageMinted, "credited again; minted at the first submission")
+
+	w.logger.Infow("Reorged bridge deposit included again; shares credited without minting",
+		"receiptId", receipt.ReceiptID,
+		"txHash", sub.TxHash,
+		"logIndex", sub.LogIndex,
+		"blockNumber", sub.BlockNumber,
+		"shares", receipt.Shares.String(),
+		"newShares", bal.Shares.String(),
+		"walrusUpdateId", cp.UpdateID,
+	)
 
-	switch {
-	case chainID == ChainIDEthereum && asset == "ETH":
-		symbol = "ETHUSDT"
-	default:
-		return decimal.Zero, fmt.Errorf("unsupported asset for price fetch: %s:%s", chainID, asset)
+	if err := w.svc.CompleteDeposit(ctx, receipt); err != nil {
+		w.logger.Errorw("Failed to record bridge deposit receipt", "receiptId", receipt.ReceiptID, "error", err)
 	}
+	return receipt, nil
+}
+
+// Revert rolls back the deposits of blocks an origin-chain reorg replaced.
+// Tokens minted on Sui for the reverted deposits cannot be taken back, so
+// each is logged for review; a deposit the new chain includes again is
+// credited again without a second mint.
+func (w *BridgeWorker) Revert(ctx context.Context, ev ReorgEvent) (*DepositRollback, error) {
+	w.depositMu.Lock()
+	defer w.depositMu.Unlock()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v3/ticker/price?symbol=%s", binance.BinanceRestAPI, symbol), nil)
+	rollback, err := w.svc.RevertDeposits(ctx, ev)
 	if err != nil {
-		return decimal.Zero, fmt.Errorf("build price request: %w", err)
+		return nil, fmt.Errorf("revert deposits: %w", err)
 	}
+	for _, receipt := range rollback.Reverted {
+		w.advanceDeposit(ctx, receipt, StageFailed, fmt.Sprintf("reverted by a reorg of block %d", receipt.BlockNumber))
+		// The vault never received the deposit, so neither its fee
+		if err := w.svc.collectFees(ctx, receipt.ChainID, receipt.Asset, BridgeOpDeposit, receipt.Fee.Neg()); err != nil {
+			w.logger.Errorw("Failed to return bridge fee", "receiptId", receipt.ReceiptID, "error", err)
+		}
+		w.logger.Errorw("Bridge deposit reverted by reorg; Sui mint needs review",
+			"receiptId", receipt.ReceiptID,
+			"txHash", receipt.TxHash,
+			"logIndex", receipt.LogIndex,
+			"blockNumber", receipt.BlockNumber,
+			"suiOwner", receipt.SuiOwner,
+			"minted", receipt.Minted,
+			"suiTxDigests", receipt.SuiTxDigests,
+		)
+	}
+	return rollback, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return decimal.Zero, fmt.Errorf("price request failed: %w", err)
+// collectFee adds a fee charged for receipt to the fees of its asset. The
+// balance has changed already, so a fee that fails to save is logged.
+func (w *BridgeWorker) collectFee(ctx context.Context, receiptID string, chainID ChainID, asset string, op BridgeOperation, fee decimal.Decimal) {
+	if !fee.IsPositive() {
+		return
+	}
+	if err := w.svc.collectFees(ctx, chainID, asset, op, fee); err != nil {
+		w.logger.Errorw("Failed to record bridge fee", "receiptId", receiptID, "error", err)
 	}
-	defer resp.Body.Close()
+	if w.feeRecorder != nil {
+		w.feeRecorder.RecordBridgeFee(ctx, string(chainID), asset, string(op), fee.InexactFloat64())
+	}
+}
 
-	if resp.StatusCode/100 != 2 {
-		return decimal.Zero, fmt.Errorf("price request returned %d", resp.StatusCode)
+// advanceDeposit moves receipt to stage. Stages are only reported, so a
+// stage that fails to save is logged rather than failing the deposit.
+func (w *BridgeWorker) advanceDeposit(ctx context.Context, receipt *BridgeReceipt, stage ReceiptStage, reason string) {
+	if err := w.svc.AdvanceDeposit(ctx, receipt, stage, reason); err != nil {
+		w.logger.Errorw("Failed to record bridge deposit stage", "receiptId", receipt.ReceiptID, "stage", stage, "error", err)
 	}
+}
 
-	var payload struct {
-		Price string `json:"price"`
+// advanceRedeem moves receipt to stage like advanceDeposit
+func (w *BridgeWorker) advanceRedeem(ctx context.Context, receipt *RedeemReceipt, stage ReceiptStage, reason string) {
+	if err := w.svc.AdvanceRedeem(ctx, receipt, stage, reason); err != nil {
+		w.logger.Errorw("Failed to record bridge redeem stage", "receiptId", receipt.ReceiptID, "stage", stage, "error", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return decimal.Zero, fmt.Errorf("decode price response: %w", err)
+}
+
+// fetchPrice returns the latest USD quote for the given chain/asset from
+// the price source, using the price symbol of the asset's vault. Receipts
+// keep the quote so that their amounts can be recomputed.
+func (w *BridgeWorker) fetchPrice(ctx context.Context, chainID ChainID, asset string) (PriceQuote, error) {
+	vault, err := w.svc.GetVault(ctx, chainID, strings.ToUpper(strings.TrimSpace(asset)))
+	if err != nil || vault.PriceSymbol == "" {
+		return PriceQuote{}, fmt.Errorf("unsupported asset for price fetch: %s:%s", chainID, asset)
 	}
 
-	price, err := decimal.NewFromString(payload.Price)
+	quote, err := w.priceSource.USDPrice(ctx, vault.PriceSymbol)
 	if err != nil {
-		return decimal.Zero, fmt.Errorf("parse price: %w", err)
+		return PriceQuote{}, err
 	}
-	if !price.GreaterThan(decimal.Zero) {
-		return decimal.Zero, fmt.Errorf("invalid price %s", payload.Price)
+	if !quote.Price.GreaterThan(decimal.Zero) {
+		return PriceQuote{}, fmt.Errorf("invalid price %s", quote.Price)
 	}
-
-	return price, nil
+	if quote.Symbol == "" {
+		quote.Symbol = vault.PriceSymbol
+	}
+	return quote, nil
 }
 
 // splitMintAmounts mirrors init_protocol's 50/50 USD split: half to fToken (Pf fixed at 1),
@@ -520,7 +837,7 @@ func (w *BridgeWorker) updateWalrusCheckpointForRedeem(ctx context.Context, sub
 		BlockHash:    blockHash,
 		TotalShares:  totalShares,
 		Index:        index,
-		BalancesRoot: balancesRootForOwner(sub.SuiOwner, sub.ChainID, sub.Asset, totalShares, blockNumber, blockHash),
+		BalancesRoot: w.svc.BalancesRootAfter(ctx, sub.SuiOwner, sub.ChainID, sub.Asset, burnShares.Neg()),
 		ProofType:    "walrus",
 		Status:       CheckpointStatusVerified,
 		Timestamp:    now,
@@ -534,7 +851,7 @@ func (w *BridgeWorker) updateWalrusCheckpointForRedeem(ctx context.Context, sub
 		}
 	}
 	if cp.WalrusBlobID == "" {
-		cp.WalrusBlobID = fmt.Sprintf("walrus-%s-%s-%d", sub.ChainID, sub.Asset, now.UnixNano())
+		cp.WalrusBlobID = syntheticBlobID(sub.ChainID, sub.Asset, now)
 	}
 
 	created, err := w.svc.SubmitCheckpoint(ctx, cp)
@@ -589,7 +906,7 @@ func (w *BridgeWorker) updateWalrusCheckpoint(ctx context.Context, sub DepositSu
 		BlockHash:    blockHash,
 		TotalShares:  totalShares,
 		Index:        index,
-		BalancesRoot: balancesRootForOwner(sub.SuiOwner, sub.ChainID, sub.Asset, totalShares, blockNumber, blockHash),
+		BalancesRoot: w.svc.BalancesRootAfter(ctx, sub.SuiOwner, sub.ChainID, sub.Asset, sub.Amount),
 		ProofType:    "walrus",
 		Status:       CheckpointStatusVerified,
 		Timestamp:    now,
@@ -603,7 +920,7 @@ func (w *BridgeWorker) updateWalrusCheckpoint(ctx context.Context, sub DepositSu
 		}
 	}
 	if cp.WalrusBlobID == "" {
-		cp.WalrusBlobID = fmt.Sprintf("walrus-%s-%s-%d", sub.ChainID, sub.Asset, now.UnixNano())
+		cp.WalrusBlobID = syntheticBlobID(sub.ChainID, sub.Asset, now)
 	}
 
 	created, err := w.svc.SubmitCheckpoint(ctx, cp)
@@ -619,85 +936,3 @@ func (w *BridgeWorker) updateWalrusCheckpoint(ctx context.Context, sub DepositSu
 
 	return created, bal, nil
 }
-
-func balancesRootForOwner(owner string, chainID ChainID, asset string, totalShares decimal.Decimal, blockNumber uint64, blockHash string) string {
-	payload := fmt.Sprintf("%s:%s:%s:%s:%d:%s", owner, chainID, asset, totalShares.String(), blockNumber, blockHash)
-	h := sha256.Sum256([]byte(payload))
-	return fmt.Sprintf("0x%x", h[:])
-}
-
-// HTTPWalrusPublisher posts checkpoints to a Walrus gateway and expects a JSON id response.
-type HTTPWalrusPublisher struct {
-	Endpoint     string
-	Client       *http.Client
-	Epochs       int
-	SendObjectTo string
-}
-
-func (p *HTTPWalrusPublisher) Publish(ctx context.Context, cp WalrusCheckpoint) (string, error) {
-	if p == nil || p.Endpoint == "" {
-		return "", fmt.Errorf("walrus endpoint not configured")
-	}
-	client := p.Client
-	if client == nil {
-		client = http.DefaultClient
-	}
-
-	body, err := json.Marshal(cp)
-	if err != nil {
-		return "", fmt.Errorf("marshal checkpoint: %w", err)
-	}
-
-	epochs := p.Epochs
-	if epochs <= 0 {
-		epochs = 1
-	}
-	u, err := url.Parse(p.Endpoint)
-	if err != nil {
-		return "", fmt.Errorf("parse walrus endpoint: %w", err)
-	}
-	if u.Path == "" || u.Path == "/" {
-		u.Path = "/v1/blobs"
-	}
-	q := u.Query()
-	q.Set("epochs", fmt.Sprintf("%d", epochs))
-	if p.SendObjectTo != "" {
-		q.Set("send_object_to", p.SendObjectTo)
-	}
-	u.RawQuery = q.Encode()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("build walrus request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("walrus post: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode/100 != 2 {
-		return "", fmt.Errorf("walrus post status %d", resp.StatusCode)
-	}
-
-	var parsed struct {
-		ID     string `json:"id"`
-		BlobID string `json:"blobId"`
-		Cid    string `json:"cid"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err == nil {
-		if parsed.ID != "" {
-			return parsed.ID, nil
-		}
-		if parsed.BlobID != "" {
-			return parsed.BlobID, nil
-		}
-		if parsed.Cid != "" {
-			return parsed.Cid, nil
-		}
-	}
-
-	return "", nil
-}
@@ -0,0 +1,106 @@
+package crosschain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChainConfig describes an EVM chain the bridge accepts deposits from.
+type ChainConfig struct {
+	ChainID       ChainID
+	RPCURL        string
+	VaultAddress  string // Vault of native asset deposits
+	NativeAsset   string // e.g. ETH on rollups, POL on Polygon
+	PriceSymbol   string // Binance ticker of the native asset
+	Confirmations uint64
+	StartBlock    uint64 // Zero starts at the latest confirmed block
+}
+
+// chainDefaults holds the native asset and confirmation depth of known chains
+var chainDefaults = map[ChainID]ChainConfig{
+	ChainIDEthereum: {NativeAsset: "ETH", PriceSymbol: "ETHUSDT", Confirmations: defaultDepositConfirmations},
+	ChainIDArbitrum: {NativeAsset: "ETH", PriceSymbol: "ETHUSDT", Confirmations: 20},
+	ChainIDBase:     {NativeAsset: "ETH", PriceSymbol: "ETHUSDT", Confirmations: 20},
+	ChainIDPolygon:  {NativeAsset: "POL", PriceSymbol: "POLUSDT", Confirmations: 128},
+}
+
+// chainsFromEnv returns the chain registry. Ethereum is included when
+// LFS_ETH_RPC_URL is set and keeps its LFS_ETH_DEPOSIT_* settings. Other
+// chains are listed in LFS_BRIDGE_CHAINS, e.g. "arbitrum,base", and each is
+// configured by
+//
+//	LFS_BRIDGE_<CHAIN>_RPC_URL        JSON-RPC endpoint
+//	LFS_BRIDGE_<CHAIN>_VAULT_ADDRESS  vault of native asset deposits
+//	LFS_BRIDGE_<CHAIN>_CONFIRMATIONS  optional, defaults per chain
+//	LFS_BRIDGE_<CHAIN>_START_BLOCK    optional
+//	LFS_BRIDGE_<CHAIN>_NATIVE_ASSET   required for unknown chains
+//	LFS_BRIDGE_<CHAIN>_PRICE_SYMBOL   required for unknown chains
+func chainsFromEnv() ([]ChainConfig, error) {
+	var chains []ChainConfig
+
+	if rpcURL := envOrDefault("", "LFS_ETH_RPC_URL", "LFS_SEPOLIA_RPC_URL", "LFS_LOCAL_ETH_RPC_URL"); rpcURL != "" {
+		eth := chainDefaults[ChainIDEthereum]
+		eth.ChainID = ChainIDEthereum
+		eth.RPCURL = rpcURL
+		eth.VaultAddress = envOrDefault("", "LFS_CROSSCHAIN_VAULT_ADDRESS", "LFS_SEPOLIA_VAULT_ADDRESS", "LFS_LOCAL_ETH_VAULT_ADDRESS")
+		if eth.VaultAddress == "" {
+			return nil, fmt.Errorf("LFS_ETH_RPC_URL is set but LFS_CROSSCHAIN_VAULT_ADDRESS is missing")
+		}
+		if err := parseUintEnv("LFS_ETH_DEPOSIT_CONFIRMATIONS", &eth.Confirmations); err != nil {
+			return nil, err
+		}
+		if err := parseUintEnv("LFS_ETH_DEPOSIT_START_BLOCK", &eth.StartBlock); err != nil {
+			return nil, err
+		}
+		chains = append(chains, eth)
+	}
+
+	seen := map[ChainID]bool{ChainIDEthereum: true}
+	for _, name := range strings.Split(envOrDefault("", "LFS_BRIDGE_CHAINS"), ",") {
+		chainID := ChainID(strings.ToLower(strings.TrimSpace(name)))
+		if chainID == "" {
+			continue
+		}
+		if seen[chainID] {
+			return nil, fmt.Errorf("bridge chain %s is configured twice", chainID)
+		}
+		seen[chainID] = true
+		prefix := "LFS_BRIDGE_" + strings.ToUpper(string(chainID)) + "_"
+
+		chain := chainDefaults[chainID]
+		chain.ChainID = chainID
+		chain.RPCURL = envOrDefault("", prefix+"RPC_URL")
+		chain.VaultAddress = envOrDefault("", prefix+"VAULT_ADDRESS")
+		chain.NativeAsset = strings.ToUpper(envOrDefault(chain.NativeAsset, prefix+"NATIVE_ASSET"))
+		chain.PriceSymbol = envOrDefault(chain.PriceSymbol, prefix+"PRICE_SYMBOL")
+		if chain.RPCURL == "" || chain.VaultAddress == "" || chain.NativeAsset == "" || chain.PriceSymbol == "" {
+			return nil, fmt.Errorf("bridge chain %s requires %sRPC_URL, %sVAULT_ADDRESS, %sNATIVE_ASSET and %sPRICE_SYMBOL", chainID, prefix, prefix, prefix, prefix)
+		}
+		if chain.Confirmations == 0 {
+			chain.Confirmations = defaultDepositConfirmations
+		}
+		if err := parseUintEnv(prefix+"CONFIRMATIONS", &chain.Confirmations); err != nil {
+			return nil, err
+		}
+		if err := parseUintEnv(prefix+"START_BLOCK", &chain.StartBlock); err != nil {
+			return nil, err
+		}
+		chains = append(chains, chain)
+	}
+	return chains, nil
+}
+
+// parseUintEnv sets dst from the env var key when it is present
+func parseUintEnv(key string, dst *uint64) error {
+	v := envOrDefault("", key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	*dst = n
+	return nil
+}
@@ -0,0 +1,238 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultCheckpointInterval is how often the checkpointer runs when
+// LFS_CHECKPOINT_INTERVAL is unset
+const defaultCheckpointInterval = 10 * time.Minute
+
+// Heartbeat statuses reported to a CheckpointRecorder
+const (
+	HeartbeatPublished   = "published"   // Checkpoint created and published to Walrus
+	HeartbeatUnpublished = "unpublished" // Checkpoint created with a synthetic blob ID
+	HeartbeatSkipped     = "skipped"     // State changing; the next run tries again
+	HeartbeatError       = "error"
+)
+
+// errSnapshotStale is returned for a snapshot whose state changed before
+// it was submitted
+var errSnapshotStale = errors.New("checkpoint snapshot is stale")
+
+// CheckpointRecorder receives a heartbeat for each chain and asset the
+// checkpointer visits, as *metrics.Metrics does. staleness is the age of
+// the latest checkpoint before the run.
+type CheckpointRecorder interface {
+	RecordCheckpointHeartbeat(ctx context.Context, chainID, asset, status string, staleness time.Duration)
+}
+
+// checkpointSnapshot is a checkpoint of the current state of a chain and
+// asset, taken while it matched their latest checkpoint
+type checkpointSnapshot struct {
+	cp     WalrusCheckpoint
+	after  uint64    // Update ID of the latest checkpoint
+	latest time.Time // Timestamp of the latest checkpoint
+}
+
+// checkpointSnapshots returns a snapshot of every chain and asset whose
+// latest checkpoint was taken by dueBy. Those whose balances no longer
+// match their latest checkpoint are being changed by the worker, which
+// checkpoints them itself; their latest checkpoints are returned as busy.
+func (s *Service) checkpointSnapshots(dueBy time.Time) ([]checkpointSnapshot, []*WalrusCheckpoint) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		snapshots []checkpointSnapshot
+		busy      []*WalrusCheckpoint
+	)
+	for _, cps := range s.checkpoints {
+		if len(cps) == 0 {
+			continue
+		}
+		last := cps[len(cps)-1]
+		if last.Timestamp.After(dueBy) {
+			continue
+		}
+		root := s.balancesRootLocked(last.ChainID, last.Asset, nil)
+		if root != last.BalancesRoot {
+			busy = append(busy, last)
+			continue
+		}
+
+		vault := last.Vault
+		if v, ok := s.vaults[s.mapKey(last.ChainID, last.Asset)]; ok && v.VaultAddress != "" {
+			vault = v.VaultAddress
+		}
+		snapshots = append(snapshots, checkpointSnapshot{
+			cp: WalrusCheckpoint{
+				ChainID:      last.ChainID,
+				Asset:        last.Asset,
+				Vault:        vault,
+				BlockNumber:  last.BlockNumber,
+				BlockHash:    last.BlockHash,
+				TotalShares:  last.TotalShares,
+				Index:        last.Index,
+				BalancesRoot: root,
+				ProofType:    "walrus",
+				Status:       CheckpointStatusVerified,
+			},
+			after:  last.UpdateID,
+			latest: last.Timestamp,
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].after < snapshots[j].after })
+	return snapshots, busy
+}
+
+// submitSnapshot records snap.cp unless a checkpoint or balance of its
+// chain and asset changed since the snapshot, in which case
+// errSnapshotStale is returned
+func (s *Service) submitSnapshot(ctx context.Context, snap checkpointSnapshot) (*WalrusCheckpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := s.latestCheckpointLocked(snap.cp.ChainID, snap.cp.Asset)
+	if last == nil || last.UpdateID != snap.after || s.balancesRootLocked(snap.cp.ChainID, snap.cp.Asset, nil) != snap.cp.BalancesRoot {
+		return nil, errSnapshotStale
+	}
+	return s.submitCheckpointLocked(ctx, snap.cp)
+}
+
+// Checkpointer periodically checkpoints and publishes the state of every
+// chain and asset, so that Walrus stays fresh when no deposits or redeems
+// arrive. A chain and asset is checkpointed once its latest checkpoint is
+// older than the interval; the snapshot re-attests the same block, shares,
+// index and balances root.
+type Checkpointer struct {
+	svc       *Service
+	publisher WalrusPublisher    // Nil records synthetic blob IDs
+	recorder  CheckpointRecorder // Nil disables heartbeats
+	interval  time.Duration
+	logger    *zap.SugaredLogger
+}
+
+// NewCheckpointer returns a checkpointer running every interval.
+func NewCheckpointer(svc *Service, publisher WalrusPublisher, recorder CheckpointRecorder, interval time.Duration, logger *zap.SugaredLogger) *Checkpointer {
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+	return &Checkpointer{
+		svc:       svc,
+		publisher: publisher,
+		recorder:  recorder,
+		interval:  interval,
+		logger:    logger,
+	}
+}
+
+// NewCheckpointerFromEnv returns a checkpointer running every
+// LFS_CHECKPOINT_INTERVAL (default 10m), or nil when it is "0".
+func NewCheckpointerFromEnv(svc *Service, publisher WalrusPublisher, recorder CheckpointRecorder, logger *zap.SugaredLogger) (*Checkpointer, error) {
+	interval := defaultCheckpointInterval
+	if v := envOrDefault("", "LFS_CHECKPOINT_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LFS_CHECKPOINT_INTERVAL %q: %w", v, err)
+		}
+		if d <= 0 {
+			return nil, nil
+		}
+		interval = d
+	}
+	return NewCheckpointer(svc, publisher, recorder, interval, logger), nil
+}
+
+// Interval returns the time between checkpointer runs.
+func (c *Checkpointer) Interval() time.Duration {
+	return c.interval
+}
+
+// Start runs the checkpointer until ctx is done.
+func (c *Checkpointer) Start(ctx context.Context) {
+	c.logger.Infow("Checkpointer starting", "interval", c.interval)
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				c.logger.Infow("Checkpointer stopped")
+				return
+			case <-ticker.C:
+				c.RunOnce(ctx, time.Now())
+			}
+		}
+	}()
+}
+
+// RunOnce checkpoints every chain and asset whose latest checkpoint is at
+// least an interval older than now.
+func (c *Checkpointer) RunOnce(ctx context.Context, now time.Time) {
+	snapshots, busy := c.svc.checkpointSnapshots(now.Add(-c.interval))
+	for _, last := range busy {
+		c.heartbeat(ctx, last.ChainID, last.Asset, HeartbeatSkipped, now.Sub(last.Timestamp))
+	}
+
+	for _, snap := range snapshots {
+		if ctx.Err() != nil {
+			return
+		}
+		staleness := now.Sub(snap.latest)
+		published := false
+		if c.publisher != nil {
+			if blobID, err := c.publisher.Publish(ctx, snap.cp); err != nil {
+				c.logger.Warnw("Walrus publish failed; falling back to synthetic blob id",
+					"chainId", snap.cp.ChainID,
+					"asset", snap.cp.Asset,
+					"error", err,
+				)
+			} else if blobID != "" {
+				snap.cp.WalrusBlobID = blobID
+				published = true
+			}
+		}
+		if snap.cp.WalrusBlobID == "" {
+			snap.cp.WalrusBlobID = syntheticBlobID(snap.cp.ChainID, snap.cp.Asset, now)
+		}
+		snap.cp.Timestamp = now
+
+		created, err := c.svc.submitSnapshot(ctx, snap)
+		switch {
+		case errors.Is(err, errSnapshotStale):
+			c.heartbeat(ctx, snap.cp.ChainID, snap.cp.Asset, HeartbeatSkipped, staleness)
+			continue
+		case err != nil:
+			c.logger.Errorw("Scheduled checkpoint failed", "chainId", snap.cp.ChainID, "asset", snap.cp.Asset, "error", err)
+			c.heartbeat(ctx, snap.cp.ChainID, snap.cp.Asset, HeartbeatError, staleness)
+			continue
+		}
+
+		status := HeartbeatUnpublished
+		if published {
+			status = HeartbeatPublished
+		}
+		c.logger.Infow("Scheduled checkpoint created",
+			"chainId", created.ChainID,
+			"asset", created.Asset,
+			"walrusUpdateId", created.UpdateID,
+			"walrusBlobId", created.WalrusBlobID,
+			"status", status,
+			"staleness", staleness,
+		)
+		c.heartbeat(ctx, created.ChainID, created.Asset, status, staleness)
+	}
+}
+
+func (c *Checkpointer) heartbeat(ctx context.Context, chainID ChainID, asset, status string, staleness time.Duration) {
+	if c.recorder != nil {
+		c.recorder.RecordCheckpointHeartbeat(ctx, string(chainID), asset, status, staleness)
+	}
+}
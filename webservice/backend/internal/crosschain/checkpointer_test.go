@@ -0,0 +1,139 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+type publishFunc func(context.Context, WalrusCheckpoint) (string, error)
+
+func (f publishFunc) Publish(ctx context.Context, cp WalrusCheckpoint) (string, error) {
+	return f(ctx, cp)
+}
+
+// heartbeats records checkpointer heartbeats as "chain:asset:status"
+type heartbeats []string
+
+func (h *heartbeats) RecordCheckpointHeartbeat(_ context.Context, chainID, asset, status string, _ time.Duration) {
+	*h = append(*h, fmt.Sprintf("%s:%s:%s", chainID, asset, status))
+}
+
+func TestCheckpointerRefreshesQuietAssets(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(zap.NewNop().Sugar())
+	seeded, err := svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+	if err != nil {
+		t.Fatalf("GetLatestCheckpoint failed: %v", err)
+	}
+
+	var published []WalrusCheckpoint
+	var failPublish bool
+	publisher := publishFunc(func(_ context.Context, cp WalrusCheckpoint) (string, error) {
+		if failPublish {
+			return "", errors.New("walrus down")
+		}
+		published = append(published, cp)
+		return fmt.Sprintf("blob-%d", len(published)), nil
+	})
+	var beats heartbeats
+	checkpointer := NewCheckpointer(svc, publisher, &beats, time.Minute, zap.NewNop().Sugar())
+
+	// A fresh checkpoint is left alone
+	now := seeded.Timestamp.Add(30 * time.Second)
+	checkpointer.RunOnce(ctx, now)
+	if len(published) != 0 || len(beats) != 0 {
+		t.Fatalf("Expected nothing before the interval, got %v", beats)
+	}
+
+	// Once it is an interval old, the same state is checkpointed again
+	now = now.Add(time.Minute)
+	checkpointer.RunOnce(ctx, now)
+	latest, err := svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+	if err != nil {
+		t.Fatalf("GetLatestCheckpoint failed: %v", err)
+	}
+	if latest.UpdateID == seeded.UpdateID || latest.WalrusBlobID != "blob-1" || !latest.Timestamp.Equal(now) {
+		t.Fatalf("Expected a published checkpoint, got %+v", latest)
+	}
+	if latest.BalancesRoot != seeded.BalancesRoot || !latest.TotalShares.Equal(seeded.TotalShares) || latest.BlockNumber != seeded.BlockNumber {
+		t.Errorf("Expected the seeded state to be re-attested, got %+v", latest)
+	}
+	if len(beats) != 1 || beats[0] != "ethereum:ETH:published" {
+		t.Errorf("Unexpected heartbeats: %v", beats)
+	}
+
+	// Without Walrus the checkpoint still refreshes, under a synthetic ID
+	failPublish = true
+	now = now.Add(time.Minute)
+	checkpointer.RunOnce(ctx, now)
+	if latest, _ = svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH"); !isSyntheticBlobID(latest.WalrusBlobID) {
+		t.Errorf("Expected a synthetic blob ID, got %+v", latest)
+	}
+	if beats[len(beats)-1] != "ethereum:ETH:unpublished" {
+		t.Errorf("Unexpected heartbeats: %v", beats)
+	}
+
+	// Balances the latest checkpoint does not match are being changed;
+	// they are skipped rather than checkpointed without their shares total
+	if _, err := svc.CreditDeposit(ctx, "0xalice", ChainIDEthereum, "ETH", decimal.RequireFromString("1")); err != nil {
+		t.Fatalf("CreditDeposit failed: %v", err)
+	}
+	before := latest.UpdateID
+	now = now.Add(time.Minute)
+	checkpointer.RunOnce(ctx, now)
+	if latest, _ = svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH"); latest.UpdateID != before {
+		t.Errorf("Expected no checkpoint while balances change, got %+v", latest)
+	}
+	if beats[len(beats)-1] != "ethereum:ETH:skipped" {
+		t.Errorf("Unexpected heartbeats: %v", beats)
+	}
+}
+
+func TestSubmitSnapshotRejectsChangedState(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(zap.NewNop().Sugar())
+	snapshots, _ := svc.checkpointSnapshots(time.Now().Add(time.Hour))
+	if len(snapshots) != 1 {
+		t.Fatalf("Expected a snapshot of the seeded checkpoint, got %d", len(snapshots))
+	}
+
+	// A deposit checkpointed between the snapshot and its submission wins
+	amount := decimal.RequireFromString("1")
+	latest := snapshots[0].cp
+	latest.TotalShares = latest.TotalShares.Add(amount)
+	latest.BalancesRoot = svc.BalancesRootAfter(ctx, "0xalice", ChainIDEthereum, "ETH", amount)
+	if _, err := svc.SubmitCheckpoint(ctx, latest); err != nil {
+		t.Fatalf("SubmitCheckpoint failed: %v", err)
+	}
+	if _, err := svc.submitSnapshot(ctx, snapshots[0]); !errors.Is(err, errSnapshotStale) {
+		t.Errorf("Expected errSnapshotStale, got %v", err)
+	}
+}
+
+func TestNewCheckpointerFromEnv(t *testing.T) {
+	svc := NewService(zap.NewNop().Sugar())
+	logger := zap.NewNop().Sugar()
+
+	t.Setenv("LFS_CHECKPOINT_INTERVAL", "")
+	if c, err := NewCheckpointerFromEnv(svc, nil, nil, logger); err != nil || c == nil || c.interval != defaultCheckpointInterval {
+		t.Errorf("Expected the default interval, got %+v (%v)", c, err)
+	}
+	t.Setenv("LFS_CHECKPOINT_INTERVAL", "30s")
+	if c, err := NewCheckpointerFromEnv(svc, nil, nil, logger); err != nil || c == nil || c.interval != 30*time.Second {
+		t.Errorf("Expected a 30s interval, got %+v (%v)", c, err)
+	}
+	t.Setenv("LFS_CHECKPOINT_INTERVAL", "0")
+	if c, err := NewCheckpointerFromEnv(svc, nil, nil, logger); err != nil || c != nil {
+		t.Errorf("Expected 0 to disable the checkpointer, got %+v (%v)", c, err)
+	}
+	t.Setenv("LFS_CHECKPOINT_INTERVAL", "often")
+	if _, err := NewCheckpointerFromEnv(svc, nil, nil, logger); err == nil {
+		t.Error("Expected an invalid interval to fail")
+	}
+}
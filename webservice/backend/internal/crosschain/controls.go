@@ -0,0 +1,263 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrBridgePaused is returned for deposits and redemptions an operator
+	// has paused
+	ErrBridgePaused = errors.New("bridge paused")
+
+	// ErrLimitExceeded is wrapped by LimitError
+	ErrLimitExceeded = errors.New("bridge limit exceeded")
+)
+
+// BridgeOperation names the bridge flows an operator can pause.
+type BridgeOperation string
+
+const (
+	BridgeOpDeposit BridgeOperation = "deposit"
+	BridgeOpRedeem  BridgeOperation = "redeem"
+)
+
+// Deposit limits checked by CheckDeposit
+const (
+	LimitMaxDeposit   = "max_deposit"
+	LimitDailyDeposit = "daily_deposit"
+)
+
+// LimitError is returned for a deposit that exceeds a limit of its asset.
+type LimitError struct {
+	ChainID   ChainID
+	Asset     string
+	Limit     string          // LimitMaxDeposit or LimitDailyDeposit
+	Max       decimal.Decimal // The configured limit
+	Requested decimal.Decimal // The deposit, plus the day's volume for LimitDailyDeposit
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %s:%s exceeded: %s over %s", e.Limit, e.ChainID, e.Asset, e.Requested, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// BridgeControl holds the operator pauses of a scope and, for an asset,
+// its deposit limits and fees. A control without ChainID covers every
+// chain, one without Asset every asset of its chain.
+type BridgeControl struct {
+	ChainID           ChainID `json:"chainId,omitempty"`
+	Asset             string  `json:"asset,omitempty"`
+	DepositsPaused    bool    `json:"depositsPaused"`
+	RedemptionsPaused bool    `json:"redemptionsPaused"`
+
+	// Limits in asset units; zero means none
+	MaxDeposit        decimal.Decimal `json:"maxDeposit"`
+	DailyDepositLimit decimal.Decimal `json:"dailyDepositLimit"`
+
+	// DailyVolume is what was deposited on VolumeDay (UTC, YYYY-MM-DD)
+	DailyVolume decimal.Decimal `json:"dailyVolume"`
+	VolumeDay   string          `json:"volumeDay,omitempty"`
+
+	// Fees in basis points of deposits and payouts, and the fees
+	// collected, in asset units
+	MintFeeBps   int             `json:"mintFeeBps"`
+	RedeemFeeBps int             `json:"redeemFeeBps"`
+	MintFees     decimal.Decimal `json:"mintFees"`
+	RedeemFees   decimal.Decimal `json:"redeemFees"`
+
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func controlKey(chainID ChainID, asset string) string {
+	if chainID == "" {
+		return "*"
+	}
+	if asset == "" {
+		return fmt.Sprintf("%s:*", chainID)
+	}
+	return fmt.Sprintf("%s:%s", chainID, asset)
+}
+
+// scopeName describes the scope of a control in errors and logs
+func scopeName(chainID ChainID, asset string) string {
+	if chainID == "" {
+		return "all chains"
+	}
+	return controlKey(chainID, asset)
+}
+
+func volumeDay(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+// volumeOn returns the volume of control on day
+func (c *BridgeControl) volumeOn(day string) decimal.Decimal {
+	if c.VolumeDay != day {
+		return decimal.Zero
+	}
+	return c.DailyVolume
+}
+
+// ListBridgeControls returns every control, widest scope first.
+func (s *Service) ListBridgeControls(_ context.Context) []*BridgeControl {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	controls := make([]*BridgeControl, 0, len(s.controls))
+	for _, control := range s.controls {
+		copied := *control
+		controls = append(controls, &copied)
+	}
+	sort.Slice(controls, func(i, j int) bool {
+		if controls[i].ChainID != controls[j].ChainID {
+			return controls[i].ChainID < controls[j].ChainID
+		}
+		return controls[i].Asset < controls[j].Asset
+	})
+	return controls
+}
+
+// SetPaused pauses or resumes op for every chain, one chain or one asset
+// of a chain. An empty op applies to deposits and redemptions alike.
+func (s *Service) SetPaused(ctx context.Context, chainID ChainID, asset string, op BridgeOperation, paused bool) (*BridgeControl, error) {
+	if chainID == "" && asset != "" {
+		return nil, fmt.Errorf("%w: asset needs a chainId", ErrInvalidRequest)
+	}
+	switch op {
+	case "", BridgeOpDeposit, BridgeOpRedeem:
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidRequest, op)
+	}
+
+	return s.updateControl(ctx, chainID, asset, func(c *BridgeControl) {
+		if op != BridgeOpRedeem {
+			c.DepositsPaused = paused
+		}
+		if op != BridgeOpDeposit {
+			c.RedemptionsPaused = paused
+		}
+	})
+}
+
+// SetDepositLimits sets the largest single deposit and the daily deposit
+// volume of an asset. Zero removes a limit.
+func (s *Service) SetDepositLimits(ctx context.Context, chainID ChainID, asset string, maxDeposit, dailyLimit decimal.Decimal) (*BridgeControl, error) {
+	if chainID == "" || asset == "" {
+		return nil, fmt.Errorf("%w: limits need a chainId and asset", ErrInvalidRequest)
+	}
+	if maxDeposit.IsNegative() || dailyLimit.IsNegative() {
+		return nil, fmt.Errorf("%w: limits must not be negative", ErrInvalidRequest)
+	}
+
+	return s.updateControl(ctx, chainID, asset, func(c *BridgeControl) {
+		c.MaxDeposit = maxDeposit
+		c.DailyDepositLimit = dailyLimit
+	})
+}
+
+// updateControl applies change to a copy of the control of a scope and
+// saves it
+func (s *Service) updateControl(ctx context.Context, chainID ChainID, asset string, change func(*BridgeControl)) (*BridgeControl, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := controlKey(chainID, asset)
+	next := BridgeControl{ChainID: chainID, Asset: asset}
+	if control, ok := s.controls[key]; ok {
+		next = *control
+	}
+	change(&next)
+	next.UpdatedAt = time.Now()
+
+	if s.store != nil {
+		if err := s.store.saveControl(ctx, &next); err != nil {
+			return nil, err
+		}
+	}
+	s.controls[key] = &next
+
+	copied := next
+	return &copied, nil
+}
+
+// pausedLocked reports whether op is paused for chainID and asset by any
+// control covering them, and by which
+func (s *Service) pausedLocked(chainID ChainID, asset string, op BridgeOperation) (string, bool) {
+	for _, scope := range []struct {
+		chainID ChainID
+		asset   string
+	}{{"", ""}, {chainID, ""}, {chainID, asset}} {
+		control, ok := s.controls[controlKey(scope.chainID, scope.asset)]
+		if !ok {
+			continue
+		}
+		if (op == BridgeOpDeposit && control.DepositsPaused) || (op == BridgeOpRedeem && control.RedemptionsPaused) {
+			return scopeName(scope.chainID, scope.asset), true
+		}
+	}
+	return "", false
+}
+
+// CheckDeposit returns ErrBridgePaused when deposits of chainID and asset
+// are paused, and a *LimitError when amount exceeds one of their limits.
+func (s *Service) CheckDeposit(_ context.Context, chainID ChainID, asset string, amount decimal.Decimal) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if scope, paused := s.pausedLocked(chainID, asset, BridgeOpDeposit); paused {
+		return fmt.Errorf("%w: deposits on %s", ErrBridgePaused, scope)
+	}
+	control, ok := s.controls[controlKey(chainID, asset)]
+	if !ok {
+		return nil
+	}
+	if control.MaxDeposit.IsPositive() && amount.GreaterThan(control.MaxDeposit) {
+		return &LimitError{ChainID: chainID, Asset: asset, Limit: LimitMaxDeposit, Max: control.MaxDeposit, Requested: amount}
+	}
+	if control.DailyDepositLimit.IsPositive() {
+		total := control.volumeOn(volumeDay(time.Now())).Add(amount)
+		if total.GreaterThan(control.DailyDepositLimit) {
+			return &LimitError{ChainID: chainID, Asset: asset, Limit: LimitDailyDeposit, Max: control.DailyDepositLimit, Requested: total}
+		}
+	}
+	return nil
+}
+
+// CheckRedeem returns ErrBridgePaused when redemptions of chainID and
+// asset are paused.
+func (s *Service) CheckRedeem(_ context.Context, chainID ChainID, asset string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if scope, paused := s.pausedLocked(chainID, asset, BridgeOpRedeem); paused {
+		return fmt.Errorf("%w: redemptions on %s", ErrBridgePaused, scope)
+	}
+	return nil
+}
+
+// recordDepositVolume adds a credited deposit to the day's volume of its
+// asset. Only assets with a control keep a volume.
+func (s *Service) recordDepositVolume(ctx context.Context, chainID ChainID, asset string, amount decimal.Decimal) error {
+	s.mu.RLock()
+	_, ok := s.controls[controlKey(chainID, asset)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	_, err := s.updateControl(ctx, chainID, asset, func(c *BridgeControl) {
+		day := volumeDay(time.Now())
+		c.DailyVolume = c.volumeOn(day).Add(amount)
+		c.VolumeDay = day
+	})
+	return err
+}
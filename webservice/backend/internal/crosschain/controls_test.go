@@ -0,0 +1,103 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestBridgeControlsPauseAndLimit(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, database := newTestService(t)
+	one := decimal.RequireFromString("1")
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.CheckDeposit(ctx, ChainIDEthereum, "ETH", one); err != nil {
+				t.Fatalf("Expected deposits without controls to pass, got %v", err)
+			}
+
+			// A global pause covers every chain; a chain pause only its own
+			if _, err := svc.SetPaused(ctx, "", "", "", true); err != nil {
+				t.Fatalf("SetPaused failed: %v", err)
+			}
+			if err := svc.CheckRedeem(ctx, ChainIDBase, "ETH"); !errors.Is(err, ErrBridgePaused) {
+				t.Errorf("Expected redemptions to be paused globally, got %v", err)
+			}
+			if _, err := svc.SetPaused(ctx, "", "", "", false); err != nil {
+				t.Fatalf("SetPaused failed: %v", err)
+			}
+			if _, err := svc.SetPaused(ctx, ChainIDBase, "", BridgeOpDeposit, true); err != nil {
+				t.Fatalf("SetPaused failed: %v", err)
+			}
+			if err := svc.CheckDeposit(ctx, ChainIDBase, "ETH", one); !errors.Is(err, ErrBridgePaused) {
+				t.Errorf("Expected deposits on base to be paused, got %v", err)
+			}
+			if err := svc.CheckRedeem(ctx, ChainIDBase, "ETH"); err != nil {
+				t.Errorf("Expected redemptions on base to pass, got %v", err)
+			}
+			if err := svc.CheckDeposit(ctx, ChainIDEthereum, "ETH", one); err != nil {
+				t.Errorf("Expected deposits on ethereum to pass, got %v", err)
+			}
+
+			if _, err := svc.SetPaused(ctx, "", "ETH", "", true); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected an asset without chain to be rejected, got %v", err)
+			}
+			if _, err := svc.SetDepositLimits(ctx, ChainIDEthereum, "", one, one); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected limits without an asset to be rejected, got %v", err)
+			}
+
+			// Limits reject single deposits and days over them
+			if _, err := svc.SetDepositLimits(ctx, ChainIDEthereum, "ETH", decimal.RequireFromString("2"), decimal.RequireFromString("3")); err != nil {
+				t.Fatalf("SetDepositLimits failed: %v", err)
+			}
+			var limitErr *LimitError
+			if err := svc.CheckDeposit(ctx, ChainIDEthereum, "ETH", decimal.RequireFromString("2.5")); !errors.As(err, &limitErr) || limitErr.Limit != LimitMaxDeposit {
+				t.Errorf("Expected the max deposit to be exceeded, got %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				if err := svc.CheckDeposit(ctx, ChainIDEthereum, "ETH", one); err != nil {
+					t.Fatalf("Deposit %d: expected to pass, got %v", i, err)
+				}
+				if err := svc.recordDepositVolume(ctx, ChainIDEthereum, "ETH", one); err != nil {
+					t.Fatalf("recordDepositVolume failed: %v", err)
+				}
+			}
+			err := svc.CheckDeposit(ctx, ChainIDEthereum, "ETH", decimal.RequireFromString("0.1"))
+			if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &limitErr) || limitErr.Limit != LimitDailyDeposit {
+				t.Fatalf("Expected the daily limit to be exceeded, got %v", err)
+			}
+
+			// Yesterday's volume does not count
+			svc.mu.Lock()
+			svc.controls[controlKey(ChainIDEthereum, "ETH")].VolumeDay = "2000-01-01"
+			svc.mu.Unlock()
+			if err := svc.CheckDeposit(ctx, ChainIDEthereum, "ETH", one); err != nil {
+				t.Errorf("Expected a new day to reset the volume, got %v", err)
+			}
+
+			if controls := svc.ListBridgeControls(ctx); len(controls) != 3 || controls[0].ChainID != "" {
+				t.Errorf("Unexpected controls: %+v", controls)
+			}
+		})
+	}
+
+	// Controls survive a restart
+	restarted := NewService(logger, WithDatabase(database))
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := restarted.CheckDeposit(ctx, ChainIDBase, "ETH", one); !errors.Is(err, ErrBridgePaused) {
+		t.Errorf("Expected the base pause to be restored, got %v", err)
+	}
+	if err := restarted.CheckDeposit(ctx, ChainIDEthereum, "ETH", decimal.RequireFromString("2.5")); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected the limits to be restored, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package crosschain
+
+import (
+	"context"
+	"fmt"
+)
+
+// eip712DomainTypeHash is the type hash of domains with a name, version,
+// chain ID and verifying contract, as OpenZeppelin's EIP712 builds them
+var eip712DomainTypeHash = keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+
+// voucherTypeHash is WalrusEthVault.VOUCHER_TYPEHASH
+var voucherTypeHash = keccak256([]byte("Voucher(bytes32 voucherId,address redeemer,string suiOwner,uint256 shares,uint64 nonce,uint64 expiry,uint64 updateId)"))
+
+// EIP712Domain is the signing domain of an EIP-712 contract.
+type EIP712Domain struct {
+	Name              string
+	Version           string
+	ChainID           uint64
+	VerifyingContract string // 0x-prefixed address
+}
+
+// VaultDomain returns the EIP-712 domain of the WalrusEthVault at
+// vaultAddress on EVM chain evmChainID.
+func VaultDomain(evmChainID uint64, vaultAddress string) EIP712Domain {
+	return EIP712Domain{
+		Name:              "WalrusEthVault",
+		Version:           "1",
+		ChainID:           evmChainID,
+		VerifyingContract: vaultAddress,
+	}
+}
+
+// Separator returns the domain separator of d.
+func (d EIP712Domain) Separator() ([]byte, error) {
+	contract, err := parseEvmAddress(d.VerifyingContract)
+	if err != nil {
+		return nil, fmt.Errorf("verifying contract: %w", err)
+	}
+	return hashStruct(eip712DomainTypeHash,
+		keccak256([]byte(d.Name)),
+		keccak256([]byte(d.Version)),
+		abiUint64(d.ChainID),
+		abiAddress(contract),
+	), nil
+}
+
+// TypedDataDigest returns the digest signed for a struct with structHash
+// in the domain with domainSeparator: keccak256("\x19\x01" ||
+// domainSeparator || structHash).
+func TypedDataDigest(domainSeparator, structHash []byte) []byte {
+	return keccak256([]byte{0x19, 0x01}, domainSeparator, structHash)
+}
+
+// hashStruct hashes the type hash and the encoded members of a struct.
+// Members are 32-byte words: static values ABI encoded, and strings and
+// bytes as their keccak256.
+func hashStruct(typeHash []byte, members ...[]byte) []byte {
+	return keccak256(append([][]byte{typeHash}, members...)...)
+}
+
+// structHash returns the EIP-712 struct hash of v, as
+// WalrusEthVault._hashVoucher encodes it
+func (v voucher) structHash() []byte {
+	return hashStruct(voucherTypeHash,
+		v.VoucherID[:],
+		abiAddress(v.Redeemer),
+		keccak256([]byte(v.SuiOwner)),
+		abiUint(v.Shares),
+		abiUint64(v.Nonce),
+		abiUint64(v.Expiry),
+		abiUint64(v.UpdateID),
+	)
+}
+
+// signTypedData signs digest with signer and returns the 65-byte signature
+// with v in {27, 28}, as ECDSA.recover expects it
+func signTypedData(ctx context.Context, signer EvmSigner, digest []byte) ([]byte, error) {
+	sig, err := signer.SignDigest(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("signature must be 65 bytes, got %d", len(sig))
+	}
+	return append(append([]byte{}, sig[:64]...), sig[64]+27), nil
+}
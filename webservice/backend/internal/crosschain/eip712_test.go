@@ -0,0 +1,116 @@
+package crosschain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypedDataDigestMatchesEIP712Example(t *testing.T) {
+	// The Mail example of EIP-712 and the hashes given with it
+	domain, err := EIP712Domain{
+		Name:              "Ether Mail",
+		Version:           "1",
+		ChainID:           1,
+		VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+	}.Separator()
+	if err != nil {
+		t.Fatalf("Separator failed: %v", err)
+	}
+	if got := hex.EncodeToString(domain); got != "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f" {
+		t.Errorf("Unexpected domain separator %s", got)
+	}
+
+	personType := keccak256([]byte("Person(string name,address wallet)"))
+	person := func(name, wallet string) []byte {
+		addr, err := parseEvmAddress(wallet)
+		if err != nil {
+			t.Fatalf("parseEvmAddress failed: %v", err)
+		}
+		return hashStruct(personType, keccak256([]byte(name)), abiAddress(addr))
+	}
+	mail := hashStruct(keccak256([]byte("Mail(Person from,Person to,string contents)Person(string name,address wallet)")),
+		person("Cow", "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"),
+		person("Bob", "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"),
+		keccak256([]byte("Hello, Bob!")),
+	)
+	if got := hex.EncodeToString(mail); got != "c52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e" {
+		t.Errorf("Unexpected struct hash %s", got)
+	}
+	if got := hex.EncodeToString(TypedDataDigest(domain, mail)); got != "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2" {
+		t.Errorf("Unexpected digest %s", got)
+	}
+}
+
+func TestVoucherDigestMatchesVault(t *testing.T) {
+	// Hashes of the encoding in WalrusEthVault._hashVoucher, computed apart
+	// from this package
+	if got := hex.EncodeToString(voucherTypeHash); got != "d0eb2a3a69a2b9ecf06a3e0e051dc982a18df06f8dc94932129b0690402ff5d5" {
+		t.Errorf("Unexpected voucher type hash %s", got)
+	}
+	vault := "0x" + strings.Repeat("11", 20)
+	domain, err := VaultDomain(11155111, vault).Separator()
+	if err != nil {
+		t.Fatalf("Separator failed: %v", err)
+	}
+	if got := hex.EncodeToString(domain); got != "ba812aaefbd7fb38a55f0dc7bf8fd5124a6cfa40d26ef704e355853e36302da4" {
+		t.Errorf("Unexpected vault domain separator %s", got)
+	}
+
+	redeemer, _ := parseEvmAddress("0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266")
+	v := voucher{
+		VoucherID: sha256.Sum256([]byte("burn-digest")),
+		Redeemer:  redeemer,
+		SuiOwner:  "0xalice",
+		Shares:    big.NewInt(400_000_000_000_000_000),
+		Nonce:     1,
+		Expiry:    1700000600,
+		UpdateID:  7,
+	}
+	if got := hex.EncodeToString(v.structHash()); got != "2ad03db5113ea52cf9c54b5cfdd46bbd04ba9665243af21c8a0df8bfe2227ba0" {
+		t.Errorf("Unexpected voucher struct hash %s", got)
+	}
+	const want = "1b35348953f05642a30c043f8bbbacf8865b047c2c08d30940a73e70e1d1ff31"
+	if got := hex.EncodeToString(TypedDataDigest(domain, v.structHash())); got != want {
+		t.Errorf("Unexpected voucher digest %s", got)
+	}
+
+	// Vouchers issued by the service hash the same
+	issued := &WithdrawalVoucher{
+		VoucherID: VoucherID("burn-digest"),
+		Redeemer:  "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266",
+		SuiOwner:  "0xalice",
+		Shares:    sharesFromBaseUnits(v.Shares, nativeDecimals),
+		Nonce:     1,
+		Expiry:    time.Unix(1700000600, 0),
+		UpdateID:  7,
+	}
+	digest, err := VoucherDigest(issued, 11155111, vault, nativeDecimals)
+	if err != nil {
+		t.Fatalf("VoucherDigest failed: %v", err)
+	}
+	if got := hex.EncodeToString(digest); got != want {
+		t.Errorf("Expected the issued voucher digest %s, got %s", want, got)
+	}
+
+	// Offline signatures recover to the redeemer
+	signer, err := NewPrivateKeySigner("0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	if err != nil {
+		t.Fatalf("NewPrivateKeySigner failed: %v", err)
+	}
+	sig, err := signTypedData(context.Background(), signer, digest)
+	if err != nil {
+		t.Fatalf("signTypedData failed: %v", err)
+	}
+	if sig[64] != 27 && sig[64] != 28 {
+		t.Fatalf("Expected v of 27 or 28, got %d", sig[64])
+	}
+	recoverable := append(append([]byte{}, sig[:64]...), sig[64]-27)
+	if addr, err := recoverEvmAddress(digest, recoverable); err != nil || addr != signer.Address() {
+		t.Errorf("Expected the signature to recover to %s, got %s (%v)", signer.Address(), addr, err)
+	}
+}
@@ -0,0 +1,488 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pattonkan/sui-go/sui"
+	"go.uber.org/zap"
+)
+
+// depositEventSignature is the WalrusEthVault event emitted by deposit()
+const depositEventSignature = "Deposit(address,address,uint256,uint256,string)"
+
+// depositEventTopic is topic 0 of Deposit logs, the keccak256 of the signature
+var depositEventTopic = "0x" + hex.EncodeToString(keccak256([]byte(depositEventSignature)))
+
+const (
+	defaultDepositConfirmations = 12
+	defaultDepositPollInterval  = 12 * time.Second
+	defaultDepositBlockRange    = 2000
+	defaultDepositReorgWindow   = 64
+)
+
+// DepositListener watches an external chain for vault deposits and forwards
+// them to the worker. handle returns an error when the deposit should be
+// delivered again later. revert is called when blocks whose deposits were
+// delivered are reorganized away, before the deposits of the replacing
+// blocks are delivered.
+type DepositListener interface {
+	Start(ctx context.Context, handle func(context.Context, DepositSubmission) error, revert func(context.Context, ReorgEvent) error) error
+}
+
+// EthDepositListenerConfig configures an EthDepositListener.
+type EthDepositListenerConfig struct {
+	ChainID      ChainID // Default: ChainIDEthereum
+	RPCURL       string
+	VaultAddress string // Vault of native asset deposits
+	NativeAsset  string // Default: ETH
+
+	// TokenVaults are ERC-20 vaults emitting the same Deposit event, with
+	// amounts in token base units. Each needs VaultAddress, Asset and Decimals.
+	TokenVaults []VaultInfo
+
+	// Confirmations is how many blocks must follow a deposit's block before
+	// it is submitted, so that reorged deposits are not bridged.
+	Confirmations uint64
+
+	// PollInterval is the time between eth_getLogs polls. Default: 12s.
+	PollInterval time.Duration
+
+	// StartBlock is the first block scanned. Zero starts at the latest
+	// confirmed block.
+	StartBlock uint64
+
+	// MaxBlockRange caps the blocks queried per eth_getLogs call. Default: 2000.
+	MaxBlockRange uint64
+
+	// ReorgWindow is how many blocks below the head are checked for
+	// reorgs. Default: 64.
+	ReorgWindow uint64
+}
+
+// EthDepositListener polls the WalrusEthVault for Deposit events with
+// eth_getLogs and submits each confirmed deposit to the bridge worker. The
+// Sui owner is taken from the deposit's suiOwner memo.
+//
+// The hashes of the blocks holding deposits and of the last block of each
+// scanned range are remembered within the reorg window. When one of them
+// is no longer canonical, the deposits from the fork point on are reverted
+// and the blocks are scanned again.
+type EthDepositListener struct {
+	cfg     EthDepositListenerConfig
+	rpc     *ethRPC
+	logger  *zap.SugaredLogger
+	vaults  map[string]VaultInfo // By lower-case vault address
+	next    uint64               // Next block to scan; zero until the first poll
+	scanned map[uint64]string    // Remembered block hashes by number
+}
+
+// NewEthDepositListener returns a listener for the vault in cfg.
+func NewEthDepositListener(cfg EthDepositListenerConfig, logger *zap.SugaredLogger) (*EthDepositListener, error) {
+	if cfg.RPCURL == "" || cfg.VaultAddress == "" {
+		return nil, fmt.Errorf("deposit listener requires an RPC URL and vault address")
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultDepositPollInterval
+	}
+	if cfg.MaxBlockRange == 0 {
+		cfg.MaxBlockRange = defaultDepositBlockRange
+	}
+	if cfg.ReorgWindow == 0 {
+		cfg.ReorgWindow = defaultDepositReorgWindow
+	}
+	if cfg.ChainID == "" {
+		cfg.ChainID = ChainIDEthereum
+	}
+	if cfg.NativeAsset == "" {
+		cfg.NativeAsset = "ETH"
+	}
+
+	vaults := map[string]VaultInfo{
+		strings.ToLower(cfg.VaultAddress): {ChainID: cfg.ChainID, Asset: cfg.NativeAsset, VaultAddress: cfg.VaultAddress, Decimals: nativeDecimals},
+	}
+	for _, vault := range cfg.TokenVaults {
+		address := strings.ToLower(vault.VaultAddress)
+		if address == "" || vault.Asset == "" {
+			return nil, fmt.Errorf("token vault requires an address and asset")
+		}
+		if _, ok := vaults[address]; ok {
+			return nil, fmt.Errorf("vault %s is configured twice", vault.VaultAddress)
+		}
+		vault.ChainID = cfg.ChainID
+		vaults[address] = vault
+	}
+
+	return &EthDepositListener{
+		cfg:     cfg,
+		rpc:     newEthRPC(cfg.RPCURL),
+		logger:  logger.With("chainId", cfg.ChainID),
+		vaults:  vaults,
+		next:    cfg.StartBlock,
+		scanned: make(map[uint64]string),
+	}, nil
+}
+
+// NewEthDepositListenersFromEnv returns a listener for each chain of the
+// registry (see chainsFromEnv) when LFS_ENABLE_BRIDGE_DEPOSITS=1. ERC-20
+// vaults are watched on Ethereum. LFS_ETH_DEPOSIT_POLL_INTERVAL and
+// LFS_ETH_DEPOSIT_REORG_WINDOW apply to every chain.
+func NewEthDepositListenersFromEnv(logger *zap.SugaredLogger) ([]*EthDepositListener, error) {
+	if !isTruthy(os.Getenv("LFS_ENABLE_BRIDGE_DEPOSITS")) {
+		return nil, nil
+	}
+
+	listeners, err := ethDepositListenersFromEnv(logger)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listeners {
+		logger.Infow("Bridge deposit listener enabled",
+			"chainId", l.cfg.ChainID,
+			"rpc", l.cfg.RPCURL,
+			"vault", l.cfg.VaultAddress,
+			"nativeAsset", l.cfg.NativeAsset,
+			"tokenVaults", len(l.cfg.TokenVaults),
+			"confirmations", l.cfg.Confirmations,
+			"pollInterval", l.cfg.PollInterval,
+			"startBlock", l.cfg.StartBlock,
+			"reorgWindow", l.cfg.ReorgWindow,
+		)
+	}
+	return listeners, nil
+}
+
+// NewEthDepositListenerFromEnv returns the listener of chainID as
+// NewEthDepositListenersFromEnv configures it, whether or not
+// LFS_ENABLE_BRIDGE_DEPOSITS is set. It is meant for replays.
+func NewEthDepositListenerFromEnv(chainID ChainID, logger *zap.SugaredLogger) (*EthDepositListener, error) {
+	listeners, err := ethDepositListenersFromEnv(logger)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listeners {
+		if l.cfg.ChainID == chainID {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("bridge chain %s is not configured", chainID)
+}
+
+// ethDepositListenersFromEnv returns a listener for each chain of the registry
+func ethDepositListenersFromEnv(logger *zap.SugaredLogger) ([]*EthDepositListener, error) {
+	chains, err := chainsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if len(chains) == 0 {
+		return nil, fmt.Errorf("deposit listener enabled but no chain is configured; set LFS_ETH_RPC_URL or LFS_BRIDGE_CHAINS")
+	}
+	tokens, err := tokenVaultsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var pollInterval time.Duration
+	if v := envOrDefault("", "LFS_ETH_DEPOSIT_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LFS_ETH_DEPOSIT_POLL_INTERVAL %q: %w", v, err)
+		}
+		pollInterval = d
+	}
+	var reorgWindow uint64
+	if err := parseUintEnv("LFS_ETH_DEPOSIT_REORG_WINDOW", &reorgWindow); err != nil {
+		return nil, err
+	}
+
+	listeners := make([]*EthDepositListener, 0, len(chains))
+	for _, chain := range chains {
+		cfg := EthDepositListenerConfig{
+			ChainID:       chain.ChainID,
+			RPCURL:        chain.RPCURL,
+			VaultAddress:  chain.VaultAddress,
+			NativeAsset:   chain.NativeAsset,
+			Confirmations: chain.Confirmations,
+			PollInterval:  pollInterval,
+			StartBlock:    chain.StartBlock,
+			ReorgWindow:   reorgWindow,
+		}
+		if chain.ChainID == ChainIDEthereum {
+			cfg.TokenVaults = tokens
+		}
+
+		l, err := NewEthDepositListener(cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", chain.ChainID, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// Start polls for deposits until ctx is done.
+func (l *EthDepositListener) Start(ctx context.Context, handle func(context.Context, DepositSubmission) error, revert func(context.Context, ReorgEvent) error) error {
+	if l == nil || handle == nil || revert == nil {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(l.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			if err := l.poll(ctx, handle, revert); err != nil && ctx.Err() == nil {
+				l.logger.Warnw("Bridge deposit poll failed; retrying", "error", err, "fromBlock", l.next)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// poll submits the deposits of the confirmed blocks not scanned yet. A
+// range is only marked scanned once all its deposits are handled, so a
+// failed range is scanned again, deposits the worker already processed
+// included; the worker returns their original receipts. Reorgs are checked
+// for before scanning.
+func (l *EthDepositListener) poll(ctx context.Context, handle func(context.Context, DepositSubmission) error, revert func(context.Context, ReorgEvent) error) error {
+	head, err := l.blockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("eth_blockNumber: %w", err)
+	}
+	if head < l.cfg.Confirmations {
+		return nil
+	}
+	confirmed := head - l.cfg.Confirmations
+	if l.next == 0 {
+		l.next = confirmed
+	}
+	if err := l.checkReorg(ctx, revert); err != nil {
+		return err
+	}
+
+	for l.next <= confirmed {
+		to := l.next + l.cfg.MaxBlockRange - 1
+		if to > confirmed {
+			to = confirmed
+		}
+
+		deposits, err := l.deposits(ctx, l.next, to)
+		if err != nil {
+			return err
+		}
+		for _, sub := range deposits {
+			if err := handle(ctx, sub); err != nil {
+				if errors.Is(err, ErrInvalidRequest) {
+					l.logger.Warnw("Skipping invalid vault deposit", "error", err, "txHash", sub.TxHash, "logIndex", sub.LogIndex)
+					continue
+				}
+				return fmt.Errorf("submit deposit %s/%d: %w", sub.TxHash, sub.LogIndex, err)
+			}
+			l.scanned[sub.BlockNumber] = sub.BlockHash
+		}
+
+		hash, err := l.blockHash(ctx, to)
+		if err != nil {
+			return fmt.Errorf("eth_getBlockByNumber %d: %w", to, err)
+		}
+		l.scanned[to] = hash
+		l.next = to + 1
+	}
+
+	// Forget blocks below the window, but the newest scanned block
+	for n := range l.scanned {
+		if n+l.cfg.ReorgWindow < head && n != l.next-1 {
+			delete(l.scanned, n)
+		}
+	}
+	return nil
+}
+
+// deposits returns the deposits logged by the vaults from block from to
+// block to. Logs of unknown vaults and malformed logs are skipped.
+func (l *EthDepositListener) deposits(ctx context.Context, from, to uint64) ([]DepositSubmission, error) {
+	logs, err := l.getLogs(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("eth_getLogs %d-%d: %w", from, to, err)
+	}
+	var deposits []DepositSubmission
+	for _, entry := range logs {
+		if entry.Removed {
+			continue
+		}
+		vault, ok := l.vaults[strings.ToLower(entry.Address)]
+		if !ok {
+			l.logger.Warnw("Skipping deposit of an unknown vault", "address", entry.Address, "txHash", entry.TxHash)
+			continue
+		}
+		sub, err := parseDepositLog(entry, vault)
+		if err != nil {
+			l.logger.Warnw("Skipping malformed vault deposit", "error", err, "txHash", entry.TxHash, "logIndex", entry.LogIndex)
+			continue
+		}
+		deposits = append(deposits, sub)
+	}
+	return deposits, nil
+}
+
+// checkReorg compares the remembered block hashes with the canonical chain,
+// newest first: once a block matches, its ancestors match too. On a
+// mismatch, the deposits after the newest matching block are reverted and
+// scanning resumes there.
+func (l *EthDepositListener) checkReorg(ctx context.Context, revert func(context.Context, ReorgEvent) error) error {
+	blocks := make([]uint64, 0, len(l.scanned))
+	for n := range l.scanned {
+		blocks = append(blocks, n)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] > blocks[j] })
+
+	var reorged, canonical uint64
+	for _, n := range blocks {
+		hash, err := l.blockHash(ctx, n)
+		if err != nil {
+			return fmt.Errorf("eth_getBlockByNumber %d: %w", n, err)
+		}
+		if strings.EqualFold(hash, l.scanned[n]) {
+			canonical = n
+			break
+		}
+		reorged = n
+	}
+	if reorged == 0 {
+		return nil
+	}
+
+	from := reorged
+	if canonical > 0 {
+		from = canonical + 1
+	} else {
+		l.logger.Errorw("Reorg reaches below the remembered blocks; reverting from the oldest", "fromBlock", from)
+	}
+	l.logger.Warnw("Vault chain reorg detected; reverting deposits", "fromBlock", from)
+	if err := revert(ctx, ReorgEvent{ChainID: l.cfg.ChainID, FromBlock: from}); err != nil {
+		return fmt.Errorf("revert deposits from block %d: %w", from, err)
+	}
+
+	for n := range l.scanned {
+		if n >= from {
+			delete(l.scanned, n)
+		}
+	}
+	if l.next > from {
+		l.next = from
+	}
+	return nil
+}
+
+// ethLog is a log entry as returned by eth_getLogs
+type ethLog struct {
+	Address     string   `json:"address"`
+	Topics      []string `json:"topics"`
+	Data        string   `json:"data"`
+	TxHash      string   `json:"transactionHash"`
+	LogIndex    string   `json:"logIndex"`
+	BlockNumber string   `json:"blockNumber"`
+	BlockHash   string   `json:"blockHash"`
+	Removed     bool     `json:"removed"`
+}
+
+// parseDepositLog decodes a Deposit log of vault. The non-indexed fields are
+// ABI encoded as (uint256 assets, uint256 shares, string suiOwner), assets
+// in base units of the vault's asset.
+func parseDepositLog(entry ethLog, vault VaultInfo) (DepositSubmission, error) {
+	if len(entry.Topics) == 0 || !strings.EqualFold(entry.Topics[0], depositEventTopic) {
+		return DepositSubmission{}, fmt.Errorf("not a Deposit event")
+	}
+	logIndex, err := strconv.ParseUint(strings.TrimPrefix(entry.LogIndex, "0x"), 16, 64)
+	if err != nil {
+		return DepositSubmission{}, fmt.Errorf("invalid log index %q", entry.LogIndex)
+	}
+	blockNumber, err := strconv.ParseUint(strings.TrimPrefix(entry.BlockNumber, "0x"), 16, 64)
+	if err != nil || entry.BlockHash == "" {
+		return DepositSubmission{}, fmt.Errorf("invalid block %q (%q)", entry.BlockNumber, entry.BlockHash)
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(entry.Data, "0x"))
+	if err != nil {
+		return DepositSubmission{}, fmt.Errorf("invalid log data: %w", err)
+	}
+	if len(data) < 4*32 {
+		return DepositSubmission{}, fmt.Errorf("log data too short (%d bytes)", len(data))
+	}
+
+	assets := new(big.Int).SetBytes(data[0:32])
+	offset := new(big.Int).SetBytes(data[64:96])
+	if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(data)) {
+		return DepositSubmission{}, fmt.Errorf("suiOwner offset out of range")
+	}
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(data[start-32 : start])
+	if !length.IsUint64() || start+length.Uint64() > uint64(len(data)) {
+		return DepositSubmission{}, fmt.Errorf("suiOwner length out of range")
+	}
+	memo := strings.TrimSpace(string(data[start : start+length.Uint64()]))
+
+	owner, err := sui.AddressFromHex(memo)
+	if err != nil {
+		return DepositSubmission{}, fmt.Errorf("suiOwner memo %q is not a Sui address: %w", memo, err)
+	}
+
+	return DepositSubmission{
+		TxHash:   strings.ToLower(entry.TxHash),
+		LogIndex: logIndex,
+		SuiOwner: owner.String(),
+		ChainID:  vault.ChainID,
+		Asset:    vault.Asset,
+		Amount:   fromBaseUnits(assets, vault.Decimals),
+
+		BlockNumber: blockNumber,
+		BlockHash:   strings.ToLower(entry.BlockHash),
+	}, nil
+}
+
+func (l *EthDepositListener) blockNumber(ctx context.Context) (uint64, error) {
+	return l.rpc.callUint64(ctx, "eth_blockNumber", []interface{}{})
+}
+
+// blockHash returns the canonical hash of block n, or "" if the chain does
+// not reach n
+func (l *EthDepositListener) blockHash(ctx context.Context, n uint64) (string, error) {
+	var block *struct {
+		Hash string `json:"hash"`
+	}
+	if err := l.rpc.call(ctx, "eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", n), false}, &block); err != nil {
+		return "", err
+	}
+	if block == nil {
+		return "", nil
+	}
+	return strings.ToLower(block.Hash), nil
+}
+
+func (l *EthDepositListener) getLogs(ctx context.Context, from, to uint64) ([]ethLog, error) {
+	var logs []ethLog
+	addresses := make([]string, 0, len(l.vaults))
+	for address := range l.vaults {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	err := l.rpc.call(ctx, "eth_getLogs", []interface{}{map[string]interface{}{
+		"address":   addresses,
+		"fromBlock": fmt.Sprintf("0x%x", from),
+		"toBlock":   fmt.Sprintf("0x%x", to),
+		"topics":    []string{depositEventTopic},
+	}}, &logs)
+	return logs, err
+}
@@ -0,0 +1,340 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// depositLogData ABI encodes the non-indexed Deposit fields
+func depositLogData(assets *big.Int, suiOwner string) string {
+	word := func(n *big.Int) []byte {
+		return n.FillBytes(make([]byte, 32))
+	}
+	data := append(word(assets), word(big.NewInt(1))...)
+	data = append(data, word(big.NewInt(96))...)
+	data = append(data, word(big.NewInt(int64(len(suiOwner))))...)
+	padded := make([]byte, (len(suiOwner)+31)/32*32)
+	copy(padded, suiOwner)
+	return "0x" + hex.EncodeToString(append(data, padded...))
+}
+
+// fakeEthNode serves eth_blockNumber, eth_getBlockByNumber and eth_getLogs
+// for logs by block. Blocks from fork on have hashes of a reorganized chain.
+type fakeEthNode struct {
+	head    uint64
+	fork    uint64
+	logs    map[uint64][]ethLog
+	queries [][2]uint64
+}
+
+func (n *fakeEthNode) hash(block uint64) string {
+	if n.fork > 0 && block >= n.fork {
+		return fmt.Sprintf("0xf%dx%d", n.fork, block)
+	}
+	return fmt.Sprintf("0xa%d", block)
+}
+
+func (n *fakeEthNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "eth_blockNumber":
+		result = fmt.Sprintf("0x%x", n.head)
+	case "eth_getBlockByNumber":
+		var number string
+		_ = json.Unmarshal(req.Params[0], &number)
+		block, _ := strconv.ParseUint(strings.TrimPrefix(number, "0x"), 16, 64)
+		result = map[string]string{"hash": n.hash(block)}
+	case "eth_getLogs":
+		var filter struct {
+			FromBlock string `json:"fromBlock"`
+			ToBlock   string `json:"toBlock"`
+		}
+		_ = json.Unmarshal(req.Params[0], &filter)
+		from, _ := strconv.ParseUint(strings.TrimPrefix(filter.FromBlock, "0x"), 16, 64)
+		to, _ := strconv.ParseUint(strings.TrimPrefix(filter.ToBlock, "0x"), 16, 64)
+		n.queries = append(n.queries, [2]uint64{from, to})
+		logs := []ethLog{}
+		for block := from; block <= to; block++ {
+			for _, entry := range n.logs[block] {
+				entry.BlockNumber = fmt.Sprintf("0x%x", block)
+				entry.BlockHash = n.hash(block)
+				logs = append(logs, entry)
+			}
+		}
+		result = logs
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": result})
+}
+
+func TestEthDepositListenerSubmitsConfirmedDeposits(t *testing.T) {
+	ctx := context.Background()
+	owner := "0x" + strings.Repeat("ab", 32)
+	oneEth := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	noReorg := func(_ context.Context, ev ReorgEvent) error {
+		t.Errorf("Unexpected reorg %+v", ev)
+		return nil
+	}
+	deposit := func(txHash string, logIndex int, memo string) ethLog {
+		return ethLog{
+			Address:  "0xvault",
+			Topics:   []string{depositEventTopic, "0x01", "0x02"},
+			Data:     depositLogData(oneEth, memo),
+			TxHash:   txHash,
+			LogIndex: fmt.Sprintf("0x%x", logIndex),
+		}
+	}
+
+	node := &fakeEthNode{
+		head: 120,
+		logs: map[uint64][]ethLog{
+			101: {deposit("0xAA", 0, owner), deposit("0xAA", 1, "not-an-address")},
+			105: {deposit("0xBB", 3, "  "+owner+" ")},
+			108: {
+				{Address: "0xUSDCVault", Topics: []string{depositEventTopic, "0x01", "0x02"}, Data: depositLogData(big.NewInt(2_500_000), owner), TxHash: "0xDD", LogIndex: "0x0"},
+				{Address: "0xother", Topics: []string{depositEventTopic, "0x01", "0x02"}, Data: depositLogData(oneEth, owner), TxHash: "0xEE", LogIndex: "0x0"},
+			},
+			115: {deposit("0xCC", 0, owner)}, // Not yet confirmed
+		},
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	l, err := NewEthDepositListener(EthDepositListenerConfig{
+		RPCURL:        server.URL,
+		VaultAddress:  "0xVault",
+		Confirmations: 10,
+		StartBlock:    100,
+		MaxBlockRange: 4,
+		TokenVaults:   []VaultInfo{{Asset: "USDC", VaultAddress: "0xusdcvault", Decimals: 6}},
+	}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewEthDepositListener failed: %v", err)
+	}
+
+	var submitted []DepositSubmission
+	fail := true
+	handle := func(_ context.Context, sub DepositSubmission) error {
+		submitted = append(submitted, sub)
+		if sub.TxHash == "0xbb" && fail {
+			fail = false
+			return errors.New("price feed down")
+		}
+		return nil
+	}
+
+	// The failed range is left for the next poll
+	if err := l.poll(ctx, handle, noReorg); err == nil {
+		t.Fatal("Expected the failed submission to fail the poll")
+	}
+	if l.next != 104 {
+		t.Fatalf("Expected to resume at block 104, got %d", l.next)
+	}
+
+	submitted = nil
+	if err := l.poll(ctx, handle, noReorg); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if l.next != 111 {
+		t.Errorf("Expected blocks up to 110 to be scanned, got next %d", l.next)
+	}
+	if len(submitted) != 2 {
+		t.Fatalf("Expected the retried deposit and the USDC deposit, got %+v", submitted)
+	}
+	want := DepositSubmission{
+		TxHash:      "0xbb",
+		LogIndex:    3,
+		SuiOwner:    owner,
+		ChainID:     ChainIDEthereum,
+		Asset:       "ETH",
+		Amount:      decimal.RequireFromString("1"),
+		BlockNumber: 105,
+		BlockHash:   "0xa105",
+	}
+	got := submitted[0]
+	if got.TxHash != want.TxHash || got.LogIndex != want.LogIndex || got.SuiOwner != want.SuiOwner || !got.Amount.Equal(want.Amount) || got.ChainID != want.ChainID || got.BlockNumber != want.BlockNumber || got.BlockHash != want.BlockHash {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	// Token amounts are converted with the decimals of their vault
+	if usdc := submitted[1]; usdc.TxHash != "0xdd" || usdc.Asset != "USDC" || !usdc.Amount.Equal(decimal.RequireFromString("2.5")) {
+		t.Errorf("Expected a 2.5 USDC deposit, got %+v", usdc)
+	}
+	for _, q := range node.queries {
+		if q[1] > 110 || q[1]-q[0] >= 4 {
+			t.Errorf("Queried unconfirmed or oversized range %v", q)
+		}
+	}
+
+	// Once confirmed, later deposits are picked up
+	node.head = 125
+	submitted = nil
+	if err := l.poll(ctx, handle, noReorg); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(submitted) != 1 || submitted[0].TxHash != "0xcc" {
+		t.Errorf("Expected the deposit in block 115, got %+v", submitted)
+	}
+}
+
+func TestEthDepositListenerRevertsReorgedBlocks(t *testing.T) {
+	ctx := context.Background()
+	owner := "0x" + strings.Repeat("ab", 32)
+	oneEth := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	deposit := ethLog{
+		Address:  "0xvault",
+		Topics:   []string{depositEventTopic, "0x01", "0x02"},
+		Data:     depositLogData(oneEth, owner),
+		TxHash:   "0xAA",
+		LogIndex: "0x0",
+	}
+
+	node := &fakeEthNode{head: 120, logs: map[uint64][]ethLog{105: {deposit}}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	l, err := NewEthDepositListener(EthDepositListenerConfig{
+		RPCURL:        server.URL,
+		VaultAddress:  "0xVault",
+		Confirmations: 10,
+		StartBlock:    100,
+		MaxBlockRange: 4,
+	}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewEthDepositListener failed: %v", err)
+	}
+
+	var submitted []DepositSubmission
+	var reverts []ReorgEvent
+	handle := func(_ context.Context, sub DepositSubmission) error {
+		submitted = append(submitted, sub)
+		return nil
+	}
+	revert := func(_ context.Context, ev ReorgEvent) error {
+		reverts = append(reverts, ev)
+		return nil
+	}
+
+	if err := l.poll(ctx, handle, revert); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(submitted) != 1 || len(reverts) != 0 {
+		t.Fatalf("Expected one deposit and no reorg, got %+v and %+v", submitted, reverts)
+	}
+
+	// Blocks from 105 on are replaced, and the deposit moves to block 107
+	node.fork = 105
+	node.logs = map[uint64][]ethLog{107: {deposit}}
+	node.head = 121
+	submitted = nil
+	if err := l.poll(ctx, handle, revert); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	// The range 100-103 is still canonical, so the rollback starts after it
+	if len(reverts) != 1 || reverts[0].FromBlock != 104 || reverts[0].ChainID != ChainIDEthereum {
+		t.Fatalf("Expected a rollback from block 104, got %+v", reverts)
+	}
+	if len(submitted) != 1 || submitted[0].BlockNumber != 107 || submitted[0].BlockHash != "0xf105x107" {
+		t.Errorf("Expected the deposit to be submitted again from block 107, got %+v", submitted)
+	}
+	if l.next != 112 {
+		t.Errorf("Expected blocks up to 111 to be scanned, got next %d", l.next)
+	}
+
+	// A failed rollback is retried before scanning further
+	node.fork = 110
+	node.head = 125
+	failing := func(context.Context, ReorgEvent) error { return errors.New("database down") }
+	if err := l.poll(ctx, handle, failing); err == nil {
+		t.Fatal("Expected the failed rollback to fail the poll")
+	}
+	if l.next != 112 {
+		t.Errorf("Expected scanning to wait for the rollback, got next %d", l.next)
+	}
+}
+
+func TestNewEthDepositListenersFromEnv(t *testing.T) {
+	owner := "0x" + strings.Repeat("ab", 32)
+	t.Setenv("LFS_ENABLE_BRIDGE_DEPOSITS", "1")
+	t.Setenv("LFS_ETH_RPC_URL", "http://eth.local")
+	t.Setenv("LFS_CROSSCHAIN_VAULT_ADDRESS", "0xethvault")
+	t.Setenv("LFS_BRIDGE_TOKENS", "USDC")
+	t.Setenv("LFS_BRIDGE_USDC_TOKEN_ADDRESS", "0xusdc")
+	t.Setenv("LFS_BRIDGE_USDC_VAULT_ADDRESS", "0xusdcvault")
+	t.Setenv("LFS_BRIDGE_USDC_DECIMALS", "6")
+	t.Setenv("LFS_BRIDGE_USDC_PRICE_SYMBOL", "USDCUSDT")
+	t.Setenv("LFS_BRIDGE_CHAINS", "Arbitrum, polygon")
+	t.Setenv("LFS_BRIDGE_ARBITRUM_RPC_URL", "http://arbitrum.local")
+	t.Setenv("LFS_BRIDGE_ARBITRUM_VAULT_ADDRESS", "0xarbvault")
+	t.Setenv("LFS_BRIDGE_ARBITRUM_CONFIRMATIONS", "5")
+	t.Setenv("LFS_BRIDGE_POLYGON_RPC_URL", "http://polygon.local")
+	t.Setenv("LFS_BRIDGE_POLYGON_VAULT_ADDRESS", "0xpolvault")
+
+	listeners, err := NewEthDepositListenersFromEnv(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewEthDepositListenersFromEnv failed: %v", err)
+	}
+	if len(listeners) != 3 {
+		t.Fatalf("Expected a listener per chain, got %d", len(listeners))
+	}
+	eth, arb, pol := listeners[0], listeners[1], listeners[2]
+	if eth.cfg.ChainID != ChainIDEthereum || len(eth.vaults) != 2 || eth.cfg.Confirmations != defaultDepositConfirmations {
+		t.Errorf("Unexpected Ethereum listener %+v", eth.cfg)
+	}
+	if arb.cfg.ChainID != ChainIDArbitrum || arb.cfg.RPCURL != "http://arbitrum.local" || arb.cfg.Confirmations != 5 || len(arb.vaults) != 1 {
+		t.Errorf("Unexpected Arbitrum listener %+v", arb.cfg)
+	}
+	if pol.cfg.ChainID != ChainIDPolygon || pol.cfg.Confirmations != 128 {
+		t.Errorf("Unexpected Polygon listener %+v", pol.cfg)
+	}
+
+	// Deposits are attributed to the chain and native asset of their listener
+	sub, err := parseDepositLog(ethLog{
+		Address:     "0xpolvault",
+		Topics:      []string{depositEventTopic, "0x01", "0x02"},
+		Data:        depositLogData(big.NewInt(3_000_000_000_000_000_000), owner),
+		TxHash:      "0xAA",
+		LogIndex:    "0x0",
+		BlockNumber: "0x10",
+		BlockHash:   "0xa16",
+	}, pol.vaults["0xpolvault"])
+	if err != nil {
+		t.Fatalf("parseDepositLog failed: %v", err)
+	}
+	if sub.ChainID != ChainIDPolygon || sub.Asset != "POL" || !sub.Amount.Equal(decimal.RequireFromString("3")) {
+		t.Errorf("Expected a 3 POL deposit on Polygon, got %+v", sub)
+	}
+
+	// The service seeds a vault for the native asset of each chain
+	svc := NewService(zap.NewNop().Sugar())
+	vault, err := svc.GetVault(context.Background(), ChainIDArbitrum, "ETH")
+	if err != nil || vault.VaultAddress != "0xarbvault" || vault.PriceSymbol != "ETHUSDT" {
+		t.Errorf("Expected the Arbitrum ETH vault, got %+v (%v)", vault, err)
+	}
+
+	t.Setenv("LFS_BRIDGE_CHAINS", "zksync")
+	t.Setenv("LFS_BRIDGE_ZKSYNC_RPC_URL", "http://zksync.local")
+	t.Setenv("LFS_BRIDGE_ZKSYNC_VAULT_ADDRESS", "0xzkvault")
+	if _, err := NewEthDepositListenersFromEnv(zap.NewNop().Sugar()); err == nil {
+		t.Error("Expected an unknown chain without a native asset to be rejected")
+	}
+}
@@ -0,0 +1,144 @@
+package crosschain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ethRPC is a minimal Ethereum JSON-RPC client
+type ethRPC struct {
+	url    string
+	client *http.Client
+}
+
+func newEthRPC(url string) *ethRPC {
+	return &ethRPC{url: url, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// call performs a JSON-RPC request against the Ethereum node
+func (c *ethRPC) call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: http %d", method, resp.StatusCode)
+	}
+
+	var decoded struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("%s: decode response: %w", method, err)
+	}
+	if decoded.Error != nil {
+		return fmt.Errorf("%s: rpc error %d: %s", method, decoded.Error.Code, decoded.Error.Message)
+	}
+	return json.Unmarshal(decoded.Result, out)
+}
+
+// callUint64 performs a call whose result is a hex quantity
+func (c *ethRPC) callUint64(ctx context.Context, method string, params []interface{}) (uint64, error) {
+	var result string
+	if err := c.call(ctx, method, params, &result); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(result, "0x"), 16, 64)
+}
+
+// callBig performs a call whose result is a hex quantity that may exceed 64 bits
+func (c *ethRPC) callBig(ctx context.Context, method string, params []interface{}) (*big.Int, error) {
+	var result string
+	if err := c.call(ctx, method, params, &result); err != nil {
+		return nil, err
+	}
+	return parseHexBig(result)
+}
+
+func parseHexBig(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	return n, nil
+}
+
+func hexQuantity(n *big.Int) string {
+	return "0x" + n.Text(16)
+}
+
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// EvmRPCChecker checks the JSON-RPC endpoints of the bridged EVM chains
+type EvmRPCChecker struct {
+	chains []ChainID
+	rpcs   map[ChainID]*ethRPC
+}
+
+// NewEvmRPCCheckerFromEnv returns a checker for the chains of
+// LFS_ETH_RPC_URL and LFS_BRIDGE_CHAINS, or nil when none is configured.
+func NewEvmRPCCheckerFromEnv() (*EvmRPCChecker, error) {
+	chains, err := chainsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if len(chains) == 0 {
+		return nil, nil
+	}
+	c := &EvmRPCChecker{rpcs: make(map[ChainID]*ethRPC, len(chains))}
+	for _, chain := range chains {
+		c.chains = append(c.chains, chain.ChainID)
+		c.rpcs[chain.ChainID] = newEthRPC(chain.RPCURL)
+	}
+	return c, nil
+}
+
+// Ping reads the head block of every chain, failing with those whose
+// endpoint does not answer
+func (c *EvmRPCChecker) Ping(ctx context.Context) error {
+	var failed []string
+	for _, chain := range c.chains {
+		if _, err := c.rpcs[chain].callUint64(ctx, "eth_blockNumber", []interface{}{}); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", chain, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("EVM RPC unreachable: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
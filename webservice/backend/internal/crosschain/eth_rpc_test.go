@@ -0,0 +1,42 @@
+package crosschain
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEvmRPCChecker(t *testing.T) {
+	t.Setenv("LFS_ETH_RPC_URL", "")
+	t.Setenv("LFS_SEPOLIA_RPC_URL", "")
+	t.Setenv("LFS_LOCAL_ETH_RPC_URL", "")
+	t.Setenv("LFS_BRIDGE_CHAINS", "")
+	if checker, err := NewEvmRPCCheckerFromEnv(); err != nil || checker != nil {
+		t.Fatalf("Expected no checker without chains, got %v, %v", checker, err)
+	}
+
+	server := httptest.NewServer(&fakeEthNode{head: 7})
+	defer server.Close()
+	t.Setenv("LFS_ETH_RPC_URL", server.URL)
+	t.Setenv("LFS_CROSSCHAIN_VAULT_ADDRESS", "0xvault")
+	checker, err := NewEvmRPCCheckerFromEnv()
+	if err != nil || checker == nil {
+		t.Fatalf("NewEvmRPCCheckerFromEnv failed: %v", err)
+	}
+	if err := checker.Ping(context.Background()); err != nil {
+		t.Fatalf("Expected a reachable chain, got %v", err)
+	}
+
+	t.Setenv("LFS_BRIDGE_CHAINS", "arbitrum")
+	t.Setenv("LFS_BRIDGE_ARBITRUM_RPC_URL", "http://127.0.0.1:1")
+	t.Setenv("LFS_BRIDGE_ARBITRUM_VAULT_ADDRESS", "0xarbvault")
+	checker, err = NewEvmRPCCheckerFromEnv()
+	if err != nil {
+		t.Fatalf("NewEvmRPCCheckerFromEnv failed: %v", err)
+	}
+	err = checker.Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "arbitrum") || strings.Contains(err.Error(), "ethereum") {
+		t.Fatalf("Expected arbitrum to be unreachable, got %v", err)
+	}
+}
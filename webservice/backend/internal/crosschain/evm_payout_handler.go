@@ -0,0 +1,587 @@
+package crosschain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultVoucherTTL          = 10 * time.Minute
+	defaultReceiptTimeout      = 3 * time.Minute
+	defaultReceiptPollInterval = 2 * time.Second
+
+	// gasLimitBufferPercent is added on top of eth_estimateGas
+	gasLimitBufferPercent = 20
+)
+
+// defaultGasTipCap is used when the node does not support eth_maxPriorityFeePerGas
+var defaultGasTipCap = big.NewInt(1_000_000_000)
+
+// WalrusEthVault function signatures called by the payout handler
+var (
+	selectorShareBalance   = abiSelector("shareBalance(address)")
+	selectorPreviewDeposit = abiSelector("previewDeposit(uint256)")
+	selectorPreviewRedeem  = abiSelector("previewRedeem(uint256)")
+	selectorDeposit        = abiSelector("deposit(address,string,uint256)")
+	selectorRedeemVoucher  = abiSelector("redeemVoucher((bytes32,address,string,uint256,uint64,uint64,uint64),bytes,address)")
+)
+
+// EvmPayoutHandlerConfig configures an EvmPayoutHandler.
+type EvmPayoutHandlerConfig struct {
+	RPCURL       string
+	VaultAddress string
+
+	// Signer redeems the vouchers and pays for gas. Its vault shares are
+	// burned for each payout and topped up with its ETH when short.
+	Signer EvmSigner
+
+	// Vouchers issues and records the vouchers of payouts. Without it the
+	// handler signs vouchers of its own that are not persisted.
+	Vouchers VoucherIssuer
+
+	// VoucherTTL is how long a voucher signed by the handler stays
+	// redeemable. Default: 10m.
+	VoucherTTL time.Duration
+
+	// ReceiptTimeout bounds the wait for each transaction to be mined. Default: 3m.
+	ReceiptTimeout time.Duration
+
+	// ReceiptPollInterval is the time between receipt lookups. Default: 2s.
+	ReceiptPollInterval time.Duration
+}
+
+// VoucherIssuer issues the vouchers redeemed by payouts; implemented by
+// *Service.
+type VoucherIssuer interface {
+	IssueVoucher(ctx context.Context, req VoucherRequest) (*WithdrawalVoucher, error)
+	MarkVoucherSpent(ctx context.Context, voucherID, txHash string) error
+}
+
+// EvmPayoutHandler pays out bridge redeems from the WalrusEthVault. For each
+// payout it signs a voucher burning the signer's shares and submits
+// redeemVoucher with the Ethereum recipient, as EIP-1559 transactions signed
+// in process. Vouchers are hashed offline with the vault's EIP-712 domain.
+type EvmPayoutHandler struct {
+	cfg    EvmPayoutHandlerConfig
+	rpc    *ethRPC
+	vault  []byte
+	from   []byte
+	logger *zap.SugaredLogger
+
+	// mu serializes sends so that nonces are assigned in order
+	mu          sync.Mutex
+	chainID     *big.Int
+	nonce       uint64
+	nonceLoaded bool
+}
+
+// NewEvmPayoutHandler returns a payout handler for the vault in cfg.
+func NewEvmPayoutHandler(cfg EvmPayoutHandlerConfig, logger *zap.SugaredLogger) (*EvmPayoutHandler, error) {
+	if cfg.RPCURL == "" || cfg.VaultAddress == "" || cfg.Signer == nil {
+		return nil, fmt.Errorf("payout handler requires an RPC URL, vault address and signer")
+	}
+	vault, err := parseEvmAddress(cfg.VaultAddress)
+	if err != nil {
+		return nil, fmt.Errorf("vault address: %w", err)
+	}
+	from, err := parseEvmAddress(cfg.Signer.Address())
+	if err != nil {
+		return nil, fmt.Errorf("signer address: %w", err)
+	}
+	if cfg.VoucherTTL <= 0 {
+		cfg.VoucherTTL = defaultVoucherTTL
+	}
+	if cfg.ReceiptTimeout <= 0 {
+		cfg.ReceiptTimeout = defaultReceiptTimeout
+	}
+	if cfg.ReceiptPollInterval <= 0 {
+		cfg.ReceiptPollInterval = defaultReceiptPollInterval
+	}
+
+	return &EvmPayoutHandler{
+		cfg:    cfg,
+		rpc:    newEthRPC(cfg.RPCURL),
+		vault:  vault,
+		from:   from,
+		logger: logger,
+	}, nil
+}
+
+// NewEvmPayoutHandlerFromEnv enables the handler when LFS_ENABLE_BRIDGE_PAYOUT=1
+// and an Ethereum RPC URL, vault address and payout private key are present.
+// Vouchers are issued through vouchers when it is not nil.
+func NewEvmPayoutHandlerFromEnv(logger *zap.SugaredLogger, vouchers VoucherIssuer) (*EvmPayoutHandler, error) {
+	if !isTruthy(os.Getenv("LFS_ENABLE_BRIDGE_PAYOUT")) {
+		return nil, nil
+	}
+
+	rpcURL := envOrDefault("", "LFS_ETH_RPC_URL", "LFS_SEPOLIA_RPC_URL", "LFS_LOCAL_ETH_RPC_URL")
+	vault := envOrDefault("", "LFS_CROSSCHAIN_VAULT_ADDRESS", "LFS_SEPOLIA_VAULT_ADDRESS", "LFS_LOCAL_ETH_VAULT_ADDRESS")
+	key := envOrDefault("", "LFS_ETH_PAYOUT_PRIVATE_KEY", "LFS_ETH_DEPLOYER_PRIVATE_KEY")
+	if rpcURL == "" || vault == "" || key == "" {
+		return nil, fmt.Errorf("payout handler enabled but missing LFS_ETH_RPC_URL, LFS_CROSSCHAIN_VAULT_ADDRESS or LFS_ETH_PAYOUT_PRIVATE_KEY")
+	}
+	signer, err := NewPrivateKeySigner(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LFS_ETH_PAYOUT_PRIVATE_KEY: %w", err)
+	}
+
+	cfg := EvmPayoutHandlerConfig{
+		RPCURL:       rpcURL,
+		VaultAddress: vault,
+		Signer:       signer,
+		Vouchers:     vouchers,
+	}
+	if v := envOrDefault("", "LFS_ETH_PAYOUT_VOUCHER_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LFS_ETH_PAYOUT_VOUCHER_TTL %q: %w", v, err)
+		}
+		cfg.VoucherTTL = d
+	}
+
+	h, err := NewEvmPayoutHandler(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infow("Bridge payout handler enabled",
+		"ethRpc", rpcURL,
+		"vault", vault,
+		"redeemer", signer.Address(),
+	)
+	return h, nil
+}
+
+// voucher mirrors WalrusEthVault.Voucher
+type voucher struct {
+	VoucherID [32]byte
+	Redeemer  []byte
+	SuiOwner  string
+	Shares    *big.Int
+	Nonce     uint64
+	Expiry    uint64
+	UpdateID  uint64
+}
+
+func (v voucher) abiValue() abiValue {
+	return abiTuple(
+		abiStatic(v.VoucherID[:]),
+		abiStatic(abiAddress(v.Redeemer)),
+		abiString(v.SuiOwner),
+		abiStatic(abiUint(v.Shares)),
+		abiStatic(abiUint64(v.Nonce)),
+		abiStatic(abiUint64(v.Expiry)),
+		abiStatic(abiUint64(v.UpdateID)),
+	)
+}
+
+// Payout redeems a voucher for payout.PayoutEth to payout.EthRecipient and
+// returns the redeemVoucher transaction hash once it is mined.
+func (h *EvmPayoutHandler) Payout(ctx context.Context, payout RedeemPayoutContext) (string, error) {
+	if payout.ChainID != ChainIDEthereum {
+		return "", fmt.Errorf("unsupported payout chain %q", payout.ChainID)
+	}
+	// ERC-20 vaults redeem through their own vault contracts
+	if payout.Asset != "" && payout.Asset != "ETH" {
+		return "", fmt.Errorf("unsupported payout asset %q", payout.Asset)
+	}
+	recipient, err := parseEvmAddress(payout.EthRecipient)
+	if err != nil {
+		return "", fmt.Errorf("eth recipient: %w", err)
+	}
+	payoutWei := toBaseUnits(payout.PayoutEth, nativeDecimals)
+	if payoutWei.Sign() <= 0 {
+		return "", fmt.Errorf("invalid payout amount %s", payout.PayoutEth)
+	}
+
+	shares, err := h.callUint(ctx, selectorPreviewDeposit, abiStatic(abiUint(payoutWei)))
+	if err != nil {
+		return "", fmt.Errorf("previewDeposit: %w", err)
+	}
+	if shares.Sign() == 0 {
+		return "", fmt.Errorf("payout %s is worth no vault shares", payout.PayoutEth)
+	}
+	if err := h.ensureShares(ctx, shares, payout.SuiOwner); err != nil {
+		return "", fmt.Errorf("ensure shares: %w", err)
+	}
+
+	var (
+		v      voucher
+		sig    []byte
+		issued *WithdrawalVoucher
+	)
+	if h.cfg.Vouchers != nil {
+		issued, err = h.cfg.Vouchers.IssueVoucher(ctx, VoucherRequest{
+			Seed:     voucherSeed(payout),
+			SuiOwner: payout.SuiOwner,
+			ChainID:  payout.ChainID,
+			Asset:    "ETH",
+			Shares:   sharesFromBaseUnits(shares, nativeDecimals),
+			Redeemer: h.cfg.Signer.Address(),
+			UpdateID: payout.WalrusUpdateID,
+		})
+		if err != nil {
+			return "", fmt.Errorf("issue voucher: %w", err)
+		}
+		if v, err = issuedVoucher(issued, nativeDecimals); err != nil {
+			return "", err
+		}
+	} else {
+		v = voucher{
+			VoucherID: sha256.Sum256([]byte(voucherSeed(payout))),
+			Redeemer:  h.from,
+			SuiOwner:  payout.SuiOwner,
+			Shares:    shares,
+			Nonce:     uint64(time.Now().UnixNano()),
+			Expiry:    uint64(time.Now().Add(h.cfg.VoucherTTL).Unix()),
+			UpdateID:  payout.WalrusUpdateID,
+		}
+	}
+
+	domain, err := h.domainSeparator(ctx)
+	if err != nil {
+		return "", err
+	}
+	digest := TypedDataDigest(domain, v.structHash())
+	if issued != nil && issued.Signature != "" {
+		// The issued signature only redeems when the service hashed the
+		// voucher in the domain of this vault and chain
+		if issued.Digest != "0x"+hex.EncodeToString(digest) {
+			return "", fmt.Errorf("voucher %s digest %s does not match vault digest 0x%x", issued.VoucherID, issued.Digest, digest)
+		}
+		if sig, err = hex.DecodeString(strings.TrimPrefix(issued.Signature, "0x")); err != nil {
+			return "", fmt.Errorf("voucher %s signature: %w", issued.VoucherID, err)
+		}
+	} else if sig, err = signTypedData(ctx, h.cfg.Signer, digest); err != nil {
+		return "", fmt.Errorf("sign voucher: %w", err)
+	}
+
+	data := abiCall(selectorRedeemVoucher, v.abiValue(), abiBytes(sig), abiStatic(abiAddress(recipient)))
+	txHash, err := h.transact(ctx, big.NewInt(0), data)
+	if err != nil {
+		return "", fmt.Errorf("redeemVoucher: %w", err)
+	}
+	if issued != nil {
+		if err := h.cfg.Vouchers.MarkVoucherSpent(ctx, issued.VoucherID, txHash); err != nil {
+			h.logger.Warnw("Failed to mark voucher spent", "voucherId", issued.VoucherID, "txHash", txHash, "error", err)
+		}
+	}
+
+	h.logger.Infow("Bridge payout sent",
+		"txHash", txHash,
+		"ethRecipient", payout.EthRecipient,
+		"payoutWei", payoutWei.String(),
+		"shares", shares.String(),
+	)
+	return txHash, nil
+}
+
+// domainSeparator returns the EIP-712 domain separator of the vault, hashed
+// offline once the chain ID is known
+func (h *EvmPayoutHandler) domainSeparator(ctx context.Context) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.loadChainID(ctx); err != nil {
+		return nil, err
+	}
+	if !h.chainID.IsUint64() {
+		return nil, fmt.Errorf("chain ID %s out of range", h.chainID)
+	}
+	return VaultDomain(h.chainID.Uint64(), h.cfg.VaultAddress).Separator()
+}
+
+// loadChainID reads the chain ID of the node once; h.mu must be held
+func (h *EvmPayoutHandler) loadChainID(ctx context.Context) error {
+	if h.chainID != nil {
+		return nil
+	}
+	chainID, err := h.rpc.callBig(ctx, "eth_chainId", []interface{}{})
+	if err != nil {
+		return fmt.Errorf("eth_chainId: %w", err)
+	}
+	h.chainID = chainID
+	return nil
+}
+
+// voucherSeed derives the voucher ID seed from the burn so that a retried
+// payout reuses the voucher and cannot be redeemed twice
+func voucherSeed(payout RedeemPayoutContext) string {
+	if payout.SuiTxDigest != "" {
+		return payout.SuiTxDigest
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%s",
+		payout.SuiOwner, payout.EthRecipient, payout.Token, payout.BurnAmount.String(), payout.PayoutEth.String())
+}
+
+// ensureShares deposits the signer's ETH into the vault when its share
+// balance cannot cover needed
+func (h *EvmPayoutHandler) ensureShares(ctx context.Context, needed *big.Int, suiOwner string) error {
+	balance, err := h.callUint(ctx, selectorShareBalance, abiStatic(abiAddress(h.from)))
+	if err != nil {
+		return fmt.Errorf("shareBalance: %w", err)
+	}
+	if balance.Cmp(needed) >= 0 {
+		return nil
+	}
+
+	missing := new(big.Int).Sub(needed, balance)
+	assets, err := h.callUint(ctx, selectorPreviewRedeem, abiStatic(abiUint(missing)))
+	if err != nil {
+		return fmt.Errorf("previewRedeem: %w", err)
+	}
+	// previewRedeem rounds down; one extra wei covers the shortfall
+	assets.Add(assets, big.NewInt(1))
+
+	data := abiCall(selectorDeposit, abiStatic(abiAddress(h.from)), abiString(suiOwner), abiStatic(abiUint(missing)))
+	txHash, err := h.transact(ctx, assets, data)
+	if err != nil {
+		return fmt.Errorf("deposit: %w", err)
+	}
+	h.logger.Infow("Topped up payout shares",
+		"txHash", txHash,
+		"assetsWei", assets.String(),
+		"shares", missing.String(),
+		"previousShares", balance.String(),
+	)
+	return nil
+}
+
+func (h *EvmPayoutHandler) ethCall(ctx context.Context, data []byte) ([]byte, error) {
+	var result string
+	err := h.rpc.call(ctx, "eth_call", []interface{}{map[string]interface{}{
+		"to":   "0x" + hex.EncodeToString(h.vault),
+		"data": "0x" + hex.EncodeToString(data),
+	}, "latest"}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimPrefix(result, "0x"))
+}
+
+// callUint calls a vault view function returning a single uint256
+func (h *EvmPayoutHandler) callUint(ctx context.Context, selector []byte, args ...abiValue) (*big.Int, error) {
+	out, err := h.ethCall(ctx, abiCall(selector, args...))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != 32 {
+		return nil, fmt.Errorf("expected a uint256, got %d bytes", len(out))
+	}
+	return new(big.Int).SetBytes(out), nil
+}
+
+// transact sends a transaction to the vault and waits for it to succeed
+func (h *EvmPayoutHandler) transact(ctx context.Context, value *big.Int, data []byte) (string, error) {
+	txHash, err := h.send(ctx, value, data)
+	if err != nil {
+		return "", err
+	}
+	if err := h.waitForReceipt(ctx, txHash); err != nil {
+		return "", fmt.Errorf("tx %s: %w", txHash, err)
+	}
+	return txHash, nil
+}
+
+// send signs and broadcasts a transaction with the next nonce. The nonce is
+// read from the node again after a failed broadcast.
+func (h *EvmPayoutHandler) send(ctx context.Context, value *big.Int, data []byte) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	from := "0x" + hex.EncodeToString(h.from)
+	to := "0x" + hex.EncodeToString(h.vault)
+	if err := h.loadChainID(ctx); err != nil {
+		return "", err
+	}
+	if !h.nonceLoaded {
+		nonce, err := h.rpc.callUint64(ctx, "eth_getTransactionCount", []interface{}{from, "pending"})
+		if err != nil {
+			return "", fmt.Errorf("eth_getTransactionCount: %w", err)
+		}
+		h.nonce, h.nonceLoaded = nonce, true
+	}
+
+	tipCap, feeCap, err := h.gasPrices(ctx)
+	if err != nil {
+		return "", err
+	}
+	gas, err := h.rpc.callUint64(ctx, "eth_estimateGas", []interface{}{map[string]interface{}{
+		"from":  from,
+		"to":    to,
+		"value": hexQuantity(value),
+		"data":  "0x" + hex.EncodeToString(data),
+	}})
+	if err != nil {
+		return "", fmt.Errorf("eth_estimateGas: %w", err)
+	}
+
+	tx := &dynamicFeeTx{
+		ChainID:   h.chainID,
+		Nonce:     h.nonce,
+		GasTipCap: tipCap,
+		GasFeeCap: feeCap,
+		Gas:       gas + gas*gasLimitBufferPercent/100,
+		To:        h.vault,
+		Value:     value,
+		Data:      data,
+	}
+	raw, hash, err := signDynamicFeeTx(ctx, tx, h.cfg.Signer)
+	if err != nil {
+		return "", fmt.Errorf("sign tx: %w", err)
+	}
+
+	var txHash string
+	if err := h.rpc.call(ctx, "eth_sendRawTransaction", []interface{}{"0x" + hex.EncodeToString(raw)}, &txHash); err != nil {
+		h.nonceLoaded = false
+		return "", fmt.Errorf("eth_sendRawTransaction: %w", err)
+	}
+	h.nonce++
+	if txHash == "" {
+		txHash = "0x" + hex.EncodeToString(hash)
+	}
+	return txHash, nil
+}
+
+// gasPrices returns the priority fee and a fee cap of twice the latest base
+// fee plus the priority fee
+func (h *EvmPayoutHandler) gasPrices(ctx context.Context) (*big.Int, *big.Int, error) {
+	tipCap, err := h.rpc.callBig(ctx, "eth_maxPriorityFeePerGas", []interface{}{})
+	if err != nil {
+		tipCap = new(big.Int).Set(defaultGasTipCap)
+	}
+
+	var block struct {
+		BaseFee string `json:"baseFeePerGas"`
+	}
+	if err := h.rpc.call(ctx, "eth_getBlockByNumber", []interface{}{"latest", false}, &block); err != nil {
+		return nil, nil, fmt.Errorf("eth_getBlockByNumber: %w", err)
+	}
+	if block.BaseFee == "" {
+		return nil, nil, fmt.Errorf("latest block has no base fee; EIP-1559 is required")
+	}
+	baseFee, err := parseHexBig(block.BaseFee)
+	if err != nil {
+		return nil, nil, fmt.Errorf("base fee: %w", err)
+	}
+	feeCap := new(big.Int).Mul(baseFee, big.NewInt(2))
+	return tipCap, feeCap.Add(feeCap, tipCap), nil
+}
+
+func (h *EvmPayoutHandler) waitForReceipt(ctx context.Context, txHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.ReceiptTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(h.cfg.ReceiptPollInterval)
+	defer ticker.Stop()
+	for {
+		var receipt *struct {
+			Status string `json:"status"`
+		}
+		if err := h.rpc.call(ctx, "eth_getTransactionReceipt", []interface{}{txHash}, &receipt); err != nil && ctx.Err() == nil {
+			h.logger.Debugw("Transaction receipt lookup failed; retrying", "error", err, "txHash", txHash)
+		}
+		if receipt != nil {
+			if receipt.Status != "0x1" {
+				return fmt.Errorf("transaction reverted")
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for receipt: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func parseEvmAddress(s string) ([]byte, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	if err != nil || len(raw) != 20 {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	return raw, nil
+}
+
+// abiValue is an ABI encoded argument; dynamic values are placed in the
+// tail and referenced by offset from the head
+type abiValue struct {
+	data    []byte
+	dynamic bool
+}
+
+func abiStatic(word []byte) abiValue {
+	return abiValue{data: word}
+}
+
+func abiString(s string) abiValue {
+	return abiBytes([]byte(s))
+}
+
+func abiBytes(b []byte) abiValue {
+	padded := make([]byte, (len(b)+31)/32*32)
+	copy(padded, b)
+	return abiValue{data: append(abiUint64(uint64(len(b))), padded...), dynamic: true}
+}
+
+// abiTuple encodes a struct; it is dynamic when any field is
+func abiTuple(fields ...abiValue) abiValue {
+	dynamic := false
+	for _, f := range fields {
+		dynamic = dynamic || f.dynamic
+	}
+	return abiValue{data: abiEncode(fields...), dynamic: dynamic}
+}
+
+func abiEncode(values ...abiValue) []byte {
+	headSize := 0
+	for _, v := range values {
+		if v.dynamic {
+			headSize += 32
+		} else {
+			headSize += len(v.data)
+		}
+	}
+
+	var head, tail []byte
+	for _, v := range values {
+		if v.dynamic {
+			head = append(head, abiUint64(uint64(headSize+len(tail)))...)
+			tail = append(tail, v.data...)
+		} else {
+			head = append(head, v.data...)
+		}
+	}
+	return append(head, tail...)
+}
+
+func abiCall(selector []byte, args ...abiValue) []byte {
+	return append(append([]byte{}, selector...), abiEncode(args...)...)
+}
+
+func abiSelector(signature string) []byte {
+	return keccak256([]byte(signature))[:4]
+}
+
+func abiUint(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+func abiUint64(n uint64) []byte {
+	return abiUint(new(big.Int).SetUint64(n))
+}
+
+func abiAddress(addr []byte) []byte {
+	word := make([]byte, 32)
+	copy(word[12:], addr)
+	return word
+}
@@ -0,0 +1,201 @@
+package crosschain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// fakeVaultNode serves the JSON-RPC methods used by EvmPayoutHandler against
+// a vault with a 1:1 share index
+type fakeVaultNode struct {
+	t            *testing.T
+	shareBalance *big.Int
+	nonceReads   int
+	estimates    []map[string]string
+	rawTxs       [][]byte
+}
+
+func (n *fakeVaultNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		return b
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "eth_chainId":
+		result = "0xaa36a7"
+	case "eth_getTransactionCount":
+		n.nonceReads++
+		result = "0x5"
+	case "eth_maxPriorityFeePerGas":
+		result = "0x3b9aca00"
+	case "eth_getBlockByNumber":
+		result = map[string]string{"baseFeePerGas": "0x10"}
+	case "eth_estimateGas":
+		var call map[string]string
+		_ = json.Unmarshal(req.Params[0], &call)
+		n.estimates = append(n.estimates, call)
+		result = "0x5208"
+	case "eth_call":
+		var call map[string]string
+		_ = json.Unmarshal(req.Params[0], &call)
+		data := hexBytes(call["data"])
+		switch {
+		case bytes.Equal(data[:4], selectorShareBalance):
+			result = "0x" + hex.EncodeToString(abiUint(n.shareBalance))
+		case bytes.Equal(data[:4], selectorPreviewDeposit), bytes.Equal(data[:4], selectorPreviewRedeem):
+			result = "0x" + hex.EncodeToString(data[4:36])
+		default:
+			n.t.Errorf("Unexpected eth_call %x", data[:4])
+		}
+	case "eth_sendRawTransaction":
+		var raw string
+		_ = json.Unmarshal(req.Params[0], &raw)
+		n.rawTxs = append(n.rawTxs, hexBytes(raw))
+		result = "0x" + hex.EncodeToString(keccak256(hexBytes(raw)))
+	case "eth_getTransactionReceipt":
+		result = map[string]string{"status": "0x1"}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": result})
+}
+
+func TestPrivateKeySignerAddress(t *testing.T) {
+	// Well-known first Anvil/Hardhat development account
+	signer, err := NewPrivateKeySigner("0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	if err != nil {
+		t.Fatalf("NewPrivateKeySigner failed: %v", err)
+	}
+	if got := signer.Address(); got != "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266" {
+		t.Errorf("Unexpected address %s", got)
+	}
+	if _, err := NewPrivateKeySigner("0x1234"); err == nil {
+		t.Error("Expected a short key to be rejected")
+	}
+}
+
+func TestEvmPayoutHandlerRedeemsVoucher(t *testing.T) {
+	ctx := context.Background()
+	signer, err := NewPrivateKeySigner("0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	if err != nil {
+		t.Fatalf("NewPrivateKeySigner failed: %v", err)
+	}
+	node := &fakeVaultNode{
+		t:            t,
+		shareBalance: big.NewInt(400_000_000_000_000_000),
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	vault := "0x" + strings.Repeat("11", 20)
+	h, err := NewEvmPayoutHandler(EvmPayoutHandlerConfig{
+		RPCURL:              server.URL,
+		VaultAddress:        vault,
+		Signer:              signer,
+		ReceiptPollInterval: time.Millisecond,
+	}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewEvmPayoutHandler failed: %v", err)
+	}
+
+	recipient := "0x" + strings.Repeat("22", 20)
+	txHash, err := h.Payout(ctx, RedeemPayoutContext{
+		SuiTxDigest:    "burn-digest",
+		SuiOwner:       "0xalice",
+		EthRecipient:   recipient,
+		ChainID:        ChainIDEthereum,
+		Asset:          "ETH",
+		Token:          "x",
+		BurnAmount:     decimal.RequireFromString("2"),
+		PayoutEth:      decimal.RequireFromString("0.5"),
+		WalrusUpdateID: 7,
+	})
+	if err != nil {
+		t.Fatalf("Payout failed: %v", err)
+	}
+
+	// The missing 0.1 ETH of shares is deposited before the redeem
+	if len(node.rawTxs) != 2 || len(node.estimates) != 2 {
+		t.Fatalf("Expected a top-up and a redeem transaction, got %d", len(node.rawTxs))
+	}
+	if v := node.estimates[0]["value"]; v != hexQuantity(big.NewInt(100_000_000_000_000_001)) {
+		t.Errorf("Unexpected top-up value %s", v)
+	}
+	if node.nonceReads != 1 || h.nonce != 7 {
+		t.Errorf("Expected the nonce to be read once and advanced to 7, got %d reads and nonce %d", node.nonceReads, h.nonce)
+	}
+	for _, raw := range node.rawTxs {
+		if raw[0] != 0x02 {
+			t.Errorf("Expected an EIP-1559 transaction, got type %x", raw[0])
+		}
+	}
+	if want := "0x" + hex.EncodeToString(keccak256(node.rawTxs[1])); txHash != want {
+		t.Errorf("Expected tx hash %s, got %s", want, txHash)
+	}
+
+	data, _ := hex.DecodeString(strings.TrimPrefix(node.estimates[1]["data"], "0x"))
+	if !bytes.Equal(data[:4], selectorRedeemVoucher) {
+		t.Fatalf("Expected a redeemVoucher call, got %x", data[:4])
+	}
+	args := data[4:]
+	tuple := args[new(big.Int).SetBytes(args[0:32]).Uint64():]
+	if wantID := sha256.Sum256([]byte("burn-digest")); !bytes.Equal(tuple[:32], wantID[:]) {
+		t.Errorf("Expected the voucher ID to be derived from the burn digest")
+	}
+	if updateID := new(big.Int).SetBytes(tuple[192:224]); updateID.Uint64() != 7 {
+		t.Errorf("Expected update ID 7, got %s", updateID)
+	}
+	if got := "0x" + hex.EncodeToString(args[76:96]); got != recipient {
+		t.Errorf("Expected recipient %s, got %s", recipient, got)
+	}
+
+	// The voucher signature recovers to the redeemer over the digest the
+	// vault computes, hashed without calling it
+	var v voucher
+	copy(v.VoucherID[:], tuple[:32])
+	v.Redeemer = tuple[44:64]
+	v.SuiOwner = "0xalice"
+	v.Shares = new(big.Int).SetBytes(tuple[96:128])
+	v.Nonce = new(big.Int).SetBytes(tuple[128:160]).Uint64()
+	v.Expiry = new(big.Int).SetBytes(tuple[160:192]).Uint64()
+	v.UpdateID = 7
+	domain, err := VaultDomain(11155111, vault).Separator()
+	if err != nil {
+		t.Fatalf("Separator failed: %v", err)
+	}
+	digest := TypedDataDigest(domain, v.structHash())
+	sigStart := new(big.Int).SetBytes(args[32:64]).Uint64() + 32
+	sig := args[sigStart : sigStart+65]
+	if sig[64] != 27 && sig[64] != 28 {
+		t.Fatalf("Expected v of 27 or 28, got %d", sig[64])
+	}
+	pub, _, err := ecdsa.RecoverCompact(append([]byte{sig[64]}, sig[:64]...), digest)
+	if err != nil {
+		t.Fatalf("RecoverCompact failed: %v", err)
+	}
+	uncompressed := pub.SerializeUncompressed()
+	if addr := "0x" + hex.EncodeToString(keccak256(uncompressed[1:])[12:]); addr != signer.Address() {
+		t.Errorf("Expected signature by %s, got %s", signer.Address(), addr)
+	}
+}
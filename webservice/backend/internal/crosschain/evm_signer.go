@@ -0,0 +1,149 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// EvmSigner signs 32-byte digests for an Ethereum account. Implementations
+// backed by a KMS or hardware wallet can replace the in-process key.
+type EvmSigner interface {
+	// Address is the 0x-prefixed account address.
+	Address() string
+	// SignDigest signs the digest as is, without prefixing or hashing it, and
+	// returns the 65-byte r || s || v signature with v in {0, 1}.
+	SignDigest(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// PrivateKeySigner is an EvmSigner holding a secp256k1 key in memory.
+type PrivateKeySigner struct {
+	key     *secp256k1.PrivateKey
+	address string
+}
+
+// NewPrivateKeySigner parses a hex private key, with or without 0x prefix.
+func NewPrivateKeySigner(hexKey string) (*PrivateKeySigner, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("private key must be 32 hex-encoded bytes")
+	}
+	key := secp256k1.PrivKeyFromBytes(raw)
+	return &PrivateKeySigner{
+		key:     key,
+		address: evmAddress(key.PubKey()),
+	}, nil
+}
+
+// evmAddress returns the lower-case 0x address of pub
+func evmAddress(pub *secp256k1.PublicKey) string {
+	raw := pub.SerializeUncompressed()
+	return "0x" + hex.EncodeToString(keccak256(raw[1:])[12:])
+}
+
+// recoverEvmAddress returns the address whose key made the 65-byte
+// r || s || v signature of digest, as returned by EvmSigner.SignDigest
+func recoverEvmAddress(digest, sig []byte) (string, error) {
+	if len(digest) != 32 || len(sig) != 65 || sig[64] > 1 {
+		return "", fmt.Errorf("signature must be 65 bytes over a 32-byte digest")
+	}
+	compact := append([]byte{sig[64] + 27}, sig[:64]...)
+	pub, _, err := ecdsa.RecoverCompact(compact, digest)
+	if err != nil {
+		return "", fmt.Errorf("recover signer: %w", err)
+	}
+	return evmAddress(pub), nil
+}
+
+func (s *PrivateKeySigner) Address() string {
+	return s.address
+}
+
+func (s *PrivateKeySigner) SignDigest(_ context.Context, digest []byte) ([]byte, error) {
+	if len(digest) != 32 {
+		return nil, fmt.Errorf("digest must be 32 bytes, got %d", len(digest))
+	}
+	// SignCompact returns <27 + recovery id> || r || s
+	compact := ecdsa.SignCompact(s.key, digest, false)
+	return append(compact[1:], compact[0]-27), nil
+}
+
+// dynamicFeeTx is an EIP-1559 (type 2) transaction without access list
+type dynamicFeeTx struct {
+	ChainID   *big.Int
+	Nonce     uint64
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+	Gas       uint64
+	To        []byte
+	Value     *big.Int
+	Data      []byte
+}
+
+func (tx *dynamicFeeTx) fields() []interface{} {
+	return []interface{}{
+		tx.ChainID,
+		new(big.Int).SetUint64(tx.Nonce),
+		tx.GasTipCap,
+		tx.GasFeeCap,
+		new(big.Int).SetUint64(tx.Gas),
+		tx.To,
+		tx.Value,
+		tx.Data,
+		[]interface{}{}, // Access list
+	}
+}
+
+// signDynamicFeeTx signs tx and returns the raw transaction and its hash
+func signDynamicFeeTx(ctx context.Context, tx *dynamicFeeTx, signer EvmSigner) ([]byte, []byte, error) {
+	sigHash := keccak256([]byte{0x02}, rlpEncode(tx.fields()))
+	sig, err := signer.SignDigest(ctx, sigHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sig) != 65 {
+		return nil, nil, fmt.Errorf("signature must be 65 bytes, got %d", len(sig))
+	}
+
+	fields := append(tx.fields(),
+		new(big.Int).SetUint64(uint64(sig[64])),
+		new(big.Int).SetBytes(sig[:32]),
+		new(big.Int).SetBytes(sig[32:64]),
+	)
+	raw := append([]byte{0x02}, rlpEncode(fields)...)
+	return raw, keccak256(raw), nil
+}
+
+// rlpEncode encodes byte strings, non-negative integers and lists thereof
+func rlpEncode(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		if len(v) == 1 && v[0] < 0x80 {
+			return v
+		}
+		return append(rlpHeader(0x80, len(v)), v...)
+	case *big.Int:
+		return rlpEncode(v.Bytes())
+	case []interface{}:
+		var payload []byte
+		for _, item := range v {
+			payload = append(payload, rlpEncode(item)...)
+		}
+		return append(rlpHeader(0xc0, len(payload)), payload...)
+	default:
+		panic(fmt.Sprintf("rlp: unsupported type %T", v))
+	}
+}
+
+func rlpHeader(offset byte, length int) []byte {
+	if length <= 55 {
+		return []byte{offset + byte(length)}
+	}
+	size := big.NewInt(int64(length)).Bytes()
+	return append([]byte{offset + 55 + byte(len(size))}, size...)
+}
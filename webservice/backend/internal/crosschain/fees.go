@@ -0,0 +1,119 @@
+package crosschain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxFeeBps caps bridge fees at 10%
+const maxFeeBps = 1000
+
+// FeeRecorder receives each fee the bridge collects, as *metrics.Metrics
+// does
+type FeeRecorder interface {
+	RecordBridgeFee(ctx context.Context, chainID, asset, operation string, fee float64)
+}
+
+// BridgeFees is the fee configuration of an asset and the fees it has
+// collected, in asset units
+type BridgeFees struct {
+	ChainID      ChainID         `json:"chainId"`
+	Asset        string          `json:"asset"`
+	MintFeeBps   int             `json:"mintFeeBps"`
+	RedeemFeeBps int             `json:"redeemFeeBps"`
+	MintFees     decimal.Decimal `json:"mintFees"`
+	RedeemFees   decimal.Decimal `json:"redeemFees"`
+}
+
+// SetFees sets the fees of an asset in basis points: mintBps of each
+// deposit and redeemBps of each payout. Zero removes a fee. Fees collected
+// so far are kept.
+func (s *Service) SetFees(ctx context.Context, chainID ChainID, asset string, mintBps, redeemBps int) (*BridgeControl, error) {
+	if chainID == "" || asset == "" {
+		return nil, fmt.Errorf("%w: fees need a chainId and asset", ErrInvalidRequest)
+	}
+	for _, bps := range []int{mintBps, redeemBps} {
+		if bps < 0 || bps > maxFeeBps {
+			return nil, fmt.Errorf("%w: fees must be between 0 and %d bps", ErrInvalidRequest, maxFeeBps)
+		}
+	}
+
+	return s.updateControl(ctx, chainID, asset, func(c *BridgeControl) {
+		c.MintFeeBps = mintBps
+		c.RedeemFeeBps = redeemBps
+	})
+}
+
+// ListBridgeFees returns the fees of every asset that has a fee or has
+// collected one
+func (s *Service) ListBridgeFees(_ context.Context) []*BridgeFees {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var fees []*BridgeFees
+	for _, c := range s.controls {
+		if c.Asset == "" || (c.MintFeeBps == 0 && c.RedeemFeeBps == 0 && c.MintFees.IsZero() && c.RedeemFees.IsZero()) {
+			continue
+		}
+		fees = append(fees, &BridgeFees{
+			ChainID:      c.ChainID,
+			Asset:        c.Asset,
+			MintFeeBps:   c.MintFeeBps,
+			RedeemFeeBps: c.RedeemFeeBps,
+			MintFees:     c.MintFees,
+			RedeemFees:   c.RedeemFees,
+		})
+	}
+	sort.Slice(fees, func(i, j int) bool {
+		if fees[i].ChainID != fees[j].ChainID {
+			return fees[i].ChainID < fees[j].ChainID
+		}
+		return fees[i].Asset < fees[j].Asset
+	})
+	return fees
+}
+
+// FeeFor returns the fee op charges on amount of chainID and asset
+func (s *Service) FeeFor(chainID ChainID, asset string, op BridgeOperation, amount decimal.Decimal) decimal.Decimal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	control, ok := s.controls[controlKey(chainID, asset)]
+	if !ok {
+		return decimal.Zero
+	}
+	bps := control.MintFeeBps
+	if op == BridgeOpRedeem {
+		bps = control.RedeemFeeBps
+	}
+	return amount.Mul(decimal.New(int64(bps), -4))
+}
+
+// collectFees adds fee to the fees collected by op on chainID and asset. A
+// negative fee gives back the fee of a reverted deposit.
+func (s *Service) collectFees(ctx context.Context, chainID ChainID, asset string, op BridgeOperation, fee decimal.Decimal) error {
+	if fee.IsZero() {
+		return nil
+	}
+	_, err := s.updateControl(ctx, chainID, asset, func(c *BridgeControl) {
+		if op == BridgeOpRedeem {
+			c.RedeemFees = c.RedeemFees.Add(fee)
+		} else {
+			c.MintFees = c.MintFees.Add(fee)
+		}
+	})
+	return err
+}
+
+// collectedFeesLocked returns the fees held in the vault of chainID and
+// asset
+func (s *Service) collectedFeesLocked(chainID ChainID, asset string) decimal.Decimal {
+	control, ok := s.controls[controlKey(chainID, asset)]
+	if !ok {
+		return decimal.Zero
+	}
+	return control.MintFees.Add(control.RedeemFees)
+}
@@ -0,0 +1,106 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// fixedPrice quotes every symbol at the same fresh price
+type fixedPrice decimal.Decimal
+
+func (p fixedPrice) USDPrice(_ context.Context, symbol string) (PriceQuote, error) {
+	return PriceQuote{Symbol: symbol, Price: decimal.Decimal(p), ObservedAt: time.Now(), Source: "fixed"}, nil
+}
+
+// feeTotals sums the fees recorded per operation
+type feeTotals map[string]float64
+
+func (f feeTotals) RecordBridgeFee(_ context.Context, _, _, operation string, fee float64) {
+	f[operation] += fee
+}
+
+func TestBridgeFeesAreDeductedAndCollected(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, database := newTestService(t)
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if _, err := svc.SetFees(ctx, ChainIDEthereum, "ETH", maxFeeBps+1, 0); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a fee over the cap to be rejected, got %v", err)
+			}
+			if _, err := svc.SetFees(ctx, ChainIDEthereum, "", 50, 100); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected fees without an asset to be rejected, got %v", err)
+			}
+			if _, err := svc.SetFees(ctx, ChainIDEthereum, "ETH", 50, 100); err != nil {
+				t.Fatalf("SetFees failed: %v", err)
+			}
+
+			recorded := feeTotals{}
+			worker := NewBridgeWorker(svc, logger, WithPriceSource(fixedPrice(decimal.RequireFromString("2000"))), WithFeeRecorder(recorded))
+
+			// 0.5% of a 2 ETH deposit stays in the vault
+			receipt, err := worker.handle(ctx, DepositSubmission{
+				TxHash:   "0xfee",
+				SuiOwner: "0xalice",
+				ChainID:  ChainIDEthereum,
+				Asset:    "ETH",
+				Amount:   decimal.RequireFromString("2"),
+			})
+			if err != nil {
+				t.Fatalf("handle failed: %v", err)
+			}
+			if receipt.Fee.String() != "0.01" || receipt.Shares.String() != "1990.995" {
+				t.Errorf("Expected a 0.01 fee and shares for 1.99 ETH, got %s and %s", receipt.Fee, receipt.Shares)
+			}
+
+			// 1% of the payout of a 1 x-token burn is kept
+			redeem, err := worker.Redeem(ctx, RedeemSubmission{
+				SuiTxDigest:  "burnfee",
+				SuiOwner:     "0xalice",
+				EthRecipient: "0xdead",
+				ChainID:      ChainIDEthereum,
+				Asset:        "ETH",
+				Token:        "x",
+				Amount:       decimal.RequireFromString("1"),
+			})
+			if err != nil {
+				t.Fatalf("Redeem failed: %v", err)
+			}
+			if redeem.Fee != "0.01" || redeem.PayoutEth != "0.99" {
+				t.Errorf("Expected a 0.01 fee from the payout, got %s of %s", redeem.Fee, redeem.PayoutEth)
+			}
+
+			fees := svc.ListBridgeFees(ctx)
+			if len(fees) != 1 || fees[0].MintFees.String() != "0.01" || fees[0].RedeemFees.String() != "0.01" || fees[0].MintFeeBps != 50 {
+				t.Errorf("Unexpected fees: %+v", fees)
+			}
+			if recorded[string(BridgeOpDeposit)] != 0.01 || recorded[string(BridgeOpRedeem)] != 0.01 {
+				t.Errorf("Expected both fees to be recorded, got %v", recorded)
+			}
+			if assets := svc.reconcileSnapshot(); len(assets) == 0 || assets[0].fees.String() != "0.02" {
+				t.Errorf("Expected the vault to hold the fees, got %+v", assets)
+			}
+		})
+	}
+
+	// Fees survive a restart
+	restarted := NewService(logger, WithDatabase(database))
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if fees := restarted.ListBridgeFees(ctx); len(fees) != 1 || fees[0].RedeemFeeBps != 100 || fees[0].MintFees.String() != "0.01" {
+		t.Errorf("Expected the fees to be restored, got %+v", fees)
+	}
+}
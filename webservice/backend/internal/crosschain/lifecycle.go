@@ -0,0 +1,224 @@
+package crosschain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ReceiptStage is the step of its lifecycle a deposit or redeem has
+// reached, as shown to users. Deposits go detected → confirmed →
+// checkpointed → minted → finalized and redeems detected → checkpointed →
+// paid → finalized; either may fail on the way, and a failed redeem may be
+// refunded. Unlike DepositStatus, which
+// guards deposits against being processed twice, stages are only reported.
+type ReceiptStage string
+
+const (
+	// StageDetected marks a deposit or burn the bridge has received
+	StageDetected ReceiptStage = "detected"
+
+	// StageConfirmed marks a deposit that passed the bridge controls.
+	// Deposit listeners only submit deposits once they are confirmed on
+	// the origin chain.
+	StageConfirmed ReceiptStage = "confirmed"
+
+	// StageCheckpointed marks a receipt whose shares a Walrus checkpoint
+	// counts
+	StageCheckpointed ReceiptStage = "checkpointed"
+
+	// StageMinted marks a deposit whose tokens were minted on Sui
+	StageMinted ReceiptStage = "minted"
+
+	// StagePaid marks a redeem paid out on the origin chain
+	StagePaid ReceiptStage = "paid"
+
+	// StageFinalized marks a minted or paid receipt whose checkpoint is
+	// verified
+	StageFinalized ReceiptStage = "finalized"
+
+	// StageFailed marks a receipt that did not complete. Failed deposits
+	// may be submitted again, which detects them anew.
+	StageFailed ReceiptStage = "failed"
+
+	// StageRefunded marks a failed redeem whose burned tokens were minted
+	// back on Sui
+	StageRefunded ReceiptStage = "refunded"
+)
+
+// stageOrder ranks the stages receipts move forward through
+var stageOrder = map[ReceiptStage]int{
+	StageDetected:     1,
+	StageConfirmed:    2,
+	StageCheckpointed: 3,
+	StageMinted:       4,
+	StagePaid:         4,
+	StageFinalized:    5,
+}
+
+// canAdvance reports whether a receipt at stage from may move to stage to:
+// forward through the lifecycle, to failed from any other stage, and from
+// failed back to detected when it is submitted again or on to refunded. A
+// reorg may fail a finalized deposit; refunds are final.
+func canAdvance(from, to ReceiptStage) bool {
+	switch {
+	case from == StageRefunded:
+		return false
+	case to == StageFailed:
+		return from != StageFailed
+	case from == StageFailed:
+		return to == StageDetected || to == StageRefunded
+	case from == "":
+		return to == StageDetected
+	}
+	rank, ok := stageOrder[to]
+	return ok && rank > stageOrder[from]
+}
+
+// ReceiptTransition records a receipt moving from one stage to the next
+type ReceiptTransition struct {
+	ReceiptID string       `json:"receiptId"`
+	Kind      ReceiptKind  `json:"kind"`
+	From      ReceiptStage `json:"from,omitempty"`
+	To        ReceiptStage `json:"to"`
+	Reason    string       `json:"reason,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// AdvanceDeposit moves receipt to stage, recording the transition with
+// reason. A deposit minted against a verified checkpoint is finalized at
+// once. Moves canAdvance does not allow fail with ErrInvalidRequest.
+func (s *Service) AdvanceDeposit(ctx context.Context, receipt *BridgeReceipt, stage ReceiptStage, reason string) error {
+	if err := s.advance(ctx, ReceiptKindDeposit, receipt.ReceiptID, receipt.Stage, stage, reason); err != nil {
+		return err
+	}
+	receipt.Stage = stage
+	if stage == StageMinted && s.checkpointVerified(receipt.WalrusUpdateID) {
+		return s.AdvanceDeposit(ctx, receipt, StageFinalized, "")
+	}
+	return nil
+}
+
+// AdvanceRedeem moves receipt to stage like AdvanceDeposit, finalizing a
+// redeem paid against a verified checkpoint at once.
+func (s *Service) AdvanceRedeem(ctx context.Context, receipt *RedeemReceipt, stage ReceiptStage, reason string) error {
+	if err := s.advance(ctx, ReceiptKindRedeem, receipt.ReceiptID, receipt.Stage, stage, reason); err != nil {
+		return err
+	}
+	receipt.Stage = stage
+	if stage == StagePaid && s.checkpointVerified(receipt.WalrusUpdateID) {
+		return s.AdvanceRedeem(ctx, receipt, StageFinalized, "")
+	}
+	return nil
+}
+
+func (s *Service) advance(ctx context.Context, kind ReceiptKind, receiptID string, from, to ReceiptStage, reason string) error {
+	if receiptID == "" || !canAdvance(from, to) {
+		return fmt.Errorf("%w: receipt %q cannot move from %q to %q", ErrInvalidRequest, receiptID, from, to)
+	}
+	t := &ReceiptTransition{
+		ReceiptID: receiptID,
+		Kind:      kind,
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if s.store != nil {
+		return s.store.saveTransition(ctx, t)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.transitions[receiptID] = append(s.transitions[receiptID], t)
+	if kind == ReceiptKindRedeem {
+		if redeem, ok := s.redeems[receiptID]; ok {
+			redeem.Stage = to
+		}
+		return nil
+	}
+	for _, deposit := range s.deposits {
+		if deposit.ReceiptID == receiptID {
+			deposit.Stage = to
+		}
+	}
+	return nil
+}
+
+// checkpointVerified reports whether the checkpoint with updateID is
+// verified
+func (s *Service) checkpointVerified(updateID uint64) bool {
+	if updateID == 0 {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cp := s.checkpointByIDLocked(updateID)
+	return cp != nil && cp.Status == CheckpointStatusVerified
+}
+
+// finalizeReceipts finalizes the minted deposits and paid redeems counted
+// by the checkpoint with updateID, once it is verified
+func (s *Service) finalizeReceipts(ctx context.Context, updateID uint64) {
+	var receipts []*Receipt
+	if s.store != nil {
+		found, err := s.store.findReceiptsToFinalize(ctx, updateID)
+		if err != nil {
+			s.logger.Errorw("Failed to find receipts to finalize", "walrusUpdateId", updateID, "error", err)
+			return
+		}
+		receipts = found
+	} else {
+		s.mu.RLock()
+		for _, deposit := range s.deposits {
+			if deposit.WalrusUpdateID == updateID && deposit.Stage == StageMinted {
+				found := *deposit
+				receipts = append(receipts, &Receipt{Kind: ReceiptKindDeposit, Deposit: &found})
+			}
+		}
+		for _, redeem := range s.redeems {
+			if redeem.WalrusUpdateID == updateID && redeem.Stage == StagePaid {
+				found := *redeem
+				receipts = append(receipts, &Receipt{Kind: ReceiptKindRedeem, Redeem: &found})
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	for _, r := range receipts {
+		var err error
+		if r.Deposit != nil {
+			err = s.AdvanceDeposit(ctx, r.Deposit, StageFinalized, "")
+		} else {
+			err = s.AdvanceRedeem(ctx, r.Redeem, StageFinalized, "")
+		}
+		if err != nil {
+			s.logger.Errorw("Failed to finalize receipt", "receiptId", r.ID(), "walrusUpdateId", updateID, "error", err)
+		}
+	}
+}
+
+// GetTransitions returns the stages receipt receiptID went through, oldest
+// first
+func (s *Service) GetTransitions(ctx context.Context, receiptID string) ([]*ReceiptTransition, error) {
+	if receiptID == "" {
+		return nil, ErrInvalidRequest
+	}
+	if s.store != nil {
+		return s.store.findTransitions(ctx, receiptID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	transitions := make([]*ReceiptTransition, 0, len(s.transitions[receiptID]))
+	for _, t := range s.transitions[receiptID] {
+		found := *t
+		transitions = append(transitions, &found)
+	}
+	sort.SliceStable(transitions, func(i, j int) bool { return transitions[i].CreatedAt.Before(transitions[j].CreatedAt) })
+	return transitions, nil
+}
@@ -0,0 +1,115 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestReceiptStagesAdvance(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, _ := newTestService(t)
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			seeded, err := svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+			if err != nil {
+				t.Fatalf("GetLatestCheckpoint failed: %v", err)
+			}
+			next := *seeded
+			next.TotalShares = next.TotalShares.Add(decimal.RequireFromString("1"))
+			cp, err := svc.SubmitCheckpoint(ctx, next)
+			if err != nil {
+				t.Fatalf("SubmitCheckpoint failed: %v", err)
+			}
+
+			receipt, _, err := svc.ClaimDeposit(ctx, &BridgeReceipt{
+				ReceiptID: svc.NextReceiptID("bridge"),
+				TxHash:    "0xstages",
+				SuiOwner:  "0xalice",
+				ChainID:   ChainIDEthereum,
+				Asset:     "ETH",
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("ClaimDeposit failed: %v", err)
+			}
+
+			// Stages only move forward
+			for _, stage := range []ReceiptStage{StageDetected, StageConfirmed} {
+				if err := svc.AdvanceDeposit(ctx, receipt, stage, ""); err != nil {
+					t.Fatalf("AdvanceDeposit to %s failed: %v", stage, err)
+				}
+			}
+			if err := svc.AdvanceDeposit(ctx, receipt, StageDetected, ""); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected moving back to fail, got %v", err)
+			}
+
+			// Minting against a verified checkpoint finalizes the deposit
+			receipt.WalrusUpdateID = cp.UpdateID
+			if err := svc.AdvanceDeposit(ctx, receipt, StageCheckpointed, ""); err != nil {
+				t.Fatalf("AdvanceDeposit failed: %v", err)
+			}
+			if err := svc.AdvanceDeposit(ctx, receipt, StageMinted, "minted"); err != nil {
+				t.Fatalf("AdvanceDeposit failed: %v", err)
+			}
+			found, err := svc.GetReceipt(ctx, receipt.ReceiptID)
+			if err != nil || found.Stage() != StageFinalized {
+				t.Fatalf("Expected the deposit to be finalized, got %+v (%v)", found, err)
+			}
+			transitions, err := svc.GetTransitions(ctx, receipt.ReceiptID)
+			if err != nil || len(transitions) != 5 {
+				t.Fatalf("Expected five transitions, got %+v (%v)", transitions, err)
+			}
+			if transitions[0].From != "" || transitions[3].Reason != "minted" || transitions[4].From != StageMinted {
+				t.Errorf("Unexpected transitions: %+v %+v %+v", transitions[0], transitions[3], transitions[4])
+			}
+			receipts, _, err := svc.ListReceipts(ctx, ReceiptFilter{Stage: StageFinalized})
+			if err != nil || len(receipts) != 1 || receipts[0].ID() != receipt.ReceiptID {
+				t.Errorf("Expected the finalized deposit to be listed, got %+v (%v)", receipts, err)
+			}
+
+			// Failed redeems keep the reason and may be detected again
+			redeem := &RedeemReceipt{
+				ReceiptID:    svc.NextReceiptID("redeem"),
+				SuiTxDigest:  "burn",
+				SuiOwner:     "0xalice",
+				EthRecipient: "0xdead",
+				ChainID:      ChainIDEthereum,
+				Asset:        "ETH",
+				Token:        "f",
+				CreatedAt:    time.Now(),
+			}
+			if err := svc.RecordRedeemReceipt(ctx, redeem); err != nil {
+				t.Fatalf("RecordRedeemReceipt failed: %v", err)
+			}
+			if err := svc.AdvanceRedeem(ctx, redeem, StageDetected, ""); err != nil {
+				t.Fatalf("AdvanceRedeem failed: %v", err)
+			}
+			if err := svc.AdvanceRedeem(ctx, redeem, StageFailed, "checkpoint unavailable"); err != nil {
+				t.Fatalf("AdvanceRedeem failed: %v", err)
+			}
+			if err := svc.AdvanceRedeem(ctx, redeem, StagePaid, ""); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a failed redeem not to be paid, got %v", err)
+			}
+			if err := svc.AdvanceRedeem(ctx, redeem, StageDetected, ""); err != nil {
+				t.Errorf("Expected a failed redeem to be detected again, got %v", err)
+			}
+			transitions, _ = svc.GetTransitions(ctx, redeem.ReceiptID)
+			if len(transitions) != 3 || transitions[1].Reason != "checkpoint unavailable" {
+				t.Errorf("Unexpected redeem transitions: %+v", transitions)
+			}
+		})
+	}
+}
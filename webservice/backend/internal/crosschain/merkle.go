@@ -0,0 +1,188 @@
+package crosschain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Balances roots are Merkle roots over the non-zero balances of a chain
+// and asset, ordered by Sui owner. Leaves and inner nodes are domain
+// separated:
+//
+//	leaf  = sha256(0x00 || "owner:chainId:asset:shares")
+//	inner = sha256(0x01 || left || right)
+//
+// A level of odd length carries its last node up unchanged. The root of no
+// balances is 32 zero bytes.
+const (
+	merkleLeafPrefix  = 0x00
+	merkleInnerPrefix = 0x01
+)
+
+// BalanceProof proves that a Sui owner's shares are part of a balances root.
+type BalanceProof struct {
+	SuiOwner  string          `json:"suiOwner"`
+	ChainID   ChainID         `json:"chainId"`
+	Asset     string          `json:"asset"`
+	Shares    decimal.Decimal `json:"shares"`
+	LeafIndex int             `json:"leafIndex"`
+	LeafCount int             `json:"leafCount"`
+	Siblings  []string        `json:"siblings"` // Hex hashes, leaf level first
+	Root      string          `json:"root"`
+
+	// UpdateID is the latest checkpoint when the proof was built; its
+	// BalancesRoot equals Root unless balances changed outside the worker
+	UpdateID uint64 `json:"updateId,omitempty"`
+}
+
+// BalanceLeaf returns the Merkle leaf of a balance.
+func BalanceLeaf(suiOwner string, chainID ChainID, asset string, shares decimal.Decimal) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	fmt.Fprintf(h, "%s:%s:%s:%s", suiOwner, chainID, asset, shares.String())
+	return h.Sum(nil)
+}
+
+func merkleParent(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleInnerPrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleLeaves returns the leaves of the non-zero balances of chainID and
+// asset, with their owners, ordered by owner
+func merkleLeaves(balances []*CrossChainBalance, chainID ChainID, asset string) ([]string, [][]byte) {
+	var included []*CrossChainBalance
+	for _, bal := range balances {
+		if bal.ChainID == chainID && bal.Asset == asset && bal.Shares.IsPositive() {
+			included = append(included, bal)
+		}
+	}
+	sort.Slice(included, func(i, j int) bool { return included[i].SuiOwner < included[j].SuiOwner })
+
+	owners := make([]string, len(included))
+	leaves := make([][]byte, len(included))
+	for i, bal := range included {
+		owners[i] = bal.SuiOwner
+		leaves[i] = BalanceLeaf(bal.SuiOwner, chainID, asset, bal.Shares)
+	}
+	return owners, leaves
+}
+
+// merkleRoot returns the root of leaves and, when index is a leaf, the
+// siblings on its path
+func merkleRoot(leaves [][]byte, index int) ([]byte, [][]byte) {
+	if len(leaves) == 0 {
+		return make([]byte, sha256.Size), nil
+	}
+	var siblings [][]byte
+	level := leaves
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			if index == i {
+				siblings = append(siblings, level[i+1])
+			} else if index == i+1 {
+				siblings = append(siblings, level[i])
+			}
+			next = append(next, merkleParent(level[i], level[i+1]))
+		}
+		if index >= 0 {
+			index /= 2
+		}
+		level = next
+	}
+	return level[0], siblings
+}
+
+// ComputeBalancesRoot returns the hex Merkle root over the non-zero
+// balances of chainID and asset.
+func ComputeBalancesRoot(balances []*CrossChainBalance, chainID ChainID, asset string) string {
+	_, leaves := merkleLeaves(balances, chainID, asset)
+	root, _ := merkleRoot(leaves, -1)
+	return "0x" + hex.EncodeToString(root)
+}
+
+// BuildBalanceProof returns the inclusion proof of suiOwner's balance of
+// chainID and asset. Owners without shares have no proof; ErrNotFound is
+// returned.
+func BuildBalanceProof(balances []*CrossChainBalance, suiOwner string, chainID ChainID, asset string) (*BalanceProof, error) {
+	owners, leaves := merkleLeaves(balances, chainID, asset)
+	index := sort.SearchStrings(owners, suiOwner)
+	if index == len(owners) || owners[index] != suiOwner {
+		return nil, ErrNotFound
+	}
+
+	root, siblings := merkleRoot(leaves, index)
+	proof := &BalanceProof{
+		SuiOwner:  suiOwner,
+		ChainID:   chainID,
+		Asset:     asset,
+		LeafIndex: index,
+		LeafCount: len(leaves),
+		Siblings:  make([]string, len(siblings)),
+		Root:      "0x" + hex.EncodeToString(root),
+	}
+	for _, bal := range balances {
+		if bal.SuiOwner == suiOwner && bal.ChainID == chainID && bal.Asset == asset {
+			proof.Shares = bal.Shares
+		}
+	}
+	for i, sibling := range siblings {
+		proof.Siblings[i] = "0x" + hex.EncodeToString(sibling)
+	}
+	return proof, nil
+}
+
+// VerifyBalanceProof checks that proof places its owner's shares in root.
+func VerifyBalanceProof(proof BalanceProof, root string) error {
+	if proof.LeafCount <= 0 || proof.LeafIndex < 0 || proof.LeafIndex >= proof.LeafCount {
+		return fmt.Errorf("leaf %d out of range of %d leaves", proof.LeafIndex, proof.LeafCount)
+	}
+	want, err := hex.DecodeString(strings.TrimPrefix(root, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid root %q: %w", root, err)
+	}
+
+	node := BalanceLeaf(proof.SuiOwner, proof.ChainID, proof.Asset, proof.Shares)
+	index, count, used := proof.LeafIndex, proof.LeafCount, 0
+	for count > 1 {
+		// The last node of an odd level is carried up
+		if !(index == count-1 && count%2 == 1) {
+			if used == len(proof.Siblings) {
+				return fmt.Errorf("proof has too few siblings")
+			}
+			sibling, err := hex.DecodeString(strings.TrimPrefix(proof.Siblings[used], "0x"))
+			if err != nil || len(sibling) != sha256.Size {
+				return fmt.Errorf("invalid sibling %d", used)
+			}
+			used++
+			if index%2 == 0 {
+				node = merkleParent(node, sibling)
+			} else {
+				node = merkleParent(sibling, node)
+			}
+		}
+		index /= 2
+		count = (count + 1) / 2
+	}
+	if used != len(proof.Siblings) {
+		return fmt.Errorf("proof has too many siblings")
+	}
+	if !bytes.Equal(node, want) {
+		return fmt.Errorf("proof does not match root %s", root)
+	}
+	return nil
+}
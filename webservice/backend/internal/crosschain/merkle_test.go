@@ -0,0 +1,104 @@
+package crosschain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestBalanceProofsVerifyAgainstRoot(t *testing.T) {
+	for count := 1; count <= 7; count++ {
+		var balances []*CrossChainBalance
+		for i := 0; i < count; i++ {
+			balances = append(balances, &CrossChainBalance{
+				SuiOwner: fmt.Sprintf("0x%02d", count-i), // Unordered on purpose
+				ChainID:  ChainIDEthereum,
+				Asset:    "ETH",
+				Shares:   decimal.NewFromInt(int64(i + 1)),
+			})
+		}
+		// Zero balances and other assets are left out of the tree
+		balances = append(balances,
+			&CrossChainBalance{SuiOwner: "0xempty", ChainID: ChainIDEthereum, Asset: "ETH"},
+			&CrossChainBalance{SuiOwner: "0x01", ChainID: ChainIDEthereum, Asset: "USDC", Shares: decimal.NewFromInt(9)},
+		)
+		root := ComputeBalancesRoot(balances, ChainIDEthereum, "ETH")
+
+		for _, bal := range balances[:count] {
+			proof, err := BuildBalanceProof(balances, bal.SuiOwner, ChainIDEthereum, "ETH")
+			if err != nil {
+				t.Fatalf("%d leaves: BuildBalanceProof(%s) failed: %v", count, bal.SuiOwner, err)
+			}
+			if proof.Root != root || proof.LeafCount != count {
+				t.Fatalf("%d leaves: unexpected proof %+v for root %s", count, proof, root)
+			}
+			if err := VerifyBalanceProof(*proof, root); err != nil {
+				t.Errorf("%d leaves: proof of %s failed: %v", count, bal.SuiOwner, err)
+			}
+
+			tampered := *proof
+			tampered.Shares = proof.Shares.Add(decimal.NewFromInt(1))
+			if err := VerifyBalanceProof(tampered, root); err == nil {
+				t.Errorf("%d leaves: expected inflated shares of %s to be rejected", count, bal.SuiOwner)
+			}
+		}
+		if _, err := BuildBalanceProof(balances, "0xempty", ChainIDEthereum, "ETH"); err != ErrNotFound {
+			t.Errorf("Expected no proof for an owner without shares, got %v", err)
+		}
+	}
+
+	if root := ComputeBalancesRoot(nil, ChainIDEthereum, "ETH"); root != "0x"+strings.Repeat("00", 32) {
+		t.Errorf("Unexpected empty root %s", root)
+	}
+}
+
+func TestServiceBalanceProofMatchesCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(zap.NewNop().Sugar())
+
+	// The seeded checkpoint commits to the seeded balance
+	proof, err := svc.GetBalanceProof(ctx, "0x123", ChainIDEthereum, "ETH")
+	if err != nil {
+		t.Fatalf("GetBalanceProof failed: %v", err)
+	}
+	cp, err := svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+	if err != nil {
+		t.Fatalf("GetLatestCheckpoint failed: %v", err)
+	}
+	if err := VerifyBalanceProof(*proof, cp.BalancesRoot); err != nil || proof.UpdateID != cp.UpdateID {
+		t.Fatalf("Expected the seeded proof to verify against checkpoint %d: %v", cp.UpdateID, err)
+	}
+
+	// A checkpoint submitted with the root after a deposit matches the
+	// proofs built once the deposit is credited
+	amount := decimal.RequireFromString("1.5")
+	root := svc.BalancesRootAfter(ctx, "0xalice", ChainIDEthereum, "ETH", amount)
+	if _, err := svc.SubmitCheckpoint(ctx, WalrusCheckpoint{
+		ChainID:      ChainIDEthereum,
+		Asset:        "ETH",
+		TotalShares:  cp.TotalShares.Add(amount),
+		Index:        cp.Index,
+		BalancesRoot: root,
+	}); err != nil {
+		t.Fatalf("SubmitCheckpoint failed: %v", err)
+	}
+	if _, err := svc.CreditDeposit(ctx, "0xalice", ChainIDEthereum, "ETH", amount); err != nil {
+		t.Fatalf("CreditDeposit failed: %v", err)
+	}
+	for _, owner := range []string{"0x123", "0xalice"} {
+		proof, err := svc.GetBalanceProof(ctx, owner, ChainIDEthereum, "ETH")
+		if err != nil {
+			t.Fatalf("GetBalanceProof(%s) failed: %v", owner, err)
+		}
+		if err := VerifyBalanceProof(*proof, root); err != nil {
+			t.Errorf("Proof of %s does not match the checkpoint: %v", owner, err)
+		}
+	}
+	if _, err := svc.GetBalanceProof(ctx, "0xbob", ChainIDEthereum, "ETH"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an owner without shares, got %v", err)
+	}
+}
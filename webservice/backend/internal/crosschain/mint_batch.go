@@ -0,0 +1,168 @@
+package crosschain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const defaultMintBatchSize = 10
+
+// BatchMintHandler mints several deposits at once. It returns one result per
+// mint, in order, and mints none of them when it fails.
+type BatchMintHandler interface {
+	MintBatch(ctx context.Context, mints []BridgeMintContext) ([]*MintResult, error)
+}
+
+// pendingMint is a credited deposit waiting for its batch
+type pendingMint struct {
+	receipt BridgeReceipt
+	mint    BridgeMintContext
+}
+
+// WithMintBatching configures the worker to mint deposits in batches of up
+// to size, sent at most window after their first deposit was credited. It
+// takes effect when the mint handler is a BatchMintHandler.
+func WithMintBatching(window time.Duration, size int) BridgeWorkerOption {
+	return func(w *BridgeWorker) {
+		if size <= 0 {
+			size = defaultMintBatchSize
+		}
+		w.batchWindow = window
+		w.batchSize = size
+	}
+}
+
+// MintBatchingFromEnv returns the batching option for
+// LFS_BRIDGE_MINT_BATCH_WINDOW and LFS_BRIDGE_MINT_BATCH_SIZE (default 10),
+// or nil when no window is set.
+func MintBatchingFromEnv() (BridgeWorkerOption, error) {
+	if envOrDefault("", "LFS_BRIDGE_MINT_BATCH_WINDOW") == "" {
+		return nil, nil
+	}
+	window, err := durationFromEnv("LFS_BRIDGE_MINT_BATCH_WINDOW", 0)
+	if err != nil {
+		return nil, err
+	}
+	size := defaultMintBatchSize
+	if v := envOrDefault("", "LFS_BRIDGE_MINT_BATCH_SIZE"); v != "" {
+		size, err = strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid LFS_BRIDGE_MINT_BATCH_SIZE %q", v)
+		}
+	}
+	return WithMintBatching(window, size), nil
+}
+
+// batchMinter returns the mint handler when deposits are minted in batches
+func (w *BridgeWorker) batchMinter() BatchMintHandler {
+	if w.batchWindow <= 0 {
+		return nil
+	}
+	b, _ := w.mintHandler.(BatchMintHandler)
+	return b
+}
+
+// enqueueMint hands a credited deposit to the batch loop. When ctx ends
+// first, the mint is queued for retry instead.
+func (w *BridgeWorker) enqueueMint(ctx context.Context, receipt *BridgeReceipt, mint BridgeMintContext) error {
+	select {
+	case w.mintQueue <- pendingMint{receipt: *receipt, mint: mint}:
+		return nil
+	case <-ctx.Done():
+		return w.queueMint(context.WithoutCancel(ctx), receipt, mint, ctx.Err())
+	}
+}
+
+// runMintBatches collects queued deposits and mints them once a batch is
+// full or its window has passed, until ctx is done. Deposits still waiting
+// then are queued for retry.
+func (w *BridgeWorker) runMintBatches(ctx context.Context) {
+	var (
+		batch []pendingMint
+		due   <-chan time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+		drain:
+			for {
+				select {
+				case p := <-w.mintQueue:
+					batch = append(batch, p)
+				default:
+					break drain
+				}
+			}
+			w.requeueMints(context.WithoutCancel(ctx), batch, ctx.Err())
+			return
+		case p := <-w.mintQueue:
+			batch = append(batch, p)
+			if len(batch) == 1 {
+				due = time.After(w.batchWindow)
+			}
+			if len(batch) < w.batchSize {
+				continue
+			}
+		case <-due:
+		}
+		w.flushMints(ctx, batch)
+		batch, due = nil, nil
+	}
+}
+
+// flushMints mints batch in one call and completes its deposits. A failed
+// batch is queued for retry deposit by deposit.
+func (w *BridgeWorker) flushMints(ctx context.Context, batch []pendingMint) {
+	if len(batch) == 0 {
+		return
+	}
+	mints := make([]BridgeMintContext, len(batch))
+	for i, p := range batch {
+		mints[i] = p.mint
+	}
+
+	results, err := w.batchMinter().MintBatch(ctx, mints)
+	if err == nil && len(results) != len(batch) {
+		err = fmt.Errorf("mint batch returned %d results for %d deposits", len(results), len(batch))
+	}
+	if err != nil {
+		w.logger.Warnw("Bridge mint batch failed; queued for retry", "deposits", len(batch), "error", err)
+		w.requeueMints(ctx, batch, err)
+		return
+	}
+
+	for i := range batch {
+		w.completeMint(ctx, &batch[i].receipt, results[i], "")
+	}
+	w.logger.Infow("Bridge mint batch succeeded", "deposits", len(batch))
+}
+
+// requeueMints queues the mints of batch for retry after cause
+func (w *BridgeWorker) requeueMints(ctx context.Context, batch []pendingMint, cause error) {
+	for i := range batch {
+		receipt := &batch[i].receipt
+		if err := w.queueMint(ctx, receipt, batch[i].mint, cause); err != nil {
+			w.advanceDeposit(ctx, receipt, StageFailed, cause.Error())
+			w.logger.Errorw("Bridge deposit credited but not minted; claim kept pending",
+				"receiptId", receipt.ReceiptID,
+				"txHash", receipt.TxHash,
+				"error", cause,
+				"queueError", err,
+			)
+		}
+	}
+}
+
+// completeMint records the digests of a minted deposit and completes it
+func (w *BridgeWorker) completeMint(ctx context.Context, receipt *BridgeReceipt, result *MintResult, reason string) {
+	if result != nil && len(result.TxDigests) > 0 {
+		receipt.SuiTxDigests = append([]string{}, result.TxDigests...)
+	}
+	w.advanceDeposit(ctx, receipt, StageMinted, reason)
+	// The deposit has been minted, so a receipt that fails to save is only logged
+	if err := w.svc.CompleteDeposit(ctx, receipt); err != nil {
+		w.logger.Errorw("Failed to record bridge deposit receipt", "receiptId", receipt.ReceiptID, "error", err)
+	}
+}
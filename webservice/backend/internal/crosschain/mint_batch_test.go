@@ -0,0 +1,123 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// stubBatchMinter records the size of each batch and fails while fail is set
+type stubBatchMinter struct {
+	mu      sync.Mutex
+	fail    bool
+	batches []int
+}
+
+func (m *stubBatchMinter) Mint(_ context.Context, _ BridgeMintContext) (*MintResult, error) {
+	return nil, errors.New("expected a batch")
+}
+
+func (m *stubBatchMinter) MintBatch(_ context.Context, mints []BridgeMintContext) ([]*MintResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches = append(m.batches, len(mints))
+	if m.fail {
+		return nil, errors.New("sui rpc unavailable")
+	}
+	results := make([]*MintResult, len(mints))
+	for i := range results {
+		results[i] = &MintResult{TxDigests: []string{"0xbatch"}}
+	}
+	return results, nil
+}
+
+func TestBridgeWorkerBatchesMints(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, _ := newTestService(t)
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			minter := &stubBatchMinter{}
+			worker := NewBridgeWorker(svc, logger,
+				WithMintHandler(minter),
+				WithMintBatching(time.Hour, 2),
+				WithPriceSource(fixedPrice(decimal.RequireFromString("2000"))),
+			)
+			runCtx, cancel := context.WithCancel(ctx)
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				worker.runMintBatches(runCtx)
+			}()
+
+			deposit := func(txHash string) *BridgeReceipt {
+				receipt, err := worker.handle(ctx, DepositSubmission{
+					TxHash:   txHash,
+					SuiOwner: "0xalice",
+					ChainID:  ChainIDEthereum,
+					Asset:    "ETH",
+					Amount:   decimal.RequireFromString("1"),
+				})
+				if err != nil {
+					t.Fatalf("handle failed: %v", err)
+				}
+				if receipt.Stage != StageCheckpointed || len(receipt.SuiTxDigests) != 0 {
+					t.Fatalf("Expected the deposit to wait for its batch, got %+v", receipt)
+				}
+				return receipt
+			}
+
+			// A full batch is minted in one call and every deposit gets its digest
+			first, second := deposit("0xbatch1"), deposit("0xbatch2")
+			for _, receipt := range []*BridgeReceipt{first, second} {
+				var found *Receipt
+				for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+					if found, _ = svc.GetReceipt(ctx, receipt.ReceiptID); found != nil && found.Stage() == StageFinalized {
+						break
+					}
+				}
+				if found == nil || found.Stage() != StageFinalized || len(found.Deposit.SuiTxDigests) != 1 || found.Deposit.SuiTxDigests[0] != "0xbatch" {
+					t.Fatalf("Expected %s to be minted by the batch, got %+v", receipt.ReceiptID, found)
+				}
+			}
+
+			// A failed batch and deposits waiting at shutdown are queued for retry
+			minter.mu.Lock()
+			minter.fail = true
+			minter.mu.Unlock()
+			deposit("0xbatch3")
+			deposit("0xbatch4")
+			deposit("0xbatch5")
+			for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+				minter.mu.Lock()
+				flushed := len(minter.batches)
+				minter.mu.Unlock()
+				if flushed == 2 {
+					break
+				}
+			}
+			cancel()
+			<-stopped
+
+			retries, err := svc.ListRetries(ctx, RetryStatusPending)
+			if err != nil || len(retries) != 3 {
+				t.Fatalf("Expected three queued mints, got %d (%v)", len(retries), err)
+			}
+			if len(minter.batches) != 2 || minter.batches[0] != 2 || minter.batches[1] != 2 {
+				t.Errorf("Expected two batches of two, got %v", minter.batches)
+			}
+		})
+	}
+}
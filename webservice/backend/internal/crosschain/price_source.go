@@ -0,0 +1,259 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/leafsii/leafsii-backend/internal/prices/binance"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultPriceMaxAge is the age past which a quote is too stale to
+	// price deposits and redeems with
+	defaultPriceMaxAge = time.Minute
+
+	// defaultPriceCacheTTL is how long quotes are reused
+	defaultPriceCacheTTL = 5 * time.Second
+
+	defaultPriceAttempts = 3
+	defaultPriceBackoff  = 200 * time.Millisecond
+)
+
+// ErrPriceUnavailable is returned when no source has a fresh price
+var ErrPriceUnavailable = errors.New("price unavailable")
+
+// PriceQuote is a USD price and the time its source observed it
+type PriceQuote struct {
+	Symbol     string          `json:"symbol"`
+	Price      decimal.Decimal `json:"price"`
+	ObservedAt time.Time       `json:"observedAt"`
+	Source     string          `json:"source"`
+
+	// Quotes of the exchanges an aggregate source took the median of; not
+	// stored with receipts
+	Sources []prices.SourcePrice `json:"sources,omitempty"`
+}
+
+// PriceSource quotes the USD price of a ticker symbol, e.g. ETHUSDT
+type PriceSource interface {
+	USDPrice(ctx context.Context, symbol string) (PriceQuote, error)
+}
+
+// quoteFromTick converts a price tick, rejecting non-positive prices
+func quoteFromTick(tick prices.Tick, source string) (PriceQuote, error) {
+	price := decimal.NewFromFloat(tick.Price)
+	if !price.GreaterThan(decimal.Zero) {
+		return PriceQuote{}, fmt.Errorf("invalid %s price %v for %s", source, tick.Price, tick.Symbol)
+	}
+	return PriceQuote{
+		Symbol:     tick.Symbol,
+		Price:      price,
+		ObservedAt: time.UnixMilli(tick.TsMs),
+		Source:     source,
+	}, nil
+}
+
+// QuoterPriceSource quotes prices from a prices.Quoter. Failed requests are
+// retried, and quotes are cached in a kv.Store so that bursts of deposits
+// share one request.
+type QuoterPriceSource struct {
+	name     string
+	quoter   prices.Quoter
+	cache    kv.Store
+	ttl      time.Duration
+	attempts int
+	backoff  time.Duration
+}
+
+// NewQuoterPriceSource returns a source quoting from quoter and caching
+// quotes in cache for ttl. A nil cache disables caching.
+func NewQuoterPriceSource(name string, quoter prices.Quoter, cache kv.Store, ttl time.Duration) *QuoterPriceSource {
+	return &QuoterPriceSource{
+		name:     name,
+		quoter:   quoter,
+		cache:    cache,
+		ttl:      ttl,
+		attempts: defaultPriceAttempts,
+		backoff:  defaultPriceBackoff,
+	}
+}
+
+// USDPrice returns the cached quote of symbol, or fetches a new one
+func (s *QuoterPriceSource) USDPrice(ctx context.Context, symbol string) (PriceQuote, error) {
+	key := fmt.Sprintf("fx:bridge:price:%s:%s", s.name, symbol)
+	if s.cache != nil {
+		if data, err := s.cache.Get(ctx, key); err == nil {
+			var quote PriceQuote
+			if json.Unmarshal(data, &quote) == nil {
+				return quote, nil
+			}
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < s.attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return PriceQuote{}, ctx.Err()
+			case <-time.After(s.backoff << (attempt - 1)):
+			}
+		}
+		tick, err := s.quoter.FetchPrice(ctx, symbol)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		quote, err := quoteFromTick(tick, s.name)
+		if err != nil {
+			return PriceQuote{}, err
+		}
+		if s.cache != nil {
+			if data, err := json.Marshal(quote); err == nil {
+				_ = s.cache.Set(ctx, key, data, s.ttl)
+			}
+		}
+		return quote, nil
+	}
+	return PriceQuote{}, fmt.Errorf("%s price of %s: %w", s.name, symbol, lastErr)
+}
+
+// AggregatePriceSource quotes the median of the quotes of several
+// exchanges, outliers rejected, with the quote of each of them
+type AggregatePriceSource struct {
+	aggregator *prices.Aggregator
+}
+
+// NewAggregatePriceSource returns a source quoting aggregates of aggregator
+func NewAggregatePriceSource(aggregator *prices.Aggregator) *AggregatePriceSource {
+	return &AggregatePriceSource{aggregator: aggregator}
+}
+
+// USDPrice returns the aggregate of symbol
+func (s *AggregatePriceSource) USDPrice(ctx context.Context, symbol string) (PriceQuote, error) {
+	aggregate, err := s.aggregator.Aggregate(ctx, symbol)
+	if err != nil {
+		return PriceQuote{}, fmt.Errorf("aggregate price of %s: %w", symbol, err)
+	}
+	quote, err := quoteFromTick(aggregate.Tick(), s.aggregator.Name())
+	if err != nil {
+		return PriceQuote{}, err
+	}
+	quote.Sources = aggregate.Sources
+	return quote, nil
+}
+
+// TickReader reads the latest tick of a symbol, as cached by the price
+// publisher. *store.Cache implements it.
+type TickReader interface {
+	GetOraclePrice(ctx context.Context, symbol string, dest interface{}) error
+}
+
+// OraclePriceSource quotes the live ticks the price publisher caches. Ticks
+// of the mock provider, which the publisher falls back to, are refused.
+type OraclePriceSource struct {
+	reader TickReader
+}
+
+// NewOraclePriceSource returns a source reading ticks from reader
+func NewOraclePriceSource(reader TickReader) *OraclePriceSource {
+	return &OraclePriceSource{reader: reader}
+}
+
+// USDPrice returns the latest cached tick of symbol
+func (s *OraclePriceSource) USDPrice(ctx context.Context, symbol string) (PriceQuote, error) {
+	var tick prices.Tick
+	if err := s.reader.GetOraclePrice(ctx, symbol, &tick); err != nil {
+		return PriceQuote{}, fmt.Errorf("oracle price of %s: %w", symbol, err)
+	}
+	if tick.Source == "mock" {
+		return PriceQuote{}, fmt.Errorf("oracle price of %s is simulated", symbol)
+	}
+	return quoteFromTick(tick, "oracle")
+}
+
+// FallbackPriceSource asks its sources in order and returns the first
+// quote no older than maxAge. It fails closed: when every source fails or
+// is stale, it returns ErrPriceUnavailable.
+type FallbackPriceSource struct {
+	sources []PriceSource
+	maxAge  time.Duration
+	logger  *zap.SugaredLogger
+}
+
+// NewFallbackPriceSource returns a source falling back through sources
+func NewFallbackPriceSource(maxAge time.Duration, logger *zap.SugaredLogger, sources ...PriceSource) *FallbackPriceSource {
+	return &FallbackPriceSource{sources: sources, maxAge: maxAge, logger: logger}
+}
+
+// USDPrice returns the first fresh quote of symbol
+func (s *FallbackPriceSource) USDPrice(ctx context.Context, symbol string) (PriceQuote, error) {
+	var errs []string
+	for _, source := range s.sources {
+		quote, err := source.USDPrice(ctx, symbol)
+		if err == nil {
+			if age := time.Since(quote.ObservedAt); age > s.maxAge {
+				err = fmt.Errorf("%s price of %s is %s old", quote.Source, symbol, age.Truncate(time.Second))
+			}
+		}
+		if err != nil {
+			s.logger.Warnw("Price source failed", "symbol", symbol, "error", err)
+			errs = append(errs, err.Error())
+			continue
+		}
+		return quote, nil
+	}
+	return PriceQuote{}, fmt.Errorf("%w for %s: %s", ErrPriceUnavailable, symbol, strings.Join(errs, "; "))
+}
+
+// NewPriceSourceFromEnv returns the bridge price source: the aggregates of
+// aggregator when it is not nil, otherwise Binance quotes cached for
+// LFS_BRIDGE_PRICE_CACHE_TTL (default 5s), then the price publisher's
+// ticks read through reader when it is not nil. Quotes older than
+// LFS_BRIDGE_PRICE_MAX_AGE (default 1m) are refused.
+func NewPriceSourceFromEnv(aggregator *prices.Aggregator, reader TickReader, logger *zap.SugaredLogger) (*FallbackPriceSource, error) {
+	maxAge, err := durationFromEnv("LFS_BRIDGE_PRICE_MAX_AGE", defaultPriceMaxAge)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := durationFromEnv("LFS_BRIDGE_PRICE_CACHE_TTL", defaultPriceCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	if ttl >= maxAge {
+		return nil, fmt.Errorf("LFS_BRIDGE_PRICE_CACHE_TTL %s must be shorter than LFS_BRIDGE_PRICE_MAX_AGE %s", ttl, maxAge)
+	}
+
+	var sources []PriceSource
+	if aggregator != nil {
+		sources = append(sources, NewAggregatePriceSource(aggregator))
+	} else {
+		sources = append(sources, NewQuoterPriceSource("binance", binance.NewProvider(logger), memkv.NewStore(), ttl))
+	}
+	if reader != nil {
+		sources = append(sources, NewOraclePriceSource(reader))
+	}
+	return NewFallbackPriceSource(maxAge, logger, sources...), nil
+}
+
+// durationFromEnv parses the positive duration in key, or returns def
+func durationFromEnv(key string, def time.Duration) (time.Duration, error) {
+	v := envOrDefault("", key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return d, nil
+}
@@ -0,0 +1,117 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"go.uber.org/zap"
+)
+
+// stubQuoter fails the first `failures` requests and then quotes price
+type stubQuoter struct {
+	price    float64
+	failures int
+	calls    int
+}
+
+func (q *stubQuoter) FetchPrice(_ context.Context, symbol string) (prices.Tick, error) {
+	q.calls++
+	if q.calls <= q.failures {
+		return prices.Tick{}, errors.New("binance unavailable")
+	}
+	return prices.Tick{Symbol: symbol, Price: q.price, TsMs: time.Now().UnixMilli()}, nil
+}
+
+// stubTicks serves a fixed tick as the price publisher's latest
+type stubTicks struct {
+	tick *prices.Tick
+}
+
+func (s stubTicks) GetOraclePrice(_ context.Context, _ string, dest interface{}) error {
+	if s.tick == nil {
+		return errors.New("cache miss")
+	}
+	*dest.(*prices.Tick) = *s.tick
+	return nil
+}
+
+func TestFallbackPriceSource(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+
+	// Failed requests are retried and quotes are cached
+	quoter := &stubQuoter{price: 3000.5, failures: 1}
+	primary := NewQuoterPriceSource("binance", quoter, memkv.NewStore(), time.Minute)
+	primary.backoff = time.Millisecond
+	for i := 0; i < 2; i++ {
+		quote, err := primary.USDPrice(ctx, "ETHUSDT")
+		if err != nil || quote.Price.String() != "3000.5" || quote.Source != "binance" {
+			t.Fatalf("Unexpected quote: %+v (%v)", quote, err)
+		}
+	}
+	if quoter.calls != 2 {
+		t.Errorf("Expected one retry and a cached quote, got %d calls", quoter.calls)
+	}
+
+	// The oracle ticks are used when the primary source fails
+	down := NewQuoterPriceSource("binance", &stubQuoter{failures: 10}, nil, time.Minute)
+	down.backoff = time.Millisecond
+	fresh := &prices.Tick{Symbol: "ETHUSDT", Price: 2999, TsMs: time.Now().UnixMilli(), Source: "binance"}
+	src := NewFallbackPriceSource(time.Minute, logger, down, NewOraclePriceSource(stubTicks{tick: fresh}))
+	quote, err := src.USDPrice(ctx, "ETHUSDT")
+	if err != nil || quote.Price.String() != "2999" || quote.Source != "oracle" {
+		t.Fatalf("Expected the oracle price, got %+v (%v)", quote, err)
+	}
+
+	// Stale and simulated prices fail closed
+	stale := *fresh
+	stale.TsMs = time.Now().Add(-2 * time.Minute).UnixMilli()
+	simulated := *fresh
+	simulated.Source = "mock"
+	for name, tick := range map[string]*prices.Tick{"stale": &stale, "mock": &simulated, "missing": nil} {
+		src := NewFallbackPriceSource(time.Minute, logger, down, NewOraclePriceSource(stubTicks{tick: tick}))
+		if _, err := src.USDPrice(ctx, "ETHUSDT"); !errors.Is(err, ErrPriceUnavailable) {
+			t.Errorf("Expected a %s price to be refused, got %v", name, err)
+		}
+	}
+}
+
+// namedQuoter is a stubQuoter aggregated under name
+type namedQuoter struct {
+	*stubQuoter
+	name string
+}
+
+func (q namedQuoter) Name() string { return q.name }
+
+func TestAggregatePriceSource(t *testing.T) {
+	ctx := context.Background()
+	aggregator := prices.NewAggregator([]prices.Source{
+		namedQuoter{&stubQuoter{price: 3000}, "binance"},
+		namedQuoter{&stubQuoter{price: 3002}, "coinbase"},
+		namedQuoter{&stubQuoter{price: 3300}, "kraken"},
+	})
+	src := NewFallbackPriceSource(time.Minute, zap.NewNop().Sugar(), NewAggregatePriceSource(aggregator))
+
+	quote, err := src.USDPrice(ctx, "ETHUSDT")
+	if err != nil || quote.Price.String() != "3001" || quote.Source != "aggregate" {
+		t.Fatalf("Expected the median of the agreeing quotes, got %+v (%v)", quote, err)
+	}
+	if len(quote.Sources) != 3 || !quote.Sources[2].Rejected {
+		t.Errorf("Expected the kraken outlier in the breakdown, got %+v", quote.Sources)
+	}
+
+	// Disagreeing sources fail closed
+	split := prices.NewAggregator([]prices.Source{
+		namedQuoter{&stubQuoter{price: 3000}, "binance"},
+		namedQuoter{&stubQuoter{price: 3300}, "kraken"},
+	})
+	src = NewFallbackPriceSource(time.Minute, zap.NewNop().Sugar(), NewAggregatePriceSource(split))
+	if _, err := src.USDPrice(ctx, "ETHUSDT"); !errors.Is(err, ErrPriceUnavailable) {
+		t.Errorf("Expected disagreeing prices to be refused, got %v", err)
+	}
+}
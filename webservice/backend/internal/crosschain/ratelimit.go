@@ -0,0 +1,223 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/shopspring/decimal"
+)
+
+// ErrRateLimited is wrapped by RateLimitError
+var ErrRateLimited = errors.New("bridge rate limited")
+
+// Owner limits checked by the worker
+const (
+	LimitOwnerRequests = "owner_requests"
+	LimitOwnerVolume   = "owner_volume"
+)
+
+// defaultOwnerRateWindow is the window of owner rate limits
+const defaultOwnerRateWindow = time.Hour
+
+// volumeScale counts volumes in kv counters with nine decimals
+const volumeScale = 9
+
+// RateLimitError is returned for a submission over a rate limit of its Sui
+// owner.
+type RateLimitError struct {
+	SuiOwner   string
+	Operation  BridgeOperation
+	Limit      string          // LimitOwnerRequests or LimitOwnerVolume
+	Max        decimal.Decimal // The configured limit
+	Requested  decimal.Decimal // The window's count or volume, with the submission
+	RetryAfter time.Duration   // Until the submission would fit
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s of %s for %s exceeded: %s over %s", e.Limit, e.Operation, e.SuiOwner, e.Requested, e.Max)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// OwnerRateLimits caps the deposits and redemptions each Sui owner submits
+// through Submit and Redeem over a sliding window. Submissions observed on
+// chain by the listeners are not limited.
+type OwnerRateLimits struct {
+	Window      time.Duration   // Defaults to 1h
+	MaxRequests int64           // Per owner and operation; 0 disables
+	MaxVolume   decimal.Decimal // Per owner, operation, chain and asset, in the submitted units; 0 disables
+}
+
+// OwnerRateLimitsFromEnv reads LFS_BRIDGE_OWNER_MAX_REQUESTS and
+// LFS_BRIDGE_OWNER_MAX_VOLUME over LFS_BRIDGE_OWNER_RATE_WINDOW (default
+// 1h). It returns nil when neither limit is set.
+func OwnerRateLimitsFromEnv() (*OwnerRateLimits, error) {
+	var requests uint64
+	if err := parseUintEnv("LFS_BRIDGE_OWNER_MAX_REQUESTS", &requests); err != nil {
+		return nil, err
+	}
+	volume := decimal.Zero
+	if v := envOrDefault("", "LFS_BRIDGE_OWNER_MAX_VOLUME"); v != "" {
+		var err error
+		if volume, err = decimal.NewFromString(v); err != nil || volume.IsNegative() {
+			return nil, fmt.Errorf("invalid LFS_BRIDGE_OWNER_MAX_VOLUME %q", v)
+		}
+	}
+	if requests == 0 && volume.IsZero() {
+		return nil, nil
+	}
+	window, err := durationFromEnv("LFS_BRIDGE_OWNER_RATE_WINDOW", defaultOwnerRateWindow)
+	if err != nil {
+		return nil, err
+	}
+	return &OwnerRateLimits{Window: window, MaxRequests: int64(requests), MaxVolume: volume}, nil
+}
+
+// WithOwnerRateLimits configures the worker to enforce limits on each Sui
+// owner, counting submissions in store so that replicas share them.
+func WithOwnerRateLimits(limits OwnerRateLimits, store kv.Store) BridgeWorkerOption {
+	return func(w *BridgeWorker) {
+		if limits.Window <= 0 {
+			limits.Window = defaultOwnerRateWindow
+		}
+		w.ownerLimiter = &ownerLimiter{limits: limits, store: store, now: time.Now}
+	}
+}
+
+// ownerLimiter counts submissions in fixed kv buckets of one window and
+// estimates the sliding window from the current bucket plus the part of
+// the previous one still inside it
+type ownerLimiter struct {
+	limits OwnerRateLimits
+	store  kv.Store
+	now    func() time.Time
+}
+
+// rateCounter is one limit a submission is counted against
+type rateCounter struct {
+	limit string
+	key   string
+	n     int64
+	max   int64
+}
+
+// allow counts a submission of amount against the limits of its owner. A
+// rejected submission is not counted.
+func (l *ownerLimiter) allow(ctx context.Context, op BridgeOperation, owner string, chainID ChainID, asset string, amount decimal.Decimal) error {
+	// Every spelling of an address shares its buckets
+	if normalized, err := onchain.NormalizeAddress(owner); err == nil {
+		owner = normalized
+	}
+	var counters []rateCounter
+	if l.limits.MaxRequests > 0 {
+		counters = append(counters, rateCounter{
+			limit: LimitOwnerRequests,
+			key:   fmt.Sprintf("bridge:ratelimit:%s:%s:requests", owner, op),
+			n:     1,
+			max:   l.limits.MaxRequests,
+		})
+	}
+	if l.limits.MaxVolume.IsPositive() && amount.IsPositive() {
+		counters = append(counters, rateCounter{
+			limit: LimitOwnerVolume,
+			key:   fmt.Sprintf("bridge:ratelimit:%s:%s:%s:%s:volume", owner, op, chainID, asset),
+			n:     amount.Shift(volumeScale).Ceil().IntPart(),
+			max:   l.limits.MaxVolume.Shift(volumeScale).IntPart(),
+		})
+	}
+
+	now := l.now()
+	window := l.limits.Window
+	bucket := now.UnixNano() / int64(window)
+	elapsed := float64(now.UnixNano()%int64(window)) / float64(window)
+
+	var counted []rateCounter
+	for _, c := range counters {
+		current := fmt.Sprintf("%s:%d", c.key, bucket)
+		prev, err := l.count(ctx, fmt.Sprintf("%s:%d", c.key, bucket-1))
+		if err != nil {
+			return err
+		}
+		// Counting first keeps concurrent submissions from all fitting
+		total, err := l.store.IncrBy(ctx, current, c.n)
+		if err != nil {
+			return err
+		}
+		if _, err := l.store.Expire(ctx, current, 2*window); err != nil {
+			return err
+		}
+		counted = append(counted, rateCounter{key: current, n: c.n})
+
+		carried := float64(prev) * (1 - elapsed)
+		if estimate := carried + float64(total); estimate > float64(c.max) {
+			l.release(ctx, counted)
+			rerr := &RateLimitError{
+				SuiOwner:  owner,
+				Operation: op,
+				Limit:     c.limit,
+				Max:       decimal.NewFromInt(c.max),
+				Requested: decimal.NewFromFloat(estimate).Ceil(),
+			}
+			if c.limit == LimitOwnerVolume {
+				rerr.Max = rerr.Max.Shift(-volumeScale)
+				rerr.Requested = rerr.Requested.Shift(-volumeScale)
+			}
+			// The previous bucket fades out over the window; past it only
+			// the current bucket counts
+			rerr.RetryAfter = time.Duration((1 - elapsed) * float64(window))
+			if fit := float64(c.max) - float64(total); fit >= 0 && prev > 0 {
+				rerr.RetryAfter = time.Duration((1 - fit/float64(prev) - elapsed) * float64(window))
+			}
+			return rerr
+		}
+	}
+	return nil
+}
+
+// count reads a bucket, zero when it expired or was never written
+func (l *ownerLimiter) count(ctx context.Context, key string) (int64, error) {
+	v, err := l.store.GetString(ctx, key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// release takes back the counts of a rejected submission
+func (l *ownerLimiter) release(ctx context.Context, counted []rateCounter) {
+	for _, c := range counted {
+		_, _ = l.store.DecrBy(ctx, c.key, c.n)
+	}
+}
+
+// checkOwnerLimits returns a *RateLimitError when a submission is over a
+// limit of its owner. The limits fail open: when the kv store is down,
+// submissions are let through.
+func (w *BridgeWorker) checkOwnerLimits(ctx context.Context, op BridgeOperation, owner string, chainID ChainID, asset string, amount decimal.Decimal) error {
+	if w.ownerLimiter == nil {
+		return nil
+	}
+	err := w.ownerLimiter.allow(ctx, op, owner, chainID, asset, amount)
+	if err != nil && !errors.Is(err, ErrRateLimited) {
+		w.logger.Warnw("Bridge rate limits unavailable; letting submission through",
+			"operation", op,
+			"suiOwner", owner,
+			"error", err,
+		)
+		return nil
+	}
+	if err != nil {
+		w.logger.Warnw("Bridge submission rate limited", "operation", op, "suiOwner", owner, "error", err)
+	}
+	return err
+}
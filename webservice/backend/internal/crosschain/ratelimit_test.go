@@ -0,0 +1,138 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestBridgeWorkerRateLimitsOwners(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := zap.NewNop().Sugar()
+	svc := NewService(logger)
+	if err := svc.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	worker := NewBridgeWorker(svc, logger,
+		WithPriceSource(fixedPrice(decimal.RequireFromString("2000"))),
+		WithOwnerRateLimits(OwnerRateLimits{Window: time.Minute, MaxRequests: 2}, memkv.NewStore()),
+	)
+	worker.ownerLimiter.now = func() time.Time { return now }
+	worker.Start(ctx)
+
+	deposits := 0
+	submit := func(owner string) error {
+		deposits++
+		_, err := worker.Submit(ctx, DepositSubmission{
+			TxHash:   fmt.Sprintf("0x%02x", deposits),
+			SuiOwner: owner,
+			ChainID:  ChainIDEthereum,
+			Asset:    "ETH",
+			Amount:   decimal.RequireFromString("0.1"),
+		})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := submit("0xalice"); err != nil {
+			t.Fatalf("Submit %d failed: %v", i, err)
+		}
+	}
+	err := submit("0xalice")
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected the third deposit to be rate limited, got %v", err)
+	}
+	if limitErr.Limit != LimitOwnerRequests || limitErr.RetryAfter <= 0 || limitErr.RetryAfter > time.Minute {
+		t.Errorf("Unexpected rate limit: %+v", limitErr)
+	}
+	if err := submit("0xbob"); err != nil {
+		t.Errorf("Expected other owners to be unaffected, got %v", err)
+	}
+	// Deposits seen on chain are not limited
+	deposits++
+	if _, err := worker.submit(ctx, DepositSubmission{
+		TxHash:   fmt.Sprintf("0x%02x", deposits),
+		SuiOwner: "0xalice",
+		ChainID:  ChainIDEthereum,
+		Asset:    "ETH",
+		Amount:   decimal.RequireFromString("0.1"),
+	}); err != nil {
+		t.Errorf("Expected a listener deposit through, got %v", err)
+	}
+
+	// Halfway through the next window, half of the previous one still counts
+	now = now.Add(90 * time.Second)
+	if err := submit("0xalice"); err != nil {
+		t.Errorf("Expected a deposit once the window slid, got %v", err)
+	}
+	if err := submit("0xalice"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the window to fill again, got %v", err)
+	}
+}
+
+func TestOwnerLimiterNormalizesOwners(t *testing.T) {
+	ctx := context.Background()
+	limiter := &ownerLimiter{
+		limits: OwnerRateLimits{Window: time.Hour, MaxRequests: 2, MaxVolume: decimal.RequireFromString("3")},
+		store:  memkv.NewStore(),
+		now:    time.Now,
+	}
+	allow := func(owner, amount string) error {
+		return limiter.allow(ctx, BridgeOpDeposit, owner, ChainIDEthereum, "ETH", decimal.RequireFromString(amount))
+	}
+
+	// Short and upper case spellings of one address share its buckets
+	if err := allow("0xA11CE", "2"); err != nil {
+		t.Fatalf("allow failed: %v", err)
+	}
+	err := allow("0x0000000000000000000000000000000000000000000000000000000000a11ce", "2")
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LimitOwnerVolume {
+		t.Fatalf("Expected the volume of the short spelling to count, got %v", err)
+	}
+	if err := allow("0xa11ce", "1"); err != nil {
+		t.Fatalf("allow failed: %v", err)
+	}
+	if err := allow("0xA11CE", "0.1"); !errors.As(err, &limitErr) || limitErr.Limit != LimitOwnerRequests {
+		t.Errorf("Expected the requests of both spellings to count, got %v", err)
+	}
+}
+
+func TestOwnerLimiterCapsVolume(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := &ownerLimiter{
+		limits: OwnerRateLimits{Window: time.Hour, MaxVolume: decimal.RequireFromString("3")},
+		store:  memkv.NewStore(),
+		now:    func() time.Time { return now },
+	}
+	allow := func(asset, amount string) error {
+		return limiter.allow(ctx, BridgeOpRedeem, "0xalice", ChainIDEthereum, asset, decimal.RequireFromString(amount))
+	}
+
+	if err := allow("ETH", "2.5"); err != nil {
+		t.Fatalf("allow failed: %v", err)
+	}
+	err := allow("ETH", "1")
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LimitOwnerVolume || limitErr.Max.String() != "3" || limitErr.Requested.String() != "3.5" {
+		t.Fatalf("Expected a volume limit of 3, got %v", err)
+	}
+	// The rejected redeem was not counted, and assets count apart
+	if err := allow("ETH", "0.5"); err != nil {
+		t.Errorf("Expected the rest of the volume to fit, got %v", err)
+	}
+	if err := allow("USDC", "3"); err != nil {
+		t.Errorf("Expected another asset to have its own volume, got %v", err)
+	}
+}
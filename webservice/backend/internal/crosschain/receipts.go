@@ -0,0 +1,340 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultReceiptPageSize = 20
+	maxReceiptPageSize     = 100
+)
+
+// ReceiptKind tells deposit receipts from redeem receipts
+type ReceiptKind string
+
+const (
+	ReceiptKindDeposit ReceiptKind = "deposit"
+	ReceiptKindRedeem  ReceiptKind = "redeem"
+)
+
+// Receipt is a deposit or redeem receipt, as listed by ListReceipts
+type Receipt struct {
+	Kind    ReceiptKind
+	Deposit *BridgeReceipt // Set for deposits
+	Redeem  *RedeemReceipt // Set for redeems
+}
+
+// ID returns the receipt ID
+func (r *Receipt) ID() string {
+	if r.Deposit != nil {
+		return r.Deposit.ReceiptID
+	}
+	return r.Redeem.ReceiptID
+}
+
+// Owner returns the Sui address the receipt is for
+func (r *Receipt) Owner() string {
+	if r.Deposit != nil {
+		return r.Deposit.SuiOwner
+	}
+	return r.Redeem.SuiOwner
+}
+
+// CreatedAt returns the time the receipt was issued
+func (r *Receipt) CreatedAt() time.Time {
+	if r.Deposit != nil {
+		return r.Deposit.CreatedAt
+	}
+	return r.Redeem.CreatedAt
+}
+
+// Status returns the DepositStatus of deposits and the RedeemStatus of
+// redeems
+func (r *Receipt) Status() string {
+	if r.Deposit != nil {
+		return string(r.Deposit.Status)
+	}
+	return string(r.Redeem.Status())
+}
+
+// Stage returns the lifecycle stage of the receipt
+func (r *Receipt) Stage() ReceiptStage {
+	if r.Deposit != nil {
+		return r.Deposit.Stage
+	}
+	return r.Redeem.Stage
+}
+
+// Status returns whether the payout of the redeem has been made
+func (r *RedeemReceipt) Status() RedeemStatus {
+	if r.PayoutTxHash != "" {
+		return RedeemStatusPaid
+	}
+	return RedeemStatusPending
+}
+
+// ReceiptFilter selects the receipts listed by ListReceipts. Empty fields
+// match every receipt.
+type ReceiptFilter struct {
+	Kind     ReceiptKind
+	SuiOwner string
+	ChainID  ChainID
+	Asset    string
+	Status   string       // A DepositStatus or a RedeemStatus
+	Stage    ReceiptStage // Lifecycle stage
+	From     time.Time    // Created at or after
+	To       time.Time    // Created before
+
+	// Limit is the page size. Default: 20, at most 100.
+	Limit int
+
+	// Cursor is empty for the first page, or the cursor returned with the
+	// previous page
+	Cursor string
+}
+
+// receiptCursor is the decoded form of a receipts cursor: the position of
+// the last receipt of the previous page
+type receiptCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+func encodeReceiptCursor(r *Receipt) string {
+	data, _ := json.Marshal(receiptCursor{CreatedAt: r.CreatedAt(), ID: r.ID()})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeReceiptCursor(cursor string) (*receiptCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	var c receiptCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	return &c, nil
+}
+
+// receiptBefore reports whether a is listed before b: newest first, then
+// by descending ID
+func receiptBefore(aCreated time.Time, aID string, bCreated time.Time, bID string) bool {
+	if !aCreated.Equal(bCreated) {
+		return aCreated.After(bCreated)
+	}
+	return aID > bID
+}
+
+// matches reports whether r is selected by filter and follows after
+func (r *Receipt) matches(filter ReceiptFilter, after *receiptCursor) bool {
+	var owner, asset string
+	var chainID ChainID
+	if r.Deposit != nil {
+		owner, chainID, asset = r.Deposit.SuiOwner, r.Deposit.ChainID, r.Deposit.Asset
+	} else {
+		owner, chainID, asset = r.Redeem.SuiOwner, r.Redeem.ChainID, r.Redeem.Asset
+	}
+	createdAt := r.CreatedAt()
+	switch {
+	case filter.Kind != "" && r.Kind != filter.Kind,
+		filter.SuiOwner != "" && owner != filter.SuiOwner,
+		filter.ChainID != "" && chainID != filter.ChainID,
+		filter.Asset != "" && asset != filter.Asset,
+		filter.Status != "" && r.Status() != filter.Status,
+		filter.Stage != "" && r.Stage() != filter.Stage,
+		!filter.From.IsZero() && createdAt.Before(filter.From),
+		!filter.To.IsZero() && !createdAt.Before(filter.To),
+		after != nil && !receiptBefore(after.CreatedAt, after.ID, createdAt, r.ID()):
+		return false
+	}
+	return true
+}
+
+// ListReceipts returns a page of the deposit and redeem receipts selected
+// by filter, newest first, and the cursor of the next page, which is empty
+// on the last page. Cursors are keyset positions, so receipts issued while
+// paging do not shift pages. A malformed cursor or an unknown kind fails
+// with ErrInvalidRequest.
+func (s *Service) ListReceipts(ctx context.Context, filter ReceiptFilter) ([]*Receipt, string, error) {
+	switch filter.Kind {
+	case "", ReceiptKindDeposit, ReceiptKindRedeem:
+	default:
+		return nil, "", fmt.Errorf("%w: unknown receipt kind %q", ErrInvalidRequest, filter.Kind)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultReceiptPageSize
+	} else if filter.Limit > maxReceiptPageSize {
+		filter.Limit = maxReceiptPageSize
+	}
+	var after *receiptCursor
+	if filter.Cursor != "" {
+		c, err := decodeReceiptCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = c
+	}
+
+	var receipts []*Receipt
+	if s.store != nil {
+		found, err := s.store.findReceipts(ctx, filter, after)
+		if err != nil {
+			return nil, "", err
+		}
+		receipts = found
+	} else {
+		s.mu.RLock()
+		for _, deposit := range s.deposits {
+			found := *deposit
+			if r := (&Receipt{Kind: ReceiptKindDeposit, Deposit: &found}); r.matches(filter, after) {
+				receipts = append(receipts, r)
+			}
+		}
+		for _, redeem := range s.redeems {
+			found := *redeem
+			if r := (&Receipt{Kind: ReceiptKindRedeem, Redeem: &found}); r.matches(filter, after) {
+				receipts = append(receipts, r)
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	sort.Slice(receipts, func(i, j int) bool {
+		return receiptBefore(receipts[i].CreatedAt(), receipts[i].ID(), receipts[j].CreatedAt(), receipts[j].ID())
+	})
+	if len(receipts) <= filter.Limit {
+		return receipts, "", nil
+	}
+	receipts = receipts[:filter.Limit]
+	return receipts, encodeReceiptCursor(receipts[len(receipts)-1]), nil
+}
+
+// GetReceipt returns the deposit or redeem receipt with receiptID, or
+// ErrNotFound
+func (s *Service) GetReceipt(ctx context.Context, receiptID string) (*Receipt, error) {
+	if receiptID == "" {
+		return nil, ErrInvalidRequest
+	}
+	if s.store != nil {
+		return s.store.getReceipt(ctx, receiptID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, deposit := range s.deposits {
+		if deposit.ReceiptID == receiptID {
+			found := *deposit
+			return &Receipt{Kind: ReceiptKindDeposit, Deposit: &found}, nil
+		}
+	}
+	if redeem, ok := s.redeems[receiptID]; ok {
+		found := *redeem
+		return &Receipt{Kind: ReceiptKindRedeem, Redeem: &found}, nil
+	}
+	return nil, ErrNotFound
+}
+
+// ReceiptOwner returns the Sui owner of the receipt with receiptID, e.g.
+// to check who may follow it
+func (s *Service) ReceiptOwner(ctx context.Context, receiptID string) (string, error) {
+	receipt, err := s.GetReceipt(ctx, receiptID)
+	if err != nil {
+		return "", err
+	}
+	return receipt.Owner(), nil
+}
+
+// ReceiptRecomputation compares the amounts of a receipt with the amounts
+// derived again from its price quote. Deposits fill the mint fields and
+// redeems the payout fields.
+type ReceiptRecomputation struct {
+	ReceiptID string      `json:"receiptId"`
+	Kind      ReceiptKind `json:"kind"`
+	Price     PriceQuote  `json:"price"`
+
+	MintF          string          `json:"mintF,omitempty"`
+	MintX          string          `json:"mintX,omitempty"`
+	Shares         decimal.Decimal `json:"shares"`
+	ExpectedMintF  string          `json:"expectedMintF,omitempty"`
+	ExpectedMintX  string          `json:"expectedMintX,omitempty"`
+	ExpectedShares decimal.Decimal `json:"expectedShares"`
+
+	Payout         string `json:"payout,omitempty"`
+	ExpectedPayout string `json:"expectedPayout,omitempty"`
+
+	// Matches is set when every recorded amount equals the expected one
+	Matches bool `json:"matches"`
+}
+
+// RecomputeReceipt derives the amounts of the receipt with receiptID again
+// from the price quote it recorded: the 50/50 split of a deposit's amount
+// net of its fee, or the payout of a burn. Receipts issued before quotes
+// were kept fail with ErrInvalidRequest.
+func (s *Service) RecomputeReceipt(ctx context.Context, receiptID string) (*ReceiptRecomputation, error) {
+	receipt, err := s.GetReceipt(ctx, receiptID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &ReceiptRecomputation{ReceiptID: receiptID, Kind: receipt.Kind}
+	if d := receipt.Deposit; d != nil {
+		if d.Price == nil || d.Amount.IsZero() {
+			return nil, fmt.Errorf("%w: receipt %s has no price quote", ErrInvalidRequest, receiptID)
+		}
+		out.Price = *d.Price
+		mintF, mintX, shares, err := splitMintAmounts(d.Amount.Sub(d.Fee), d.Price.Price)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		out.ExpectedMintF, out.ExpectedMintX = mintF.StringFixed(9), mintX.StringFixed(9)
+		out.ExpectedShares = shares
+		out.Shares = d.Shares
+		// Minted is recorded as "f=<amount>,x=<amount>"
+		for _, part := range strings.Split(d.Minted, ",") {
+			if k, v, ok := strings.Cut(part, "="); ok && k == "f" {
+				out.MintF = v
+			} else if ok && k == "x" {
+				out.MintX = v
+			}
+		}
+		out.Matches = out.MintF == out.ExpectedMintF && out.MintX == out.ExpectedMintX && d.Shares.Equal(shares)
+		return out, nil
+	}
+
+	r := receipt.Redeem
+	if r.Price == nil {
+		return nil, fmt.Errorf("%w: receipt %s has no price quote", ErrInvalidRequest, receiptID)
+	}
+	out.Price = *r.Price
+	burned, err := decimal.NewFromString(r.Burned)
+	if err != nil {
+		return nil, fmt.Errorf("parse burned amount of %s: %w", receiptID, err)
+	}
+	fee := decimal.Zero
+	if r.Fee != "" {
+		if fee, err = decimal.NewFromString(r.Fee); err != nil {
+			return nil, fmt.Errorf("parse fee of %s: %w", receiptID, err)
+		}
+	}
+	payout := burned
+	if r.Token == "f" {
+		if !r.Price.Price.IsPositive() {
+			return nil, fmt.Errorf("%w: receipt %s has no price", ErrInvalidRequest, receiptID)
+		}
+		payout = burned.Div(r.Price.Price)
+	}
+	out.ExpectedPayout = payout.Sub(fee).String()
+	out.Payout = r.PayoutEth
+	out.Matches = out.Payout == out.ExpectedPayout
+	return out, nil
+}
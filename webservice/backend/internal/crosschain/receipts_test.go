@@ -0,0 +1,222 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestListReceiptsFiltersAndPages(t *testing.T) {
+	// Receipts are written with the times they were created at, rather
+	// than the time of the write, so that they can be spread out
+	ctx := query.KeepTimestamps(context.Background())
+	logger := zap.NewNop().Sugar()
+	persisted, _ := newTestService(t)
+	start := time.Unix(1700000000, 0).UTC()
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Five deposits and three redeems, a minute apart, alternating owners
+			for i := 0; i < 5; i++ {
+				owner := "0xalice"
+				if i%2 == 1 {
+					owner = "0xbob"
+				}
+				receipt, _, err := svc.ClaimDeposit(ctx, &BridgeReceipt{
+					ReceiptID: svc.NextReceiptID("bridge"),
+					TxHash:    fmt.Sprintf("0x%d", i),
+					SuiOwner:  owner,
+					ChainID:   ChainIDEthereum,
+					Asset:     "ETH",
+					CreatedAt: start.Add(time.Duration(2*i) * time.Minute),
+				})
+				if err != nil {
+					t.Fatalf("ClaimDeposit failed: %v", err)
+				}
+				receipt.SuiTxDigests = []string{fmt.Sprintf("digest%d", i)}
+				if i < 4 {
+					if err := svc.CompleteDeposit(ctx, receipt); err != nil {
+						t.Fatalf("CompleteDeposit failed: %v", err)
+					}
+				}
+			}
+			for i := 0; i < 3; i++ {
+				receipt := &RedeemReceipt{
+					ReceiptID:    svc.NextReceiptID("redeem"),
+					SuiTxDigest:  fmt.Sprintf("burn%d", i),
+					SuiOwner:     "0xalice",
+					EthRecipient: "0xdead",
+					ChainID:      ChainIDEthereum,
+					Asset:        "ETH",
+					Token:        "f",
+					CreatedAt:    start.Add(time.Duration(2*i+1) * time.Minute),
+				}
+				if i > 0 {
+					receipt.PayoutTxHash = fmt.Sprintf("0xpay%d", i)
+				}
+				if err := svc.RecordRedeemReceipt(ctx, receipt); err != nil {
+					t.Fatalf("RecordRedeemReceipt failed: %v", err)
+				}
+			}
+
+			// Pages of three walk every receipt once, newest first
+			var ids []string
+			cursor := ""
+			for page := 0; ; page++ {
+				receipts, next, err := svc.ListReceipts(ctx, ReceiptFilter{Limit: 3, Cursor: cursor})
+				if err != nil {
+					t.Fatalf("ListReceipts failed: %v", err)
+				}
+				for _, r := range receipts {
+					ids = append(ids, r.ID())
+				}
+				if next == "" {
+					break
+				}
+				if page > 3 {
+					t.Fatal("Expected paging to end")
+				}
+				cursor = next
+			}
+			want := []string{"bridge_5", "bridge_4", "redeem_8", "bridge_3", "redeem_7", "bridge_2", "redeem_6", "bridge_1"}
+			if fmt.Sprint(ids) != fmt.Sprint(want) {
+				t.Errorf("Expected %v, got %v", want, ids)
+			}
+
+			// Filters combine
+			receipts, _, err := svc.ListReceipts(ctx, ReceiptFilter{SuiOwner: "0xalice", Status: "minted"})
+			if err != nil {
+				t.Fatalf("ListReceipts failed: %v", err)
+			}
+			if len(receipts) != 2 || receipts[0].ID() != "bridge_3" || receipts[0].Deposit.SuiTxDigests[0] != "digest2" {
+				t.Errorf("Expected alice's minted deposits, got %+v", receipts)
+			}
+			receipts, _, _ = svc.ListReceipts(ctx, ReceiptFilter{Kind: ReceiptKindRedeem, Status: string(RedeemStatusPaid)})
+			if len(receipts) != 2 || receipts[0].Redeem.PayoutTxHash != "0xpay2" {
+				t.Errorf("Expected two paid redeems, got %+v", receipts)
+			}
+			receipts, _, _ = svc.ListReceipts(ctx, ReceiptFilter{Status: string(RedeemStatusPending)})
+			if len(receipts) != 2 || receipts[0].ID() != "bridge_5" || receipts[1].ID() != "redeem_6" {
+				t.Errorf("Expected the pending deposit and redeem, got %+v", receipts)
+			}
+			receipts, _, _ = svc.ListReceipts(ctx, ReceiptFilter{From: start.Add(3 * time.Minute), To: start.Add(6 * time.Minute)})
+			if len(receipts) != 3 || receipts[0].ID() != "redeem_8" || receipts[2].ID() != "redeem_7" {
+				t.Errorf("Expected the receipts from 3 to 6 minutes, got %+v", receipts)
+			}
+			if _, _, err := svc.ListReceipts(ctx, ReceiptFilter{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a malformed cursor to fail, got %v", err)
+			}
+
+			// Receipts are found by ID
+			receipt, err := svc.GetReceipt(ctx, "redeem_7")
+			if err != nil || receipt.Kind != ReceiptKindRedeem || receipt.Redeem.SuiTxDigest != "burn1" || receipt.Status() != string(RedeemStatusPaid) {
+				t.Errorf("Unexpected redeem receipt: %+v (%v)", receipt, err)
+			}
+			receipt, err = svc.GetReceipt(ctx, "bridge_1")
+			if err != nil || receipt.Kind != ReceiptKindDeposit || receipt.Deposit.SuiTxDigests[0] != "digest0" {
+				t.Errorf("Unexpected deposit receipt: %+v (%v)", receipt, err)
+			}
+			if _, err := svc.GetReceipt(ctx, "bridge_99"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReceiptsRecordPriceAndRecompute(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, _ := newTestService(t)
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if _, err := svc.SetFees(ctx, ChainIDEthereum, "ETH", 50, 30); err != nil {
+				t.Fatalf("SetFees failed: %v", err)
+			}
+			worker := NewBridgeWorker(svc, logger, WithPriceSource(fixedPrice(decimal.RequireFromString("2000"))))
+
+			deposit, err := worker.handle(ctx, DepositSubmission{
+				TxHash:   "0xpriced",
+				SuiOwner: "0xalice",
+				ChainID:  ChainIDEthereum,
+				Asset:    "ETH",
+				Amount:   decimal.RequireFromString("2"),
+			})
+			if err != nil {
+				t.Fatalf("handle failed: %v", err)
+			}
+			redeem, err := worker.Redeem(ctx, RedeemSubmission{
+				SuiTxDigest:  "burnpriced",
+				SuiOwner:     "0xalice",
+				EthRecipient: "0xdead",
+				ChainID:      ChainIDEthereum,
+				Asset:        "ETH",
+				Token:        "f",
+				Amount:       decimal.RequireFromString("300"),
+			})
+			if err != nil {
+				t.Fatalf("Redeem failed: %v", err)
+			}
+
+			stored, err := svc.GetReceipt(ctx, deposit.ReceiptID)
+			if err != nil {
+				t.Fatalf("GetReceipt failed: %v", err)
+			}
+			price := stored.Deposit.Price
+			if price == nil || price.Source != "fixed" || price.Symbol != "ETHUSDT" || price.Price.String() != "2000" || price.ObservedAt.IsZero() {
+				t.Fatalf("Expected the deposit to keep its quote, got %+v", price)
+			}
+			if stored.Deposit.Amount.String() != "2" {
+				t.Errorf("Expected the deposited amount 2, got %s", stored.Deposit.Amount)
+			}
+
+			rc, err := svc.RecomputeReceipt(ctx, deposit.ReceiptID)
+			if err != nil {
+				t.Fatalf("RecomputeReceipt failed: %v", err)
+			}
+			// 1.99 ETH after the 0.5% fee: $1990 as f, 0.995 ETH as x
+			if !rc.Matches || rc.ExpectedMintF != "1990.000000000" || rc.ExpectedMintX != "0.995000000" || rc.ExpectedShares.String() != "1990.995" {
+				t.Errorf("Unexpected deposit recomputation %+v", rc)
+			}
+
+			rc, err = svc.RecomputeReceipt(ctx, redeem.ReceiptID)
+			if err != nil {
+				t.Fatalf("RecomputeReceipt failed: %v", err)
+			}
+			if !rc.Matches || rc.Price.Price.String() != "2000" || rc.ExpectedPayout != redeem.PayoutEth {
+				t.Errorf("Unexpected redeem recomputation %+v", rc)
+			}
+
+			// Receipts without a quote cannot be recomputed
+			unpriced, _, err := svc.ClaimDeposit(ctx, &BridgeReceipt{
+				ReceiptID: svc.NextReceiptID("bridge"),
+				TxHash:    "0xunpriced",
+				SuiOwner:  "0xalice",
+				ChainID:   ChainIDEthereum,
+				Asset:     "ETH",
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("ClaimDeposit failed: %v", err)
+			}
+			if _, err := svc.RecomputeReceipt(ctx, unpriced.ReceiptID); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected ErrInvalidRequest, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,456 @@
+package crosschain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// Reconciliation checks
+const (
+	ReconcileVault  = "vault"  // Vault balance against the collateral of the latest checkpoint and the fees collected
+	ReconcileLedger = "ledger" // Shares of the latest checkpoint against the balances
+	ReconcileSupply = "supply" // Sui f + x supply against the shares of every checkpoint
+)
+
+// defaultReconcileInterval is how often reconciliation runs when
+// LFS_RECONCILE_INTERVAL is unset
+const defaultReconcileInterval = 5 * time.Minute
+
+// defaultReconcileTolerance is the relative drift allowed when
+// LFS_RECONCILE_TOLERANCE is unset
+var defaultReconcileTolerance = decimal.RequireFromString("0.001")
+
+// suiTokenDecimals are the decimals of the f and x coins
+const suiTokenDecimals = 9
+
+var selectorBalanceOf = abiSelector("balanceOf(address)")
+
+// VaultBalanceReader returns what a vault holds on its chain, in whole
+// units of its asset.
+type VaultBalanceReader interface {
+	VaultBalance(ctx context.Context, vault VaultInfo) (decimal.Decimal, error)
+}
+
+// SupplyReader returns the combined Sui supply of fToken and xToken in
+// whole tokens.
+type SupplyReader interface {
+	TokenSupply(ctx context.Context) (decimal.Decimal, error)
+}
+
+// ReconcileRecorder receives the drift of each check, as *metrics.Metrics
+// does. chainID and asset are empty for the supply check.
+type ReconcileRecorder interface {
+	RecordReconcileDrift(ctx context.Context, check, chainID, asset string, drift float64, diverged bool)
+}
+
+// ReconcileAlerter is told when a check starts to diverge.
+type ReconcileAlerter interface {
+	Alert(ctx context.Context, drift ReconcileDrift) error
+}
+
+// ReconcileDrift is the outcome of one reconciliation check.
+type ReconcileDrift struct {
+	Check     string          `json:"check"`
+	ChainID   ChainID         `json:"chainId,omitempty"`
+	Asset     string          `json:"asset,omitempty"`
+	Expected  decimal.Decimal `json:"expected"`
+	Actual    decimal.Decimal `json:"actual"`
+	Drift     decimal.Decimal `json:"drift"` // (Actual - Expected) / Expected
+	Tolerance decimal.Decimal `json:"tolerance"`
+	Diverged  bool            `json:"diverged"`
+	CheckedAt time.Time       `json:"checkedAt"`
+}
+
+func (d ReconcileDrift) key() string {
+	return fmt.Sprintf("%s:%s:%s", d.Check, d.ChainID, d.Asset)
+}
+
+// relativeDrift returns (actual - expected) / expected, or 1 when nothing
+// is expected but something is there
+func relativeDrift(expected, actual decimal.Decimal) decimal.Decimal {
+	if expected.IsZero() {
+		if actual.IsZero() {
+			return decimal.Zero
+		}
+		return decimal.NewFromInt(1)
+	}
+	return actual.Sub(expected).Div(expected)
+}
+
+// reconcileAsset is the state of a chain and asset the checks compare
+type reconcileAsset struct {
+	checkpoint WalrusCheckpoint
+	shares     decimal.Decimal // Sum of the balances
+	fees       decimal.Decimal // Fees collected, which stay in the vault
+	vault      *VaultInfo
+}
+
+// reconcileSnapshot returns the latest checkpoint, balance total, fees and
+// vault of every chain and asset with a checkpoint
+func (s *Service) reconcileSnapshot() []reconcileAsset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var assets []reconcileAsset
+	for key, cps := range s.checkpoints {
+		if len(cps) == 0 {
+			continue
+		}
+		last := cps[len(cps)-1]
+		asset := reconcileAsset{checkpoint: *last, shares: decimal.Zero, fees: s.collectedFeesLocked(last.ChainID, last.Asset)}
+		for _, bal := range s.balances {
+			if bal.ChainID == last.ChainID && bal.Asset == last.Asset {
+				asset.shares = asset.shares.Add(bal.Shares)
+			}
+		}
+		if vault, ok := s.vaults[key]; ok && vault.VaultAddress != "" {
+			asset.vault = &vault
+		}
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return s.mapKey(assets[i].checkpoint.ChainID, assets[i].checkpoint.Asset) < s.mapKey(assets[j].checkpoint.ChainID, assets[j].checkpoint.Asset)
+	})
+	return assets
+}
+
+// ReconcilerConfig configures a Reconciler. Checks without their reader
+// are skipped.
+type ReconcilerConfig struct {
+	Vaults    VaultBalanceReader
+	Supply    SupplyReader
+	Recorder  ReconcileRecorder
+	Alerter   ReconcileAlerter
+	Tolerance decimal.Decimal // Relative drift allowed; defaults to 0.1%
+	Interval  time.Duration
+}
+
+// Reconciler periodically checks that the vaults hold the collateral the
+// checkpoints claim, that the checkpoints match the balances, and that
+// the Sui supply matches the shares minted. A check whose drift exceeds
+// the tolerance is logged and alerted once when it starts diverging, and
+// logged again when it recovers.
+type Reconciler struct {
+	svc    *Service
+	cfg    ReconcilerConfig
+	logger *zap.SugaredLogger
+
+	diverged map[string]bool // By ReconcileDrift.key; touched by RunOnce only
+}
+
+// NewReconciler returns a reconciler of svc.
+func NewReconciler(svc *Service, cfg ReconcilerConfig, logger *zap.SugaredLogger) *Reconciler {
+	if !cfg.Tolerance.IsPositive() {
+		cfg.Tolerance = defaultReconcileTolerance
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultReconcileInterval
+	}
+	return &Reconciler{
+		svc:      svc,
+		cfg:      cfg,
+		logger:   logger,
+		diverged: make(map[string]bool),
+	}
+}
+
+// NewReconcilerFromEnv returns a reconciler running every
+// LFS_RECONCILE_INTERVAL (default 5m) with the relative tolerance
+// LFS_RECONCILE_TOLERANCE (default 0.001), or nil when the interval is
+// "0". Vault balances are read from the chains of the deposit listeners,
+// supplies from LFS_SUI_RPC_URL and the coin types of the minter, and
+// alerts are posted to LFS_RECONCILE_WEBHOOK_URL when it is set.
+func NewReconcilerFromEnv(svc *Service, recorder ReconcileRecorder, logger *zap.SugaredLogger) (*Reconciler, error) {
+	cfg := ReconcilerConfig{Recorder: recorder}
+	if v := envOrDefault("", "LFS_RECONCILE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LFS_RECONCILE_INTERVAL %q: %w", v, err)
+		}
+		if d <= 0 {
+			return nil, nil
+		}
+		cfg.Interval = d
+	}
+	if v := envOrDefault("", "LFS_RECONCILE_TOLERANCE"); v != "" {
+		tolerance, err := decimal.NewFromString(v)
+		if err != nil || !tolerance.IsPositive() {
+			return nil, fmt.Errorf("invalid LFS_RECONCILE_TOLERANCE %q", v)
+		}
+		cfg.Tolerance = tolerance
+	}
+
+	vaults, err := NewEvmVaultBalanceReaderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if vaults != nil {
+		cfg.Vaults = vaults
+	}
+	if supply := NewSuiSupplyReaderFromEnv(); supply != nil {
+		cfg.Supply = supply
+	}
+	if webhook := envOrDefault("", "LFS_RECONCILE_WEBHOOK_URL"); webhook != "" {
+		cfg.Alerter = &WebhookAlerter{URL: webhook, Client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	return NewReconciler(svc, cfg, logger), nil
+}
+
+// Interval returns the time between reconciler runs.
+func (r *Reconciler) Interval() time.Duration {
+	return r.cfg.Interval
+}
+
+// Start runs the reconciler until ctx is done.
+func (r *Reconciler) Start(ctx context.Context) {
+	r.logger.Infow("Reconciler starting",
+		"interval", r.cfg.Interval,
+		"tolerance", r.cfg.Tolerance.String(),
+		"vaults", r.cfg.Vaults != nil,
+		"supply", r.cfg.Supply != nil,
+		"webhook", r.cfg.Alerter != nil,
+	)
+	go func() {
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				r.logger.Infow("Reconciler stopped")
+				return
+			case <-ticker.C:
+				r.RunOnce(ctx, time.Now())
+			}
+		}
+	}()
+}
+
+// RunOnce runs every check and returns their drifts. Checks whose source
+// cannot be read are logged and left out.
+func (r *Reconciler) RunOnce(ctx context.Context, now time.Time) []ReconcileDrift {
+	var (
+		drifts      []ReconcileDrift
+		totalShares = decimal.Zero
+	)
+	for _, asset := range r.svc.reconcileSnapshot() {
+		cp := asset.checkpoint
+		totalShares = totalShares.Add(cp.TotalShares)
+		drifts = append(drifts, r.check(ctx, ReconcileLedger, cp.ChainID, cp.Asset, cp.TotalShares, asset.shares, now))
+
+		if r.cfg.Vaults == nil || asset.vault == nil {
+			continue
+		}
+		balance, err := r.cfg.Vaults.VaultBalance(ctx, *asset.vault)
+		if err != nil {
+			r.logger.Warnw("Reconcile: vault balance unavailable", "chainId", cp.ChainID, "asset", cp.Asset, "error", err)
+			continue
+		}
+		drifts = append(drifts, r.check(ctx, ReconcileVault, cp.ChainID, cp.Asset, cp.TotalShares.Mul(cp.Index).Add(asset.fees), balance, now))
+	}
+
+	if r.cfg.Supply != nil {
+		supply, err := r.cfg.Supply.TokenSupply(ctx)
+		if err != nil {
+			r.logger.Warnw("Reconcile: Sui supply unavailable", "error", err)
+		} else {
+			drifts = append(drifts, r.check(ctx, ReconcileSupply, "", "", totalShares, supply, now))
+		}
+	}
+	return drifts
+}
+
+// check compares expected and actual, records the drift and alerts when
+// the check starts diverging
+func (r *Reconciler) check(ctx context.Context, check string, chainID ChainID, asset string, expected, actual decimal.Decimal, now time.Time) ReconcileDrift {
+	drift := relativeDrift(expected, actual)
+	d := ReconcileDrift{
+		Check:     check,
+		ChainID:   chainID,
+		Asset:     asset,
+		Expected:  expected,
+		Actual:    actual,
+		Drift:     drift,
+		Tolerance: r.cfg.Tolerance,
+		Diverged:  drift.Abs().GreaterThan(r.cfg.Tolerance),
+		CheckedAt: now,
+	}
+	if r.cfg.Recorder != nil {
+		r.cfg.Recorder.RecordReconcileDrift(ctx, check, string(chainID), asset, drift.InexactFloat64(), d.Diverged)
+	}
+
+	was := r.diverged[d.key()]
+	r.diverged[d.key()] = d.Diverged
+	switch {
+	case d.Diverged && !was:
+		r.logger.Errorw("Bridge reconciliation diverged",
+			"check", check,
+			"chainId", chainID,
+			"asset", asset,
+			"expected", expected.String(),
+			"actual", actual.String(),
+			"drift", drift.String(),
+			"tolerance", r.cfg.Tolerance.String(),
+		)
+		if r.cfg.Alerter != nil {
+			if err := r.cfg.Alerter.Alert(ctx, d); err != nil {
+				r.logger.Errorw("Reconcile alert failed", "check", check, "chainId", chainID, "asset", asset, "error", err)
+			}
+		}
+	case !d.Diverged && was:
+		r.logger.Infow("Bridge reconciliation recovered", "check", check, "chainId", chainID, "asset", asset, "drift", drift.String())
+	}
+	return d
+}
+
+// EvmVaultBalanceReader reads vault balances over the JSON-RPC endpoints
+// of their chains: the native balance of native vaults and the token
+// balanceOf of ERC-20 vaults.
+type EvmVaultBalanceReader struct {
+	rpcs map[ChainID]*ethRPC
+}
+
+// NewEvmVaultBalanceReaderFromEnv returns a reader for the chains of
+// LFS_ETH_RPC_URL and LFS_BRIDGE_CHAINS, or nil when none is configured.
+func NewEvmVaultBalanceReaderFromEnv() (*EvmVaultBalanceReader, error) {
+	chains, err := chainsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if len(chains) == 0 {
+		return nil, nil
+	}
+	r := &EvmVaultBalanceReader{rpcs: make(map[ChainID]*ethRPC, len(chains))}
+	for _, chain := range chains {
+		r.rpcs[chain.ChainID] = newEthRPC(chain.RPCURL)
+	}
+	return r, nil
+}
+
+func (r *EvmVaultBalanceReader) VaultBalance(ctx context.Context, vault VaultInfo) (decimal.Decimal, error) {
+	rpc, ok := r.rpcs[vault.ChainID]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("no RPC endpoint for chain %s", vault.ChainID)
+	}
+	decimals := vault.Decimals
+	if decimals == 0 {
+		decimals = nativeDecimals
+	}
+
+	if vault.TokenAddress == "" {
+		balance, err := rpc.callBig(ctx, "eth_getBalance", []interface{}{vault.VaultAddress, "latest"})
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return fromBaseUnits(balance, decimals), nil
+	}
+
+	owner, err := parseEvmAddress(vault.VaultAddress)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	var result string
+	err = rpc.call(ctx, "eth_call", []interface{}{map[string]interface{}{
+		"to":   vault.TokenAddress,
+		"data": "0x" + hex.EncodeToString(abiCall(selectorBalanceOf, abiStatic(abiAddress(owner)))),
+	}, "latest"}, &result)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	balance, err := parseHexBig(result)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("balanceOf: %w", err)
+	}
+	return fromBaseUnits(balance, decimals), nil
+}
+
+// SuiSupplyReader reads the total supplies of the f and x coins from a Sui
+// full node.
+type SuiSupplyReader struct {
+	client     *suiclient.ClientImpl
+	fTokenType string
+	xTokenType string
+}
+
+// NewSuiSupplyReaderFromEnv returns a reader for LFS_SUI_FTOKEN_TYPE and
+// LFS_SUI_XTOKEN_TYPE on LFS_SUI_RPC_URL, or nil unless all are set.
+func NewSuiSupplyReaderFromEnv() *SuiSupplyReader {
+	rpc := envOrDefault("", "LFS_SUI_RPC_URL")
+	fTokenType := envOrDefault("", "LFS_SUI_FTOKEN_TYPE")
+	xTokenType := envOrDefault("", "LFS_SUI_XTOKEN_TYPE")
+	if rpc == "" || fTokenType == "" || xTokenType == "" {
+		return nil
+	}
+	return &SuiSupplyReader{
+		client:     suiclient.NewClient(rpc),
+		fTokenType: fTokenType,
+		xTokenType: xTokenType,
+	}
+}
+
+func (r *SuiSupplyReader) TokenSupply(ctx context.Context) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for _, coinType := range []string{r.fTokenType, r.xTokenType} {
+		supply, err := r.client.GetTotalSupply(ctx, coinType)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("total supply of %s: %w", coinType, err)
+		}
+		if supply.Value == nil || supply.Value.Int == nil {
+			return decimal.Zero, fmt.Errorf("total supply of %s: empty response", coinType)
+		}
+		total = total.Add(fromBaseUnits(supply.Value.Int, suiTokenDecimals))
+	}
+	return total, nil
+}
+
+// WebhookAlerter posts each alert as JSON to URL.
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (a *WebhookAlerter) Alert(ctx context.Context, drift ReconcileDrift) error {
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	scope := "all chains"
+	if drift.ChainID != "" {
+		scope = fmt.Sprintf("%s:%s", drift.ChainID, drift.Asset)
+	}
+	body, err := json.Marshal(struct {
+		Text string `json:"text"` // Shown by Slack-compatible receivers
+		ReconcileDrift
+	}{
+		Text:           fmt.Sprintf("Bridge reconciliation diverged: %s check on %s drifted %s", drift.Check, scope, drift.Drift.StringFixed(6)),
+		ReconcileDrift: drift,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("post alert: status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+type vaultBalanceFunc func(context.Context, VaultInfo) (decimal.Decimal, error)
+
+func (f vaultBalanceFunc) VaultBalance(ctx context.Context, vault VaultInfo) (decimal.Decimal, error) {
+	return f(ctx, vault)
+}
+
+type supplyFunc func(context.Context) (decimal.Decimal, error)
+
+func (f supplyFunc) TokenSupply(ctx context.Context) (decimal.Decimal, error) {
+	return f(ctx)
+}
+
+type alerts []ReconcileDrift
+
+func (a *alerts) Alert(_ context.Context, drift ReconcileDrift) error {
+	*a = append(*a, drift)
+	return nil
+}
+
+// findDrift returns the drift of check in drifts
+func findDrift(t *testing.T, drifts []ReconcileDrift, check string) ReconcileDrift {
+	t.Helper()
+	for _, d := range drifts {
+		if d.Check == check {
+			return d
+		}
+	}
+	t.Fatalf("No %s check in %+v", check, drifts)
+	return ReconcileDrift{}
+}
+
+func TestReconcilerAlertsOnDivergence(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(zap.NewNop().Sugar())
+
+	// The seeded checkpoint counts 0.5 shares at index 1.0001
+	vaultBalance := decimal.RequireFromString("0.50005")
+	supply := decimal.RequireFromString("0.5")
+	var sent alerts
+	reconciler := NewReconciler(svc, ReconcilerConfig{
+		Vaults: vaultBalanceFunc(func(context.Context, VaultInfo) (decimal.Decimal, error) {
+			return vaultBalance, nil
+		}),
+		Supply:    supplyFunc(func(context.Context) (decimal.Decimal, error) { return supply, nil }),
+		Alerter:   &sent,
+		Tolerance: decimal.RequireFromString("0.01"),
+	}, zap.NewNop().Sugar())
+
+	drifts := reconciler.RunOnce(ctx, time.Now())
+	if len(drifts) != 3 {
+		t.Fatalf("Expected vault, ledger and supply checks, got %+v", drifts)
+	}
+	for _, d := range drifts {
+		if d.Diverged {
+			t.Errorf("Expected matching state to reconcile, got %+v", d)
+		}
+	}
+
+	// Drift within the tolerance is fine; beyond it alerts once
+	vaultBalance = decimal.RequireFromString("0.4975")
+	if d := findDrift(t, reconciler.RunOnce(ctx, time.Now()), ReconcileVault); d.Diverged {
+		t.Errorf("Expected a 0.5%% drift to be tolerated, got %+v", d)
+	}
+	vaultBalance = decimal.RequireFromString("0.4")
+	for i := 0; i < 2; i++ {
+		if d := findDrift(t, reconciler.RunOnce(ctx, time.Now()), ReconcileVault); !d.Diverged || d.Drift.StringFixed(4) != "-0.2001" {
+			t.Errorf("Expected the vault to diverge, got %+v", d)
+		}
+	}
+	if len(sent) != 1 || sent[0].Check != ReconcileVault || sent[0].ChainID != ChainIDEthereum {
+		t.Fatalf("Expected one vault alert, got %+v", sent)
+	}
+
+	// Recovery and a new divergence alert again
+	vaultBalance = decimal.RequireFromString("0.50005")
+	reconciler.RunOnce(ctx, time.Now())
+	vaultBalance = decimal.Zero
+	reconciler.RunOnce(ctx, time.Now())
+	if len(sent) != 2 {
+		t.Errorf("Expected a second alert after recovering, got %+v", sent)
+	}
+
+	// Balances credited without a checkpoint, and a supply minted without
+	// shares, are caught too
+	if _, err := svc.CreditDeposit(ctx, "0xalice", ChainIDEthereum, "ETH", decimal.RequireFromString("1")); err != nil {
+		t.Fatalf("CreditDeposit failed: %v", err)
+	}
+	supply = decimal.RequireFromString("2")
+	drifts = reconciler.RunOnce(ctx, time.Now())
+	if d := findDrift(t, drifts, ReconcileLedger); !d.Diverged || !d.Actual.Equal(decimal.RequireFromString("1.5")) {
+		t.Errorf("Expected the ledger to diverge, got %+v", d)
+	}
+	if d := findDrift(t, drifts, ReconcileSupply); !d.Diverged || !d.Drift.Equal(decimal.RequireFromString("3")) {
+		t.Errorf("Expected the supply to diverge, got %+v", d)
+	}
+}
+
+func TestWebhookAlerter(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode alert: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	alerter := &WebhookAlerter{URL: srv.URL}
+	err := alerter.Alert(context.Background(), ReconcileDrift{
+		Check:    ReconcileVault,
+		ChainID:  ChainIDEthereum,
+		Asset:    "ETH",
+		Expected: decimal.RequireFromString("1"),
+		Actual:   decimal.RequireFromString("0.5"),
+		Drift:    decimal.RequireFromString("-0.5"),
+		Diverged: true,
+	})
+	if err != nil {
+		t.Fatalf("Alert failed: %v", err)
+	}
+	if got["check"] != ReconcileVault || got["asset"] != "ETH" || got["text"] == "" {
+		t.Errorf("Unexpected alert body: %v", got)
+	}
+}
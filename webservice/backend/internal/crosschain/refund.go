@@ -0,0 +1,289 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/shopspring/decimal"
+)
+
+// RefundStatus tracks the refund of a failed redeem.
+type RefundStatus string
+
+const (
+	// RefundStatusPending marks a refund waiting for an operator
+	RefundStatusPending RefundStatus = "pending"
+
+	// RefundStatusRefunded marks a refund whose burned tokens were minted
+	// back on Sui
+	RefundStatusRefunded RefundStatus = "refunded"
+
+	// RefundStatusRejected marks a refund settled some other way, e.g. a
+	// payout made by hand
+	RefundStatusRejected RefundStatus = "rejected"
+)
+
+// BridgeRefund compensates a redeem whose payout failed for good. It has the
+// ID of the redeem receipt. Approving it credits the burned shares back,
+// returns the redeem fee and mints the burned tokens on Sui again.
+type BridgeRefund struct {
+	ID           string          `json:"id"`
+	ChainID      ChainID         `json:"chainId"`
+	Asset        string          `json:"asset"`
+	SuiOwner     string          `json:"suiOwner"`
+	Token        string          `json:"token"`  // "f" or "x"
+	Amount       decimal.Decimal `json:"amount"` // Burned, in token units
+	Fee          decimal.Decimal `json:"fee"`    // Redeem fee given back
+	Status       RefundStatus    `json:"status"`
+	Reason       string          `json:"reason,omitempty"` // Why the payout failed, or the refund was rejected
+	Credited     bool            `json:"credited"`         // Shares credited back; set before the mint
+	SuiTxDigests []string        `json:"suiTxDigests,omitempty"`
+	LastError    string          `json:"lastError,omitempty"` // Of the last failed approval
+	CreatedAt    time.Time       `json:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt"`
+}
+
+// RequestRefund records refund as pending. A redeem refunded twice fails
+// with ErrDepositInProgress.
+func (s *Service) RequestRefund(ctx context.Context, refund *BridgeRefund) error {
+	if refund.ID == "" || refund.SuiOwner == "" || !refund.Amount.IsPositive() {
+		return ErrInvalidRequest
+	}
+	now := time.Now()
+	refund.Status = RefundStatusPending
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+
+	if s.store != nil {
+		err := s.store.createRefund(ctx, refund)
+		if errors.Is(err, interfaces.ErrUniqueConstraint) {
+			return ErrDepositInProgress
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.refunds[refund.ID]; ok {
+		return ErrDepositInProgress
+	}
+	saved := *refund
+	s.refunds[refund.ID] = &saved
+	return nil
+}
+
+// GetRefund returns the refund of the redeem with receiptID, or ErrNotFound.
+func (s *Service) GetRefund(ctx context.Context, receiptID string) (*BridgeRefund, error) {
+	if s.store != nil {
+		return s.store.getRefund(ctx, receiptID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	refund, ok := s.refunds[receiptID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *refund
+	return &found, nil
+}
+
+// ListRefunds returns the refunds with status, or every refund when it is
+// empty, oldest first.
+func (s *Service) ListRefunds(ctx context.Context, status RefundStatus) ([]*BridgeRefund, error) {
+	if s.store != nil {
+		return s.store.findRefunds(ctx, status)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var refunds []*BridgeRefund
+	for _, refund := range s.refunds {
+		if status == "" || refund.Status == status {
+			found := *refund
+			refunds = append(refunds, &found)
+		}
+	}
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].CreatedAt.Before(refunds[j].CreatedAt) })
+	return refunds, nil
+}
+
+// saveRefund writes the outcome of an approval or rejection of refund
+func (s *Service) saveRefund(ctx context.Context, refund *BridgeRefund) error {
+	refund.UpdatedAt = time.Now()
+	if s.store != nil {
+		return s.store.saveRefund(ctx, refund)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.refunds[refund.ID]; !ok {
+		return ErrNotFound
+	}
+	saved := *refund
+	s.refunds[refund.ID] = &saved
+	return nil
+}
+
+// RejectRefund settles a pending refund without minting, e.g. once the
+// payout has been made by hand. A refund whose shares were credited back
+// cannot be rejected.
+func (s *Service) RejectRefund(ctx context.Context, receiptID, reason string) (*BridgeRefund, error) {
+	refund, err := s.GetRefund(ctx, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status != RefundStatusPending || refund.Credited {
+		return nil, fmt.Errorf("%w: refund %s is %s and cannot be rejected", ErrInvalidRequest, receiptID, refund.Status)
+	}
+	refund.Status = RefundStatusRejected
+	refund.Reason = reason
+	if err := s.saveRefund(ctx, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// requestRefund records a refund for receipt after its payout failed for
+// good with cause, and fails the redeem
+func (w *BridgeWorker) requestRefund(ctx context.Context, receipt *RedeemReceipt, cause error) error {
+	amount, err := decimal.NewFromString(receipt.Burned)
+	if err != nil {
+		return fmt.Errorf("parse burned amount %q: %w", receipt.Burned, err)
+	}
+	fee := decimal.Zero
+	if receipt.Fee != "" {
+		if fee, err = decimal.NewFromString(receipt.Fee); err != nil {
+			return fmt.Errorf("parse fee %q: %w", receipt.Fee, err)
+		}
+	}
+
+	if err := w.svc.RequestRefund(ctx, &BridgeRefund{
+		ID:       receipt.ReceiptID,
+		ChainID:  receipt.ChainID,
+		Asset:    receipt.Asset,
+		SuiOwner: receipt.SuiOwner,
+		Token:    receipt.Token,
+		Amount:   amount,
+		Fee:      fee,
+		Reason:   cause.Error(),
+	}); err != nil {
+		return err
+	}
+	w.advanceRedeem(ctx, receipt, StageFailed, "payout failed; refund requested: "+cause.Error())
+	w.logger.Errorw("Bridge payout failed for good; refund awaits approval",
+		"receiptId", receipt.ReceiptID,
+		"suiOwner", receipt.SuiOwner,
+		"token", receipt.Token,
+		"burned", receipt.Burned,
+		"error", cause,
+	)
+	return nil
+}
+
+// refundStuckPayout requests a refund for the redeem of a payout retry that
+// used up its attempts
+func (w *BridgeWorker) refundStuckPayout(ctx context.Context, retry *BridgeRetry, cause error) {
+	var payload payoutRetry
+	if err := json.Unmarshal(retry.Payload, &payload); err != nil {
+		w.logger.Errorw("Failed to request refund of stuck payout", "retryId", retry.ID, "error", err)
+		return
+	}
+	receipt := payload.Receipt
+	if err := w.requestRefund(ctx, &receipt, cause); err != nil {
+		w.logger.Errorw("Failed to request refund of stuck payout", "retryId", retry.ID, "error", err)
+	}
+}
+
+// ApproveRefund refunds a pending refund: the burned shares are credited
+// back under a new checkpoint, the redeem fee is returned and the burned
+// tokens are minted on Sui again. A failed mint leaves the refund pending,
+// with its shares credited, to be approved again.
+func (w *BridgeWorker) ApproveRefund(ctx context.Context, receiptID string) (*BridgeRefund, error) {
+	w.depositMu.Lock()
+	defer w.depositMu.Unlock()
+
+	refund, err := w.svc.GetRefund(ctx, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status != RefundStatusPending {
+		return nil, fmt.Errorf("%w: refund %s is %s", ErrInvalidRequest, receiptID, refund.Status)
+	}
+	if w.mintHandler == nil {
+		return nil, fmt.Errorf("mint handler not configured")
+	}
+
+	sub := DepositSubmission{
+		SuiOwner: refund.SuiOwner,
+		ChainID:  refund.ChainID,
+		Asset:    refund.Asset,
+		Amount:   refund.Amount,
+	}
+	if !refund.Credited {
+		if _, _, err := w.updateWalrusCheckpoint(ctx, sub); err != nil {
+			return nil, fmt.Errorf("credit refund: %w", err)
+		}
+		if err := w.svc.collectFees(ctx, refund.ChainID, refund.Asset, BridgeOpRedeem, refund.Fee.Neg()); err != nil {
+			w.logger.Errorw("Failed to return bridge fee", "receiptId", receiptID, "error", err)
+		}
+		refund.Credited = true
+		if err := w.svc.saveRefund(ctx, refund); err != nil {
+			// The shares are credited, so the refund must not credit them again
+			w.logger.Errorw("Bridge refund credited but not saved; do not approve it again",
+				"receiptId", receiptID,
+				"error", err,
+			)
+			return nil, fmt.Errorf("save refund: %w", err)
+		}
+	}
+
+	mint := BridgeMintContext{Submission: sub, NewShares: refund.Amount}
+	if refund.Token == "x" {
+		mint.MintX = toUint(refund.Amount)
+	} else {
+		mint.MintF = toUint(refund.Amount)
+	}
+	result, err := w.mintHandler.Mint(ctx, mint)
+	if err != nil {
+		refund.LastError = err.Error()
+		if serr := w.svc.saveRefund(ctx, refund); serr != nil {
+			w.logger.Errorw("Failed to save bridge refund", "receiptId", receiptID, "error", serr)
+		}
+		return nil, fmt.Errorf("mint handler: %w", err)
+	}
+
+	refund.Status = RefundStatusRefunded
+	refund.LastError = ""
+	if result != nil {
+		refund.SuiTxDigests = append([]string{}, result.TxDigests...)
+	}
+	// The tokens have been minted, so failures from here on are only logged
+	if err := w.svc.saveRefund(ctx, refund); err != nil {
+		w.logger.Errorw("Failed to save bridge refund", "receiptId", receiptID, "suiTxDigests", refund.SuiTxDigests, "error", err)
+	}
+	if found, err := w.svc.GetReceipt(ctx, receiptID); err == nil && found.Redeem != nil {
+		w.advanceRedeem(ctx, found.Redeem, StageRefunded, "burned tokens minted back")
+	}
+	if retry, err := w.svc.getRetry(ctx, fmt.Sprintf("%s:%s", RetryKindPayout, receiptID)); err == nil && retry.Status == RetryStatusStuck {
+		retry.Status = RetryStatusDone
+		retry.LastError = "refunded"
+		if err := w.svc.SaveRetry(ctx, retry); err != nil {
+			w.logger.Errorw("Failed to close refunded payout retry", "retryId", retry.ID, "error", err)
+		}
+	}
+
+	w.logger.Infow("Bridge refund minted",
+		"receiptId", receiptID,
+		"suiOwner", refund.SuiOwner,
+		"token", refund.Token,
+		"amount", refund.Amount.String(),
+		"suiTxDigests", refund.SuiTxDigests,
+	)
+	return refund, nil
+}
@@ -0,0 +1,131 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// brokenPayer fails every payout
+type brokenPayer struct{}
+
+func (brokenPayer) Payout(_ context.Context, _ RedeemPayoutContext) (string, error) {
+	return "", errors.New("vault drained")
+}
+
+// recordingMinter records its mints and fails them while fail is set
+type recordingMinter struct {
+	fail  bool
+	mints []BridgeMintContext
+}
+
+func (m *recordingMinter) Mint(_ context.Context, mint BridgeMintContext) (*MintResult, error) {
+	if m.fail {
+		return nil, errors.New("sui rpc unavailable")
+	}
+	m.mints = append(m.mints, mint)
+	return &MintResult{TxDigests: []string{"0xrefund"}}, nil
+}
+
+func TestStuckPayoutsAreRefunded(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, _ := newTestService(t)
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			minter := &recordingMinter{}
+			worker := NewBridgeWorker(svc, logger,
+				WithMintHandler(minter),
+				WithPayoutHandler(brokenPayer{}),
+				WithPriceSource(fixedPrice(decimal.RequireFromString("2000"))),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+			)
+			if _, err := worker.handle(ctx, DepositSubmission{
+				TxHash:   "0xrefund",
+				SuiOwner: "0xalice",
+				ChainID:  ChainIDEthereum,
+				Asset:    "ETH",
+				Amount:   decimal.RequireFromString("2"),
+			}); err != nil {
+				t.Fatalf("handle failed: %v", err)
+			}
+
+			// The payout is retried until stuck, and then refunded
+			redeem, err := worker.Redeem(ctx, RedeemSubmission{
+				SuiTxDigest:  "burnrefund",
+				SuiOwner:     "0xalice",
+				EthRecipient: "0xdead",
+				ChainID:      ChainIDEthereum,
+				Asset:        "ETH",
+				Token:        "x",
+				Amount:       decimal.RequireFromString("1"),
+			})
+			if err != nil {
+				t.Fatalf("Redeem failed: %v", err)
+			}
+			if refunds, _ := svc.ListRefunds(ctx, ""); len(refunds) != 0 {
+				t.Fatalf("Expected no refund while the payout is retried, got %+v", refunds)
+			}
+			worker.retryDue(ctx, time.Now().Add(time.Hour))
+			refunds, err := svc.ListRefunds(ctx, RefundStatusPending)
+			if err != nil || len(refunds) != 1 || refunds[0].ID != redeem.ReceiptID || refunds[0].Amount.String() != "1" || refunds[0].Reason == "" {
+				t.Fatalf("Expected a pending refund of the redeem, got %+v (%v)", refunds, err)
+			}
+			if _, err := svc.RequeueRetry(ctx, "payout:"+redeem.ReceiptID); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a payout with a pending refund not to be requeued, got %v", err)
+			}
+			burned, err := svc.GetBalance(ctx, "0xalice", ChainIDEthereum, "ETH")
+			if err != nil {
+				t.Fatalf("GetBalance failed: %v", err)
+			}
+			shares := burned.Shares
+
+			// A failed mint keeps the refund pending without crediting twice
+			minter.fail = true
+			if _, err := worker.ApproveRefund(ctx, redeem.ReceiptID); err == nil {
+				t.Fatal("Expected the approval to fail with the mint")
+			}
+			minter.fail = false
+			refund, err := worker.ApproveRefund(ctx, redeem.ReceiptID)
+			if err != nil {
+				t.Fatalf("ApproveRefund failed: %v", err)
+			}
+			if refund.Status != RefundStatusRefunded || len(refund.SuiTxDigests) != 1 || refund.LastError != "" {
+				t.Errorf("Unexpected refund: %+v", refund)
+			}
+			last := minter.mints[len(minter.mints)-1]
+			if last.MintX != 1_000_000_000 || last.MintF != 0 || last.Submission.SuiOwner != "0xalice" {
+				t.Errorf("Expected the burned x-token to be minted back, got %+v", last)
+			}
+			bal, _ := svc.GetBalance(ctx, "0xalice", ChainIDEthereum, "ETH")
+			if !bal.Shares.Equal(shares.Add(decimal.RequireFromString("1"))) {
+				t.Errorf("Expected the burned shares to be credited once, got %s after %s", bal.Shares, shares)
+			}
+			found, err := svc.GetReceipt(ctx, redeem.ReceiptID)
+			if err != nil || found.Stage() != StageRefunded {
+				t.Errorf("Expected the redeem to be refunded, got %+v (%v)", found, err)
+			}
+			if done, _ := svc.ListRetries(ctx, RetryStatusDone); len(done) != 1 || done[0].LastError != "refunded" {
+				t.Errorf("Expected the payout retry to be closed, got %+v", done)
+			}
+
+			if _, err := worker.ApproveRefund(ctx, redeem.ReceiptID); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a refund not to be approved twice, got %v", err)
+			}
+			if _, err := svc.RejectRefund(ctx, redeem.ReceiptID, "paid by hand"); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a refunded refund not to be rejected, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package crosschain
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ReorgEvent reports that the blocks of an origin chain from FromBlock on
+// were replaced by a reorganization.
+type ReorgEvent struct {
+	ChainID   ChainID
+	FromBlock uint64
+}
+
+// DepositRollback describes the state rolled back after a reorg.
+type DepositRollback struct {
+	ChainID   ChainID
+	FromBlock uint64
+
+	// Reverted are the deposits rolled back; their shares were debited
+	// again. Their Sui mints are not undone.
+	Reverted []*BridgeReceipt
+
+	// Rejected are the update IDs of the checkpoints that counted reverted
+	// shares, now marked CheckpointStatusRejected
+	Rejected []uint64
+
+	// Checkpoints replace the rejected ones, one per affected asset
+	Checkpoints []*WalrusCheckpoint
+}
+
+// reclaimReverted carries the Sui mint of a reverted deposit over to its
+// resubmission, so that a deposit included again after a reorg is credited
+// again but not minted twice
+func reclaimReverted(receipt, previous *BridgeReceipt) {
+	receipt.Minted = previous.Minted
+	receipt.SuiTxDigests = previous.SuiTxDigests
+	receipt.Shares = previous.Shares
+}
+
+// RevertDeposits rolls back the minted deposits of ev.ChainID in blocks
+// from ev.FromBlock on. Their shares are debited from their owners, never
+// below zero, and they are marked DepositStatusReverted so that deposits
+// the new chain includes again are claimed again. Every checkpoint from the
+// first that counted a reverted deposit is rejected, and a checkpoint
+// without the reverted shares is submitted in their place. With a database,
+// the rollback is written in one transaction before memory changes.
+func (s *Service) RevertDeposits(ctx context.Context, ev ReorgEvent) (*DepositRollback, error) {
+	if ev.ChainID == "" {
+		return nil, ErrInvalidRequest
+	}
+	// Deposits submitted without a block are at block zero and never reverted
+	fromBlock := ev.FromBlock
+	if fromBlock == 0 {
+		fromBlock = 1
+	}
+
+	var deposits []*BridgeReceipt
+	if s.store != nil {
+		found, err := s.store.findMintedDeposits(ctx, ev.ChainID, fromBlock)
+		if err != nil {
+			return nil, err
+		}
+		deposits = found
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.store == nil {
+		for _, receipt := range s.deposits {
+			if receipt.ChainID == ev.ChainID && receipt.Status == DepositStatusMinted && receipt.BlockNumber >= fromBlock {
+				found := *receipt
+				deposits = append(deposits, &found)
+			}
+		}
+		sort.Slice(deposits, func(i, j int) bool {
+			if deposits[i].BlockNumber != deposits[j].BlockNumber {
+				return deposits[i].BlockNumber < deposits[j].BlockNumber
+			}
+			return deposits[i].LogIndex < deposits[j].LogIndex
+		})
+	}
+
+	rollback := &DepositRollback{ChainID: ev.ChainID, FromBlock: ev.FromBlock, Reverted: deposits}
+	if len(deposits) == 0 {
+		return rollback, nil
+	}
+
+	now := time.Now()
+	revertedShares := make(map[string]decimal.Decimal)
+	firstUpdate := make(map[string]uint64)
+	for _, receipt := range deposits {
+		revertedShares[receipt.Asset] = revertedShares[receipt.Asset].Add(receipt.Shares)
+		if id := receipt.WalrusUpdateID; id > 0 && (firstUpdate[receipt.Asset] == 0 || id < firstUpdate[receipt.Asset]) {
+			firstUpdate[receipt.Asset] = id
+		}
+		receipt.Status = DepositStatusReverted
+	}
+	assets := make([]string, 0, len(revertedShares))
+	for asset := range revertedShares {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	// Reject the checkpoints counting reverted shares and replace them
+	var (
+		rejected     []*WalrusCheckpoint
+		rejectedFrom []*WalrusCheckpoint // The in-memory checkpoints to reject
+		replacements = make(map[string]*WalrusCheckpoint)
+		updateID     = s.updateCounter
+	)
+	for _, asset := range assets {
+		cps := s.checkpoints[s.mapKey(ev.ChainID, asset)]
+		if len(cps) == 0 {
+			continue
+		}
+		for _, cp := range cps {
+			if firstUpdate[asset] > 0 && cp.UpdateID >= firstUpdate[asset] && cp.Status != CheckpointStatusRejected {
+				next := *cp
+				next.Status = CheckpointStatusRejected
+				rejected = append(rejected, &next)
+				rejectedFrom = append(rejectedFrom, cp)
+				rollback.Rejected = append(rollback.Rejected, cp.UpdateID)
+			}
+		}
+
+		latest := cps[len(cps)-1]
+		totalShares := latest.TotalShares.Sub(revertedShares[asset])
+		if totalShares.IsNegative() {
+			totalShares = decimal.Zero
+		}
+		updateID++
+		replacements[asset] = &WalrusCheckpoint{
+			UpdateID:    updateID,
+			ChainID:     ev.ChainID,
+			Asset:       asset,
+			Vault:       latest.Vault,
+			BlockNumber: latest.BlockNumber + 1,
+			TotalShares: totalShares,
+			Index:       latest.Index,
+			ProofType:   "reorg",
+			Status:      s.initialCheckpointStatus(),
+			Timestamp:   now,
+		}
+	}
+	for _, asset := range assets {
+		if cp, ok := replacements[asset]; ok {
+			rollback.Checkpoints = append(rollback.Checkpoints, cp)
+		}
+	}
+
+	// Debit the reverted shares from their owners
+	nextBalances := make(map[string]*CrossChainBalance)
+	for _, receipt := range deposits {
+		key := s.balanceKey(receipt.SuiOwner, receipt.ChainID, receipt.Asset)
+		next, ok := nextBalances[key]
+		if !ok {
+			bal, found := s.balances[key]
+			if !found {
+				s.logger.Errorw("Reorged deposit has no balance to debit", "receiptId", receipt.ReceiptID, "suiOwner", receipt.SuiOwner)
+				continue
+			}
+			copied := *bal
+			next = &copied
+			nextBalances[key] = next
+		}
+		if next.Shares.LessThan(receipt.Shares) {
+			s.logger.Errorw("Reorged deposit exceeds the remaining balance; debiting what is left",
+				"receiptId", receipt.ReceiptID,
+				"suiOwner", receipt.SuiOwner,
+				"shares", receipt.Shares.String(),
+				"balance", next.Shares.String(),
+			)
+			next.Shares = decimal.Zero
+		} else {
+			next.Shares = next.Shares.Sub(receipt.Shares)
+		}
+		next.Value = next.Shares.Mul(next.Index)
+		if cp, ok := replacements[receipt.Asset]; ok {
+			next.LastCheckpointID = cp.UpdateID
+		}
+		next.UpdatedAt = now
+	}
+	for asset, cp := range replacements {
+		cp.BalancesRoot = s.balancesRootLocked(ev.ChainID, asset, nextBalances)
+	}
+
+	if s.store != nil {
+		balances := make([]*CrossChainBalance, 0, len(nextBalances))
+		for _, next := range nextBalances {
+			balances = append(balances, next)
+		}
+		if err := s.store.saveRollback(ctx, deposits, balances, rejected, rollback.Checkpoints); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, receipt := range deposits {
+			reverted := *receipt
+			s.deposits[s.depositKey(receipt.ChainID, receipt.TxHash, receipt.LogIndex)] = &reverted
+		}
+	}
+
+	for _, cp := range rejectedFrom {
+		cp.Status = CheckpointStatusRejected
+	}
+	for _, cp := range rollback.Checkpoints {
+		key := s.mapKey(cp.ChainID, cp.Asset)
+		s.checkpoints[key] = append(s.checkpoints[key], cp)
+	}
+	s.updateCounter = updateID
+	for key, next := range nextBalances {
+		*s.balances[key] = *next
+	}
+
+	s.logger.Warnw("Rolled back reorged deposits",
+		"chainId", ev.ChainID,
+		"fromBlock", ev.FromBlock,
+		"deposits", len(deposits),
+		"rejectedCheckpoints", rollback.Rejected,
+	)
+	return rollback, nil
+}
@@ -0,0 +1,99 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ReplayStats reports the progress of a deposit replay. NextBlock is the
+// first block not replayed yet, where an interrupted replay resumes.
+type ReplayStats struct {
+	ChainID   ChainID `json:"chainId"`
+	FromBlock uint64  `json:"fromBlock"`
+	ToBlock   uint64  `json:"toBlock"`
+	NextBlock uint64  `json:"nextBlock"`
+	Found     int     `json:"found"`
+	Submitted int     `json:"submitted"`
+	Skipped   int     `json:"skipped"`
+	Invalid   int     `json:"invalid"`
+}
+
+// ReplayDeposits scans the vaults of l from fromBlock to toBlock for
+// deposits missed while the bridge was down and submits them to the worker,
+// which must be started. toBlock zero, or past the latest confirmed block,
+// stops at the latest confirmed block. Deposits whose receipts are minted or
+// still being minted are skipped; failed and reverted ones are submitted
+// again.
+func (w *BridgeWorker) ReplayDeposits(ctx context.Context, l *EthDepositListener, fromBlock, toBlock uint64) (*ReplayStats, error) {
+	head, err := l.blockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("eth_blockNumber: %w", err)
+	}
+	if head < l.cfg.Confirmations {
+		return nil, fmt.Errorf("%w: no confirmed blocks on %s", ErrInvalidRequest, l.cfg.ChainID)
+	}
+	confirmed := head - l.cfg.Confirmations
+	if toBlock == 0 || toBlock > confirmed {
+		toBlock = confirmed
+	}
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("%w: from block %d is past block %d", ErrInvalidRequest, fromBlock, toBlock)
+	}
+
+	stats := &ReplayStats{ChainID: l.cfg.ChainID, FromBlock: fromBlock, ToBlock: toBlock, NextBlock: fromBlock}
+	for stats.NextBlock <= toBlock {
+		to := stats.NextBlock + l.cfg.MaxBlockRange - 1
+		if to > toBlock {
+			to = toBlock
+		}
+		deposits, err := l.deposits(ctx, stats.NextBlock, to)
+		if err != nil {
+			return stats, err
+		}
+		for _, sub := range deposits {
+			stats.Found++
+			processed, err := w.processed(ctx, sub)
+			if err != nil {
+				return stats, fmt.Errorf("look up deposit %s/%d: %w", sub.TxHash, sub.LogIndex, err)
+			}
+			if processed {
+				stats.Skipped++
+				continue
+			}
+			if _, err := w.submit(ctx, sub); err != nil {
+				if errors.Is(err, ErrInvalidRequest) {
+					w.logger.Warnw("Skipping invalid vault deposit", "error", err, "txHash", sub.TxHash, "logIndex", sub.LogIndex)
+					stats.Invalid++
+					continue
+				}
+				return stats, fmt.Errorf("submit deposit %s/%d: %w", sub.TxHash, sub.LogIndex, err)
+			}
+			stats.Submitted++
+		}
+		stats.NextBlock = to + 1
+		w.logger.Infow("Bridge replay progress",
+			"chainId", stats.ChainID,
+			"nextBlock", stats.NextBlock,
+			"toBlock", toBlock,
+			"submitted", stats.Submitted,
+			"skipped", stats.Skipped,
+		)
+	}
+	return stats, nil
+}
+
+// processed reports whether sub has a receipt that is minted or pending
+func (w *BridgeWorker) processed(ctx context.Context, sub DepositSubmission) (bool, error) {
+	receipts, err := w.svc.GetDeposits(ctx, sub.ChainID, sub.TxHash)
+	if err != nil {
+		return false, err
+	}
+	for _, receipt := range receipts {
+		if receipt.LogIndex != sub.LogIndex {
+			continue
+		}
+		return receipt.Status == DepositStatusMinted || receipt.Status == DepositStatusPending, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,98 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestBridgeWorkerReplaysMissedDeposits(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, _ := newTestService(t)
+
+	owner := "0x" + strings.Repeat("ab", 32)
+	oneEth := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	deposit := func(txHash string, logIndex int) ethLog {
+		return ethLog{
+			Address:  "0xvault",
+			Topics:   []string{depositEventTopic, "0x01", "0x02"},
+			Data:     depositLogData(oneEth, owner),
+			TxHash:   txHash,
+			LogIndex: fmt.Sprintf("0x%x", logIndex),
+		}
+	}
+	node := &fakeEthNode{
+		head: 120,
+		logs: map[uint64][]ethLog{
+			101: {deposit("0xAA", 0)},
+			105: {deposit("0xBB", 3)},
+			115: {deposit("0xCC", 0)}, // Not yet confirmed
+		},
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			l, err := NewEthDepositListener(EthDepositListenerConfig{
+				RPCURL:        server.URL,
+				VaultAddress:  "0xVault",
+				Confirmations: 10,
+				MaxBlockRange: 4,
+			}, logger)
+			if err != nil {
+				t.Fatalf("NewEthDepositListener failed: %v", err)
+			}
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			worker := NewBridgeWorker(svc, logger, WithPriceSource(fixedPrice(decimal.RequireFromString("2000"))))
+			worker.Start(runCtx)
+
+			// The first deposit was bridged before the downtime
+			if _, err := worker.handle(ctx, DepositSubmission{
+				TxHash:   "0xaa",
+				SuiOwner: owner,
+				ChainID:  ChainIDEthereum,
+				Asset:    "ETH",
+				Amount:   decimal.RequireFromString("1"),
+			}); err != nil {
+				t.Fatalf("handle failed: %v", err)
+			}
+
+			stats, err := worker.ReplayDeposits(ctx, l, 100, 0)
+			if err != nil {
+				t.Fatalf("ReplayDeposits failed: %v", err)
+			}
+			if stats.ToBlock != 110 || stats.NextBlock != 111 || stats.Found != 2 || stats.Skipped != 1 || stats.Submitted != 1 {
+				t.Errorf("Unexpected replay: %+v", stats)
+			}
+			receipts, err := svc.GetDeposits(ctx, ChainIDEthereum, "0xbb")
+			if err != nil || len(receipts) != 1 || receipts[0].Status != DepositStatusMinted || receipts[0].LogIndex != 3 {
+				t.Fatalf("Expected the missed deposit to be minted, got %+v (%v)", receipts, err)
+			}
+
+			// Replaying again submits nothing
+			stats, err = worker.ReplayDeposits(ctx, l, 100, 110)
+			if err != nil || stats.Submitted != 0 || stats.Skipped != 2 {
+				t.Errorf("Expected every deposit to be skipped, got %+v (%v)", stats, err)
+			}
+			if _, err := worker.ReplayDeposits(ctx, l, 111, 0); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a range past the confirmed blocks to be rejected, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// RetryKind names the bridge step a retry repeats.
+type RetryKind string
+
+const (
+	RetryKindMint   RetryKind = "mint"   // Sui mint of a credited deposit
+	RetryKindPayout RetryKind = "payout" // Origin-chain payout of a burn
+)
+
+// RetryStatus tracks a queued retry.
+type RetryStatus string
+
+const (
+	RetryStatusPending RetryStatus = "pending"
+	RetryStatusDone    RetryStatus = "done"
+
+	// RetryStatusStuck marks a retry that used up its attempts; it waits
+	// for an operator to requeue it
+	RetryStatusStuck RetryStatus = "stuck"
+)
+
+// BridgeRetry is a failed mint or payout queued to be attempted again.
+// Payload holds everything needed to repeat the step and complete its
+// receipt.
+type BridgeRetry struct {
+	ID            string          `json:"id"` // "<kind>:<receiptId>"
+	Kind          RetryKind       `json:"kind"`
+	ReceiptID     string          `json:"receiptId"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        RetryStatus     `json:"status"`
+	Attempts      int             `json:"attempts"` // Failed attempts, the first included
+	LastError     string          `json:"lastError,omitempty"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+// mintRetry is the payload of a RetryKindMint retry
+type mintRetry struct {
+	Receipt BridgeReceipt     `json:"receipt"`
+	Mint    BridgeMintContext `json:"mint"`
+}
+
+// payoutRetry is the payload of a RetryKindPayout retry
+type payoutRetry struct {
+	Receipt RedeemReceipt       `json:"receipt"`
+	Payout  RedeemPayoutContext `json:"payout"`
+}
+
+// RetryPolicy configures how the bridge worker retries failed steps.
+type RetryPolicy struct {
+	// MaxAttempts is how many failed attempts, the original included, mark
+	// a retry stuck. Default: 8.
+	MaxAttempts int
+
+	// BaseDelay is the wait after the first failure, doubled after each
+	// further one up to MaxDelay. Defaults: 5s and 10m.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// PollInterval is the time between checks for due retries. Default: 5s.
+	PollInterval time.Duration
+
+	// BatchSize caps the retries attempted per poll. Default: 20.
+	BatchSize int
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 8
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 5 * time.Second
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 10 * time.Minute
+	}
+	if p.PollInterval <= 0 {
+		p.PollInterval = 5 * time.Second
+	}
+	if p.BatchSize <= 0 {
+		p.BatchSize = 20
+	}
+	return p
+}
+
+// delay returns the wait before the attempt following the given number of
+// failed attempts
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempts && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// EnqueueRetry queues retry as pending. Its ID is derived from its kind and
+// receipt; a retry of the same step queued twice fails with
+// ErrDepositInProgress.
+func (s *Service) EnqueueRetry(ctx context.Context, retry *BridgeRetry) error {
+	if retry.Kind == "" || retry.ReceiptID == "" {
+		return ErrInvalidRequest
+	}
+	now := time.Now()
+	retry.ID = fmt.Sprintf("%s:%s", retry.Kind, retry.ReceiptID)
+	retry.Status = RetryStatusPending
+	retry.CreatedAt = now
+	retry.UpdatedAt = now
+	if retry.NextAttemptAt.IsZero() {
+		retry.NextAttemptAt = now
+	}
+
+	if s.store != nil {
+		err := s.store.createRetry(ctx, retry)
+		if errors.Is(err, interfaces.ErrUniqueConstraint) {
+			return ErrDepositInProgress
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.retries[retry.ID]; ok {
+		return ErrDepositInProgress
+	}
+	queued := *retry
+	s.retries[retry.ID] = &queued
+	return nil
+}
+
+// SaveRetry writes the outcome of an attempt of retry.
+func (s *Service) SaveRetry(ctx context.Context, retry *BridgeRetry) error {
+	retry.UpdatedAt = time.Now()
+	if s.store != nil {
+		return s.store.saveRetry(ctx, retry)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.retries[retry.ID]; !ok {
+		return ErrNotFound
+	}
+	saved := *retry
+	s.retries[retry.ID] = &saved
+	return nil
+}
+
+// DueRetries returns up to limit pending retries due by now, the most
+// overdue first.
+func (s *Service) DueRetries(ctx context.Context, now time.Time, limit int) ([]*BridgeRetry, error) {
+	if s.store != nil {
+		return s.store.findRetries(ctx, RetryStatusPending, now, limit)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var due []*BridgeRetry
+	for _, retry := range s.retries {
+		if retry.Status == RetryStatusPending && !retry.NextAttemptAt.After(now) {
+			found := *retry
+			due = append(due, &found)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// ListRetries returns the retries with status, oldest first.
+func (s *Service) ListRetries(ctx context.Context, status RetryStatus) ([]*BridgeRetry, error) {
+	if s.store != nil {
+		return s.store.findRetries(ctx, status, time.Time{}, 0)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var retries []*BridgeRetry
+	for _, retry := range s.retries {
+		if retry.Status == status {
+			found := *retry
+			retries = append(retries, &found)
+		}
+	}
+	sort.Slice(retries, func(i, j int) bool { return retries[i].CreatedAt.Before(retries[j].CreatedAt) })
+	return retries, nil
+}
+
+// RequeueRetry makes a stuck retry pending again with a fresh set of
+// attempts. Retries that are done cannot be requeued.
+func (s *Service) RequeueRetry(ctx context.Context, id string) (*BridgeRetry, error) {
+	retry, err := s.getRetry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if retry.Status == RetryStatusDone {
+		return nil, fmt.Errorf("%w: retry %s is done", ErrInvalidRequest, id)
+	}
+	// A payout whose refund is requested is only paid once the refund is rejected
+	if retry.Kind == RetryKindPayout {
+		if refund, err := s.GetRefund(ctx, retry.ReceiptID); err == nil && refund.Status != RefundStatusRejected {
+			return nil, fmt.Errorf("%w: retry %s has a %s refund", ErrInvalidRequest, id, refund.Status)
+		}
+	}
+
+	retry.Status = RetryStatusPending
+	retry.Attempts = 0
+	retry.NextAttemptAt = time.Now()
+	if err := s.SaveRetry(ctx, retry); err != nil {
+		return nil, err
+	}
+	return retry, nil
+}
+
+// getRetry returns the retry with id, or ErrNotFound
+func (s *Service) getRetry(ctx context.Context, id string) (*BridgeRetry, error) {
+	if s.store != nil {
+		return s.store.getRetry(ctx, id)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found, ok := s.retries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *found
+	return &copied, nil
+}
+
+// queueMint queues the mint of a credited deposit after it failed with
+// cause
+func (w *BridgeWorker) queueMint(ctx context.Context, receipt *BridgeReceipt, mint BridgeMintContext, cause error) error {
+	payload, err := json.Marshal(mintRetry{Receipt: *receipt, Mint: mint})
+	if err != nil {
+		return fmt.Errorf("encode mint retry: %w", err)
+	}
+	return w.svc.EnqueueRetry(ctx, &BridgeRetry{
+		Kind:          RetryKindMint,
+		ReceiptID:     receipt.ReceiptID,
+		Payload:       payload,
+		Attempts:      1,
+		LastError:     cause.Error(),
+		NextAttemptAt: time.Now().Add(w.retryPolicy.delay(1)),
+	})
+}
+
+// queuePayout queues the payout of a debited burn after it failed with
+// cause
+func (w *BridgeWorker) queuePayout(ctx context.Context, receipt *RedeemReceipt, payout RedeemPayoutContext, cause error) error {
+	payload, err := json.Marshal(payoutRetry{Receipt: *receipt, Payout: payout})
+	if err != nil {
+		return fmt.Errorf("encode payout retry: %w", err)
+	}
+	return w.svc.EnqueueRetry(ctx, &BridgeRetry{
+		Kind:          RetryKindPayout,
+		ReceiptID:     receipt.ReceiptID,
+		Payload:       payload,
+		Attempts:      1,
+		LastError:     cause.Error(),
+		NextAttemptAt: time.Now().Add(w.retryPolicy.delay(1)),
+	})
+}
+
+// runRetries attempts due retries every poll interval until ctx is done
+func (w *BridgeWorker) runRetries(ctx context.Context) {
+	ticker := time.NewTicker(w.retryPolicy.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.retryDue(ctx, time.Now())
+		}
+	}
+}
+
+// retryDue attempts the retries due by now once each
+func (w *BridgeWorker) retryDue(ctx context.Context, now time.Time) {
+	due, err := w.svc.DueRetries(ctx, now, w.retryPolicy.BatchSize)
+	if err != nil {
+		w.logger.Warnw("Failed to load bridge retries", "error", err)
+		return
+	}
+	for _, retry := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		w.attempt(ctx, retry)
+	}
+}
+
+// attempt repeats the step of retry and records the outcome
+func (w *BridgeWorker) attempt(ctx context.Context, retry *BridgeRetry) {
+	var err error
+	switch retry.Kind {
+	case RetryKindMint:
+		err = w.retryMint(ctx, retry)
+	case RetryKindPayout:
+		err = w.retryPayout(ctx, retry)
+	default:
+		err = fmt.Errorf("unknown retry kind %q", retry.Kind)
+	}
+
+	if err == nil {
+		retry.Status = RetryStatusDone
+		retry.LastError = ""
+		w.logger.Infow("Bridge retry succeeded", "retryId", retry.ID, "attempts", retry.Attempts+1)
+	} else {
+		retry.Attempts++
+		retry.LastError = err.Error()
+		if retry.Attempts >= w.retryPolicy.MaxAttempts {
+			retry.Status = RetryStatusStuck
+			w.logger.Errorw("Bridge retry is stuck; requeue it once the cause is fixed",
+				"retryId", retry.ID,
+				"attempts", retry.Attempts,
+				"error", err,
+			)
+			if retry.Kind == RetryKindPayout {
+				w.refundStuckPayout(ctx, retry, err)
+			}
+		} else {
+			retry.NextAttemptAt = time.Now().Add(w.retryPolicy.delay(retry.Attempts))
+			w.logger.Warnw("Bridge retry failed",
+				"retryId", retry.ID,
+				"attempts", retry.Attempts,
+				"nextAttemptAt", retry.NextAttemptAt,
+				"error", err,
+			)
+		}
+	}
+	if err := w.svc.SaveRetry(ctx, retry); err != nil {
+		w.logger.Errorw("Failed to save bridge retry", "retryId", retry.ID, "error", err)
+	}
+}
+
+func (w *BridgeWorker) retryMint(ctx context.Context, retry *BridgeRetry) error {
+	if w.mintHandler == nil {
+		return fmt.Errorf("mint handler not configured")
+	}
+	var payload mintRetry
+	if err := json.Unmarshal(retry.Payload, &payload); err != nil {
+		return fmt.Errorf("decode mint retry: %w", err)
+	}
+
+	result, err := w.mintHandler.Mint(ctx, payload.Mint)
+	if err != nil {
+		return fmt.Errorf("mint handler: %w", err)
+	}
+	receipt := payload.Receipt
+	w.completeMint(ctx, &receipt, result, "minted on retry")
+	return nil
+}
+
+func (w *BridgeWorker) retryPayout(ctx context.Context, retry *BridgeRetry) error {
+	if w.payoutHandler == nil {
+		return fmt.Errorf("payout handler not configured")
+	}
+	var payload payoutRetry
+	if err := json.Unmarshal(retry.Payload, &payload); err != nil {
+		return fmt.Errorf("decode payout retry: %w", err)
+	}
+
+	txHash, err := w.payoutHandler.Payout(ctx, payload.Payout)
+	if err != nil {
+		return fmt.Errorf("payout handler: %w", err)
+	}
+	receipt := payload.Receipt
+	receipt.PayoutTxHash = txHash
+	w.advanceRedeem(ctx, &receipt, StagePaid, "paid on retry")
+	// The payout has been made, so a receipt that fails to save is only logged
+	if err := w.svc.UpdateRedeemReceipt(ctx, &receipt); err != nil {
+		w.logger.Errorw("Failed to record bridge redeem payout", "receiptId", receipt.ReceiptID, "payoutTxHash", txHash, "error", err)
+	}
+	return nil
+}
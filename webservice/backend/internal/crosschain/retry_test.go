@@ -0,0 +1,128 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// flakyMinter fails the first `failures` mints and then succeeds
+type flakyMinter struct {
+	failures int
+	calls    int
+}
+
+func (m *flakyMinter) Mint(_ context.Context, _ BridgeMintContext) (*MintResult, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return nil, errors.New("sui rpc unavailable")
+	}
+	return &MintResult{TxDigests: []string{"0xdigest"}}, nil
+}
+
+func TestBridgeWorkerRetriesFailedMints(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, _ := newTestService(t)
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			minter := &flakyMinter{failures: 3}
+			policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: 90 * time.Second}
+			worker := NewBridgeWorker(svc, logger, WithMintHandler(minter), WithRetryPolicy(policy))
+
+			receipt, claimed, err := svc.ClaimDeposit(ctx, &BridgeReceipt{
+				ReceiptID: svc.NextReceiptID("bridge"),
+				TxHash:    "0xretry",
+				SuiOwner:  "0xalice",
+				ChainID:   ChainIDEthereum,
+				Asset:     "ETH",
+				CreatedAt: time.Now(),
+			})
+			if err != nil || !claimed {
+				t.Fatalf("Expected the deposit to be claimed, got %v (%v)", claimed, err)
+			}
+			receipt.Minted = "f=1,x=1"
+			mint := BridgeMintContext{NewShares: decimal.RequireFromString("1")}
+			if err := worker.queueMint(ctx, receipt, mint, errors.New("sui rpc unavailable")); err != nil {
+				t.Fatalf("queueMint failed: %v", err)
+			}
+			if err := worker.queueMint(ctx, receipt, mint, errors.New("again")); !errors.Is(err, ErrDepositInProgress) {
+				t.Fatalf("Expected a second retry of the mint to be rejected, got %v", err)
+			}
+
+			// Nothing is attempted before the backoff elapses
+			worker.retryDue(ctx, time.Now())
+			if minter.calls != 0 {
+				t.Fatalf("Expected no attempt before the retry is due, got %d", minter.calls)
+			}
+
+			// Each failure backs off further until the retry is stuck
+			var lastDelay time.Duration
+			for attempt := 2; attempt <= policy.MaxAttempts; attempt++ {
+				start := time.Now()
+				worker.retryDue(ctx, start.Add(time.Hour))
+				retries, err := svc.ListRetries(ctx, RetryStatusPending)
+				if err != nil {
+					t.Fatalf("ListRetries failed: %v", err)
+				}
+				if attempt == policy.MaxAttempts {
+					if len(retries) != 0 {
+						t.Fatalf("Expected no pending retries once stuck, got %+v", retries)
+					}
+					break
+				}
+				if len(retries) != 1 || retries[0].Attempts != attempt || retries[0].LastError == "" {
+					t.Fatalf("Unexpected retries after attempt %d: %+v", attempt, retries)
+				}
+				delay := retries[0].NextAttemptAt.Sub(start)
+				if delay <= lastDelay || delay > policy.MaxDelay+time.Second {
+					t.Errorf("Expected a longer delay capped at %s, got %s after %s", policy.MaxDelay, delay, lastDelay)
+				}
+				lastDelay = delay
+			}
+			stuck, err := svc.ListRetries(ctx, RetryStatusStuck)
+			if err != nil || len(stuck) != 1 || stuck[0].Attempts != policy.MaxAttempts {
+				t.Fatalf("Expected one stuck retry, got %+v (%v)", stuck, err)
+			}
+			worker.retryDue(ctx, time.Now().Add(time.Hour))
+			if minter.calls != policy.MaxAttempts-1 {
+				t.Fatalf("Expected stuck retries not to be attempted, got %d calls", minter.calls)
+			}
+
+			// A requeued retry starts over and completes the receipt
+			requeued, err := svc.RequeueRetry(ctx, stuck[0].ID)
+			if err != nil || requeued.Status != RetryStatusPending || requeued.Attempts != 0 {
+				t.Fatalf("Expected the retry to be requeued, got %+v (%v)", requeued, err)
+			}
+			worker.retryDue(ctx, time.Now().Add(time.Hour)) // Last failure
+			worker.retryDue(ctx, time.Now().Add(time.Hour))
+			done, err := svc.ListRetries(ctx, RetryStatusDone)
+			if err != nil || len(done) != 1 || done[0].LastError != "" {
+				t.Fatalf("Expected the retry to be done, got %+v (%v)", done, err)
+			}
+
+			receipts, err := svc.GetDeposits(ctx, ChainIDEthereum, "0xretry")
+			if err != nil || len(receipts) != 1 {
+				t.Fatalf("GetDeposits failed: %+v (%v)", receipts, err)
+			}
+			if receipts[0].Status != DepositStatusMinted || len(receipts[0].SuiTxDigests) != 1 {
+				t.Errorf("Expected the deposit to be minted, got %+v", receipts[0])
+			}
+
+			if _, err := svc.RequeueRetry(ctx, done[0].ID); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a done retry not to be requeued, got %v", err)
+			}
+			if _, err := svc.RequeueRetry(ctx, "mint:missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for an unknown retry, got %v", err)
+			}
+		})
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
@@ -16,37 +18,173 @@ import (
 var (
 	ErrNotFound       = errors.New("not found")
 	ErrInvalidRequest = errors.New("invalid request")
+
+	// ErrDepositInProgress is returned for a deposit that another
+	// submission is processing
+	ErrDepositInProgress = errors.New("deposit in progress")
 )
 
 // Service manages cross-chain checkpoints, balances, and vouchers in-memory.
+// With WithDatabase, checkpoints and balances are written through to the
+// database before they change in memory, and restored by Load.
 type Service struct {
 	mu sync.RWMutex
 
 	checkpoints map[string][]*WalrusCheckpoint
 	balances    map[string]*CrossChainBalance
 	vouchers    map[string]*WithdrawalVoucher
+	deposits    map[string]*BridgeReceipt // Without a database, by depositKey
+	redeems     map[string]*RedeemReceipt // Without a database, by receipt ID
 	params      map[string]CollateralParams
 	vaults      map[string]VaultInfo
+	retries     map[string]*BridgeRetry   // Without a database, by ID
+	refunds     map[string]*BridgeRefund  // Without a database, by receipt ID
+	controls    map[string]*BridgeControl // By controlKey
+
+	// Signatures by checkpoint update ID and signer
+	attestations map[uint64]map[string]*CheckpointAttestation
 
-	updateCounter uint64
-	nonceCounter  uint64
+	// Without a database, stage transitions by receipt ID
+	transitions map[string][]*ReceiptTransition
 
-	logger *zap.SugaredLogger
+	updateCounter  uint64
+	nonceCounter   uint64
+	receiptCounter uint64
+
+	store       *store             // Nil keeps state in memory only
+	walrus      *WalrusReader      // Nil disables checkpoint verification
+	attestation *AttestationConfig // Nil verifies checkpoints as submitted
+	vouchersCfg VoucherConfig
+	logger      *zap.SugaredLogger
+}
+
+// ServiceOption configures a Service
+type ServiceOption func(*Service)
+
+// WithDatabase persists checkpoints, balances and bridge receipts to
+// database, which must be migrated with the crosschain entity schemas.
+// Call Load before serving to restore the state of earlier runs.
+func WithDatabase(database interfaces.Database) ServiceOption {
+	return func(s *Service) {
+		s.store = newStore(database)
+	}
 }
 
-func NewService(logger *zap.SugaredLogger) *Service {
+// WithWalrusReader enables VerifyCheckpoint, which reads checkpoints back
+// from Walrus through r.
+func WithWalrusReader(r *WalrusReader) ServiceOption {
+	return func(s *Service) {
+		s.walrus = r
+	}
+}
+
+func NewService(logger *zap.SugaredLogger, opts ...ServiceOption) *Service {
 	s := &Service{
 		checkpoints: make(map[string][]*WalrusCheckpoint),
 		balances:    make(map[string]*CrossChainBalance),
 		vouchers:    make(map[string]*WithdrawalVoucher),
+		deposits:    make(map[string]*BridgeReceipt),
+		redeems:     make(map[string]*RedeemReceipt),
 		params:      make(map[string]CollateralParams),
 		vaults:      make(map[string]VaultInfo),
+		retries:     make(map[string]*BridgeRetry),
+		refunds:     make(map[string]*BridgeRefund),
+		controls:    make(map[string]*BridgeControl),
 		logger:      logger,
+
+		attestations: make(map[uint64]map[string]*CheckpointAttestation),
+		transitions:  make(map[string][]*ReceiptTransition),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	s.seedDefaults()
 	return s
 }
 
+// Load restores checkpoints, balances and the receipt counter from the
+// database. A database without checkpoints is filled with the seeded
+// state instead. Without WithDatabase it does nothing.
+func (s *Service) Load(ctx context.Context) error {
+	if s.store == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	controls, err := s.store.loadControls(ctx)
+	if err != nil {
+		return err
+	}
+	for _, control := range controls {
+		s.controls[controlKey(control.ChainID, control.Asset)] = control
+	}
+
+	checkpoints, err := s.store.loadCheckpoints(ctx)
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		var seeded []*WalrusCheckpoint
+		for _, cps := range s.checkpoints {
+			seeded = append(seeded, cps...)
+		}
+		var balances []*CrossChainBalance
+		for _, bal := range s.balances {
+			balances = append(balances, bal)
+		}
+		return s.store.saveState(ctx, seeded, balances)
+	}
+
+	balances, err := s.store.loadBalances(ctx)
+	if err != nil {
+		return err
+	}
+	receiptCounter, err := s.store.lastReceiptNumber(ctx)
+	if err != nil {
+		return err
+	}
+	voucherNonce, err := s.store.lastVoucherNonce(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.checkpoints = make(map[string][]*WalrusCheckpoint)
+	s.updateCounter = 0
+	for _, cp := range checkpoints {
+		key := s.mapKey(cp.ChainID, cp.Asset)
+		s.checkpoints[key] = append(s.checkpoints[key], cp)
+		if cp.UpdateID > s.updateCounter {
+			s.updateCounter = cp.UpdateID
+		}
+	}
+	s.balances = make(map[string]*CrossChainBalance, len(balances))
+	for _, bal := range balances {
+		s.balances[s.balanceKey(bal.SuiOwner, bal.ChainID, bal.Asset)] = bal
+	}
+	s.receiptCounter = receiptCounter
+	s.nonceCounter = voucherNonce
+
+	attestations, err := s.store.loadAttestations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, att := range attestations {
+		if s.attestations[att.UpdateID] == nil {
+			s.attestations[att.UpdateID] = make(map[string]*CheckpointAttestation)
+		}
+		s.attestations[att.UpdateID][att.Signer] = att
+	}
+
+	s.logger.Infow("Cross-chain state loaded",
+		"checkpoints", len(checkpoints),
+		"balances", len(balances),
+		"lastUpdateId", s.updateCounter,
+	)
+	return nil
+}
+
 func envOrDefault(def string, keys ...string) string {
 	for _, k := range keys {
 		if v := strings.TrimSpace(os.Getenv(k)); v != "" {
@@ -56,7 +194,10 @@ func envOrDefault(def string, keys ...string) string {
 	return def
 }
 
-// seedDefaults initializes the MVP with a single Ethereum vault and checkpoint.
+// seedDefaults initializes the MVP with an Ethereum vault and checkpoint,
+// plus a vault for each ERC-20 token configured by tokenVaultsFromEnv and
+// for the native asset of each other chain of chainsFromEnv. These vaults
+// start without a checkpoint; their first deposit creates one.
 func (s *Service) seedDefaults() {
 	key := s.mapKey(ChainIDEthereum, "ETH")
 	now := time.Now().Add(-2 * time.Minute)
@@ -80,29 +221,51 @@ func (s *Service) seedDefaults() {
 	proofCID := envOrDefault("bafyEthereumVaultProof", "LFS_CROSSCHAIN_PROOF_CID")
 	snapshotURL := envOrDefault("https://walrus.storage/eth/latest.json", "LFS_CROSSCHAIN_SNAPSHOT_URL")
 
-	s.params[key] = CollateralParams{
-		ChainID:              ChainIDEthereum,
-		Asset:                "ETH",
-		LTV:                  decimal.RequireFromString("0.65"),
-		MaintenanceThreshold: decimal.RequireFromString("0.72"),
-		LiquidationPenalty:   decimal.RequireFromString("0.06"),
-		OracleHaircut:        decimal.RequireFromString("0.02"),
-		StalenessHardCap:     60 * time.Minute,
-		MintRateLimit:        decimal.RequireFromString("1000"),
-		WithdrawRateLimit:    decimal.RequireFromString("1000"),
-		Active:               true,
-	}
+	s.params[key] = defaultCollateralParams(ChainIDEthereum, "ETH")
 
 	s.vaults[key] = VaultInfo{
 		ChainID:           ChainIDEthereum,
 		Asset:             "ETH",
 		VaultAddress:      vaultAddr,
+		Decimals:          nativeDecimals,
+		PriceSymbol:       "ETHUSDT",
 		DepositMemoFormat: memoFormat,
 		FeedURL:           feedURL,
 		ProofCID:          proofCID,
 		SnapshotURL:       snapshotURL,
 	}
 
+	tokens, err := tokenVaultsFromEnv()
+	if err != nil {
+		s.logger.Warnw("Bridge token vaults disabled", "error", err)
+	}
+	for _, vault := range tokens {
+		vault.DepositMemoFormat = memoFormat
+		tokenKey := s.mapKey(vault.ChainID, vault.Asset)
+		s.vaults[tokenKey] = vault
+		s.params[tokenKey] = defaultCollateralParams(vault.ChainID, vault.Asset)
+	}
+
+	chains, err := chainsFromEnv()
+	if err != nil {
+		s.logger.Warnw("Bridge chain vaults disabled", "error", err)
+	}
+	for _, chain := range chains {
+		if chain.ChainID == ChainIDEthereum {
+			continue
+		}
+		chainKey := s.mapKey(chain.ChainID, chain.NativeAsset)
+		s.vaults[chainKey] = VaultInfo{
+			ChainID:           chain.ChainID,
+			Asset:             chain.NativeAsset,
+			VaultAddress:      chain.VaultAddress,
+			Decimals:          nativeDecimals,
+			PriceSymbol:       chain.PriceSymbol,
+			DepositMemoFormat: memoFormat,
+		}
+		s.params[chainKey] = defaultCollateralParams(chain.ChainID, chain.NativeAsset)
+	}
+
 	checkpoint := &WalrusCheckpoint{
 		UpdateID:     1,
 		ChainID:      ChainIDEthereum,
@@ -112,7 +275,6 @@ func (s *Service) seedDefaults() {
 		BlockHash:    "0xmockblock",
 		TotalShares:  decimal.RequireFromString("0.5"),
 		Index:        decimal.RequireFromString("1.0001"),
-		BalancesRoot: "0xmockroot",
 		ProofType:    "zk",
 		WalrusBlobID: "bafyEthereumVaultProof",
 		Status:       CheckpointStatusVerified,
@@ -134,6 +296,22 @@ func (s *Service) seedDefaults() {
 		LastCheckpointID: checkpoint.UpdateID,
 		UpdatedAt:        now,
 	}
+	checkpoint.BalancesRoot = s.balancesRootLocked(ChainIDEthereum, "ETH", nil)
+}
+
+func defaultCollateralParams(chainID ChainID, asset string) CollateralParams {
+	return CollateralParams{
+		ChainID:              chainID,
+		Asset:                asset,
+		LTV:                  decimal.RequireFromString("0.65"),
+		MaintenanceThreshold: decimal.RequireFromString("0.72"),
+		LiquidationPenalty:   decimal.RequireFromString("0.06"),
+		OracleHaircut:        decimal.RequireFromString("0.02"),
+		StalenessHardCap:     60 * time.Minute,
+		MintRateLimit:        decimal.RequireFromString("1000"),
+		WithdrawRateLimit:    decimal.RequireFromString("1000"),
+		Active:               true,
+	}
 }
 
 func (s *Service) mapKey(chainID ChainID, asset string) string {
@@ -144,8 +322,12 @@ func (s *Service) balanceKey(owner string, chainID ChainID, asset string) string
 	return fmt.Sprintf("%s:%s:%s", owner, chainID, asset)
 }
 
+func (s *Service) depositKey(chainID ChainID, txHash string, logIndex uint64) string {
+	return fmt.Sprintf("%s:%s:%d", chainID, txHash, logIndex)
+}
+
 // CreditDeposit mints shares for a Sui owner based on an observed external deposit.
-func (s *Service) CreditDeposit(_ context.Context, suiOwner string, chainID ChainID, asset string, shares decimal.Decimal) (*CrossChainBalance, error) {
+func (s *Service) CreditDeposit(ctx context.Context, suiOwner string, chainID ChainID, asset string, shares decimal.Decimal) (*CrossChainBalance, error) {
 	if suiOwner == "" || shares.LessThanOrEqual(decimal.Zero) {
 		return nil, ErrInvalidRequest
 	}
@@ -167,22 +349,28 @@ func (s *Service) CreditDeposit(_ context.Context, suiOwner string, chainID Chai
 			ChainID:  chainID,
 			Asset:    asset,
 		}
-		s.balances[key] = bal
 	}
 
-	bal.Shares = bal.Shares.Add(shares)
-	bal.Index = idx
-	bal.Value = bal.Shares.Mul(idx)
+	next := *bal
+	next.Shares = next.Shares.Add(shares)
+	next.Index = idx
+	next.Value = next.Shares.Mul(idx)
 	if cp := s.latestCheckpointLocked(chainID, asset); cp != nil {
-		bal.LastCheckpointID = cp.UpdateID
+		next.LastCheckpointID = cp.UpdateID
+	}
+	next.UpdatedAt = time.Now()
+
+	if err := s.saveBalance(ctx, &next); err != nil {
+		return nil, err
 	}
-	bal.UpdatedAt = time.Now()
+	*bal = next
+	s.balances[key] = bal
 
 	return bal, nil
 }
 
 // DebitWithdrawal burns shares for a Sui owner when a redeem is fulfilled.
-func (s *Service) DebitWithdrawal(_ context.Context, suiOwner string, chainID ChainID, asset string, shares decimal.Decimal) (*CrossChainBalance, error) {
+func (s *Service) DebitWithdrawal(ctx context.Context, suiOwner string, chainID ChainID, asset string, shares decimal.Decimal) (*CrossChainBalance, error) {
 	if suiOwner == "" || shares.LessThanOrEqual(decimal.Zero) {
 		return nil, ErrInvalidRequest
 	}
@@ -201,17 +389,31 @@ func (s *Service) DebitWithdrawal(_ context.Context, suiOwner string, chainID Ch
 		return nil, ErrInvalidRequest
 	}
 
-	bal.Shares = bal.Shares.Sub(shares)
-	bal.Index = idx
-	bal.Value = bal.Shares.Mul(idx)
+	next := *bal
+	next.Shares = next.Shares.Sub(shares)
+	next.Index = idx
+	next.Value = next.Shares.Mul(idx)
 	if cp := s.latestCheckpointLocked(chainID, asset); cp != nil {
-		bal.LastCheckpointID = cp.UpdateID
+		next.LastCheckpointID = cp.UpdateID
+	}
+	next.UpdatedAt = time.Now()
+
+	if err := s.saveBalance(ctx, &next); err != nil {
+		return nil, err
 	}
-	bal.UpdatedAt = time.Now()
+	*bal = next
 
 	return bal, nil
 }
 
+// saveBalance writes bal through to the database, if any
+func (s *Service) saveBalance(ctx context.Context, bal *CrossChainBalance) error {
+	if s.store == nil {
+		return nil
+	}
+	return s.store.saveBalance(ctx, bal)
+}
+
 func (s *Service) GetLatestCheckpoint(_ context.Context, chainID ChainID, asset string) (*WalrusCheckpoint, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -224,7 +426,37 @@ func (s *Service) GetLatestCheckpoint(_ context.Context, chainID ChainID, asset
 	return cps[len(cps)-1], nil
 }
 
-func (s *Service) SubmitCheckpoint(_ context.Context, cp WalrusCheckpoint) (*WalrusCheckpoint, error) {
+// GetCheckpoint returns the checkpoint with updateID.
+func (s *Service) GetCheckpoint(_ context.Context, updateID uint64) (*WalrusCheckpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, cps := range s.checkpoints {
+		for _, cp := range cps {
+			if cp.UpdateID == updateID {
+				found := *cp
+				return &found, nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// VerifyCheckpoint reads the checkpoint with updateID back from Walrus and
+// compares it with the local copy. It fails with ErrWalrusUnavailable
+// without WithWalrusReader.
+func (s *Service) VerifyCheckpoint(ctx context.Context, updateID uint64) (*CheckpointVerification, error) {
+	cp, err := s.GetCheckpoint(ctx, updateID)
+	if err != nil {
+		return nil, err
+	}
+	if s.walrus == nil {
+		return nil, fmt.Errorf("%w: no aggregator configured", ErrWalrusUnavailable)
+	}
+	return s.walrus.VerifyCheckpoint(ctx, *cp)
+}
+
+func (s *Service) SubmitCheckpoint(ctx context.Context, cp WalrusCheckpoint) (*WalrusCheckpoint, error) {
 	if cp.ChainID == "" || cp.Asset == "" {
 		return nil, ErrInvalidRequest
 	}
@@ -232,31 +464,192 @@ func (s *Service) SubmitCheckpoint(_ context.Context, cp WalrusCheckpoint) (*Wal
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.updateCounter++
-	cp.UpdateID = s.updateCounter
+	return s.submitCheckpointLocked(ctx, cp)
+}
+
+// submitCheckpointLocked records cp as the latest checkpoint of its chain
+// and asset and revalues their balances at its index
+func (s *Service) submitCheckpointLocked(ctx context.Context, cp WalrusCheckpoint) (*WalrusCheckpoint, error) {
+	cp.UpdateID = s.updateCounter + 1
 	if cp.Timestamp.IsZero() {
 		cp.Timestamp = time.Now()
 	}
-	if cp.Status == "" {
-		cp.Status = CheckpointStatusVerified
+	if cp.Status == "" || s.attestation != nil {
+		cp.Status = s.initialCheckpointStatus()
 	}
 
-	key := s.mapKey(cp.ChainID, cp.Asset)
-	s.checkpoints[key] = append(s.checkpoints[key], &cp)
-
 	// Bump balances to new index for the given asset.
+	bumped := make(map[*CrossChainBalance]*CrossChainBalance)
 	for _, bal := range s.balances {
 		if bal.ChainID == cp.ChainID && bal.Asset == cp.Asset {
-			bal.Index = cp.Index
-			bal.Value = bal.Shares.Mul(cp.Index)
-			bal.LastCheckpointID = cp.UpdateID
-			bal.UpdatedAt = cp.Timestamp
+			next := *bal
+			next.Index = cp.Index
+			next.Value = next.Shares.Mul(cp.Index)
+			next.LastCheckpointID = cp.UpdateID
+			next.UpdatedAt = cp.Timestamp
+			bumped[bal] = &next
+		}
+	}
+
+	if s.store != nil {
+		balances := make([]*CrossChainBalance, 0, len(bumped))
+		for _, next := range bumped {
+			balances = append(balances, next)
+		}
+		if err := s.store.saveState(ctx, []*WalrusCheckpoint{&cp}, balances); err != nil {
+			return nil, err
 		}
 	}
 
+	s.updateCounter = cp.UpdateID
+	key := s.mapKey(cp.ChainID, cp.Asset)
+	s.checkpoints[key] = append(s.checkpoints[key], &cp)
+	for bal, next := range bumped {
+		*bal = *next
+	}
+
 	return &cp, nil
 }
 
+// NextReceiptID returns a new receipt ID with the given prefix, e.g.
+// "bridge". Numbers are shared across prefixes and continue after those
+// restored by Load.
+func (s *Service) NextReceiptID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.receiptCounter++
+	return fmt.Sprintf("%s_%d", prefix, s.receiptCounter)
+}
+
+// ClaimDeposit reserves the deposit of receipt, identified by its chain,
+// transaction hash and log index, and records receipt as pending. It
+// reports whether the deposit was claimed; if not, it returns the receipt
+// of the deposit's earlier, minted submission. A deposit another
+// submission is processing fails with ErrDepositInProgress. Failed and
+// reverted deposits are claimed again under their original receipt ID, and
+// deposits without a transaction hash are always claimed. A reclaimed
+// reverted deposit keeps the Sui mint of its first submission in Minted
+// and SuiTxDigests.
+func (s *Service) ClaimDeposit(ctx context.Context, receipt *BridgeReceipt) (*BridgeReceipt, bool, error) {
+	// Hex hashes are compared case-insensitively
+	receipt.TxHash = strings.ToLower(strings.TrimSpace(receipt.TxHash))
+	receipt.Status = DepositStatusPending
+	if receipt.TxHash == "" {
+		return receipt, true, nil
+	}
+	if s.store != nil {
+		return s.store.claimDeposit(ctx, receipt)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.depositKey(receipt.ChainID, receipt.TxHash, receipt.LogIndex)
+	if existing, ok := s.deposits[key]; ok {
+		switch existing.Status {
+		case DepositStatusMinted:
+			return existing, false, nil
+		case DepositStatusPending:
+			return nil, false, ErrDepositInProgress
+		case DepositStatusReverted:
+			reclaimReverted(receipt, existing)
+		}
+		receipt.ReceiptID = existing.ReceiptID
+		receipt.Stage = existing.Stage
+	}
+	claimed := *receipt
+	s.deposits[key] = &claimed
+	return receipt, true, nil
+}
+
+// CompleteDeposit records a claimed deposit as minted
+func (s *Service) CompleteDeposit(ctx context.Context, receipt *BridgeReceipt) error {
+	return s.finishDeposit(ctx, receipt, DepositStatusMinted)
+}
+
+// ReleaseDeposit records a claimed deposit as failed, so that it may be
+// submitted again
+func (s *Service) ReleaseDeposit(ctx context.Context, receipt *BridgeReceipt) error {
+	return s.finishDeposit(ctx, receipt, DepositStatusFailed)
+}
+
+func (s *Service) finishDeposit(ctx context.Context, receipt *BridgeReceipt, status DepositStatus) error {
+	receipt.Status = status
+	if s.store != nil {
+		return s.store.finishDeposit(ctx, receipt)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.depositKey(receipt.ChainID, receipt.TxHash, receipt.LogIndex)
+	if receipt.TxHash == "" {
+		// Never claimed again, so only kept to be listed
+		key = receipt.ReceiptID
+	}
+	finished := *receipt
+	s.deposits[key] = &finished
+	return nil
+}
+
+// GetDeposits returns the receipts of the deposits in an external
+// transaction, ordered by log index. An empty chainID matches every chain.
+func (s *Service) GetDeposits(ctx context.Context, chainID ChainID, txHash string) ([]*BridgeReceipt, error) {
+	txHash = strings.ToLower(strings.TrimSpace(txHash))
+	if txHash == "" {
+		return nil, ErrInvalidRequest
+	}
+	if s.store != nil {
+		return s.store.findDeposits(ctx, chainID, txHash)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*BridgeReceipt
+	for _, receipt := range s.deposits {
+		if receipt.TxHash == txHash && (chainID == "" || receipt.ChainID == chainID) {
+			found := *receipt
+			result = append(result, &found)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChainID != result[j].ChainID {
+			return result[i].ChainID < result[j].ChainID
+		}
+		return result[i].LogIndex < result[j].LogIndex
+	})
+	return result, nil
+}
+
+// RecordRedeemReceipt records a processed redeem
+func (s *Service) RecordRedeemReceipt(ctx context.Context, receipt *RedeemReceipt) error {
+	if s.store != nil {
+		return s.store.saveRedeemReceipt(ctx, receipt)
+	}
+	s.saveRedeem(receipt)
+	return nil
+}
+
+// UpdateRedeemReceipt overwrites a recorded redeem, e.g. once its payout
+// has been retried
+func (s *Service) UpdateRedeemReceipt(ctx context.Context, receipt *RedeemReceipt) error {
+	if s.store != nil {
+		return s.store.updateRedeemReceipt(ctx, receipt)
+	}
+	s.saveRedeem(receipt)
+	return nil
+}
+
+func (s *Service) saveRedeem(receipt *RedeemReceipt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *receipt
+	s.redeems[receipt.ReceiptID] = &saved
+}
+
 func (s *Service) GetBalance(_ context.Context, suiOwner string, chainID ChainID, asset string) (*CrossChainBalance, error) {
 	if suiOwner == "" {
 		return nil, ErrInvalidRequest
@@ -292,71 +685,95 @@ func (s *Service) GetBalance(_ context.Context, suiOwner string, chainID ChainID
 	}, nil
 }
 
-func (s *Service) latestCheckpointLocked(chainID ChainID, asset string) *WalrusCheckpoint {
-	key := s.mapKey(chainID, asset)
-	cps := s.checkpoints[key]
-	if len(cps) == 0 {
-		return nil
+// BalancesRootAfter returns the balances root of chainID and asset once
+// delta shares are added to suiOwner's balance, or debited when negative,
+// so that a checkpoint commits to the balances it is submitted with.
+func (s *Service) BalancesRootAfter(_ context.Context, suiOwner string, chainID ChainID, asset string, delta decimal.Decimal) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := s.balanceKey(suiOwner, chainID, asset)
+	next := CrossChainBalance{SuiOwner: suiOwner, ChainID: chainID, Asset: asset}
+	if bal, ok := s.balances[key]; ok {
+		next = *bal
 	}
-	return cps[len(cps)-1]
+	next.Shares = next.Shares.Add(delta)
+	return s.balancesRootLocked(chainID, asset, map[string]*CrossChainBalance{key: &next})
+}
+
+// balancesRootLocked returns the balances root of chainID and asset, with
+// the balances in overrides, by balance key, replacing the current ones
+func (s *Service) balancesRootLocked(chainID ChainID, asset string, overrides map[string]*CrossChainBalance) string {
+	balances := make([]*CrossChainBalance, 0, len(s.balances)+len(overrides))
+	for key, bal := range s.balances {
+		if _, ok := overrides[key]; !ok {
+			balances = append(balances, bal)
+		}
+	}
+	for _, bal := range overrides {
+		balances = append(balances, bal)
+	}
+	return ComputeBalancesRoot(balances, chainID, asset)
 }
 
-func (s *Service) CreateVoucher(_ context.Context, voucher WithdrawalVoucher) (*WithdrawalVoucher, error) {
-	if voucher.SuiOwner == "" || voucher.Shares.LessThanOrEqual(decimal.Zero) {
+// GetBalanceProof returns the Merkle inclusion proof of suiOwner's balance
+// against the current balances. ErrNotFound is returned for owners
+// without shares.
+func (s *Service) GetBalanceProof(_ context.Context, suiOwner string, chainID ChainID, asset string) (*BalanceProof, error) {
+	if suiOwner == "" || chainID == "" || asset == "" {
 		return nil, ErrInvalidRequest
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	s.nonceCounter++
-	voucher.Nonce = s.nonceCounter
-	if voucher.VoucherID == "" {
-		voucher.VoucherID = fmt.Sprintf("voucher_%d", voucher.Nonce)
+	balances := make([]*CrossChainBalance, 0, len(s.balances))
+	for _, bal := range s.balances {
+		balances = append(balances, bal)
 	}
-	if voucher.Status == "" {
-		voucher.Status = VoucherStatusPending
+	proof, err := BuildBalanceProof(balances, suiOwner, chainID, asset)
+	if err != nil {
+		return nil, err
 	}
-	if voucher.CreatedAt.IsZero() {
-		voucher.CreatedAt = time.Now()
+	if cp := s.latestCheckpointLocked(chainID, asset); cp != nil {
+		proof.UpdateID = cp.UpdateID
 	}
-
-	s.vouchers[voucher.VoucherID] = &voucher
-	return &voucher, nil
+	return proof, nil
 }
 
-func (s *Service) ListVouchers(_ context.Context, suiOwner string) ([]*WithdrawalVoucher, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var result []*WithdrawalVoucher
-	for _, v := range s.vouchers {
-		if v.SuiOwner == suiOwner {
-			result = append(result, v)
-		}
+func (s *Service) latestCheckpointLocked(chainID ChainID, asset string) *WalrusCheckpoint {
+	key := s.mapKey(chainID, asset)
+	cps := s.checkpoints[key]
+	if len(cps) == 0 {
+		return nil
 	}
-	return result, nil
+	return cps[len(cps)-1]
 }
 
-func (s *Service) GetVoucher(_ context.Context, voucherID string) (*WithdrawalVoucher, error) {
+func (s *Service) GetCollateralParams(_ context.Context, chainID ChainID, asset string) (*CollateralParams, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if v, ok := s.vouchers[voucherID]; ok {
-		return v, nil
+	key := s.mapKey(chainID, asset)
+	if params, ok := s.params[key]; ok {
+		return &params, nil
 	}
 	return nil, ErrNotFound
 }
 
-func (s *Service) GetCollateralParams(_ context.Context, chainID ChainID, asset string) (*CollateralParams, error) {
+// ListVaults returns the vaults of chainID ordered by asset
+func (s *Service) ListVaults(_ context.Context, chainID ChainID) []VaultInfo {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	key := s.mapKey(chainID, asset)
-	if params, ok := s.params[key]; ok {
-		return &params, nil
+	var vaults []VaultInfo
+	for _, vault := range s.vaults {
+		if vault.ChainID == chainID {
+			vaults = append(vaults, vault)
+		}
 	}
-	return nil, ErrNotFound
+	sort.Slice(vaults, func(i, j int) bool { return vaults[i].Asset < vaults[j].Asset })
+	return vaults
 }
 
 func (s *Service) GetVault(_ context.Context, chainID ChainID, asset string) (*VaultInfo, error) {
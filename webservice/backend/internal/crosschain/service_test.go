@@ -0,0 +1,360 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// newTestService returns a Service configured with opts over a migrated
+// in-memory database, disconnected when the test ends. The database is
+// returned too, for tests that restart the service on it.
+func newTestService(t *testing.T, opts ...ServiceOption) (*Service, interfaces.Database) {
+	t.Helper()
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	if err := db.ConnectAndMigrate(ctx, database, db.AllSchemas()); err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	t.Cleanup(func() { database.Disconnect(ctx) })
+	return NewService(zap.NewNop().Sugar(), append(opts, WithDatabase(database))...), database
+}
+
+func TestServiceStateSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	svc, database := newTestService(t)
+	if err := svc.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, err := svc.CreditDeposit(ctx, "0xalice", ChainIDEthereum, "ETH", decimal.RequireFromString("2")); err != nil {
+		t.Fatalf("CreditDeposit failed: %v", err)
+	}
+	if _, err := svc.DebitWithdrawal(ctx, "0xalice", ChainIDEthereum, "ETH", decimal.RequireFromString("0.5")); err != nil {
+		t.Fatalf("DebitWithdrawal failed: %v", err)
+	}
+	cp, err := svc.SubmitCheckpoint(ctx, WalrusCheckpoint{
+		ChainID:      ChainIDEthereum,
+		Asset:        "ETH",
+		TotalShares:  decimal.RequireFromString("2"),
+		Index:        decimal.RequireFromString("1.5"),
+		BalancesRoot: "0xroot",
+		ProofBlob:    []byte{0x01, 0x02},
+	})
+	if err != nil {
+		t.Fatalf("SubmitCheckpoint failed: %v", err)
+	}
+	deposit := &BridgeReceipt{
+		ReceiptID: svc.NextReceiptID("bridge"),
+		SuiOwner:  "0xalice",
+		ChainID:   ChainIDEthereum,
+		Asset:     "ETH",
+		Minted:    "f=1,x=1",
+		CreatedAt: time.Now(),
+	}
+	if err := svc.CompleteDeposit(ctx, deposit); err != nil {
+		t.Fatalf("CompleteDeposit failed: %v", err)
+	}
+	// Deposits without a transaction hash do not collide
+	second := *deposit
+	second.ReceiptID = svc.NextReceiptID("bridge")
+	if err := svc.CompleteDeposit(ctx, &second); err != nil {
+		t.Fatalf("CompleteDeposit without tx hash failed: %v", err)
+	}
+	if err := svc.RecordRedeemReceipt(ctx, &RedeemReceipt{
+		ReceiptID:      svc.NextReceiptID("redeem"),
+		SuiOwner:       "0xalice",
+		EthRecipient:   "0xrecipient",
+		ChainID:        ChainIDEthereum,
+		Asset:          "ETH",
+		Token:          "x",
+		Burned:         "0.5",
+		PayoutEth:      "0.5",
+		WalrusUpdateID: cp.UpdateID,
+		CreatedAt:      time.Now(),
+	}); err != nil {
+		t.Fatalf("RecordRedeemReceipt failed: %v", err)
+	}
+
+	restarted := NewService(logger, WithDatabase(database))
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load after restart failed: %v", err)
+	}
+
+	latest, err := restarted.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+	if err != nil {
+		t.Fatalf("GetLatestCheckpoint failed: %v", err)
+	}
+	if latest.UpdateID != cp.UpdateID || !latest.Index.Equal(cp.Index) || string(latest.ProofBlob) != string(cp.ProofBlob) {
+		t.Errorf("Expected checkpoint %+v, got %+v", cp, latest)
+	}
+
+	bal, err := restarted.GetBalance(ctx, "0xalice", ChainIDEthereum, "ETH")
+	if err != nil {
+		t.Fatalf("GetBalance failed: %v", err)
+	}
+	if !bal.Shares.Equal(decimal.RequireFromString("1.5")) || !bal.Value.Equal(decimal.RequireFromString("2.25")) || bal.LastCheckpointID != cp.UpdateID {
+		t.Errorf("Unexpected balance after restart: %+v", bal)
+	}
+	seeded, err := restarted.GetBalance(ctx, "0x123", ChainIDEthereum, "ETH")
+	if err != nil || !seeded.Shares.Equal(decimal.RequireFromString("0.5")) {
+		t.Errorf("Expected the seeded balance to be kept, got %+v (%v)", seeded, err)
+	}
+
+	// Counters continue where the previous run stopped
+	next, err := restarted.SubmitCheckpoint(ctx, WalrusCheckpoint{ChainID: ChainIDEthereum, Asset: "ETH", Index: decimal.RequireFromString("1.6")})
+	if err != nil {
+		t.Fatalf("SubmitCheckpoint after restart failed: %v", err)
+	}
+	if next.UpdateID != cp.UpdateID+1 {
+		t.Errorf("Expected update ID %d, got %d", cp.UpdateID+1, next.UpdateID)
+	}
+	if id := restarted.NextReceiptID("bridge"); id != "bridge_4" {
+		t.Errorf("Expected receipt ID bridge_4, got %s", id)
+	}
+
+	if count, err := database.Repository(entities.BridgeReceiptSchema).Count(ctx, nil); err != nil || count != 2 {
+		t.Errorf("Expected 2 bridge receipts, got %d (%v)", count, err)
+	}
+}
+
+func TestClaimDepositDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, _ := newTestService(t)
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			deposit := func(logIndex uint64) *BridgeReceipt {
+				return &BridgeReceipt{
+					ReceiptID: svc.NextReceiptID("bridge"),
+					TxHash:    "0xABC",
+					LogIndex:  logIndex,
+					SuiOwner:  "0xalice",
+					ChainID:   ChainIDEthereum,
+					Asset:     "ETH",
+					CreatedAt: time.Now(),
+				}
+			}
+
+			first, claimed, err := svc.ClaimDeposit(ctx, deposit(0))
+			if err != nil || !claimed {
+				t.Fatalf("Expected the first submission to be claimed, got %v (%v)", claimed, err)
+			}
+			if _, _, err := svc.ClaimDeposit(ctx, deposit(0)); !errors.Is(err, ErrDepositInProgress) {
+				t.Fatalf("Expected ErrDepositInProgress while processing, got %v", err)
+			}
+			// Another event of the same transaction is a separate deposit
+			if _, claimed, err := svc.ClaimDeposit(ctx, deposit(1)); err != nil || !claimed {
+				t.Fatalf("Expected another log index to be claimed, got %v (%v)", claimed, err)
+			}
+
+			// A failed deposit is claimed again under its receipt ID
+			if err := svc.ReleaseDeposit(ctx, first); err != nil {
+				t.Fatalf("ReleaseDeposit failed: %v", err)
+			}
+			retry, claimed, err := svc.ClaimDeposit(ctx, deposit(0))
+			if err != nil || !claimed || retry.ReceiptID != first.ReceiptID {
+				t.Fatalf("Expected %s to be reclaimed, got %+v, %v (%v)", first.ReceiptID, retry, claimed, err)
+			}
+			retry.Minted = "f=1,x=1"
+			if err := svc.CompleteDeposit(ctx, retry); err != nil {
+				t.Fatalf("CompleteDeposit failed: %v", err)
+			}
+
+			// Replays return the original receipt, whatever the hash's case
+			replay := deposit(0)
+			replay.TxHash = "0xabc"
+			original, claimed, err := svc.ClaimDeposit(ctx, replay)
+			if err != nil || claimed {
+				t.Fatalf("Expected the replay not to be claimed, got %v (%v)", claimed, err)
+			}
+			if original.ReceiptID != first.ReceiptID || original.Status != DepositStatusMinted || original.Minted != "f=1,x=1" {
+				t.Errorf("Expected the original receipt, got %+v", original)
+			}
+
+			receipts, err := svc.GetDeposits(ctx, "", "0xAbC")
+			if err != nil {
+				t.Fatalf("GetDeposits failed: %v", err)
+			}
+			if len(receipts) != 2 || receipts[0].LogIndex != 0 || receipts[1].LogIndex != 1 || receipts[1].Status != DepositStatusPending {
+				t.Errorf("Unexpected receipts: %+v", receipts)
+			}
+		})
+	}
+}
+
+func TestRevertDepositsRollsBackReorgedBlocks(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	persisted, database := newTestService(t)
+
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			base, err := svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+			if err != nil {
+				t.Fatalf("GetLatestCheckpoint failed: %v", err)
+			}
+
+			// mint processes a deposit the way the bridge worker does
+			mint := func(txHash, owner string, block uint64, shares string) *BridgeReceipt {
+				receipt, claimed, err := svc.ClaimDeposit(ctx, &BridgeReceipt{
+					ReceiptID:   svc.NextReceiptID("bridge"),
+					TxHash:      txHash,
+					BlockNumber: block,
+					BlockHash:   "0xblock",
+					SuiOwner:    owner,
+					ChainID:     ChainIDEthereum,
+					Asset:       "ETH",
+					CreatedAt:   time.Now(),
+				})
+				if err != nil || !claimed {
+					t.Fatalf("ClaimDeposit failed: %v (%v)", claimed, err)
+				}
+				latest, _ := svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+				amount := decimal.RequireFromString(shares)
+				cp, err := svc.SubmitCheckpoint(ctx, WalrusCheckpoint{
+					ChainID:     ChainIDEthereum,
+					Asset:       "ETH",
+					TotalShares: latest.TotalShares.Add(amount),
+					Index:       decimal.RequireFromString("1"),
+				})
+				if err != nil {
+					t.Fatalf("SubmitCheckpoint failed: %v", err)
+				}
+				if _, err := svc.CreditDeposit(ctx, owner, ChainIDEthereum, "ETH", amount); err != nil {
+					t.Fatalf("CreditDeposit failed: %v", err)
+				}
+				receipt.Minted = "f=1,x=1"
+				receipt.Shares = amount
+				receipt.WalrusUpdateID = cp.UpdateID
+				if err := svc.CompleteDeposit(ctx, receipt); err != nil {
+					t.Fatalf("CompleteDeposit failed: %v", err)
+				}
+				return receipt
+			}
+
+			kept := mint("0xkept", "0xalice", 10, "2")
+			reorged := mint("0xreorged", "0xbob", 20, "3")
+
+			rollback, err := svc.RevertDeposits(ctx, ReorgEvent{ChainID: ChainIDEthereum, FromBlock: 15})
+			if err != nil {
+				t.Fatalf("RevertDeposits failed: %v", err)
+			}
+			if len(rollback.Reverted) != 1 || rollback.Reverted[0].ReceiptID != reorged.ReceiptID {
+				t.Fatalf("Expected only %s to be reverted, got %+v", reorged.ReceiptID, rollback.Reverted)
+			}
+			if len(rollback.Rejected) != 1 || rollback.Rejected[0] != reorged.WalrusUpdateID {
+				t.Errorf("Expected checkpoint %d to be rejected, got %v", reorged.WalrusUpdateID, rollback.Rejected)
+			}
+
+			latest, err := svc.GetLatestCheckpoint(ctx, ChainIDEthereum, "ETH")
+			if err != nil {
+				t.Fatalf("GetLatestCheckpoint failed: %v", err)
+			}
+			if latest.UpdateID <= reorged.WalrusUpdateID || latest.Status != CheckpointStatusVerified || !latest.TotalShares.Equal(base.TotalShares.Add(kept.Shares)) {
+				t.Errorf("Expected a replacement checkpoint without the reverted shares, got %+v", latest)
+			}
+			if bal, _ := svc.GetBalance(ctx, "0xbob", ChainIDEthereum, "ETH"); !bal.Shares.IsZero() || bal.LastCheckpointID != latest.UpdateID {
+				t.Errorf("Expected the reverted shares to be debited, got %+v", bal)
+			}
+			if bal, _ := svc.GetBalance(ctx, "0xalice", ChainIDEthereum, "ETH"); !bal.Shares.Equal(kept.Shares) {
+				t.Errorf("Expected the kept deposit to stay credited, got %+v", bal)
+			}
+			receipts, err := svc.GetDeposits(ctx, ChainIDEthereum, "0xreorged")
+			if err != nil || len(receipts) != 1 || receipts[0].Status != DepositStatusReverted {
+				t.Fatalf("Expected the receipt to be marked reverted, got %+v (%v)", receipts, err)
+			}
+
+			// Rolling back the same blocks again changes nothing
+			if again, err := svc.RevertDeposits(ctx, ReorgEvent{ChainID: ChainIDEthereum, FromBlock: 15}); err != nil || len(again.Reverted) != 0 {
+				t.Errorf("Expected nothing left to revert, got %+v (%v)", again, err)
+			}
+
+			// Included again, the deposit is reclaimed with its earlier mint
+			again, claimed, err := svc.ClaimDeposit(ctx, &BridgeReceipt{
+				ReceiptID:   svc.NextReceiptID("bridge"),
+				TxHash:      "0xreorged",
+				BlockNumber: 22,
+				SuiOwner:    "0xbob",
+				ChainID:     ChainIDEthereum,
+				Asset:       "ETH",
+				CreatedAt:   time.Now(),
+			})
+			if err != nil || !claimed {
+				t.Fatalf("Expected the reverted deposit to be reclaimed, got %v (%v)", claimed, err)
+			}
+			if again.ReceiptID != reorged.ReceiptID || again.Minted != reorged.Minted || !again.Shares.Equal(reorged.Shares) {
+				t.Errorf("Expected the earlier mint to be kept, got %+v", again)
+			}
+		})
+	}
+
+	// The rejected checkpoint survives a restart
+	restarted := NewService(logger, WithDatabase(database))
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load after restart failed: %v", err)
+	}
+	var rejected int
+	for _, cp := range restarted.checkpoints[restarted.mapKey(ChainIDEthereum, "ETH")] {
+		if cp.Status == CheckpointStatusRejected {
+			rejected++
+		}
+	}
+	if rejected != 1 {
+		t.Errorf("Expected one rejected checkpoint after restart, got %d", rejected)
+	}
+}
+
+func TestServiceSeedsTokenVaults(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("LFS_BRIDGE_TOKENS", "usdc")
+	t.Setenv("LFS_BRIDGE_USDC_TOKEN_ADDRESS", "0xusdc")
+	t.Setenv("LFS_BRIDGE_USDC_VAULT_ADDRESS", "0xusdcvault")
+	t.Setenv("LFS_BRIDGE_USDC_DECIMALS", "6")
+	t.Setenv("LFS_BRIDGE_USDC_PRICE_SYMBOL", "USDCUSDT")
+
+	svc := NewService(zap.NewNop().Sugar())
+	vault, err := svc.GetVault(ctx, ChainIDEthereum, "USDC")
+	if err != nil {
+		t.Fatalf("GetVault failed: %v", err)
+	}
+	if vault.TokenAddress != "0xusdc" || vault.VaultAddress != "0xusdcvault" || vault.Decimals != 6 || vault.PriceSymbol != "USDCUSDT" {
+		t.Errorf("Unexpected USDC vault %+v", vault)
+	}
+	if _, err := svc.GetCollateralParams(ctx, ChainIDEthereum, "USDC"); err != nil {
+		t.Errorf("Expected collateral params for USDC: %v", err)
+	}
+	if vaults := svc.ListVaults(ctx, ChainIDEthereum); len(vaults) != 2 || vaults[0].Asset != "ETH" || vaults[1].Asset != "USDC" {
+		t.Errorf("Expected the ETH and USDC vaults, got %+v", vaults)
+	}
+
+	amount := toBaseUnits(decimal.RequireFromString("2.5000019"), vault.Decimals)
+	if amount.Int64() != 2_500_001 {
+		t.Errorf("Expected 2500001 base units, got %s", amount)
+	}
+	if back := fromBaseUnits(amount, vault.Decimals); !back.Equal(decimal.RequireFromString("2.500001")) {
+		t.Errorf("Expected 2.500001 USDC, got %s", back)
+	}
+
+	t.Setenv("LFS_BRIDGE_USDC_DECIMALS", "six")
+	if _, err := tokenVaultsFromEnv(); err == nil {
+		t.Error("Expected invalid decimals to be rejected")
+	}
+}
@@ -0,0 +1,1193 @@
+package crosschain
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	gdb "github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/shopspring/decimal"
+)
+
+// receiptScanWindow is how many of the newest receipts of each kind are
+// read to restore the receipt counter. Receipts are created concurrently,
+// so the newest one does not always carry the highest number.
+const receiptScanWindow = 100
+
+// store persists checkpoints, balances and receipts through the db
+// abstraction, as described by the crosschain entity schemas
+type store struct {
+	db          interfaces.Database
+	checkpoints *gdb.TypedRepository[entities.WalrusCheckpoint]
+	balances    *gdb.TypedRepository[entities.CrossChainBalance]
+	redeems     *gdb.TypedRepository[entities.RedeemReceipt]
+	retries     *gdb.TypedRepository[entities.BridgeRetry]
+	refunds     *gdb.TypedRepository[entities.BridgeRefund]
+	vouchers    *gdb.TypedRepository[entities.WithdrawalVoucher]
+	controls    *gdb.TypedRepository[entities.BridgeControl]
+	attests     *gdb.TypedRepository[entities.CheckpointAttestation]
+	transitions *gdb.TypedRepository[entities.ReceiptTransition]
+
+	// Bridge receipts are written untyped so that deposits without a
+	// transaction hash store NULL rather than colliding on ""
+	bridge interfaces.Repository
+}
+
+func newStore(database interfaces.Database) *store {
+	return &store{
+		db:          database,
+		checkpoints: gdb.MustNewTypedRepository[entities.WalrusCheckpoint](database, entities.WalrusCheckpointSchema),
+		balances:    gdb.MustNewTypedRepository[entities.CrossChainBalance](database, entities.CrossChainBalanceSchema),
+		redeems:     gdb.MustNewTypedRepository[entities.RedeemReceipt](database, entities.RedeemReceiptSchema),
+		retries:     gdb.MustNewTypedRepository[entities.BridgeRetry](database, entities.BridgeRetrySchema),
+		refunds:     gdb.MustNewTypedRepository[entities.BridgeRefund](database, entities.BridgeRefundSchema),
+		vouchers:    gdb.MustNewTypedRepository[entities.WithdrawalVoucher](database, entities.WithdrawalVoucherSchema),
+		controls:    gdb.MustNewTypedRepository[entities.BridgeControl](database, entities.BridgeControlSchema),
+		attests:     gdb.MustNewTypedRepository[entities.CheckpointAttestation](database, entities.CheckpointAttestationSchema),
+		transitions: gdb.MustNewTypedRepository[entities.ReceiptTransition](database, entities.ReceiptTransitionSchema),
+		bridge:      database.Repository(entities.BridgeReceiptSchema),
+	}
+}
+
+// loadCheckpoints returns every checkpoint in update order
+func (st *store) loadCheckpoints(ctx context.Context) ([]*WalrusCheckpoint, error) {
+	page, err := st.checkpoints.FindMany(ctx, &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{{Field: "update_id", Direction: "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load checkpoints: %w", err)
+	}
+
+	checkpoints := make([]*WalrusCheckpoint, 0, len(page.Data))
+	for i := range page.Data {
+		cp, err := checkpointFromEntity(&page.Data[i])
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, nil
+}
+
+// loadBalances returns every balance
+func (st *store) loadBalances(ctx context.Context) ([]*CrossChainBalance, error) {
+	page, err := st.balances.FindMany(ctx, &interfaces.Query{})
+	if err != nil {
+		return nil, fmt.Errorf("load balances: %w", err)
+	}
+
+	balances := make([]*CrossChainBalance, 0, len(page.Data))
+	for i := range page.Data {
+		bal, err := balanceFromEntity(&page.Data[i])
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, bal)
+	}
+	return balances, nil
+}
+
+// lastReceiptNumber returns the highest number of the bridge and redeem
+// receipt IDs issued by NextReceiptID, or 0 if there are none
+func (st *store) lastReceiptNumber(ctx context.Context) (uint64, error) {
+	limit := receiptScanWindow
+	q := &interfaces.Query{
+		Select:      []string{"id"},
+		OrderBy:     []interfaces.OrderBy{{Field: "created_at", Direction: "desc"}},
+		Limit:       &limit,
+		WithDeleted: true,
+	}
+
+	var last uint64
+	for _, repo := range []interfaces.Repository{st.bridge, st.redeems.Untyped()} {
+		page, err := repo.FindMany(ctx, q)
+		if err != nil {
+			return 0, fmt.Errorf("load receipts: %w", err)
+		}
+		for _, record := range page.Data {
+			id, _ := record["id"].(string)
+			_, number, found := strings.Cut(id, "_")
+			if !found {
+				continue
+			}
+			if n, err := strconv.ParseUint(number, 10, 64); err == nil && n > last {
+				last = n
+			}
+		}
+	}
+	return last, nil
+}
+
+// saveState writes checkpoints and balances in one transaction
+func (st *store) saveState(ctx context.Context, checkpoints []*WalrusCheckpoint, balances []*CrossChainBalance) error {
+	return st.db.Transaction(ctx, func(ctx context.Context, _ interfaces.Transaction) error {
+		for _, cp := range checkpoints {
+			if _, err := st.checkpoints.Create(ctx, checkpointToEntity(cp)); err != nil {
+				return fmt.Errorf("save checkpoint %d: %w", cp.UpdateID, err)
+			}
+		}
+		for _, bal := range balances {
+			if err := st.saveBalance(ctx, bal); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveBalance creates or updates bal
+func (st *store) saveBalance(ctx context.Context, bal *CrossChainBalance) error {
+	entity := balanceToEntity(bal)
+	_, err := st.balances.Update(ctx, entity)
+	if errors.Is(err, interfaces.ErrNotFound) {
+		_, err = st.balances.Create(ctx, entity)
+	}
+	if err != nil {
+		return fmt.Errorf("save balance %s: %w", entity.ID, err)
+	}
+	return nil
+}
+
+// claimDeposit creates receipt as a pending deposit, or reclaims the
+// failed deposit it repeats. See Service.ClaimDeposit.
+func (st *store) claimDeposit(ctx context.Context, receipt *BridgeReceipt) (*BridgeReceipt, bool, error) {
+	_, err := st.bridge.Create(ctx, bridgeReceiptRecord(receipt))
+	if err == nil {
+		return receipt, true, nil
+	}
+	if !errors.Is(err, interfaces.ErrUniqueConstraint) {
+		return nil, false, fmt.Errorf("claim deposit %s: %w", receipt.ReceiptID, err)
+	}
+
+	existing, err := st.bridge.FindOne(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "chain_id", Value: string(receipt.ChainID)},
+			{Field: "tx_hash", Value: receipt.TxHash},
+			{Field: "log_index", Value: int64(receipt.LogIndex)},
+		}},
+		WithDeleted: true,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("claim deposit %s: %w", receipt.ReceiptID, err)
+	}
+	switch DepositStatus(fmt.Sprint(existing["status"])) {
+	case DepositStatusMinted:
+		return bridgeReceiptFromRecord(existing), false, nil
+	case DepositStatusFailed, DepositStatusReverted:
+		previous := bridgeReceiptFromRecord(existing)
+		if previous.Status == DepositStatusReverted {
+			reclaimReverted(receipt, previous)
+		}
+		receipt.Stage = previous.Stage
+		// The version makes concurrent reclaims fail but one
+		data := bridgeReceiptRecord(receipt)
+		delete(data, "id")
+		delete(data, "created_at")
+		data["version"] = existing["version"]
+		_, err := st.bridge.Update(ctx, interfaces.StringID(fmt.Sprint(existing["id"])), data)
+		if errors.Is(err, interfaces.ErrConflict) {
+			return nil, false, ErrDepositInProgress
+		} else if err != nil {
+			return nil, false, fmt.Errorf("claim deposit %s: %w", receipt.ReceiptID, err)
+		}
+		receipt.ReceiptID = fmt.Sprint(existing["id"])
+		return receipt, true, nil
+	default:
+		return nil, false, ErrDepositInProgress
+	}
+}
+
+// finishDeposit writes the outcome of a claimed deposit. Deposits without
+// a transaction hash are not claimed, so they are created.
+func (st *store) finishDeposit(ctx context.Context, receipt *BridgeReceipt) error {
+	data := bridgeReceiptRecord(receipt)
+	var err error
+	if receipt.TxHash == "" {
+		_, err = st.bridge.Create(ctx, data)
+	} else {
+		delete(data, "id")
+		delete(data, "created_at")
+		_, err = st.bridge.Update(ctx, interfaces.StringID(receipt.ReceiptID), data)
+	}
+	if err != nil {
+		return fmt.Errorf("save bridge receipt %s: %w", receipt.ReceiptID, err)
+	}
+	return nil
+}
+
+// findDeposits returns the receipts of txHash, on chainID unless it is empty
+func (st *store) findDeposits(ctx context.Context, chainID ChainID, txHash string) ([]*BridgeReceipt, error) {
+	conditions := []interfaces.Filter{{Field: "tx_hash", Value: txHash}}
+	if chainID != "" {
+		conditions = append(conditions, interfaces.Filter{Field: "chain_id", Value: string(chainID)})
+	}
+	page, err := st.bridge.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: conditions},
+		OrderBy: []interfaces.OrderBy{
+			{Field: "chain_id", Direction: "asc"},
+			{Field: "log_index", Direction: "asc"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find deposits: %w", err)
+	}
+
+	receipts := make([]*BridgeReceipt, 0, len(page.Data))
+	for _, record := range page.Data {
+		receipts = append(receipts, bridgeReceiptFromRecord(record))
+	}
+	return receipts, nil
+}
+
+// findMintedDeposits returns the minted deposits of chainID in blocks from
+// fromBlock on
+func (st *store) findMintedDeposits(ctx context.Context, chainID ChainID, fromBlock uint64) ([]*BridgeReceipt, error) {
+	page, err := st.bridge.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "chain_id", Value: string(chainID)},
+			{Field: "status", Value: string(DepositStatusMinted)},
+			{Field: "block_number", Operator: &interfaces.FilterOperator{Gte: int64(fromBlock)}},
+		}},
+		OrderBy: []interfaces.OrderBy{{Field: "block_number", Direction: "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find deposits from block %d: %w", fromBlock, err)
+	}
+
+	receipts := make([]*BridgeReceipt, 0, len(page.Data))
+	for _, record := range page.Data {
+		receipts = append(receipts, bridgeReceiptFromRecord(record))
+	}
+	return receipts, nil
+}
+
+// saveRollback writes a reorg rollback in one transaction: the reverted
+// receipts, the reversed balances, the rejected checkpoints and the
+// checkpoints replacing them
+func (st *store) saveRollback(ctx context.Context, reverted []*BridgeReceipt, balances []*CrossChainBalance, rejected, checkpoints []*WalrusCheckpoint) error {
+	return st.db.Transaction(ctx, func(ctx context.Context, _ interfaces.Transaction) error {
+		for _, receipt := range reverted {
+			if err := st.finishDeposit(ctx, receipt); err != nil {
+				return err
+			}
+		}
+		for _, bal := range balances {
+			if err := st.saveBalance(ctx, bal); err != nil {
+				return err
+			}
+		}
+		for _, cp := range rejected {
+			if _, err := st.checkpoints.Update(ctx, checkpointToEntity(cp)); err != nil {
+				return fmt.Errorf("reject checkpoint %d: %w", cp.UpdateID, err)
+			}
+		}
+		for _, cp := range checkpoints {
+			if _, err := st.checkpoints.Create(ctx, checkpointToEntity(cp)); err != nil {
+				return fmt.Errorf("save checkpoint %d: %w", cp.UpdateID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// saveRedeemReceipt records a processed redeem
+func (st *store) saveRedeemReceipt(ctx context.Context, receipt *RedeemReceipt) error {
+	if _, err := st.redeems.Create(ctx, redeemReceiptToEntity(receipt)); err != nil {
+		return fmt.Errorf("save redeem receipt %s: %w", receipt.ReceiptID, err)
+	}
+	return nil
+}
+
+// updateRedeemReceipt overwrites a recorded redeem
+func (st *store) updateRedeemReceipt(ctx context.Context, receipt *RedeemReceipt) error {
+	if _, err := st.redeems.Update(ctx, redeemReceiptToEntity(receipt)); err != nil {
+		return fmt.Errorf("update redeem receipt %s: %w", receipt.ReceiptID, err)
+	}
+	return nil
+}
+
+// findReceipts returns the deposit and redeem receipts selected by filter
+// that follow after, up to filter.Limit+1 of each kind, newest first
+func (st *store) findReceipts(ctx context.Context, filter ReceiptFilter, after *receiptCursor) ([]*Receipt, error) {
+	limit := filter.Limit + 1
+	where := func() *interfaces.Filters {
+		f := &interfaces.Filters{}
+		if filter.SuiOwner != "" {
+			f.Conditions = append(f.Conditions, interfaces.Filter{Field: "sui_owner", Value: filter.SuiOwner})
+		}
+		if filter.ChainID != "" {
+			f.Conditions = append(f.Conditions, interfaces.Filter{Field: "chain_id", Value: string(filter.ChainID)})
+		}
+		if filter.Asset != "" {
+			f.Conditions = append(f.Conditions, interfaces.Filter{Field: "asset", Value: filter.Asset})
+		}
+		if filter.Stage != "" {
+			f.Conditions = append(f.Conditions, interfaces.Filter{Field: "stage", Value: string(filter.Stage)})
+		}
+		if !filter.From.IsZero() {
+			f.Conditions = append(f.Conditions, interfaces.Filter{Field: "created_at", Operator: &interfaces.FilterOperator{Gte: filter.From}})
+		}
+		if !filter.To.IsZero() {
+			f.Conditions = append(f.Conditions, interfaces.Filter{Field: "created_at", Operator: &interfaces.FilterOperator{Lt: filter.To}})
+		}
+		if after != nil {
+			f.OR = []*interfaces.Filters{
+				{Conditions: []interfaces.Filter{{Field: "created_at", Operator: &interfaces.FilterOperator{Lt: after.CreatedAt}}}},
+				{Conditions: []interfaces.Filter{
+					{Field: "created_at", Value: after.CreatedAt},
+					{Field: "id", Operator: &interfaces.FilterOperator{Lt: after.ID}},
+				}},
+			}
+		}
+		return f
+	}
+	order := []interfaces.OrderBy{
+		{Field: "created_at", Direction: "desc"},
+		{Field: "id", Direction: "desc"},
+	}
+
+	var receipts []*Receipt
+	if filter.Kind != ReceiptKindRedeem {
+		q := &interfaces.Query{Where: where(), OrderBy: order, Limit: &limit}
+		if filter.Status != "" {
+			q.Where.Conditions = append(q.Where.Conditions, interfaces.Filter{Field: "status", Value: filter.Status})
+		}
+		page, err := st.bridge.FindMany(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("find bridge receipts: %w", err)
+		}
+		for _, record := range page.Data {
+			receipts = append(receipts, &Receipt{Kind: ReceiptKindDeposit, Deposit: bridgeReceiptFromRecord(record)})
+		}
+	}
+
+	if filter.Kind != ReceiptKindDeposit {
+		q := &interfaces.Query{Where: where(), OrderBy: order, Limit: &limit}
+		// Redeem statuses are derived from the payout transaction
+		switch RedeemStatus(filter.Status) {
+		case "":
+		case RedeemStatusPending:
+			q.Where.AND = append(q.Where.AND, &interfaces.Filters{OR: []*interfaces.Filters{
+				{Conditions: []interfaces.Filter{{Field: "payout_tx_hash", Operator: &interfaces.FilterOperator{IsNull: true}}}},
+				{Conditions: []interfaces.Filter{{Field: "payout_tx_hash", Value: ""}}},
+			}})
+		case RedeemStatusPaid:
+			q.Where.Conditions = append(q.Where.Conditions,
+				interfaces.Filter{Field: "payout_tx_hash", Operator: &interfaces.FilterOperator{IsNotNull: true}},
+				interfaces.Filter{Field: "payout_tx_hash", Operator: &interfaces.FilterOperator{Ne: ""}},
+			)
+		default:
+			return receipts, nil
+		}
+		page, err := st.redeems.FindMany(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("find redeem receipts: %w", err)
+		}
+		for i := range page.Data {
+			receipts = append(receipts, &Receipt{Kind: ReceiptKindRedeem, Redeem: redeemReceiptFromEntity(&page.Data[i])})
+		}
+	}
+	return receipts, nil
+}
+
+// getReceipt returns the deposit or redeem receipt with id, or ErrNotFound
+func (st *store) getReceipt(ctx context.Context, id string) (*Receipt, error) {
+	record, err := st.bridge.GetByID(ctx, interfaces.StringID(id))
+	if err == nil {
+		return &Receipt{Kind: ReceiptKindDeposit, Deposit: bridgeReceiptFromRecord(record)}, nil
+	} else if !errors.Is(err, interfaces.ErrNotFound) {
+		return nil, fmt.Errorf("get receipt %s: %w", id, err)
+	}
+
+	e, err := st.redeems.GetByID(ctx, interfaces.StringID(id))
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("get receipt %s: %w", id, err)
+	}
+	return &Receipt{Kind: ReceiptKindRedeem, Redeem: redeemReceiptFromEntity(e)}, nil
+}
+
+// saveTransition records t and sets the stage of its receipt. Redeem
+// receipts are written once processed, so their stage may be written
+// with them instead.
+func (st *store) saveTransition(ctx context.Context, t *ReceiptTransition) error {
+	return st.db.Transaction(ctx, func(ctx context.Context, _ interfaces.Transaction) error {
+		if _, err := st.transitions.Create(ctx, transitionToEntity(t)); err != nil {
+			return fmt.Errorf("save transition of %s: %w", t.ReceiptID, err)
+		}
+		receipts := st.bridge
+		if t.Kind == ReceiptKindRedeem {
+			receipts = st.redeems.Untyped()
+		}
+		_, err := receipts.Update(ctx, interfaces.StringID(t.ReceiptID), map[string]interface{}{"stage": string(t.To)})
+		if err != nil && !errors.Is(err, interfaces.ErrNotFound) {
+			return fmt.Errorf("save stage of %s: %w", t.ReceiptID, err)
+		}
+		return nil
+	})
+}
+
+// findTransitions returns the transitions of receiptID, oldest first
+func (st *store) findTransitions(ctx context.Context, receiptID string) ([]*ReceiptTransition, error) {
+	page, err := st.transitions.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "receipt_id", Value: receiptID},
+		}},
+		OrderBy: []interfaces.OrderBy{{Field: "created_at", Direction: "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find transitions of %s: %w", receiptID, err)
+	}
+	transitions := make([]*ReceiptTransition, 0, len(page.Data))
+	for i := range page.Data {
+		transitions = append(transitions, transitionFromEntity(&page.Data[i]))
+	}
+	return transitions, nil
+}
+
+// findReceiptsToFinalize returns the minted deposits and paid redeems
+// counted by the checkpoint with updateID
+func (st *store) findReceiptsToFinalize(ctx context.Context, updateID uint64) ([]*Receipt, error) {
+	query := func(stage ReceiptStage) *interfaces.Query {
+		return &interfaces.Query{Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "walrus_update_id", Value: int64(updateID)},
+			{Field: "stage", Value: string(stage)},
+		}}}
+	}
+
+	deposits, err := st.bridge.FindMany(ctx, query(StageMinted))
+	if err != nil {
+		return nil, fmt.Errorf("find deposits of checkpoint %d: %w", updateID, err)
+	}
+	redeems, err := st.redeems.FindMany(ctx, query(StagePaid))
+	if err != nil {
+		return nil, fmt.Errorf("find redeems of checkpoint %d: %w", updateID, err)
+	}
+
+	receipts := make([]*Receipt, 0, len(deposits.Data)+len(redeems.Data))
+	for _, record := range deposits.Data {
+		receipts = append(receipts, &Receipt{Kind: ReceiptKindDeposit, Deposit: bridgeReceiptFromRecord(record)})
+	}
+	for i := range redeems.Data {
+		receipts = append(receipts, &Receipt{Kind: ReceiptKindRedeem, Redeem: redeemReceiptFromEntity(&redeems.Data[i])})
+	}
+	return receipts, nil
+}
+
+// createRetry queues retry; a retry with the same ID fails with
+// interfaces.ErrUniqueConstraint
+func (st *store) createRetry(ctx context.Context, retry *BridgeRetry) error {
+	if _, err := st.retries.Create(ctx, retryToEntity(retry)); err != nil {
+		return fmt.Errorf("queue retry %s: %w", retry.ID, err)
+	}
+	return nil
+}
+
+// saveRetry writes the outcome of an attempt of retry
+func (st *store) saveRetry(ctx context.Context, retry *BridgeRetry) error {
+	if _, err := st.retries.Update(ctx, retryToEntity(retry)); err != nil {
+		return fmt.Errorf("save retry %s: %w", retry.ID, err)
+	}
+	return nil
+}
+
+// getRetry returns the retry with id, or ErrNotFound
+func (st *store) getRetry(ctx context.Context, id string) (*BridgeRetry, error) {
+	e, err := st.retries.GetByID(ctx, interfaces.StringID(id))
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("get retry %s: %w", id, err)
+	}
+	return retryFromEntity(e), nil
+}
+
+// findRetries returns the retries with status, oldest first. With a
+// non-zero dueBy only those due by then are returned, by due time, up to
+// limit when it is positive.
+func (st *store) findRetries(ctx context.Context, status RetryStatus, dueBy time.Time, limit int) ([]*BridgeRetry, error) {
+	q := &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "status", Value: string(status)},
+		}},
+		OrderBy: []interfaces.OrderBy{{Field: "created_at", Direction: "asc"}},
+	}
+	if !dueBy.IsZero() {
+		q.Where.Conditions = append(q.Where.Conditions, interfaces.Filter{
+			Field: "next_attempt_at", Operator: &interfaces.FilterOperator{Lte: dueBy},
+		})
+		q.OrderBy = []interfaces.OrderBy{{Field: "next_attempt_at", Direction: "asc"}}
+	}
+	if limit > 0 {
+		q.Limit = &limit
+	}
+
+	page, err := st.retries.FindMany(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("find retries: %w", err)
+	}
+	retries := make([]*BridgeRetry, 0, len(page.Data))
+	for i := range page.Data {
+		retries = append(retries, retryFromEntity(&page.Data[i]))
+	}
+	return retries, nil
+}
+
+// createRefund records refund; a refund of the same redeem fails with
+// interfaces.ErrUniqueConstraint
+func (st *store) createRefund(ctx context.Context, refund *BridgeRefund) error {
+	if _, err := st.refunds.Create(ctx, refundToEntity(refund)); err != nil {
+		return fmt.Errorf("record refund %s: %w", refund.ID, err)
+	}
+	return nil
+}
+
+// saveRefund writes refund
+func (st *store) saveRefund(ctx context.Context, refund *BridgeRefund) error {
+	if _, err := st.refunds.Update(ctx, refundToEntity(refund)); err != nil {
+		return fmt.Errorf("save refund %s: %w", refund.ID, err)
+	}
+	return nil
+}
+
+// getRefund returns the refund with id, or ErrNotFound
+func (st *store) getRefund(ctx context.Context, id string) (*BridgeRefund, error) {
+	e, err := st.refunds.GetByID(ctx, interfaces.StringID(id))
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("get refund %s: %w", id, err)
+	}
+	return refundFromEntity(e)
+}
+
+// findRefunds returns the refunds with status, or every refund when it is
+// empty, oldest first
+func (st *store) findRefunds(ctx context.Context, status RefundStatus) ([]*BridgeRefund, error) {
+	q := &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{{Field: "created_at", Direction: "asc"}},
+	}
+	if status != "" {
+		q.Where = &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "status", Value: string(status)},
+		}}
+	}
+
+	page, err := st.refunds.FindMany(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("find refunds: %w", err)
+	}
+	refunds := make([]*BridgeRefund, 0, len(page.Data))
+	for i := range page.Data {
+		refund, err := refundFromEntity(&page.Data[i])
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, nil
+}
+
+// createVoucher records v; a voucher with its ID or nonce fails with
+// interfaces.ErrUniqueConstraint
+func (st *store) createVoucher(ctx context.Context, v *WithdrawalVoucher) error {
+	if _, err := st.vouchers.Create(ctx, voucherToEntity(v)); err != nil {
+		return fmt.Errorf("record voucher %s: %w", v.VoucherID, err)
+	}
+	return nil
+}
+
+// saveVoucher writes v
+func (st *store) saveVoucher(ctx context.Context, v *WithdrawalVoucher) error {
+	if _, err := st.vouchers.Update(ctx, voucherToEntity(v)); err != nil {
+		return fmt.Errorf("save voucher %s: %w", v.VoucherID, err)
+	}
+	return nil
+}
+
+// getVoucher returns the voucher with id, or ErrNotFound
+func (st *store) getVoucher(ctx context.Context, id string) (*WithdrawalVoucher, error) {
+	e, err := st.vouchers.GetByID(ctx, interfaces.StringID(id))
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("get voucher %s: %w", id, err)
+	}
+	return voucherFromEntity(e)
+}
+
+// findVouchers returns the vouchers of suiOwner with status in nonce
+// order; empty filters match every voucher
+func (st *store) findVouchers(ctx context.Context, suiOwner string, status VoucherStatus) ([]*WithdrawalVoucher, error) {
+	q := &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{{Field: "nonce", Direction: "asc"}},
+	}
+	var conditions []interfaces.Filter
+	if suiOwner != "" {
+		conditions = append(conditions, interfaces.Filter{Field: "sui_owner", Value: suiOwner})
+	}
+	if status != "" {
+		conditions = append(conditions, interfaces.Filter{Field: "status", Value: string(status)})
+	}
+	if len(conditions) > 0 {
+		q.Where = &interfaces.Filters{Conditions: conditions}
+	}
+
+	page, err := st.vouchers.FindMany(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("find vouchers: %w", err)
+	}
+	vouchers := make([]*WithdrawalVoucher, 0, len(page.Data))
+	for i := range page.Data {
+		v, err := voucherFromEntity(&page.Data[i])
+		if err != nil {
+			return nil, err
+		}
+		vouchers = append(vouchers, v)
+	}
+	return vouchers, nil
+}
+
+// lastVoucherNonce returns the highest nonce allocated to a voucher
+func (st *store) lastVoucherNonce(ctx context.Context) (uint64, error) {
+	limit := 1
+	page, err := st.vouchers.FindMany(ctx, &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{{Field: "nonce", Direction: "desc"}},
+		Limit:   &limit,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("load voucher nonce: %w", err)
+	}
+	if len(page.Data) == 0 {
+		return 0, nil
+	}
+	return uint64(page.Data[0].Nonce), nil
+}
+
+// loadControls returns every bridge control
+func (st *store) loadControls(ctx context.Context) ([]*BridgeControl, error) {
+	page, err := st.controls.FindMany(ctx, &interfaces.Query{})
+	if err != nil {
+		return nil, fmt.Errorf("load bridge controls: %w", err)
+	}
+
+	controls := make([]*BridgeControl, 0, len(page.Data))
+	for i := range page.Data {
+		control, err := controlFromEntity(&page.Data[i])
+		if err != nil {
+			return nil, err
+		}
+		controls = append(controls, control)
+	}
+	return controls, nil
+}
+
+func (st *store) saveControl(ctx context.Context, control *BridgeControl) error {
+	entity := controlToEntity(control)
+	_, err := st.controls.Update(ctx, entity)
+	if errors.Is(err, interfaces.ErrNotFound) {
+		_, err = st.controls.Create(ctx, entity)
+	}
+	if err != nil {
+		return fmt.Errorf("save bridge control %s: %w", entity.ID, err)
+	}
+	return nil
+}
+
+// loadAttestations returns every checkpoint attestation
+func (st *store) loadAttestations(ctx context.Context) ([]*CheckpointAttestation, error) {
+	page, err := st.attests.FindMany(ctx, &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{{Field: "created_at", Direction: "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load attestations: %w", err)
+	}
+
+	attestations := make([]*CheckpointAttestation, 0, len(page.Data))
+	for i := range page.Data {
+		e := &page.Data[i]
+		attestations = append(attestations, &CheckpointAttestation{
+			UpdateID:  uint64(e.UpdateID),
+			Signer:    e.Signer,
+			Signature: e.Signature,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	return attestations, nil
+}
+
+// saveAttestation records att and, once it completes the threshold, the
+// verified checkpoint in one transaction
+func (st *store) saveAttestation(ctx context.Context, att *CheckpointAttestation, verified *WalrusCheckpoint) error {
+	return st.db.Transaction(ctx, func(ctx context.Context, _ interfaces.Transaction) error {
+		if _, err := st.attests.Create(ctx, &entities.CheckpointAttestation{
+			ID:        fmt.Sprintf("%d:%s", att.UpdateID, att.Signer),
+			UpdateID:  int64(att.UpdateID),
+			Signer:    att.Signer,
+			Signature: att.Signature,
+			CreatedAt: att.CreatedAt,
+		}); err != nil {
+			return fmt.Errorf("save attestation of checkpoint %d: %w", att.UpdateID, err)
+		}
+		if verified != nil {
+			if _, err := st.checkpoints.Update(ctx, checkpointToEntity(verified)); err != nil {
+				return fmt.Errorf("verify checkpoint %d: %w", verified.UpdateID, err)
+			}
+		}
+		return nil
+	})
+}
+
+func redeemReceiptToEntity(receipt *RedeemReceipt) *entities.RedeemReceipt {
+	e := &entities.RedeemReceipt{
+		ID:             receipt.ReceiptID,
+		SuiTxDigest:    receipt.SuiTxDigest,
+		SuiOwner:       receipt.SuiOwner,
+		EthRecipient:   receipt.EthRecipient,
+		ChainID:        string(receipt.ChainID),
+		Asset:          receipt.Asset,
+		Token:          receipt.Token,
+		Burned:         receipt.Burned,
+		PayoutEth:      receipt.PayoutEth,
+		Fee:            receipt.Fee,
+		WalrusUpdateID: int64(receipt.WalrusUpdateID),
+		WalrusBlobID:   receipt.WalrusBlobID,
+		PayoutTxHash:   receipt.PayoutTxHash,
+		Stage:          string(receipt.Stage),
+		CreatedAt:      receipt.CreatedAt,
+	}
+	if receipt.Price != nil {
+		e.PriceSource = receipt.Price.Source
+		e.PriceSymbol = receipt.Price.Symbol
+		e.Price = receipt.Price.Price.String()
+		e.PriceAt = &receipt.Price.ObservedAt
+	}
+	return e
+}
+
+func redeemReceiptFromEntity(e *entities.RedeemReceipt) *RedeemReceipt {
+	return &RedeemReceipt{
+		ReceiptID:      e.ID,
+		SuiTxDigest:    e.SuiTxDigest,
+		SuiOwner:       e.SuiOwner,
+		EthRecipient:   e.EthRecipient,
+		ChainID:        ChainID(e.ChainID),
+		Asset:          e.Asset,
+		Token:          e.Token,
+		Burned:         e.Burned,
+		PayoutEth:      e.PayoutEth,
+		Fee:            e.Fee,
+		Price:          priceQuoteFromColumns(e.PriceSource, e.PriceSymbol, e.Price, e.PriceAt),
+		WalrusUpdateID: uint64(e.WalrusUpdateID),
+		WalrusBlobID:   e.WalrusBlobID,
+		PayoutTxHash:   e.PayoutTxHash,
+		Stage:          ReceiptStage(e.Stage),
+		CreatedAt:      e.CreatedAt,
+	}
+}
+
+func transitionToEntity(t *ReceiptTransition) *entities.ReceiptTransition {
+	return &entities.ReceiptTransition{
+		ReceiptID: t.ReceiptID,
+		Kind:      string(t.Kind),
+		FromStage: string(t.From),
+		ToStage:   string(t.To),
+		Reason:    t.Reason,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func transitionFromEntity(e *entities.ReceiptTransition) *ReceiptTransition {
+	return &ReceiptTransition{
+		ReceiptID: e.ReceiptID,
+		Kind:      ReceiptKind(e.Kind),
+		From:      ReceiptStage(e.FromStage),
+		To:        ReceiptStage(e.ToStage),
+		Reason:    e.Reason,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+func retryToEntity(retry *BridgeRetry) *entities.BridgeRetry {
+	return &entities.BridgeRetry{
+		ID:            retry.ID,
+		Kind:          string(retry.Kind),
+		ReceiptID:     retry.ReceiptID,
+		Payload:       string(retry.Payload),
+		Status:        string(retry.Status),
+		Attempts:      int64(retry.Attempts),
+		LastError:     retry.LastError,
+		NextAttemptAt: retry.NextAttemptAt,
+		CreatedAt:     retry.CreatedAt,
+	}
+}
+
+func retryFromEntity(e *entities.BridgeRetry) *BridgeRetry {
+	return &BridgeRetry{
+		ID:            e.ID,
+		Kind:          RetryKind(e.Kind),
+		ReceiptID:     e.ReceiptID,
+		Payload:       []byte(e.Payload),
+		Status:        RetryStatus(e.Status),
+		Attempts:      int(e.Attempts),
+		LastError:     e.LastError,
+		NextAttemptAt: e.NextAttemptAt,
+		CreatedAt:     e.CreatedAt,
+		UpdatedAt:     e.UpdatedAt,
+	}
+}
+
+func refundToEntity(refund *BridgeRefund) *entities.BridgeRefund {
+	return &entities.BridgeRefund{
+		ID:           refund.ID,
+		ChainID:      string(refund.ChainID),
+		Asset:        refund.Asset,
+		SuiOwner:     refund.SuiOwner,
+		Token:        refund.Token,
+		Amount:       refund.Amount.String(),
+		Fee:          refund.Fee.String(),
+		Status:       string(refund.Status),
+		Reason:       refund.Reason,
+		Credited:     refund.Credited,
+		SuiTxDigests: strings.Join(refund.SuiTxDigests, ","),
+		LastError:    refund.LastError,
+		CreatedAt:    refund.CreatedAt,
+		UpdatedAt:    refund.UpdatedAt,
+	}
+}
+
+func refundFromEntity(e *entities.BridgeRefund) (*BridgeRefund, error) {
+	amount, err := decimal.NewFromString(e.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("parse amount of refund %s: %w", e.ID, err)
+	}
+	fee := decimal.Zero
+	if e.Fee != "" {
+		if fee, err = decimal.NewFromString(e.Fee); err != nil {
+			return nil, fmt.Errorf("parse fee of refund %s: %w", e.ID, err)
+		}
+	}
+	refund := &BridgeRefund{
+		ID:        e.ID,
+		ChainID:   ChainID(e.ChainID),
+		Asset:     e.Asset,
+		SuiOwner:  e.SuiOwner,
+		Token:     e.Token,
+		Amount:    amount,
+		Fee:       fee,
+		Status:    RefundStatus(e.Status),
+		Reason:    e.Reason,
+		Credited:  e.Credited,
+		LastError: e.LastError,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+	if e.SuiTxDigests != "" {
+		refund.SuiTxDigests = strings.Split(e.SuiTxDigests, ",")
+	}
+	return refund, nil
+}
+
+func controlToEntity(control *BridgeControl) *entities.BridgeControl {
+	return &entities.BridgeControl{
+		ID:                controlKey(control.ChainID, control.Asset),
+		ChainID:           string(control.ChainID),
+		Asset:             control.Asset,
+		DepositsPaused:    control.DepositsPaused,
+		RedemptionsPaused: control.RedemptionsPaused,
+		MaxDeposit:        control.MaxDeposit.String(),
+		DailyDepositLimit: control.DailyDepositLimit.String(),
+		DailyVolume:       control.DailyVolume.String(),
+		VolumeDay:         control.VolumeDay,
+		MintFeeBps:        int64(control.MintFeeBps),
+		RedeemFeeBps:      int64(control.RedeemFeeBps),
+		MintFees:          control.MintFees.String(),
+		RedeemFees:        control.RedeemFees.String(),
+	}
+}
+
+func controlFromEntity(e *entities.BridgeControl) (*BridgeControl, error) {
+	amounts := make([]decimal.Decimal, 5)
+	for i, s := range []string{e.MaxDeposit, e.DailyDepositLimit, e.DailyVolume, e.MintFees, e.RedeemFees} {
+		if s == "" {
+			continue
+		}
+		d, err := decimal.NewFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("bridge control %s: %w", e.ID, err)
+		}
+		amounts[i] = d
+	}
+
+	return &BridgeControl{
+		ChainID:           ChainID(e.ChainID),
+		Asset:             e.Asset,
+		DepositsPaused:    e.DepositsPaused,
+		RedemptionsPaused: e.RedemptionsPaused,
+		MaxDeposit:        amounts[0],
+		DailyDepositLimit: amounts[1],
+		DailyVolume:       amounts[2],
+		VolumeDay:         e.VolumeDay,
+		MintFeeBps:        int(e.MintFeeBps),
+		RedeemFeeBps:      int(e.RedeemFeeBps),
+		MintFees:          amounts[3],
+		RedeemFees:        amounts[4],
+		UpdatedAt:         e.UpdatedAt,
+	}, nil
+}
+
+func bridgeReceiptRecord(receipt *BridgeReceipt) map[string]interface{} {
+	record := map[string]interface{}{
+		"id":               receipt.ReceiptID,
+		"log_index":        int64(receipt.LogIndex),
+		"block_number":     int64(receipt.BlockNumber),
+		"sui_owner":        receipt.SuiOwner,
+		"chain_id":         string(receipt.ChainID),
+		"asset":            receipt.Asset,
+		"minted":           receipt.Minted,
+		"shares":           receipt.Shares.String(),
+		"fee":              receipt.Fee.String(),
+		"walrus_update_id": int64(receipt.WalrusUpdateID),
+		"status":           string(receipt.Status),
+		"created_at":       receipt.CreatedAt,
+	}
+	if receipt.TxHash != "" {
+		record["tx_hash"] = receipt.TxHash
+	}
+	if receipt.BlockHash != "" {
+		record["block_hash"] = receipt.BlockHash
+	}
+	if receipt.Stage != "" {
+		record["stage"] = string(receipt.Stage)
+	}
+	if !receipt.Amount.IsZero() {
+		record["amount"] = receipt.Amount.String()
+	}
+	if receipt.Price != nil {
+		record["price_source"] = receipt.Price.Source
+		record["price_symbol"] = receipt.Price.Symbol
+		record["price"] = receipt.Price.Price.String()
+		record["price_at"] = receipt.Price.ObservedAt
+	}
+	if len(receipt.SuiTxDigests) > 0 {
+		record["metadata"] = map[string]interface{}{"sui_tx_digests": receipt.SuiTxDigests}
+	}
+	return record
+}
+
+func bridgeReceiptFromRecord(record map[string]interface{}) *BridgeReceipt {
+	receipt := &BridgeReceipt{
+		ReceiptID: fmt.Sprint(record["id"]),
+		SuiOwner:  fmt.Sprint(record["sui_owner"]),
+		ChainID:   ChainID(fmt.Sprint(record["chain_id"])),
+		Asset:     fmt.Sprint(record["asset"]),
+		Status:    DepositStatus(fmt.Sprint(record["status"])),
+	}
+	if txHash, ok := record["tx_hash"].(string); ok {
+		receipt.TxHash = txHash
+	}
+	if logIndex, ok := record["log_index"].(int64); ok {
+		receipt.LogIndex = uint64(logIndex)
+	}
+	if blockNumber, ok := record["block_number"].(int64); ok {
+		receipt.BlockNumber = uint64(blockNumber)
+	}
+	if blockHash, ok := record["block_hash"].(string); ok {
+		receipt.BlockHash = blockHash
+	}
+	if stage, ok := record["stage"].(string); ok {
+		receipt.Stage = ReceiptStage(stage)
+	}
+	if minted, ok := record["minted"].(string); ok {
+		receipt.Minted = minted
+	}
+	if shares, ok := record["shares"].(string); ok {
+		receipt.Shares, _ = decimal.NewFromString(shares)
+	}
+	if fee, ok := record["fee"].(string); ok {
+		receipt.Fee, _ = decimal.NewFromString(fee)
+	}
+	if amount, ok := record["amount"].(string); ok {
+		receipt.Amount, _ = decimal.NewFromString(amount)
+	}
+	if price, ok := record["price"].(string); ok {
+		source, _ := record["price_source"].(string)
+		symbol, _ := record["price_symbol"].(string)
+		var observedAt *time.Time
+		if at, ok := record["price_at"].(time.Time); ok {
+			observedAt = &at
+		}
+		receipt.Price = priceQuoteFromColumns(source, symbol, price, observedAt)
+	}
+	if updateID, ok := record["walrus_update_id"].(int64); ok {
+		receipt.WalrusUpdateID = uint64(updateID)
+	}
+	if createdAt, ok := record["created_at"].(time.Time); ok {
+		receipt.CreatedAt = createdAt
+	}
+	if metadata, ok := record["metadata"].(map[string]interface{}); ok {
+		if digests, ok := metadata["sui_tx_digests"].([]interface{}); ok {
+			for _, digest := range digests {
+				receipt.SuiTxDigests = append(receipt.SuiTxDigests, fmt.Sprint(digest))
+			}
+		}
+	}
+	return receipt
+}
+
+func checkpointToEntity(cp *WalrusCheckpoint) *entities.WalrusCheckpoint {
+	return &entities.WalrusCheckpoint{
+		ID:           fmt.Sprintf("checkpoint_%d", cp.UpdateID),
+		UpdateID:     int64(cp.UpdateID),
+		ChainID:      string(cp.ChainID),
+		Asset:        cp.Asset,
+		Vault:        cp.Vault,
+		BlockNumber:  int64(cp.BlockNumber),
+		BlockHash:    cp.BlockHash,
+		TotalShares:  cp.TotalShares.String(),
+		Index:        cp.Index.String(),
+		BalancesRoot: cp.BalancesRoot,
+		ProofType:    cp.ProofType,
+		ProofBlob:    base64.StdEncoding.EncodeToString(cp.ProofBlob),
+		WalrusBlobID: cp.WalrusBlobID,
+		Status:       string(cp.Status),
+		Timestamp:    cp.Timestamp,
+	}
+}
+
+func checkpointFromEntity(e *entities.WalrusCheckpoint) (*WalrusCheckpoint, error) {
+	totalShares, err := decimal.NewFromString(e.TotalShares)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint %s: total shares: %w", e.ID, err)
+	}
+	index, err := decimal.NewFromString(e.Index)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint %s: index: %w", e.ID, err)
+	}
+	proof, err := base64.StdEncoding.DecodeString(e.ProofBlob)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint %s: proof blob: %w", e.ID, err)
+	}
+	if len(proof) == 0 {
+		proof = nil
+	}
+
+	return &WalrusCheckpoint{
+		UpdateID:     uint64(e.UpdateID),
+		ChainID:      ChainID(e.ChainID),
+		Asset:        e.Asset,
+		Vault:        e.Vault,
+		BlockNumber:  uint64(e.BlockNumber),
+		BlockHash:    e.BlockHash,
+		TotalShares:  totalShares,
+		Index:        index,
+		BalancesRoot: e.BalancesRoot,
+		ProofType:    e.ProofType,
+		ProofBlob:    proof,
+		WalrusBlobID: e.WalrusBlobID,
+		Status:       CheckpointStatus(e.Status),
+		Timestamp:    e.Timestamp,
+	}, nil
+}
+
+func balanceToEntity(bal *CrossChainBalance) *entities.CrossChainBalance {
+	return &entities.CrossChainBalance{
+		ID:               fmt.Sprintf("%s:%s:%s", bal.SuiOwner, bal.ChainID, bal.Asset),
+		SuiOwner:         bal.SuiOwner,
+		ChainID:          string(bal.ChainID),
+		Asset:            bal.Asset,
+		Shares:           bal.Shares.String(),
+		Index:            bal.Index.String(),
+		Value:            bal.Value.String(),
+		CollateralUSD:    bal.CollateralUSD.String(),
+		LastCheckpointID: int64(bal.LastCheckpointID),
+	}
+}
+
+func balanceFromEntity(e *entities.CrossChainBalance) (*CrossChainBalance, error) {
+	amounts := make([]decimal.Decimal, 4)
+	for i, s := range []string{e.Shares, e.Index, e.Value, e.CollateralUSD} {
+		d, err := decimal.NewFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("balance %s: %w", e.ID, err)
+		}
+		amounts[i] = d
+	}
+
+	return &CrossChainBalance{
+		SuiOwner:         e.SuiOwner,
+		ChainID:          ChainID(e.ChainID),
+		Asset:            e.Asset,
+		Shares:           amounts[0],
+		Index:            amounts[1],
+		Value:            amounts[2],
+		CollateralUSD:    amounts[3],
+		LastCheckpointID: uint64(e.LastCheckpointID),
+		UpdatedAt:        e.UpdatedAt,
+	}, nil
+}
+
+func voucherToEntity(v *WithdrawalVoucher) *entities.WithdrawalVoucher {
+	return &entities.WithdrawalVoucher{
+		ID:        v.VoucherID,
+		SuiOwner:  v.SuiOwner,
+		ChainID:   string(v.ChainID),
+		Asset:     v.Asset,
+		Shares:    v.Shares.String(),
+		Nonce:     int64(v.Nonce),
+		Expiry:    v.Expiry,
+		UpdateID:  int64(v.UpdateID),
+		Redeemer:  v.Redeemer,
+		Digest:    v.Digest,
+		Signature: v.Signature,
+		Status:    string(v.Status),
+		TxHash:    v.TxHash,
+		CreatedAt: v.CreatedAt,
+		UpdatedAt: v.UpdatedAt,
+	}
+}
+
+func voucherFromEntity(e *entities.WithdrawalVoucher) (*WithdrawalVoucher, error) {
+	shares, err := decimal.NewFromString(e.Shares)
+	if err != nil {
+		return nil, fmt.Errorf("parse shares of voucher %s: %w", e.ID, err)
+	}
+	return &WithdrawalVoucher{
+		VoucherID: e.ID,
+		SuiOwner:  e.SuiOwner,
+		ChainID:   ChainID(e.ChainID),
+		Asset:     e.Asset,
+		Shares:    shares,
+		Nonce:     uint64(e.Nonce),
+		Expiry:    e.Expiry,
+		UpdateID:  uint64(e.UpdateID),
+		Redeemer:  e.Redeemer,
+		Digest:    e.Digest,
+		Signature: e.Signature,
+		Status:    VoucherStatus(e.Status),
+		TxHash:    e.TxHash,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}, nil
+}
+
+// priceQuoteFromColumns reads the quote a receipt was priced with, nil for
+// receipts issued before quotes were kept
+func priceQuoteFromColumns(source, symbol, price string, observedAt *time.Time) *PriceQuote {
+	if price == "" {
+		return nil
+	}
+	quote := &PriceQuote{Source: source, Symbol: symbol}
+	quote.Price, _ = decimal.NewFromString(price)
+	if observedAt != nil {
+		quote.ObservedAt = *observedAt
+	}
+	return quote
+}
@@ -11,6 +11,9 @@ type ChainID string
 
 const (
 	ChainIDEthereum ChainID = "ethereum"
+	ChainIDArbitrum ChainID = "arbitrum"
+	ChainIDBase     ChainID = "base"
+	ChainIDPolygon  ChainID = "polygon"
 )
 
 // CheckpointStatus reflects verification lifecycle.
@@ -29,6 +32,31 @@ const (
 	VoucherStatusPending VoucherStatus = "pending"
 	VoucherStatusSpent   VoucherStatus = "spent"
 	VoucherStatusSettled VoucherStatus = "settled"
+
+	// VoucherStatusCancelled marks a voucher that expired unredeemed
+	VoucherStatusCancelled VoucherStatus = "cancelled"
+)
+
+// DepositStatus tracks a bridged deposit through processing.
+type DepositStatus string
+
+const (
+	DepositStatusPending DepositStatus = "pending"
+	DepositStatusMinted  DepositStatus = "minted"
+	DepositStatusFailed  DepositStatus = "failed"
+
+	// DepositStatusReverted marks a minted deposit whose block was
+	// reorganized out of the origin chain
+	DepositStatusReverted DepositStatus = "reverted"
+)
+
+// RedeemStatus tracks the origin-chain payout of a redeem. It is derived
+// from the receipt rather than stored.
+type RedeemStatus string
+
+const (
+	RedeemStatusPending RedeemStatus = "pending"
+	RedeemStatusPaid    RedeemStatus = "paid"
 )
 
 // WalrusCheckpoint captures cross-chain vault state published to Walrus.
@@ -63,19 +91,24 @@ type CrossChainBalance struct {
 }
 
 // WithdrawalVoucher is used for self-custody withdrawals on the source chain.
+// It mirrors WalrusEthVault.Voucher; Digest is its EIP-712 hash, signed by
+// the redeemer.
 type WithdrawalVoucher struct {
-	VoucherID     string          `json:"voucherId"`
-	SuiOwner      string          `json:"suiOwner"`
-	ChainID       ChainID         `json:"chainId"`
-	Asset         string          `json:"asset"`
-	Shares        decimal.Decimal `json:"shares"`
-	Nonce         uint64          `json:"nonce"`
-	Expiry        time.Time       `json:"expiry"`
-	UserSignature string          `json:"userSignature"`
-	ProofBlob     []byte          `json:"proofBlob,omitempty"`
-	Status        VoucherStatus   `json:"status"`
-	TxHash        string          `json:"txHash,omitempty"`
-	CreatedAt     time.Time       `json:"createdAt"`
+	VoucherID string          `json:"voucherId"` // 0x hex of 32 bytes
+	SuiOwner  string          `json:"suiOwner"`
+	ChainID   ChainID         `json:"chainId"`
+	Asset     string          `json:"asset"`
+	Shares    decimal.Decimal `json:"shares"` // In vault share units
+	Nonce     uint64          `json:"nonce"`
+	Expiry    time.Time       `json:"expiry"`
+	UpdateID  uint64          `json:"updateId"` // Checkpoint the shares were read from
+	Redeemer  string          `json:"redeemer,omitempty"`
+	Digest    string          `json:"digest,omitempty"`
+	Signature string          `json:"signature,omitempty"` // With v in {27, 28}
+	Status    VoucherStatus   `json:"status"`
+	TxHash    string          `json:"txHash,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
 }
 
 // CollateralParams capture collateralization settings for a cross-chain asset.
@@ -97,6 +130,9 @@ type VaultInfo struct {
 	ChainID           ChainID `json:"chainId"`
 	Asset             string  `json:"asset"`
 	VaultAddress      string  `json:"vaultAddress"`
+	TokenAddress      string  `json:"tokenAddress,omitempty"` // ERC-20 contract; empty for the native asset
+	Decimals          int32   `json:"decimals"`               // Decimals of on-chain amounts
+	PriceSymbol       string  `json:"priceSymbol"`            // Binance ticker pricing the asset in USD
 	DepositMemoFormat string  `json:"depositMemoFormat"`
 	FeedURL           string  `json:"feedUrl,omitempty"`
 	ProofCID          string  `json:"proofCid,omitempty"`
@@ -0,0 +1,410 @@
+package crosschain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/shopspring/decimal"
+)
+
+// voucherExpirySweep is how often the worker cancels expired vouchers
+const voucherExpirySweep = time.Minute
+
+// voucherExpiryGrace covers origin chain timestamps lagging behind ours: a
+// voucher is cancelled only once no block can still accept it
+const voucherExpiryGrace = time.Minute
+
+// VoucherConfig configures how the Service issues vouchers.
+type VoucherConfig struct {
+	// EvmChainIDs are the EIP-712 chain IDs of the origin chains. Vouchers
+	// of other chains are issued without a digest.
+	EvmChainIDs map[ChainID]uint64
+
+	// Signer signs the vouchers it is the redeemer of, which is the default
+	// redeemer. Nil leaves vouchers for their redeemer to sign.
+	Signer EvmSigner
+
+	// TTL is how long a voucher stays redeemable. Default: 10m.
+	TTL time.Duration
+}
+
+// NewVoucherConfigFromEnv reads the EIP-712 chain ID of Ethereum from
+// LFS_ETH_CHAIN_ID and of each chain in LFS_BRIDGE_CHAINS from
+// LFS_BRIDGE_<CHAIN>_EVM_CHAIN_ID. Vouchers are signed with
+// LFS_ETH_PAYOUT_PRIVATE_KEY, the key of the payout handler, and expire
+// after LFS_ETH_PAYOUT_VOUCHER_TTL (default 10m).
+func NewVoucherConfigFromEnv() (*VoucherConfig, error) {
+	cfg := &VoucherConfig{EvmChainIDs: make(map[ChainID]uint64)}
+	var ethChainID uint64
+	if err := parseUintEnv("LFS_ETH_CHAIN_ID", &ethChainID); err != nil {
+		return nil, err
+	}
+	if ethChainID != 0 {
+		cfg.EvmChainIDs[ChainIDEthereum] = ethChainID
+	}
+	for _, name := range splitList(envOrDefault("", "LFS_BRIDGE_CHAINS")) {
+		chainID := ChainID(strings.ToLower(name))
+		var evmChainID uint64
+		if err := parseUintEnv("LFS_BRIDGE_"+strings.ToUpper(name)+"_EVM_CHAIN_ID", &evmChainID); err != nil {
+			return nil, err
+		}
+		if evmChainID != 0 {
+			cfg.EvmChainIDs[chainID] = evmChainID
+		}
+	}
+
+	if key := envOrDefault("", "LFS_ETH_PAYOUT_PRIVATE_KEY"); key != "" {
+		signer, err := NewPrivateKeySigner(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LFS_ETH_PAYOUT_PRIVATE_KEY: %w", err)
+		}
+		cfg.Signer = signer
+	}
+	ttl, err := durationFromEnv("LFS_ETH_PAYOUT_VOUCHER_TTL", defaultVoucherTTL)
+	if err != nil {
+		return nil, err
+	}
+	cfg.TTL = ttl
+	return cfg, nil
+}
+
+// WithVouchers configures the Service to hash and sign the vouchers it
+// issues. Without it, vouchers are issued without digest.
+func WithVouchers(cfg *VoucherConfig) ServiceOption {
+	return func(s *Service) {
+		if cfg != nil {
+			s.vouchersCfg = *cfg
+		}
+	}
+}
+
+// VoucherRequest asks for a voucher redeeming Shares of a vault.
+type VoucherRequest struct {
+	// Seed derives the voucher ID, e.g. the digest of a Sui burn, so that a
+	// voucher asked for twice is issued once. Empty derives it from the
+	// nonce.
+	Seed     string
+	SuiOwner string
+	ChainID  ChainID
+	Asset    string
+	Shares   decimal.Decimal // In vault share units
+	Redeemer string          // Defaults to the configured signer
+	Expiry   time.Time       // Defaults to now plus the voucher TTL
+	UpdateID uint64          // Defaults to the latest checkpoint of the asset
+}
+
+// VoucherID derives the 0x hex voucher ID of seed
+func VoucherID(seed string) string {
+	id := sha256.Sum256([]byte(seed))
+	return "0x" + hex.EncodeToString(id[:])
+}
+
+// VoucherDigest returns the EIP-712 digest WalrusEthVault.hashVoucher
+// computes for v on the vault at vaultAddress of EVM chain evmChainID.
+// Shares are converted to base units with decimals.
+func VoucherDigest(v *WithdrawalVoucher, evmChainID uint64, vaultAddress string, decimals int32) ([]byte, error) {
+	onchain, err := issuedVoucher(v, decimals)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := VaultDomain(evmChainID, vaultAddress).Separator()
+	if err != nil {
+		return nil, err
+	}
+	return TypedDataDigest(domain, onchain.structHash()), nil
+}
+
+// IssueVoucher allocates the next nonce to a voucher for req, binds it to a
+// checkpoint and, when the chain's EIP-712 chain ID is known, hashes it.
+// Vouchers redeemed by the configured signer are signed. A seed whose
+// voucher is still redeemable returns that voucher, and one that expired
+// unredeemed is reissued; a spent one fails with ErrInvalidRequest.
+func (s *Service) IssueVoucher(ctx context.Context, req VoucherRequest) (*WithdrawalVoucher, error) {
+	if req.SuiOwner == "" || !req.Shares.IsPositive() {
+		return nil, ErrInvalidRequest
+	}
+	vault, err := s.GetVault(ctx, req.ChainID, req.Asset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no vault for %s:%s", ErrInvalidRequest, req.ChainID, req.Asset)
+	}
+	now := time.Now()
+	var existing *WithdrawalVoucher
+	if req.Seed != "" {
+		found, err := s.GetVoucher(ctx, VoucherID(req.Seed))
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			return nil, err
+		case found.Status == VoucherStatusPending && found.Expiry.After(now):
+			return found, nil
+		case found.Status == VoucherStatusPending, found.Status == VoucherStatusCancelled:
+			// Never redeemed before it expired: reissue under a new nonce
+			existing = found
+		default:
+			return nil, fmt.Errorf("%w: voucher %s is %s", ErrInvalidRequest, found.VoucherID, found.Status)
+		}
+	}
+
+	v := &WithdrawalVoucher{
+		SuiOwner:  req.SuiOwner,
+		ChainID:   req.ChainID,
+		Asset:     req.Asset,
+		Shares:    req.Shares,
+		Expiry:    req.Expiry,
+		UpdateID:  req.UpdateID,
+		Redeemer:  strings.ToLower(req.Redeemer),
+		Status:    VoucherStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if v.Expiry.IsZero() {
+		ttl := s.vouchersCfg.TTL
+		if ttl <= 0 {
+			ttl = defaultVoucherTTL
+		}
+		v.Expiry = now.Add(ttl)
+	}
+	if !v.Expiry.After(now) {
+		return nil, fmt.Errorf("%w: voucher expiry %s has passed", ErrInvalidRequest, v.Expiry.Format(time.RFC3339))
+	}
+	if v.UpdateID == 0 {
+		latest, err := s.GetLatestCheckpoint(ctx, req.ChainID, req.Asset)
+		if err != nil {
+			return nil, fmt.Errorf("%w: no checkpoint for %s:%s", ErrInvalidRequest, req.ChainID, req.Asset)
+		}
+		v.UpdateID = latest.UpdateID
+	}
+	signer := s.vouchersCfg.Signer
+	if v.Redeemer == "" && signer != nil {
+		v.Redeemer = strings.ToLower(signer.Address())
+	}
+
+	s.mu.Lock()
+	s.nonceCounter++
+	v.Nonce = s.nonceCounter
+	s.mu.Unlock()
+	v.VoucherID = VoucherID(req.Seed)
+	if req.Seed == "" {
+		v.VoucherID = VoucherID(fmt.Sprintf("voucher:%s:%d", req.SuiOwner, v.Nonce))
+	}
+
+	if evmChainID, ok := s.vouchersCfg.EvmChainIDs[req.ChainID]; ok && v.Redeemer != "" {
+		digest, err := VoucherDigest(v, evmChainID, vault.VaultAddress, vault.Decimals)
+		if err != nil {
+			return nil, fmt.Errorf("hash voucher: %w", err)
+		}
+		v.Digest = "0x" + hex.EncodeToString(digest)
+		if signer != nil && strings.EqualFold(signer.Address(), v.Redeemer) {
+			sig, err := signTypedData(ctx, signer, digest)
+			if err != nil {
+				return nil, fmt.Errorf("sign voucher: %w", err)
+			}
+			v.Signature = "0x" + hex.EncodeToString(sig)
+		}
+	}
+
+	if existing != nil {
+		v.CreatedAt = existing.CreatedAt
+		if err := s.saveVoucher(ctx, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	if err := s.createVoucher(ctx, v); err != nil {
+		if errors.Is(err, ErrDepositInProgress) && req.Seed != "" {
+			// Issued concurrently under the same seed
+			if found, ferr := s.GetVoucher(ctx, v.VoucherID); ferr == nil {
+				return found, nil
+			}
+		}
+		return nil, err
+	}
+	return v, nil
+}
+
+// createVoucher records v; a voucher with its ID or nonce fails with
+// ErrDepositInProgress
+func (s *Service) createVoucher(ctx context.Context, v *WithdrawalVoucher) error {
+	if s.store != nil {
+		err := s.store.createVoucher(ctx, v)
+		if errors.Is(err, interfaces.ErrUniqueConstraint) {
+			return ErrDepositInProgress
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.vouchers[v.VoucherID]; ok {
+		return ErrDepositInProgress
+	}
+	saved := *v
+	s.vouchers[v.VoucherID] = &saved
+	return nil
+}
+
+// saveVoucher writes a status change of v
+func (s *Service) saveVoucher(ctx context.Context, v *WithdrawalVoucher) error {
+	v.UpdatedAt = time.Now()
+	if s.store != nil {
+		return s.store.saveVoucher(ctx, v)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.vouchers[v.VoucherID]; !ok {
+		return ErrNotFound
+	}
+	saved := *v
+	s.vouchers[v.VoucherID] = &saved
+	return nil
+}
+
+// GetVoucher returns the voucher with voucherID, or ErrNotFound.
+func (s *Service) GetVoucher(ctx context.Context, voucherID string) (*WithdrawalVoucher, error) {
+	if s.store != nil {
+		return s.store.getVoucher(ctx, voucherID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.vouchers[voucherID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *v
+	return &found, nil
+}
+
+// ListVouchers returns the vouchers of suiOwner with status, oldest first.
+// An empty suiOwner or status matches every owner or status.
+func (s *Service) ListVouchers(ctx context.Context, suiOwner string, status VoucherStatus) ([]*WithdrawalVoucher, error) {
+	if s.store != nil {
+		return s.store.findVouchers(ctx, suiOwner, status)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var vouchers []*WithdrawalVoucher
+	for _, v := range s.vouchers {
+		if (suiOwner == "" || v.SuiOwner == suiOwner) && (status == "" || v.Status == status) {
+			found := *v
+			vouchers = append(vouchers, &found)
+		}
+	}
+	sort.Slice(vouchers, func(i, j int) bool { return vouchers[i].Nonce < vouchers[j].Nonce })
+	return vouchers, nil
+}
+
+// MarkVoucherSpent records that the pending voucher voucherID was redeemed
+// in txHash.
+func (s *Service) MarkVoucherSpent(ctx context.Context, voucherID, txHash string) error {
+	v, err := s.GetVoucher(ctx, voucherID)
+	if err != nil {
+		return err
+	}
+	if v.Status != VoucherStatusPending {
+		return fmt.Errorf("%w: voucher %s is %s", ErrInvalidRequest, voucherID, v.Status)
+	}
+	v.Status = VoucherStatusSpent
+	v.TxHash = txHash
+	return s.saveVoucher(ctx, v)
+}
+
+// CancelVoucher cancels the pending voucher voucherID. Only expired
+// vouchers can be cancelled, since a signed voucher stays redeemable on
+// chain until then.
+func (s *Service) CancelVoucher(ctx context.Context, voucherID string, now time.Time) (*WithdrawalVoucher, error) {
+	v, err := s.GetVoucher(ctx, voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if v.Status != VoucherStatusPending {
+		return nil, fmt.Errorf("%w: voucher %s is %s", ErrInvalidRequest, voucherID, v.Status)
+	}
+	if now.Before(v.Expiry.Add(voucherExpiryGrace)) {
+		return nil, fmt.Errorf("%w: voucher %s is redeemable until %s", ErrInvalidRequest, voucherID, v.Expiry.Format(time.RFC3339))
+	}
+	v.Status = VoucherStatusCancelled
+	if err := s.saveVoucher(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// CancelExpiredVouchers cancels the pending vouchers that expired by now
+// and returns them.
+func (s *Service) CancelExpiredVouchers(ctx context.Context, now time.Time) ([]*WithdrawalVoucher, error) {
+	pending, err := s.ListVouchers(ctx, "", VoucherStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	var cancelled []*WithdrawalVoucher
+	for _, v := range pending {
+		if now.Before(v.Expiry.Add(voucherExpiryGrace)) {
+			continue
+		}
+		v.Status = VoucherStatusCancelled
+		if err := s.saveVoucher(ctx, v); err != nil {
+			return cancelled, err
+		}
+		cancelled = append(cancelled, v)
+	}
+	return cancelled, nil
+}
+
+// runVoucherExpiry cancels expired vouchers every sweep until ctx is done
+func (w *BridgeWorker) runVoucherExpiry(ctx context.Context) {
+	ticker := time.NewTicker(voucherExpirySweep)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			cancelled, err := w.svc.CancelExpiredVouchers(ctx, now)
+			if err != nil {
+				w.logger.Warnw("Failed to cancel expired vouchers", "error", err)
+			}
+			for _, v := range cancelled {
+				w.logger.Infow("Expired voucher cancelled", "voucherId", v.VoucherID, "suiOwner", v.SuiOwner, "nonce", v.Nonce)
+			}
+		}
+	}
+}
+
+// issuedVoucher converts an issued voucher to the WalrusEthVault.Voucher it
+// redeems
+func issuedVoucher(v *WithdrawalVoucher, decimals int32) (voucher, error) {
+	id, err := hex.DecodeString(strings.TrimPrefix(v.VoucherID, "0x"))
+	if err != nil || len(id) != 32 {
+		return voucher{}, fmt.Errorf("voucher ID %q must be 32 hex-encoded bytes", v.VoucherID)
+	}
+	redeemer, err := parseEvmAddress(v.Redeemer)
+	if err != nil {
+		return voucher{}, fmt.Errorf("redeemer: %w", err)
+	}
+	out := voucher{
+		Redeemer: redeemer,
+		SuiOwner: v.SuiOwner,
+		Shares:   toBaseUnits(v.Shares, decimals),
+		Nonce:    v.Nonce,
+		Expiry:   uint64(v.Expiry.Unix()),
+		UpdateID: v.UpdateID,
+	}
+	copy(out.VoucherID[:], id)
+	return out, nil
+}
+
+// sharesFromBaseUnits converts on-chain shares to vault share units
+func sharesFromBaseUnits(shares *big.Int, decimals int32) decimal.Decimal {
+	return decimal.NewFromBigInt(shares, -decimals)
+}
@@ -0,0 +1,148 @@
+package crosschain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestServiceIssuesAndExpiresVouchers(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("LFS_CROSSCHAIN_VAULT_ADDRESS", "0x"+strings.Repeat("11", 20))
+	logger := zap.NewNop().Sugar()
+
+	signer, err := NewPrivateKeySigner("0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	if err != nil {
+		t.Fatalf("NewPrivateKeySigner failed: %v", err)
+	}
+	cfg := &VoucherConfig{EvmChainIDs: map[ChainID]uint64{ChainIDEthereum: 11155111}, Signer: signer}
+
+	persisted, database := newTestService(t, WithVouchers(cfg))
+	for name, svc := range map[string]*Service{
+		"memory":   NewService(logger, WithVouchers(cfg)),
+		"database": persisted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := svc.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			issue := func(seed string, expiry time.Time) (*WithdrawalVoucher, error) {
+				return svc.IssueVoucher(ctx, VoucherRequest{
+					Seed:     seed,
+					SuiOwner: "0xalice",
+					ChainID:  ChainIDEthereum,
+					Asset:    "ETH",
+					Shares:   decimal.RequireFromString("0.5"),
+					Expiry:   expiry,
+					UpdateID: 7,
+				})
+			}
+
+			now := time.Now()
+			first, err := issue("burn-1", time.Time{})
+			if err != nil {
+				t.Fatalf("IssueVoucher failed: %v", err)
+			}
+			if wantID := sha256.Sum256([]byte("burn-1")); first.VoucherID != "0x"+hex.EncodeToString(wantID[:]) {
+				t.Errorf("Expected the voucher ID to be derived from the seed, got %s", first.VoucherID)
+			}
+			if first.Nonce != 1 || first.UpdateID != 7 || first.Redeemer != signer.Address() || first.Status != VoucherStatusPending {
+				t.Errorf("Unexpected voucher %+v", first)
+			}
+			if ttl := first.Expiry.Sub(now); ttl < defaultVoucherTTL-time.Minute || ttl > defaultVoucherTTL+time.Minute {
+				t.Errorf("Expected the default TTL, got %s", ttl)
+			}
+
+			// The signature recovers to the redeemer over the EIP-712 digest
+			digest, err := VoucherDigest(first, 11155111, "0x"+strings.Repeat("11", 20), nativeDecimals)
+			if err != nil {
+				t.Fatalf("VoucherDigest failed: %v", err)
+			}
+			if first.Digest != "0x"+hex.EncodeToString(digest) {
+				t.Errorf("Expected digest 0x%x, got %s", digest, first.Digest)
+			}
+			sig, _ := hex.DecodeString(strings.TrimPrefix(first.Signature, "0x"))
+			if len(sig) != 65 || (sig[64] != 27 && sig[64] != 28) {
+				t.Fatalf("Expected a 65-byte signature with v of 27 or 28, got %s", first.Signature)
+			}
+			sig[64] -= 27
+			if addr, err := recoverEvmAddress(digest, sig); err != nil || addr != signer.Address() {
+				t.Errorf("Expected the signature to recover to %s, got %s (%v)", signer.Address(), addr, err)
+			}
+
+			// Issuing the seed again returns the same voucher
+			again, err := issue("burn-1", time.Time{})
+			if err != nil || again.Nonce != first.Nonce || again.Signature != first.Signature {
+				t.Errorf("Expected the pending voucher back, got %+v (%v)", again, err)
+			}
+			if _, err := issue("burn-past", now.Add(-time.Second)); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected an expired request to be rejected, got %v", err)
+			}
+
+			second, err := issue("burn-2", now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("IssueVoucher failed: %v", err)
+			}
+			if second.Nonce != 2 {
+				t.Errorf("Expected nonce 2, got %d", second.Nonce)
+			}
+			if _, err := svc.CancelVoucher(ctx, second.VoucherID, now); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a redeemable voucher not to be cancelled, got %v", err)
+			}
+
+			cancelled, err := svc.CancelExpiredVouchers(ctx, now.Add(5*time.Minute))
+			if err != nil {
+				t.Fatalf("CancelExpiredVouchers failed: %v", err)
+			}
+			if len(cancelled) != 1 || cancelled[0].VoucherID != second.VoucherID || cancelled[0].Status != VoucherStatusCancelled {
+				t.Fatalf("Expected the expired voucher to be cancelled, got %+v", cancelled)
+			}
+			pending, err := svc.ListVouchers(ctx, "0xalice", VoucherStatusPending)
+			if err != nil || len(pending) != 1 || pending[0].VoucherID != first.VoucherID {
+				t.Errorf("Expected one pending voucher, got %+v (%v)", pending, err)
+			}
+
+			// A cancelled voucher is reissued under a new nonce; a spent one is not
+			reissued, err := issue("burn-2", time.Time{})
+			if err != nil || reissued.Nonce != 3 || reissued.Status != VoucherStatusPending {
+				t.Errorf("Expected the cancelled voucher to be reissued with nonce 3, got %+v (%v)", reissued, err)
+			}
+			if err := svc.MarkVoucherSpent(ctx, first.VoucherID, "0xredeem"); err != nil {
+				t.Fatalf("MarkVoucherSpent failed: %v", err)
+			}
+			if _, err := issue("burn-1", time.Time{}); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Expected a spent voucher not to be reissued, got %v", err)
+			}
+
+			if name != "database" {
+				return
+			}
+			// Nonces are not reused after a restart
+			restarted := NewService(logger, WithVouchers(cfg), WithDatabase(database))
+			if err := restarted.Load(ctx); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			next, err := restarted.IssueVoucher(ctx, VoucherRequest{
+				SuiOwner: "0xbob",
+				ChainID:  ChainIDEthereum,
+				Asset:    "ETH",
+				Shares:   decimal.RequireFromString("1"),
+				UpdateID: 7,
+			})
+			if err != nil || next.Nonce != 4 {
+				t.Errorf("Expected nonce 4 after a restart, got %+v (%v)", next, err)
+			}
+			spent, err := restarted.GetVoucher(ctx, first.VoucherID)
+			if err != nil || spent.Status != VoucherStatusSpent || spent.TxHash != "0xredeem" {
+				t.Errorf("Expected the spent voucher to persist, got %+v (%v)", spent, err)
+			}
+		})
+	}
+}
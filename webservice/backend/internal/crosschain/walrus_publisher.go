@@ -0,0 +1,217 @@
+package crosschain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Publish statuses reported to a WalrusPublishRecorder
+const (
+	PublishOK         = "ok"
+	PublishError      = "error"
+	PublishUnverified = "unverified"
+)
+
+// defaultPublishTimeout bounds a publish to one endpoint
+const defaultPublishTimeout = 30 * time.Second
+
+// WalrusPublishRecorder receives the outcome of each publish attempt, as
+// *metrics.Metrics does
+type WalrusPublishRecorder interface {
+	RecordWalrusPublish(ctx context.Context, endpoint, status string, latency time.Duration)
+}
+
+// HTTPWalrusPublisher puts checkpoints to Walrus publishers. Each endpoint is
+// tried in turn until one stores the blob. With a Reader, a blob ID is only
+// accepted once the blob reads back from an aggregator unchanged.
+type HTTPWalrusPublisher struct {
+	Endpoints    []string // Base URLs, or URLs with the blob path
+	Client       *http.Client
+	Timeout      time.Duration // Per endpoint; defaults to 30s
+	Epochs       int
+	SendObjectTo string
+	Reader       *WalrusReader         // Nil skips the read-back
+	Recorder     WalrusPublishRecorder // Nil disables metrics
+}
+
+// NewHTTPWalrusPublisherFromEnv returns a publisher for the comma-separated
+// Walrus publishers in LFS_WALRUS_PUBLISHER_URLS, storing blobs for
+// LFS_WALRUS_EPOCHS epochs (default 1), or nil when none are set. Each
+// publisher gets LFS_WALRUS_PUBLISH_TIMEOUT (default 30s). Blobs are read
+// back through reader when it is not nil.
+func NewHTTPWalrusPublisherFromEnv(reader *WalrusReader, recorder WalrusPublishRecorder) (*HTTPWalrusPublisher, error) {
+	var endpoints []string
+	for _, u := range strings.Split(envOrDefault("", "LFS_WALRUS_PUBLISHER_URLS", "LFS_WALRUS_PUBLISHER_URL"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			endpoints = append(endpoints, u)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, nil
+	}
+	var epochs uint64
+	if err := parseUintEnv("LFS_WALRUS_EPOCHS", &epochs); err != nil {
+		return nil, err
+	}
+	timeout, err := durationFromEnv("LFS_WALRUS_PUBLISH_TIMEOUT", defaultPublishTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPWalrusPublisher{
+		Endpoints:    endpoints,
+		Client:       &http.Client{},
+		Timeout:      timeout,
+		Epochs:       int(epochs),
+		SendObjectTo: envOrDefault("", "LFS_WALRUS_SEND_OBJECT_TO"),
+		Reader:       reader,
+		Recorder:     recorder,
+	}, nil
+}
+
+// Publish stores cp on the first endpoint that accepts it and returns its
+// blob ID. A response without a blob ID, or a blob that does not read back,
+// fails over to the next endpoint.
+func (p *HTTPWalrusPublisher) Publish(ctx context.Context, cp WalrusCheckpoint) (string, error) {
+	if p == nil || len(p.Endpoints) == 0 {
+		return "", fmt.Errorf("walrus endpoint not configured")
+	}
+	body, err := json.Marshal(cp)
+	if err != nil {
+		return "", fmt.Errorf("marshal checkpoint: %w", err)
+	}
+
+	var errs []error
+	for _, endpoint := range p.Endpoints {
+		start := time.Now()
+		blobID, err := p.publishTo(ctx, endpoint, body)
+		status := PublishOK
+		if err != nil {
+			status = PublishError
+		} else if err = p.verify(ctx, blobID, body); err != nil {
+			status = PublishUnverified
+		}
+		if p.Recorder != nil {
+			p.Recorder.RecordWalrusPublish(ctx, endpoint, status, time.Since(start))
+		}
+		if err == nil {
+			return blobID, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+	}
+	return "", fmt.Errorf("walrus publish: %w", errors.Join(errs...))
+}
+
+func (p *HTTPWalrusPublisher) publishTo(ctx context.Context, endpoint string, body []byte) (string, error) {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultPublishTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	epochs := p.Epochs
+	if epochs <= 0 {
+		epochs = 1
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse walrus endpoint: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/blobs"
+	}
+	q := u.Query()
+	q.Set("epochs", fmt.Sprintf("%d", epochs))
+	if p.SendObjectTo != "" {
+		q.Set("send_object_to", p.SendObjectTo)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("build walrus request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("walrus put: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("walrus put status %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckpointBlobSize))
+	if err != nil {
+		return "", fmt.Errorf("read walrus response: %w", err)
+	}
+	blobID := parseBlobID(raw)
+	if blobID == "" {
+		return "", fmt.Errorf("walrus response has no blob id: %.200s", raw)
+	}
+	return blobID, nil
+}
+
+// parseBlobID reads the blob ID of a publisher response: Walrus answers
+// with newlyCreated or alreadyCertified, gateways with id, blobId or cid
+func parseBlobID(raw []byte) string {
+	var parsed struct {
+		ID           string `json:"id"`
+		BlobID       string `json:"blobId"`
+		Cid          string `json:"cid"`
+		NewlyCreated *struct {
+			BlobObject struct {
+				BlobID string `json:"blobId"`
+			} `json:"blobObject"`
+		} `json:"newlyCreated"`
+		AlreadyCertified *struct {
+			BlobID string `json:"blobId"`
+		} `json:"alreadyCertified"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return ""
+	}
+	switch {
+	case parsed.NewlyCreated != nil && parsed.NewlyCreated.BlobObject.BlobID != "":
+		return parsed.NewlyCreated.BlobObject.BlobID
+	case parsed.AlreadyCertified != nil && parsed.AlreadyCertified.BlobID != "":
+		return parsed.AlreadyCertified.BlobID
+	case parsed.BlobID != "":
+		return parsed.BlobID
+	case parsed.ID != "":
+		return parsed.ID
+	default:
+		return parsed.Cid
+	}
+}
+
+// verify reads blobID back through the reader and checks that it holds body
+func (p *HTTPWalrusPublisher) verify(ctx context.Context, blobID string, body []byte) error {
+	if p.Reader == nil {
+		return nil
+	}
+	stored, err := p.Reader.Fetch(ctx, blobID)
+	if err != nil {
+		return fmt.Errorf("read back blob %s: %w", blobID, err)
+	}
+	if !bytes.Equal(stored, body) {
+		return fmt.Errorf("blob %s differs from the published checkpoint", blobID)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package crosschain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// publishLog records the publish attempts of each endpoint
+type publishLog struct {
+	statuses map[string][]string
+}
+
+func (l *publishLog) RecordWalrusPublish(_ context.Context, endpoint, status string, _ time.Duration) {
+	l.statuses[endpoint] = append(l.statuses[endpoint], status)
+}
+
+func TestWalrusPublisherFailsOverUntilBlobReadsBack(t *testing.T) {
+	ctx := context.Background()
+	walrus := &fakeWalrus{blobs: make(map[string][]byte)}
+	good := httptest.NewServer(walrus)
+	defer good.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer empty.Close()
+	// Answers with a blob ID but never stores the blob
+	lost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"newlyCreated":{"blobObject":{"blobId":"blob-lost"}}}`)
+	}))
+	defer lost.Close()
+
+	log := &publishLog{statuses: make(map[string][]string)}
+	publisher := &HTTPWalrusPublisher{
+		Endpoints: []string{down.URL, empty.URL, lost.URL, good.URL},
+		Timeout:   time.Second,
+		Reader:    &WalrusReader{Aggregators: []string{good.URL}},
+		Recorder:  log,
+	}
+	cp := WalrusCheckpoint{
+		ChainID:     ChainIDEthereum,
+		Asset:       "ETH",
+		BlockNumber: 7,
+		TotalShares: decimal.RequireFromString("2"),
+		Index:       decimal.NewFromInt(1),
+	}
+
+	blobID, err := publisher.Publish(ctx, cp)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if blobID != "blob-1" {
+		t.Errorf("Expected the blob of the last publisher, got %q", blobID)
+	}
+	for endpoint, want := range map[string]string{
+		down.URL:  PublishError,
+		empty.URL: PublishError,
+		lost.URL:  PublishUnverified,
+		good.URL:  PublishOK,
+	} {
+		if got := log.statuses[endpoint]; len(got) != 1 || got[0] != want {
+			t.Errorf("Expected %s to record %s, got %v", endpoint, want, got)
+		}
+	}
+
+	// Without a publisher that stores the blob, publishing fails
+	publisher.Endpoints = []string{down.URL, lost.URL}
+	if blobID, err := publisher.Publish(ctx, cp); err == nil {
+		t.Errorf("Expected the publish to fail, got blob %q", blobID)
+	}
+}
@@ -0,0 +1,190 @@
+package crosschain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrWalrusUnavailable is returned when no Walrus aggregator serves a blob.
+var ErrWalrusUnavailable = errors.New("walrus unavailable")
+
+// maxCheckpointBlobSize bounds the checkpoint blobs read back from Walrus
+const maxCheckpointBlobSize = 1 << 20
+
+// WalrusReader reads checkpoint blobs back from Walrus aggregators. Each
+// aggregator is tried in turn until one serves the blob.
+type WalrusReader struct {
+	Aggregators []string // Base URLs, e.g. https://aggregator.walrus-testnet.walrus.space
+	Client      *http.Client
+}
+
+// NewWalrusReaderFromEnv returns a reader for the comma-separated
+// aggregators in LFS_WALRUS_AGGREGATOR_URLS, or nil when none are set.
+func NewWalrusReaderFromEnv() *WalrusReader {
+	var aggregators []string
+	for _, u := range strings.Split(envOrDefault("", "LFS_WALRUS_AGGREGATOR_URLS", "LFS_WALRUS_AGGREGATOR_URL"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			aggregators = append(aggregators, strings.TrimRight(u, "/"))
+		}
+	}
+	if len(aggregators) == 0 {
+		return nil
+	}
+	return &WalrusReader{
+		Aggregators: aggregators,
+		Client:      &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Fetch returns the content of blobID. ErrNotFound is returned when every
+// aggregator reports the blob missing, ErrWalrusUnavailable when any failed.
+func (r *WalrusReader) Fetch(ctx context.Context, blobID string) ([]byte, error) {
+	if r == nil || len(r.Aggregators) == 0 {
+		return nil, fmt.Errorf("%w: no aggregator configured", ErrWalrusUnavailable)
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var errs []error
+	missing := 0
+	for _, aggregator := range r.Aggregators {
+		body, status, err := r.fetchFrom(ctx, client, aggregator, blobID)
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if status == http.StatusNotFound {
+			missing++
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", aggregator, err))
+	}
+	if missing == len(r.Aggregators) {
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("%w: %v", ErrWalrusUnavailable, errors.Join(errs...))
+}
+
+func (r *WalrusReader) fetchFrom(ctx context.Context, client *http.Client, aggregator, blobID string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, aggregator+"/v1/blobs/"+url.PathEscape(blobID), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckpointBlobSize+1))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if len(body) > maxCheckpointBlobSize {
+		return nil, resp.StatusCode, fmt.Errorf("blob exceeds %d bytes", maxCheckpointBlobSize)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// FetchCheckpoint returns the checkpoint published as blobID.
+func (r *WalrusReader) FetchCheckpoint(ctx context.Context, blobID string) (*WalrusCheckpoint, error) {
+	body, err := r.Fetch(ctx, blobID)
+	if err != nil {
+		return nil, err
+	}
+	var cp WalrusCheckpoint
+	if err := json.Unmarshal(body, &cp); err != nil {
+		return nil, fmt.Errorf("decode checkpoint blob %s: %w", blobID, err)
+	}
+	return &cp, nil
+}
+
+// syntheticBlobID names a checkpoint whose Walrus publish failed or was
+// not configured
+func syntheticBlobID(chainID ChainID, asset string, now time.Time) string {
+	return fmt.Sprintf("walrus-%s-%s-%d", chainID, asset, now.UnixNano())
+}
+
+func isSyntheticBlobID(blobID string) bool {
+	return strings.HasPrefix(blobID, "walrus-")
+}
+
+// CheckpointHash returns the hex sha256 of the state a checkpoint commits
+// to. The update ID, blob ID, status and timestamp are left out: they are
+// assigned or may change after the checkpoint is published.
+func CheckpointHash(cp WalrusCheckpoint) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s:%s:%s:%d:%s:%s:%s:%s:%s:",
+		cp.ChainID,
+		cp.Asset,
+		strings.ToLower(cp.Vault),
+		cp.BlockNumber,
+		cp.BlockHash,
+		cp.TotalShares.String(),
+		cp.Index.String(),
+		cp.BalancesRoot,
+		cp.ProofType,
+	)
+	h.Write(cp.ProofBlob)
+	return fmt.Sprintf("0x%x", h.Sum(nil))
+}
+
+// CheckpointVerification is the result of reading a checkpoint back from
+// Walrus and comparing it with the local copy.
+type CheckpointVerification struct {
+	UpdateID           uint64 `json:"updateId"`
+	WalrusBlobID       string `json:"walrusBlobId"`
+	LocalHash          string `json:"localHash"`
+	RemoteHash         string `json:"remoteHash,omitempty"`
+	BalancesRoot       string `json:"balancesRoot"`
+	RemoteBalancesRoot string `json:"remoteBalancesRoot,omitempty"`
+	Verified           bool   `json:"verified"`
+	Reason             string `json:"reason,omitempty"` // Why verification failed
+}
+
+// VerifyCheckpoint fetches the blob of cp from Walrus and checks that it
+// holds the same checkpoint. A mismatch is reported in the result; errors
+// are returned only when the blob could not be read.
+func (r *WalrusReader) VerifyCheckpoint(ctx context.Context, cp WalrusCheckpoint) (*CheckpointVerification, error) {
+	result := &CheckpointVerification{
+		UpdateID:     cp.UpdateID,
+		WalrusBlobID: cp.WalrusBlobID,
+		LocalHash:    CheckpointHash(cp),
+		BalancesRoot: cp.BalancesRoot,
+	}
+	if cp.WalrusBlobID == "" || isSyntheticBlobID(cp.WalrusBlobID) {
+		result.Reason = "checkpoint was not published to Walrus"
+		return result, nil
+	}
+
+	remote, err := r.FetchCheckpoint(ctx, cp.WalrusBlobID)
+	if err != nil {
+		return nil, err
+	}
+	result.RemoteHash = CheckpointHash(*remote)
+	result.RemoteBalancesRoot = remote.BalancesRoot
+
+	switch {
+	case remote.BalancesRoot != cp.BalancesRoot:
+		result.Reason = "balances root differs from the published checkpoint"
+	case result.RemoteHash != result.LocalHash:
+		result.Reason = "checkpoint differs from the published checkpoint"
+	default:
+		result.Verified = true
+	}
+	return result, nil
+}
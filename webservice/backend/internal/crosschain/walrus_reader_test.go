@@ -0,0 +1,123 @@
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// fakeWalrus stores blobs put to /v1/blobs and serves them by ID
+type fakeWalrus struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (f *fakeWalrus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/blobs":
+		body, _ := io.ReadAll(r.Body)
+		id := fmt.Sprintf("blob-%d", len(f.blobs)+1)
+		f.blobs[id] = body
+		fmt.Fprintf(w, `{"blobId":%q}`, id)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/blobs/"):
+		body, ok := f.blobs[strings.TrimPrefix(r.URL.Path, "/v1/blobs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestVerifyCheckpointReadsBackFromWalrus(t *testing.T) {
+	ctx := context.Background()
+	walrus := &fakeWalrus{blobs: make(map[string][]byte)}
+	server := httptest.NewServer(walrus)
+	defer server.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	// The first aggregator fails over to the second
+	reader := &WalrusReader{Aggregators: []string{down.URL, server.URL}}
+	svc := NewService(zap.NewNop().Sugar(), WithWalrusReader(reader))
+
+	cp := WalrusCheckpoint{
+		ChainID:      ChainIDEthereum,
+		Asset:        "ETH",
+		Vault:        "0xVault",
+		BlockNumber:  101,
+		TotalShares:  decimal.RequireFromString("1.5"),
+		Index:        decimal.RequireFromString("1.0001"),
+		BalancesRoot: svc.BalancesRootAfter(ctx, "0xalice", ChainIDEthereum, "ETH", decimal.RequireFromString("1")),
+		ProofType:    "walrus",
+	}
+	blobID, err := (&HTTPWalrusPublisher{Endpoints: []string{server.URL}}).Publish(ctx, cp)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	cp.WalrusBlobID = blobID
+	created, err := svc.SubmitCheckpoint(ctx, cp)
+	if err != nil {
+		t.Fatalf("SubmitCheckpoint failed: %v", err)
+	}
+
+	result, err := svc.VerifyCheckpoint(ctx, created.UpdateID)
+	if err != nil {
+		t.Fatalf("VerifyCheckpoint failed: %v", err)
+	}
+	if !result.Verified || result.RemoteHash != result.LocalHash || result.RemoteBalancesRoot != cp.BalancesRoot {
+		t.Errorf("Expected the checkpoint to verify, got %+v", result)
+	}
+
+	// A blob with another balances root is reported, not failed
+	walrus.blobs[blobID] = []byte(strings.Replace(string(walrus.blobs[blobID]), cp.BalancesRoot, "0xforged", 1))
+	result, err = svc.VerifyCheckpoint(ctx, created.UpdateID)
+	if err != nil {
+		t.Fatalf("VerifyCheckpoint failed: %v", err)
+	}
+	if result.Verified || result.RemoteBalancesRoot != "0xforged" || result.Reason == "" {
+		t.Errorf("Expected a balances root mismatch, got %+v", result)
+	}
+
+	// Checkpoints that never reached Walrus cannot be verified
+	cp.WalrusBlobID = syntheticBlobID(cp.ChainID, cp.Asset, created.Timestamp)
+	unpublished, err := svc.SubmitCheckpoint(ctx, cp)
+	if err != nil {
+		t.Fatalf("SubmitCheckpoint failed: %v", err)
+	}
+	if result, err := svc.VerifyCheckpoint(ctx, unpublished.UpdateID); err != nil || result.Verified {
+		t.Errorf("Expected an unpublished checkpoint to fail verification, got %+v (%v)", result, err)
+	}
+
+	cp.WalrusBlobID = "blob-missing"
+	missing, err := svc.SubmitCheckpoint(ctx, cp)
+	if err != nil {
+		t.Fatalf("SubmitCheckpoint failed: %v", err)
+	}
+	if _, err := svc.VerifyCheckpoint(ctx, missing.UpdateID); !errors.Is(err, ErrWalrusUnavailable) {
+		t.Errorf("Expected ErrWalrusUnavailable while an aggregator is down, got %v", err)
+	}
+	reader.Aggregators = []string{server.URL}
+	if _, err := svc.VerifyCheckpoint(ctx, missing.UpdateID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing blob, got %v", err)
+	}
+	if _, err := svc.VerifyCheckpoint(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown checkpoint, got %v", err)
+	}
+}
@@ -3,10 +3,12 @@ package memory
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"sync"
 
 	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
 )
 
 var (
@@ -18,6 +20,9 @@ type Database struct {
 	mu      sync.RWMutex
 	tables  map[string]map[string]map[string]interface{} // tableName -> recordID -> record
 	schemas map[string]*interfaces.Schema                 // tableName -> schema
+	hooks   query.HookRegistry
+	changes query.ChangeFeed
+	search  map[string]*query.TextIndex // tableName -> tokenized text for Search filters
 	connected bool
 }
 
@@ -26,6 +31,7 @@ func NewDatabase() *Database {
 	return &Database{
 		tables:  make(map[string]map[string]map[string]interface{}),
 		schemas: make(map[string]*interfaces.Schema),
+		search:  make(map[string]*query.TextIndex),
 	}
 }
 
@@ -47,6 +53,7 @@ func (db *Database) Disconnect(ctx context.Context) error {
 	db.connected = false
 	db.tables = make(map[string]map[string]map[string]interface{})
 	db.schemas = make(map[string]*interfaces.Schema)
+	db.search = make(map[string]*query.TextIndex)
 	log.Println("Disconnected from in-memory database")
 	return nil
 }
@@ -61,10 +68,29 @@ func (db *Database) IsHealthy(ctx context.Context) bool {
 
 // Transaction executes a function within a database transaction
 func (db *Database) Transaction(ctx context.Context, fn func(ctx context.Context, tx interfaces.Transaction) error) error {
+	return db.TransactionWithOptions(ctx, nil, fn)
+}
+
+// readOnlyKey marks contexts of read-only transactions
+type readOnlyKey struct{}
+
+// TransactionWithOptions executes a function within a database transaction.
+// Changes are applied in place and undone on rollback, so the backend never
+// reports conflicts and every isolation level is accepted without being
+// enforced. Read-only transactions reject writes; callbacks returning
+// ErrTxConflict are retried as opts allows.
+func (db *Database) TransactionWithOptions(ctx context.Context, opts *interfaces.TxOptions, fn func(ctx context.Context, tx interfaces.Transaction) error) error {
 	if !db.connected {
 		return interfaces.ErrDatabaseNotConnected
 	}
 	
+	return query.RetryConflicts(ctx, opts, func() error {
+		return db.transaction(ctx, opts, fn)
+	})
+}
+
+// transaction runs fn in a new transaction
+func (db *Database) transaction(ctx context.Context, opts *interfaces.TxOptions, fn func(ctx context.Context, tx interfaces.Transaction) error) error {
 	tx := NewTransaction(db)
 	
 	defer func() {
@@ -73,12 +99,21 @@ func (db *Database) Transaction(ctx context.Context, fn func(ctx context.Context
 		}
 	}()
 	
-	if err := fn(ctx, tx); err != nil {
+	// Hold change events until the transaction commits
+	txCtx := db.changes.CollectChanges(ctx)
+	if opts != nil && opts.ReadOnly {
+		txCtx = context.WithValue(txCtx, readOnlyKey{}, true)
+	}
+	if err := fn(txCtx, tx); err != nil {
 		tx.Rollback(ctx)
 		return err
 	}
 	
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	db.changes.FlushChanges(ctx, txCtx)
+	return nil
 }
 
 // Repository returns a repository for the given schema
@@ -90,6 +125,42 @@ func (db *Database) Repository(schema *interfaces.Schema) interfaces.Repository
 	return NewRepository(db, schema)
 }
 
+// RegisterHooks adds hooks run around the mutations of the schema's table
+func (db *Database) RegisterHooks(schema *interfaces.Schema, hooks interfaces.Hooks) {
+	db.hooks.Register(schema.TableName, hooks)
+}
+
+// Watch streams committed changes to records of the schema's table that
+// match filter, dispatched in process
+func (db *Database) Watch(ctx context.Context, schema *interfaces.Schema, filter *interfaces.Filters) (<-chan interfaces.ChangeEvent, error) {
+	return db.changes.Watch(ctx, schema, filter)
+}
+
+// relatedRepository resolves Include relations against registered schemas
+func (db *Database) relatedRepository(table string) (interfaces.Repository, error) {
+	db.mu.RLock()
+	schema, exists := db.schemas[table]
+	db.mu.RUnlock()
+	
+	if !exists {
+		return nil, fmt.Errorf("%w: no schema registered for table %q", interfaces.ErrInvalidQuery, table)
+	}
+	return NewRepository(db, schema), nil
+}
+
+// textIndex returns the search index of a table, creating it on first use
+func (db *Database) textIndex(table string) *query.TextIndex {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	
+	index, exists := db.search[table]
+	if !exists {
+		index = query.NewTextIndex()
+		db.search[table] = index
+	}
+	return index
+}
+
 // Migrate creates tables and applies schema changes
 func (db *Database) Migrate(ctx context.Context, schemas []*interfaces.Schema) error {
 	if !db.connected {
@@ -100,6 +171,14 @@ func (db *Database) Migrate(ctx context.Context, schemas []*interfaces.Schema) e
 	defer db.mu.Unlock()
 	
 	for _, schema := range schemas {
+		if err := query.ValidateIndexes(schema); err != nil {
+			return &interfaces.DatabaseError{Op: "migrate", Err: err}
+		}
+		// Existing records must satisfy unique indexes added or changed
+		// since the table was created
+		if err := checkUniqueIndexes(schema, db.tables[schema.TableName]); err != nil {
+			return &interfaces.DatabaseError{Op: "migrate " + schema.TableName, Err: err}
+		}
 		db.schemas[schema.TableName] = schema
 		
 		// Create table if it doesn't exist
@@ -113,6 +192,28 @@ func (db *Database) Migrate(ctx context.Context, schemas []*interfaces.Schema) e
 	return nil
 }
 
+// checkUniqueIndexes reports the first unique index of schema that records
+// violate
+func checkUniqueIndexes(schema *interfaces.Schema, records map[string]map[string]interface{}) error {
+	for _, index := range schema.Indexes {
+		if !index.Unique {
+			continue
+		}
+		seen := make(map[string]bool, len(records))
+		for _, record := range records {
+			key, ok := query.IndexKey(index, record)
+			if !ok {
+				continue
+			}
+			if seen[key] {
+				return fmt.Errorf("%w: existing records violate unique index '%s'", interfaces.ErrUniqueConstraint, index.Name)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
 // Seed inserts initial data into the database
 func (db *Database) Seed(ctx context.Context, schema *interfaces.Schema, data []map[string]interface{}) error {
 	if !db.connected {
@@ -132,6 +233,16 @@ func (db *Database) Seed(ctx context.Context, schema *interfaces.Schema, data []
 	return nil
 }
 
+// Export writes the records of opts.Schemas to w as newline-delimited JSON
+func (db *Database) Export(ctx context.Context, w io.Writer, opts interfaces.ExportOptions) error {
+	return query.Export(ctx, db, w, opts)
+}
+
+// Import creates the records of an Export stream in one transaction
+func (db *Database) Import(ctx context.Context, r io.Reader, opts interfaces.ImportOptions) error {
+	return query.Import(ctx, db, r, opts)
+}
+
 // GetTables returns all table names (for debugging/testing)
 func (db *Database) GetTables() []string {
 	db.mu.RLock()
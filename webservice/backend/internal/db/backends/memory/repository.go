@@ -20,10 +20,12 @@ type Repository struct {
 
 // NewRepository creates a new in-memory repository
 func NewRepository(db *Database, schema *interfaces.Schema) *Repository {
+	builder := query.NewBuilder(schema)
+	builder.UseTextIndex(db.textIndex(schema.TableName))
 	return &Repository{
 		db:        db,
 		schema:    schema,
-		builder:   query.NewBuilder(schema),
+		builder:   builder,
 		tableName: schema.TableName,
 	}
 }
@@ -39,7 +41,7 @@ func (r *Repository) GetByID(ctx context.Context, id interfaces.ID) (map[string]
 	}
 	
 	record, exists := table[id.String()]
-	if !exists {
+	if !exists || query.IsDeleted(r.schema, record) {
 		return nil, interfaces.ErrNotFound
 	}
 	
@@ -80,6 +82,19 @@ func (r *Repository) FindMany(ctx context.Context, q *interfaces.Query) (*interf
 		q = &interfaces.Query{}
 	}
 	
+	pagination, err := query.Paginate(r.schema, q)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.ValidateOperators(r.schema, q.Where); err != nil {
+		return nil, err
+	}
+	
+	selected, added, err := query.IncludeSelect(r.schema, pagination.Select, q.Include)
+	if err != nil {
+		return nil, err
+	}
+	
 	r.db.mu.RLock()
 	table, exists := r.db.tables[r.tableName]
 	if !exists {
@@ -105,10 +120,10 @@ func (r *Repository) FindMany(ctx context.Context, q *interfaces.Query) (*interf
 	r.db.mu.RUnlock()
 	
 	// Apply filters
-	if q.Where != nil {
+	if where := query.LiveFilters(r.schema, q.Where, q.WithDeleted); where != nil {
 		var filtered []map[string]interface{}
 		for _, record := range records {
-			if r.builder.MatchesFilters(record, q.Where) {
+			if r.builder.MatchesFilters(record, where) {
 				filtered = append(filtered, record)
 			}
 		}
@@ -117,9 +132,31 @@ func (r *Repository) FindMany(ctx context.Context, q *interfaces.Query) (*interf
 	
 	total := int64(len(records))
 	
+	// Narrow to the rows after or before a cursor
+	if pagination.Where != q.Where {
+		where := query.LiveFilters(r.schema, pagination.Where, q.WithDeleted)
+		var filtered []map[string]interface{}
+		for _, record := range records {
+			if r.builder.MatchesFilters(record, where) {
+				filtered = append(filtered, record)
+			}
+		}
+		records = filtered
+	}
+	
+	// Rank search results
+	search := query.HasSearch(q.Where)
+	if search {
+		for _, record := range records {
+			record[query.RankField] = r.builder.Rank(record, q.Where)
+		}
+	}
+	
 	// Apply sorting
-	if len(q.OrderBy) > 0 {
-		records = r.builder.ApplySort(records, q.OrderBy)
+	if len(pagination.OrderBy) > 0 {
+		records = r.builder.ApplySort(records, pagination.OrderBy)
+	} else if search {
+		query.SortByRank(r.schema, records)
 	}
 	
 	// Apply pagination
@@ -132,14 +169,14 @@ func (r *Repository) FindMany(ctx context.Context, q *interfaces.Query) (*interf
 		pageSize = *q.Limit
 	}
 	
-	records = r.builder.ApplyPagination(records, q.Limit, q.Offset)
+	records = r.builder.ApplyPagination(records, pagination.Limit, pagination.Offset)
 	
 	// Apply field selection
-	if len(q.Select) > 0 {
+	if len(selected) > 0 {
 		var projected []map[string]interface{}
 		for _, record := range records {
 			projectedRecord := make(map[string]interface{})
-			for _, field := range q.Select {
+			for _, field := range append(selected, query.RankField) {
 				if value, exists := record[field]; exists {
 					projectedRecord[field] = value
 				}
@@ -149,21 +186,44 @@ func (r *Repository) FindMany(ctx context.Context, q *interfaces.Query) (*interf
 		records = projected
 	}
 	
+	// Eager load relations
+	if len(q.Include) > 0 {
+		if err := query.LoadIncludes(ctx, r.schema, records, q.Include, r.db.relatedRepository); err != nil {
+			return nil, err
+		}
+		query.StripFields(records, added)
+	}
+	records, next, prev := pagination.Page(records)
+	ranks := query.TakeRanks(records)
+	
 	page := 1
 	if pageSize > 0 {
 		page = (offset / pageSize) + 1
 	}
 	
 	return &interfaces.ResultPage{
-		Data:     records,
-		Total:    total,
-		Page:     page,
-		PageSize: pageSize,
+		Data:       records,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		NextCursor: next,
+		PrevCursor: prev,
+		Ranks:      ranks,
 	}, nil
 }
 
 // Create inserts a new record
 func (r *Repository) Create(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	return r.hooked().Create(ctx, data, r.create)
+}
+
+// create inserts data without running hooks
+func (r *Repository) create(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	data = query.WithInitialVersion(r.schema, data)
+	
 	// Validate data
 	if err := r.builder.ValidateData(data); err != nil {
 		return nil, fmt.Errorf("validation error: %w", err)
@@ -180,10 +240,13 @@ func (r *Repository) Create(ctx context.Context, data map[string]interface{}) (m
 		record["id"] = uuid.New().String()
 	}
 	
-	// Set timestamps
+	// Set timestamps, unless an import restores them
 	now := time.Now()
-	record["created_at"] = now
-	record["updated_at"] = now
+	for _, field := range []string{"created_at", "updated_at"} {
+		if _, kept := record[field]; !kept || !query.KeepsTimestamps(ctx) {
+			record[field] = now
+		}
+	}
 	
 	// Apply default values
 	for fieldName, fieldSchema := range r.schema.Fields {
@@ -191,6 +254,7 @@ func (r *Repository) Create(ctx context.Context, data map[string]interface{}) (m
 			record[fieldName] = fieldSchema.DefaultValue
 		}
 	}
+	query.NormalizeJSONFields(r.schema, record)
 	
 	r.db.mu.Lock()
 	defer r.db.mu.Unlock()
@@ -205,7 +269,7 @@ func (r *Repository) Create(ctx context.Context, data map[string]interface{}) (m
 	
 	// Check if ID already exists
 	if _, exists := table[id]; exists {
-		return nil, fmt.Errorf("record with id '%s' already exists", id)
+		return nil, fmt.Errorf("%w: record with id '%s' already exists", interfaces.ErrUniqueConstraint, id)
 	}
 	
 	// Validate unique constraints
@@ -220,6 +284,7 @@ func (r *Repository) Create(ctx context.Context, data map[string]interface{}) (m
 	
 	// Store record
 	table[id] = record
+	r.db.changes.Publish(ctx, query.Change(r.schema, interfaces.ChangeCreate, record))
 	
 	// Return copy
 	result := make(map[string]interface{})
@@ -232,6 +297,19 @@ func (r *Repository) Create(ctx context.Context, data map[string]interface{}) (m
 
 // Update modifies an existing record by ID
 func (r *Repository) Update(ctx context.Context, id interfaces.ID, data map[string]interface{}) (map[string]interface{}, error) {
+	return r.hooked().Update(ctx, id, data, r.update)
+}
+
+// update modifies a record without running hooks
+func (r *Repository) update(ctx context.Context, id interfaces.ID, data map[string]interface{}) (map[string]interface{}, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	data, expected, checkVersion, err := query.ExpectedVersion(r.schema, data)
+	if err != nil {
+		return nil, err
+	}
+	
 	r.db.mu.Lock()
 	defer r.db.mu.Unlock()
 	
@@ -241,7 +319,7 @@ func (r *Repository) Update(ctx context.Context, id interfaces.ID, data map[stri
 	}
 	
 	existing, exists := table[id.String()]
-	if !exists {
+	if !exists || query.IsDeleted(r.schema, existing) {
 		return nil, interfaces.ErrNotFound
 	}
 	
@@ -254,6 +332,16 @@ func (r *Repository) Update(ctx context.Context, id interfaces.ID, data map[stri
 		updated[k] = v
 	}
 	updated["updated_at"] = time.Now()
+	query.NormalizeJSONFields(r.schema, updated)
+	
+	// Optimistic locking
+	if field := r.schema.VersionField; field != "" {
+		current, _ := query.VersionNumber(existing[field])
+		if checkVersion && current != expected {
+			return nil, interfaces.ErrConflict
+		}
+		updated[field] = query.VersionValue(r.schema, current+1)
+	}
 	
 	// Validate unique constraints (excluding this record)
 	if err := r.validateUniqueConstraints(table, updated, id.String()); err != nil {
@@ -267,6 +355,7 @@ func (r *Repository) Update(ctx context.Context, id interfaces.ID, data map[stri
 	
 	// Update record
 	table[id.String()] = updated
+	r.db.changes.Publish(ctx, query.Change(r.schema, interfaces.ChangeUpdate, updated))
 	
 	// Return copy
 	result := make(map[string]interface{})
@@ -318,6 +407,14 @@ func (r *Repository) Upsert(ctx context.Context, uniqueFields map[string]interfa
 
 // Delete removes a record by ID
 func (r *Repository) Delete(ctx context.Context, id interfaces.ID) error {
+	return r.hooked().Delete(ctx, id, r.remove)
+}
+
+// remove deletes a record without running hooks
+func (r *Repository) remove(ctx context.Context, id interfaces.ID) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
 	r.db.mu.Lock()
 	defer r.db.mu.Unlock()
 	
@@ -326,22 +423,113 @@ func (r *Repository) Delete(ctx context.Context, id interfaces.ID) error {
 		return interfaces.ErrNotFound
 	}
 	
-	if _, exists := table[id.String()]; !exists {
+	existing, exists := table[id.String()]
+	if !exists || query.IsDeleted(r.schema, existing) {
 		return interfaces.ErrNotFound
 	}
 	
+	// Soft-deleted records stay in place, so references to them remain valid
+	if r.schema.SoftDelete {
+		deleted := r.touch(existing)
+		deleted[interfaces.DeletedAtField] = deleted["updated_at"]
+		table[id.String()] = deleted
+		r.db.changes.Publish(ctx, query.Change(r.schema, interfaces.ChangeDelete, deleted))
+		return nil
+	}
+	
 	// Check foreign key constraints from other tables
 	if err := r.validateForeignKeyConstraintsOnDelete(id.String()); err != nil {
 		return err
 	}
 	
 	delete(table, id.String())
+	r.builder.RemoveIndexed(id.String())
+	r.db.changes.Publish(ctx, query.Change(r.schema, interfaces.ChangeDelete, existing))
+	return nil
+}
+
+// Restore brings back a soft-deleted record by ID. Restoring a record that
+// is not deleted returns it unchanged.
+func (r *Repository) Restore(ctx context.Context, id interfaces.ID) (map[string]interface{}, error) {
+	if err := query.RequireSoftDelete(r.schema); err != nil {
+		return nil, err
+	}
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	
+	table, exists := r.db.tables[r.tableName]
+	if !exists {
+		return nil, interfaces.ErrNotFound
+	}
+	
+	existing, exists := table[id.String()]
+	if !exists {
+		return nil, interfaces.ErrNotFound
+	}
+	
+	if query.IsDeleted(r.schema, existing) {
+		existing = r.touch(existing)
+		existing[interfaces.DeletedAtField] = nil
+		table[id.String()] = existing
+		r.db.changes.Publish(ctx, query.Change(r.schema, interfaces.ChangeUpdate, existing))
+	}
+	
+	// Return copy
+	result := make(map[string]interface{})
+	for k, v := range existing {
+		result[k] = v
+	}
+	
+	return result, nil
+}
+
+// checkWritable rejects writes made in a read-only transaction
+func checkWritable(ctx context.Context) error {
+	if readOnly, _ := ctx.Value(readOnlyKey{}).(bool); readOnly {
+		return interfaces.ErrReadOnly
+	}
 	return nil
 }
 
+// touch returns a copy of record with updated_at set and the version, if
+// any, incremented
+func (r *Repository) touch(record map[string]interface{}) map[string]interface{} {
+	touched := make(map[string]interface{})
+	for k, v := range record {
+		touched[k] = v
+	}
+	touched["updated_at"] = time.Now()
+	if field := r.schema.VersionField; field != "" {
+		current, _ := query.VersionNumber(record[field])
+		touched[field] = query.VersionValue(r.schema, current+1)
+	}
+	return touched
+}
+
+// CreateMany inserts records one at a time; the in-memory backend has no
+// round trips to save, so BatchSize is ignored. Rows that fail are reported
+// in the result and the other rows are still inserted.
+func (r *Repository) CreateMany(ctx context.Context, data []map[string]interface{}, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	return query.CreateEach(ctx, r, data)
+}
+
+// UpdateMany updates records one at a time, reporting rows that fail
+func (r *Repository) UpdateMany(ctx context.Context, updates []interfaces.BulkUpdate, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	return query.UpdateEach(ctx, r, updates)
+}
+
+// DeleteMany deletes records one at a time, reporting rows that fail
+func (r *Repository) DeleteMany(ctx context.Context, ids []interfaces.ID, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	return query.DeleteEach(ctx, r, ids)
+}
+
 // Count returns the number of records matching the query
 func (r *Repository) Count(ctx context.Context, q *interfaces.Query) (int64, error) {
-	if q == nil {
+	if q == nil && !r.schema.SoftDelete {
 		r.db.mu.RLock()
 		table, exists := r.db.tables[r.tableName]
 		count := int64(0)
@@ -351,10 +539,14 @@ func (r *Repository) Count(ctx context.Context, q *interfaces.Query) (int64, err
 		r.db.mu.RUnlock()
 		return count, nil
 	}
+	if q == nil {
+		q = &interfaces.Query{}
+	}
 	
 	// Use FindMany but without pagination to get accurate count
 	countQuery := &interfaces.Query{
-		Where: q.Where,
+		Where:       q.Where,
+		WithDeleted: q.WithDeleted,
 	}
 	
 	result, err := r.FindMany(ctx, countQuery)
@@ -365,11 +557,39 @@ func (r *Repository) Count(ctx context.Context, q *interfaces.Query) (int64, err
 	return result.Total, nil
 }
 
+// Aggregate returns one row per group with the group fields and the
+// requested aggregates
+func (r *Repository) Aggregate(ctx context.Context, q *interfaces.AggregateQuery) ([]map[string]interface{}, error) {
+	out, err := query.AggregateSchema(r.schema, q)
+	if err != nil {
+		return nil, err
+	}
+	
+	result, err := r.FindMany(ctx, &interfaces.Query{Where: q.Where, WithDeleted: q.WithDeleted})
+	if err != nil {
+		return nil, err
+	}
+	
+	return r.builder.Aggregate(result.Data, q, out), nil
+}
+
 // GetSchema returns the schema for this repository
 func (r *Repository) GetSchema() *interfaces.Schema {
 	return r.schema
 }
 
+// hooked runs mutations with the hooks registered for this table
+func (r *Repository) hooked() query.Hooked {
+	return query.Hooked{
+		Hooks: r.db.hooks.Hooks(r.tableName),
+		Transaction: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return r.db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+				return fn(ctx)
+			})
+		},
+	}
+}
+
 // Helper methods for constraint validation
 
 func (r *Repository) validateUniqueConstraints(table map[string]map[string]interface{}, record map[string]interface{}, excludeID string) error {
@@ -395,49 +615,24 @@ func (r *Repository) validateUniqueConstraints(table map[string]map[string]inter
 		}
 	}
 	
-	// Check unique indexes
+	// Check unique indexes; records with a null in an indexed column never
+	// conflict, as in SQL
 	for _, index := range r.schema.Indexes {
 		if !index.Unique {
 			continue
 		}
 		
-		// Build composite key
-		var keyParts []interface{}
-		for _, column := range index.Columns {
-			if value, exists := record[column]; exists {
-				keyParts = append(keyParts, value)
-			} else {
-				keyParts = append(keyParts, nil)
-			}
+		key, ok := query.IndexKey(index, record)
+		if !ok {
+			continue
 		}
 		
-		// Check if any other record has the same composite key
 		for id, existing := range table {
 			if id == excludeID {
 				continue
 			}
-			
-			var existingKeyParts []interface{}
-			for _, column := range index.Columns {
-				if value, exists := existing[column]; exists {
-					existingKeyParts = append(existingKeyParts, value)
-				} else {
-					existingKeyParts = append(existingKeyParts, nil)
-				}
-			}
-			
-			// Compare composite keys
-			if len(keyParts) == len(existingKeyParts) {
-				match := true
-				for i, part := range keyParts {
-					if part != existingKeyParts[i] {
-						match = false
-						break
-					}
-				}
-				if match {
-					return fmt.Errorf("%w: unique index '%s'", interfaces.ErrUniqueConstraint, index.Name)
-				}
+			if existingKey, ok := query.IndexKey(index, existing); ok && existingKey == key {
+				return fmt.Errorf("%w: unique index '%s'", interfaces.ErrUniqueConstraint, index.Name)
 			}
 		}
 	}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// CreateMany inserts records with one multi-row INSERT per batch. Rows
+// rejected by validation or a constraint are reported in the result and
+// the other rows are still inserted.
+func (r *Repository) CreateMany(ctx context.Context, data []map[string]interface{}, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	if _, err := r.db.querier(ctx); err != nil {
+		return nil, err
+	}
+	return r.bulk().CreateMany(ctx, data, opts)
+}
+
+// UpdateMany updates records by ID with one UPDATE ... FROM (VALUES ...)
+// per batch. Unknown IDs, stale versions and rows rejected by a constraint
+// are reported in the result and the other rows are still updated.
+func (r *Repository) UpdateMany(ctx context.Context, updates []interfaces.BulkUpdate, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	if _, err := r.db.querier(ctx); err != nil {
+		return nil, err
+	}
+	return r.bulk().UpdateMany(ctx, updates, opts)
+}
+
+// DeleteMany deletes records by ID with one DELETE per batch. Unknown IDs
+// and rows still referenced by a foreign key are reported in the result and
+// the other rows are still deleted.
+func (r *Repository) DeleteMany(ctx context.Context, ids []interfaces.ID, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	if _, err := r.db.querier(ctx); err != nil {
+		return nil, err
+	}
+	return r.bulk().DeleteMany(ctx, ids, opts)
+}
+
+// bulk writes the rows of this table in batches, see sqlgen.Bulk
+func (r *Repository) bulk() *sqlgen.Bulk {
+	return &sqlgen.Bulk{
+		Dialect:    dialect,
+		Schema:     r.schema,
+		Querier:    sqlgen.QuerierFunc(r.queryRecords),
+		Repository: r,
+		Hooked:     r.hooked(),
+		Prepare:    r.prepareCreate,
+	}
+}
+
+// queryRecords runs a statement writing rows and returns the records it
+// returns, as sqlgen.Querier
+func (r *Repository) queryRecords(ctx context.Context, op interfaces.ChangeOp, stmt string, args []interface{}) ([]map[string]interface{}, error) {
+	q, err := r.db.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(ctx, stmt, args...)
+	if err != nil {
+		return nil, translateError(string(op), err)
+	}
+	records, err := collect(rows, r.schema)
+	if err != nil {
+		return nil, translateError(string(op), err)
+	}
+	return records, nil
+}
+
+// transaction adapts Database.Transaction to query.Hooked and
+// query.BulkWriter
+func (r *Repository) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+		return fn(ctx)
+	})
+}
@@ -0,0 +1,268 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// changeChannel is the NOTIFY channel the change triggers publish on
+const changeChannel = "leafsii_changes"
+
+// notifyFunctionSQL creates the trigger function reporting row changes on
+// changeChannel. TG_ARGV[0] names the primary key and TG_ARGV[1] is 'soft'
+// for soft-delete tables, where setting deleted_at reports a delete.
+// Payloads over the 8000 byte NOTIFY limit carry only the key and the
+// listener reads the row back.
+const notifyFunctionSQL = `CREATE OR REPLACE FUNCTION leafsii_notify_change() RETURNS trigger AS $$
+DECLARE
+	row_data json;
+	op text;
+	payload text;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		row_data := row_to_json(OLD);
+	ELSE
+		row_data := row_to_json(NEW);
+	END IF;
+
+	op := CASE TG_OP WHEN 'INSERT' THEN 'create' WHEN 'UPDATE' THEN 'update' ELSE 'delete' END;
+	IF TG_OP = 'UPDATE' AND TG_ARGV[1] = 'soft'
+		AND row_to_json(OLD)->>'deleted_at' IS NULL AND row_data->>'deleted_at' IS NOT NULL THEN
+		op := 'delete';
+	END IF;
+
+	payload := json_build_object('table', TG_TABLE_NAME, 'op', op, 'id', row_data->>TG_ARGV[0], 'record', row_data)::text;
+	IF octet_length(payload) > 7900 THEN
+		payload := json_build_object('table', TG_TABLE_NAME, 'op', op, 'id', row_data->>TG_ARGV[0])::text;
+	END IF;
+	PERFORM pg_notify('` + changeChannel + `', payload);
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql`
+
+// changeTriggerSQL returns the statements (re)creating the change trigger
+// of schema's table
+func changeTriggerSQL(schema *interfaces.Schema) []string {
+	table := sqlgen.QuoteIdent(schema.TableName)
+	trigger := sqlgen.QuoteIdent(schema.TableName + "_changes")
+	mode := "hard"
+	if schema.SoftDelete {
+		mode = "soft"
+	}
+	pk := strings.ReplaceAll(primaryKeyName(schema), "'", "''")
+
+	return []string{
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", trigger, table),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION leafsii_notify_change('%s', '%s')",
+			trigger, table, pk, mode),
+	}
+}
+
+// primaryKeyName returns the name of schema's primary key field
+func primaryKeyName(schema *interfaces.Schema) string {
+	for name, field := range schema.Fields {
+		if field.PrimaryKey {
+			return name
+		}
+	}
+	return "id"
+}
+
+// Watch streams committed changes to records of the schema's table that
+// match filter. Changes are reported by triggers through LISTEN/NOTIFY, so
+// writes from every connection and process are seen, and only once they
+// commit. All watchers share one listening connection, started on first
+// use; if it is lost, every watch channel is closed.
+func (db *Database) Watch(ctx context.Context, schema *interfaces.Schema, filter *interfaces.Filters) (<-chan interfaces.ChangeEvent, error) {
+	db.registerSchema(schema)
+	if err := db.startListener(); err != nil {
+		return nil, err
+	}
+	return db.changes.Watch(ctx, schema, filter)
+}
+
+// startListener starts the LISTEN loop unless it is already running
+func (db *Database) startListener() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.listener != nil {
+		return nil
+	}
+	if db.pool == nil {
+		return interfaces.ErrDatabaseNotConnected
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pooled, err := db.pool.Acquire(ctx)
+	if err != nil {
+		cancel()
+		return &interfaces.DatabaseError{Op: "listen", Err: err}
+	}
+	// The connection stays subscribed, so it must not go back to the pool
+	conn := pooled.Hijack()
+	if _, err := conn.Exec(ctx, "LISTEN "+changeChannel); err != nil {
+		conn.Close(context.Background())
+		cancel()
+		return &interfaces.DatabaseError{Op: "listen", Err: err}
+	}
+
+	l := &listener{stop: cancel}
+	db.listener = l
+	go db.listen(ctx, conn, l)
+	return nil
+}
+
+// listener is a running LISTEN loop
+type listener struct {
+	stop context.CancelFunc
+}
+
+// listen publishes notifications until ctx is cancelled or the connection
+// fails, then closes every watcher
+func (db *Database) listen(ctx context.Context, conn *pgx.Conn, l *listener) {
+	defer conn.Close(context.Background())
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("PostgreSQL change listener stopped: %v", err)
+			}
+			break
+		}
+
+		event, err := db.decodeChange(ctx, notification.Payload)
+		if err != nil {
+			log.Printf("Failed to decode change notification: %v", err)
+			continue
+		}
+		if event != nil {
+			db.changes.Publish(ctx, *event)
+		}
+	}
+
+	// A later Watch starts a new listener
+	db.mu.Lock()
+	if db.listener == l {
+		db.listener = nil
+	}
+	db.mu.Unlock()
+	l.stop()
+	db.changes.CloseAll()
+}
+
+// changePayload is the JSON sent by leafsii_notify_change
+type changePayload struct {
+	Table  string                 `json:"table"`
+	Op     interfaces.ChangeOp    `json:"op"`
+	ID     string                 `json:"id"`
+	Record map[string]interface{} `json:"record"`
+}
+
+// decodeChange turns a notification payload into an event, or nil for
+// tables without a registered schema. Payloads carrying only the key are
+// completed by reading the row; a row deleted since keeps just its key.
+func (db *Database) decodeChange(ctx context.Context, payload string) (*interfaces.ChangeEvent, error) {
+	var change changePayload
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&change); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	schema, exists := db.schemas[change.Table]
+	db.mu.RUnlock()
+	if !exists {
+		return nil, nil
+	}
+
+	event := changeEvent(schema, change)
+	if event.Record != nil {
+		return event, nil
+	}
+
+	pk := primaryKeyName(schema)
+	record, err := NewRepository(db, schema).FindOne(interfaces.WithPrimary(ctx), &interfaces.Query{
+		Where:       &interfaces.Filters{Conditions: []interfaces.Filter{{Field: pk, Value: event.ID}}},
+		WithDeleted: true,
+	})
+	switch {
+	case err == nil:
+		event.Record = record
+	case errors.Is(err, interfaces.ErrNotFound):
+		event.Record = map[string]interface{}{pk: fromJSONValue(schema.Fields[pk], event.ID)}
+	default:
+		return nil, err
+	}
+	return event, nil
+}
+
+// changeEvent builds the event for a decoded payload, converting record
+// values to the Go types of schema
+func changeEvent(schema *interfaces.Schema, change changePayload) *interfaces.ChangeEvent {
+	event := &interfaces.ChangeEvent{Table: change.Table, Op: change.Op, ID: change.ID}
+	if change.Record != nil {
+		event.Record = make(map[string]interface{}, len(change.Record))
+		for name, value := range change.Record {
+			event.Record[name] = fromJSONValue(schema.Fields[name], value)
+		}
+	}
+	return event
+}
+
+// fromJSONValue converts a value decoded from row_to_json to field's Go type
+func fromJSONValue(field interfaces.FieldSchema, value interface{}) interface{} {
+	if field.Type == "json" {
+		// Numbers inside the document decode as float64, as when read
+		if normalized, err := query.NormalizeJSON(value); err == nil {
+			return normalized
+		}
+		return value
+	}
+	switch v := value.(type) {
+	case json.Number:
+		switch field.Type {
+		case "int":
+			if n, err := v.Int64(); err == nil {
+				return int(n)
+			}
+		case "int64":
+			if n, err := v.Int64(); err == nil {
+				return n
+			}
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	case string:
+		switch field.Type {
+		case "time":
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t
+			}
+		case "int":
+			var n int
+			if _, err := fmt.Sscan(v, &n); err == nil {
+				return n
+			}
+		case "int64":
+			var n int64
+			if _, err := fmt.Sscan(v, &n); err == nil {
+				return n
+			}
+		}
+	}
+	return value
+}
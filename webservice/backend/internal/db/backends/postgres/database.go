@@ -0,0 +1,601 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// Options configures the connection pool
+type Options struct {
+	MaxOpenConns int      // Maximum open connections, per pool. Default: pgxpool default
+	MaxIdleConns int      // Minimum connections kept open, per pool. Default: 0
+	Replicas     []string // DSNs of read replicas. Default: none, reads go to the primary
+}
+
+// querier is implemented by both the pool and transactions
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// txKey carries the active transaction in the context passed to
+// Transaction callbacks, so repositories join it transparently
+type txKey struct{}
+
+// Database implements the Database interface for PostgreSQL
+type Database struct {
+	dsn     string
+	options Options
+
+	mu       sync.RWMutex
+	pool     *pgxpool.Pool                 // Primary, serving writes and transactions
+	replicas []*pgxpool.Pool               // Serving reads outside transactions, in turn
+	next     atomic.Uint64                 // Replica for the next read
+	schemas  map[string]*interfaces.Schema // tableName -> schema, for Include
+	hooks    query.HookRegistry
+
+	changes  query.ChangeFeed
+	listener *listener // LISTEN loop serving changes, started by Watch
+}
+
+// NewDatabase creates a PostgreSQL database. No connection is made until
+// Connect is called.
+func NewDatabase(dsn string, options Options) *Database {
+	return &Database{
+		dsn:     dsn,
+		options: options,
+		schemas: make(map[string]*interfaces.Schema),
+	}
+}
+
+// Connect establishes connection pools to the primary and every replica
+func (db *Database) Connect(ctx context.Context) error {
+	pool, err := db.connectPool(ctx, db.dsn, "connect")
+	if err != nil {
+		return err
+	}
+	replicas := make([]*pgxpool.Pool, 0, len(db.options.Replicas))
+	for _, dsn := range db.options.Replicas {
+		replica, err := db.connectPool(ctx, dsn, "connect_replica")
+		if err != nil {
+			pool.Close()
+			closePools(replicas)
+			return err
+		}
+		replicas = append(replicas, replica)
+	}
+
+	db.mu.Lock()
+	if db.pool != nil {
+		db.pool.Close()
+	}
+	closePools(db.replicas)
+	db.pool = pool
+	db.replicas = replicas
+	db.mu.Unlock()
+
+	log.Printf("Connected to PostgreSQL database with %d read replicas", len(replicas))
+	return nil
+}
+
+// connectPool opens and pings a connection pool to dsn
+func (db *Database) connectPool(ctx context.Context, dsn, op string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, &interfaces.DatabaseError{Op: "parse_dsn", Err: err}
+	}
+	if db.options.MaxOpenConns > 0 {
+		config.MaxConns = int32(db.options.MaxOpenConns)
+	}
+	if db.options.MaxIdleConns > 0 {
+		config.MinConns = int32(db.options.MaxIdleConns)
+	}
+	config.ConnConfig.Tracer = statementTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, &interfaces.DatabaseError{Op: op, Err: err}
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, &interfaces.DatabaseError{Op: op, Err: err}
+	}
+	return pool, nil
+}
+
+// statementTracer records the statements run for instrumented calls, see
+// query.CaptureStatements
+type statementTracer struct{}
+
+func (statementTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	query.RecordStatement(ctx, data.SQL)
+	return ctx
+}
+
+func (statementTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+func closePools(pools []*pgxpool.Pool) {
+	for _, pool := range pools {
+		pool.Close()
+	}
+}
+
+// Disconnect closes the connection pool
+func (db *Database) Disconnect(ctx context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.listener != nil {
+		db.listener.stop()
+		db.listener = nil
+	}
+	if db.pool != nil {
+		db.pool.Close()
+		db.pool = nil
+	}
+	closePools(db.replicas)
+	db.replicas = nil
+	log.Println("Disconnected from PostgreSQL database")
+	return nil
+}
+
+// IsHealthy checks that the primary and every replica answer
+func (db *Database) IsHealthy(ctx context.Context) bool {
+	pool, err := db.getPool()
+	if err != nil {
+		return false
+	}
+	db.mu.RLock()
+	replicas := db.replicas
+	db.mu.RUnlock()
+
+	for _, p := range append([]*pgxpool.Pool{pool}, replicas...) {
+		if p.Ping(ctx) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Transaction executes a function within a database transaction. Repository
+// calls made with the callback's context run inside the transaction; nested
+// calls create savepoints.
+func (db *Database) Transaction(ctx context.Context, fn func(ctx context.Context, tx interfaces.Transaction) error) error {
+	return db.TransactionWithOptions(ctx, nil, fn)
+}
+
+// TransactionWithOptions executes a function within a database transaction
+// begun with the isolation level and access mode of opts. Serialization
+// failures and deadlocks are returned as ErrTxConflict and retried as opts
+// allows; nested calls create savepoints and leave retries to the outermost
+// call, as PostgreSQL aborts the whole transaction on a conflict.
+func (db *Database) TransactionWithOptions(ctx context.Context, opts *interfaces.TxOptions, fn func(ctx context.Context, tx interfaces.Transaction) error) error {
+	if outer, ok := ctx.Value(txKey{}).(*Transaction); ok {
+		return db.transaction(ctx, fn, func() (pgx.Tx, error) {
+			return outer.tx.Begin(ctx)
+		})
+	}
+
+	pool, err := db.getPool()
+	if err != nil {
+		return err
+	}
+	return query.RetryConflicts(ctx, opts, func() error {
+		return db.transaction(ctx, fn, func() (pgx.Tx, error) {
+			return pool.BeginTx(ctx, txOptions(opts))
+		})
+	})
+}
+
+// txOptions maps TxOptions to pgx
+func txOptions(opts *interfaces.TxOptions) pgx.TxOptions {
+	var options pgx.TxOptions
+	if opts == nil {
+		return options
+	}
+	switch opts.Isolation {
+	case interfaces.IsolationReadCommitted:
+		options.IsoLevel = pgx.ReadCommitted
+	case interfaces.IsolationRepeatableRead:
+		options.IsoLevel = pgx.RepeatableRead
+	case interfaces.IsolationSerializable:
+		options.IsoLevel = pgx.Serializable
+	}
+	if opts.ReadOnly {
+		options.AccessMode = pgx.ReadOnly
+	}
+	return options
+}
+
+// transaction runs fn in the transaction or savepoint begun by begin
+func (db *Database) transaction(ctx context.Context, fn func(ctx context.Context, tx interfaces.Transaction) error, begin func() (pgx.Tx, error)) error {
+	pgTx, err := begin()
+	if err != nil {
+		return translateError("begin", err)
+	}
+
+	tx := &Transaction{tx: pgTx}
+	txCtx := context.WithValue(ctx, txKey{}, tx)
+
+	defer func() {
+		if !tx.IsCompleted() {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	if err := fn(txCtx, tx); err != nil {
+		tx.Rollback(ctx)
+		return conflictError(err)
+	}
+
+	if tx.IsCompleted() {
+		return nil
+	}
+	return tx.Commit(ctx)
+}
+
+// Repository returns a repository for the given schema
+func (db *Database) Repository(schema *interfaces.Schema) interfaces.Repository {
+	db.registerSchema(schema)
+	return NewRepository(db, schema)
+}
+
+// RegisterHooks adds hooks run around the mutations of the schema's table
+func (db *Database) RegisterHooks(schema *interfaces.Schema, hooks interfaces.Hooks) {
+	db.hooks.Register(schema.TableName, hooks)
+}
+
+// registerSchema records a schema so relations can resolve its table
+func (db *Database) registerSchema(schema *interfaces.Schema) {
+	db.mu.Lock()
+	db.schemas[schema.TableName] = schema
+	db.mu.Unlock()
+}
+
+// relatedRepository resolves Include relations against registered schemas
+func (db *Database) relatedRepository(table string) (interfaces.Repository, error) {
+	db.mu.RLock()
+	schema, exists := db.schemas[table]
+	db.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: no schema registered for table %q", interfaces.ErrInvalidQuery, table)
+	}
+	return NewRepository(db, schema), nil
+}
+
+// Migrate creates tables and indexes for the given schemas, adds columns
+// that were introduced since a table was created and recreates indexes
+// whose definition changed. Schemas must be ordered so that
+// referenced tables come first.
+func (db *Database) Migrate(ctx context.Context, schemas []*interfaces.Schema) error {
+	return db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+		q, err := db.querier(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Change triggers feed Watch
+		if _, err := q.Exec(ctx, notifyFunctionSQL); err != nil {
+			return &interfaces.DatabaseError{Op: "migrate", Err: err}
+		}
+
+		for _, schema := range schemas {
+			statements, err := sqlgen.CreateTableSQL(dialect, schema)
+			if err != nil {
+				return &interfaces.DatabaseError{Op: "migrate", Err: err}
+			}
+			if _, err := q.Exec(ctx, statements[0]); err != nil {
+				return &interfaces.DatabaseError{Op: "migrate " + schema.TableName, Err: err}
+			}
+			if err := addMissingColumns(ctx, q, schema); err != nil {
+				return &interfaces.DatabaseError{Op: "migrate " + schema.TableName, Err: err}
+			}
+			if err := migrateIndexes(ctx, q, schema); err != nil {
+				return &interfaces.DatabaseError{Op: "migrate " + schema.TableName, Err: err}
+			}
+			for _, stmt := range changeTriggerSQL(schema) {
+				if _, err := q.Exec(ctx, stmt); err != nil {
+					return &interfaces.DatabaseError{Op: "migrate " + schema.TableName, Err: err}
+				}
+			}
+			db.registerSchema(schema)
+			log.Printf("Migrated PostgreSQL table: %s", schema.TableName)
+		}
+
+		log.Printf("Migration completed for %d schemas", len(schemas))
+		return nil
+	})
+}
+
+// addMissingColumns adds schema fields absent from an existing table. New
+// columns without a default are added as nullable, as existing rows have no
+// value for them.
+func addMissingColumns(ctx context.Context, q querier, schema *interfaces.Schema) error {
+	rows, err := q.Query(ctx, `SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1`, schema.TableName)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range sqlgen.ColumnNames(schema) {
+		if existing[name] {
+			continue
+		}
+		field := schema.Fields[name]
+		field.PrimaryKey = false
+		if field.DefaultValue == nil {
+			field.Nullable = true
+		}
+
+		def, err := sqlgen.ColumnDefinition(dialect, name, field)
+		if err != nil {
+			return err
+		}
+
+		sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", sqlgen.QuoteIdent(schema.TableName), def)
+		if _, err := q.Exec(ctx, sql); err != nil {
+			return err
+		}
+		log.Printf("Added column %s.%s", schema.TableName, name)
+	}
+	return nil
+}
+
+// indexesSQL lists the column indexes of a table in the current schema,
+// one row per indexed column in index order. Primary keys are skipped.
+const indexesSQL = `SELECT i.relname, ix.indisunique, a.attname
+FROM pg_index ix
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+CROSS JOIN LATERAL unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE t.relname = $1 AND n.nspname = current_schema() AND NOT ix.indisprimary
+ORDER BY i.relname, k.ord`
+
+// existingIndexes returns the column indexes of a table by name
+func existingIndexes(ctx context.Context, q querier, table string) (map[string]interfaces.Index, error) {
+	rows, err := q.Query(ctx, indexesSQL, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]interfaces.Index)
+	for rows.Next() {
+		var name, column string
+		var unique bool
+		if err := rows.Scan(&name, &unique, &column); err != nil {
+			return nil, err
+		}
+		index := existing[name]
+		index.Name, index.Unique = name, unique
+		index.Columns = append(index.Columns, column)
+		existing[name] = index
+	}
+	return existing, rows.Err()
+}
+
+// migrateIndexes creates the indexes of schema that are missing and
+// recreates those whose columns or uniqueness changed
+func migrateIndexes(ctx context.Context, q querier, schema *interfaces.Schema) error {
+	existing, err := existingIndexes(ctx, q, schema.TableName)
+	if err != nil {
+		return err
+	}
+
+	statements, err := sqlgen.MigrateIndexesSQL(schema, existing)
+	if err != nil {
+		return err
+	}
+	for _, sql := range statements {
+		if _, err := q.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnsSQL lists the columns of a table in the current schema with their
+// type and nullability
+const columnsSQL = `SELECT a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull
+FROM pg_attribute a
+JOIN pg_class t ON t.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+WHERE t.relname = $1 AND n.nspname = current_schema() AND t.relkind = 'r'
+	AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum`
+
+// Inspect reads the columns and indexes of the tables of schemas that
+// exist, by table name
+func (db *Database) Inspect(ctx context.Context, schemas []*interfaces.Schema) (map[string]*sqlgen.LiveTable, error) {
+	q, err := db.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make(map[string]*sqlgen.LiveTable)
+	for _, schema := range schemas {
+		columns, err := existingColumns(ctx, q, schema.TableName)
+		if err != nil {
+			return nil, &interfaces.DatabaseError{Op: "inspect " + schema.TableName, Err: err}
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		indexes, err := existingIndexes(ctx, q, schema.TableName)
+		if err != nil {
+			return nil, &interfaces.DatabaseError{Op: "inspect " + schema.TableName, Err: err}
+		}
+		live[schema.TableName] = &sqlgen.LiveTable{Columns: columns, Indexes: indexes}
+	}
+	return live, nil
+}
+
+// existingColumns returns the columns of a table by name, or none if it
+// does not exist
+func existingColumns(ctx context.Context, q querier, table string) (map[string]sqlgen.LiveColumn, error) {
+	rows, err := q.Query(ctx, columnsSQL, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]sqlgen.LiveColumn)
+	for rows.Next() {
+		var name string
+		var column sqlgen.LiveColumn
+		if err := rows.Scan(&name, &column.Type, &column.Nullable); err != nil {
+			return nil, err
+		}
+		columns[name] = column
+	}
+	return columns, rows.Err()
+}
+
+// Plan returns the migration bringing the database in line with schemas,
+// without applying it. Unlike Migrate it also alters the type and
+// nullability of existing columns. See sqlgen.DiffSQL.
+func (db *Database) Plan(ctx context.Context, schemas []*interfaces.Schema) (*sqlgen.Migration, error) {
+	live, err := db.Inspect(ctx, schemas)
+	if err != nil {
+		return nil, err
+	}
+	return sqlgen.DiffSQL(dialect, schemas, live)
+}
+
+// Seed inserts initial data into the database
+func (db *Database) Seed(ctx context.Context, schema *interfaces.Schema, data []map[string]interface{}) error {
+	if _, err := db.getPool(); err != nil {
+		return err
+	}
+
+	result, err := db.Repository(schema).CreateMany(ctx, data, nil)
+	if err != nil {
+		return err
+	}
+
+	// Failed records are logged rather than failing the whole seed
+	for _, rowErr := range result.Errors {
+		log.Printf("Failed to seed record %d in table %s: %v", rowErr.Index, schema.TableName, rowErr.Err)
+	}
+
+	log.Printf("Seeded %d records into table %s", result.Affected, schema.TableName)
+	return nil
+}
+
+// Export writes the records of opts.Schemas to w as newline-delimited JSON
+func (db *Database) Export(ctx context.Context, w io.Writer, opts interfaces.ExportOptions) error {
+	return query.Export(ctx, db, w, opts)
+}
+
+// Import creates the records of an Export stream in one transaction
+func (db *Database) Import(ctx context.Context, r io.Reader, opts interfaces.ImportOptions) error {
+	return query.Import(ctx, db, r, opts)
+}
+
+func (db *Database) getPool() (*pgxpool.Pool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.pool == nil {
+		return nil, interfaces.ErrDatabaseNotConnected
+	}
+	return db.pool, nil
+}
+
+// querier returns the transaction carried by ctx, or the pool
+func (db *Database) querier(ctx context.Context) (querier, error) {
+	if tx, ok := ctx.Value(txKey{}).(*Transaction); ok {
+		if tx.IsCompleted() {
+			return nil, interfaces.ErrTransactionCompleted
+		}
+		return tx.tx, nil
+	}
+	return db.getPool()
+}
+
+// reader returns where read-only Repository calls run: the transaction
+// carried by ctx, the primary if ctx asks for it with
+// interfaces.WithPrimary, else the replicas in turn. Without replicas it is
+// the same as querier.
+func (db *Database) reader(ctx context.Context) (querier, error) {
+	if _, ok := ctx.Value(txKey{}).(*Transaction); ok || interfaces.UsePrimary(ctx) {
+		return db.querier(ctx)
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.pool == nil {
+		return nil, interfaces.ErrDatabaseNotConnected
+	}
+	if len(db.replicas) == 0 {
+		return db.pool, nil
+	}
+	return db.replicas[db.next.Add(1)%uint64(len(db.replicas))], nil
+}
+
+// translateError maps PostgreSQL constraint violations, transaction
+// conflicts and writes in read-only transactions to the shared errors
+func translateError(op string, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return interfaces.ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505": // unique_violation
+			return fmt.Errorf("%w: %s", interfaces.ErrUniqueConstraint, pgErr.ConstraintName)
+		case "23503": // foreign_key_violation
+			return fmt.Errorf("%w: %s", interfaces.ErrForeignKeyConstraint, pgErr.ConstraintName)
+		case "40001", "40P01": // serialization_failure, deadlock_detected
+			return fmt.Errorf("%w: %s", interfaces.ErrTxConflict, pgErr.Message)
+		case "25006": // read_only_sql_transaction
+			return fmt.Errorf("%w: %s", interfaces.ErrReadOnly, pgErr.Message)
+		}
+	}
+
+	return &interfaces.DatabaseError{Op: op, Err: err}
+}
+
+// conflictError marks conflicts the callback of a transaction returned
+// untranslated, so they are retried
+func conflictError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && !errors.Is(err, interfaces.ErrTxConflict) {
+		switch pgErr.Code {
+		case "40001", "40P01":
+			return fmt.Errorf("%w: %w", interfaces.ErrTxConflict, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// dialect renders SQL for PostgreSQL
+var dialect = &sqlgen.Dialect{
+	Name: "postgres",
+	Placeholder: func(n int) string {
+		return "$" + strconv.Itoa(n)
+	},
+	MaxArgs:       65535,
+	ColumnType:    columnType,
+	CanonicalType: canonicalType,
+	Contains: func(col, placeholder string, caseSensitive bool) string {
+		if caseSensitive {
+			return col + " LIKE " + placeholder
+		}
+		return col + " ILIKE " + placeholder
+	},
+	ContainsArg: func(pattern string) string {
+		return "%" + sqlgen.EscapeLike(pattern) + "%"
+	},
+	TruncateTime: func(col, unit string) string {
+		return "date_trunc('" + unit + "', " + col + ", 'UTC')"
+	},
+	JSONPath: func(col, placeholder string) string {
+		return col + " #> CAST(" + placeholder + " AS TEXT[])"
+	},
+	JSONPathArg: func(path []string) (interface{}, error) {
+		return path, nil
+	},
+	JSONValue: func(placeholder string) string {
+		return "CAST(" + placeholder + " AS JSONB)"
+	},
+	JSONContains: func(target, value string) string {
+		return target + " @> " + value
+	},
+	Search: func(col, placeholder string) string {
+		return searchVector(col) + " @@ plainto_tsquery('simple', " + placeholder + ")"
+	},
+	SearchRank: func(col, placeholder string) string {
+		return "ts_rank(" + searchVector(col) + ", plainto_tsquery('simple', " + placeholder + "))"
+	},
+}
+
+// searchVector renders the text search document of col. The 'simple'
+// configuration lower-cases words without stemming, as the other backends do.
+func searchVector(col string) string {
+	return "to_tsvector('simple', coalesce(" + col + ", ''))"
+}
+
+// typeAliases maps the names PostgreSQL reports for column types, and
+// the serial pseudo-types, to the names columnType uses
+var typeAliases = map[string]string{
+	"INT":                      "INTEGER",
+	"INT4":                     "INTEGER",
+	"SERIAL":                   "INTEGER",
+	"INT8":                     "BIGINT",
+	"BIGSERIAL":                "BIGINT",
+	"BOOL":                     "BOOLEAN",
+	"FLOAT8":                   "DOUBLE PRECISION",
+	"TIMESTAMP WITH TIME ZONE": "TIMESTAMPTZ",
+}
+
+// canonicalType spells a column type as columnType does
+func canonicalType(colType string) string {
+	colType = strings.ToUpper(colType)
+	if alias, exists := typeAliases[colType]; exists {
+		return alias
+	}
+	return colType
+}
+
+// columnType maps a schema field type to a PostgreSQL column type
+func columnType(field interfaces.FieldSchema) (string, error) {
+	switch field.Type {
+	case "string":
+		return "TEXT", nil
+	case "int":
+		return "INTEGER", nil
+	case "int64":
+		if field.PrimaryKey && field.DefaultValue == nil {
+			return "BIGSERIAL", nil
+		}
+		return "BIGINT", nil
+	case "bool":
+		return "BOOLEAN", nil
+	case "float64":
+		return "DOUBLE PRECISION", nil
+	case "time":
+		return "TIMESTAMPTZ", nil
+	case "json":
+		return "JSONB", nil
+	default:
+		return "", fmt.Errorf("unsupported field type %q", field.Type)
+	}
+}
@@ -0,0 +1,369 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/dbtest"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+func TestPostgresDatabase(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set, skipping PostgreSQL tests")
+	}
+
+	ctx := context.Background()
+	db := NewDatabase(dsn, Options{})
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Disconnect(ctx)
+
+	// Start from empty tables; the suite expects a fresh database
+	schemas := dbtest.Schemas()
+	for i := len(schemas) - 1; i >= 0; i-- {
+		if _, err := db.pool.Exec(ctx, "DROP TABLE IF EXISTS "+sqlgen.QuoteIdent(schemas[i].TableName)+" CASCADE"); err != nil {
+			t.Fatalf("Failed to drop %s: %v", schemas[i].TableName, err)
+		}
+	}
+	if err := db.Migrate(ctx, schemas); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	dbtest.Run(t, db)
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	statements, err := sqlgen.CreateTableSQL(dialect, entities.PostSchema)
+	if err != nil {
+		t.Fatalf("CreateTableSQL failed: %v", err)
+	}
+
+	want := []string{
+		`CREATE TABLE IF NOT EXISTS "posts" (
+	"id" TEXT PRIMARY KEY,
+	"author_id" TEXT NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+	"content" TEXT NOT NULL,
+	"created_at" TIMESTAMPTZ NOT NULL,
+	"published_at" TIMESTAMPTZ,
+	"title" TEXT NOT NULL,
+	"updated_at" TIMESTAMPTZ NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS "idx_posts_author" ON "posts" ("author_id")`,
+		`CREATE INDEX IF NOT EXISTS "idx_posts_published" ON "posts" ("published_at")`,
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Fatalf("Unexpected DDL:\n%s", strings.Join(statements, ";\n"))
+	}
+
+	statements, err = sqlgen.CreateTableSQL(dialect, entities.UserSchema)
+	if err != nil {
+		t.Fatalf("CreateTableSQL failed: %v", err)
+	}
+	if !strings.Contains(statements[0], `"email" TEXT NOT NULL UNIQUE`) {
+		t.Errorf("Expected unique email column, got:\n%s", statements[0])
+	}
+	if !strings.Contains(statements[0], `"is_active" BOOLEAN NOT NULL DEFAULT TRUE`) {
+		t.Errorf("Expected is_active default, got:\n%s", statements[0])
+	}
+}
+
+func TestPlanSQL(t *testing.T) {
+	live := map[string]*sqlgen.LiveTable{
+		"posts": {
+			Columns: map[string]sqlgen.LiveColumn{
+				"id":           {Type: "text"},
+				"author_id":    {Type: "text"},
+				"content":      {Type: "text", Nullable: true},
+				"created_at":   {Type: "timestamp with time zone"},
+				"published_at": {Type: "timestamp without time zone", Nullable: true},
+				"updated_at":   {Type: "timestamp with time zone"},
+				"legacy":       {Type: "text", Nullable: true},
+			},
+			Indexes: map[string]interfaces.Index{
+				"idx_posts_author": {Name: "idx_posts_author", Columns: []string{"author_id"}},
+			},
+		},
+	}
+
+	// Referenced tables are created first whatever the order given
+	m, err := sqlgen.DiffSQL(dialect, []*interfaces.Schema{entities.PostSchema, entities.UserSchema}, live)
+	if err != nil {
+		t.Fatalf("DiffSQL failed: %v", err)
+	}
+	createUsers, err := sqlgen.CreateTableSQL(dialect, entities.UserSchema)
+	if err != nil {
+		t.Fatalf("CreateTableSQL failed: %v", err)
+	}
+	wantUp := append(createUsers,
+		`ALTER TABLE "posts" ALTER COLUMN "content" SET NOT NULL`,
+		`ALTER TABLE "posts" ALTER COLUMN "published_at" TYPE TIMESTAMPTZ USING CAST("published_at" AS TIMESTAMPTZ)`,
+		`ALTER TABLE "posts" ADD COLUMN "title" TEXT`,
+		`CREATE INDEX IF NOT EXISTS "idx_posts_published" ON "posts" ("published_at")`,
+	)
+	wantDown := []string{
+		`DROP INDEX IF EXISTS "idx_posts_published"`,
+		`ALTER TABLE "posts" DROP COLUMN IF EXISTS "title"`,
+		`ALTER TABLE "posts" ALTER COLUMN "published_at" TYPE TIMESTAMP WITHOUT TIME ZONE USING CAST("published_at" AS TIMESTAMP WITHOUT TIME ZONE)`,
+		`ALTER TABLE "posts" ALTER COLUMN "content" DROP NOT NULL`,
+		`DROP TABLE IF EXISTS "users"`,
+	}
+	if !reflect.DeepEqual(m.Up, wantUp) {
+		t.Errorf("Unexpected up statements:\n%s", strings.Join(m.Up, ";\n"))
+	}
+	if !reflect.DeepEqual(m.Down, wantDown) {
+		t.Errorf("Unexpected down statements:\n%s", strings.Join(m.Down, ";\n"))
+	}
+	wantNotes := []string{
+		"posts.title is added as nullable; set NOT NULL once it is filled",
+		"posts.legacy is not in the schema and is kept",
+	}
+	if !reflect.DeepEqual(m.Notes, wantNotes) {
+		t.Errorf("Unexpected notes: %q", m.Notes)
+	}
+
+	// Catalog spellings of the declared types are not drift
+	live["posts"].Columns["content"] = sqlgen.LiveColumn{Type: "text"}
+	live["posts"].Columns["published_at"] = sqlgen.LiveColumn{Type: "timestamp with time zone", Nullable: true}
+	live["posts"].Columns["title"] = sqlgen.LiveColumn{Type: "text"}
+	live["posts"].Indexes["idx_posts_published"] = interfaces.Index{Name: "idx_posts_published", Columns: []string{"published_at"}}
+	m, err = sqlgen.DiffSQL(dialect, []*interfaces.Schema{entities.PostSchema}, live)
+	if err != nil {
+		t.Fatalf("DiffSQL failed: %v", err)
+	}
+	if !m.Empty() {
+		t.Errorf("Expected no changes, got:\n%s", strings.Join(m.Up, ";\n"))
+	}
+}
+
+func TestTxOptions(t *testing.T) {
+	got := txOptions(&interfaces.TxOptions{Isolation: interfaces.IsolationSerializable, ReadOnly: true})
+	if got.IsoLevel != pgx.Serializable || got.AccessMode != pgx.ReadOnly {
+		t.Errorf("Unexpected options: %+v", got)
+	}
+	if got := txOptions(nil); got != (pgx.TxOptions{}) {
+		t.Errorf("Expected server defaults, got %+v", got)
+	}
+
+	for code, want := range map[string]error{
+		"40001": interfaces.ErrTxConflict,
+		"40P01": interfaces.ErrTxConflict,
+		"25006": interfaces.ErrReadOnly,
+	} {
+		if err := translateError("update", &pgconn.PgError{Code: code}); !errors.Is(err, want) {
+			t.Errorf("Expected %s to map to %v, got %v", code, want, err)
+		}
+	}
+	if err := conflictError(fmt.Errorf("scan: %w", &pgconn.PgError{Code: "40001"})); !errors.Is(err, interfaces.ErrTxConflict) {
+		t.Errorf("Expected untranslated serialization failures to be conflicts, got %v", err)
+	}
+}
+
+func TestWhereSQL(t *testing.T) {
+	caseInsensitive := false
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	b := sqlgen.NewBuilder(dialect, entities.UserSchema)
+	where, err := b.Where(&interfaces.Filters{
+		Conditions: []interfaces.Filter{
+			{Field: "is_active", Value: true},
+			{Field: "age", Operator: &interfaces.FilterOperator{Gte: 18}},
+		},
+		OR: []*interfaces.Filters{
+			{Conditions: []interfaces.Filter{{Field: "name", Operator: &interfaces.FilterOperator{Like: "%al_ce%", CaseSensitive: &caseInsensitive}}}},
+			{Conditions: []interfaces.Filter{{Field: "email", Operator: &interfaces.FilterOperator{In: []interface{}{"a@x.io", "b@x.io"}}}}},
+		},
+		AND: []*interfaces.Filters{
+			{Conditions: []interfaces.Filter{{Field: "created_at", Operator: &interfaces.FilterOperator{Lt: since.Format(time.RFC3339)}}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Where failed: %v", err)
+	}
+
+	wantSQL := `"is_active" = $1 AND "age" >= $2 AND ("created_at" < $3) AND (("name" ILIKE $4) OR ("email" IN ($5, $6)))`
+	if where != wantSQL {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", where, wantSQL)
+	}
+
+	wantArgs := []interface{}{true, 18, since, `%al\_ce%`, "a@x.io", "b@x.io"}
+	if !reflect.DeepEqual(b.Args(), wantArgs) {
+		t.Fatalf("Unexpected args: %#v", b.Args())
+	}
+}
+
+func TestJSONWhereSQL(t *testing.T) {
+	b := sqlgen.NewBuilder(dialect, entities.BridgeReceiptSchema)
+	where, err := b.Where(&interfaces.Filters{
+		Conditions: []interfaces.Filter{
+			{Field: "metadata", Path: []string{"source", "chain"}, Value: "ethereum"},
+			{Field: "metadata", Operator: &interfaces.FilterOperator{Contains: map[string]interface{}{"tags": []string{"l1"}}}},
+			{Field: "metadata", Path: []string{"memo"}, Operator: &interfaces.FilterOperator{IsNull: true}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Where failed: %v", err)
+	}
+
+	wantSQL := `("metadata" #> CAST($1 AS TEXT[])) = CAST($2 AS JSONB)` +
+		` AND "metadata" @> CAST($3 AS JSONB)` +
+		` AND (("metadata" #> CAST($4 AS TEXT[])) IS NULL OR ("metadata" #> CAST($4 AS TEXT[])) = CAST($5 AS JSONB))`
+	if where != wantSQL {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", where, wantSQL)
+	}
+	wantArgs := []interface{}{[]string{"source", "chain"}, `"ethereum"`, `{"tags":["l1"]}`, []string{"memo"}, "null"}
+	if !reflect.DeepEqual(b.Args(), wantArgs) {
+		t.Fatalf("Unexpected args: %#v", b.Args())
+	}
+
+	if _, err := sqlgen.NewBuilder(dialect, entities.BridgeReceiptSchema).Where(&interfaces.Filters{
+		Conditions: []interfaces.Filter{{Field: "metadata", Operator: &interfaces.FilterOperator{Like: "%eth%"}}},
+	}); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Fatalf("Expected ErrInvalidQuery for LIKE on a JSON field, got %v", err)
+	}
+}
+
+func TestReaderRouting(t *testing.T) {
+	ctx := context.Background()
+	// Pools connect lazily, so no server is needed to check routing
+	newPool := func(host string) *pgxpool.Pool {
+		pool, err := pgxpool.New(ctx, "postgres://"+host+"/leafsii")
+		if err != nil {
+			t.Fatalf("Failed to create pool: %v", err)
+		}
+		t.Cleanup(pool.Close)
+		return pool
+	}
+
+	db := NewDatabase("", Options{})
+	if _, err := db.reader(ctx); !errors.Is(err, interfaces.ErrDatabaseNotConnected) {
+		t.Fatalf("Expected ErrDatabaseNotConnected, got %v", err)
+	}
+
+	db.pool = newPool("primary")
+	if q, _ := db.reader(ctx); q != db.pool {
+		t.Fatal("Expected reads to go to the primary without replicas")
+	}
+
+	db.replicas = []*pgxpool.Pool{newPool("replica-a"), newPool("replica-b")}
+	seen := make(map[querier]int)
+	for i := 0; i < 4; i++ {
+		q, err := db.reader(ctx)
+		if err != nil {
+			t.Fatalf("reader failed: %v", err)
+		}
+		seen[q]++
+	}
+	if seen[db.replicas[0]] != 2 || seen[db.replicas[1]] != 2 {
+		t.Fatalf("Expected reads to alternate between replicas, got %v", seen)
+	}
+
+	if q, _ := db.reader(interfaces.WithPrimary(ctx)); q != db.pool {
+		t.Fatal("Expected WithPrimary reads to go to the primary")
+	}
+	if q, _ := db.querier(ctx); q != db.pool {
+		t.Fatal("Expected writes to go to the primary")
+	}
+
+	// Reads inside a transaction use the transaction
+	tx := &Transaction{}
+	if q, _ := db.reader(context.WithValue(ctx, txKey{}, tx)); q != tx.tx {
+		t.Fatal("Expected reads in a transaction to use it")
+	}
+}
+
+func TestSearchSQL(t *testing.T) {
+	b := sqlgen.NewBuilder(dialect, entities.PostSchema)
+	filters := &interfaces.Filters{
+		Conditions: []interfaces.Filter{
+			{Field: "content", Operator: &interfaces.FilterOperator{Search: "bridge fees"}},
+		},
+	}
+	where, err := b.Where(filters)
+	if err != nil {
+		t.Fatalf("Where failed: %v", err)
+	}
+	selectList, orderBy, err := b.Ranked("*", "", filters)
+	if err != nil {
+		t.Fatalf("Ranked failed: %v", err)
+	}
+
+	wantWhere := `to_tsvector('simple', coalesce("content", '')) @@ plainto_tsquery('simple', $1)`
+	if where != wantWhere {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", where, wantWhere)
+	}
+	wantSelect := `*, (ts_rank(to_tsvector('simple', coalesce("content", '')), plainto_tsquery('simple', $2))) AS "_rank"`
+	if selectList != wantSelect {
+		t.Fatalf("Unexpected select list:\n got: %s\nwant: %s", selectList, wantSelect)
+	}
+	if wantOrder := ` ORDER BY "_rank" DESC, "id" ASC`; orderBy != wantOrder {
+		t.Fatalf("Unexpected order:\n got: %s\nwant: %s", orderBy, wantOrder)
+	}
+	if !reflect.DeepEqual(b.Args(), []interface{}{"bridge fees", "bridge fees"}) {
+		t.Fatalf("Unexpected args: %#v", b.Args())
+	}
+
+	// An explicit order is kept
+	if _, orderBy, _ := b.Ranked("*", ` ORDER BY "title" ASC`, filters); orderBy != ` ORDER BY "title" ASC` {
+		t.Fatalf("Expected explicit order to be kept, got %s", orderBy)
+	}
+}
+
+func TestWhereSQLRejectsUnknownFields(t *testing.T) {
+	b := sqlgen.NewBuilder(dialect, entities.UserSchema)
+	_, err := b.Where(&interfaces.Filters{
+		Conditions: []interfaces.Filter{{Field: `name"; DROP TABLE users; --`, Value: "x"}},
+	})
+	if !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Fatalf("Expected ErrInvalidQuery, got %v", err)
+	}
+
+	if _, err := b.OrderBy([]interfaces.OrderBy{{Field: "missing"}}); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Fatalf("Expected ErrInvalidQuery for unknown order field, got %v", err)
+	}
+}
+
+func TestChangeEventFromPayload(t *testing.T) {
+	var change changePayload
+	decoder := json.NewDecoder(strings.NewReader(`{"table":"bridge_receipts","op":"delete","id":"r1","record":{"id":"r1","version":3,"status":"minted","minted":null,"deleted_at":"2024-05-01T12:30:00.123456+00:00"}}`))
+	decoder.UseNumber()
+	if err := decoder.Decode(&change); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+
+	event := changeEvent(entities.BridgeReceiptSchema, change)
+	if event.Op != interfaces.ChangeDelete || event.ID != "r1" {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+	deletedAt := time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	if at, ok := event.Record["deleted_at"].(time.Time); !ok || !at.Equal(deletedAt) {
+		t.Errorf("Expected deleted_at %v, got %#v", deletedAt, event.Record["deleted_at"])
+	}
+	if event.Record["version"] != int64(3) || event.Record["status"] != "minted" || event.Record["minted"] != nil {
+		t.Errorf("Unexpected record: %#v", event.Record)
+	}
+}
+
+func TestChangeTriggerSQL(t *testing.T) {
+	want := []string{
+		`DROP TRIGGER IF EXISTS "bridge_receipts_changes" ON "bridge_receipts"`,
+		`CREATE TRIGGER "bridge_receipts_changes" AFTER INSERT OR UPDATE OR DELETE ON "bridge_receipts" FOR EACH ROW EXECUTE FUNCTION leafsii_notify_change('id', 'soft')`,
+	}
+	if got := changeTriggerSQL(entities.BridgeReceiptSchema); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected trigger SQL:\n%s", strings.Join(got, ";\n"))
+	}
+}
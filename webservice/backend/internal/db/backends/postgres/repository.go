@@ -0,0 +1,511 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// Repository implements the Repository interface for PostgreSQL
+type Repository struct {
+	db      *Database
+	schema  *interfaces.Schema
+	builder *query.Builder
+	table   string
+	pk      string
+}
+
+// NewRepository creates a new PostgreSQL repository
+func NewRepository(db *Database, schema *interfaces.Schema) *Repository {
+	return &Repository{
+		db:      db,
+		schema:  schema,
+		builder: query.NewBuilder(schema),
+		table:   sqlgen.QuoteIdent(schema.TableName),
+		pk:      sqlgen.PrimaryKey(schema),
+	}
+}
+
+// GetByID retrieves a single record by its ID
+func (r *Repository) GetByID(ctx context.Context, id interfaces.ID) (map[string]interface{}, error) {
+	q, err := r.db.reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pkValue, err := r.pkValue(id)
+	if err != nil {
+		return nil, err
+	}
+
+	where := sqlgen.Live(r.schema, sqlgen.QuoteIdent(r.pk)+" = $1")
+	sql := fmt.Sprintf("SELECT * FROM %s WHERE %s", r.table, where)
+	rows, err := q.Query(ctx, sql, pkValue)
+	if err != nil {
+		return nil, translateError("get_by_id", err)
+	}
+	return r.collectOne(rows, "get_by_id")
+}
+
+// FindOne retrieves the first record matching the query
+func (r *Repository) FindOne(ctx context.Context, q *interfaces.Query) (map[string]interface{}, error) {
+	limited := interfaces.Query{}
+	if q != nil {
+		limited = *q
+	}
+
+	// Set limit to 1 for efficiency
+	limit := 1
+	limited.Limit = &limit
+
+	result, err := r.FindMany(ctx, &limited)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, interfaces.ErrNotFound
+	}
+
+	return result.Data[0], nil
+}
+
+// FindMany retrieves multiple records matching the query with pagination
+func (r *Repository) FindMany(ctx context.Context, q *interfaces.Query) (*interfaces.ResultPage, error) {
+	if q == nil {
+		q = &interfaces.Query{}
+	}
+
+	querier, err := r.db.reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pagination, err := query.Paginate(r.schema, q)
+	if err != nil {
+		return nil, err
+	}
+
+	b := sqlgen.NewBuilder(dialect, r.schema)
+	where, err := b.Where(query.LiveFilters(r.schema, q.Where, q.WithDeleted))
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", r.table, where)
+	if err := querier.QueryRow(ctx, countSQL, b.Args()...).Scan(&total); err != nil {
+		return nil, translateError("count", err)
+	}
+
+	// Narrow to the rows after or before a cursor
+	if pagination.Where != q.Where {
+		b = sqlgen.NewBuilder(dialect, r.schema)
+		if where, err = b.Where(query.LiveFilters(r.schema, pagination.Where, q.WithDeleted)); err != nil {
+			return nil, err
+		}
+	}
+
+	selected, added, err := query.IncludeSelect(r.schema, pagination.Select, q.Include)
+	if err != nil {
+		return nil, err
+	}
+	selectList, err := b.SelectList(selected)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, err := b.OrderBy(pagination.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+	if selectList, orderBy, err = b.Ranked(selectList, orderBy, q.Where); err != nil {
+		return nil, err
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE %s%s", selectList, r.table, where, orderBy)
+	if pagination.Limit != nil {
+		sql += " LIMIT " + b.Arg(*pagination.Limit)
+	}
+	if pagination.Offset != nil {
+		sql += " OFFSET " + b.Arg(*pagination.Offset)
+	}
+
+	rows, err := querier.Query(ctx, sql, b.Args()...)
+	if err != nil {
+		return nil, translateError("find_many", err)
+	}
+	records, err := collect(rows, r.schema)
+	if err != nil {
+		return nil, translateError("find_many", err)
+	}
+
+	// Eager load relations
+	if len(q.Include) > 0 {
+		if err := query.LoadIncludes(ctx, r.schema, records, q.Include, r.db.relatedRepository); err != nil {
+			return nil, err
+		}
+		query.StripFields(records, added)
+	}
+	records, next, prev := pagination.Page(records)
+	ranks := query.TakeRanks(records)
+
+	offset := 0
+	if q.Offset != nil {
+		offset = *q.Offset
+	}
+	pageSize := int(total)
+	if q.Limit != nil {
+		pageSize = *q.Limit
+	}
+	page := 1
+	if pageSize > 0 {
+		page = (offset / pageSize) + 1
+	}
+
+	return &interfaces.ResultPage{
+		Data:       records,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		NextCursor: next,
+		PrevCursor: prev,
+		Ranks:      ranks,
+	}, nil
+}
+
+// Create inserts a new record
+func (r *Repository) Create(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	return r.hooked().Create(ctx, data, r.create)
+}
+
+// create inserts data without running hooks
+func (r *Repository) create(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	record, err := r.prepareCreate(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := r.bulk().Insert(ctx, []map[string]interface{}{record})
+	if err != nil {
+		return nil, err
+	}
+	return created[0], nil
+}
+
+// prepareCreate validates data and returns the record to insert, with the
+// primary key, timestamps and defaults filled in
+func (r *Repository) prepareCreate(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	data = query.WithInitialVersion(r.schema, data)
+
+	// Validate data
+	if err := r.builder.ValidateData(data); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	// Prepare record with defaults and timestamps
+	record := make(map[string]interface{})
+	for k, v := range data {
+		record[k] = v
+	}
+
+	// Set ID if not provided; integer keys are generated by the database
+	if _, exists := record[r.pk]; !exists && r.schema.Fields[r.pk].Type == "string" {
+		record[r.pk] = uuid.New().String()
+	}
+
+	// Imports restore the timestamps they carry
+	now := time.Now()
+	for _, field := range []string{"created_at", "updated_at"} {
+		if _, kept := record[field]; !kept || !query.KeepsTimestamps(ctx) {
+			r.setTimestamp(record, field, now)
+		}
+	}
+
+	// Apply default values
+	for fieldName, fieldSchema := range r.schema.Fields {
+		if _, exists := record[fieldName]; !exists && fieldSchema.DefaultValue != nil {
+			record[fieldName] = fieldSchema.DefaultValue
+		}
+	}
+
+	return record, nil
+}
+
+// Update modifies an existing record by ID
+func (r *Repository) Update(ctx context.Context, id interfaces.ID, data map[string]interface{}) (map[string]interface{}, error) {
+	return r.hooked().Update(ctx, id, data, r.update)
+}
+
+// update modifies a record without running hooks
+func (r *Repository) update(ctx context.Context, id interfaces.ID, data map[string]interface{}) (map[string]interface{}, error) {
+	q, err := r.db.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, expected, checkVersion, err := query.ExpectedVersion(r.schema, data)
+	if err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]interface{})
+	for k, v := range data {
+		record[k] = v
+	}
+	r.setTimestamp(record, "updated_at", time.Now())
+	if len(record) == 0 && r.schema.VersionField == "" {
+		return r.GetByID(interfaces.WithPrimary(ctx), id)
+	}
+
+	b := sqlgen.NewBuilder(dialect, r.schema)
+	columns := sqlgen.SortedKeys(record)
+	assignments := make([]string, len(columns))
+	for i, name := range columns {
+		col, err := b.Column(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := b.Value(name, record[name])
+		if err != nil {
+			return nil, err
+		}
+		assignments[i] = col + " = " + b.Arg(value)
+	}
+
+	pkValue, err := r.pkValue(id)
+	if err != nil {
+		return nil, err
+	}
+	where := sqlgen.Live(r.schema, sqlgen.QuoteIdent(r.pk)+" = "+b.Arg(pkValue))
+
+	// Optimistic locking: bump the version, and only match the expected one
+	if field := r.schema.VersionField; field != "" {
+		col := sqlgen.QuoteIdent(field)
+		assignments = append(assignments, col+" = "+col+" + 1")
+		if checkVersion {
+			where += " AND " + col + " = " + b.Arg(expected)
+		}
+	}
+
+	sql := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING *", r.table, strings.Join(assignments, ", "), where)
+	rows, err := q.Query(ctx, sql, b.Args()...)
+	if err != nil {
+		return nil, translateError("update", err)
+	}
+	updated, err := r.collectOne(rows, "update")
+	if errors.Is(err, interfaces.ErrNotFound) && checkVersion {
+		// The row exists but holds another version
+		if _, getErr := r.GetByID(interfaces.WithPrimary(ctx), id); getErr == nil {
+			return nil, interfaces.ErrConflict
+		}
+	}
+	return updated, err
+}
+
+// Upsert inserts or updates based on unique field constraints
+func (r *Repository) Upsert(ctx context.Context, uniqueFields map[string]interface{}, data map[string]interface{}) (map[string]interface{}, error) {
+	// Try to find existing record by unique fields
+	q := &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: make([]interfaces.Filter, 0, len(uniqueFields)),
+		},
+	}
+
+	for field, value := range uniqueFields {
+		q.Where.Conditions = append(q.Where.Conditions, interfaces.Filter{
+			Field: field,
+			Value: value,
+		})
+	}
+
+	existing, err := r.FindOne(interfaces.WithPrimary(ctx), q)
+	if err != nil && err != interfaces.ErrNotFound {
+		return nil, err
+	}
+
+	if existing != nil {
+		// Update existing record
+		return r.Update(ctx, interfaces.StringID(fmt.Sprint(existing[r.pk])), data)
+	}
+
+	// Create new record
+	createData := make(map[string]interface{})
+	for k, v := range data {
+		createData[k] = v
+	}
+	for k, v := range uniqueFields {
+		createData[k] = v
+	}
+
+	return r.Create(ctx, createData)
+}
+
+// Delete removes a record by ID
+func (r *Repository) Delete(ctx context.Context, id interfaces.ID) error {
+	return r.hooked().Delete(ctx, id, r.remove)
+}
+
+// remove deletes a record without running hooks
+func (r *Repository) remove(ctx context.Context, id interfaces.ID) error {
+	pkValue, err := r.pkValue(id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := r.bulk().DeleteIn(ctx, []interface{}{pkValue})
+	if err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return interfaces.ErrNotFound
+	}
+	return nil
+}
+
+// Restore brings back a soft-deleted record by ID. Restoring a record that
+// is not deleted returns it unchanged.
+func (r *Repository) Restore(ctx context.Context, id interfaces.ID) (map[string]interface{}, error) {
+	if err := query.RequireSoftDelete(r.schema); err != nil {
+		return nil, err
+	}
+
+	q, err := r.db.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pkValue, err := r.pkValue(id)
+	if err != nil {
+		return nil, err
+	}
+
+	b := sqlgen.NewBuilder(dialect, r.schema)
+	sql, err := b.Restore(r.pk, pkValue, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Query(ctx, sql, b.Args()...)
+	if err != nil {
+		return nil, translateError("restore", err)
+	}
+	restored, err := r.collectOne(rows, "restore")
+	if errors.Is(err, interfaces.ErrNotFound) {
+		// Not deleted, or not there at all
+		return r.GetByID(interfaces.WithPrimary(ctx), id)
+	}
+	return restored, err
+}
+
+// Count returns the number of records matching the query
+func (r *Repository) Count(ctx context.Context, q *interfaces.Query) (int64, error) {
+	querier, err := r.db.reader(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	b := sqlgen.NewBuilder(dialect, r.schema)
+	if q == nil {
+		q = &interfaces.Query{}
+	}
+	where, err := b.Where(query.LiveFilters(r.schema, q.Where, q.WithDeleted))
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	sql := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", r.table, where)
+	if err := querier.QueryRow(ctx, sql, b.Args()...).Scan(&count); err != nil {
+		return 0, translateError("count", err)
+	}
+	return count, nil
+}
+
+// Aggregate returns one row per group with the group fields and the
+// requested aggregates
+func (r *Repository) Aggregate(ctx context.Context, q *interfaces.AggregateQuery) ([]map[string]interface{}, error) {
+	out, err := query.AggregateSchema(r.schema, q)
+	if err != nil {
+		return nil, err
+	}
+
+	querier, err := r.db.reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b := sqlgen.NewBuilder(dialect, r.schema)
+	stmt, err := b.Aggregate(q, out)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := querier.Query(ctx, stmt, b.Args()...)
+	if err != nil {
+		return nil, translateError("aggregate", err)
+	}
+	records, err := collect(rows, out)
+	if err != nil {
+		return nil, translateError("aggregate", err)
+	}
+	return records, nil
+}
+
+// GetSchema returns the schema for this repository
+func (r *Repository) GetSchema() *interfaces.Schema {
+	return r.schema
+}
+
+// hooked runs mutations with the hooks registered for this table
+func (r *Repository) hooked() query.Hooked {
+	return query.Hooked{
+		Hooks:       r.db.hooks.Hooks(r.schema.TableName),
+		Transaction: r.transaction,
+	}
+}
+
+// pkValue converts an ID to the primary key column type
+func (r *Repository) pkValue(id interfaces.ID) (interface{}, error) {
+	return sqlgen.PKValue(r.schema, id)
+}
+
+// setTimestamp sets a managed timestamp column if the schema declares it
+func (r *Repository) setTimestamp(record map[string]interface{}, field string, now time.Time) {
+	sqlgen.SetTimestamp(r.schema, record, field, now)
+}
+
+// collect reads all rows, converting values to schema's Go types
+func collect(rows pgx.Rows, schema *interfaces.Schema) ([]map[string]interface{}, error) {
+	records, err := pgx.CollectRows(rows, pgx.RowToMap)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		for name, value := range record {
+			record[name] = sqlgen.FromColumnValue(dialect, schema.Fields[name], value)
+		}
+	}
+	if records == nil {
+		records = []map[string]interface{}{}
+	}
+	return records, nil
+}
+
+// collectOne reads a single row, returning ErrNotFound when there is none
+func (r *Repository) collectOne(rows pgx.Rows, op string) (map[string]interface{}, error) {
+	records, err := collect(rows, r.schema)
+	if err != nil {
+		return nil, translateError(op, err)
+	}
+	if len(records) == 0 {
+		return nil, interfaces.ErrNotFound
+	}
+	return records[0], nil
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// Transaction wraps a PostgreSQL transaction or savepoint
+type Transaction struct {
+	mu        sync.Mutex
+	tx        pgx.Tx
+	completed bool
+}
+
+// Commit commits the transaction
+func (tx *Transaction) Commit(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.completed {
+		return interfaces.ErrTransactionCompleted
+	}
+	tx.completed = true
+
+	if err := tx.tx.Commit(ctx); err != nil {
+		return translateError("commit", err)
+	}
+	return nil
+}
+
+// Rollback rolls back the transaction
+func (tx *Transaction) Rollback(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.completed {
+		return interfaces.ErrTransactionCompleted
+	}
+	tx.completed = true
+
+	if err := tx.tx.Rollback(ctx); err != nil {
+		return translateError("rollback", err)
+	}
+	return nil
+}
+
+// IsCompleted returns true if the transaction has been committed or rolled back
+func (tx *Transaction) IsCompleted() bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.completed
+}
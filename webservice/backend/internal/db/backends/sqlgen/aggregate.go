@@ -0,0 +1,103 @@
+package sqlgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// Aggregate renders an aggregate query against the builder's table. out is
+// the schema query.AggregateSchema returned for q. Grouping happens in a
+// subquery so that Having and OrderBy can refer to aggregate aliases in
+// every dialect.
+func (b *Builder) Aggregate(q *interfaces.AggregateQuery, out *interfaces.Schema) (string, error) {
+	where, err := b.Where(query.LiveFilters(b.schema, q.Where, q.WithDeleted))
+	if err != nil {
+		return "", err
+	}
+
+	var columns, groups []string
+	for _, group := range q.GroupBy {
+		expr, err := b.Column(group.Field)
+		if err != nil {
+			return "", err
+		}
+		if group.Truncate != "" {
+			expr = b.dialect.TruncateTime(expr, group.Truncate)
+		}
+		groups = append(groups, expr)
+		columns = append(columns, expr+" AS "+QuoteIdent(group.Field))
+	}
+
+	for _, agg := range q.Aggregates {
+		expr, err := b.aggregate(agg, out.Fields[query.AggregateAlias(agg)])
+		if err != nil {
+			return "", err
+		}
+		columns = append(columns, expr+" AS "+QuoteIdent(query.AggregateAlias(agg)))
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(columns, ", "), QuoteIdent(b.schema.TableName), where)
+	if len(groups) > 0 {
+		stmt += " GROUP BY " + strings.Join(groups, ", ")
+	}
+
+	// Continue argument numbering against the result columns
+	outer := &Builder{dialect: b.dialect, schema: out, args: b.args}
+	having, err := outer.Where(q.Having)
+	if err != nil {
+		return "", err
+	}
+
+	orderBy := q.OrderBy
+	if len(orderBy) == 0 {
+		for _, group := range q.GroupBy {
+			orderBy = append(orderBy, interfaces.OrderBy{Field: group.Field, Direction: "asc"})
+		}
+	}
+	order, err := outer.OrderBy(orderBy)
+	if err != nil {
+		return "", err
+	}
+
+	stmt = fmt.Sprintf("SELECT * FROM (%s) AS %s WHERE %s%s", stmt, QuoteIdent("aggregate"), having, order)
+	if q.Limit != nil {
+		stmt += " LIMIT " + outer.Arg(*q.Limit)
+	}
+	if q.Offset != nil {
+		stmt += " OFFSET " + outer.Arg(*q.Offset)
+	}
+
+	b.args = outer.args
+	return stmt, nil
+}
+
+// aggregate renders one aggregate function, cast to the column type of its
+// result so every dialect returns the same Go types
+func (b *Builder) aggregate(agg interfaces.Aggregate, result interfaces.FieldSchema) (string, error) {
+	arg := "*"
+	if agg.Field != "" {
+		col, err := b.Column(agg.Field)
+		if err != nil {
+			return "", err
+		}
+		arg = col
+	}
+
+	switch agg.Function {
+	case "count":
+		return "COUNT(" + arg + ")", nil
+	case "sum", "avg":
+		colType, err := b.dialect.ColumnType(interfaces.FieldSchema{Type: result.Type})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("CAST(%s(%s) AS %s)", strings.ToUpper(agg.Function), arg, colType), nil
+	case "min", "max":
+		return strings.ToUpper(agg.Function) + "(" + arg + ")", nil
+	default:
+		return "", fmt.Errorf("%w: unsupported aggregate function '%s'", interfaces.ErrInvalidQuery, agg.Function)
+	}
+}
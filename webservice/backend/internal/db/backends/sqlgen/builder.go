@@ -0,0 +1,403 @@
+package sqlgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// Builder accumulates positional arguments while rendering SQL fragments
+// for a single statement
+type Builder struct {
+	dialect *Dialect
+	schema  *interfaces.Schema
+	args    []interface{}
+}
+
+// NewBuilder creates a builder for one statement against schema
+func NewBuilder(dialect *Dialect, schema *interfaces.Schema) *Builder {
+	return &Builder{dialect: dialect, schema: schema}
+}
+
+// Args returns the arguments recorded so far, in placeholder order
+func (b *Builder) Args() []interface{} {
+	return b.args
+}
+
+// Arg records a value and returns its placeholder
+func (b *Builder) Arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return b.dialect.Placeholder(len(b.args))
+}
+
+// Column validates a field name against the schema and returns it quoted.
+// Only schema fields may appear in generated SQL.
+func (b *Builder) Column(field string) (string, error) {
+	if _, exists := b.schema.Fields[field]; !exists {
+		return "", fmt.Errorf("%w: unknown field '%s'", interfaces.ErrInvalidQuery, field)
+	}
+	return QuoteIdent(field), nil
+}
+
+// Value converts an input value to the Go type bound for the field
+func (b *Builder) Value(field string, value interface{}) (interface{}, error) {
+	return ToColumnValue(b.dialect, b.schema.Fields[field], value)
+}
+
+// Where renders filters as a boolean SQL expression
+func (b *Builder) Where(filters *interfaces.Filters) (string, error) {
+	if filters == nil {
+		return "TRUE", nil
+	}
+
+	var parts []string
+	for _, condition := range filters.Conditions {
+		expr, err := b.condition(condition)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, expr)
+	}
+
+	for _, and := range filters.AND {
+		expr, err := b.Where(and)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "("+expr+")")
+	}
+
+	if len(filters.OR) > 0 {
+		var alternatives []string
+		for _, or := range filters.OR {
+			expr, err := b.Where(or)
+			if err != nil {
+				return "", err
+			}
+			alternatives = append(alternatives, "("+expr+")")
+		}
+		parts = append(parts, "("+strings.Join(alternatives, " OR ")+")")
+	}
+
+	if len(parts) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(parts, " AND "), nil
+}
+
+// condition renders a single filter. Operators are checked in the same order
+// as the in-memory backend and only the first one set applies.
+func (b *Builder) condition(filter interfaces.Filter) (string, error) {
+	col, err := b.Column(filter.Field)
+	if err != nil {
+		return "", err
+	}
+
+	if err := query.ValidateOperator(b.schema, filter); err != nil {
+		return "", err
+	}
+	if b.schema.Fields[filter.Field].Type == "json" {
+		return b.jsonCondition(col, filter)
+	}
+
+	op := filter.Operator
+	if op == nil {
+		if filter.Value == nil {
+			return col + " IS NULL", nil
+		}
+		return b.compare(col, "=", filter.Field, filter.Value)
+	}
+
+	switch {
+	case op.IsNull:
+		return col + " IS NULL", nil
+	case op.IsNotNull:
+		return col + " IS NOT NULL", nil
+	case op.Eq != nil:
+		return b.compare(col, "=", filter.Field, op.Eq)
+	case op.Ne != nil:
+		return b.compare(col, "<>", filter.Field, op.Ne)
+	case op.Gt != nil:
+		return b.compare(col, ">", filter.Field, op.Gt)
+	case op.Gte != nil:
+		return b.compare(col, ">=", filter.Field, op.Gte)
+	case op.Lt != nil:
+		return b.compare(col, "<", filter.Field, op.Lt)
+	case op.Lte != nil:
+		return b.compare(col, "<=", filter.Field, op.Lte)
+	case len(op.In) > 0:
+		return b.list(col, "IN", filter.Field, op.In)
+	case len(op.NotIn) > 0:
+		return b.list(col, "NOT IN", filter.Field, op.NotIn)
+	case op.Like != "":
+		return b.contains(col, op.Like, op), nil
+	case op.NotLike != "":
+		return fmt.Sprintf("(%s IS NULL OR NOT %s)", col, b.contains(col, op.NotLike, op)), nil
+	case op.Search != "":
+		return b.dialect.Search(col, b.Arg(op.Search)), nil
+	default:
+		return "TRUE", nil
+	}
+}
+
+// Rank renders the search rank of a row for the Search conditions of
+// filters, summed, or "" if there are none. Its arguments are bound after
+// those of Where.
+func (b *Builder) Rank(filters *interfaces.Filters) (string, error) {
+	var ranks []string
+	var collect func(filters *interfaces.Filters) error
+	collect = func(filters *interfaces.Filters) error {
+		if filters == nil {
+			return nil
+		}
+		for _, filter := range filters.Conditions {
+			if filter.Operator == nil || filter.Operator.Search == "" {
+				continue
+			}
+			col, err := b.Column(filter.Field)
+			if err != nil {
+				return err
+			}
+			ranks = append(ranks, b.dialect.SearchRank(col, b.Arg(filter.Operator.Search)))
+		}
+		for _, nested := range append(append([]*interfaces.Filters{}, filters.AND...), filters.OR...) {
+			if err := collect(nested); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := collect(filters); err != nil {
+		return "", err
+	}
+	if len(ranks) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(ranks, " + ") + ")", nil
+}
+
+// Ranked adds the search rank of filters to selectList as query.RankField
+// and, when orderBy is empty, orders by it, best match first. Without
+// Search conditions both are returned unchanged.
+func (b *Builder) Ranked(selectList, orderBy string, filters *interfaces.Filters) (string, string, error) {
+	rank, err := b.Rank(filters)
+	if err != nil || rank == "" {
+		return selectList, orderBy, err
+	}
+	selectList += ", " + rank + " AS " + QuoteIdent(query.RankField)
+	if orderBy == "" {
+		orderBy = " ORDER BY " + QuoteIdent(query.RankField) + " DESC, " + QuoteIdent(PrimaryKey(b.schema)) + " ASC"
+	}
+	return selectList, orderBy, nil
+}
+
+func (b *Builder) compare(col, operator, field string, value interface{}) (string, error) {
+	v, err := b.Value(field, value)
+	if err != nil {
+		return "", err
+	}
+	return col + " " + operator + " " + b.Arg(v), nil
+}
+
+func (b *Builder) list(col, operator, field string, values []interface{}) (string, error) {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		v, err := b.Value(field, value)
+		if err != nil {
+			return "", err
+		}
+		placeholders[i] = b.Arg(v)
+	}
+	return col + " " + operator + " (" + strings.Join(placeholders, ", ") + ")", nil
+}
+
+// jsonCondition renders a filter on a json column, comparing JSON values
+// the way the in-memory backend does: a missing path reads as null, and Ne
+// matches nulls
+func (b *Builder) jsonCondition(col string, filter interfaces.Filter) (string, error) {
+	target := col
+	if len(filter.Path) > 0 {
+		path, err := b.dialect.JSONPathArg(filter.Path)
+		if err != nil {
+			return "", err
+		}
+		target = "(" + b.dialect.JSONPath(col, b.Arg(path)) + ")"
+	}
+	isNull := func() string {
+		if len(filter.Path) == 0 {
+			return target + " IS NULL"
+		}
+		// Also JSON null inside the document
+		return "(" + target + " IS NULL OR " + target + " = " + b.dialect.JSONValue(b.Arg("null")) + ")"
+	}
+
+	value := func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", interfaces.ErrInvalidQuery, err)
+		}
+		return b.dialect.JSONValue(b.Arg(string(data))), nil
+	}
+	compare := func(operator string, v interface{}) (string, error) {
+		arg, err := value(v)
+		if err != nil {
+			return "", err
+		}
+		return target + " " + operator + " " + arg, nil
+	}
+
+	op := filter.Operator
+	switch {
+	case op == nil && filter.Value == nil, op != nil && op.IsNull:
+		return isNull(), nil
+	case op == nil:
+		return compare("=", filter.Value)
+	case op.IsNotNull:
+		return "NOT (" + isNull() + ")", nil
+	case op.Eq != nil:
+		return compare("=", op.Eq)
+	case op.Ne != nil:
+		ne, err := compare("<>", op.Ne)
+		if err != nil {
+			return "", err
+		}
+		return "(" + isNull() + " OR " + ne + ")", nil
+	case op.Contains != nil:
+		arg, err := value(op.Contains)
+		if err != nil {
+			return "", err
+		}
+		return b.dialect.JSONContains(target, arg), nil
+	default:
+		return "TRUE", nil
+	}
+}
+
+// contains renders a substring match. The in-memory backend ignores '%' and
+// matches everything else literally, case sensitive unless CaseSensitive is
+// explicitly false.
+func (b *Builder) contains(col, pattern string, op *interfaces.FilterOperator) string {
+	caseSensitive := op.CaseSensitive == nil || *op.CaseSensitive
+	arg := b.dialect.ContainsArg(strings.ReplaceAll(pattern, "%", ""))
+	return b.dialect.Contains(col, b.Arg(arg), caseSensitive)
+}
+
+// OrderBy renders an ORDER BY clause, or an empty string
+func (b *Builder) OrderBy(orders []interfaces.OrderBy) (string, error) {
+	if len(orders) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(orders))
+	for i, order := range orders {
+		col, err := b.Column(order.Field)
+		if err != nil {
+			return "", err
+		}
+		direction := "ASC"
+		if strings.EqualFold(order.Direction, "desc") {
+			direction = "DESC"
+		}
+		parts[i] = col + " " + direction
+	}
+	return " ORDER BY " + strings.Join(parts, ", "), nil
+}
+
+// SelectList renders the projected columns, or * when none are requested
+func (b *Builder) SelectList(fields []string) (string, error) {
+	if len(fields) == 0 {
+		return "*", nil
+	}
+	cols := make([]string, len(fields))
+	for i, field := range fields {
+		col, err := b.Column(field)
+		if err != nil {
+			return "", err
+		}
+		cols[i] = col
+	}
+	return strings.Join(cols, ", "), nil
+}
+
+// ToColumnValue converts an input value to the type bound for the column.
+// Time fields accept RFC 3339 strings, matching query.Builder validation,
+// and json fields are bound as JSON text.
+func ToColumnValue(dialect *Dialect, field interfaces.FieldSchema, value interface{}) (interface{}, error) {
+	if value != nil && field.Type == "json" {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid JSON value: %v", interfaces.ErrInvalidQuery, err)
+		}
+		if string(data) == "null" {
+			// Nil maps and slices are stored as NULL, as in memory
+			return nil, nil
+		}
+		return string(data), nil
+	}
+	if value == nil || field.Type != "time" {
+		return value, nil
+	}
+
+	t, ok := value.(time.Time)
+	if s, isString := value.(string); isString {
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid time %q", interfaces.ErrInvalidQuery, s)
+		}
+		t, ok = parsed, true
+	}
+	if !ok {
+		return value, nil
+	}
+
+	if dialect.TimeValue != nil {
+		return dialect.TimeValue(t), nil
+	}
+	return t, nil
+}
+
+// FromColumnValue converts a scanned value to the Go type the schema
+// declares, so records look the same as those of the in-memory backend
+func FromColumnValue(dialect *Dialect, field interfaces.FieldSchema, value interface{}) interface{} {
+	switch field.Type {
+	case "int":
+		switch v := value.(type) {
+		case int32:
+			return int(v)
+		case int64:
+			return int(v)
+		}
+	case "int64":
+		switch v := value.(type) {
+		case int32:
+			return int64(v)
+		}
+	case "float64":
+		switch v := value.(type) {
+		case float32:
+			return float64(v)
+		}
+	case "bool":
+		switch v := value.(type) {
+		case int64:
+			return v != 0
+		}
+	case "json":
+		if s, ok := value.(string); ok && dialect.JSONText {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(s), &decoded); err == nil {
+				return decoded
+			}
+		}
+	case "time":
+		if s, ok := value.(string); ok && dialect.ParseTime != nil {
+			if t, err := dialect.ParseTime(s); err == nil {
+				return t
+			}
+		}
+	}
+	return value
+}
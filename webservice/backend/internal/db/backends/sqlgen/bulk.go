@@ -0,0 +1,330 @@
+package sqlgen
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// Querier runs a statement rendered by a Builder and returns the rows it
+// returns as records of the table, with driver errors translated. Each
+// backend implements it over its driver; op names the write, for backends
+// that publish changes themselves.
+type Querier interface {
+	QueryRecords(ctx context.Context, op interfaces.ChangeOp, stmt string, args []interface{}) ([]map[string]interface{}, error)
+}
+
+// QuerierFunc adapts a function to a Querier
+type QuerierFunc func(ctx context.Context, op interfaces.ChangeOp, stmt string, args []interface{}) ([]map[string]interface{}, error)
+
+// QueryRecords calls f
+func (f QuerierFunc) QueryRecords(ctx context.Context, op interfaces.ChangeOp, stmt string, args []interface{}) ([]map[string]interface{}, error) {
+	return f(ctx, op, stmt, args)
+}
+
+// Bulk implements the bulk writes of the repositories of SQL backends,
+// with one multi-row statement per batch run by Querier. Rows rejected by
+// validation or a constraint are reported in the result and the other
+// rows are still written.
+type Bulk struct {
+	Dialect *Dialect
+	Schema  *interfaces.Schema
+	Querier Querier
+
+	// Repository writes rows one by one, when hooks are registered or
+	// to find the rows that failed a batch
+	Repository interfaces.Repository
+	Hooked     query.Hooked
+
+	// Prepare returns the record a create of data inserts, with the
+	// primary key, timestamps and defaults filled in
+	Prepare func(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error)
+}
+
+// CreateMany inserts records with one multi-row INSERT per batch
+func (b *Bulk) CreateMany(ctx context.Context, data []map[string]interface{}, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	if b.Hooked.OnCreate() {
+		// Every row has to pass through the hooks
+		return query.CreateEach(ctx, b.Repository, data)
+	}
+
+	result := &interfaces.BulkResult{Records: make([]map[string]interface{}, len(data))}
+	records := make([]map[string]interface{}, len(data))
+	var indexes []int
+	for i, row := range data {
+		record, err := b.Prepare(ctx, row)
+		if err != nil {
+			result.Errors = append(result.Errors, interfaces.RowError{Index: i, Err: err})
+			continue
+		}
+		records[i] = record
+		indexes = append(indexes, i)
+	}
+
+	writer := &query.BulkWriter{
+		Transaction: b.Hooked.Transaction,
+		Batch: func(ctx context.Context, batch []int) (map[int]map[string]interface{}, error) {
+			written := make(map[int]map[string]interface{}, len(batch))
+			err := b.Dialect.Statements(records, batch, func(part []int) error {
+				created, err := b.Insert(ctx, pick(records, part))
+				if err != nil {
+					return err
+				}
+				for j, index := range part {
+					written[index] = created[j]
+				}
+				return nil
+			})
+			return written, err
+		},
+		Single: func(ctx context.Context, index int) (map[string]interface{}, error) {
+			created, err := b.Insert(ctx, []map[string]interface{}{records[index]})
+			if err != nil {
+				return nil, err
+			}
+			return created[0], nil
+		},
+	}
+	if err := writer.Run(ctx, result, indexes, opts.GetBatchSize()); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateMany updates records by ID with one UPDATE ... FROM (VALUES ...)
+// per batch. Unknown IDs and stale versions are reported in the result.
+func (b *Bulk) UpdateMany(ctx context.Context, updates []interfaces.BulkUpdate, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	if b.Hooked.OnUpdate() {
+		// Every row has to pass through the hooks
+		return query.UpdateEach(ctx, b.Repository, updates)
+	}
+
+	pk := PrimaryKey(b.Schema)
+	result := &interfaces.BulkResult{Records: make([]map[string]interface{}, len(updates))}
+	records := make([]map[string]interface{}, len(updates))
+	keys := make(map[string]int)
+	var indexes []int
+	for i, update := range updates {
+		record, err := b.prepareUpdate(update)
+		if err == nil {
+			if _, duplicate := keys[fmt.Sprint(record[pk])]; duplicate {
+				err = fmt.Errorf("%w: duplicate id %s", interfaces.ErrInvalidQuery, update.ID.String())
+			}
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, interfaces.RowError{Index: i, Err: err})
+			continue
+		}
+		keys[fmt.Sprint(record[pk])] = i
+		records[i] = record
+		indexes = append(indexes, i)
+	}
+
+	writer := &query.BulkWriter{
+		Transaction: b.Hooked.Transaction,
+		Batch: func(ctx context.Context, batch []int) (map[int]map[string]interface{}, error) {
+			written := make(map[int]map[string]interface{}, len(batch))
+			err := b.Dialect.Statements(records, batch, func(part []int) error {
+				updated, err := b.UpdateFrom(ctx, pick(records, part))
+				if err != nil {
+					return err
+				}
+				for _, record := range updated {
+					written[keys[fmt.Sprint(record[pk])]] = record
+				}
+				return nil
+			})
+			return written, err
+		},
+		Single: func(ctx context.Context, index int) (map[string]interface{}, error) {
+			return b.Repository.Update(ctx, updates[index].ID, updates[index].Data)
+		},
+		Missing: func(ctx context.Context, index int) error {
+			// A row that exists was skipped because its version moved on
+			if _, versioned := records[index][b.Schema.VersionField]; versioned {
+				if _, err := b.Repository.GetByID(interfaces.WithPrimary(ctx), updates[index].ID); err == nil {
+					return interfaces.ErrConflict
+				}
+			}
+			return interfaces.ErrNotFound
+		},
+	}
+	if err := writer.Run(ctx, result, indexes, opts.GetBatchSize()); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteMany deletes records by ID with one DELETE per batch. Unknown IDs
+// are reported in the result.
+func (b *Bulk) DeleteMany(ctx context.Context, ids []interfaces.ID, opts *interfaces.BulkOptions) (*interfaces.BulkResult, error) {
+	if b.Hooked.OnDelete() {
+		// Every row has to pass through the hooks
+		return query.DeleteEach(ctx, b.Repository, ids)
+	}
+
+	result := &interfaces.BulkResult{}
+	values := make([]interface{}, len(ids))
+	var indexes []int
+	for i, id := range ids {
+		value, err := PKValue(b.Schema, id)
+		if err != nil {
+			result.Errors = append(result.Errors, interfaces.RowError{Index: i, Err: err})
+			continue
+		}
+		values[i] = value
+		indexes = append(indexes, i)
+	}
+
+	batchSize := b.Dialect.RowsPerStatement(1, opts.GetBatchSize())
+	writer := &query.BulkWriter{
+		Transaction: b.Hooked.Transaction,
+		Batch: func(ctx context.Context, batch []int) (map[int]map[string]interface{}, error) {
+			// Repeated IDs match one row, which is credited to the first
+			positions := make(map[string]int, len(batch))
+			keys := make([]interface{}, 0, len(batch))
+			for _, index := range batch {
+				key := fmt.Sprint(values[index])
+				if _, seen := positions[key]; !seen {
+					positions[key] = index
+					keys = append(keys, values[index])
+				}
+			}
+
+			deleted, err := b.DeleteIn(ctx, keys)
+			if err != nil {
+				return nil, err
+			}
+			written := make(map[int]map[string]interface{}, len(deleted))
+			for _, key := range deleted {
+				written[positions[fmt.Sprint(key)]] = nil
+			}
+			return written, nil
+		},
+		Single: func(ctx context.Context, index int) (map[string]interface{}, error) {
+			return nil, b.Repository.Delete(ctx, ids[index])
+		},
+	}
+	if err := writer.Run(ctx, result, indexes, batchSize); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Insert writes records that share the same keys in one statement and
+// returns them as stored, in input order
+func (b *Bulk) Insert(ctx context.Context, records []map[string]interface{}) ([]map[string]interface{}, error) {
+	builder := NewBuilder(b.Dialect, b.Schema)
+	stmt, err := builder.Insert(records)
+	if err != nil {
+		return nil, err
+	}
+	return b.Querier.QueryRecords(ctx, interfaces.ChangeCreate, stmt, builder.Args())
+}
+
+// UpdateFrom updates rows that share the same keys in one statement and
+// returns the updated records
+func (b *Bulk) UpdateFrom(ctx context.Context, records []map[string]interface{}) ([]map[string]interface{}, error) {
+	builder := NewBuilder(b.Dialect, b.Schema)
+	stmt, err := builder.UpdateFrom(PrimaryKey(b.Schema), records)
+	if err != nil {
+		return nil, err
+	}
+	return b.Querier.QueryRecords(ctx, interfaces.ChangeUpdate, stmt, builder.Args())
+}
+
+// DeleteIn deletes the rows with the given keys, or marks them deleted if
+// the schema soft deletes, and returns the keys of the rows that existed
+func (b *Bulk) DeleteIn(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+	pk := PrimaryKey(b.Schema)
+	builder := NewBuilder(b.Dialect, b.Schema)
+	var stmt string
+	var err error
+	if b.Schema.SoftDelete {
+		stmt, err = builder.SoftDeleteIn(pk, keys, time.Now())
+	} else {
+		stmt, err = builder.DeleteIn(pk, keys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := b.Querier.QueryRecords(ctx, interfaces.ChangeDelete, stmt, builder.Args())
+	if err != nil {
+		return nil, err
+	}
+
+	existed := make([]interface{}, len(deleted))
+	for i, record := range deleted {
+		existed[i] = record[pk]
+	}
+	return existed, nil
+}
+
+// prepareUpdate returns the row UpdateMany writes for update: its new
+// values, the refreshed updated_at, the primary key and the expected
+// version, if any
+func (b *Bulk) prepareUpdate(update interfaces.BulkUpdate) (map[string]interface{}, error) {
+	pk := PrimaryKey(b.Schema)
+	pkValue, err := PKValue(b.Schema, update.ID)
+	if err != nil {
+		return nil, err
+	}
+	data, expected, checkVersion, err := query.ExpectedVersion(b.Schema, update.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]interface{}, len(data)+3)
+	for k, v := range data {
+		if _, exists := b.Schema.Fields[k]; !exists {
+			return nil, fmt.Errorf("%w: unknown field '%s'", interfaces.ErrInvalidQuery, k)
+		}
+		if k == pk {
+			return nil, fmt.Errorf("%w: cannot change the primary key", interfaces.ErrInvalidQuery)
+		}
+		record[k] = v
+	}
+	SetTimestamp(b.Schema, record, "updated_at", time.Now())
+	if len(record) == 0 && b.Schema.VersionField == "" {
+		return nil, fmt.Errorf("%w: no fields to update", interfaces.ErrInvalidQuery)
+	}
+	record[pk] = pkValue
+	if checkVersion {
+		record[b.Schema.VersionField] = query.VersionValue(b.Schema, expected)
+	}
+	return record, nil
+}
+
+// PKValue converts an ID to the primary key column type of schema
+func PKValue(schema *interfaces.Schema, id interfaces.ID) (interface{}, error) {
+	switch schema.Fields[PrimaryKey(schema)].Type {
+	case "int", "int64":
+		n, err := strconv.ParseInt(id.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid id %q", interfaces.ErrInvalidQuery, id.String())
+		}
+		return n, nil
+	default:
+		return id.String(), nil
+	}
+}
+
+// SetTimestamp sets a managed timestamp column if schema declares it
+func SetTimestamp(schema *interfaces.Schema, record map[string]interface{}, field string, now time.Time) {
+	if _, exists := schema.Fields[field]; exists {
+		record[field] = now
+	}
+}
+
+// pick returns the records at indexes
+func pick(records []map[string]interface{}, indexes []int) []map[string]interface{} {
+	picked := make([]map[string]interface{}, len(indexes))
+	for i, index := range indexes {
+		picked[i] = records[index]
+	}
+	return picked
+}
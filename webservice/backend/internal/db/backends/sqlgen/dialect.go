@@ -0,0 +1,93 @@
+// Package sqlgen renders interfaces.Schema and interfaces.Query values as SQL
+// for the SQL database backends. Dialect captures what differs between
+// engines; everything else is shared so backends filter, sort and migrate
+// identically.
+package sqlgen
+
+import (
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// Dialect describes the engine-specific parts of generated SQL
+type Dialect struct {
+	// Name identifies the dialect in errors
+	Name string
+
+	// Placeholder returns the placeholder for the n-th argument, starting at 1
+	Placeholder func(n int) string
+
+	// MaxArgs is the most arguments one statement may bind. Zero means no
+	// limit.
+	MaxArgs int
+
+	// ColumnType maps a schema field to a column type
+	ColumnType func(field interfaces.FieldSchema) (string, error)
+
+	// CanonicalType spells a column type, as given by ColumnType or read
+	// from the catalog, so that equal types compare equal. Nil upper-cases
+	// it.
+	CanonicalType func(colType string) string
+
+	// Contains renders a substring match of col against the argument at
+	// placeholder. The argument is produced by ContainsArg.
+	Contains func(col, placeholder string, caseSensitive bool) string
+
+	// ContainsArg turns a filter pattern, with '%' already stripped, into
+	// the argument bound for Contains
+	ContainsArg func(pattern string) string
+
+	// TruncateTime renders col truncated in UTC to the start of its "hour",
+	// "day" or "month", as a value of the column's own type
+	TruncateTime func(col, unit string) string
+
+	// TimeValue converts a time before it is bound. Nil binds time.Time as is.
+	TimeValue func(t time.Time) interface{}
+
+	// ParseTime parses times the driver returns as strings. Nil leaves
+	// strings untouched.
+	ParseTime func(s string) (time.Time, error)
+
+	// JSONPath renders the JSON value inside the json column col at the
+	// path bound at placeholder, or NULL where the path does not exist
+	JSONPath func(col, placeholder string) string
+
+	// JSONPathArg turns object keys into the argument bound for JSONPath
+	JSONPathArg func(path []string) (interface{}, error)
+
+	// JSONValue renders the JSON text bound at placeholder as a value that
+	// compares with json columns and JSONPath
+	JSONValue func(placeholder string) string
+
+	// JSONContains renders whether the JSON value target contains the JSON
+	// value value, as the PostgreSQL @> operator
+	JSONContains func(target, value string) string
+
+	// Search renders whether the text column col contains every word of the
+	// search query bound at placeholder
+	Search func(col, placeholder string) string
+
+	// SearchRank renders how well col matches the search query bound at
+	// placeholder, as a number that is higher for better matches
+	SearchRank func(col, placeholder string) string
+
+	// JSONText is set when the driver returns json columns as JSON text,
+	// which FromColumnValue then decodes
+	JSONText bool
+}
+
+// QuoteIdent quotes a table or column name
+func QuoteIdent(name string) string {
+	name = strings.ReplaceAll(name, "\x00", "")
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// EscapeLike escapes LIKE wildcards so pattern matches literally, using
+// backslash as the escape character
+func EscapeLike(pattern string) string {
+	pattern = strings.ReplaceAll(pattern, `\`, `\\`)
+	pattern = strings.ReplaceAll(pattern, "%", `\%`)
+	return strings.ReplaceAll(pattern, "_", `\_`)
+}
@@ -0,0 +1,171 @@
+package sqlgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// LiveColumn describes a column of an existing table
+type LiveColumn struct {
+	Type     string
+	Nullable bool
+}
+
+// LiveTable describes an existing table as read from the database catalog
+type LiveTable struct {
+	Columns map[string]LiveColumn
+	Indexes map[string]interfaces.Index
+}
+
+// Migration holds the statements bringing a database in line with a set of
+// schemas and back
+type Migration struct {
+	Up   []string
+	Down []string
+
+	// Notes explain drift the statements leave alone
+	Notes []string
+}
+
+// Empty reports whether the database already matches the schemas
+func (m *Migration) Empty() bool {
+	return len(m.Up) == 0
+}
+
+// DiffSQL returns the migration bringing the live tables, by name, in line
+// with schemas. Missing tables, columns and indexes are created; column
+// types, nullability and changed indexes are altered. Down undoes Up in
+// reverse order.
+//
+// Defaults, foreign keys and UNIQUE columns are only set when their table
+// or column is created. Columns and indexes the schemas do not declare are
+// never dropped, as they may hold data or have been added by hand; extra
+// columns are listed in Notes. Type changes use ALTER COLUMN, which SQLite
+// does not support.
+func DiffSQL(dialect *Dialect, schemas []*interfaces.Schema, live map[string]*LiveTable) (*Migration, error) {
+	schemas, err := query.ReferentialOrder(schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migration{}
+	var downs [][]string
+	change := func(up, down []string) {
+		m.Up = append(m.Up, up...)
+		downs = append(downs, down)
+	}
+
+	for _, schema := range schemas {
+		table := QuoteIdent(schema.TableName)
+		current, exists := live[schema.TableName]
+		if !exists {
+			statements, err := CreateTableSQL(dialect, schema)
+			if err != nil {
+				return nil, err
+			}
+			change(statements, []string{"DROP TABLE IF EXISTS " + table})
+			continue
+		}
+
+		for _, name := range ColumnNames(schema) {
+			field := schema.Fields[name]
+			col := QuoteIdent(name)
+			colType, err := dialect.ColumnType(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", schema.TableName, name, err)
+			}
+
+			column, exists := current.Columns[name]
+			if !exists {
+				// As in Migrate, existing rows have no value for the column
+				field.PrimaryKey = false
+				if field.DefaultValue == nil && !field.Nullable {
+					field.Nullable = true
+					m.Notes = append(m.Notes, fmt.Sprintf("%s.%s is added as nullable; set NOT NULL once it is filled", schema.TableName, name))
+				}
+				def, err := ColumnDefinition(dialect, name, field)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", schema.TableName, name, err)
+				}
+				change(
+					[]string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, def)},
+					[]string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", table, col)},
+				)
+				continue
+			}
+
+			want, have := canonicalType(dialect, colType), canonicalType(dialect, column.Type)
+			if want != have {
+				change(
+					[]string{alterTypeSQL(table, col, want)},
+					[]string{alterTypeSQL(table, col, have)},
+				)
+			}
+			nullable := field.Nullable && !field.PrimaryKey
+			if nullable != column.Nullable {
+				change(
+					[]string{alterNullSQL(table, col, nullable)},
+					[]string{alterNullSQL(table, col, column.Nullable)},
+				)
+			}
+		}
+
+		var extra []string
+		for name := range current.Columns {
+			if _, declared := schema.Fields[name]; !declared {
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+		for _, name := range extra {
+			m.Notes = append(m.Notes, fmt.Sprintf("%s.%s is not in the schema and is kept", schema.TableName, name))
+		}
+
+		if err := query.ValidateIndexes(schema); err != nil {
+			return nil, err
+		}
+		for _, index := range schema.Indexes {
+			drop := "DROP INDEX IF EXISTS " + QuoteIdent(index.Name)
+			existing, exists := current.Indexes[index.Name]
+			switch {
+			case !exists:
+				change([]string{CreateIndexSQL(schema, index)}, []string{drop})
+			case !query.EqualIndexes(existing, index):
+				change(
+					[]string{drop, CreateIndexSQL(schema, index)},
+					[]string{drop, CreateIndexSQL(schema, existing)},
+				)
+			}
+		}
+	}
+
+	for i := len(downs) - 1; i >= 0; i-- {
+		m.Down = append(m.Down, downs[i]...)
+	}
+	return m, nil
+}
+
+// canonicalType spells colType as the dialect compares column types
+func canonicalType(dialect *Dialect, colType string) string {
+	if dialect.CanonicalType != nil {
+		return dialect.CanonicalType(colType)
+	}
+	return strings.ToUpper(colType)
+}
+
+// alterTypeSQL changes the type of col, converting existing values
+func alterTypeSQL(table, col, colType string) string {
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING CAST(%s AS %s)", table, col, colType, col, colType)
+}
+
+// alterNullSQL allows or forbids NULL in col
+func alterNullSQL(table, col string, nullable bool) string {
+	if nullable {
+		return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", table, col)
+	}
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", table, col)
+}
@@ -0,0 +1,184 @@
+package sqlgen
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// RowsPerStatement returns how many rows of the given width fit in one
+// statement without exceeding the dialect's argument limit, capped at limit
+func (d *Dialect) RowsPerStatement(columns, limit int) int {
+	if d.MaxArgs <= 0 || columns <= 0 {
+		return limit
+	}
+	return max(1, min(limit, d.MaxArgs/columns))
+}
+
+// Statements splits the records at indexes into the parts written by one
+// multi-row statement each, grouping rows with identical keys and keeping
+// within MaxArgs, and calls write for every part
+func (d *Dialect) Statements(records []map[string]interface{}, indexes []int, write func(part []int) error) error {
+	for _, group := range GroupRows(records, indexes) {
+		size := d.RowsPerStatement(len(records[group[0]]), len(group))
+		for start := 0; start < len(group); start += size {
+			if err := write(group[start:min(start+size, len(group))]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SortedKeys returns the keys of a record in alphabetical order
+func SortedKeys(record map[string]interface{}) []string {
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GroupRows splits the records at indexes into groups with identical keys,
+// as multi-row statements need, keeping first-seen order
+func GroupRows(records []map[string]interface{}, indexes []int) [][]int {
+	var groups [][]int
+	position := make(map[string]int)
+	for _, index := range indexes {
+		signature := strings.Join(SortedKeys(records[index]), "\x00")
+		i, exists := position[signature]
+		if !exists {
+			i = len(groups)
+			position[signature] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], index)
+	}
+	return groups
+}
+
+// Insert renders a multi-row INSERT returning the inserted rows in input
+// order. All records must have the same keys.
+func (b *Builder) Insert(records []map[string]interface{}) (string, error) {
+	columns := SortedKeys(records[0])
+	cols := make([]string, len(columns))
+	for i, name := range columns {
+		col, err := b.Column(name)
+		if err != nil {
+			return "", err
+		}
+		cols[i] = col
+	}
+
+	tuples := make([]string, len(records))
+	for r, record := range records {
+		placeholders := make([]string, len(columns))
+		for i, name := range columns {
+			value, err := b.Value(name, record[name])
+			if err != nil {
+				return "", err
+			}
+			placeholders[i] = b.Arg(value)
+		}
+		tuples[r] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s RETURNING *",
+		QuoteIdent(b.schema.TableName), strings.Join(cols, ", "), strings.Join(tuples, ", ")), nil
+}
+
+// UpdateFrom renders an UPDATE of several rows in one statement. Each
+// record holds the primary key under pk and the new values under their
+// field names; all records must have the same keys. Values are joined from
+// a VALUES list and cast to the column types, as untyped placeholders in
+// VALUES would otherwise be read as text. For versioned schemas the version
+// is incremented, and records carrying the version field only update rows
+// still at that version. Soft-deleted rows are left alone. Updated rows are
+// returned.
+func (b *Builder) UpdateFrom(pk string, records []map[string]interface{}) (string, error) {
+	columns := SortedKeys(records[0])
+	source := QuoteIdent("bulk_values")
+	table := QuoteIdent(b.schema.TableName)
+	version := b.schema.VersionField
+
+	aliases := make([]string, len(columns))
+	types := make([]string, len(columns))
+	var assignments, conditions []string
+	for i, name := range columns {
+		col, err := b.Column(name)
+		if err != nil {
+			return "", err
+		}
+		field := b.schema.Fields[name]
+		field.PrimaryKey = false
+		if types[i], err = b.dialect.ColumnType(field); err != nil {
+			return "", err
+		}
+		aliases[i] = QuoteIdent("v" + strconv.Itoa(i))
+		switch name {
+		case pk:
+			conditions = append([]string{table + "." + col + " = " + source + "." + aliases[i]}, conditions...)
+		case version:
+			conditions = append(conditions, table+"."+col+" = "+source+"."+aliases[i])
+		default:
+			assignments = append(assignments, col+" = "+source+"."+aliases[i])
+		}
+	}
+	if version != "" {
+		col := QuoteIdent(version)
+		assignments = append(assignments, col+" = "+table+"."+col+" + 1")
+	}
+	if len(assignments) == 0 {
+		return "", fmt.Errorf("%w: no fields to update", interfaces.ErrInvalidQuery)
+	}
+
+	if b.schema.SoftDelete {
+		conditions = append(conditions, table+"."+QuoteIdent(interfaces.DeletedAtField)+" IS NULL")
+	}
+
+	if _, exists := records[0][pk]; !exists {
+		return "", fmt.Errorf("%w: rows must include the primary key", interfaces.ErrInvalidQuery)
+	}
+
+	tuples := make([]string, len(records))
+	for r, record := range records {
+		values := make([]string, len(columns))
+		for i, name := range columns {
+			value, err := b.Value(name, record[name])
+			if err != nil {
+				return "", err
+			}
+			values[i] = "CAST(" + b.Arg(value) + " AS " + types[i] + ")"
+		}
+		tuples[r] = "(" + strings.Join(values, ", ") + ")"
+	}
+
+	returning := ColumnNames(b.schema)
+	for i, name := range returning {
+		returning[i] = QuoteIdent(name)
+	}
+
+	return fmt.Sprintf("WITH %s (%s) AS (VALUES %s) UPDATE %s SET %s FROM %s WHERE %s RETURNING %s",
+		source, strings.Join(aliases, ", "), strings.Join(tuples, ", "),
+		table, strings.Join(assignments, ", "), source,
+		strings.Join(conditions, " AND "),
+		strings.Join(returning, ", ")), nil
+}
+
+// DeleteIn renders a DELETE of the rows whose pk is one of values,
+// returning the deleted rows
+func (b *Builder) DeleteIn(pk string, values []interface{}) (string, error) {
+	col, err := b.Column(pk)
+	if err != nil {
+		return "", err
+	}
+	in, err := b.list(col, "IN", pk, values)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE %s RETURNING *", QuoteIdent(b.schema.TableName), in), nil
+}
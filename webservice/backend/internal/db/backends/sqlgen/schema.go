@@ -0,0 +1,169 @@
+package sqlgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// PrimaryKey returns the primary key column of a schema, defaulting to "id"
+func PrimaryKey(schema *interfaces.Schema) string {
+	for name, field := range schema.Fields {
+		if field.PrimaryKey {
+			return name
+		}
+	}
+	return "id"
+}
+
+// ColumnNames returns the schema columns with the primary key first and the
+// rest in alphabetical order, so generated SQL is deterministic
+func ColumnNames(schema *interfaces.Schema) []string {
+	pk := PrimaryKey(schema)
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		if name != pk {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, exists := schema.Fields[pk]; exists {
+		names = append([]string{pk}, names...)
+	}
+	return names
+}
+
+// defaultLiteral renders a schema default value as a SQL literal
+func defaultLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int, int32, int64, float32, float64:
+		return fmt.Sprint(v), nil
+	case time.Time:
+		return "'" + v.UTC().Format(time.RFC3339Nano) + "'", nil
+	default:
+		return "", fmt.Errorf("unsupported default value %v (%T)", value, value)
+	}
+}
+
+// onDeleteAction maps a ForeignKey.OnDelete value to SQL
+func onDeleteAction(action string) string {
+	switch strings.ToUpper(action) {
+	case "CASCADE":
+		return "CASCADE"
+	case "SET_NULL", "SET NULL":
+		return "SET NULL"
+	case "RESTRICT":
+		return "RESTRICT"
+	default:
+		return "NO ACTION"
+	}
+}
+
+// ColumnDefinition renders a column as it appears in CREATE TABLE
+func ColumnDefinition(dialect *Dialect, name string, field interfaces.FieldSchema) (string, error) {
+	colType, err := dialect.ColumnType(field)
+	if err != nil {
+		return "", err
+	}
+
+	def := QuoteIdent(name) + " " + colType
+	if field.PrimaryKey {
+		def += " PRIMARY KEY"
+	} else {
+		if !field.Nullable {
+			def += " NOT NULL"
+		}
+		if field.Unique {
+			def += " UNIQUE"
+		}
+	}
+	if field.DefaultValue != nil {
+		literal, err := defaultLiteral(field.DefaultValue)
+		if err != nil {
+			return "", err
+		}
+		def += " DEFAULT " + literal
+	}
+	if fk := field.ForeignKey; fk != nil {
+		def += fmt.Sprintf(" REFERENCES %s (%s) ON DELETE %s",
+			QuoteIdent(fk.Table), QuoteIdent(fk.Column), onDeleteAction(fk.OnDelete))
+	}
+	return def, nil
+}
+
+// CreateTableSQL returns the statements creating a schema's table and
+// indexes. Statements are idempotent so Migrate can run on every start.
+func CreateTableSQL(dialect *Dialect, schema *interfaces.Schema) ([]string, error) {
+	table := QuoteIdent(schema.TableName)
+
+	var columns []string
+	for _, name := range ColumnNames(schema) {
+		def, err := ColumnDefinition(dialect, name, schema.Fields[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", schema.TableName, name, err)
+		}
+		columns = append(columns, def)
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", table, strings.Join(columns, ",\n\t")),
+	}
+
+	if err := query.ValidateIndexes(schema); err != nil {
+		return nil, err
+	}
+	for _, index := range schema.Indexes {
+		statements = append(statements, CreateIndexSQL(schema, index))
+	}
+
+	return statements, nil
+}
+
+// CreateIndexSQL returns the statement creating index on schema's table
+func CreateIndexSQL(schema *interfaces.Schema, index interfaces.Index) string {
+	cols := make([]string, len(index.Columns))
+	for i, col := range index.Columns {
+		cols[i] = QuoteIdent(col)
+	}
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)",
+		unique, QuoteIdent(index.Name), QuoteIdent(schema.TableName), strings.Join(cols, ", "))
+}
+
+// MigrateIndexesSQL returns the statements bringing the indexes of an
+// existing table in line with schema, given the indexes it has by name.
+// Missing indexes are created and those whose columns or uniqueness changed
+// are dropped and recreated. Indexes the schema does not declare are left
+// alone, as they may have been added by hand.
+func MigrateIndexesSQL(schema *interfaces.Schema, existing map[string]interfaces.Index) ([]string, error) {
+	if err := query.ValidateIndexes(schema); err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	for _, index := range schema.Indexes {
+		current, exists := existing[index.Name]
+		if exists && query.EqualIndexes(current, index) {
+			continue
+		}
+		if exists {
+			statements = append(statements, "DROP INDEX IF EXISTS "+QuoteIdent(index.Name))
+		}
+		statements = append(statements, CreateIndexSQL(schema, index))
+	}
+	return statements, nil
+}
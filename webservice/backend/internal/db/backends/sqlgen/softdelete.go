@@ -0,0 +1,75 @@
+package sqlgen
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// Live restricts a WHERE expression to rows that are not soft deleted when
+// schema soft deletes
+func Live(schema *interfaces.Schema, where string) string {
+	if !schema.SoftDelete {
+		return where
+	}
+	return where + " AND " + QuoteIdent(interfaces.DeletedAtField) + " IS NULL"
+}
+
+// SoftDeleteIn renders an UPDATE marking the live rows whose pk is one of
+// values as deleted at now, returning the deleted rows
+func (b *Builder) SoftDeleteIn(pk string, values []interface{}, now time.Time) (string, error) {
+	col, err := b.Column(pk)
+	if err != nil {
+		return "", err
+	}
+	deletedAt, err := b.Column(interfaces.DeletedAtField)
+	if err != nil {
+		return "", err
+	}
+	value, err := b.Value(interfaces.DeletedAtField, now)
+	if err != nil {
+		return "", err
+	}
+	assignments := append([]string{deletedAt + " = " + b.Arg(value)}, b.touch(now)...)
+
+	in, err := b.list(col, "IN", pk, values)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING *",
+		QuoteIdent(b.schema.TableName), strings.Join(assignments, ", "), Live(b.schema, in)), nil
+}
+
+// Restore renders an UPDATE clearing the deletion mark of the row whose pk
+// is value, returning the row if it was deleted
+func (b *Builder) Restore(pk string, value interface{}, now time.Time) (string, error) {
+	col, err := b.Column(pk)
+	if err != nil {
+		return "", err
+	}
+	deletedAt, err := b.Column(interfaces.DeletedAtField)
+	if err != nil {
+		return "", err
+	}
+	assignments := append([]string{deletedAt + " = NULL"}, b.touch(now)...)
+
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s AND %s IS NOT NULL RETURNING *",
+		QuoteIdent(b.schema.TableName), strings.Join(assignments, ", "), col, b.Arg(value), deletedAt), nil
+}
+
+// touch returns the assignments that accompany a soft delete or restore:
+// updated_at set to now and the version, if any, incremented
+func (b *Builder) touch(now time.Time) []string {
+	var assignments []string
+	if _, exists := b.schema.Fields["updated_at"]; exists {
+		value, _ := b.Value("updated_at", now)
+		assignments = append(assignments, QuoteIdent("updated_at")+" = "+b.Arg(value))
+	}
+	if version := b.schema.VersionField; version != "" {
+		col := QuoteIdent(version)
+		assignments = append(assignments, col+" = "+col+" + 1")
+	}
+	return assignments
+}
@@ -0,0 +1,354 @@
+package sqlgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+var testDialect = &Dialect{
+	Name:        "test",
+	Placeholder: func(n int) string { return "?" + strconv.Itoa(n) },
+	ColumnType:  func(interfaces.FieldSchema) (string, error) { return "TEXT", nil },
+	Contains: func(col, placeholder string, caseSensitive bool) string {
+		return "contains(" + col + ", " + placeholder + ")"
+	},
+	ContainsArg: func(pattern string) string { return pattern },
+	TruncateTime: func(col, unit string) string {
+		return "trunc(" + col + ", '" + unit + "')"
+	},
+	TimeValue: func(t time.Time) interface{} { return t.UTC().Format(time.RFC3339) },
+	ParseTime: func(s string) (time.Time, error) { return time.Parse(time.RFC3339, s) },
+}
+
+var testSchema = &interfaces.Schema{
+	TableName: "events",
+	Fields: map[string]interfaces.FieldSchema{
+		"id":     {Type: "string", PrimaryKey: true},
+		"count":  {Type: "int"},
+		"active": {Type: "bool"},
+		"ts":     {Type: "time"},
+	},
+}
+
+func TestQuoteIdent(t *testing.T) {
+	if got := QuoteIdent(`we"ird`); got != `"we""ird"` {
+		t.Fatalf("Unexpected quoting: %s", got)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := EscapeLike(`a_b%c\d`); got != `a\_b\%c\\d` {
+		t.Fatalf("Unexpected escaping: %s", got)
+	}
+}
+
+func TestBuilderUsesDialect(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("X", 3600))
+
+	b := NewBuilder(testDialect, testSchema)
+	where, err := b.Where(&interfaces.Filters{
+		Conditions: []interfaces.Filter{
+			{Field: "ts", Operator: &interfaces.FilterOperator{Gt: ts}},
+			{Field: "id", Operator: &interfaces.FilterOperator{NotLike: "%abc%"}},
+			{Field: "count", Value: nil},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Where failed: %v", err)
+	}
+
+	want := `"ts" > ?1 AND ("id" IS NULL OR NOT contains("id", ?2)) AND "count" IS NULL`
+	if where != want {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", where, want)
+	}
+	if args := b.Args(); len(args) != 2 || args[0] != "2024-05-06T06:08:09Z" || args[1] != "abc" {
+		t.Fatalf("Unexpected args: %#v", args)
+	}
+}
+
+func TestBuilderRejectsUnknownSelect(t *testing.T) {
+	b := NewBuilder(testDialect, testSchema)
+	if _, err := b.SelectList([]string{"id", "*"}); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Fatalf("Expected ErrInvalidQuery, got %v", err)
+	}
+}
+
+func TestAggregateSQL(t *testing.T) {
+	q := &interfaces.AggregateQuery{
+		Where:   &interfaces.Filters{Conditions: []interfaces.Filter{{Field: "active", Value: true}}},
+		GroupBy: []interfaces.GroupBy{{Field: "ts", Truncate: "day"}},
+		Aggregates: []interfaces.Aggregate{
+			{Function: "count"},
+			{Function: "sum", Field: "count", Alias: "total"},
+		},
+		Having: &interfaces.Filters{
+			Conditions: []interfaces.Filter{{Field: "total", Operator: &interfaces.FilterOperator{Gt: 10}}},
+		},
+	}
+	out, err := query.AggregateSchema(testSchema, q)
+	if err != nil {
+		t.Fatalf("AggregateSchema failed: %v", err)
+	}
+
+	b := NewBuilder(testDialect, testSchema)
+	stmt, err := b.Aggregate(q, out)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+
+	want := `SELECT * FROM (SELECT trunc("ts", 'day') AS "ts", COUNT(*) AS "count", CAST(SUM("count") AS TEXT) AS "total" ` +
+		`FROM "events" WHERE "active" = ?1 GROUP BY trunc("ts", 'day')) AS "aggregate" WHERE "total" > ?2 ORDER BY "ts" ASC`
+	if stmt != want {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", stmt, want)
+	}
+	if args := b.Args(); len(args) != 2 || args[0] != true || args[1] != 10 {
+		t.Fatalf("Unexpected args: %#v", args)
+	}
+}
+
+func TestBulkSQL(t *testing.T) {
+	records := []map[string]interface{}{
+		{"id": "a", "count": 1},
+		{"id": "b", "count": 2},
+	}
+
+	b := NewBuilder(testDialect, testSchema)
+	stmt, err := b.Insert(records)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	want := `INSERT INTO "events" ("count", "id") VALUES (?1, ?2), (?3, ?4) RETURNING *`
+	if stmt != want {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", stmt, want)
+	}
+
+	b = NewBuilder(testDialect, testSchema)
+	stmt, err = b.UpdateFrom("id", records)
+	if err != nil {
+		t.Fatalf("UpdateFrom failed: %v", err)
+	}
+	want = `WITH "bulk_values" ("v0", "v1") AS (VALUES (CAST(?1 AS TEXT), CAST(?2 AS TEXT)), (CAST(?3 AS TEXT), CAST(?4 AS TEXT))) ` +
+		`UPDATE "events" SET "count" = "bulk_values"."v0" FROM "bulk_values" WHERE "events"."id" = "bulk_values"."v1" ` +
+		`RETURNING "id", "active", "count", "ts"`
+	if stmt != want {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", stmt, want)
+	}
+
+	b = NewBuilder(testDialect, testSchema)
+	stmt, err = b.DeleteIn("id", []interface{}{"a", "b"})
+	if err != nil {
+		t.Fatalf("DeleteIn failed: %v", err)
+	}
+	if want := `DELETE FROM "events" WHERE "id" IN (?1, ?2) RETURNING *`; stmt != want {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", stmt, want)
+	}
+}
+
+func TestVersionedUpdateFromSQL(t *testing.T) {
+	versioned := *testSchema
+	versioned.Fields = map[string]interfaces.FieldSchema{
+		"id":      {Type: "string", PrimaryKey: true},
+		"count":   {Type: "int"},
+		"version": {Type: "int64"},
+	}
+	versioned.VersionField = "version"
+
+	b := NewBuilder(testDialect, &versioned)
+	stmt, err := b.UpdateFrom("id", []map[string]interface{}{{"id": "a", "count": 1, "version": int64(3)}})
+	if err != nil {
+		t.Fatalf("UpdateFrom failed: %v", err)
+	}
+	want := `WITH "bulk_values" ("v0", "v1", "v2") AS (VALUES (CAST(?1 AS TEXT), CAST(?2 AS TEXT), CAST(?3 AS TEXT))) ` +
+		`UPDATE "events" SET "count" = "bulk_values"."v0", "version" = "events"."version" + 1 FROM "bulk_values" ` +
+		`WHERE "events"."id" = "bulk_values"."v1" AND "events"."version" = "bulk_values"."v2" ` +
+		`RETURNING "id", "count", "version"`
+	if stmt != want {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", stmt, want)
+	}
+}
+
+func TestSoftDeleteSQL(t *testing.T) {
+	soft := *testSchema
+	soft.Fields = map[string]interfaces.FieldSchema{
+		"id":         {Type: "string", PrimaryKey: true},
+		"updated_at": {Type: "time"},
+		"deleted_at": {Type: "time", Nullable: true},
+	}
+	soft.SoftDelete = true
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	b := NewBuilder(testDialect, &soft)
+	stmt, err := b.SoftDeleteIn("id", []interface{}{"a", "b"}, now)
+	if err != nil {
+		t.Fatalf("SoftDeleteIn failed: %v", err)
+	}
+	want := `UPDATE "events" SET "deleted_at" = ?1, "updated_at" = ?2 WHERE "id" IN (?3, ?4) AND "deleted_at" IS NULL RETURNING *`
+	if stmt != want {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", stmt, want)
+	}
+	if args := b.Args(); len(args) != 4 || args[0] != "2024-01-02T03:04:05Z" {
+		t.Errorf("Unexpected args: %v", args)
+	}
+
+	b = NewBuilder(testDialect, &soft)
+	stmt, err = b.Restore("id", "a", now)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	want = `UPDATE "events" SET "deleted_at" = NULL, "updated_at" = ?1 WHERE "id" = ?2 AND "deleted_at" IS NOT NULL RETURNING *`
+	if stmt != want {
+		t.Fatalf("Unexpected SQL:\n got: %s\nwant: %s", stmt, want)
+	}
+
+	if got := Live(&soft, `"id" = ?1`); got != `"id" = ?1 AND "deleted_at" IS NULL` {
+		t.Errorf("Unexpected live condition: %s", got)
+	}
+	if got := Live(testSchema, `"id" = ?1`); got != `"id" = ?1` {
+		t.Errorf("Expected hard-deleting schema to be unchanged, got %s", got)
+	}
+}
+
+func TestMigrateIndexesSQL(t *testing.T) {
+	indexed := *testSchema
+	indexed.Indexes = []interfaces.Index{
+		{Name: "idx_events_count", Columns: []string{"count"}},
+		{Name: "idx_events_active_ts", Columns: []string{"active", "ts"}, Unique: true},
+		{Name: "idx_events_ts", Columns: []string{"ts"}},
+	}
+	existing := map[string]interfaces.Index{
+		"idx_events_count":     {Name: "idx_events_count", Columns: []string{"count"}},
+		"idx_events_active_ts": {Name: "idx_events_active_ts", Columns: []string{"active", "ts"}},
+		"idx_manual":           {Name: "idx_manual", Columns: []string{"id"}},
+	}
+
+	got, err := MigrateIndexesSQL(&indexed, existing)
+	if err != nil {
+		t.Fatalf("MigrateIndexesSQL failed: %v", err)
+	}
+	want := []string{
+		`DROP INDEX IF EXISTS "idx_events_active_ts"`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS "idx_events_active_ts" ON "events" ("active", "ts")`,
+		`CREATE INDEX IF NOT EXISTS "idx_events_ts" ON "events" ("ts")`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected statements:\n got: %q\nwant: %q", got, want)
+	}
+
+	for _, index := range []interfaces.Index{
+		{Name: "", Columns: []string{"count"}},
+		{Name: "idx_empty"},
+		{Name: "idx_unknown", Columns: []string{"missing"}},
+		{Name: "idx_twice", Columns: []string{"count", "count"}},
+		{Name: "idx_events_count", Columns: []string{"ts"}},
+	} {
+		invalid := indexed
+		invalid.Indexes = append(append([]interfaces.Index(nil), indexed.Indexes...), index)
+		if _, err := MigrateIndexesSQL(&invalid, nil); err == nil {
+			t.Errorf("Expected index %+v to be rejected", index)
+		}
+	}
+}
+
+func TestStatementsRespectArgLimit(t *testing.T) {
+	limited := *testDialect
+	limited.MaxArgs = 5
+
+	records := []map[string]interface{}{
+		{"id": "a", "count": 1},
+		{"id": "b"},
+		{"id": "c", "count": 3},
+		{"id": "d", "count": 4},
+	}
+
+	var parts [][]int
+	err := limited.Statements(records, []int{0, 1, 2, 3}, func(part []int) error {
+		parts = append(parts, append([]int(nil), part...))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Statements failed: %v", err)
+	}
+
+	// Two-column rows fit two per statement; the one-column row is separate
+	want := [][]int{{0, 2}, {3}, {1}}
+	if fmt.Sprint(parts) != fmt.Sprint(want) {
+		t.Fatalf("Expected parts %v, got %v", want, parts)
+	}
+}
+
+func TestBulkCreateManyBatchesRows(t *testing.T) {
+	limited := *testDialect
+	limited.MaxArgs = 4
+
+	var statements int
+	bulk := &Bulk{
+		Dialect: &limited,
+		Schema:  testSchema,
+		Querier: QuerierFunc(func(ctx context.Context, op interfaces.ChangeOp, stmt string, args []interface{}) ([]map[string]interface{}, error) {
+			statements++
+			// Columns are bound in alphabetical order: count, id
+			records := make([]map[string]interface{}, 0, len(args)/2)
+			for i := 0; i < len(args); i += 2 {
+				records = append(records, map[string]interface{}{"count": args[i], "id": args[i+1]})
+			}
+			return records, nil
+		}),
+		Hooked: query.Hooked{Transaction: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		}},
+		Prepare: func(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+			if data["id"] == "" {
+				return nil, errors.New("missing id")
+			}
+			return data, nil
+		},
+	}
+
+	data := []map[string]interface{}{
+		{"id": "a", "count": 1},
+		{"id": ""},
+		{"id": "b", "count": 2},
+		{"id": "c", "count": 3},
+	}
+	result, err := bulk.CreateMany(context.Background(), data, nil)
+	if err != nil {
+		t.Fatalf("CreateMany failed: %v", err)
+	}
+
+	// Two rows fit a statement, and the rejected row is reported alone
+	if statements != 2 {
+		t.Errorf("Expected 2 statements, got %d", statements)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Index != 1 {
+		t.Errorf("Expected an error for row 1, got %v", result.Errors)
+	}
+	for i, id := range []interface{}{"a", nil, "b", "c"} {
+		if got := result.Records[i]["id"]; got != id {
+			t.Errorf("Expected record %d to be %v, got %v", i, id, got)
+		}
+	}
+}
+
+func TestFromColumnValue(t *testing.T) {
+	if v := FromColumnValue(testDialect, testSchema.Fields["count"], int32(35)); v != 35 {
+		t.Errorf("Expected int 35, got %#v", v)
+	}
+	if v := FromColumnValue(testDialect, interfaces.FieldSchema{Type: "int64"}, int32(7)); v != int64(7) {
+		t.Errorf("Expected int64 7, got %#v", v)
+	}
+	if v := FromColumnValue(testDialect, testSchema.Fields["active"], int64(1)); v != true {
+		t.Errorf("Expected bool true, got %#v", v)
+	}
+	v := FromColumnValue(testDialect, testSchema.Fields["ts"], "2024-05-06T06:08:09Z")
+	if ts, ok := v.(time.Time); !ok || !ts.Equal(time.Date(2024, 5, 6, 6, 8, 9, 0, time.UTC)) {
+		t.Errorf("Expected parsed time, got %#v", v)
+	}
+}
@@ -0,0 +1,493 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Options configures the connection pool
+type Options struct {
+	MaxOpenConns int // Maximum open connections. Default: 1, as SQLite serializes writes
+	MaxIdleConns int // Maximum idle connections. Default: database/sql default
+}
+
+// defaultPragmas are applied to every connection unless the DSN sets them
+var defaultPragmas = []string{
+	"foreign_keys(1)",
+	"busy_timeout(5000)",
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// txKey carries the active transaction in the context passed to
+// Transaction callbacks, so repositories join it transparently
+type txKey struct{}
+
+// Database implements the Database interface for SQLite
+type Database struct {
+	dsn     string
+	options Options
+
+	mu         sync.RWMutex
+	conn       *sql.DB
+	schemas    map[string]*interfaces.Schema // tableName -> schema, for Include
+	savepoints atomic.Int64
+	hooks      query.HookRegistry
+	changes    query.ChangeFeed
+}
+
+// NewDatabase creates a SQLite database for a DSN such as "file:dev.db" or
+// ":memory:". No connection is made until Connect is called.
+func NewDatabase(dsn string, options Options) *Database {
+	return &Database{
+		dsn:     dsn,
+		options: options,
+		schemas: make(map[string]*interfaces.Schema),
+	}
+}
+
+// Connect opens the database file, creating it if needed
+func (db *Database) Connect(ctx context.Context) error {
+	conn, err := sql.Open("sqlite", withPragmas(db.dsn))
+	if err != nil {
+		return &interfaces.DatabaseError{Op: "connect", Err: err}
+	}
+
+	maxOpen := db.options.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = 1
+	}
+	conn.SetMaxOpenConns(maxOpen)
+	if db.options.MaxIdleConns > 0 {
+		conn.SetMaxIdleConns(db.options.MaxIdleConns)
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return &interfaces.DatabaseError{Op: "connect", Err: err}
+	}
+
+	db.mu.Lock()
+	if db.conn != nil {
+		db.conn.Close()
+	}
+	db.conn = conn
+	db.mu.Unlock()
+
+	log.Printf("Connected to SQLite database: %s", db.dsn)
+	return nil
+}
+
+// Disconnect closes the database
+func (db *Database) Disconnect(ctx context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.conn != nil {
+		if err := db.conn.Close(); err != nil {
+			return &interfaces.DatabaseError{Op: "disconnect", Err: err}
+		}
+		db.conn = nil
+	}
+	log.Println("Disconnected from SQLite database")
+	return nil
+}
+
+// IsHealthy checks if the database connection is healthy
+func (db *Database) IsHealthy(ctx context.Context) bool {
+	conn, err := db.getConn()
+	if err != nil {
+		return false
+	}
+	return conn.PingContext(ctx) == nil
+}
+
+// Transaction executes a function within a database transaction. Repository
+// calls made with the callback's context run inside the transaction; nested
+// calls create savepoints.
+func (db *Database) Transaction(ctx context.Context, fn func(ctx context.Context, tx interfaces.Transaction) error) error {
+	return db.TransactionWithOptions(ctx, nil, fn)
+}
+
+// TransactionWithOptions executes a function within a database transaction.
+// SQLite transactions are serializable, so every isolation level is
+// accepted. Read-only transactions set query_only on their connection.
+// Failures to get a lock in time are returned as ErrTxConflict and retried
+// as opts allows; nested calls create savepoints and leave retries to the
+// outermost call.
+func (db *Database) TransactionWithOptions(ctx context.Context, opts *interfaces.TxOptions, fn func(ctx context.Context, tx interfaces.Transaction) error) error {
+	if outer, ok := ctx.Value(txKey{}).(*Transaction); ok {
+		return db.transaction(ctx, fn, func() (*Transaction, error) {
+			name := fmt.Sprintf("sp_%d", db.savepoints.Add(1))
+			if _, err := outer.tx.ExecContext(ctx, "SAVEPOINT "+sqlgen.QuoteIdent(name)); err != nil {
+				return nil, err
+			}
+			return &Transaction{tx: outer.tx, savepoint: name}, nil
+		})
+	}
+
+	conn, err := db.getConn()
+	if err != nil {
+		return err
+	}
+	readOnly := opts != nil && opts.ReadOnly
+	return query.RetryConflicts(ctx, opts, func() error {
+		return db.transaction(ctx, fn, func() (*Transaction, error) {
+			sqlTx, err := conn.BeginTx(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+			if readOnly {
+				if _, err := sqlTx.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+					sqlTx.Rollback()
+					return nil, err
+				}
+			}
+			return &Transaction{tx: sqlTx, readOnly: readOnly}, nil
+		})
+	})
+}
+
+// transaction runs fn in the transaction or savepoint begun by begin
+func (db *Database) transaction(ctx context.Context, fn func(ctx context.Context, tx interfaces.Transaction) error, begin func() (*Transaction, error)) error {
+	tx, err := begin()
+	if err != nil {
+		return translateError("begin", err)
+	}
+
+	// Hold change events until the transaction commits
+	txCtx := db.changes.CollectChanges(context.WithValue(ctx, txKey{}, tx))
+
+	defer func() {
+		if !tx.IsCompleted() {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	if err := fn(txCtx, tx); err != nil {
+		tx.Rollback(ctx)
+		return conflictError(err)
+	}
+
+	if !tx.IsCompleted() {
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	if tx.isCommitted() {
+		db.changes.FlushChanges(ctx, txCtx)
+	}
+	return nil
+}
+
+// Repository returns a repository for the given schema
+func (db *Database) Repository(schema *interfaces.Schema) interfaces.Repository {
+	db.registerSchema(schema)
+	return NewRepository(db, schema)
+}
+
+// RegisterHooks adds hooks run around the mutations of the schema's table
+func (db *Database) RegisterHooks(schema *interfaces.Schema, hooks interfaces.Hooks) {
+	db.hooks.Register(schema.TableName, hooks)
+}
+
+// Watch streams committed changes to records of the schema's table that
+// match filter. SQLite runs in process, so changes made through this
+// Database are dispatched directly; writes by other processes sharing the
+// file are not seen.
+func (db *Database) Watch(ctx context.Context, schema *interfaces.Schema, filter *interfaces.Filters) (<-chan interfaces.ChangeEvent, error) {
+	return db.changes.Watch(ctx, schema, filter)
+}
+
+// registerSchema records a schema so relations can resolve its table
+func (db *Database) registerSchema(schema *interfaces.Schema) {
+	db.mu.Lock()
+	db.schemas[schema.TableName] = schema
+	db.mu.Unlock()
+}
+
+// relatedRepository resolves Include relations against registered schemas
+func (db *Database) relatedRepository(table string) (interfaces.Repository, error) {
+	db.mu.RLock()
+	schema, exists := db.schemas[table]
+	db.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: no schema registered for table %q", interfaces.ErrInvalidQuery, table)
+	}
+	return NewRepository(db, schema), nil
+}
+
+// Migrate creates tables and indexes for the given schemas, adds columns
+// that were introduced since a table was created and recreates indexes
+// whose definition changed. Schemas must be ordered so
+// that referenced tables come first.
+func (db *Database) Migrate(ctx context.Context, schemas []*interfaces.Schema) error {
+	return db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+		q, err := db.querier(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, schema := range schemas {
+			statements, err := sqlgen.CreateTableSQL(dialect, schema)
+			if err != nil {
+				return &interfaces.DatabaseError{Op: "migrate", Err: err}
+			}
+
+			// Create the table first so missing columns exist before indexes
+			if _, err := q.ExecContext(ctx, statements[0]); err != nil {
+				return &interfaces.DatabaseError{Op: "migrate " + schema.TableName, Err: err}
+			}
+			if err := addMissingColumns(ctx, q, schema); err != nil {
+				return &interfaces.DatabaseError{Op: "migrate " + schema.TableName, Err: err}
+			}
+			if err := migrateIndexes(ctx, q, schema); err != nil {
+				return &interfaces.DatabaseError{Op: "migrate " + schema.TableName, Err: err}
+			}
+			db.registerSchema(schema)
+			log.Printf("Migrated SQLite table: %s", schema.TableName)
+		}
+
+		log.Printf("Migration completed for %d schemas", len(schemas))
+		return nil
+	})
+}
+
+// addMissingColumns adds schema fields absent from an existing table.
+// SQLite only allows NOT NULL on added columns that have a default, so
+// other new columns are added as nullable.
+func addMissingColumns(ctx context.Context, q querier, schema *interfaces.Schema) error {
+	rows, err := q.QueryContext(ctx, "SELECT name FROM pragma_table_info("+dialect.Placeholder(1)+")", schema.TableName)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range sqlgen.ColumnNames(schema) {
+		if existing[name] {
+			continue
+		}
+		field := schema.Fields[name]
+		field.PrimaryKey = false
+		field.Unique = false
+		if field.DefaultValue == nil {
+			field.Nullable = true
+		}
+
+		def, err := sqlgen.ColumnDefinition(dialect, name, field)
+		if err != nil {
+			return err
+		}
+
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", sqlgen.QuoteIdent(schema.TableName), def)
+		if _, err := q.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+		log.Printf("Added column %s.%s", schema.TableName, name)
+	}
+	return nil
+}
+
+// migrateIndexes creates the indexes of schema that are missing and
+// recreates those whose columns or uniqueness changed
+func migrateIndexes(ctx context.Context, q querier, schema *interfaces.Schema) error {
+	// Only indexes made by CREATE INDEX; those backing UNIQUE and PRIMARY KEY
+	// column constraints follow the table definition
+	rows, err := q.QueryContext(ctx, `SELECT il.name, il."unique", ii.name
+		FROM pragma_index_list(`+dialect.Placeholder(1)+`) AS il, pragma_index_info(il.name) AS ii
+		WHERE il.origin = 'c' ORDER BY il.name, ii.seqno`, schema.TableName)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]interfaces.Index)
+	for rows.Next() {
+		var name, column string
+		var unique bool
+		if err := rows.Scan(&name, &unique, &column); err != nil {
+			rows.Close()
+			return err
+		}
+		index := existing[name]
+		index.Name, index.Unique = name, unique
+		index.Columns = append(index.Columns, column)
+		existing[name] = index
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	statements, err := sqlgen.MigrateIndexesSQL(schema, existing)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range statements {
+		if _, err := q.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Seed inserts initial data into the database
+func (db *Database) Seed(ctx context.Context, schema *interfaces.Schema, data []map[string]interface{}) error {
+	if _, err := db.getConn(); err != nil {
+		return err
+	}
+
+	result, err := db.Repository(schema).CreateMany(ctx, data, nil)
+	if err != nil {
+		return err
+	}
+
+	// Failed records are logged rather than failing the whole seed
+	for _, rowErr := range result.Errors {
+		log.Printf("Failed to seed record %d in table %s: %v", rowErr.Index, schema.TableName, rowErr.Err)
+	}
+
+	log.Printf("Seeded %d records into table %s", result.Affected, schema.TableName)
+	return nil
+}
+
+// Export writes the records of opts.Schemas to w as newline-delimited JSON
+func (db *Database) Export(ctx context.Context, w io.Writer, opts interfaces.ExportOptions) error {
+	return query.Export(ctx, db, w, opts)
+}
+
+// Import creates the records of an Export stream in one transaction
+func (db *Database) Import(ctx context.Context, r io.Reader, opts interfaces.ImportOptions) error {
+	return query.Import(ctx, db, r, opts)
+}
+
+func (db *Database) getConn() (*sql.DB, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.conn == nil {
+		return nil, interfaces.ErrDatabaseNotConnected
+	}
+	return db.conn, nil
+}
+
+// querier returns the transaction carried by ctx, or the connection pool
+func (db *Database) querier(ctx context.Context) (querier, error) {
+	if tx, ok := ctx.Value(txKey{}).(*Transaction); ok {
+		if tx.IsCompleted() {
+			return nil, interfaces.ErrTransactionCompleted
+		}
+		return recorder{tx.tx}, nil
+	}
+	conn, err := db.getConn()
+	if err != nil {
+		return nil, err
+	}
+	return recorder{conn}, nil
+}
+
+// recorder records the statements run for instrumented calls, see
+// query.CaptureStatements
+type recorder struct {
+	q querier
+}
+
+func (r recorder) ExecContext(ctx context.Context, stmt string, args ...any) (sql.Result, error) {
+	query.RecordStatement(ctx, stmt)
+	return r.q.ExecContext(ctx, stmt, args...)
+}
+
+func (r recorder) QueryContext(ctx context.Context, stmt string, args ...any) (*sql.Rows, error) {
+	query.RecordStatement(ctx, stmt)
+	return r.q.QueryContext(ctx, stmt, args...)
+}
+
+func (r recorder) QueryRowContext(ctx context.Context, stmt string, args ...any) *sql.Row {
+	query.RecordStatement(ctx, stmt)
+	return r.q.QueryRowContext(ctx, stmt, args...)
+}
+
+// withPragmas appends defaultPragmas the DSN does not already set
+func withPragmas(dsn string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	for _, pragma := range defaultPragmas {
+		name := pragma[:strings.Index(pragma, "(")]
+		if strings.Contains(dsn, "_pragma="+name) {
+			continue
+		}
+		dsn += sep + "_pragma=" + pragma
+		sep = "&"
+	}
+	return dsn
+}
+
+// translateError maps SQLite constraint violations, lock failures and
+// writes in read-only transactions to the shared errors
+func translateError(op string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return interfaces.ErrNotFound
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %s", interfaces.ErrUniqueConstraint, sqliteErr.Error())
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return fmt.Errorf("%w: %s", interfaces.ErrForeignKeyConstraint, sqliteErr.Error())
+		}
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return fmt.Errorf("%w: %s", interfaces.ErrTxConflict, sqliteErr.Error())
+		case sqlite3.SQLITE_READONLY:
+			return fmt.Errorf("%w: %s", interfaces.ErrReadOnly, sqliteErr.Error())
+		}
+	}
+
+	return &interfaces.DatabaseError{Op: op, Err: err}
+}
+
+// conflictError marks lock failures the callback of a transaction returned
+// untranslated, so they are retried
+func conflictError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && !errors.Is(err, interfaces.ErrTxConflict) {
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return fmt.Errorf("%w: %w", interfaces.ErrTxConflict, err)
+		}
+	}
+	return err
+}
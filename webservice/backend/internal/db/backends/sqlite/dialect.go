@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+	"modernc.org/sqlite"
+)
+
+// timeFormat stores times as fixed-width UTC text so that string
+// comparison in SQL orders them chronologically
+const timeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// dialect renders SQL for SQLite
+var dialect = &sqlgen.Dialect{
+	Name: "sqlite",
+	Placeholder: func(n int) string {
+		return "?" + strconv.Itoa(n)
+	},
+	MaxArgs:    32766, // SQLITE_MAX_VARIABLE_NUMBER
+	ColumnType: columnType,
+	Contains: func(col, placeholder string, caseSensitive bool) string {
+		// LIKE ignores ASCII case in SQLite, so use instr for exact matching
+		if caseSensitive {
+			return "instr(" + col + ", " + placeholder + ") > 0"
+		}
+		return "instr(lower(" + col + "), lower(" + placeholder + ")) > 0"
+	},
+	ContainsArg: func(pattern string) string {
+		return pattern
+	},
+	TruncateTime: func(col, unit string) string {
+		// Times are stored in timeFormat, so truncation keeps a prefix and
+		// pads the rest with zeroes
+		switch unit {
+		case "hour":
+			return "substr(" + col + ", 1, 13) || ':00:00.000000000Z'"
+		case "month":
+			return "substr(" + col + ", 1, 7) || '-01T00:00:00.000000000Z'"
+		default:
+			return "substr(" + col + ", 1, 10) || 'T00:00:00.000000000Z'"
+		}
+	},
+	TimeValue: func(t time.Time) interface{} {
+		return t.UTC().Format(timeFormat)
+	},
+	ParseTime: func(s string) (time.Time, error) {
+		return time.Parse(time.RFC3339Nano, s)
+	},
+	JSONPath: func(col, placeholder string) string {
+		return col + " -> " + placeholder
+	},
+	JSONPathArg: func(path []string) (interface{}, error) {
+		var b strings.Builder
+		b.WriteString("$")
+		for _, key := range path {
+			if strings.ContainsAny(key, `"\`) {
+				return nil, fmt.Errorf("%w: unsupported character in JSON path key %q", interfaces.ErrInvalidQuery, key)
+			}
+			b.WriteString(`."` + key + `"`)
+		}
+		return b.String(), nil
+	},
+	JSONValue: func(placeholder string) string {
+		return "json(" + placeholder + ")"
+	},
+	JSONContains: func(target, value string) string {
+		return jsonContainsFunc + "(" + target + ", " + value + ")"
+	},
+	Search: func(col, placeholder string) string {
+		return searchRankFunc + "(" + col + ", " + placeholder + ") > 0"
+	},
+	SearchRank: func(col, placeholder string) string {
+		return searchRankFunc + "(" + col + ", " + placeholder + ")"
+	},
+	JSONText: true,
+}
+
+// jsonContainsFunc is a SQL function implementing JSON containment, which
+// SQLite lacks, with the same rules as the in-memory backend
+const jsonContainsFunc = "leafsii_json_contains"
+
+// searchRankFunc is a SQL function ranking text against a search query,
+// tokenized as by the in-memory backend
+const searchRankFunc = "leafsii_search_rank"
+
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction(jsonContainsFunc, 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		var values [2]interface{}
+		for i, arg := range args {
+			var text []byte
+			switch v := arg.(type) {
+			case nil:
+				return nil, nil
+			case string:
+				text = []byte(v)
+			case []byte:
+				text = v
+			default:
+				// Numbers extracted from a document
+				text = []byte(fmt.Sprint(v))
+			}
+			if err := json.Unmarshal(text, &values[i]); err != nil {
+				return nil, err
+			}
+		}
+		return query.JSONContains(values[0], values[1]), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction(searchRankFunc, 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, _ := args[0].(string)
+		search, _ := args[1].(string)
+		return query.SearchRank(text, search), nil
+	})
+}
+
+// columnType maps a schema field type to a SQLite column type. Booleans are
+// stored as integers, times as text in timeFormat and JSON as text.
+func columnType(field interfaces.FieldSchema) (string, error) {
+	switch field.Type {
+	case "string", "time", "json":
+		return "TEXT", nil
+	case "int", "int64", "bool":
+		return "INTEGER", nil
+	case "float64":
+		return "REAL", nil
+	default:
+		return "", fmt.Errorf("unsupported field type %q", field.Type)
+	}
+}
@@ -0,0 +1,251 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/dbtest"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+func newTestDatabase(t *testing.T, dsn string) *Database {
+	t.Helper()
+
+	ctx := context.Background()
+	db := NewDatabase(dsn, Options{})
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { db.Disconnect(ctx) })
+	return db
+}
+
+func TestSQLiteDatabase(t *testing.T) {
+	db := newTestDatabase(t, ":memory:")
+	if err := db.Migrate(context.Background(), dbtest.Schemas()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	dbtest.Run(t, db)
+}
+
+func TestSQLitePersistsAcrossConnections(t *testing.T) {
+	ctx := context.Background()
+	dsn := "file:" + filepath.Join(t.TempDir(), "dev.db")
+
+	db := newTestDatabase(t, dsn)
+	if err := db.Migrate(ctx, dbtest.Schemas()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	users := db.Repository(dbtest.Schemas()[0])
+	created, err := users.Create(ctx, map[string]interface{}{
+		"email":     "durable@example.com",
+		"name":      "Durable",
+		"is_active": true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	db.Disconnect(ctx)
+
+	reopened := newTestDatabase(t, dsn)
+	if err := reopened.Migrate(ctx, dbtest.Schemas()); err != nil {
+		t.Fatalf("Failed to re-run migration: %v", err)
+	}
+	user, err := reopened.Repository(dbtest.Schemas()[0]).GetByID(ctx, interfaces.StringID(created["id"].(string)))
+	if err != nil {
+		t.Fatalf("Expected user to survive reconnect: %v", err)
+	}
+	if user["email"] != "durable@example.com" || user["is_active"] != true {
+		t.Fatalf("Unexpected user after reconnect: %v", user)
+	}
+	if _, ok := user["created_at"].(time.Time); !ok {
+		t.Fatalf("Expected created_at to be a time.Time, got %T", user["created_at"])
+	}
+}
+
+func TestSQLiteMigrateAddsColumns(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, ":memory:")
+
+	v1 := &interfaces.Schema{
+		TableName: "ticks",
+		Fields: map[string]interfaces.FieldSchema{
+			"id":    {Type: "string", PrimaryKey: true},
+			"price": {Type: "float64"},
+		},
+	}
+	if err := db.Migrate(ctx, []*interfaces.Schema{v1}); err != nil {
+		t.Fatalf("Failed to migrate v1: %v", err)
+	}
+	if _, err := db.Repository(v1).Create(ctx, map[string]interface{}{"price": 1.5}); err != nil {
+		t.Fatalf("Failed to create tick: %v", err)
+	}
+
+	v2 := &interfaces.Schema{
+		TableName: "ticks",
+		Fields: map[string]interfaces.FieldSchema{
+			"id":     {Type: "string", PrimaryKey: true},
+			"price":  {Type: "float64"},
+			"symbol": {Type: "string", DefaultValue: "SUI"},
+			"ts":     {Type: "time", Nullable: true},
+		},
+		Indexes: []interfaces.Index{{Name: "idx_ticks_symbol", Columns: []string{"symbol"}}},
+	}
+	if err := db.Migrate(ctx, []*interfaces.Schema{v2}); err != nil {
+		t.Fatalf("Failed to migrate v2: %v", err)
+	}
+
+	tick, err := db.Repository(v2).FindOne(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to read tick: %v", err)
+	}
+	if tick["symbol"] != "SUI" || tick["ts"] != nil || tick["price"] != 1.5 {
+		t.Fatalf("Unexpected migrated row: %v", tick)
+	}
+}
+
+func TestSQLiteTimeFilters(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, ":memory:")
+
+	schema := &interfaces.Schema{
+		TableName: "events",
+		Fields: map[string]interfaces.FieldSchema{
+			"id": {Type: "string", PrimaryKey: true},
+			"ts": {Type: "time"},
+		},
+	}
+	if err := db.Migrate(ctx, []*interfaces.Schema{schema}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	repo := db.Repository(schema)
+
+	// Mixed zones and precisions must still compare chronologically
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, ts := range []time.Time{
+		base.Add(-time.Hour).In(time.FixedZone("E", 5*3600)),
+		base.Add(-time.Second / 2),
+		base.Add(time.Hour),
+	} {
+		if _, err := repo.Create(ctx, map[string]interface{}{"ts": ts}); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+
+	count, err := repo.Count(ctx, &interfaces.Query{Where: &interfaces.Filters{
+		Conditions: []interfaces.Filter{{Field: "ts", Operator: &interfaces.FilterOperator{Lt: base}}},
+	}})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 events before base, got %d", count)
+	}
+}
+
+func TestSQLiteNestedTransactionRollsBackSavepoint(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, ":memory:")
+	if err := db.Migrate(ctx, dbtest.Schemas()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	users := db.Repository(dbtest.Schemas()[0])
+
+	err := db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+		if _, err := users.Create(ctx, map[string]interface{}{"email": "outer@example.com", "name": "Outer"}); err != nil {
+			return err
+		}
+		inner := db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+			if _, err := users.Create(ctx, map[string]interface{}{"email": "inner@example.com", "name": "Inner"}); err != nil {
+				return err
+			}
+			return interfaces.ErrInvalidQuery
+		})
+		if !errors.Is(inner, interfaces.ErrInvalidQuery) {
+			t.Errorf("Expected inner transaction error, got %v", inner)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Outer transaction failed: %v", err)
+	}
+
+	count, err := users.Count(ctx, nil)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected only the outer user to be committed, got %d users", count)
+	}
+}
+
+func TestSQLiteLockConflictsAreRetried(t *testing.T) {
+	ctx := context.Background()
+	dsn := "file:" + filepath.Join(t.TempDir(), "locks.db") + "?_pragma=busy_timeout(50)"
+	db := NewDatabase(dsn, Options{MaxOpenConns: 2})
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Disconnect(ctx)
+	if err := db.Migrate(ctx, dbtest.Schemas()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	users := db.Repository(dbtest.Schemas()[0])
+
+	// The first transaction holds the write lock until the second failed once
+	locked, release := make(chan struct{}), make(chan struct{})
+	holder := make(chan error, 1)
+	go func() {
+		holder <- db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+			if _, err := users.Create(ctx, map[string]interface{}{"email": "holder@example.com", "name": "Holder", "is_active": true}); err != nil {
+				close(locked)
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	var first error
+	attempts := 0
+	opts := &interfaces.TxOptions{MaxRetries: 3, RetryBackoff: 20 * time.Millisecond}
+	err := db.TransactionWithOptions(ctx, opts, func(ctx context.Context, tx interfaces.Transaction) error {
+		attempts++
+		_, err := users.Create(ctx, map[string]interface{}{"email": "waiter@example.com", "name": "Waiter", "is_active": true})
+		if attempts == 1 {
+			first = err
+			close(release)
+		}
+		return err
+	})
+	if err := <-holder; err != nil {
+		t.Fatalf("Holding transaction failed: %v", err)
+	}
+	if !errors.Is(first, interfaces.ErrTxConflict) {
+		t.Fatalf("Expected the first attempt to fail with ErrTxConflict, got %v", first)
+	}
+	if err != nil || attempts < 2 {
+		t.Fatalf("Expected a retry to succeed, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestWithPragmas(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{":memory:", ":memory:?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"},
+		{"file:dev.db?_pragma=foreign_keys(0)", "file:dev.db?_pragma=foreign_keys(0)&_pragma=busy_timeout(5000)"},
+	}
+	for _, tt := range tests {
+		if got := withPragmas(tt.dsn); got != tt.want {
+			t.Errorf("withPragmas(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlgen"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// Transaction wraps a SQLite transaction, or a savepoint when nested
+type Transaction struct {
+	mu        sync.Mutex
+	tx        *sql.Tx
+	savepoint string
+	readOnly  bool // query_only is set on the connection until completion
+	completed bool
+	committed bool
+}
+
+// release clears query_only before the connection goes back to the pool
+func (tx *Transaction) release(ctx context.Context) error {
+	if !tx.readOnly {
+		return nil
+	}
+	_, err := tx.tx.ExecContext(ctx, "PRAGMA query_only = OFF")
+	return err
+}
+
+// Commit commits the transaction
+func (tx *Transaction) Commit(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.completed {
+		return interfaces.ErrTransactionCompleted
+	}
+	tx.completed = true
+
+	var err error
+	if tx.savepoint != "" {
+		_, err = tx.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sqlgen.QuoteIdent(tx.savepoint))
+	} else if err = tx.release(ctx); err != nil {
+		tx.tx.Rollback()
+	} else {
+		err = tx.tx.Commit()
+	}
+	if err != nil {
+		return translateError("commit", err)
+	}
+	tx.committed = true
+	return nil
+}
+
+// Rollback rolls back the transaction
+func (tx *Transaction) Rollback(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.completed {
+		return interfaces.ErrTransactionCompleted
+	}
+	tx.completed = true
+
+	var err error
+	if tx.savepoint != "" {
+		name := sqlgen.QuoteIdent(tx.savepoint)
+		if _, err = tx.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); err == nil {
+			_, err = tx.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+		}
+	} else {
+		err = tx.release(ctx)
+		if rollbackErr := tx.tx.Rollback(); err == nil {
+			err = rollbackErr
+		}
+	}
+	if err != nil {
+		return translateError("rollback", err)
+	}
+	return nil
+}
+
+// isCommitted reports whether the transaction was committed
+func (tx *Transaction) isCommitted() bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.committed
+}
+
+// IsCompleted returns true if the transaction has been committed or rolled back
+func (tx *Transaction) IsCompleted() bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.completed
+}
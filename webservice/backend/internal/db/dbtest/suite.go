@@ -0,0 +1,1585 @@
+// Package dbtest provides a conformance suite that every interfaces.Database
+// backend must pass.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// Schemas returns the schemas the suite expects to be migrated
+func Schemas() []*interfaces.Schema {
+	return []*interfaces.Schema{
+		entities.UserSchema,
+		entities.PostSchema,
+		entities.BridgeReceiptSchema,
+	}
+}
+
+// Run exercises CRUD, queries, constraints and transactions against db,
+// which must be connected, migrated with Schemas() and empty.
+func Run(t *testing.T, db interfaces.Database) {
+	ctx := context.Background()
+
+	// Test health check
+	if !db.IsHealthy(ctx) {
+		t.Fatal("Database should be healthy")
+	}
+
+	// Get repositories
+	userRepo := db.Repository(entities.UserSchema)
+	postRepo := db.Repository(entities.PostSchema)
+
+	t.Run("CRUD Operations", func(t *testing.T) {
+		testCRUDOperations(t, ctx, userRepo)
+	})
+
+	t.Run("Query Operations", func(t *testing.T) {
+		testQueryOperations(t, ctx, userRepo)
+	})
+
+	t.Run("Constraint Validation", func(t *testing.T) {
+		testConstraintValidation(t, ctx, userRepo, postRepo)
+	})
+
+	t.Run("Transactions", func(t *testing.T) {
+		testTransactions(t, ctx, db, userRepo)
+	})
+
+	t.Run("Transaction Options", func(t *testing.T) {
+		testTransactionOptions(t, ctx, db, userRepo)
+	})
+
+	t.Run("Relations", func(t *testing.T) {
+		testRelations(t, ctx, userRepo, postRepo)
+	})
+
+	t.Run("Aggregates", func(t *testing.T) {
+		testAggregates(t, ctx, userRepo)
+	})
+
+	t.Run("Bulk Mutations", func(t *testing.T) {
+		testBulkMutations(t, ctx, userRepo)
+	})
+
+	t.Run("Optimistic Locking", func(t *testing.T) {
+		testOptimisticLocking(t, ctx, db.Repository(entities.BridgeReceiptSchema))
+	})
+
+	t.Run("Soft Delete", func(t *testing.T) {
+		testSoftDelete(t, ctx, db.Repository(entities.BridgeReceiptSchema), db.Repository(entities.PostSchema))
+	})
+
+	t.Run("Cursor Pagination", func(t *testing.T) {
+		testCursorPagination(t, ctx, db.Repository(entities.BridgeReceiptSchema))
+	})
+
+	t.Run("JSON Fields", func(t *testing.T) {
+		testJSONFields(t, ctx, db.Repository(entities.BridgeReceiptSchema))
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		testSearch(t, ctx, userRepo, postRepo)
+	})
+
+	t.Run("Indexes", func(t *testing.T) {
+		testIndexes(t, ctx, db)
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		testWatch(t, ctx, db)
+	})
+
+	// Registers hooks on bridge receipts, so it runs last
+	t.Run("Hooks", func(t *testing.T) {
+		testHooks(t, ctx, db)
+	})
+}
+
+func testCRUDOperations(t *testing.T, ctx context.Context, repo interfaces.Repository) {
+	// Create
+	userData := map[string]interface{}{
+		"email":     "test@example.com",
+		"name":      "Test User",
+		"age":       30,
+		"is_active": true,
+	}
+
+	user, err := repo.Create(ctx, userData)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if user["email"] != "test@example.com" {
+		t.Errorf("Expected email 'test@example.com', got '%v'", user["email"])
+	}
+
+	userID := user["id"].(string)
+	if userID == "" {
+		t.Fatal("User ID should not be empty")
+	}
+
+	// Read
+	retrieved, err := repo.GetByID(ctx, interfaces.StringID(userID))
+	if err != nil {
+		t.Fatalf("Failed to get user by ID: %v", err)
+	}
+
+	if retrieved["email"] != "test@example.com" {
+		t.Errorf("Expected email 'test@example.com', got '%v'", retrieved["email"])
+	}
+
+	// Update
+	updated, err := repo.Update(ctx, interfaces.StringID(userID), map[string]interface{}{
+		"name": "Updated User",
+		"age":  35,
+	})
+	if err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	if updated["name"] != "Updated User" {
+		t.Errorf("Expected name 'Updated User', got '%v'", updated["name"])
+	}
+	if updated["age"] != 35 {
+		t.Errorf("Expected age 35, got '%v'", updated["age"])
+	}
+
+	// Delete
+	if err := repo.Delete(ctx, interfaces.StringID(userID)); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	// Verify deletion
+	_, err = repo.GetByID(ctx, interfaces.StringID(userID))
+	if err != interfaces.ErrNotFound {
+		t.Errorf("Expected ErrNotFound after deletion, got: %v", err)
+	}
+}
+
+func testQueryOperations(t *testing.T, ctx context.Context, repo interfaces.Repository) {
+	// Create test data
+	users := []map[string]interface{}{
+		{"email": "alice@example.com", "name": "Alice", "age": 25, "is_active": true},
+		{"email": "bob@example.com", "name": "Bob", "age": 30, "is_active": false},
+		{"email": "charlie@example.com", "name": "Charlie", "age": 35, "is_active": true},
+	}
+
+	for _, userData := range users {
+		if _, err := repo.Create(ctx, userData); err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
+	}
+
+	// Test filtering
+	result, err := repo.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{
+				{Field: "is_active", Value: true},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to find active users: %v", err)
+	}
+
+	if result.Total != 2 {
+		t.Errorf("Expected 2 active users, got %d", result.Total)
+	}
+
+	// Test sorting
+	result, err = repo.FindMany(ctx, &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{
+			{Field: "age", Direction: "desc"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to sort users: %v", err)
+	}
+
+	if len(result.Data) != 3 {
+		t.Errorf("Expected 3 users, got %d", len(result.Data))
+	}
+
+	// Check sorting order
+	if result.Data[0]["age"] != 35 {
+		t.Errorf("Expected first user age 35, got %v", result.Data[0]["age"])
+	}
+
+	// Test pagination
+	limit := 2
+	result, err = repo.FindMany(ctx, &interfaces.Query{
+		Limit: &limit,
+		OrderBy: []interfaces.OrderBy{
+			{Field: "name", Direction: "asc"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to paginate users: %v", err)
+	}
+
+	if len(result.Data) != 2 {
+		t.Errorf("Expected 2 users per page, got %d", len(result.Data))
+	}
+	if result.Total != 3 {
+		t.Errorf("Expected total 3 users, got %d", result.Total)
+	}
+
+	// Test count
+	count, err := repo.Count(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{
+				{Field: "is_active", Value: true},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to count active users: %v", err)
+	}
+
+	if count != 2 {
+		t.Errorf("Expected count 2, got %d", count)
+	}
+}
+
+func testConstraintValidation(t *testing.T, ctx context.Context, userRepo, postRepo interfaces.Repository) {
+	// Create a user first
+	user, err := userRepo.Create(ctx, map[string]interface{}{
+		"email":     "constraint@example.com",
+		"name":      "Constraint User",
+		"is_active": true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	userID := user["id"].(string)
+
+	// Test unique constraint violation
+	_, err = userRepo.Create(ctx, map[string]interface{}{
+		"email":     "constraint@example.com", // Duplicate email
+		"name":      "Another User",
+		"is_active": true,
+	})
+	if err == nil {
+		t.Error("Expected unique constraint error for duplicate email")
+	}
+
+	// Test foreign key constraint - valid reference
+	_, err = postRepo.Create(ctx, map[string]interface{}{
+		"title":     "Test Post",
+		"content":   "Test content",
+		"author_id": userID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create post with valid foreign key: %v", err)
+	}
+
+	// Test foreign key constraint - invalid reference
+	_, err = postRepo.Create(ctx, map[string]interface{}{
+		"title":     "Invalid Post",
+		"content":   "Test content",
+		"author_id": "non-existent-id",
+	})
+	if err == nil {
+		t.Error("Expected foreign key constraint error for invalid author_id")
+	}
+}
+
+func testJSONFields(t *testing.T, ctx context.Context, repo interfaces.Repository) {
+	create := func(txHash string, metadata interface{}) map[string]interface{} {
+		t.Helper()
+		record, err := repo.Create(ctx, map[string]interface{}{
+			"tx_hash":   txHash,
+			"sui_owner": "0xjson",
+			"chain_id":  "1",
+			"asset":     "ETH",
+			"metadata":  metadata,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create receipt: %v", err)
+		}
+		return record
+	}
+
+	fast := create("0xjson-fast", map[string]interface{}{
+		"source": map[string]interface{}{"chain": "ethereum", "block": 12},
+		"tags":   []string{"fast", "l1"},
+		"memo":   nil,
+	})
+	create("0xjson-slow", map[string]interface{}{
+		"source": map[string]interface{}{"chain": "arbitrum", "block": 40},
+		"tags":   []string{"l2"},
+	})
+	create("0xjson-none", nil)
+
+	// Documents read back as decoded JSON, numbers as float64
+	want := map[string]interface{}{
+		"source": map[string]interface{}{"chain": "ethereum", "block": float64(12)},
+		"tags":   []interface{}{"fast", "l1"},
+		"memo":   nil,
+	}
+	if !reflect.DeepEqual(fast["metadata"], want) {
+		t.Fatalf("Expected created metadata %v, got %v", want, fast["metadata"])
+	}
+	got, err := repo.GetByID(ctx, interfaces.StringID(fast["id"].(string)))
+	if err != nil || !reflect.DeepEqual(got["metadata"], want) {
+		t.Fatalf("Expected stored metadata %v, got %v (%v)", want, got["metadata"], err)
+	}
+
+	find := func(condition interfaces.Filter) []interface{} {
+		t.Helper()
+		result, err := repo.FindMany(ctx, &interfaces.Query{
+			Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+				{Field: "sui_owner", Value: "0xjson"},
+				condition,
+			}},
+			OrderBy: []interfaces.OrderBy{{Field: "tx_hash", Direction: "asc"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to filter by %+v: %v", condition, err)
+		}
+		var hashes []interface{}
+		for _, record := range result.Data {
+			hashes = append(hashes, record["tx_hash"])
+		}
+		return hashes
+	}
+	for _, tc := range []struct {
+		name      string
+		condition interfaces.Filter
+		want      []interface{}
+	}{
+		{"path equality", interfaces.Filter{Field: "metadata", Path: []string{"source", "chain"}, Value: "ethereum"}, []interface{}{"0xjson-fast"}},
+		{"numeric path", interfaces.Filter{Field: "metadata", Path: []string{"source", "block"}, Operator: &interfaces.FilterOperator{Eq: 40}}, []interface{}{"0xjson-slow"}},
+		{"path inequality", interfaces.Filter{Field: "metadata", Path: []string{"source", "chain"}, Operator: &interfaces.FilterOperator{Ne: "ethereum"}}, []interface{}{"0xjson-none", "0xjson-slow"}},
+		{"whole document", interfaces.Filter{Field: "metadata", Value: map[string]interface{}{"source": map[string]interface{}{"chain": "arbitrum", "block": 40}, "tags": []string{"l2"}}}, []interface{}{"0xjson-slow"}},
+		{"contains object", interfaces.Filter{Field: "metadata", Operator: &interfaces.FilterOperator{Contains: map[string]interface{}{"source": map[string]interface{}{"chain": "arbitrum"}}}}, []interface{}{"0xjson-slow"}},
+		{"contains array", interfaces.Filter{Field: "metadata", Operator: &interfaces.FilterOperator{Contains: map[string]interface{}{"tags": []string{"l1"}}}}, []interface{}{"0xjson-fast"}},
+		{"contains at path", interfaces.Filter{Field: "metadata", Path: []string{"tags"}, Operator: &interfaces.FilterOperator{Contains: "fast"}}, []interface{}{"0xjson-fast"}},
+		{"null path", interfaces.Filter{Field: "metadata", Path: []string{"memo"}, Operator: &interfaces.FilterOperator{IsNull: true}}, []interface{}{"0xjson-fast", "0xjson-none", "0xjson-slow"}},
+		{"present path", interfaces.Filter{Field: "metadata", Path: []string{"tags"}, Operator: &interfaces.FilterOperator{IsNotNull: true}}, []interface{}{"0xjson-fast", "0xjson-slow"}},
+		{"null document", interfaces.Filter{Field: "metadata", Value: nil}, []interface{}{"0xjson-none"}},
+	} {
+		if hashes := find(tc.condition); !reflect.DeepEqual(hashes, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, hashes)
+		}
+	}
+
+	updated, err := repo.Update(ctx, interfaces.StringID(fast["id"].(string)), map[string]interface{}{
+		"metadata": map[string]interface{}{"source": map[string]interface{}{"chain": "base"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update metadata: %v", err)
+	}
+	if chain, _ := query.JSONPath(updated["metadata"], []string{"source", "chain"}); chain != "base" {
+		t.Errorf("Expected updated chain base, got %v", updated["metadata"])
+	}
+
+	for _, condition := range []interfaces.Filter{
+		{Field: "metadata", Operator: &interfaces.FilterOperator{Gt: 1}},
+		{Field: "asset", Path: []string{"symbol"}, Value: "ETH"},
+		{Field: "asset", Operator: &interfaces.FilterOperator{Contains: "ETH"}},
+	} {
+		_, err := repo.FindMany(ctx, &interfaces.Query{Where: &interfaces.Filters{Conditions: []interfaces.Filter{condition}}})
+		if !errors.Is(err, interfaces.ErrInvalidQuery) {
+			t.Errorf("Expected ErrInvalidQuery for %+v, got %v", condition, err)
+		}
+	}
+}
+
+// indexProbeSchema returns the schema of a table used to test index
+// migrations, with the slot index unique or not
+func indexProbeSchema(unique bool) *interfaces.Schema {
+	return &interfaces.Schema{
+		TableName: "index_probes",
+		Fields: map[string]interfaces.FieldSchema{
+			"id":         {Type: "string", PrimaryKey: true},
+			"owner":      {Type: "string"},
+			"slot":       {Type: "int"},
+			"label":      {Type: "string", Nullable: true},
+			"created_at": {Type: "time"},
+			"updated_at": {Type: "time"},
+		},
+		Indexes: []interfaces.Index{
+			{Name: "idx_index_probes_owner_slot", Columns: []string{"owner", "slot"}, Unique: unique},
+			{Name: "idx_index_probes_owner_label", Columns: []string{"owner", "label"}, Unique: true},
+		},
+	}
+}
+
+func testSearch(t *testing.T, ctx context.Context, userRepo, postRepo interfaces.Repository) {
+	author, err := userRepo.Create(ctx, map[string]interface{}{
+		"email":     "searcher@example.com",
+		"name":      "Searcher",
+		"is_active": true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create author: %v", err)
+	}
+
+	ids := make(map[string]interface{})
+	for title, content := range map[string]string{
+		"short":   "Bridge fees",
+		"long":    "Notes on the fees of the Sui bridge, and on withdrawal limits",
+		"partial": "Bridge status page",
+		"other":   "Staking rewards",
+	} {
+		post, err := postRepo.Create(ctx, map[string]interface{}{
+			"title":     title,
+			"content":   content,
+			"author_id": author["id"],
+		})
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		ids[title] = post["id"]
+	}
+
+	search := func(text string, orderBy ...interfaces.OrderBy) *interfaces.ResultPage {
+		t.Helper()
+		result, err := postRepo.FindMany(ctx, &interfaces.Query{
+			Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+				{Field: "author_id", Value: author["id"]},
+				{Field: "content", Operator: &interfaces.FilterOperator{Search: text}},
+			}},
+			OrderBy: orderBy,
+		})
+		if err != nil {
+			t.Fatalf("Failed to search %q: %v", text, err)
+		}
+		if len(result.Ranks) != len(result.Data) {
+			t.Fatalf("Expected a rank per record, got %d ranks for %d records", len(result.Ranks), len(result.Data))
+		}
+		for _, rank := range result.Ranks {
+			if rank <= 0 {
+				t.Errorf("Expected positive ranks for matches, got %v", result.Ranks)
+			}
+		}
+		return result
+	}
+	titles := func(result *interfaces.ResultPage) []interface{} {
+		var titles []interface{}
+		for _, record := range result.Data {
+			titles = append(titles, record["title"])
+		}
+		return titles
+	}
+
+	// Every word must match, in any case and order
+	result := search("FEES bridge")
+	if result.Total != 2 || len(result.Data) != 2 {
+		t.Fatalf("Expected 2 posts about bridge fees, got %d: %v", result.Total, titles(result))
+	}
+	if result.Ranks[0] < result.Ranks[1] {
+		t.Errorf("Expected searches without an order to rank best first, got %v", result.Ranks)
+	}
+
+	// An explicit order takes precedence over rank
+	result = search("bridge fees", interfaces.OrderBy{Field: "title", Direction: "asc"})
+	if want := []interface{}{"long", "short"}; !reflect.DeepEqual(titles(result), want) {
+		t.Errorf("Expected %v in title order, got %v", want, titles(result))
+	}
+
+	// Changed and deleted records are searched as they are now
+	if _, err := postRepo.Update(ctx, interfaces.StringID(fmt.Sprint(ids["partial"])), map[string]interface{}{
+		"content": "Bridge fees explained",
+	}); err != nil {
+		t.Fatalf("Failed to update post: %v", err)
+	}
+	if err := postRepo.Delete(ctx, interfaces.StringID(fmt.Sprint(ids["short"]))); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	result = search("bridge fees", interfaces.OrderBy{Field: "title", Direction: "asc"})
+	if want := []interface{}{"long", "partial"}; !reflect.DeepEqual(titles(result), want) {
+		t.Errorf("Expected %v after changes, got %v", want, titles(result))
+	}
+
+	if result := search("bridge custody"); len(result.Data) != 0 || result.Total != 0 {
+		t.Errorf("Expected no post to match every word, got %v", titles(result))
+	}
+
+	// Ranks are only returned for searches
+	plain, err := postRepo.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{{Field: "author_id", Value: author["id"]}}},
+	})
+	if err != nil || plain.Ranks != nil {
+		t.Errorf("Expected no ranks without Search, got %v (%v)", plain.Ranks, err)
+	}
+
+	_, err = postRepo.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "published_at", Operator: &interfaces.FilterOperator{Search: "bridge"}},
+		}},
+	})
+	if !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery searching a time field, got %v", err)
+	}
+}
+
+func testIndexes(t *testing.T, ctx context.Context, db interfaces.Database) {
+	// The table outlives the suite on persistent databases, so start from
+	// the non-unique definition and no rows
+	if err := db.Migrate(ctx, []*interfaces.Schema{indexProbeSchema(false)}); err != nil {
+		t.Fatalf("Failed to migrate index probes: %v", err)
+	}
+	repo := db.Repository(indexProbeSchema(false))
+	existing, err := repo.FindMany(ctx, &interfaces.Query{})
+	if err != nil {
+		t.Fatalf("Failed to list index probes: %v", err)
+	}
+	for _, record := range existing.Data {
+		if err := repo.Delete(ctx, interfaces.StringID(record["id"].(string))); err != nil {
+			t.Fatalf("Failed to clear index probes: %v", err)
+		}
+	}
+
+	create := func(repo interfaces.Repository, slot int, label interface{}) (map[string]interface{}, error) {
+		return repo.Create(ctx, map[string]interface{}{"owner": "0xindex", "slot": slot, "label": label})
+	}
+
+	first, err := create(repo, 1, nil)
+	if err != nil {
+		t.Fatalf("Failed to create probe: %v", err)
+	}
+	if _, err := create(repo, 1, "b"); err != nil {
+		t.Fatalf("Non-unique index should allow a repeated slot: %v", err)
+	}
+	// Nulls never conflict in a unique index
+	if _, err := create(repo, 2, nil); err != nil {
+		t.Fatalf("Unique index should allow repeated nulls: %v", err)
+	}
+	if _, err := create(repo, 3, "b"); !errors.Is(err, interfaces.ErrUniqueConstraint) {
+		t.Fatalf("Expected ErrUniqueConstraint for a repeated owner and label, got %v", err)
+	}
+
+	// Making the index unique fails while rows violate it
+	if err := db.Migrate(ctx, []*interfaces.Schema{indexProbeSchema(true)}); err == nil {
+		t.Fatal("Expected migrating to a unique index over duplicates to fail")
+	}
+	if _, err := create(repo, 2, "c"); err != nil {
+		t.Fatalf("Failed migration should leave the index non-unique: %v", err)
+	}
+
+	if err := repo.Delete(ctx, interfaces.StringID(first["id"].(string))); err != nil {
+		t.Fatalf("Failed to delete probe: %v", err)
+	}
+	if err := db.Migrate(ctx, []*interfaces.Schema{indexProbeSchema(true)}); err == nil {
+		t.Fatal("Expected migrating to a unique index over duplicates to fail")
+	}
+	remaining, err := repo.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{{Field: "slot", Value: 2}, {Field: "label", Value: "c"}}},
+	})
+	if err != nil || len(remaining.Data) != 1 {
+		t.Fatalf("Expected one probe in slot 2 labelled c, got %v (%v)", remaining, err)
+	}
+	if err := repo.Delete(ctx, interfaces.StringID(remaining.Data[0]["id"].(string))); err != nil {
+		t.Fatalf("Failed to delete probe: %v", err)
+	}
+
+	if err := db.Migrate(ctx, []*interfaces.Schema{indexProbeSchema(true)}); err != nil {
+		t.Fatalf("Migrating to a unique index should succeed without duplicates: %v", err)
+	}
+	repo = db.Repository(indexProbeSchema(true))
+	if _, err := create(repo, 1, "d"); !errors.Is(err, interfaces.ErrUniqueConstraint) {
+		t.Fatalf("Expected ErrUniqueConstraint for a repeated owner and slot, got %v", err)
+	}
+	if _, err := create(repo, 4, "d"); err != nil {
+		t.Fatalf("Failed to create probe in a free slot: %v", err)
+	}
+}
+
+func testTransactions(t *testing.T, ctx context.Context, db interfaces.Database, repo interfaces.Repository) {
+	// Test successful transaction
+	err := db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+		_, err := repo.Create(ctx, map[string]interface{}{
+			"email":     "tx@example.com",
+			"name":      "TX User",
+			"is_active": true,
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction should succeed: %v", err)
+	}
+
+	// Verify user was created
+	result, err := repo.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{
+				{Field: "email", Value: "tx@example.com"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to find transaction user: %v", err)
+	}
+	if result.Total != 1 {
+		t.Errorf("Expected 1 user from successful transaction, got %d", result.Total)
+	}
+
+	// Test failed transaction (should rollback)
+	err = db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+		_, err := repo.Create(ctx, map[string]interface{}{
+			"email":     "rollback@example.com",
+			"name":      "Rollback User",
+			"is_active": true,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Force an error to trigger rollback
+		return interfaces.ErrInvalidQuery
+	})
+	if err == nil {
+		t.Error("Transaction should fail")
+	}
+
+	// Verify user was not created due to rollback
+	result, err = repo.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{
+				{Field: "email", Value: "rollback@example.com"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to search for rollback user: %v", err)
+	}
+	if result.Total != 0 {
+		t.Errorf("Expected 0 users after rollback, got %d", result.Total)
+	}
+}
+
+func testTransactionOptions(t *testing.T, ctx context.Context, db interfaces.Database, repo interfaces.Repository) {
+	// Read-only transactions read but do not write
+	err := db.TransactionWithOptions(ctx, &interfaces.TxOptions{ReadOnly: true}, func(ctx context.Context, tx interfaces.Transaction) error {
+		if _, err := repo.Count(ctx, nil); err != nil {
+			return err
+		}
+		_, err := repo.Create(ctx, map[string]interface{}{
+			"email":     "readonly@example.com",
+			"name":      "Read Only",
+			"is_active": true,
+		})
+		return err
+	})
+	if !errors.Is(err, interfaces.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got %v", err)
+	}
+
+	// Later transactions write again
+	if _, err := repo.Create(ctx, map[string]interface{}{
+		"email":     "after-readonly@example.com",
+		"name":      "After Read Only",
+		"is_active": true,
+	}); err != nil {
+		t.Fatalf("Expected writes after a read-only transaction to succeed: %v", err)
+	}
+
+	// Conflicts are retried in a new transaction, rolling back each attempt
+	opts := &interfaces.TxOptions{
+		Isolation:    interfaces.IsolationSerializable,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}
+	attempts := 0
+	err = db.TransactionWithOptions(ctx, opts, func(ctx context.Context, tx interfaces.Transaction) error {
+		attempts++
+		if _, err := repo.Create(ctx, map[string]interface{}{
+			"email":     "retry@example.com",
+			"name":      "Retry",
+			"is_active": true,
+		}); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return fmt.Errorf("attempt %d: %w", attempts, interfaces.ErrTxConflict)
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("Expected the third attempt to succeed, got %v after %d attempts", err, attempts)
+	}
+	count, err := repo.Count(ctx, &interfaces.Query{Where: &interfaces.Filters{
+		Conditions: []interfaces.Filter{{Field: "email", Value: "retry@example.com"}},
+	}})
+	if err != nil || count != 1 {
+		t.Fatalf("Expected one retried user, got %d (%v)", count, err)
+	}
+
+	// Retries run out, and other errors are not retried
+	attempts = 0
+	err = db.TransactionWithOptions(ctx, opts, func(ctx context.Context, tx interfaces.Transaction) error {
+		attempts++
+		return interfaces.ErrTxConflict
+	})
+	if !errors.Is(err, interfaces.ErrTxConflict) || attempts != 3 {
+		t.Fatalf("Expected ErrTxConflict after 3 attempts, got %v after %d", err, attempts)
+	}
+	attempts = 0
+	err = db.TransactionWithOptions(ctx, opts, func(ctx context.Context, tx interfaces.Transaction) error {
+		attempts++
+		return interfaces.ErrInvalidQuery
+	})
+	if !errors.Is(err, interfaces.ErrInvalidQuery) || attempts != 1 {
+		t.Fatalf("Expected one attempt failing with ErrInvalidQuery, got %v after %d", err, attempts)
+	}
+}
+
+func testRelations(t *testing.T, ctx context.Context, userRepo, postRepo interfaces.Repository) {
+	author, err := userRepo.Create(ctx, map[string]interface{}{
+		"email":     "author@example.com",
+		"name":      "Author",
+		"is_active": true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create author: %v", err)
+	}
+	lurker, err := userRepo.Create(ctx, map[string]interface{}{
+		"email":     "lurker@example.com",
+		"name":      "Lurker",
+		"is_active": true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create lurker: %v", err)
+	}
+
+	for _, title := range []string{"First", "Second"} {
+		if _, err := postRepo.Create(ctx, map[string]interface{}{
+			"title":     title,
+			"content":   "Relation content",
+			"author_id": author["id"],
+		}); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+	}
+
+	byEmail := &interfaces.Filters{
+		Conditions: []interfaces.Filter{
+			{
+				Field:    "email",
+				Operator: &interfaces.FilterOperator{In: []interface{}{"author@example.com", "lurker@example.com"}},
+			},
+		},
+	}
+
+	// hasMany
+	users, err := userRepo.FindMany(ctx, &interfaces.Query{
+		Where:   byEmail,
+		OrderBy: []interfaces.OrderBy{{Field: "name", Direction: "asc"}},
+		Include: []string{"posts"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to find users with posts: %v", err)
+	}
+	if len(users.Data) != 2 {
+		t.Fatalf("Expected 2 users, got %d", len(users.Data))
+	}
+	posts, ok := users.Data[0]["posts"].([]map[string]interface{})
+	if !ok || len(posts) != 2 {
+		t.Fatalf("Expected author to include 2 posts, got %v", users.Data[0]["posts"])
+	}
+	for _, post := range posts {
+		if post["author_id"] != author["id"] {
+			t.Errorf("Expected post of %v, got author_id %v", author["id"], post["author_id"])
+		}
+	}
+	if posts, ok := users.Data[1]["posts"].([]map[string]interface{}); !ok || len(posts) != 0 {
+		t.Errorf("Expected lurker to include no posts, got %v", users.Data[1]["posts"])
+	}
+
+	// belongsTo
+	post, err := postRepo.FindOne(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{{Field: "title", Value: "First"}},
+		},
+		Include: []string{"author"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to find post with author: %v", err)
+	}
+	parent, ok := post["author"].(map[string]interface{})
+	if !ok || parent["name"] != "Author" {
+		t.Errorf("Expected post to include its author, got %v", post["author"])
+	}
+
+	// Nested includes
+	lurkerOnly := &interfaces.Filters{
+		Conditions: []interfaces.Filter{{Field: "id", Value: lurker["id"]}},
+	}
+	nested, err := userRepo.FindOne(ctx, &interfaces.Query{
+		Where:   &interfaces.Filters{Conditions: []interfaces.Filter{{Field: "id", Value: author["id"]}}},
+		Include: []string{"posts.author"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to find nested includes: %v", err)
+	}
+	for _, post := range nested["posts"].([]map[string]interface{}) {
+		parent, ok := post["author"].(map[string]interface{})
+		if !ok || parent["id"] != author["id"] {
+			t.Errorf("Expected nested author %v, got %v", author["id"], post["author"])
+		}
+	}
+
+	// Key columns needed by includes are fetched but not returned
+	selected, err := userRepo.FindOne(ctx, &interfaces.Query{
+		Where:   lurkerOnly,
+		Select:  []string{"name"},
+		Include: []string{"posts"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to find with select and include: %v", err)
+	}
+	if _, exists := selected["id"]; exists {
+		t.Errorf("Expected id to be stripped from selection, got %v", selected)
+	}
+	if selected["name"] != "Lurker" {
+		t.Errorf("Expected name 'Lurker', got %v", selected["name"])
+	}
+	if _, exists := selected["posts"]; !exists {
+		t.Errorf("Expected posts to be included, got %v", selected)
+	}
+
+	// Unknown relations are rejected
+	_, err = userRepo.FindMany(ctx, &interfaces.Query{Include: []string{"comments"}})
+	if !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for unknown relation, got %v", err)
+	}
+}
+
+func testAggregates(t *testing.T, ctx context.Context, repo interfaces.Repository) {
+	ages := []interface{}{20, 30, nil, 40}
+	for i, age := range ages {
+		data := map[string]interface{}{
+			"email":     fmt.Sprintf("agg%d@aggregate.test", i),
+			"name":      fmt.Sprintf("Aggregate %d", i),
+			"is_active": i < 3,
+		}
+		if age != nil {
+			data["age"] = age
+		}
+		if _, err := repo.Create(ctx, data); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	onlyAggregate := &interfaces.Filters{
+		Conditions: []interfaces.Filter{
+			{Field: "email", Operator: &interfaces.FilterOperator{Like: "%@aggregate.test"}},
+		},
+	}
+
+	// Grouped aggregates, ordered by the group field by default
+	rows, err := repo.Aggregate(ctx, &interfaces.AggregateQuery{
+		Where:   onlyAggregate,
+		GroupBy: []interfaces.GroupBy{{Field: "is_active"}},
+		Aggregates: []interfaces.Aggregate{
+			{Function: "count"},
+			{Function: "count", Field: "age", Alias: "aged"},
+			{Function: "sum", Field: "age"},
+			{Function: "avg", Field: "age"},
+			{Function: "min", Field: "age"},
+			{Function: "max", Field: "age"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 groups, got %d: %v", len(rows), rows)
+	}
+	expected := []map[string]interface{}{
+		{"is_active": false, "count": int64(1), "aged": int64(1), "sum_age": int64(40), "avg_age": 40.0, "min_age": 40, "max_age": 40},
+		{"is_active": true, "count": int64(3), "aged": int64(2), "sum_age": int64(50), "avg_age": 25.0, "min_age": 20, "max_age": 30},
+	}
+	for i, want := range expected {
+		for key, value := range want {
+			if rows[i][key] != value {
+				t.Errorf("Group %d: expected %s = %v (%T), got %v (%T)", i, key, value, value, rows[i][key], rows[i][key])
+			}
+		}
+	}
+
+	// Having and ordering by aliases
+	rows, err = repo.Aggregate(ctx, &interfaces.AggregateQuery{
+		Where:      onlyAggregate,
+		GroupBy:    []interfaces.GroupBy{{Field: "is_active"}},
+		Aggregates: []interfaces.Aggregate{{Function: "count"}},
+		Having: &interfaces.Filters{
+			Conditions: []interfaces.Filter{
+				{Field: "count", Operator: &interfaces.FilterOperator{Gte: 2}},
+			},
+		},
+		OrderBy: []interfaces.OrderBy{{Field: "count", Direction: "desc"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to aggregate with having: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["is_active"] != true {
+		t.Errorf("Expected only the active group, got %v", rows)
+	}
+
+	// Without group fields there is always exactly one row
+	rows, err = repo.Aggregate(ctx, &interfaces.AggregateQuery{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{{Field: "email", Value: "nobody@aggregate.test"}},
+		},
+		Aggregates: []interfaces.Aggregate{{Function: "count"}, {Function: "sum", Field: "age"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to aggregate empty set: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["count"] != int64(0) || rows[0]["sum_age"] != nil {
+		t.Errorf("Expected a single row with count 0 and nil sum, got %v", rows)
+	}
+
+	// Time buckets
+	rows, err = repo.Aggregate(ctx, &interfaces.AggregateQuery{
+		Where:      onlyAggregate,
+		GroupBy:    []interfaces.GroupBy{{Field: "created_at", Truncate: "day"}},
+		Aggregates: []interfaces.Aggregate{{Function: "count"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to aggregate by day: %v", err)
+	}
+	var total int64
+	for _, row := range rows {
+		day, ok := row["created_at"].(time.Time)
+		if !ok {
+			t.Fatalf("Expected time bucket, got %v (%T)", row["created_at"], row["created_at"])
+		}
+		if day = day.UTC(); !day.Equal(day.Truncate(24 * time.Hour)) {
+			t.Errorf("Expected bucket at midnight UTC, got %v", day)
+		}
+		total += row["count"].(int64)
+	}
+	if total != 4 {
+		t.Errorf("Expected 4 records across day buckets, got %d", total)
+	}
+
+	// Invalid aggregates are rejected
+	_, err = repo.Aggregate(ctx, &interfaces.AggregateQuery{
+		Aggregates: []interfaces.Aggregate{{Function: "sum", Field: "name"}},
+	})
+	if !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for sum of a string field, got %v", err)
+	}
+}
+
+func testBulkMutations(t *testing.T, ctx context.Context, repo interfaces.Repository) {
+	opts := &interfaces.BulkOptions{BatchSize: 2}
+
+	created, err := repo.CreateMany(ctx, []map[string]interface{}{
+		{"email": "bulk0@example.com", "name": "Bulk 0"},
+		{"email": "bulk1@example.com"}, // Missing name
+		{"email": "bulk2@example.com", "name": "Bulk 2"},
+		{"email": "bulk0@example.com", "name": "Duplicate"},
+		{"email": "bulk4@example.com", "name": "Bulk 4", "age": 44},
+	}, opts)
+	if err != nil {
+		t.Fatalf("CreateMany failed: %v", err)
+	}
+	if created.Affected != 3 {
+		t.Errorf("Expected 3 created rows, got %d", created.Affected)
+	}
+	if len(created.Errors) != 2 || created.Errors[0].Index != 1 || created.Errors[1].Index != 3 {
+		t.Fatalf("Expected errors for rows 1 and 3, got %v", created.Errors)
+	}
+	if !errors.Is(created.Err(), interfaces.ErrUniqueConstraint) {
+		t.Errorf("Expected a unique constraint error, got %v", created.Err())
+	}
+	if created.Records[1] != nil || created.Records[3] != nil {
+		t.Errorf("Expected no records for failed rows, got %v", created.Records)
+	}
+	for _, i := range []int{0, 2, 4} {
+		if created.Records[i] == nil || created.Records[i]["id"] == nil {
+			t.Fatalf("Expected record %d with an id, got %v", i, created.Records[i])
+		}
+	}
+	if created.Records[4]["age"] != 44 || created.Records[0]["is_active"] != true {
+		t.Errorf("Expected stored values and defaults, got %v and %v", created.Records[4], created.Records[0])
+	}
+
+	id := func(i int) interfaces.ID {
+		return interfaces.StringID(created.Records[i]["id"].(string))
+	}
+
+	updated, err := repo.UpdateMany(ctx, []interfaces.BulkUpdate{
+		{ID: id(0), Data: map[string]interface{}{"name": "Renamed 0"}},
+		{ID: id(4), Data: map[string]interface{}{"email": "bulk2@example.com"}},
+		{ID: interfaces.StringID("missing-bulk-id"), Data: map[string]interface{}{"name": "Nobody"}},
+		{ID: id(2), Data: map[string]interface{}{"name": "Renamed 2", "age": 22}},
+	}, opts)
+	if err != nil {
+		t.Fatalf("UpdateMany failed: %v", err)
+	}
+	if updated.Affected != 2 || len(updated.Errors) != 2 {
+		t.Fatalf("Expected 2 updated rows and 2 errors, got %d and %v", updated.Affected, updated.Errors)
+	}
+	if !errors.Is(updated.Errors[0].Err, interfaces.ErrUniqueConstraint) || updated.Errors[0].Index != 1 {
+		t.Errorf("Expected unique constraint error for row 1, got %v", updated.Errors[0])
+	}
+	if !errors.Is(updated.Errors[1].Err, interfaces.ErrNotFound) || updated.Errors[1].Index != 2 {
+		t.Errorf("Expected not found error for row 2, got %v", updated.Errors[1])
+	}
+	if updated.Records[0]["name"] != "Renamed 0" || updated.Records[3]["age"] != 22 {
+		t.Errorf("Expected updated records, got %v", updated.Records)
+	}
+	stored, err := repo.GetByID(ctx, id(4))
+	if err != nil || stored["email"] != "bulk4@example.com" {
+		t.Errorf("Expected failed update to leave row unchanged, got %v (%v)", stored, err)
+	}
+
+	deleted, err := repo.DeleteMany(ctx, []interfaces.ID{id(0), interfaces.StringID("missing-bulk-id"), id(2)}, opts)
+	if err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	if deleted.Affected != 2 || len(deleted.Errors) != 1 || deleted.Errors[0].Index != 1 {
+		t.Fatalf("Expected 2 deleted rows and an error for row 1, got %d and %v", deleted.Affected, deleted.Errors)
+	}
+	if _, err := repo.GetByID(ctx, id(0)); !errors.Is(err, interfaces.ErrNotFound) {
+		t.Errorf("Expected deleted row to be gone, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, id(4)); err != nil {
+		t.Errorf("Expected untouched row to remain, got %v", err)
+	}
+}
+
+func testOptimisticLocking(t *testing.T, ctx context.Context, repo interfaces.Repository) {
+	receipt, err := repo.Create(ctx, map[string]interface{}{
+		"tx_hash":   "0xlocking",
+		"sui_owner": "0xowner",
+		"chain_id":  "1",
+		"asset":     "ETH",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create receipt: %v", err)
+	}
+	if receipt["version"] != int64(1) {
+		t.Fatalf("Expected initial version 1, got %v (%T)", receipt["version"], receipt["version"])
+	}
+	id := interfaces.StringID(receipt["id"].(string))
+
+	updated, err := repo.Update(ctx, id, map[string]interface{}{"status": "minted", "version": int64(1)})
+	if err != nil {
+		t.Fatalf("Failed to update with current version: %v", err)
+	}
+	if updated["version"] != int64(2) || updated["status"] != "minted" {
+		t.Errorf("Expected version 2 and status minted, got %v", updated)
+	}
+
+	// A writer still holding version 1 loses
+	if _, err := repo.Update(ctx, id, map[string]interface{}{"status": "failed", "version": 1}); !errors.Is(err, interfaces.ErrConflict) {
+		t.Fatalf("Expected ErrConflict for stale version, got %v", err)
+	}
+	stored, err := repo.GetByID(ctx, id)
+	if err != nil || stored["status"] != "minted" || stored["version"] != int64(2) {
+		t.Errorf("Expected conflicting update to change nothing, got %v (%v)", stored, err)
+	}
+
+	// Updates without a version apply unconditionally but still bump it
+	updated, err = repo.Update(ctx, id, map[string]interface{}{"minted": "100"})
+	if err != nil || updated["version"] != int64(3) {
+		t.Errorf("Expected unconditional update to version 3, got %v (%v)", updated, err)
+	}
+
+	if _, err := repo.Update(ctx, interfaces.StringID("missing-receipt"), map[string]interface{}{"version": 1}); !errors.Is(err, interfaces.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown receipt, got %v", err)
+	}
+	if _, err := repo.Update(ctx, id, map[string]interface{}{"version": "3"}); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for non-integer version, got %v", err)
+	}
+
+	// Concurrent writers with the same expected version: exactly one wins
+	const writers = 5
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = repo.Update(ctx, id, map[string]interface{}{"status": fmt.Sprintf("writer-%d", i), "version": int64(3)})
+		}(i)
+	}
+	wg.Wait()
+	wins := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			wins++
+		case !errors.Is(err, interfaces.ErrConflict):
+			t.Errorf("Expected ErrConflict for losing writer, got %v", err)
+		}
+	}
+	if wins != 1 {
+		t.Errorf("Expected exactly one concurrent writer to win, got %d", wins)
+	}
+
+	// Batch updates report stale rows individually
+	other, err := repo.Create(ctx, map[string]interface{}{
+		"tx_hash":   "0xlocking-other",
+		"sui_owner": "0xowner",
+		"chain_id":  "1",
+		"asset":     "ETH",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create receipt: %v", err)
+	}
+	result, err := repo.UpdateMany(ctx, []interfaces.BulkUpdate{
+		{ID: id, Data: map[string]interface{}{"status": "stale", "version": int64(1)}},
+		{ID: interfaces.StringID(other["id"].(string)), Data: map[string]interface{}{"status": "minted", "version": int64(1)}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("UpdateMany failed: %v", err)
+	}
+	if result.Affected != 1 || len(result.Errors) != 1 || result.Errors[0].Index != 0 || !errors.Is(result.Errors[0].Err, interfaces.ErrConflict) {
+		t.Fatalf("Expected a conflict for row 0 only, got %d and %v", result.Affected, result.Errors)
+	}
+	if result.Records[1]["version"] != int64(2) {
+		t.Errorf("Expected batch update to bump version, got %v", result.Records[1])
+	}
+}
+
+func testSoftDelete(t *testing.T, ctx context.Context, repo, hardRepo interfaces.Repository) {
+	var ids []interfaces.ID
+	for i := 0; i < 3; i++ {
+		receipt, err := repo.Create(ctx, map[string]interface{}{
+			"tx_hash":   fmt.Sprintf("0xsoft-%d", i),
+			"sui_owner": "0xowner",
+			"chain_id":  "1",
+			"asset":     "ETH",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create receipt: %v", err)
+		}
+		if receipt["deleted_at"] != nil {
+			t.Fatalf("Expected new receipt to be live, got deleted_at %v", receipt["deleted_at"])
+		}
+		ids = append(ids, interfaces.StringID(receipt["id"].(string)))
+	}
+	ours := &interfaces.Filters{
+		Conditions: []interfaces.Filter{{Field: "tx_hash", Operator: &interfaces.FilterOperator{Like: "0xsoft-%"}}},
+	}
+
+	if err := repo.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("Failed to soft delete receipt: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, ids[0]); !errors.Is(err, interfaces.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for soft-deleted receipt, got %v", err)
+	}
+	if _, err := repo.Update(ctx, ids[0], map[string]interface{}{"status": "minted"}); !errors.Is(err, interfaces.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating soft-deleted receipt, got %v", err)
+	}
+	if err := repo.Delete(ctx, ids[0]); !errors.Is(err, interfaces.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting soft-deleted receipt twice, got %v", err)
+	}
+
+	if count, err := repo.Count(ctx, &interfaces.Query{Where: ours}); err != nil || count != 2 {
+		t.Errorf("Expected 2 live receipts, got %d (%v)", count, err)
+	}
+	all, err := repo.FindMany(ctx, &interfaces.Query{Where: ours, WithDeleted: true})
+	if err != nil || all.Total != 3 {
+		t.Fatalf("Expected 3 receipts with deleted, got %v (%v)", all, err)
+	}
+	for _, receipt := range all.Data {
+		_, deleted := receipt["deleted_at"].(time.Time)
+		if deleted != (receipt["id"] == ids[0].String()) {
+			t.Errorf("Unexpected deleted_at %v on receipt %v", receipt["deleted_at"], receipt["id"])
+		}
+	}
+	rows, err := repo.Aggregate(ctx, &interfaces.AggregateQuery{Where: ours, Aggregates: []interfaces.Aggregate{{Function: "count"}}})
+	if err != nil || len(rows) != 1 || rows[0]["count"] != int64(2) {
+		t.Errorf("Expected aggregate over 2 live receipts, got %v (%v)", rows, err)
+	}
+
+	// Restore brings the record back and is a no-op for live records
+	restored, err := repo.Restore(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("Failed to restore receipt: %v", err)
+	}
+	if restored["deleted_at"] != nil || restored["version"] != int64(3) {
+		t.Errorf("Expected restored receipt at version 3 without deleted_at, got %v", restored)
+	}
+	if _, err := repo.GetByID(ctx, ids[0]); err != nil {
+		t.Errorf("Expected restored receipt to be readable, got %v", err)
+	}
+	if live, err := repo.Restore(ctx, ids[1]); err != nil || live["version"] != int64(1) {
+		t.Errorf("Expected restoring a live receipt to change nothing, got %v (%v)", live, err)
+	}
+	if _, err := repo.Restore(ctx, interfaces.StringID("missing-receipt")); !errors.Is(err, interfaces.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound restoring unknown receipt, got %v", err)
+	}
+	if _, err := hardRepo.Restore(ctx, interfaces.StringID("any")); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery restoring without soft delete, got %v", err)
+	}
+
+	// Batch operations skip soft-deleted rows like their single-row forms
+	result, err := repo.DeleteMany(ctx, ids[:2], nil)
+	if err != nil || result.Affected != 2 || len(result.Errors) != 0 {
+		t.Fatalf("Expected DeleteMany to soft delete 2 receipts, got %+v (%v)", result, err)
+	}
+	result, err = repo.DeleteMany(ctx, ids, nil)
+	if err != nil || result.Affected != 1 || len(result.Errors) != 2 || !errors.Is(result.Errors[0].Err, interfaces.ErrNotFound) {
+		t.Errorf("Expected DeleteMany to reject deleted receipts, got %+v (%v)", result, err)
+	}
+	result, err = repo.UpdateMany(ctx, []interfaces.BulkUpdate{{ID: ids[0], Data: map[string]interface{}{"status": "minted"}}}, nil)
+	if err != nil || result.Affected != 0 || len(result.Errors) != 1 || !errors.Is(result.Errors[0].Err, interfaces.ErrNotFound) {
+		t.Errorf("Expected UpdateMany to reject deleted receipt, got %+v (%v)", result, err)
+	}
+	if count, err := repo.Count(ctx, &interfaces.Query{Where: ours, WithDeleted: true}); err != nil || count != 3 {
+		t.Errorf("Expected soft-deleted receipts to be kept, got %d (%v)", count, err)
+	}
+}
+
+func testCursorPagination(t *testing.T, ctx context.Context, repo interfaces.Repository) {
+	create := func(txHash string) {
+		t.Helper()
+		if _, err := repo.Create(ctx, map[string]interface{}{
+			"tx_hash":   txHash,
+			"sui_owner": "0xcursor",
+			"chain_id":  "1",
+			"asset":     "ETH",
+		}); err != nil {
+			t.Fatalf("Failed to create receipt: %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		create(fmt.Sprintf("0xcursor-%d", i))
+	}
+
+	ours := &interfaces.Filters{Conditions: []interfaces.Filter{{Field: "sui_owner", Value: "0xcursor"}}}
+	order := []interfaces.OrderBy{{Field: "created_at", Direction: "desc"}}
+	all, err := repo.FindMany(ctx, &interfaces.Query{Where: ours, OrderBy: append(order, interfaces.OrderBy{Field: "id", Direction: "asc"})})
+	if err != nil || len(all.Data) != 5 {
+		t.Fatalf("Expected 5 receipts, got %v (%v)", all, err)
+	}
+	if all.NextCursor != "" || all.PrevCursor != "" {
+		t.Errorf("Expected no cursors without a limit, got %q and %q", all.NextCursor, all.PrevCursor)
+	}
+
+	hashes := func(records []map[string]interface{}) []interface{} {
+		var out []interface{}
+		for _, record := range records {
+			out = append(out, record["tx_hash"])
+		}
+		return out
+	}
+	limit := 2
+
+	// Walk forward, inserting a newer row mid-scan that must not shift pages
+	var walked []interface{}
+	var pages []*interfaces.ResultPage
+	after := ""
+	for {
+		page, err := repo.FindMany(ctx, &interfaces.Query{Where: ours, OrderBy: order, Limit: &limit, After: after, Select: []string{"tx_hash"}})
+		if err != nil {
+			t.Fatalf("Failed to get page after %q: %v", after, err)
+		}
+		if page.Total < 5 {
+			t.Errorf("Expected total over all pages, got %d", page.Total)
+		}
+		if _, exists := page.Data[0]["created_at"]; exists {
+			t.Errorf("Expected sort fields added for cursors to be stripped, got %v", page.Data[0])
+		}
+		if (after == "") != (page.PrevCursor == "") {
+			t.Errorf("Expected a previous cursor on every page but the first, got %q", page.PrevCursor)
+		}
+		pages = append(pages, page)
+		walked = append(walked, hashes(page.Data)...)
+		if len(pages) == 1 {
+			create("0xcursor-late")
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		after = page.NextCursor
+	}
+	if !reflect.DeepEqual(walked, hashes(all.Data)) || len(pages) != 3 {
+		t.Fatalf("Expected pages to cover %v once, got %v in %d pages", hashes(all.Data), walked, len(pages))
+	}
+
+	// Walk back from the last page
+	before, err := repo.FindMany(ctx, &interfaces.Query{Where: ours, OrderBy: order, Limit: &limit, Before: pages[2].PrevCursor})
+	if err != nil {
+		t.Fatalf("Failed to get page before: %v", err)
+	}
+	if !reflect.DeepEqual(hashes(before.Data), hashes(pages[1].Data)) || before.NextCursor == "" || before.PrevCursor == "" {
+		t.Errorf("Expected the middle page with both cursors, got %v (next %q, prev %q)", hashes(before.Data), before.NextCursor, before.PrevCursor)
+	}
+
+	// The late row sorts first, so it is only seen paging back to the start
+	first, err := repo.FindMany(ctx, &interfaces.Query{Where: ours, OrderBy: order, Limit: &limit, Before: pages[0].NextCursor})
+	if err != nil {
+		t.Fatalf("Failed to get first page: %v", err)
+	}
+	if want := append([]interface{}{"0xcursor-late"}, hashes(pages[0].Data)[0]); !reflect.DeepEqual(hashes(first.Data), want) || first.PrevCursor != "" {
+		t.Errorf("Expected %v as the first page, got %v (prev %q)", want, hashes(first.Data), first.PrevCursor)
+	}
+
+	for name, q := range map[string]*interfaces.Query{
+		"cursor with offset":  {OrderBy: order, Limit: &limit, After: pages[0].NextCursor, Offset: &limit},
+		"mismatched order":    {OrderBy: []interfaces.OrderBy{{Field: "tx_hash"}}, Limit: &limit, After: pages[0].NextCursor},
+		"malformed cursor":    {OrderBy: order, Limit: &limit, After: "not a cursor"},
+		"nullable sort field": {OrderBy: []interfaces.OrderBy{{Field: "minted"}}, Limit: &limit, After: pages[0].NextCursor},
+		"after and before":    {OrderBy: order, Limit: &limit, After: pages[0].NextCursor, Before: pages[1].PrevCursor},
+	} {
+		if _, err := repo.FindMany(ctx, q); !errors.Is(err, interfaces.ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", name, err)
+		}
+	}
+}
+
+func testWatch(t *testing.T, ctx context.Context, db interfaces.Database) {
+	repo := db.Repository(entities.BridgeReceiptSchema)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := db.Watch(watchCtx, entities.BridgeReceiptSchema, &interfaces.Filters{
+		Conditions: []interfaces.Filter{{Field: "sui_owner", Value: "0xwatcher"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch receipts: %v", err)
+	}
+	if _, err := db.Watch(ctx, entities.BridgeReceiptSchema, &interfaces.Filters{
+		Conditions: []interfaces.Filter{{Field: "missing", Value: 1}},
+	}); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for unknown filter field, got %v", err)
+	}
+
+	// Notifications may be delivered asynchronously
+	next := func() interfaces.ChangeEvent {
+		t.Helper()
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("Watch channel closed unexpectedly")
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for change event")
+		}
+		return interfaces.ChangeEvent{}
+	}
+	create := func(ctx context.Context, txHash, owner string) (map[string]interface{}, error) {
+		return repo.Create(ctx, map[string]interface{}{
+			"tx_hash":   txHash,
+			"sui_owner": owner,
+			"chain_id":  "1",
+			"asset":     "ETH",
+		})
+	}
+
+	// Changes to other owners' receipts do not match the filter
+	if _, err := create(ctx, "0xwatch-other", "0xsomeone"); err != nil {
+		t.Fatalf("Failed to create receipt: %v", err)
+	}
+	receipt, err := create(ctx, "0xwatch-1", "0xwatcher")
+	if err != nil {
+		t.Fatalf("Failed to create receipt: %v", err)
+	}
+	id := interfaces.StringID(receipt["id"].(string))
+
+	event := next()
+	if event.Table != "bridge_receipts" || event.Op != interfaces.ChangeCreate || event.ID != id.String() || event.Record["tx_hash"] != "0xwatch-1" {
+		t.Fatalf("Unexpected create event: %+v", event)
+	}
+
+	if _, err := repo.Update(ctx, id, map[string]interface{}{"status": "minted"}); err != nil {
+		t.Fatalf("Failed to update receipt: %v", err)
+	}
+	event = next()
+	if event.Op != interfaces.ChangeUpdate || event.Record["status"] != "minted" || event.Record["version"] != int64(2) {
+		t.Errorf("Unexpected update event: %+v", event)
+	}
+
+	// Rolled back changes are never reported
+	errRollback := errors.New("rollback")
+	err = db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+		if _, err := create(ctx, "0xwatch-rolled-back", "0xwatcher"); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Expected rollback error, got %v", err)
+	}
+	err = db.Transaction(ctx, func(ctx context.Context, tx interfaces.Transaction) error {
+		_, err := create(ctx, "0xwatch-2", "0xwatcher")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+	if event = next(); event.Op != interfaces.ChangeCreate || event.Record["tx_hash"] != "0xwatch-2" {
+		t.Errorf("Expected only the committed create, got %+v", event)
+	}
+
+	// Soft deletes are reported as deletes
+	if err := repo.Delete(ctx, id); err != nil {
+		t.Fatalf("Failed to delete receipt: %v", err)
+	}
+	event = next()
+	if event.Op != interfaces.ChangeDelete || event.ID != id.String() {
+		t.Errorf("Unexpected delete event: %+v", event)
+	}
+	if _, deleted := event.Record["deleted_at"].(time.Time); !deleted {
+		t.Errorf("Expected deleted_at on soft delete event, got %v", event.Record["deleted_at"])
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		for ok {
+			_, ok = <-events
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch channel was not closed on cancel")
+	}
+}
+
+func testHooks(t *testing.T, ctx context.Context, db interfaces.Database) {
+	repo := db.Repository(entities.BridgeReceiptSchema)
+	audit := db.Repository(entities.UserSchema)
+	errRejected := errors.New("rejected by hook")
+
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	takeEvents := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		taken := events
+		events = nil
+		return taken
+	}
+
+	db.RegisterHooks(entities.BridgeReceiptSchema, interfaces.Hooks{
+		BeforeCreate: []interfaces.BeforeCreateHook{func(ctx context.Context, data map[string]interface{}) error {
+			if _, exists := data["chain_id"]; !exists {
+				data["chain_id"] = "1"
+			}
+			record("before_create")
+			return nil
+		}},
+		AfterCreate: []interfaces.AfterCreateHook{func(ctx context.Context, receipt map[string]interface{}) error {
+			record(fmt.Sprintf("after_create %v", receipt["tx_hash"]))
+			return nil
+		}},
+		BeforeUpdate: []interfaces.BeforeUpdateHook{func(ctx context.Context, id interfaces.ID, data map[string]interface{}) error {
+			if data["status"] == "frozen" {
+				return errRejected
+			}
+			record("before_update")
+			return nil
+		}},
+		AfterUpdate: []interfaces.AfterUpdateHook{func(ctx context.Context, receipt map[string]interface{}) error {
+			// Writes through ctx join the mutation's transaction
+			_, err := audit.Create(ctx, map[string]interface{}{
+				"email": fmt.Sprintf("audit-%v-%v@example.com", receipt["tx_hash"], receipt["status"]),
+				"name":  "audit",
+			})
+			if err != nil {
+				return err
+			}
+			if receipt["status"] == "failed" {
+				return errRejected
+			}
+			return nil
+		}},
+		BeforeDelete: []interfaces.BeforeDeleteHook{func(ctx context.Context, id interfaces.ID) error {
+			record("before_delete")
+			return nil
+		}},
+		AfterDelete: []interfaces.AfterDeleteHook{func(ctx context.Context, id interfaces.ID) error {
+			record("after_delete")
+			return nil
+		}},
+	})
+
+	data := map[string]interface{}{"tx_hash": "0xhooks", "sui_owner": "0xowner", "asset": "ETH"}
+	receipt, err := repo.Create(ctx, data)
+	if err != nil {
+		t.Fatalf("Failed to create receipt: %v", err)
+	}
+	if receipt["chain_id"] != "1" {
+		t.Errorf("Expected BeforeCreate to populate chain_id, got %v", receipt["chain_id"])
+	}
+	if _, exists := data["chain_id"]; exists {
+		t.Error("Expected hooks to leave the caller's data alone")
+	}
+	if got := fmt.Sprint(takeEvents()); got != "[before_create after_create 0xhooks]" {
+		t.Errorf("Unexpected create events: %s", got)
+	}
+	id := interfaces.StringID(receipt["id"].(string))
+
+	auditCount := func(status string) int64 {
+		count, err := audit.Count(ctx, &interfaces.Query{Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{{Field: "email", Value: "audit-0xhooks-" + status + "@example.com"}},
+		}})
+		if err != nil {
+			t.Fatalf("Failed to count audit entries: %v", err)
+		}
+		return count
+	}
+
+	if _, err := repo.Update(ctx, id, map[string]interface{}{"status": "minted"}); err != nil {
+		t.Fatalf("Failed to update receipt: %v", err)
+	}
+	if auditCount("minted") != 1 {
+		t.Error("Expected AfterUpdate to write an audit entry")
+	}
+
+	// A failing before hook stops the update
+	if _, err := repo.Update(ctx, id, map[string]interface{}{"status": "frozen"}); !errors.Is(err, errRejected) {
+		t.Errorf("Expected BeforeUpdate error, got %v", err)
+	}
+
+	// A failing after hook rolls back the update and the hook's own writes
+	if _, err := repo.Update(ctx, id, map[string]interface{}{"status": "failed"}); !errors.Is(err, errRejected) {
+		t.Errorf("Expected AfterUpdate error, got %v", err)
+	}
+	if stored, err := repo.GetByID(ctx, id); err != nil || stored["status"] != "minted" {
+		t.Errorf("Expected rejected updates to be rolled back, got %v (%v)", stored, err)
+	}
+	if auditCount("failed") != 0 {
+		t.Error("Expected the audit entry of the rejected update to be rolled back")
+	}
+	takeEvents()
+
+	if err := repo.Delete(ctx, id); err != nil {
+		t.Fatalf("Failed to delete receipt: %v", err)
+	}
+	if got := fmt.Sprint(takeEvents()); got != "[before_delete after_delete]" {
+		t.Errorf("Unexpected delete events: %s", got)
+	}
+
+	// Batch mutations run the hooks of every row
+	result, err := repo.CreateMany(ctx, []map[string]interface{}{
+		{"tx_hash": "0xhooks-1", "sui_owner": "0xowner", "asset": "ETH"},
+		{"tx_hash": "0xhooks-2", "sui_owner": "0xowner", "asset": "ETH"},
+	}, nil)
+	if err != nil || result.Affected != 2 {
+		t.Fatalf("Expected CreateMany to create 2 receipts, got %+v (%v)", result, err)
+	}
+	if result.Records[1]["chain_id"] != "1" {
+		t.Errorf("Expected BeforeCreate to run for every row, got %v", result.Records[1])
+	}
+	if got := len(takeEvents()); got != 4 {
+		t.Errorf("Expected 4 create events, got %d", got)
+	}
+}
@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// APIKey is the key of a server-to-server consumer. Only the SHA-256 hash
+// of its secret is stored; the key is shown once when created or rotated.
+type APIKey struct {
+	ID           string     `json:"id" db:"id"`
+	Name         string     `json:"name" db:"name"`
+	KeyHash      string     `json:"key_hash" db:"key_hash"`
+	Scopes       string     `json:"scopes" db:"scopes"`                 // Space separated
+	RateLimitRPM int64      `json:"rate_limit_rpm" db:"rate_limit_rpm"` // 0 takes the default of keys
+	RotatedAt    *time.Time `json:"rotated_at,omitempty" db:"rotated_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// APIKeySchema defines the database schema for API keys
+var APIKeySchema = &interfaces.Schema{
+	TableName: "api_keys",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"name": {
+			Type: "string",
+		},
+		"key_hash": {
+			Type: "string",
+		},
+		"scopes": {
+			Type: "string",
+		},
+		"rate_limit_rpm": {
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"rotated_at": {
+			Type:     "time",
+			Nullable: true,
+		},
+		"revoked_at": {
+			Type:     "time",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_api_keys_key_hash",
+			Columns: []string{"key_hash"},
+			Unique:  true,
+		},
+	},
+}
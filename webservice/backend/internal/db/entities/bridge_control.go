@@ -0,0 +1,93 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// BridgeControl holds the operator pauses, deposit limits and fees of a
+// bridge scope: every chain, one chain, or one asset of a chain. Empty
+// chain and asset columns widen the scope. Amounts are decimal strings.
+type BridgeControl struct {
+	ID                string    `json:"id" db:"id"`
+	ChainID           string    `json:"chain_id" db:"chain_id"`
+	Asset             string    `json:"asset" db:"asset"`
+	DepositsPaused    bool      `json:"deposits_paused" db:"deposits_paused"`
+	RedemptionsPaused bool      `json:"redemptions_paused" db:"redemptions_paused"`
+	MaxDeposit        string    `json:"max_deposit" db:"max_deposit"`
+	DailyDepositLimit string    `json:"daily_deposit_limit" db:"daily_deposit_limit"`
+	DailyVolume       string    `json:"daily_volume" db:"daily_volume"`
+	VolumeDay         string    `json:"volume_day" db:"volume_day"`
+	MintFeeBps        int64     `json:"mint_fee_bps" db:"mint_fee_bps"`
+	RedeemFeeBps      int64     `json:"redeem_fee_bps" db:"redeem_fee_bps"`
+	MintFees          string    `json:"mint_fees" db:"mint_fees"`
+	RedeemFees        string    `json:"redeem_fees" db:"redeem_fees"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BridgeControlSchema defines the database schema for bridge controls
+var BridgeControlSchema = &interfaces.Schema{
+	TableName: "bridge_controls",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"chain_id": {
+			Type: "string",
+		},
+		"asset": {
+			Type: "string",
+		},
+		"deposits_paused": {
+			Type:         "bool",
+			DefaultValue: false,
+		},
+		"redemptions_paused": {
+			Type:         "bool",
+			DefaultValue: false,
+		},
+		"max_deposit": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"daily_deposit_limit": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"daily_volume": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"volume_day": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"mint_fee_bps": {
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"redeem_fee_bps": {
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"mint_fees": {
+			// Fees collected on deposits, in asset units
+			Type:     "string",
+			Nullable: true,
+		},
+		"redeem_fees": {
+			// Fees collected on redemptions, in asset units
+			Type:     "string",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+}
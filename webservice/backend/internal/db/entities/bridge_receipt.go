@@ -0,0 +1,167 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// BridgeReceipt represents a deposit processed by the bridge worker
+type BridgeReceipt struct {
+	ID             string                 `json:"id" db:"id"`
+	TxHash         string                 `json:"tx_hash" db:"tx_hash"`
+	LogIndex       int64                  `json:"log_index" db:"log_index"`
+	BlockNumber    int64                  `json:"block_number" db:"block_number"`
+	BlockHash      string                 `json:"block_hash" db:"block_hash"`
+	SuiOwner       string                 `json:"sui_owner" db:"sui_owner"`
+	ChainID        string                 `json:"chain_id" db:"chain_id"`
+	Asset          string                 `json:"asset" db:"asset"`
+	Minted         string                 `json:"minted" db:"minted"`
+	Amount         string                 `json:"amount" db:"amount"`
+	Shares         string                 `json:"shares" db:"shares"`
+	Fee            string                 `json:"fee" db:"fee"`
+	PriceSource    string                 `json:"price_source" db:"price_source"`
+	PriceSymbol    string                 `json:"price_symbol" db:"price_symbol"`
+	Price          string                 `json:"price" db:"price"`
+	PriceAt        *time.Time             `json:"price_at,omitempty" db:"price_at"`
+	WalrusUpdateID int64                  `json:"walrus_update_id" db:"walrus_update_id"`
+	Status         string                 `json:"status" db:"status"`
+	Stage          string                 `json:"stage" db:"stage"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
+	Version        int64                  `json:"version" db:"version"`
+	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at" db:"updated_at"`
+	DeletedAt      *time.Time             `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// BridgeReceiptSchema defines the database schema for bridge receipts.
+// Receipts are updated by several API replicas, so updates are guarded by
+// the version field. They are soft deleted to keep an audit trail.
+// Receipts are keyed by chain, transaction hash and log index, as one
+// transaction may hold several deposits. The block is kept to find the
+// deposits to roll back when the origin chain reorganizes.
+var BridgeReceiptSchema = &interfaces.Schema{
+	TableName: "bridge_receipts",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"tx_hash": {
+			// NULL for deposits submitted without a transaction hash
+			Type:     "string",
+			Nullable: true,
+		},
+		"log_index": {
+			// Position of the deposit event in its transaction
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"block_number": {
+			// Zero for deposits submitted without a block
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"block_hash": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"sui_owner": {
+			Type: "string",
+		},
+		"chain_id": {
+			Type: "string",
+		},
+		"asset": {
+			Type: "string",
+		},
+		"minted": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"amount": {
+			// Decimal string of the deposited amount, in asset units
+			Type:     "string",
+			Nullable: true,
+		},
+		"shares": {
+			// Decimal string of the shares credited for the deposit
+			Type:     "string",
+			Nullable: true,
+		},
+		"fee": {
+			// Decimal string of the fee kept from the deposit
+			Type:     "string",
+			Nullable: true,
+		},
+		"price_source": {
+			// Source of the USD quote the mint was split with
+			Type:     "string",
+			Nullable: true,
+		},
+		"price_symbol": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"price": {
+			// Decimal string of the quoted USD price
+			Type:     "string",
+			Nullable: true,
+		},
+		"price_at": {
+			// When the source observed the price
+			Type:     "time",
+			Nullable: true,
+		},
+		"walrus_update_id": {
+			// First checkpoint counting the credited shares
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"status": {
+			Type:         "string",
+			DefaultValue: "pending",
+		},
+		"stage": {
+			// Lifecycle stage shown to users; see ReceiptTransitionSchema
+			Type:     "string",
+			Nullable: true,
+		},
+		"metadata": {
+			// Chain-specific details of the deposit, e.g. Sui mint digests
+			Type:     "json",
+			Nullable: true,
+		},
+		"version": {
+			Type: "int64",
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+		"deleted_at": {
+			Type:     "time",
+			Nullable: true,
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_bridge_receipts_status",
+			Columns: []string{"status"},
+		},
+		{
+			// One receipt per deposit event, so replayed deposits are not minted twice
+			Name:    "idx_bridge_receipts_deposit",
+			Columns: []string{"chain_id", "tx_hash", "log_index"},
+			Unique:  true,
+		},
+		{
+			Name:    "idx_bridge_receipts_block",
+			Columns: []string{"chain_id", "block_number"},
+		},
+	},
+	VersionField: "version",
+	SoftDelete:   true,
+}
@@ -0,0 +1,88 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// BridgeRefund is the compensation of a redeem whose payout failed for
+// good: the burned tokens are minted back on Sui once an operator approves
+// it. Amounts are decimal strings; Sui digests are comma-separated.
+type BridgeRefund struct {
+	ID           string    `json:"id" db:"id"`
+	ChainID      string    `json:"chain_id" db:"chain_id"`
+	Asset        string    `json:"asset" db:"asset"`
+	SuiOwner     string    `json:"sui_owner" db:"sui_owner"`
+	Token        string    `json:"token" db:"token"`
+	Amount       string    `json:"amount" db:"amount"`
+	Fee          string    `json:"fee" db:"fee"`
+	Status       string    `json:"status" db:"status"`
+	Reason       string    `json:"reason" db:"reason"`
+	Credited     bool      `json:"credited" db:"credited"`
+	SuiTxDigests string    `json:"sui_tx_digests" db:"sui_tx_digests"`
+	LastError    string    `json:"last_error" db:"last_error"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BridgeRefundSchema defines the database schema for bridge refunds
+var BridgeRefundSchema = &interfaces.Schema{
+	TableName: "bridge_refunds",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"chain_id": {
+			Type: "string",
+		},
+		"asset": {
+			Type: "string",
+		},
+		"sui_owner": {
+			Type: "string",
+		},
+		"token": {
+			Type: "string",
+		},
+		"amount": {
+			Type: "string",
+		},
+		"fee": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"status": {
+			Type: "string",
+		},
+		"reason": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"credited": {
+			Type:         "bool",
+			DefaultValue: false,
+		},
+		"sui_tx_digests": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"last_error": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_bridge_refunds_status",
+			Columns: []string{"status", "created_at"},
+		},
+	},
+}
@@ -0,0 +1,68 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// BridgeRetry is a failed bridge mint or payout queued to be attempted
+// again by the bridge worker
+type BridgeRetry struct {
+	ID            string    `json:"id" db:"id"`
+	Kind          string    `json:"kind" db:"kind"`
+	ReceiptID     string    `json:"receipt_id" db:"receipt_id"`
+	Payload       string    `json:"payload" db:"payload"`
+	Status        string    `json:"status" db:"status"`
+	Attempts      int64     `json:"attempts" db:"attempts"`
+	LastError     string    `json:"last_error" db:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BridgeRetrySchema defines the database schema for bridge retries
+var BridgeRetrySchema = &interfaces.Schema{
+	TableName: "bridge_retries",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"kind": {
+			Type: "string",
+		},
+		"receipt_id": {
+			Type: "string",
+		},
+		"payload": {
+			Type: "string",
+		},
+		"status": {
+			Type: "string",
+		},
+		"attempts": {
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"last_error": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"next_attempt_at": {
+			Type: "time",
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_bridge_retries_due",
+			Columns: []string{"status", "next_attempt_at"},
+		},
+	},
+}
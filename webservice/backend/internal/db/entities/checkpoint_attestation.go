@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// CheckpointAttestation is an operator signature over the digest of a
+// Walrus checkpoint. The ID is "<updateId>:<signer>".
+type CheckpointAttestation struct {
+	ID        string    `json:"id" db:"id"`
+	UpdateID  int64     `json:"update_id" db:"update_id"`
+	Signer    string    `json:"signer" db:"signer"`
+	Signature string    `json:"signature" db:"signature"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CheckpointAttestationSchema defines the database schema for checkpoint
+// attestations
+var CheckpointAttestationSchema = &interfaces.Schema{
+	TableName: "checkpoint_attestations",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"update_id": {
+			Type: "int64",
+		},
+		"signer": {
+			Type: "string",
+		},
+		"signature": {
+			Type: "string",
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_checkpoint_attestations_update",
+			Columns: []string{"update_id"},
+		},
+	},
+}
@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// CrossChainBalance represents a user's balance bridged from another chain.
+// Amounts are decimal strings, so no precision is lost.
+type CrossChainBalance struct {
+	ID               string    `json:"id" db:"id"`
+	SuiOwner         string    `json:"sui_owner" db:"sui_owner"`
+	ChainID          string    `json:"chain_id" db:"chain_id"`
+	Asset            string    `json:"asset" db:"asset"`
+	Shares           string    `json:"shares" db:"shares"`
+	Index            string    `json:"index" db:"index"`
+	Value            string    `json:"value" db:"value"`
+	CollateralUSD    string    `json:"collateral_usd" db:"collateral_usd"`
+	LastCheckpointID int64     `json:"last_checkpoint_id" db:"last_checkpoint_id"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CrossChainBalanceSchema defines the database schema for cross-chain
+// balances, one per owner, chain and asset
+var CrossChainBalanceSchema = &interfaces.Schema{
+	TableName: "crosschain_balances",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"sui_owner": {
+			Type: "string",
+		},
+		"chain_id": {
+			Type: "string",
+		},
+		"asset": {
+			Type: "string",
+		},
+		"shares": {
+			Type: "string",
+		},
+		"index": {
+			Type: "string",
+		},
+		"value": {
+			Type: "string",
+		},
+		"collateral_usd": {
+			Type: "string",
+		},
+		"last_checkpoint_id": {
+			Type: "int64",
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_crosschain_balances_owner_asset",
+			Columns: []string{"sui_owner", "chain_id", "asset"},
+			Unique:  true,
+		},
+	},
+}
@@ -0,0 +1,90 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// Event represents an indexed protocol event, such as a mint or a stake,
+// attributed to the address that sent its transaction. Amount is in base
+// units of Token; Fields holds the decoded Move event.
+type Event struct {
+	ID             string                 `json:"id" db:"id"`
+	Checkpoint     int64                  `json:"checkpoint" db:"checkpoint"`
+	SequenceNumber int64                  `json:"sequence_number" db:"sequence_number"`
+	Timestamp      time.Time              `json:"timestamp" db:"timestamp"`
+	Type           string                 `json:"type" db:"type"`
+	TxDigest       string                 `json:"tx_digest" db:"tx_digest"`
+	Sender         string                 `json:"sender" db:"sender"`
+	Token          string                 `json:"token" db:"token"`
+	Amount         string                 `json:"amount" db:"amount"`
+	Fields         map[string]interface{} `json:"fields" db:"fields"`
+	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+// EventSchema defines the database schema for protocol events. Events are
+// listed per sender, newest first, for the user transactions endpoint, and
+// by type for the protocol history.
+var EventSchema = &interfaces.Schema{
+	TableName: "events",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"checkpoint": {
+			Type: "int64",
+		},
+		"sequence_number": {
+			Type: "int64",
+		},
+		"timestamp": {
+			Type: "time",
+		},
+		"type": {
+			Type: "string",
+		},
+		"tx_digest": {
+			Type: "string",
+		},
+		"sender": {
+			Type: "string",
+		},
+		"token": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"amount": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"fields": {
+			Type:     "json",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_events_sender_timestamp",
+			Columns: []string{"sender", "timestamp"},
+		},
+		{
+			Name:    "idx_events_type_timestamp",
+			Columns: []string{"type", "timestamp"},
+		},
+		{
+			// An event is identified by its transaction and position in it
+			Name:    "idx_events_tx_sequence",
+			Columns: []string{"tx_digest", "sequence_number"},
+			Unique:  true,
+		},
+	},
+}
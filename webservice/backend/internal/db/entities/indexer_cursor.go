@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// IndexerCursor is the position of the event indexer in the events of one
+// Move event type: the ID of the last event it stored
+type IndexerCursor struct {
+	ID        string    `json:"id" db:"id"` // Move event type
+	TxDigest  string    `json:"tx_digest" db:"tx_digest"`
+	EventSeq  string    `json:"event_seq" db:"event_seq"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// IndexerCursorSchema defines the database schema for indexer cursors
+var IndexerCursorSchema = &interfaces.Schema{
+	TableName: "indexer_cursors",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"tx_digest": {
+			Type: "string",
+		},
+		"event_seq": {
+			Type: "string",
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+}
@@ -60,4 +60,11 @@ var PostSchema = &interfaces.Schema{
 			Columns: []string{"published_at"},
 		},
 	},
+	Relations: map[string]interfaces.Relation{
+		"author": {
+			Type:       interfaces.RelationBelongsTo,
+			Table:      "users",
+			ForeignKey: "author_id",
+		},
+	},
 }
\ No newline at end of file
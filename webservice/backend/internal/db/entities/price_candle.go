@@ -0,0 +1,78 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// PriceCandle is the OHLCV candle of a provider symbol, e.g. SUIUSDT, over
+// the interval starting at Time. Interval is written as for the candles
+// API, e.g. 1m or 1d. Source names the provider whose history backfilled
+// the candle, or is "ticks" for candles aggregated from live ticks.
+type PriceCandle struct {
+	ID        string    `json:"id" db:"id"`
+	Symbol    string    `json:"symbol" db:"symbol"`
+	Interval  string    `json:"interval" db:"interval"`
+	Time      time.Time `json:"time" db:"time"`
+	Open      float64   `json:"open" db:"open"`
+	High      float64   `json:"high" db:"high"`
+	Low       float64   `json:"low" db:"low"`
+	Close     float64   `json:"close" db:"close"`
+	Volume    float64   `json:"volume" db:"volume"`
+	Source    string    `json:"source" db:"source"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PriceCandleSchema defines the database schema for price candles. An
+// interval of a symbol holds one candle, which replicas update in place.
+var PriceCandleSchema = &interfaces.Schema{
+	TableName: "price_candles",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"symbol": {
+			Type: "string",
+		},
+		"interval": {
+			Type: "string",
+		},
+		"time": {
+			Type: "time",
+		},
+		"open": {
+			Type: "float64",
+		},
+		"high": {
+			Type: "float64",
+		},
+		"low": {
+			Type: "float64",
+		},
+		"close": {
+			Type: "float64",
+		},
+		"volume": {
+			Type: "float64",
+		},
+		"source": {
+			Type: "string",
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_price_candles_symbol_interval_time",
+			Columns: []string{"symbol", "interval", "time"},
+			Unique:  true,
+		},
+	},
+}
@@ -0,0 +1,69 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// ProtocolSample is the protocol state recorded by the history sampler at
+// Timestamp, the start of its sampling slot. Amounts are in base units;
+// they are floats, precise enough for the charts and valuations they feed.
+type ProtocolSample struct {
+	ID           string    `json:"id" db:"id"`
+	Timestamp    time.Time `json:"timestamp" db:"timestamp"`
+	CR           float64   `json:"cr" db:"cr"`
+	ReservesR    float64   `json:"reserves_r" db:"reserves_r"`
+	SupplyF      float64   `json:"supply_f" db:"supply_f"`
+	SupplyX      float64   `json:"supply_x" db:"supply_x"`
+	PegDeviation float64   `json:"peg_deviation" db:"peg_deviation"`
+	ReservePrice float64   `json:"reserve_price" db:"reserve_price"` // In USD
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ProtocolSampleSchema defines the database schema for protocol samples.
+// A slot holds one sample, so replicas sampling it at once leave one.
+var ProtocolSampleSchema = &interfaces.Schema{
+	TableName: "protocol_samples",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"timestamp": {
+			Type: "time",
+		},
+		"cr": {
+			Type: "float64",
+		},
+		"reserves_r": {
+			Type: "float64",
+		},
+		"supply_f": {
+			Type: "float64",
+		},
+		"supply_x": {
+			Type: "float64",
+		},
+		"peg_deviation": {
+			Type: "float64",
+		},
+		"reserve_price": {
+			Type: "float64",
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_protocol_samples_timestamp",
+			Columns: []string{"timestamp"},
+			Unique:  true,
+		},
+	},
+}
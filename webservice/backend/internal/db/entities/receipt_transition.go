@@ -0,0 +1,65 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// ReceiptTransition records a deposit or redeem receipt moving from one
+// lifecycle stage to the next
+type ReceiptTransition struct {
+	ID        string    `json:"id" db:"id"`
+	ReceiptID string    `json:"receipt_id" db:"receipt_id"`
+	Kind      string    `json:"kind" db:"kind"`
+	FromStage string    `json:"from_stage" db:"from_stage"`
+	ToStage   string    `json:"to_stage" db:"to_stage"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ReceiptTransitionSchema defines the database schema for receipt
+// transitions. Transitions are only appended, so they form the history of
+// each receipt.
+var ReceiptTransitionSchema = &interfaces.Schema{
+	TableName: "receipt_transitions",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"receipt_id": {
+			Type: "string",
+		},
+		"kind": {
+			// "deposit" or "redeem"
+			Type: "string",
+		},
+		"from_stage": {
+			// Empty for the first stage of a receipt
+			Type:     "string",
+			Nullable: true,
+		},
+		"to_stage": {
+			Type: "string",
+		},
+		"reason": {
+			// Why a receipt failed, or how it reached a stage
+			Type:     "string",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_receipt_transitions_receipt",
+			Columns: []string{"receipt_id"},
+		},
+	},
+}
@@ -0,0 +1,123 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// RedeemReceipt represents a burn on Sui paid out by the bridge worker on
+// the origin chain
+type RedeemReceipt struct {
+	ID             string     `json:"id" db:"id"`
+	SuiTxDigest    string     `json:"sui_tx_digest" db:"sui_tx_digest"`
+	SuiOwner       string     `json:"sui_owner" db:"sui_owner"`
+	EthRecipient   string     `json:"eth_recipient" db:"eth_recipient"`
+	ChainID        string     `json:"chain_id" db:"chain_id"`
+	Asset          string     `json:"asset" db:"asset"`
+	Token          string     `json:"token" db:"token"`
+	Burned         string     `json:"burned" db:"burned"`
+	PayoutEth      string     `json:"payout_eth" db:"payout_eth"`
+	Fee            string     `json:"fee" db:"fee"`
+	PriceSource    string     `json:"price_source" db:"price_source"`
+	PriceSymbol    string     `json:"price_symbol" db:"price_symbol"`
+	Price          string     `json:"price" db:"price"`
+	PriceAt        *time.Time `json:"price_at,omitempty" db:"price_at"`
+	WalrusUpdateID int64      `json:"walrus_update_id" db:"walrus_update_id"`
+	WalrusBlobID   string     `json:"walrus_blob_id" db:"walrus_blob_id"`
+	PayoutTxHash   string     `json:"payout_tx_hash" db:"payout_tx_hash"`
+	Stage          string     `json:"stage" db:"stage"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// RedeemReceiptSchema defines the database schema for redeem receipts
+var RedeemReceiptSchema = &interfaces.Schema{
+	TableName: "redeem_receipts",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"sui_tx_digest": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"sui_owner": {
+			Type: "string",
+		},
+		"eth_recipient": {
+			Type: "string",
+		},
+		"chain_id": {
+			Type: "string",
+		},
+		"asset": {
+			Type: "string",
+		},
+		"token": {
+			Type: "string",
+		},
+		"burned": {
+			Type: "string",
+		},
+		"payout_eth": {
+			Type: "string",
+		},
+		"fee": {
+			// Kept from the payout, in asset units
+			Type:     "string",
+			Nullable: true,
+		},
+		"price_source": {
+			// Source of the USD quote the payout was computed with
+			Type:     "string",
+			Nullable: true,
+		},
+		"price_symbol": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"price": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"price_at": {
+			Type:     "time",
+			Nullable: true,
+		},
+		"walrus_update_id": {
+			Type:     "int64",
+			Nullable: true,
+		},
+		"walrus_blob_id": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"payout_tx_hash": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"stage": {
+			// Lifecycle stage shown to users; see ReceiptTransitionSchema
+			Type:     "string",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_redeem_receipts_owner",
+			Columns: []string{"sui_owner"},
+		},
+		{
+			Name:    "idx_redeem_receipts_digest",
+			Columns: []string{"sui_tx_digest"},
+		},
+	},
+}
@@ -15,9 +15,11 @@ type User struct {
 	IsActive  bool       `json:"is_active" db:"is_active"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
-// UserSchema defines the database schema for users
+// UserSchema defines the database schema for users. Users are soft deleted
+// so their history stays auditable.
 var UserSchema = &interfaces.Schema{
 	TableName: "users",
 	Fields: map[string]interfaces.FieldSchema{
@@ -46,6 +48,10 @@ var UserSchema = &interfaces.Schema{
 		"updated_at": {
 			Type: "time",
 		},
+		"deleted_at": {
+			Type:     "time",
+			Nullable: true,
+		},
 	},
 	Indexes: []interfaces.Index{
 		{
@@ -58,4 +64,15 @@ var UserSchema = &interfaces.Schema{
 			Columns: []string{"is_active"},
 		},
 	},
+	Relations: map[string]interfaces.Relation{
+		"posts": {
+			Type:       interfaces.RelationHasMany,
+			Table:      "posts",
+			ForeignKey: "author_id",
+			OrderBy: []interfaces.OrderBy{
+				{Field: "created_at", Direction: "asc"},
+			},
+		},
+	},
+	SoftDelete: true,
 }
\ No newline at end of file
@@ -0,0 +1,102 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// WalrusCheckpoint represents cross-chain vault state published to Walrus.
+// Share amounts and the index are decimal strings, so no precision is lost.
+type WalrusCheckpoint struct {
+	ID           string    `json:"id" db:"id"`
+	UpdateID     int64     `json:"update_id" db:"update_id"`
+	ChainID      string    `json:"chain_id" db:"chain_id"`
+	Asset        string    `json:"asset" db:"asset"`
+	Vault        string    `json:"vault" db:"vault"`
+	BlockNumber  int64     `json:"block_number" db:"block_number"`
+	BlockHash    string    `json:"block_hash" db:"block_hash"`
+	TotalShares  string    `json:"total_shares" db:"total_shares"`
+	Index        string    `json:"index" db:"index"`
+	BalancesRoot string    `json:"balances_root" db:"balances_root"`
+	ProofType    string    `json:"proof_type" db:"proof_type"`
+	ProofBlob    string    `json:"proof_blob" db:"proof_blob"`
+	WalrusBlobID string    `json:"walrus_blob_id" db:"walrus_blob_id"`
+	Status       string    `json:"status" db:"status"`
+	Timestamp    time.Time `json:"timestamp" db:"timestamp"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WalrusCheckpointSchema defines the database schema for Walrus
+// checkpoints. Update IDs are assigned in sequence across assets; the
+// latest checkpoint of an asset is the one with the highest.
+var WalrusCheckpointSchema = &interfaces.Schema{
+	TableName: "walrus_checkpoints",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"update_id": {
+			Type:   "int64",
+			Unique: true,
+		},
+		"chain_id": {
+			Type: "string",
+		},
+		"asset": {
+			Type: "string",
+		},
+		"vault": {
+			Type: "string",
+		},
+		"block_number": {
+			Type: "int64",
+		},
+		"block_hash": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"total_shares": {
+			Type: "string",
+		},
+		"index": {
+			Type: "string",
+		},
+		"balances_root": {
+			Type: "string",
+		},
+		"proof_type": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"proof_blob": {
+			// Base64 encoded
+			Type:     "string",
+			Nullable: true,
+		},
+		"walrus_blob_id": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"status": {
+			Type: "string",
+		},
+		"timestamp": {
+			Type: "time",
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_walrus_checkpoints_asset_update",
+			Columns: []string{"chain_id", "asset", "update_id"},
+		},
+	},
+}
@@ -0,0 +1,79 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// WatchlistItem is a condition a signed-in user is alerted on: a balance
+// change, a bridge receipt status change or a peg deviation. State holds
+// what the evaluator last observed, as JSON, so that changes are told
+// across restarts and replicas.
+type WatchlistItem struct {
+	ID          string     `json:"id" db:"id"`
+	Owner       string     `json:"owner" db:"owner"`
+	Kind        string     `json:"kind" db:"kind"`
+	Target      string     `json:"target" db:"target"`       // Address or receipt ID; empty for the peg
+	Threshold   float64    `json:"threshold" db:"threshold"` // Meaning depends on the kind
+	Label       string     `json:"label" db:"label"`
+	Active      bool       `json:"active" db:"active"`
+	State       string     `json:"state" db:"state"` // Empty until first evaluated
+	TriggeredAt *time.Time `json:"triggered_at,omitempty" db:"triggered_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// WatchlistItemSchema defines the database schema for watchlist items,
+// listed per owner oldest first
+var WatchlistItemSchema = &interfaces.Schema{
+	TableName: "watchlist_items",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"owner": {
+			Type: "string",
+		},
+		"kind": {
+			Type: "string",
+		},
+		"target": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"threshold": {
+			Type:         "float64",
+			DefaultValue: float64(0),
+		},
+		"label": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"active": {
+			Type:         "bool",
+			DefaultValue: true,
+		},
+		"state": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"triggered_at": {
+			Type:     "time",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_watchlist_items_owner",
+			Columns: []string{"owner", "created_at"},
+		},
+	},
+}
@@ -0,0 +1,132 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// WebhookSubscription is an integrator endpoint that protocol and bridge
+// events are posted to. The secret is kept as issued, since deliveries are
+// signed with it.
+type WebhookSubscription struct {
+	ID        string    `json:"id" db:"id"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"secret" db:"secret"`
+	Events    string    `json:"events" db:"events"` // Space separated event type patterns; empty for all
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookSubscriptionSchema defines the database schema for webhook
+// subscriptions
+var WebhookSubscriptionSchema = &interfaces.Schema{
+	TableName: "webhook_subscriptions",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"url": {
+			Type: "string",
+		},
+		"secret": {
+			Type: "string",
+		},
+		"events": {
+			Type: "string",
+		},
+		"active": {
+			Type:         "bool",
+			DefaultValue: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+}
+
+// WebhookDelivery is one event posted, or to be posted, to a subscription.
+// Deliveries failing every attempt are kept as dead letters.
+type WebhookDelivery struct {
+	ID             string     `json:"id" db:"id"`
+	SubscriptionID string     `json:"subscription_id" db:"subscription_id"`
+	EventID        string     `json:"event_id" db:"event_id"`
+	EventType      string     `json:"event_type" db:"event_type"`
+	Payload        string     `json:"payload" db:"payload"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int64      `json:"attempts" db:"attempts"`
+	ResponseStatus int64      `json:"response_status" db:"response_status"` // Of the last attempt; 0 without a response
+	LastError      string     `json:"last_error" db:"last_error"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookDeliverySchema defines the database schema for webhook
+// deliveries. Due deliveries are found by status and time, and the
+// deliveries of a subscription listed newest first.
+var WebhookDeliverySchema = &interfaces.Schema{
+	TableName: "webhook_deliveries",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"subscription_id": {
+			Type: "string",
+		},
+		"event_id": {
+			Type: "string",
+		},
+		"event_type": {
+			Type: "string",
+		},
+		"payload": {
+			Type: "string",
+		},
+		"status": {
+			Type: "string",
+		},
+		"attempts": {
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"response_status": {
+			Type:         "int64",
+			DefaultValue: int64(0),
+		},
+		"last_error": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"next_attempt_at": {
+			Type: "time",
+		},
+		"delivered_at": {
+			Type:     "time",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_webhook_deliveries_due",
+			Columns: []string{"status", "next_attempt_at"},
+		},
+		{
+			Name:    "idx_webhook_deliveries_subscription",
+			Columns: []string{"subscription_id", "created_at"},
+		},
+	},
+}
@@ -0,0 +1,96 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// WithdrawalVoucher is a voucher redeeming vault shares on the origin chain.
+// Shares are a decimal string; the digest and signature are 0x hex.
+type WithdrawalVoucher struct {
+	ID        string    `json:"id" db:"id"`
+	SuiOwner  string    `json:"sui_owner" db:"sui_owner"`
+	ChainID   string    `json:"chain_id" db:"chain_id"`
+	Asset     string    `json:"asset" db:"asset"`
+	Shares    string    `json:"shares" db:"shares"`
+	Nonce     int64     `json:"nonce" db:"nonce"`
+	Expiry    time.Time `json:"expiry" db:"expiry"`
+	UpdateID  int64     `json:"update_id" db:"update_id"`
+	Redeemer  string    `json:"redeemer" db:"redeemer"`
+	Digest    string    `json:"digest" db:"digest"`
+	Signature string    `json:"signature" db:"signature"`
+	Status    string    `json:"status" db:"status"`
+	TxHash    string    `json:"tx_hash" db:"tx_hash"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WithdrawalVoucherSchema defines the database schema for withdrawal
+// vouchers. Nonces are unique across vouchers.
+var WithdrawalVoucherSchema = &interfaces.Schema{
+	TableName: "withdrawal_vouchers",
+	Fields: map[string]interfaces.FieldSchema{
+		"id": {
+			Type:       "string",
+			PrimaryKey: true,
+		},
+		"sui_owner": {
+			Type: "string",
+		},
+		"chain_id": {
+			Type: "string",
+		},
+		"asset": {
+			Type: "string",
+		},
+		"shares": {
+			Type: "string",
+		},
+		"nonce": {
+			Type:   "int64",
+			Unique: true,
+		},
+		"expiry": {
+			Type: "time",
+		},
+		"update_id": {
+			Type: "int64",
+		},
+		"redeemer": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"digest": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"signature": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"status": {
+			Type: "string",
+		},
+		"tx_hash": {
+			Type:     "string",
+			Nullable: true,
+		},
+		"created_at": {
+			Type: "time",
+		},
+		"updated_at": {
+			Type: "time",
+		},
+	},
+	Indexes: []interfaces.Index{
+		{
+			Name:    "idx_withdrawal_vouchers_owner",
+			Columns: []string{"sui_owner", "created_at"},
+		},
+		{
+			Name:    "idx_withdrawal_vouchers_status",
+			Columns: []string{"status", "expiry"},
+		},
+	},
+}
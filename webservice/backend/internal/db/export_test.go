@@ -0,0 +1,169 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+)
+
+// readExport decodes an export stream into the tables in stream order and
+// the records of each table by primary key, with times in UTC and without
+// null fields, which only SQL backends return
+func readExport(t *testing.T, data []byte) ([]string, map[string]map[string]map[string]interface{}) {
+	t.Helper()
+	schemas := make(map[string]*interfaces.Schema)
+	for _, schema := range AllSchemas() {
+		schemas[schema.TableName] = schema
+	}
+
+	var tables []string
+	records := make(map[string]map[string]map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+		decoder.UseNumber()
+		var entry interfaces.ExportRecord
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatalf("Malformed export line %q: %v", scanner.Text(), err)
+		}
+		record, err := query.DecodeRecord(schemas[entry.Table], entry.Record)
+		if err != nil {
+			t.Fatalf("Malformed export record %q: %v", scanner.Text(), err)
+		}
+		for name, value := range record {
+			switch v := value.(type) {
+			case nil:
+				delete(record, name)
+			case time.Time:
+				record[name] = v.UTC()
+			}
+		}
+
+		if len(tables) == 0 || tables[len(tables)-1] != entry.Table {
+			tables = append(tables, entry.Table)
+		}
+		if records[entry.Table] == nil {
+			records[entry.Table] = make(map[string]map[string]interface{})
+		}
+		records[entry.Table][record["id"].(string)] = record
+	}
+	return tables, records
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	source := NewInMemoryDatabase()
+	if err := ConnectAndMigrate(ctx, source, AllSchemas()); err != nil {
+		t.Fatalf("Failed to connect and migrate: %v", err)
+	}
+	defer source.Disconnect(ctx)
+
+	users := source.Repository(entities.UserSchema)
+	var authorIDs []string
+	for _, fixture := range UserFixtures {
+		user, err := users.Create(ctx, fixture)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		authorIDs = append(authorIDs, user["id"].(string))
+	}
+	posts := source.Repository(entities.PostSchema)
+	for _, fixture := range PostFixtures(authorIDs) {
+		if _, err := posts.Create(ctx, fixture); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+	}
+	receipts := source.Repository(entities.BridgeReceiptSchema)
+	receipt, err := receipts.Create(ctx, map[string]interface{}{
+		"tx_hash":   "0xexport",
+		"sui_owner": "0xowner",
+		"chain_id":  "1",
+		"asset":     "ETH",
+		"metadata":  map[string]interface{}{"tags": []string{"l1"}, "block": 12},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create receipt: %v", err)
+	}
+	// Soft-deleted records are exported too
+	if err := receipts.Delete(ctx, interfaces.StringID(receipt["id"].(string))); err != nil {
+		t.Fatalf("Failed to delete receipt: %v", err)
+	}
+
+	// Referencing tables listed first are still exported after users
+	schemas := []*interfaces.Schema{entities.PostSchema, entities.UserSchema, entities.BridgeReceiptSchema}
+	var exported bytes.Buffer
+	if err := source.Export(ctx, &exported, interfaces.ExportOptions{Schemas: schemas, BatchSize: 2}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	tables, want := readExport(t, exported.Bytes())
+	if !reflect.DeepEqual(tables, []string{"users", "posts", "bridge_receipts"}) {
+		t.Fatalf("Expected users, posts and receipts in order, got %v", tables)
+	}
+	if len(want["users"]) != len(UserFixtures) || len(want["bridge_receipts"]) != 1 {
+		t.Fatalf("Expected every record to be exported, got %d users and %d receipts", len(want["users"]), len(want["bridge_receipts"]))
+	}
+
+	target, err := NewDatabase(&Config{
+		Type: "sqlite",
+		DSN:  "file:" + filepath.Join(t.TempDir(), "import.db"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if err := ConnectAndMigrate(ctx, target, AllSchemas()); err != nil {
+		t.Fatalf("Failed to connect and migrate: %v", err)
+	}
+	defer target.Disconnect(ctx)
+
+	if err := target.Import(ctx, bytes.NewReader(exported.Bytes()), interfaces.ImportOptions{Schemas: schemas}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	// Keys, timestamps and documents survive the round trip
+	var reexported bytes.Buffer
+	if err := target.Export(ctx, &reexported, interfaces.ExportOptions{Schemas: schemas}); err != nil {
+		t.Fatalf("Export after import failed: %v", err)
+	}
+	if _, got := readExport(t, reexported.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Imported records differ:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestImportIsAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	db := NewInMemoryDatabase()
+	if err := ConnectAndMigrate(ctx, db, AllSchemas()); err != nil {
+		t.Fatalf("Failed to connect and migrate: %v", err)
+	}
+	defer db.Disconnect(ctx)
+	schemas := []*interfaces.Schema{entities.UserSchema, entities.PostSchema}
+
+	// The post references a missing user, so the user is not kept either
+	stream := `{"table":"posts","record":{"id":"p1","title":"Orphan","content":"x","author_id":"missing"}}
+{"table":"users","record":{"id":"u1","email":"import@example.com","name":"Import","is_active":true}}
+`
+	if err := db.Import(ctx, strings.NewReader(stream), interfaces.ImportOptions{Schemas: schemas}); !errors.Is(err, interfaces.ErrForeignKeyConstraint) {
+		t.Fatalf("Expected ErrForeignKeyConstraint, got %v", err)
+	}
+	if count, err := db.Repository(entities.UserSchema).Count(ctx, nil); err != nil || count != 0 {
+		t.Fatalf("Expected a failed import to create nothing, got %d users (%v)", count, err)
+	}
+
+	stream = `{"table":"events","record":{"id":"e1"}}` + "\n"
+	if err := db.Import(ctx, strings.NewReader(stream), interfaces.ImportOptions{Schemas: schemas}); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Fatalf("Expected ErrInvalidQuery for a table not imported, got %v", err)
+	}
+}
@@ -5,18 +5,25 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/leafsii/leafsii-backend/internal/db/backends/memory"
+	"github.com/leafsii/leafsii-backend/internal/db/backends/postgres"
+	"github.com/leafsii/leafsii-backend/internal/db/backends/sqlite"
 	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
 )
 
+// DefaultSQLiteDSN is used when DB_TYPE=sqlite and no DSN is configured
+const DefaultSQLiteDSN = "file:leafsii.db"
+
 // Config holds database configuration
 type Config struct {
-	Type         string // "memory", "postgres", "sqlite"
-	DSN          string // Data Source Name / Connection String
-	UseInMemory  bool   // Force in-memory usage
-	MaxOpenConns int    // Maximum open connections (for SQL backends)
-	MaxIdleConns int    // Maximum idle connections (for SQL backends)
+	Type         string   // "memory", "postgres", "sqlite"
+	DSN          string   // Data Source Name / Connection String
+	ReplicaDSNs  []string // Read replicas serving reads outside transactions (postgres only)
+	UseInMemory  bool     // Force in-memory usage
+	MaxOpenConns int      // Maximum open connections (for SQL backends)
+	MaxIdleConns int      // Maximum idle connections (for SQL backends)
 }
 
 // NewDatabase creates a new database instance based on configuration
@@ -32,10 +39,18 @@ func NewDatabase(config *Config) (interfaces.Database, error) {
 	if config.DSN == "" {
 		config.DSN = os.Getenv("DB_DSN")
 	}
+	if len(config.ReplicaDSNs) == 0 {
+		config.ReplicaDSNs = splitList(os.Getenv("DB_REPLICA_DSNS"))
+	}
 	if !config.UseInMemory && os.Getenv("USE_IN_MEMORY") == "true" {
 		config.UseInMemory = true
 	}
 
+	// SQLite needs no server, so fall back to a local file rather than memory
+	if config.Type == "sqlite" && config.DSN == "" {
+		config.DSN = DefaultSQLiteDSN
+	}
+
 	// Force in-memory if no DSN provided or explicitly requested
 	if config.UseInMemory || (config.DSN == "" && config.Type != "memory") {
 		log.Println("Using in-memory database")
@@ -47,13 +62,21 @@ func NewDatabase(config *Config) (interfaces.Database, error) {
 		log.Println("Using in-memory database")
 		return memory.NewDatabase(), nil
 	case "postgres":
-		// TODO: Implement PostgreSQL backend
-		log.Println("PostgreSQL backend not yet implemented, falling back to in-memory")
-		return memory.NewDatabase(), nil
+		log.Println("Using PostgreSQL database")
+		return postgres.NewDatabase(config.DSN, postgres.Options{
+			MaxOpenConns: config.MaxOpenConns,
+			MaxIdleConns: config.MaxIdleConns,
+			Replicas:     config.ReplicaDSNs,
+		}), nil
 	case "sqlite":
-		// TODO: Implement SQLite backend
-		log.Println("SQLite backend not yet implemented, falling back to in-memory")
-		return memory.NewDatabase(), nil
+		log.Println("Using SQLite database")
+		if len(config.ReplicaDSNs) > 0 {
+			log.Println("Ignoring read replicas, which SQLite does not support")
+		}
+		return sqlite.NewDatabase(config.DSN, sqlite.Options{
+			MaxOpenConns: config.MaxOpenConns,
+			MaxIdleConns: config.MaxIdleConns,
+		}), nil
 	default:
 		return nil, fmt.Errorf("unsupported database type: %s", config.Type)
 	}
@@ -96,3 +119,14 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -77,5 +77,23 @@ func AllSchemas() []*interfaces.Schema {
 	return []*interfaces.Schema{
 		entities.UserSchema,
 		entities.PostSchema,
+		entities.BridgeReceiptSchema,
+		entities.RedeemReceiptSchema,
+		entities.WalrusCheckpointSchema,
+		entities.CrossChainBalanceSchema,
+		entities.BridgeRetrySchema,
+		entities.BridgeRefundSchema,
+		entities.WithdrawalVoucherSchema,
+		entities.BridgeControlSchema,
+		entities.CheckpointAttestationSchema,
+		entities.ReceiptTransitionSchema,
+		entities.EventSchema,
+		entities.IndexerCursorSchema,
+		entities.ProtocolSampleSchema,
+		entities.APIKeySchema,
+		entities.WebhookSubscriptionSchema,
+		entities.WebhookDeliverySchema,
+		entities.PriceCandleSchema,
+		entities.WatchlistItemSchema,
 	}
 }
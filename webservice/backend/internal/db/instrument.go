@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/db/query"
+	"go.uber.org/zap"
+)
+
+// QueryRecorder receives the duration of every Repository call, as
+// *metrics.Metrics does
+type QueryRecorder interface {
+	RecordDBQuery(ctx context.Context, table, operation, status string, duration time.Duration)
+}
+
+// SlowQueryLog configures the logging of slow Repository calls
+type SlowQueryLog struct {
+	Logger     *zap.SugaredLogger
+	Threshold  time.Duration // Calls taking at least this long are logged. Zero disables the log.
+	SampleRate float64       // Share of slow calls logged. Default: 1, all of them
+}
+
+// Instrument wraps database so that every Repository call records its
+// duration on recorder, by table, operation and status, and calls slower
+// than slow.Threshold are logged with their filters and, on SQL backends,
+// the statements they ran. recorder may be nil.
+func Instrument(database interfaces.Database, recorder QueryRecorder, slow SlowQueryLog) interfaces.Database {
+	if slow.SampleRate <= 0 || slow.SampleRate > 1 {
+		slow.SampleRate = 1
+	}
+	return &instrumentedDatabase{Database: database, recorder: recorder, slow: slow}
+}
+
+// instrumentedDatabase hands out instrumented repositories
+type instrumentedDatabase struct {
+	interfaces.Database
+	recorder QueryRecorder
+	slow     SlowQueryLog
+}
+
+// Repository returns an instrumented repository for the given schema
+func (db *instrumentedDatabase) Repository(schema *interfaces.Schema) interfaces.Repository {
+	return &instrumentedRepository{repo: db.Database.Repository(schema), db: db}
+}
+
+// observe runs call as operation on table, recording its duration and
+// logging it if slow. detail lists what the slow-query log shows of the
+// call's arguments.
+func (db *instrumentedDatabase) observe(ctx context.Context, table, operation string, call func(ctx context.Context) error, detail ...interface{}) error {
+	var statements *query.Statements
+	if db.slow.Threshold > 0 && db.slow.Logger != nil {
+		ctx, statements = query.CaptureStatements(ctx)
+	}
+
+	start := time.Now()
+	err := call(ctx)
+	duration := time.Since(start)
+
+	status := "ok"
+	switch {
+	case errors.Is(err, interfaces.ErrNotFound):
+		status = "not_found"
+	case err != nil:
+		status = "error"
+	}
+	if db.recorder != nil {
+		db.recorder.RecordDBQuery(ctx, table, operation, status, duration)
+	}
+
+	if statements != nil && duration >= db.slow.Threshold && (db.slow.SampleRate >= 1 || rand.Float64() < db.slow.SampleRate) {
+		sql, dropped := statements.SQL()
+		fields := []interface{}{
+			"table", table,
+			"operation", operation,
+			"status", status,
+			"duration", duration,
+			"threshold", db.slow.Threshold,
+		}
+		if len(sql) > 0 {
+			fields = append(fields, "sql", sql)
+		}
+		if dropped > 0 {
+			fields = append(fields, "sql_dropped", dropped)
+		}
+		if err != nil {
+			fields = append(fields, "error", err)
+		}
+		db.slow.Logger.Warnw("Slow database query", append(fields, detail...)...)
+	}
+	return err
+}
+
+// instrumentedRepository observes every call to repo
+type instrumentedRepository struct {
+	repo interfaces.Repository
+	db   *instrumentedDatabase
+}
+
+func (r *instrumentedRepository) observe(ctx context.Context, operation string, call func(ctx context.Context) error, detail ...interface{}) error {
+	return r.db.observe(ctx, r.repo.GetSchema().TableName, operation, call, detail...)
+}
+
+// GetByID retrieves a single record by its ID
+func (r *instrumentedRepository) GetByID(ctx context.Context, id interfaces.ID) (record map[string]interface{}, err error) {
+	err = r.observe(ctx, "get_by_id", func(ctx context.Context) error {
+		record, err = r.repo.GetByID(ctx, id)
+		return err
+	}, "id", id.String())
+	return record, err
+}
+
+// FindOne retrieves the first record matching the query
+func (r *instrumentedRepository) FindOne(ctx context.Context, q *interfaces.Query) (record map[string]interface{}, err error) {
+	err = r.observe(ctx, "find_one", func(ctx context.Context) error {
+		record, err = r.repo.FindOne(ctx, q)
+		return err
+	}, queryDetail(q)...)
+	return record, err
+}
+
+// FindMany retrieves multiple records matching the query with pagination
+func (r *instrumentedRepository) FindMany(ctx context.Context, q *interfaces.Query) (result *interfaces.ResultPage, err error) {
+	err = r.observe(ctx, "find_many", func(ctx context.Context) error {
+		result, err = r.repo.FindMany(ctx, q)
+		return err
+	}, queryDetail(q)...)
+	return result, err
+}
+
+// Create inserts a new record
+func (r *instrumentedRepository) Create(ctx context.Context, data map[string]interface{}) (record map[string]interface{}, err error) {
+	err = r.observe(ctx, "create", func(ctx context.Context) error {
+		record, err = r.repo.Create(ctx, data)
+		return err
+	})
+	return record, err
+}
+
+// Update modifies an existing record by ID
+func (r *instrumentedRepository) Update(ctx context.Context, id interfaces.ID, data map[string]interface{}) (record map[string]interface{}, err error) {
+	err = r.observe(ctx, "update", func(ctx context.Context) error {
+		record, err = r.repo.Update(ctx, id, data)
+		return err
+	}, "id", id.String())
+	return record, err
+}
+
+// Upsert inserts or updates based on unique field constraints
+func (r *instrumentedRepository) Upsert(ctx context.Context, uniqueFields map[string]interface{}, data map[string]interface{}) (record map[string]interface{}, err error) {
+	err = r.observe(ctx, "upsert", func(ctx context.Context) error {
+		record, err = r.repo.Upsert(ctx, uniqueFields, data)
+		return err
+	})
+	return record, err
+}
+
+// Delete removes a record by ID
+func (r *instrumentedRepository) Delete(ctx context.Context, id interfaces.ID) error {
+	return r.observe(ctx, "delete", func(ctx context.Context) error {
+		return r.repo.Delete(ctx, id)
+	}, "id", id.String())
+}
+
+// Restore brings back a soft-deleted record by ID
+func (r *instrumentedRepository) Restore(ctx context.Context, id interfaces.ID) (record map[string]interface{}, err error) {
+	err = r.observe(ctx, "restore", func(ctx context.Context) error {
+		record, err = r.repo.Restore(ctx, id)
+		return err
+	}, "id", id.String())
+	return record, err
+}
+
+// CreateMany inserts records in batches
+func (r *instrumentedRepository) CreateMany(ctx context.Context, data []map[string]interface{}, opts *interfaces.BulkOptions) (result *interfaces.BulkResult, err error) {
+	err = r.observe(ctx, "create_many", func(ctx context.Context) error {
+		result, err = r.repo.CreateMany(ctx, data, opts)
+		return err
+	}, "rows", len(data))
+	return result, err
+}
+
+// UpdateMany modifies records by ID in batches
+func (r *instrumentedRepository) UpdateMany(ctx context.Context, updates []interfaces.BulkUpdate, opts *interfaces.BulkOptions) (result *interfaces.BulkResult, err error) {
+	err = r.observe(ctx, "update_many", func(ctx context.Context) error {
+		result, err = r.repo.UpdateMany(ctx, updates, opts)
+		return err
+	}, "rows", len(updates))
+	return result, err
+}
+
+// DeleteMany removes records by ID in batches
+func (r *instrumentedRepository) DeleteMany(ctx context.Context, ids []interfaces.ID, opts *interfaces.BulkOptions) (result *interfaces.BulkResult, err error) {
+	err = r.observe(ctx, "delete_many", func(ctx context.Context) error {
+		result, err = r.repo.DeleteMany(ctx, ids, opts)
+		return err
+	}, "rows", len(ids))
+	return result, err
+}
+
+// Count returns the number of records matching the query
+func (r *instrumentedRepository) Count(ctx context.Context, q *interfaces.Query) (count int64, err error) {
+	err = r.observe(ctx, "count", func(ctx context.Context) error {
+		count, err = r.repo.Count(ctx, q)
+		return err
+	}, queryDetail(q)...)
+	return count, err
+}
+
+// Aggregate returns one row per group with the group fields and the
+// requested aggregates
+func (r *instrumentedRepository) Aggregate(ctx context.Context, q *interfaces.AggregateQuery) (rows []map[string]interface{}, err error) {
+	var detail []interface{}
+	if q != nil {
+		detail = []interface{}{"filters", q.Where, "group_by", q.GroupBy}
+	}
+	err = r.observe(ctx, "aggregate", func(ctx context.Context) error {
+		rows, err = r.repo.Aggregate(ctx, q)
+		return err
+	}, detail...)
+	return rows, err
+}
+
+// GetSchema returns the schema for this repository
+func (r *instrumentedRepository) GetSchema() *interfaces.Schema {
+	return r.repo.GetSchema()
+}
+
+// queryDetail is what the slow-query log shows of q
+func queryDetail(q *interfaces.Query) []interface{} {
+	if q == nil {
+		return nil
+	}
+	detail := []interface{}{"filters", q.Where}
+	if len(q.OrderBy) > 0 {
+		detail = append(detail, "order_by", q.OrderBy)
+	}
+	if q.Limit != nil {
+		detail = append(detail, "limit", *q.Limit)
+	}
+	if q.Offset != nil {
+		detail = append(detail, "offset", *q.Offset)
+	}
+	return detail
+}
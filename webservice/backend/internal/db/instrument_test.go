@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/dbtest"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeRecorder counts recorded calls by table, operation and status
+type fakeRecorder struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (f *fakeRecorder) RecordDBQuery(ctx context.Context, table, operation, status string, duration time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[table+" "+operation+" "+status]++
+}
+
+func (f *fakeRecorder) count(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[key]
+}
+
+func TestInstrumentedDatabase(t *testing.T) {
+	ctx := context.Background()
+
+	inner, err := NewDatabase(&Config{
+		Type: "sqlite",
+		DSN:  "file:" + filepath.Join(t.TempDir(), "instrumented.db"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	recorder := &fakeRecorder{calls: make(map[string]int)}
+	core, logs := observer.New(zap.WarnLevel)
+	db := Instrument(inner, recorder, SlowQueryLog{Logger: zap.New(core).Sugar(), Threshold: time.Nanosecond})
+
+	if err := ConnectAndMigrate(ctx, db, AllSchemas()); err != nil {
+		t.Fatalf("Failed to connect and migrate: %v", err)
+	}
+	defer db.Disconnect(ctx)
+
+	// Instrumentation must not change behaviour
+	dbtest.Run(t, db)
+
+	users := db.Repository(entities.UserSchema)
+	if _, err := users.GetByID(ctx, interfaces.StringID("missing")); !errors.Is(err, interfaces.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if recorder.count("users create ok") == 0 {
+		t.Errorf("Expected creates to be recorded, got %v", recorder.calls)
+	}
+	if recorder.count("users get_by_id not_found") == 0 {
+		t.Errorf("Expected missing records to be recorded as not_found, got %v", recorder.calls)
+	}
+
+	// Every call passes the threshold, so the last one was logged with its SQL
+	entries := logs.FilterMessage("Slow database query").All()
+	if len(entries) == 0 {
+		t.Fatal("Expected slow queries to be logged")
+	}
+	last := entries[len(entries)-1].ContextMap()
+	if last["table"] != "users" || last["operation"] != "get_by_id" || last["id"] != "missing" {
+		t.Errorf("Unexpected slow-query entry: %v", last)
+	}
+	sql, ok := last["sql"].([]interface{})
+	if !ok || len(sql) == 0 || !strings.HasPrefix(sql[0].(string), "SELECT") {
+		t.Errorf("Expected the entry to carry the statement run, got %v", last["sql"])
+	}
+}
+
+func TestSlowQueryLogThreshold(t *testing.T) {
+	ctx := context.Background()
+
+	core, logs := observer.New(zap.WarnLevel)
+	db := Instrument(NewInMemoryDatabase(), nil, SlowQueryLog{Logger: zap.New(core).Sugar(), Threshold: time.Hour})
+	if err := ConnectAndMigrate(ctx, db, AllSchemas()); err != nil {
+		t.Fatalf("Failed to connect and migrate: %v", err)
+	}
+	defer db.Disconnect(ctx)
+
+	if _, err := db.Repository(entities.UserSchema).Count(ctx, nil); err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Expected no calls under the threshold to be logged, got %d", logs.Len())
+	}
+}
@@ -0,0 +1,19 @@
+package interfaces
+
+// ChangeOp is the kind of mutation a ChangeEvent reports
+type ChangeOp string
+
+// Change operations
+const (
+	ChangeCreate ChangeOp = "create"
+	ChangeUpdate ChangeOp = "update"
+	ChangeDelete ChangeOp = "delete" // Includes soft deletes
+)
+
+// ChangeEvent reports a committed change to one record
+type ChangeEvent struct {
+	Table  string                 `json:"table"`
+	Op     ChangeOp               `json:"op"`
+	ID     string                 `json:"id"`
+	Record map[string]interface{} `json:"record"` // The record after the change; for deletes, its last state
+}
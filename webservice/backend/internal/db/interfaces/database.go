@@ -1,6 +1,9 @@
 package interfaces
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 // Database represents the main database interface
 type Database interface {
@@ -16,12 +19,52 @@ type Database interface {
 	// Transaction executes a function within a database transaction
 	Transaction(ctx context.Context, fn func(ctx context.Context, tx Transaction) error) error
 	
+	// TransactionWithOptions executes a function within a database
+	// transaction run as opts asks, rerunning it on ErrTxConflict up to
+	// opts.MaxRetries times. fn must be safe to rerun. Nil opts is the same
+	// as Transaction.
+	TransactionWithOptions(ctx context.Context, opts *TxOptions, fn func(ctx context.Context, tx Transaction) error) error
+	
 	// Repository returns a repository for the given schema
 	Repository(schema *Schema) Repository
 	
+	// RegisterHooks adds hooks run around the mutations of the schema's
+	// table by every repository of this database
+	RegisterHooks(schema *Schema, hooks Hooks)
+	
+	// Watch streams committed changes to records of the schema's table that
+	// match filter, until ctx is done. The channel is closed when ctx is
+	// done or the watcher falls too far behind.
+	Watch(ctx context.Context, schema *Schema, filter *Filters) (<-chan ChangeEvent, error)
+	
 	// Migrate creates tables and applies schema changes
 	Migrate(ctx context.Context, schemas []*Schema) error
 	
 	// Seed inserts initial data into the database
 	Seed(ctx context.Context, schema *Schema, data []map[string]interface{}) error
-}
\ No newline at end of file
+	
+	// Export writes every record of opts.Schemas to w as newline-delimited
+	// ExportRecord JSON, referenced tables first. Soft-deleted records are
+	// included.
+	Export(ctx context.Context, w io.Writer, opts ExportOptions) error
+	
+	// Import creates the records of an Export stream read from r in one
+	// transaction, referenced tables first, keeping their keys and
+	// timestamps
+	Import(ctx context.Context, r io.Reader, opts ImportOptions) error
+}
+// primaryKey marks contexts whose reads must go to the primary database
+type primaryKey struct{}
+
+// WithPrimary returns a context whose Repository reads go to the primary
+// database rather than a read replica, for reads that must see writes the
+// caller just made. Backends without replicas ignore it.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsePrimary reports whether reads made with ctx must go to the primary
+func UsePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+// ExportRecord is one line of the newline-delimited JSON written by
+// Database.Export and read by Database.Import
+type ExportRecord struct {
+	Table  string                 `json:"table"`
+	Record map[string]interface{} `json:"record"`
+}
+
+// ExportOptions configures Database.Export
+type ExportOptions struct {
+	Schemas   []*Schema // Tables to export, in any order
+	BatchSize int       // Records read per query. Default: DefaultBatchSize
+}
+
+// ImportOptions configures Database.Import
+type ImportOptions struct {
+	Schemas   []*Schema // Tables the stream may contain, in any order
+	BatchSize int       // Records created per statement. Default: DefaultBatchSize
+}
@@ -0,0 +1,36 @@
+package interfaces
+
+import "context"
+
+// BeforeCreateHook runs before a record is validated and inserted and may
+// modify data
+type BeforeCreateHook func(ctx context.Context, data map[string]interface{}) error
+
+// AfterCreateHook runs after a record is inserted
+type AfterCreateHook func(ctx context.Context, record map[string]interface{}) error
+
+// BeforeUpdateHook runs before a record is updated and may modify data
+type BeforeUpdateHook func(ctx context.Context, id ID, data map[string]interface{}) error
+
+// AfterUpdateHook runs after a record is updated
+type AfterUpdateHook func(ctx context.Context, record map[string]interface{}) error
+
+// BeforeDeleteHook runs before a record is deleted
+type BeforeDeleteHook func(ctx context.Context, id ID) error
+
+// AfterDeleteHook runs after a record is deleted
+type AfterDeleteHook func(ctx context.Context, id ID) error
+
+// Hooks are callbacks run around the mutations of one table. A mutation and
+// its hooks run in one transaction: an error from a hook aborts the
+// mutation and rolls back what was written through the hook's ctx. Hooks
+// must use that ctx for their own database calls to take part in the
+// transaction.
+type Hooks struct {
+	BeforeCreate []BeforeCreateHook
+	AfterCreate  []AfterCreateHook
+	BeforeUpdate []BeforeUpdateHook
+	AfterUpdate  []AfterUpdateHook
+	BeforeDelete []BeforeDeleteHook
+	AfterDelete  []AfterDeleteHook
+}
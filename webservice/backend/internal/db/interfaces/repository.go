@@ -22,12 +22,29 @@ type Repository interface {
 	// Upsert inserts or updates based on unique field constraints
 	Upsert(ctx context.Context, uniqueFields map[string]interface{}, data map[string]interface{}) (map[string]interface{}, error)
 	
-	// Delete removes a record by ID
+	// Delete removes a record by ID, or marks it deleted if the schema
+	// soft deletes
 	Delete(ctx context.Context, id ID) error
 	
+	// Restore brings back a soft-deleted record by ID
+	Restore(ctx context.Context, id ID) (map[string]interface{}, error)
+	
+	// CreateMany inserts records in batches
+	CreateMany(ctx context.Context, data []map[string]interface{}, opts *BulkOptions) (*BulkResult, error)
+	
+	// UpdateMany modifies records by ID in batches
+	UpdateMany(ctx context.Context, updates []BulkUpdate, opts *BulkOptions) (*BulkResult, error)
+	
+	// DeleteMany removes records by ID in batches
+	DeleteMany(ctx context.Context, ids []ID, opts *BulkOptions) (*BulkResult, error)
+	
 	// Count returns the number of records matching the query
 	Count(ctx context.Context, query *Query) (int64, error)
 	
+	// Aggregate returns one row per group with the group fields and the
+	// requested aggregates
+	Aggregate(ctx context.Context, query *AggregateQuery) ([]map[string]interface{}, error)
+	
 	// GetSchema returns the schema for this repository
 	GetSchema() *Schema
 }
\ No newline at end of file
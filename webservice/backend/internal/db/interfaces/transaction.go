@@ -1,6 +1,9 @@
 package interfaces
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Transaction represents a database transaction
 type Transaction interface {
@@ -12,4 +15,51 @@ type Transaction interface {
 	
 	// IsCompleted returns true if the transaction has been committed or rolled back
 	IsCompleted() bool
-}
\ No newline at end of file
+}
+
+// IsolationLevel is the isolation of a transaction from concurrent ones
+type IsolationLevel string
+
+// Isolation levels, as in SQL. Backends may run a transaction at a stricter
+// level than asked for.
+const (
+	IsolationDefault        IsolationLevel = "" // The backend's default
+	IsolationReadCommitted  IsolationLevel = "read_committed"
+	IsolationRepeatableRead IsolationLevel = "repeatable_read"
+	IsolationSerializable   IsolationLevel = "serializable"
+)
+
+// DefaultRetryBackoff is the wait before the first retry of a conflicting
+// transaction when TxOptions does not set one
+const DefaultRetryBackoff = 10 * time.Millisecond
+
+// TxOptions configures TransactionWithOptions. Nested transactions join the
+// outer one, so only the outermost call's options apply.
+type TxOptions struct {
+	Isolation IsolationLevel `json:"isolation,omitempty"`
+	ReadOnly  bool           `json:"read_only,omitempty"` // Writes fail with ErrReadOnly
+
+	// MaxRetries is how many times the callback is rerun, in a new
+	// transaction, after failing with ErrTxConflict. Default: 0
+	MaxRetries int `json:"max_retries,omitempty"`
+
+	// RetryBackoff is the wait before the first retry, doubled for each
+	// further one and jittered. Default: DefaultRetryBackoff
+	RetryBackoff time.Duration `json:"retry_backoff,omitempty"`
+}
+
+// GetMaxRetries returns the configured number of retries
+func (o *TxOptions) GetMaxRetries() int {
+	if o == nil || o.MaxRetries < 0 {
+		return 0
+	}
+	return o.MaxRetries
+}
+
+// GetRetryBackoff returns the configured backoff or DefaultRetryBackoff
+func (o *TxOptions) GetRetryBackoff() time.Duration {
+	if o == nil || o.RetryBackoff <= 0 {
+		return DefaultRetryBackoff
+	}
+	return o.RetryBackoff
+}
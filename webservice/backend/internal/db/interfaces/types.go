@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -46,11 +47,20 @@ type FilterOperator struct {
 	IsNull   bool          `json:"is_null,omitempty"`
 	IsNotNull bool         `json:"is_not_null,omitempty"`
 	CaseSensitive *bool    `json:"case_sensitive,omitempty"`
+	Contains interface{}   `json:"contains,omitempty"` // JSON fields only: see Filter
+	Search   string        `json:"search,omitempty"`   // String fields only: matches text containing every word, ranked in ResultPage.Ranks
 }
 
-// Filter represents a field filter
+// Filter represents a field filter. Filters on "json" fields compare JSON
+// values, as a whole or at Path, and support equality (Value, Eq, Ne),
+// IsNull, IsNotNull and Contains. A path that does not exist reads as null.
+// Contains matches values containing the given JSON like the PostgreSQL @>
+// operator: an object contains an object whose keys it has with contained
+// values, an array contains an array whose elements it each contains, and
+// scalars contain equal scalars.
 type Filter struct {
 	Field    string          `json:"field"`
+	Path     []string        `json:"path,omitempty"` // JSON fields only: object keys leading to the filtered value
 	Value    interface{}     `json:"value,omitempty"`
 	Operator *FilterOperator `json:"operator,omitempty"`
 }
@@ -70,20 +80,115 @@ type OrderBy struct {
 
 // Query represents a database query with filtering, sorting, and pagination
 type Query struct {
-	Where   *Filters   `json:"where,omitempty"`
-	Select  []string   `json:"select,omitempty"`
-	OrderBy []OrderBy  `json:"order_by,omitempty"`
-	Limit   *int       `json:"limit,omitempty"`
-	Offset  *int       `json:"offset,omitempty"`
-	Include []string   `json:"include,omitempty"`
+	Where       *Filters  `json:"where,omitempty"`
+	Select      []string  `json:"select,omitempty"`
+	OrderBy     []OrderBy `json:"order_by,omitempty"`
+	Limit       *int      `json:"limit,omitempty"`
+	Offset      *int      `json:"offset,omitempty"`
+	Include     []string  `json:"include,omitempty"`      // Relations to eager load, e.g. "posts" or "posts.author"
+	WithDeleted bool      `json:"with_deleted,omitempty"` // Include soft-deleted records
+	After       string    `json:"after,omitempty"`        // Cursor: return the page following ResultPage.NextCursor
+	Before      string    `json:"before,omitempty"`       // Cursor: return the page preceding ResultPage.PrevCursor
 }
 
-// ResultPage represents paginated query results
+// GroupBy represents a grouping field of an aggregate query
+type GroupBy struct {
+	Field    string `json:"field"`
+	Truncate string `json:"truncate,omitempty"` // Time fields only: "hour", "day" or "month", in UTC
+}
+
+// Aggregate represents one aggregate computed per group
+type Aggregate struct {
+	Function string `json:"function"`        // "count", "sum", "avg", "min" or "max"
+	Field    string `json:"field,omitempty"` // Aggregated field. Empty counts rows
+	Alias    string `json:"alias,omitempty"` // Result key. Default: function, or function_field
+}
+
+// AggregateQuery groups the records matching Where and computes aggregates
+// per group. Having, OrderBy, Limit and Offset apply to the result rows and
+// refer to group fields and aggregate aliases. Without OrderBy, rows are
+// sorted by the group fields.
+type AggregateQuery struct {
+	Where       *Filters    `json:"where,omitempty"`
+	GroupBy     []GroupBy   `json:"group_by,omitempty"`
+	Aggregates  []Aggregate `json:"aggregates"`
+	Having      *Filters    `json:"having,omitempty"`
+	OrderBy     []OrderBy   `json:"order_by,omitempty"`
+	Limit       *int        `json:"limit,omitempty"`
+	Offset      *int        `json:"offset,omitempty"`
+	WithDeleted bool        `json:"with_deleted,omitempty"` // Include soft-deleted records
+}
+
+// ResultPage represents paginated query results. The cursors are set for
+// limited queries ordered by non-nullable fields when a next or previous
+// page exists.
 type ResultPage struct {
-	Data     []map[string]interface{} `json:"data"`
-	Total    int64                    `json:"total"`
-	Page     int                      `json:"page"`
-	PageSize int                      `json:"page_size"`
+	Data       []map[string]interface{} `json:"data"`
+	Total      int64                    `json:"total"`
+	Page       int                      `json:"page"`
+	PageSize   int                      `json:"page_size"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+	PrevCursor string                   `json:"prev_cursor,omitempty"`
+
+	// Ranks holds the relevance of each record in Data to the query's
+	// Search filters, higher is better, when it has any. Searches without
+	// OrderBy are sorted by rank. Ranks only compare within one backend.
+	Ranks []float64 `json:"ranks,omitempty"`
+}
+
+// DefaultBatchSize is the number of rows written per statement by batch
+// mutations when BulkOptions does not set one
+const DefaultBatchSize = 500
+
+// BulkOptions configures CreateMany, UpdateMany and DeleteMany
+type BulkOptions struct {
+	BatchSize int `json:"batch_size,omitempty"` // Rows per statement. Default: DefaultBatchSize
+}
+
+// GetBatchSize returns the configured batch size or DefaultBatchSize
+func (o *BulkOptions) GetBatchSize() int {
+	if o == nil || o.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return o.BatchSize
+}
+
+// BulkUpdate is one row of an UpdateMany call
+type BulkUpdate struct {
+	ID   ID                     `json:"id"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// RowError reports the failure of one input row of a batch mutation
+type RowError struct {
+	Index int   `json:"index"`
+	Err   error `json:"-"`
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Index, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// BulkResult reports the outcome of a batch mutation. Rows fail
+// independently: a row rejected by validation or a constraint is listed in
+// Errors and the remaining rows are still written.
+type BulkResult struct {
+	Records  []map[string]interface{} `json:"records,omitempty"` // Index-aligned with the input, nil for failed rows. Unset by DeleteMany
+	Errors   []RowError               `json:"errors,omitempty"`  // Sorted by Index
+	Affected int64                    `json:"affected"`          // Rows written or deleted
+}
+
+// Err joins the row errors, or returns nil when every row succeeded
+func (r *BulkResult) Err() error {
+	errs := make([]error, len(r.Errors))
+	for i := range r.Errors {
+		errs[i] = &r.Errors[i]
+	}
+	return errors.Join(errs...)
 }
 
 // Schema represents entity schema definition
@@ -91,11 +196,27 @@ type Schema struct {
 	TableName string                 `json:"table_name"`
 	Fields    map[string]FieldSchema `json:"fields"`
 	Indexes   []Index               `json:"indexes,omitempty"`
+	Relations map[string]Relation   `json:"relations,omitempty"` // Keyed by the name used in Query.Include
+
+	// VersionField names an int or int64 field used for optimistic locking.
+	// It starts at 1 and is incremented by every update; an update whose
+	// data carries the field only applies if it equals the stored version
+	// and fails with ErrConflict otherwise.
+	VersionField string `json:"version_field,omitempty"`
+
+	// SoftDelete makes Delete set DeletedAtField instead of removing the
+	// row. Soft-deleted records are hidden from reads, updates and deletes
+	// unless a query sets WithDeleted, and Restore brings them back. The
+	// schema must declare DeletedAtField as a nullable time field.
+	SoftDelete bool `json:"soft_delete,omitempty"`
 }
 
+// DeletedAtField is the time field that marks soft-deleted records
+const DeletedAtField = "deleted_at"
+
 // FieldSchema represents a field definition
 type FieldSchema struct {
-	Type         string      `json:"type"`         // "string", "int", "int64", "bool", "time", "float64"
+	Type         string      `json:"type"`         // "string", "int", "int64", "bool", "time", "float64", "json"
 	Nullable     bool        `json:"nullable"`
 	DefaultValue interface{} `json:"default_value,omitempty"`
 	Unique       bool        `json:"unique"`
@@ -110,7 +231,27 @@ type ForeignKey struct {
 	OnDelete string `json:"on_delete,omitempty"` // CASCADE, SET_NULL, RESTRICT
 }
 
-// Index represents a database index
+// Relation types
+const (
+	RelationHasMany   = "hasMany"
+	RelationBelongsTo = "belongsTo"
+)
+
+// Relation links a schema to records of another table so they can be
+// eager loaded with Query.Include. For hasMany the foreign key lives on the
+// related table; for belongsTo it lives on this one.
+type Relation struct {
+	Type       string    `json:"type"`                 // RelationHasMany or RelationBelongsTo
+	Table      string    `json:"table"`                // Related table
+	ForeignKey string    `json:"foreign_key"`          // Column holding the reference
+	References string    `json:"references,omitempty"` // Referenced column. Default: "id"
+	OrderBy    []OrderBy `json:"order_by,omitempty"`   // Ordering of hasMany records
+}
+
+// Index represents a database index. A unique index over several columns
+// is a composite unique constraint; as in SQL, rows with a null in any of
+// its columns never conflict. Migrate creates missing indexes and recreates
+// those whose columns or uniqueness changed.
 type Index struct {
 	Name    string   `json:"name"`
 	Columns []string `json:"columns"`
@@ -125,6 +266,9 @@ var (
 	ErrInvalidQuery          = errors.New("invalid query")
 	ErrTransactionCompleted  = errors.New("transaction already completed")
 	ErrDatabaseNotConnected  = errors.New("database not connected")
+	ErrConflict              = errors.New("version conflict")
+	ErrTxConflict            = errors.New("transaction conflict")
+	ErrReadOnly              = errors.New("write in read-only transaction")
 )
 
 // DatabaseError wraps database-specific errors
@@ -0,0 +1,352 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// AggregateAlias returns the result key of an aggregate
+func AggregateAlias(agg interfaces.Aggregate) string {
+	if agg.Alias != "" {
+		return agg.Alias
+	}
+	if agg.Field == "" {
+		return agg.Function
+	}
+	return agg.Function + "_" + agg.Field
+}
+
+// AggregateSchema validates q against schema and describes the rows
+// Aggregate returns: one field per group field and per aggregate alias.
+// count yields int64, sum int64 or float64 depending on the field, avg
+// float64, and min and max the type of the aggregated field.
+func AggregateSchema(schema *interfaces.Schema, q *interfaces.AggregateQuery) (*interfaces.Schema, error) {
+	if q == nil || (len(q.GroupBy) == 0 && len(q.Aggregates) == 0) {
+		return nil, fmt.Errorf("%w: aggregate query needs group fields or aggregates", interfaces.ErrInvalidQuery)
+	}
+
+	out := &interfaces.Schema{
+		TableName: schema.TableName,
+		Fields:    make(map[string]interfaces.FieldSchema),
+	}
+
+	for _, group := range q.GroupBy {
+		field, exists := schema.Fields[group.Field]
+		if !exists {
+			return nil, fmt.Errorf("%w: unknown group field '%s'", interfaces.ErrInvalidQuery, group.Field)
+		}
+		if _, duplicate := out.Fields[group.Field]; duplicate {
+			return nil, fmt.Errorf("%w: duplicate group field '%s'", interfaces.ErrInvalidQuery, group.Field)
+		}
+		if group.Truncate != "" {
+			if field.Type != "time" {
+				return nil, fmt.Errorf("%w: cannot truncate non-time field '%s'", interfaces.ErrInvalidQuery, group.Field)
+			}
+			switch group.Truncate {
+			case "hour", "day", "month":
+			default:
+				return nil, fmt.Errorf("%w: unsupported truncation '%s'", interfaces.ErrInvalidQuery, group.Truncate)
+			}
+		}
+		out.Fields[group.Field] = interfaces.FieldSchema{Type: field.Type, Nullable: field.Nullable}
+	}
+
+	for _, agg := range q.Aggregates {
+		resultType, err := aggregateType(schema, agg)
+		if err != nil {
+			return nil, err
+		}
+		alias := AggregateAlias(agg)
+		if _, duplicate := out.Fields[alias]; duplicate {
+			return nil, fmt.Errorf("%w: duplicate aggregate alias '%s'", interfaces.ErrInvalidQuery, alias)
+		}
+		out.Fields[alias] = interfaces.FieldSchema{Type: resultType, Nullable: agg.Function != "count"}
+	}
+
+	if err := validateFilterFields(out, q.Having); err != nil {
+		return nil, err
+	}
+	for _, order := range q.OrderBy {
+		if _, exists := out.Fields[order.Field]; !exists {
+			return nil, fmt.Errorf("%w: cannot order by '%s'", interfaces.ErrInvalidQuery, order.Field)
+		}
+	}
+
+	return out, nil
+}
+
+// aggregateType returns the field type an aggregate produces
+func aggregateType(schema *interfaces.Schema, agg interfaces.Aggregate) (string, error) {
+	field, exists := schema.Fields[agg.Field]
+	if agg.Field != "" && !exists {
+		return "", fmt.Errorf("%w: unknown aggregate field '%s'", interfaces.ErrInvalidQuery, agg.Field)
+	}
+
+	switch agg.Function {
+	case "count":
+		return "int64", nil
+	case "sum", "avg":
+		switch field.Type {
+		case "int", "int64":
+			if agg.Function == "sum" {
+				return "int64", nil
+			}
+			return "float64", nil
+		case "float64":
+			return "float64", nil
+		}
+		return "", fmt.Errorf("%w: %s needs a numeric field, got '%s'", interfaces.ErrInvalidQuery, agg.Function, agg.Field)
+	case "min", "max":
+		switch field.Type {
+		case "int", "int64", "float64", "string", "time":
+			return field.Type, nil
+		}
+		return "", fmt.Errorf("%w: %s needs an ordered field, got '%s'", interfaces.ErrInvalidQuery, agg.Function, agg.Field)
+	default:
+		return "", fmt.Errorf("%w: unsupported aggregate function '%s'", interfaces.ErrInvalidQuery, agg.Function)
+	}
+}
+
+// validateFilterFields rejects filters on fields the schema does not have
+func validateFilterFields(schema *interfaces.Schema, filters *interfaces.Filters) error {
+	if filters == nil {
+		return nil
+	}
+	for _, condition := range filters.Conditions {
+		if _, exists := schema.Fields[condition.Field]; !exists {
+			return fmt.Errorf("%w: unknown field '%s'", interfaces.ErrInvalidQuery, condition.Field)
+		}
+	}
+	for _, nested := range append(append([]*interfaces.Filters{}, filters.AND...), filters.OR...) {
+		if err := validateFilterFields(schema, nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TruncateTime truncates t in UTC to the start of its hour, day or month
+func TruncateTime(t time.Time, unit string) time.Time {
+	t = t.UTC()
+	switch unit {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t
+}
+
+// aggregateGroup accumulates the records of one group
+type aggregateGroup struct {
+	row     map[string]interface{}
+	records []map[string]interface{}
+}
+
+// Aggregate groups records and computes the aggregates of q. out is the
+// schema returned by AggregateSchema for q.
+func (b *Builder) Aggregate(records []map[string]interface{}, q *interfaces.AggregateQuery, out *interfaces.Schema) []map[string]interface{} {
+	var groups []*aggregateGroup
+	index := make(map[string]*aggregateGroup)
+
+	// Without group fields every record falls into one group, which exists
+	// even when there are no records, as in SQL
+	if len(q.GroupBy) == 0 {
+		group := &aggregateGroup{row: map[string]interface{}{}}
+		groups = append(groups, group)
+		index[""] = group
+	}
+
+	for _, record := range records {
+		row := make(map[string]interface{}, len(q.GroupBy))
+		keyParts := make([]string, len(q.GroupBy))
+		for i, group := range q.GroupBy {
+			value := record[group.Field]
+			if t, ok := value.(time.Time); ok && group.Truncate != "" {
+				value = TruncateTime(t, group.Truncate)
+			}
+			row[group.Field] = value
+			keyParts[i] = fmt.Sprintf("%T=%v", value, value)
+		}
+
+		key := strings.Join(keyParts, "\x00")
+		group, exists := index[key]
+		if !exists {
+			group = &aggregateGroup{row: row}
+			groups = append(groups, group)
+			index[key] = group
+		}
+		group.records = append(group.records, record)
+	}
+
+	rows := make([]map[string]interface{}, 0, len(groups))
+	for _, group := range groups {
+		for _, agg := range q.Aggregates {
+			group.row[AggregateAlias(agg)] = b.aggregate(group.records, agg, out.Fields[AggregateAlias(agg)].Type)
+		}
+		rows = append(rows, group.row)
+	}
+
+	if q.Having != nil {
+		having := CoerceFilters(out, q.Having)
+		outBuilder := NewBuilder(out)
+		var filtered []map[string]interface{}
+		for _, row := range rows {
+			if outBuilder.MatchesFilters(row, having) {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+
+	orderBy := q.OrderBy
+	if len(orderBy) == 0 {
+		for _, group := range q.GroupBy {
+			orderBy = append(orderBy, interfaces.OrderBy{Field: group.Field, Direction: "asc"})
+		}
+	}
+	rows = b.ApplySort(rows, orderBy)
+
+	rows = b.ApplyPagination(rows, q.Limit, q.Offset)
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	return rows
+}
+
+// aggregate computes one aggregate over the records of a group. Null
+// values are skipped; sum, avg, min and max of no values are nil.
+func (b *Builder) aggregate(records []map[string]interface{}, agg interfaces.Aggregate, resultType string) interface{} {
+	if agg.Function == "count" {
+		var count int64
+		for _, record := range records {
+			if agg.Field == "" || record[agg.Field] != nil {
+				count++
+			}
+		}
+		return count
+	}
+
+	var values []interface{}
+	for _, record := range records {
+		if value := record[agg.Field]; value != nil {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	switch agg.Function {
+	case "sum", "avg":
+		var intSum int64
+		var floatSum float64
+		for _, value := range values {
+			switch v := value.(type) {
+			case int:
+				intSum += int64(v)
+				floatSum += float64(v)
+			case int64:
+				intSum += v
+				floatSum += float64(v)
+			case float64:
+				floatSum += v
+			}
+		}
+		if agg.Function == "avg" {
+			return floatSum / float64(len(values))
+		}
+		if resultType == "int64" {
+			return intSum
+		}
+		return floatSum
+	case "min", "max":
+		best := values[0]
+		for _, value := range values[1:] {
+			cmp := b.compare(value, best)
+			if (agg.Function == "min" && cmp < 0) || (agg.Function == "max" && cmp > 0) {
+				best = value
+			}
+		}
+		return best
+	}
+	return nil
+}
+
+// CoerceFilters returns a copy of filters with values converted to the
+// types schema declares, so that for example an int bound compares against
+// an int64 count
+func CoerceFilters(schema *interfaces.Schema, filters *interfaces.Filters) *interfaces.Filters {
+	if filters == nil {
+		return nil
+	}
+
+	coerced := &interfaces.Filters{}
+	for _, condition := range filters.Conditions {
+		fieldType := schema.Fields[condition.Field].Type
+		condition.Value = coerceValue(fieldType, condition.Value)
+		if condition.Operator != nil {
+			op := *condition.Operator
+			op.Eq = coerceValue(fieldType, op.Eq)
+			op.Ne = coerceValue(fieldType, op.Ne)
+			op.Gt = coerceValue(fieldType, op.Gt)
+			op.Gte = coerceValue(fieldType, op.Gte)
+			op.Lt = coerceValue(fieldType, op.Lt)
+			op.Lte = coerceValue(fieldType, op.Lte)
+			op.In = coerceValues(fieldType, op.In)
+			op.NotIn = coerceValues(fieldType, op.NotIn)
+			condition.Operator = &op
+		}
+		coerced.Conditions = append(coerced.Conditions, condition)
+	}
+	for _, and := range filters.AND {
+		coerced.AND = append(coerced.AND, CoerceFilters(schema, and))
+	}
+	for _, or := range filters.OR {
+		coerced.OR = append(coerced.OR, CoerceFilters(schema, or))
+	}
+	return coerced
+}
+
+func coerceValues(fieldType string, values []interface{}) []interface{} {
+	if values == nil {
+		return nil
+	}
+	coerced := make([]interface{}, len(values))
+	for i, value := range values {
+		coerced[i] = coerceValue(fieldType, value)
+	}
+	return coerced
+}
+
+func coerceValue(fieldType string, value interface{}) interface{} {
+	switch fieldType {
+	case "int":
+		if v, ok := value.(int64); ok {
+			return int(v)
+		}
+	case "int64":
+		if v, ok := value.(int); ok {
+			return int64(v)
+		}
+	case "float64":
+		switch v := value.(type) {
+		case int:
+			return float64(v)
+		case int64:
+			return float64(v)
+		}
+	case "time":
+		if s, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t
+			}
+		}
+	}
+	return value
+}
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -11,6 +12,7 @@ import (
 // Builder helps construct database queries
 type Builder struct {
 	schema *interfaces.Schema
+	index  *TextIndex // Optional cache for Search filters
 }
 
 // NewBuilder creates a new query builder for a schema
@@ -58,12 +60,17 @@ func (b *Builder) MatchesFilters(record map[string]interface{}, filters *interfa
 func (b *Builder) matchesCondition(record map[string]interface{}, condition interfaces.Filter) bool {
 	fieldValue, exists := record[condition.Field]
 	
+	// JSON values are compared structurally
+	if b.schema.Fields[condition.Field].Type == "json" {
+		return b.matchesJSON(fieldValue, condition)
+	}
+	
 	// Handle simple equality
 	if condition.Operator == nil {
 		if !exists && condition.Value == nil {
 			return true
 		}
-		return fieldValue == condition.Value
+		return b.equal(fieldValue, condition.Value)
 	}
 	
 	op := condition.Operator
@@ -83,10 +90,10 @@ func (b *Builder) matchesCondition(record map[string]interface{}, condition inte
 	
 	// Equality checks
 	if op.Eq != nil {
-		return fieldValue == op.Eq
+		return b.equal(fieldValue, op.Eq)
 	}
 	if op.Ne != nil {
-		return fieldValue != op.Ne
+		return !b.equal(fieldValue, op.Ne)
 	}
 	
 	// Comparison checks (only for comparable types)
@@ -149,9 +156,25 @@ func (b *Builder) matchesCondition(record map[string]interface{}, condition inte
 		return !strings.Contains(strValue, pattern)
 	}
 	
+	// Full-text search
+	if op.Search != "" {
+		return b.searchRank(record, condition.Field, op.Search) > 0
+	}
+	
 	return true
 }
 
+// equal compares values like ==, but treats times as equal when they are
+// the same instant regardless of location or monotonic reading
+func (b *Builder) equal(a, other interface{}) bool {
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := other.(time.Time); ok {
+			return at.Equal(bt)
+		}
+	}
+	return a == other
+}
+
 func (b *Builder) compare(a, other interface{}) int {
 	switch av := a.(type) {
 	case int:
@@ -185,6 +208,17 @@ func (b *Builder) compare(a, other interface{}) int {
 		if bv, ok := other.(string); ok {
 			return strings.Compare(av, bv)
 		}
+	case time.Time:
+		if bv, ok := other.(time.Time); ok {
+			return av.Compare(bv)
+		}
+	case bool:
+		if bv, ok := other.(bool); ok && av != bv {
+			if av {
+				return 1
+			}
+			return -1
+		}
 	}
 	return 0
 }
@@ -312,6 +346,10 @@ func (b *Builder) validateFieldType(fieldName string, value interface{}, expecte
 		if _, ok := value.(float64); !ok {
 			return fmt.Errorf("field '%s' must be a float64", fieldName)
 		}
+	case "json":
+		if _, err := json.Marshal(value); err != nil {
+			return fmt.Errorf("field '%s' must be JSON encodable: %v", fieldName, err)
+		}
 	case "time":
 		// Accept both time.Time and string representations
 		switch value.(type) {
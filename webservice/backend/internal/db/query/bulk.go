@@ -0,0 +1,167 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// IsRowError reports whether err was caused by the data of a single row
+// rather than by the database, so a batch mutation can report it and go on
+func IsRowError(err error) bool {
+	return errors.Is(err, interfaces.ErrNotFound) ||
+		errors.Is(err, interfaces.ErrUniqueConstraint) ||
+		errors.Is(err, interfaces.ErrForeignKeyConstraint) ||
+		errors.Is(err, interfaces.ErrInvalidQuery) ||
+		errors.Is(err, interfaces.ErrConflict)
+}
+
+// BulkWriter runs a batch mutation for a SQL backend. Each batch is written
+// in its own transaction; when a batch fails because of a row, it is rolled
+// back and its rows are retried one at a time to find the offending ones.
+type BulkWriter struct {
+	// Transaction runs fn atomically, as a savepoint when ctx already
+	// carries a transaction
+	Transaction func(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// Batch writes the rows at indexes and returns the written records by
+	// input index. Rows absent from the map matched nothing.
+	Batch func(ctx context.Context, indexes []int) (map[int]map[string]interface{}, error)
+
+	// Single writes the row at index
+	Single func(ctx context.Context, index int) (map[string]interface{}, error)
+
+	// Missing explains why Batch did not write the row at index. Nil
+	// reports ErrNotFound.
+	Missing func(ctx context.Context, index int) error
+}
+
+// Run writes the rows at indexes in batches of batchSize and records the
+// outcome in result. It only fails for errors not attributable to a row.
+func (w *BulkWriter) Run(ctx context.Context, result *interfaces.BulkResult, indexes []int, batchSize int) error {
+	for start := 0; start < len(indexes); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunk := indexes[start:min(start+batchSize, len(indexes))]
+
+		var written map[int]map[string]interface{}
+		err := w.Transaction(ctx, func(ctx context.Context) error {
+			var err error
+			written, err = w.Batch(ctx, chunk)
+			return err
+		})
+		if err == nil {
+			for _, index := range chunk {
+				record, ok := written[index]
+				if !ok {
+					result.Errors = append(result.Errors, interfaces.RowError{Index: index, Err: w.missing(ctx, index)})
+					continue
+				}
+				w.record(result, index, record)
+			}
+			continue
+		}
+		if !IsRowError(err) {
+			return err
+		}
+
+		for _, index := range chunk {
+			var record map[string]interface{}
+			err := w.Transaction(ctx, func(ctx context.Context) error {
+				var err error
+				record, err = w.Single(ctx, index)
+				return err
+			})
+			if err != nil {
+				if !IsRowError(err) {
+					return err
+				}
+				result.Errors = append(result.Errors, interfaces.RowError{Index: index, Err: err})
+				continue
+			}
+			w.record(result, index, record)
+		}
+	}
+
+	SortRowErrors(result)
+	return nil
+}
+
+func (w *BulkWriter) missing(ctx context.Context, index int) error {
+	if w.Missing == nil {
+		return interfaces.ErrNotFound
+	}
+	return w.Missing(ctx, index)
+}
+
+func (w *BulkWriter) record(result *interfaces.BulkResult, index int, record map[string]interface{}) {
+	if result.Records != nil {
+		result.Records[index] = record
+	}
+	result.Affected++
+}
+
+// CreateEach creates data one row at a time through repo, reporting the
+// rows that fail, for backends or tables where rows cannot be batched
+func CreateEach(ctx context.Context, repo interfaces.Repository, data []map[string]interface{}) (*interfaces.BulkResult, error) {
+	result := &interfaces.BulkResult{Records: make([]map[string]interface{}, len(data))}
+	for i, row := range data {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := repo.Create(ctx, row)
+		if err != nil {
+			result.Errors = append(result.Errors, interfaces.RowError{Index: i, Err: err})
+			continue
+		}
+		result.Records[i] = record
+		result.Affected++
+	}
+	return result, nil
+}
+
+// UpdateEach updates records one at a time through repo, reporting the
+// rows that fail
+func UpdateEach(ctx context.Context, repo interfaces.Repository, updates []interfaces.BulkUpdate) (*interfaces.BulkResult, error) {
+	result := &interfaces.BulkResult{Records: make([]map[string]interface{}, len(updates))}
+	for i, update := range updates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := repo.Update(ctx, update.ID, update.Data)
+		if err != nil {
+			result.Errors = append(result.Errors, interfaces.RowError{Index: i, Err: err})
+			continue
+		}
+		result.Records[i] = record
+		result.Affected++
+	}
+	return result, nil
+}
+
+// DeleteEach deletes records one at a time through repo, reporting the
+// rows that fail
+func DeleteEach(ctx context.Context, repo interfaces.Repository, ids []interfaces.ID) (*interfaces.BulkResult, error) {
+	result := &interfaces.BulkResult{}
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := repo.Delete(ctx, id); err != nil {
+			result.Errors = append(result.Errors, interfaces.RowError{Index: i, Err: err})
+			continue
+		}
+		result.Affected++
+	}
+	return result, nil
+}
+
+// SortRowErrors orders the row errors of result by input index
+func SortRowErrors(result *interfaces.BulkResult) {
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Index < result.Errors[j].Index
+	})
+}
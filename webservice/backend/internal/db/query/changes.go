@@ -0,0 +1,177 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// WatchBuffer is the number of events a watcher may fall behind before it
+// is closed
+const WatchBuffer = 256
+
+// ChangeFeed dispatches change events to in-process watchers. Events
+// published with a context from CollectChanges are held until FlushChanges,
+// so a transaction's changes reach watchers only once it commits. The zero
+// value is ready to use.
+type ChangeFeed struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+// watcher is one Watch call
+type watcher struct {
+	table   string
+	filter  *interfaces.Filters
+	builder *Builder
+	events  chan interfaces.ChangeEvent
+}
+
+// pendingKey carries the changes of a transaction in a context
+type pendingKey struct{}
+
+// pendingChanges holds the events published inside one transaction
+type pendingChanges struct {
+	feed   *ChangeFeed
+	mu     sync.Mutex
+	events []interfaces.ChangeEvent
+}
+
+// Watch registers a watcher for changes to schema's table that match
+// filter. It is removed and its channel closed when ctx is done, or when it
+// falls WatchBuffer events behind.
+func (f *ChangeFeed) Watch(ctx context.Context, schema *interfaces.Schema, filter *interfaces.Filters) (<-chan interfaces.ChangeEvent, error) {
+	if err := validateFilterFields(schema, filter); err != nil {
+		return nil, err
+	}
+
+	w := &watcher{
+		table:   schema.TableName,
+		filter:  CoerceFilters(schema, filter),
+		builder: NewBuilder(schema),
+		events:  make(chan interfaces.ChangeEvent, WatchBuffer),
+	}
+
+	f.mu.Lock()
+	if f.watchers == nil {
+		f.watchers = make(map[*watcher]struct{})
+	}
+	f.watchers[w] = struct{}{}
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.remove(w)
+	}()
+	return w.events, nil
+}
+
+// Watching reports whether any watcher is registered
+func (f *ChangeFeed) Watching() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.watchers) > 0
+}
+
+// Publish delivers events to the matching watchers, or holds them until
+// FlushChanges if ctx collects changes
+func (f *ChangeFeed) Publish(ctx context.Context, events ...interfaces.ChangeEvent) {
+	if pending, ok := ctx.Value(pendingKey{}).(*pendingChanges); ok && pending.feed == f {
+		pending.mu.Lock()
+		pending.events = append(pending.events, events...)
+		pending.mu.Unlock()
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, event := range events {
+		for w := range f.watchers {
+			if w.table != event.Table {
+				continue
+			}
+			if w.filter != nil && !w.builder.MatchesFilters(event.Record, w.filter) {
+				continue
+			}
+
+			// Each watcher gets its own copy of the record
+			delivered := event
+			delivered.Record = copyData(event.Record)
+			select {
+			case w.events <- delivered:
+			default:
+				// Too slow: close rather than silently drop events
+				f.remove(w)
+			}
+		}
+	}
+}
+
+// CloseAll removes every watcher, for when the source of events is lost
+func (f *ChangeFeed) CloseAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for w := range f.watchers {
+		f.remove(w)
+	}
+}
+
+// remove unregisters w and closes its channel. Callers hold f.mu.
+func (f *ChangeFeed) remove(w *watcher) {
+	if _, exists := f.watchers[w]; exists {
+		delete(f.watchers, w)
+		close(w.events)
+	}
+}
+
+// CollectChanges returns a context for a transaction in which published
+// events are held rather than delivered
+func (f *ChangeFeed) CollectChanges(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pendingKey{}, &pendingChanges{feed: f})
+}
+
+// FlushChanges publishes the events held by txCtx once its transaction has
+// committed. ctx is the context the transaction was started with, so the
+// events of a nested transaction move on to the enclosing one.
+func (f *ChangeFeed) FlushChanges(ctx, txCtx context.Context) {
+	pending, ok := txCtx.Value(pendingKey{}).(*pendingChanges)
+	if !ok || pending.feed != f {
+		return
+	}
+
+	pending.mu.Lock()
+	events := pending.events
+	pending.events = nil
+	pending.mu.Unlock()
+
+	if len(events) > 0 {
+		f.Publish(ctx, events...)
+	}
+}
+
+// Change builds the event reporting op on record of schema
+func Change(schema *interfaces.Schema, op interfaces.ChangeOp, record map[string]interface{}) interfaces.ChangeEvent {
+	return interfaces.ChangeEvent{
+		Table:  schema.TableName,
+		Op:     op,
+		ID:     fmt.Sprint(record[primaryKey(schema)]),
+		Record: copyData(record),
+	}
+}
+
+// primaryKey returns the name of schema's primary key field, "id" by default
+func primaryKey(schema *interfaces.Schema) string {
+	for name, field := range schema.Fields {
+		if field.PrimaryKey {
+			return name
+		}
+	}
+	return "id"
+}
@@ -0,0 +1,251 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// cursorToken is the decoded form of a cursor: the sort keys it was made
+// for and the values of the row it points at
+type cursorToken struct {
+	Keys   []string      `json:"k"`
+	Values []interface{} `json:"v"`
+}
+
+// Pagination is the plan for one FindMany page. Backends count with the
+// query's own Where, read records with Pagination's Where, OrderBy, Limit,
+// Offset and Select, and pass them to Page.
+type Pagination struct {
+	Where   *interfaces.Filters
+	OrderBy []interfaces.OrderBy
+	Limit   *int
+	Offset  *int
+	Select  []string
+
+	order   []interfaces.OrderBy // Requested order with the key tiebreaker
+	limit   *int                 // Requested page size
+	before  bool
+	cursors bool     // Whether the order supports cursors
+	earlier bool     // Whether rows precede the page
+	added   []string // Fields added to Select for cursors
+}
+
+// Paginate plans the page q asks for. When q has a limit and an order of
+// non-nullable fields, the primary key is added as a tiebreaker and one
+// extra row is read to tell whether a next page exists. After and Before
+// continue from a cursor of an earlier page, in the order given, by
+// primary key if none, and cannot be combined with Offset or a nullable
+// sort field.
+func Paginate(schema *interfaces.Schema, q *interfaces.Query) (*Pagination, error) {
+	p := &Pagination{
+		Where:   q.Where,
+		OrderBy: q.OrderBy,
+		Limit:   q.Limit,
+		Offset:  q.Offset,
+		Select:  q.Select,
+		limit:   q.Limit,
+	}
+
+	token := q.After
+	if q.Before != "" {
+		if token != "" {
+			return nil, fmt.Errorf("%w: cannot page both after and before a cursor", interfaces.ErrInvalidQuery)
+		}
+		token, p.before = q.Before, true
+	}
+	if token != "" && q.Offset != nil {
+		return nil, fmt.Errorf("%w: cannot combine a cursor with offset", interfaces.ErrInvalidQuery)
+	}
+
+	p.order, p.cursors = cursorOrder(schema, q.OrderBy)
+	if token == "" && (q.Limit == nil || len(q.OrderBy) == 0 || !p.cursors) {
+		p.cursors = false
+		return p, nil
+	}
+	if !p.cursors {
+		return nil, fmt.Errorf("%w: cursor pagination needs a non-nullable sort order", interfaces.ErrInvalidQuery)
+	}
+
+	p.OrderBy = p.order
+	if p.before {
+		p.OrderBy = reverseOrder(p.order)
+	}
+	if q.Limit != nil {
+		extra := *q.Limit + 1
+		p.Limit = &extra
+	}
+	p.earlier = q.Offset != nil && *q.Offset > 0
+
+	if token != "" {
+		values, err := decodeCursor(schema, p.order, token)
+		if err != nil {
+			return nil, err
+		}
+		keyset := keysetFilters(p.OrderBy, values)
+		if q.Where != nil {
+			keyset = &interfaces.Filters{AND: []*interfaces.Filters{q.Where, keyset}}
+		}
+		p.Where = keyset
+		p.earlier = true
+	}
+
+	if len(q.Select) > 0 {
+		present := make(map[string]bool, len(q.Select))
+		for _, field := range q.Select {
+			present[field] = true
+		}
+		p.Select = append([]string(nil), q.Select...)
+		for _, order := range p.order {
+			if !present[order.Field] {
+				present[order.Field] = true
+				p.Select = append(p.Select, order.Field)
+				p.added = append(p.added, order.Field)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Page trims the records read for p to the requested page, restores the
+// requested order and returns the cursors of the neighbouring pages, empty
+// where there is none
+func (p *Pagination) Page(records []map[string]interface{}) (page []map[string]interface{}, next, prev string) {
+	if !p.cursors || (p.limit == nil && !p.earlier) {
+		return records, "", ""
+	}
+
+	more := p.limit != nil && len(records) > *p.limit
+	if more {
+		records = records[:*p.limit]
+	}
+	if p.before {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+
+	if len(records) > 0 {
+		// Reading backwards, the extra row lies before the page and the
+		// cursor's row after it
+		hasNext, hasPrev := more, p.earlier
+		if p.before {
+			hasNext, hasPrev = true, more
+		}
+		if hasNext {
+			next = p.encode(records[len(records)-1])
+		}
+		if hasPrev {
+			prev = p.encode(records[0])
+		}
+	}
+
+	StripFields(records, p.added)
+	return records, next, prev
+}
+
+// encode returns the cursor pointing at record
+func (p *Pagination) encode(record map[string]interface{}) string {
+	token := cursorToken{Keys: cursorKeys(p.order), Values: make([]interface{}, len(p.order))}
+	for i, order := range p.order {
+		token.Values[i] = record[order.Field]
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// cursorOrder returns orderBy with normalized directions and the primary
+// key appended as a tiebreaker, and whether every sort field is a
+// non-nullable, non-JSON field of schema, as cursors need
+func cursorOrder(schema *interfaces.Schema, orderBy []interfaces.OrderBy) ([]interfaces.OrderBy, bool) {
+	pk := primaryKey(schema)
+	order := make([]interfaces.OrderBy, 0, len(orderBy)+1)
+	hasKey := false
+	for _, o := range orderBy {
+		field, exists := schema.Fields[o.Field]
+		if !exists || field.Nullable || field.Type == "json" {
+			return nil, false
+		}
+		direction := "asc"
+		if strings.EqualFold(o.Direction, "desc") {
+			direction = "desc"
+		}
+		order = append(order, interfaces.OrderBy{Field: o.Field, Direction: direction})
+		hasKey = hasKey || o.Field == pk
+	}
+	if !hasKey {
+		order = append(order, interfaces.OrderBy{Field: pk, Direction: "asc"})
+	}
+	return order, true
+}
+
+func reverseOrder(order []interfaces.OrderBy) []interfaces.OrderBy {
+	reversed := make([]interfaces.OrderBy, len(order))
+	for i, o := range order {
+		o.Direction = map[string]string{"asc": "desc", "desc": "asc"}[o.Direction]
+		reversed[i] = o
+	}
+	return reversed
+}
+
+func cursorKeys(order []interfaces.OrderBy) []string {
+	keys := make([]string, len(order))
+	for i, o := range order {
+		keys[i] = o.Field + ":" + o.Direction
+	}
+	return keys
+}
+
+// decodeCursor reads the values of a cursor made for order, converted to
+// the field types of schema
+func decodeCursor(schema *interfaces.Schema, order []interfaces.OrderBy, cursor string) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", interfaces.ErrInvalidQuery)
+	}
+
+	var token cursorToken
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&token); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", interfaces.ErrInvalidQuery)
+	}
+	if !reflect.DeepEqual(token.Keys, cursorKeys(order)) || len(token.Values) != len(order) {
+		return nil, fmt.Errorf("%w: cursor does not match the query order", interfaces.ErrInvalidQuery)
+	}
+
+	values := make([]interface{}, len(order))
+	for i, o := range order {
+		if values[i], err = decodeValue(schema.Fields[o.Field].Type, token.Values[i]); err != nil {
+			return nil, fmt.Errorf("%w: malformed cursor", interfaces.ErrInvalidQuery)
+		}
+	}
+	return values, nil
+}
+
+// keysetFilters matches the rows that follow values in order: those
+// greater in the first field, or equal in it and greater in the next, and
+// so on, with "greater" reversed for descending fields
+func keysetFilters(order []interfaces.OrderBy, values []interface{}) *interfaces.Filters {
+	keyset := &interfaces.Filters{}
+	for i, o := range order {
+		alternative := &interfaces.Filters{}
+		for j := 0; j < i; j++ {
+			alternative.Conditions = append(alternative.Conditions, interfaces.Filter{Field: order[j].Field, Value: values[j]})
+		}
+		op := &interfaces.FilterOperator{Gt: values[i]}
+		if o.Direction == "desc" {
+			op = &interfaces.FilterOperator{Lt: values[i]}
+		}
+		alternative.Conditions = append(alternative.Conditions, interfaces.Filter{Field: o.Field, Operator: op})
+		keyset.OR = append(keyset.OR, alternative)
+	}
+	return keyset
+}
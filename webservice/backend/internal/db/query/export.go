@@ -0,0 +1,211 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// keepTimestampsKey marks contexts whose creates keep given timestamps
+type keepTimestampsKey struct{}
+
+// KeepTimestamps returns a context in which creates keep the created_at and
+// updated_at values given in their data rather than setting them to now,
+// so that imported records are restored as they were
+func KeepTimestamps(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keepTimestampsKey{}, true)
+}
+
+// KeepsTimestamps reports whether creates made with ctx keep given
+// timestamps
+func KeepsTimestamps(ctx context.Context) bool {
+	keep, _ := ctx.Value(keepTimestampsKey{}).(bool)
+	return keep
+}
+
+// ReferentialOrder orders schemas so that tables referenced by foreign keys
+// come before the tables referencing them, otherwise keeping the given
+// order. References to tables outside schemas and to the table itself are
+// ignored; cycles are an error.
+func ReferentialOrder(schemas []*interfaces.Schema) ([]*interfaces.Schema, error) {
+	byTable := make(map[string]*interfaces.Schema, len(schemas))
+	for _, schema := range schemas {
+		if _, exists := byTable[schema.TableName]; exists {
+			return nil, fmt.Errorf("%w: table %s listed twice", interfaces.ErrInvalidQuery, schema.TableName)
+		}
+		byTable[schema.TableName] = schema
+	}
+
+	ordered := make([]*interfaces.Schema, 0, len(schemas))
+	state := make(map[string]int, len(schemas)) // 1 while visiting, 2 once ordered
+	var visit func(schema *interfaces.Schema) error
+	visit = func(schema *interfaces.Schema) error {
+		switch state[schema.TableName] {
+		case 1:
+			return fmt.Errorf("%w: foreign keys of %s form a cycle", interfaces.ErrInvalidQuery, schema.TableName)
+		case 2:
+			return nil
+		}
+		state[schema.TableName] = 1
+		names := make([]string, 0, len(schema.Fields))
+		for name := range schema.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fk := schema.Fields[name].ForeignKey
+			if fk == nil || fk.Table == schema.TableName {
+				continue
+			}
+			if referenced, exists := byTable[fk.Table]; exists {
+				if err := visit(referenced); err != nil {
+					return err
+				}
+			}
+		}
+		state[schema.TableName] = 2
+		ordered = append(ordered, schema)
+		return nil
+	}
+	for _, schema := range schemas {
+		if err := visit(schema); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Export implements Database.Export on top of db's repositories, reading
+// each table in primary key order with cursor pagination
+func Export(ctx context.Context, db interfaces.Database, w io.Writer, opts interfaces.ExportOptions) error {
+	schemas, err := ReferentialOrder(opts.Schemas)
+	if err != nil {
+		return err
+	}
+	limit := (&interfaces.BulkOptions{BatchSize: opts.BatchSize}).GetBatchSize()
+
+	encoder := json.NewEncoder(w)
+	for _, schema := range schemas {
+		repo := db.Repository(schema)
+		q := &interfaces.Query{
+			OrderBy:     []interfaces.OrderBy{{Field: primaryKey(schema), Direction: "asc"}},
+			Limit:       &limit,
+			WithDeleted: true,
+		}
+		for {
+			page, err := repo.FindMany(ctx, q)
+			if err != nil {
+				return fmt.Errorf("export %s: %w", schema.TableName, err)
+			}
+			for _, record := range page.Data {
+				if err := encoder.Encode(interfaces.ExportRecord{Table: schema.TableName, Record: record}); err != nil {
+					return fmt.Errorf("export %s: %w", schema.TableName, err)
+				}
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.After = page.NextCursor
+		}
+	}
+	return nil
+}
+
+// Import implements Database.Import on top of db's repositories. The whole
+// stream is read before anything is created, so its records may come in
+// any order; records referencing others of the same table must follow
+// them.
+func Import(ctx context.Context, db interfaces.Database, r io.Reader, opts interfaces.ImportOptions) error {
+	schemas, err := ReferentialOrder(opts.Schemas)
+	if err != nil {
+		return err
+	}
+	byTable := make(map[string]*interfaces.Schema, len(schemas))
+	for _, schema := range schemas {
+		byTable[schema.TableName] = schema
+	}
+
+	records := make(map[string][]map[string]interface{}, len(schemas))
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for n := 1; ; n++ {
+		var entry interfaces.ExportRecord
+		if err := decoder.Decode(&entry); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%w: import record %d: %v", interfaces.ErrInvalidQuery, n, err)
+		}
+		schema, exists := byTable[entry.Table]
+		if !exists {
+			return fmt.Errorf("%w: import record %d: table %q is not imported", interfaces.ErrInvalidQuery, n, entry.Table)
+		}
+		record, err := DecodeRecord(schema, entry.Record)
+		if err != nil {
+			return fmt.Errorf("%w: import record %d: %v", interfaces.ErrInvalidQuery, n, err)
+		}
+		records[schema.TableName] = append(records[schema.TableName], record)
+	}
+
+	bulk := &interfaces.BulkOptions{BatchSize: opts.BatchSize}
+	return db.Transaction(KeepTimestamps(ctx), func(ctx context.Context, tx interfaces.Transaction) error {
+		for _, schema := range schemas {
+			data := records[schema.TableName]
+			if len(data) == 0 {
+				continue
+			}
+			result, err := db.Repository(schema).CreateMany(ctx, data, bulk)
+			if err != nil {
+				return fmt.Errorf("import %s: %w", schema.TableName, err)
+			}
+			if err := result.Err(); err != nil {
+				return fmt.Errorf("import %s: %w", schema.TableName, err)
+			}
+		}
+		return nil
+	})
+}
+
+// DecodeRecord converts a record decoded from JSON with UseNumber to the Go
+// types of schema's fields. Unknown fields are left for validation to
+// reject.
+func DecodeRecord(schema *interfaces.Schema, record map[string]interface{}) (map[string]interface{}, error) {
+	decoded := make(map[string]interface{}, len(record))
+	for name, value := range record {
+		field, exists := schema.Fields[name]
+		if !exists {
+			decoded[name] = value
+			continue
+		}
+		v, err := decodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", name, err)
+		}
+		decoded[name] = v
+	}
+	return decoded, nil
+}
+
+// decodeValue converts a value decoded from JSON with UseNumber to the Go
+// type of fieldType
+func decodeValue(fieldType string, value interface{}) (interface{}, error) {
+	if fieldType == "json" {
+		return NormalizeJSON(value)
+	}
+	if n, ok := value.(json.Number); ok {
+		var err error
+		if fieldType == "float64" {
+			value, err = n.Float64()
+		} else {
+			value, err = n.Int64()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return coerceValue(fieldType, value), nil
+}
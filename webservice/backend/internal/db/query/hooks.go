@@ -0,0 +1,177 @@
+package query
+
+import (
+	"context"
+	"sync"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// HookRegistry holds the hooks registered per table. The zero value is
+// ready to use.
+type HookRegistry struct {
+	mu    sync.RWMutex
+	hooks map[string]*interfaces.Hooks
+}
+
+// Register appends hooks to those of table
+func (r *HookRegistry) Register(table string, hooks interfaces.Hooks) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hooks == nil {
+		r.hooks = make(map[string]*interfaces.Hooks)
+	}
+	registered := r.hooks[table]
+	if registered == nil {
+		registered = &interfaces.Hooks{}
+	}
+
+	// Replace rather than modify the registered hooks, which running
+	// mutations may be reading
+	r.hooks[table] = &interfaces.Hooks{
+		BeforeCreate: append(registered.BeforeCreate, hooks.BeforeCreate...),
+		AfterCreate:  append(registered.AfterCreate, hooks.AfterCreate...),
+		BeforeUpdate: append(registered.BeforeUpdate, hooks.BeforeUpdate...),
+		AfterUpdate:  append(registered.AfterUpdate, hooks.AfterUpdate...),
+		BeforeDelete: append(registered.BeforeDelete, hooks.BeforeDelete...),
+		AfterDelete:  append(registered.AfterDelete, hooks.AfterDelete...),
+	}
+}
+
+// Hooks returns the hooks of table, or nil if none were registered
+func (r *HookRegistry) Hooks(table string) *interfaces.Hooks {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.hooks[table]
+}
+
+// Hooked runs single-row mutations with their hooks. When hooks are
+// registered for an operation, the hooks and the mutation run in one
+// transaction; otherwise the mutation runs directly.
+type Hooked struct {
+	Hooks *interfaces.Hooks
+
+	// Transaction runs fn atomically, as a savepoint when ctx already
+	// carries a transaction
+	Transaction func(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// OnCreate reports whether creates have hooks
+func (h Hooked) OnCreate() bool {
+	return h.Hooks != nil && len(h.Hooks.BeforeCreate)+len(h.Hooks.AfterCreate) > 0
+}
+
+// OnUpdate reports whether updates have hooks
+func (h Hooked) OnUpdate() bool {
+	return h.Hooks != nil && len(h.Hooks.BeforeUpdate)+len(h.Hooks.AfterUpdate) > 0
+}
+
+// OnDelete reports whether deletes have hooks
+func (h Hooked) OnDelete() bool {
+	return h.Hooks != nil && len(h.Hooks.BeforeDelete)+len(h.Hooks.AfterDelete) > 0
+}
+
+// Create runs create between the create hooks. Before hooks see a copy of
+// data, so the caller's map is left alone.
+func (h Hooked) Create(ctx context.Context, data map[string]interface{}, create func(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if !h.OnCreate() {
+		return create(ctx, data)
+	}
+
+	var record map[string]interface{}
+	err := h.Transaction(ctx, func(ctx context.Context) error {
+		data := copyData(data)
+		for _, hook := range h.Hooks.BeforeCreate {
+			if err := hook(ctx, data); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if record, err = create(ctx, data); err != nil {
+			return err
+		}
+
+		for _, hook := range h.Hooks.AfterCreate {
+			if err := hook(ctx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Update runs update between the update hooks. Before hooks see a copy of
+// data, so the caller's map is left alone.
+func (h Hooked) Update(ctx context.Context, id interfaces.ID, data map[string]interface{}, update func(ctx context.Context, id interfaces.ID, data map[string]interface{}) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if !h.OnUpdate() {
+		return update(ctx, id, data)
+	}
+
+	var record map[string]interface{}
+	err := h.Transaction(ctx, func(ctx context.Context) error {
+		data := copyData(data)
+		for _, hook := range h.Hooks.BeforeUpdate {
+			if err := hook(ctx, id, data); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if record, err = update(ctx, id, data); err != nil {
+			return err
+		}
+
+		for _, hook := range h.Hooks.AfterUpdate {
+			if err := hook(ctx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Delete runs remove between the delete hooks
+func (h Hooked) Delete(ctx context.Context, id interfaces.ID, remove func(ctx context.Context, id interfaces.ID) error) error {
+	if !h.OnDelete() {
+		return remove(ctx, id)
+	}
+
+	return h.Transaction(ctx, func(ctx context.Context) error {
+		for _, hook := range h.Hooks.BeforeDelete {
+			if err := hook(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		if err := remove(ctx, id); err != nil {
+			return err
+		}
+
+		for _, hook := range h.Hooks.AfterDelete {
+			if err := hook(ctx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// copyData returns a shallow copy of data
+func copyData(data map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		copied[k] = v
+	}
+	return copied
+}
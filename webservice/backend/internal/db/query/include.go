@@ -0,0 +1,189 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// includeBatchSize bounds the number of keys in one related-record query
+const includeBatchSize = 500
+
+// RepositoryResolver returns the repository for a related table
+type RepositoryResolver func(table string) (interfaces.Repository, error)
+
+// splitIncludes groups include paths by their first segment, keeping the
+// order in which relations were first named. "posts.author" yields
+// posts -> [author].
+func splitIncludes(include []string) ([]string, map[string][]string) {
+	var names []string
+	nested := make(map[string][]string)
+	for _, path := range include {
+		name, rest, _ := strings.Cut(path, ".")
+		if _, seen := nested[name]; !seen {
+			names = append(names, name)
+			nested[name] = nil
+		}
+		if rest != "" {
+			nested[name] = append(nested[name], rest)
+		}
+	}
+	return names, nested
+}
+
+// relation looks up a named relation and resolves its key columns: local is
+// the column read from schema's records, remote the column matched on the
+// related table
+func relation(schema *interfaces.Schema, name string) (rel interfaces.Relation, local, remote string, err error) {
+	rel, exists := schema.Relations[name]
+	if !exists {
+		return rel, "", "", fmt.Errorf("%w: unknown relation %q on %s", interfaces.ErrInvalidQuery, name, schema.TableName)
+	}
+
+	references := rel.References
+	if references == "" {
+		references = "id"
+	}
+
+	switch rel.Type {
+	case interfaces.RelationBelongsTo:
+		return rel, rel.ForeignKey, references, nil
+	case interfaces.RelationHasMany:
+		return rel, references, rel.ForeignKey, nil
+	default:
+		return rel, "", "", fmt.Errorf("%w: relation %q has unsupported type %q", interfaces.ErrInvalidQuery, name, rel.Type)
+	}
+}
+
+// IncludeSelect validates the relations named in include and extends a
+// non-empty selection with the local key columns they need. It returns the
+// selection to query with and the columns that were added, which the caller
+// removes again once includes are loaded.
+func IncludeSelect(schema *interfaces.Schema, selected, include []string) ([]string, []string, error) {
+	names, _ := splitIncludes(include)
+
+	var keys []string
+	for _, name := range names {
+		_, local, _, err := relation(schema, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, local)
+	}
+
+	if len(selected) == 0 {
+		return selected, nil, nil
+	}
+
+	present := make(map[string]bool, len(selected))
+	for _, field := range selected {
+		present[field] = true
+	}
+
+	extended := append([]string(nil), selected...)
+	var added []string
+	for _, key := range keys {
+		if !present[key] {
+			present[key] = true
+			extended = append(extended, key)
+			added = append(added, key)
+		}
+	}
+	return extended, added, nil
+}
+
+// LoadIncludes attaches the relations named in include to records. Each
+// relation is fetched with one query per batch of keys rather than one per
+// record; dotted paths are passed on so the related repository loads the
+// next level. belongsTo relations set a record or nil, hasMany relations
+// set a possibly empty slice.
+func LoadIncludes(ctx context.Context, schema *interfaces.Schema, records []map[string]interface{}, include []string, resolve RepositoryResolver) error {
+	if len(include) == 0 || len(records) == 0 {
+		return nil
+	}
+
+	names, nested := splitIncludes(include)
+	for _, name := range names {
+		rel, local, remote, err := relation(schema, name)
+		if err != nil {
+			return err
+		}
+		repo, err := resolve(rel.Table)
+		if err != nil {
+			return err
+		}
+
+		// Collect the distinct keys referenced by the records
+		var keys []interface{}
+		seen := make(map[string]bool)
+		for _, record := range records {
+			value := record[local]
+			if value == nil {
+				continue
+			}
+			key := fmt.Sprint(value)
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, value)
+			}
+		}
+
+		related := make(map[string][]map[string]interface{})
+		for start := 0; start < len(keys); start += includeBatchSize {
+			end := min(start+includeBatchSize, len(keys))
+			result, err := repo.FindMany(ctx, &interfaces.Query{
+				Where: &interfaces.Filters{
+					Conditions: []interfaces.Filter{
+						{Field: remote, Operator: &interfaces.FilterOperator{In: keys[start:end]}},
+					},
+				},
+				OrderBy: rel.OrderBy,
+				Include: nested[name],
+			})
+			if err != nil {
+				return fmt.Errorf("include %s: %w", name, err)
+			}
+			for _, record := range result.Data {
+				key := fmt.Sprint(record[remote])
+				related[key] = append(related[key], record)
+			}
+		}
+
+		for _, record := range records {
+			matches := related[fmt.Sprint(record[local])]
+			if record[local] == nil {
+				matches = nil
+			}
+
+			if rel.Type == interfaces.RelationBelongsTo {
+				if len(matches) > 0 {
+					// Copy so records sharing a parent do not alias it
+					parent := make(map[string]interface{}, len(matches[0]))
+					for k, v := range matches[0] {
+						parent[k] = v
+					}
+					record[name] = parent
+				} else {
+					record[name] = nil
+				}
+				continue
+			}
+			if matches == nil {
+				matches = []map[string]interface{}{}
+			}
+			record[name] = matches
+		}
+	}
+	return nil
+}
+
+// StripFields removes fields from every record
+func StripFields(records []map[string]interface{}, fields []string) {
+	for _, record := range records {
+		for _, field := range fields {
+			delete(record, field)
+		}
+	}
+}
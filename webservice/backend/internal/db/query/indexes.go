@@ -0,0 +1,72 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// ValidateIndexes checks that the indexes of schema are named uniquely and
+// cover one or more distinct fields of the schema
+func ValidateIndexes(schema *interfaces.Schema) error {
+	names := make(map[string]bool, len(schema.Indexes))
+	for _, index := range schema.Indexes {
+		if index.Name == "" {
+			return fmt.Errorf("%s: index without a name", schema.TableName)
+		}
+		if names[index.Name] {
+			return fmt.Errorf("%s: duplicate index %s", schema.TableName, index.Name)
+		}
+		names[index.Name] = true
+
+		if len(index.Columns) == 0 {
+			return fmt.Errorf("index %s has no columns", index.Name)
+		}
+		columns := make(map[string]bool, len(index.Columns))
+		for _, col := range index.Columns {
+			if _, exists := schema.Fields[col]; !exists {
+				return fmt.Errorf("index %s references unknown column %q", index.Name, col)
+			}
+			if columns[col] {
+				return fmt.Errorf("index %s lists column %q twice", index.Name, col)
+			}
+			columns[col] = true
+		}
+	}
+	return nil
+}
+
+// IndexKey returns the key record has in index, for backends enforcing
+// unique indexes themselves. As in SQL, a record with a null in any indexed
+// column has no key and never conflicts.
+func IndexKey(index interfaces.Index, record map[string]interface{}) (string, bool) {
+	parts := make([]string, len(index.Columns))
+	for i, col := range index.Columns {
+		value := record[col]
+		switch v := value.(type) {
+		case nil:
+			return "", false
+		case time.Time:
+			// Equal instants in different locations are the same key
+			value = v.UTC().Format(time.RFC3339Nano)
+		}
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// EqualIndexes reports whether two index definitions cover the same columns
+// in the same order with the same uniqueness, ignoring names
+func EqualIndexes(a, b interfaces.Index) bool {
+	if a.Unique != b.Unique || len(a.Columns) != len(b.Columns) {
+		return false
+	}
+	for i := range a.Columns {
+		if a.Columns[i] != b.Columns[i] {
+			return false
+		}
+	}
+	return true
+}
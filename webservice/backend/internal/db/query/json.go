@@ -0,0 +1,142 @@
+package query
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// NormalizeJSON returns value as encoding/json decodes it into an
+// interface{}: objects become map[string]interface{}, arrays
+// []interface{} and numbers float64. Values of json fields are normalized
+// so that records and filters compare the same on every backend.
+func NormalizeJSON(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// NormalizeJSONFields normalizes the values of the json fields of schema in
+// record. Values that cannot be encoded are left for validation to reject.
+func NormalizeJSONFields(schema *interfaces.Schema, record map[string]interface{}) {
+	for name, field := range schema.Fields {
+		value, exists := record[name]
+		if field.Type != "json" || !exists {
+			continue
+		}
+		if normalized, err := NormalizeJSON(value); err == nil {
+			record[name] = normalized
+		}
+	}
+}
+
+// JSONPath returns the value at path inside a normalized JSON value, and
+// whether it exists
+func JSONPath(value interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// JSONContains reports whether the normalized JSON value container contains
+// value, with the semantics of the PostgreSQL jsonb @> operator
+func JSONContains(container, value interface{}) bool {
+	if array, ok := container.([]interface{}); ok {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			// As in PostgreSQL, an array contains its scalar elements, but
+			// only at the top level
+			return jsonContains(array, []interface{}{value})
+		}
+	}
+	return jsonContains(container, value)
+}
+
+func jsonContains(container, value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object, ok := container.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, want := range v {
+			got, exists := object[key]
+			if !exists || !jsonContains(got, want) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		array, ok := container.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, want := range v {
+			found := false
+			for _, got := range array {
+				if jsonContains(got, want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(container, value)
+	}
+}
+
+// matchesJSON evaluates a filter on a json field against its value
+func (b *Builder) matchesJSON(fieldValue interface{}, condition interfaces.Filter) bool {
+	value, exists := JSONPath(fieldValue, condition.Path)
+	if !exists {
+		value = nil
+	}
+
+	normalized := func(v interface{}) interface{} {
+		n, err := NormalizeJSON(v)
+		if err != nil {
+			return v
+		}
+		return n
+	}
+
+	op := condition.Operator
+	switch {
+	case op == nil:
+		return reflect.DeepEqual(value, normalized(condition.Value))
+	case op.IsNull:
+		return value == nil
+	case op.IsNotNull:
+		return value != nil
+	case op.Eq != nil:
+		return reflect.DeepEqual(value, normalized(op.Eq))
+	case op.Ne != nil:
+		return !reflect.DeepEqual(value, normalized(op.Ne))
+	case op.Contains != nil:
+		return value != nil && JSONContains(value, normalized(op.Contains))
+	default:
+		return true
+	}
+}
@@ -0,0 +1,50 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// ValidateOperators rejects operators used on fields whose type does not
+// support them: JSON paths and containment outside json fields, ordering
+// and pattern operators on json fields, and Search outside string fields
+func ValidateOperators(schema *interfaces.Schema, filters *interfaces.Filters) error {
+	if filters == nil {
+		return nil
+	}
+	for _, condition := range filters.Conditions {
+		if err := ValidateOperator(schema, condition); err != nil {
+			return err
+		}
+	}
+	for _, nested := range append(append([]*interfaces.Filters{}, filters.AND...), filters.OR...) {
+		if err := ValidateOperators(schema, nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateOperator is ValidateOperators for a single condition
+func ValidateOperator(schema *interfaces.Schema, condition interfaces.Filter) error {
+	field, exists := schema.Fields[condition.Field]
+	if !exists {
+		return nil
+	}
+	op := condition.Operator
+	if op != nil && op.Search != "" && field.Type != "string" {
+		return fmt.Errorf("%w: search needs a string field, got '%s'", interfaces.ErrInvalidQuery, condition.Field)
+	}
+	if field.Type != "json" {
+		if len(condition.Path) > 0 || (op != nil && op.Contains != nil) {
+			return fmt.Errorf("%w: field '%s' is not a JSON field", interfaces.ErrInvalidQuery, condition.Field)
+		}
+		return nil
+	}
+	if op != nil && (op.Gt != nil || op.Gte != nil || op.Lt != nil || op.Lte != nil ||
+		len(op.In) > 0 || len(op.NotIn) > 0 || op.Like != "" || op.NotLike != "") {
+		return fmt.Errorf("%w: JSON field '%s' only supports equality, null checks and contains", interfaces.ErrInvalidQuery, condition.Field)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// RetryConflicts runs attempt, rerunning it while it fails with
+// interfaces.ErrTxConflict, up to opts.MaxRetries times. Retries wait the
+// backoff, doubled each time, with up to half of it taken off at random so
+// that conflicting callers spread out. If ctx is done while waiting, the
+// last conflict is returned.
+func RetryConflicts(ctx context.Context, opts *interfaces.TxOptions, attempt func() error) error {
+	err := attempt()
+	backoff := opts.GetRetryBackoff()
+	for retry := 0; retry < opts.GetMaxRetries() && errors.Is(err, interfaces.ErrTxConflict); retry++ {
+		wait := backoff << retry
+		wait -= time.Duration(rand.Int63n(int64(wait/2) + 1))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = attempt()
+	}
+	return err
+}
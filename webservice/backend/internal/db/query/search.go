@@ -0,0 +1,208 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// RankField is the key under which backends carry the search rank of a
+// record between reading and paging it. TakeRanks removes it.
+const RankField = "_rank"
+
+// Tokenize splits text into lower-case words of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchDoc is the tokenized text of one field of one record
+type searchDoc struct {
+	text   string
+	terms  map[string]int
+	length int
+}
+
+func newSearchDoc(text string) *searchDoc {
+	tokens := Tokenize(text)
+	doc := &searchDoc{text: text, terms: make(map[string]int, len(tokens)), length: len(tokens)}
+	for _, token := range tokens {
+		doc.terms[token]++
+	}
+	return doc
+}
+
+// rank returns the share of the document's words that are search terms, or
+// zero unless every term occurs
+func (d *searchDoc) rank(terms []string) float64 {
+	if len(terms) == 0 || d.length == 0 {
+		return 0
+	}
+	matched := 0
+	for _, term := range terms {
+		count := d.terms[term]
+		if count == 0 {
+			return 0
+		}
+		matched += count
+	}
+	return float64(matched) / float64(d.length)
+}
+
+// SearchRank returns how well text matches a search query: zero unless
+// text contains every word of the query, else the share of its words that
+// are query words
+func SearchRank(text, search string) float64 {
+	return newSearchDoc(text).rank(uniqueTerms(search))
+}
+
+func uniqueTerms(search string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(search) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// TextIndex caches the tokenized text of record fields for Search filters,
+// so each value is only tokenized again once it changes. It is safe for
+// concurrent use.
+type TextIndex struct {
+	mu   sync.Mutex
+	docs map[string]map[string]*searchDoc // Primary key -> field -> text
+}
+
+// NewTextIndex creates an empty index
+func NewTextIndex() *TextIndex {
+	return &TextIndex{docs: make(map[string]map[string]*searchDoc)}
+}
+
+// doc returns the tokenized text of field for the record with key id
+func (idx *TextIndex) doc(field, id, text string) *searchDoc {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	fields, exists := idx.docs[id]
+	if !exists {
+		fields = make(map[string]*searchDoc)
+		idx.docs[id] = fields
+	}
+	doc, exists := fields[field]
+	if !exists || doc.text != text {
+		doc = newSearchDoc(text)
+		fields[field] = doc
+	}
+	return doc
+}
+
+// Remove drops the entries of the record with key id
+func (idx *TextIndex) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.docs, id)
+}
+
+// UseTextIndex makes b tokenize searched fields through index
+func (b *Builder) UseTextIndex(index *TextIndex) {
+	b.index = index
+}
+
+// searchRank ranks the field value of a Search condition
+func (b *Builder) searchRank(record map[string]interface{}, field, search string) float64 {
+	text, ok := record[field].(string)
+	if !ok {
+		return 0
+	}
+	if b.index == nil {
+		return SearchRank(text, search)
+	}
+	return b.index.doc(field, fmt.Sprint(record[primaryKey(b.schema)]), text).rank(uniqueTerms(search))
+}
+
+// Rank sums the ranks of record for the Search conditions of filters
+func (b *Builder) Rank(record map[string]interface{}, filters *interfaces.Filters) float64 {
+	if filters == nil {
+		return 0
+	}
+	rank := 0.0
+	for _, condition := range filters.Conditions {
+		if condition.Operator != nil && condition.Operator.Search != "" {
+			rank += b.searchRank(record, condition.Field, condition.Operator.Search)
+		}
+	}
+	for _, nested := range append(append([]*interfaces.Filters{}, filters.AND...), filters.OR...) {
+		rank += b.Rank(record, nested)
+	}
+	return rank
+}
+
+// HasSearch reports whether filters contain a Search condition
+func HasSearch(filters *interfaces.Filters) bool {
+	if filters == nil {
+		return false
+	}
+	for _, condition := range filters.Conditions {
+		if condition.Operator != nil && condition.Operator.Search != "" {
+			return true
+		}
+	}
+	for _, nested := range append(append([]*interfaces.Filters{}, filters.AND...), filters.OR...) {
+		if HasSearch(nested) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortByRank orders records by RankField, highest first, then by primary
+// key, for searches without an explicit order
+func SortByRank(schema *interfaces.Schema, records []map[string]interface{}) {
+	pk := primaryKey(schema)
+	sort.SliceStable(records, func(i, j int) bool {
+		ri, _ := records[i][RankField].(float64)
+		rj, _ := records[j][RankField].(float64)
+		if ri != rj {
+			return ri > rj
+		}
+		return fmt.Sprint(records[i][pk]) < fmt.Sprint(records[j][pk])
+	})
+}
+
+// TakeRanks removes RankField from records and returns the ranks in
+// record order, or nil when records carry none
+func TakeRanks(records []map[string]interface{}) []float64 {
+	var ranks []float64
+	for i, record := range records {
+		value, exists := record[RankField]
+		if !exists {
+			continue
+		}
+		if ranks == nil {
+			ranks = make([]float64, len(records))
+		}
+		switch v := value.(type) {
+		case float64:
+			ranks[i] = v
+		case float32:
+			ranks[i] = float64(v)
+		}
+		delete(record, RankField)
+	}
+	return ranks
+}
+
+// RemoveIndexed drops the indexed text of the record with key id, if b
+// uses a text index
+func (b *Builder) RemoveIndexed(id string) {
+	if b.index != nil {
+		b.index.Remove(id)
+	}
+}
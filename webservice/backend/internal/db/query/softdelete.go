@@ -0,0 +1,41 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// LiveFilters returns filters restricted to records that are not soft
+// deleted. filters is returned unchanged when the schema does not soft
+// delete or withDeleted is set.
+func LiveFilters(schema *interfaces.Schema, filters *interfaces.Filters, withDeleted bool) *interfaces.Filters {
+	if !schema.SoftDelete || withDeleted {
+		return filters
+	}
+
+	live := &interfaces.Filters{
+		Conditions: []interfaces.Filter{{
+			Field:    interfaces.DeletedAtField,
+			Operator: &interfaces.FilterOperator{IsNull: true},
+		}},
+	}
+	if filters != nil {
+		live.AND = []*interfaces.Filters{filters}
+	}
+	return live
+}
+
+// IsDeleted reports whether a record of schema has been soft deleted
+func IsDeleted(schema *interfaces.Schema, record map[string]interface{}) bool {
+	return schema.SoftDelete && record[interfaces.DeletedAtField] != nil
+}
+
+// RequireSoftDelete fails with ErrInvalidQuery for schemas that do not soft
+// delete
+func RequireSoftDelete(schema *interfaces.Schema) error {
+	if !schema.SoftDelete {
+		return fmt.Errorf("%w: %s does not soft delete", interfaces.ErrInvalidQuery, schema.TableName)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package query
+
+import (
+	"context"
+	"sync"
+)
+
+// MaxStatements is the most statements Statements keeps for one call
+const MaxStatements = 8
+
+// statementsKey carries the Statements collecting for a call
+type statementsKey struct{}
+
+// Statements collects the SQL that SQL backends run for one Repository
+// call, so that slow calls can be logged with it. Argument values are not
+// kept. It is safe for concurrent use.
+type Statements struct {
+	mu      sync.Mutex
+	sql     []string
+	dropped int
+}
+
+// CaptureStatements returns a context whose statements are recorded in the
+// returned Statements
+func CaptureStatements(ctx context.Context) (context.Context, *Statements) {
+	statements := &Statements{}
+	return context.WithValue(ctx, statementsKey{}, statements), statements
+}
+
+// RecordStatement adds sql to the Statements of ctx, if any. Backends call
+// it for every statement they run.
+func RecordStatement(ctx context.Context, sql string) {
+	statements, ok := ctx.Value(statementsKey{}).(*Statements)
+	if !ok {
+		return
+	}
+	statements.mu.Lock()
+	defer statements.mu.Unlock()
+	if len(statements.sql) < MaxStatements {
+		statements.sql = append(statements.sql, sql)
+	} else {
+		statements.dropped++
+	}
+}
+
+// SQL returns the recorded statements in the order they ran, and how many
+// more were run than kept
+func (s *Statements) SQL() ([]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.sql...), s.dropped
+}
@@ -0,0 +1,74 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// InitialVersion is the version of newly created records
+const InitialVersion = 1
+
+// WithInitialVersion returns data with the version field set to
+// InitialVersion when the schema is versioned and data does not set it
+func WithInitialVersion(schema *interfaces.Schema, data map[string]interface{}) map[string]interface{} {
+	field := schema.VersionField
+	if field == "" {
+		return data
+	}
+	if _, exists := data[field]; exists {
+		return data
+	}
+
+	versioned := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		versioned[k] = v
+	}
+	versioned[field] = VersionValue(schema, InitialVersion)
+	return versioned
+}
+
+// ExpectedVersion separates the expected version from update data. check
+// is false when the schema is unversioned or data does not carry the
+// version, in which case the update applies unconditionally.
+func ExpectedVersion(schema *interfaces.Schema, data map[string]interface{}) (rest map[string]interface{}, expected int64, check bool, err error) {
+	field := schema.VersionField
+	value, exists := data[field]
+	if field == "" || !exists {
+		return data, 0, false, nil
+	}
+
+	expected, ok := VersionNumber(value)
+	if !ok {
+		return nil, 0, false, fmt.Errorf("%w: version must be an integer, got %v", interfaces.ErrInvalidQuery, value)
+	}
+
+	rest = make(map[string]interface{}, len(data)-1)
+	for k, v := range data {
+		if k != field {
+			rest[k] = v
+		}
+	}
+	return rest, expected, true, nil
+}
+
+// VersionNumber reads a stored or supplied version
+func VersionNumber(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// VersionValue converts a version to the Go type of the version field
+func VersionValue(schema *interfaces.Schema, version int64) interface{} {
+	if schema.Fields[schema.VersionField].Type == "int" {
+		return int(version)
+	}
+	return version
+}
@@ -0,0 +1,206 @@
+package retention
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// TableReport summarizes one policy run
+type TableReport struct {
+	Table    string    `json:"table"`
+	Cutoff   time.Time `json:"cutoff"`
+	DryRun   bool      `json:"dry_run"`
+	Eligible int64     `json:"eligible"`
+	Archived int64     `json:"archived"`
+	Deleted  int64     `json:"deleted"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Report summarizes a run across all policies
+type Report struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	DryRun    bool          `json:"dry_run"`
+	Tables    []TableReport `json:"tables"`
+}
+
+// Manager applies retention policies to a database: rows older than a
+// policy's hot retention are archived to the sink, then deleted.
+//
+// Rows are archived before they are deleted, so an interrupted run never
+// loses data; the next run may archive some rows a second time.
+type Manager struct {
+	db       interfaces.Database
+	sink     Sink
+	policies []Policy
+	metrics  *Metrics
+	now      func() time.Time
+}
+
+// NewManager validates policies and creates a manager. sink may be nil when
+// no policy archives; metrics may be nil to disable instrumentation.
+func NewManager(db interfaces.Database, sink Sink, metrics *Metrics, policies ...Policy) (*Manager, error) {
+	for _, policy := range policies {
+		if err := policy.validate(); err != nil {
+			return nil, err
+		}
+		if policy.Archive && sink == nil {
+			return nil, fmt.Errorf("retention policy for %s archives but no sink is configured", policy.Table())
+		}
+	}
+
+	return &Manager{
+		db:       db,
+		sink:     sink,
+		policies: policies,
+		metrics:  metrics,
+		now:      time.Now,
+	}, nil
+}
+
+// Run applies every policy once. In dry-run mode eligible rows are counted
+// but nothing is archived or deleted. A failing policy does not stop the
+// others; the first error is returned alongside the full report.
+func (m *Manager) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	report := &Report{
+		StartedAt: m.now(),
+		DryRun:    dryRun,
+		Tables:    make([]TableReport, 0, len(m.policies)),
+	}
+
+	var firstErr error
+	for _, policy := range m.policies {
+		tableReport, err := m.runPolicy(ctx, policy, report.StartedAt, dryRun)
+		if err != nil {
+			tableReport.Error = err.Error()
+			m.metrics.recordFailure(ctx, policy.Table())
+			if firstErr == nil {
+				firstErr = fmt.Errorf("retention for %s: %w", policy.Table(), err)
+			}
+		}
+		m.metrics.recordTable(ctx, tableReport)
+		report.Tables = append(report.Tables, tableReport)
+	}
+
+	report.Duration = m.now().Sub(report.StartedAt)
+	return report, firstErr
+}
+
+// Start runs all policies every interval until ctx is canceled
+func (m *Manager) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := m.Run(ctx, false)
+		if err != nil {
+			log.Printf("Retention run failed: %v", err)
+		}
+		for _, table := range report.Tables {
+			log.Printf("Retention for %s: archived %d, deleted %d rows older than %s",
+				table.Table, table.Archived, table.Deleted, table.Cutoff.Format(time.RFC3339))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) runPolicy(ctx context.Context, policy Policy, startedAt time.Time, dryRun bool) (TableReport, error) {
+	cutoff := startedAt.Add(-policy.HotRetention)
+	report := TableReport{
+		Table:  policy.Table(),
+		Cutoff: cutoff,
+		DryRun: dryRun,
+	}
+
+	repo := m.db.Repository(policy.Schema)
+	expired := &interfaces.Filters{
+		Conditions: []interfaces.Filter{{
+			Field:    policy.TimeField,
+			Operator: &interfaces.FilterOperator{Lt: cutoff},
+		}},
+	}
+
+	eligible, err := repo.Count(ctx, &interfaces.Query{Where: expired})
+	if err != nil {
+		return report, err
+	}
+	report.Eligible = eligible
+	if dryRun || eligible == 0 {
+		return report, nil
+	}
+
+	limit := policy.batchSize()
+	for batch := 0; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		// Deleted rows drop out of the filter, so every batch starts at offset 0
+		page, err := repo.FindMany(ctx, &interfaces.Query{
+			Where:   expired,
+			OrderBy: []interfaces.OrderBy{{Field: policy.TimeField, Direction: "asc"}},
+			Limit:   &limit,
+		})
+		if err != nil {
+			return report, err
+		}
+		if len(page.Data) == 0 {
+			return report, nil
+		}
+
+		if policy.Archive {
+			key := archiveKey(policy.Table(), startedAt, batch)
+			if err := m.archive(ctx, key, page.Data); err != nil {
+				return report, fmt.Errorf("archive %s: %w", key, err)
+			}
+			report.Archived += int64(len(page.Data))
+		}
+
+		deleted := int64(0)
+		for _, row := range page.Data {
+			id, ok := row["id"]
+			if !ok {
+				return report, fmt.Errorf("row without id in %s", policy.Table())
+			}
+			err := repo.Delete(ctx, interfaces.StringID(fmt.Sprint(id)))
+			if err != nil && !errors.Is(err, interfaces.ErrNotFound) {
+				return report, err
+			}
+			deleted++
+		}
+		report.Deleted += deleted
+
+		if len(page.Data) < limit {
+			return report, nil
+		}
+	}
+}
+
+// archive writes rows to the sink as JSON lines
+func (m *Manager) archive(ctx context.Context, key string, rows []map[string]interface{}) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return m.sink.Put(ctx, key, buf.Bytes())
+}
+
+// archiveKey names an archive object as table/run-timestamp/batch.jsonl
+func archiveKey(table string, startedAt time.Time, batch int) string {
+	return fmt.Sprintf("%s/%s/%06d.jsonl", table, startedAt.UTC().Format("20060102T150405Z"), batch)
+}
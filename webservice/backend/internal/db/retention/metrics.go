@@ -0,0 +1,76 @@
+package retention
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Metrics records retention activity per table
+type Metrics struct {
+	ArchivedRows metric.Int64Counter
+	DeletedRows  metric.Int64Counter
+	EligibleRows metric.Int64Counter
+	Failures     metric.Int64Counter
+}
+
+// NewMetrics registers retention instruments on meter
+func NewMetrics(meter metric.Meter) (*Metrics, error) {
+	m := &Metrics{}
+	var err error
+
+	m.ArchivedRows, err = meter.Int64Counter(
+		"fx_retention_archived_rows_total",
+		metric.WithDescription("Total number of rows written to the archive sink"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.DeletedRows, err = meter.Int64Counter(
+		"fx_retention_deleted_rows_total",
+		metric.WithDescription("Total number of rows deleted by retention policies"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.EligibleRows, err = meter.Int64Counter(
+		"fx_retention_eligible_rows_total",
+		metric.WithDescription("Total number of rows found past retention, including dry runs"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Failures, err = meter.Int64Counter(
+		"fx_retention_failures_total",
+		metric.WithDescription("Total number of failed retention policy runs"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) recordTable(ctx context.Context, report TableReport) {
+	if m == nil {
+		return
+	}
+	labels := metric.WithAttributes(
+		attribute.String("table", report.Table),
+		attribute.Bool("dry_run", report.DryRun),
+	)
+	m.EligibleRows.Add(ctx, report.Eligible, labels)
+	m.ArchivedRows.Add(ctx, report.Archived, labels)
+	m.DeletedRows.Add(ctx, report.Deleted, labels)
+}
+
+func (m *Metrics) recordFailure(ctx context.Context, table string) {
+	if m == nil {
+		return
+	}
+	m.Failures.Add(ctx, 1, metric.WithAttributes(attribute.String("table", table)))
+}
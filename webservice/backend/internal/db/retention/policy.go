@@ -0,0 +1,66 @@
+package retention
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// DefaultBatchSize is the number of rows archived and deleted per batch
+const DefaultBatchSize = 500
+
+// Policy describes how long rows of a table stay hot and what happens to
+// them afterwards
+type Policy struct {
+	// Schema is the table the policy applies to
+	Schema *interfaces.Schema
+
+	// TimeField is the time column used to age rows, e.g. "ts" or "created_at"
+	TimeField string
+
+	// HotRetention is how long rows are kept in the database
+	HotRetention time.Duration
+
+	// Archive writes expired rows to the sink before they are deleted.
+	// When false, expired rows are deleted without a copy.
+	Archive bool
+
+	// BatchSize limits rows handled per batch. Default: DefaultBatchSize
+	BatchSize int
+}
+
+// Table returns the name of the table the policy applies to
+func (p Policy) Table() string {
+	if p.Schema == nil {
+		return ""
+	}
+	return p.Schema.TableName
+}
+
+func (p Policy) validate() error {
+	if p.Schema == nil {
+		return fmt.Errorf("retention policy has no schema")
+	}
+	if p.TimeField == "" {
+		return fmt.Errorf("retention policy for %s has no time field", p.Schema.TableName)
+	}
+	field, exists := p.Schema.Fields[p.TimeField]
+	if !exists {
+		return fmt.Errorf("retention policy for %s: unknown field %q", p.Schema.TableName, p.TimeField)
+	}
+	if field.Type != "time" {
+		return fmt.Errorf("retention policy for %s: field %q is %s, not time", p.Schema.TableName, p.TimeField, field.Type)
+	}
+	if p.HotRetention <= 0 {
+		return fmt.Errorf("retention policy for %s: hot retention must be positive", p.Schema.TableName)
+	}
+	return nil
+}
+
+func (p Policy) batchSize() int {
+	if p.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return p.BatchSize
+}
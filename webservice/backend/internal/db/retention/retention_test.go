@@ -0,0 +1,235 @@
+package retention
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/backends/memory"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+var eventSchema = &interfaces.Schema{
+	TableName: "events",
+	Fields: map[string]interfaces.FieldSchema{
+		"id":   {Type: "string", PrimaryKey: true},
+		"ts":   {Type: "time"},
+		"type": {Type: "string"},
+	},
+}
+
+type recordingSink struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *recordingSink) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.objects == nil {
+		s.objects = make(map[string][]byte)
+	}
+	s.objects[key] = data
+	return nil
+}
+
+func (s *recordingSink) lines() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, data := range s.objects {
+		total += bytes.Count(data, []byte("\n"))
+	}
+	return total
+}
+
+func seedEvents(t *testing.T, now time.Time, oldCount, freshCount int) *memory.Database {
+	t.Helper()
+	ctx := context.Background()
+
+	db := memory.NewDatabase()
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err := db.Migrate(ctx, []*interfaces.Schema{eventSchema}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	repo := db.Repository(eventSchema)
+	for i := 0; i < oldCount; i++ {
+		if _, err := repo.Create(ctx, map[string]interface{}{
+			"ts":   now.Add(-time.Duration(48+i) * time.Hour),
+			"type": "MINT",
+		}); err != nil {
+			t.Fatalf("Failed to create old event: %v", err)
+		}
+	}
+	for i := 0; i < freshCount; i++ {
+		if _, err := repo.Create(ctx, map[string]interface{}{
+			"ts":   now.Add(-time.Duration(i) * time.Minute),
+			"type": "REDEEM",
+		}); err != nil {
+			t.Fatalf("Failed to create fresh event: %v", err)
+		}
+	}
+	return db
+}
+
+func newTestManager(t *testing.T, db interfaces.Database, sink Sink, now time.Time, policy Policy) *Manager {
+	t.Helper()
+	manager, err := NewManager(db, sink, nil, policy)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	manager.now = func() time.Time { return now }
+	return manager
+}
+
+func TestRunDryRunReportsWithoutChanges(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	db := seedEvents(t, now, 5, 3)
+	sink := &recordingSink{}
+
+	manager := newTestManager(t, db, sink, now, Policy{
+		Schema:       eventSchema,
+		TimeField:    "ts",
+		HotRetention: 24 * time.Hour,
+		Archive:      true,
+	})
+
+	report, err := manager.Run(ctx, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(report.Tables) != 1 {
+		t.Fatalf("Expected one table report, got %d", len(report.Tables))
+	}
+	table := report.Tables[0]
+	if table.Eligible != 5 || table.Archived != 0 || table.Deleted != 0 || !table.DryRun {
+		t.Fatalf("Unexpected dry run report: %+v", table)
+	}
+	if len(db.GetTableData("events")) != 8 {
+		t.Fatal("Dry run must not delete rows")
+	}
+	if sink.lines() != 0 {
+		t.Fatal("Dry run must not archive rows")
+	}
+}
+
+func TestRunArchivesThenDeletes(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	db := seedEvents(t, now, 7, 3)
+	sink := &recordingSink{}
+
+	manager := newTestManager(t, db, sink, now, Policy{
+		Schema:       eventSchema,
+		TimeField:    "ts",
+		HotRetention: 24 * time.Hour,
+		Archive:      true,
+		BatchSize:    3,
+	})
+
+	report, err := manager.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	table := report.Tables[0]
+	if table.Eligible != 7 || table.Archived != 7 || table.Deleted != 7 {
+		t.Fatalf("Unexpected report: %+v", table)
+	}
+
+	if len(sink.objects) != 3 {
+		t.Fatalf("Expected 3 archive batches, got %d", len(sink.objects))
+	}
+	if sink.lines() != 7 {
+		t.Fatalf("Expected 7 archived rows, got %d", sink.lines())
+	}
+
+	remaining := db.GetTableData("events")
+	if len(remaining) != 3 {
+		t.Fatalf("Expected 3 fresh rows to remain, got %d", len(remaining))
+	}
+	for _, row := range remaining {
+		if row["type"] != "REDEEM" {
+			t.Fatalf("Expected only fresh rows to remain, found %v", row)
+		}
+	}
+}
+
+func TestRunDeletesWithoutArchive(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	db := seedEvents(t, now, 4, 2)
+
+	manager := newTestManager(t, db, nil, now, Policy{
+		Schema:       eventSchema,
+		TimeField:    "ts",
+		HotRetention: 24 * time.Hour,
+	})
+
+	report, err := manager.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if table := report.Tables[0]; table.Archived != 0 || table.Deleted != 4 {
+		t.Fatalf("Unexpected report: %+v", table)
+	}
+	if len(db.GetTableData("events")) != 2 {
+		t.Fatal("Expected fresh rows to remain")
+	}
+}
+
+func TestNewManagerValidatesPolicies(t *testing.T) {
+	db := memory.NewDatabase()
+
+	tests := []struct {
+		name   string
+		sink   Sink
+		policy Policy
+	}{
+		{"missing schema", nil, Policy{TimeField: "ts", HotRetention: time.Hour}},
+		{"unknown field", nil, Policy{Schema: eventSchema, TimeField: "missing", HotRetention: time.Hour}},
+		{"non-time field", nil, Policy{Schema: eventSchema, TimeField: "type", HotRetention: time.Hour}},
+		{"zero retention", nil, Policy{Schema: eventSchema, TimeField: "ts"}},
+		{"archive without sink", nil, Policy{Schema: eventSchema, TimeField: "ts", HotRetention: time.Hour, Archive: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewManager(db, tt.sink, nil, tt.policy); err == nil {
+				t.Fatal("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewFileSink(dir)
+
+	key := archiveKey("events", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), 1)
+	if err := sink.Put(context.Background(), key, []byte("{\"id\":\"1\"}\n")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "events", "20240102T030405Z", "000001.jsonl"))
+	if err != nil {
+		t.Fatalf("Expected archive object: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || scanner.Text() != `{"id":"1"}` {
+		t.Fatalf("Unexpected archive contents: %q", scanner.Text())
+	}
+
+	if err := sink.Put(context.Background(), "../escape.jsonl", nil); err == nil {
+		t.Fatal("Expected keys escaping the sink directory to be rejected")
+	}
+}
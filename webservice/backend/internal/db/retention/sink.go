@@ -0,0 +1,49 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sink stores archived rows. Implementations wrap an object store such as
+// S3, GCS or Walrus; keys are slash-separated paths.
+type Sink interface {
+	// Put stores an object, replacing any existing object with the same key
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// FileSink is a Sink that writes objects below a local directory
+type FileSink struct {
+	dir string
+}
+
+// NewFileSink creates a sink rooted at dir
+func NewFileSink(dir string) *FileSink {
+	return &FileSink{dir: dir}
+}
+
+// Put writes the object to dir/key, creating parent directories as needed
+func (s *FileSink) Put(ctx context.Context, key string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return fmt.Errorf("archive key %q escapes sink directory", key)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial objects
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
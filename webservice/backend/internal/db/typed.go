@@ -0,0 +1,376 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// TypedRepository wraps a Repository with a struct type T whose fields are
+// mapped to the schema by `db` tags, as on the entities in this module.
+// Fields tagged with a relation name receive eagerly loaded records.
+type TypedRepository[T any] struct {
+	repo    interfaces.Repository
+	schema  *interfaces.Schema
+	columns []typedColumn // Struct fields mapped to schema fields
+	all     []typedColumn // Also includes fields mapped to relations
+}
+
+// TypedPage is a page of FindMany results
+type TypedPage[T any] struct {
+	Data     []T       `json:"data"`
+	Total    int64     `json:"total"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+	Ranks    []float64 `json:"ranks,omitempty"` // Search ranks, aligned with Data
+}
+
+// typedColumn maps one struct field to a schema field or relation
+type typedColumn struct {
+	name  string
+	index []int
+	field interfaces.FieldSchema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// NewTypedRepository returns a typed repository for schema on database. It
+// fails if a tagged field of T names neither a field nor a relation of
+// schema, or has a Go type that cannot hold the field's values.
+func NewTypedRepository[T any](database interfaces.Database, schema *interfaces.Schema) (*TypedRepository[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: typed repository needs a struct, got %s", interfaces.ErrInvalidQuery, t)
+	}
+
+	r := &TypedRepository[T]{repo: database.Repository(schema), schema: schema}
+	for _, sf := range reflect.VisibleFields(t) {
+		name := sf.Tag.Get("db")
+		if name == "" || name == "-" || !sf.IsExported() {
+			continue
+		}
+
+		column := typedColumn{name: name, index: sf.Index}
+		if field, exists := schema.Fields[name]; exists {
+			if !holdsFieldType(sf.Type, field.Type) {
+				return nil, fmt.Errorf("%w: %s.%s (%s) cannot hold %s field '%s'",
+					interfaces.ErrInvalidQuery, t.Name(), sf.Name, sf.Type, field.Type, name)
+			}
+			column.field = field
+			r.columns = append(r.columns, column)
+		} else if _, exists := schema.Relations[name]; !exists {
+			return nil, fmt.Errorf("%w: %s.%s maps to unknown field '%s' of %s",
+				interfaces.ErrInvalidQuery, t.Name(), sf.Name, name, schema.TableName)
+		}
+		r.all = append(r.all, column)
+	}
+	return r, nil
+}
+
+// MustNewTypedRepository is like NewTypedRepository but panics on error, for
+// package-level repositories of known entities
+func MustNewTypedRepository[T any](database interfaces.Database, schema *interfaces.Schema) *TypedRepository[T] {
+	r, err := NewTypedRepository[T](database, schema)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// holdsFieldType reports whether a Go type can hold values of a schema
+// field type, directly or through a pointer
+func holdsFieldType(t reflect.Type, fieldType string) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch fieldType {
+	case "string":
+		return t.Kind() == reflect.String
+	case "int", "int64":
+		switch t.Kind() {
+		case reflect.Int, reflect.Int64:
+			return true
+		}
+	case "float64":
+		return t.Kind() == reflect.Float64
+	case "bool":
+		return t.Kind() == reflect.Bool
+	case "time":
+		return t == timeType
+	case "json":
+		// Decoded documents: objects, arrays or any JSON value
+		switch t.Kind() {
+		case reflect.Map:
+			return t.Key().Kind() == reflect.String
+		case reflect.Slice, reflect.Interface:
+			return true
+		}
+	}
+	return false
+}
+
+// Untyped returns the underlying map-based repository
+func (r *TypedRepository[T]) Untyped() interfaces.Repository {
+	return r.repo
+}
+
+// Field returns the schema field name of the struct field selected by
+// selector, which must return a pointer to a field of its argument:
+//
+//	email := users.Field(func(u *entities.User) any { return &u.Email })
+//
+// Building queries with Field rather than string literals keeps them
+// checked by the compiler when struct fields are renamed. It panics if the
+// selected field is not mapped to a schema field.
+func (r *TypedRepository[T]) Field(selector func(*T) any) string {
+	var entity T
+	target := reflect.ValueOf(selector(&entity))
+	if target.Kind() == reflect.Pointer {
+		v := reflect.ValueOf(&entity).Elem()
+		for _, column := range r.columns {
+			if f := v.FieldByIndex(column.index); f.Addr().Pointer() == target.Pointer() && f.Addr().Type() == target.Type() {
+				return column.name
+			}
+		}
+	}
+	panic(fmt.Sprintf("db: selector does not return a mapped field of %s", r.schema.TableName))
+}
+
+// Create inserts entity and returns the stored record. Nil pointer fields,
+// and a zero primary key, version or created_at/updated_at, are omitted so
+// that defaults and generated values apply. Other fields are written as
+// they are, so schema defaults only apply to pointer fields.
+func (r *TypedRepository[T]) Create(ctx context.Context, entity *T) (*T, error) {
+	record, err := r.repo.Create(ctx, r.toMap(entity, true))
+	if err != nil {
+		return nil, err
+	}
+	return r.fromMap(record)
+}
+
+// GetByID retrieves the record with the given ID
+func (r *TypedRepository[T]) GetByID(ctx context.Context, id interfaces.ID) (*T, error) {
+	record, err := r.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.fromMap(record)
+}
+
+// FindOne returns the first record matching q
+func (r *TypedRepository[T]) FindOne(ctx context.Context, q *interfaces.Query) (*T, error) {
+	record, err := r.repo.FindOne(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return r.fromMap(record)
+}
+
+// FindMany returns the records matching q
+func (r *TypedRepository[T]) FindMany(ctx context.Context, q *interfaces.Query) (*TypedPage[T], error) {
+	result, err := r.repo.FindMany(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TypedPage[T]{
+		Data:     make([]T, len(result.Data)),
+		Total:    result.Total,
+		Page:     result.Page,
+		PageSize: result.PageSize,
+		Ranks:    result.Ranks,
+	}
+	for i, record := range result.Data {
+		if err := r.decode(record, &page.Data[i]); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// Update writes every mapped field of entity to the record with its
+// primary key, except the timestamps, and returns the stored record. On
+// versioned schemas the entity's version makes the update conditional, so
+// an entity read before a concurrent update fails with ErrConflict.
+func (r *TypedRepository[T]) Update(ctx context.Context, entity *T) (*T, error) {
+	data := r.toMap(entity, false)
+	pk := r.primaryKey()
+	id, exists := data[pk]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s has no field mapped to primary key '%s'", interfaces.ErrInvalidQuery, r.schema.TableName, pk)
+	}
+	delete(data, pk)
+	delete(data, "created_at")
+	delete(data, "updated_at")
+	if r.schema.SoftDelete {
+		// Deleting and restoring go through Delete and Restore
+		delete(data, interfaces.DeletedAtField)
+	}
+
+	record, err := r.repo.Update(ctx, interfaces.StringID(fmt.Sprint(id)), data)
+	if err != nil {
+		return nil, err
+	}
+	return r.fromMap(record)
+}
+
+// Delete deletes the record with the given ID
+func (r *TypedRepository[T]) Delete(ctx context.Context, id interfaces.ID) error {
+	return r.repo.Delete(ctx, id)
+}
+
+// Restore undeletes a soft-deleted record and returns it
+func (r *TypedRepository[T]) Restore(ctx context.Context, id interfaces.ID) (*T, error) {
+	record, err := r.repo.Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.fromMap(record)
+}
+
+// Count returns the number of records matching q
+func (r *TypedRepository[T]) Count(ctx context.Context, q *interfaces.Query) (int64, error) {
+	return r.repo.Count(ctx, q)
+}
+
+// primaryKey returns the schema's primary key field
+func (r *TypedRepository[T]) primaryKey() string {
+	for name, field := range r.schema.Fields {
+		if field.PrimaryKey {
+			return name
+		}
+	}
+	return "id"
+}
+
+// toMap converts entity to a record. For creates, values left for the
+// repository to fill in are omitted.
+func (r *TypedRepository[T]) toMap(entity *T, create bool) map[string]interface{} {
+	v := reflect.ValueOf(entity).Elem()
+	data := make(map[string]interface{}, len(r.columns))
+	for _, column := range r.columns {
+		f := v.FieldByIndex(column.index)
+		if f.Kind() == reflect.Pointer {
+			if f.IsNil() {
+				if !create {
+					data[column.name] = nil
+				}
+				continue
+			}
+			f = f.Elem()
+		}
+		if create && f.IsZero() && r.generated(column) {
+			continue
+		}
+		data[column.name] = f.Interface()
+	}
+	return data
+}
+
+// generated reports whether the repository fills in column on create
+func (r *TypedRepository[T]) generated(column typedColumn) bool {
+	switch column.name {
+	case "created_at", "updated_at", r.schema.VersionField:
+		return true
+	}
+	return column.field.PrimaryKey
+}
+
+// fromMap converts a record to a new T
+func (r *TypedRepository[T]) fromMap(record map[string]interface{}) (*T, error) {
+	entity := new(T)
+	if err := r.decode(record, entity); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// decode sets the mapped fields of entity from record
+func (r *TypedRepository[T]) decode(record map[string]interface{}, entity *T) error {
+	v := reflect.ValueOf(entity).Elem()
+	for _, column := range r.all {
+		value, exists := record[column.name]
+		if !exists {
+			continue
+		}
+		if err := assign(v.FieldByIndex(column.index), value); err != nil {
+			return fmt.Errorf("decode %s.%s: %w", r.schema.TableName, column.name, err)
+		}
+	}
+	return nil
+}
+
+// assign stores a record value in dst, converting between numeric types
+// and decoding included records into structs by their `db` tags. Nil
+// leaves dst at its zero value.
+func assign(dst reflect.Value, value interface{}) error {
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(dst.Type().Elem())
+		if err := assign(elem.Elem(), value); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case reflect.Struct:
+		if record, ok := value.(map[string]interface{}); ok {
+			return assignStruct(dst, record)
+		}
+	case reflect.Slice:
+		if records, ok := value.([]map[string]interface{}); ok {
+			slice := reflect.MakeSlice(dst.Type(), len(records), len(records))
+			for i, record := range records {
+				if err := assign(slice.Index(i), record); err != nil {
+					return err
+				}
+			}
+			dst.Set(slice)
+			return nil
+		}
+	}
+
+	src := reflect.ValueOf(value)
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+	case src.Type().ConvertibleTo(dst.Type()) && isNumeric(src.Kind()) && isNumeric(dst.Kind()):
+		dst.Set(src.Convert(dst.Type()))
+	default:
+		return fmt.Errorf("cannot assign %T to %s", value, dst.Type())
+	}
+	return nil
+}
+
+// assignStruct sets the `db` tagged fields of dst from record
+func assignStruct(dst reflect.Value, record map[string]interface{}) error {
+	for _, sf := range reflect.VisibleFields(dst.Type()) {
+		name := sf.Tag.Get("db")
+		if name == "" || name == "-" || !sf.IsExported() {
+			continue
+		}
+		if value, exists := record[name]; exists {
+			if err := assign(dst.FieldByIndex(sf.Index), value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func isNumeric(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
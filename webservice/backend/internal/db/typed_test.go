@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+)
+
+// authorWithPosts maps the posts relation as well as user fields
+type authorWithPosts struct {
+	ID    string          `db:"id"`
+	Name  string          `db:"name"`
+	Posts []entities.Post `db:"posts"`
+}
+
+func TestTypedRepository(t *testing.T) {
+	ctx := context.Background()
+	database := NewInMemoryDatabase()
+	if err := ConnectAndMigrate(ctx, database, AllSchemas()); err != nil {
+		t.Fatalf("Failed to connect and migrate: %v", err)
+	}
+	defer database.Disconnect(ctx)
+
+	users := MustNewTypedRepository[entities.User](database, entities.UserSchema)
+	posts := MustNewTypedRepository[entities.Post](database, entities.PostSchema)
+
+	age := 30
+	user, err := users.Create(ctx, &entities.User{Email: "typed@example.com", Name: "Typed", Age: &age, IsActive: true})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if user.ID == "" || user.CreatedAt.IsZero() || user.Age == nil || *user.Age != 30 || user.DeletedAt != nil {
+		t.Fatalf("Unexpected created user: %+v", user)
+	}
+
+	if _, err := posts.Create(ctx, &entities.Post{Title: "Typed post", Content: "body", AuthorID: user.ID}); err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+
+	email := users.Field(func(u *entities.User) any { return &u.Email })
+	if email != "email" {
+		t.Fatalf("Expected field email, got %q", email)
+	}
+	found, err := users.FindOne(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{{Field: email, Value: "typed@example.com"}}},
+	})
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("Expected to find user %s, got %+v (%v)", user.ID, found, err)
+	}
+
+	found.Name = "Renamed"
+	found.Age = nil
+	updated, err := users.Update(ctx, found)
+	if err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	if updated.Name != "Renamed" || updated.Age != nil || !updated.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("Unexpected updated user: %+v", updated)
+	}
+
+	authors, err := MustNewTypedRepository[authorWithPosts](database, entities.UserSchema).FindMany(ctx, &interfaces.Query{
+		Where:   &interfaces.Filters{Conditions: []interfaces.Filter{{Field: "id", Value: user.ID}}},
+		Include: []string{"posts"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to find authors: %v", err)
+	}
+	if authors.Total != 1 || len(authors.Data[0].Posts) != 1 || authors.Data[0].Posts[0].Title != "Typed post" {
+		t.Errorf("Unexpected authors: %+v", authors)
+	}
+
+	if err := users.Delete(ctx, interfaces.StringID(user.ID)); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if _, err := users.GetByID(ctx, interfaces.StringID(user.ID)); !errors.Is(err, interfaces.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	restored, err := users.Restore(ctx, interfaces.StringID(user.ID))
+	if err != nil || restored.DeletedAt != nil {
+		t.Errorf("Expected restored user, got %+v (%v)", restored, err)
+	}
+}
+
+func TestTypedRepositoryVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	database := NewInMemoryDatabase()
+	if err := ConnectAndMigrate(ctx, database, AllSchemas()); err != nil {
+		t.Fatalf("Failed to connect and migrate: %v", err)
+	}
+	defer database.Disconnect(ctx)
+
+	receipts := MustNewTypedRepository[entities.BridgeReceipt](database, entities.BridgeReceiptSchema)
+	receipt, err := receipts.Create(ctx, &entities.BridgeReceipt{TxHash: "0xtyped", SuiOwner: "0xowner", ChainID: "1", Asset: "ETH", Status: "pending"})
+	if err != nil {
+		t.Fatalf("Failed to create receipt: %v", err)
+	}
+	if receipt.Version != 1 {
+		t.Fatalf("Expected version 1, got %d", receipt.Version)
+	}
+
+	stale := *receipt
+	receipt.Status = "minted"
+	if receipt, err = receipts.Update(ctx, receipt); err != nil || receipt.Version != 2 {
+		t.Fatalf("Expected update to version 2, got %+v (%v)", receipt, err)
+	}
+	stale.Status = "failed"
+	if _, err := receipts.Update(ctx, &stale); !errors.Is(err, interfaces.ErrConflict) {
+		t.Errorf("Expected ErrConflict for stale receipt, got %v", err)
+	}
+}
+
+func TestNewTypedRepositoryRejectsMismatchedFields(t *testing.T) {
+	database := NewInMemoryDatabase()
+
+	type unknownField struct {
+		Nickname string `db:"nickname"`
+	}
+	if _, err := NewTypedRepository[unknownField](database, entities.UserSchema); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for unknown field, got %v", err)
+	}
+
+	type wrongType struct {
+		CreatedAt string `db:"created_at"`
+	}
+	if _, err := NewTypedRepository[wrongType](database, entities.UserSchema); !errors.Is(err, interfaces.ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for mismatched type, got %v", err)
+	}
+
+	type timestamps struct {
+		UpdatedAt *time.Time `db:"updated_at"`
+	}
+	if _, err := NewTypedRepository[timestamps](database, entities.UserSchema); err != nil {
+		t.Errorf("Expected pointer time field to be accepted, got %v", err)
+	}
+}
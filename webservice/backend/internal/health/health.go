@@ -0,0 +1,155 @@
+// Package health runs the dependency checks behind /readyz
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses of checks
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Statuses of reports
+const (
+	StatusReady       = "ready"
+	StatusDegraded    = "degraded"    // A non-critical check failed
+	StatusUnavailable = "unavailable" // A critical check failed
+)
+
+// DefaultTimeout bounds a check when the checker is given none
+const DefaultTimeout = 3 * time.Second
+
+// Probe reports whether a dependency is usable
+type Probe func(ctx context.Context) error
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the outcome of every check, in registration order
+type Report struct {
+	Status    string        `json:"status"`
+	CheckedAt time.Time     `json:"checkedAt"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+// Ready reports whether every critical check passed
+func (r *Report) Ready() bool {
+	return r.Status != StatusUnavailable
+}
+
+type check struct {
+	name  string
+	probe Probe
+}
+
+// Checker runs the registered checks concurrently, each bounded by the
+// timeout. Checks named critical fail readiness; the others only degrade it.
+type Checker struct {
+	timeout  time.Duration
+	critical map[string]bool
+
+	mu     sync.RWMutex
+	checks []check
+}
+
+func NewChecker(timeout time.Duration, critical []string) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	c := &Checker{timeout: timeout, critical: make(map[string]bool, len(critical))}
+	for _, name := range critical {
+		c.critical[name] = true
+	}
+	return c
+}
+
+// Register adds a check, replacing any registered under name
+func (c *Checker) Register(name string, probe Probe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.checks {
+		if c.checks[i].name == name {
+			c.checks[i].probe = probe
+			return
+		}
+	}
+	c.checks = append(c.checks, check{name: name, probe: probe})
+}
+
+// Check runs every check and reports their outcome. A critical check that
+// is not registered fails too, rather than passing unnoticed.
+func (c *Checker) Check(ctx context.Context) *Report {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	report := &Report{Status: StatusReady, CheckedAt: time.Now().UTC(), Checks: make([]CheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func(i int, ch check) {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, ch)
+		}(i, ch)
+	}
+	wg.Wait()
+
+	registered := make(map[string]bool, len(checks))
+	for _, ch := range checks {
+		registered[ch.name] = true
+	}
+	var missing []string
+	for name := range c.critical {
+		if !registered[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		report.Checks = append(report.Checks, CheckResult{Name: name, Status: StatusDown, Critical: true, Error: "not configured"})
+	}
+
+	for _, result := range report.Checks {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusUnavailable
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, ch check) (result CheckResult) {
+	result = CheckResult{Name: ch.name, Status: StatusUp, Critical: c.critical[ch.name]}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		result.LatencyMs = time.Since(start).Milliseconds()
+		if r := recover(); r != nil {
+			result.Status = StatusDown
+			result.Error = fmt.Sprintf("check panicked: %v", r)
+		}
+	}()
+	if err := ch.probe(ctx); err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	up := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	c := NewChecker(time.Second, []string{"database"})
+	c.Register("database", up)
+	c.Register("prices", up)
+	report := c.Check(ctx)
+	assert.Equal(t, StatusReady, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, CheckResult{Name: "database", Status: StatusUp, Critical: true}, withoutLatency(report.Checks[0]))
+
+	// Non-critical failures degrade readiness, critical ones fail it
+	c.Register("prices", down)
+	report = c.Check(ctx)
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.True(t, report.Ready())
+	assert.Equal(t, "connection refused", report.Checks[1].Error)
+
+	c.Register("database", down)
+	report = c.Check(ctx)
+	assert.Equal(t, StatusUnavailable, report.Status)
+	assert.False(t, report.Ready())
+	require.Len(t, report.Checks, 2)
+}
+
+func TestCheckerTimeoutAndMissingChecks(t *testing.T) {
+	c := NewChecker(20*time.Millisecond, []string{"sui-rpc", "cache"})
+	c.Register("cache", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	report := c.Check(context.Background())
+	assert.Equal(t, StatusUnavailable, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	assert.Equal(t, CheckResult{Name: "sui-rpc", Status: StatusDown, Critical: true, Error: "not configured"}, report.Checks[1])
+}
+
+func withoutLatency(result CheckResult) CheckResult {
+	result.LatencyMs = 0
+	return result
+}
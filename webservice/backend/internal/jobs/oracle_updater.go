@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/pattonkan/sui-go/suisigner"
+	"go.uber.org/zap"
+)
+
+// oracleUpdaterLockKey elects the one replica pushing oracle updates
+const oracleUpdaterLockKey = "fx:lock:oracle-updater"
+
+// OracleStateReader reads the oracle price the protocol holds, as
+// *onchain.Client does
+type OracleStateReader interface {
+	OracleState(ctx context.Context) (*onchain.OracleState, error)
+}
+
+// OracleUpdateBuilder builds and submits oracle updates, as
+// *onchain.TransactionBuilder does
+type OracleUpdateBuilder interface {
+	BuildUpdateOracleTransaction(ctx context.Context, req onchain.UpdateOracleTxRequest) (*onchain.UnsignedTransaction, error)
+	SubmitSignedTransaction(ctx context.Context, txBytes string, signatures ...string) (*onchain.TransactionResult, error)
+}
+
+// OraclePriceSource provides the off-chain reserve price, in 1e6 USD
+type OraclePriceSource interface {
+	OraclePrice(ctx context.Context) (uint64, error)
+}
+
+// TickPriceSource takes the price of symbol from the latest tick the price
+// publisher cached
+type TickPriceSource struct {
+	Cache  *store.Cache
+	Symbol string
+}
+
+func (s TickPriceSource) OraclePrice(ctx context.Context) (uint64, error) {
+	var tick prices.Tick
+	if err := s.Cache.GetOraclePrice(ctx, s.Symbol, &tick); err != nil {
+		return 0, fmt.Errorf("no recent %s price: %w", s.Symbol, err)
+	}
+	return uint64(math.Round(tick.Price * 1e6)), nil
+}
+
+// Age returns how long before now the latest tick was published
+func (s TickPriceSource) Age(ctx context.Context, now time.Time) (time.Duration, error) {
+	var tick prices.Tick
+	if err := s.Cache.GetOraclePrice(ctx, s.Symbol, &tick); err != nil {
+		return 0, fmt.Errorf("no recent %s price: %w", s.Symbol, err)
+	}
+	return now.Sub(time.UnixMilli(tick.TsMs)), nil
+}
+
+// AggregatePriceSource takes the TWAP of the aggregate of symbol the price
+// publisher cached, so that no single exchange print moves the oracle
+type AggregatePriceSource struct {
+	Cache  *store.Cache
+	Symbol string
+}
+
+func (s AggregatePriceSource) OraclePrice(ctx context.Context) (uint64, error) {
+	var aggregate prices.Aggregate
+	if err := s.Cache.GetOracleAggregate(ctx, s.Symbol, &aggregate); err != nil {
+		return 0, fmt.Errorf("no recent %s aggregate: %w", s.Symbol, err)
+	}
+	return uint64(math.Round(aggregate.TWAP * 1e6)), nil
+}
+
+// FeedPriceSource takes the price from an on-chain price feed
+type FeedPriceSource struct {
+	Feed onchain.PriceFeed
+}
+
+func (s FeedPriceSource) OraclePrice(ctx context.Context) (uint64, error) {
+	price, err := s.Feed.LatestPrice(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return price.PriceE6, nil
+}
+
+type OracleUpdaterConfig struct {
+	Interval     time.Duration // Between price checks
+	DeviationBps uint64        // Off-chain price move that triggers an update
+	Heartbeat    time.Duration // On-chain price age that triggers an update
+	FeedPriced   bool          // Updates are built without a price, which the builder's feed provides
+}
+
+// OracleUpdater pushes oracle updates when the off-chain price deviates
+// from the on-chain one or the on-chain one gets old. Replicas share a
+// lock so that one of them updates.
+type OracleUpdater struct {
+	chain   OracleStateReader
+	builder OracleUpdateBuilder
+	source  OraclePriceSource
+	signer  *suisigner.Signer // AdminCap owner
+	cache   *store.Cache
+	logger  *zap.SugaredLogger
+	config  OracleUpdaterConfig
+	id      string // Lock owner
+}
+
+func NewOracleUpdater(
+	chain OracleStateReader,
+	builder OracleUpdateBuilder,
+	source OraclePriceSource,
+	signer *suisigner.Signer,
+	cache *store.Cache,
+	logger *zap.SugaredLogger,
+	config OracleUpdaterConfig,
+) *OracleUpdater {
+	return &OracleUpdater{
+		chain:   chain,
+		builder: builder,
+		source:  source,
+		signer:  signer,
+		cache:   cache,
+		logger:  logger,
+		config:  config,
+		id:      uuid.New().String(),
+	}
+}
+
+// Start checks prices every interval in the background until ctx is done
+func (u *OracleUpdater) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(u.config.Interval)
+		defer ticker.Stop()
+		defer func() {
+			// Let another replica take over at once
+			if err := u.cache.ReleaseLock(context.Background(), oracleUpdaterLockKey, u.id); err != nil {
+				u.logger.Warnw("Failed to release oracle updater lock", "error", err)
+			}
+		}()
+		for {
+			if err := u.check(ctx); err != nil && ctx.Err() == nil {
+				u.logger.Errorw("Oracle update check failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// check pushes an update when this replica holds the lock and the price
+// deviates or is due for its heartbeat
+func (u *OracleUpdater) check(ctx context.Context) error {
+	// The lock outlives a few missed checks before another replica takes over
+	held, err := u.cache.AcquireLock(ctx, oracleUpdaterLockKey, u.id, 3*u.config.Interval)
+	if err != nil || !held {
+		return err
+	}
+
+	state, err := u.chain.OracleState(ctx)
+	if err != nil {
+		return err
+	}
+	price, err := u.source.OraclePrice(ctx)
+	if err != nil {
+		return err
+	}
+	reason := updateReason(state, price, time.Now(), u.config)
+	if reason == "" {
+		return nil
+	}
+
+	req := onchain.UpdateOracleTxRequest{
+		SignerAddress: u.signer.Address,
+		NewPrice:      price,
+		Mode:          onchain.TxBuildModeExecution,
+	}
+	if u.config.FeedPriced {
+		req.NewPrice = 0
+	}
+	tx, err := u.builder.BuildUpdateOracleTransaction(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to build oracle update: %w", err)
+	}
+	signature, err := u.signer.SignDigest(tx.TransactionBlockBytes, suisigner.IntentTransaction())
+	if err != nil {
+		return fmt.Errorf("failed to sign oracle update: %w", err)
+	}
+	result, err := u.builder.SubmitSignedTransaction(ctx,
+		base64.StdEncoding.EncodeToString(tx.TransactionBlockBytes),
+		base64.StdEncoding.EncodeToString(signature.Bytes()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to submit oracle update: %w", err)
+	}
+	if result.Error != "" {
+		return fmt.Errorf("oracle update %s failed: %s", result.TransactionDigest, result.Error)
+	}
+	u.logger.Infow("Pushed oracle update",
+		"reason", reason,
+		"digest", result.TransactionDigest,
+		"old_price", state.PriceE6,
+		"new_price", tx.Metadata["price"],
+	)
+	return nil
+}
+
+// updateReason returns why the on-chain state needs an update to price at
+// now, or "" when it does not
+func updateReason(state *onchain.OracleState, price uint64, now time.Time, config OracleUpdaterConfig) string {
+	switch {
+	case state.PriceE6 == 0 || state.UpdatedAt.IsZero():
+		return "unset"
+	case now.Sub(state.UpdatedAt) >= config.Heartbeat:
+		return "heartbeat"
+	}
+	diff := price - state.PriceE6
+	if price < state.PriceE6 {
+		diff = state.PriceE6 - price
+	}
+	if float64(diff)*10_000 >= float64(state.PriceE6)*float64(config.DeviationBps) {
+		return "deviation"
+	}
+	return ""
+}
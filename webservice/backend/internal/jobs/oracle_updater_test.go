@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateReason(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	config := OracleUpdaterConfig{DeviationBps: 50, Heartbeat: 30 * time.Minute}
+	fresh := &onchain.OracleState{PriceE6: 3_000_000, UpdatedAt: now.Add(-time.Minute)}
+
+	assert.Equal(t, "unset", updateReason(&onchain.OracleState{}, 3_000_000, now, config))
+	assert.Equal(t, "heartbeat", updateReason(&onchain.OracleState{PriceE6: 3_000_000, UpdatedAt: now.Add(-time.Hour)}, 3_000_000, now, config))
+	assert.Equal(t, "", updateReason(fresh, 3_014_999, now, config))
+	assert.Equal(t, "deviation", updateReason(fresh, 3_015_000, now, config))
+	assert.Equal(t, "deviation", updateReason(fresh, 2_985_000, now, config))
+	assert.Equal(t, "", updateReason(fresh, 2_985_001, now, config))
+}
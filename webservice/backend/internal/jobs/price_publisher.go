@@ -3,12 +3,14 @@ package jobs
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/leafsii/leafsii-backend/internal/prices"
 	"github.com/leafsii/leafsii-backend/internal/prices/binance"
 	"github.com/leafsii/leafsii-backend/internal/prices/mock"
+	"github.com/leafsii/leafsii-backend/internal/prices/providers"
 	"github.com/leafsii/leafsii-backend/internal/store"
 	"go.uber.org/zap"
 )
@@ -20,22 +22,57 @@ type PricePublisher struct {
 	cache        *store.Cache
 	logger       *zap.SugaredLogger
 	config       PricePublisherConfig
+	recorder     TickRecorder // Optional; receives every processed tick
+
+	aggregator        *prices.Aggregator // Optional; aggregates quotes of several providers
+	aggregateInterval time.Duration
+	ingestRecorder    PriceIngestRecorder // Optional; receives staleness and reconnections
+
+	restart chan struct{} // Signalled when the current provider changes
+	started time.Time
 
 	mu             sync.RWMutex
 	currentCandles map[string]*CandleAggregator // symbol -> aggregator
+	lastTicks      map[string]int64             // symbol -> time of the latest tick, ms
 	usingMock      bool
 	cancelCtx      context.CancelFunc
 }
 
 type PricePublisherConfig struct {
-	ProviderType   string        // "binance" or "mock"
-	RetryInterval  time.Duration // How long to wait before retrying failed provider
+	ProviderType   string        // "binance", "coinbase", "kraken", "okx", a comma-separated fallback list of them, or "mock"
+	IngestMode     string        // IngestStream or IngestPoll
+	PollInterval   time.Duration // How often IngestPoll quotes each symbol
+	RetryInterval  time.Duration // Most to wait before retrying failed provider
 	MaxTicksPerSym int           // Maximum ticks to keep per symbol in cache
 	TTL            time.Duration // Cache TTL for latest prices
 	MockVolatility float64       // Volatility for mock data
 	MockBasePrice  float64       // Base price for mock data
 }
 
+// TickRecorder receives the ticks the publisher processes, e.g. to keep
+// candles of them
+type TickRecorder interface {
+	Record(tick prices.Tick)
+}
+
+type PricePublisherOption func(*PricePublisher)
+
+// WithTickRecorder passes every processed tick to recorder
+func WithTickRecorder(recorder TickRecorder) PricePublisherOption {
+	return func(p *PricePublisher) {
+		p.recorder = recorder
+	}
+}
+
+// WithAggregator caches the aggregate of each symbol every interval, for
+// the oracle updater and clients to price with it
+func WithAggregator(aggregator *prices.Aggregator, interval time.Duration) PricePublisherOption {
+	return func(p *PricePublisher) {
+		p.aggregator = aggregator
+		p.aggregateInterval = interval
+	}
+}
+
 // CandleAggregator aggregates ticks into candles
 type CandleAggregator struct {
 	interval      time.Duration
@@ -43,36 +80,50 @@ type CandleAggregator struct {
 	lastUpdate    time.Time
 }
 
-func NewPricePublisher(cache *store.Cache, logger *zap.SugaredLogger, config PricePublisherConfig) *PricePublisher {
+func NewPricePublisher(cache *store.Cache, logger *zap.SugaredLogger, config PricePublisherConfig, opts ...PricePublisherOption) *PricePublisher {
+	if config.IngestMode == "" {
+		config.IngestMode = IngestStream
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPricePublisherConfig().PollInterval
+	}
+
 	// Create primary provider
 	var provider prices.Provider
-	switch config.ProviderType {
-	case "binance":
-		provider = binance.NewProvider(logger)
-	case "mock":
+	if config.ProviderType == "mock" {
 		provider = mock.NewGenerator(logger, config.MockBasePrice, config.MockVolatility)
-	default:
-		provider = binance.NewProvider(logger) // Default to Binance
+	} else if selected, err := providers.New(logger, strings.Split(config.ProviderType, ",")...); err == nil {
+		provider = selected
+	} else {
+		logger.Warnw("Invalid price provider, defaulting to Binance", "provider", config.ProviderType, "error", err)
+		provider = binance.NewProvider(logger)
 	}
 
 	// Always create mock provider as fallback
 	mockProvider := mock.NewGenerator(logger, config.MockBasePrice, config.MockVolatility)
 
-	return &PricePublisher{
+	p := &PricePublisher{
 		provider:       provider,
 		mockProvider:   mockProvider,
 		registry:       prices.NewRegistry(),
 		cache:          cache,
 		logger:         logger,
 		config:         config,
+		restart:        make(chan struct{}, 1),
 		currentCandles: make(map[string]*CandleAggregator),
+		lastTicks:      make(map[string]int64),
 		usingMock:      false,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (p *PricePublisher) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	p.cancelCtx = cancel
+	p.started = time.Now()
 
 	symbols := p.registry.GetProviderSymbols()
 	if len(symbols) == 0 {
@@ -81,12 +132,14 @@ func (p *PricePublisher) Start(ctx context.Context) error {
 
 	p.logger.Infow("Starting price publisher",
 		"provider", p.provider.Name(),
+		"mode", p.config.IngestMode,
 		"symbols", symbols,
 		"mappings", p.registry.GetAllMappings(),
 	)
 
-	for _, symbol := range symbols {
-		go p.subscribeLiveData(ctx, symbol)
+	go p.ingest(ctx, symbols)
+	if p.aggregator != nil {
+		go p.aggregatePrices(ctx, symbols)
 	}
 
 	// Health check and retry loop
@@ -100,6 +153,7 @@ func (p *PricePublisher) Start(ctx context.Context) error {
 			return ctx.Err()
 		case <-retryTicker.C:
 			p.checkProviderHealth(ctx, symbols)
+			p.recordStaleness(ctx, symbols)
 		}
 	}
 }
@@ -110,46 +164,43 @@ func (p *PricePublisher) Stop() {
 	}
 }
 
-// subscribeLiveData subscribes to live price data for a symbol
-func (p *PricePublisher) subscribeLiveData(ctx context.Context, symbol string) {
-	tickChan := make(chan prices.Tick, 100) // Buffer for ticks
-
-	p.logger.Infow("Starting live subscription", "symbol", symbol, "provider", p.getCurrentProvider().Name())
+// aggregatePrices caches the aggregate of each symbol every interval, for
+// twice the interval so that one failed round leaves the previous one
+func (p *PricePublisher) aggregatePrices(ctx context.Context, symbols []string) {
+	ticker := time.NewTicker(p.aggregateInterval)
+	defer ticker.Stop()
 
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		currentProvider := p.getCurrentProvider()
-
-		// Subscribe to live data
-		go func() {
-			if err := currentProvider.SubscribeLive(ctx, symbol, tickChan); err != nil {
-				p.logger.Warnw("Live subscription failed", "symbol", symbol, "provider", currentProvider.Name(), "error", err)
-
-				// Switch to mock provider if primary fails
-				if !p.usingMock && currentProvider.Name() != "mock" {
-					p.switchToMock(symbol, "live subscription failed")
+		for _, symbol := range symbols {
+			aggregate, err := p.aggregator.Aggregate(ctx, symbol)
+			if err != nil {
+				if ctx.Err() == nil {
+					p.logger.Warnw("Failed to aggregate prices", "symbol", symbol, "error", err)
 				}
+				continue
+			}
+			if rejected := aggregate.Rejected(); len(rejected) > 0 {
+				p.logger.Warnw("Price sources left out of aggregate", "symbol", symbol, "sources", rejected, "median", aggregate.Median)
+			}
+			if err := p.cache.SetOracleAggregate(ctx, symbol, aggregate, 2*p.aggregateInterval); err != nil {
+				p.logger.Warnw("Failed to cache aggregate", "symbol", symbol, "error", err)
 			}
-		}()
-
-		// Process ticks
-		for tick := range tickChan {
-			p.processTick(ctx, tick)
 		}
 
-		// If we get here, the channel was closed, retry after delay
-		p.logger.Warnw("Tick channel closed, retrying", "symbol", symbol)
-		time.Sleep(p.config.RetryInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
 // processTick handles incoming price ticks
 func (p *PricePublisher) processTick(ctx context.Context, tick prices.Tick) {
+	p.mu.Lock()
+	p.lastTicks[tick.Symbol] = max(p.lastTicks[tick.Symbol], tick.TsMs)
+	p.mu.Unlock()
+
 	// Cache latest price
 	cacheKey := fmt.Sprintf("fx:oracle:price:%s", tick.Symbol)
 	if err := p.cache.Set(ctx, cacheKey, tick, p.config.TTL); err != nil {
@@ -163,6 +214,9 @@ func (p *PricePublisher) processTick(ctx context.Context, tick prices.Tick) {
 
 	// Update candle aggregators
 	p.updateCandleAggregators(ctx, tick)
+	if p.recorder != nil {
+		p.recorder.Record(tick)
+	}
 
 	// Publish to pub/sub channel
 	channel := fmt.Sprintf("fx:oracle:price:%s", tick.Symbol)
@@ -263,6 +317,7 @@ func (p *PricePublisher) switchToMock(symbol, reason string) {
 
 	if !p.usingMock {
 		p.usingMock = true
+		p.signalRestart()
 		p.logger.Warnw("Switching to mock provider",
 			"symbol", symbol,
 			"reason", reason,
@@ -280,11 +335,34 @@ func (p *PricePublisher) switchToMock(symbol, reason string) {
 	}
 }
 
-// checkProviderHealth checks and potentially switches providers
+// signalRestart restarts the price feed on the current provider
+func (p *PricePublisher) signalRestart() {
+	select {
+	case p.restart <- struct{}{}:
+	default:
+		// A restart is already pending
+	}
+}
+
+// checkProviderHealth checks and potentially switches providers. While
+// mock prices are published nothing feeds from the primary provider, so
+// it is quoted to learn whether it recovered.
 func (p *PricePublisher) checkProviderHealth(ctx context.Context, symbols []string) {
+	p.mu.RLock()
+	usingMock := p.usingMock
+	p.mu.RUnlock()
+	if quoter, ok := p.provider.(prices.Quoter); ok && usingMock {
+		probeCtx, cancel := context.WithTimeout(ctx, p.config.RetryInterval)
+		_, err := quoter.FetchPrice(probeCtx, symbols[0])
+		cancel()
+		if err != nil {
+			p.logger.Debugw("Primary provider still failing", "provider", p.provider.Name(), "error", err)
+			return
+		}
+	}
 	providerHealth := p.provider.Health()
 
-	if !providerHealth.Healthy && !p.usingMock {
+	if !providerHealth.Healthy && !usingMock {
 		p.logger.Warnw("Primary provider unhealthy, switching to mock",
 			"provider", p.provider.Name(),
 			"lastError", providerHealth.LastError,
@@ -294,7 +372,7 @@ func (p *PricePublisher) checkProviderHealth(ctx context.Context, symbols []stri
 		for _, symbol := range symbols {
 			p.switchToMock(symbol, "provider health check failed")
 		}
-	} else if providerHealth.Healthy && p.usingMock {
+	} else if providerHealth.Healthy && usingMock {
 		p.logger.Infow("Primary provider recovered, switching back",
 			"provider", p.provider.Name(),
 		)
@@ -302,11 +380,7 @@ func (p *PricePublisher) checkProviderHealth(ctx context.Context, symbols []stri
 		p.mu.Lock()
 		p.usingMock = false
 		p.mu.Unlock()
-
-		// Restart live subscriptions for all symbols
-		for _, symbol := range symbols {
-			go p.subscribeLiveData(ctx, symbol)
-		}
+		p.signalRestart()
 	}
 }
 
@@ -341,6 +415,8 @@ func (p *PricePublisher) addToTickHistory(ctx context.Context, symbol string, ti
 func DefaultPricePublisherConfig() PricePublisherConfig {
 	return PricePublisherConfig{
 		ProviderType:   "binance",
+		IngestMode:     IngestStream,
+		PollInterval:   2 * time.Second,
 		RetryInterval:  5 * time.Second,
 		MaxTicksPerSym: 10000,           // Keep last 10k ticks per symbol
 		TTL:            5 * time.Second, // Cache TTL for latest price
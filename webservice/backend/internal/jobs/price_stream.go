@@ -0,0 +1,259 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/prices"
+)
+
+// Ingest modes of the price publisher
+const (
+	// IngestStream streams trades over the providers' WebSockets
+	IngestStream = "stream"
+	// IngestPoll quotes the providers' REST tickers every poll interval
+	IngestPoll = "poll"
+)
+
+const (
+	// maxFeedFailures is the number of consecutive feed failures without a
+	// tick after which the publisher falls back to mock prices
+	maxFeedFailures = 3
+	// maxGapFillCandles bounds the minute candles read to fill the gap of
+	// one reconnection
+	maxGapFillCandles = 300
+)
+
+// PriceIngestRecorder receives the staleness of each symbol's price and the
+// reconnections of the feed, as *metrics.Metrics does
+type PriceIngestRecorder interface {
+	RecordPriceStaleness(ctx context.Context, symbol string, age time.Duration)
+	RecordPriceReconnect(ctx context.Context, provider string)
+	RecordPriceGapFill(ctx context.Context, symbol string, candles int)
+}
+
+// WithIngestRecorder reports the staleness of prices and the reconnections
+// of the feed to recorder
+func WithIngestRecorder(recorder PriceIngestRecorder) PricePublisherOption {
+	return func(p *PricePublisher) {
+		p.ingestRecorder = recorder
+	}
+}
+
+// ingest feeds the ticks of symbols from the current provider until ctx is
+// done. A feed that fails is restarted after a backoff capped at the retry
+// interval, filling the candles it missed from the provider's history; one
+// that keeps failing without a tick falls back to mock prices. Switching
+// provider restarts the feed.
+func (p *PricePublisher) ingest(ctx context.Context, symbols []string) {
+	var failures, sessions int
+	for ctx.Err() == nil {
+		// The provider read below is the one any pending restart was for
+		select {
+		case <-p.restart:
+		default:
+		}
+		provider := p.getCurrentProvider()
+		if sessions > 0 {
+			p.fillGaps(ctx, provider, symbols)
+		}
+		sessions++
+
+		feedCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-p.restart:
+				cancel()
+			case <-feedCtx.Done():
+			}
+		}()
+		p.logger.Infow("Starting price feed", "provider", provider.Name(), "mode", p.ingestMode(provider), "symbols", symbols)
+		delivered, err := p.feed(ctx, feedCtx, provider, symbols)
+		restarted := feedCtx.Err() != nil
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if restarted {
+			p.logger.Infow("Restarting price feed on provider switch", "provider", provider.Name())
+			failures = 0
+			continue
+		}
+
+		if delivered {
+			failures = 0
+		}
+		failures++
+		p.logger.Warnw("Price feed failed", "provider", provider.Name(), "failures", failures, "error", err)
+		if p.ingestRecorder != nil {
+			p.ingestRecorder.RecordPriceReconnect(ctx, provider.Name())
+		}
+		if failures >= maxFeedFailures && provider != p.mockProvider {
+			for _, symbol := range symbols {
+				p.switchToMock(symbol, "price feed failed")
+			}
+		}
+
+		backoff := min(time.Second<<min(failures-1, 5), p.config.RetryInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// ingestMode returns the mode provider is fed in: providers that do not
+// quote on request are streamed whatever the configured mode
+func (p *PricePublisher) ingestMode(provider prices.Provider) string {
+	if _, ok := provider.(prices.Quoter); ok && p.config.IngestMode == IngestPoll {
+		return IngestPoll
+	}
+	return IngestStream
+}
+
+// feed processes the ticks of symbols from provider until feedCtx is done
+// or the feed fails, reporting whether any tick arrived. Ticks are
+// processed on ctx, so that those received before a restart are kept.
+func (p *PricePublisher) feed(ctx, feedCtx context.Context, provider prices.Provider, symbols []string) (bool, error) {
+	if p.ingestMode(provider) == IngestPoll {
+		return p.poll(ctx, feedCtx, provider.(prices.Quoter), symbols)
+	}
+
+	ticks := make(chan prices.Tick, 100) // Buffer for ticks
+	done := make(chan error, 1)
+	go func() {
+		done <- prices.StreamTicks(feedCtx, provider, symbols, ticks)
+	}()
+
+	var delivered bool
+	for {
+		select {
+		case tick := <-ticks:
+			delivered = true
+			p.processTick(ctx, tick)
+		case err := <-done:
+			// Ticks buffered before the stream ended
+			for len(ticks) > 0 {
+				delivered = true
+				p.processTick(ctx, <-ticks)
+			}
+			return delivered, err
+		}
+	}
+}
+
+// poll quotes symbols from quoter every poll interval until feedCtx is
+// done or a round fails for every symbol
+func (p *PricePublisher) poll(ctx, feedCtx context.Context, quoter prices.Quoter, symbols []string) (bool, error) {
+	ticker := time.NewTicker(p.config.PollInterval)
+	defer ticker.Stop()
+
+	var delivered bool
+	for {
+		var errs []error
+		for _, symbol := range symbols {
+			tick, err := quoter.FetchPrice(feedCtx, symbol)
+			if err != nil {
+				if feedCtx.Err() != nil {
+					return delivered, feedCtx.Err()
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", symbol, err))
+				continue
+			}
+			delivered = true
+			p.processTick(ctx, tick)
+		}
+		if len(errs) == len(symbols) {
+			return delivered, errors.Join(errs...)
+		}
+		for _, err := range errs {
+			p.logger.Warnw("Failed to poll price", "error", err)
+		}
+
+		select {
+		case <-feedCtx.Done():
+			return delivered, feedCtx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// fillGaps records the minute candles of each symbol since its last tick
+// from provider's history, so that the candles of a reconnection cover
+// the trades the feed missed. The candles go to the tick recorder only:
+// the cached price and its subscribers stay on live ticks.
+func (p *PricePublisher) fillGaps(ctx context.Context, provider prices.Provider, symbols []string) {
+	if p.recorder == nil || provider == p.mockProvider {
+		return
+	}
+	now := time.Now()
+	for _, symbol := range symbols {
+		last, ok := p.lastTickTime(symbol)
+		if !ok {
+			continue
+		}
+		from := prices.AlignTime(last, time.Minute)
+		limit := min(int(now.Sub(from)/time.Minute)+1, maxGapFillCandles)
+		history, err := provider.FetchHistory(ctx, symbol, time.Minute, limit)
+		if err != nil {
+			if ctx.Err() == nil {
+				p.logger.Warnw("Failed to fill price gap", "symbol", symbol, "provider", provider.Name(), "error", err)
+			}
+			continue
+		}
+
+		var filled int
+		for _, candle := range history {
+			if candle.Time < from.Unix() {
+				continue
+			}
+			for _, tick := range candleTicks(symbol, candle, provider.Name()) {
+				p.recorder.Record(tick)
+			}
+			filled++
+		}
+		p.logger.Infow("Filled price gap", "symbol", symbol, "provider", provider.Name(), "since", last, "candles", filled)
+		if p.ingestRecorder != nil {
+			p.ingestRecorder.RecordPriceGapFill(ctx, symbol, filled)
+		}
+	}
+}
+
+// candleTicks returns ticks spanning the minute candle of symbol: its open,
+// high and low at the start of the minute and its close at the end
+func candleTicks(symbol string, candle prices.Candle, source string) []prices.Tick {
+	start := candle.Time * 1000
+	return []prices.Tick{
+		{Symbol: symbol, Price: candle.Open, TsMs: start, Source: source},
+		{Symbol: symbol, Price: candle.High, TsMs: start, Source: source},
+		{Symbol: symbol, Price: candle.Low, TsMs: start, Source: source},
+		{Symbol: symbol, Price: candle.Close, TsMs: start + time.Minute.Milliseconds() - 1, Source: source},
+	}
+}
+
+// lastTickTime returns the time of the latest tick of symbol, if any
+func (p *PricePublisher) lastTickTime(symbol string) (time.Time, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ts, ok := p.lastTicks[symbol]
+	return time.UnixMilli(ts), ok
+}
+
+// recordStaleness reports the age of the latest tick of each symbol, or the
+// time since the publisher started for those without one
+func (p *PricePublisher) recordStaleness(ctx context.Context, symbols []string) {
+	if p.ingestRecorder == nil {
+		return
+	}
+	now := time.Now()
+	for _, symbol := range symbols {
+		last, ok := p.lastTickTime(symbol)
+		if !ok {
+			last = p.started
+		}
+		p.ingestRecorder.RecordPriceStaleness(ctx, symbol, max(now.Sub(last), 0))
+	}
+}
@@ -0,0 +1,197 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// streamProvider streams one batch of ticks per connection, failing all but
+// the last one, and serves minute candles of history
+type streamProvider struct {
+	batches [][]prices.Tick
+	history []prices.Candle
+
+	mu      sync.Mutex
+	streams int
+	limits  []int
+}
+
+func (s *streamProvider) Name() string                  { return "stub" }
+func (s *streamProvider) Health() prices.ProviderHealth { return prices.ProviderHealth{Healthy: true} }
+
+func (s *streamProvider) FetchHistory(ctx context.Context, symbol string, interval time.Duration, limit int) ([]prices.Candle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = append(s.limits, limit)
+	return s.history, nil
+}
+
+func (s *streamProvider) SubscribeLive(ctx context.Context, symbol string, out chan<- prices.Tick) error {
+	return s.Stream(ctx, []string{symbol}, out)
+}
+
+func (s *streamProvider) Stream(ctx context.Context, symbols []string, out chan<- prices.Tick) error {
+	s.mu.Lock()
+	batch := s.batches[s.streams]
+	s.streams++
+	last := s.streams == len(s.batches)
+	s.mu.Unlock()
+	for _, tick := range batch {
+		out <- tick
+	}
+	if !last {
+		return errors.New("connection reset")
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type tickLog struct {
+	mu    sync.Mutex
+	ticks []prices.Tick
+}
+
+func (l *tickLog) Record(tick prices.Tick) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ticks = append(l.ticks, tick)
+}
+
+func (l *tickLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.ticks)
+}
+
+type ingestLog struct {
+	mu         sync.Mutex
+	staleness  map[string]time.Duration
+	reconnects []string
+	gapFills   map[string]int
+}
+
+func (l *ingestLog) RecordPriceStaleness(ctx context.Context, symbol string, age time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.staleness[symbol] = age
+}
+
+func (l *ingestLog) RecordPriceReconnect(ctx context.Context, provider string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reconnects = append(l.reconnects, provider)
+}
+
+func (l *ingestLog) RecordPriceGapFill(ctx context.Context, symbol string, candles int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gapFills[symbol] += candles
+}
+
+func TestPricePublisherStreamReconnects(t *testing.T) {
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	minute := prices.AlignTime(time.Now().Add(-3*time.Minute), time.Minute)
+	provider := &streamProvider{
+		batches: [][]prices.Tick{
+			{{Symbol: "SUIUSDT", Price: 1.00, TsMs: minute.UnixMilli() + 1000}},
+			{{Symbol: "SUIUSDT", Price: 1.10, TsMs: time.Now().UnixMilli()}},
+		},
+		history: []prices.Candle{
+			{Time: minute.Add(-time.Minute).Unix(), Open: 0.9, High: 0.9, Low: 0.9, Close: 0.9},
+			{Time: minute.Unix(), Open: 1.00, High: 1.05, Low: 0.95, Close: 1.02},
+			{Time: minute.Add(time.Minute).Unix(), Open: 1.02, High: 1.08, Low: 1.01, Close: 1.07},
+		},
+	}
+	recorder := &tickLog{}
+	ingest := &ingestLog{staleness: map[string]time.Duration{}, gapFills: map[string]int{}}
+	config := DefaultPricePublisherConfig()
+	config.RetryInterval = 10 * time.Millisecond
+	p := NewPricePublisher(cache, zap.NewNop().Sugar(), config, WithTickRecorder(recorder), WithIngestRecorder(ingest))
+	p.provider = provider
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Start(ctx)
+	// The first tick, four per gap-filled candle, then the live tick
+	require.Eventually(t, func() bool { return recorder.len() == 10 }, 2*time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		ingest.mu.Lock()
+		defer ingest.mu.Unlock()
+		// Measured from the live tick
+		return ingest.staleness["SUIUSDT"] > 0 && ingest.staleness["SUIUSDT"] < time.Minute
+	}, 2*time.Second, 5*time.Millisecond)
+	cancel()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	// The candle before the last tick is left out
+	assert.Equal(t, prices.Tick{Symbol: "SUIUSDT", Price: 1.00, TsMs: minute.UnixMilli(), Source: "stub"}, recorder.ticks[1])
+	assert.Equal(t, prices.Tick{Symbol: "SUIUSDT", Price: 1.07, TsMs: minute.Add(2*time.Minute).UnixMilli() - 1, Source: "stub"}, recorder.ticks[8])
+	assert.Equal(t, 1.10, recorder.ticks[9].Price)
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	assert.Equal(t, 2, provider.streams)
+	// The minutes since the last tick, this one included
+	require.Len(t, provider.limits, 1)
+	assert.GreaterOrEqual(t, provider.limits[0], 4)
+
+	ingest.mu.Lock()
+	defer ingest.mu.Unlock()
+	assert.Equal(t, []string{"stub"}, ingest.reconnects)
+	assert.Equal(t, 2, ingest.gapFills["SUIUSDT"])
+	assert.Same(t, provider, p.getCurrentProvider())
+}
+
+// quoteProvider quotes prices on request and cannot stream
+type quoteProvider struct {
+	streamProvider
+	quotes int
+}
+
+func (q *quoteProvider) Stream(ctx context.Context, symbols []string, out chan<- prices.Tick) error {
+	return errors.New("not streaming")
+}
+
+func (q *quoteProvider) FetchPrice(ctx context.Context, symbol string) (prices.Tick, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.quotes++
+	return prices.Tick{Symbol: symbol, Price: 1.25, TsMs: time.Now().UnixMilli(), Source: "stub"}, nil
+}
+
+func TestPricePublisherPolls(t *testing.T) {
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	recorder := &tickLog{}
+	config := DefaultPricePublisherConfig()
+	config.IngestMode = IngestPoll
+	config.PollInterval = 5 * time.Millisecond
+	p := NewPricePublisher(cache, zap.NewNop().Sugar(), config, WithTickRecorder(recorder))
+	provider := &quoteProvider{}
+	p.provider = provider
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Start(ctx)
+	require.Eventually(t, func() bool { return recorder.len() >= 3 }, 2*time.Second, 5*time.Millisecond)
+	cancel()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.Equal(t, 1.25, recorder.ticks[0].Price)
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	assert.Zero(t, provider.streams)
+	assert.GreaterOrEqual(t, provider.quotes, 3)
+}
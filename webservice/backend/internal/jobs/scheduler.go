@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"go.uber.org/zap"
+)
+
+// jobPausedKeyPrefix marks paused jobs in the cache, so that a pause
+// applies to every replica and outlives restarts
+const jobPausedKeyPrefix = "fx:jobs:paused:"
+
+// ErrJobNotFound is returned for names no job was scheduled under
+var ErrJobNotFound = errors.New("job not found")
+
+// JobStatus reports a scheduled job
+type JobStatus struct {
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Interval     string     `json:"interval"`
+	Paused       bool       `json:"paused"`
+	Running      bool       `json:"running"`
+	Runs         int64      `json:"runs"`
+	LastRunAt    *time.Time `json:"lastRunAt,omitempty"`
+	LastDuration string     `json:"lastDuration,omitempty"`
+	LastError    string     `json:"lastError,omitempty"`
+}
+
+// Scheduler runs periodic jobs that operators can pause, resume and
+// trigger without a restart. Paused jobs skip their ticks; triggering one
+// runs it anyway.
+type Scheduler struct {
+	cache  *store.Cache // Nil keeps pauses to this replica
+	logger *zap.SugaredLogger
+
+	mu     sync.Mutex
+	jobs   map[string]*scheduledJob
+	paused map[string]bool // Without a cache
+}
+
+type scheduledJob struct {
+	name        string
+	description string
+	interval    time.Duration
+	run         func(context.Context) error
+	trigger     chan struct{}
+
+	// Guarded by Scheduler.mu
+	running      bool
+	runs         int64
+	lastRunAt    time.Time
+	lastDuration time.Duration
+	lastError    string
+}
+
+func NewScheduler(cache *store.Cache, logger *zap.SugaredLogger) *Scheduler {
+	return &Scheduler{
+		cache:  cache,
+		logger: logger,
+		jobs:   make(map[string]*scheduledJob),
+		paused: make(map[string]bool),
+	}
+}
+
+// Schedule runs run at once and then every interval in the background,
+// until ctx is done
+func (s *Scheduler) Schedule(ctx context.Context, name, description string, interval time.Duration, run func(context.Context) error) {
+	job := &scheduledJob{
+		name:        name,
+		description: description,
+		interval:    interval,
+		run:         run,
+		trigger:     make(chan struct{}, 1),
+	}
+	s.mu.Lock()
+	s.jobs[name] = job
+	s.mu.Unlock()
+	s.logger.Infow("Job scheduled", "job", name, "interval", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		due := !s.isPaused(ctx, name)
+		for {
+			if due {
+				s.runJob(ctx, job)
+			}
+			select {
+			case <-ctx.Done():
+				s.logger.Infow("Job stopped", "job", name)
+				return
+			case <-ticker.C:
+				due = !s.isPaused(ctx, name)
+			case <-job.trigger:
+				due = true
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) runJob(ctx context.Context, job *scheduledJob) {
+	s.mu.Lock()
+	job.running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := job.run(ctx)
+	if err != nil && ctx.Err() == nil {
+		s.logger.Warnw("Job failed", "job", job.name, "error", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.running = false
+	job.runs++
+	job.lastRunAt = start
+	job.lastDuration = time.Since(start)
+	job.lastError = ""
+	if err != nil {
+		job.lastError = err.Error()
+	}
+}
+
+// Jobs returns the scheduled jobs by name
+func (s *Scheduler) Jobs(ctx context.Context) []JobStatus {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+
+	statuses := make([]JobStatus, 0, len(names))
+	for _, name := range names {
+		status, _ := s.Job(ctx, name)
+		statuses = append(statuses, *status)
+	}
+	return statuses
+}
+
+// Job returns the status of the job scheduled under name
+func (s *Scheduler) Job(ctx context.Context, name string) (*JobStatus, error) {
+	paused := s.isPaused(ctx, name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	status := &JobStatus{
+		Name:        job.name,
+		Description: job.description,
+		Interval:    job.interval.String(),
+		Paused:      paused,
+		Running:     job.running,
+		Runs:        job.runs,
+		LastError:   job.lastError,
+	}
+	if !job.lastRunAt.IsZero() {
+		lastRunAt := job.lastRunAt
+		status.LastRunAt = &lastRunAt
+		status.LastDuration = job.lastDuration.String()
+	}
+	return status, nil
+}
+
+// Pause makes a job skip its ticks until resumed
+func (s *Scheduler) Pause(ctx context.Context, name string) (*JobStatus, error) {
+	return s.setPaused(ctx, name, true)
+}
+
+// Resume lets a paused job run on its ticks again
+func (s *Scheduler) Resume(ctx context.Context, name string) (*JobStatus, error) {
+	return s.setPaused(ctx, name, false)
+}
+
+// Trigger runs a job now, paused or not, unless a triggered run is pending
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*JobStatus, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	select {
+	case job.trigger <- struct{}{}:
+	default:
+	}
+	return s.Job(ctx, name)
+}
+
+func (s *Scheduler) setPaused(ctx context.Context, name string, paused bool) (*JobStatus, error) {
+	s.mu.Lock()
+	_, ok := s.jobs[name]
+	if ok && s.cache == nil {
+		s.paused[name] = paused
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	if s.cache != nil {
+		var err error
+		if paused {
+			err = s.cache.Set(ctx, jobPausedKeyPrefix+name, time.Now().Unix(), 0)
+		} else {
+			err = s.cache.Delete(ctx, jobPausedKeyPrefix+name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to record pause of %s: %w", name, err)
+		}
+	}
+	return s.Job(ctx, name)
+}
+
+// isPaused reports whether the job under name is paused. Jobs run when the
+// cache cannot tell.
+func (s *Scheduler) isPaused(ctx context.Context, name string) bool {
+	if s.cache == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.paused[name]
+	}
+	paused, err := s.cache.Exists(ctx, jobPausedKeyPrefix+name)
+	if err != nil {
+		s.logger.Warnw("Failed to read job pause", "job", name, "error", err)
+		return false
+	}
+	return paused
+}
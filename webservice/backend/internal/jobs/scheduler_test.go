@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestScheduler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("invalid:6379", zap.NewNop().Sugar(), nil)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	s := NewScheduler(cache, zap.NewNop().Sugar())
+	var runs atomic.Int64
+	s.Schedule(ctx, "sampler", "Samples", time.Hour, func(context.Context) error {
+		runs.Add(1)
+		return errors.New("chain unreachable")
+	})
+	require.Eventually(t, func() bool {
+		jobs := s.Jobs(ctx)
+		return len(jobs) == 1 && jobs[0].Runs == 1
+	}, time.Second, 5*time.Millisecond)
+	job, err := s.Job(ctx, "sampler")
+	require.NoError(t, err)
+	assert.Equal(t, "1h0m0s", job.Interval)
+	assert.Equal(t, "chain unreachable", job.LastError)
+	assert.NotNil(t, job.LastRunAt)
+
+	// Pauses are kept in the cache for every replica; triggers run anyway
+	job, err = s.Pause(ctx, "sampler")
+	require.NoError(t, err)
+	assert.True(t, job.Paused)
+	assert.True(t, NewScheduler(cache, zap.NewNop().Sugar()).isPaused(ctx, "sampler"))
+	_, err = s.Trigger(ctx, "sampler")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return runs.Load() == 2 }, time.Second, 5*time.Millisecond)
+
+	job, err = s.Resume(ctx, "sampler")
+	require.NoError(t, err)
+	assert.False(t, job.Paused)
+
+	_, err = s.Trigger(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = s.Pause(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestSchedulerSkipsPausedTicks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := NewScheduler(nil, zap.NewNop().Sugar())
+	var runs atomic.Int64
+	s.Schedule(ctx, "monitor", "Monitors", 10*time.Millisecond, func(context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	require.Eventually(t, func() bool { return runs.Load() > 0 }, time.Second, time.Millisecond)
+
+	_, err := s.Pause(ctx, "monitor")
+	require.NoError(t, err)
+	time.Sleep(30 * time.Millisecond) // Let a run in flight finish
+	paused := runs.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, paused, runs.Load())
+
+	_, err = s.Resume(ctx, "monitor")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return runs.Load() > paused }, time.Second, time.Millisecond)
+}
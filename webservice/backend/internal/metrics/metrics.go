@@ -19,6 +19,30 @@ type Metrics struct {
 	CacheHits         metric.Int64Counter
 	CacheMisses       metric.Int64Counter
 	ActiveConnections metric.Int64UpDownCounter
+	DBQueryDuration   metric.Float64Histogram
+
+	CheckpointHeartbeats metric.Int64Counter
+	CheckpointStaleness  metric.Float64Histogram
+	ReconcileDrift       metric.Float64Histogram
+	ReconcileDivergences metric.Int64Counter
+	BridgeFees           metric.Float64Counter
+	WalrusPublishes      metric.Int64Counter
+	WalrusPublishLatency metric.Float64Histogram
+	SuiRPCRequests       metric.Int64Counter
+	SuiRPCLatency        metric.Float64Histogram
+	APIVersionRequests   metric.Int64Counter
+	WSFanoutPublishes    metric.Int64Counter
+	WSFanoutDeliveries   metric.Int64Counter
+	WSFanoutLag          metric.Float64Histogram
+	WSQueueDepth         metric.Int64Histogram
+	WSDroppedUpdates     metric.Int64Counter
+	WSSlowDisconnects    metric.Int64Counter
+	WSSubscriptions      metric.Int64UpDownCounter
+	WSMessagesSent       metric.Int64Counter
+	WSHandshakeFailures  metric.Int64Counter
+	PriceStaleness       metric.Float64Histogram
+	PriceReconnects      metric.Int64Counter
+	PriceGapFills        metric.Int64Counter
 }
 
 func Setup(serviceName string) (*Metrics, http.Handler, error) {
@@ -74,6 +98,190 @@ func Setup(serviceName string) (*Metrics, http.Handler, error) {
 		return nil, nil, err
 	}
 
+	m.DBQueryDuration, err = meter.Float64Histogram(
+		"fx_db_query_duration_seconds",
+		metric.WithDescription("Database repository call duration in seconds"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.CheckpointHeartbeats, err = meter.Int64Counter(
+		"fx_checkpoint_heartbeats_total",
+		metric.WithDescription("Scheduled checkpoint runs by chain, asset and status"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.CheckpointStaleness, err = meter.Float64Histogram(
+		"fx_checkpoint_staleness_seconds",
+		metric.WithDescription("Age of the latest checkpoint when the checkpointer visits it"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.ReconcileDrift, err = meter.Float64Histogram(
+		"fx_bridge_reconcile_drift_ratio",
+		metric.WithDescription("Relative drift of each bridge reconciliation check"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.ReconcileDivergences, err = meter.Int64Counter(
+		"fx_bridge_reconcile_divergences_total",
+		metric.WithDescription("Bridge reconciliation checks beyond their tolerance"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.BridgeFees, err = meter.Float64Counter(
+		"fx_bridge_fees_total",
+		metric.WithDescription("Bridge fees collected, in asset units"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WalrusPublishes, err = meter.Int64Counter(
+		"fx_walrus_publishes_total",
+		metric.WithDescription("Walrus publish attempts by endpoint and status"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WalrusPublishLatency, err = meter.Float64Histogram(
+		"fx_walrus_publish_duration_seconds",
+		metric.WithDescription("Walrus publish duration per endpoint in seconds, read-back included"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.SuiRPCRequests, err = meter.Int64Counter(
+		"fx_sui_rpc_requests_total",
+		metric.WithDescription("Sui RPC calls by endpoint, method and status"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.SuiRPCLatency, err = meter.Float64Histogram(
+		"fx_sui_rpc_duration_seconds",
+		metric.WithDescription("Sui RPC call duration per endpoint and method in seconds"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.APIVersionRequests, err = meter.Int64Counter(
+		"fx_api_version_requests_total",
+		metric.WithDescription("API requests by version, deprecation and status"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSFanoutPublishes, err = meter.Int64Counter(
+		"fx_ws_fanout_publishes_total",
+		metric.WithDescription("WebSocket updates fanned out to other replicas, by status"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSFanoutDeliveries, err = meter.Int64Counter(
+		"fx_ws_fanout_deliveries_total",
+		metric.WithDescription("WebSocket updates received from other replicas"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSFanoutLag, err = meter.Float64Histogram(
+		"fx_ws_fanout_lag_seconds",
+		metric.WithDescription("Delay between a replica pushing a WebSocket update and another receiving it"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSQueueDepth, err = meter.Int64Histogram(
+		"fx_ws_send_queue_depth",
+		metric.WithDescription("Updates queued for a WebSocket client, sampled as each is queued"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSDroppedUpdates, err = meter.Int64Counter(
+		"fx_ws_dropped_updates_total",
+		metric.WithDescription("WebSocket updates dropped from the full queue of a slow client"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSSlowDisconnects, err = meter.Int64Counter(
+		"fx_ws_slow_disconnects_total",
+		metric.WithDescription("WebSocket clients disconnected for falling behind, by reason"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSSubscriptions, err = meter.Int64UpDownCounter(
+		"fx_ws_subscriptions",
+		metric.WithDescription("WebSocket subscriptions, by topic; topics of single users, receipts and transactions by kind"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSMessagesSent, err = meter.Int64Counter(
+		"fx_ws_messages_sent_total",
+		metric.WithDescription("WebSocket frames written to clients"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.WSHandshakeFailures, err = meter.Int64Counter(
+		"fx_ws_handshake_failures_total",
+		metric.WithDescription("WebSocket handshakes refused or failed, by reason"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.PriceStaleness, err = meter.Float64Histogram(
+		"fx_price_tick_staleness_seconds",
+		metric.WithDescription("Age of the latest price tick of each symbol when the publisher checks it"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.PriceReconnects, err = meter.Int64Counter(
+		"fx_price_stream_reconnects_total",
+		metric.WithDescription("Price feed streams and polls that failed and were restarted, by provider"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.PriceGapFills, err = meter.Int64Counter(
+		"fx_price_gap_fill_candles_total",
+		metric.WithDescription("Minute candles read over REST to fill the gap of a price feed reconnection"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	handler := promhttp.Handler()
 	return m, handler, nil
 }
@@ -104,3 +312,137 @@ func (m *Metrics) IncrementConnections(ctx context.Context) {
 func (m *Metrics) DecrementConnections(ctx context.Context) {
 	m.ActiveConnections.Add(ctx, -1)
 }
+
+// RecordDBQuery records one repository call on table. status is "ok",
+// "not_found" or "error".
+func (m *Metrics) RecordDBQuery(ctx context.Context, table, operation, status string, duration time.Duration) {
+	m.DBQueryDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+		attribute.String("table", table),
+		attribute.String("operation", operation),
+		attribute.String("status", status),
+	))
+}
+
+// RecordCheckpointHeartbeat records one checkpointer visit of a chain and
+// asset. status is "published", "unpublished", "skipped" or "error".
+func (m *Metrics) RecordCheckpointHeartbeat(ctx context.Context, chainID, asset, status string, staleness time.Duration) {
+	chain := attribute.String("chain_id", chainID)
+	assetAttr := attribute.String("asset", asset)
+	m.CheckpointHeartbeats.Add(ctx, 1, metric.WithAttributes(chain, assetAttr, attribute.String("status", status)))
+	m.CheckpointStaleness.Record(ctx, staleness.Seconds(), metric.WithAttributes(chain, assetAttr))
+}
+
+// RecordReconcileDrift records the drift of one reconciliation check.
+// chainID and asset are empty for checks across every chain.
+func (m *Metrics) RecordReconcileDrift(ctx context.Context, check, chainID, asset string, drift float64, diverged bool) {
+	attrs := metric.WithAttributes(
+		attribute.String("check", check),
+		attribute.String("chain_id", chainID),
+		attribute.String("asset", asset),
+	)
+	m.ReconcileDrift.Record(ctx, drift, attrs)
+	if diverged {
+		m.ReconcileDivergences.Add(ctx, 1, attrs)
+	}
+}
+
+// RecordBridgeFee records a fee collected on a deposit or redemption.
+// operation is "deposit" or "redeem".
+func (m *Metrics) RecordBridgeFee(ctx context.Context, chainID, asset, operation string, fee float64) {
+	m.BridgeFees.Add(ctx, fee, metric.WithAttributes(
+		attribute.String("chain_id", chainID),
+		attribute.String("asset", asset),
+		attribute.String("operation", operation),
+	))
+}
+
+// RecordWalrusPublish records one publish attempt on a Walrus publisher.
+// status is "ok", "error" or "unverified".
+func (m *Metrics) RecordWalrusPublish(ctx context.Context, endpoint, status string, latency time.Duration) {
+	endpointAttr := attribute.String("endpoint", endpoint)
+	m.WalrusPublishes.Add(ctx, 1, metric.WithAttributes(endpointAttr, attribute.String("status", status)))
+	m.WalrusPublishLatency.Record(ctx, latency.Seconds(), metric.WithAttributes(endpointAttr))
+}
+
+// RecordSuiRPC records one call to a Sui RPC endpoint. method is "health"
+// for health checks; status is "ok" or "error".
+func (m *Metrics) RecordSuiRPC(ctx context.Context, endpoint, method, status string, latency time.Duration) {
+	attrs := []attribute.KeyValue{attribute.String("endpoint", endpoint), attribute.String("method", method)}
+	m.SuiRPCRequests.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("status", status))...))
+	m.SuiRPCLatency.Record(ctx, latency.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// RecordAPIVersionRequest records one request to an API version, so that
+// the migration off deprecated versions can be tracked
+func (m *Metrics) RecordAPIVersionRequest(ctx context.Context, version string, deprecated bool, status int) {
+	m.APIVersionRequests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("version", version),
+		attribute.Bool("deprecated", deprecated),
+		attribute.Int("status", status),
+	))
+}
+
+// RecordWSFanoutPublish records one WebSocket update fanned out to the
+// other replicas; status is "ok" or "error"
+func (m *Metrics) RecordWSFanoutPublish(ctx context.Context, status string) {
+	m.WSFanoutPublishes.Add(ctx, 1, metric.WithAttributes(attribute.String("status", status)))
+}
+
+// RecordWSFanoutDelivery records one WebSocket update received from another
+// replica lag after it was pushed
+func (m *Metrics) RecordWSFanoutDelivery(ctx context.Context, lag time.Duration) {
+	m.WSFanoutDeliveries.Add(ctx, 1)
+	m.WSFanoutLag.Record(ctx, lag.Seconds())
+}
+
+// RecordWSQueueDepth records the updates queued for a WebSocket client
+func (m *Metrics) RecordWSQueueDepth(ctx context.Context, depth int) {
+	m.WSQueueDepth.Record(ctx, int64(depth))
+}
+
+// RecordWSDroppedUpdate records one update dropped from the queue of a slow
+// WebSocket client
+func (m *Metrics) RecordWSDroppedUpdate(ctx context.Context) {
+	m.WSDroppedUpdates.Add(ctx, 1)
+}
+
+// RecordWSSlowDisconnect records one WebSocket client disconnected for
+// falling behind: "backpressure" when its queue was full, "write_timeout"
+// when a write missed its deadline
+func (m *Metrics) RecordWSSlowDisconnect(ctx context.Context, reason string) {
+	m.WSSlowDisconnects.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// RecordWSSubscription records delta subscriptions to the topic of label
+func (m *Metrics) RecordWSSubscription(ctx context.Context, label string, delta int64) {
+	m.WSSubscriptions.Add(ctx, delta, metric.WithAttributes(attribute.String("topic", label)))
+}
+
+// RecordWSMessagesSent records n frames written to a WebSocket client
+func (m *Metrics) RecordWSMessagesSent(ctx context.Context, n int) {
+	m.WSMessagesSent.Add(ctx, int64(n))
+}
+
+// RecordWSHandshakeFailure records one WebSocket handshake that failed:
+// "encoding", "upgrade", or "connection_limit" and "ip_limit" for those
+// over the connection caps
+func (m *Metrics) RecordWSHandshakeFailure(ctx context.Context, reason string) {
+	m.WSHandshakeFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// RecordPriceStaleness records the age of the latest tick of symbol
+func (m *Metrics) RecordPriceStaleness(ctx context.Context, symbol string, age time.Duration) {
+	m.PriceStaleness.Record(ctx, age.Seconds(), metric.WithAttributes(attribute.String("symbol", symbol)))
+}
+
+// RecordPriceReconnect records one restart of the price feed of provider
+// after it failed
+func (m *Metrics) RecordPriceReconnect(ctx context.Context, provider string) {
+	m.PriceReconnects.Add(ctx, 1, metric.WithAttributes(attribute.String("provider", provider)))
+}
+
+// RecordPriceGapFill records candles minute candles of symbol read to fill
+// the gap of a reconnection
+func (m *Metrics) RecordPriceGapFill(ctx context.Context, symbol string, candles int) {
+	m.PriceGapFills.Add(ctx, int64(candles), metric.WithAttributes(attribute.String("symbol", symbol)))
+}
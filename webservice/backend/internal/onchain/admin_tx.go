@@ -0,0 +1,133 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/sui/suiptb"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/shopspring/decimal"
+)
+
+// crScale is the scale of collateral ratios on chain
+var crScale = decimal.New(1, 9)
+
+// RebalanceTxRequest contains parameters for building protocol rebalances,
+// which burn fTokens of the stability pool until the collateral ratio
+// reaches TargetCR, as far as the pool allows
+type RebalanceTxRequest struct {
+	SignerAddress *sui.Address // AdminCap owner; the configured admin when nil
+	TargetCR      decimal.Decimal
+	Mode          TxBuildMode
+}
+
+// UserActionsTxRequest contains parameters for building transactions that
+// pause or resume user mints and redeems
+type UserActionsTxRequest struct {
+	SignerAddress *sui.Address // AdminCap owner; the configured admin when nil
+	Allowed       bool
+	Mode          TxBuildMode
+}
+
+// adminCap returns the sender of admin transactions, signer or else the
+// configured admin, and the AdminCap it owns
+func (tb *TransactionBuilder) adminCap(ctx context.Context, signer *sui.Address) (*sui.Address, *sui.ObjectRef, error) {
+	sender := signer
+	if sender == nil {
+		sender = tb.adminAddress
+	}
+	if sender == nil {
+		return nil, nil, fmt.Errorf("signer address is required when no admin address is configured")
+	}
+
+	res, err := tb.client.GetObject(ctx, &suiclient.GetObjectRequest{
+		ObjectId: tb.adminCapId,
+		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get admin cap object: %w", err)
+	}
+	if owner := res.Data.Owner; owner != nil && owner.ObjectOwnerInternal != nil &&
+		owner.AddressOwner != nil && *owner.AddressOwner != *sender {
+		return nil, nil, fmt.Errorf("admin cap is owned by %s, not %s", owner.AddressOwner, sender)
+	}
+	return sender, res.Data.Ref(), nil
+}
+
+// BuildRebalanceTransaction builds a protocol rebalance towards the target
+// collateral ratio, for the AdminCap owner to sign. The protocol leaves
+// the state as is when the ratio is at the target already.
+func (tb *TransactionBuilder) BuildRebalanceTransaction(ctx context.Context, req RebalanceTxRequest) (*UnsignedTransaction, error) {
+	if !req.TargetCR.GreaterThan(decimal.NewFromInt(1)) {
+		return nil, fmt.Errorf("target collateral ratio must be above 1")
+	}
+	sender, adminCapRef, err := tb.adminCap(ctx, req.SignerAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	ptb := suiptb.NewTransactionDataTransactionBuilder()
+	protocolArg, err := tb.sharedObjectArg(ctx, ptb, tb.protocolId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get protocol object: %w", err)
+	}
+	poolArg, err := tb.sharedObjectArg(ctx, ptb, tb.poolId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool object: %w", err)
+	}
+	ptb.Command(suiptb.Command{
+		MoveCall: &suiptb.ProgrammableMoveCall{
+			Package:       tb.packageId,
+			Module:        "leafsii",
+			Function:      "protocol_rebalance_l3_to_target",
+			TypeArguments: []sui.TypeTag{tb.ftokenTypeTag(), tb.xtokenTypeTag()},
+			Arguments: []suiptb.Argument{
+				protocolArg,
+				poolArg,
+				ptb.MustPure(req.TargetCR.Mul(crScale).BigInt().Uint64()),
+				ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: adminCapRef}),
+			},
+		},
+	})
+
+	return tb.finishUserTransaction(ctx, sender, ptb.Finish(), req.Mode, false, map[string]string{
+		"action":   "rebalance",
+		"sender":   sender.String(),
+		"targetCr": req.TargetCR.String(),
+	})
+}
+
+// BuildUserActionsTransaction builds a pause or resume of user mints and
+// redeems, for the AdminCap owner to sign
+func (tb *TransactionBuilder) BuildUserActionsTransaction(ctx context.Context, req UserActionsTxRequest) (*UnsignedTransaction, error) {
+	sender, adminCapRef, err := tb.adminCap(ctx, req.SignerAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	ptb := suiptb.NewTransactionDataTransactionBuilder()
+	protocolArg, err := tb.sharedObjectArg(ctx, ptb, tb.protocolId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get protocol object: %w", err)
+	}
+	ptb.Command(suiptb.Command{
+		MoveCall: &suiptb.ProgrammableMoveCall{
+			Package:       tb.packageId,
+			Module:        "leafsii",
+			Function:      "set_user_actions_allowed",
+			TypeArguments: []sui.TypeTag{tb.ftokenTypeTag(), tb.xtokenTypeTag()},
+			Arguments: []suiptb.Argument{
+				protocolArg,
+				ptb.MustPure(req.Allowed),
+				ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: adminCapRef}),
+			},
+		},
+	})
+
+	return tb.finishUserTransaction(ctx, sender, ptb.Finish(), req.Mode, false, map[string]string{
+		"action":  "set_user_actions",
+		"sender":  sender.String(),
+		"allowed": fmt.Sprintf("%t", req.Allowed),
+	})
+}
@@ -2,12 +2,14 @@ package onchain
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"strings"
 	"time"
 
 	"github.com/fardream/go-bcs/bcs"
+	"github.com/leafsii/leafsii-backend/internal/prices"
 	"github.com/leafsii/leafsii-backend/internal/prices/binance"
 	"github.com/pattonkan/sui-go/sui"
 	"github.com/pattonkan/sui-go/sui/suiptb"
@@ -29,7 +31,7 @@ type ChainReader interface {
 
 type Client struct {
 	rpcURL           string
-	client           *suiclient.ClientImpl
+	client           SuiRPC
 	wsURL            string
 	objectsCore      string
 	objectsSP        string
@@ -42,6 +44,7 @@ type Client struct {
 	ftokenCoinType   sui.ObjectType
 	xtokenCoinType   sui.ObjectType
 	provider         *binance.Provider
+	aggregator       *prices.Aggregator
 }
 
 type ClientOptions struct {
@@ -51,6 +54,8 @@ type ClientOptions struct {
 	XtokenPackageId  *sui.PackageId
 	LeafsiiPackageId *sui.PackageId
 	Provider         *binance.Provider
+	Aggregator       *prices.Aggregator // Prices SUI in place of Provider when set
+	RPC              SuiRPC             // Defaults to a client of rpcURL, set to an *RPCPool for failover
 }
 
 func NewClient(rpcURL, wsURL, objectsCore, objectsSP, network string) *Client {
@@ -58,7 +63,10 @@ func NewClient(rpcURL, wsURL, objectsCore, objectsSP, network string) *Client {
 }
 
 func NewClientWithOptions(rpcURL, wsURL, objectsCore, objectsSP, network string, opts ClientOptions) *Client {
-	client := suiclient.NewClient(rpcURL)
+	var client SuiRPC = suiclient.NewClient(rpcURL)
+	if opts.RPC != nil {
+		client = opts.RPC
+	}
 
 	var ftokenCoinType, xtokenCoinType sui.ObjectType
 	if opts.FtokenPackageId != nil {
@@ -83,6 +91,7 @@ func NewClientWithOptions(rpcURL, wsURL, objectsCore, objectsSP, network string,
 		ftokenCoinType:   ftokenCoinType,
 		xtokenCoinType:   xtokenCoinType,
 		provider:         opts.Provider,
+		aggregator:       opts.Aggregator,
 	}
 }
 
@@ -119,20 +128,62 @@ func (c *Client) ProtocolState(ctx context.Context) (*ProtocolState, error) {
 	reserveNetVal := float64(moveProtocol.ReserveTokenBalance.Value) * float64(moveProtocol.LastReservePrice)
 	ftokenNetVal := float64(ftokenSupply) * float64(moveProtocol.Pf)
 
+	cr := decimal.NewFromFloat(reserveNetVal / ftokenNetVal)
+	var fees *ProtocolFees
+	if fc := moveProtocol.FeeConfig; fc != nil {
+		fees = &ProtocolFees{
+			MintFBps:          fc.NormalMintFFeeBps,
+			MintXBps:          fc.NormalMintXFeeBps,
+			RedeemFBps:        fc.NormalRedeemFFeeBps,
+			RedeemXBps:        fc.NormalRedeemXFeeBps,
+			L1RedeemXBps:      fc.L1RedeemXFeeBps,
+			StabilityBonusBps: fc.StabilityBonusRateBps,
+		}
+		if fc.FeeRecipient != nil {
+			fees.FeeRecipient = fc.FeeRecipient.String()
+		}
+	}
 	return &ProtocolState{
-		CR:           decimal.NewFromFloat(reserveNetVal / ftokenNetVal),
+		CR:           cr,
 		ReservesR:    decimal.NewFromBigInt(new(big.Int).SetUint64(moveProtocol.ReserveTokenBalance.Value), 0),
 		SupplyF:      decimal.NewFromBigInt(new(big.Int).SetUint64(ftokenSupply), 0),
 		SupplyX:      decimal.NewFromBigInt(new(big.Int).SetUint64(xtokenSupply), 0),
 		Pf:           moveProtocol.Pf,
 		Px:           moveProtocol.Px,
 		P:            moveProtocol.LastReservePrice,
-		Mode:         "normal",
+		Mode:         ProtocolModeOf(cr),
 		OracleAgeSec: 30,
+		Fees:         fees,
 		AsOf:         time.Now(),
 	}, nil
 }
 
+// OracleState reads the last oracle price and update time of the protocol
+func (c *Client) OracleState(ctx context.Context) (*OracleState, error) {
+	res, err := c.client.GetObject(ctx, &suiclient.GetObjectRequest{
+		ObjectId: c.protocolId,
+		Options:  &suiclient.SuiObjectDataOptions{ShowContent: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get protocol object: %w", err)
+	}
+	if res.Data == nil || res.Data.Content == nil || res.Data.Content.Data.MoveObject == nil {
+		return nil, fmt.Errorf("protocol object %s has no content", c.protocolId)
+	}
+	var fields struct {
+		LastReservePrice sui.BigInt `json:"last_reserve_price"`
+		LastOracleTs     sui.BigInt `json:"last_oracle_ts"`
+	}
+	if err := json.Unmarshal(res.Data.Content.Data.MoveObject.Fields, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode protocol fields: %w", err)
+	}
+	state := &OracleState{PriceE6: bigIntUint64(&fields.LastReservePrice)}
+	if ts := bigIntUint64(&fields.LastOracleTs); ts != 0 {
+		state.UpdatedAt = time.UnixMilli(int64(ts)).UTC()
+	}
+	return state, nil
+}
+
 func (c *Client) getSupplyOnChain(ctx context.Context, tokenName string, protocolRef *sui.ObjectRef) (uint64, error) {
 	var funcName string
 	if strings.ToLower(tokenName) == "ftoken" {
@@ -279,7 +330,7 @@ func (c *Client) GetLatestCheckpoint(ctx context.Context) (uint64, error) {
 }
 
 func (c *Client) GetOraclePrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
-	if c.provider == nil {
+	if c.provider == nil && c.aggregator == nil {
 		return decimal.Zero, time.Time{}, fmt.Errorf("provider not configured")
 	}
 
@@ -287,23 +338,34 @@ func (c *Client) GetOraclePrice(ctx context.Context, symbol string) (decimal.Dec
 	case "FTOKEN":
 		return decimal.NewFromFloat(1 * binance.BinanceScale), time.Now().Add(-30 * time.Second), nil
 	case "SUIUSDT":
-		price, err := c.provider.GetLatestPrice(ctx, symbol)
-		if err != nil {
-			return decimal.Zero, time.Time{}, fmt.Errorf("get latest price: %w", err)
-		}
-		return price, time.Now().UTC(), nil
+		return c.latestPrice(ctx, symbol)
 	case "RTOKEN":
 		// RTOKEN represents the underlying SUI token, so get SUIUSDT price
-		price, err := c.provider.GetLatestPrice(ctx, "SUIUSDT")
-		if err != nil {
-			return decimal.Zero, time.Time{}, fmt.Errorf("get latest price: %w", err)
-		}
-		return price, time.Now().UTC(), nil
+		return c.latestPrice(ctx, "SUIUSDT")
 	default:
 		return decimal.Zero, time.Time{}, fmt.Errorf("unknown symbol: %s", symbol)
 	}
 }
 
+// latestPrice returns the price of symbol, scaled by binance.BinanceScale:
+// the median of the aggregator when there is one, the latest Binance price
+// otherwise
+func (c *Client) latestPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	if c.aggregator != nil {
+		aggregate, err := c.aggregator.Aggregate(ctx, symbol)
+		if err != nil {
+			return decimal.Zero, time.Time{}, fmt.Errorf("aggregate price: %w", err)
+		}
+		price := decimal.NewFromFloat(aggregate.Median).Mul(decimal.NewFromInt(binance.BinanceScale)).Round(0)
+		return price, time.UnixMilli(aggregate.TsMs).UTC(), nil
+	}
+	price, err := c.provider.GetLatestPrice(ctx, symbol)
+	if err != nil {
+		return decimal.Zero, time.Time{}, fmt.Errorf("get latest price: %w", err)
+	}
+	return price, time.Now().UTC(), nil
+}
+
 const SuiDecimal = 9
 
 func (c *Client) GetAllBalances(ctx context.Context, addr *sui.Address) (*Balances, error) {
@@ -0,0 +1,257 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/sui/suiptb"
+	"github.com/pattonkan/sui-go/suiclient"
+)
+
+var (
+	// ErrInsufficientBalance is returned when an owner's coins cannot cover
+	// an amount
+	ErrInsufficientBalance = errors.New("not enough balance")
+	// ErrCoinsFragmented is returned when covering an amount takes more
+	// coins than a transaction may use. Consolidating the coins first with
+	// BuildConsolidateCoinsTransaction fixes it.
+	ErrCoinsFragmented = errors.New("coins too fragmented")
+)
+
+const (
+	// DefaultMaxInputCoins caps the coins a transaction takes as inputs
+	DefaultMaxInputCoins = 200
+	// maxGasPaymentCoins is the most gas coins a Sui transaction may pay with
+	maxGasPaymentCoins = 256
+	// maxCoinPages caps the pages of coins read for an owner
+	maxCoinPages = 20
+)
+
+// WithMaxInputCoins caps the coins a transaction takes as inputs, and the
+// coins one consolidation merges. Values below 1 are ignored.
+func WithMaxInputCoins(max int) TransactionBuilderOption {
+	return func(tb *TransactionBuilder) {
+		if max > 0 {
+			tb.maxInputCoins = max
+		}
+	}
+}
+
+// ownerCoins returns the coins of coinType owned by owner, SUI when
+// coinType is nil, across pages
+func (tb *TransactionBuilder) ownerCoins(ctx context.Context, owner *sui.Address, coinType *string) (suiclient.Coins, error) {
+	var coins suiclient.Coins
+	var cursor *string
+	for page := 0; page < maxCoinPages; page++ {
+		res, err := tb.client.GetCoins(ctx, &suiclient.GetCoinsRequest{Owner: owner, CoinType: coinType, Cursor: cursor})
+		if err != nil {
+			return nil, err
+		}
+		coins = append(coins, res.Data...)
+		if !res.HasNextPage || res.NextCursor == nil {
+			break
+		}
+		cursor = res.NextCursor
+	}
+	return coins, nil
+}
+
+// selectCoins picks at most maxCoins of coins whose balances cover target.
+// A single coin covering target is preferred, the smallest such one;
+// otherwise coins are taken largest first and the last one is swapped for
+// the smallest coin still covering the rest, to keep the change small.
+func selectCoins(coins suiclient.Coins, target uint64, maxCoins int) (suiclient.Coins, error) {
+	sorted := make(suiclient.Coins, 0, len(coins))
+	for _, coin := range coins {
+		if coin.Balance != nil && coin.Balance.Int != nil && coin.Balance.Sign() > 0 {
+			sorted = append(sorted, coin)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Balance.Uint64() > sorted[j].Balance.Uint64()
+	})
+	if target == 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if total := sorted.TotalBalance(); total.Cmp(new(big.Int).SetUint64(target)) < 0 {
+		return nil, fmt.Errorf("%w: have %s, need %d", ErrInsufficientBalance, total, target)
+	}
+
+	var picked int
+	var sum uint64
+	for picked < len(sorted) && sum < target {
+		sum += sorted[picked].Balance.Uint64()
+		picked++
+	}
+	if picked > maxCoins {
+		return nil, fmt.Errorf("%w: %d coins needed, at most %d allowed", ErrCoinsFragmented, picked, maxCoins)
+	}
+
+	// The smallest coin that covers what the others leave. Coins are sorted
+	// descending, so the last candidate that qualifies is the smallest.
+	last := picked - 1
+	rest := target - (sum - sorted[last].Balance.Uint64())
+	best := last
+	for i := last + 1; i < len(sorted) && sorted[i].Balance.Uint64() >= rest; i++ {
+		best = i
+	}
+	selected := append(suiclient.Coins{}, sorted[:last]...)
+	return append(selected, sorted[best]), nil
+}
+
+// splitFromCoins adds commands splitting amount off coins to ptb and
+// returns the split coin. Coins are only merged when there are several.
+func splitFromCoins(ptb *suiptb.ProgrammableTransactionBuilder, coins suiclient.Coins, amount uint64) suiptb.Argument {
+	target := ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: coins[0].Ref()})
+	if len(coins) > 1 {
+		sources := make([]suiptb.Argument, 0, len(coins)-1)
+		for _, coin := range coins[1:] {
+			sources = append(sources, ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: coin.Ref()}))
+		}
+		ptb.Command(suiptb.Command{
+			MergeCoins: &suiptb.ProgrammableMergeCoins{
+				Destination: target,
+				Sources:     sources,
+			},
+		})
+	}
+	return ptb.Command(suiptb.Command{
+		SplitCoins: &suiptb.ProgrammableSplitCoins{
+			Coin:    target,
+			Amounts: []suiptb.Argument{ptb.MustPure(amount)},
+		},
+	})
+}
+
+// gasCoins selects SUI coins of owner covering budget, to pay gas with
+func (tb *TransactionBuilder) gasCoins(ctx context.Context, owner *sui.Address, budget uint64) (suiclient.Coins, error) {
+	coins, err := tb.ownerCoins(ctx, owner, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas coin: %w", err)
+	}
+	gasCoins, err := selectCoins(coins, budget, min(tb.maxInputCoins, maxGasPaymentCoins))
+	if err != nil {
+		return nil, fmt.Errorf("gas: %w", err)
+	}
+	return gasCoins, nil
+}
+
+// ConsolidateCoinsTxRequest contains parameters for building coin
+// consolidation transactions. TokenType is "ftoken", "xtoken", or "sui"
+// when empty.
+type ConsolidateCoinsTxRequest struct {
+	TokenType   string
+	UserAddress *sui.Address
+	Mode        TxBuildMode
+}
+
+// BuildConsolidateCoinsTransaction builds a transaction merging the
+// smallest coins of a type owned by the user into the largest, for wallets
+// too fragmented to pay in one transaction. Up to the builder's maximum
+// input coins are merged at once; SUI coins are merged as gas payment.
+func (tb *TransactionBuilder) BuildConsolidateCoinsTransaction(ctx context.Context, req ConsolidateCoinsTxRequest) (*UnsignedTransaction, error) {
+	var coinType *string
+	maxCoins := tb.maxInputCoins
+	switch req.TokenType {
+	case "", "sui":
+		maxCoins = min(maxCoins, maxGasPaymentCoins)
+	case "ftoken":
+		ftokenType := fmt.Sprintf("%s::ftoken::FTOKEN", tb.ftokenPackageId)
+		coinType = &ftokenType
+	case "xtoken":
+		xtokenType := fmt.Sprintf("%s::xtoken::XTOKEN", tb.xtokenPackageId)
+		coinType = &xtokenType
+	default:
+		return nil, fmt.Errorf("unsupported token type: %s", req.TokenType)
+	}
+	coins, err := tb.ownerCoins(ctx, req.UserAddress, coinType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin object: %w", err)
+	}
+	if len(coins) < 2 {
+		return nil, fmt.Errorf("nothing to consolidate: %d coins", len(coins))
+	}
+
+	// The largest coin and the smallest others
+	sorted := append(suiclient.Coins{}, coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Balance.Uint64() > sorted[j].Balance.Uint64()
+	})
+	merged := append(suiclient.Coins{sorted[0]}, sorted[max(1, len(sorted)-maxCoins+1):]...)
+
+	ptb := suiptb.NewTransactionDataTransactionBuilder()
+	if coinType == nil {
+		// Gas coins are merged into the first one, sent back to the user
+		ptb.Command(suiptb.Command{
+			TransferObjects: &suiptb.ProgrammableTransferObjects{
+				Objects: []suiptb.Argument{{GasCoin: &sui.EmptyEnum{}}},
+				Address: ptb.MustPure(req.UserAddress),
+			},
+		})
+	} else {
+		sources := make([]suiptb.Argument, 0, len(merged)-1)
+		for _, coin := range merged[1:] {
+			sources = append(sources, ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: coin.Ref()}))
+		}
+		ptb.Command(suiptb.Command{
+			MergeCoins: &suiptb.ProgrammableMergeCoins{
+				Destination: ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: merged[0].Ref()}),
+				Sources:     sources,
+			},
+		})
+	}
+	pt := ptb.Finish()
+
+	gasUsed, budget, err := tb.estimateGas(ctx, req.UserAddress, pt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	gasCoins := merged
+	if coinType != nil {
+		if gasCoins, err = tb.gasCoins(ctx, req.UserAddress, budget); err != nil {
+			return nil, err
+		}
+	} else if merged.TotalBalance().Cmp(new(big.Int).SetUint64(budget)) < 0 {
+		return nil, fmt.Errorf("%w: merged coins cannot pay gas of %d", ErrInsufficientBalance, budget)
+	}
+	tx := suiptb.NewTransactionData(
+		req.UserAddress,
+		pt,
+		gasCoins.CoinRefs(),
+		budget,
+		suiclient.DefaultGasPrice,
+	)
+
+	var txBytes []byte
+	if req.Mode == TxBuildModeDevInspect {
+		txBytes, err = bcs.Marshal(tx.V1.Kind)
+	} else {
+		txBytes, err = bcs.Marshal(tx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+	}
+
+	tokenType := req.TokenType
+	if tokenType == "" {
+		tokenType = "sui"
+	}
+	return &UnsignedTransaction{
+		TransactionBlockBytes: txBytes,
+		GasEstimate:           gasUsed,
+		GasBudget:             budget,
+		Metadata: map[string]string{
+			"action":    "consolidate",
+			"tokenType": tokenType,
+			"merged":    fmt.Sprintf("%d", len(merged)),
+			"remaining": fmt.Sprintf("%d", len(coins)-len(merged)+1),
+			"network":   tb.network,
+			"mode":      string(req.Mode),
+		},
+	}, nil
+}
@@ -0,0 +1,83 @@
+package onchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/sui/suiptb"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCoins(balances ...uint64) suiclient.Coins {
+	coins := make(suiclient.Coins, len(balances))
+	for i, balance := range balances {
+		id := sui.MustObjectIdFromHex("0x" + string(rune('1'+i)))
+		coins[i] = &suiclient.Coin{
+			CoinObjectId: id,
+			Version:      sui.NewBigInt(1),
+			Digest:       sui.MustNewDigest("11111111111111111111111111111111"),
+			Balance:      sui.NewBigInt(balance),
+		}
+	}
+	return coins
+}
+
+func coinBalances(coins suiclient.Coins) []uint64 {
+	balances := make([]uint64, len(coins))
+	for i, coin := range coins {
+		balances[i] = coin.Balance.Uint64()
+	}
+	return balances
+}
+
+func TestSelectCoins(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances []uint64
+		target   uint64
+		maxCoins int
+		want     []uint64
+		err      error
+	}{
+		{name: "smallest single coin", balances: []uint64{50, 500, 120, 200}, target: 100, maxCoins: 10, want: []uint64{120}},
+		{name: "exact coin", balances: []uint64{50, 100, 120}, target: 100, maxCoins: 10, want: []uint64{100}},
+		{name: "largest first, smallest last", balances: []uint64{10, 60, 30, 80, 35}, target: 110, maxCoins: 10, want: []uint64{80, 30}},
+		{name: "dust skipped", balances: []uint64{0, 40, 70}, target: 100, maxCoins: 10, want: []uint64{70, 40}},
+		{name: "not enough", balances: []uint64{40, 50}, target: 100, maxCoins: 10, err: ErrInsufficientBalance},
+		{name: "too fragmented", balances: []uint64{10, 10, 10, 10, 10}, target: 40, maxCoins: 3, err: ErrCoinsFragmented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := selectCoins(testCoins(tt.balances...), tt.target, tt.maxCoins)
+			if tt.err != nil {
+				assert.True(t, errors.Is(err, tt.err), "expected %v, got %v", tt.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, coinBalances(selected))
+		})
+	}
+}
+
+func TestSplitFromCoinsMergesOnlySeveralCoins(t *testing.T) {
+	for _, tt := range []struct {
+		coins    int
+		commands int
+	}{
+		{coins: 1, commands: 1},
+		{coins: 3, commands: 2},
+	} {
+		ptb := suiptb.NewTransactionDataTransactionBuilder()
+		splitFromCoins(ptb, testCoins(make([]uint64, tt.coins)...), 10)
+		pt := ptb.Finish()
+		require.Len(t, pt.Commands, tt.commands)
+		if tt.coins == 1 {
+			assert.NotNil(t, pt.Commands[0].SplitCoins)
+		} else {
+			assert.Len(t, pt.Commands[0].MergeCoins.Sources, tt.coins-1)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// exportPageSize is the number of events read per query of an export
+const exportPageSize = 500
+
+// ValuedEvent is an event with the USD prices of its token and the reserve
+// when it was emitted. Valued is false when no protocol sample precedes it.
+type ValuedEvent struct {
+	Event
+	Valued       bool
+	TokenPrice   decimal.Decimal // Of one whole token
+	ReservePrice decimal.Decimal
+	Value        decimal.Decimal // Of Amount
+}
+
+// ExportTransactions passes every event sent by address that matches filter
+// to fn, newest first, valued at the protocol samples of its time. Events
+// are read a page at a time, so exports of long histories hold one page in
+// memory. An error of fn stops the export and is returned.
+func (s *UserService) ExportTransactions(ctx context.Context, address string, filter TransactionFilter, fn func(ValuedEvent) error) error {
+	if s.events == nil {
+		return nil
+	}
+	cursor := ""
+	for {
+		events, next, err := listEvents(ctx, s.events, filter.conditions(address), exportPageSize, cursor)
+		if err != nil {
+			s.logger.Errorw("Failed to export user transactions", "address", address, "error", err)
+			return fmt.Errorf("failed to export user transactions: %w", err)
+		}
+		for _, event := range events {
+			valued, err := s.valueEvent(ctx, event)
+			if err != nil {
+				return err
+			}
+			if err := fn(valued); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// valueEvent values event at the latest protocol sample at or before it
+func (s *UserService) valueEvent(ctx context.Context, event Event) (ValuedEvent, error) {
+	valued := ValuedEvent{Event: event}
+	prices, err := s.pricesAt(ctx, event.Timestamp, tokenPrices{})
+	if err != nil {
+		return valued, err
+	}
+	if !prices.R.IsPositive() {
+		return valued, nil
+	}
+	valued.Valued = true
+	valued.ReservePrice = prices.R
+	valued.TokenPrice = prices.of(event.Token)
+	if amount, err := decimal.NewFromString(event.Amount); err == nil {
+		valued.Value = amount.Div(tokenScale).Mul(valued.TokenPrice)
+	}
+	return valued, nil
+}
@@ -0,0 +1,175 @@
+package onchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	feeScheduleKey = "protocol:fees:schedule"
+	feeAuditKey    = "protocol:fees:audit"
+
+	// maxFeeChanges bounds the audit trail of the fee schedule
+	maxFeeChanges = 200
+)
+
+// ErrInvalidFeeSchedule is returned for fee schedules with rates outside
+// 0-10000 bps or a collateral ratio floor of 1 or less
+var ErrInvalidFeeSchedule = errors.New("invalid fee schedule")
+
+// FeeSchedule is the off-chain part of the fees: the rates quotes and
+// simulations charge, in bps, and the collateral ratio they may not take
+// the protocol below. Operators change it at runtime; the contract's own
+// fees are the ProtocolFees of the protocol state.
+type FeeSchedule struct {
+	MintFBps   int64           `json:"mintFBps"`
+	RedeemFBps int64           `json:"redeemFBps"`
+	MintXBps   int64           `json:"mintXBps"`
+	RedeemXBps int64           `json:"redeemXBps"`
+	MinCR      decimal.Decimal `json:"minCR"`
+	UpdatedAt  time.Time       `json:"updatedAt,omitempty"` // Zero until changed from the defaults
+}
+
+// DefaultFeeSchedule is the schedule of a QuoteService configured without
+// WithFeeSchedule
+func DefaultFeeSchedule() FeeSchedule {
+	return FeeSchedule{MintFBps: 30, RedeemFBps: 50, MintXBps: 200, RedeemXBps: 200, MinCR: decimal.RequireFromString("1.1")}
+}
+
+// Validate checks the rates and collateral ratio floor of f
+func (f FeeSchedule) Validate() error {
+	for name, bps := range map[string]int64{"mintFBps": f.MintFBps, "redeemFBps": f.RedeemFBps, "mintXBps": f.MintXBps, "redeemXBps": f.RedeemXBps} {
+		if bps < 0 || bps > 10_000 {
+			return fmt.Errorf("%w: %s must be between 0 and 10000", ErrInvalidFeeSchedule, name)
+		}
+	}
+	if !f.MinCR.GreaterThan(decimal.NewFromInt(1)) {
+		return fmt.Errorf("%w: minCR must be above 1", ErrInvalidFeeSchedule)
+	}
+	return nil
+}
+
+// bpsRate is bps as a share
+func bpsRate(bps int64) decimal.Decimal {
+	return decimal.New(bps, -4)
+}
+
+// FeeChange is an entry of the audit trail of the fee schedule
+type FeeChange struct {
+	Before     FeeSchedule `json:"before"`
+	After      FeeSchedule `json:"after"`
+	Reason     string      `json:"reason,omitempty"`
+	RequestID  string      `json:"requestId,omitempty"`
+	RemoteAddr string      `json:"remoteAddr,omitempty"`
+	At         time.Time   `json:"at"`
+}
+
+// QuoteServiceOption configures a QuoteService
+type QuoteServiceOption func(*QuoteService)
+
+// WithFeeSchedule configures the fee schedule quotes start from, and the
+// store changes to it and their audit trail are kept in, so that every
+// replica quotes alike. Changes are kept in memory otherwise.
+func WithFeeSchedule(defaults FeeSchedule, store kv.Store) QuoteServiceOption {
+	return func(s *QuoteService) {
+		s.defaultFees = defaults
+		s.fees = store
+	}
+}
+
+// FeeSchedule returns the fee schedule in effect
+func (s *QuoteService) FeeSchedule(ctx context.Context) (FeeSchedule, error) {
+	raw, err := s.fees.Get(ctx, feeScheduleKey)
+	if errors.Is(err, kv.ErrNotFound) {
+		return s.defaultFees, nil
+	}
+	if err != nil {
+		return FeeSchedule{}, fmt.Errorf("get fee schedule: %w", err)
+	}
+	var schedule FeeSchedule
+	if err := json.Unmarshal(raw, &schedule); err != nil {
+		return FeeSchedule{}, fmt.Errorf("decode fee schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// SetFeeSchedule puts schedule in effect for the quotes that follow and
+// records the change, described by change, in the audit trail
+func (s *QuoteService) SetFeeSchedule(ctx context.Context, schedule FeeSchedule, change FeeChange) (*FeeChange, error) {
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+	before, err := s.FeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	change.At = time.Now()
+	schedule.UpdatedAt = change.At
+	change.Before, change.After = before, schedule
+	raw, err := json.Marshal(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("encode fee schedule: %w", err)
+	}
+	if err := s.fees.Set(ctx, feeScheduleKey, raw); err != nil {
+		return nil, fmt.Errorf("save fee schedule: %w", err)
+	}
+
+	// The schedule is changed already, so a change that fails to record is
+	// only logged
+	s.logger.Warnw("Fee schedule changed",
+		"before", before,
+		"after", schedule,
+		"reason", change.Reason,
+		"requestId", change.RequestID,
+		"remoteAddr", change.RemoteAddr,
+	)
+	if err := s.recordFeeChange(ctx, &change); err != nil {
+		s.logger.Errorw("Failed to record fee schedule change", "error", err)
+	}
+	return &change, nil
+}
+
+// FeeChanges returns the last limit changes of the fee schedule, newest
+// first
+func (s *QuoteService) FeeChanges(ctx context.Context, limit int) ([]FeeChange, error) {
+	if limit <= 0 || limit > maxFeeChanges {
+		limit = maxFeeChanges
+	}
+	raws, err := s.fees.LRange(ctx, feeAuditKey, 0, int64(limit-1))
+	if err != nil && !errors.Is(err, kv.ErrNotFound) {
+		return nil, fmt.Errorf("list fee schedule changes: %w", err)
+	}
+	changes := make([]FeeChange, 0, len(raws))
+	for _, raw := range raws {
+		var change FeeChange
+		if err := json.Unmarshal(raw, &change); err != nil {
+			return nil, fmt.Errorf("decode fee schedule change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (s *QuoteService) recordFeeChange(ctx context.Context, change *FeeChange) error {
+	raw, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("encode fee schedule change: %w", err)
+	}
+	n, err := s.fees.LPush(ctx, feeAuditKey, raw)
+	if err != nil {
+		return fmt.Errorf("record fee schedule change: %w", err)
+	}
+	for ; n > maxFeeChanges; n-- {
+		if _, err := s.fees.RPop(ctx, feeAuditKey); err != nil {
+			return fmt.Errorf("trim fee schedule changes: %w", err)
+		}
+	}
+	return nil
+}
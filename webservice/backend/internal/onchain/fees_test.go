@@ -0,0 +1,74 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFeeSchedule(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, cache.SetProtocolState(ctx, ProtocolState{
+		CR:        decimal.NewFromInt(2),
+		ReservesR: decimal.NewFromInt(1_000_000),
+		SupplyX:   decimal.NewFromInt(100_000),
+		AsOf:      time.Now(),
+	}))
+	cfg := &config.Config{Oracle: config.OracleConfig{MaxAge: time.Minute}}
+	protocol := NewProtocolService(nil, cache, cfg, logger)
+	fees := memkv.NewStore()
+	svc := NewQuoteService(nil, cache, protocol, cfg, logger, WithFeeSchedule(DefaultFeeSchedule(), fees))
+
+	schedule, err := svc.FeeSchedule(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultFeeSchedule(), schedule)
+	quote, err := svc.GetMintXQuote(ctx, decimal.NewFromInt(100))
+	require.NoError(t, err)
+	assert.True(t, quote.Fee.Equal(decimal.NewFromInt(2)), "fee %s", quote.Fee)
+
+	// A change prices the quotes that follow, on every service sharing the store
+	schedule.MintXBps = 500
+	change, err := svc.SetFeeSchedule(ctx, schedule, FeeChange{Reason: "widen", RequestID: "req-1"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), change.Before.MintXBps)
+	assert.Equal(t, int64(500), change.After.MintXBps)
+	other := NewQuoteService(nil, cache, protocol, cfg, logger, WithFeeSchedule(DefaultFeeSchedule(), fees))
+	quote, err = other.GetMintXQuote(ctx, decimal.NewFromInt(100))
+	require.NoError(t, err)
+	assert.True(t, quote.Fee.Equal(decimal.NewFromInt(5)), "fee %s", quote.Fee)
+
+	for _, invalid := range []FeeSchedule{
+		{MintFBps: -1, MinCR: decimal.NewFromInt(2)},
+		{RedeemXBps: 10_001, MinCR: decimal.NewFromInt(2)},
+		{MinCR: decimal.NewFromInt(1)},
+	} {
+		_, err := svc.SetFeeSchedule(ctx, invalid, FeeChange{})
+		assert.True(t, errors.Is(err, ErrInvalidFeeSchedule), "expected %+v rejected, got %v", invalid, err)
+	}
+
+	// The audit trail is newest first and bounded
+	for i := 0; i < maxFeeChanges+5; i++ {
+		_, err := svc.SetFeeSchedule(ctx, schedule, FeeChange{Reason: fmt.Sprintf("change %d", i)})
+		require.NoError(t, err)
+	}
+	changes, err := svc.FeeChanges(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, maxFeeChanges)
+	assert.Equal(t, fmt.Sprintf("change %d", maxFeeChanges+4), changes[0].Reason)
+	changes, err = svc.FeeChanges(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+}
@@ -0,0 +1,208 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"go.uber.org/zap"
+)
+
+// DefaultHistorySampleInterval is the time between protocol samples
+const DefaultHistorySampleInterval = time.Minute
+
+// maxHistoryPoints bounds the points of one history query
+const maxHistoryPoints = 2000
+
+// historyPageSize is the number of samples read per query of the history
+const historyPageSize = 1000
+
+// historyMetrics are the sampled metrics, by name, with their fields in
+// entities.ProtocolSampleSchema
+var historyMetrics = map[string]string{
+	"cr":            "cr",
+	"reserves":      "reserves_r",
+	"supply_f":      "supply_f",
+	"supply_x":      "supply_x",
+	"peg_deviation": "peg_deviation",
+	"price":         "reserve_price",
+}
+
+var (
+	// ErrUnknownMetric is returned for history queries of metrics that are
+	// not sampled
+	ErrUnknownMetric = errors.New("unknown metric")
+	// ErrInvalidRange is returned for history queries whose range is empty
+	// or has too many points for the interval
+	ErrInvalidRange = errors.New("invalid range")
+)
+
+// WithProtocolSampleStore serves the protocol history from a samples
+// repository, as described by entities.ProtocolSampleSchema
+func WithProtocolSampleStore(samples interfaces.Repository) ProtocolServiceOption {
+	return func(s *ProtocolService) {
+		s.samples = samples
+	}
+}
+
+// HistoryPoint summarizes the samples of a metric in the interval starting
+// at Time
+type HistoryPoint struct {
+	Time    time.Time
+	Avg     float64
+	Min     float64
+	Max     float64
+	Last    float64 // Of the newest sample
+	Samples int
+}
+
+// GetHistory returns the series of metric from from to to, downsampled to
+// one point per interval. Intervals without samples have no point.
+func (s *ProtocolService) GetHistory(ctx context.Context, metric string, interval time.Duration, from, to time.Time) ([]HistoryPoint, error) {
+	field, ok := historyMetrics[metric]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMetric, metric)
+	}
+	if interval <= 0 || !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRange)
+	}
+	if to.Sub(from)/interval > maxHistoryPoints {
+		return nil, fmt.Errorf("%w: more than %d points, use a longer interval", ErrInvalidRange, maxHistoryPoints)
+	}
+	if s.samples == nil {
+		return []HistoryPoint{}, nil
+	}
+
+	var points []HistoryPoint
+	limit := historyPageSize
+	query := &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{
+				{Field: "timestamp", Operator: &interfaces.FilterOperator{Gte: from}},
+				{Field: "timestamp", Operator: &interfaces.FilterOperator{Lt: to}},
+			},
+		},
+		Select:  []string{"timestamp", field},
+		OrderBy: []interfaces.OrderBy{{Field: "timestamp", Direction: "asc"}},
+		Limit:   &limit,
+	}
+	for {
+		page, err := s.samples.FindMany(ctx, query)
+		if err != nil {
+			s.logger.Errorw("Failed to read protocol history", "metric", metric, "error", err)
+			return nil, fmt.Errorf("failed to read protocol history: %w", err)
+		}
+		for _, record := range page.Data {
+			at, _ := record["timestamp"].(time.Time)
+			value, ok := toFloat(record[field])
+			if !ok {
+				continue
+			}
+			points = addToHistory(points, at.Truncate(interval), value)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.After = page.NextCursor
+	}
+	if points == nil {
+		points = []HistoryPoint{}
+	}
+	return points, nil
+}
+
+// addToHistory adds a sample at the bucket time to points, whose samples
+// come in time order
+func addToHistory(points []HistoryPoint, bucket time.Time, value float64) []HistoryPoint {
+	if n := len(points); n > 0 && points[n-1].Time.Equal(bucket) {
+		p := &points[n-1]
+		p.Avg += (value - p.Avg) / float64(p.Samples+1)
+		p.Min = min(p.Min, value)
+		p.Max = max(p.Max, value)
+		p.Last = value
+		p.Samples++
+		return points
+	}
+	return append(points, HistoryPoint{Time: bucket, Avg: value, Min: value, Max: value, Last: value, Samples: 1})
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// ProtocolSampler records the protocol state every interval in a samples
+// repository, as described by entities.ProtocolSampleSchema, for the
+// protocol history. Samples are keyed by their slot, the sample time
+// truncated to the interval, so replicas sampling a slot leave one sample.
+type ProtocolSampler struct {
+	chain    ProtocolStateReader
+	samples  interfaces.Repository
+	interval time.Duration
+	logger   *zap.SugaredLogger
+}
+
+func NewProtocolSampler(chain ProtocolStateReader, samples interfaces.Repository, interval time.Duration, logger *zap.SugaredLogger) *ProtocolSampler {
+	if interval <= 0 {
+		interval = DefaultHistorySampleInterval
+	}
+	return &ProtocolSampler{
+		chain:    chain,
+		samples:  samples,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start samples until ctx is done
+func (s *ProtocolSampler) Start(ctx context.Context) {
+	s.logger.Infow("Protocol sampler starting", "interval", s.interval)
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if err := s.RunOnce(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				s.logger.Warnw("Protocol sample failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				s.logger.Infow("Protocol sampler stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce records the protocol state in the slot of now
+func (s *ProtocolSampler) RunOnce(ctx context.Context, now time.Time) error {
+	state, err := s.chain.ProtocolState(ctx)
+	if err != nil {
+		return err
+	}
+	slot := now.UTC().Truncate(s.interval)
+	_, err = s.samples.Upsert(ctx, map[string]interface{}{"timestamp": slot}, map[string]interface{}{
+		"cr":            state.CR.InexactFloat64(),
+		"reserves_r":    state.ReservesR.InexactFloat64(),
+		"supply_f":      state.SupplyF.InexactFloat64(),
+		"supply_x":      state.SupplyX.InexactFloat64(),
+		"peg_deviation": state.PegDeviation.InexactFloat64(),
+		"reserve_price": reservePriceUSD(state).InexactFloat64(),
+	})
+	if err != nil {
+		return fmt.Errorf("save protocol sample: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package onchain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestProtocolHistory(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+
+	samples := database.Repository(entities.ProtocolSampleSchema)
+	logger := zap.NewNop().Sugar()
+	chain := &fakeStateReader{}
+	sampler := NewProtocolSampler(chain, samples, 15*time.Minute, logger)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, cr := range []string{"1.5", "1.4", "1.3", "1.2", "1.6"} {
+		chain.cr = decimal.RequireFromString(cr)
+		require.NoError(t, sampler.RunOnce(ctx, start.Add(time.Duration(i)*15*time.Minute)))
+	}
+	// A second sample in a slot replaces the first
+	chain.cr = decimal.RequireFromString("1.1")
+	require.NoError(t, sampler.RunOnce(ctx, start.Add(65*time.Minute)))
+	count, err := samples.Count(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	svc := NewProtocolService(nil, nil, nil, logger, WithProtocolSampleStore(samples))
+	points, err := svc.GetHistory(ctx, "cr", time.Hour, start, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, start, points[0].Time)
+	assert.InDelta(t, 1.35, points[0].Avg, 1e-9)
+	assert.Equal(t, 1.2, points[0].Min)
+	assert.Equal(t, 1.5, points[0].Max)
+	assert.Equal(t, 1.2, points[0].Last)
+	assert.Equal(t, 4, points[0].Samples)
+	assert.Equal(t, start.Add(time.Hour), points[1].Time)
+	assert.Equal(t, 1.1, points[1].Avg)
+	assert.Equal(t, 1, points[1].Samples)
+
+	// The range excludes its end
+	points, err = svc.GetHistory(ctx, "cr", 15*time.Minute, start.Add(15*time.Minute), start.Add(45*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, 1.4, points[0].Avg)
+	assert.Equal(t, 1.3, points[1].Avg)
+
+	_, err = svc.GetHistory(ctx, "tvl", time.Hour, start, start.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrUnknownMetric)
+	_, err = svc.GetHistory(ctx, "cr", time.Hour, start, start)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = svc.GetHistory(ctx, "cr", time.Minute, start, start.Add(30*24*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	points, err = NewProtocolService(nil, nil, nil, logger).GetHistory(ctx, "cr", time.Hour, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, points)
+}
@@ -0,0 +1,280 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultIndexerPollInterval is the time between queryEvents polls of
+	// the event indexer
+	DefaultIndexerPollInterval = 5 * time.Second
+	// indexerPageSize is the number of events read per queryEvents call,
+	// and the most transactions multiGetTransactionBlocks accepts
+	indexerPageSize = 50
+)
+
+// SuiEventSource is the part of the Sui RPC API the event indexer reads.
+// *suiclient.ClientImpl and *RPCPool implement it.
+type SuiEventSource interface {
+	QueryEvents(ctx context.Context, req *suiclient.QueryEventsRequest) (*suiclient.EventPage, error)
+	MultiGetTransactionBlocks(ctx context.Context, req *suiclient.MultiGetTransactionBlocksRequest) ([]*suiclient.SuiTransactionBlockResponse, error)
+}
+
+// indexedEvent describes how a Move event of the leafsii package is stored
+type indexedEvent struct {
+	module      string
+	name        string
+	eventType   string // One of the EventType constants
+	token       string // Token of amountField
+	amountField string
+}
+
+// indexedEvents are the protocol events the indexer follows
+var indexedEvents = []indexedEvent{
+	{"leafsii", "MintF", EventTypeMint, "fToken", "f_minted"},
+	{"leafsii", "MintX", EventTypeMint, "xToken", "x_minted"},
+	{"leafsii", "RedeemF", EventTypeRedeem, "fToken", "f_burned"},
+	{"leafsii", "RedeemX", EventTypeRedeem, "xToken", "x_burned"},
+	{"stability_pool", "SPDeposit", EventTypeStake, "fToken", "f_amount"},
+	{"stability_pool", "SPWithdraw", EventTypeUnstake, "fToken", "f_amount"},
+	{"stability_pool", "SPIndexAccrual", EventTypeRebalance, "Sui", "indexed_r"},
+	{"stability_pool", "SPScaleShrink", EventTypeRebalance, "fToken", "burned_f"},
+}
+
+// EventIndexer stores the protocol events of the leafsii package in an
+// events repository, as described by entities.EventSchema. Each Move event
+// type is followed with suix_queryEvents from a cursor kept in a cursors
+// repository, as described by entities.IndexerCursorSchema, so indexing
+// resumes where it stopped across restarts. Events are stored by their
+// transaction digest and sequence, so storing one twice leaves one record.
+type EventIndexer struct {
+	source  SuiEventSource
+	events  interfaces.Repository
+	cursors interfaces.Repository
+	types   map[string]indexedEvent // By Move event type
+	order   []*sui.StructTag
+	logger  *zap.SugaredLogger
+}
+
+// NewEventIndexer returns an indexer of the events of packageId.
+func NewEventIndexer(source SuiEventSource, events, cursors interfaces.Repository, packageId *sui.PackageId, logger *zap.SugaredLogger) (*EventIndexer, error) {
+	idx := &EventIndexer{
+		source:  source,
+		events:  events,
+		cursors: cursors,
+		types:   make(map[string]indexedEvent),
+		logger:  logger,
+	}
+	for _, e := range indexedEvents {
+		tag, err := sui.StructTagFromString(fmt.Sprintf("%s::%s::%s", packageId, e.module, e.name))
+		if err != nil {
+			return nil, fmt.Errorf("parse %s event type: %w", e.name, err)
+		}
+		idx.types[tag.String()] = e
+		idx.order = append(idx.order, tag)
+	}
+	return idx, nil
+}
+
+// Start polls for new events each interval, DefaultIndexerPollInterval
+// when it is not positive, until ctx is done.
+func (idx *EventIndexer) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultIndexerPollInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := idx.poll(ctx); err != nil && ctx.Err() == nil {
+				idx.logger.Warnw("Event indexing failed; retrying", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// poll stores the events of each type after its cursor. Types are queried
+// one by one, as full nodes do not accept Any filters for queryEvents.
+func (idx *EventIndexer) poll(ctx context.Context) error {
+	limit := uint(indexerPageSize)
+	for _, eventType := range idx.order {
+		key := eventType.String()
+		cursor, err := idx.cursor(ctx, key)
+		if err != nil {
+			return err
+		}
+		for {
+			page, err := idx.source.QueryEvents(ctx, &suiclient.QueryEventsRequest{
+				Query:  &suiclient.EventFilter{MoveEventType: eventType},
+				Cursor: cursor,
+				Limit:  &limit,
+			})
+			if err != nil {
+				return fmt.Errorf("query %s: %w", key, err)
+			}
+			if err := idx.store(ctx, page.Data); err != nil {
+				return fmt.Errorf("store %s: %w", key, err)
+			}
+			if page.NextCursor != nil && len(page.Data) > 0 {
+				cursor = page.NextCursor
+				if err := idx.saveCursor(ctx, key, cursor); err != nil {
+					return err
+				}
+			}
+			if !page.HasNextPage || len(page.Data) == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// cursor returns the ID of the last event of eventType stored, or nil
+// when none was
+func (idx *EventIndexer) cursor(ctx context.Context, eventType string) (*suiclient.EventId, error) {
+	record, err := idx.cursors.GetByID(ctx, interfaces.StringID(eventType))
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load %s cursor: %w", eventType, err)
+	}
+	digest, err := sui.NewDigest(fmt.Sprint(record["tx_digest"]))
+	if err != nil {
+		return nil, fmt.Errorf("load %s cursor: %w", eventType, err)
+	}
+	seq, ok := new(big.Int).SetString(fmt.Sprint(record["event_seq"]), 10)
+	if !ok {
+		return nil, fmt.Errorf("load %s cursor: invalid event sequence %v", eventType, record["event_seq"])
+	}
+	return &suiclient.EventId{TxDigest: *digest, EventSeq: &sui.BigInt{Int: seq}}, nil
+}
+
+func (idx *EventIndexer) saveCursor(ctx context.Context, eventType string, cursor *suiclient.EventId) error {
+	_, err := idx.cursors.Upsert(ctx, map[string]interface{}{"id": eventType}, map[string]interface{}{
+		"tx_digest": cursor.TxDigest.String(),
+		"event_seq": eventSeq(cursor),
+	})
+	if err != nil {
+		return fmt.Errorf("save %s cursor: %w", eventType, err)
+	}
+	return nil
+}
+
+// store saves events with the checkpoints of their transactions
+func (idx *EventIndexer) store(ctx context.Context, events []suiclient.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	checkpoints, err := idx.checkpoints(ctx, events)
+	if err != nil {
+		return err
+	}
+	for _, evt := range events {
+		if evt.Type == nil {
+			continue
+		}
+		spec, ok := idx.types[evt.Type.String()]
+		if !ok {
+			continue
+		}
+		record := decodeEvent(evt, spec)
+		record["checkpoint"] = checkpoints[evt.Id.TxDigest.String()]
+		_, err := idx.events.Upsert(ctx, map[string]interface{}{
+			"tx_digest":       evt.Id.TxDigest.String(),
+			"sequence_number": record["sequence_number"],
+		}, record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpoints returns the checkpoint of the transaction of each event, by
+// transaction digest
+func (idx *EventIndexer) checkpoints(ctx context.Context, events []suiclient.Event) (map[string]int64, error) {
+	checkpoints := make(map[string]int64)
+	var digests []*sui.Digest
+	for _, evt := range events {
+		if _, ok := checkpoints[evt.Id.TxDigest.String()]; ok {
+			continue
+		}
+		checkpoints[evt.Id.TxDigest.String()] = 0
+		digest := evt.Id.TxDigest
+		digests = append(digests, &digest)
+	}
+	txs, err := idx.source.MultiGetTransactionBlocks(ctx, &suiclient.MultiGetTransactionBlocksRequest{
+		Digests: digests,
+		Options: &suiclient.SuiTransactionBlockResponseOptions{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get transactions: %w", err)
+	}
+	for _, tx := range txs {
+		if tx != nil && tx.Checkpoint != nil && tx.Checkpoint.Int != nil {
+			checkpoints[tx.Digest.String()] = tx.Checkpoint.Int64()
+		}
+	}
+	return checkpoints, nil
+}
+
+// decodeEvent returns the record of evt. User events are attributed to
+// their user field, and the others to the sender of their transaction.
+func decodeEvent(evt suiclient.Event, spec indexedEvent) map[string]interface{} {
+	fields, _ := evt.ParsedJson.(map[string]interface{})
+	sender := ""
+	if evt.Sender != nil {
+		sender = evt.Sender.String()
+	}
+	if user, ok := fields["user"].(string); ok && user != "" {
+		sender = user
+	}
+	timestamp := time.Time{}
+	if evt.TimestampMs != nil && evt.TimestampMs.Int != nil {
+		timestamp = time.UnixMilli(evt.TimestampMs.Int64()).UTC()
+	}
+	var seq int64
+	if evt.Id.EventSeq != nil && evt.Id.EventSeq.Int != nil {
+		seq = evt.Id.EventSeq.Int64()
+	}
+	// Sui renders u64 values as decimal strings
+	amount, _ := fields[spec.amountField].(string)
+	if amount == "" {
+		amount = "0"
+	}
+	record := map[string]interface{}{
+		"sequence_number": seq,
+		"timestamp":       timestamp,
+		"type":            spec.eventType,
+		"tx_digest":       evt.Id.TxDigest.String(),
+		"sender":          sender,
+		"token":           spec.token,
+		"amount":          amount,
+	}
+	if fields != nil {
+		record["fields"] = fields
+	}
+	return record
+}
+
+func eventSeq(id *suiclient.EventId) string {
+	if id.EventSeq == nil || id.EventSeq.Int == nil {
+		return "0"
+	}
+	return id.EventSeq.String()
+}
@@ -0,0 +1,124 @@
+package onchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeEventSource serves events by type in pages of one, as queryEvents
+// does after a cursor
+type fakeEventSource struct {
+	events      map[string][]suiclient.Event
+	checkpoints map[string]int64
+	queries     int
+}
+
+func (f *fakeEventSource) QueryEvents(_ context.Context, req *suiclient.QueryEventsRequest) (*suiclient.EventPage, error) {
+	f.queries++
+	events := f.events[req.Query.MoveEventType.String()]
+	next := 0
+	if req.Cursor != nil {
+		for i, evt := range events {
+			if evt.Id.TxDigest.String() == req.Cursor.TxDigest.String() && evt.Id.EventSeq.Cmp(req.Cursor.EventSeq.Int) == 0 {
+				next = i + 1
+			}
+		}
+	}
+	page := &suiclient.EventPage{NextCursor: req.Cursor}
+	if next < len(events) {
+		page.Data = events[next : next+1]
+		page.NextCursor = &events[next].Id
+		page.HasNextPage = next+1 < len(events)
+	}
+	return page, nil
+}
+
+func (f *fakeEventSource) MultiGetTransactionBlocks(_ context.Context, req *suiclient.MultiGetTransactionBlocksRequest) ([]*suiclient.SuiTransactionBlockResponse, error) {
+	var txs []*suiclient.SuiTransactionBlockResponse
+	for _, digest := range req.Digests {
+		checkpoint := f.checkpoints[digest.String()]
+		txs = append(txs, &suiclient.SuiTransactionBlockResponse{
+			Digest:     *digest,
+			Checkpoint: &sui.BigInt{Int: big.NewInt(checkpoint)},
+		})
+	}
+	return txs, nil
+}
+
+func (f *fakeEventSource) add(eventType string, digest byte, seq int64, timestampMs int64, fields map[string]interface{}) {
+	tag, _ := sui.StructTagFromString(eventType)
+	f.events[eventType] = append(f.events[eventType], suiclient.Event{
+		Id:          suiclient.EventId{TxDigest: sui.Digest{digest}, EventSeq: &sui.BigInt{Int: big.NewInt(seq)}},
+		Sender:      sui.MustAddressFromHex("0x99"),
+		Type:        tag,
+		ParsedJson:  fields,
+		TimestampMs: &sui.BigInt{Int: big.NewInt(timestampMs)},
+	})
+	f.checkpoints[sui.Digest{digest}.String()] = int64(digest) * 10
+}
+
+func TestEventIndexerStoresDecodedEvents(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+	events := database.Repository(entities.EventSchema)
+	cursors := database.Repository(entities.IndexerCursorSchema)
+
+	pkg := sui.MustPackageIdFromHex("0x1")
+	user := sui.MustAddressFromHex("0x1234").String()
+	source := &fakeEventSource{events: map[string][]suiclient.Event{}, checkpoints: map[string]int64{}}
+	source.add(pkg.String()+"::leafsii::MintF", 1, 0, 1_700_000_000_000, map[string]interface{}{"user": user, "reserve_in": "1000", "f_minted": "990"})
+	source.add(pkg.String()+"::leafsii::RedeemX", 2, 1, 1_700_000_060_000, map[string]interface{}{"user": user, "x_burned": "50", "reserve_out": "49"})
+	source.add(pkg.String()+"::stability_pool::SPIndexAccrual", 3, 0, 1_700_000_120_000, map[string]interface{}{"delta": "7", "new_index": "8", "indexed_r": "300"})
+
+	logger := zap.NewNop().Sugar()
+	indexer, err := NewEventIndexer(source, events, cursors, pkg, logger)
+	require.NoError(t, err)
+	require.NoError(t, indexer.poll(ctx))
+
+	userSvc := NewUserService(nil, nil, logger, WithEventStore(events))
+	txs, _, err := userSvc.GetTransactions(ctx, user, TransactionFilter{}, 10, "")
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	assert.Equal(t, EventTypeRedeem, txs[0].Type)
+	assert.Equal(t, "xToken", txs[0].Token)
+	assert.Equal(t, "50", txs[0].Amount)
+	assert.Equal(t, uint64(1), txs[0].SequenceNumber)
+	assert.Equal(t, uint64(20), txs[0].Checkpoint)
+	assert.Equal(t, EventTypeMint, txs[1].Type)
+	assert.Equal(t, "fToken", txs[1].Token)
+	assert.Equal(t, "990", txs[1].Amount)
+	assert.Equal(t, "1000", txs[1].Fields["reserve_in"])
+	assert.Equal(t, int64(1_700_000_000_000), txs[1].Timestamp.UnixMilli())
+
+	// Pool events are attributed to the sender of their transaction
+	protocolSvc := NewProtocolService(nil, nil, nil, logger, WithProtocolEventStore(events))
+	rebalances, _, err := protocolSvc.GetEvents(ctx, EventTypeRebalance, 10, "")
+	require.NoError(t, err)
+	require.Len(t, rebalances, 1)
+	assert.Equal(t, "Sui", rebalances[0].Token)
+	assert.Equal(t, "300", rebalances[0].Amount)
+	assert.NotEqual(t, user, rebalances[0].Sender)
+
+	// A restarted indexer resumes from the stored cursors
+	source.add(pkg.String()+"::leafsii::MintF", 4, 0, 1_700_000_180_000, map[string]interface{}{"user": user, "reserve_in": "10", "f_minted": "9"})
+	indexer, err = NewEventIndexer(source, events, cursors, pkg, logger)
+	require.NoError(t, err)
+	source.queries = 0
+	require.NoError(t, indexer.poll(ctx))
+	assert.Equal(t, len(indexedEvents), source.queries, "expected one query per type")
+	all, _, err := protocolSvc.GetEvents(ctx, "", 10, "")
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, "9", all[0].Amount)
+}
@@ -0,0 +1,302 @@
+package onchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// Operational modes of the protocol, by collateral ratio
+const (
+	// ProtocolModeNormal enables every action
+	ProtocolModeNormal = "normal"
+	// ProtocolModeStability disables fToken mints and incentivizes xToken
+	ProtocolModeStability = "stability"
+	// ProtocolModeUserRebalance pays bonuses for user rebalancing
+	ProtocolModeUserRebalance = "user_rebalance"
+	// ProtocolModeProtocolRebalance burns stability pool fTokens
+	ProtocolModeProtocolRebalance = "protocol_rebalance"
+)
+
+// Collateral ratios at which the protocol leaves each mode, as in the
+// CR_T_L1..3 constants of the leafsii module
+var (
+	crThresholdStability         = decimal.RequireFromString("1.306")
+	crThresholdUserRebalance     = decimal.RequireFromString("1.206")
+	crThresholdProtocolRebalance = decimal.RequireFromString("1.144")
+)
+
+// ProtocolModeThresholds returns the collateral ratios below which the
+// protocol enters stability, user rebalance and protocol rebalance mode
+func ProtocolModeThresholds() (stability, userRebalance, protocolRebalance decimal.Decimal) {
+	return crThresholdStability, crThresholdUserRebalance, crThresholdProtocolRebalance
+}
+
+// ProtocolModeOf returns the operational mode of the protocol at the
+// collateral ratio cr
+func ProtocolModeOf(cr decimal.Decimal) string {
+	switch {
+	case cr.GreaterThanOrEqual(crThresholdStability):
+		return ProtocolModeNormal
+	case cr.GreaterThanOrEqual(crThresholdUserRebalance):
+		return ProtocolModeStability
+	case cr.GreaterThanOrEqual(crThresholdProtocolRebalance):
+		return ProtocolModeUserRebalance
+	default:
+		return ProtocolModeProtocolRebalance
+	}
+}
+
+// ProtocolAlertsChannel is the pub/sub channel, and WebSocket topic, of
+// protocol alerts
+const ProtocolAlertsChannel = "fx:protocol:alerts"
+
+// Types of protocol alerts
+const (
+	AlertModeChange  = "mode_change"  // The protocol changed mode
+	AlertCRBreach    = "cr_breach"    // The CR fell below the alert threshold
+	AlertCRCritical  = "cr_critical"  // The CR fell below the pause threshold
+	AlertCRRecovered = "cr_recovered" // The CR rose back above the alert or pause threshold
+)
+
+// ProtocolAlert reports a collateral ratio breach or mode change. Alerts
+// calling for a protective action carry it built for the operator to sign
+// and submit.
+type ProtocolAlert struct {
+	Type         string          `json:"type"`
+	Mode         string          `json:"mode"`
+	PreviousMode string          `json:"previous_mode,omitempty"`
+	CR           decimal.Decimal `json:"cr"`
+	Threshold    decimal.Decimal `json:"threshold"` // Crossed by the CR; zero on mode changes
+	Action       string          `json:"action,omitempty"`
+	Transaction  string          `json:"transaction,omitempty"` // Base64 transaction of the action
+	At           time.Time       `json:"at"`
+}
+
+// ProtocolAlerter delivers protocol alerts
+type ProtocolAlerter interface {
+	Alert(ctx context.Context, alert ProtocolAlert) error
+}
+
+// CacheAlerter publishes alerts on ProtocolAlertsChannel, which the
+// WebSocket hub forwards to its subscribers
+type CacheAlerter struct {
+	Cache *store.Cache
+}
+
+func (a CacheAlerter) Alert(ctx context.Context, alert ProtocolAlert) error {
+	return a.Cache.Publish(ctx, ProtocolAlertsChannel, alert)
+}
+
+// WebhookAlerter posts each alert as JSON to URL
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (a *WebhookAlerter) Alert(ctx context.Context, alert ProtocolAlert) error {
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	text := fmt.Sprintf("Protocol %s: CR %s in %s mode", alert.Type, alert.CR.StringFixed(4), alert.Mode)
+	if alert.Action != "" {
+		text += fmt.Sprintf(", %s transaction ready to sign", alert.Action)
+	}
+	body, err := json.Marshal(struct {
+		Text string `json:"text"` // Shown by Slack-compatible receivers
+		ProtocolAlert
+	}{
+		Text:          text,
+		ProtocolAlert: alert,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("post alert: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ProtocolStateReader reads the protocol state, as *Client does
+type ProtocolStateReader interface {
+	ProtocolState(ctx context.Context) (*ProtocolState, error)
+}
+
+// ProtectiveActionBuilder builds the admin transactions of protective
+// actions, as *TransactionBuilder does
+type ProtectiveActionBuilder interface {
+	BuildRebalanceTransaction(ctx context.Context, req RebalanceTxRequest) (*UnsignedTransaction, error)
+	BuildUserActionsTransaction(ctx context.Context, req UserActionsTxRequest) (*UnsignedTransaction, error)
+}
+
+// MonitorConfig configures a ProtocolMonitor. Zero thresholds are not
+// watched.
+type MonitorConfig struct {
+	Interval          time.Duration
+	AlertCR           decimal.Decimal // Alert below this CR
+	PauseCR           decimal.Decimal // Build a pause of user actions below this CR
+	RebalanceTargetCR decimal.Decimal // Build a rebalance to this CR on entering protocol rebalance mode
+}
+
+// ProtocolMonitor polls the protocol state and alerts when the collateral
+// ratio crosses the configured thresholds or the mode changes. With a
+// builder, alerts calling for a rebalance or pause carry its transaction,
+// sent by the configured admin.
+type ProtocolMonitor struct {
+	chain    ProtocolStateReader
+	builder  ProtectiveActionBuilder // nil builds no actions
+	alerters []ProtocolAlerter
+	config   MonitorConfig
+	logger   *zap.SugaredLogger
+
+	// Touched by RunOnce only
+	mode     string
+	breached bool // Below the alert threshold
+	paused   bool // Below the pause threshold
+}
+
+func NewProtocolMonitor(chain ProtocolStateReader, builder ProtectiveActionBuilder, config MonitorConfig, logger *zap.SugaredLogger, alerters ...ProtocolAlerter) *ProtocolMonitor {
+	return &ProtocolMonitor{
+		chain:    chain,
+		builder:  builder,
+		alerters: alerters,
+		config:   config,
+		logger:   logger,
+	}
+}
+
+// Start runs the monitor until ctx is done
+func (m *ProtocolMonitor) Start(ctx context.Context) {
+	m.logger.Infow("Protocol monitor starting",
+		"interval", m.config.Interval,
+		"alertCr", m.config.AlertCR.String(),
+		"pauseCr", m.config.PauseCR.String(),
+		"buildActions", m.builder != nil,
+	)
+	go func() {
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.RunOnce(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				m.logger.Warnw("Protocol monitor check failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				m.logger.Infow("Protocol monitor stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce reads the protocol state, delivers the alerts it calls for and
+// returns them. Thresholds alert when crossed either way; a mode the
+// protocol starts in alerts unless normal.
+func (m *ProtocolMonitor) RunOnce(ctx context.Context, now time.Time) ([]ProtocolAlert, error) {
+	state, err := m.chain.ProtocolState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cr := state.CR
+	mode := ProtocolModeOf(cr)
+
+	var alerts []ProtocolAlert
+	if mode != m.mode && (m.mode != "" || mode != ProtocolModeNormal) {
+		alert := ProtocolAlert{Type: AlertModeChange, Mode: mode, PreviousMode: m.mode, CR: cr, At: now}
+		if mode == ProtocolModeProtocolRebalance && m.config.RebalanceTargetCR.IsPositive() {
+			m.withAction(ctx, &alert, "rebalance", func() (*UnsignedTransaction, error) {
+				return m.builder.BuildRebalanceTransaction(ctx, RebalanceTxRequest{
+					TargetCR: m.config.RebalanceTargetCR,
+					Mode:     TxBuildModeExecution,
+				})
+			})
+		}
+		alerts = append(alerts, alert)
+	}
+	m.mode = mode
+
+	if m.config.AlertCR.IsPositive() {
+		breached := cr.LessThan(m.config.AlertCR)
+		if breached != m.breached {
+			alert := ProtocolAlert{Type: AlertCRBreach, Mode: mode, CR: cr, Threshold: m.config.AlertCR, At: now}
+			if !breached {
+				alert.Type = AlertCRRecovered
+			}
+			alerts = append(alerts, alert)
+		}
+		m.breached = breached
+	}
+
+	if m.config.PauseCR.IsPositive() {
+		paused := cr.LessThan(m.config.PauseCR)
+		if paused != m.paused {
+			alert := ProtocolAlert{Type: AlertCRCritical, Mode: mode, CR: cr, Threshold: m.config.PauseCR, At: now}
+			action := "pause"
+			if !paused {
+				alert.Type = AlertCRRecovered
+				action = "resume"
+			}
+			m.withAction(ctx, &alert, action, func() (*UnsignedTransaction, error) {
+				return m.builder.BuildUserActionsTransaction(ctx, UserActionsTxRequest{
+					Allowed: !paused,
+					Mode:    TxBuildModeExecution,
+				})
+			})
+			alerts = append(alerts, alert)
+		}
+		m.paused = paused
+	}
+
+	for _, alert := range alerts {
+		m.logger.Warnw("Protocol alert",
+			"type", alert.Type,
+			"mode", alert.Mode,
+			"cr", alert.CR.String(),
+			"action", alert.Action,
+		)
+		for _, alerter := range m.alerters {
+			if err := alerter.Alert(ctx, alert); err != nil {
+				m.logger.Errorw("Failed to deliver protocol alert", "type", alert.Type, "error", err)
+			}
+		}
+	}
+	return alerts, nil
+}
+
+// withAction attaches the transaction build returns to alert, unless the
+// monitor has no builder. Failed builds are logged and leave the alert
+// without a transaction.
+func (m *ProtocolMonitor) withAction(ctx context.Context, alert *ProtocolAlert, action string, build func() (*UnsignedTransaction, error)) {
+	if m.builder == nil {
+		return
+	}
+	tx, err := build()
+	if err != nil {
+		m.logger.Errorw("Failed to build protective action", "action", action, "error", err)
+		return
+	}
+	alert.Action = action
+	alert.Transaction = base64.StdEncoding.EncodeToString(tx.TransactionBlockBytes)
+}
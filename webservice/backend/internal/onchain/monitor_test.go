@@ -0,0 +1,96 @@
+package onchain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeStateReader struct {
+	cr decimal.Decimal
+}
+
+func (f *fakeStateReader) ProtocolState(context.Context) (*ProtocolState, error) {
+	return &ProtocolState{CR: f.cr}, nil
+}
+
+// fakeActionBuilder builds transactions whose bytes name the action
+type fakeActionBuilder struct{}
+
+func (fakeActionBuilder) BuildRebalanceTransaction(_ context.Context, req RebalanceTxRequest) (*UnsignedTransaction, error) {
+	return &UnsignedTransaction{TransactionBlockBytes: []byte("rebalance " + req.TargetCR.String())}, nil
+}
+
+func (fakeActionBuilder) BuildUserActionsTransaction(_ context.Context, req UserActionsTxRequest) (*UnsignedTransaction, error) {
+	if req.Allowed {
+		return &UnsignedTransaction{TransactionBlockBytes: []byte("resume")}, nil
+	}
+	return &UnsignedTransaction{TransactionBlockBytes: []byte("pause")}, nil
+}
+
+type recordingAlerter struct {
+	alerts []ProtocolAlert
+}
+
+func (r *recordingAlerter) Alert(_ context.Context, alert ProtocolAlert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func TestProtocolModeOf(t *testing.T) {
+	assert.Equal(t, ProtocolModeNormal, ProtocolModeOf(decimal.RequireFromString("1.306")))
+	assert.Equal(t, ProtocolModeStability, ProtocolModeOf(decimal.RequireFromString("1.3059")))
+	assert.Equal(t, ProtocolModeUserRebalance, ProtocolModeOf(decimal.RequireFromString("1.2")))
+	assert.Equal(t, ProtocolModeProtocolRebalance, ProtocolModeOf(decimal.RequireFromString("1.1")))
+}
+
+func TestProtocolMonitor(t *testing.T) {
+	ctx := context.Background()
+	chain := &fakeStateReader{cr: decimal.RequireFromString("1.5")}
+	alerter := &recordingAlerter{}
+	monitor := NewProtocolMonitor(chain, fakeActionBuilder{}, MonitorConfig{
+		AlertCR:           decimal.RequireFromString("1.4"),
+		PauseCR:           decimal.RequireFromString("1.2"),
+		RebalanceTargetCR: decimal.RequireFromString("1.306"),
+	}, zap.NewNop().Sugar(), alerter)
+	check := func(cr string) []ProtocolAlert {
+		chain.cr = decimal.RequireFromString(cr)
+		alerts, err := monitor.RunOnce(ctx, time.Now())
+		require.NoError(t, err)
+		return alerts
+	}
+	types := func(alerts []ProtocolAlert) []string {
+		var types []string
+		for _, alert := range alerts {
+			types = append(types, alert.Type)
+		}
+		return types
+	}
+
+	assert.Empty(t, check("1.5"), "a normal start is quiet")
+	assert.Equal(t, []string{AlertCRBreach}, types(check("1.35")))
+	assert.Empty(t, check("1.34"), "breaches alert once")
+
+	alerts := check("1.25")
+	require.Equal(t, []string{AlertModeChange}, types(alerts))
+	assert.Equal(t, ProtocolModeStability, alerts[0].Mode)
+	assert.Equal(t, ProtocolModeNormal, alerts[0].PreviousMode)
+
+	alerts = check("1.1")
+	require.Equal(t, []string{AlertModeChange, AlertCRCritical}, types(alerts))
+	assert.Equal(t, "rebalance", alerts[0].Action)
+	assert.Equal(t, "cmViYWxhbmNlIDEuMzA2", alerts[0].Transaction) // "rebalance 1.306"
+	assert.Equal(t, "pause", alerts[1].Action)
+
+	alerts = check("1.5")
+	require.Equal(t, []string{AlertModeChange, AlertCRRecovered, AlertCRRecovered}, types(alerts))
+	assert.True(t, alerts[1].Threshold.Equal(decimal.RequireFromString("1.4")))
+	assert.Equal(t, "resume", alerts[2].Action)
+
+	assert.Len(t, alerter.alerts, 7)
+}
@@ -0,0 +1,172 @@
+package onchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+)
+
+// DefaultObjectCacheTTL is how long cached object refs and coin metadata
+// are kept
+const DefaultObjectCacheTTL = time.Hour
+
+// ObjectCache keeps what transaction builds read of objects that do not
+// change in a kv store: the initial versions of shared objects and coin
+// metadata. Shared refs are dropped when a dry run finds them outdated.
+// A nil cache caches nothing.
+type ObjectCache struct {
+	store kv.Store
+	ttl   time.Duration
+}
+
+// NewObjectCache returns a cache keeping entries in store for ttl, or
+// DefaultObjectCacheTTL when ttl is not positive
+func NewObjectCache(store kv.Store, ttl time.Duration) *ObjectCache {
+	if ttl <= 0 {
+		ttl = DefaultObjectCacheTTL
+	}
+	return &ObjectCache{store: store, ttl: ttl}
+}
+
+// WithObjectCache lets the builder reuse shared object refs and coin
+// metadata across builds
+func WithObjectCache(cache *ObjectCache) TransactionBuilderOption {
+	return func(tb *TransactionBuilder) {
+		tb.objects = cache
+	}
+}
+
+func sharedObjectKey(id *sui.ObjectId) string {
+	return fmt.Sprintf("sui:object:shared:%s", id)
+}
+
+func coinMetadataKey(coinType string) string {
+	return fmt.Sprintf("sui:coin:metadata:%s", coinType)
+}
+
+// sharedVersion returns the cached initial shared version of id, if any
+func (c *ObjectCache) sharedVersion(ctx context.Context, id *sui.ObjectId) (sui.SequenceNumber, bool) {
+	if c == nil {
+		return 0, false
+	}
+	v, err := c.store.GetString(ctx, sharedObjectKey(id))
+	if err != nil {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+func (c *ObjectCache) setSharedVersion(ctx context.Context, id *sui.ObjectId, version sui.SequenceNumber) {
+	if c == nil {
+		return
+	}
+	_ = c.store.SetString(ctx, sharedObjectKey(id), strconv.FormatUint(version, 10), c.ttl)
+}
+
+// invalidate drops the cached refs of ids
+func (c *ObjectCache) invalidate(ctx context.Context, ids ...*sui.ObjectId) {
+	if c == nil {
+		return
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, sharedObjectKey(id))
+	}
+	_, _ = c.store.Del(ctx, keys...)
+}
+
+// coinMetadata returns the cached metadata of coinType, if any
+func (c *ObjectCache) coinMetadata(ctx context.Context, coinType string) (*suiclient.CoinMetadata, bool) {
+	if c == nil {
+		return nil, false
+	}
+	b, err := c.store.Get(ctx, coinMetadataKey(coinType))
+	if err != nil {
+		return nil, false
+	}
+	var metadata suiclient.CoinMetadata
+	if err := json.Unmarshal(b, &metadata); err != nil {
+		return nil, false
+	}
+	return &metadata, true
+}
+
+func (c *ObjectCache) setCoinMetadata(ctx context.Context, coinType string, metadata *suiclient.CoinMetadata) {
+	if c == nil {
+		return
+	}
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return
+	}
+	_ = c.store.Set(ctx, coinMetadataKey(coinType), b, c.ttl)
+}
+
+// sharedObjectRef returns the ref of the shared object id, at its initial
+// shared version
+func (tb *TransactionBuilder) sharedObjectRef(ctx context.Context, id *sui.ObjectId) (*sui.ObjectRef, error) {
+	if version, ok := tb.objects.sharedVersion(ctx, id); ok {
+		return &sui.ObjectRef{ObjectId: id, Version: version}, nil
+	}
+	res, err := tb.client.GetObject(ctx, &suiclient.GetObjectRequest{
+		ObjectId: id,
+		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Data == nil {
+		return nil, fmt.Errorf("object %s not found", id)
+	}
+	ref := res.Data.RefSharedObject()
+	tb.objects.setSharedVersion(ctx, id, ref.Version)
+	return ref, nil
+}
+
+// coinMetadata returns the metadata of coinType
+func (tb *TransactionBuilder) coinMetadata(ctx context.Context, coinType string) (*suiclient.CoinMetadata, error) {
+	if metadata, ok := tb.objects.coinMetadata(ctx, coinType); ok {
+		return metadata, nil
+	}
+	metadata, err := tb.client.GetCoinMetadata(ctx, coinType)
+	if err != nil {
+		return nil, err
+	}
+	tb.objects.setCoinMetadata(ctx, coinType, metadata)
+	return metadata, nil
+}
+
+// errDryRun returns the error of a dry run failing with msg, a
+// *MoveAbortError for aborts. Failures on outdated object versions drop
+// the cached shared refs, so that the next build reads them again.
+func (tb *TransactionBuilder) errDryRun(ctx context.Context, msg string) error {
+	if isObjectVersionMismatch(msg) {
+		tb.objects.invalidate(ctx, tb.protocolId, tb.poolId)
+	}
+	if abort := parseMoveAbort(msg); abort != nil {
+		return fmt.Errorf("dry run failed: %w", abort)
+	}
+	return fmt.Errorf("dry run failed: %s", msg)
+}
+
+// isObjectVersionMismatch reports whether msg is a Sui error on object
+// versions that do not match the ones on chain
+func isObjectVersionMismatch(msg string) bool {
+	for _, s := range []string{"SharedObjectStartingVersionMismatch", "ObjectVersionUnavailableForConsumption", "not available for consumption"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
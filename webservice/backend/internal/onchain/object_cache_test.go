@@ -0,0 +1,74 @@
+package onchain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRPC serves shared objects at version 7 and counts the reads
+type countingRPC struct {
+	SuiRPC
+	objectReads   int
+	metadataReads int
+}
+
+func (c *countingRPC) GetObject(_ context.Context, req *suiclient.GetObjectRequest) (*suiclient.SuiObjectResponse, error) {
+	c.objectReads++
+	version := sui.SequenceNumber(7)
+	owner := &suiclient.ObjectOwnerInternal{Shared: &struct {
+		InitialSharedVersion *sui.SequenceNumber `json:"initial_shared_version"`
+	}{InitialSharedVersion: &version}}
+	return &suiclient.SuiObjectResponse{Data: &suiclient.SuiObjectData{
+		ObjectId: req.ObjectId,
+		Owner:    &suiclient.ObjectOwner{ObjectOwnerInternal: owner},
+	}}, nil
+}
+
+func (c *countingRPC) GetCoinMetadata(context.Context, string) (*suiclient.CoinMetadata, error) {
+	c.metadataReads++
+	return &suiclient.CoinMetadata{Decimals: 9, Symbol: "FTOKEN"}, nil
+}
+
+func TestObjectCache(t *testing.T) {
+	ctx := context.Background()
+	rpc := &countingRPC{}
+	protocolId := sui.MustObjectIdFromHex("0x1d")
+	tb := &TransactionBuilder{client: rpc, protocolId: protocolId, poolId: sui.MustObjectIdFromHex("0x2d")}
+	WithObjectCache(NewObjectCache(memory.NewStore(), 0))(tb)
+
+	for i := 0; i < 3; i++ {
+		ref, err := tb.sharedObjectRef(ctx, protocolId)
+		require.NoError(t, err)
+		assert.Equal(t, sui.SequenceNumber(7), ref.Version)
+		assert.Equal(t, protocolId, ref.ObjectId)
+
+		metadata, err := tb.coinMetadata(ctx, "0x3::ftoken::FTOKEN")
+		require.NoError(t, err)
+		assert.Equal(t, uint8(9), metadata.Decimals)
+	}
+	assert.Equal(t, 1, rpc.objectReads)
+	assert.Equal(t, 1, rpc.metadataReads)
+
+	// Other dry run failures keep the refs
+	assert.Error(t, tb.errDryRun(ctx, "MoveAbort(..., 6)"))
+	_, err := tb.sharedObjectRef(ctx, protocolId)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rpc.objectReads)
+
+	assert.Error(t, tb.errDryRun(ctx, "SharedObjectStartingVersionMismatch"))
+	_, err = tb.sharedObjectRef(ctx, protocolId)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rpc.objectReads, "refs are read again after a version mismatch")
+
+	// Without a cache every build reads
+	tb.objects = nil
+	_, err = tb.coinMetadata(ctx, "0x3::ftoken::FTOKEN")
+	require.NoError(t, err)
+	assert.Equal(t, 2, rpc.metadataReads)
+}
@@ -0,0 +1,150 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrOracleStale is returned for feed prices older than the maximum age
+	ErrOracleStale = errors.New("oracle price is stale")
+	// ErrOracleUncertain is returned for feed prices whose confidence
+	// interval is too wide relative to the price
+	ErrOracleUncertain = errors.New("oracle price confidence too wide")
+)
+
+// Sources of oracle update prices
+const (
+	// OracleSourceMock takes the price from the operator
+	OracleSourceMock = "mock"
+	// OracleSourcePyth reads the price from a Pyth price feed object
+	OracleSourcePyth = "pyth"
+)
+
+// oraclePriceDecimals is the scale of protocol oracle prices
+const oraclePriceDecimals = 6
+
+// FeedPrice is a price read from an on-chain feed, scaled to 1e6 like
+// protocol oracle prices
+type FeedPrice struct {
+	Source       string
+	FeedObjectId *sui.ObjectId
+	PriceE6      uint64
+	ConfidenceE6 uint64
+	PublishTime  time.Time
+}
+
+// PriceFeed provides checked prices of the reserve for oracle updates
+type PriceFeed interface {
+	LatestPrice(ctx context.Context) (*FeedPrice, error)
+}
+
+// SuiObjectReader reads objects. *suiclient.ClientImpl and *RPCPool
+// implement it.
+type SuiObjectReader interface {
+	GetObject(ctx context.Context, req *suiclient.GetObjectRequest) (*suiclient.SuiObjectResponse, error)
+}
+
+// MovePythI64 is the signed integer of Pyth prices
+type MovePythI64 struct {
+	Negative  bool
+	Magnitude uint64
+}
+
+func (i MovePythI64) Int64() int64 {
+	if i.Negative {
+		return -int64(i.Magnitude)
+	}
+	return int64(i.Magnitude)
+}
+
+type MovePythPrice struct {
+	Price     MovePythI64
+	Conf      uint64
+	Expo      MovePythI64
+	Timestamp uint64 // Publish time, in seconds
+}
+
+// MovePythPriceInfoObject is the layout of a Pyth PriceInfoObject, with
+// its nested PriceInfo and PriceFeed flattened
+type MovePythPriceInfoObject struct {
+	Id              *sui.ObjectId
+	AttestationTime uint64
+	ArrivalTime     uint64
+	PriceIdentifier []byte
+	Price           MovePythPrice
+	EmaPrice        MovePythPrice
+}
+
+// PythPriceFeed reads a Pyth price feed object on Sui, the price info
+// object updated by Pyth's price service
+type PythPriceFeed struct {
+	reader           SuiObjectReader
+	objectId         *sui.ObjectId
+	maxAge           time.Duration
+	maxConfidenceBps uint64
+	now              func() time.Time
+}
+
+func NewPythPriceFeed(reader SuiObjectReader, objectId *sui.ObjectId, maxAge time.Duration, maxConfidenceBps uint64) *PythPriceFeed {
+	return &PythPriceFeed{
+		reader:           reader,
+		objectId:         objectId,
+		maxAge:           maxAge,
+		maxConfidenceBps: maxConfidenceBps,
+		now:              time.Now,
+	}
+}
+
+// LatestPrice returns the price of the feed, unless older than the maximum
+// age or less certain than the maximum confidence interval allows
+func (f *PythPriceFeed) LatestPrice(ctx context.Context) (*FeedPrice, error) {
+	res, err := f.reader.GetObject(ctx, &suiclient.GetObjectRequest{
+		ObjectId: f.objectId,
+		Options:  &suiclient.SuiObjectDataOptions{ShowBcs: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price feed object: %w", err)
+	}
+	if res.Data == nil || res.Data.Bcs == nil || res.Data.Bcs.Data.MoveObject == nil {
+		return nil, fmt.Errorf("price feed object %s not found", f.objectId)
+	}
+	var obj MovePythPriceInfoObject
+	if _, err := bcs.Unmarshal(res.Data.Bcs.Data.MoveObject.BcsBytes, &obj); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal to MovePythPriceInfoObject: %w", err)
+	}
+
+	price := obj.Price
+	if price.Price.Negative || price.Price.Magnitude == 0 {
+		return nil, fmt.Errorf("invalid feed price %d", price.Price.Int64())
+	}
+	published := time.Unix(int64(price.Timestamp), 0).UTC()
+	if age := f.now().Sub(published); age > f.maxAge {
+		return nil, fmt.Errorf("%w: published %s ago, maximum %s", ErrOracleStale, age.Round(time.Second), f.maxAge)
+	}
+	if new(big.Int).Mul(new(big.Int).SetUint64(price.Conf), big.NewInt(10_000)).Cmp(
+		new(big.Int).Mul(new(big.Int).SetUint64(price.Price.Magnitude), new(big.Int).SetUint64(f.maxConfidenceBps))) > 0 {
+		return nil, fmt.Errorf("%w: %d around %d, maximum %d bps", ErrOracleUncertain, price.Conf, price.Price.Magnitude, f.maxConfidenceBps)
+	}
+
+	exp := int32(price.Expo.Int64()) + oraclePriceDecimals
+	priceE6 := decimal.NewFromBigInt(new(big.Int).SetUint64(price.Price.Magnitude), exp).BigInt()
+	if !priceE6.IsUint64() || priceE6.Sign() == 0 {
+		return nil, fmt.Errorf("feed price %de%d out of range", price.Price.Magnitude, price.Expo.Int64())
+	}
+	return &FeedPrice{
+		Source:       OracleSourcePyth,
+		FeedObjectId: f.objectId,
+		PriceE6:      priceE6.Uint64(),
+		ConfidenceE6: decimal.NewFromBigInt(new(big.Int).SetUint64(price.Conf), exp).BigInt().Uint64(),
+		PublishTime:  published,
+	}, nil
+}
@@ -0,0 +1,70 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObjectReader serves obj as the BCS bytes of any object
+type fakeObjectReader struct {
+	obj MovePythPriceInfoObject
+}
+
+func (f *fakeObjectReader) GetObject(_ context.Context, req *suiclient.GetObjectRequest) (*suiclient.SuiObjectResponse, error) {
+	bcsBytes, err := bcs.Marshal(f.obj)
+	if err != nil {
+		return nil, err
+	}
+	return &suiclient.SuiObjectResponse{Data: &suiclient.SuiObjectData{
+		ObjectId: req.ObjectId,
+		Bcs: &suiclient.WrapperTaggedJson[suiclient.SuiRawData]{Data: suiclient.SuiRawData{
+			MoveObject: &suiclient.SuiRawMoveObject{BcsBytes: bcsBytes},
+		}},
+	}}, nil
+}
+
+func TestPythPriceFeed(t *testing.T) {
+	feedId := sui.MustObjectIdFromHex("0x50c")
+	now := time.Unix(1_700_000_000, 0)
+	// $3.41234567 ± $0.01, in the usual exponent of -8
+	reader := &fakeObjectReader{obj: MovePythPriceInfoObject{
+		Id:              feedId,
+		PriceIdentifier: make([]byte, 32),
+		Price: MovePythPrice{
+			Price:     MovePythI64{Magnitude: 341_234_567},
+			Conf:      1_000_000,
+			Expo:      MovePythI64{Negative: true, Magnitude: 8},
+			Timestamp: uint64(now.Unix() - 10),
+		},
+	}}
+	feed := NewPythPriceFeed(reader, feedId, time.Minute, 100)
+	feed.now = func() time.Time { return now }
+
+	price, err := feed.LatestPrice(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3_412_345), price.PriceE6)
+	assert.Equal(t, uint64(10_000), price.ConfidenceE6)
+	assert.Equal(t, now.Add(-10*time.Second).UTC(), price.PublishTime)
+	assert.Equal(t, OracleSourcePyth, price.Source)
+
+	feed.now = func() time.Time { return now.Add(time.Minute) }
+	_, err = feed.LatestPrice(context.Background())
+	assert.True(t, errors.Is(err, ErrOracleStale), "expected ErrOracleStale, got %v", err)
+
+	feed.now = func() time.Time { return now }
+	reader.obj.Price.Conf = 5_000_000
+	_, err = feed.LatestPrice(context.Background())
+	assert.True(t, errors.Is(err, ErrOracleUncertain), "expected ErrOracleUncertain, got %v", err)
+
+	reader.obj.Price.Price.Negative = true
+	_, err = feed.LatestPrice(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,286 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/shopspring/decimal"
+)
+
+// portfolioPageSize is the number of events read per query of a portfolio
+const portfolioPageSize = 500
+
+var (
+	// tokenScale is the scale of the base units of the reserve, fTokens and
+	// xTokens
+	tokenScale = decimal.New(1, SuiDecimal)
+	// reservePriceScale is the scale of the reserve price the protocol
+	// takes from its oracle, in USD
+	reservePriceScale = decimal.New(1, 6)
+)
+
+// WithSampleStore values portfolios at the prices of the protocol samples
+// of a samples repository, as described by entities.ProtocolSampleSchema.
+// Without it, events and history are valued at current prices.
+func WithSampleStore(samples interfaces.Repository) UserServiceOption {
+	return func(s *UserService) {
+		s.samples = samples
+	}
+}
+
+// reservePriceUSD returns the reserve price of state, in USD
+func reservePriceUSD(state *ProtocolState) decimal.Decimal {
+	return decimal.NewFromBigInt(new(big.Int).SetUint64(state.P), 0).Div(reservePriceScale)
+}
+
+// tokenPrices are the USD prices of one whole reserve token, fToken and
+// xToken
+type tokenPrices struct {
+	R, F, X decimal.Decimal
+}
+
+// pricesOf prices fTokens at their $1 peg and xTokens at their share of the
+// reserve value left after fTokens, as update_px of the leafsii module does.
+// Reserves and supplies are in base units, which all tokens share.
+func pricesOf(reservePrice, reservesR, supplyF, supplyX decimal.Decimal) tokenPrices {
+	prices := tokenPrices{R: reservePrice, F: decimal.NewFromInt(1)}
+	if supplyX.IsPositive() {
+		leveraged := reservesR.Mul(reservePrice).Sub(supplyF)
+		if leveraged.IsPositive() {
+			prices.X = leveraged.Div(supplyX)
+		}
+	}
+	return prices
+}
+
+func (p tokenPrices) of(token string) decimal.Decimal {
+	switch token {
+	case "xToken":
+		return p.X
+	case "Sui": // The reserve, as the indexer names it
+		return p.R
+	}
+	return p.F
+}
+
+// TokenPosition is what an address holds of a token by its mints and
+// redeems, at average cost. Amounts are in base units, values in USD.
+type TokenPosition struct {
+	Token         string
+	Quantity      decimal.Decimal // Minted less redeemed
+	CostBasis     decimal.Decimal
+	Price         decimal.Decimal // Of one whole token
+	Value         decimal.Decimal
+	RealizedPnL   decimal.Decimal
+	UnrealizedPnL decimal.Decimal
+}
+
+// PortfolioPoint is the value and cost basis of a portfolio at Time
+type PortfolioPoint struct {
+	Time      time.Time
+	Value     decimal.Decimal
+	CostBasis decimal.Decimal
+}
+
+// Portfolio is the P&L of an address from the protocol events it sent.
+// Tokens received or sent by transfer are not tracked: redeeming more than
+// was minted realizes no P&L on the excess.
+type Portfolio struct {
+	Address       string
+	Positions     []TokenPosition // fToken, then xToken
+	CostBasis     decimal.Decimal
+	Value         decimal.Decimal
+	RealizedPnL   decimal.Decimal
+	UnrealizedPnL decimal.Decimal
+	History       []PortfolioPoint // Value at the end of each interval of the range
+	AsOf          time.Time
+}
+
+// ledger replays mints and redeems into positions at average cost
+type ledger map[string]*TokenPosition
+
+func (l ledger) position(token string) *TokenPosition {
+	p, ok := l[token]
+	if !ok {
+		p = &TokenPosition{Token: token}
+		l[token] = p
+	}
+	return p
+}
+
+// apply books event, whose reserve flow is valued at reservePrice
+func (l ledger) apply(event Event, reservePrice decimal.Decimal) {
+	if event.Token != "fToken" && event.Token != "xToken" {
+		return
+	}
+	amount, err := decimal.NewFromString(event.Amount)
+	if err != nil || !amount.IsPositive() {
+		return
+	}
+	p := l.position(event.Token)
+	switch event.Type {
+	case EventTypeMint:
+		p.Quantity = p.Quantity.Add(amount)
+		p.CostBasis = p.CostBasis.Add(reserveValue(event.Fields["reserve_in"], reservePrice))
+	case EventTypeRedeem:
+		proceeds := reserveValue(event.Fields["reserve_out"], reservePrice)
+		held := decimal.Min(amount, p.Quantity)
+		if !held.IsPositive() {
+			return
+		}
+		basis := p.CostBasis.Mul(held).Div(p.Quantity)
+		p.RealizedPnL = p.RealizedPnL.Add(proceeds.Mul(held).Div(amount)).Sub(basis)
+		p.CostBasis = p.CostBasis.Sub(basis)
+		p.Quantity = p.Quantity.Sub(held)
+	}
+}
+
+// value returns the value of the positions and their cost basis at prices
+func (l ledger) value(prices tokenPrices) (decimal.Decimal, decimal.Decimal) {
+	value, basis := decimal.Zero, decimal.Zero
+	for token, p := range l {
+		value = value.Add(p.Quantity.Div(tokenScale).Mul(prices.of(token)))
+		basis = basis.Add(p.CostBasis)
+	}
+	return value, basis
+}
+
+// reserveValue values a reserve amount of an event field, a u64 string,
+// in USD
+func reserveValue(field interface{}, reservePrice decimal.Decimal) decimal.Decimal {
+	s, _ := field.(string)
+	amount, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return amount.Div(tokenScale).Mul(reservePrice)
+}
+
+// GetPortfolio returns the positions and P&L of address from its indexed
+// mints and redeems, valued at current prices, and the portfolio value
+// at the end of each interval from from to to
+func (s *UserService) GetPortfolio(ctx context.Context, address string, interval time.Duration, from, to time.Time) (*Portfolio, error) {
+	if interval <= 0 || !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRange)
+	}
+	if to.Sub(from)/interval > maxHistoryPoints {
+		return nil, fmt.Errorf("%w: more than %d points, use a longer interval", ErrInvalidRange, maxHistoryPoints)
+	}
+
+	state, err := s.chain.ProtocolState(ctx)
+	if err != nil {
+		s.logger.Errorw("Failed to fetch protocol state for portfolio", "address", address, "error", err)
+		return nil, fmt.Errorf("failed to fetch protocol state: %w", err)
+	}
+	current := pricesOf(reservePriceUSD(state), state.ReservesR, state.SupplyF, state.SupplyX)
+
+	events, err := s.portfolioEvents(ctx, address)
+	if err != nil {
+		s.logger.Errorw("Failed to list portfolio events", "address", address, "error", err)
+		return nil, fmt.Errorf("failed to list portfolio events: %w", err)
+	}
+
+	positions := ledger{}
+	var history []PortfolioPoint
+	next := 0
+	for t := from.Truncate(interval).Add(interval); ; t = t.Add(interval) {
+		if t.After(to) {
+			t = to
+		}
+		for ; next < len(events) && !events[next].Timestamp.After(t); next++ {
+			prices, err := s.pricesAt(ctx, events[next].Timestamp, current)
+			if err != nil {
+				return nil, err
+			}
+			positions.apply(events[next], prices.R)
+		}
+		prices, err := s.pricesAt(ctx, t, current)
+		if err != nil {
+			return nil, err
+		}
+		value, basis := positions.value(prices)
+		history = append(history, PortfolioPoint{Time: t, Value: value, CostBasis: basis})
+		if !t.Before(to) {
+			break
+		}
+	}
+	// Events after the range count towards the current positions
+	for ; next < len(events); next++ {
+		prices, err := s.pricesAt(ctx, events[next].Timestamp, current)
+		if err != nil {
+			return nil, err
+		}
+		positions.apply(events[next], prices.R)
+	}
+
+	portfolio := &Portfolio{Address: address, History: history, AsOf: time.Now()}
+	for _, token := range []string{"fToken", "xToken"} {
+		p := positions.position(token)
+		p.Price = current.of(token)
+		p.Value = p.Quantity.Div(tokenScale).Mul(p.Price)
+		p.UnrealizedPnL = p.Value.Sub(p.CostBasis)
+		portfolio.Positions = append(portfolio.Positions, *p)
+		portfolio.CostBasis = portfolio.CostBasis.Add(p.CostBasis)
+		portfolio.Value = portfolio.Value.Add(p.Value)
+		portfolio.RealizedPnL = portfolio.RealizedPnL.Add(p.RealizedPnL)
+		portfolio.UnrealizedPnL = portfolio.UnrealizedPnL.Add(p.UnrealizedPnL)
+	}
+	return portfolio, nil
+}
+
+// portfolioEvents lists the events of address, oldest first
+func (s *UserService) portfolioEvents(ctx context.Context, address string) ([]Event, error) {
+	if s.events == nil {
+		return nil, nil
+	}
+	var events []Event
+	cursor := ""
+	for {
+		page, next, err := listEvents(ctx, s.events, []interfaces.Filter{{Field: "sender", Value: address}}, portfolioPageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// pricesAt returns the prices of the latest protocol sample at or before
+// t, or current when there is none
+func (s *UserService) pricesAt(ctx context.Context, t time.Time, current tokenPrices) (tokenPrices, error) {
+	if s.samples == nil {
+		return current, nil
+	}
+	record, err := s.samples.FindOne(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: []interfaces.Filter{{Field: "timestamp", Operator: &interfaces.FilterOperator{Lte: t}}},
+		},
+		OrderBy: []interfaces.OrderBy{{Field: "timestamp", Direction: "desc"}},
+	})
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return current, nil
+	}
+	if err != nil {
+		return tokenPrices{}, fmt.Errorf("failed to read protocol sample: %w", err)
+	}
+	field := func(name string) decimal.Decimal {
+		v, _ := toFloat(record[name])
+		return decimal.NewFromFloat(v)
+	}
+	price := field("reserve_price")
+	if !price.IsPositive() {
+		return current, nil
+	}
+	return pricesOf(price, field("reserves_r"), field("supply_f"), field("supply_x")), nil
+}
@@ -0,0 +1,200 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fixedState serves a fixed protocol state; its other reads are not
+// implemented
+type fixedState struct {
+	ChainReader
+	state ProtocolState
+}
+
+func (f *fixedState) ProtocolState(context.Context) (*ProtocolState, error) {
+	return &f.state, nil
+}
+
+func TestGetPortfolio(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+
+	events := database.Repository(entities.EventSchema)
+	samples := database.Repository(entities.ProtocolSampleSchema)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	addEvent := func(minutes int, eventType, token, amount string, fields map[string]interface{}) {
+		t.Helper()
+		_, err := events.Create(ctx, map[string]interface{}{
+			"checkpoint":      int64(minutes),
+			"sequence_number": int64(0),
+			"timestamp":       start.Add(time.Duration(minutes) * time.Minute),
+			"type":            eventType,
+			"tx_digest":       fmt.Sprintf("digest-%d", minutes),
+			"sender":          "0xuser",
+			"token":           token,
+			"amount":          amount,
+			"fields":          fields,
+		})
+		require.NoError(t, err)
+	}
+	addSample := func(hours int, price float64) {
+		t.Helper()
+		_, err := samples.Create(ctx, map[string]interface{}{
+			"timestamp":     start.Add(time.Duration(hours) * time.Hour),
+			"cr":            2.0,
+			"reserves_r":    10e9,
+			"supply_f":      10e9,
+			"supply_x":      5e9,
+			"peg_deviation": 0.0,
+			"reserve_price": price,
+		})
+		require.NoError(t, err)
+	}
+	addSample(0, 2)
+	addSample(1, 3)
+	addSample(2, 2.5)
+
+	// $2 in for 2 fTokens, $3 in for 1 xToken, then 1 fToken out for $1.25
+	addEvent(10, EventTypeMint, "fToken", "2000000000", map[string]interface{}{"reserve_in": "1000000000"})
+	addEvent(70, EventTypeMint, "xToken", "1000000000", map[string]interface{}{"reserve_in": "1000000000"})
+	addEvent(130, EventTypeRedeem, "fToken", "1000000000", map[string]interface{}{"reserve_out": "500000000"})
+	// Not a position of the portfolio
+	addEvent(140, EventTypeStake, "fToken", "1000000000", nil)
+
+	// Reserves at $2 back 10 fTokens and 5 xTokens at $2
+	chain := &fixedState{state: ProtocolState{
+		P:         2_000_000,
+		ReservesR: decimal.NewFromInt(10e9),
+		SupplyF:   decimal.NewFromInt(10e9),
+		SupplyX:   decimal.NewFromInt(5e9),
+	}}
+	svc := NewUserService(chain, nil, zap.NewNop().Sugar(), WithEventStore(events), WithSampleStore(samples))
+
+	portfolio, err := svc.GetPortfolio(ctx, "0xuser", time.Hour, start, start.Add(3*time.Hour))
+	require.NoError(t, err)
+	usd := func(d decimal.Decimal) string { return d.StringFixed(2) }
+
+	require.Len(t, portfolio.Positions, 2)
+	f, x := portfolio.Positions[0], portfolio.Positions[1]
+	assert.Equal(t, "fToken", f.Token)
+	assert.Equal(t, "1000000000", f.Quantity.String())
+	assert.Equal(t, "1.00", usd(f.CostBasis))
+	assert.Equal(t, "1.00", usd(f.Value))
+	assert.Equal(t, "0.25", usd(f.RealizedPnL))
+	assert.Equal(t, "0.00", usd(f.UnrealizedPnL))
+	assert.Equal(t, "xToken", x.Token)
+	assert.Equal(t, "2.00", usd(x.Price))
+	assert.Equal(t, "3.00", usd(x.CostBasis))
+	assert.Equal(t, "-1.00", usd(x.UnrealizedPnL))
+	assert.Equal(t, "4.00", usd(portfolio.CostBasis))
+	assert.Equal(t, "3.00", usd(portfolio.Value))
+	assert.Equal(t, "0.25", usd(portfolio.RealizedPnL))
+	assert.Equal(t, "-1.00", usd(portfolio.UnrealizedPnL))
+
+	// xTokens are worth $3 at the $2.50 of the last sample
+	require.Len(t, portfolio.History, 3)
+	var values, bases []string
+	for i, point := range portfolio.History {
+		assert.Equal(t, start.Add(time.Duration(i+1)*time.Hour), point.Time)
+		values = append(values, usd(point.Value))
+		bases = append(bases, usd(point.CostBasis))
+	}
+	assert.Equal(t, []string{"2.00", "5.00", "4.00"}, values)
+	assert.Equal(t, []string{"2.00", "5.00", "4.00"}, bases)
+
+	// Redeeming tokens received by transfer realizes no P&L
+	addEvent(150, EventTypeRedeem, "xToken", "3000000000", map[string]interface{}{"reserve_out": "3000000000"})
+	portfolio, err = svc.GetPortfolio(ctx, "0xuser", time.Hour, start, start.Add(3*time.Hour))
+	require.NoError(t, err)
+	x = portfolio.Positions[1]
+	assert.True(t, x.Quantity.IsZero())
+	assert.Equal(t, "0.00", usd(x.CostBasis))
+	assert.Equal(t, "-0.50", usd(x.RealizedPnL))
+
+	_, err = svc.GetPortfolio(ctx, "0xuser", time.Minute, start, start.Add(30*24*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestExportTransactions(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	defer database.Disconnect(ctx)
+
+	events := database.Repository(entities.EventSchema)
+	samples := database.Repository(entities.ProtocolSampleSchema)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < exportPageSize+2; i++ {
+		eventType, token := EventTypeMint, "xToken"
+		if i%2 == 1 {
+			eventType, token = EventTypeStake, "fToken"
+		}
+		_, err := events.Create(ctx, map[string]interface{}{
+			"checkpoint":      int64(i),
+			"sequence_number": int64(0),
+			"timestamp":       start.Add(time.Duration(i) * time.Minute),
+			"type":            eventType,
+			"tx_digest":       fmt.Sprintf("digest-%d", i),
+			"sender":          "0xuser",
+			"token":           token,
+			"amount":          "2000000000",
+		})
+		require.NoError(t, err)
+	}
+	// Events before the first sample are not valued
+	_, err := samples.Create(ctx, map[string]interface{}{
+		"timestamp":     start.Add(time.Minute),
+		"cr":            2.0,
+		"reserves_r":    10e9,
+		"supply_f":      10e9,
+		"supply_x":      5e9,
+		"peg_deviation": 0.0,
+		"reserve_price": 2.0,
+	})
+	require.NoError(t, err)
+
+	svc := NewUserService(nil, nil, zap.NewNop().Sugar(), WithEventStore(events), WithSampleStore(samples))
+	var exported []ValuedEvent
+	require.NoError(t, svc.ExportTransactions(ctx, "0xuser", TransactionFilter{}, func(e ValuedEvent) error {
+		exported = append(exported, e)
+		return nil
+	}))
+	require.Len(t, exported, exportPageSize+2)
+	assert.Equal(t, fmt.Sprintf("digest-%d", exportPageSize+1), exported[0].TxDigest)
+	last := exported[len(exported)-1]
+	assert.Equal(t, "digest-0", last.TxDigest)
+	assert.False(t, last.Valued)
+
+	// $20 of reserves back 10 fTokens, leaving $2 per xToken
+	second := exported[len(exported)-2]
+	require.True(t, second.Valued)
+	assert.Equal(t, "1", second.TokenPrice.String())
+	assert.Equal(t, "2", second.ReservePrice.String())
+	assert.Equal(t, "2", second.Value.String())
+	mint := exported[len(exported)-3]
+	assert.Equal(t, "4", mint.Value.String())
+
+	// Filters narrow the export, and errors of fn stop it
+	var stakes int
+	require.NoError(t, svc.ExportTransactions(ctx, "0xuser", TransactionFilter{Type: EventTypeStake, To: start.Add(10 * time.Minute)}, func(e ValuedEvent) error {
+		assert.Equal(t, EventTypeStake, e.Type)
+		stakes++
+		return nil
+	}))
+	assert.Equal(t, 5, stakes)
+	stop := fmt.Errorf("stop")
+	assert.ErrorIs(t, svc.ExportTransactions(ctx, "0xuser", TransactionFilter{}, func(ValuedEvent) error { return stop }), stop)
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/leafsii/leafsii-backend/internal/calc"
 	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
 	"github.com/leafsii/leafsii-backend/internal/store"
 	"github.com/leafsii/leafsii-backend/internal/util"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
@@ -20,7 +24,21 @@ type ProtocolService struct {
 	cache  *store.Cache
 	config *config.Config
 	logger *zap.SugaredLogger
-	sf     *util.Group // singleflight to dedupe expensive calls
+	sf     *util.Group           // singleflight to dedupe expensive calls
+	events interfaces.Repository // Indexed events; nil lists no history
+
+	samples interfaces.Repository // Protocol samples; nil serves no history
+}
+
+// ProtocolServiceOption configures a ProtocolService
+type ProtocolServiceOption func(*ProtocolService)
+
+// WithProtocolEventStore lists the protocol history from an events
+// repository, as described by entities.EventSchema
+func WithProtocolEventStore(events interfaces.Repository) ProtocolServiceOption {
+	return func(s *ProtocolService) {
+		s.events = events
+	}
 }
 
 type ProtocolHealth struct {
@@ -32,14 +50,38 @@ func NewProtocolService(
 	cache *store.Cache,
 	config *config.Config,
 	logger *zap.SugaredLogger,
+	opts ...ProtocolServiceOption,
 ) *ProtocolService {
-	return &ProtocolService{
+	s := &ProtocolService{
 		chain:  chain,
 		cache:  cache,
 		config: config,
 		logger: logger,
 		sf:     &util.Group{},
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// GetEvents lists the indexed protocol events, newest first, of eventType
+// or of every type when it is empty. Cursors page as in
+// UserService.GetTransactions.
+func (s *ProtocolService) GetEvents(ctx context.Context, eventType string, limit int, cursor string) ([]Event, string, error) {
+	if s.events == nil {
+		return []Event{}, "", nil
+	}
+	var conditions []interfaces.Filter
+	if eventType != "" {
+		conditions = append(conditions, interfaces.Filter{Field: "type", Value: eventType})
+	}
+	events, next, err := listEvents(ctx, s.events, conditions, limit, cursor)
+	if err != nil && !errors.Is(err, ErrInvalidCursor) {
+		s.logger.Errorw("Failed to list protocol events", "type", eventType, "error", err)
+		return nil, "", fmt.Errorf("failed to list protocol events: %w", err)
+	}
+	return events, next, err
 }
 
 func (s *ProtocolService) GetState(ctx context.Context) (*ProtocolState, error) {
@@ -124,42 +166,49 @@ type QuoteService struct {
 	config   *config.Config
 	logger   *zap.SugaredLogger
 	sf       *util.Group
+
+	defaultFees FeeSchedule
+	fees        kv.Store // Changes to the fee schedule and their audit trail
 }
 
 type MintQuote struct {
-	FOut    decimal.Decimal
-	Fee     decimal.Decimal
-	PostCR  decimal.Decimal
-	TTLSec  int
-	QuoteID string
-	AsOf    time.Time
+	AmountIn decimal.Decimal // As requested
+	FOut     decimal.Decimal
+	Fee      decimal.Decimal
+	PostCR   decimal.Decimal
+	TTLSec   int
+	QuoteID  string
+	AsOf     time.Time
 }
 
 type RedeemQuote struct {
-	ROut    decimal.Decimal
-	Fee     decimal.Decimal
-	PostCR  decimal.Decimal
-	TTLSec  int
-	QuoteID string
-	AsOf    time.Time
+	AmountIn decimal.Decimal // As requested
+	ROut     decimal.Decimal
+	Fee      decimal.Decimal
+	PostCR   decimal.Decimal
+	TTLSec   int
+	QuoteID  string
+	AsOf     time.Time
 }
 
 type MintXQuote struct {
-	XOut    decimal.Decimal
-	Fee     decimal.Decimal
-	PostCR  decimal.Decimal
-	TTLSec  int
-	QuoteID string
-	AsOf    time.Time
+	AmountIn decimal.Decimal // As requested
+	XOut     decimal.Decimal
+	Fee      decimal.Decimal
+	PostCR   decimal.Decimal
+	TTLSec   int
+	QuoteID  string
+	AsOf     time.Time
 }
 
 type RedeemXQuote struct {
-	ROut    decimal.Decimal
-	Fee     decimal.Decimal
-	PostCR  decimal.Decimal
-	TTLSec  int
-	QuoteID string
-	AsOf    time.Time
+	AmountIn decimal.Decimal // As requested
+	ROut     decimal.Decimal
+	Fee      decimal.Decimal
+	PostCR   decimal.Decimal
+	TTLSec   int
+	QuoteID  string
+	AsOf     time.Time
 }
 
 func NewQuoteService(
@@ -168,15 +217,24 @@ func NewQuoteService(
 	protocol *ProtocolService,
 	config *config.Config,
 	logger *zap.SugaredLogger,
+	opts ...QuoteServiceOption,
 ) *QuoteService {
-	return &QuoteService{
-		chain:    chain,
-		cache:    cache,
-		protocol: protocol,
-		config:   config,
-		logger:   logger,
-		sf:       &util.Group{},
-	}
+	s := &QuoteService{
+		chain:       chain,
+		cache:       cache,
+		protocol:    protocol,
+		config:      config,
+		logger:      logger,
+		sf:          &util.Group{},
+		defaultFees: DefaultFeeSchedule(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.fees == nil {
+		s.fees = memkv.NewStore()
+	}
+	return s
 }
 
 // fetchAndValidateOraclePrices fetches oracle prices for both tokens and validates freshness
@@ -217,9 +275,6 @@ func (s *QuoteService) fetchAndValidateOraclePrices(ctx context.Context) (rPrice
 }
 
 func (s *QuoteService) GetMintQuote(ctx context.Context, amountR decimal.Decimal) (*MintQuote, error) {
-	// Get protocol state
-	amountR = amountR.Mul(decimal.NewFromFloat(1000_000_000))
-
 	state, err := s.protocol.GetState(ctx)
 	if err != nil {
 		return nil, err
@@ -231,42 +286,23 @@ func (s *QuoteService) GetMintQuote(ctx context.Context, amountR decimal.Decimal
 		return nil, err
 	}
 
-	// Calculate cross-token exchange rate: rateRtoF = pR / pF (amount of f per 1 r)
-	rateRtoF := pR.Div(pF)
-
-	// Calculate mint quote with oracle-based pricing
-	feeRateF := decimal.NewFromFloat(0.003) // 0.3% fee in fToken units
-
-	// grossF = amountR * rateRtoF (gross fToken before fee)
-	grossF := amountR.Mul(rateRtoF)
-
-	// feeF = grossF * feeRateF (fee in fToken units)
-	feeF := grossF.Mul(feeRateF)
-
-	// fOut = grossF - feeF (net fToken output)
-	fOut := grossF.Sub(feeF)
-
-	// Calculate post-transaction CR using new reserves and supply
-	// newReservesR = current reserves + full amountR input (fees are in fToken units)
-	newReservesR := state.ReservesR.Add(amountR)
-	// newSupplyF = current supply + net fToken output
-	newSupplyF := state.SupplyF.Add(fOut)
-
-	postCR := calc.CollateralRatio(newReservesR.Mul(decimal.NewFromInt(int64(state.P))), newSupplyF.Mul(decimal.NewFromInt(int64(state.Pf))))
-
-	// Validate CR constraint (assuming 110% minimum)
-	minCR := decimal.NewFromFloat(1.1)
-	if err := calc.ValidateCRConstraint(postCR, minCR); err != nil {
-		return nil, fmt.Errorf("mintF would breach CR constraint: %w", err)
+	fees, err := s.FeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	outcome, err := quoteMintF(state, fees, pR, pF, amountR)
+	if err != nil {
+		return nil, err
 	}
 
 	quote := &MintQuote{
-		FOut:    fOut.Div(decimal.NewFromInt(1000_000_000)),
-		Fee:     feeF,
-		PostCR:  postCR,
-		TTLSec:  30, // 30 second TTL for quotes
-		QuoteID: generateQuoteID(),
-		AsOf:    time.Now(),
+		AmountIn: amountR,
+		FOut:     outcome.Out,
+		Fee:      outcome.Fee,
+		PostCR:   outcome.State.CR,
+		TTLSec:   30, // 30 second TTL for quotes
+		QuoteID:  generateQuoteID(),
+		AsOf:     time.Now(),
 	}
 
 	// Cache the quote for the TTL period
@@ -295,42 +331,23 @@ func (s *QuoteService) GetRedeemQuote(ctx context.Context, amountF decimal.Decim
 		return nil, err
 	}
 
-	// Calculate cross-token exchange rate: rateFtoR = pF / pR (amount of r per 1 f)
-	rateFtoR := pF.Div(pR)
-
-	// Calculate redeem quote with oracle-based pricing
-	feeRateR := decimal.NewFromFloat(0.005) // 0.5% fee in Sui units
-
-	// grossR = amountF * rateFtoR (gross Sui before fee)
-	grossR := amountF.Mul(rateFtoR)
-
-	// feeR = grossR * feeRateR (fee in Sui units)
-	feeR := grossR.Mul(feeRateR)
-
-	// rOut = grossR - feeR (net Sui output)
-	rOut := grossR.Sub(feeR)
-
-	// Calculate post-transaction CR using new reserves and supply
-	// newReservesR = current reserves - net Sui output
-	newReservesR := state.ReservesR.Sub(rOut)
-	// newSupplyF = current supply - redeemed fToken amount
-	newSupplyF := state.SupplyF.Sub(amountF)
-
-	postCR := calc.CollateralRatio(newReservesR.Mul(decimal.NewFromInt(int64(state.P))), newSupplyF.Mul(decimal.NewFromInt(int64(state.Pf))))
-
-	// Validate CR constraint
-	minCR := decimal.NewFromFloat(1.1)
-	if err := calc.ValidateCRConstraint(postCR, minCR); err != nil {
-		return nil, fmt.Errorf("redeem would breach CR constraint: %w", err)
+	fees, err := s.FeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	outcome, err := quoteRedeemF(state, fees, pR, pF, amountF)
+	if err != nil {
+		return nil, err
 	}
 
 	quote := &RedeemQuote{
-		ROut:    rOut,
-		Fee:     feeR,
-		PostCR:  postCR,
-		TTLSec:  30, // 30 second TTL for quotes
-		QuoteID: generateQuoteID(),
-		AsOf:    time.Now(),
+		AmountIn: amountF,
+		ROut:     outcome.Out,
+		Fee:      outcome.Fee,
+		PostCR:   outcome.State.CR,
+		TTLSec:   30, // 30 second TTL for quotes
+		QuoteID:  generateQuoteID(),
+		AsOf:     time.Now(),
 	}
 
 	// Cache the quote for the TTL period
@@ -352,25 +369,23 @@ func (s *QuoteService) GetMintXQuote(ctx context.Context, amountR decimal.Decima
 		return nil, fmt.Errorf("oracle data too stale: %ds > %s", state.OracleAgeSec, s.config.Oracle.MaxAge)
 	}
 
-	// Calculate mint X quote with higher fees
-	feeRate := decimal.NewFromFloat(0.02)           // 2% fee for xToken
-	xOut := amountR.Mul(decimal.NewFromFloat(0.98)) // 98% conversion rate
-	fee := amountR.Mul(feeRate)
-	postCR := calc.PostMintCR(state.ReservesR, state.SupplyX, amountR) // Use SupplyX for xToken
-
-	// Validate CR constraint (assuming 110% minimum)
-	minCR := decimal.NewFromFloat(1.1)
-	if err := calc.ValidateCRConstraint(postCR, minCR); err != nil {
-		return nil, fmt.Errorf("mintX would breach CR constraint: %w", err)
+	fees, err := s.FeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	outcome, err := quoteMintX(state, fees, amountR)
+	if err != nil {
+		return nil, err
 	}
 
 	quote := &MintXQuote{
-		XOut:    xOut,
-		Fee:     fee,
-		PostCR:  postCR,
-		TTLSec:  30, // 30 second TTL for quotes
-		QuoteID: generateQuoteID(),
-		AsOf:    time.Now(),
+		AmountIn: amountR,
+		XOut:     outcome.Out,
+		Fee:      outcome.Fee,
+		PostCR:   outcome.State.CR,
+		TTLSec:   30, // 30 second TTL for quotes
+		QuoteID:  generateQuoteID(),
+		AsOf:     time.Now(),
 	}
 
 	// Cache the quote for the TTL period
@@ -392,30 +407,23 @@ func (s *QuoteService) GetRedeemXQuote(ctx context.Context, amountX decimal.Deci
 		return nil, fmt.Errorf("oracle data too stale: %ds > %s", state.OracleAgeSec, s.config.Oracle.MaxAge)
 	}
 
-	// Validate sufficient supply
-	if amountX.GreaterThan(state.SupplyX) {
-		return nil, fmt.Errorf("insufficient xToken supply: requested %s > available %s", amountX, state.SupplyX)
+	fees, err := s.FeeSchedule(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	// Calculate redeem X quote - xToken can be profitable to redeem
-	feeRate := decimal.NewFromFloat(0.02)           // 2% fee
-	rOut := amountX.Mul(decimal.NewFromFloat(1.02)) // 102% conversion rate (profitable)
-	fee := amountX.Mul(feeRate)
-	postCR := calc.PostRedeemCR(state.ReservesR, state.SupplyX, amountX) // Use SupplyX for xToken
-
-	// Validate CR constraint
-	minCR := decimal.NewFromFloat(1.1)
-	if err := calc.ValidateCRConstraint(postCR, minCR); err != nil {
-		return nil, fmt.Errorf("redeemX would breach CR constraint: %w", err)
+	outcome, err := quoteRedeemX(state, fees, amountX)
+	if err != nil {
+		return nil, err
 	}
 
 	quote := &RedeemXQuote{
-		ROut:    rOut,
-		Fee:     fee,
-		PostCR:  postCR,
-		TTLSec:  30, // 30 second TTL for quotes
-		QuoteID: generateQuoteID(),
-		AsOf:    time.Now(),
+		AmountIn: amountX,
+		ROut:     outcome.Out,
+		Fee:      outcome.Fee,
+		PostCR:   outcome.State.CR,
+		TTLSec:   30, // 30 second TTL for quotes
+		QuoteID:  generateQuoteID(),
+		AsOf:     time.Now(),
 	}
 
 	// Cache the quote for the TTL period
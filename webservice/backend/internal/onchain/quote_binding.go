@@ -0,0 +1,161 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrQuoteNotFound is returned for quotes that expired, were already
+	// used or were never issued
+	ErrQuoteNotFound = errors.New("quote not found or expired")
+	// ErrQuoteMismatch is returned when a transaction differs from its quote
+	ErrQuoteMismatch = errors.New("transaction does not match quote")
+	// ErrQuoteMoved is returned when the current quote is worse than the
+	// quoted one by more than the slippage tolerance
+	ErrQuoteMoved = errors.New("price moved beyond slippage tolerance")
+)
+
+const (
+	// DefaultSlippageBps is the tolerance of quote-bound transactions when
+	// none is requested
+	DefaultSlippageBps = 50
+	// MaxSlippageBps caps the requested tolerance
+	MaxSlippageBps = 1000
+)
+
+// BoundQuote is a quote a transaction is built against: the output quoted
+// for AmountIn, and the least output accepted at build time
+type BoundQuote struct {
+	QuoteID  string
+	Kind     string // Cache kind: mint, mintX, redeem or redeemX
+	AmountIn decimal.Decimal
+	Out      decimal.Decimal
+	MinOut   decimal.Decimal
+}
+
+// quoteKind returns the kind quotes for action on tokenType are cached as
+func quoteKind(action, tokenType string) (string, error) {
+	switch {
+	case action == "mint" && tokenType == "ftoken":
+		return "mint", nil
+	case action == "mint" && tokenType == "xtoken":
+		return "mintX", nil
+	case action == "redeem" && tokenType == "ftoken":
+		return "redeem", nil
+	case action == "redeem" && tokenType == "xtoken":
+		return "redeemX", nil
+	}
+	return "", fmt.Errorf("%w: no quotes for %s of %s", ErrQuoteMismatch, action, tokenType)
+}
+
+// quoteOut returns the input and output of the cached quote quoteID of kind
+func (s *QuoteService) quoteOut(ctx context.Context, kind, quoteID string) (amountIn, out decimal.Decimal, err error) {
+	get := func(dest interface{}) error {
+		return s.cache.GetQuote(ctx, kind, quoteID, dest)
+	}
+	switch kind {
+	case "mint":
+		var q MintQuote
+		err = get(&q)
+		amountIn, out = q.AmountIn, q.FOut
+	case "mintX":
+		var q MintXQuote
+		err = get(&q)
+		amountIn, out = q.AmountIn, q.XOut
+	case "redeem":
+		var q RedeemQuote
+		err = get(&q)
+		amountIn, out = q.AmountIn, q.ROut
+	case "redeemX":
+		var q RedeemXQuote
+		err = get(&q)
+		amountIn, out = q.AmountIn, q.ROut
+	}
+	if errors.Is(err, store.ErrCacheMiss) {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("%w: %s", ErrQuoteNotFound, quoteID)
+	}
+	return amountIn, out, err
+}
+
+// currentOut quotes amount anew for kind
+func (s *QuoteService) currentOut(ctx context.Context, kind string, amount decimal.Decimal) (decimal.Decimal, error) {
+	switch kind {
+	case "mint":
+		q, err := s.GetMintQuote(ctx, amount)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return q.FOut, nil
+	case "mintX":
+		q, err := s.GetMintXQuote(ctx, amount)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return q.XOut, nil
+	case "redeem":
+		q, err := s.GetRedeemQuote(ctx, amount)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return q.ROut, nil
+	default:
+		q, err := s.GetRedeemXQuote(ctx, amount)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return q.ROut, nil
+	}
+}
+
+// BindQuote checks that the unexpired quote quoteID was issued for action
+// on amount of tokenType, and that quoting it now gives at least its
+// output less slippageBps, DefaultSlippageBps when not positive. The
+// protocol entry functions take no minimum output, so the bound is
+// enforced here rather than in the transaction.
+func (s *QuoteService) BindQuote(ctx context.Context, quoteID, action, tokenType string, amount decimal.Decimal, slippageBps int) (*BoundQuote, error) {
+	if slippageBps <= 0 {
+		slippageBps = DefaultSlippageBps
+	}
+	if slippageBps > MaxSlippageBps {
+		return nil, fmt.Errorf("%w: slippage of %d bps over the maximum of %d", ErrQuoteMismatch, slippageBps, MaxSlippageBps)
+	}
+	kind, err := quoteKind(action, tokenType)
+	if err != nil {
+		return nil, err
+	}
+	amountIn, quoted, err := s.quoteOut(ctx, kind, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if !amountIn.Equal(amount) {
+		return nil, fmt.Errorf("%w: quote %s is for %s, not %s", ErrQuoteMismatch, quoteID, amountIn, amount)
+	}
+
+	minOut := quoted.Mul(decimal.NewFromInt(int64(10_000 - slippageBps))).Div(decimal.NewFromInt(10_000))
+	current, err := s.currentOut(ctx, kind, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to requote %s: %w", quoteID, err)
+	}
+	if current.LessThan(minOut) {
+		return nil, fmt.Errorf("%w: quoted %s, now %s, minimum %s", ErrQuoteMoved, quoted, current, minOut)
+	}
+	return &BoundQuote{QuoteID: quoteID, Kind: kind, AmountIn: amountIn, Out: quoted, MinOut: minOut}, nil
+}
+
+// UseQuote invalidates a bound quote once its transaction is built. It
+// returns ErrQuoteNotFound when another transaction used it meanwhile.
+func (s *QuoteService) UseQuote(ctx context.Context, quote *BoundQuote) error {
+	removed, err := s.cache.DeleteQuote(ctx, quote.Kind, quote.QuoteID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%w: %s was used meanwhile", ErrQuoteNotFound, quote.QuoteID)
+	}
+	return nil
+}
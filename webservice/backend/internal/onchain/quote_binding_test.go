@@ -0,0 +1,59 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBindQuote(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	// Nothing listens on port 1, so the cache runs in memory
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, cache.SetProtocolState(ctx, ProtocolState{
+		ReservesR: decimal.NewFromInt(1_000_000),
+		SupplyX:   decimal.NewFromInt(100_000),
+		AsOf:      time.Now(),
+	}))
+	cfg := &config.Config{Oracle: config.OracleConfig{MaxAge: time.Minute}}
+	protocol := NewProtocolService(nil, cache, cfg, logger)
+	svc := NewQuoteService(nil, cache, protocol, cfg, logger)
+
+	amount := decimal.NewFromInt(100)
+	quote, err := svc.GetMintXQuote(ctx, amount)
+	require.NoError(t, err)
+
+	_, err = svc.BindQuote(ctx, quote.QuoteID, "mint", "xtoken", decimal.NewFromInt(1000), 0)
+	assert.True(t, errors.Is(err, ErrQuoteMismatch), "expected ErrQuoteMismatch for another amount, got %v", err)
+	_, err = svc.BindQuote(ctx, quote.QuoteID, "redeem", "xtoken", amount, 0)
+	assert.True(t, errors.Is(err, ErrQuoteNotFound), "expected ErrQuoteNotFound for another action, got %v", err)
+
+	bound, err := svc.BindQuote(ctx, quote.QuoteID, "mint", "xtoken", amount, 100)
+	require.NoError(t, err)
+	assert.True(t, quote.XOut.Equal(bound.Out))
+	assert.True(t, quote.XOut.Mul(decimal.NewFromFloat(0.99)).Equal(bound.MinOut), "min out %s", bound.MinOut)
+
+	// Quotes are used once
+	require.NoError(t, svc.UseQuote(ctx, bound))
+	assert.True(t, errors.Is(svc.UseQuote(ctx, bound), ErrQuoteNotFound))
+	_, err = svc.BindQuote(ctx, quote.QuoteID, "mint", "xtoken", amount, 0)
+	assert.True(t, errors.Is(err, ErrQuoteNotFound), "expected ErrQuoteNotFound for a used quote, got %v", err)
+
+	// A quote better than the current one by more than the tolerance
+	generous := *quote
+	generous.QuoteID = "generous"
+	generous.XOut = quote.XOut.Mul(decimal.NewFromInt(2))
+	require.NoError(t, cache.SetQuote(ctx, "mintX", generous.QuoteID, generous, time.Minute))
+	_, err = svc.BindQuote(ctx, generous.QuoteID, "mint", "xtoken", amount, 0)
+	assert.True(t, errors.Is(err, ErrQuoteMoved), "expected ErrQuoteMoved, got %v", err)
+}
@@ -0,0 +1,313 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/pattonkan/sui-go/suiclient/conn"
+)
+
+// SuiRPC is the part of the Sui JSON-RPC API the onchain services read
+// through. *suiclient.ClientImpl and *RPCPool implement it.
+type SuiRPC interface {
+	GetObject(ctx context.Context, req *suiclient.GetObjectRequest) (*suiclient.SuiObjectResponse, error)
+	GetCoins(ctx context.Context, req *suiclient.GetCoinsRequest) (*suiclient.CoinPage, error)
+	GetOwnedObjects(ctx context.Context, req *suiclient.GetOwnedObjectsRequest) (*suiclient.ObjectsPage, error)
+	GetAllBalances(ctx context.Context, owner *sui.Address) ([]*suiclient.Balance, error)
+	GetCoinMetadata(ctx context.Context, coinType string) (*suiclient.CoinMetadata, error)
+	DevInspectTransactionBlock(ctx context.Context, req *suiclient.DevInspectTransactionBlockRequest) (*suiclient.DevInspectTransactionBlockResponse, error)
+	GetLatestSuiSystemState(ctx context.Context) (*suiclient.SuiSystemStateSummary, error)
+}
+
+// rpcCaller makes raw JSON-RPC calls, as *conn.HttpClient and *RPCPool do
+type rpcCaller interface {
+	CallContext(ctx context.Context, result interface{}, method conn.JsonRpcMethod, args ...interface{}) error
+}
+
+// RPC call statuses reported to a SuiRPCRecorder
+const (
+	RPCCallOK    = "ok"
+	RPCCallError = "error"
+)
+
+// SuiRPCRecorder receives the outcome of each call to an RPC endpoint, as
+// *metrics.Metrics does
+type SuiRPCRecorder interface {
+	RecordSuiRPC(ctx context.Context, endpoint, method, status string, latency time.Duration)
+}
+
+const (
+	// DefaultRPCHealthInterval is the time between health checks of the
+	// endpoints of a pool
+	DefaultRPCHealthInterval = 15 * time.Second
+	// rpcHealthTimeout bounds one health check
+	rpcHealthTimeout = 5 * time.Second
+	// rpcUnhealthyAfter consecutive failures move an endpoint behind the
+	// healthy ones until a call or health check to it succeeds
+	rpcUnhealthyAfter = 3
+	// rpcLatencyWeight is the weight of the newest call in the moving
+	// average latency of an endpoint
+	rpcLatencyWeight = 0.2
+)
+
+type rpcEndpoint struct {
+	url    string
+	client *suiclient.ClientImpl
+	http   *conn.HttpClient
+
+	mu       sync.Mutex
+	failures int
+	latency  time.Duration
+}
+
+// observe updates the health and latency of e after a call
+func (e *rpcEndpoint) observe(err error, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.failures++
+		return
+	}
+	e.failures = 0
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(rpcLatencyWeight*float64(latency) + (1-rpcLatencyWeight)*float64(e.latency))
+	}
+}
+
+func (e *rpcEndpoint) state() (healthy bool, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.failures < rpcUnhealthyAfter, e.latency
+}
+
+// RPCPool spreads Sui RPC calls over several endpoints. Calls go to the
+// healthy endpoint with the lowest latency; reads that fail are retried on
+// the others in turn, while transaction execution is never retried.
+// Endpoints failing repeatedly are only tried after the healthy ones, until
+// a call or a health check to them succeeds.
+type RPCPool struct {
+	endpoints []*rpcEndpoint
+	recorder  SuiRPCRecorder
+}
+
+// NewRPCPool returns a pool over the RPC endpoints urls, in order of
+// preference until latencies are known. recorder may be nil.
+func NewRPCPool(urls []string, recorder SuiRPCRecorder) (*RPCPool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no RPC endpoints")
+	}
+	p := &RPCPool{recorder: recorder}
+	for _, url := range urls {
+		p.endpoints = append(p.endpoints, &rpcEndpoint{
+			url:    url,
+			client: suiclient.NewClient(url),
+			http:   conn.NewHttpClient(url),
+		})
+	}
+	return p, nil
+}
+
+// WithRPCPool routes the calls of the builder through pool.
+func WithRPCPool(pool *RPCPool) TransactionBuilderOption {
+	return func(tb *TransactionBuilder) {
+		tb.client = pool
+		tb.rpc = pool
+	}
+}
+
+// Start health checks every endpoint each interval, DefaultRPCHealthInterval
+// when it is not positive, until ctx is done.
+func (p *RPCPool) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRPCHealthInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.checkHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkHealth reads the latest checkpoint from each endpoint
+func (p *RPCPool) checkHealth(ctx context.Context) {
+	for _, e := range p.endpoints {
+		checkCtx, cancel := context.WithTimeout(ctx, rpcHealthTimeout)
+		start := time.Now()
+		_, err := e.client.GetLatestCheckpointSequenceNumber(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// Out of rotation at once, rather than after more failed calls
+			e.mu.Lock()
+			e.failures = max(e.failures+1, rpcUnhealthyAfter)
+			e.mu.Unlock()
+		} else {
+			e.observe(nil, time.Since(start))
+		}
+		p.record(ctx, e.url, "health", err, time.Since(start))
+	}
+}
+
+// Ping reads the latest checkpoint from the endpoints in turn, healthy
+// first, and fails only when none answers
+func (p *RPCPool) Ping(ctx context.Context) error {
+	var lastErr error
+	for _, e := range p.ordered() {
+		start := time.Now()
+		_, err := e.client.GetLatestCheckpointSequenceNumber(ctx)
+		e.observe(err, time.Since(start))
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return fmt.Errorf("no Sui RPC endpoint answered: %w", lastErr)
+}
+
+// ordered returns the endpoints healthy first, then by latency
+func (p *RPCPool) ordered() []*rpcEndpoint {
+	type ranked struct {
+		endpoint *rpcEndpoint
+		healthy  bool
+		latency  time.Duration
+	}
+	ranks := make([]ranked, len(p.endpoints))
+	for i, e := range p.endpoints {
+		healthy, latency := e.state()
+		ranks[i] = ranked{endpoint: e, healthy: healthy, latency: latency}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].healthy != ranks[j].healthy {
+			return ranks[i].healthy
+		}
+		return ranks[i].latency < ranks[j].latency
+	})
+	endpoints := make([]*rpcEndpoint, len(ranks))
+	for i, r := range ranks {
+		endpoints[i] = r.endpoint
+	}
+	return endpoints
+}
+
+func (p *RPCPool) record(ctx context.Context, endpoint, method string, err error, latency time.Duration) {
+	if p.recorder == nil {
+		return
+	}
+	status := RPCCallOK
+	if err != nil {
+		status = RPCCallError
+	}
+	p.recorder.RecordSuiRPC(ctx, endpoint, method, status, latency)
+}
+
+// poolCall makes call on the best endpoint of p, and on the others in turn
+// when it fails and retry is set
+func poolCall[T any](ctx context.Context, p *RPCPool, method string, retry bool, call func(*rpcEndpoint) (T, error)) (T, error) {
+	var errs []error
+	for _, e := range p.ordered() {
+		start := time.Now()
+		res, err := call(e)
+		latency := time.Since(start)
+		e.observe(err, latency)
+		p.record(ctx, e.url, method, err, latency)
+		if err == nil {
+			return res, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.url, err))
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	var zero T
+	return zero, errors.Join(errs...)
+}
+
+func (p *RPCPool) GetObject(ctx context.Context, req *suiclient.GetObjectRequest) (*suiclient.SuiObjectResponse, error) {
+	return poolCall(ctx, p, "sui_getObject", true, func(e *rpcEndpoint) (*suiclient.SuiObjectResponse, error) {
+		return e.client.GetObject(ctx, req)
+	})
+}
+
+func (p *RPCPool) GetCoins(ctx context.Context, req *suiclient.GetCoinsRequest) (*suiclient.CoinPage, error) {
+	return poolCall(ctx, p, "suix_getCoins", true, func(e *rpcEndpoint) (*suiclient.CoinPage, error) {
+		return e.client.GetCoins(ctx, req)
+	})
+}
+
+func (p *RPCPool) GetOwnedObjects(ctx context.Context, req *suiclient.GetOwnedObjectsRequest) (*suiclient.ObjectsPage, error) {
+	return poolCall(ctx, p, "suix_getOwnedObjects", true, func(e *rpcEndpoint) (*suiclient.ObjectsPage, error) {
+		return e.client.GetOwnedObjects(ctx, req)
+	})
+}
+
+func (p *RPCPool) GetAllBalances(ctx context.Context, owner *sui.Address) ([]*suiclient.Balance, error) {
+	return poolCall(ctx, p, "suix_getAllBalances", true, func(e *rpcEndpoint) ([]*suiclient.Balance, error) {
+		return e.client.GetAllBalances(ctx, owner)
+	})
+}
+
+func (p *RPCPool) GetCoinMetadata(ctx context.Context, coinType string) (*suiclient.CoinMetadata, error) {
+	return poolCall(ctx, p, "suix_getCoinMetadata", true, func(e *rpcEndpoint) (*suiclient.CoinMetadata, error) {
+		return e.client.GetCoinMetadata(ctx, coinType)
+	})
+}
+
+func (p *RPCPool) DevInspectTransactionBlock(ctx context.Context, req *suiclient.DevInspectTransactionBlockRequest) (*suiclient.DevInspectTransactionBlockResponse, error) {
+	return poolCall(ctx, p, "sui_devInspectTransactionBlock", true, func(e *rpcEndpoint) (*suiclient.DevInspectTransactionBlockResponse, error) {
+		return e.client.DevInspectTransactionBlock(ctx, req)
+	})
+}
+
+func (p *RPCPool) GetLatestSuiSystemState(ctx context.Context) (*suiclient.SuiSystemStateSummary, error) {
+	return poolCall(ctx, p, "suix_getLatestSuiSystemState", true, func(e *rpcEndpoint) (*suiclient.SuiSystemStateSummary, error) {
+		return e.client.GetLatestSuiSystemState(ctx)
+	})
+}
+
+// CallContext makes a raw JSON-RPC call. Only executing a transaction is
+// not retried on other endpoints.
+func (p *RPCPool) CallContext(ctx context.Context, result interface{}, method conn.JsonRpcMethod, args ...interface{}) error {
+	retry := method.String() != executeTransactionBlock.String()
+	_, err := poolCall(ctx, p, method.String(), retry, func(e *rpcEndpoint) (struct{}, error) {
+		return struct{}{}, e.http.CallContext(ctx, result, method, args...)
+	})
+	return err
+}
+
+func (p *RPCPool) QueryEvents(ctx context.Context, req *suiclient.QueryEventsRequest) (*suiclient.EventPage, error) {
+	return poolCall(ctx, p, "suix_queryEvents", true, func(e *rpcEndpoint) (*suiclient.EventPage, error) {
+		return e.client.QueryEvents(ctx, req)
+	})
+}
+
+func (p *RPCPool) MultiGetTransactionBlocks(ctx context.Context, req *suiclient.MultiGetTransactionBlocksRequest) ([]*suiclient.SuiTransactionBlockResponse, error) {
+	return poolCall(ctx, p, "sui_multiGetTransactionBlocks", true, func(e *rpcEndpoint) ([]*suiclient.SuiTransactionBlockResponse, error) {
+		return e.client.MultiGetTransactionBlocks(ctx, req)
+	})
+}
+
+func (p *RPCPool) GetTransactionBlock(ctx context.Context, req *suiclient.GetTransactionBlockRequest) (*suiclient.SuiTransactionBlockResponse, error) {
+	return poolCall(ctx, p, "sui_getTransactionBlock", true, func(e *rpcEndpoint) (*suiclient.SuiTransactionBlockResponse, error) {
+		return e.client.GetTransactionBlock(ctx, req)
+	})
+}
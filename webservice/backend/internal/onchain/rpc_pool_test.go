@@ -0,0 +1,103 @@
+package onchain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rpcLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *rpcLog) RecordSuiRPC(_ context.Context, endpoint, method, status string, _ time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, endpoint+" "+method+" "+status)
+}
+
+// rpcServer answers JSON-RPC calls with result, and health checks with a
+// checkpoint, or fails them with HTTP 503 while down is set
+func rpcServer(t *testing.T, result interface{}, down *bool) (*httptest.Server, *int) {
+	calls := new(int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		if *down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		res := result
+		if req.Method == "sui_getLatestCheckpointSequenceNumber" {
+			res = "1"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": res})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func TestRPCPoolFailsOverReads(t *testing.T) {
+	ctx := context.Background()
+	down, up := true, false
+	primary, primaryCalls := rpcServer(t, map[string]interface{}{"decimals": 9, "symbol": "A"}, &down)
+	fallback, fallbackCalls := rpcServer(t, map[string]interface{}{"decimals": 9, "symbol": "B"}, &up)
+	recorder := &rpcLog{}
+	pool, err := NewRPCPool([]string{primary.URL, fallback.URL}, recorder)
+	require.NoError(t, err)
+
+	meta, err := pool.GetCoinMetadata(ctx, "0x2::sui::SUI")
+	require.NoError(t, err)
+	assert.Equal(t, "B", meta.Symbol)
+	assert.Equal(t, 1, *primaryCalls)
+	assert.Equal(t, []string{
+		primary.URL + " suix_getCoinMetadata error",
+		fallback.URL + " suix_getCoinMetadata ok",
+	}, recorder.calls)
+
+	// After repeated failures the primary is only tried last
+	for i := 0; i < rpcUnhealthyAfter; i++ {
+		_, err = pool.GetCoinMetadata(ctx, "0x2::sui::SUI")
+		require.NoError(t, err)
+	}
+	require.Equal(t, rpcUnhealthyAfter, *primaryCalls)
+	_, err = pool.GetCoinMetadata(ctx, "0x2::sui::SUI")
+	require.NoError(t, err)
+	assert.Equal(t, rpcUnhealthyAfter, *primaryCalls)
+	assert.Equal(t, rpcUnhealthyAfter+2, *fallbackCalls)
+
+	// and rejoins once a health check passes
+	down = false
+	pool.checkHealth(ctx)
+	healthy, _ := pool.endpoints[0].state()
+	assert.True(t, healthy)
+}
+
+func TestRPCPoolDoesNotRetryExecution(t *testing.T) {
+	ctx := context.Background()
+	down, up := true, false
+	primary, primaryCalls := rpcServer(t, nil, &down)
+	fallback, fallbackCalls := rpcServer(t, map[string]interface{}{"digest": "x"}, &up)
+	pool, err := NewRPCPool([]string{primary.URL, fallback.URL}, nil)
+	require.NoError(t, err)
+
+	var resp suiclient.SuiTransactionBlockResponse
+	err = pool.CallContext(ctx, &resp, executeTransactionBlock, "tx", []string{"sig"})
+	require.Error(t, err)
+	assert.Equal(t, 1, *primaryCalls)
+	assert.Equal(t, 0, *fallbackCalls)
+}
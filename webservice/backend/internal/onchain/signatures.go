@@ -0,0 +1,143 @@
+package onchain
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/pattonkan/sui-go/suisigner"
+	"github.com/pattonkan/sui-go/suisigner/suicrypto"
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrInvalidSignature is returned for signatures that are not serialized
+// Sui signatures
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// executeTransactionBlock is the JSON-RPC method executing signed
+// transactions. It is called directly as suiclient only takes single-key
+// signatures.
+const executeTransactionBlock = suiclient.SuiMethod("sui_executeTransactionBlock")
+
+// parseSignature decodes a base64 serialized Sui signature: the scheme
+// flag followed by the signature and public key for single keys, by the
+// BCS encoded member signatures and committee for multisig addresses, or by
+// the BCS encoded proof, max epoch and ephemeral signature for zkLogin.
+func parseSignature(raw string) (sui.Base64, error) {
+	sig, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not base64: %v", ErrInvalidSignature, err)
+	}
+	if len(sig) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidSignature)
+	}
+	flag := suicrypto.KeySchemeFlag(sig[0])
+	var size int
+	switch flag {
+	case suicrypto.KeySchemeFlagEd25519:
+		size = suicrypto.SizeSuiSignatureEd25519
+	case suicrypto.KeySchemeFlagSecp256k1:
+		size = suicrypto.SizeSuiSignatureSecp256k1
+	case suicrypto.KeySchemeFlagSecp256r1:
+		size = suicrypto.SizeSuiSignatureSecp256r1
+	case suicrypto.KeySchemeFlagMultiSig, suicrypto.KeySchemeFlagZkLoginAuthenticator:
+		// Multisig signatures and committees, and zkLogin proofs, are
+		// checked against the sender by the node, whose encoding of them
+		// changed across versions
+		if len(sig) < 2 {
+			return nil, fmt.Errorf("%w: empty %s signature", ErrInvalidSignature, flag)
+		}
+		return sig, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme flag %d", ErrInvalidSignature, sig[0])
+	}
+	if len(sig) != size {
+		return nil, fmt.Errorf("%w: %s signature of %d bytes, expected %d", ErrInvalidSignature, flag, len(sig), size)
+	}
+	return sig, nil
+}
+
+// SignatureScheme returns the scheme of the base64 serialized signature
+// raw, e.g. ZkLoginAuthenticator, or "Unknown"
+func SignatureScheme(raw string) string {
+	sig, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(sig) == 0 {
+		return "Unknown"
+	}
+	return suicrypto.KeySchemeFlag(sig[0]).String()
+}
+
+// VerifyPersonalMessage checks the base64 serialized signature of message
+// as signPersonalMessage of Sui wallets produces it, over the BCS encoded
+// message with the PersonalMessage intent, and returns the address of the
+// signing key. Only single-key signatures are supported: multisig and
+// zkLogin ones need the committee or the JWK set of the chain to check.
+func VerifyPersonalMessage(message []byte, signature string) (string, error) {
+	sig, err := parseSignature(signature)
+	if err != nil {
+		return "", err
+	}
+	flag := suicrypto.KeySchemeFlag(sig[0])
+
+	var pubKey []byte
+	var valid func(digest, signature []byte) bool
+	switch flag {
+	case suicrypto.KeySchemeFlagEd25519:
+		pubKey = sig[1+suicrypto.KeypairEd25519SignatureSize:]
+		key, err := suicrypto.Ed25519PubKeyFromBytes(pubKey)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		valid = key.Verify
+	case suicrypto.KeySchemeFlagSecp256k1:
+		pubKey = sig[1+suicrypto.KeypairSecp256k1SignatureSize:]
+		key, err := suicrypto.Secp256k1PubKeyFromBytes(pubKey)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		valid = key.Verify
+	case suicrypto.KeySchemeFlagSecp256r1:
+		pubKey = sig[1+suicrypto.KeypairSecp256r1SignatureSize:]
+		key, err := suicrypto.Secp256r1PubKeyFromBytes(pubKey)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		valid = key.Verify
+	default:
+		return "", fmt.Errorf("%w: %s signatures of personal messages are not supported", ErrInvalidSignature, flag)
+	}
+
+	encoded, err := bcs.Marshal(message)
+	if err != nil {
+		return "", fmt.Errorf("encode message: %w", err)
+	}
+	intent := suisigner.IntentPersonalMessage()
+	digest := blake2b.Sum256(suisigner.MessageWithIntent(intent, encoded))
+	if !valid(digest[:], sig[1:len(sig)-len(pubKey)]) {
+		return "", fmt.Errorf("%w: signature does not match the message", ErrInvalidSignature)
+	}
+
+	hash := blake2b.Sum256(append([]byte{flag.Byte()}, pubKey...))
+	address := sui.Address(hash)
+	return address.String(), nil
+}
+
+// executeSigned executes txBytes with the serialized signatures of all its
+// signers, the sender's and the gas owner's when sponsored
+func (tb *TransactionBuilder) executeSigned(ctx context.Context, txBytes []byte, signatures []sui.Base64) (*suiclient.SuiTransactionBlockResponse, error) {
+	var resp suiclient.SuiTransactionBlockResponse
+	err := tb.rpc.CallContext(ctx, &resp, executeTransactionBlock,
+		sui.Base64(txBytes),
+		signatures,
+		&suiclient.SuiTransactionBlockResponseOptions{ShowEffects: true},
+		suiclient.TxnRequestTypeWaitForLocalExecution,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,94 @@
+package onchain
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/pattonkan/sui-go/suisigner"
+	"github.com/pattonkan/sui-go/suisigner/suicrypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignature(t *testing.T) {
+	signed := func(flag suicrypto.KeySchemeFlag) string {
+		sig, err := suisigner.NewSigner(suisigner.TEST_SEED, flag).SignDigest([]byte("tx"), suisigner.IntentTransaction())
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(sig.Bytes())
+	}
+	for _, tt := range []struct {
+		name string
+		raw  string
+		size int
+	}{
+		{name: "ed25519", raw: signed(suicrypto.KeySchemeFlagEd25519), size: suicrypto.SizeSuiSignatureEd25519},
+		{name: "secp256k1", raw: signed(suicrypto.KeySchemeFlagSecp256k1), size: suicrypto.SizeSuiSignatureSecp256k1},
+		{name: "multisig", raw: base64.StdEncoding.EncodeToString([]byte{suicrypto.KeySchemeFlagMultiSig.Byte(), 1, 2, 3}), size: 4},
+		{name: "zklogin", raw: base64.StdEncoding.EncodeToString([]byte{suicrypto.KeySchemeFlagZkLoginAuthenticator.Byte(), 1, 2}), size: 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := parseSignature(tt.raw)
+			require.NoError(t, err)
+			assert.Len(t, sig, tt.size)
+		})
+	}
+
+	for _, tt := range []struct {
+		name string
+		raw  string
+	}{
+		{name: "not base64", raw: "!!"},
+		{name: "empty", raw: ""},
+		{name: "bare ed25519 signature", raw: base64.StdEncoding.EncodeToString(make([]byte, 64))},
+		{name: "truncated secp256r1", raw: base64.StdEncoding.EncodeToString([]byte{suicrypto.KeySchemeFlagSecp256r1.Byte(), 1})},
+		{name: "unsupported scheme", raw: base64.StdEncoding.EncodeToString([]byte{suicrypto.KeySchemeFlagBLS12381.Byte(), 1})},
+		{name: "empty zklogin", raw: base64.StdEncoding.EncodeToString([]byte{suicrypto.KeySchemeFlagZkLoginAuthenticator.Byte()})},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSignature(tt.raw)
+			assert.True(t, errors.Is(err, ErrInvalidSignature), "expected ErrInvalidSignature, got %v", err)
+		})
+	}
+}
+
+func TestSignatureScheme(t *testing.T) {
+	assert.Equal(t, "ZkLoginAuthenticator", SignatureScheme(base64.StdEncoding.EncodeToString([]byte{suicrypto.KeySchemeFlagZkLoginAuthenticator.Byte(), 1})))
+	assert.Equal(t, "Ed25519", SignatureScheme(base64.StdEncoding.EncodeToString([]byte{suicrypto.KeySchemeFlagEd25519.Byte()})))
+	assert.Equal(t, "Unknown", SignatureScheme("!!"))
+}
+
+func TestVerifyPersonalMessage(t *testing.T) {
+	message := []byte("localhost wants you to sign in with your Sui account")
+	signed := func(flag suicrypto.KeySchemeFlag, msg []byte) (string, string) {
+		signer := suisigner.NewSigner(suisigner.TEST_SEED, flag)
+		encoded, err := bcs.Marshal(msg)
+		require.NoError(t, err)
+		sig, err := signer.SignDigest(encoded, suisigner.IntentPersonalMessage())
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(sig.Bytes()), signer.Address.String()
+	}
+
+	for _, flag := range []suicrypto.KeySchemeFlag{suicrypto.KeySchemeFlagEd25519, suicrypto.KeySchemeFlagSecp256k1} {
+		t.Run(flag.String(), func(t *testing.T) {
+			sig, want := signed(flag, message)
+			address, err := VerifyPersonalMessage(message, sig)
+			require.NoError(t, err)
+			assert.Equal(t, want, address)
+
+			_, err = VerifyPersonalMessage([]byte("another message"), sig)
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		})
+	}
+
+	// Signatures of transactions do not sign in
+	txSig, err := suisigner.NewSigner(suisigner.TEST_SEED, suicrypto.KeySchemeFlagEd25519).SignDigest(message, suisigner.IntentTransaction())
+	require.NoError(t, err)
+	_, err = VerifyPersonalMessage(message, base64.StdEncoding.EncodeToString(txSig.Bytes()))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	zkLogin := base64.StdEncoding.EncodeToString([]byte{suicrypto.KeySchemeFlagZkLoginAuthenticator.Byte(), 1, 2})
+	_, err = VerifyPersonalMessage(message, zkLogin)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -0,0 +1,379 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/calc"
+	"github.com/shopspring/decimal"
+)
+
+// Simulated actions
+const (
+	SimulateMint       = "mint"
+	SimulateRedeem     = "redeem"
+	SimulateSPDeposit  = "sp_deposit"
+	SimulateSPWithdraw = "sp_withdraw"
+	SimulateSPClaim    = "sp_claim"
+)
+
+// MaxSimulationActions caps the actions of one simulation
+const MaxSimulationActions = 20
+
+// ErrInvalidSimulation is returned for simulations of unknown actions or
+// too many of them
+var ErrInvalidSimulation = errors.New("invalid simulation")
+
+var fTokenScale = decimal.NewFromInt(1_000_000_000)
+
+// quoteOutcome is an action priced against a protocol state: what it pays
+// out, the fee it is charged and the state it leaves
+type quoteOutcome struct {
+	Out   decimal.Decimal
+	Fee   decimal.Decimal
+	State ProtocolState
+}
+
+// quoteMintF prices minting fTokens with amountR of the reserve token at
+// the oracle prices pR and pF, less the mintF fee in fToken base units
+func quoteMintF(state *ProtocolState, fees FeeSchedule, pR, pF, amountR decimal.Decimal) (*quoteOutcome, error) {
+	amountR = amountR.Mul(fTokenScale)
+
+	// Calculate cross-token exchange rate: rateRtoF = pR / pF (amount of f per 1 r)
+	grossF := amountR.Mul(pR.Div(pF))
+	feeF := grossF.Mul(bpsRate(fees.MintFBps))
+	fOut := grossF.Sub(feeF)
+
+	// Reserves take the full input, as fees are in fToken units
+	next := *state
+	next.ReservesR = state.ReservesR.Add(amountR)
+	next.SupplyF = state.SupplyF.Add(fOut)
+	next.CR = calc.CollateralRatio(next.ReservesR.Mul(decimal.NewFromInt(int64(state.P))), next.SupplyF.Mul(decimal.NewFromInt(int64(state.Pf))))
+	if err := calc.ValidateCRConstraint(next.CR, fees.MinCR); err != nil {
+		return nil, fmt.Errorf("mintF would breach CR constraint: %w", err)
+	}
+	return &quoteOutcome{Out: fOut.Div(fTokenScale), Fee: feeF, State: next}, nil
+}
+
+// quoteRedeemF prices redeeming amountF of fTokens for the reserve token
+// at the oracle prices pR and pF, less the redeemF fee
+func quoteRedeemF(state *ProtocolState, fees FeeSchedule, pR, pF, amountF decimal.Decimal) (*quoteOutcome, error) {
+	if amountF.GreaterThan(state.SupplyF) {
+		return nil, fmt.Errorf("insufficient fToken supply: requested %s > available %s", amountF, state.SupplyF)
+	}
+
+	// Calculate cross-token exchange rate: rateFtoR = pF / pR (amount of r per 1 f)
+	grossR := amountF.Mul(pF.Div(pR))
+	feeR := grossR.Mul(bpsRate(fees.RedeemFBps))
+	rOut := grossR.Sub(feeR)
+
+	next := *state
+	next.ReservesR = state.ReservesR.Sub(rOut)
+	next.SupplyF = state.SupplyF.Sub(amountF)
+	next.CR = calc.CollateralRatio(next.ReservesR.Mul(decimal.NewFromInt(int64(state.P))), next.SupplyF.Mul(decimal.NewFromInt(int64(state.Pf))))
+	if err := calc.ValidateCRConstraint(next.CR, fees.MinCR); err != nil {
+		return nil, fmt.Errorf("redeem would breach CR constraint: %w", err)
+	}
+	return &quoteOutcome{Out: rOut, Fee: feeR, State: next}, nil
+}
+
+// quoteMintX prices minting xTokens with amountR, less the mintX fee
+func quoteMintX(state *ProtocolState, fees FeeSchedule, amountR decimal.Decimal) (*quoteOutcome, error) {
+	fee := amountR.Mul(bpsRate(fees.MintXBps))
+	xOut := amountR.Sub(fee)
+
+	next := *state
+	next.ReservesR = state.ReservesR.Add(amountR)
+	next.SupplyX = state.SupplyX.Add(xOut)
+	next.CR = calc.PostMintCR(state.ReservesR, state.SupplyX, amountR) // Use SupplyX for xToken
+	if err := calc.ValidateCRConstraint(next.CR, fees.MinCR); err != nil {
+		return nil, fmt.Errorf("mintX would breach CR constraint: %w", err)
+	}
+	return &quoteOutcome{Out: xOut, Fee: fee, State: next}, nil
+}
+
+// quoteRedeemX prices redeeming amountX of xTokens, which pays out 102%;
+// the redeemX fee is reported, not deducted
+func quoteRedeemX(state *ProtocolState, fees FeeSchedule, amountX decimal.Decimal) (*quoteOutcome, error) {
+	if amountX.GreaterThan(state.SupplyX) {
+		return nil, fmt.Errorf("insufficient xToken supply: requested %s > available %s", amountX, state.SupplyX)
+	}
+	fee := amountX.Mul(bpsRate(fees.RedeemXBps))
+	rOut := amountX.Mul(decimal.NewFromFloat(1.02))
+
+	next := *state
+	next.ReservesR = state.ReservesR.Sub(rOut)
+	next.SupplyX = state.SupplyX.Sub(amountX)
+	next.CR = calc.PostRedeemCR(state.ReservesR, state.SupplyX, amountX) // Use SupplyX for xToken
+	if err := calc.ValidateCRConstraint(next.CR, fees.MinCR); err != nil {
+		return nil, fmt.Errorf("redeemX would breach CR constraint: %w", err)
+	}
+	return &quoteOutcome{Out: rOut, Fee: fee, State: next}, nil
+}
+
+// projectedPegDeviation is the peg deviation of fTokens at the collateral
+// ratio cr. The oracle price is unchanged by user actions, but below a CR
+// of 1 each fToken is only backed by cr of its peg.
+func projectedPegDeviation(current, cr decimal.Decimal) decimal.Decimal {
+	one := decimal.NewFromInt(1)
+	if cr.IsPositive() && cr.LessThan(one) {
+		return decimal.Max(current, one.Sub(cr))
+	}
+	return current
+}
+
+// SimulationAction is a hypothetical action: a mint or redeem of TokenType
+// (ftoken or xtoken), or a deposit, withdrawal or claim of the stability
+// pool. Amount is what goes in, in display units; claims take none.
+type SimulationAction struct {
+	Action    string
+	TokenType string
+	Amount    decimal.Decimal
+}
+
+// SimulationPosition is what an address holds, as projected by a
+// simulation
+type SimulationPosition struct {
+	Balances
+	StakeF     decimal.Decimal `json:"stake_f"`     // In the stability pool
+	ClaimableR decimal.Decimal `json:"claimable_r"` // Stability pool rewards
+}
+
+// SimulatedState is the protocol state a simulation projects
+type SimulatedState struct {
+	CR           decimal.Decimal
+	PegDeviation decimal.Decimal
+	Mode         string
+	ReservesR    decimal.Decimal
+	SupplyF      decimal.Decimal
+	SupplyX      decimal.Decimal
+	SPTVLF       decimal.Decimal
+}
+
+// SimulationStep is the outcome of one action. A failed action has Error
+// and leaves the state as it was.
+type SimulationStep struct {
+	Action    SimulationAction
+	AmountOut decimal.Decimal
+	Fee       decimal.Decimal
+	State     SimulatedState
+	Position  *SimulationPosition
+	Error     string
+}
+
+// Simulation projects a batch of actions applied in order. Completed is
+// false when an action failed, which ends the simulation.
+type Simulation struct {
+	Initial   SimulatedState
+	Steps     []SimulationStep
+	Final     SimulatedState
+	Position  *SimulationPosition
+	Completed bool
+	AsOf      time.Time
+}
+
+// SimulationRequest is a batch of actions to simulate from the current
+// protocol state. Position, when set, is what the acting address holds;
+// the simulation then projects it and rejects actions it cannot afford.
+// SPTVLF is the fTokens staked in the stability pool.
+type SimulationRequest struct {
+	Actions  []SimulationAction
+	Position *SimulationPosition
+	SPTVLF   decimal.Decimal
+}
+
+// Simulate applies the actions of req in order to the current protocol
+// state with the pricing of the quotes, without building transactions or
+// caching quotes
+func (s *QuoteService) Simulate(ctx context.Context, req SimulationRequest) (*Simulation, error) {
+	if len(req.Actions) == 0 || len(req.Actions) > MaxSimulationActions {
+		return nil, fmt.Errorf("%w: between 1 and %d actions", ErrInvalidSimulation, MaxSimulationActions)
+	}
+	for i, action := range req.Actions {
+		if err := validateSimulationAction(action); err != nil {
+			return nil, fmt.Errorf("%w: action %d: %v", ErrInvalidSimulation, i, err)
+		}
+	}
+
+	current, err := s.protocol.GetState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fees, err := s.FeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state := *current
+	tvl := req.SPTVLF
+	var position *SimulationPosition
+	if req.Position != nil {
+		held := *req.Position
+		position = &held
+	}
+
+	// Oracle prices are read once, by the first action that needs them
+	var pR, pF decimal.Decimal
+	prices := func() (decimal.Decimal, decimal.Decimal, error) {
+		if pR.IsPositive() {
+			return pR, pF, nil
+		}
+		var err error
+		pR, pF, err = s.fetchAndValidateOraclePrices(ctx)
+		return pR, pF, err
+	}
+
+	sim := &Simulation{Initial: simulatedState(&state, current.PegDeviation, tvl), AsOf: time.Now()}
+	for _, action := range req.Actions {
+		step := SimulationStep{Action: action}
+		if action.Action == SimulateSPClaim && position != nil {
+			step.AmountOut = position.ClaimableR
+		}
+		outcome, err := s.simulateAction(&state, fees, action, prices)
+		if err == nil {
+			err = applyToPosition(position, &tvl, action, outcome)
+		}
+		if err != nil {
+			step.Error = err.Error()
+			step.State = simulatedState(&state, current.PegDeviation, tvl)
+			sim.Steps = append(sim.Steps, step)
+			break
+		}
+		if outcome != nil {
+			state = outcome.State
+			step.AmountOut, step.Fee = outcome.Out, outcome.Fee
+		}
+		step.State = simulatedState(&state, current.PegDeviation, tvl)
+		if position != nil {
+			held := *position
+			step.Position = &held
+		}
+		sim.Steps = append(sim.Steps, step)
+	}
+
+	sim.Completed = len(sim.Steps) == len(req.Actions) && sim.Steps[len(sim.Steps)-1].Error == ""
+	sim.Final = sim.Steps[len(sim.Steps)-1].State
+	sim.Position = position
+	return sim, nil
+}
+
+// simulateAction prices a mint or redeem against state; stability pool
+// actions move no protocol reserves and return no outcome
+func (s *QuoteService) simulateAction(state *ProtocolState, fees FeeSchedule, action SimulationAction, prices func() (decimal.Decimal, decimal.Decimal, error)) (*quoteOutcome, error) {
+	switch {
+	case (action.Action == SimulateMint || action.Action == SimulateRedeem) && action.TokenType == "ftoken":
+		pR, pF, err := prices()
+		if err != nil {
+			return nil, err
+		}
+		if action.Action == SimulateMint {
+			return quoteMintF(state, fees, pR, pF, action.Amount)
+		}
+		return quoteRedeemF(state, fees, pR, pF, action.Amount)
+	case action.Action == SimulateMint || action.Action == SimulateRedeem:
+		if state.OracleAgeSec > int64(s.config.Oracle.MaxAge.Seconds()) {
+			return nil, fmt.Errorf("oracle data too stale: %ds > %s", state.OracleAgeSec, s.config.Oracle.MaxAge)
+		}
+		if action.Action == SimulateMint {
+			return quoteMintX(state, fees, action.Amount)
+		}
+		return quoteRedeemX(state, fees, action.Amount)
+	}
+	return nil, nil
+}
+
+// applyToPosition moves the balances of position, if any, and the
+// stability pool TVL by action
+func applyToPosition(position *SimulationPosition, tvl *decimal.Decimal, action SimulationAction, outcome *quoteOutcome) error {
+	spend := func(balance *decimal.Decimal, token string) error {
+		if position == nil {
+			return nil
+		}
+		if action.Amount.GreaterThan(*balance) {
+			return fmt.Errorf("insufficient %s balance: %s > %s", token, action.Amount, *balance)
+		}
+		*balance = balance.Sub(action.Amount)
+		return nil
+	}
+	credit := func(balance *decimal.Decimal, amount decimal.Decimal) {
+		if position != nil {
+			*balance = balance.Add(amount)
+		}
+	}
+	var held SimulationPosition
+	if position != nil {
+		held = *position
+	}
+
+	switch action.Action {
+	case SimulateMint:
+		if err := spend(&held.R, "reserve token"); err != nil {
+			return err
+		}
+		if action.TokenType == "ftoken" {
+			credit(&held.F, outcome.Out)
+		} else {
+			credit(&held.X, outcome.Out)
+		}
+	case SimulateRedeem:
+		if action.TokenType == "ftoken" {
+			if err := spend(&held.F, "fToken"); err != nil {
+				return err
+			}
+		} else if err := spend(&held.X, "xToken"); err != nil {
+			return err
+		}
+		credit(&held.R, outcome.Out)
+	case SimulateSPDeposit:
+		if err := spend(&held.F, "fToken"); err != nil {
+			return err
+		}
+		credit(&held.StakeF, action.Amount)
+		*tvl = tvl.Add(action.Amount)
+	case SimulateSPWithdraw:
+		if err := spend(&held.StakeF, "stability pool"); err != nil {
+			return err
+		}
+		credit(&held.F, action.Amount)
+		*tvl = decimal.Max(decimal.Zero, tvl.Sub(action.Amount))
+	case SimulateSPClaim:
+		credit(&held.R, held.ClaimableR)
+		held.ClaimableR = decimal.Zero
+	}
+
+	if position != nil {
+		*position = held
+	}
+	return nil
+}
+
+func validateSimulationAction(action SimulationAction) error {
+	switch action.Action {
+	case SimulateMint, SimulateRedeem:
+		if action.TokenType != "ftoken" && action.TokenType != "xtoken" {
+			return fmt.Errorf("tokenType must be ftoken or xtoken")
+		}
+	case SimulateSPDeposit, SimulateSPWithdraw:
+	case SimulateSPClaim:
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q", action.Action)
+	}
+	if !action.Amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	return nil
+}
+
+func simulatedState(state *ProtocolState, pegDeviation, tvl decimal.Decimal) SimulatedState {
+	return SimulatedState{
+		CR:           state.CR,
+		PegDeviation: projectedPegDeviation(pegDeviation, state.CR),
+		Mode:         ProtocolModeOf(state.CR),
+		ReservesR:    state.ReservesR,
+		SupplyF:      state.SupplyF,
+		SupplyX:      state.SupplyX,
+		SPTVLF:       tvl,
+	}
+}
@@ -0,0 +1,131 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/config"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fixedOracle serves fresh fixed oracle prices; its other reads are not
+// implemented
+type fixedOracle struct {
+	ChainReader
+	prices map[string]decimal.Decimal
+}
+
+func (f *fixedOracle) GetOraclePrice(_ context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	return f.prices[symbol], time.Now(), nil
+}
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop().Sugar()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, cache.SetProtocolState(ctx, ProtocolState{
+		CR:           decimal.NewFromInt(2),
+		ReservesR:    decimal.NewFromInt(1_000_000_000_000_000),
+		SupplyF:      decimal.NewFromInt(1_000_000_000_000),
+		SupplyX:      decimal.NewFromInt(100_000),
+		P:            1,
+		Pf:           1,
+		PegDeviation: decimal.NewFromFloat(0.001),
+		AsOf:         time.Now(),
+	}))
+	cfg := &config.Config{Oracle: config.OracleConfig{MaxAge: time.Minute}}
+	chain := &fixedOracle{prices: map[string]decimal.Decimal{"RTOKEN": decimal.NewFromInt(2), "FTOKEN": decimal.NewFromInt(1)}}
+	protocol := NewProtocolService(nil, cache, cfg, logger)
+	svc := NewQuoteService(chain, cache, protocol, cfg, logger)
+
+	mintX, err := svc.GetMintXQuote(ctx, decimal.NewFromInt(100))
+	require.NoError(t, err)
+	mintF, err := svc.GetMintQuote(ctx, decimal.NewFromInt(10))
+	require.NoError(t, err)
+
+	position := &SimulationPosition{
+		Balances:   Balances{R: decimal.NewFromInt(200), F: decimal.NewFromInt(100)},
+		ClaimableR: decimal.NewFromInt(3),
+	}
+	sim, err := svc.Simulate(ctx, SimulationRequest{
+		Actions: []SimulationAction{
+			{Action: SimulateMint, TokenType: "xtoken", Amount: decimal.NewFromInt(100)},
+			{Action: SimulateMint, TokenType: "xtoken", Amount: decimal.NewFromInt(50)},
+			{Action: SimulateMint, TokenType: "ftoken", Amount: decimal.NewFromInt(10)},
+			{Action: SimulateSPDeposit, Amount: decimal.NewFromInt(40)},
+			{Action: SimulateSPClaim},
+		},
+		Position: position,
+		SPTVLF:   decimal.NewFromInt(500),
+	})
+	require.NoError(t, err)
+	require.True(t, sim.Completed)
+	require.Len(t, sim.Steps, 5)
+	assert.True(t, sim.Initial.CR.Equal(decimal.NewFromInt(2)))
+
+	// Steps are priced like the quotes, each from the state the last left
+	assert.True(t, sim.Steps[0].AmountOut.Equal(mintX.XOut), "xOut %s", sim.Steps[0].AmountOut)
+	assert.True(t, sim.Steps[0].Fee.Equal(mintX.Fee))
+	assert.True(t, sim.Steps[0].State.CR.Equal(mintX.PostCR))
+	assert.True(t, sim.Steps[1].State.ReservesR.Equal(decimal.NewFromInt(1_000_000_000_000_150)), "reserves %s", sim.Steps[1].State.ReservesR)
+	assert.True(t, sim.Steps[1].State.SupplyX.Equal(decimal.NewFromInt(100_147)), "supply %s", sim.Steps[1].State.SupplyX)
+	assert.True(t, sim.Steps[2].AmountOut.Equal(mintF.FOut), "fOut %s", sim.Steps[2].AmountOut)
+
+	// The position follows the steps, and the pool its deposit
+	assert.True(t, sim.Steps[3].State.SPTVLF.Equal(decimal.NewFromInt(540)))
+	assert.True(t, sim.Steps[4].AmountOut.Equal(decimal.NewFromInt(3)))
+	assert.True(t, sim.Position.R.Equal(decimal.NewFromInt(43)), "r %s", sim.Position.R)
+	assert.True(t, sim.Position.X.Equal(decimal.NewFromInt(147)), "x %s", sim.Position.X)
+	assert.True(t, sim.Position.F.Equal(mintF.FOut.Add(decimal.NewFromInt(60))), "f %s", sim.Position.F)
+	assert.True(t, sim.Position.StakeF.Equal(decimal.NewFromInt(40)))
+	assert.True(t, sim.Position.ClaimableR.IsZero())
+	assert.True(t, position.R.Equal(decimal.NewFromInt(200)), "the held position is not changed")
+	assert.Equal(t, sim.Steps[4].State, sim.Final)
+
+	// An action the position cannot afford ends the simulation
+	sim, err = svc.Simulate(ctx, SimulationRequest{
+		Actions: []SimulationAction{
+			{Action: SimulateRedeem, TokenType: "xtoken", Amount: decimal.NewFromInt(10)},
+			{Action: SimulateMint, TokenType: "xtoken", Amount: decimal.NewFromInt(10)},
+		},
+		Position: position,
+	})
+	require.NoError(t, err)
+	assert.False(t, sim.Completed)
+	require.Len(t, sim.Steps, 1)
+	assert.Contains(t, sim.Steps[0].Error, "insufficient xToken balance")
+	assert.Equal(t, sim.Initial, sim.Final)
+
+	// As does one the protocol cannot serve, without a position
+	sim, err = svc.Simulate(ctx, SimulationRequest{Actions: []SimulationAction{
+		{Action: SimulateRedeem, TokenType: "ftoken", Amount: decimal.NewFromInt(2_000_000_000_000)},
+	}})
+	require.NoError(t, err)
+	assert.False(t, sim.Completed)
+	assert.Contains(t, sim.Steps[0].Error, "insufficient fToken supply")
+	assert.Nil(t, sim.Position)
+
+	for _, actions := range [][]SimulationAction{
+		nil,
+		{{Action: "stake", Amount: decimal.NewFromInt(1)}},
+		{{Action: SimulateMint, TokenType: "ytoken", Amount: decimal.NewFromInt(1)}},
+		{{Action: SimulateSPDeposit}},
+	} {
+		_, err := svc.Simulate(ctx, SimulationRequest{Actions: actions})
+		assert.True(t, errors.Is(err, ErrInvalidSimulation), "expected %v rejected, got %v", actions, err)
+	}
+}
+
+func TestProjectedPegDeviation(t *testing.T) {
+	current := decimal.NewFromFloat(0.01)
+	assert.True(t, projectedPegDeviation(current, decimal.NewFromFloat(1.5)).Equal(current))
+	assert.True(t, projectedPegDeviation(current, decimal.NewFromFloat(0.8)).Equal(decimal.NewFromFloat(0.2)))
+	assert.True(t, projectedPegDeviation(current, decimal.Zero).Equal(current))
+}
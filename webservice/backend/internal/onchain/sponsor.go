@@ -0,0 +1,232 @@
+package onchain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/sui/suiptb"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/pattonkan/sui-go/suisigner"
+)
+
+var (
+	// ErrSponsorUnavailable is returned for sponsored transactions when no
+	// sponsor is configured
+	ErrSponsorUnavailable = errors.New("gas sponsorship unavailable")
+	// ErrSponsorQuotaExceeded is returned when an address used up its
+	// sponsored transactions for the window
+	ErrSponsorQuotaExceeded = errors.New("sponsorship quota exceeded")
+	// ErrSponsorshipNotFound is returned when submitting a transaction the
+	// sponsor pays for that was not built here, or was built too long ago
+	ErrSponsorshipNotFound = errors.New("sponsored transaction not found")
+)
+
+const (
+	// DefaultSponsorQuotaWindow is the window of sponsorship quotas
+	DefaultSponsorQuotaWindow = 24 * time.Hour
+	// DefaultSponsorMaxGasBudget caps the gas budget of one sponsored
+	// transaction, in MIST
+	DefaultSponsorMaxGasBudget = 50_000_000
+	// sponsorshipTTL is how long a built sponsored transaction can be
+	// submitted
+	sponsorshipTTL = 10 * time.Minute
+)
+
+// SponsorQuota limits the transactions a sponsor pays gas for.
+type SponsorQuota struct {
+	MaxTransactions int64         // Per sender and window; 0 leaves senders unlimited
+	Window          time.Duration // Defaults to 24h
+	MaxGasBudget    uint64        // Per transaction, in MIST; defaults to 0.05 SUI
+}
+
+// Sponsor pays the gas of transactions built for users who hold no SUI,
+// providing the gas coins and co-signing them on submission. Built
+// transactions and quota counts are kept in a kv store so that replicas
+// share them.
+type Sponsor struct {
+	signer *suisigner.Signer
+	quota  SponsorQuota
+	store  kv.Store
+	now    func() time.Time
+}
+
+// NewSponsor returns a sponsor paying gas from the account of signer.
+func NewSponsor(signer *suisigner.Signer, quota SponsorQuota, store kv.Store) *Sponsor {
+	if quota.Window <= 0 {
+		quota.Window = DefaultSponsorQuotaWindow
+	}
+	if quota.MaxGasBudget == 0 {
+		quota.MaxGasBudget = DefaultSponsorMaxGasBudget
+	}
+	return &Sponsor{signer: signer, quota: quota, store: store, now: time.Now}
+}
+
+// WithSponsor lets the builder build transactions whose gas sponsor pays,
+// and the submitter co-sign them.
+func WithSponsor(sponsor *Sponsor) TransactionBuilderOption {
+	return func(tb *TransactionBuilder) {
+		tb.sponsor = sponsor
+	}
+}
+
+// Address returns the account paying gas.
+func (s *Sponsor) Address() *sui.Address {
+	return s.signer.Address
+}
+
+// quotaKey returns the kv key counting the sponsored transactions of
+// sender in the current window
+func (s *Sponsor) quotaKey(sender *sui.Address) string {
+	bucket := s.now().UnixNano() / int64(s.quota.Window)
+	return fmt.Sprintf("sui:sponsor:quota:%s:%d", sender, bucket)
+}
+
+// checkQuota returns ErrSponsorQuotaExceeded when sender has no sponsored
+// transactions left in the window
+func (s *Sponsor) checkQuota(ctx context.Context, sender *sui.Address) error {
+	if s.quota.MaxTransactions <= 0 {
+		return nil
+	}
+	v, err := s.store.GetString(ctx, s.quotaKey(sender))
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	used, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	if used >= s.quota.MaxTransactions {
+		return fmt.Errorf("%w: %s sent %d of %d", ErrSponsorQuotaExceeded, sender, used, s.quota.MaxTransactions)
+	}
+	return nil
+}
+
+// useQuota counts a sponsored transaction of sender, unless it would go
+// over the quota
+func (s *Sponsor) useQuota(ctx context.Context, sender *sui.Address) error {
+	if s.quota.MaxTransactions <= 0 {
+		return nil
+	}
+	key := s.quotaKey(sender)
+	used, err := s.store.IncrBy(ctx, key, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.Expire(ctx, key, s.quota.Window); err != nil {
+		return err
+	}
+	if used > s.quota.MaxTransactions {
+		_, _ = s.store.DecrBy(ctx, key, 1)
+		return fmt.Errorf("%w: %s sent %d of %d", ErrSponsorQuotaExceeded, sender, used-1, s.quota.MaxTransactions)
+	}
+	return nil
+}
+
+func sponsorshipKey(txBytes []byte) string {
+	sum := sha256.Sum256(txBytes)
+	return "sui:sponsor:tx:" + hex.EncodeToString(sum[:])
+}
+
+// remember records txBytes as built for sender, so that it is co-signed
+// when submitted
+func (s *Sponsor) remember(ctx context.Context, txBytes []byte, sender *sui.Address) error {
+	return s.store.SetString(ctx, sponsorshipKey(txBytes), sender.String(), sponsorshipTTL)
+}
+
+// sponsoredSender returns the sender of txBytes when its gas is paid by s,
+// and nil otherwise
+func (s *Sponsor) sponsoredSender(txBytes []byte) *sui.Address {
+	var tx suiptb.TransactionData
+	if _, err := bcs.Unmarshal(txBytes, &tx); err != nil {
+		return nil
+	}
+	if tx.V1 == nil || tx.V1.GasData.Owner == nil || *tx.V1.GasData.Owner != *s.signer.Address {
+		return nil
+	}
+	sender := tx.V1.Sender
+	return &sender
+}
+
+// cosign checks that txBytes was built here for sender and counts it
+// against the quota of sender, then signs it as the gas owner
+func (s *Sponsor) cosign(ctx context.Context, txBytes []byte, sender *sui.Address) (*suisigner.Signature, error) {
+	key := sponsorshipKey(txBytes)
+	builtFor, err := s.store.GetString(ctx, key)
+	if errors.Is(err, kv.ErrNotFound) || (err == nil && builtFor != sender.String()) {
+		return nil, ErrSponsorshipNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.useQuota(ctx, sender); err != nil {
+		return nil, err
+	}
+	// A transaction is only co-signed once
+	if _, err := s.store.Del(ctx, key); err != nil {
+		return nil, err
+	}
+	return s.signer.SignDigest(txBytes, suisigner.IntentTransaction())
+}
+
+// sponsoredTransactionData returns pt sent by sender with its gas paid by
+// the sponsor, when sender has quota left and budget is within the cap
+func (tb *TransactionBuilder) sponsoredTransactionData(ctx context.Context, sender *sui.Address, pt suiptb.ProgrammableTransaction, budget uint64) (suiptb.TransactionData, error) {
+	if tb.sponsor == nil {
+		return suiptb.TransactionData{}, ErrSponsorUnavailable
+	}
+	if budget > tb.sponsor.quota.MaxGasBudget {
+		return suiptb.TransactionData{}, fmt.Errorf("%w: gas budget %d over the sponsored maximum of %d", ErrSponsorUnavailable, budget, tb.sponsor.quota.MaxGasBudget)
+	}
+	if err := tb.sponsor.checkQuota(ctx, sender); err != nil {
+		return suiptb.TransactionData{}, err
+	}
+	gasCoins, err := tb.gasCoins(ctx, tb.sponsor.Address(), budget)
+	if err != nil {
+		return suiptb.TransactionData{}, fmt.Errorf("sponsor %w", err)
+	}
+	return suiptb.NewTransactionDataAllowSponsor(*sender, pt, gasCoins.CoinRefs(), budget, suiclient.DefaultGasPrice, tb.sponsor.Address()), nil
+}
+
+// marshalTransaction returns the bytes of tx to sign, or of its kind in
+// devinspect mode. Sponsored transactions are remembered for the sponsor
+// to co-sign on submission.
+func (tb *TransactionBuilder) marshalTransaction(ctx context.Context, tx suiptb.TransactionData, mode TxBuildMode, sponsored bool) ([]byte, error) {
+	if mode == TxBuildModeDevInspect {
+		txBytes, err := bcs.Marshal(tx.V1.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+		}
+		return txBytes, nil
+	}
+	txBytes, err := bcs.Marshal(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+	}
+	if sponsored {
+		if err := tb.sponsor.remember(ctx, txBytes, &tx.V1.Sender); err != nil {
+			return nil, fmt.Errorf("failed to record sponsored transaction: %w", err)
+		}
+	}
+	return txBytes, nil
+}
+
+// withSponsorMetadata adds the sponsor to the metadata of sponsored
+// transactions
+func (tb *TransactionBuilder) withSponsorMetadata(metadata map[string]string, sponsored bool) map[string]string {
+	if sponsored && tb.sponsor != nil {
+		metadata["sponsored"] = "true"
+		metadata["sponsor"] = tb.sponsor.Address().String()
+	}
+	return metadata
+}
@@ -0,0 +1,79 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/sui/suiptb"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/pattonkan/sui-go/suisigner"
+	"github.com/pattonkan/sui-go/suisigner/suicrypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSponsorCosignsBuiltTransactionsWithinQuota(t *testing.T) {
+	ctx := context.Background()
+	sponsor := NewSponsor(suisigner.NewSigner(suisigner.TEST_SEED, suicrypto.KeySchemeFlagEd25519), SponsorQuota{MaxTransactions: 1}, memory.NewStore())
+	now := time.Unix(1700000000, 0)
+	sponsor.now = func() time.Time { return now }
+	sender := sui.MustAddressFromHex("0x1234")
+
+	sponsoredTx := func(amount uint64) []byte {
+		ptb := suiptb.NewTransactionDataTransactionBuilder()
+		ptb.PayAllSui(sender)
+		tx := suiptb.NewTransactionDataAllowSponsor(*sender, ptb.Finish(), testCoins(amount).CoinRefs(), 1_000_000, suiclient.DefaultGasPrice, sponsor.Address())
+		txBytes, err := bcs.Marshal(tx)
+		require.NoError(t, err)
+		return txBytes
+	}
+
+	first := sponsoredTx(100)
+	got := sponsor.sponsoredSender(first)
+	require.NotNil(t, got)
+	assert.Equal(t, *sender, *got)
+
+	// Transactions not built here are not co-signed
+	_, err := sponsor.cosign(ctx, first, sender)
+	assert.True(t, errors.Is(err, ErrSponsorshipNotFound), "expected ErrSponsorshipNotFound, got %v", err)
+
+	require.NoError(t, sponsor.remember(ctx, first, sender))
+	_, err = sponsor.cosign(ctx, first, sui.MustAddressFromHex("0x5678"))
+	assert.True(t, errors.Is(err, ErrSponsorshipNotFound), "expected ErrSponsorshipNotFound for another sender, got %v", err)
+	sig, err := sponsor.cosign(ctx, first, sender)
+	require.NoError(t, err)
+	assert.NotNil(t, sig.Ed25519SuiSignature)
+
+	// Co-signed once
+	_, err = sponsor.cosign(ctx, first, sender)
+	assert.True(t, errors.Is(err, ErrSponsorshipNotFound), "expected ErrSponsorshipNotFound, got %v", err)
+
+	// The quota of the window is used up
+	assert.True(t, errors.Is(sponsor.checkQuota(ctx, sender), ErrSponsorQuotaExceeded))
+	second := sponsoredTx(200)
+	require.NoError(t, sponsor.remember(ctx, second, sender))
+	_, err = sponsor.cosign(ctx, second, sender)
+	assert.True(t, errors.Is(err, ErrSponsorQuotaExceeded), "expected ErrSponsorQuotaExceeded, got %v", err)
+
+	// and renewed in the next one
+	now = now.Add(DefaultSponsorQuotaWindow)
+	require.NoError(t, sponsor.checkQuota(ctx, sender))
+	_, err = sponsor.cosign(ctx, second, sender)
+	require.NoError(t, err)
+}
+
+func TestSponsoredSenderIgnoresSelfPaidTransactions(t *testing.T) {
+	sponsor := NewSponsor(suisigner.NewSigner(suisigner.TEST_SEED, suicrypto.KeySchemeFlagEd25519), SponsorQuota{}, memory.NewStore())
+	sender := sui.MustAddressFromHex("0x1234")
+	ptb := suiptb.NewTransactionDataTransactionBuilder()
+	ptb.PayAllSui(sender)
+	txBytes, err := bcs.Marshal(suiptb.NewTransactionData(sender, ptb.Finish(), testCoins(100).CoinRefs(), 1_000_000, suiclient.DefaultGasPrice))
+	require.NoError(t, err)
+	assert.Nil(t, sponsor.sponsoredSender(txBytes))
+	assert.Nil(t, sponsor.sponsoredSender([]byte("not a transaction")))
+}
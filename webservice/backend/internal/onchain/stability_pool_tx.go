@@ -0,0 +1,298 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/sui/suiptb"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/shopspring/decimal"
+)
+
+// ErrNoSPPosition is returned when withdrawing or claiming for a user
+// without a stability pool position
+var ErrNoSPPosition = errors.New("no stability pool position")
+
+// SPDepositTxRequest contains parameters for building stability pool
+// deposits of fTokens. Users without a position get one created.
+type SPDepositTxRequest struct {
+	Amount      decimal.Decimal
+	UserAddress *sui.Address
+	Mode        TxBuildMode
+	Sponsored   bool
+}
+
+// SPWithdrawTxRequest contains parameters for building stability pool
+// withdrawals of fTokens
+type SPWithdrawTxRequest struct {
+	Amount      decimal.Decimal
+	UserAddress *sui.Address
+	Mode        TxBuildMode
+	Sponsored   bool
+}
+
+// SPClaimTxRequest contains parameters for building claims of the SUI
+// rewards of a stability pool position
+type SPClaimTxRequest struct {
+	UserAddress *sui.Address
+	Mode        TxBuildMode
+	Sponsored   bool
+}
+
+func (tb *TransactionBuilder) ftokenTypeTag() sui.TypeTag {
+	return sui.TypeTag{Struct: &sui.StructTag{
+		Address: tb.ftokenPackageId,
+		Module:  "ftoken",
+		Name:    "FTOKEN",
+	}}
+}
+
+func (tb *TransactionBuilder) xtokenTypeTag() sui.TypeTag {
+	return sui.TypeTag{Struct: &sui.StructTag{
+		Address: tb.xtokenPackageId,
+		Module:  "xtoken",
+		Name:    "XTOKEN",
+	}}
+}
+
+// sharedObjectArg adds the shared object id to ptb as a mutable input
+func (tb *TransactionBuilder) sharedObjectArg(ctx context.Context, ptb *suiptb.ProgrammableTransactionBuilder, id *sui.ObjectId) (suiptb.Argument, error) {
+	ref, err := tb.sharedObjectRef(ctx, id)
+	if err != nil {
+		return suiptb.Argument{}, err
+	}
+	return ptb.MustObj(suiptb.ObjectArg{SharedObject: &suiptb.SharedObjectArg{
+		Id:                   ref.ObjectId,
+		InitialSharedVersion: ref.Version,
+		Mutable:              true,
+	}}), nil
+}
+
+// spPosition returns the stability pool position owned by owner, or nil
+// when it has none. Of several positions, the first listed is used.
+func (tb *TransactionBuilder) spPosition(ctx context.Context, owner *sui.Address) (*sui.ObjectRef, error) {
+	res, err := tb.client.GetOwnedObjects(ctx, &suiclient.GetOwnedObjectsRequest{
+		Address: owner,
+		Query: &suiclient.SuiObjectResponseQuery{
+			Filter: &suiclient.SuiObjectDataFilter{StructType: &sui.StructTag{
+				Address:    tb.packageId,
+				Module:     "stability_pool",
+				Name:       "SPPosition",
+				TypeParams: []sui.TypeTag{tb.ftokenTypeTag()},
+			}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stability pool position: %w", err)
+	}
+	for _, obj := range res.Data {
+		if obj.Data != nil {
+			return obj.Data.Ref(), nil
+		}
+	}
+	return nil, nil
+}
+
+// ftokenUnits converts amount of fTokens to base units
+func (tb *TransactionBuilder) ftokenUnits(ctx context.Context, amount decimal.Decimal) (string, uint64, error) {
+	coinType := fmt.Sprintf("%s::ftoken::FTOKEN", tb.ftokenPackageId)
+	metadata, err := tb.coinMetadata(ctx, coinType)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get input token coin_metadata: %w", err)
+	}
+	return coinType, amount.Mul(decimal.New(1, int32(metadata.Decimals))).BigInt().Uint64(), nil
+}
+
+// finishUserTransaction sets the gas of pt sent by sender, paid by the
+// sponsor when sponsored, and returns it ready to sign
+func (tb *TransactionBuilder) finishUserTransaction(ctx context.Context, sender *sui.Address, pt suiptb.ProgrammableTransaction, mode TxBuildMode, sponsored bool, metadata map[string]string) (*UnsignedTransaction, error) {
+	gasUsed, budget, err := tb.estimateGas(ctx, sender, pt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	var tx suiptb.TransactionData
+	if sponsored {
+		tx, err = tb.sponsoredTransactionData(ctx, sender, pt, budget)
+	} else {
+		var gasCoins suiclient.Coins
+		gasCoins, err = tb.gasCoins(ctx, sender, budget)
+		tx = suiptb.NewTransactionData(sender, pt, gasCoins.CoinRefs(), budget, suiclient.DefaultGasPrice)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	txBytes, err := tb.marshalTransaction(ctx, tx, mode, sponsored)
+	if err != nil {
+		return nil, err
+	}
+	metadata["network"] = tb.network
+	metadata["mode"] = string(mode)
+	return &UnsignedTransaction{
+		TransactionBlockBytes: txBytes,
+		GasEstimate:           gasUsed,
+		GasBudget:             budget,
+		Metadata:              tb.withSponsorMetadata(metadata, sponsored),
+	}, nil
+}
+
+// BuildSPDepositTransaction builds a deposit of the user's fTokens into the
+// stability pool. A position is created and sent to users without one.
+func (tb *TransactionBuilder) BuildSPDepositTransaction(ctx context.Context, req SPDepositTxRequest) (*UnsignedTransaction, error) {
+	coinType, amount, err := tb.ftokenUnits(ctx, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	coins, err := tb.ownerCoins(ctx, req.UserAddress, &coinType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin object: %w", err)
+	}
+	inCoins, err := selectCoins(coins, amount, tb.maxInputCoins)
+	if err != nil {
+		return nil, err
+	}
+	position, err := tb.spPosition(ctx, req.UserAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	ptb := suiptb.NewTransactionDataTransactionBuilder()
+	poolArg, err := tb.sharedObjectArg(ctx, ptb, tb.poolId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool object: %w", err)
+	}
+	var positionArg suiptb.Argument
+	if position != nil {
+		positionArg = ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: position})
+	} else {
+		positionArg = ptb.Command(suiptb.Command{
+			MoveCall: &suiptb.ProgrammableMoveCall{
+				Package:       tb.packageId,
+				Module:        "stability_pool",
+				Function:      "create_position",
+				TypeArguments: []sui.TypeTag{tb.ftokenTypeTag()},
+				Arguments:     []suiptb.Argument{},
+			},
+		})
+	}
+	splitCoinArg := splitFromCoins(ptb, inCoins, amount)
+	ptb.Command(suiptb.Command{
+		MoveCall: &suiptb.ProgrammableMoveCall{
+			Package:       tb.packageId,
+			Module:        "stability_pool",
+			Function:      "deposit_f",
+			TypeArguments: []sui.TypeTag{tb.ftokenTypeTag()},
+			Arguments:     []suiptb.Argument{poolArg, positionArg, splitCoinArg},
+		},
+	})
+	if position == nil {
+		ptb.Command(suiptb.Command{
+			TransferObjects: &suiptb.ProgrammableTransferObjects{
+				Objects: []suiptb.Argument{positionArg},
+				Address: ptb.MustPure(req.UserAddress),
+			},
+		})
+	}
+
+	return tb.finishUserTransaction(ctx, req.UserAddress, ptb.Finish(), req.Mode, req.Sponsored, map[string]string{
+		"action":      "sp_deposit",
+		"tokenType":   "ftoken",
+		"amount":      req.Amount.String(),
+		"newPosition": fmt.Sprintf("%t", position == nil),
+	})
+}
+
+// BuildSPWithdrawTransaction builds a withdrawal of fTokens from the user's
+// stability pool position
+func (tb *TransactionBuilder) BuildSPWithdrawTransaction(ctx context.Context, req SPWithdrawTxRequest) (*UnsignedTransaction, error) {
+	_, amount, err := tb.ftokenUnits(ctx, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	position, err := tb.spPosition(ctx, req.UserAddress)
+	if err != nil {
+		return nil, err
+	}
+	if position == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoSPPosition, req.UserAddress)
+	}
+
+	ptb := suiptb.NewTransactionDataTransactionBuilder()
+	poolArg, err := tb.sharedObjectArg(ctx, ptb, tb.poolId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool object: %w", err)
+	}
+	withdrawnArg := ptb.Command(suiptb.Command{
+		MoveCall: &suiptb.ProgrammableMoveCall{
+			Package:       tb.packageId,
+			Module:        "stability_pool",
+			Function:      "withdraw_f",
+			TypeArguments: []sui.TypeTag{tb.ftokenTypeTag()},
+			Arguments: []suiptb.Argument{
+				poolArg,
+				ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: position}),
+				ptb.MustPure(amount),
+			},
+		},
+	})
+	ptb.Command(suiptb.Command{
+		TransferObjects: &suiptb.ProgrammableTransferObjects{
+			Objects: []suiptb.Argument{withdrawnArg},
+			Address: ptb.MustPure(req.UserAddress),
+		},
+	})
+
+	return tb.finishUserTransaction(ctx, req.UserAddress, ptb.Finish(), req.Mode, req.Sponsored, map[string]string{
+		"action":    "sp_withdraw",
+		"tokenType": "ftoken",
+		"amount":    req.Amount.String(),
+	})
+}
+
+// BuildSPClaimTransaction builds a claim of the SUI rewards accrued to the
+// user's stability pool position
+func (tb *TransactionBuilder) BuildSPClaimTransaction(ctx context.Context, req SPClaimTxRequest) (*UnsignedTransaction, error) {
+	position, err := tb.spPosition(ctx, req.UserAddress)
+	if err != nil {
+		return nil, err
+	}
+	if position == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoSPPosition, req.UserAddress)
+	}
+
+	ptb := suiptb.NewTransactionDataTransactionBuilder()
+	protocolArg, err := tb.sharedObjectArg(ctx, ptb, tb.protocolId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get protocol object: %w", err)
+	}
+	poolArg, err := tb.sharedObjectArg(ctx, ptb, tb.poolId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool object: %w", err)
+	}
+	rewardArg := ptb.Command(suiptb.Command{
+		MoveCall: &suiptb.ProgrammableMoveCall{
+			Package:       tb.packageId,
+			Module:        "leafsii",
+			Function:      "claim_sp_rewards",
+			TypeArguments: []sui.TypeTag{tb.ftokenTypeTag(), tb.xtokenTypeTag()},
+			Arguments: []suiptb.Argument{
+				protocolArg,
+				poolArg,
+				ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: position}),
+			},
+		},
+	})
+	ptb.Command(suiptb.Command{
+		TransferObjects: &suiptb.ProgrammableTransferObjects{
+			Objects: []suiptb.Argument{rewardArg},
+			Address: ptb.MustPure(req.UserAddress),
+		},
+	})
+
+	return tb.finishUserTransaction(ctx, req.UserAddress, ptb.Finish(), req.Mode, req.Sponsored, map[string]string{
+		"action":    "sp_claim",
+		"tokenType": "sui",
+	})
+}
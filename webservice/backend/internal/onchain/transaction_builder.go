@@ -4,13 +4,14 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/fardream/go-bcs/bcs"
 	"github.com/pattonkan/sui-go/sui"
 	"github.com/pattonkan/sui-go/sui/suiptb"
 	"github.com/pattonkan/sui-go/suiclient"
-	"github.com/pattonkan/sui-go/suisigner"
-	"github.com/pattonkan/sui-go/suisigner/suicrypto"
+	"github.com/pattonkan/sui-go/suiclient/conn"
 	"github.com/pattonkan/sui-go/utils/unit"
 	"github.com/shopspring/decimal"
 )
@@ -23,24 +24,32 @@ const (
 	TxBuildModeDevInspect TxBuildMode = "devinspect"
 )
 
-// MintTxRequest contains parameters for building mint transactions
+// MintTxRequest contains parameters for building mint transactions.
+// Sponsored transactions have their gas paid by the builder's sponsor.
 type MintTxRequest struct {
 	OutTokenType string
 	Amount       decimal.Decimal
 	UserAddress  *sui.Address
 	Mode         TxBuildMode
+	Sponsored    bool
 }
 
-// RedeemTxRequest contains parameters for building redeem transactions
+// RedeemTxRequest contains parameters for building redeem transactions.
+// Sponsored transactions have their gas paid by the builder's sponsor.
 type RedeemTxRequest struct {
 	InTokenType string
 	Amount      decimal.Decimal
 	UserAddress *sui.Address
 	Mode        TxBuildMode
+	Sponsored   bool
 }
 
+// UpdateOracleTxRequest contains parameters for building oracle update
+// transactions. SignerAddress owns the AdminCap and defaults to the
+// builder's admin address. NewPrice is in 1e6 USD and must be left zero
+// when the builder has a price feed, which provides it instead.
 type UpdateOracleTxRequest struct {
-	SingerAddress *sui.Address
+	SignerAddress *sui.Address
 	NewPrice      uint64
 	Mode          TxBuildMode
 }
@@ -50,20 +59,29 @@ type TransactionBuilderInterface interface {
 	BuildMintTransaction(ctx context.Context, req MintTxRequest) (*UnsignedTransaction, error)
 	BuildRedeemTransaction(ctx context.Context, req RedeemTxRequest) (*UnsignedTransaction, error)
 	BuildUpdateOracleTransaction(ctx context.Context, req UpdateOracleTxRequest) (*UnsignedTransaction, error)
+	BuildConsolidateCoinsTransaction(ctx context.Context, req ConsolidateCoinsTxRequest) (*UnsignedTransaction, error)
+	BuildSPDepositTransaction(ctx context.Context, req SPDepositTxRequest) (*UnsignedTransaction, error)
+	BuildSPWithdrawTransaction(ctx context.Context, req SPWithdrawTxRequest) (*UnsignedTransaction, error)
+	BuildSPClaimTransaction(ctx context.Context, req SPClaimTxRequest) (*UnsignedTransaction, error)
+	ValidateTransaction(ctx context.Context, sender *sui.Address, txBytes []byte, mode TxBuildMode) (*TransactionValidation, error)
 }
 
 // TransactionSubmitterInterface defines the interface for submitting signed transactions
 type TransactionSubmitterInterface interface {
-	SubmitSignedTransaction(ctx context.Context, txBytes, signature string) (*TransactionResult, error)
+	SubmitSignedTransaction(ctx context.Context, txBytes string, signatures ...string) (*TransactionResult, error)
 }
 
+// TransactionResult is the outcome of an executed transaction. Status is
+// "success" or "failure", with Error set on failure.
 type TransactionResult struct {
 	TransactionDigest string
 	Status            string
+	Error             string
 }
 
 type TransactionBuilder struct {
-	client          *suiclient.ClientImpl
+	client          SuiRPC
+	rpc             rpcCaller
 	packageId       *sui.PackageId
 	protocolId      *sui.ObjectId
 	poolId          *sui.ObjectId
@@ -72,6 +90,29 @@ type TransactionBuilder struct {
 	xtokenPackageId *sui.PackageId
 	rpcURL          string
 	network         string
+	gasSafetyMargin float64
+	maxInputCoins   int
+	adminAddress    *sui.Address
+	sponsor         *Sponsor
+	priceFeed       PriceFeed
+	objects         *ObjectCache // nil reads objects on every build
+}
+
+// DefaultGasSafetyMargin is the share added to the dry-run gas estimate of
+// a transaction to set its gas budget
+const DefaultGasSafetyMargin = 0.2
+
+// TransactionBuilderOption configures a TransactionBuilder
+type TransactionBuilderOption func(*TransactionBuilder)
+
+// WithGasSafetyMargin sets the share added to dry-run gas estimates when
+// setting gas budgets. Negative margins are ignored.
+func WithGasSafetyMargin(margin float64) TransactionBuilderOption {
+	return func(tb *TransactionBuilder) {
+		if margin >= 0 {
+			tb.gasSafetyMargin = margin
+		}
+	}
 }
 
 func NewTransactionBuilder(
@@ -79,10 +120,12 @@ func NewTransactionBuilder(
 	packageId *sui.PackageId,
 	protocolId, poolId, adminCapId *sui.ObjectId,
 	ftokenPackageId, xtokenPackageId *sui.PackageId,
+	opts ...TransactionBuilderOption,
 ) *TransactionBuilder {
 	client := suiclient.NewClient(rpcURL)
-	return &TransactionBuilder{
+	tb := &TransactionBuilder{
 		client:          client,
+		rpc:             conn.NewHttpClient(rpcURL),
 		packageId:       packageId,
 		protocolId:      protocolId,
 		poolId:          poolId,
@@ -91,6 +134,28 @@ func NewTransactionBuilder(
 		xtokenPackageId: xtokenPackageId,
 		rpcURL:          rpcURL,
 		network:         network,
+		gasSafetyMargin: DefaultGasSafetyMargin,
+		maxInputCoins:   DefaultMaxInputCoins,
+	}
+	for _, opt := range opts {
+		opt(tb)
+	}
+	return tb
+}
+
+// WithAdminAddress sets the owner of the AdminCap, which signs oracle
+// updates built without a signer address.
+func WithAdminAddress(addr *sui.Address) TransactionBuilderOption {
+	return func(tb *TransactionBuilder) {
+		tb.adminAddress = addr
+	}
+}
+
+// WithPriceFeed makes oracle updates take their price from feed rather
+// than from the operator.
+func WithPriceFeed(feed PriceFeed) TransactionBuilderOption {
+	return func(tb *TransactionBuilder) {
+		tb.priceFeed = feed
 	}
 }
 
@@ -104,6 +169,7 @@ func NewTransactionBuilderWithClient(
 ) *TransactionBuilder {
 	return &TransactionBuilder{
 		client:          client,
+		rpc:             conn.NewHttpClient(rpcURL),
 		packageId:       packageId,
 		protocolId:      protocolId,
 		poolId:          poolId,
@@ -111,83 +177,121 @@ func NewTransactionBuilderWithClient(
 		xtokenPackageId: xtokenPackageId,
 		rpcURL:          rpcURL,
 		network:         network,
+		gasSafetyMargin: DefaultGasSafetyMargin,
+		maxInputCoins:   DefaultMaxInputCoins,
 	}
 }
 
 type UnsignedTransaction struct {
 	TransactionBlockBytes []byte
-	GasEstimate           uint64
+	GasEstimate           uint64 // Gas the dry run used, in MIST
+	GasBudget             uint64 // Budget set on the transaction, in MIST
 	Metadata              map[string]string
 }
 
-func (tb *TransactionBuilder) BuildMintTransaction(ctx context.Context, req MintTxRequest) (*UnsignedTransaction, error) {
-	protocolGetObject, err := tb.client.GetObject(ctx, &suiclient.GetObjectRequest{
-		ObjectId: tb.protocolId,
-		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
+// estimateGas dry runs pt from sender and returns the gas it uses and the
+// gas budget to set for it
+func (tb *TransactionBuilder) estimateGas(ctx context.Context, sender *sui.Address, pt suiptb.ProgrammableTransaction) (uint64, uint64, error) {
+	tx := suiptb.NewTransactionData(
+		sender,
+		pt,
+		[]*sui.ObjectRef{},
+		suiclient.DefaultGasBudget,
+		suiclient.DefaultGasPrice,
+	)
+	kindBytes, err := bcs.Marshal(tx.V1.Kind)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to marshal transaction kind: %w", err)
+	}
+	res, err := tb.client.DevInspectTransactionBlock(ctx, &suiclient.DevInspectTransactionBlockRequest{
+		SenderAddress: sender,
+		TxKindBytes:   kindBytes,
+		GasPrice:      sui.NewBigInt(suiclient.DefaultGasPrice),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get protocol object: %w", err)
+		return 0, 0, fmt.Errorf("failed to dry run transaction: %w", err)
+	}
+	if res.Error != "" {
+		return 0, 0, tb.errDryRun(ctx, res.Error)
+	}
+	effects := res.Effects.Data.V1
+	if effects == nil {
+		return 0, 0, fmt.Errorf("dry run returned no effects")
+	}
+	if effects.Status.Status != suiclient.ExecutionStatusSuccess {
+		return 0, 0, tb.errDryRun(ctx, effects.Status.Error)
+	}
+	estimate := gasEstimate(effects.GasUsed)
+	return estimate, gasBudget(estimate, tb.gasSafetyMargin), nil
+}
+
+// gasEstimate returns the gas a transaction is charged: its computation
+// cost plus the storage it pays for net of rebates, but never less than
+// the computation cost, which the budget must cover before rebates apply
+func gasEstimate(used suiclient.GasCostSummary) uint64 {
+	computation := bigIntUint64(used.ComputationCost)
+	storage := bigIntUint64(used.StorageCost)
+	rebate := bigIntUint64(used.StorageRebate)
+	if storage > rebate {
+		return computation + storage - rebate
+	}
+	return computation
+}
+
+// gasBudget adds margin to estimate, and never goes below the minimum
+// budget the network accepts
+func gasBudget(estimate uint64, margin float64) uint64 {
+	budget := uint64(math.Ceil(float64(estimate) * (1 + margin)))
+	if minimum := minGasBudgetUnits * suiclient.DefaultGasPrice; budget < minimum {
+		return minimum
 	}
-	protocolRef := protocolGetObject.Data.RefSharedObject()
+	return budget
+}
 
-	poolGetObject, err := tb.client.GetObject(ctx, &suiclient.GetObjectRequest{
-		ObjectId: tb.poolId,
-		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
-	})
+// minGasBudgetUnits is the smallest gas budget the network accepts, in
+// gas units
+const minGasBudgetUnits = 1000
+
+func bigIntUint64(v *sui.BigInt) uint64 {
+	if v == nil || v.Int == nil {
+		return 0
+	}
+	return v.Uint64()
+}
+
+func (tb *TransactionBuilder) BuildMintTransaction(ctx context.Context, req MintTxRequest) (*UnsignedTransaction, error) {
+	protocolRef, err := tb.sharedObjectRef(ctx, tb.protocolId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pool object: %w", err)
+		return nil, fmt.Errorf("failed to get protocol object: %w", err)
 	}
-	poolRef := poolGetObject.Data.RefSharedObject()
 
-	coinPages, err := tb.client.GetCoins(ctx, &suiclient.GetCoinsRequest{Owner: req.UserAddress})
+	poolRef, err := tb.sharedObjectRef(ctx, tb.poolId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get coin object: %w", err)
+		return nil, fmt.Errorf("failed to get pool object: %w", err)
 	}
-	coins := suiclient.Coins(coinPages.Data)
 
 	// Convert amount to the appropriate unit (assuming 9 decimal places for Sui tokens)
 	amountMist := req.Amount.Mul(decimal.New(1, unit.SuiDecimal)).BigInt().Uint64()
 
 	ptb := suiptb.NewTransactionDataTransactionBuilder()
 
-	if coins.TotalBalance().Uint64() < req.Amount.BigInt().Uint64() {
-		return nil, fmt.Errorf("not enough balance")
-	}
-
-	var splitTargetCoinArg suiptb.Argument
-	var mergeCoinsArgs []suiptb.Argument
-	var bal uint64
-	for i, coin := range coins {
-		if bal > amountMist {
-			break
+	// The SUI paid in is split off the gas coin, which the coins paying for
+	// gas are merged into, unless the sponsor's coins pay for gas
+	var splitCoinArg suiptb.Argument
+	if req.Sponsored {
+		coins, err := tb.ownerCoins(ctx, req.UserAddress, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get coin object: %w", err)
 		}
-		bal += coin.Balance.Uint64()
-
-		if i == 0 {
-			splitTargetCoinArg = ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: coin.Ref()})
-		} else {
-			mergeCoinsArgs = append(mergeCoinsArgs, ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: coin.Ref()}))
+		inCoins, err := selectCoins(coins, amountMist, tb.maxInputCoins)
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	var splitCoinArg suiptb.Argument
-	if len(mergeCoinsArgs) < 1 {
-		splitCoinArg = ptb.Command(suiptb.Command{
-			SplitCoins: &suiptb.ProgrammableSplitCoins{
-				Coin:    splitTargetCoinArg,
-				Amounts: []suiptb.Argument{ptb.MustPure(amountMist)},
-			},
-		})
+		splitCoinArg = splitFromCoins(ptb, inCoins, amountMist)
 	} else {
-		ptb.Command(suiptb.Command{
-			MergeCoins: &suiptb.ProgrammableMergeCoins{
-				Destination: splitTargetCoinArg,
-				Sources:     mergeCoinsArgs,
-			},
-		})
 		splitCoinArg = ptb.Command(suiptb.Command{
 			SplitCoins: &suiptb.ProgrammableSplitCoins{
-				Coin:    splitTargetCoinArg,
+				Coin:    suiptb.Argument{GasCoin: &sui.EmptyEnum{}},
 				Amounts: []suiptb.Argument{ptb.MustPure(amountMist)},
 			},
 		})
@@ -287,55 +391,54 @@ func (tb *TransactionBuilder) BuildMintTransaction(ctx context.Context, req Mint
 
 	pt := ptb.Finish()
 
-	tx := suiptb.NewTransactionData(
-		req.UserAddress,
-		pt,
-		[]*sui.ObjectRef{coins.CoinRefs()[len(coins)-1]},
-		suiclient.DefaultGasBudget,
-		suiclient.DefaultGasPrice,
-	)
-
-	var txBytes []byte
-	if req.Mode == TxBuildModeDevInspect {
-		txBytes, err = bcs.Marshal(tx.V1.Kind)
+	gasUsed, budget, err := tb.estimateGas(ctx, req.UserAddress, pt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	var tx suiptb.TransactionData
+	if req.Sponsored {
+		tx, err = tb.sponsoredTransactionData(ctx, req.UserAddress, pt, budget)
 	} else {
-		txBytes, err = bcs.Marshal(tx)
+		var coins, gasCoins suiclient.Coins
+		if coins, err = tb.ownerCoins(ctx, req.UserAddress, nil); err != nil {
+			return nil, fmt.Errorf("failed to get coin object: %w", err)
+		}
+		gasCoins, err = selectCoins(coins, amountMist+budget, min(tb.maxInputCoins, maxGasPaymentCoins))
+		tx = suiptb.NewTransactionData(req.UserAddress, pt, gasCoins.CoinRefs(), budget, suiclient.DefaultGasPrice)
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+		return nil, err
+	}
+
+	txBytes, err := tb.marshalTransaction(ctx, tx, req.Mode, req.Sponsored)
+	if err != nil {
+		return nil, err
 	}
 
 	return &UnsignedTransaction{
 		TransactionBlockBytes: txBytes,
-		GasEstimate:           suiclient.DefaultGasBudget,
-		Metadata: map[string]string{
+		GasEstimate:           gasUsed,
+		GasBudget:             budget,
+		Metadata: tb.withSponsorMetadata(map[string]string{
 			"action":    "mint",
 			"tokenType": req.OutTokenType,
 			"amount":    req.Amount.String(),
 			"network":   tb.network,
 			"mode":      string(req.Mode),
-		},
+		}, req.Sponsored),
 	}, nil
 }
 
 func (tb *TransactionBuilder) BuildRedeemTransaction(ctx context.Context, req RedeemTxRequest) (*UnsignedTransaction, error) {
-	protocolGetObject, err := tb.client.GetObject(ctx, &suiclient.GetObjectRequest{
-		ObjectId: tb.protocolId,
-		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
-	})
+	protocolRef, err := tb.sharedObjectRef(ctx, tb.protocolId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get protocol object: %w", err)
 	}
-	protocolRef := protocolGetObject.Data.RefSharedObject()
 
-	poolGetObject, err := tb.client.GetObject(ctx, &suiclient.GetObjectRequest{
-		ObjectId: tb.poolId,
-		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
-	})
+	poolRef, err := tb.sharedObjectRef(ctx, tb.poolId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pool object: %w", err)
 	}
-	poolRef := poolGetObject.Data.RefSharedObject()
 
 	coinType := ""
 	switch req.InTokenType {
@@ -346,63 +449,25 @@ func (tb *TransactionBuilder) BuildRedeemTransaction(ctx context.Context, req Re
 	default:
 		return nil, fmt.Errorf("unsupported token type: %s", req.InTokenType)
 	}
-	coinPages, err := tb.client.GetCoins(ctx, &suiclient.GetCoinsRequest{Owner: req.UserAddress, CoinType: &coinType})
+	coins, err := tb.ownerCoins(ctx, req.UserAddress, &coinType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get coin object: %w", err)
 	}
-	coins := suiclient.Coins(coinPages.Data)
 
 	// Convert amount to the appropriate unit
-	intTokenMetadata, err := tb.client.GetCoinMetadata(ctx, coinType)
+	intTokenMetadata, err := tb.coinMetadata(ctx, coinType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get input token coin_metadata: %w", err)
 	}
 	amountMist := req.Amount.Mul(decimal.New(1, int32(intTokenMetadata.Decimals))).BigInt().Uint64()
 
-	ptb := suiptb.NewTransactionDataTransactionBuilder()
-
-	if coins.TotalBalance().Uint64() < req.Amount.BigInt().Uint64() {
-		return nil, fmt.Errorf("not enough balance")
-	}
-
-	var splitTargetInCoinArg suiptb.Argument
-	var mergeInCoinsArgs []suiptb.Argument
-	var bal uint64
-	for i, coin := range coins {
-		if bal > amountMist {
-			break
-		}
-		bal += coin.Balance.Uint64()
-
-		if i == 0 {
-			splitTargetInCoinArg = ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: coin.Ref()})
-		} else {
-			mergeInCoinsArgs = append(mergeInCoinsArgs, ptb.MustObj(suiptb.ObjectArg{ImmOrOwnedObject: coin.Ref()}))
-		}
+	inCoins, err := selectCoins(coins, amountMist, tb.maxInputCoins)
+	if err != nil {
+		return nil, err
 	}
 
-	var splitCoinArg suiptb.Argument
-	if len(mergeInCoinsArgs) < 1 {
-		splitCoinArg = ptb.Command(suiptb.Command{
-			SplitCoins: &suiptb.ProgrammableSplitCoins{
-				Coin:    splitTargetInCoinArg,
-				Amounts: []suiptb.Argument{ptb.MustPure(amountMist)},
-			},
-		})
-	} else {
-		ptb.Command(suiptb.Command{
-			MergeCoins: &suiptb.ProgrammableMergeCoins{
-				Destination: splitTargetInCoinArg,
-				Sources:     mergeInCoinsArgs,
-			},
-		})
-		splitCoinArg = ptb.Command(suiptb.Command{
-			SplitCoins: &suiptb.ProgrammableSplitCoins{
-				Coin:    splitTargetInCoinArg,
-				Amounts: []suiptb.Argument{ptb.MustPure(amountMist)},
-			},
-		})
-	}
+	ptb := suiptb.NewTransactionDataTransactionBuilder()
+	splitCoinArg := splitFromCoins(ptb, inCoins, amountMist)
 
 	var redeemedArg suiptb.Argument
 	switch req.InTokenType {
@@ -497,75 +562,84 @@ func (tb *TransactionBuilder) BuildRedeemTransaction(ctx context.Context, req Re
 
 	pt := ptb.Finish()
 
-	gasGetCoins, err := tb.client.GetCoins(ctx, &suiclient.GetCoinsRequest{Owner: req.UserAddress})
+	gasUsed, budget, err := tb.estimateGas(ctx, req.UserAddress, pt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get gas coin: %w", err)
+		return nil, fmt.Errorf("failed to estimate gas: %w", err)
 	}
-	tx := suiptb.NewTransactionData(
-		req.UserAddress,
-		pt,
-		[]*sui.ObjectRef{gasGetCoins.Data[0].Ref()},
-		suiclient.DefaultGasBudget,
-		suiclient.DefaultGasPrice,
-	)
-
-	var txBytes []byte
-	if req.Mode == TxBuildModeDevInspect {
-		txBytes, err = bcs.Marshal(tx.V1.Kind)
+	var tx suiptb.TransactionData
+	if req.Sponsored {
+		tx, err = tb.sponsoredTransactionData(ctx, req.UserAddress, pt, budget)
 	} else {
-		txBytes, err = bcs.Marshal(tx)
+		var gasCoins suiclient.Coins
+		gasCoins, err = tb.gasCoins(ctx, req.UserAddress, budget)
+		tx = suiptb.NewTransactionData(req.UserAddress, pt, gasCoins.CoinRefs(), budget, suiclient.DefaultGasPrice)
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+		return nil, err
+	}
+
+	txBytes, err := tb.marshalTransaction(ctx, tx, req.Mode, req.Sponsored)
+	if err != nil {
+		return nil, err
 	}
 
 	return &UnsignedTransaction{
 		TransactionBlockBytes: txBytes,
-		GasEstimate:           suiclient.DefaultGasBudget,
-		Metadata: map[string]string{
+		GasEstimate:           gasUsed,
+		GasBudget:             budget,
+		Metadata: tb.withSponsorMetadata(map[string]string{
 			"action":    "redeem",
 			"tokenType": req.InTokenType,
 			"amount":    req.Amount.String(),
 			"network":   tb.network,
 			"mode":      string(req.Mode),
-		},
+		}, req.Sponsored),
 	}, nil
 }
 
-// BuildUpdateOracleTransaction builds an unsigned transaction for oracle updates
+// BuildUpdateOracleTransaction builds an unsigned transaction for oracle
+// updates, sent by the AdminCap owner. The transaction is signed by that
+// owner and sent with SubmitSignedTransaction.
 //
 //	curl -X POST http://localhost:8080/v1/oracle/update/build \
 //	  -H "Content-Type: application/json" \
 //	  -d '{
 //	    "mode": "execution",
+//	    "sender": "0x...",
 //	    "price": 4467890
 //	  }'
+//
+// With a price feed, the price is omitted and read from the feed. The
+// protocol only accepts mock oracles, so the feed price is carried by one.
 func (tb *TransactionBuilder) BuildUpdateOracleTransaction(ctx context.Context, req UpdateOracleTxRequest) (*UnsignedTransaction, error) {
-	protocolGetObject, err := tb.client.GetObject(ctx, &suiclient.GetObjectRequest{
-		ObjectId: tb.protocolId,
-		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get protocol object: %w", err)
+	metadata := map[string]string{"oracleSource": OracleSourceMock}
+	newPrice := req.NewPrice
+	switch {
+	case tb.priceFeed != nil && newPrice != 0:
+		return nil, fmt.Errorf("price is read from the price feed and must be omitted")
+	case tb.priceFeed != nil:
+		feedPrice, err := tb.priceFeed.LatestPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		newPrice = feedPrice.PriceE6
+		metadata["oracleSource"] = feedPrice.Source
+		metadata["feedObjectId"] = feedPrice.FeedObjectId.String()
+		metadata["confidence"] = fmt.Sprintf("%d", feedPrice.ConfidenceE6)
+		metadata["publishTime"] = feedPrice.PublishTime.Format(time.RFC3339)
+	case newPrice == 0:
+		return nil, fmt.Errorf("price is required")
 	}
-	protocolRef := protocolGetObject.Data.RefSharedObject()
-
-	signer := suisigner.NewSignerByIndex(suisigner.TEST_SEED, suicrypto.KeySchemeFlagDefault, 0)
 
-	adminCapGetObjectRes, err := tb.client.GetObject(ctx, &suiclient.GetObjectRequest{
-		ObjectId: tb.adminCapId, // FIXME pass AdminCap
-		Options:  &suiclient.SuiObjectDataOptions{ShowOwner: true},
-	})
+	protocolRef, err := tb.sharedObjectRef(ctx, tb.protocolId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get coin object: %w", err)
+		return nil, fmt.Errorf("failed to get protocol object: %w", err)
 	}
-	adminCapRef := adminCapGetObjectRes.Data.Ref()
 
-	coinPages, err := tb.client.GetCoins(ctx, &suiclient.GetCoinsRequest{Owner: signer.Address})
+	sender, adminCapRef, err := tb.adminCap(ctx, req.SignerAddress)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get coin object: %w", err)
+		return nil, err
 	}
-	coins := suiclient.Coins(coinPages.Data)
 
 	ptb := suiptb.NewTransactionDataTransactionBuilder()
 
@@ -587,7 +661,7 @@ func (tb *TransactionBuilder) BuildUpdateOracleTransaction(ctx context.Context,
 				}},
 			},
 			Arguments: []suiptb.Argument{
-				ptb.MustForceSeparatePure(req.NewPrice),
+				ptb.MustForceSeparatePure(newPrice),
 				clockArg,
 			},
 		},
@@ -630,17 +704,25 @@ func (tb *TransactionBuilder) BuildUpdateOracleTransaction(ctx context.Context,
 	ptb.Command(suiptb.Command{
 		TransferObjects: &suiptb.ProgrammableTransferObjects{
 			Objects: []suiptb.Argument{oracleArg},
-			Address: ptb.MustPure(signer.Address),
+			Address: ptb.MustPure(sender),
 		},
 	})
 
 	pt := ptb.Finish()
 
+	gasUsed, budget, err := tb.estimateGas(ctx, sender, pt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	gasCoins, err := tb.gasCoins(ctx, sender, budget)
+	if err != nil {
+		return nil, err
+	}
 	tx := suiptb.NewTransactionData(
-		signer.Address,
+		sender,
 		pt,
-		[]*sui.ObjectRef{coins.CoinRefs()[len(coins)-1]},
-		suiclient.DefaultGasBudget,
+		gasCoins.CoinRefs(),
+		budget,
 		suiclient.DefaultGasPrice,
 	)
 
@@ -654,58 +736,66 @@ func (tb *TransactionBuilder) BuildUpdateOracleTransaction(ctx context.Context,
 		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
 	}
 
-	res, err := tb.client.SignAndExecuteTransaction(ctx, signer, txBytes, &suiclient.SuiTransactionBlockResponseOptions{
-		ShowInput:          true,
-		ShowRawInput:       true,
-		ShowEffects:        true,
-		ShowEvents:         true,
-		ShowObjectChanges:  true,
-		ShowBalanceChanges: true,
-		ShowRawEffects:     true,
-	})
-	if err != nil || !res.Effects.Data.IsSuccess() {
-		return nil, fmt.Errorf("ExecuteTransactionBlock failed or not success: %w", err)
-	}
-
+	metadata["action"] = "update_oracle"
+	metadata["sender"] = sender.String()
+	metadata["price"] = fmt.Sprintf("%d", newPrice)
+	metadata["mode"] = string(req.Mode)
 	return &UnsignedTransaction{
 		TransactionBlockBytes: txBytes,
-		GasEstimate:           suiclient.DefaultGasBudget,
-		Metadata: map[string]string{
-			"action": "update_oracle",
-			"mode":   string(req.Mode),
-		},
+		GasEstimate:           gasUsed,
+		GasBudget:             budget,
+		Metadata:              metadata,
 	}, nil
 }
 
-// SubmitSignedTransaction submits a signed transaction to the Sui network
+// SubmitSignedTransaction submits a transaction with the base64
+// serialized Sui signatures of its signers: one per key, or the combined
+// signature of a multisig sender. Transactions whose gas the sponsor pays
+// are co-signed. The result carries the digest and effects status even
+// when execution failed on chain.
 func (tb *TransactionBuilder) SubmitSignedTransaction(
 	ctx context.Context,
-	rawTxBytes, rawSignature string,
+	rawTxBytes string,
+	rawSignatures ...string,
 ) (*TransactionResult, error) {
 	txBytes, err := base64.StdEncoding.DecodeString(rawTxBytes)
 	if err != nil {
 		return nil, fmt.Errorf("invalid base64 encoded transaction bytes: %w", err)
 	}
-
-	signatureBytes, err := base64.StdEncoding.DecodeString(rawSignature)
-	if err != nil {
-		return nil, fmt.Errorf("invalid base64 encoded signature: %w", err)
+	if len(rawSignatures) == 0 {
+		return nil, fmt.Errorf("%w: no signatures", ErrInvalidSignature)
 	}
 
-	sig := &suisigner.Signature{Ed25519SuiSignature: &suisigner.Ed25519SuiSignature{}}
-	copy(sig.Ed25519SuiSignature.Signature[:], signatureBytes)
-	response, err := tb.client.ExecuteTransactionBlock(ctx, &suiclient.ExecuteTransactionBlockRequest{
-		TxDataBytes: txBytes,
-		Signatures:  []*suisigner.Signature{sig},
-		Options:     &suiclient.SuiTransactionBlockResponseOptions{ShowEffects: true},
-		RequestType: suiclient.TxnRequestTypeWaitForLocalExecution,
-	})
-	if err != nil || !response.Effects.Data.IsSuccess() {
-		return nil, fmt.Errorf("ExecuteTransactionBlock failed or not success: %w", err)
+	signatures := make([]sui.Base64, 0, len(rawSignatures)+1)
+	for _, raw := range rawSignatures {
+		sig, err := parseSignature(raw)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, sig)
+	}
+	// Transactions whose gas the sponsor pays need its signature too
+	if tb.sponsor != nil {
+		if sender := tb.sponsor.sponsoredSender(txBytes); sender != nil {
+			sponsorSig, err := tb.sponsor.cosign(ctx, txBytes, sender)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sponsor transaction: %w", err)
+			}
+			signatures = append(signatures, sponsorSig.Bytes())
+		}
 	}
 
+	response, err := tb.executeSigned(ctx, txBytes, signatures)
+	if err != nil {
+		return nil, fmt.Errorf("ExecuteTransactionBlock failed: %w", err)
+	}
+	if response.Effects == nil || response.Effects.Data.V1 == nil {
+		return nil, fmt.Errorf("ExecuteTransactionBlock returned no effects for %s", response.Digest)
+	}
+	status := response.Effects.Data.V1.Status
 	return &TransactionResult{
-		TransactionDigest: "placeholder_digest_" + rawTxBytes[:8],
-		Status:            "success",
+		TransactionDigest: response.Digest.String(),
+		Status:            status.Status,
+		Error:             status.Error,
 	}, nil
 }
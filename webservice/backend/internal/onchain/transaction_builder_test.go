@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"strings"
 	"testing"
 	"time"
 
@@ -154,16 +153,16 @@ func TestBuildMintTransaction(t *testing.T) {
 				return
 			}
 
-			if err != nil && strings.Contains(err.Error(), "MergeCoin") && strings.Contains(err.Error(), "empty arguments") {
-				t.Skip("Known issue in TransactionBuilder: MergeCoins emitted with empty Sources. Fix builder to avoid MergeCoins when single coin.")
-			}
-
 			require.NoError(t, err)
 			require.NotEmpty(t, unsigned.TransactionBlockBytes)
 
 			// Verify mode is set in metadata
 			assert.Equal(t, string(tt.mode), unsigned.Metadata["mode"])
 
+			// The budget is the dry-run estimate plus the safety margin
+			assert.Greater(t, unsigned.GasEstimate, uint64(0))
+			assert.Equal(t, gasBudget(unsigned.GasEstimate, DefaultGasSafetyMargin), unsigned.GasBudget)
+
 			// Only execute in execution mode (not devinspect mode as it's incomplete tx)
 			if tt.mode == TxBuildModeExecution {
 				_ = signAndExecute(t, client, signer, unsigned.TransactionBlockBytes)
@@ -260,10 +259,6 @@ func TestBuildRedeemTransaction(t *testing.T) {
 				}
 				mintUnsigned, err := tb.BuildMintTransaction(ctx, mintReq)
 
-				if err != nil && strings.Contains(err.Error(), "MergeCoin") && strings.Contains(err.Error(), "empty arguments") {
-					t.Skip("Known issue in TransactionBuilder: MergeCoins emitted with empty Sources. Fix builder to avoid MergeCoins when single coin.")
-				}
-
 				require.NoError(t, err)
 				_ = signAndExecute(t, client, signer, mintUnsigned.TransactionBlockBytes)
 			}
@@ -287,10 +282,6 @@ func TestBuildRedeemTransaction(t *testing.T) {
 				return
 			}
 
-			if err != nil && strings.Contains(err.Error(), "MergeCoin") && strings.Contains(err.Error(), "empty arguments") {
-				t.Skip("Known issue in TransactionBuilder: MergeCoins emitted with empty Sources. Fix builder to avoid MergeCoins when single coin.")
-			}
-
 			require.NoError(t, err)
 			require.NotEmpty(t, redeemUnsigned.TransactionBlockBytes)
 
@@ -308,6 +299,24 @@ func TestBuildRedeemTransaction(t *testing.T) {
 	}
 }
 
+// TestGasBudget checks the gas estimate of a dry run and the margin added to it
+func TestGasBudget(t *testing.T) {
+	used := suiclient.GasCostSummary{
+		ComputationCost: sui.NewBigInt(1_000_000),
+		StorageCost:     sui.NewBigInt(3_000_000),
+		StorageRebate:   sui.NewBigInt(500_000),
+	}
+	assert.Equal(t, uint64(3_500_000), gasEstimate(used))
+
+	// Rebates never bring the estimate below the computation cost
+	used.StorageRebate = sui.NewBigInt(5_000_000)
+	assert.Equal(t, uint64(1_000_000), gasEstimate(used))
+
+	assert.Equal(t, uint64(4_200_000), gasBudget(3_500_000, 0.2))
+	assert.Equal(t, uint64(3_500_000), gasBudget(3_500_000, 0))
+	assert.Equal(t, minGasBudgetUnits*suiclient.DefaultGasPrice, gasBudget(10, 0.2))
+}
+
 // TestTransactionModesDifferent ensures execution and devinspect modes produce different bytes
 func TestTransactionModesDifferent(t *testing.T) {
 	require.NotNil(t, testProtocolId, "Test setup failed: protocolId is nil")
@@ -342,15 +351,9 @@ func TestTransactionModesDifferent(t *testing.T) {
 	}
 
 	executionTx, err := tb.BuildMintTransaction(context.Background(), executionReq)
-	if err != nil && strings.Contains(err.Error(), "MergeCoin") && strings.Contains(err.Error(), "empty arguments") {
-		t.Skip("Known issue in TransactionBuilder: MergeCoins emitted with empty Sources.")
-	}
 	require.NoError(t, err)
 
 	devinspectTx, err := tb.BuildMintTransaction(context.Background(), devinspectReq)
-	if err != nil && strings.Contains(err.Error(), "MergeCoin") && strings.Contains(err.Error(), "empty arguments") {
-		t.Skip("Known issue in TransactionBuilder: MergeCoins emitted with empty Sources.")
-	}
 	require.NoError(t, err)
 
 	// Bytes should be different between execution and devinspect modes
@@ -0,0 +1,240 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidDigest is returned for strings that are not transaction digests
+var ErrInvalidDigest = errors.New("invalid transaction digest")
+
+// Transaction statuses besides the execution statuses "success" and
+// "failure"
+const (
+	// TxStatusPending is reported for digests the node does not know yet
+	TxStatusPending = "pending"
+)
+
+const (
+	// finalizedStatusTTL caches the status of transactions in a checkpoint,
+	// which no longer changes
+	finalizedStatusTTL = time.Hour
+	// pendingStatusTTL caches the status of other transactions briefly, so
+	// that polling clients share node requests
+	pendingStatusTTL = 2 * time.Second
+	// DefaultTxWatchInterval is the time between status reads of a watched
+	// transaction
+	DefaultTxWatchInterval = time.Second
+	// txWatchTimeout bounds how long a transaction is watched
+	txWatchTimeout = 2 * time.Minute
+)
+
+// SuiTransactionReader reads executed transactions. *suiclient.ClientImpl
+// and *RPCPool implement it.
+type SuiTransactionReader interface {
+	GetTransactionBlock(ctx context.Context, req *suiclient.GetTransactionBlockRequest) (*suiclient.SuiTransactionBlockResponse, error)
+}
+
+// TransactionStatus is the state of a transaction on chain. Status is
+// TxStatusPending until the transaction is executed, then "success" or
+// "failure", with Error set on failure. A transaction is final once
+// included in a checkpoint.
+type TransactionStatus struct {
+	Digest         string            `json:"digest"`
+	Status         string            `json:"status"`
+	Finalized      bool              `json:"finalized"`
+	Checkpoint     uint64            `json:"checkpoint,omitempty"`
+	Timestamp      time.Time         `json:"timestamp,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	GasUsed        *GasUsed          `json:"gas_used,omitempty"`
+	BalanceChanges []TxBalanceChange `json:"balance_changes,omitempty"`
+}
+
+// GasUsed summarizes the gas charged to a transaction, in MIST
+type GasUsed struct {
+	ComputationCost string `json:"computation_cost"`
+	StorageCost     string `json:"storage_cost"`
+	StorageRebate   string `json:"storage_rebate"`
+}
+
+// TxBalanceChange is the change of the balance of an owner in one coin
+// type. Amount is negative for coins sent.
+type TxBalanceChange struct {
+	Owner    string `json:"owner"`
+	CoinType string `json:"coin_type"`
+	Amount   string `json:"amount"`
+}
+
+// TransactionStatusService reads the status of submitted transactions and
+// watches them until they are final.
+type TransactionStatusService struct {
+	reader   SuiTransactionReader
+	cache    *store.Cache // nil disables caching
+	logger   *zap.SugaredLogger
+	interval time.Duration
+
+	mu       sync.Mutex
+	watching map[string]bool // By digest
+}
+
+func NewTransactionStatusService(
+	reader SuiTransactionReader,
+	cache *store.Cache,
+	logger *zap.SugaredLogger,
+) *TransactionStatusService {
+	return &TransactionStatusService{
+		reader:   reader,
+		cache:    cache,
+		logger:   logger,
+		interval: DefaultTxWatchInterval,
+		watching: make(map[string]bool),
+	}
+}
+
+// GetStatus returns the status of the transaction digest
+func (s *TransactionStatusService) GetStatus(ctx context.Context, digest string) (*TransactionStatus, error) {
+	d, err := sui.NewDigest(digest)
+	if err != nil || len(*d) != 32 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDigest, digest)
+	}
+	key := fmt.Sprintf("tx-status-%s", digest)
+	if s.cache != nil {
+		var cached TransactionStatus
+		if err := s.cache.Get(ctx, key, &cached); err == nil {
+			return &cached, nil
+		}
+	}
+
+	resp, err := s.reader.GetTransactionBlock(ctx, &suiclient.GetTransactionBlockRequest{
+		Digest: d,
+		Options: &suiclient.SuiTransactionBlockResponseOptions{
+			ShowEffects:        true,
+			ShowBalanceChanges: true,
+		},
+	})
+	var status *TransactionStatus
+	switch {
+	case err != nil && strings.Contains(err.Error(), "Could not find the referenced transaction"):
+		status = &TransactionStatus{Digest: digest, Status: TxStatusPending}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get transaction %s: %w", digest, err)
+	default:
+		status = transactionStatus(digest, resp)
+	}
+
+	if s.cache != nil {
+		ttl := pendingStatusTTL
+		if status.Finalized {
+			ttl = finalizedStatusTTL
+		}
+		if err := s.cache.Set(ctx, key, status, ttl); err != nil {
+			s.logger.Warnw("Failed to cache transaction status", "digest", digest, "error", err)
+		}
+	}
+	return status, nil
+}
+
+// transactionStatus summarizes resp, the transaction digest read with its
+// effects and balance changes
+func transactionStatus(digest string, resp *suiclient.SuiTransactionBlockResponse) *TransactionStatus {
+	status := &TransactionStatus{Digest: digest, Status: TxStatusPending}
+	if resp.Effects != nil && resp.Effects.Data.V1 != nil {
+		effects := resp.Effects.Data.V1
+		status.Status = effects.Status.Status
+		status.Error = effects.Status.Error
+		status.GasUsed = &GasUsed{
+			ComputationCost: bigIntString(effects.GasUsed.ComputationCost),
+			StorageCost:     bigIntString(effects.GasUsed.StorageCost),
+			StorageRebate:   bigIntString(effects.GasUsed.StorageRebate),
+		}
+	}
+	if resp.Checkpoint != nil && resp.Checkpoint.Int != nil {
+		status.Finalized = true
+		status.Checkpoint = resp.Checkpoint.Uint64()
+	}
+	if resp.TimestampMs != nil && resp.TimestampMs.Int != nil {
+		status.Timestamp = time.UnixMilli(resp.TimestampMs.Int64()).UTC()
+	}
+	for _, change := range resp.BalanceChanges {
+		status.BalanceChanges = append(status.BalanceChanges, TxBalanceChange{
+			Owner:    ownerString(change.Owner),
+			CoinType: change.CoinType,
+			Amount:   change.Amount,
+		})
+	}
+	return status
+}
+
+func bigIntString(v *sui.BigInt) string {
+	if v == nil || v.Int == nil {
+		return "0"
+	}
+	return v.String()
+}
+
+// ownerString returns the address or object owning a balance, or the
+// kind of owner for shared and immutable objects
+func ownerString(owner suiclient.ObjectOwner) string {
+	switch {
+	case owner.ObjectOwnerInternal == nil:
+		return "Immutable"
+	case owner.AddressOwner != nil:
+		return owner.AddressOwner.String()
+	case owner.ObjectOwner != nil:
+		return owner.ObjectOwner.String()
+	case owner.SingleOwner != nil:
+		return owner.SingleOwner.String()
+	default:
+		return "Shared"
+	}
+}
+
+// Watch reads the status of digest in the background until it is final,
+// for at most two minutes, and then calls notify with it. Watching a digest
+// already watched does nothing.
+func (s *TransactionStatusService) Watch(ctx context.Context, digest string, notify func(ctx context.Context, status *TransactionStatus)) {
+	s.mu.Lock()
+	if s.watching[digest] {
+		s.mu.Unlock()
+		return
+	}
+	s.watching[digest] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.watching, digest)
+			s.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(ctx, txWatchTimeout)
+		defer cancel()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			status, err := s.GetStatus(ctx, digest)
+			if err != nil && ctx.Err() == nil {
+				s.logger.Debugw("Failed to read watched transaction", "digest", digest, "error", err)
+			}
+			if err == nil && status.Finalized {
+				notify(ctx, status)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				s.logger.Debugw("Stopped watching transaction", "digest", digest, "error", ctx.Err())
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,113 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeTransactionReader returns the transaction after executed reads, in a
+// checkpoint after finalized reads
+type fakeTransactionReader struct {
+	mu        sync.Mutex
+	reads     int
+	executed  int
+	finalized int
+}
+
+func (f *fakeTransactionReader) GetTransactionBlock(_ context.Context, req *suiclient.GetTransactionBlockRequest) (*suiclient.SuiTransactionBlockResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads++
+	if f.reads <= f.executed {
+		return nil, errors.New("Could not find the referenced transaction [TransactionDigest(x)].")
+	}
+	resp := &suiclient.SuiTransactionBlockResponse{
+		Digest: *req.Digest,
+		Effects: &suiclient.WrapperTaggedJson[suiclient.SuiTransactionBlockEffects]{
+			Data: suiclient.SuiTransactionBlockEffects{V1: &suiclient.SuiTransactionBlockEffectsV1{
+				Status: suiclient.ExecutionStatus{Status: suiclient.ExecutionStatusSuccess},
+				GasUsed: suiclient.GasCostSummary{
+					ComputationCost: &sui.BigInt{Int: big.NewInt(1000)},
+					StorageCost:     &sui.BigInt{Int: big.NewInt(2000)},
+					StorageRebate:   &sui.BigInt{Int: big.NewInt(500)},
+				},
+			}},
+		},
+		BalanceChanges: []suiclient.BalanceChange{{
+			Owner:    suiclient.ObjectOwner{ObjectOwnerInternal: &suiclient.ObjectOwnerInternal{AddressOwner: sui.MustAddressFromHex("0x1234")}},
+			CoinType: "0x2::sui::SUI",
+			Amount:   "-2500",
+		}},
+	}
+	if f.reads > f.finalized {
+		resp.Checkpoint = &sui.BigInt{Int: big.NewInt(42)}
+		resp.TimestampMs = &sui.BigInt{Int: big.NewInt(1_700_000_000_000)}
+	}
+	return resp, nil
+}
+
+func TestTransactionStatusLifecycle(t *testing.T) {
+	ctx := context.Background()
+	digest := sui.Digest(make([]byte, 32)).String()
+	svc := NewTransactionStatusService(&fakeTransactionReader{executed: 1, finalized: 2}, nil, zap.NewNop().Sugar())
+
+	status, err := svc.GetStatus(ctx, digest)
+	require.NoError(t, err)
+	assert.Equal(t, TxStatusPending, status.Status)
+	assert.False(t, status.Finalized)
+
+	status, err = svc.GetStatus(ctx, digest)
+	require.NoError(t, err)
+	assert.Equal(t, suiclient.ExecutionStatusSuccess, status.Status)
+	assert.False(t, status.Finalized)
+	assert.Equal(t, "1000", status.GasUsed.ComputationCost)
+	require.Len(t, status.BalanceChanges, 1)
+	assert.Equal(t, sui.MustAddressFromHex("0x1234").String(), status.BalanceChanges[0].Owner)
+	assert.Equal(t, "-2500", status.BalanceChanges[0].Amount)
+
+	status, err = svc.GetStatus(ctx, digest)
+	require.NoError(t, err)
+	assert.True(t, status.Finalized)
+	assert.Equal(t, uint64(42), status.Checkpoint)
+	assert.Equal(t, int64(1_700_000_000_000), status.Timestamp.UnixMilli())
+
+	_, err = svc.GetStatus(ctx, "not a digest")
+	assert.True(t, errors.Is(err, ErrInvalidDigest), "expected ErrInvalidDigest, got %v", err)
+}
+
+func TestTransactionStatusWatchNotifiesOnce(t *testing.T) {
+	digest := sui.Digest(make([]byte, 32)).String()
+	reader := &fakeTransactionReader{executed: 1, finalized: 3}
+	svc := NewTransactionStatusService(reader, nil, zap.NewNop().Sugar())
+	svc.interval = time.Millisecond
+
+	notified := make(chan *TransactionStatus, 2)
+	notify := func(_ context.Context, status *TransactionStatus) { notified <- status }
+	svc.Watch(context.Background(), digest, notify)
+	svc.Watch(context.Background(), digest, notify)
+
+	select {
+	case status := <-notified:
+		assert.True(t, status.Finalized)
+	case <-time.After(time.Second):
+		t.Fatal("watched transaction was not notified")
+	}
+	select {
+	case <-notified:
+		t.Fatal("transaction watched twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	assert.Equal(t, 4, reader.reads)
+}
@@ -23,9 +23,28 @@ type ProtocolState struct {
 	PegDeviation decimal.Decimal `json:"peg_deviation"`
 	Mode         string          `json:"mode"`
 	OracleAgeSec int64           `json:"oracle_age_sec"`
+	Fees         *ProtocolFees   `json:"fees,omitempty"` // Nil when the protocol object carries none
 	AsOf         time.Time       `json:"as_of"`
 }
 
+// ProtocolFees are the fees the protocol contract charges, in bps
+type ProtocolFees struct {
+	MintFBps          uint64 `json:"mint_f_bps"`
+	MintXBps          uint64 `json:"mint_x_bps"`
+	RedeemFBps        uint64 `json:"redeem_f_bps"`
+	RedeemXBps        uint64 `json:"redeem_x_bps"`
+	L1RedeemXBps      uint64 `json:"l1_redeem_x_bps"`     // Of xToken redeems in stability mode
+	StabilityBonusBps uint64 `json:"stability_bonus_bps"` // Paid to rebalancers
+	FeeRecipient      string `json:"fee_recipient,omitempty"`
+}
+
+// OracleState is the reserve price the protocol last took from its
+// oracle, in 1e6 USD, and when. UpdatedAt is zero before the first update.
+type OracleState struct {
+	PriceE6   uint64    `json:"price_e6"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type MoveObjectProtocol struct {
 	Id                      *sui.ObjectId
 	AuthorizedPoolId        *sui.ObjectId
@@ -97,6 +116,8 @@ type Event struct {
 	Type           string                 `json:"type"`
 	TxDigest       string                 `json:"tx_digest"`
 	Sender         string                 `json:"sender"`
+	Token          string                 `json:"token,omitempty"`  // Token of Amount
+	Amount         string                 `json:"amount,omitempty"` // In base units
 	Fields         map[string]interface{} `json:"fields"`
 }
 
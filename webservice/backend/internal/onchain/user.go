@@ -2,32 +2,60 @@ package onchain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
 	"github.com/leafsii/leafsii-backend/internal/store"
 	"github.com/leafsii/leafsii-backend/internal/util"
 	"github.com/pattonkan/sui-go/sui"
 	"go.uber.org/zap"
 )
 
+// ErrInvalidCursor is returned when a transactions cursor cannot be used
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type UserService struct {
 	chain  ChainReader
 	cache  *store.Cache
 	logger *zap.SugaredLogger
 	sf     *util.Group
+	events interfaces.Repository // Indexed events; nil lists no transactions
+
+	samples interfaces.Repository // Protocol samples; nil values portfolios at current prices
+
+	epochs           EpochReader // nil hands out no zkLogin nonces
+	zkLoginMaxEpochs uint64
+}
+
+// UserServiceOption configures a UserService
+type UserServiceOption func(*UserService)
+
+// WithEventStore lists user transactions from an events repository, as
+// described by entities.EventSchema
+func WithEventStore(events interfaces.Repository) UserServiceOption {
+	return func(s *UserService) {
+		s.events = events
+	}
 }
 
 func NewUserService(
 	chain ChainReader,
 	cache *store.Cache,
 	logger *zap.SugaredLogger,
+	opts ...UserServiceOption,
 ) *UserService {
-	return &UserService{
+	s := &UserService{
 		chain:  chain,
 		cache:  cache,
 		logger: logger,
 		sf:     &util.Group{},
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *UserService) GetPositions(ctx context.Context, address string) (*UserPositions, error) {
@@ -93,17 +121,92 @@ func (s *UserService) getBalancesInternal(ctx context.Context, address string) (
 	return balances, nil
 }
 
-// GetTransactions fetches user's recent transactions
-// For now, this is a placeholder - in reality, it would query the events table
-func (s *UserService) GetTransactions(ctx context.Context, address string, limit int, cursor string) ([]Event, string, error) {
-	// TODO: Implement actual database query for user transactions
-	// This would typically involve:
-	// 1. Query events table filtered by user address
-	// 2. Parse cursor for pagination
-	// 3. Return events and next cursor
+// TransactionFilter narrows the transactions of a user. Zero fields do not
+// filter; To is exclusive.
+type TransactionFilter struct {
+	Type     string // One of the EventType constants
+	From, To time.Time
+}
 
-	s.logger.Debugw("GetTransactions called", "address", address, "limit", limit, "cursor", cursor)
+// conditions returns the event filters of the transactions of address
+func (f TransactionFilter) conditions(address string) []interfaces.Filter {
+	conditions := []interfaces.Filter{{Field: "sender", Value: address}}
+	if f.Type != "" {
+		conditions = append(conditions, interfaces.Filter{Field: "type", Value: f.Type})
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, interfaces.Filter{Field: "timestamp", Operator: &interfaces.FilterOperator{Gte: f.From}})
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, interfaces.Filter{Field: "timestamp", Operator: &interfaces.FilterOperator{Lt: f.To}})
+	}
+	return conditions
+}
+
+// GetTransactions lists the events sent by address that match filter,
+// newest first. cursor is empty for the first page or the next cursor
+// returned with the previous page; the returned cursor is empty on the last
+// page. Cursors are keyset positions, so events indexed while paging do
+// not shift pages.
+func (s *UserService) GetTransactions(ctx context.Context, address string, filter TransactionFilter, limit int, cursor string) ([]Event, string, error) {
+	if s.events == nil {
+		return []Event{}, "", nil
+	}
+
+	events, next, err := listEvents(ctx, s.events, filter.conditions(address), limit, cursor)
+	if err != nil && !errors.Is(err, ErrInvalidCursor) {
+		s.logger.Errorw("Failed to list user transactions", "address", address, "error", err)
+		return nil, "", fmt.Errorf("failed to list user transactions: %w", err)
+	}
+	return events, next, err
+}
 
-	// Return empty results for now
-	return []Event{}, "", nil
+// listEvents lists the events of repo matching conditions, newest first,
+// from cursor
+func listEvents(ctx context.Context, repo interfaces.Repository, conditions []interfaces.Filter, limit int, cursor string) ([]Event, string, error) {
+	page, err := repo.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{
+			Conditions: conditions,
+		},
+		OrderBy: []interfaces.OrderBy{
+			{Field: "timestamp", Direction: "desc"},
+		},
+		Limit: &limit,
+		After: cursor,
+	})
+	if err != nil {
+		if cursor != "" && errors.Is(err, interfaces.ErrInvalidQuery) {
+			return nil, "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		return nil, "", err
+	}
+
+	events := make([]Event, 0, len(page.Data))
+	for _, record := range page.Data {
+		event := Event{
+			Type:     fmt.Sprint(record["type"]),
+			TxDigest: fmt.Sprint(record["tx_digest"]),
+			Sender:   fmt.Sprint(record["sender"]),
+		}
+		if checkpoint, ok := record["checkpoint"].(int64); ok {
+			event.Checkpoint = uint64(checkpoint)
+		}
+		if seq, ok := record["sequence_number"].(int64); ok {
+			event.SequenceNumber = uint64(seq)
+		}
+		if ts, ok := record["timestamp"].(time.Time); ok {
+			event.Timestamp = ts
+		}
+		if token, ok := record["token"].(string); ok {
+			event.Token = token
+		}
+		if amount, ok := record["amount"].(string); ok {
+			event.Amount = amount
+		}
+		if fields, ok := record["fields"].(map[string]interface{}); ok {
+			event.Fields = fields
+		}
+		events = append(events, event)
+	}
+	return events, page.NextCursor, nil
 }
@@ -0,0 +1,76 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"go.uber.org/zap"
+)
+
+func TestGetTransactionsPagesByCursor(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	if err := db.ConnectAndMigrate(ctx, database, db.AllSchemas()); err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	defer database.Disconnect(ctx)
+
+	events := database.Repository(entities.EventSchema)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	addEvent := func(i int, sender string) {
+		t.Helper()
+		if _, err := events.Create(ctx, map[string]interface{}{
+			"checkpoint":      int64(i),
+			"sequence_number": int64(0),
+			"timestamp":       start.Add(time.Duration(i) * time.Minute),
+			"type":            EventTypeMint,
+			"tx_digest":       fmt.Sprintf("digest-%d", i),
+			"sender":          sender,
+		}); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		addEvent(i, "0xuser")
+	}
+	addEvent(10, "0xother")
+
+	svc := NewUserService(nil, nil, zap.NewNop().Sugar(), WithEventStore(events))
+
+	page, cursor, err := svc.GetTransactions(ctx, "0xuser", TransactionFilter{}, 2, "")
+	if err != nil {
+		t.Fatalf("GetTransactions failed: %v", err)
+	}
+	if len(page) != 2 || page[0].TxDigest != "digest-4" || page[1].TxDigest != "digest-3" || cursor == "" {
+		t.Fatalf("Unexpected first page: %+v (cursor %q)", page, cursor)
+	}
+	if page[0].Checkpoint != 4 || !page[0].Timestamp.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("Unexpected event fields: %+v", page[0])
+	}
+
+	// A newer event indexed mid-scan does not shift later pages
+	addEvent(5, "0xuser")
+
+	var digests []string
+	for cursor != "" {
+		page, cursor, err = svc.GetTransactions(ctx, "0xuser", TransactionFilter{}, 2, cursor)
+		if err != nil {
+			t.Fatalf("GetTransactions failed: %v", err)
+		}
+		for _, event := range page {
+			digests = append(digests, event.TxDigest)
+		}
+	}
+	if fmt.Sprint(digests) != "[digest-2 digest-1 digest-0]" {
+		t.Errorf("Unexpected remaining pages: %v", digests)
+	}
+
+	if _, _, err := svc.GetTransactions(ctx, "0xuser", TransactionFilter{}, 2, "garbage"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
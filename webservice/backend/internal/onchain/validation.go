@@ -0,0 +1,158 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/fardream/go-bcs/bcs"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/sui/suiptb"
+	"github.com/pattonkan/sui-go/suiclient"
+)
+
+// abortReasons explains the abort codes of Move modules, as declared by
+// their E_* constants
+var abortReasons = map[string]map[uint64]string{
+	"leafsii": {
+		1: "amount is zero or invalid",
+		2: "protocol reserves are insufficient",
+		3: "user actions are paused",
+		4: "oracle price is stale",
+		5: "oracle price moved too far in one update",
+		6: "action is blocked at the current collateral ratio",
+		7: "admin capability does not match the protocol",
+		8: "stability pool is not authorized",
+	},
+	"stability_pool": {
+		1: "amount is zero or invalid",
+		2: "stability pool balance is insufficient",
+		4: "invalid stability pool controller",
+	},
+	"oracle": {
+		1: "oracle price is stale",
+		2: "oracle price is invalid",
+		3: "oracle is paused",
+	},
+	"balance": {
+		2: "balance is insufficient",
+	},
+}
+
+// MoveAbortError is a Move abort found by simulating a transaction
+type MoveAbortError struct {
+	Module   string `json:"module"`
+	Function string `json:"function,omitempty"`
+	Code     uint64 `json:"code"`
+	Command  int    `json:"command"` // Of the programmable transaction
+	Reason   string `json:"reason"`
+}
+
+func (e *MoveAbortError) Error() string {
+	return fmt.Sprintf("%s::%s aborted with code %d: %s", e.Module, e.Function, e.Code, e.Reason)
+}
+
+// moveAbortPattern matches the MoveAbort execution errors of Sui, e.g.
+//
+//	MoveAbort(MoveLocation { module: ModuleId { address: 0x2, name: Identifier("balance") },
+//	function: 2, instruction: 10, function_name: Some("split") }, 2) in command 1
+var moveAbortPattern = regexp.MustCompile(`MoveAbort\(MoveLocation \{ module: ModuleId \{ address: \w+, name: Identifier\("(\w+)"\) \}, function: \d+, instruction: \d+, function_name: (?:Some\("(\w+)"\)|None) \}, (\d+)\)(?: in command (\d+))?`)
+
+// parseMoveAbort returns the Move abort msg reports, or nil
+func parseMoveAbort(msg string) *MoveAbortError {
+	m := moveAbortPattern.FindStringSubmatch(msg)
+	if m == nil {
+		return nil
+	}
+	code, err := strconv.ParseUint(m[3], 10, 64)
+	if err != nil {
+		return nil
+	}
+	abort := &MoveAbortError{Module: m[1], Function: m[2], Code: code}
+	abort.Command, _ = strconv.Atoi(m[4])
+	if reason, ok := abortReasons[abort.Module][code]; ok {
+		abort.Reason = reason
+	} else {
+		abort.Reason = "transaction would abort"
+	}
+	return abort
+}
+
+// TransactionValidation is the outcome of simulating a built transaction
+type TransactionValidation struct {
+	Success bool              `json:"success"`
+	GasUsed uint64            `json:"gasUsed"`           // In MIST
+	Effects map[string]string `json:"effects,omitempty"` // Amounts of the protocol events, e.g. x_minted
+	Abort   *MoveAbortError   `json:"abort,omitempty"`
+	Error   string            `json:"error,omitempty"` // Failures other than aborts
+}
+
+// ValidateTransaction simulates a transaction built by the builder in
+// mode, sent by sender, with DevInspectTransactionBlock. Transactions that
+// would fail are reported in the validation, not as errors.
+func (tb *TransactionBuilder) ValidateTransaction(ctx context.Context, sender *sui.Address, txBytes []byte, mode TxBuildMode) (*TransactionValidation, error) {
+	kindBytes := txBytes
+	if mode != TxBuildModeDevInspect {
+		var tx suiptb.TransactionData
+		if _, err := bcs.Unmarshal(txBytes, &tx); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal transaction: %w", err)
+		}
+		var err error
+		if kindBytes, err = bcs.Marshal(tx.V1.Kind); err != nil {
+			return nil, fmt.Errorf("failed to marshal transaction kind: %w", err)
+		}
+	}
+	res, err := tb.client.DevInspectTransactionBlock(ctx, &suiclient.DevInspectTransactionBlockRequest{
+		SenderAddress: sender,
+		TxKindBytes:   kindBytes,
+		GasPrice:      sui.NewBigInt(suiclient.DefaultGasPrice),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dry run transaction: %w", err)
+	}
+
+	failure := res.Error
+	validation := &TransactionValidation{}
+	if effects := res.Effects.Data.V1; effects != nil {
+		validation.GasUsed = gasEstimate(effects.GasUsed)
+		if failure == "" && effects.Status.Status != suiclient.ExecutionStatusSuccess {
+			failure = effects.Status.Error
+		}
+	} else if failure == "" {
+		failure = "dry run returned no effects"
+	}
+	if failure != "" {
+		if validation.Abort = parseMoveAbort(failure); validation.Abort == nil {
+			validation.Error = failure
+		}
+		return validation, nil
+	}
+
+	validation.Success = true
+	validation.Effects = tb.eventAmounts(res.Events)
+	return validation, nil
+}
+
+// eventAmounts collects the amount fields of the events of the protocol
+// package, which are u64 strings in their parsed JSON
+func (tb *TransactionBuilder) eventAmounts(events []suiclient.Event) map[string]string {
+	amounts := make(map[string]string)
+	for _, event := range events {
+		if event.Type == nil || event.Type.Address == nil || tb.packageId == nil || *event.Type.Address != *tb.packageId {
+			continue
+		}
+		fields, ok := event.ParsedJson.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, value := range fields {
+			if s, ok := value.(string); ok {
+				if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+					amounts[name] = s
+				}
+			}
+		}
+	}
+	return amounts
+}
@@ -0,0 +1,62 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoveAbort(t *testing.T) {
+	abort := parseMoveAbort(`MoveAbort(MoveLocation { module: ModuleId { address: 6a5c, name: Identifier("leafsii") }, function: 21, instruction: 40, function_name: Some("mint_f") }, 6) in command 2`)
+	require.NotNil(t, abort)
+	assert.Equal(t, &MoveAbortError{
+		Module:   "leafsii",
+		Function: "mint_f",
+		Code:     6,
+		Command:  2,
+		Reason:   "action is blocked at the current collateral ratio",
+	}, abort)
+
+	abort = parseMoveAbort(`MoveAbort(MoveLocation { module: ModuleId { address: 0000000000000000000000000000000000000000000000000000000000000002, name: Identifier("tx_context") }, function: 3, instruction: 1, function_name: None }, 99)`)
+	require.NotNil(t, abort)
+	assert.Equal(t, "transaction would abort", abort.Reason)
+
+	assert.Nil(t, parseMoveAbort("InsufficientGas"))
+
+	// Dry runs of builds fail with the abort
+	tb := &TransactionBuilder{}
+	var buildAbort *MoveAbortError
+	assert.True(t, errors.As(tb.errDryRun(context.Background(), `MoveAbort(MoveLocation { module: ModuleId { address: 2, name: Identifier("balance") }, function: 2, instruction: 10, function_name: Some("split") }, 2) in command 0`), &buildAbort))
+	assert.Equal(t, "balance is insufficient", buildAbort.Reason)
+}
+
+func TestEventAmounts(t *testing.T) {
+	packageId := sui.MustObjectIdFromHex("0x1eaf")
+	tb := &TransactionBuilder{packageId: packageId}
+	amounts := tb.eventAmounts([]suiclient.Event{
+		{
+			Type: &sui.StructTag{Address: packageId, Module: "leafsii", Name: "MintX"},
+			ParsedJson: map[string]interface{}{
+				"user":        "0xabc",
+				"reserve_in":  "1000000000",
+				"x_minted":    "2500000000",
+				"fee_charged": "5000000",
+				"cr_level":    float64(0),
+			},
+		},
+		{
+			Type:       &sui.StructTag{Address: sui.MustObjectIdFromHex("0x2"), Module: "coin", Name: "Minted"},
+			ParsedJson: map[string]interface{}{"amount": "1"},
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"reserve_in":  "1000000000",
+		"x_minted":    "2500000000",
+		"fee_charged": "5000000",
+	}, amounts)
+}
@@ -0,0 +1,116 @@
+package onchain
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/pattonkan/sui-go/suisigner/suicrypto"
+	"golang.org/x/crypto/blake2b"
+)
+
+// DefaultZkLoginMaxEpochs is how many epochs past the current one the
+// ephemeral keys of zkLogin sessions stay valid
+const DefaultZkLoginMaxEpochs = 2
+
+// ErrZkLoginUnavailable is returned for zkLogin nonces when the service
+// has no RPC to read the epoch from
+var ErrZkLoginUnavailable = errors.New("zkLogin is not configured")
+
+// EpochReader reads the current epoch, as *RPCPool does
+type EpochReader interface {
+	GetLatestSuiSystemState(ctx context.Context) (*suiclient.SuiSystemStateSummary, error)
+}
+
+// WithZkLogin lets the service hand out the parameters of zkLogin nonces,
+// for ephemeral keys valid maxEpochs past the current epoch
+func WithZkLogin(epochs EpochReader, maxEpochs uint64) UserServiceOption {
+	return func(s *UserService) {
+		s.epochs = epochs
+		s.zkLoginMaxEpochs = maxEpochs
+	}
+}
+
+// ZkLoginNonce holds what the frontend hashes with its ephemeral public
+// key into the nonce of the OpenID request, as generateNonce of the Sui
+// SDK does. Transactions signed with the ephemeral key are valid until
+// MaxEpoch ends.
+type ZkLoginNonce struct {
+	Epoch       uint64
+	MaxEpoch    uint64
+	Randomness  string // Decimal, 128 bits
+	EpochEndsAt time.Time
+}
+
+// ZkLoginNonce returns fresh nonce parameters for a zkLogin session
+func (s *UserService) ZkLoginNonce(ctx context.Context) (*ZkLoginNonce, error) {
+	if s.epochs == nil {
+		return nil, ErrZkLoginUnavailable
+	}
+	state, err := s.epochs.GetLatestSuiSystemState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system state: %w", err)
+	}
+	if state.Epoch == nil {
+		return nil, fmt.Errorf("system state has no epoch")
+	}
+	randomness, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate randomness: %w", err)
+	}
+
+	epoch := state.Epoch.Uint64()
+	maxEpochs := s.zkLoginMaxEpochs
+	if maxEpochs == 0 {
+		maxEpochs = DefaultZkLoginMaxEpochs
+	}
+	nonce := &ZkLoginNonce{
+		Epoch:      epoch,
+		MaxEpoch:   epoch + maxEpochs,
+		Randomness: randomness.String(),
+	}
+	if state.EpochStartTimestampMs != nil && state.EpochDurationMs != nil {
+		nonce.EpochEndsAt = time.UnixMilli(state.EpochStartTimestampMs.Int64() + state.EpochDurationMs.Int64()).UTC()
+	}
+	return nonce, nil
+}
+
+// ZkLoginAddress derives the Sui address of a zkLogin account from the
+// issuer of its JWTs and its address seed, the Poseidon hash of the salt
+// and the JWT claims the prover returns
+func ZkLoginAddress(iss string, addressSeed *big.Int) (*sui.Address, error) {
+	if addressSeed == nil || addressSeed.Sign() < 0 || addressSeed.BitLen() > 256 {
+		return nil, fmt.Errorf("address seed must fit 32 bytes")
+	}
+	// Google issues tokens both with and without the scheme
+	if iss == "accounts.google.com" {
+		iss = "https://accounts.google.com"
+	}
+	if len(iss) == 0 || len(iss) > 255 {
+		return nil, fmt.Errorf("invalid issuer %q", iss)
+	}
+
+	buf := make([]byte, 0, 2+len(iss)+32)
+	buf = append(buf, suicrypto.KeySchemeFlagZkLoginAuthenticator.Byte(), byte(len(iss)))
+	buf = append(buf, iss...)
+	buf = append(buf, addressSeed.FillBytes(make([]byte, 32))...)
+	hash := blake2b.Sum256(buf)
+	address := sui.Address(hash)
+	return &address, nil
+}
+
+// NormalizeAddress returns address in the form Sui reports senders and
+// owners in, 0x followed by 64 lowercase hex digits, so that addresses
+// given with leading zeros trimmed or in upper case match them
+func NormalizeAddress(address string) (string, error) {
+	addr, err := sui.AddressFromHex(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address format: %w", err)
+	}
+	return addr.String(), nil
+}
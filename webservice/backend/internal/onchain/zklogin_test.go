@@ -0,0 +1,96 @@
+package onchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/pattonkan/sui-go/suiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/blake2b"
+)
+
+type fixedEpoch suiclient.SuiSystemStateSummary
+
+func (e *fixedEpoch) GetLatestSuiSystemState(context.Context) (*suiclient.SuiSystemStateSummary, error) {
+	return (*suiclient.SuiSystemStateSummary)(e), nil
+}
+
+func TestZkLoginNonce(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	_, err := NewUserService(nil, nil, logger).ZkLoginNonce(context.Background())
+	assert.ErrorIs(t, err, ErrZkLoginUnavailable)
+
+	epochs := &fixedEpoch{
+		Epoch:                 sui.NewBigInt(400),
+		EpochStartTimestampMs: sui.NewBigInt(1_700_000_000_000),
+		EpochDurationMs:       sui.NewBigInt(86_400_000),
+	}
+	svc := NewUserService(nil, nil, logger, WithZkLogin(epochs, 3))
+	nonce, err := svc.ZkLoginNonce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(400), nonce.Epoch)
+	assert.Equal(t, uint64(403), nonce.MaxEpoch)
+	assert.Equal(t, time.UnixMilli(1_700_086_400_000).UTC(), nonce.EpochEndsAt)
+
+	randomness, ok := new(big.Int).SetString(nonce.Randomness, 10)
+	require.True(t, ok)
+	assert.LessOrEqual(t, randomness.BitLen(), 128)
+	other, err := svc.ZkLoginNonce(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, nonce.Randomness, other.Randomness)
+
+	nonce, err = NewUserService(nil, nil, logger, WithZkLogin(epochs, 0)).ZkLoginNonce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(400+DefaultZkLoginMaxEpochs), nonce.MaxEpoch)
+}
+
+func TestZkLoginAddress(t *testing.T) {
+	seed, _ := new(big.Int).SetString("13322897930163218532266430409510394316985274769125667290600321564259466511711", 10)
+	address, err := ZkLoginAddress("https://accounts.google.com", seed)
+	require.NoError(t, err)
+
+	// blake2b-256 of the zkLogin flag, the issuer length and bytes, and
+	// the 32-byte big-endian seed
+	iss := "https://accounts.google.com"
+	expected := append([]byte{0x05, byte(len(iss))}, iss...)
+	expected = append(expected, seed.FillBytes(make([]byte, 32))...)
+	assert.Equal(t, sui.Address(blake2b.Sum256(expected)), *address)
+
+	short, err := ZkLoginAddress("accounts.google.com", seed)
+	require.NoError(t, err)
+	assert.Equal(t, address, short)
+
+	other, err := ZkLoginAddress("https://id.twitch.tv/oauth2", seed)
+	require.NoError(t, err)
+	assert.NotEqual(t, address, other)
+
+	_, err = ZkLoginAddress("", seed)
+	assert.Error(t, err)
+	_, err = ZkLoginAddress(iss, new(big.Int).Lsh(big.NewInt(1), 256))
+	assert.Error(t, err)
+	_, err = ZkLoginAddress(iss, big.NewInt(-1))
+	assert.Error(t, err)
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	full := "0x00c1fb5e4b2a8e3b8c8e2a5d0f3b7c1a9e8d7c6b5a4f3e2d1c0b0a0908070605"
+	for _, address := range []string{
+		full,
+		"0xc1fb5e4b2a8e3b8c8e2a5d0f3b7c1a9e8d7c6b5a4f3e2d1c0b0a0908070605",
+		"0x00C1FB5E4B2A8E3B8C8E2A5D0F3B7C1A9E8D7C6B5A4F3E2D1C0B0A0908070605",
+	} {
+		normalized, err := NormalizeAddress(address)
+		require.NoError(t, err)
+		assert.Equal(t, full, normalized)
+	}
+
+	_, err := NormalizeAddress("0xnothex")
+	assert.Error(t, err)
+	_, err = NormalizeAddress(full + "00")
+	assert.Error(t, err)
+}
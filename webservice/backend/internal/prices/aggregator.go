@@ -0,0 +1,302 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTooFewSources is returned when fewer sources than required quote a
+// price within the deviation threshold
+var ErrTooFewSources = errors.New("too few price sources agree")
+
+// Source is a provider quoting prices on request, as the exchange
+// providers do
+type Source interface {
+	Name() string
+	Quoter
+}
+
+// Aggregate is the price of a symbol across sources: the median of the
+// quotes within the deviation threshold, and its time-weighted average
+// over the TWAP window
+type Aggregate struct {
+	Symbol  string        `json:"symbol"`
+	Median  float64       `json:"median"`
+	TWAP    float64       `json:"twap"`
+	TsMs    int64         `json:"ts"` // When aggregated
+	Sources []SourcePrice `json:"sources"`
+}
+
+// SourcePrice is the quote of one source of an aggregate
+type SourcePrice struct {
+	Source       string  `json:"source"`
+	Price        float64 `json:"price,omitempty"`
+	TsMs         int64   `json:"ts,omitempty"`
+	DeviationBps float64 `json:"deviation_bps"`      // From the median of all quotes
+	Rejected     bool    `json:"rejected,omitempty"` // As an outlier
+	Error        string  `json:"error,omitempty"`    // The quote failed
+}
+
+// Tick returns the median of a as a tick
+func (a Aggregate) Tick() Tick {
+	return Tick{Symbol: a.Symbol, Price: a.Median, TsMs: a.TsMs, Source: "aggregate"}
+}
+
+// Rejected returns the names of the sources whose quote failed or was
+// rejected as an outlier
+func (a Aggregate) Rejected() []string {
+	var rejected []string
+	for _, s := range a.Sources {
+		if s.Rejected || s.Error != "" {
+			rejected = append(rejected, s.Source)
+		}
+	}
+	return rejected
+}
+
+// Aggregator quotes prices from several sources concurrently, so that no
+// single exchange print prices the protocol. Quotes deviating from their
+// median by more than the threshold are rejected; the median of the rest
+// is the price, and aggregates are kept for the TWAP window to average it.
+// The window is kept in memory, per replica.
+type Aggregator struct {
+	sources      []Source
+	maxDeviation float64 // Fraction of the median
+	minSources   int
+	window       time.Duration
+	ttl          time.Duration
+	timeout      time.Duration
+	now          func() time.Time
+
+	mu      sync.Mutex
+	samples map[string][]priceSample // symbol -> medians, oldest first
+	latest  map[string]Aggregate
+}
+
+type priceSample struct {
+	at    time.Time
+	price float64
+}
+
+// AggregatorOption configures an Aggregator
+type AggregatorOption func(*Aggregator)
+
+// WithMaxDeviationBps rejects quotes deviating from the median of all
+// quotes by more than bps basis points; 0 rejects none
+func WithMaxDeviationBps(bps uint64) AggregatorOption {
+	return func(a *Aggregator) {
+		a.maxDeviation = float64(bps) / 10_000
+	}
+}
+
+// WithMinSources requires n quotes within the threshold per aggregate
+func WithMinSources(n int) AggregatorOption {
+	return func(a *Aggregator) {
+		a.minSources = max(n, 1)
+	}
+}
+
+// WithTWAPWindow averages the medians of the last window
+func WithTWAPWindow(window time.Duration) AggregatorOption {
+	return func(a *Aggregator) {
+		a.window = window
+	}
+}
+
+// WithAggregateTTL reuses an aggregate for ttl, so that bursts of quotes
+// share one round of requests
+func WithAggregateTTL(ttl time.Duration) AggregatorOption {
+	return func(a *Aggregator) {
+		a.ttl = ttl
+	}
+}
+
+// WithQuoteTimeout bounds the requests to each source, so that a slow one
+// does not hold up the aggregate
+func WithQuoteTimeout(timeout time.Duration) AggregatorOption {
+	return func(a *Aggregator) {
+		a.timeout = timeout
+	}
+}
+
+// NewAggregator returns an aggregator of sources. By default it rejects
+// quotes 1% off the median, requires two of them and averages 5 minutes.
+func NewAggregator(sources []Source, opts ...AggregatorOption) *Aggregator {
+	a := &Aggregator{
+		sources:      sources,
+		maxDeviation: 0.01,
+		minSources:   2,
+		window:       5 * time.Minute,
+		timeout:      3 * time.Second,
+		now:          time.Now,
+		samples:      make(map[string][]priceSample),
+		latest:       make(map[string]Aggregate),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.minSources = min(a.minSources, len(sources))
+	return a
+}
+
+// Name returns the provider identifier of aggregated ticks
+func (a *Aggregator) Name() string {
+	return "aggregate"
+}
+
+// FetchPrice returns the median of the aggregate of symbol as a tick, so
+// that the aggregator quotes in place of a single provider
+func (a *Aggregator) FetchPrice(ctx context.Context, symbol string) (Tick, error) {
+	aggregate, err := a.Aggregate(ctx, symbol)
+	if err != nil {
+		return Tick{}, err
+	}
+	return aggregate.Tick(), nil
+}
+
+// Aggregate quotes symbol from every source and aggregates the quotes
+func (a *Aggregator) Aggregate(ctx context.Context, symbol string) (Aggregate, error) {
+	now := a.now()
+	a.mu.Lock()
+	latest, ok := a.latest[symbol]
+	a.mu.Unlock()
+	if ok && a.ttl > 0 && now.Sub(time.UnixMilli(latest.TsMs)) < a.ttl {
+		return latest, nil
+	}
+
+	quotes := a.quote(ctx, symbol)
+	if ctx.Err() != nil {
+		return Aggregate{}, ctx.Err()
+	}
+
+	var all []float64
+	for _, q := range quotes {
+		if q.Error == "" {
+			all = append(all, q.Price)
+		}
+	}
+	if len(all) == 0 || len(all) < a.minSources {
+		return Aggregate{}, fmt.Errorf("%w on %s: %d quoted, %d required%s", ErrTooFewSources, symbol, len(all), a.minSources, quoteErrors(quotes))
+	}
+
+	// Reject the quotes off the median of all of them, then take the
+	// median of the rest
+	reference := median(all)
+	var accepted []float64
+	for i, q := range quotes {
+		if q.Error != "" {
+			continue
+		}
+		deviation := math.Abs(q.Price-reference) / reference
+		quotes[i].DeviationBps = math.Round(deviation * 10_000)
+		if a.maxDeviation > 0 && deviation > a.maxDeviation {
+			quotes[i].Rejected = true
+			continue
+		}
+		accepted = append(accepted, q.Price)
+	}
+	if len(accepted) < a.minSources {
+		return Aggregate{}, fmt.Errorf("%w on %s: %d within %.0f bps of %v, %d required", ErrTooFewSources, symbol, len(accepted), a.maxDeviation*10_000, reference, a.minSources)
+	}
+
+	aggregate := Aggregate{
+		Symbol:  symbol,
+		Median:  median(accepted),
+		TsMs:    now.UnixMilli(),
+		Sources: quotes,
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	from := now.Add(-a.window)
+	samples := append(a.samples[symbol], priceSample{at: now, price: aggregate.Median})
+	for len(samples) > 1 && !samples[1].at.After(from) {
+		samples = samples[1:]
+	}
+	a.samples[symbol] = samples
+	aggregate.TWAP = twap(samples, from, now)
+	a.latest[symbol] = aggregate
+	return aggregate, nil
+}
+
+// quote asks every source for the price of symbol concurrently
+func (a *Aggregator) quote(ctx context.Context, symbol string) []SourcePrice {
+	quotes := make([]SourcePrice, len(a.sources))
+	var wg sync.WaitGroup
+	for i, source := range a.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			quoteCtx, cancel := context.WithTimeout(ctx, a.timeout)
+			defer cancel()
+
+			quotes[i] = SourcePrice{Source: source.Name()}
+			tick, err := source.FetchPrice(quoteCtx, symbol)
+			switch {
+			case err != nil:
+				quotes[i].Error = err.Error()
+			case !(tick.Price > 0):
+				quotes[i].Error = fmt.Sprintf("invalid price %v", tick.Price)
+			default:
+				quotes[i].Price = tick.Price
+				quotes[i].TsMs = tick.TsMs
+			}
+		}()
+	}
+	wg.Wait()
+	return quotes
+}
+
+// quoteErrors describes the failed quotes of an aggregate
+func quoteErrors(quotes []SourcePrice) string {
+	var errs []string
+	for _, q := range quotes {
+		if q.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", q.Source, q.Error))
+		}
+	}
+	if len(errs) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(errs, "; ") + ")"
+}
+
+// median returns the median of values, which it sorts
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// twap averages samples from from to now, each price weighted by how long
+// it held. Until a sample held for any time, the latest price is the
+// average.
+func twap(samples []priceSample, from, now time.Time) float64 {
+	var sum, total float64
+	for i, s := range samples {
+		start, end := s.at, now
+		if start.Before(from) {
+			start = from
+		}
+		if i+1 < len(samples) {
+			end = samples[i+1].at
+		}
+		if d := end.Sub(start).Seconds(); d > 0 {
+			sum += s.price * d
+			total += d
+		}
+	}
+	if total == 0 {
+		return samples[len(samples)-1].price
+	}
+	return sum / total
+}
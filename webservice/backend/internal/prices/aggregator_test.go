@@ -0,0 +1,114 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quoteSource quotes a settable price, or fails with err
+type quoteSource struct {
+	name string
+
+	mu    sync.Mutex
+	price float64
+	err   error
+	calls int
+}
+
+func (q *quoteSource) Name() string { return q.name }
+
+func (q *quoteSource) FetchPrice(ctx context.Context, symbol string) (Tick, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.calls++
+	if q.err != nil {
+		return Tick{}, q.err
+	}
+	return Tick{Symbol: symbol, Price: q.price, TsMs: 1700000000000, Source: q.name}, nil
+}
+
+func (q *quoteSource) set(price float64, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.price, q.err = price, err
+}
+
+func TestAggregator(t *testing.T) {
+	ctx := context.Background()
+	binance := &quoteSource{name: "binance", price: 1.00}
+	coinbase := &quoteSource{name: "coinbase", price: 1.01}
+	kraken := &quoteSource{name: "kraken", price: 1.20}
+	okx := &quoteSource{name: "okx", err: errors.New("geo-blocked")}
+	aggregator := NewAggregator([]Source{binance, coinbase, kraken, okx}, WithMaxDeviationBps(200), WithMinSources(2), WithTWAPWindow(time.Minute))
+	now := time.Unix(1700000000, 0)
+	aggregator.now = func() time.Time { return now }
+
+	// The median of all three quotes is 1.01: kraken is 18.8% off it
+	aggregate, err := aggregator.Aggregate(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.InDelta(t, 1.005, aggregate.Median, 1e-9)
+	assert.InDelta(t, 1.005, aggregate.TWAP, 1e-9)
+	assert.Equal(t, now.UnixMilli(), aggregate.TsMs)
+	assert.Equal(t, []SourcePrice{
+		{Source: "binance", Price: 1.00, TsMs: 1700000000000, DeviationBps: 99},
+		{Source: "coinbase", Price: 1.01, TsMs: 1700000000000, DeviationBps: 0},
+		{Source: "kraken", Price: 1.20, TsMs: 1700000000000, DeviationBps: 1881, Rejected: true},
+		{Source: "okx", Error: "geo-blocked"},
+	}, aggregate.Sources)
+	assert.Equal(t, []string{"kraken", "okx"}, aggregate.Rejected())
+
+	tick, err := aggregator.FetchPrice(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, Tick{Symbol: "SUIUSDT", Price: aggregate.Median, TsMs: now.UnixMilli(), Source: "aggregate"}, tick)
+
+	// The TWAP weighs each median by how long it held
+	now = now.Add(30 * time.Second)
+	binance.set(1.10, nil)
+	coinbase.set(1.10, nil)
+	kraken.set(1.10, nil)
+	aggregate, err = aggregator.Aggregate(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.InDelta(t, 1.10, aggregate.Median, 1e-9)
+	assert.InDelta(t, 1.005, aggregate.TWAP, 1e-9)
+	now = now.Add(45 * time.Second)
+	aggregate, err = aggregator.Aggregate(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	// 15s of 1.005 left in the window, then 45s of 1.10
+	assert.InDelta(t, (15*1.005+45*1.10)/60, aggregate.TWAP, 1e-9)
+
+	// Too few quotes within the threshold
+	kraken.set(0, errors.New("maintenance"))
+	binance.set(1.50, nil)
+	_, err = aggregator.Aggregate(ctx, "SUIUSDT")
+	assert.ErrorIs(t, err, ErrTooFewSources)
+	coinbase.set(0, errors.New("rate limited"))
+	_, err = aggregator.Aggregate(ctx, "SUIUSDT")
+	assert.ErrorIs(t, err, ErrTooFewSources)
+	assert.ErrorContains(t, err, "coinbase: rate limited")
+}
+
+func TestAggregatorTTL(t *testing.T) {
+	ctx := context.Background()
+	binance := &quoteSource{name: "binance", price: 1.00}
+	aggregator := NewAggregator([]Source{binance}, WithAggregateTTL(5*time.Second))
+	now := time.Unix(1700000000, 0)
+	aggregator.now = func() time.Time { return now }
+
+	_, err := aggregator.Aggregate(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	now = now.Add(4 * time.Second)
+	_, err = aggregator.Aggregate(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 1, binance.calls)
+
+	now = now.Add(time.Second)
+	_, err = aggregator.Aggregate(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 2, binance.calls)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,32 +18,52 @@ import (
 )
 
 const (
-	BinanceRestAPI = "https://api.binance.com"
-	BinanceWS      = "wss://stream.binance.com:9443/ws"
-	BinanceScale   = 1_000_000
+	BinanceRestAPI  = "https://api.binance.com"
+	BinanceWS       = "wss://stream.binance.com:9443/ws"
+	BinanceStreamWS = "wss://stream.binance.com:9443/stream" // Combined streams
+	BinanceScale    = 1_000_000
 )
 
 // Provider implements the prices.Provider interface for Binance
 type Provider struct {
-	logger *zap.SugaredLogger
-	client *http.Client
+	logger    *zap.SugaredLogger
+	client    *http.Client
+	restURL   string
+	streamURL string
 
 	mu     sync.RWMutex
 	health prices.ProviderHealth
 }
 
+// Option configures a Provider
+type Option func(*Provider)
+
+// WithURLs points the provider at other REST and combined stream endpoints
+func WithURLs(restURL, streamURL string) Option {
+	return func(p *Provider) {
+		p.restURL = restURL
+		p.streamURL = streamURL
+	}
+}
+
 // NewProvider creates a new Binance provider
-func NewProvider(logger *zap.SugaredLogger) *Provider {
-	return &Provider{
+func NewProvider(logger *zap.SugaredLogger, opts ...Option) *Provider {
+	p := &Provider{
 		logger: logger,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		restURL:   BinanceRestAPI,
+		streamURL: BinanceStreamWS,
 		health: prices.ProviderHealth{
 			Healthy:     true,
 			LastSuccess: time.Now(),
 		},
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // Name returns the provider identifier
@@ -74,7 +95,7 @@ func (p *Provider) updateHealth(healthy bool, err error) {
 // FetchHistory retrieves historical kline data from Binance
 func (p *Provider) FetchHistory(ctx context.Context, symbol string, interval time.Duration, limit int) ([]prices.Candle, error) {
 	// Build request URL
-	baseURL := fmt.Sprintf("%s/api/v3/klines", BinanceRestAPI)
+	baseURL := fmt.Sprintf("%s/api/v3/klines", p.restURL)
 	params := url.Values{}
 	params.Set("symbol", symbol)
 	params.Set("interval", binanceInterval(interval))
@@ -133,9 +154,20 @@ func (p *Provider) FetchHistory(ctx context.Context, symbol string, interval tim
 
 // SubscribeLive subscribes to real-time trade data via WebSocket
 func (p *Provider) SubscribeLive(ctx context.Context, symbol string, out chan<- prices.Tick) error {
-	// Convert symbol to lowercase for WebSocket
-	wsSymbol := symbol + "@trade"
-	wsURL := fmt.Sprintf("%s/%s", BinanceWS, wsSymbol)
+	return p.Stream(ctx, []string{symbol}, out)
+}
+
+// Stream subscribes to the trades of symbols over one connection to the
+// combined streams endpoint
+func (p *Provider) Stream(ctx context.Context, symbols []string, out chan<- prices.Tick) error {
+	// Stream names are lowercase; trades carry the uppercase symbol
+	streams := make([]string, len(symbols))
+	bySymbol := make(map[string]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = strings.ToLower(symbol) + "@trade"
+		bySymbol[strings.ToUpper(symbol)] = symbol
+	}
+	wsURL := fmt.Sprintf("%s?streams=%s", p.streamURL, strings.Join(streams, "/"))
 
 	p.logger.Infow("Connecting to Binance WebSocket", "url", wsURL)
 
@@ -148,7 +180,7 @@ func (p *Provider) SubscribeLive(ctx context.Context, symbol string, out chan<-
 	defer conn.Close()
 
 	p.updateHealth(true, nil)
-	p.logger.Infow("Connected to Binance WebSocket", "symbol", symbol)
+	p.logger.Infow("Connected to Binance WebSocket", "symbols", symbols)
 
 	// Read messages
 	for {
@@ -171,11 +203,17 @@ func (p *Provider) SubscribeLive(ctx context.Context, symbol string, out chan<-
 		}
 
 		// Parse trade message
-		var trade BinanceTrade
-		if err := json.Unmarshal(message, &trade); err != nil {
+		var combined BinanceStreamMessage
+		if err := json.Unmarshal(message, &combined); err != nil {
 			p.logger.Warnw("Failed to parse trade message", "error", err, "message", string(message))
 			continue
 		}
+		trade := combined.Data
+		symbol, ok := bySymbol[trade.Symbol]
+		if !ok {
+			p.logger.Debugw("Skipping message of unknown stream", "stream", combined.Stream)
+			continue
+		}
 
 		// Convert to tick
 		price, err := strconv.ParseFloat(trade.Price, 64)
@@ -188,6 +226,7 @@ func (p *Provider) SubscribeLive(ctx context.Context, symbol string, out chan<-
 			Symbol: symbol,
 			Price:  price,
 			TsMs:   trade.EventTime,
+			Source: p.Name(),
 		}
 
 		// Send tick (non-blocking)
@@ -204,6 +243,55 @@ func (p *Provider) SubscribeLive(ctx context.Context, symbol string, out chan<-
 	}
 }
 
+// FetchPrice retrieves the latest price of symbol from the ticker endpoint.
+// The tick is timestamped when the response arrives.
+func (p *Provider) FetchPrice(ctx context.Context, symbol string) (prices.Tick, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	requestURL := fmt.Sprintf("%s/api/v3/ticker/price?%s", p.restURL, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		p.updateHealth(false, err)
+		return prices.Tick{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.updateHealth(false, err)
+		return prices.Tick{}, fmt.Errorf("failed to fetch from Binance: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("Binance API error: %d", resp.StatusCode)
+		p.updateHealth(false, err)
+		return prices.Tick{}, err
+	}
+
+	var ticker struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ticker); err != nil {
+		p.updateHealth(false, err)
+		return prices.Tick{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	price, err := strconv.ParseFloat(ticker.Price, 64)
+	if err != nil {
+		p.updateHealth(false, err)
+		return prices.Tick{}, fmt.Errorf("invalid price %q: %w", ticker.Price, err)
+	}
+
+	p.updateHealth(true, nil)
+	return prices.Tick{
+		Symbol: symbol,
+		Price:  price,
+		TsMs:   time.Now().UnixMilli(),
+		Source: p.Name(),
+	}, nil
+}
+
 // BinanceTrade represents a trade message from Binance WebSocket
 type BinanceTrade struct {
 	EventType     string `json:"e"`
@@ -218,6 +306,13 @@ type BinanceTrade struct {
 	IsBuyerMaker  bool   `json:"m"`
 }
 
+// BinanceStreamMessage wraps a trade of the combined streams endpoint with
+// the name of its stream
+type BinanceStreamMessage struct {
+	Stream string       `json:"stream"`
+	Data   BinanceTrade `json:"data"`
+}
+
 // parseKline converts Binance kline array to our Candle struct
 func parseKline(kline []interface{}) (prices.Candle, error) {
 	if len(kline) < 11 {
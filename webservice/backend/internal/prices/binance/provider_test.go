@@ -0,0 +1,69 @@
+package binance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestProvider(t *testing.T) {
+	minute := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/klines":
+			assert.Equal(t, "SUIUSDT", r.URL.Query().Get("symbol"))
+			assert.Equal(t, "1m", r.URL.Query().Get("interval"))
+			fmt.Fprintf(w, `[[%d,"1.0","1.2","0.9","1.1","100",%d,"110",7,"50","55","0"]]`, minute*1000, minute*1000+59999)
+		case "/api/v3/ticker/price":
+			if r.URL.Query().Get("symbol") != "SUIUSDT" {
+				http.Error(w, `{"code":-1121,"msg":"Invalid symbol."}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"symbol":"SUIUSDT","price":"1.23450000"}`)
+		case "/stream":
+			assert.Equal(t, "suiusdt@trade/ethusdt@trade", r.URL.Query().Get("streams"))
+			conn, err := upgrader.Upgrade(w, r, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"stream":"suiusdt@trade","data":{"e":"trade","E":%d,"s":"SUIUSDT","p":"1.25","q":"3"}}`, minute*1000+2000)))
+			conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"stream":"ethusdt@trade","data":{"e":"trade","E":%d,"s":"ETHUSDT","p":"2500.5","q":"1"}}`, minute*1000+3000)))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"btcusdt@trade","data":{"e":"trade","E":1,"s":"BTCUSDT","p":"40000","q":"1"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	p := NewProvider(zap.NewNop().Sugar(), WithURLs(server.URL, "ws"+strings.TrimPrefix(server.URL, "http")+"/stream"))
+	ctx := context.Background()
+
+	candles, err := p.FetchHistory(ctx, "SUIUSDT", time.Minute, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []prices.Candle{{Time: minute, Open: 1.0, High: 1.2, Low: 0.9, Close: 1.1, Volume: 100}}, candles)
+
+	tick, err := p.FetchPrice(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "binance", tick.Source)
+	assert.Equal(t, 1.2345, tick.Price)
+	_, err = p.FetchPrice(ctx, "SUIUSDC")
+	assert.ErrorContains(t, err, "Binance API error: 400")
+	assert.False(t, p.Health().Healthy)
+
+	out := make(chan prices.Tick, 10)
+	err = p.Stream(ctx, []string{"SUIUSDT", "ETHUSDT"}, out)
+	assert.ErrorContains(t, err, "WebSocket read error")
+	require.Len(t, out, 2)
+	assert.Equal(t, prices.Tick{Symbol: "SUIUSDT", Price: 1.25, TsMs: minute*1000 + 2000, Source: "binance"}, <-out)
+	assert.Equal(t, prices.Tick{Symbol: "ETHUSDT", Price: 2500.5, TsMs: minute*1000 + 3000, Source: "binance"}, <-out)
+	assert.Equal(t, 1, p.Health().Reconnects)
+}
@@ -0,0 +1,341 @@
+package coinbase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"go.uber.org/zap"
+)
+
+const (
+	CoinbaseRestAPI = "https://api.exchange.coinbase.com"
+	CoinbaseWS      = "wss://ws-feed.exchange.coinbase.com"
+
+	// maxCandles is the most candles Coinbase returns per request
+	maxCandles = 300
+)
+
+// Provider implements the prices.Provider interface for Coinbase Exchange
+type Provider struct {
+	logger  *zap.SugaredLogger
+	client  *http.Client
+	restURL string
+	wsURL   string
+
+	mu     sync.RWMutex
+	health prices.ProviderHealth
+}
+
+// Option configures a Provider
+type Option func(*Provider)
+
+// WithURLs points the provider at other REST and WebSocket endpoints
+func WithURLs(restURL, wsURL string) Option {
+	return func(p *Provider) {
+		p.restURL = restURL
+		p.wsURL = wsURL
+	}
+}
+
+// NewProvider creates a new Coinbase provider
+func NewProvider(logger *zap.SugaredLogger, opts ...Option) *Provider {
+	p := &Provider{
+		logger: logger,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		restURL: CoinbaseRestAPI,
+		wsURL:   CoinbaseWS,
+		health: prices.ProviderHealth{
+			Healthy:     true,
+			LastSuccess: time.Now(),
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Name returns the provider identifier
+func (p *Provider) Name() string {
+	return "coinbase"
+}
+
+// Health returns current provider health status
+func (p *Provider) Health() prices.ProviderHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.health
+}
+
+// updateHealth updates the provider health status
+func (p *Provider) updateHealth(healthy bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.health.Healthy = healthy
+	if healthy {
+		p.health.LastSuccess = time.Now()
+		p.health.LastError = ""
+	} else if err != nil {
+		p.health.LastError = err.Error()
+	}
+}
+
+// ProductID returns the Coinbase product of a normalized symbol, e.g.
+// SUI-USD for SUIUSDT: Coinbase quotes in USD rather than USDT
+func ProductID(symbol string) (string, error) {
+	base, quote, err := prices.SplitSymbol(symbol)
+	if err != nil {
+		return "", err
+	}
+	if quote == "USDT" {
+		quote = "USD"
+	}
+	return base + "-" + quote, nil
+}
+
+// get decodes the JSON response to a GET of path into v
+func (p *Provider) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	requestURL := p.restURL + path
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to fetch from Coinbase: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("Coinbase API error: %d", resp.StatusCode)
+		p.updateHealth(false, err)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// FetchHistory retrieves historical candles from Coinbase. Coinbase has no
+// 4h candles, so those are rolled up from 1h ones.
+func (p *Provider) FetchHistory(ctx context.Context, symbol string, interval time.Duration, limit int) ([]prices.Candle, error) {
+	product, err := ProductID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	granularity, per := interval, 1
+	if interval == 4*time.Hour {
+		granularity, per = time.Hour, 4
+	}
+	count := min(max(limit, 1)*per, maxCandles)
+
+	end := time.Now()
+	start := prices.AlignTime(end, granularity).Add(-time.Duration(count-1) * granularity)
+	params := url.Values{}
+	params.Set("granularity", strconv.Itoa(int(granularity.Seconds())))
+	params.Set("start", start.UTC().Format(time.RFC3339))
+	params.Set("end", end.UTC().Format(time.RFC3339))
+
+	// Rows of [time, low, high, open, close, volume], newest first
+	var rows [][]float64
+	if err := p.get(ctx, fmt.Sprintf("/products/%s/candles", product), params, &rows); err != nil {
+		return nil, err
+	}
+
+	candles := make([]prices.Candle, 0, len(rows))
+	for _, row := range slices.Backward(rows) {
+		if len(row) < 6 {
+			p.logger.Warnw("Failed to parse candle", "row", row)
+			continue
+		}
+		candles = append(candles, prices.Candle{
+			Time:   prices.AlignTime(time.Unix(int64(row[0]), 0), granularity).Unix(),
+			Open:   row[3],
+			High:   row[2],
+			Low:    row[1],
+			Close:  row[4],
+			Volume: row[5],
+		})
+	}
+	if per > 1 {
+		candles = prices.Resample(candles, interval)
+	}
+	if len(candles) > limit {
+		candles = candles[len(candles)-limit:]
+	}
+
+	p.updateHealth(true, nil)
+	p.logger.Debugw("Fetched history from Coinbase", "symbol", symbol, "interval", interval, "candles", len(candles))
+
+	return candles, nil
+}
+
+// FetchPrice retrieves the latest trade price of symbol from the ticker
+// endpoint
+func (p *Provider) FetchPrice(ctx context.Context, symbol string) (prices.Tick, error) {
+	product, err := ProductID(symbol)
+	if err != nil {
+		return prices.Tick{}, err
+	}
+
+	var ticker struct {
+		Price string    `json:"price"`
+		Time  time.Time `json:"time"`
+	}
+	if err := p.get(ctx, fmt.Sprintf("/products/%s/ticker", product), nil, &ticker); err != nil {
+		return prices.Tick{}, err
+	}
+	price, err := strconv.ParseFloat(ticker.Price, 64)
+	if err != nil {
+		p.updateHealth(false, err)
+		return prices.Tick{}, fmt.Errorf("invalid price %q: %w", ticker.Price, err)
+	}
+
+	p.updateHealth(true, nil)
+	return prices.Tick{
+		Symbol: symbol,
+		Price:  price,
+		TsMs:   ticker.Time.UnixMilli(),
+		Source: p.Name(),
+	}, nil
+}
+
+// SubscribeLive subscribes to the trades of symbol on the matches channel
+func (p *Provider) SubscribeLive(ctx context.Context, symbol string, out chan<- prices.Tick) error {
+	return p.Stream(ctx, []string{symbol}, out)
+}
+
+// Stream subscribes to the trades of symbols on the matches channel of one
+// connection. The heartbeat channel keeps quiet products within the read
+// deadline.
+func (p *Provider) Stream(ctx context.Context, symbols []string, out chan<- prices.Tick) error {
+	products := make([]string, len(symbols))
+	byProduct := make(map[string]string, len(symbols))
+	for i, symbol := range symbols {
+		product, err := ProductID(symbol)
+		if err != nil {
+			return err
+		}
+		products[i] = product
+		byProduct[product] = symbol
+	}
+
+	p.logger.Infow("Connecting to Coinbase WebSocket", "url", p.wsURL, "products", products)
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, p.wsURL, nil)
+	if err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to connect to Coinbase WebSocket: %w", err)
+	}
+	defer conn.Close()
+
+	subscribe := map[string]interface{}{
+		"type":        "subscribe",
+		"product_ids": products,
+		"channels":    []string{"matches", "heartbeat"},
+	}
+	if err := conn.WriteJSON(subscribe); err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to subscribe to %v: %w", products, err)
+	}
+
+	p.updateHealth(true, nil)
+	p.logger.Infow("Connected to Coinbase WebSocket", "symbols", symbols)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			p.updateHealth(false, err)
+			p.mu.Lock()
+			p.health.Reconnects++
+			p.mu.Unlock()
+			return fmt.Errorf("WebSocket read error: %w", err)
+		}
+
+		var match CoinbaseMessage
+		if err := json.Unmarshal(message, &match); err != nil {
+			p.logger.Warnw("Failed to parse message", "error", err, "message", string(message))
+			continue
+		}
+		switch match.Type {
+		case "error":
+			err := fmt.Errorf("Coinbase WebSocket error: %s %s", match.Message, match.Reason)
+			p.updateHealth(false, err)
+			return err
+		case "match", "last_match":
+		default:
+			continue
+		}
+		symbol, ok := byProduct[match.ProductID]
+		if !ok {
+			continue
+		}
+
+		price, err := strconv.ParseFloat(match.Price, 64)
+		if err != nil {
+			p.logger.Warnw("Failed to parse trade price", "error", err, "price", match.Price)
+			continue
+		}
+
+		tick := prices.Tick{
+			Symbol: symbol,
+			Price:  price,
+			TsMs:   match.Time.UnixMilli(),
+			Source: p.Name(),
+		}
+
+		// Send tick (non-blocking)
+		select {
+		case out <- tick:
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			// Channel full, skip this tick
+			p.logger.Debugw("Tick channel full, skipping", "symbol", symbol)
+		}
+
+		p.updateHealth(true, nil)
+	}
+}
+
+// CoinbaseMessage represents a message of the Coinbase WebSocket feed: a
+// match of the matches channel, a heartbeat or an error
+type CoinbaseMessage struct {
+	Type      string    `json:"type"`
+	ProductID string    `json:"product_id"`
+	TradeID   int64     `json:"trade_id"`
+	Price     string    `json:"price"`
+	Size      string    `json:"size"`
+	Side      string    `json:"side"`
+	Time      time.Time `json:"time"`
+	Message   string    `json:"message"`
+	Reason    string    `json:"reason"`
+}
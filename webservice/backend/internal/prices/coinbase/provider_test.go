@@ -0,0 +1,80 @@
+package coinbase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestProductID(t *testing.T) {
+	product, err := ProductID("SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "SUI-USD", product)
+	product, err = ProductID("ETHBTC")
+	require.NoError(t, err)
+	assert.Equal(t, "ETH-BTC", product)
+}
+
+func TestProvider(t *testing.T) {
+	hour := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products/SUI-USD/candles":
+			assert.Equal(t, "3600", r.URL.Query().Get("granularity"))
+			// Newest first
+			fmt.Fprintf(w, `[[%d,1.1,1.4,1.2,1.3,20],[%d,1.0,1.3,1.1,1.2,10]]`, hour+3600, hour)
+		case "/products/SUI-USD/ticker":
+			fmt.Fprint(w, `{"price":"1.2345","time":"2024-01-01T00:00:01.5Z"}`)
+		case "/ws":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			var subscribe CoinbaseMessage
+			require.NoError(t, conn.ReadJSON(&subscribe))
+			assert.Equal(t, "subscribe", subscribe.Type)
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"heartbeat","product_id":"SUI-USD"}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"match","product_id":"SUI-USD","price":"1.25","time":"2024-01-01T00:00:02Z"}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"error","message":"Failed to subscribe","reason":"SUI-USD is delisted"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	p := NewProvider(zap.NewNop().Sugar(), WithURLs(server.URL, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws"))
+	ctx := context.Background()
+
+	candles, err := p.FetchHistory(ctx, "SUIUSDT", time.Hour, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []prices.Candle{
+		{Time: hour, Open: 1.1, High: 1.3, Low: 1.0, Close: 1.2, Volume: 10},
+		{Time: hour + 3600, Open: 1.2, High: 1.4, Low: 1.1, Close: 1.3, Volume: 20},
+	}, candles)
+	candles, err = p.FetchHistory(ctx, "SUIUSDT", 4*time.Hour, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []prices.Candle{{Time: hour, Open: 1.1, High: 1.4, Low: 1.0, Close: 1.3, Volume: 30}}, candles)
+
+	tick, err := p.FetchPrice(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, prices.Tick{Symbol: "SUIUSDT", Price: 1.2345, TsMs: hour*1000 + 1500, Source: "coinbase"}, tick)
+
+	out := make(chan prices.Tick, 10)
+	err = p.SubscribeLive(ctx, "SUIUSDT", out)
+	assert.ErrorContains(t, err, "SUI-USD is delisted")
+	require.Len(t, out, 1)
+	assert.Equal(t, prices.Tick{Symbol: "SUIUSDT", Price: 1.25, TsMs: (hour + 2) * 1000, Source: "coinbase"}, <-out)
+	assert.False(t, p.Health().Healthy)
+
+	_, err = p.FetchHistory(ctx, "SUIEUR", time.Hour, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fallback combines providers in order of preference: each request goes to
+// the first of them that serves it, so that one exchange failing or being
+// geo-blocked does not take prices down
+type Fallback struct {
+	providers []Provider
+}
+
+// NewFallback combines providers, the preferred one first
+func NewFallback(providers ...Provider) *Fallback {
+	return &Fallback{providers: providers}
+}
+
+// Name returns the names of the combined providers
+func (f *Fallback) Name() string {
+	names := make([]string, len(f.providers))
+	for i, p := range f.providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Health is healthy while any of the providers is
+func (f *Fallback) Health() ProviderHealth {
+	var health ProviderHealth
+	for _, p := range f.providers {
+		h := p.Health()
+		health.Healthy = health.Healthy || h.Healthy
+		if health.LastError == "" && h.LastError != "" {
+			health.LastError = fmt.Sprintf("%s: %s", p.Name(), h.LastError)
+		}
+		if h.LastSuccess.After(health.LastSuccess) {
+			health.LastSuccess = h.LastSuccess
+		}
+		health.Reconnects += h.Reconnects
+	}
+	return health
+}
+
+// FetchHistory returns the history of the first provider serving it
+func (f *Fallback) FetchHistory(ctx context.Context, symbol string, interval time.Duration, limit int) ([]Candle, error) {
+	var errs []error
+	for _, p := range f.providers {
+		candles, err := p.FetchHistory(ctx, symbol, interval, limit)
+		if err == nil {
+			return candles, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// FetchPrice returns the price quoted by the first provider serving it
+func (f *Fallback) FetchPrice(ctx context.Context, symbol string) (Tick, error) {
+	var errs []error
+	for _, p := range f.providers {
+		quoter, ok := p.(Quoter)
+		if !ok {
+			continue
+		}
+		tick, err := quoter.FetchPrice(ctx, symbol)
+		if err == nil {
+			return tick, nil
+		}
+		if ctx.Err() != nil {
+			return Tick{}, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	if len(errs) == 0 {
+		return Tick{}, fmt.Errorf("no provider of %s quotes prices", f.Name())
+	}
+	return Tick{}, errors.Join(errs...)
+}
+
+// SubscribeLive streams the ticks of the first provider, then those of the
+// next one once its subscription fails, until the last one fails
+func (f *Fallback) SubscribeLive(ctx context.Context, symbol string, out chan<- Tick) error {
+	var errs []error
+	for _, p := range f.providers {
+		err := p.SubscribeLive(ctx, symbol, out)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return errors.Join(errs...)
+}
+
+// Stream streams the ticks of symbols from the first provider, then from
+// the next one once its stream fails, until the last one fails
+func (f *Fallback) Stream(ctx context.Context, symbols []string, out chan<- Tick) error {
+	var errs []error
+	for _, p := range f.providers {
+		err := StreamTicks(ctx, p, symbols, out)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,128 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubProvider struct {
+	name   string
+	health ProviderHealth
+	err    error
+	ticks  []Tick
+}
+
+func (s *stubProvider) Name() string           { return s.name }
+func (s *stubProvider) Health() ProviderHealth { return s.health }
+
+func (s *stubProvider) FetchHistory(ctx context.Context, symbol string, interval time.Duration, limit int) ([]Candle, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []Candle{{Close: s.ticks[0].Price}}, nil
+}
+
+func (s *stubProvider) FetchPrice(ctx context.Context, symbol string) (Tick, error) {
+	if s.err != nil {
+		return Tick{}, s.err
+	}
+	return s.ticks[0], nil
+}
+
+func (s *stubProvider) SubscribeLive(ctx context.Context, symbol string, out chan<- Tick) error {
+	for _, tick := range s.ticks {
+		out <- tick
+	}
+	if s.err != nil {
+		return s.err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	blocked := &stubProvider{name: "binance", err: errors.New("451 unavailable"), health: ProviderHealth{LastError: "451 unavailable"}}
+	coinbase := &stubProvider{name: "coinbase", ticks: []Tick{{Symbol: "SUIUSDT", Price: 1.25, Source: "coinbase"}}, health: ProviderHealth{Healthy: true}}
+	fallback := NewFallback(blocked, coinbase)
+
+	assert.Equal(t, "binance,coinbase", fallback.Name())
+	health := fallback.Health()
+	assert.True(t, health.Healthy)
+	assert.Equal(t, "binance: 451 unavailable", health.LastError)
+
+	tick, err := fallback.FetchPrice(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "coinbase", tick.Source)
+	candles, err := fallback.FetchHistory(ctx, "SUIUSDT", time.Hour, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1.25, candles[0].Close)
+
+	out := make(chan Tick, 1)
+	go fallback.SubscribeLive(ctx, "SUIUSDT", out)
+	select {
+	case tick := <-out:
+		assert.Equal(t, "coinbase", tick.Source)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no tick from the fallback provider")
+	}
+
+	_, err = NewFallback(blocked).FetchPrice(ctx, "SUIUSDT")
+	assert.ErrorContains(t, err, "binance: 451 unavailable")
+	assert.ErrorContains(t, NewFallback(blocked).SubscribeLive(ctx, "SUIUSDT", out), "binance: 451 unavailable")
+}
+
+// symbolProvider fails the subscriptions of the symbols of errs, and
+// streams one tick of each of the others until cancelled
+type symbolProvider struct {
+	stubProvider
+	errs map[string]error
+}
+
+func (s *symbolProvider) SubscribeLive(ctx context.Context, symbol string, out chan<- Tick) error {
+	if err := s.errs[symbol]; err != nil {
+		return err
+	}
+	out <- Tick{Symbol: symbol, Source: s.name}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestStreamTicks(t *testing.T) {
+	ctx := context.Background()
+	kraken := &symbolProvider{stubProvider: stubProvider{name: "kraken"}, errs: map[string]error{"ETHUSDT": errors.New("unknown pair")}}
+	out := make(chan Tick, 10)
+
+	// A subscription per symbol, ended by the first failure
+	err := StreamTicks(ctx, kraken, []string{"SUIUSDT", "ETHUSDT"}, out)
+	assert.ErrorContains(t, err, "unknown pair")
+
+	// Then the next provider of a fallback
+	okx := &symbolProvider{stubProvider: stubProvider{name: "okx"}}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- StreamTicks(ctx, NewFallback(kraken, okx), []string{"SUIUSDT", "ETHUSDT"}, out)
+	}()
+	// Kraken may send its SUIUSDT tick before its ETHUSDT subscription
+	// fails, each time
+	symbols := map[string]bool{}
+	for len(symbols) < 2 {
+		select {
+		case tick := <-out:
+			if tick.Source == "okx" {
+				symbols[tick.Symbol] = true
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no tick from the fallback provider")
+		}
+	}
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,407 @@
+package kraken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"go.uber.org/zap"
+)
+
+const (
+	KrakenRestAPI = "https://api.kraken.com"
+	KrakenWS      = "wss://ws.kraken.com/v2"
+)
+
+// Provider implements the prices.Provider interface for Kraken
+type Provider struct {
+	logger  *zap.SugaredLogger
+	client  *http.Client
+	restURL string
+	wsURL   string
+
+	mu     sync.RWMutex
+	health prices.ProviderHealth
+}
+
+// Option configures a Provider
+type Option func(*Provider)
+
+// WithURLs points the provider at other REST and WebSocket endpoints
+func WithURLs(restURL, wsURL string) Option {
+	return func(p *Provider) {
+		p.restURL = restURL
+		p.wsURL = wsURL
+	}
+}
+
+// NewProvider creates a new Kraken provider
+func NewProvider(logger *zap.SugaredLogger, opts ...Option) *Provider {
+	p := &Provider{
+		logger: logger,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		restURL: KrakenRestAPI,
+		wsURL:   KrakenWS,
+		health: prices.ProviderHealth{
+			Healthy:     true,
+			LastSuccess: time.Now(),
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Name returns the provider identifier
+func (p *Provider) Name() string {
+	return "kraken"
+}
+
+// Health returns current provider health status
+func (p *Provider) Health() prices.ProviderHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.health
+}
+
+// updateHealth updates the provider health status
+func (p *Provider) updateHealth(healthy bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.health.Healthy = healthy
+	if healthy {
+		p.health.LastSuccess = time.Now()
+		p.health.LastError = ""
+	} else if err != nil {
+		p.health.LastError = err.Error()
+	}
+}
+
+// pairAssets returns the Kraken assets of a normalized symbol: Kraken
+// quotes in USD rather than USDT
+func pairAssets(symbol string) (base, quote string, err error) {
+	base, quote, err = prices.SplitSymbol(symbol)
+	if err != nil {
+		return "", "", err
+	}
+	if quote == "USDT" {
+		quote = "USD"
+	}
+	return base, quote, nil
+}
+
+// Pair returns the REST pair of a normalized symbol, e.g. SUIUSD for
+// SUIUSDT. The REST API still names bitcoin XBT.
+func Pair(symbol string) (string, error) {
+	base, quote, err := pairAssets(symbol)
+	if err != nil {
+		return "", err
+	}
+	xbt := strings.NewReplacer("BTC", "XBT")
+	return xbt.Replace(base) + xbt.Replace(quote), nil
+}
+
+// WSSymbol returns the WebSocket symbol of a normalized symbol, e.g.
+// SUI/USD for SUIUSDT
+func WSSymbol(symbol string) (string, error) {
+	base, quote, err := pairAssets(symbol)
+	if err != nil {
+		return "", err
+	}
+	return base + "/" + quote, nil
+}
+
+// get returns the results of the pairs of the public REST method, Kraken
+// keying them by its own name of the pair
+func (p *Provider) get(ctx context.Context, method string, params url.Values) (map[string]json.RawMessage, error) {
+	requestURL := fmt.Sprintf("%s/0/public/%s?%s", p.restURL, method, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		p.updateHealth(false, err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.updateHealth(false, err)
+		return nil, fmt.Errorf("failed to fetch from Kraken: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("Kraken API error: %d", resp.StatusCode)
+		p.updateHealth(false, err)
+		return nil, err
+	}
+
+	var body struct {
+		Error  []string                   `json:"error"`
+		Result map[string]json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		p.updateHealth(false, err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(body.Error) > 0 {
+		err := fmt.Errorf("Kraken API error: %s", strings.Join(body.Error, "; "))
+		p.updateHealth(false, err)
+		return nil, err
+	}
+	delete(body.Result, "last")
+	return body.Result, nil
+}
+
+// FetchHistory retrieves historical OHLC data from Kraken, which returns
+// up to 720 candles
+func (p *Provider) FetchHistory(ctx context.Context, symbol string, interval time.Duration, limit int) ([]prices.Candle, error) {
+	pair, err := Pair(symbol)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("pair", pair)
+	params.Set("interval", strconv.Itoa(int(interval.Minutes())))
+	params.Set("since", strconv.FormatInt(time.Now().Add(-time.Duration(limit)*interval).Unix(), 10))
+
+	result, err := p.get(ctx, "OHLC", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var candles []prices.Candle
+	for _, raw := range result {
+		// Rows of [time, open, high, low, close, vwap, volume, count], oldest first
+		var rows [][]interface{}
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			p.updateHealth(false, err)
+			return nil, fmt.Errorf("failed to decode OHLC: %w", err)
+		}
+		candles = make([]prices.Candle, 0, len(rows))
+		for _, row := range rows {
+			candle, err := parseOHLC(row)
+			if err != nil {
+				p.logger.Warnw("Failed to parse OHLC", "error", err, "row", row)
+				continue
+			}
+			candle.Time = prices.AlignTime(time.Unix(candle.Time, 0), interval).Unix()
+			candles = append(candles, candle)
+		}
+	}
+	if len(candles) > limit {
+		candles = candles[len(candles)-limit:]
+	}
+
+	p.updateHealth(true, nil)
+	p.logger.Debugw("Fetched history from Kraken", "symbol", symbol, "interval", interval, "candles", len(candles))
+
+	return candles, nil
+}
+
+// FetchPrice retrieves the last trade price of symbol from the ticker
+// endpoint. The tick is timestamped when the response arrives.
+func (p *Provider) FetchPrice(ctx context.Context, symbol string) (prices.Tick, error) {
+	pair, err := Pair(symbol)
+	if err != nil {
+		return prices.Tick{}, err
+	}
+	params := url.Values{}
+	params.Set("pair", pair)
+
+	result, err := p.get(ctx, "Ticker", params)
+	if err != nil {
+		return prices.Tick{}, err
+	}
+	for _, raw := range result {
+		var ticker struct {
+			LastTrade []string `json:"c"` // [price, lot volume]
+		}
+		if err := json.Unmarshal(raw, &ticker); err != nil || len(ticker.LastTrade) == 0 {
+			err := fmt.Errorf("invalid ticker %s", raw)
+			p.updateHealth(false, err)
+			return prices.Tick{}, err
+		}
+		price, err := strconv.ParseFloat(ticker.LastTrade[0], 64)
+		if err != nil {
+			p.updateHealth(false, err)
+			return prices.Tick{}, fmt.Errorf("invalid price %q: %w", ticker.LastTrade[0], err)
+		}
+
+		p.updateHealth(true, nil)
+		return prices.Tick{
+			Symbol: symbol,
+			Price:  price,
+			TsMs:   time.Now().UnixMilli(),
+			Source: p.Name(),
+		}, nil
+	}
+	err = fmt.Errorf("no ticker for %s", pair)
+	p.updateHealth(false, err)
+	return prices.Tick{}, err
+}
+
+// SubscribeLive subscribes to the trades of symbol on the trade channel
+func (p *Provider) SubscribeLive(ctx context.Context, symbol string, out chan<- prices.Tick) error {
+	return p.Stream(ctx, []string{symbol}, out)
+}
+
+// Stream subscribes to the trades of symbols on the trade channel of one
+// connection. Kraken sends heartbeats every second, keeping the connection
+// within the read deadline.
+func (p *Provider) Stream(ctx context.Context, symbols []string, out chan<- prices.Tick) error {
+	wsSymbols := make([]string, len(symbols))
+	byWSSymbol := make(map[string]string, len(symbols))
+	for i, symbol := range symbols {
+		wsSymbol, err := WSSymbol(symbol)
+		if err != nil {
+			return err
+		}
+		wsSymbols[i] = wsSymbol
+		byWSSymbol[wsSymbol] = symbol
+	}
+
+	p.logger.Infow("Connecting to Kraken WebSocket", "url", p.wsURL, "symbols", wsSymbols)
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, p.wsURL, nil)
+	if err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to connect to Kraken WebSocket: %w", err)
+	}
+	defer conn.Close()
+
+	subscribe := map[string]interface{}{
+		"method": "subscribe",
+		"params": map[string]interface{}{
+			"channel":  "trade",
+			"symbol":   wsSymbols,
+			"snapshot": false,
+		},
+	}
+	if err := conn.WriteJSON(subscribe); err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to subscribe to %v: %w", wsSymbols, err)
+	}
+
+	p.updateHealth(true, nil)
+	p.logger.Infow("Connected to Kraken WebSocket", "symbols", symbols)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			p.updateHealth(false, err)
+			p.mu.Lock()
+			p.health.Reconnects++
+			p.mu.Unlock()
+			return fmt.Errorf("WebSocket read error: %w", err)
+		}
+
+		var msg KrakenMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			p.logger.Warnw("Failed to parse message", "error", err, "message", string(message))
+			continue
+		}
+		if msg.Method == "subscribe" && msg.Success != nil && !*msg.Success {
+			err := fmt.Errorf("Kraken WebSocket error: %s", msg.Error)
+			p.updateHealth(false, err)
+			return err
+		}
+		if msg.Channel != "trade" {
+			continue
+		}
+
+		for _, trade := range msg.Data {
+			symbol, ok := byWSSymbol[trade.Symbol]
+			if !ok {
+				continue
+			}
+			tick := prices.Tick{
+				Symbol: symbol,
+				Price:  trade.Price,
+				TsMs:   trade.Timestamp.UnixMilli(),
+				Source: p.Name(),
+			}
+
+			// Send tick (non-blocking)
+			select {
+			case out <- tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				// Channel full, skip this tick
+				p.logger.Debugw("Tick channel full, skipping", "symbol", symbol)
+			}
+		}
+
+		p.updateHealth(true, nil)
+	}
+}
+
+// KrakenMessage represents a message of the Kraken WebSocket v2 API: the
+// acknowledgement of a subscription or an update of a channel
+type KrakenMessage struct {
+	Method  string `json:"method"`
+	Success *bool  `json:"success"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	Type    string `json:"type"`
+	Data    []struct {
+		Symbol    string    `json:"symbol"`
+		Side      string    `json:"side"`
+		Price     float64   `json:"price"`
+		Qty       float64   `json:"qty"`
+		TradeID   int64     `json:"trade_id"`
+		Timestamp time.Time `json:"timestamp"`
+	} `json:"data"`
+}
+
+// parseOHLC converts a Kraken OHLC row to our Candle struct
+func parseOHLC(row []interface{}) (prices.Candle, error) {
+	if len(row) < 7 {
+		return prices.Candle{}, fmt.Errorf("invalid OHLC format: expected 8 fields, got %d", len(row))
+	}
+	openTime, ok := row[0].(float64)
+	if !ok {
+		return prices.Candle{}, fmt.Errorf("invalid open time format")
+	}
+
+	var values [5]float64 // open, high, low, close, volume
+	for i, idx := range []int{1, 2, 3, 4, 6} {
+		s, ok := row[idx].(string)
+		if !ok {
+			return prices.Candle{}, fmt.Errorf("invalid field %d: %v", idx, row[idx])
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return prices.Candle{}, fmt.Errorf("invalid field %d: %w", idx, err)
+		}
+		values[i] = v
+	}
+
+	return prices.Candle{
+		Time:   int64(openTime),
+		Open:   values[0],
+		High:   values[1],
+		Low:    values[2],
+		Close:  values[3],
+		Volume: values[4],
+	}, nil
+}
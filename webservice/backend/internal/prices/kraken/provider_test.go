@@ -0,0 +1,83 @@
+package kraken
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPair(t *testing.T) {
+	pair, err := Pair("SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "SUIUSD", pair)
+	pair, err = Pair("BTCUSDC")
+	require.NoError(t, err)
+	assert.Equal(t, "XBTUSDC", pair)
+	symbol, err := WSSymbol("BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "BTC/USD", symbol)
+}
+
+func TestProvider(t *testing.T) {
+	minute := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/public/OHLC":
+			assert.Equal(t, "SUIUSD", r.URL.Query().Get("pair"))
+			assert.Equal(t, "1", r.URL.Query().Get("interval"))
+			fmt.Fprintf(w, `{"error":[],"result":{"SUIUSD":[[%d,"1.0","1.2","0.9","1.1","1.05","100.5",7],[%d,"1.1","1.3","1.0","1.2","1.15","50",3]],"last":%d}}`, minute, minute+60, minute+60)
+		case "/0/public/Ticker":
+			if r.URL.Query().Get("pair") != "SUIUSD" {
+				fmt.Fprint(w, `{"error":["EQuery:Unknown asset pair"]}`)
+				return
+			}
+			fmt.Fprint(w, `{"error":[],"result":{"SUIUSD":{"c":["1.2345","10"]}}}`)
+		case "/ws":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			var subscribe map[string]interface{}
+			require.NoError(t, conn.ReadJSON(&subscribe))
+			assert.Equal(t, []interface{}{"SUI/USD"}, subscribe["params"].(map[string]interface{})["symbol"])
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"method":"subscribe","result":{"channel":"trade","symbol":"SUI/USD"},"success":true}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"channel":"heartbeat"}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"channel":"trade","type":"update","data":[{"symbol":"SUI/USD","price":1.25,"qty":3,"timestamp":"2024-01-01T00:00:02.000000Z"},{"symbol":"SUI/USD","price":1.26,"qty":1,"timestamp":"2024-01-01T00:00:03.000000Z"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	p := NewProvider(zap.NewNop().Sugar(), WithURLs(server.URL, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws"))
+	ctx := context.Background()
+
+	candles, err := p.FetchHistory(ctx, "SUIUSDT", time.Minute, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []prices.Candle{{Time: minute + 60, Open: 1.1, High: 1.3, Low: 1.0, Close: 1.2, Volume: 50}}, candles)
+
+	tick, err := p.FetchPrice(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "kraken", tick.Source)
+	assert.Equal(t, 1.2345, tick.Price)
+	_, err = p.FetchPrice(ctx, "ETHUSDT")
+	assert.ErrorContains(t, err, "Unknown asset pair")
+	assert.False(t, p.Health().Healthy)
+
+	out := make(chan prices.Tick, 10)
+	err = p.SubscribeLive(ctx, "SUIUSDT", out)
+	assert.ErrorContains(t, err, "WebSocket read error")
+	require.Len(t, out, 2)
+	assert.Equal(t, prices.Tick{Symbol: "SUIUSDT", Price: 1.25, TsMs: (minute + 2) * 1000, Source: "kraken"}, <-out)
+	assert.Equal(t, 1.26, (<-out).Price)
+	assert.Equal(t, 1, p.Health().Reconnects)
+}
@@ -123,6 +123,7 @@ func (g *Generator) SubscribeLive(ctx context.Context, symbol string, out chan<-
 				Symbol: symbol,
 				Price:  currentPrice,
 				TsMs:   time.Now().UnixMilli(),
+				Source: "mock",
 			}
 			
 			// Send tick (non-blocking)
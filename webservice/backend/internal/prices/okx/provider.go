@@ -0,0 +1,410 @@
+package okx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"go.uber.org/zap"
+)
+
+const (
+	OKXRestAPI = "https://www.okx.com"
+	OKXWS      = "wss://ws.okx.com:8443/ws/v5/public"
+
+	// maxCandles is the most candles OKX returns per request
+	maxCandles = 300
+	// pingInterval keeps connections alive: OKX drops them after 30s
+	// without messages
+	pingInterval = 20 * time.Second
+)
+
+// Provider implements the prices.Provider interface for OKX
+type Provider struct {
+	logger  *zap.SugaredLogger
+	client  *http.Client
+	restURL string
+	wsURL   string
+
+	mu     sync.RWMutex
+	health prices.ProviderHealth
+}
+
+// Option configures a Provider
+type Option func(*Provider)
+
+// WithURLs points the provider at other REST and WebSocket endpoints
+func WithURLs(restURL, wsURL string) Option {
+	return func(p *Provider) {
+		p.restURL = restURL
+		p.wsURL = wsURL
+	}
+}
+
+// NewProvider creates a new OKX provider
+func NewProvider(logger *zap.SugaredLogger, opts ...Option) *Provider {
+	p := &Provider{
+		logger: logger,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		restURL: OKXRestAPI,
+		wsURL:   OKXWS,
+		health: prices.ProviderHealth{
+			Healthy:     true,
+			LastSuccess: time.Now(),
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Name returns the provider identifier
+func (p *Provider) Name() string {
+	return "okx"
+}
+
+// Health returns current provider health status
+func (p *Provider) Health() prices.ProviderHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.health
+}
+
+// updateHealth updates the provider health status
+func (p *Provider) updateHealth(healthy bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.health.Healthy = healthy
+	if healthy {
+		p.health.LastSuccess = time.Now()
+		p.health.LastError = ""
+	} else if err != nil {
+		p.health.LastError = err.Error()
+	}
+}
+
+// InstID returns the OKX instrument of a normalized symbol, e.g. SUI-USDT
+// for SUIUSDT
+func InstID(symbol string) (string, error) {
+	base, quote, err := prices.SplitSymbol(symbol)
+	if err != nil {
+		return "", err
+	}
+	return base + "-" + quote, nil
+}
+
+// get decodes the data of the response to a GET of path into v
+func (p *Provider) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	requestURL := fmt.Sprintf("%s%s?%s", p.restURL, path, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to fetch from OKX: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("OKX API error: %d", resp.StatusCode)
+		p.updateHealth(false, err)
+		return err
+	}
+
+	var body struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if body.Code != "0" {
+		err := fmt.Errorf("OKX API error %s: %s", body.Code, body.Msg)
+		p.updateHealth(false, err)
+		return err
+	}
+	if err := json.Unmarshal(body.Data, v); err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to decode data: %w", err)
+	}
+	return nil
+}
+
+// FetchHistory retrieves historical candles from OKX
+func (p *Provider) FetchHistory(ctx context.Context, symbol string, interval time.Duration, limit int) ([]prices.Candle, error) {
+	instID, err := InstID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("instId", instID)
+	params.Set("bar", okxBar(interval))
+	params.Set("limit", strconv.Itoa(min(max(limit, 1), maxCandles)))
+
+	// Rows of [ts, open, high, low, close, volume, ...], newest first
+	var rows [][]string
+	if err := p.get(ctx, "/api/v5/market/candles", params, &rows); err != nil {
+		return nil, err
+	}
+
+	candles := make([]prices.Candle, 0, len(rows))
+	for _, row := range slices.Backward(rows) {
+		candle, err := parseCandle(row)
+		if err != nil {
+			p.logger.Warnw("Failed to parse candle", "error", err, "row", row)
+			continue
+		}
+		candle.Time = prices.AlignTime(time.Unix(candle.Time, 0), interval).Unix()
+		candles = append(candles, candle)
+	}
+
+	p.updateHealth(true, nil)
+	p.logger.Debugw("Fetched history from OKX", "symbol", symbol, "interval", interval, "candles", len(candles))
+
+	return candles, nil
+}
+
+// FetchPrice retrieves the last trade price of symbol from the ticker
+// endpoint
+func (p *Provider) FetchPrice(ctx context.Context, symbol string) (prices.Tick, error) {
+	instID, err := InstID(symbol)
+	if err != nil {
+		return prices.Tick{}, err
+	}
+	params := url.Values{}
+	params.Set("instId", instID)
+
+	var tickers []struct {
+		Last string `json:"last"`
+		Ts   string `json:"ts"`
+	}
+	if err := p.get(ctx, "/api/v5/market/ticker", params, &tickers); err != nil {
+		return prices.Tick{}, err
+	}
+	if len(tickers) == 0 {
+		err := fmt.Errorf("no ticker for %s", instID)
+		p.updateHealth(false, err)
+		return prices.Tick{}, err
+	}
+	price, err := strconv.ParseFloat(tickers[0].Last, 64)
+	if err != nil {
+		p.updateHealth(false, err)
+		return prices.Tick{}, fmt.Errorf("invalid price %q: %w", tickers[0].Last, err)
+	}
+	ts, err := strconv.ParseInt(tickers[0].Ts, 10, 64)
+	if err != nil {
+		ts = time.Now().UnixMilli()
+	}
+
+	p.updateHealth(true, nil)
+	return prices.Tick{
+		Symbol: symbol,
+		Price:  price,
+		TsMs:   ts,
+		Source: p.Name(),
+	}, nil
+}
+
+// SubscribeLive subscribes to the trades of symbol on the trades channel
+func (p *Provider) SubscribeLive(ctx context.Context, symbol string, out chan<- prices.Tick) error {
+	return p.Stream(ctx, []string{symbol}, out)
+}
+
+// Stream subscribes to the trades channels of symbols over one connection
+func (p *Provider) Stream(ctx context.Context, symbols []string, out chan<- prices.Tick) error {
+	args := make([]map[string]string, len(symbols))
+	instIDs := make([]string, len(symbols))
+	byInstID := make(map[string]string, len(symbols))
+	for i, symbol := range symbols {
+		instID, err := InstID(symbol)
+		if err != nil {
+			return err
+		}
+		args[i] = map[string]string{"channel": "trades", "instId": instID}
+		instIDs[i] = instID
+		byInstID[instID] = symbol
+	}
+
+	p.logger.Infow("Connecting to OKX WebSocket", "url", p.wsURL, "instIds", instIDs)
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, p.wsURL, nil)
+	if err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to connect to OKX WebSocket: %w", err)
+	}
+	defer conn.Close()
+
+	subscribe := map[string]interface{}{
+		"op":   "subscribe",
+		"args": args,
+	}
+	if err := conn.WriteJSON(subscribe); err != nil {
+		p.updateHealth(false, err)
+		return fmt.Errorf("failed to subscribe to %v: %w", instIDs, err)
+	}
+
+	p.updateHealth(true, nil)
+	p.logger.Infow("Connected to OKX WebSocket", "symbols", symbols)
+
+	// Keep quiet instruments alive; the reads below see the pongs
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			p.updateHealth(false, err)
+			p.mu.Lock()
+			p.health.Reconnects++
+			p.mu.Unlock()
+			return fmt.Errorf("WebSocket read error: %w", err)
+		}
+		if string(message) == "pong" {
+			continue
+		}
+
+		var msg OKXMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			p.logger.Warnw("Failed to parse message", "error", err, "message", string(message))
+			continue
+		}
+		if msg.Event == "error" {
+			err := fmt.Errorf("OKX WebSocket error %s: %s", msg.Code, msg.Msg)
+			p.updateHealth(false, err)
+			return err
+		}
+
+		for _, trade := range msg.Data {
+			symbol, ok := byInstID[trade.InstID]
+			if !ok {
+				continue
+			}
+			price, err := strconv.ParseFloat(trade.Px, 64)
+			if err != nil {
+				p.logger.Warnw("Failed to parse trade price", "error", err, "price", trade.Px)
+				continue
+			}
+			ts, _ := strconv.ParseInt(trade.Ts, 10, 64)
+
+			tick := prices.Tick{
+				Symbol: symbol,
+				Price:  price,
+				TsMs:   ts,
+				Source: p.Name(),
+			}
+
+			// Send tick (non-blocking)
+			select {
+			case out <- tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				// Channel full, skip this tick
+				p.logger.Debugw("Tick channel full, skipping", "symbol", symbol)
+			}
+		}
+
+		p.updateHealth(true, nil)
+	}
+}
+
+// OKXMessage represents a message of the OKX public WebSocket: an event
+// answering a subscription, or the trades of a channel
+type OKXMessage struct {
+	Event string `json:"event"`
+	Code  string `json:"code"`
+	Msg   string `json:"msg"`
+	Data  []struct {
+		InstID  string `json:"instId"`
+		TradeID string `json:"tradeId"`
+		Px      string `json:"px"`
+		Sz      string `json:"sz"`
+		Side    string `json:"side"`
+		Ts      string `json:"ts"`
+	} `json:"data"`
+}
+
+// parseCandle converts an OKX candle row to our Candle struct
+func parseCandle(row []string) (prices.Candle, error) {
+	if len(row) < 6 {
+		return prices.Candle{}, fmt.Errorf("invalid candle format: expected 9 fields, got %d", len(row))
+	}
+	var values [6]float64 // ts, open, high, low, close, volume
+	for i := range values {
+		v, err := strconv.ParseFloat(row[i], 64)
+		if err != nil {
+			return prices.Candle{}, fmt.Errorf("invalid field %d: %w", i, err)
+		}
+		values[i] = v
+	}
+
+	return prices.Candle{
+		Time:   int64(values[0]) / 1000, // Convert ms to seconds
+		Open:   values[1],
+		High:   values[2],
+		Low:    values[3],
+		Close:  values[4],
+		Volume: values[5],
+	}, nil
+}
+
+// okxBar converts time.Duration to an OKX bar. Daily bars are those of
+// UTC days, as ours are.
+func okxBar(d time.Duration) string {
+	switch d {
+	case time.Minute:
+		return "1m"
+	case 5 * time.Minute:
+		return "5m"
+	case 15 * time.Minute:
+		return "15m"
+	case time.Hour:
+		return "1H"
+	case 4 * time.Hour:
+		return "4H"
+	case 24 * time.Hour:
+		return "1Dutc"
+	default:
+		return "1H" // default fallback
+	}
+}
@@ -0,0 +1,72 @@
+package okx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestProvider(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v5/market/candles":
+			assert.Equal(t, "SUI-USDT", r.URL.Query().Get("instId"))
+			assert.Equal(t, "1Dutc", r.URL.Query().Get("bar"))
+			// Newest first
+			fmt.Fprintf(w, `{"code":"0","msg":"","data":[["%d","1.1","1.3","1.0","1.2","50","60","60","0"],["%d","1.0","1.2","0.9","1.1","100","110","110","1"]]}`, (day+86400)*1000, day*1000)
+		case "/api/v5/market/ticker":
+			if r.URL.Query().Get("instId") != "SUI-USDT" {
+				fmt.Fprint(w, `{"code":"51001","msg":"Instrument ID does not exist","data":[]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"code":"0","msg":"","data":[{"instId":"SUI-USDT","last":"1.2345","ts":"%d"}]}`, day*1000)
+		case "/ws":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			var subscribe map[string]interface{}
+			require.NoError(t, conn.ReadJSON(&subscribe))
+			assert.Equal(t, "subscribe", subscribe["op"])
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"subscribe","arg":{"channel":"trades","instId":"SUI-USDT"}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+			conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"arg":{"channel":"trades","instId":"SUI-USDT"},"data":[{"instId":"SUI-USDT","px":"1.25","sz":"3","side":"buy","ts":"%d"}]}`, day*1000+2000)))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"error","code":"60012","msg":"Invalid request"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	p := NewProvider(zap.NewNop().Sugar(), WithURLs(server.URL, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws"))
+	ctx := context.Background()
+
+	candles, err := p.FetchHistory(ctx, "SUIUSDT", 24*time.Hour, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []prices.Candle{
+		{Time: day, Open: 1.0, High: 1.2, Low: 0.9, Close: 1.1, Volume: 100},
+		{Time: day + 86400, Open: 1.1, High: 1.3, Low: 1.0, Close: 1.2, Volume: 50},
+	}, candles)
+
+	tick, err := p.FetchPrice(ctx, "SUIUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, prices.Tick{Symbol: "SUIUSDT", Price: 1.2345, TsMs: day * 1000, Source: "okx"}, tick)
+	_, err = p.FetchPrice(ctx, "SUIUSDC")
+	assert.ErrorContains(t, err, "Instrument ID does not exist")
+
+	out := make(chan prices.Tick, 10)
+	err = p.SubscribeLive(ctx, "SUIUSDT", out)
+	assert.ErrorContains(t, err, "Invalid request")
+	require.Len(t, out, 1)
+	assert.Equal(t, prices.Tick{Symbol: "SUIUSDT", Price: 1.25, TsMs: day*1000 + 2000, Source: "okx"}, <-out)
+}
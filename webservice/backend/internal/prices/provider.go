@@ -9,7 +9,8 @@ import (
 type Tick struct {
 	Symbol string  `json:"symbol"`
 	Price  float64 `json:"price"`
-	TsMs   int64   `json:"ts"` // milliseconds since epoch
+	TsMs   int64   `json:"ts"`               // milliseconds since epoch
+	Source string  `json:"source,omitempty"` // Name of the provider
 }
 
 // Candle represents OHLCV data for a time period
@@ -42,6 +43,18 @@ type Provider interface {
 	Health() ProviderHealth
 }
 
+// Quoter is implemented by providers that quote the latest price of a
+// symbol on request
+type Quoter interface {
+	FetchPrice(ctx context.Context, symbol string) (Tick, error)
+}
+
+// Streamer is implemented by providers that stream the trades of several
+// symbols over one connection
+type Streamer interface {
+	Stream(ctx context.Context, symbols []string, out chan<- Tick) error
+}
+
 // ProviderHealth represents the current status of a provider
 type ProviderHealth struct {
 	Healthy     bool      `json:"healthy"`
@@ -0,0 +1,87 @@
+// Package providers builds the exchange price providers selected by name
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/leafsii/leafsii-backend/internal/prices"
+	"github.com/leafsii/leafsii-backend/internal/prices/binance"
+	"github.com/leafsii/leafsii-backend/internal/prices/coinbase"
+	"github.com/leafsii/leafsii-backend/internal/prices/kraken"
+	"github.com/leafsii/leafsii-backend/internal/prices/okx"
+	"go.uber.org/zap"
+)
+
+// Exchange providers
+const (
+	Binance  = "binance"
+	Coinbase = "coinbase"
+	Kraken   = "kraken"
+	OKX      = "okx"
+)
+
+// Provider is a provider also quoting the latest price on request, as all
+// the exchange providers do
+type Provider interface {
+	prices.Provider
+	prices.Quoter
+}
+
+// New returns the provider of names, or their prices.Fallback in the order
+// given when there are several
+func New(logger *zap.SugaredLogger, names ...string) (Provider, error) {
+	selected, err := newProviders(logger, names)
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 1 {
+		return selected[0], nil
+	}
+	combined := make([]prices.Provider, len(selected))
+	for i, p := range selected {
+		combined[i] = p
+	}
+	return prices.NewFallback(combined...), nil
+}
+
+// NewAggregator returns an aggregator of the quotes of the providers of
+// names
+func NewAggregator(logger *zap.SugaredLogger, names []string, opts ...prices.AggregatorOption) (*prices.Aggregator, error) {
+	selected, err := newProviders(logger, names)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]prices.Source, len(selected))
+	for i, p := range selected {
+		sources[i] = p
+	}
+	return prices.NewAggregator(sources, opts...), nil
+}
+
+// newProviders returns the providers of names, skipping blank ones
+func newProviders(logger *zap.SugaredLogger, names []string) ([]Provider, error) {
+	var selected []Provider
+	for _, name := range names {
+		var p Provider
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+			continue
+		case Binance:
+			p = binance.NewProvider(logger)
+		case Coinbase:
+			p = coinbase.NewProvider(logger)
+		case Kraken:
+			p = kraken.NewProvider(logger)
+		case OKX:
+			p = okx.NewProvider(logger)
+		default:
+			return nil, fmt.Errorf("unknown price provider %q: want %s, %s, %s or %s", name, Binance, Coinbase, Kraken, OKX)
+		}
+		selected = append(selected, p)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no price provider selected")
+	}
+	return selected, nil
+}
@@ -0,0 +1,28 @@
+package prices
+
+import "context"
+
+// StreamTicks streams the ticks of symbols from p until ctx is done or the
+// stream fails: over one connection when p is a Streamer, otherwise over a
+// subscription per symbol, all of which end once any of them fails
+func StreamTicks(ctx context.Context, p Provider, symbols []string, out chan<- Tick) error {
+	if streamer, ok := p.(Streamer); ok {
+		return streamer.Stream(ctx, symbols, out)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(symbols))
+	for _, symbol := range symbols {
+		go func() {
+			errs <- p.SubscribeLive(ctx, symbol, out)
+		}()
+	}
+	err := <-errs
+	cancel()
+	// Nothing writes to out once returned
+	for range len(symbols) - 1 {
+		<-errs
+	}
+	return err
+}
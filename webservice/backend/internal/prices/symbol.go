@@ -0,0 +1,44 @@
+package prices
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// quoteAssets are the quote assets of normalized symbols, longest first so
+// that e.g. USDT is not taken for USD
+var quoteAssets = []string{"USDT", "USDC", "USD", "BTC", "ETH"}
+
+// SplitSymbol splits a normalized symbol, the Binance style concatenation
+// of base and quote used across the backend (e.g. "SUIUSDT"), into its
+// base and quote assets
+func SplitSymbol(symbol string) (base, quote string, err error) {
+	symbol = strings.ToUpper(symbol)
+	for _, quote := range quoteAssets {
+		if base, ok := strings.CutSuffix(symbol, quote); ok && base != "" {
+			return base, quote, nil
+		}
+	}
+	return "", "", fmt.Errorf("unsupported symbol %q", symbol)
+}
+
+// Resample rolls candles, oldest first, up into candles of interval, for
+// providers without history at that interval
+func Resample(candles []Candle, interval time.Duration) []Candle {
+	resampled := make([]Candle, 0, len(candles))
+	for _, candle := range candles {
+		start := AlignTime(time.Unix(candle.Time, 0), interval).Unix()
+		if n := len(resampled); n > 0 && resampled[n-1].Time == start {
+			last := &resampled[n-1]
+			last.High = max(last.High, candle.High)
+			last.Low = min(last.Low, candle.Low)
+			last.Close = candle.Close
+			last.Volume += candle.Volume
+			continue
+		}
+		candle.Time = start
+		resampled = append(resampled, candle)
+	}
+	return resampled
+}
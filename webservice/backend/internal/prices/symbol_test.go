@@ -0,0 +1,39 @@
+package prices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSymbol(t *testing.T) {
+	for symbol, want := range map[string][2]string{
+		"SUIUSDT": {"SUI", "USDT"},
+		"ethusdc": {"ETH", "USDC"},
+		"SUIUSD":  {"SUI", "USD"},
+		"ETHBTC":  {"ETH", "BTC"},
+	} {
+		base, quote, err := SplitSymbol(symbol)
+		require.NoError(t, err, symbol)
+		assert.Equal(t, want, [2]string{base, quote}, symbol)
+	}
+	for _, symbol := range []string{"", "USDT", "SUIEUR"} {
+		_, _, err := SplitSymbol(symbol)
+		assert.Error(t, err, symbol)
+	}
+}
+
+func TestResample(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	var hourly []Candle
+	for i := int64(0); i < 6; i++ {
+		hourly = append(hourly, Candle{Time: start + i*3600, Open: float64(i), High: float64(i) + 2, Low: float64(i) - 1, Close: float64(i) + 1, Volume: 10})
+	}
+
+	assert.Equal(t, []Candle{
+		{Time: start, Open: 0, High: 5, Low: -1, Close: 4, Volume: 40},
+		{Time: start + 4*3600, Open: 4, High: 7, Low: 3, Close: 6, Volume: 20},
+	}, Resample(hourly, 4*time.Hour))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -23,6 +24,8 @@ type Cache struct {
 
 	logger  *zap.SugaredLogger
 	metrics *metrics.Metrics
+
+	lockMu sync.Mutex // Serializes in-memory locks
 }
 
 func NewCache(addr string, logger *zap.SugaredLogger, metrics *metrics.Metrics) (*Cache, error) {
@@ -62,15 +65,22 @@ func NewCache(addr string, logger *zap.SugaredLogger, metrics *metrics.Metrics)
 	}, nil
 }
 
+// UsingFallback reports whether Redis was unavailable at startup and the
+// cache is running on the in-memory store
+func (c *Cache) UsingFallback() bool {
+	return c.client == nil
+}
+
 // Cache key prefixes
 const (
-	KeyProtocolState = "fx:protocol:state"
-	KeySPIndex       = "fx:sp:index"
-	KeyOraclePrice   = "fx:oracle:price"
-	KeyUserPosition  = "fx:user:position"
-	KeyQuoteMint     = "fx:quotes:mint"
-	KeyQuoteRedeem   = "fx:quotes:redeem"
-	KeyQuoteStake    = "fx:quotes:stake"
+	KeyProtocolState   = "fx:protocol:state"
+	KeySPIndex         = "fx:sp:index"
+	KeyOraclePrice     = "fx:oracle:price"
+	KeyOracleAggregate = "fx:oracle:aggregate"
+	KeyUserPosition    = "fx:user:position"
+	KeyQuoteMint       = "fx:quotes:mint"
+	KeyQuoteRedeem     = "fx:quotes:redeem"
+	KeyQuoteStake      = "fx:quotes:stake"
 )
 
 func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
@@ -173,6 +183,71 @@ func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
 	return count > 0, nil
 }
 
+// DeletePrefix deletes every key starting with prefix and returns how many
+// were removed. Keys are scanned in batches, so keys written meanwhile may
+// survive.
+func (c *Cache) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	if prefix == "" {
+		return 0, fmt.Errorf("cache delete prefix: empty prefix")
+	}
+	match := prefix + "*"
+
+	var scan func(cursor uint64) ([]string, uint64, error)
+	var del func(keys []string) (int64, error)
+	if c.client != nil {
+		scan = func(cursor uint64) ([]string, uint64, error) {
+			return c.client.Scan(ctx, cursor, match, 500).Result()
+		}
+		del = func(keys []string) (int64, error) {
+			return c.client.Del(ctx, keys...).Result()
+		}
+	} else {
+		scanner, ok := c.kvStore.(kv.Scanner)
+		if !ok {
+			return 0, kv.ErrScanNotSupported
+		}
+		scan = func(cursor uint64) ([]string, uint64, error) {
+			return scanner.Scan(ctx, cursor, match, 500)
+		}
+		del = func(keys []string) (int64, error) {
+			return c.kvStore.Del(ctx, keys...)
+		}
+	}
+
+	// Collect before deleting: the in-memory cursor is an offset into the
+	// sorted keys, which deletes would shift
+	var matched []string
+	var cursor uint64
+	for {
+		keys, next, err := scan(cursor)
+		if err != nil {
+			return 0, fmt.Errorf("cache scan error: %w", err)
+		}
+		matched = append(matched, keys...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	var deleted int64
+	for start := 0; start < len(matched); start += 500 {
+		end := start + 500
+		if end > len(matched) {
+			end = len(matched)
+		}
+		removed, err := del(matched[start:end])
+		if err != nil {
+			return deleted, fmt.Errorf("cache delete error: %w", err)
+		}
+		deleted += removed
+	}
+	if c.logger != nil {
+		c.logger.Infow("Cache keys deleted", "prefix", prefix, "deleted", deleted)
+	}
+	return deleted, nil
+}
+
 // Specialized cache methods
 func (c *Cache) GetProtocolState(ctx context.Context, dest interface{}) error {
 	return c.Get(ctx, KeyProtocolState, dest)
@@ -210,6 +285,18 @@ func (c *Cache) SetOraclePrice(ctx context.Context, symbol string, value interfa
 	return c.Set(ctx, key, value, ttl)
 }
 
+// GetOracleAggregate reads the latest aggregate of the quotes of symbol
+// across price providers, as the price publisher caches it
+func (c *Cache) GetOracleAggregate(ctx context.Context, symbol string, dest interface{}) error {
+	key := fmt.Sprintf("%s:%s", KeyOracleAggregate, symbol)
+	return c.Get(ctx, key, dest)
+}
+
+func (c *Cache) SetOracleAggregate(ctx context.Context, symbol string, value interface{}, ttl time.Duration) error {
+	key := fmt.Sprintf("%s:%s", KeyOracleAggregate, symbol)
+	return c.Set(ctx, key, value, ttl)
+}
+
 // Quote cache methods with unique keys
 func (c *Cache) GetQuote(ctx context.Context, quoteType, quoteID string, dest interface{}) error {
 	key := fmt.Sprintf("fx:quotes:%s:%s", quoteType, quoteID)
@@ -221,6 +308,83 @@ func (c *Cache) SetQuote(ctx context.Context, quoteType, quoteID string, value i
 	return c.Set(ctx, key, value, ttl)
 }
 
+// DeleteQuote removes a cached quote, so that it is used once, and reports
+// whether it was still cached
+func (c *Cache) DeleteQuote(ctx context.Context, quoteType, quoteID string) (bool, error) {
+	key := fmt.Sprintf("fx:quotes:%s:%s", quoteType, quoteID)
+	return c.remove(ctx, key)
+}
+
+// Wallet sign-in methods: challenges are keyed by address, sessions by the
+// hash of their token
+func (c *Cache) SetAuthChallenge(ctx context.Context, address string, value interface{}, ttl time.Duration) error {
+	return c.Set(ctx, "fx:auth:challenge:"+address, value, ttl)
+}
+
+// TakeAuthChallenge reads and removes the challenge of address, so that it
+// is answered once. It returns ErrCacheMiss when another request took it.
+func (c *Cache) TakeAuthChallenge(ctx context.Context, address string, dest interface{}) error {
+	key := "fx:auth:challenge:" + address
+	if err := c.Get(ctx, key, dest); err != nil {
+		return err
+	}
+	removed, err := c.remove(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrCacheMiss
+	}
+	return nil
+}
+
+func (c *Cache) GetAuthSession(ctx context.Context, tokenHash string, dest interface{}) error {
+	return c.Get(ctx, "fx:auth:session:"+tokenHash, dest)
+}
+
+func (c *Cache) SetAuthSession(ctx context.Context, tokenHash string, value interface{}, ttl time.Duration) error {
+	return c.Set(ctx, "fx:auth:session:"+tokenHash, value, ttl)
+}
+
+func (c *Cache) DeleteAuthSession(ctx context.Context, tokenHash string) error {
+	return c.Delete(ctx, "fx:auth:session:"+tokenHash)
+}
+
+// Idempotency methods: responses of mutating requests, keyed by the
+// route and the Idempotency-Key of the client
+func (c *Cache) GetIdempotentResponse(ctx context.Context, key string, dest interface{}) error {
+	return c.Get(ctx, "fx:idempotency:"+key, dest)
+}
+
+func (c *Cache) SetIdempotentResponse(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return c.Set(ctx, "fx:idempotency:"+key, value, ttl)
+}
+
+// Response cache methods: rendered responses of read-heavy routes, keyed
+// by the route and its query
+func (c *Cache) GetCachedResponse(ctx context.Context, key string, dest interface{}) error {
+	return c.Get(ctx, "fx:response:"+key, dest)
+}
+
+func (c *Cache) SetCachedResponse(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return c.Set(ctx, "fx:response:"+key, value, ttl)
+}
+
+// remove deletes key and reports whether it was still cached
+func (c *Cache) remove(ctx context.Context, key string) (bool, error) {
+	var removed int64
+	var err error
+	if c.client != nil {
+		removed, err = c.client.Del(ctx, key).Result()
+	} else {
+		removed, err = c.kvStore.Del(ctx, key)
+	}
+	if err != nil {
+		return false, fmt.Errorf("cache delete error: %w", err)
+	}
+	return removed > 0, nil
+}
+
 // Pub/Sub methods for real-time updates
 func (c *Cache) Publish(ctx context.Context, channel string, message interface{}) error {
 	data, err := json.Marshal(message)
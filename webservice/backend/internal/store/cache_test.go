@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -96,4 +97,69 @@ func TestInMemoryPubSub(t *testing.T) {
 	}
 	
 	t.Log("In-memory PubSub test completed successfully")
-}
\ No newline at end of file
+}
+func TestInMemoryLock(t *testing.T) {
+	cache, err := NewCache("invalid:6379", zap.NewNop().Sugar(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+	ctx := context.Background()
+
+	held, err := cache.AcquireLock(ctx, "test:lock", "a", time.Minute)
+	if err != nil || !held {
+		t.Fatalf("Expected a to take the lock, got %v, %v", held, err)
+	}
+	if held, _ := cache.AcquireLock(ctx, "test:lock", "b", time.Minute); held {
+		t.Fatal("Expected b not to take a held lock")
+	}
+	if held, _ := cache.AcquireLock(ctx, "test:lock", "a", time.Minute); !held {
+		t.Fatal("Expected a to extend its lock")
+	}
+
+	// Only the holder releases
+	if err := cache.ReleaseLock(ctx, "test:lock", "b"); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if held, _ := cache.AcquireLock(ctx, "test:lock", "b", time.Minute); held {
+		t.Fatal("Expected the lock to survive release by b")
+	}
+	if err := cache.ReleaseLock(ctx, "test:lock", "a"); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if held, _ := cache.AcquireLock(ctx, "test:lock", "b", time.Minute); !held {
+		t.Fatal("Expected b to take the released lock")
+	}
+}
+
+func TestInMemoryDeletePrefix(t *testing.T) {
+	cache, err := NewCache("invalid:6379", zap.NewNop().Sugar(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 1200; i++ {
+		if err := cache.Set(ctx, fmt.Sprintf("fx:response:%d", i), i, time.Minute); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+	if err := cache.Set(ctx, KeyProtocolState, 1, time.Minute); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	deleted, err := cache.DeletePrefix(ctx, "fx:response:")
+	if err != nil || deleted != 1200 {
+		t.Fatalf("Expected 1200 keys deleted, got %d, %v", deleted, err)
+	}
+	if exists, _ := cache.Exists(ctx, "fx:response:7"); exists {
+		t.Fatal("Expected prefixed keys to be deleted")
+	}
+	if exists, _ := cache.Exists(ctx, KeyProtocolState); !exists {
+		t.Fatal("Expected other keys to survive")
+	}
+	if _, err := cache.DeletePrefix(ctx, ""); err == nil {
+		t.Fatal("Expected an empty prefix to be rejected")
+	}
+}
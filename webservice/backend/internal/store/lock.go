@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireLockScript takes KEYS[1] for ARGV[1] for ARGV[2] milliseconds,
+// or extends it when ARGV[1] holds it already
+var acquireLockScript = redis.NewScript(`
+if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	redis.call("pexpire", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseLockScript deletes KEYS[1] when ARGV[1] holds it
+var releaseLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLock takes the lock key for owner until ttl passes, or extends it
+// when owner holds it already, and reports whether owner holds it. Locks
+// are shared by replicas through Redis; in memory they only exclude
+// callers of this process.
+func (c *Cache) AcquireLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	if c.client != nil {
+		held, err := acquireLockScript.Run(ctx, c.client, []string{key}, owner, ttl.Milliseconds()).Int()
+		if err != nil {
+			return false, fmt.Errorf("cache lock error: %w", err)
+		}
+		return held == 1, nil
+	}
+
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+	holder, err := c.kvStore.GetString(ctx, key)
+	if err != nil && err != kv.ErrNotFound {
+		return false, fmt.Errorf("cache lock error: %w", err)
+	}
+	if err == nil && holder != owner {
+		return false, nil
+	}
+	if err := c.kvStore.SetString(ctx, key, owner, ttl); err != nil {
+		return false, fmt.Errorf("cache lock error: %w", err)
+	}
+	return true, nil
+}
+
+// ReleaseLock frees the lock key when owner holds it
+func (c *Cache) ReleaseLock(ctx context.Context, key, owner string) error {
+	if c.client != nil {
+		if err := releaseLockScript.Run(ctx, c.client, []string{key}, owner).Err(); err != nil {
+			return fmt.Errorf("cache unlock error: %w", err)
+		}
+		return nil
+	}
+
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+	holder, err := c.kvStore.GetString(ctx, key)
+	if err == kv.ErrNotFound || (err == nil && holder != owner) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cache unlock error: %w", err)
+	}
+	if _, err := c.kvStore.Del(ctx, key); err != nil {
+		return fmt.Errorf("cache unlock error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Package validation checks request DTOs against their validate struct tags,
+// so that REST and JSON-RPC requests are held to the same rules.
+//
+// Rules are comma separated:
+//
+//	required     not empty
+//	omitempty    skip the other rules when empty
+//	oneof=a b    one of the space separated values
+//	amount       positive decimal string, at most calc.MaxAmount
+//	min=n, max=n numbers, or decimal strings, within the bound
+//	suiaddress   Sui address
+//	base64       standard base64
+//
+// Fields are named by their json or form tag. A code tag names the API
+// error code failures of the field are reported with, optionally per rule:
+// code:"INVALID_USER_ADDRESS,required=MISSING_USER_ADDRESS".
+package validation
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/leafsii/leafsii-backend/internal/calc"
+	"github.com/pattonkan/sui-go/sui"
+	"github.com/shopspring/decimal"
+)
+
+// FieldError is a field failing one of its rules
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Errors are the fields of a request failing their rules, in field order
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// rule is a parsed rule of a validate tag
+type rule struct {
+	name  string
+	param string
+}
+
+// field is a struct field with rules
+type field struct {
+	index []int
+	name  string
+	codes map[string]string // By rule, "" for the others
+	rules []rule
+}
+
+// fieldsCache holds the fields of the struct types checked so far
+var fieldsCache sync.Map // reflect.Type -> []field
+
+// Struct checks v, a struct or a pointer to one, and returns Errors
+// listing each field failing a rule, or nil. Only the first failing rule of
+// a field is reported.
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: %T is not a struct", v)
+	}
+
+	var errs Errors
+	for _, f := range fieldsOf(rv.Type()) {
+		if fe := f.check(rv.FieldByIndex(f.index)); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" || !sf.IsExported() {
+			continue
+		}
+		f := field{index: sf.Index, name: fieldName(sf), codes: map[string]string{}}
+		if codes := sf.Tag.Get("code"); codes != "" {
+			for _, part := range strings.Split(codes, ",") {
+				if ruleName, code, ok := strings.Cut(part, "="); ok {
+					f.codes[ruleName] = code
+				} else {
+					f.codes[""] = part
+				}
+			}
+		}
+		for _, part := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+			f.rules = append(f.rules, rule{name: name, param: param})
+		}
+		fields = append(fields, f)
+	}
+	fieldsCache.Store(t, fields)
+	return fields
+}
+
+// fieldName returns the json or form name of sf
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// check returns the first rule v fails, or nil
+func (f field) check(v reflect.Value) *FieldError {
+	empty := v.IsZero() || (v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "") ||
+		((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0)
+	for _, r := range f.rules {
+		var message string
+		switch r.name {
+		case "omitempty":
+			if empty {
+				return nil
+			}
+		case "required":
+			if empty {
+				message = "is required"
+			}
+		case "oneof":
+			if !strings.Contains(" "+r.param+" ", " "+fmt.Sprint(v.Interface())+" ") {
+				message = "must be one of " + strings.Join(strings.Fields(r.param), ", ")
+			}
+		case "amount":
+			amount, err := decimal.NewFromString(v.String())
+			switch {
+			case err != nil || !amount.IsPositive():
+				message = "must be a positive decimal string"
+			case amount.GreaterThan(calc.MaxAmount):
+				message = "is too large"
+			}
+		case "min", "max":
+			message = checkBound(v, r)
+		case "suiaddress":
+			if _, err := sui.AddressFromHex(v.String()); err != nil {
+				message = "must be a Sui address"
+			}
+		case "base64":
+			if _, err := base64.StdEncoding.DecodeString(v.String()); err != nil {
+				message = "must be base64 encoded"
+			}
+		default:
+			panic("validation: unknown rule " + r.name + " of " + f.name)
+		}
+		if message != "" {
+			code, ok := f.codes[r.name]
+			if !ok {
+				code = f.codes[""]
+			}
+			return &FieldError{Field: f.name, Rule: r.name, Code: code, Message: f.name + " " + message}
+		}
+	}
+	return nil
+}
+
+// checkBound checks v against the min or max rule r, returning the failure
+// message or ""
+func checkBound(v reflect.Value, r rule) string {
+	bound, err := decimal.NewFromString(r.param)
+	if err != nil {
+		panic("validation: bad bound " + r.param)
+	}
+	var value decimal.Decimal
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = decimal.NewFromInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = decimal.RequireFromString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.String:
+		if value, err = decimal.NewFromString(v.String()); err != nil {
+			return "must be a decimal string"
+		}
+	default:
+		panic("validation: " + r.name + " of " + v.Kind().String())
+	}
+	if r.name == "min" && value.LessThan(bound) {
+		return "must be at least " + r.param
+	}
+	if r.name == "max" && value.GreaterThan(bound) {
+		return "must be at most " + r.param
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type buildRequest struct {
+	Action      string `json:"action" validate:"required,oneof=mint redeem" code:"INVALID_ACTION"`
+	Amount      string `json:"amount" validate:"required,amount" code:"INVALID_AMOUNT,required=MISSING_AMOUNT"`
+	Mode        string `json:"mode,omitempty" validate:"omitempty,oneof=execution devinspect"`
+	SlippageBps int    `json:"slippageBps,omitempty" validate:"min=0,max=10000"`
+	Sender      string `form:"sender" validate:"omitempty,suiaddress"`
+	TxBytes     string `validate:"omitempty,base64"`
+	Note        string `json:"note"`
+}
+
+func TestStruct(t *testing.T) {
+	valid := buildRequest{Action: "mint", Amount: "1.5", SlippageBps: 50}
+	require.NoError(t, Struct(valid))
+	require.NoError(t, Struct(&valid))
+
+	for _, tt := range []struct {
+		name string
+		edit func(*buildRequest)
+		want FieldError
+	}{
+		{name: "missing", edit: func(r *buildRequest) { r.Action = " " },
+			want: FieldError{Field: "action", Rule: "required", Code: "INVALID_ACTION", Message: "action is required"}},
+		{name: "not one of", edit: func(r *buildRequest) { r.Action = "burn" },
+			want: FieldError{Field: "action", Rule: "oneof", Code: "INVALID_ACTION", Message: "action must be one of mint, redeem"}},
+		{name: "rule code", edit: func(r *buildRequest) { r.Amount = "" },
+			want: FieldError{Field: "amount", Rule: "required", Code: "MISSING_AMOUNT", Message: "amount is required"}},
+		{name: "not a number", edit: func(r *buildRequest) { r.Amount = "abc" },
+			want: FieldError{Field: "amount", Rule: "amount", Code: "INVALID_AMOUNT", Message: "amount must be a positive decimal string"}},
+		{name: "zero", edit: func(r *buildRequest) { r.Amount = "0" },
+			want: FieldError{Field: "amount", Rule: "amount", Code: "INVALID_AMOUNT", Message: "amount must be a positive decimal string"}},
+		{name: "too large", edit: func(r *buildRequest) { r.Amount = "1e31" },
+			want: FieldError{Field: "amount", Rule: "amount", Code: "INVALID_AMOUNT", Message: "amount is too large"}},
+		{name: "optional enum", edit: func(r *buildRequest) { r.Mode = "dry" },
+			want: FieldError{Field: "mode", Rule: "oneof", Message: "mode must be one of execution, devinspect"}},
+		{name: "below min", edit: func(r *buildRequest) { r.SlippageBps = -1 },
+			want: FieldError{Field: "slippageBps", Rule: "min", Message: "slippageBps must be at least 0"}},
+		{name: "above max", edit: func(r *buildRequest) { r.SlippageBps = 10001 },
+			want: FieldError{Field: "slippageBps", Rule: "max", Message: "slippageBps must be at most 10000"}},
+		{name: "address", edit: func(r *buildRequest) { r.Sender = "0xnot" },
+			want: FieldError{Field: "sender", Rule: "suiaddress", Message: "sender must be a Sui address"}},
+		{name: "base64", edit: func(r *buildRequest) { r.TxBytes = "!!" },
+			want: FieldError{Field: "TxBytes", Rule: "base64", Message: "TxBytes must be base64 encoded"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.edit(&req)
+			err := Struct(req)
+			var errs Errors
+			require.ErrorAs(t, err, &errs)
+			assert.Equal(t, Errors{tt.want}, errs)
+			assert.Equal(t, tt.want.Message, err.Error())
+		})
+	}
+
+	// Every failing field is listed, in field order
+	err := Struct(buildRequest{Action: "burn", SlippageBps: 20000})
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 3)
+	assert.Equal(t, "action must be one of mint, redeem; amount is required; slippageBps must be at most 10000", err.Error())
+
+	assert.Error(t, Struct("mint"))
+	assert.NoError(t, Struct((*buildRequest)(nil)))
+}
@@ -0,0 +1,240 @@
+package watchlist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/shopspring/decimal"
+)
+
+// Alert tells the owner of an item that its condition changed. Previous
+// and Current are what the evaluator observed before and now; Previous is
+// empty when a peg item starts beyond its threshold.
+type Alert struct {
+	ID       string          `json:"id"`
+	ItemID   string          `json:"itemId"`
+	Owner    string          `json:"owner"`
+	Kind     string          `json:"kind"`
+	Target   string          `json:"target,omitempty"`
+	Label    string          `json:"label,omitempty"`
+	Message  string          `json:"message"`
+	Previous json.RawMessage `json:"previous,omitempty"`
+	Current  json.RawMessage `json:"current"`
+	At       time.Time       `json:"at"`
+}
+
+// balanceState is the observation of a balance item
+type balanceState struct {
+	F decimal.Decimal `json:"f"`
+	X decimal.Decimal `json:"x"`
+	R decimal.Decimal `json:"r"`
+}
+
+// movedBy reports whether any balance moved by more than threshold from
+// previous
+func (b balanceState) movedBy(previous balanceState, threshold decimal.Decimal) bool {
+	return b.F.Sub(previous.F).Abs().GreaterThan(threshold) ||
+		b.X.Sub(previous.X).Abs().GreaterThan(threshold) ||
+		b.R.Sub(previous.R).Abs().GreaterThan(threshold)
+}
+
+// receiptState is the observation of a receipt item
+type receiptState struct {
+	Status string `json:"status"`
+	Stage  string `json:"stage"`
+}
+
+// pegState is the observation of a peg item
+type pegState struct {
+	Deviation decimal.Decimal `json:"deviation"`
+	Beyond    bool            `json:"beyond"` // Of the threshold
+}
+
+// RunOnce evaluates every active item, records what it observed and
+// delivers the alerts it calls for, which it returns. Items are first
+// observed silently, except peg items already beyond their threshold.
+// Items failing to be read are skipped until the next run. With a lock,
+// replicas not holding it evaluate nothing.
+func (s *Service) RunOnce(ctx context.Context, now time.Time) ([]Alert, error) {
+	if s.cache != nil {
+		held, err := s.cache.AcquireLock(ctx, evaluatorLockKey, s.id, evaluatorLockTTL)
+		if err != nil || !held {
+			return nil, err
+		}
+		defer func() {
+			if err := s.cache.ReleaseLock(context.Background(), evaluatorLockKey, s.id); err != nil {
+				s.logger.Warnw("Failed to release watchlist lock", "error", err)
+			}
+		}()
+	}
+
+	// The protocol state is read once per run, by the first peg item
+	var protocol *onchain.ProtocolState
+	protocolState := func() (*onchain.ProtocolState, error) {
+		if protocol != nil {
+			return protocol, nil
+		}
+		state, err := s.protocol.ProtocolState(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("read protocol state: %w", err)
+		}
+		protocol = state
+		return state, nil
+	}
+
+	var alerts []Alert
+	after := ""
+	for {
+		limit := evaluatePageSize
+		q := &interfaces.Query{
+			Where:   &interfaces.Filters{Conditions: []interfaces.Filter{{Field: "active", Value: true}}},
+			OrderBy: []interfaces.OrderBy{{Field: "id", Direction: "asc"}},
+			Limit:   &limit,
+		}
+		if after != "" {
+			q.Where.Conditions = append(q.Where.Conditions, interfaces.Filter{Field: "id", Operator: &interfaces.FilterOperator{Gt: after}})
+		}
+		page, err := s.items.FindMany(ctx, q)
+		if err != nil {
+			return alerts, fmt.Errorf("find watchlist items: %w", err)
+		}
+		for i := range page.Data {
+			item := &page.Data[i]
+			alert, err := s.evaluate(ctx, item, now, protocolState)
+			if err != nil {
+				s.logger.Warnw("Failed to evaluate watchlist item", "id", item.ID, "kind", item.Kind, "error", err)
+				continue
+			}
+			if alert == nil {
+				continue
+			}
+			alerts = append(alerts, *alert)
+			for _, notifier := range s.notifiers {
+				if err := notifier.Notify(ctx, *alert); err != nil {
+					s.logger.Warnw("Failed to deliver watchlist alert", "id", alert.ID, "owner", alert.Owner, "error", err)
+				}
+			}
+		}
+		if len(page.Data) < limit {
+			return alerts, nil
+		}
+		after = page.Data[len(page.Data)-1].ID
+	}
+}
+
+// evaluate observes item, records the observation when it is the first or
+// calls for an alert, and returns the alert, if any
+func (s *Service) evaluate(ctx context.Context, item *entities.WatchlistItem, now time.Time, protocolState func() (*onchain.ProtocolState, error)) (*Alert, error) {
+	first := item.State == ""
+	var current interface{}
+	var alert bool
+	var message string
+
+	switch item.Kind {
+	case KindBalance:
+		if s.balances == nil {
+			return nil, nil
+		}
+		balances, err := s.balances.GetBalances(ctx, item.Target)
+		if err != nil {
+			return nil, err
+		}
+		observed := balanceState{F: balances.F, X: balances.X, R: balances.R}
+		if !first {
+			var previous balanceState
+			if err := json.Unmarshal([]byte(item.State), &previous); err != nil {
+				return nil, fmt.Errorf("decode state: %w", err)
+			}
+			alert = observed.movedBy(previous, decimal.NewFromFloat(item.Threshold))
+		}
+		current = observed
+		message = fmt.Sprintf("Balances of %s changed to %s F, %s X and %s R", item.Target, observed.F, observed.X, observed.R)
+
+	case KindReceipt:
+		if s.receipts == nil {
+			return nil, nil
+		}
+		receipt, err := s.receipts.GetReceipt(ctx, item.Target)
+		if err != nil {
+			return nil, err
+		}
+		observed := receiptState{Status: receipt.Status(), Stage: string(receipt.Stage())}
+		var previous receiptState
+		if !first {
+			if err := json.Unmarshal([]byte(item.State), &previous); err != nil {
+				return nil, fmt.Errorf("decode state: %w", err)
+			}
+			alert = observed != previous
+		}
+		current = observed
+		message = fmt.Sprintf("Receipt %s moved from %s to %s", item.Target, previous.Stage, observed.Stage)
+		if previous.Stage == observed.Stage {
+			message = fmt.Sprintf("Receipt %s is now %s", item.Target, observed.Status)
+		}
+
+	case KindPeg:
+		if s.protocol == nil {
+			return nil, nil
+		}
+		state, err := protocolState()
+		if err != nil {
+			return nil, err
+		}
+		threshold := decimal.NewFromFloat(item.Threshold)
+		deviation := state.PegDeviation
+		observed := pegState{Deviation: deviation, Beyond: deviation.Abs().GreaterThanOrEqual(threshold)}
+		var previous pegState
+		if !first {
+			if err := json.Unmarshal([]byte(item.State), &previous); err != nil {
+				return nil, fmt.Errorf("decode state: %w", err)
+			}
+		}
+		alert = observed.Beyond != previous.Beyond
+		current = observed
+		percent := threshold.Mul(decimal.NewFromInt(100)).String()
+		message = fmt.Sprintf("fToken is back within %s%% of its peg", percent)
+		if observed.Beyond {
+			message = fmt.Sprintf("fToken is %s%% off its peg, beyond %s%%", deviation.Abs().Mul(decimal.NewFromInt(100)).StringFixed(2), percent)
+		}
+
+	default:
+		return nil, nil
+	}
+
+	if !first && !alert {
+		return nil, nil
+	}
+	previous := item.State
+	item.State = marshalState(current)
+	if alert {
+		at := now.UTC()
+		item.TriggeredAt = &at
+	}
+	if _, err := s.items.Update(ctx, item); err != nil {
+		return nil, fmt.Errorf("record watchlist state: %w", err)
+	}
+	if !alert {
+		return nil, nil
+	}
+
+	result := &Alert{
+		ID:      fmt.Sprintf("%s-%d", item.ID, now.UnixMilli()),
+		ItemID:  item.ID,
+		Owner:   item.Owner,
+		Kind:    item.Kind,
+		Target:  item.Target,
+		Label:   item.Label,
+		Message: message,
+		Current: json.RawMessage(item.State),
+		At:      now.UTC(),
+	}
+	if previous != "" {
+		result.Previous = json.RawMessage(previous)
+	}
+	return result, nil
+}
@@ -0,0 +1,412 @@
+// Package watchlist alerts signed-in users when conditions they watch
+// change: the balances of an address, the status of one of their bridge
+// receipts, or the deviation of fToken from its peg. An evaluator job
+// compares each active item with what it last observed and delivers alerts
+// through notifiers, such as the WebSocket hub and webhook subscriptions.
+package watchlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	gdb "github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/leafsii/leafsii-backend/internal/webhooks"
+	"go.uber.org/zap"
+)
+
+// Kinds of items
+const (
+	// KindBalance watches the F, X and R balances of Target, the owner
+	// by default, alerting on changes larger than Threshold tokens
+	KindBalance = "balance"
+
+	// KindReceipt watches the status and stage of the bridge receipt
+	// Target, which the owner must own
+	KindReceipt = "receipt"
+
+	// KindPeg watches the peg deviation of fToken, alerting when it
+	// crosses Threshold, a fraction such as 0.01, either way
+	KindPeg = "peg"
+)
+
+// MaxItemsPerOwner caps the items of one owner
+const MaxItemsPerOwner = 50
+
+// maxLabelLength caps the labels owners give items
+const maxLabelLength = 64
+
+// AlertsChannel is the cache channel alerts are published on. The
+// WebSocket hub forwards them to the topic of their owner, fx:user:<owner>.
+const AlertsChannel = "fx:watchlist:alerts"
+
+// EventAlert is the webhook event type of alerts
+const EventAlert = "watchlist.alert"
+
+const (
+	evaluatorLockKey = "fx:lock:watchlist"
+	evaluatorLockTTL = time.Minute
+	evaluatePageSize = 200
+)
+
+var (
+	// ErrNotFound is returned for unknown item IDs and items of other
+	// owners
+	ErrNotFound = errors.New("watchlist item not found")
+
+	// ErrInvalidRequest is returned for unknown kinds, malformed targets,
+	// receipts of other owners and thresholds out of range
+	ErrInvalidRequest = errors.New("invalid watchlist request")
+
+	// ErrLimitExceeded is returned when an owner has MaxItemsPerOwner
+	// items already
+	ErrLimitExceeded = errors.New("watchlist limit exceeded")
+)
+
+// Item is a watched condition, without the state it was last seen in
+type Item struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Target      string     `json:"target,omitempty"`
+	Threshold   float64    `json:"threshold"`
+	Label       string     `json:"label,omitempty"`
+	Active      bool       `json:"active"`
+	TriggeredAt *time.Time `json:"triggeredAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// ItemRequest describes an item to create
+type ItemRequest struct {
+	Kind      string
+	Target    string
+	Threshold float64
+	Label     string
+}
+
+// ItemUpdate changes the fields of an item that are set
+type ItemUpdate struct {
+	Threshold *float64
+	Label     *string
+	Active    *bool
+}
+
+// BalanceReader reads the token balances of an address
+type BalanceReader interface {
+	GetBalances(ctx context.Context, address string) (*onchain.Balances, error)
+}
+
+// ReceiptReader reads bridge receipts
+type ReceiptReader interface {
+	GetReceipt(ctx context.Context, receiptID string) (*crosschain.Receipt, error)
+}
+
+// Service manages the items of owners and evaluates them
+type Service struct {
+	items     *gdb.TypedRepository[entities.WatchlistItem]
+	balances  BalanceReader               // Nil rejects balance items
+	receipts  ReceiptReader               // Nil rejects receipt items
+	protocol  onchain.ProtocolStateReader // Nil rejects peg items
+	notifiers []Notifier
+	cache     *store.Cache // Nil evaluates on every replica
+	id        string       // Owner of the evaluator lock
+	logger    *zap.SugaredLogger
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithBalances enables balance items, read from r
+func WithBalances(r BalanceReader) Option {
+	return func(s *Service) {
+		s.balances = r
+	}
+}
+
+// WithReceipts enables receipt items, read from r
+func WithReceipts(r ReceiptReader) Option {
+	return func(s *Service) {
+		s.receipts = r
+	}
+}
+
+// WithProtocol enables peg items, read from the protocol state of r
+func WithProtocol(r onchain.ProtocolStateReader) Option {
+	return func(s *Service) {
+		s.protocol = r
+	}
+}
+
+// WithNotifiers delivers alerts through notifiers
+func WithNotifiers(notifiers ...Notifier) Option {
+	return func(s *Service) {
+		s.notifiers = append(s.notifiers, notifiers...)
+	}
+}
+
+// WithLock elects the one replica evaluating through a lock of cache
+func WithLock(cache *store.Cache) Option {
+	return func(s *Service) {
+		s.cache = cache
+	}
+}
+
+func NewService(database interfaces.Database, logger *zap.SugaredLogger, opts ...Option) *Service {
+	s := &Service{
+		items:  gdb.MustNewTypedRepository[entities.WatchlistItem](database, entities.WatchlistItemSchema),
+		id:     randomHex(8),
+		logger: logger,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Create adds an item to the watchlist of owner, a normalized address
+func (s *Service) Create(ctx context.Context, owner string, req ItemRequest) (*Item, error) {
+	target, err := s.validateTarget(ctx, owner, req.Kind, req.Target)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateThreshold(req.Kind, req.Threshold); err != nil {
+		return nil, err
+	}
+	if err := validateLabel(req.Label); err != nil {
+		return nil, err
+	}
+	count, err := s.items.Count(ctx, &interfaces.Query{Where: ownerFilter(owner)})
+	if err != nil {
+		return nil, fmt.Errorf("count watchlist items: %w", err)
+	}
+	if count >= MaxItemsPerOwner {
+		return nil, fmt.Errorf("%w: at most %d items per address", ErrLimitExceeded, MaxItemsPerOwner)
+	}
+
+	entity, err := s.items.Create(ctx, &entities.WatchlistItem{
+		ID:        randomHex(8),
+		Owner:     owner,
+		Kind:      req.Kind,
+		Target:    target,
+		Threshold: req.Threshold,
+		Label:     req.Label,
+		Active:    true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create watchlist item: %w", err)
+	}
+	return itemFromEntity(entity), nil
+}
+
+// List returns the items of owner, oldest first
+func (s *Service) List(ctx context.Context, owner string) ([]*Item, error) {
+	page, err := s.items.FindMany(ctx, &interfaces.Query{
+		Where:   ownerFilter(owner),
+		OrderBy: []interfaces.OrderBy{{Field: "created_at", Direction: "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list watchlist items: %w", err)
+	}
+	items := make([]*Item, 0, len(page.Data))
+	for i := range page.Data {
+		items = append(items, itemFromEntity(&page.Data[i]))
+	}
+	return items, nil
+}
+
+// Get returns the item of owner with id
+func (s *Service) Get(ctx context.Context, owner, id string) (*Item, error) {
+	entity, err := s.get(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	return itemFromEntity(entity), nil
+}
+
+// Update changes the threshold, label or activity of an item of owner.
+// The state it was last seen in is kept, so a new threshold applies from
+// the next evaluation on.
+func (s *Service) Update(ctx context.Context, owner, id string, update ItemUpdate) (*Item, error) {
+	entity, err := s.get(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if update.Threshold != nil {
+		if err := validateThreshold(entity.Kind, *update.Threshold); err != nil {
+			return nil, err
+		}
+		entity.Threshold = *update.Threshold
+	}
+	if update.Label != nil {
+		if err := validateLabel(*update.Label); err != nil {
+			return nil, err
+		}
+		entity.Label = *update.Label
+	}
+	if update.Active != nil {
+		entity.Active = *update.Active
+	}
+	entity, err = s.items.Update(ctx, entity)
+	if err != nil {
+		return nil, fmt.Errorf("update watchlist item: %w", err)
+	}
+	return itemFromEntity(entity), nil
+}
+
+// Delete removes an item of owner
+func (s *Service) Delete(ctx context.Context, owner, id string) error {
+	if _, err := s.get(ctx, owner, id); err != nil {
+		return err
+	}
+	if err := s.items.Delete(ctx, interfaces.StringID(id)); err != nil {
+		return fmt.Errorf("delete watchlist item: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) get(ctx context.Context, owner, id string) (*entities.WatchlistItem, error) {
+	entity, err := s.items.GetByID(ctx, interfaces.StringID(id))
+	if errors.Is(err, interfaces.ErrNotFound) || (err == nil && entity.Owner != owner) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get watchlist item: %w", err)
+	}
+	return entity, nil
+}
+
+// validateTarget checks that owner may watch target with an item of kind
+// and returns it normalized
+func (s *Service) validateTarget(ctx context.Context, owner, kind, target string) (string, error) {
+	switch kind {
+	case KindBalance:
+		if s.balances == nil {
+			return "", fmt.Errorf("%w: balance alerts are unavailable", ErrInvalidRequest)
+		}
+		if target == "" {
+			return owner, nil
+		}
+		normalized, err := onchain.NormalizeAddress(target)
+		if err != nil {
+			return "", fmt.Errorf("%w: target must be a Sui address", ErrInvalidRequest)
+		}
+		return normalized, nil
+
+	case KindReceipt:
+		if s.receipts == nil {
+			return "", fmt.Errorf("%w: receipt alerts are unavailable", ErrInvalidRequest)
+		}
+		if target == "" {
+			return "", fmt.Errorf("%w: target must be a receipt ID", ErrInvalidRequest)
+		}
+		receipt, err := s.receipts.GetReceipt(ctx, target)
+		if errors.Is(err, crosschain.ErrNotFound) {
+			return "", fmt.Errorf("%w: unknown receipt %s", ErrInvalidRequest, target)
+		}
+		if err != nil {
+			return "", err
+		}
+		if receiptOwner, err := onchain.NormalizeAddress(ownerOf(receipt)); err != nil || receiptOwner != owner {
+			return "", fmt.Errorf("%w: receipt %s is not owned by the signed-in address", ErrInvalidRequest, target)
+		}
+		return target, nil
+
+	case KindPeg:
+		if s.protocol == nil {
+			return "", fmt.Errorf("%w: peg alerts are unavailable", ErrInvalidRequest)
+		}
+		if target != "" {
+			return "", fmt.Errorf("%w: peg items take no target", ErrInvalidRequest)
+		}
+		return "", nil
+
+	default:
+		return "", fmt.Errorf("%w: kind must be one of %s, %s or %s", ErrInvalidRequest, KindBalance, KindReceipt, KindPeg)
+	}
+}
+
+func validateThreshold(kind string, threshold float64) error {
+	switch {
+	case threshold < 0:
+		return fmt.Errorf("%w: threshold must not be negative", ErrInvalidRequest)
+	case kind == KindPeg && (threshold == 0 || threshold >= 1):
+		return fmt.Errorf("%w: peg thresholds are fractions between 0 and 1", ErrInvalidRequest)
+	}
+	return nil
+}
+
+func validateLabel(label string) error {
+	if len(label) > maxLabelLength {
+		return fmt.Errorf("%w: label must be at most %d bytes", ErrInvalidRequest, maxLabelLength)
+	}
+	return nil
+}
+
+func ownerFilter(owner string) *interfaces.Filters {
+	return &interfaces.Filters{Conditions: []interfaces.Filter{{Field: "owner", Value: owner}}}
+}
+
+func ownerOf(receipt *crosschain.Receipt) string {
+	if receipt.Deposit != nil {
+		return receipt.Deposit.SuiOwner
+	}
+	return receipt.Redeem.SuiOwner
+}
+
+func itemFromEntity(e *entities.WatchlistItem) *Item {
+	return &Item{
+		ID:          e.ID,
+		Kind:        e.Kind,
+		Target:      e.Target,
+		Threshold:   e.Threshold,
+		Label:       e.Label,
+		Active:      e.Active,
+		TriggeredAt: e.TriggeredAt,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+}
+
+func randomHex(size int) string {
+	bytes := make([]byte, size)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// Notifier delivers alerts
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// CacheNotifier publishes alerts on AlertsChannel
+type CacheNotifier struct {
+	Cache *store.Cache
+}
+
+func (n CacheNotifier) Notify(ctx context.Context, alert Alert) error {
+	return n.Cache.Publish(ctx, AlertsChannel, alert)
+}
+
+// WebhookNotifier publishes alerts as EventAlert webhook events
+type WebhookNotifier struct {
+	Webhooks *webhooks.Service
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	return n.Webhooks.Publish(ctx, EventAlert, "watchlist:"+alert.ID, alert)
+}
+
+// marshalState encodes an observation for WatchlistItem.State
+func marshalState(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
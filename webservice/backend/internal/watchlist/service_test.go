@@ -0,0 +1,231 @@
+package watchlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/crosschain"
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	alice = "0x0000000000000000000000000000000000000000000000000000000000000a11"
+	bob   = "0x0000000000000000000000000000000000000000000000000000000000000b0b"
+)
+
+type fakeBalances map[string]*onchain.Balances
+
+func (f fakeBalances) GetBalances(_ context.Context, address string) (*onchain.Balances, error) {
+	if b, ok := f[address]; ok {
+		return b, nil
+	}
+	return nil, errors.New("no balances")
+}
+
+type fakeReceipts map[string]*crosschain.BridgeReceipt
+
+func (f fakeReceipts) GetReceipt(_ context.Context, id string) (*crosschain.Receipt, error) {
+	if r, ok := f[id]; ok {
+		found := *r
+		return &crosschain.Receipt{Kind: crosschain.ReceiptKindDeposit, Deposit: &found}, nil
+	}
+	return nil, crosschain.ErrNotFound
+}
+
+type fakeProtocol struct {
+	deviation string
+}
+
+func (f *fakeProtocol) ProtocolState(context.Context) (*onchain.ProtocolState, error) {
+	return &onchain.ProtocolState{PegDeviation: decimal.RequireFromString(f.deviation)}, nil
+}
+
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, alert Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func newTestService(t *testing.T, opts ...Option) *Service {
+	t.Helper()
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	t.Cleanup(func() { database.Disconnect(ctx) })
+	return NewService(database, zap.NewNop().Sugar(), opts...)
+}
+
+func TestItemCRUD(t *testing.T) {
+	ctx := context.Background()
+	receipts := fakeReceipts{
+		"dep-1": {ReceiptID: "dep-1", SuiOwner: alice},
+		"dep-2": {ReceiptID: "dep-2", SuiOwner: bob},
+	}
+	svc := newTestService(t, WithBalances(fakeBalances{}), WithReceipts(receipts), WithProtocol(&fakeProtocol{deviation: "0"}))
+
+	for _, bad := range []ItemRequest{
+		{Kind: "price"},
+		{Kind: KindBalance, Target: "not-an-address"},
+		{Kind: KindBalance, Threshold: -1},
+		{Kind: KindReceipt},
+		{Kind: KindReceipt, Target: "dep-9"},
+		{Kind: KindReceipt, Target: "dep-2"},
+		{Kind: KindPeg},
+		{Kind: KindPeg, Threshold: 1.5},
+		{Kind: KindPeg, Target: alice, Threshold: 0.01},
+	} {
+		_, err := svc.Create(ctx, alice, bad)
+		assert.ErrorIs(t, err, ErrInvalidRequest, "%+v", bad)
+	}
+
+	balance, err := svc.Create(ctx, alice, ItemRequest{Kind: KindBalance, Label: "main wallet"})
+	require.NoError(t, err)
+	assert.Equal(t, alice, balance.Target)
+	assert.True(t, balance.Active)
+	receipt, err := svc.Create(ctx, alice, ItemRequest{Kind: KindReceipt, Target: "dep-1"})
+	require.NoError(t, err)
+	_, err = svc.Create(ctx, bob, ItemRequest{Kind: KindPeg, Threshold: 0.01})
+	require.NoError(t, err)
+
+	items, err := svc.List(ctx, alice)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, balance.ID, items[0].ID)
+
+	// Items of other owners are not found
+	_, err = svc.Get(ctx, bob, receipt.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, svc.Delete(ctx, bob, receipt.ID), ErrNotFound)
+
+	inactive, label := false, "savings"
+	updated, err := svc.Update(ctx, alice, balance.ID, ItemUpdate{Active: &inactive, Label: &label})
+	require.NoError(t, err)
+	assert.False(t, updated.Active)
+	assert.Equal(t, "savings", updated.Label)
+	negative := -2.0
+	_, err = svc.Update(ctx, alice, balance.ID, ItemUpdate{Threshold: &negative})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	require.NoError(t, svc.Delete(ctx, alice, receipt.ID))
+	_, err = svc.Get(ctx, alice, receipt.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestItemLimit(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, WithProtocol(&fakeProtocol{deviation: "0"}))
+	for i := 0; i < MaxItemsPerOwner; i++ {
+		_, err := svc.Create(ctx, alice, ItemRequest{Kind: KindPeg, Threshold: 0.01})
+		require.NoError(t, err)
+	}
+	_, err := svc.Create(ctx, alice, ItemRequest{Kind: KindPeg, Threshold: 0.01})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	_, err = svc.Create(ctx, bob, ItemRequest{Kind: KindPeg, Threshold: 0.01})
+	assert.NoError(t, err)
+}
+
+func TestRunOnce(t *testing.T) {
+	ctx := context.Background()
+	balances := fakeBalances{alice: {F: decimal.NewFromInt(100), X: decimal.NewFromInt(5), R: decimal.NewFromInt(1)}}
+	receipts := fakeReceipts{"dep-1": {ReceiptID: "dep-1", SuiOwner: alice, Status: crosschain.DepositStatusPending, Stage: crosschain.StageConfirmed}}
+	protocol := &fakeProtocol{deviation: "0.02"}
+	notifier := &recordingNotifier{}
+	cache, err := store.NewCache("invalid:6379", zap.NewNop().Sugar(), nil)
+	require.NoError(t, err)
+	svc := newTestService(t,
+		WithBalances(balances), WithReceipts(receipts), WithProtocol(protocol),
+		WithNotifiers(notifier), WithLock(cache),
+	)
+
+	balance, err := svc.Create(ctx, alice, ItemRequest{Kind: KindBalance, Threshold: 10})
+	require.NoError(t, err)
+	receipt, err := svc.Create(ctx, alice, ItemRequest{Kind: KindReceipt, Target: "dep-1"})
+	require.NoError(t, err)
+	peg, err := svc.Create(ctx, alice, ItemRequest{Kind: KindPeg, Threshold: 0.01})
+	require.NoError(t, err)
+
+	// Balances and receipts are first observed silently; the peg starts
+	// beyond its threshold
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alerts, err := svc.RunOnce(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, peg.ID, alerts[0].ItemID)
+	assert.Equal(t, alice, alerts[0].Owner)
+	assert.Equal(t, "fToken is 2.00% off its peg, beyond 1%", alerts[0].Message)
+	assert.Empty(t, alerts[0].Previous)
+	assert.Equal(t, alerts, notifier.alerts)
+
+	// Nothing changed
+	alerts, err = svc.RunOnce(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	// A move within the threshold is not alerted, nor forgotten: moves
+	// add up against the last alerted balances
+	balances[alice] = &onchain.Balances{F: decimal.NewFromInt(108), X: decimal.NewFromInt(5), R: decimal.NewFromInt(1)}
+	alerts, err = svc.RunOnce(ctx, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+	balances[alice] = &onchain.Balances{F: decimal.NewFromInt(112), X: decimal.NewFromInt(5), R: decimal.NewFromInt(1)}
+	receipts["dep-1"].Status, receipts["dep-1"].Stage = crosschain.DepositStatusMinted, crosschain.StageMinted
+	protocol.deviation = "-0.004"
+	at := now.Add(3 * time.Minute)
+	alerts, err = svc.RunOnce(ctx, at)
+	require.NoError(t, err)
+	require.Len(t, alerts, 3)
+	byItem := map[string]Alert{}
+	for _, alert := range alerts {
+		byItem[alert.ItemID] = alert
+	}
+
+	var previous, current balanceState
+	require.NoError(t, json.Unmarshal(byItem[balance.ID].Previous, &previous))
+	require.NoError(t, json.Unmarshal(byItem[balance.ID].Current, &current))
+	assert.Equal(t, "100", previous.F.String())
+	assert.Equal(t, "112", current.F.String())
+	assert.Equal(t, "Receipt dep-1 moved from confirmed to minted", byItem[receipt.ID].Message)
+	assert.Equal(t, "fToken is back within 1% of its peg", byItem[peg.ID].Message)
+
+	item, err := svc.Get(ctx, alice, receipt.ID)
+	require.NoError(t, err)
+	require.NotNil(t, item.TriggeredAt)
+	assert.True(t, item.TriggeredAt.Equal(at))
+
+	// Inactive items are not evaluated
+	inactive := false
+	_, err = svc.Update(ctx, alice, peg.ID, ItemUpdate{Active: &inactive})
+	require.NoError(t, err)
+	protocol.deviation = "0.05"
+	alerts, err = svc.RunOnce(ctx, now.Add(4*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestRunOnceSkipsFailingItems(t *testing.T) {
+	ctx := context.Background()
+	balances := fakeBalances{alice: {F: decimal.NewFromInt(1)}}
+	svc := newTestService(t, WithBalances(balances), WithProtocol(&fakeProtocol{deviation: "0.5"}))
+
+	_, err := svc.Create(ctx, alice, ItemRequest{Kind: KindBalance, Target: bob})
+	require.NoError(t, err)
+	_, err = svc.Create(ctx, alice, ItemRequest{Kind: KindPeg, Threshold: 0.1})
+	require.NoError(t, err)
+
+	alerts, err := svc.RunOnce(ctx, time.Now())
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, KindPeg, alerts[0].Kind)
+}
@@ -0,0 +1,417 @@
+// Package webhooks posts protocol and bridge events to the endpoints
+// integrators subscribe, so that they need not hold a WebSocket open.
+// Deliveries are signed with the secret of their subscription, retried
+// with exponential backoff and kept as dead letters once their attempts
+// are used up.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	gdb "github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"go.uber.org/zap"
+)
+
+// Types of events. Protocol events are named after the indexed event, e.g.
+// protocol.mint, and bridge events after the receipt kind and the stage it
+// reached, e.g. bridge.deposit.minted.
+const (
+	EventProtocolAlert = "protocol.alert"
+	eventProtocol      = "protocol."
+	eventBridge        = "bridge."
+)
+
+// Statuses of deliveries
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+
+	// StatusDead marks a delivery that used up its attempts, or whose
+	// subscription is gone; it waits for an operator to redeliver it
+	StatusDead = "dead"
+)
+
+// secretPrefix starts the secrets the service issues
+const secretPrefix = "whsec_"
+
+var (
+	// ErrNotFound is returned for unknown subscription and delivery IDs
+	ErrNotFound = errors.New("webhook not found")
+
+	// ErrInvalidRequest is returned for subscriptions without an http(s)
+	// URL or with a malformed event pattern
+	ErrInvalidRequest = errors.New("invalid webhook request")
+)
+
+// eventPattern matches event types, optionally ending in a .* wildcard
+var eventPattern = regexp.MustCompile(`^[a-z_]+(\.[a-z_]+)*(\.\*)?$`)
+
+// Event is the body of a delivery
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Subscription is an endpoint events are posted to, without its secret.
+// Events lists the event types it receives, where "protocol.*" matches
+// every protocol event; an empty list matches every event.
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Matches reports whether the subscription receives events of eventType
+func (s *Subscription) Matches(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, pattern := range s.Events {
+		if pattern == "*" || pattern == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is an event posted, or to be posted, to a subscription
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscriptionId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"responseStatus,omitempty"` // Of the last attempt
+	LastError      string          `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+// Service manages subscriptions and delivers events to them
+type Service struct {
+	subscriptions *gdb.TypedRepository[entities.WebhookSubscription]
+	deliveries    *gdb.TypedRepository[entities.WebhookDelivery]
+	policy        Policy
+	client        Doer
+	cache         *store.Cache // Nil delivers from every replica
+	id            string       // Owner of the delivery lock
+	logger        *zap.SugaredLogger
+
+	wake chan struct{} // Signals new deliveries to the worker
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithPolicy sets how deliveries are attempted and retried
+func WithPolicy(p Policy) Option {
+	return func(s *Service) {
+		s.policy = p
+	}
+}
+
+// WithClient posts deliveries through client rather than an *http.Client
+// with the policy's timeout
+func WithClient(client Doer) Option {
+	return func(s *Service) {
+		s.client = client
+	}
+}
+
+// WithLock elects the one replica delivering through a lock of cache
+func WithLock(cache *store.Cache) Option {
+	return func(s *Service) {
+		s.cache = cache
+	}
+}
+
+func NewService(database interfaces.Database, logger *zap.SugaredLogger, opts ...Option) *Service {
+	s := &Service{
+		subscriptions: gdb.MustNewTypedRepository[entities.WebhookSubscription](database, entities.WebhookSubscriptionSchema),
+		deliveries:    gdb.MustNewTypedRepository[entities.WebhookDelivery](database, entities.WebhookDeliverySchema),
+		id:            randomHex(8),
+		logger:        logger,
+		wake:          make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.policy = s.policy.withDefaults()
+	if s.client == nil {
+		s.client = newHTTPClient(s.policy.Timeout)
+	}
+	return s
+}
+
+// Subscribe registers endpoint for the events matching patterns and returns
+// the subscription with its signing secret, which is not shown again. An
+// empty secret has one issued.
+func (s *Service) Subscribe(ctx context.Context, endpoint, secret string, patterns []string) (*Subscription, string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, "", fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidRequest)
+	}
+	for _, pattern := range patterns {
+		if pattern != "*" && !eventPattern.MatchString(pattern) {
+			return nil, "", fmt.Errorf("%w: malformed event pattern %q", ErrInvalidRequest, pattern)
+		}
+	}
+	if secret == "" {
+		secret = secretPrefix + randomHex(24)
+	}
+
+	entity, err := s.subscriptions.Create(ctx, &entities.WebhookSubscription{
+		ID:     randomHex(8),
+		URL:    endpoint,
+		Secret: secret,
+		Events: strings.Join(patterns, " "),
+		Active: true,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("create webhook subscription: %w", err)
+	}
+	return subscriptionFromEntity(entity), secret, nil
+}
+
+// Unsubscribe deletes a subscription along with its deliveries
+func (s *Service) Unsubscribe(ctx context.Context, id string) error {
+	if _, err := s.getSubscription(ctx, id); err != nil {
+		return err
+	}
+	deliveries, err := s.Deliveries(ctx, id, "", 0)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		if err := s.deliveries.Delete(ctx, interfaces.StringID(delivery.ID)); err != nil && !errors.Is(err, interfaces.ErrNotFound) {
+			return fmt.Errorf("delete webhook delivery: %w", err)
+		}
+	}
+	if err := s.subscriptions.Delete(ctx, interfaces.StringID(id)); err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// Subscription returns the subscription with id
+func (s *Service) Subscription(ctx context.Context, id string) (*Subscription, error) {
+	entity, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return subscriptionFromEntity(entity), nil
+}
+
+// Subscriptions returns every subscription, oldest first
+func (s *Service) Subscriptions(ctx context.Context) ([]*Subscription, error) {
+	page, err := s.subscriptions.FindMany(ctx, &interfaces.Query{
+		OrderBy: []interfaces.OrderBy{{Field: "created_at", Direction: "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list webhook subscriptions: %w", err)
+	}
+	subscriptions := make([]*Subscription, 0, len(page.Data))
+	for i := range page.Data {
+		subscriptions = append(subscriptions, subscriptionFromEntity(&page.Data[i]))
+	}
+	return subscriptions, nil
+}
+
+// Publish queues event for every active subscription it matches. Events
+// are identified by their source, so the same event published by several
+// replicas is delivered once. An empty ID has one generated.
+func (s *Service) Publish(ctx context.Context, eventType, eventID string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal %s event: %w", eventType, err)
+	}
+	if eventID == "" {
+		eventID = randomHex(12)
+	}
+	body, err := json.Marshal(Event{ID: eventID, Type: eventType, CreatedAt: time.Now().UTC(), Data: payload})
+	if err != nil {
+		return fmt.Errorf("marshal %s event: %w", eventType, err)
+	}
+
+	subscriptions, err := s.Subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	queued := 0
+	for _, sub := range subscriptions {
+		if !sub.Active || !sub.Matches(eventType) {
+			continue
+		}
+		_, err := s.deliveries.Create(ctx, &entities.WebhookDelivery{
+			ID:             deliveryID(sub.ID, eventID),
+			SubscriptionID: sub.ID,
+			EventID:        eventID,
+			EventType:      eventType,
+			Payload:        string(body),
+			Status:         StatusPending,
+			NextAttemptAt:  now,
+		})
+		if errors.Is(err, interfaces.ErrUniqueConstraint) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("queue %s delivery: %w", eventType, err)
+		}
+		queued++
+	}
+	if queued > 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Delivery returns the delivery with id
+func (s *Service) Delivery(ctx context.Context, id string) (*Delivery, error) {
+	entity, err := s.getDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return deliveryFromEntity(entity), nil
+}
+
+// Deliveries returns up to limit deliveries of a subscription, newest
+// first, optionally only those with status
+func (s *Service) Deliveries(ctx context.Context, subscriptionID, status string, limit int) ([]*Delivery, error) {
+	q := &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "subscription_id", Value: subscriptionID},
+		}},
+		OrderBy: []interfaces.OrderBy{{Field: "created_at", Direction: "desc"}},
+	}
+	if status != "" {
+		q.Where.Conditions = append(q.Where.Conditions, interfaces.Filter{Field: "status", Value: status})
+	}
+	if limit > 0 {
+		q.Limit = &limit
+	}
+	return s.findDeliveries(ctx, q)
+}
+
+// Redeliver queues a delivery to be attempted again at once, with its
+// attempts reset, whatever its status
+func (s *Service) Redeliver(ctx context.Context, id string) (*Delivery, error) {
+	entity, err := s.getDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	entity.Status = StatusPending
+	entity.Attempts = 0
+	entity.LastError = ""
+	entity.NextAttemptAt = time.Now()
+	entity, err = s.deliveries.Update(ctx, entity)
+	if err != nil {
+		return nil, fmt.Errorf("redeliver webhook: %w", err)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return deliveryFromEntity(entity), nil
+}
+
+func (s *Service) getSubscription(ctx context.Context, id string) (*entities.WebhookSubscription, error) {
+	entity, err := s.subscriptions.GetByID(ctx, interfaces.StringID(id))
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, fmt.Errorf("%w: subscription %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get webhook subscription: %w", err)
+	}
+	return entity, nil
+}
+
+func (s *Service) getDelivery(ctx context.Context, id string) (*entities.WebhookDelivery, error) {
+	entity, err := s.deliveries.GetByID(ctx, interfaces.StringID(id))
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, fmt.Errorf("%w: delivery %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get webhook delivery: %w", err)
+	}
+	return entity, nil
+}
+
+func (s *Service) findDeliveries(ctx context.Context, q *interfaces.Query) ([]*Delivery, error) {
+	page, err := s.deliveries.FindMany(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("find webhook deliveries: %w", err)
+	}
+	deliveries := make([]*Delivery, 0, len(page.Data))
+	for i := range page.Data {
+		deliveries = append(deliveries, deliveryFromEntity(&page.Data[i]))
+	}
+	return deliveries, nil
+}
+
+// deliveryID derives the ID of the delivery of an event to a subscription
+func deliveryID(subscriptionID, eventID string) string {
+	sum := sha256.Sum256([]byte(subscriptionID + "\x00" + eventID))
+	return hex.EncodeToString(sum[:12])
+}
+
+func subscriptionFromEntity(e *entities.WebhookSubscription) *Subscription {
+	return &Subscription{
+		ID:        e.ID,
+		URL:       e.URL,
+		Events:    strings.Fields(e.Events),
+		Active:    e.Active,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
+
+func deliveryFromEntity(e *entities.WebhookDelivery) *Delivery {
+	return &Delivery{
+		ID:             e.ID,
+		SubscriptionID: e.SubscriptionID,
+		EventID:        e.EventID,
+		EventType:      e.EventType,
+		Payload:        json.RawMessage(e.Payload),
+		Status:         e.Status,
+		Attempts:       int(e.Attempts),
+		ResponseStatus: int(e.ResponseStatus),
+		LastError:      e.LastError,
+		NextAttemptAt:  e.NextAttemptAt,
+		DeliveredAt:    e.DeliveredAt,
+		CreatedAt:      e.CreatedAt,
+	}
+}
+
+func randomHex(size int) string {
+	bytes := make([]byte, size)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
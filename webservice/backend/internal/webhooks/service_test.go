@@ -0,0 +1,174 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db"
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestService(t *testing.T, opts ...Option) *Service {
+	t.Helper()
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, db.ConnectAndMigrate(ctx, database, db.AllSchemas()))
+	t.Cleanup(func() { database.Disconnect(ctx) })
+	return NewService(database, zap.NewNop().Sugar(), opts...)
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	for _, bad := range []string{"", "ftp://example.com", "/hook", "http://"} {
+		_, _, err := svc.Subscribe(ctx, bad, "", nil)
+		assert.ErrorIs(t, err, ErrInvalidRequest, bad)
+	}
+	_, _, err := svc.Subscribe(ctx, "https://example.com/hook", "", []string{"Protocol.Mint"})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	sub, secret, err := svc.Subscribe(ctx, "https://example.com/hook", "", []string{"protocol.*", "bridge.deposit.minted"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, secretPrefix))
+	assert.True(t, sub.Active)
+	assert.True(t, sub.Matches("protocol.mint"))
+	assert.True(t, sub.Matches("bridge.deposit.minted"))
+	assert.False(t, sub.Matches("bridge.redeem.paid"))
+	assert.True(t, (&Subscription{}).Matches("bridge.redeem.paid"))
+
+	got, err := svc.Subscription(ctx, sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sub.Events, got.Events)
+
+	require.NoError(t, svc.Unsubscribe(ctx, sub.ID))
+	_, err = svc.Subscription(ctx, sub.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, svc.Unsubscribe(ctx, sub.ID), ErrNotFound)
+}
+
+func TestDeliver(t *testing.T) {
+	ctx := context.Background()
+	var received atomic.Int32
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil || r.Header.Get(HeaderSignature) != Signature("s3cret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var event Event
+		if json.Unmarshal(body, &event) != nil || event.Type != r.Header.Get(HeaderEvent) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	svc := newTestService(t, WithPolicy(Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	sub, _, err := svc.Subscribe(ctx, server.URL, "s3cret", []string{"protocol.*"})
+	require.NoError(t, err)
+
+	// The same event published twice, e.g. by two replicas, is queued once
+	require.NoError(t, svc.Publish(ctx, "protocol.mint", "events:1", map[string]string{"amount": "10"}))
+	require.NoError(t, svc.Publish(ctx, "protocol.mint", "events:1", map[string]string{"amount": "10"}))
+	require.NoError(t, svc.Publish(ctx, "bridge.deposit.minted", "receipt_transitions:1", nil))
+	require.NoError(t, svc.DeliverDue(ctx))
+	assert.Equal(t, int32(1), received.Load())
+
+	delivered, err := svc.Deliveries(ctx, sub.ID, StatusDelivered, 0)
+	require.NoError(t, err)
+	require.Len(t, delivered, 1)
+	assert.Equal(t, 1, delivered[0].Attempts)
+	assert.Equal(t, http.StatusOK, delivered[0].ResponseStatus)
+	assert.NotNil(t, delivered[0].DeliveredAt)
+
+	// Failures are retried after a backoff, then dead-lettered
+	failing.Store(true)
+	require.NoError(t, svc.Publish(ctx, "protocol.redeem", "events:2", nil))
+	require.NoError(t, svc.DeliverDue(ctx))
+	pending, err := svc.Deliveries(ctx, sub.ID, StatusPending, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, http.StatusBadGateway, pending[0].ResponseStatus)
+	assert.Equal(t, "post: status 502", pending[0].LastError)
+
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, svc.DeliverDue(ctx))
+	dead, err := svc.Delivery(ctx, pending[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDead, dead.Status)
+	assert.Equal(t, 2, dead.Attempts)
+
+	// Dead letters are delivered once redelivered
+	failing.Store(false)
+	redelivered, err := svc.Redeliver(ctx, dead.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, redelivered.Status)
+	assert.Equal(t, 0, redelivered.Attempts)
+	require.NoError(t, svc.DeliverDue(ctx))
+	got, err := svc.Delivery(ctx, dead.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDelivered, got.Status)
+	assert.Equal(t, int32(2), received.Load())
+
+	_, err = svc.Redeliver(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Removing a subscription removes its deliveries
+	require.NoError(t, svc.Unsubscribe(ctx, sub.ID))
+	_, err = svc.Delivery(ctx, dead.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestForwardChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := newTestService(t)
+	sub, _, err := svc.Subscribe(ctx, "https://example.com/hook", "", nil)
+	require.NoError(t, err)
+
+	changes := make(chan interfaces.ChangeEvent, 3)
+	changes <- interfaces.ChangeEvent{Table: entities.EventSchema.TableName, Op: interfaces.ChangeCreate, ID: "7",
+		Record: map[string]interface{}{"type": "MINT"}}
+	changes <- interfaces.ChangeEvent{Table: entities.EventSchema.TableName, Op: interfaces.ChangeUpdate, ID: "7"}
+	changes <- interfaces.ChangeEvent{Table: entities.ReceiptTransitionSchema.TableName, Op: interfaces.ChangeCreate, ID: "9",
+		Record: map[string]interface{}{"kind": "deposit", "to_stage": "minted"}}
+	close(changes)
+	svc.ForwardChanges(ctx, changes)
+
+	deliveries, err := svc.Deliveries(ctx, sub.ID, "", 0)
+	require.NoError(t, err)
+	types := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		types = append(types, d.EventType)
+	}
+	assert.ElementsMatch(t, []string{"protocol.mint", "bridge.deposit.minted"}, types)
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}.withDefaults()
+	assert.Equal(t, time.Second, p.delay(1))
+	assert.Equal(t, 2*time.Second, p.delay(2))
+	assert.Equal(t, 4*time.Second, p.delay(3))
+	assert.Equal(t, 5*time.Second, p.delay(4))
+}
@@ -0,0 +1,284 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/db/entities"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/onchain"
+)
+
+// Headers of deliveries. The signature is the hex HMAC-SHA256, keyed with
+// the subscription secret, of the timestamp, a dot and the body, so that
+// receivers can reject replays of old deliveries.
+const (
+	HeaderEvent     = "X-Leafsii-Event"
+	HeaderDelivery  = "X-Leafsii-Delivery"
+	HeaderTimestamp = "X-Leafsii-Timestamp"
+	HeaderSignature = "X-Leafsii-Signature"
+)
+
+// deliveryLockKey elects the one replica delivering webhooks
+const deliveryLockKey = "fx:lock:webhooks"
+
+// Doer posts deliveries, as *http.Client does
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Policy configures how deliveries are attempted and retried
+type Policy struct {
+	// MaxAttempts is how many failed attempts make a delivery a dead
+	// letter. Default: 8.
+	MaxAttempts int
+
+	// BaseDelay is the wait after the first failure, doubled after each
+	// further one up to MaxDelay. Defaults: 10s and 1h.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Timeout bounds each attempt. Default: 10s.
+	Timeout time.Duration
+
+	// PollInterval is the time between checks for due deliveries, which
+	// new events also trigger. Default: 2s.
+	PollInterval time.Duration
+
+	// BatchSize caps the deliveries attempted per poll. Default: 20.
+	BatchSize int
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 8
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 10 * time.Second
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = time.Hour
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = 10 * time.Second
+	}
+	if p.PollInterval <= 0 {
+		p.PollInterval = 2 * time.Second
+	}
+	if p.BatchSize <= 0 {
+		p.BatchSize = 20
+	}
+	return p
+}
+
+// delay returns the wait before the attempt following the given number of
+// failed attempts
+func (p Policy) delay(attempts int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempts && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		// Redirects would post the event to an endpoint nobody subscribed
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// Signature returns the value of HeaderSignature for a delivery of body at
+// timestamp, in Unix seconds
+func Signature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start spins up the delivery loop; call once during application startup
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.policy.PollInterval)
+		defer ticker.Stop()
+		defer func() {
+			if s.cache != nil {
+				// Let another replica take over at once
+				if err := s.cache.ReleaseLock(context.Background(), deliveryLockKey, s.id); err != nil {
+					s.logger.Warnw("Failed to release webhook delivery lock", "error", err)
+				}
+			}
+		}()
+		for {
+			if err := s.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Errorw("Webhook delivery failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// DeliverDue attempts the deliveries due by now, a batch at a time
+func (s *Service) DeliverDue(ctx context.Context) error {
+	if s.cache != nil {
+		// The lock outlives a few missed polls before another replica
+		// takes over
+		held, err := s.cache.AcquireLock(ctx, deliveryLockKey, s.id, 3*s.policy.PollInterval+s.policy.Timeout)
+		if err != nil || !held {
+			return err
+		}
+	}
+
+	limit := s.policy.BatchSize
+	due, err := s.deliveries.FindMany(ctx, &interfaces.Query{
+		Where: &interfaces.Filters{Conditions: []interfaces.Filter{
+			{Field: "status", Value: StatusPending},
+			{Field: "next_attempt_at", Operator: &interfaces.FilterOperator{Lte: time.Now()}},
+		}},
+		OrderBy: []interfaces.OrderBy{{Field: "next_attempt_at", Direction: "asc"}},
+		Limit:   &limit,
+	})
+	if err != nil {
+		return fmt.Errorf("find due webhook deliveries: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range due.Data {
+		wg.Add(1)
+		go func(delivery *entities.WebhookDelivery) {
+			defer wg.Done()
+			s.deliver(ctx, delivery)
+		}(&due.Data[i])
+	}
+	wg.Wait()
+	return nil
+}
+
+// deliver makes one attempt of delivery and records its outcome
+func (s *Service) deliver(ctx context.Context, delivery *entities.WebhookDelivery) {
+	now := time.Now()
+	sub, err := s.getSubscription(ctx, delivery.SubscriptionID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		delivery.Status = StatusDead
+		delivery.LastError = "subscription removed"
+	case err != nil:
+		s.logger.Warnw("Failed to load webhook subscription", "delivery", delivery.ID, "error", err)
+		return
+	case !sub.Active:
+		delivery.Status = StatusDead
+		delivery.LastError = "subscription inactive"
+	default:
+		status, err := s.post(ctx, sub, delivery, now)
+		delivery.Attempts++
+		delivery.ResponseStatus = int64(status)
+		if err == nil {
+			delivery.Status = StatusDelivered
+			delivery.LastError = ""
+			delivery.DeliveredAt = &now
+			break
+		}
+		delivery.LastError = err.Error()
+		if int(delivery.Attempts) >= s.policy.MaxAttempts {
+			delivery.Status = StatusDead
+			s.logger.Warnw("Webhook delivery dead-lettered", "delivery", delivery.ID, "subscription", sub.ID, "event", delivery.EventType, "attempts", delivery.Attempts, "error", err)
+		} else {
+			delivery.NextAttemptAt = now.Add(s.policy.delay(int(delivery.Attempts)))
+		}
+	}
+
+	if _, err := s.deliveries.Update(context.WithoutCancel(ctx), delivery); err != nil {
+		s.logger.Errorw("Failed to record webhook delivery", "delivery", delivery.ID, "error", err)
+	}
+}
+
+// post sends delivery to the endpoint of sub, returning the response status
+// and an error unless it is 2xx
+func (s *Service) post(ctx context.Context, sub *entities.WebhookSubscription, delivery *entities.WebhookDelivery, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.policy.Timeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "leafsii-webhooks")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Signature(sub.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("post: status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Alert publishes a protocol alert, so that the service may be one of the
+// alerters of the protocol monitor
+func (s *Service) Alert(ctx context.Context, alert onchain.ProtocolAlert) error {
+	return s.Publish(ctx, EventProtocolAlert, fmt.Sprintf("alert:%s:%d", alert.Type, alert.At.Unix()), alert)
+}
+
+// ForwardChanges publishes the protocol events and receipt transitions
+// committed to the database, as reported by a Watch of the events or
+// receipt_transitions table, until ctx is done or events closes
+func (s *Service) ForwardChanges(ctx context.Context, events <-chan interfaces.ChangeEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				s.logger.Warnw("Database change feed closed; webhooks stop receiving its events")
+				return
+			}
+			if event.Op != interfaces.ChangeCreate {
+				continue
+			}
+
+			var eventType string
+			switch event.Table {
+			case entities.EventSchema.TableName:
+				eventType = eventProtocol + strings.ToLower(fmt.Sprint(event.Record["type"]))
+			case entities.ReceiptTransitionSchema.TableName:
+				eventType = fmt.Sprintf("%s%s.%s", eventBridge, event.Record["kind"], event.Record["to_stage"])
+			default:
+				continue
+			}
+			if err := s.Publish(ctx, eventType, event.Table+":"+event.ID, event.Record); err != nil {
+				s.logger.Errorw("Failed to publish webhook event", "type", eventType, "id", event.ID, "error", err)
+			}
+		}
+	}
+}
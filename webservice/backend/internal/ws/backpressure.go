@@ -0,0 +1,114 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SlowClientPolicy is what the hub does with an update for a client whose
+// send queue is full
+type SlowClientPolicy string
+
+const (
+	// SlowClientDisconnect closes the connection with CloseBackpressure
+	SlowClientDisconnect SlowClientPolicy = "disconnect"
+	// SlowClientDropOldest drops the oldest queued update to make room
+	SlowClientDropOldest SlowClientPolicy = "drop_oldest"
+)
+
+// CloseBackpressure is the close code of connections dropped for falling
+// behind their updates. Clients should reconnect and subscribe again.
+const CloseBackpressure = 4008
+
+const (
+	defaultSendBuffer   = 256
+	defaultWriteTimeout = 10 * time.Second
+)
+
+// WithSendBuffer bounds the updates queued for each client to size, and
+// sets what happens to the updates of clients whose queue is full
+func WithSendBuffer(size int, policy SlowClientPolicy) HubOption {
+	return func(h *Hub) {
+		if size > 0 {
+			h.sendBuffer = size
+		}
+		if policy != "" {
+			h.slowClientPolicy = policy
+		}
+	}
+}
+
+// WithWriteTimeout sets how long a write to a client may take before the
+// client is disconnected
+func WithWriteTimeout(timeout time.Duration) HubOption {
+	return func(h *Hub) {
+		if timeout > 0 {
+			h.writeTimeout = timeout
+		}
+	}
+}
+
+// enqueue queues message for c, applying the slow client policy when its
+// queue is full. h.mu must be held for writing.
+func (h *Hub) enqueue(ctx context.Context, c *Client, message []byte) {
+	select {
+	case c.send <- message:
+		h.metrics.RecordWSQueueDepth(ctx, len(c.send))
+		return
+	default:
+	}
+
+	if h.slowClientPolicy == SlowClientDropOldest {
+		// The write pump may take the oldest first, leaving room all the same
+		select {
+		case <-c.send:
+		default:
+		}
+		h.metrics.RecordWSDroppedUpdate(ctx)
+		select {
+		case c.send <- message:
+			h.metrics.RecordWSQueueDepth(ctx, len(c.send))
+		default:
+		}
+		return
+	}
+
+	h.logger.Infow("Disconnecting slow WebSocket client", "address", c.userAddress(), "queued", len(c.send))
+	h.metrics.RecordWSSlowDisconnect(ctx, "backpressure")
+	h.drop(c, CloseBackpressure, "send queue full")
+}
+
+// drop removes c; its write pump then closes the connection with code, or
+// without one if code is 0. h.mu must be held for writing.
+func (h *Hub) drop(c *Client, code int, reason string) {
+	delete(h.clients, c)
+	h.release(c.ip)
+	c.mu.Lock()
+	c.dropped = true
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	c.mu.Unlock()
+	h.recordSubscriptions(topics, -1)
+	c.closeCode, c.closeReason = code, reason
+	close(c.send)
+}
+
+// closeMessage returns the payload of the close frame sent to c
+func (c *Client) closeMessage() []byte {
+	if c.closeCode == 0 {
+		return []byte{}
+	}
+	return websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+}
+
+// isTimeout reports whether err is a write deadline expiring
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,251 @@
+package ws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/ws/streampb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encoding is the encoding of the frames a connection gets. Clients choose
+// it when connecting, with a WebSocket subprotocol or ?encoding=; their own
+// frames are JSON whatever it is.
+type Encoding string
+
+const (
+	EncodingJSON     Encoding = "json"
+	EncodingProtobuf Encoding = "protobuf" // Frames of streampb/stream.proto
+	EncodingMsgpack  Encoding = "msgpack"  // The JSON frames, as MessagePack
+)
+
+// Subprotocols selecting the encoding of WebSocket connections, in order
+// of preference
+var subprotocols = []string{"leafsii.protobuf", "leafsii.msgpack", "leafsii.json"}
+
+// parseEncoding reads an encoding, JSON when s is empty
+func parseEncoding(s string) (Encoding, error) {
+	switch Encoding(strings.ToLower(s)) {
+	case "", EncodingJSON:
+		return EncodingJSON, nil
+	case EncodingProtobuf:
+		return EncodingProtobuf, nil
+	case EncodingMsgpack:
+		return EncodingMsgpack, nil
+	}
+	return "", fmt.Errorf("unknown encoding %q, want json, protobuf or msgpack", s)
+}
+
+// encodingOfRequest returns the encoding of the ?encoding= parameter of r
+func encodingOfRequest(r *http.Request) (Encoding, error) {
+	return parseEncoding(r.URL.Query().Get("encoding"))
+}
+
+// encodingOfSubprotocol returns the encoding of a negotiated subprotocol
+func encodingOfSubprotocol(subprotocol string) (Encoding, bool) {
+	name, ok := strings.CutPrefix(subprotocol, "leafsii.")
+	if !ok {
+		return "", false
+	}
+	encoding, err := parseEncoding(name)
+	return encoding, err == nil
+}
+
+// binary reports whether frames of e go in binary WebSocket messages
+func (e Encoding) binary() bool {
+	return e == EncodingProtobuf || e == EncodingMsgpack
+}
+
+// encodeFrame encodes a Message, AckFrame or ErrorFrame in e
+func encodeFrame(e Encoding, frame interface{}) ([]byte, error) {
+	if e == EncodingProtobuf {
+		return marshalProtoFrame(frame)
+	}
+	data, err := json.Marshal(frame)
+	if err != nil || e != EncodingMsgpack {
+		return data, err
+	}
+	return jsonToMsgpack(data)
+}
+
+// encodeStreamEvent encodes the data of an SSE event in e: its JSON data,
+// the Update of stream.proto or the MessagePack of its data. Events are
+// text, so binary encodings are base64 encoded.
+func encodeStreamEvent(e Encoding, event StreamEvent) ([]byte, error) {
+	var data []byte
+	switch e {
+	case EncodingProtobuf:
+		var err error
+		if data, err = proto.Marshal(protoUpdate(Message{Type: "update", Topic: event.Topic, Data: event.Data, Timestamp: event.At / 1000})); err != nil {
+			return nil, err
+		}
+	case EncodingMsgpack:
+		var err error
+		if data, err = jsonToMsgpack(event.Data); err != nil {
+			return nil, err
+		}
+	default:
+		return event.Data, nil
+	}
+	return base64.StdEncoding.AppendEncode(nil, data), nil
+}
+
+// marshalProtoFrame encodes frame as the Frame message of stream.proto
+func marshalProtoFrame(frame interface{}) ([]byte, error) {
+	var pb streampb.Frame
+	switch frame := frame.(type) {
+	case Message:
+		pb.Frame = &streampb.Frame_Update{Update: protoUpdate(frame)}
+	case AckFrame:
+		pb.Frame = &streampb.Frame_Ack{Ack: &streampb.Ack{
+			Id:        frame.ID,
+			Op:        frame.Op,
+			Topics:    frame.Topics,
+			Timestamp: frame.Timestamp,
+		}}
+	case ErrorFrame:
+		pb.Frame = &streampb.Frame_Error{Error: &streampb.Error{
+			Id:        frame.ID,
+			Code:      frame.Code,
+			Message:   frame.Message,
+			Topic:     frame.Topic,
+			Timestamp: frame.Timestamp,
+		}}
+	default:
+		return nil, fmt.Errorf("no protobuf schema for %T", frame)
+	}
+	return proto.Marshal(&pb)
+}
+
+// protoUpdate converts an update with the schema of its topic, or as JSON
+// when it has none or its data does not fit it
+func protoUpdate(msg Message) *streampb.Update {
+	update := &streampb.Update{
+		Topic:     msg.Topic,
+		Timestamp: msg.Timestamp,
+		Replay:    msg.Type == FrameReplay,
+	}
+
+	kind, _, _ := strings.Cut(msg.Topic, ":")
+	var err error
+	switch {
+	case kind == "prices":
+		var tick *streampb.PriceTick
+		if tick, err = protoTick(msg.Data); err == nil {
+			update.Data = &streampb.Update_Tick{Tick: tick}
+		}
+	case msg.Topic == TopicProtocolState:
+		var state *streampb.ProtocolState
+		if state, err = protoProtocolState(msg.Data); err == nil {
+			update.Data = &streampb.Update_ProtocolState{ProtocolState: state}
+		}
+	case kind == "bridge":
+		var receipt *streampb.ReceiptChange
+		if receipt, err = protoReceipt(msg.Data); err == nil {
+			update.Data = &streampb.Update_Receipt{Receipt: receipt}
+		}
+	default:
+		err = errNoSchema
+	}
+	if err != nil {
+		update.Data = &streampb.Update_Json{Json: msg.Data}
+	}
+	return update
+}
+
+// errNoSchema is returned for the updates of topics without a schema
+var errNoSchema = errors.New("no schema")
+
+func protoTick(data json.RawMessage) (*streampb.PriceTick, error) {
+	var tick struct {
+		Symbol string  `json:"symbol"`
+		Price  float64 `json:"price"`
+		TsMs   int64   `json:"ts"`
+		Source string  `json:"source"`
+	}
+	if err := json.Unmarshal(data, &tick); err != nil {
+		return nil, err
+	}
+	return &streampb.PriceTick{
+		Symbol: tick.Symbol,
+		Price:  tick.Price,
+		TsMs:   tick.TsMs,
+		Source: tick.Source,
+	}, nil
+}
+
+func protoProtocolState(data json.RawMessage) (*streampb.ProtocolState, error) {
+	var state struct {
+		CR           json.Number `json:"cr"`
+		CRTarget     json.Number `json:"cr_target"`
+		ReservesR    json.Number `json:"reserves_r"`
+		SupplyF      json.Number `json:"supply_f"`
+		SupplyX      json.Number `json:"supply_x"`
+		Pf           uint64      `json:"pf"`
+		Px           uint64      `json:"px"`
+		P            uint64      `json:"p"`
+		PegDeviation json.Number `json:"peg_deviation"`
+		Mode         string      `json:"mode"`
+		OracleAgeSec int64       `json:"oracle_age_sec"`
+		AsOf         time.Time   `json:"as_of"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	pb := &streampb.ProtocolState{
+		Cr:           state.CR.String(),
+		CrTarget:     state.CRTarget.String(),
+		ReservesR:    state.ReservesR.String(),
+		SupplyF:      state.SupplyF.String(),
+		SupplyX:      state.SupplyX.String(),
+		Pf:           state.Pf,
+		Px:           state.Px,
+		P:            state.P,
+		PegDeviation: state.PegDeviation.String(),
+		Mode:         state.Mode,
+		OracleAgeSec: state.OracleAgeSec,
+	}
+	if !state.AsOf.IsZero() {
+		pb.AsOfMs = state.AsOf.UnixMilli()
+	}
+	return pb, nil
+}
+
+func protoReceipt(data json.RawMessage) (*streampb.ReceiptChange, error) {
+	var change struct {
+		Table  string                 `json:"table"`
+		Op     string                 `json:"op"`
+		ID     string                 `json:"id"`
+		Record map[string]interface{} `json:"record"`
+	}
+	if err := json.Unmarshal(data, &change); err != nil {
+		return nil, err
+	}
+	text := func(key string) string {
+		s, _ := change.Record[key].(string)
+		return s
+	}
+	record, err := json.Marshal(change.Record)
+	if err != nil {
+		return nil, err
+	}
+	return &streampb.ReceiptChange{
+		Table:     change.Table,
+		Op:        change.Op,
+		Id:        change.ID,
+		ReceiptId: text("receipt_id"),
+		Status:    text("status"),
+		Stage:     text("stage"),
+		SuiOwner:  text("sui_owner"),
+		ChainId:   text("chain_id"),
+		Asset:     text("asset"),
+		Amount:    text("amount"),
+		TxHash:    text("tx_hash"),
+		Record:    record,
+	}, nil
+}
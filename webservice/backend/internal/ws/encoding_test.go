@@ -0,0 +1,182 @@
+package ws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/metrics"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/leafsii/leafsii-backend/internal/ws/streampb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// decodeFrame decodes a Frame of stream.proto
+func decodeFrame(t *testing.T, data []byte) *streampb.Frame {
+	var frame streampb.Frame
+	require.NoError(t, proto.Unmarshal(data, &frame))
+	return &frame
+}
+
+// decodeMsgpack decodes MessagePack data into generic values
+func decodeMsgpack(t *testing.T, data []byte) interface{} {
+	var value interface{}
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	require.NoError(t, codec.NewDecoderBytes(data, h).Decode(&value))
+	return value
+}
+
+func TestEncodeFrame(t *testing.T) {
+	tick := Message{Type: "update", Topic: PriceTopic("SUIUSDT"), Data: json.RawMessage(`{"symbol":"SUIUSDT","price":1.25,"ts":1700000000000,"source":"binance"}`), Timestamp: 1700000000}
+
+	data, err := encodeFrame(EncodingJSON, tick)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"update","topic":"prices:SUIUSDT","data":{"symbol":"SUIUSDT","price":1.25,"ts":1700000000000,"source":"binance"},"timestamp":1700000000}`, string(data))
+
+	data, err = encodeFrame(EncodingProtobuf, tick)
+	require.NoError(t, err)
+	update := decodeFrame(t, data).GetUpdate()
+	assert.Equal(t, "prices:SUIUSDT", update.GetTopic())
+	assert.Equal(t, int64(1700000000), update.GetTimestamp())
+	assert.False(t, update.GetReplay())
+	assert.True(t, proto.Equal(&streampb.PriceTick{Symbol: "SUIUSDT", Price: 1.25, TsMs: 1700000000000, Source: "binance"}, update.GetTick()))
+	assert.Less(t, len(data), len(`{"type":"update","topic":"prices:SUIUSDT","data":{"symbol":"SUIUSDT","price":1.25,"ts":1700000000000,"source":"binance"},"timestamp":1700000000}`)/2)
+
+	state := Message{Type: FrameReplay, Topic: TopicProtocolState, Data: json.RawMessage(`{"cr":"1.306","mode":"normal","pf":1000000,"as_of":"2024-01-01T00:00:00Z"}`)}
+	data, err = encodeFrame(EncodingProtobuf, state)
+	require.NoError(t, err)
+	update = decodeFrame(t, data).GetUpdate()
+	assert.True(t, update.GetReplay())
+	assert.True(t, proto.Equal(&streampb.ProtocolState{
+		Cr:     "1.306",
+		Pf:     1000000,
+		Mode:   "normal",
+		AsOfMs: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(),
+	}, update.GetProtocolState()), "%v", update.GetProtocolState())
+
+	receipt := Message{Type: "update", Topic: BridgeTopic("bridge_7"), Data: json.RawMessage(`{"table":"bridge_receipts","op":"update","id":"bridge_7","record":{"stage":"minted","sui_owner":"0xa11"}}`)}
+	data, err = encodeFrame(EncodingProtobuf, receipt)
+	require.NoError(t, err)
+	change := decodeFrame(t, data).GetUpdate().GetReceipt()
+	assert.Equal(t, "bridge_receipts", change.GetTable())
+	assert.Equal(t, "bridge_7", change.GetId())
+	assert.Equal(t, "minted", change.GetStage())
+	assert.Equal(t, "0xa11", change.GetSuiOwner())
+	assert.JSONEq(t, `{"stage":"minted","sui_owner":"0xa11"}`, string(change.GetRecord()))
+
+	// Topics without a schema carry their JSON
+	alert := Message{Type: "update", Topic: TopicProtocolAlerts, Data: json.RawMessage(`{"level":"warn"}`)}
+	data, err = encodeFrame(EncodingProtobuf, alert)
+	require.NoError(t, err)
+	assert.Equal(t, `{"level":"warn"}`, string(decodeFrame(t, data).GetUpdate().GetJson()))
+
+	data, err = encodeFrame(EncodingProtobuf, AckFrame{Type: FrameAck, Op: OpSubscribe, Topics: []string{"a", "b"}})
+	require.NoError(t, err)
+	ack := decodeFrame(t, data).GetAck()
+	assert.Equal(t, OpSubscribe, ack.GetOp())
+	assert.Equal(t, []string{"a", "b"}, ack.GetTopics())
+
+	data, err = encodeFrame(EncodingProtobuf, ErrorFrame{Type: FrameError, Code: "bad_topic", Message: "unknown topic", Topic: "nope", Timestamp: 5})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&streampb.Error{Code: "bad_topic", Message: "unknown topic", Topic: "nope", Timestamp: 5}, decodeFrame(t, data).GetError()))
+
+	data, err = encodeFrame(EncodingMsgpack, AckFrame{Type: FrameAck, Op: OpPing, Topics: []string{}, Timestamp: 300})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"op": "ping", "timestamp": int64(300), "topics": []interface{}{}, "type": "ack"}, decodeMsgpack(t, data))
+}
+
+func TestJSONToMsgpack(t *testing.T) {
+	data, err := jsonToMsgpack([]byte(`{"b":[1,-33,5000000000,1.5,"x",null,true],"a":{"nested":"y"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{"nested": "y"},
+		"b": []interface{}{int64(1), int64(-33), int64(5000000000), 1.5, "x", nil, true},
+	}, decodeMsgpack(t, data))
+
+	// Keys are sorted, so that equal values encode alike
+	other, err := jsonToMsgpack([]byte(`{"a":{"nested":"y"},"b":[1,-33,5000000000,1.5,"x",null,true]}`))
+	require.NoError(t, err)
+	assert.Equal(t, data, other)
+}
+
+func TestHubEncodings(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-encoding-test")
+	require.NoError(t, err)
+	hub := NewHub(cache, zap.NewNop().Sugar(), metricsObj)
+	go hub.Run(ctx)
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.Dial(url+"?encoding=xml", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	dialer := websocket.Dialer{Subprotocols: []string{"leafsii.protobuf"}}
+	conn, _, err := dialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "leafsii.protobuf", conn.Subprotocol())
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe","topics":["prices:SUIUSDT"]}`)))
+
+	read := func(conn *websocket.Conn) []byte {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		typ, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, websocket.BinaryMessage, typ)
+		return data
+	}
+	assert.NotNil(t, decodeFrame(t, read(conn)).GetAck())
+
+	msgpack, _, err := websocket.DefaultDialer.Dial(url+"?encoding=msgpack", nil)
+	require.NoError(t, err)
+	defer msgpack.Close()
+	require.NoError(t, msgpack.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe","topics":["prices:SUIUSDT"]}`)))
+	assert.Equal(t, "ack", decodeMsgpack(t, read(msgpack)).(map[string]interface{})["type"])
+
+	require.NoError(t, hub.Push(ctx, PriceTopic("SUIUSDT"), map[string]interface{}{"symbol": "SUIUSDT", "price": 1.25}))
+	assert.Equal(t, "SUIUSDT", decodeFrame(t, read(conn)).GetUpdate().GetTick().GetSymbol())
+	update := decodeMsgpack(t, read(msgpack)).(map[string]interface{})
+	assert.Equal(t, "update", update["type"])
+	assert.Equal(t, map[string]interface{}{"symbol": "SUIUSDT", "price": 1.25}, update["data"])
+}
+
+func TestEncodeStreamEvent(t *testing.T) {
+	event := StreamEvent{Topic: TopicSPIndex, ID: 3, Data: json.RawMessage(`{"index":1}`), At: 1700000000000}
+	data, err := encodeStreamEvent(EncodingJSON, event)
+	require.NoError(t, err)
+	assert.Equal(t, `{"index":1}`, string(data))
+
+	data, err = encodeStreamEvent(EncodingMsgpack, event)
+	require.NoError(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"index": int64(1)}, decodeMsgpack(t, decoded))
+
+	data, err = encodeStreamEvent(EncodingProtobuf, event)
+	require.NoError(t, err)
+	decoded, err = base64.StdEncoding.DecodeString(string(data))
+	require.NoError(t, err)
+	var update streampb.Update
+	require.NoError(t, proto.Unmarshal(decoded, &update))
+	assert.Equal(t, TopicSPIndex, update.GetTopic())
+	assert.Equal(t, int64(1700000000), update.GetTimestamp())
+	assert.Equal(t, `{"index":1}`, string(update.GetJson()))
+}
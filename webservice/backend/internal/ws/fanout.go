@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// fanoutChannel carries the updates pushed on one replica to the hubs of
+// the others. Channels published by the services and the database change
+// feeds reach every replica on their own, so only Push goes through it.
+const fanoutChannel = "fx:ws:fanout"
+
+// fanoutEnvelope is an update pushed by the hub of instance
+type fanoutEnvelope struct {
+	Instance string          `json:"instance"`
+	Topic    string          `json:"topic"`
+	Data     json.RawMessage `json:"data"`
+	SentAt   int64           `json:"sentAt"` // Unix nanoseconds
+}
+
+// newInstanceID returns a random ID telling the hub of a replica apart
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// InstanceID returns the ID the hub tags the updates it fans out with
+func (h *Hub) InstanceID() string {
+	return h.instanceID
+}
+
+// fanout publishes an update delivered on this replica to the others
+func (h *Hub) fanout(ctx context.Context, topic string, data json.RawMessage, sentAt time.Time) {
+	err := h.cache.Publish(ctx, fanoutChannel, fanoutEnvelope{
+		Instance: h.instanceID,
+		Topic:    topic,
+		Data:     data,
+		SentAt:   sentAt.UnixNano(),
+	})
+	status := "ok"
+	if err != nil {
+		status = "error"
+		h.logger.Warnw("Failed to fan out WebSocket update", "topic", topic, "error", err)
+	}
+	h.metrics.RecordWSFanoutPublish(ctx, status)
+}
+
+// receiveFanout delivers an update fanned out by another replica. Updates
+// of this one were delivered when pushed, so their echo is dropped.
+func (h *Hub) receiveFanout(ctx context.Context, payload string) {
+	var envelope fanoutEnvelope
+	if err := json.Unmarshal([]byte(payload), &envelope); err != nil {
+		h.logger.Warnw("Invalid WebSocket fan-out message", "error", err)
+		return
+	}
+	if envelope.Instance == h.instanceID {
+		return
+	}
+
+	sentAt := time.Unix(0, envelope.SentAt)
+	h.metrics.RecordWSFanoutDelivery(ctx, time.Since(sentAt))
+	h.broadcastToClients(ctx, Message{
+		Type:      "update",
+		Topic:     envelope.Topic,
+		Data:      envelope.Data,
+		Timestamp: sentAt.Unix(),
+	})
+}
@@ -5,34 +5,86 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
 	"github.com/leafsii/leafsii-backend/internal/metrics"
 	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
+// watchlistAlertsChannel carries the alerts of watchlists, each forwarded
+// to the topic of its owner
+const watchlistAlertsChannel = "fx:watchlist:alerts"
+
+// Hub fans out updates to the WebSocket clients subscribed to their topic,
+// see protocol.go
 type Hub struct {
-	clients    map[*Client]bool
-	register   chan *Client
-	unregister chan *Client
-	broadcast  chan []byte
-	cache      *store.Cache
-	logger     *zap.SugaredLogger
-	metrics    *metrics.Metrics
-	mu         sync.RWMutex
+	clients       map[*Client]bool
+	register      chan *Client
+	unregister    chan *Client
+	cache         *store.Cache
+	logger        *zap.SugaredLogger
+	metrics       *metrics.Metrics
+	priceSymbols  []string
+	receiptOwners ReceiptOwnerFunc
+	instanceID    string // Tags the updates fanned out to other replicas
+	retained      *ReplayBuffer
+	mu            sync.RWMutex
+
+	sendBuffer       int // Updates queued per client
+	slowClientPolicy SlowClientPolicy
+	writeTimeout     time.Duration
+
+	// Connection caps, see limits.go; counts are guarded by mu
+	maxConns       int
+	maxConnsPerIP  int
+	trustedProxies []netip.Prefix
+	limitAction    LimitAction
+	conns          int
+	connsPerIP     map[string]int
+}
+
+// HubOption configures a Hub
+type HubOption func(*Hub)
+
+// WithPriceSymbols forwards the price ticks of symbols to their topics
+func WithPriceSymbols(symbols ...string) HubOption {
+	return func(h *Hub) {
+		h.priceSymbols = symbols
+	}
+}
+
+// WithReceiptOwners lets the owners of bridge receipts, as found by owners,
+// subscribe to their topics; without it bridge topics are refused
+func WithReceiptOwners(owners ReceiptOwnerFunc) HubOption {
+	return func(h *Hub) {
+		h.receiptOwners = owners
+	}
 }
 
 type Client struct {
 	hub        *Hub
 	conn       *websocket.Conn
 	send       chan []byte
+	mu         sync.Mutex // Guards topics and address
 	topics     map[string]bool
 	address    string // User address for user-specific updates
+	session    string // Address signed in on the connection, see WithSession
+	encoding   Encoding
+	ip         string
+	dropped    bool // Set once the hub dropped it, guarded by mu
 	lastActive time.Time
+
+	// Close frame of the connection once dropped, see Hub.drop
+	closeCode   int
+	closeReason string
 }
 
 type Message struct {
@@ -42,15 +94,10 @@ type Message struct {
 	Timestamp int64           `json:"timestamp"`
 }
 
-type WSSubscriptionRequest struct {
-	Type    string   `json:"type"`
-	Topics  []string `json:"topics"`
-	Address string   `json:"address,omitempty"`
-}
-
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    subprotocols,
 	CheckOrigin: func(r *http.Request) bool {
 		// Check allowed origins - in production, this should be configurable
 		origin := r.Header.Get("Origin")
@@ -71,16 +118,34 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func NewHub(cache *store.Cache, logger *zap.SugaredLogger, metrics *metrics.Metrics) *Hub {
-	return &Hub{
+func NewHub(cache *store.Cache, logger *zap.SugaredLogger, metrics *metrics.Metrics, opts ...HubOption) *Hub {
+	h := &Hub{
 		clients:    make(map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		broadcast:  make(chan []byte),
 		cache:      cache,
 		logger:     logger,
 		metrics:    metrics,
+		instanceID: newInstanceID(),
+
+		sendBuffer:       defaultSendBuffer,
+		slowClientPolicy: SlowClientDisconnect,
+		writeTimeout:     defaultWriteTimeout,
+
+		limitAction: LimitReject,
+		connsPerIP:  make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
+}
+
+// ClientCount returns the number of connected WebSocket clients
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
 }
 
 func (h *Hub) Run(ctx context.Context) {
@@ -101,45 +166,23 @@ func (h *Hub) Run(ctx context.Context) {
 			h.clients[client] = true
 			h.mu.Unlock()
 			h.metrics.IncrementConnections(ctx)
-			h.logger.Debugw("Client registered", "address", client.address, "topics", client.topics)
+			h.logger.Debugw("Client registered")
 
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.drop(client, 0, "")
 			}
 			h.mu.Unlock()
 			h.metrics.DecrementConnections(ctx)
-			h.logger.Debugw("Client unregistered", "address", client.address)
-
-		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					delete(h.clients, client)
-					close(client.send)
-				}
-			}
-			h.mu.RUnlock()
+			h.logger.Debugw("Client unregistered", "address", client.userAddress())
 		}
 	}
 }
 
 func (h *Hub) startRedisSubscription(ctx context.Context) {
-	// Subscribe to all event channels
-	channels := []string{
-		"fx:protocol:state",
-		"fx:sp:index",
-		"fx:events:REBALANCE",
-		"fx:events:MINT",
-		"fx:events:REDEEM",
-		"fx:events:STAKE",
-		"fx:events:UNSTAKE",
-		"fx:events:CLAIM",
-	}
+	// Subscribe to all event channels, and the updates other replicas push
+	channels := append(sourceChannels(h.priceSymbols), fanoutChannel)
 
 	// Try Redis pubsub first
 	pubsub := h.cache.Subscribe(ctx, channels...)
@@ -165,39 +208,102 @@ func (h *Hub) startRedisSubscription(ctx context.Context) {
 
 func (h *Hub) handleRedisMessage(ctx context.Context, msg *redis.Message) {
 	h.logger.Debugw("Received Redis message", "channel", msg.Channel, "payload", msg.Payload)
-
-	// Create WebSocket message
-	wsMessage := Message{
+	if msg.Channel == fanoutChannel {
+		h.receiveFanout(ctx, msg.Payload)
+		return
+	}
+	// Broadcast to relevant clients
+	h.broadcastToClients(ctx, Message{
 		Type:      "update",
-		Topic:     msg.Channel,
+		Topic:     topicOf(msg.Channel, msg.Payload),
 		Data:      json.RawMessage(msg.Payload),
 		Timestamp: time.Now().Unix(),
+	})
+}
+
+// topicOf returns the topic of a message published on channel. Watchlist
+// alerts go to the topic of their owner.
+func topicOf(channel, payload string) string {
+	if channel != watchlistAlertsChannel {
+		return topicOfChannel(channel)
+	}
+	var alert struct {
+		Owner string `json:"owner"`
+	}
+	if err := json.Unmarshal([]byte(payload), &alert); err != nil || alert.Owner == "" {
+		return topicOfChannel(channel)
 	}
+	return UserTopic(alert.Owner)
+}
 
-	messageBytes, err := json.Marshal(wsMessage)
-	if err != nil {
-		h.logger.Errorw("Failed to marshal WebSocket message", "error", err)
-		return
+// ForwardChanges pushes database change events to the clients subscribed
+// to their topic, e.g. BridgeTopicOf, until events is closed or ctx is done
+func (h *Hub) ForwardChanges(ctx context.Context, topicOf func(interfaces.ChangeEvent) string, events <-chan interfaces.ChangeEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				h.logger.Warnw("Database change feed closed")
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.Errorw("Failed to marshal change event", "error", err, "table", event.Table)
+				continue
+			}
+
+			h.broadcastToClients(ctx, Message{
+				Type:      "update",
+				Topic:     topicOf(event),
+				Data:      data,
+				Timestamp: time.Now().Unix(),
+			})
+		}
 	}
+}
 
-	// Broadcast to relevant clients
-	h.broadcastToClients(messageBytes, msg.Channel)
+// Push sends data to the clients subscribed to topic, on this replica and,
+// through the fan-out channel, on the others
+func (h *Hub) Push(ctx context.Context, topic string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal %s update: %w", topic, err)
+	}
+	now := time.Now()
+	h.broadcastToClients(ctx, Message{
+		Type:      "update",
+		Topic:     topic,
+		Data:      payload,
+		Timestamp: now.Unix(),
+	})
+	h.fanout(ctx, topic, payload, now)
+	return nil
 }
 
-func (h *Hub) broadcastToClients(message []byte, topic string) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+func (h *Hub) broadcastToClients(ctx context.Context, msg Message) {
+	// Slow clients may be dropped, so the write lock
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
+	// Encoded once per encoding of the subscribers
+	frames := make(map[Encoding][]byte, 1)
 	for client := range h.clients {
 		// Check if client is subscribed to this topic
-		if client.isSubscribed(topic) {
-			select {
-			case client.send <- message:
-			default:
-				// Client is slow or disconnected
-				delete(h.clients, client)
-				close(client.send)
+		if !client.isSubscribed(msg.Topic) {
+			continue
+		}
+		frame, ok := frames[client.encoding]
+		if !ok {
+			var err error
+			if frame, err = encodeFrame(client.encoding, msg); err != nil {
+				h.logger.Errorw("Failed to encode WebSocket message", "encoding", client.encoding, "topic", msg.Topic, "error", err)
 			}
+			frames[client.encoding] = frame
+		}
+		if frame != nil {
+			h.enqueue(ctx, client, frame)
 		}
 	}
 }
@@ -224,26 +330,48 @@ func (h *Hub) cleanupInactiveClients() {
 
 	for client := range h.clients {
 		if client.lastActive.Before(cutoff) {
-			delete(h.clients, client)
-			close(client.send)
-			h.logger.Debugw("Cleaned up inactive client", "address", client.address)
+			h.drop(client, 0, "")
+			h.logger.Debugw("Cleaned up inactive client", "address", client.userAddress())
 		}
 	}
 }
 
 // WebSocket endpoint handler
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Clients choose the encoding of their frames with a subprotocol, or
+	// with ?encoding= when they cannot
+	encoding, err := encodingOfRequest(r)
+	if err != nil {
+		h.metrics.RecordWSHandshakeFailure(r.Context(), handshakeEncoding)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ip := h.clientIP(r)
+	if reason := h.admit(ip); reason != "" {
+		h.refuse(w, r, reason)
+		return
+	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		h.mu.Lock()
+		h.release(ip)
+		h.mu.Unlock()
+		h.metrics.RecordWSHandshakeFailure(r.Context(), handshakeUpgrade)
 		h.logger.Errorw("WebSocket upgrade failed", "error", err)
 		return
 	}
+	if negotiated, ok := encodingOfSubprotocol(conn.Subprotocol()); ok {
+		encoding = negotiated
+	}
 
 	client := &Client{
 		hub:        h,
 		conn:       conn,
-		send:       make(chan []byte, 256),
+		send:       make(chan []byte, h.sendBuffer),
 		topics:     make(map[string]bool),
+		session:    SessionAddress(r.Context()),
+		encoding:   encoding,
+		ip:         ip,
 		lastActive: time.Now(),
 	}
 
@@ -260,7 +388,7 @@ func (c *Client) readPump() {
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(512)
+	c.conn.SetReadLimit(4096)
 	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -291,84 +419,195 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeTimeout))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage, c.closeMessage())
 				return
 			}
 
+			if c.encoding.binary() {
+				// Binary frames cannot be joined
+				if err := c.conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+					c.writeFailed(err)
+					return
+				}
+				c.hub.metrics.RecordWSMessagesSent(context.Background(), 1)
+				continue
+			}
+
 			w, err := c.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
+				c.writeFailed(err)
 				return
 			}
 			w.Write(message)
+			sent := 1
 
-			// Add queued messages to the current message
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+			// Add queued messages to the current message. The hub may
+			// drop them meanwhile, so without blocking.
+		batch:
+			for n := len(c.send); n > 0; n-- {
+				select {
+				case queued, ok := <-c.send:
+					if !ok {
+						break batch
+					}
+					w.Write([]byte{'\n'})
+					w.Write(queued)
+					sent++
+				default:
+					break batch
+				}
 			}
 
 			if err := w.Close(); err != nil {
+				c.writeFailed(err)
 				return
 			}
+			c.hub.metrics.RecordWSMessagesSent(context.Background(), sent)
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeTimeout))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.writeFailed(err)
 				return
 			}
 		}
 	}
 }
 
+// writeFailed records a write to c that failed, closing its connection
+func (c *Client) writeFailed(err error) {
+	if isTimeout(err) {
+		c.hub.logger.Infow("Disconnecting WebSocket client past its write deadline", "address", c.userAddress())
+		c.hub.metrics.RecordWSSlowDisconnect(context.Background(), "write_timeout")
+	}
+}
+
 func (c *Client) handleMessage(message []byte) {
-	var sub WSSubscriptionRequest
-	if err := json.Unmarshal(message, &sub); err != nil {
-		c.hub.logger.Warnw("Invalid subscription message", "error", err)
+	var req WSSubscriptionRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		c.replyError(req.ID, ErrCodeInvalidFrame, "frames must be JSON objects", "")
 		return
 	}
 
-	switch sub.Type {
-	case "subscribe":
-		for _, topic := range sub.Topics {
+	topics := req.Topics
+	if req.Address != "" {
+		topics = append(topics, UserTopic(req.Address))
+	}
+	var added []string
+	switch req.Type {
+	case OpSubscribe:
+		for i, topic := range topics {
+			if err := validateTopic(topic); err != nil {
+				c.replyError(req.ID, ErrCodeInvalidTopic, err.Error(), topic)
+				return
+			}
+			canonical, code, err := authorizeTopic(topic, c.session, c.hub.receiptOwners)
+			if err != nil {
+				c.replyError(req.ID, code, err.Error(), topic)
+				return
+			}
+			topics[i] = canonical
+		}
+		c.mu.Lock()
+		for _, topic := range topics {
+			if !c.topics[topic] && !slices.Contains(added, topic) {
+				added = append(added, topic)
+			}
+		}
+		if len(c.topics)+len(added) > maxTopicsPerClient {
+			c.mu.Unlock()
+			c.replyError(req.ID, ErrCodeTooManyTopics, fmt.Sprintf("at most %d topics per connection", maxTopicsPerClient), "")
+			return
+		}
+		for _, topic := range added {
 			c.topics[topic] = true
 		}
-		if sub.Address != "" {
-			c.address = sub.Address
-			// Subscribe to user-specific updates
-			userTopic := fmt.Sprintf("fx:user:%s", sub.Address)
-			c.topics[userTopic] = true
+		if req.Address != "" {
+			c.address = c.session
 		}
-		c.hub.logger.Debugw("Client subscribed to topics", "topics", sub.Topics, "address", sub.Address)
-
-	case "unsubscribe":
-		for _, topic := range sub.Topics {
-			delete(c.topics, topic)
+		if !c.dropped {
+			c.hub.recordSubscriptions(added, 1)
+		}
+		c.mu.Unlock()
+		c.hub.logger.Debugw("Client subscribed to topics", "topics", topics, "address", req.Address)
+
+	case OpUnsubscribe:
+		c.mu.Lock()
+		var removed []string
+		for _, topic := range topics {
+			if canonical, _, err := authorizeTopic(topic, c.session, c.hub.receiptOwners); err == nil && c.topics[canonical] {
+				delete(c.topics, canonical)
+				removed = append(removed, canonical)
+			}
 		}
-		c.hub.logger.Debugw("Client unsubscribed from topics", "topics", sub.Topics)
+		if !c.dropped {
+			c.hub.recordSubscriptions(removed, -1)
+		}
+		c.mu.Unlock()
+		c.hub.logger.Debugw("Client unsubscribed from topics", "topics", topics)
+
+	case OpPing:
+
+	default:
+		c.replyError(req.ID, ErrCodeUnknownOp, fmt.Sprintf("unknown frame type %q, want subscribe, unsubscribe or ping", req.Type), "")
+		return
 	}
+	c.reply(AckFrame{Type: FrameAck, ID: req.ID, Op: req.Type, Topics: c.subscriptions(), Timestamp: time.Now().Unix()})
+	c.replayRetained(context.Background(), added, replayLength(req))
 }
 
-func (c *Client) isSubscribed(topic string) bool {
-	// Check exact match
-	if c.topics[topic] {
-		return true
+// userAddress returns the address c subscribed with, if any
+func (c *Client) userAddress() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.address
+}
+
+// subscriptions returns the topics of c, sorted
+func (c *Client) subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
 	}
+	sort.Strings(topics)
+	return topics
+}
 
-	// Check pattern matches (simplified)
-	if c.topics["fx:protocol:*"] && topic == "fx:protocol:state" {
-		return true
+func (c *Client) replyError(id, code, message, topic string) {
+	c.reply(ErrorFrame{Type: FrameError, ID: id, Code: code, Message: message, Topic: topic, Timestamp: time.Now().Unix()})
+}
+
+// reply queues frame for c, unless c is dropped or its queue full
+func (c *Client) reply(frame interface{}) {
+	data, err := encodeFrame(c.encoding, frame)
+	if err != nil {
+		c.hub.logger.Errorw("Failed to encode WebSocket frame", "encoding", c.encoding, "error", err)
+		return
 	}
-	if c.topics["fx:sp:*"] && topic == "fx:sp:index" {
-		return true
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	if !c.hub.clients[c] {
+		return
 	}
-	if c.topics["fx:events:*"] && topic[:10] == "fx:events:" {
-		return true
+	select {
+	case c.send <- data:
+	default:
 	}
+}
 
-	return false
+// isSubscribed reports whether c is subscribed to topic or its wildcard
+func (c *Client) isSubscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.topics[topic] {
+		return true
+	}
+	wildcard, ok := wildcardOf(topic)
+	return ok && c.topics[wildcard]
 }
 
 // handleRedisPubSubMessages handles Redis pubsub messages
@@ -402,21 +641,15 @@ func (h *Hub) handleMockPubSubMessages(ctx context.Context, mockPubsub *store.Mo
 // handleMockMessage processes in-memory pubsub messages
 func (h *Hub) handleMockMessage(ctx context.Context, msg *store.MockMessage) {
 	h.logger.Debugw("Received in-memory message", "channel", msg.Channel, "payload", msg.Payload)
-
-	// Create WebSocket message - same format as Redis
-	wsMessage := Message{
+	if msg.Channel == fanoutChannel {
+		h.receiveFanout(ctx, msg.Payload)
+		return
+	}
+	// Broadcast to relevant clients - same format as Redis
+	h.broadcastToClients(ctx, Message{
 		Type:      "update",
-		Topic:     msg.Channel,
+		Topic:     topicOf(msg.Channel, msg.Payload),
 		Data:      json.RawMessage(msg.Payload),
 		Timestamp: time.Now().Unix(),
-	}
-
-	messageBytes, err := json.Marshal(wsMessage)
-	if err != nil {
-		h.logger.Errorw("Failed to marshal WebSocket message", "error", err)
-		return
-	}
-
-	// Broadcast to relevant clients
-	h.broadcastToClients(messageBytes, msg.Channel)
+	})
 }
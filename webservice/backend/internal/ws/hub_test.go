@@ -0,0 +1,509 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/leafsii/leafsii-backend/internal/metrics"
+	"github.com/leafsii/leafsii-backend/internal/store"
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testAddress = "0x0000000000000000000000000000000000000000000000000000000000000a11"
+
+// testConn is a WebSocket client of a hub, reading its frames one by one
+type testConn struct {
+	t       *testing.T
+	conn    *websocket.Conn
+	pending [][]byte
+}
+
+func dialHub(t *testing.T, hub *Hub) *testConn {
+	return dialHubAs(t, hub, "")
+}
+
+// dialHubAs connects to hub with the wallet session of address
+func dialHubAs(t *testing.T, hub *Hub, address string) *testConn {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if address != "" {
+			r = r.WithContext(WithSession(r.Context(), address))
+		}
+		hub.HandleWebSocket(w, r)
+	}))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &testConn{t: t, conn: conn}
+}
+
+func (c *testConn) send(frame string) {
+	require.NoError(c.t, c.conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+}
+
+// next returns the next frame; queued frames arrive newline separated
+func (c *testConn) next() map[string]interface{} {
+	if len(c.pending) == 0 {
+		require.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, data, err := c.conn.ReadMessage()
+		require.NoError(c.t, err)
+		c.pending = bytes.Split(data, []byte{'\n'})
+	}
+	var frame map[string]interface{}
+	require.NoError(c.t, json.Unmarshal(c.pending[0], &frame))
+	c.pending = c.pending[1:]
+	return frame
+}
+
+func TestHubTopics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-test")
+	require.NoError(t, err)
+	hub := NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithPriceSymbols("SUIUSDT"), WithReceiptOwners(testReceiptOwners))
+	go hub.Run(ctx)
+
+	prices := dialHub(t, hub)
+	prices.send(`{"type":"subscribe","id":"1","topics":["prices:*"]}`)
+	ack := prices.next()
+	assert.Equal(t, FrameAck, ack["type"])
+	assert.Equal(t, "1", ack["id"])
+	assert.Equal(t, []interface{}{"prices:*"}, ack["topics"])
+
+	user := dialHubAs(t, hub, testAddress)
+	user.send(`{"type":"subscribe","topics":["protocol:state","bridge:bridge_7"],"address":"` + testAddress + `"}`)
+	ack = user.next()
+	assert.Equal(t, []interface{}{"bridge:bridge_7", "protocol:state", UserTopic(testAddress)}, ack["topics"])
+
+	// Updates go to the subscribers of their topic only
+	require.NoError(t, hub.Push(ctx, UserTopic(testAddress), map[string]string{"hello": "user"}))
+	require.NoError(t, hub.Push(ctx, PriceTopic("SUIUSDT"), map[string]string{"price": "1.5"}))
+	update := user.next()
+	assert.Equal(t, "update", update["type"])
+	assert.Equal(t, UserTopic(testAddress), update["topic"])
+	update = prices.next()
+	assert.Equal(t, PriceTopic("SUIUSDT"), update["topic"])
+
+	// Published channels are forwarded under their topic, once the hub
+	// has subscribed to them
+	published := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-published:
+				return
+			case <-ticker.C:
+				cache.Publish(ctx, "fx:oracle:price:SUIUSDT", map[string]string{"price": "1.6"})
+			}
+		}
+	}()
+	update = prices.next()
+	close(published)
+	assert.Equal(t, PriceTopic("SUIUSDT"), update["topic"])
+	assert.Equal(t, "1.6", update["data"].(map[string]interface{})["price"])
+
+	// As are database changes
+	events := make(chan interfaces.ChangeEvent, 1)
+	go hub.ForwardChanges(ctx, BridgeTopicOf, events)
+	events <- interfaces.ChangeEvent{Table: "receipt_transitions", ID: "t1", Record: map[string]interface{}{"receipt_id": "bridge_7"}}
+	update = user.next()
+	assert.Equal(t, BridgeTopic("bridge_7"), update["topic"])
+
+	user.send(`{"type":"unsubscribe","id":"2","topics":["protocol:state"]}`)
+	ack = user.next()
+	assert.Equal(t, "unsubscribe", ack["op"])
+	assert.Len(t, ack["topics"], 2)
+
+	for frame, code := range map[string]string{
+		`not json`:                  ErrCodeInvalidFrame,
+		`{"type":"stake","id":"3"}`: ErrCodeUnknownOp,
+		`{"type":"subscribe","topics":["prices:sui"]}`:   ErrCodeInvalidTopic,
+		`{"type":"subscribe","topics":["user:*"]}`:       ErrCodeInvalidTopic,
+		`{"type":"subscribe","topics":["nothing:here"]}`: ErrCodeInvalidTopic,
+	} {
+		user.send(frame)
+		reply := user.next()
+		assert.Equal(t, FrameError, reply["type"], frame)
+		assert.Equal(t, code, reply["code"], frame)
+	}
+
+	topics := make([]string, maxTopicsPerClient)
+	for i := range topics {
+		topics[i] = PriceTopic(fmt.Sprintf("SYM%d", i))
+	}
+	raw, err := json.Marshal(WSSubscriptionRequest{Type: OpSubscribe, Topics: topics})
+	require.NoError(t, err)
+	user.send(string(raw))
+	reply := user.next()
+	assert.Equal(t, ErrCodeTooManyTopics, reply["code"])
+}
+
+func TestHubFanout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-fanout-test")
+	require.NoError(t, err)
+
+	// Two replicas sharing the pubsub of the cache
+	local := NewHub(cache, zap.NewNop().Sugar(), metricsObj)
+	remote := NewHub(cache, zap.NewNop().Sugar(), metricsObj)
+	require.NotEqual(t, local.InstanceID(), remote.InstanceID())
+	go local.Run(ctx)
+	go remote.Run(ctx)
+
+	topic := TransactionTopic("4Kp1a8cXc9hVhxYUaZyDg3WTxKsdxUVsJYzXUo3aHgSx")
+	localConn, remoteConn := dialHub(t, local), dialHub(t, remote)
+	for _, conn := range []*testConn{localConn, remoteConn} {
+		conn.send(`{"type":"subscribe","topics":["` + topic + `"]}`)
+		assert.Equal(t, FrameAck, conn.next()["type"])
+	}
+
+	// Pushes reach the other replica once it subscribed to the fan-out
+	// channel, and the pushing one only once
+	pushed := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for seq := 0; ; seq++ {
+			select {
+			case <-pushed:
+				return
+			case <-ticker.C:
+				local.Push(ctx, topic, map[string]int{"seq": seq})
+			}
+		}
+	}()
+	update := remoteConn.next()
+	assert.Equal(t, topic, update["topic"])
+	first := localConn.next()
+	second := localConn.next()
+	close(pushed)
+	assert.NotEqual(t, first["data"], second["data"], "no echo of the local push")
+}
+
+func TestTopicOfChannel(t *testing.T) {
+	assert.Equal(t, "prices:SUIUSDT", topicOfChannel("fx:oracle:price:SUIUSDT"))
+	assert.Equal(t, TopicProtocolState, topicOfChannel("fx:protocol:state"))
+	assert.Equal(t, "events:MINT", topicOfChannel("fx:events:MINT"))
+	assert.Equal(t, UserTopic(testAddress), topicOf(watchlistAlertsChannel, `{"owner":"`+testAddress+`"}`))
+}
+
+func TestHubPrivateTopics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-private-test")
+	require.NoError(t, err)
+	hub := NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithReceiptOwners(testReceiptOwners))
+	go hub.Run(ctx)
+
+	anonymous := dialHub(t, hub)
+	anonymous.send(`{"type":"subscribe","id":"1","topics":["user:` + testAddress + `"]}`)
+	frame := anonymous.next()
+	assert.Equal(t, FrameError, frame["type"])
+	assert.Equal(t, ErrCodeUnauthorized, frame["code"])
+	anonymous.send(`{"type":"subscribe","address":"` + testAddress + `"}`)
+	assert.Equal(t, ErrCodeUnauthorized, anonymous.next()["code"])
+	anonymous.send(`{"type":"subscribe","topics":["bridge:bridge_7"]}`)
+	assert.Equal(t, ErrCodeUnauthorized, anonymous.next()["code"])
+	anonymous.send(`{"type":"subscribe","topics":["bridge:*"]}`)
+	assert.Equal(t, ErrCodeInvalidTopic, anonymous.next()["code"])
+
+	other := dialHubAs(t, hub, "0x0000000000000000000000000000000000000000000000000000000000000b0b")
+	other.send(`{"type":"subscribe","topics":["protocol:state","user:` + testAddress + `"]}`)
+	frame = other.next()
+	assert.Equal(t, ErrCodeForbidden, frame["code"])
+	assert.Equal(t, "user:"+testAddress, frame["topic"])
+	other.send(`{"type":"subscribe","topics":["bridge:bridge_7"]}`)
+	assert.Equal(t, ErrCodeForbidden, other.next()["code"])
+	other.send(`{"type":"subscribe","topics":["bridge:bridge_8"]}`)
+	assert.Equal(t, ErrCodeForbidden, other.next()["code"])
+	other.send(`{"type":"ping"}`)
+	assert.Empty(t, other.next()["topics"])
+
+	// Short addresses name the topic of their canonical form
+	owner := dialHubAs(t, hub, "0x0000000000000000000000000000000000000000000000000000000000000a11")
+	owner.send(`{"type":"subscribe","topics":["user:0xa11"]}`)
+	assert.Equal(t, []interface{}{UserTopic(testAddress)}, owner.next()["topics"])
+	require.NoError(t, hub.Push(ctx, UserTopic(testAddress), map[string]string{"hello": "owner"}))
+	assert.Equal(t, UserTopic(testAddress), owner.next()["topic"])
+	owner.send(`{"type":"unsubscribe","topics":["user:0xa11"]}`)
+	assert.Empty(t, owner.next()["topics"])
+
+	// Receipts are private to their owner
+	owner.send(`{"type":"subscribe","topics":["bridge:bridge_7"]}`)
+	assert.Equal(t, []interface{}{"bridge:bridge_7"}, owner.next()["topics"])
+}
+
+// testReceiptOwners finds the receipt bridge_7 of testAddress, spelled short
+func testReceiptOwners(ctx context.Context, receiptID string) (string, error) {
+	if receiptID != "bridge_7" {
+		return "", errors.New("receipt not found")
+	}
+	return "0xa11", nil
+}
+
+func TestHubBackpressure(t *testing.T) {
+	ctx := context.Background()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-backpressure-test")
+	require.NoError(t, err)
+
+	// A client whose write pump is stalled
+	stalled := func(hub *Hub) *Client {
+		client := &Client{hub: hub, send: make(chan []byte, hub.sendBuffer), topics: map[string]bool{TopicSPIndex: true}}
+		hub.clients[client] = true
+		return client
+	}
+	queued := func(client *Client) []string {
+		var messages []string
+		for message := range client.send {
+			var update Message
+			require.NoError(t, json.Unmarshal(message, &update))
+			messages = append(messages, string(update.Data))
+		}
+		return messages
+	}
+
+	hub := NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithSendBuffer(2, SlowClientDropOldest))
+	client := stalled(hub)
+	for _, message := range []string{"1", "2", "3"} {
+		hub.broadcastToClients(ctx, Message{Type: "update", Topic: TopicSPIndex, Data: json.RawMessage(message)})
+	}
+	assert.Equal(t, 1, hub.ClientCount())
+	close(client.send)
+	assert.Equal(t, []string{"2", "3"}, queued(client))
+
+	hub = NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithSendBuffer(2, SlowClientDisconnect))
+	client = stalled(hub)
+	for _, message := range []string{"1", "2", "3"} {
+		hub.broadcastToClients(ctx, Message{Type: "update", Topic: TopicSPIndex, Data: json.RawMessage(message)})
+	}
+	assert.Zero(t, hub.ClientCount())
+	assert.Equal(t, []string{"1", "2"}, queued(client))
+	assert.Equal(t, websocket.FormatCloseMessage(CloseBackpressure, "send queue full"), client.closeMessage())
+}
+
+func TestHubBackpressureClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-backpressure-close-test")
+	require.NoError(t, err)
+	hub := NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithSendBuffer(1, SlowClientDisconnect))
+	go hub.Run(ctx)
+
+	conn := dialHub(t, hub)
+	conn.send(`{"type":"subscribe","topics":["sp:index"]}`)
+	assert.Equal(t, FrameAck, conn.next()["type"])
+
+	// Updates outpace the client until its queue overflows
+	require.Eventually(t, func() bool {
+		for i := 0; i < 100; i++ {
+			hub.broadcastToClients(ctx, Message{Type: "update", Topic: TopicSPIndex, Data: json.RawMessage(`{}`)})
+		}
+		return hub.ClientCount() == 0
+	}, 2*time.Second, time.Millisecond)
+
+	var closeErr *websocket.CloseError
+	for {
+		require.NoError(t, conn.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		if _, _, err = conn.conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseBackpressure, closeErr.Code)
+}
+
+func TestHubConnectionLimits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-limits-test")
+	require.NoError(t, err)
+
+	rejecting := NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithConnectionLimits(0, 1, LimitReject))
+	go rejecting.Run(ctx)
+	server := httptest.NewServer(http.HandlerFunc(rejecting.HandleWebSocket))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	first, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// Closed connections free their place
+	first.Close()
+	require.Eventually(t, func() bool {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 2*time.Second, 20*time.Millisecond)
+
+	closing := NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithConnectionLimits(1, 0, LimitClose))
+	go closing.Run(ctx)
+	admitted := dialHub(t, closing)
+	admitted.send(`{"type":"ping"}`)
+	assert.Equal(t, FrameAck, admitted.next()["type"])
+	refused := dialHub(t, closing)
+	_, _, err = refused.conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseTryAgainLater, closeErr.Code)
+}
+
+func TestHubTrustedProxies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-proxies-test")
+	require.NoError(t, err)
+	proxies, err := ParseTrustedProxies([]string{"127.0.0.1", "10.0.0.0/8"})
+	require.NoError(t, err)
+
+	hub := NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithConnectionLimits(0, 1, LimitReject), WithTrustedProxies(proxies...))
+	go hub.Run(ctx)
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	dial := func(forwardedFor string) (*http.Response, error) {
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"X-Forwarded-For": {forwardedFor}})
+		if err == nil {
+			t.Cleanup(func() { conn.Close() })
+		}
+		return resp, err
+	}
+
+	// Clients behind the proxy have their own cap, past the proxy's hops
+	_, err = dial("203.0.113.7, 10.1.2.3")
+	require.NoError(t, err)
+	_, err = dial("203.0.113.8")
+	require.NoError(t, err)
+	// Hops before the client's are its own to forge
+	resp, err := dial("198.51.100.1, 203.0.113.7")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	hub := &Hub{trustedProxies: proxies}
+
+	for _, tt := range []struct {
+		remote string
+		header http.Header
+		want   string
+	}{
+		{remote: "203.0.113.7:4000", header: http.Header{"X-Forwarded-For": {"198.51.100.1"}}, want: "203.0.113.7"},
+		{remote: "10.0.0.1:4000", header: http.Header{"X-Forwarded-For": {"198.51.100.1, 10.0.0.2"}}, want: "198.51.100.1"},
+		{remote: "10.0.0.1:4000", header: http.Header{"X-Real-Ip": {"198.51.100.1"}}, want: "198.51.100.1"},
+		{remote: "10.0.0.1:4000", header: http.Header{"X-Forwarded-For": {"unknown"}}, want: "10.0.0.1"},
+		{remote: "10.0.0.1:4000", want: "10.0.0.1"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/ws", nil)
+		r.RemoteAddr = tt.remote
+		for name, values := range tt.header {
+			r.Header[name] = values
+		}
+		assert.Equal(t, tt.want, hub.clientIP(r), tt)
+	}
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestTopicLabel(t *testing.T) {
+	assert.Equal(t, TopicProtocolState, topicLabel(TopicProtocolState))
+	assert.Equal(t, "prices:SUIUSDT", topicLabel("prices:SUIUSDT"))
+	assert.Equal(t, "bridge", topicLabel("bridge:bridge_7"))
+	assert.Equal(t, "user", topicLabel(UserTopic(testAddress)))
+	assert.Equal(t, "tx", topicLabel("tx:abc"))
+}
+
+func TestHubRetained(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	metricsObj, _, err := metrics.Setup("ws-retained-test")
+	require.NoError(t, err)
+	buffer := NewReplayBuffer(memkv.NewStore(), 10)
+	for _, update := range []struct{ topic, data string }{
+		{TopicProtocolState, `{"seq":1}`},
+		{TopicProtocolState, `{"seq":2}`},
+		{TopicProtocolState, `{"seq":3}`},
+		{PriceTopic("SUIUSDT"), `{"price":1.25}`},
+		{"events:MINT", `{"amount":"1"}`},
+	} {
+		_, err := buffer.Append(ctx, update.topic, json.RawMessage(update.data))
+		require.NoError(t, err)
+	}
+	hub := NewHub(cache, zap.NewNop().Sugar(), metricsObj, WithPriceSymbols("SUIUSDT", "BTCUSDT"), WithRetained(buffer))
+	go hub.Run(ctx)
+
+	// The latest update of each state topic follows the ack; events are not
+	// replayed
+	conn := dialHub(t, hub)
+	conn.send(`{"type":"subscribe","topics":["protocol:state","prices:*","events:MINT"]}`)
+	assert.Equal(t, FrameAck, conn.next()["type"])
+	frame := conn.next()
+	assert.Equal(t, FrameReplay, frame["type"])
+	assert.Equal(t, TopicProtocolState, frame["topic"])
+	assert.Equal(t, map[string]interface{}{"seq": float64(3)}, frame["data"])
+	frame = conn.next()
+	assert.Equal(t, PriceTopic("SUIUSDT"), frame["topic"])
+	require.NoError(t, hub.Push(ctx, "events:MINT", map[string]string{"amount": "2"}))
+	assert.Equal(t, "update", conn.next()["type"])
+
+	// Re-subscribing replays nothing
+	conn.send(`{"type":"subscribe","topics":["protocol:state"],"replay":5}`)
+	assert.Equal(t, FrameAck, conn.next()["type"])
+
+	last := dialHub(t, hub)
+	last.send(`{"type":"subscribe","topics":["protocol:state"],"replay":2}`)
+	assert.Equal(t, FrameAck, last.next()["type"])
+	assert.Equal(t, map[string]interface{}{"seq": float64(2)}, last.next()["data"])
+	assert.Equal(t, map[string]interface{}{"seq": float64(3)}, last.next()["data"])
+
+	none := dialHub(t, hub)
+	none.send(`{"type":"subscribe","topics":["protocol:state"],"replay":0}`)
+	assert.Equal(t, FrameAck, none.next()["type"])
+	require.NoError(t, hub.Push(ctx, TopicProtocolState, map[string]int{"seq": 4}))
+	frame = none.next()
+	assert.Equal(t, "update", frame["type"])
+	assert.Equal(t, map[string]interface{}{"seq": float64(4)}, frame["data"])
+	for _, conn := range []*testConn{conn, last} {
+		assert.Equal(t, "update", conn.next()["type"])
+	}
+}
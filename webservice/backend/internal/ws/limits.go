@@ -0,0 +1,185 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// LimitAction is what the hub does with a connection over its caps
+type LimitAction string
+
+const (
+	// LimitReject answers the handshake with 429 Too Many Requests
+	LimitReject LimitAction = "reject"
+	// LimitClose completes the handshake, then closes the connection with
+	// 1013 Try Again Later, for clients that cannot read handshake errors
+	LimitClose LimitAction = "close"
+)
+
+// Reasons of handshake failures
+const (
+	handshakeEncoding = "encoding"
+	handshakeUpgrade  = "upgrade"
+	handshakeLimit    = "connection_limit"
+	handshakeIPLimit  = "ip_limit"
+)
+
+// WithConnectionLimits caps the connections of the hub to max, and those
+// of each client IP to perIP; 0 leaves them unlimited
+func WithConnectionLimits(max, perIP int, action LimitAction) HubOption {
+	return func(h *Hub) {
+		h.maxConns = max
+		h.maxConnsPerIP = perIP
+		if action != "" {
+			h.limitAction = action
+		}
+	}
+}
+
+// WithTrustedProxies counts the connections of each client IP behind the
+// proxies of prefixes, e.g. a load balancer, by the IP they forward rather
+// than their own, see clientIP
+func WithTrustedProxies(prefixes ...netip.Prefix) HubOption {
+	return func(h *Hub) {
+		h.trustedProxies = prefixes
+	}
+}
+
+// ParseTrustedProxies parses a list of IPs and CIDR prefixes, as
+// LFS_WS_TRUSTED_PROXIES holds
+func ParseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: want an IP or CIDR prefix", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// admit reserves a connection of ip, or returns the handshake failure of
+// the cap it would exceed
+func (h *Hub) admit(ip string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxConns > 0 && h.conns >= h.maxConns {
+		return handshakeLimit
+	}
+	if h.maxConnsPerIP > 0 && h.connsPerIP[ip] >= h.maxConnsPerIP {
+		return handshakeIPLimit
+	}
+	h.conns++
+	h.connsPerIP[ip]++
+	return ""
+}
+
+// release frees the connection of ip reserved by admit. h.mu must be held
+// for writing.
+func (h *Hub) release(ip string) {
+	h.conns--
+	if h.connsPerIP[ip]--; h.connsPerIP[ip] <= 0 {
+		delete(h.connsPerIP, ip)
+	}
+}
+
+// refuse turns away a connection over the caps, as set by LimitAction
+func (h *Hub) refuse(w http.ResponseWriter, r *http.Request, reason string) {
+	h.metrics.RecordWSHandshakeFailure(r.Context(), reason)
+	h.logger.Infow("Refusing WebSocket connection over the limit", "reason", reason, "ip", h.clientIP(r))
+	if h.limitAction != LimitClose {
+		http.Error(w, "too many WebSocket connections", http.StatusTooManyRequests)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many connections"),
+		time.Now().Add(h.writeTimeout))
+	conn.Close()
+}
+
+// clientIP returns the IP of the client of r. A peer that is a trusted
+// proxy is looked through: the client is the last hop of X-Forwarded-For
+// that is not a trusted proxy, or else X-Real-IP. The headers of other
+// peers are ignored, as clients may forge them.
+func (h *Hub) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !h.trustedProxy(host) {
+		return host
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			host = hop.Unmap().String()
+			if !h.trustedProxy(host) {
+				break
+			}
+		}
+		return host
+	}
+	if real, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return real.Unmap().String()
+	}
+	return host
+}
+
+// trustedProxy reports whether ip is of a proxy set by WithTrustedProxies
+func (h *Hub) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// topicLabel returns the metric label of topic: the topic itself, or its
+// kind for the topics of single users, receipts and transactions, which
+// are too many to label one by one
+func topicLabel(topic string) string {
+	kind, _, _ := strings.Cut(topic, ":")
+	switch kind {
+	case "user", "bridge", "tx":
+		return kind
+	}
+	return topic
+}
+
+// recordSubscriptions records topics subscribed to, delta 1, or left, -1
+func (h *Hub) recordSubscriptions(topics []string, delta int64) {
+	for _, topic := range topics {
+		h.metrics.RecordWSSubscription(context.Background(), topicLabel(topic), delta)
+	}
+}
@@ -0,0 +1,39 @@
+package ws
+
+import (
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+)
+
+var (
+	// jsonHandle decodes JSON objects into maps with string keys, and
+	// integers into int64, so that they stay integers in MessagePack
+	jsonHandle = func() *codec.JsonHandle {
+		h := &codec.JsonHandle{}
+		h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+		h.SignedInteger = true
+		return h
+	}()
+	// msgpackHandle writes the MessagePack string and binary formats, with
+	// map keys sorted so that equal values encode alike
+	msgpackHandle = func() *codec.MsgpackHandle {
+		h := &codec.MsgpackHandle{WriteExt: true}
+		h.Canonical = true
+		return h
+	}()
+)
+
+// jsonToMsgpack re-encodes JSON data as MessagePack. Integers take the
+// smallest format holding them.
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := codec.NewDecoderBytes(data, jsonHandle).Decode(&value); err != nil {
+		return nil, err
+	}
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, msgpackHandle).Encode(value); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,194 @@
+package ws
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/leafsii/leafsii-backend/internal/db/interfaces"
+	"github.com/pattonkan/sui-go/sui"
+)
+
+// Client frames: a client subscribes to and unsubscribes from topics, and
+// pings to check the connection. Each frame is answered with an ack
+// listing the topics the client is subscribed to, or an error.
+const (
+	OpSubscribe   = "subscribe"
+	OpUnsubscribe = "unsubscribe"
+	OpPing        = "ping"
+)
+
+// Server frames besides updates
+const (
+	FrameAck    = "ack"
+	FrameError  = "error"
+	FrameReplay = "replay" // Retained update, sent after the ack of a subscription
+)
+
+// Codes of error frames
+const (
+	ErrCodeInvalidFrame  = "INVALID_FRAME"
+	ErrCodeUnknownOp     = "UNKNOWN_OP"
+	ErrCodeInvalidTopic  = "INVALID_TOPIC"
+	ErrCodeTooManyTopics = "TOO_MANY_TOPICS"
+	ErrCodeUnauthorized  = "UNAUTHORIZED" // User topics need a wallet session
+	ErrCodeForbidden     = "FORBIDDEN"    // User topic of another address
+)
+
+// Topics of the hub. Topics of a kind are matched by the wildcard
+// <kind>:*, e.g. prices:*, except user, bridge and transaction topics,
+// which are named one by one. User and bridge topics are private, see
+// authorizeTopic.
+const (
+	TopicProtocolState  = "protocol:state"
+	TopicProtocolAlerts = "protocol:alerts"
+	TopicSPIndex        = "sp:index"
+)
+
+// maxTopicsPerClient bounds the subscriptions of a connection
+const maxTopicsPerClient = 50
+
+var (
+	symbolPattern    = regexp.MustCompile(`^[A-Z0-9]{2,20}$`)
+	receiptIDPattern = regexp.MustCompile(`^[A-Za-z0-9:_\-]{1,128}$`)
+	digestPattern    = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)
+	eventTypes       = map[string]bool{"MINT": true, "REDEEM": true, "STAKE": true, "UNSTAKE": true, "CLAIM": true, "REBALANCE": true}
+)
+
+// PriceTopic is the topic of the price ticks of symbol, e.g. SUIUSDT
+func PriceTopic(symbol string) string {
+	return "prices:" + symbol
+}
+
+// UserTopic is the topic of the updates of the user at address
+func UserTopic(address string) string {
+	return "user:" + address
+}
+
+// BridgeTopic is the topic of the changes of a bridge receipt
+func BridgeTopic(receiptID string) string {
+	return "bridge:" + receiptID
+}
+
+// TransactionTopic is the topic of the final status of a transaction
+func TransactionTopic(digest string) string {
+	return "tx:" + digest
+}
+
+// BridgeTopicOf returns the topic of a change of a bridge receipt or of
+// one of its stage transitions
+func BridgeTopicOf(event interfaces.ChangeEvent) string {
+	if receiptID, ok := event.Record["receipt_id"].(string); ok && receiptID != "" {
+		return BridgeTopic(receiptID)
+	}
+	return BridgeTopic(event.ID)
+}
+
+// WSSubscriptionRequest is a client frame
+type WSSubscriptionRequest struct {
+	Type    string   `json:"type"`         // subscribe, unsubscribe or ping
+	ID      string   `json:"id,omitempty"` // Echoed by the frame answering it
+	Topics  []string `json:"topics"`
+	Address string   `json:"address,omitempty"` // Shorthand for the topic user:<address>
+	Replay  *int     `json:"replay,omitempty"`  // Retained updates per topic sent on subscribe; 1 when unset
+}
+
+// AckFrame answers a client frame that succeeded
+type AckFrame struct {
+	Type      string   `json:"type"`
+	ID        string   `json:"id,omitempty"`
+	Op        string   `json:"op"`
+	Topics    []string `json:"topics"` // All the topics of the client, sorted
+	Timestamp int64    `json:"timestamp"`
+}
+
+// ErrorFrame answers a client frame that failed; the subscriptions of the
+// client are left as they were
+type ErrorFrame struct {
+	Type      string `json:"type"`
+	ID        string `json:"id,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Topic     string `json:"topic,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// validateTopic checks that clients may subscribe to topic
+func validateTopic(topic string) error {
+	switch topic {
+	case TopicProtocolState, TopicProtocolAlerts, TopicSPIndex:
+		return nil
+	}
+	kind, name, ok := strings.Cut(topic, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("unknown topic %q", topic)
+	}
+	switch kind {
+	case "prices":
+		if name == "*" || symbolPattern.MatchString(name) {
+			return nil
+		}
+		return fmt.Errorf("topic %q needs an upper case symbol, e.g. prices:SUIUSDT", topic)
+	case "events":
+		if name == "*" || eventTypes[name] {
+			return nil
+		}
+		return fmt.Errorf("topic %q needs an event type, e.g. events:MINT", topic)
+	case "user":
+		if _, err := sui.AddressFromHex(name); err != nil {
+			return fmt.Errorf("topic %q needs a Sui address", topic)
+		}
+		return nil
+	case "bridge":
+		if receiptIDPattern.MatchString(name) {
+			return nil
+		}
+		return fmt.Errorf("topic %q needs a receipt ID", topic)
+	case "tx":
+		if digestPattern.MatchString(name) {
+			return nil
+		}
+		return fmt.Errorf("topic %q needs a transaction digest", topic)
+	}
+	return fmt.Errorf("unknown topic %q", topic)
+}
+
+// wildcardOf returns the wildcard matching topic, if any
+func wildcardOf(topic string) (string, bool) {
+	kind, _, ok := strings.Cut(topic, ":")
+	if !ok || kind == "user" || kind == "bridge" || kind == "tx" {
+		return "", false
+	}
+	return kind + ":*", true
+}
+
+// sourceChannels returns the pubsub channels the services publish updates
+// on, the prices of symbols included
+func sourceChannels(priceSymbols []string) []string {
+	channels := []string{
+		"fx:protocol:state",
+		"fx:protocol:alerts",
+		"fx:sp:index",
+		"fx:events:REBALANCE",
+		"fx:events:MINT",
+		"fx:events:REDEEM",
+		"fx:events:STAKE",
+		"fx:events:UNSTAKE",
+		"fx:events:CLAIM",
+		watchlistAlertsChannel,
+	}
+	for _, symbol := range priceSymbols {
+		channels = append(channels, fmt.Sprintf("fx:oracle:price:%s", symbol))
+	}
+	return channels
+}
+
+// topicOfChannel returns the topic of the pubsub channel, e.g. prices:SUIUSDT
+// for fx:oracle:price:SUIUSDT
+func topicOfChannel(channel string) string {
+	topic := strings.TrimPrefix(channel, "fx:")
+	if symbol, ok := strings.CutPrefix(topic, "oracle:price:"); ok {
+		return PriceTopic(symbol)
+	}
+	return topic
+}
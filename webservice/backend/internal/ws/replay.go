@@ -0,0 +1,149 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+)
+
+// DefaultReplaySize is the number of events kept per topic when none is
+// configured
+const DefaultReplaySize = 500
+
+// StreamEvent is an event of a stream topic. The IDs of a topic increase by
+// one from event to event.
+type StreamEvent struct {
+	Topic string          `json:"topic"`
+	ID    int64           `json:"id"`
+	Data  json.RawMessage `json:"data"`
+	At    int64           `json:"at"` // Unix milliseconds
+}
+
+// ReplayBuffer numbers the events of each topic and keeps the last of
+// them, so that clients reconnecting with Last-Event-ID get the events
+// they missed. Kept in a shared store, it numbers the events of every
+// replica alike.
+type ReplayBuffer struct {
+	store kv.Store
+	size  int64
+}
+
+// NewReplayBuffer keeps the last size events of each topic in store
+func NewReplayBuffer(store kv.Store, size int64) *ReplayBuffer {
+	if size <= 0 {
+		size = DefaultReplaySize
+	}
+	return &ReplayBuffer{store: store, size: size}
+}
+
+func replaySeqKey(topic string) string {
+	return "fx:sse:seq:" + topic
+}
+
+func replayKey(topic string) string {
+	return "fx:sse:replay:" + topic
+}
+
+// Append numbers an event of topic and keeps it
+func (b *ReplayBuffer) Append(ctx context.Context, topic string, data json.RawMessage) (StreamEvent, error) {
+	id, err := b.store.IncrBy(ctx, replaySeqKey(topic), 1)
+	if err != nil {
+		return StreamEvent{}, fmt.Errorf("number %s event: %w", topic, err)
+	}
+	event := StreamEvent{Topic: topic, ID: id, Data: data, At: time.Now().UnixMilli()}
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return StreamEvent{}, fmt.Errorf("encode %s event: %w", topic, err)
+	}
+	n, err := b.store.LPush(ctx, replayKey(topic), raw)
+	if err != nil {
+		return StreamEvent{}, fmt.Errorf("keep %s event: %w", topic, err)
+	}
+	for ; n > b.size; n-- {
+		if _, err := b.store.RPop(ctx, replayKey(topic)); err != nil {
+			return event, fmt.Errorf("trim %s events: %w", topic, err)
+		}
+	}
+	return event, nil
+}
+
+// Since returns the kept events of topic after the event after, oldest
+// first, and whether they follow it without a gap
+func (b *ReplayBuffer) Since(ctx context.Context, topic string, after int64) ([]StreamEvent, bool, error) {
+	raws, err := b.store.LRange(ctx, replayKey(topic), 0, b.size-1)
+	if err != nil && !errors.Is(err, kv.ErrNotFound) {
+		return nil, false, fmt.Errorf("list %s events: %w", topic, err)
+	}
+	var events []StreamEvent
+	for _, raw := range raws {
+		var event StreamEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, false, fmt.Errorf("decode %s event: %w", topic, err)
+		}
+		if event.ID > after {
+			events = append(events, event)
+		}
+	}
+	// Replicas appending at once may keep events slightly out of order
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, len(events) == 0 || events[0].ID == after+1, nil
+}
+
+// Last returns the last n kept events of topic, oldest first
+func (b *ReplayBuffer) Last(ctx context.Context, topic string, n int64) ([]StreamEvent, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	raws, err := b.store.LRange(ctx, replayKey(topic), 0, min(n, b.size)-1)
+	if err != nil && !errors.Is(err, kv.ErrNotFound) {
+		return nil, fmt.Errorf("list %s events: %w", topic, err)
+	}
+	events := make([]StreamEvent, 0, len(raws))
+	for _, raw := range raws {
+		var event StreamEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("decode %s event: %w", topic, err)
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// streamCursor is the last event ID a client got of each of its topics. It
+// is the ID of the events of a stream, so that a client resuming with
+// Last-Event-ID resumes every topic.
+type streamCursor map[string]int64
+
+// parseStreamCursor reads a cursor formatted by String, skipping malformed
+// entries
+func parseStreamCursor(s string) streamCursor {
+	cursor := streamCursor{}
+	for _, entry := range strings.Split(s, ",") {
+		topic, id, ok := strings.Cut(entry, "=")
+		if !ok || topic == "" {
+			continue
+		}
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil && n >= 0 {
+			cursor[topic] = n
+		}
+	}
+	return cursor
+}
+
+// String formats the cursor as topic=id pairs, sorted by topic
+func (c streamCursor) String() string {
+	entries := make([]string, 0, len(c))
+	for topic, id := range c {
+		entries = append(entries, topic+"="+strconv.FormatInt(id, 10))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
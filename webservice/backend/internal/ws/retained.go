@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"context"
+	"strings"
+)
+
+// maxReplay bounds the retained updates per topic a subscribe frame asks for
+const maxReplay = 100
+
+// WithRetained replays the last updates kept in buffer to the clients
+// subscribing to state topics, so that they need not wait for the next one.
+// The SSE handlers keep them, see ReplayBuffer.
+func WithRetained(buffer *ReplayBuffer) HubOption {
+	return func(h *Hub) {
+		h.retained = buffer
+	}
+}
+
+// isRetained reports whether the updates of topic are state worth
+// replaying to new subscribers. Events and alerts are not.
+func isRetained(topic string) bool {
+	return topic == TopicProtocolState || topic == TopicSPIndex || strings.HasPrefix(topic, "prices:")
+}
+
+// retainedTopics returns the retained topics a subscription to topic
+// covers: prices:* those of the symbols of the hub
+func (h *Hub) retainedTopics(topic string) []string {
+	if topic == "prices:*" {
+		topics := make([]string, 0, len(h.priceSymbols))
+		for _, symbol := range h.priceSymbols {
+			topics = append(topics, PriceTopic(symbol))
+		}
+		return topics
+	}
+	if isRetained(topic) {
+		return []string{topic}
+	}
+	return nil
+}
+
+// replayLength returns the retained updates per topic asked for by req:
+// the latest one unless it says otherwise
+func replayLength(req WSSubscriptionRequest) int64 {
+	if req.Replay == nil {
+		return 1
+	}
+	return int64(min(max(*req.Replay, 0), maxReplay))
+}
+
+// replayRetained queues the last n retained updates of the topics c
+// subscribed to, oldest first, after the ack of its subscription
+func (c *Client) replayRetained(ctx context.Context, topics []string, n int64) {
+	if c.hub.retained == nil || n == 0 {
+		return
+	}
+	for _, topic := range topics {
+		for _, retained := range c.hub.retainedTopics(topic) {
+			events, err := c.hub.retained.Last(ctx, retained, n)
+			if err != nil {
+				c.hub.logger.Warnw("Failed to read retained updates", "topic", retained, "error", err)
+				continue
+			}
+			for _, event := range events {
+				c.reply(Message{
+					Type:      FrameReplay,
+					Topic:     event.Topic,
+					Data:      event.Data,
+					Timestamp: event.At / 1000,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pattonkan/sui-go/sui"
+)
+
+// sessionKey is the context key of the address signed in on a connection
+type sessionKey struct{}
+
+// WithSession returns ctx carrying the address of the wallet session of a
+// connection, resolved by the API before the upgrade. Connections without
+// one get public topics only.
+func WithSession(ctx context.Context, address string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, address)
+}
+
+// SessionAddress returns the address of the session of ctx, if any
+func SessionAddress(ctx context.Context) string {
+	address, _ := ctx.Value(sessionKey{}).(string)
+	return address
+}
+
+// ReceiptOwnerFunc returns the Sui owner of the bridge receipt receiptID
+type ReceiptOwnerFunc func(ctx context.Context, receiptID string) (string, error)
+
+// receiptOwnerTimeout bounds the lookup of the owner of a bridge topic
+const receiptOwnerTimeout = 5 * time.Second
+
+// authorizeTopic checks that a connection signed in as session may get the
+// updates of topic, returning the topic in canonical form. User topics are
+// private to the session of their address and bridge topics to the session
+// of the owner of their receipt, as found by owners.
+func authorizeTopic(topic, session string, owners ReceiptOwnerFunc) (string, string, error) {
+	kind, name, _ := strings.Cut(topic, ":")
+	if kind != "user" && kind != "bridge" {
+		return topic, "", nil
+	}
+	if session == "" {
+		return "", ErrCodeUnauthorized, fmt.Errorf("topic %q needs a wallet session, see /v1/auth/challenge", topic)
+	}
+	if kind == "bridge" {
+		return authorizeReceipt(topic, name, session, owners)
+	}
+	address, err := sui.AddressFromHex(name)
+	if err != nil {
+		return "", ErrCodeInvalidTopic, fmt.Errorf("topic %q needs a Sui address", topic)
+	}
+	if address.String() != session {
+		return "", ErrCodeForbidden, fmt.Errorf("topic %q is not of the signed-in address", topic)
+	}
+	return UserTopic(session), "", nil
+}
+
+// authorizeReceipt checks that session owns the bridge receipt receiptID
+func authorizeReceipt(topic, receiptID, session string, owners ReceiptOwnerFunc) (string, string, error) {
+	if owners == nil {
+		return "", ErrCodeForbidden, fmt.Errorf("topic %q is not served", topic)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), receiptOwnerTimeout)
+	defer cancel()
+	owner, err := owners(ctx, receiptID)
+	if err != nil {
+		return "", ErrCodeForbidden, fmt.Errorf("topic %q is not of the signed-in address", topic)
+	}
+	if address, err := sui.AddressFromHex(owner); err != nil || address.String() != session {
+		return "", ErrCodeForbidden, fmt.Errorf("topic %q is not of the signed-in address", topic)
+	}
+	return topic, "", nil
+}
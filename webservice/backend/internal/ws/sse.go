@@ -2,33 +2,254 @@ package ws
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	"github.com/leafsii/leafsii-backend/internal/store"
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
 	"go.uber.org/zap"
 )
 
+const (
+	// sseEventsChannel carries the numbered events of the streams to the
+	// SSE handlers of every replica
+	sseEventsChannel = "fx:sse:events"
+
+	// sseClaimTTL is how long the replica numbering an update holds its
+	// claim, keeping the others from numbering it again
+	sseClaimTTL = time.Minute
+
+	// sseEventBuffer bounds the events queued for a stream; streams falling
+	// further behind are closed, to resume with Last-Event-ID
+	sseEventBuffer = 256
+
+	defaultSSEHeartbeat = 15 * time.Second
+	defaultSSERetry     = 3 * time.Second
+)
+
+// SSEHandler serves the live updates of topics as Server-Sent Events.
+// Each replica numbers the updates the services publish in a shared
+// ReplayBuffer, one replica per update, and publishes them numbered to
+// the streams of every replica. Clients reconnecting with Last-Event-ID
+// get the events of their topics they missed first.
 type SSEHandler struct {
-	cache   *store.Cache
-	logger  *zap.SugaredLogger
-	request *http.Request // Store request for query parameter access
+	cache         *store.Cache
+	logger        *zap.SugaredLogger
+	replay        *ReplayBuffer
+	heartbeat     time.Duration
+	retry         time.Duration
+	priceSymbols  []string
+	receiptOwners ReceiptOwnerFunc
+	instanceID    string
+
+	mu          sync.Mutex
+	subscribers map[*sseSubscriber]bool
+
+	streams atomic.Int64 // Open streams
+}
+
+// SSEOption configures an SSEHandler
+type SSEOption func(*SSEHandler)
+
+// WithReplay keeps the last size events of each topic in store, shared by
+// the replicas. Events are kept in memory otherwise.
+func WithReplay(store kv.Store, size int64) SSEOption {
+	return func(h *SSEHandler) {
+		h.replay = NewReplayBuffer(store, size)
+	}
+}
+
+// WithHeartbeat sets the interval of the heartbeat comments of streams and
+// the reconnection delay hinted to clients
+func WithHeartbeat(heartbeat, retry time.Duration) SSEOption {
+	return func(h *SSEHandler) {
+		h.heartbeat = heartbeat
+		h.retry = retry
+	}
+}
+
+// WithSSEPriceSymbols streams the price ticks of symbols
+func WithSSEPriceSymbols(symbols ...string) SSEOption {
+	return func(h *SSEHandler) {
+		h.priceSymbols = symbols
+	}
 }
 
-func NewSSEHandler(cache *store.Cache, logger *zap.SugaredLogger) *SSEHandler {
-	return &SSEHandler{
-		cache:  cache,
-		logger: logger,
+// WithSSEReceiptOwners lets the owners of bridge receipts stream their
+// topics, see WithReceiptOwners
+func WithSSEReceiptOwners(owners ReceiptOwnerFunc) SSEOption {
+	return func(h *SSEHandler) {
+		h.receiptOwners = owners
+	}
+}
+
+// sseSubscriber is a stream waiting for the events of its topics
+type sseSubscriber struct {
+	topics   map[string]bool
+	events   chan StreamEvent
+	overflow chan struct{} // Closed once events is full
+	once     sync.Once
+}
+
+func NewSSEHandler(cache *store.Cache, logger *zap.SugaredLogger, opts ...SSEOption) *SSEHandler {
+	h := &SSEHandler{
+		cache:       cache,
+		logger:      logger,
+		heartbeat:   defaultSSEHeartbeat,
+		retry:       defaultSSERetry,
+		instanceID:  newInstanceID(),
+		subscribers: make(map[*sseSubscriber]bool),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.replay == nil {
+		h.replay = NewReplayBuffer(memkv.NewStore(), DefaultReplaySize)
 	}
+	return h
+}
+
+// Streams returns the number of open event streams
+func (h *SSEHandler) Streams() int64 {
+	return h.streams.Load()
+}
+
+// Run numbers the updates the services publish and delivers the numbered
+// events to the streams of this replica until ctx is done
+func (h *SSEHandler) Run(ctx context.Context) {
+	channels := append(sourceChannels(h.priceSymbols), sseEventsChannel)
+
+	// Try Redis pubsub first
+	if pubsub := h.cache.Subscribe(ctx, channels...); pubsub != nil {
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-ch:
+				if msg != nil {
+					h.handleMessage(ctx, msg.Channel, msg.Payload)
+				}
+			}
+		}
+	}
+
+	// Fall back to in-memory pubsub if available
+	if h.cache.IsInMemoryMode() {
+		if mockPubsub := h.cache.SubscribeInMemory(ctx, channels...); mockPubsub != nil {
+			defer mockPubsub.Close()
+			h.logger.Debugw("Using in-memory PubSub for SSE", "channels", channels)
+			ch := mockPubsub.Channel()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg := <-ch:
+					if msg != nil {
+						h.handleMessage(ctx, msg.Channel, msg.Payload)
+					}
+				}
+			}
+		}
+	}
+
+	h.logger.Warnw("No PubSub available; SSE updates disabled")
+}
+
+func (h *SSEHandler) handleMessage(ctx context.Context, channel, payload string) {
+	if channel == sseEventsChannel {
+		var event StreamEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			h.logger.Warnw("Invalid SSE event", "error", err)
+			return
+		}
+		h.deliver(event)
+		return
+	}
+	if !json.Valid([]byte(payload)) {
+		h.logger.Warnw("Failed to parse message payload", "channel", channel)
+		return
+	}
+
+	// Every replica gets the update; the one claiming it numbers it
+	digest := sha256.Sum256([]byte(channel + "\n" + payload))
+	claimed, err := h.cache.AcquireLock(ctx, "fx:sse:claim:"+hex.EncodeToString(digest[:]), h.instanceID, sseClaimTTL)
+	if err != nil {
+		h.logger.Warnw("Failed to claim SSE event", "channel", channel, "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	topic := topicOf(channel, payload)
+	event, err := h.replay.Append(ctx, topic, json.RawMessage(payload))
+	if err != nil {
+		h.logger.Warnw("Failed to keep SSE event", "topic", topic, "error", err)
+		if event.ID == 0 {
+			return
+		}
+	}
+	if err := h.cache.Publish(ctx, sseEventsChannel, event); err != nil {
+		h.logger.Warnw("Failed to publish SSE event", "topic", topic, "error", err)
+	}
+}
+
+// deliver queues event for the streams of its topic on this replica
+func (h *SSEHandler) deliver(event StreamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers {
+		if !sub.topics[event.Topic] {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			sub.once.Do(func() { close(sub.overflow) })
+		}
+	}
+}
+
+func (h *SSEHandler) subscribe(topics []string) *sseSubscriber {
+	sub := &sseSubscriber{
+		topics:   make(map[string]bool, len(topics)),
+		events:   make(chan StreamEvent, sseEventBuffer),
+		overflow: make(chan struct{}),
+	}
+	for _, topic := range topics {
+		sub.topics[topic] = true
+	}
+	h.mu.Lock()
+	h.subscribers[sub] = true
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *SSEHandler) unsubscribe(sub *sseSubscriber) {
+	h.mu.Lock()
+	delete(h.subscribers, sub)
+	h.mu.Unlock()
 }
 
 func (h *SSEHandler) HandleSSE(w http.ResponseWriter, r *http.Request) {
-	// Store request for later use
-	h.request = r
+	h.streams.Add(1)
+	defer h.streams.Add(-1)
+
+	encoding, err := encodingOfRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -53,223 +274,199 @@ func (h *SSEHandler) HandleSSE(w http.ResponseWriter, r *http.Request) {
 	if corsOrigin != "" {
 		w.Header().Set("Access-Control-Allow-Origin", corsOrigin)
 	}
-	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
+	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control, Last-Event-ID")
 
 	// Parse query parameters for subscription topics
-	topics := h.parseTopics(r)
-	address := r.URL.Query().Get("address")
+	topics := h.mapTopics(r)
+	if len(topics) == 0 {
+		// Default to protocol updates if no specific topics requested
+		topics = []string{TopicProtocolState}
+	}
+	session := SessionAddress(r.Context())
+	for i, topic := range topics {
+		canonical, code, err := authorizeTopic(topic, session, h.receiptOwners)
+		if err != nil {
+			status := http.StatusForbidden
+			if code == ErrCodeUnauthorized {
+				status = http.StatusUnauthorized
+			} else if code == ErrCodeInvalidTopic {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		topics[i] = canonical
+	}
 
-	h.logger.Debugw("SSE connection established", "topics", topics, "address", address)
+	// Browsers resend the ID of the last event they got when reconnecting;
+	// other clients may pass it as lastEventId
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	cursor := parseStreamCursor(lastEventID)
 
-	// Create context that cancels when client disconnects
-	ctx, cancel := context.WithCancel(r.Context())
-	defer cancel()
+	h.logger.Debugw("SSE connection established", "topics", topics, "resume", lastEventID)
 
-	// Subscribe to Redis channels
-	channels := h.mapTopicsToChannels(topics, address)
-	if len(channels) == 0 {
-		// Default to protocol updates if no specific topics requested
-		channels = []string{"fx:protocol:state"}
-	}
+	// Subscribe before replaying, so that no event falls between
+	sub := h.subscribe(topics)
+	defer h.unsubscribe(sub)
 
-	// Try Redis pubsub first
-	pubsub := h.cache.Subscribe(ctx, channels...)
-	if pubsub != nil {
-		defer pubsub.Close()
-		h.handleRedisPubSub(ctx, w, pubsub)
-		return
+	fmt.Fprintf(w, "retry: %d\n\n", h.retry.Milliseconds())
+	h.sendEvent(w, "connected", "", map[string]interface{}{"topics": topics})
+
+	sort.Strings(topics)
+	for _, topic := range topics {
+		after, ok := cursor[topic]
+		if !ok {
+			continue
+		}
+		events, complete, err := h.replay.Since(r.Context(), topic, after)
+		if err != nil {
+			h.logger.Warnw("Failed to replay SSE events", "topic", topic, "error", err)
+			continue
+		}
+		if !complete {
+			// The events right after the client's are no longer kept
+			h.sendEvent(w, "replay_gap", "", map[string]interface{}{"topic": topic, "after": after})
+		}
+		for _, event := range events {
+			cursor[topic] = event.ID
+			h.sendStreamEvent(w, event, cursor, encoding)
+		}
 	}
 
-	// Fall back to in-memory pubsub if available
-	if h.cache.IsInMemoryMode() {
-		mockPubsub := h.cache.SubscribeInMemory(ctx, channels...)
-		if mockPubsub != nil {
-			defer mockPubsub.Close()
-			h.logger.Debugw("Using in-memory PubSub for SSE", "channels", channels)
-			h.handleMockPubSub(ctx, w, mockPubsub)
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			h.logger.Debugw("SSE client disconnected")
+			return
+
+		case <-sub.overflow:
+			h.logger.Debugw("SSE client fell behind; closing its stream")
 			return
+
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": heartbeat %d\n\n", time.Now().Unix())
+			flush(w)
+
+		case event := <-sub.events:
+			// Replayed already
+			if event.ID <= cursor[event.Topic] {
+				continue
+			}
+			cursor[event.Topic] = event.ID
+			h.sendStreamEvent(w, event, cursor, encoding)
 		}
 	}
-
-	h.logger.Warnw("No PubSub available; SSE updates disabled for this connection")
-	h.sendEvent(w, "connected", "SSE connection established (no pubsub)", nil)
-	return
 }
 
-func (h *SSEHandler) parseTopics(r *http.Request) []string {
+// mapTopics returns the topics of the stream: the topics of the hub, or
+// their short names, e.g. protocol
+func (h *SSEHandler) mapTopics(r *http.Request) []string {
 	topicsParam := r.URL.Query().Get("topics")
-	if topicsParam == "" {
-		return nil
+	topics := make([]string, 0)
+	add := func(topic string) {
+		for _, t := range topics {
+			if t == topic {
+				return
+			}
+		}
+		topics = append(topics, topic)
 	}
-	return strings.Split(topicsParam, ",")
-}
-
-func (h *SSEHandler) getSymbolFromQuery(r *http.Request) string {
-	return r.URL.Query().Get("symbol")
-}
 
-func (h *SSEHandler) mapTopicsToChannels(topics []string, address string) []string {
-	channels := make([]string, 0)
-
-	for _, topic := range topics {
+	for _, topic := range strings.Split(topicsParam, ",") {
 		switch topic {
+		case "":
 		case "protocol", "protocol_state":
-			channels = append(channels, "fx:protocol:state")
+			add(TopicProtocolState)
 		case "sp", "stability_pool":
-			channels = append(channels, "fx:sp:index")
+			add(TopicSPIndex)
 		case "rebalance":
-			channels = append(channels, "fx:events:REBALANCE")
+			add("events:REBALANCE")
 		case "price":
 			// Price topic requires symbol parameter
-			symbol := h.getSymbolFromQuery(h.request)
-			if symbol != "" {
-				channels = append(channels, fmt.Sprintf("fx:oracle:price:%s", strings.ToUpper(symbol)))
-			} else {
+			symbol := r.URL.Query().Get("symbol")
+			if symbol == "" {
 				// Default to FTOKEN if no symbol specified
-				channels = append(channels, "fx:oracle:price:FTOKEN")
+				symbol = "FTOKEN"
 			}
+			add(PriceTopic(strings.ToUpper(symbol)))
 		case "events":
-			channels = append(channels,
-				"fx:events:MINT",
-				"fx:events:REDEEM",
-				"fx:events:STAKE",
-				"fx:events:UNSTAKE",
-				"fx:events:CLAIM",
-				"fx:events:REBALANCE",
-			)
+			for _, eventType := range []string{"MINT", "REDEEM", "STAKE", "UNSTAKE", "CLAIM", "REBALANCE"} {
+				add("events:" + eventType)
+			}
+		default:
+			// Events are numbered per topic, so wildcards cannot resume
+			if !strings.HasSuffix(topic, ":*") && validateTopic(topic) == nil {
+				add(topic)
+			}
 		}
 	}
 
-	// Add user-specific channel if address provided
-	if address != "" {
-		channels = append(channels, fmt.Sprintf("fx:user:%s", address))
+	// Add user-specific topic if address provided
+	if address := r.URL.Query().Get("address"); address != "" {
+		add(UserTopic(address))
 	}
 
-	return channels
+	return topics
 }
 
-func (h *SSEHandler) channelToEventType(channel string) string {
+func (h *SSEHandler) topicToEventType(topic string) string {
 	switch {
-	case channel == "fx:protocol:state":
+	case topic == TopicProtocolState:
 		return "protocol_update"
-	case channel == "fx:sp:index":
+	case topic == TopicSPIndex:
 		return "sp_update"
-	case strings.HasPrefix(channel, "fx:oracle:price:"):
+	case strings.HasPrefix(topic, "prices:"):
 		return "price_update"
-	case strings.HasPrefix(channel, "fx:events:"):
-		eventType := strings.TrimPrefix(channel, "fx:events:")
+	case strings.HasPrefix(topic, "events:"):
+		eventType := strings.TrimPrefix(topic, "events:")
 		return strings.ToLower(eventType) + "_event"
-	case strings.HasPrefix(channel, "fx:user:"):
+	case strings.HasPrefix(topic, "user:"):
 		return "user_update"
 	default:
 		return "update"
 	}
 }
 
+// sendStreamEvent writes event with the cursor of the stream as its ID
+func (h *SSEHandler) sendStreamEvent(w http.ResponseWriter, event StreamEvent, cursor streamCursor, encoding Encoding) {
+	data, err := encodeStreamEvent(encoding, event)
+	if err != nil {
+		h.logger.Errorw("Failed to encode SSE event", "encoding", encoding, "topic", event.Topic, "error", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\n", h.topicToEventType(event.Topic))
+	fmt.Fprintf(w, "id: %s\n", cursor)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	flush(w)
+}
+
+// sendEvent writes an event; without an id, the client keeps the ID of the
+// last stream event
 func (h *SSEHandler) sendEvent(w http.ResponseWriter, eventType, id string, data interface{}) {
+	dataBytes := []byte("{}")
 	if data != nil {
-		dataBytes, err := json.Marshal(data)
-		if err != nil {
+		var err error
+		if dataBytes, err = json.Marshal(data); err != nil {
 			h.logger.Errorw("Failed to marshal SSE data", "error", err)
 			return
 		}
-		fmt.Fprintf(w, "event: %s\n", eventType)
-		fmt.Fprintf(w, "id: %s\n", id)
-		fmt.Fprintf(w, "data: %s\n\n", dataBytes)
-	} else {
-		fmt.Fprintf(w, "event: %s\n", eventType)
+	}
+	fmt.Fprintf(w, "event: %s\n", eventType)
+	if id != "" {
 		fmt.Fprintf(w, "id: %s\n", id)
-		fmt.Fprintf(w, "data: {}\n\n")
 	}
+	fmt.Fprintf(w, "data: %s\n\n", dataBytes)
+	flush(w)
+}
 
-	// Flush the data to the client
+// flush sends the data written to w to the client
+func flush(w http.ResponseWriter) {
 	if flusher, ok := w.(http.Flusher); ok {
 		flusher.Flush()
 	}
 }
-
-// handleRedisPubSub handles Redis pubsub messages for SSE
-func (h *SSEHandler) handleRedisPubSub(ctx context.Context, w http.ResponseWriter, pubsub *redis.PubSub) {
-	// Send initial heartbeat
-	h.sendEvent(w, "connected", "SSE connection established", nil)
-
-	// Start heartbeat routine
-	heartbeat := time.NewTicker(30 * time.Second)
-	defer heartbeat.Stop()
-
-	// Listen for messages
-	ch := pubsub.Channel()
-	for {
-		select {
-		case <-ctx.Done():
-			h.logger.Debugw("SSE client disconnected")
-			return
-
-		case <-heartbeat.C:
-			h.sendEvent(w, "heartbeat", "ping", map[string]interface{}{
-				"timestamp": time.Now().Unix(),
-			})
-
-		case msg := <-ch:
-			if msg == nil {
-				continue
-			}
-
-			h.logger.Debugw("Sending SSE message", "channel", msg.Channel)
-
-			// Parse message data
-			var data interface{}
-			if err := json.Unmarshal([]byte(msg.Payload), &data); err != nil {
-				h.logger.Warnw("Failed to parse message payload", "error", err)
-				continue
-			}
-
-			// Send SSE event
-			eventType := h.channelToEventType(msg.Channel)
-			h.sendEvent(w, eventType, msg.Channel, data)
-		}
-	}
-}
-
-// handleMockPubSub handles in-memory pubsub messages for SSE
-func (h *SSEHandler) handleMockPubSub(ctx context.Context, w http.ResponseWriter, mockPubsub *store.MockPubSub) {
-	// Send initial heartbeat
-	h.sendEvent(w, "connected", "SSE connection established (in-memory)", nil)
-
-	// Start heartbeat routine
-	heartbeat := time.NewTicker(30 * time.Second)
-	defer heartbeat.Stop()
-
-	// Listen for messages
-	ch := mockPubsub.Channel()
-	for {
-		select {
-		case <-ctx.Done():
-			h.logger.Debugw("SSE client disconnected")
-			return
-
-		case <-heartbeat.C:
-			h.sendEvent(w, "heartbeat", "ping", map[string]interface{}{
-				"timestamp": time.Now().Unix(),
-			})
-
-		case msg := <-ch:
-			if msg == nil {
-				continue
-			}
-
-			h.logger.Debugw("Sending SSE message", "channel", msg.Channel)
-
-			// Parse message data
-			var data interface{}
-			if err := json.Unmarshal([]byte(msg.Payload), &data); err != nil {
-				h.logger.Warnw("Failed to parse message payload", "error", err)
-				continue
-			}
-
-			// Send SSE event
-			eventType := h.channelToEventType(msg.Channel)
-			h.sendEvent(w, eventType, msg.Channel, data)
-		}
-	}
-}
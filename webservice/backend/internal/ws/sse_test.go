@@ -0,0 +1,208 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/internal/store"
+	memkv "github.com/leafsii/leafsii-backend/pkg/kv/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewReplayBuffer(memkv.NewStore(), 3)
+	for i := 1; i <= 5; i++ {
+		event, err := buffer.Append(ctx, TopicProtocolState, json.RawMessage(fmt.Sprintf(`{"seq":%d}`, i)))
+		require.NoError(t, err)
+		assert.Equal(t, int64(i), event.ID)
+	}
+
+	events, complete, err := buffer.Since(ctx, TopicProtocolState, 3)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	require.Len(t, events, 2)
+	assert.Equal(t, int64(4), events[0].ID)
+	assert.JSONEq(t, `{"seq":5}`, string(events[1].Data))
+
+	// Events 2 and before are no longer kept
+	events, complete, err = buffer.Since(ctx, TopicProtocolState, 1)
+	require.NoError(t, err)
+	assert.False(t, complete)
+	assert.Len(t, events, 3)
+
+	events, err = buffer.Last(ctx, TopicProtocolState, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, []int64{4, 5}, []int64{events[0].ID, events[1].ID})
+
+	events, complete, err = buffer.Since(ctx, TopicSPIndex, 0)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	assert.Empty(t, events)
+}
+
+func TestStreamCursor(t *testing.T) {
+	cursor := parseStreamCursor("sp:index=4,protocol:state=17,bad,user:=x")
+	assert.Equal(t, streamCursor{"sp:index": 4, "protocol:state": 17}, cursor)
+	assert.Equal(t, "protocol:state=17,sp:index=4", cursor.String())
+}
+
+// sseFrame is an event or comment of a stream
+type sseFrame struct {
+	event, id, data, comment string
+}
+
+func readFrame(t *testing.T, r *bufio.Reader) sseFrame {
+	var frame sseFrame
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return frame
+		case strings.HasPrefix(line, ":"):
+			frame.comment = strings.TrimSpace(line[1:])
+		case strings.HasPrefix(line, "event: "):
+			frame.event = line[len("event: "):]
+		case strings.HasPrefix(line, "id: "):
+			frame.id = line[len("id: "):]
+		case strings.HasPrefix(line, "data: "):
+			frame.data = line[len("data: "):]
+		}
+	}
+}
+
+// readUntil skips frames until one of eventType
+func readUntil(t *testing.T, r *bufio.Reader, eventType string) sseFrame {
+	for {
+		if frame := readFrame(t, r); frame.event == eventType {
+			return frame
+		}
+	}
+}
+
+func TestSSEResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	handler := NewSSEHandler(cache, zap.NewNop().Sugar(), WithReplay(memkv.NewStore(), 3), WithHeartbeat(50*time.Millisecond, 2*time.Second))
+	go handler.Run(ctx)
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleSSE))
+	defer server.Close()
+
+	open := func(lastEventID string) (*bufio.Reader, func()) {
+		reqCtx, stop := context.WithCancel(ctx)
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, server.URL+"?topics=protocol", nil)
+		require.NoError(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		r := bufio.NewReader(resp.Body)
+		assert.Equal(t, "retry: 2000", strings.TrimSpace(must(r.ReadString('\n'))))
+		_ = must(r.ReadString('\n'))
+		return r, func() { stop(); resp.Body.Close() }
+	}
+
+	// Updates are published until the journal numbers one
+	stream, closeStream := open("")
+	published := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for seq := 0; ; seq++ {
+			select {
+			case <-published:
+				return
+			case <-ticker.C:
+				cache.Publish(ctx, "fx:protocol:state", map[string]int{"seq": seq})
+			}
+		}
+	}()
+	first := readUntil(t, stream, "protocol_update")
+	close(published)
+	closeStream()
+	cursor := parseStreamCursor(first.id)
+	require.Contains(t, cursor, TopicProtocolState)
+
+	// Updates published while disconnected are replayed on reconnection
+	latest := func() int64 {
+		events, _, err := handler.replay.Since(ctx, TopicProtocolState, 0)
+		require.NoError(t, err)
+		if len(events) == 0 {
+			return 0
+		}
+		return events[len(events)-1].ID
+	}
+	before := latest()
+	require.NoError(t, cache.Publish(ctx, "fx:protocol:state", map[string]string{"missed": "yes"}))
+	require.Eventually(t, func() bool { return latest() > before }, time.Second, 10*time.Millisecond)
+
+	stream, closeStream = open(fmt.Sprintf("%s=%d", TopicProtocolState, latest()-1))
+	replayed := readUntil(t, stream, "protocol_update")
+	assert.JSONEq(t, `{"missed":"yes"}`, replayed.data)
+	assert.Equal(t, fmt.Sprintf("%s=%d", TopicProtocolState, latest()), replayed.id)
+
+	// Idle streams get heartbeat comments
+	for frame := readFrame(t, stream); frame.comment == ""; frame = readFrame(t, stream) {
+	}
+	closeStream()
+
+	// Resuming from an event no longer kept is flagged
+	if latest() > 3 {
+		stream, closeStream = open(TopicProtocolState + "=0")
+		gap := readUntil(t, stream, "replay_gap")
+		assert.Contains(t, gap.data, TopicProtocolState)
+		closeStream()
+	}
+}
+
+func must(s string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func TestSSEPrivateTopics(t *testing.T) {
+	cache, err := store.NewCache("127.0.0.1:1", nil, nil)
+	require.NoError(t, err)
+	handler := NewSSEHandler(cache, zap.NewNop().Sugar(), WithSSEReceiptOwners(testReceiptOwners))
+
+	stream := func(session string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/stream?topics=protocol&address="+testAddress, nil)
+		if session != "" {
+			req = req.WithContext(WithSession(req.Context(), session))
+		}
+		rec := httptest.NewRecorder()
+		handler.HandleSSE(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, stream(""))
+	assert.Equal(t, http.StatusForbidden, stream("0x0000000000000000000000000000000000000000000000000000000000000b0b"))
+
+	receipt := func(session string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/stream?topics=bridge:bridge_7", nil)
+		if session != "" {
+			req = req.WithContext(WithSession(req.Context(), session))
+		}
+		rec := httptest.NewRecorder()
+		handler.HandleSSE(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, receipt(""))
+	assert.Equal(t, http.StatusForbidden, receipt("0x0000000000000000000000000000000000000000000000000000000000000b0b"))
+}
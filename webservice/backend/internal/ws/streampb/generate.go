@@ -0,0 +1,6 @@
+// Package streampb holds the Go types generated from stream.proto, the
+// schema of live update frames in the protobuf encoding. Regenerate them
+// with `make generate` after changing it.
+package streampb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative stream.proto
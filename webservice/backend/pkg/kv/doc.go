@@ -34,4 +34,14 @@
 // The in-memory implementation provides a first-class development and testing
 // experience with full TTL support and background expiration. The Redis adapter
 // wraps go-redis/v8 for production use while maintaining the same interface.
+//
+// Backends are compared with the shared benchmark matrix in kvtest, which
+// covers Get/Set/HSet/LPush/MGet across value sizes and contention levels:
+//
+//	go test -run '^$' -bench . ./pkg/kv/memory
+//	REDIS_URL=redis://localhost:6379/0 go test -run '^$' -bench . ./pkg/kv/redis
+//
+// The memory backend's hot-path allocation budgets are enforced by
+// TestMemoryStoreAllocBudgets; a change that adds allocations to Get, Set or
+// HSet fails that test rather than silently regressing.
 package kv
\ No newline at end of file
@@ -480,6 +480,26 @@ func (fs *FailoverStore) MSet(ctx context.Context, kv map[string][]byte, ttl ...
 	})
 }
 
+// Key scanning
+
+// Scan enumerates keys in the active store. The cursor is only meaningful
+// while the same backend stays active.
+func (fs *FailoverStore) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	scanner, ok := fs.getActiveStore().(Scanner)
+	if !ok {
+		return nil, cursor, ErrScanNotSupported
+	}
+	return scanner.Scan(ctx, cursor, match, count)
+}
+
+func (fs *FailoverStore) Type(ctx context.Context, key string) (KeyType, error) {
+	scanner, ok := fs.getActiveStore().(Scanner)
+	if !ok {
+		return KeyTypeNone, ErrScanNotSupported
+	}
+	return scanner.Type(ctx, key)
+}
+
 // Health check
 
 func (fs *FailoverStore) Ping(ctx context.Context) error {
@@ -0,0 +1,227 @@
+package kvtest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+)
+
+// BenchmarkFactory creates a fresh Store instance for benchmarking
+type BenchmarkFactory func(b *testing.B) kv.Store
+
+// BenchmarkValueSizes are the payload sizes exercised by RunBenchmarks
+var BenchmarkValueSizes = []int{64, 1 << 10, 16 << 10, 256 << 10}
+
+// benchmarkKeySpace bounds the number of distinct keys touched by the
+// DistinctKeys contention level so the working set stays comparable across backends
+const benchmarkKeySpace = 1024
+
+// benchmarkListCap bounds list growth in push benchmarks
+const benchmarkListCap = 1024
+
+// contention describes how parallel benchmark workers pick keys
+type contention struct {
+	name     string
+	parallel bool
+	shared   bool
+}
+
+var contentionLevels = []contention{
+	{name: "Serial"},
+	{name: "ParallelSharedKey", parallel: true, shared: true},
+	{name: "ParallelDistinctKeys", parallel: true},
+}
+
+// RunBenchmarks runs the standard benchmark matrix against a Store implementation.
+// Each operation is measured for every value size in BenchmarkValueSizes and
+// at three contention levels: serial, parallel on a single hot key, and
+// parallel over a spread of keys.
+func RunBenchmarks(b *testing.B, factory BenchmarkFactory) {
+	b.Run("Get", func(b *testing.B) {
+		benchmarkMatrix(b, factory, benchGet)
+	})
+	b.Run("Set", func(b *testing.B) {
+		benchmarkMatrix(b, factory, benchSet)
+	})
+	b.Run("HSet", func(b *testing.B) {
+		benchmarkMatrix(b, factory, benchHSet)
+	})
+	b.Run("LPush", func(b *testing.B) {
+		benchmarkMatrix(b, factory, benchLPush)
+	})
+	b.Run("MGet", func(b *testing.B) {
+		benchmarkMatrix(b, factory, benchMGet)
+	})
+}
+
+// benchOp prepares a store and returns the per-iteration operation.
+// The returned function receives a key chosen according to the contention level.
+type benchOp func(b *testing.B, store kv.Store, value []byte, keys []string) func(ctx context.Context, key string) error
+
+func benchmarkMatrix(b *testing.B, factory BenchmarkFactory, op benchOp) {
+	for _, size := range BenchmarkValueSizes {
+		for _, level := range contentionLevels {
+			name := fmt.Sprintf("%s/%s", formatSize(size), level.name)
+			b.Run(name, func(b *testing.B) {
+				store := factory(b)
+				defer store.Close()
+
+				value := largeValue(size)
+				keys := benchmarkKeys("bench:key")
+				run := op(b, store, value, keys)
+
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				ctx := context.Background()
+				if !level.parallel {
+					for i := 0; i < b.N; i++ {
+						if err := run(ctx, keys[0]); err != nil {
+							b.Fatal(err)
+						}
+					}
+					return
+				}
+
+				var worker atomic.Int64
+				b.RunParallel(func(pb *testing.PB) {
+					id := int(worker.Add(1))
+					i := 0
+					for pb.Next() {
+						key := keys[0]
+						if !level.shared {
+							key = keys[(id*7919+i)%len(keys)]
+							i++
+						}
+						if err := run(ctx, key); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+func benchmarkKeys(prefix string) []string {
+	keys := make([]string, benchmarkKeySpace)
+	for i := range keys {
+		keys[i] = prefix + ":" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func formatSize(size int) string {
+	switch {
+	case size >= 1<<20 && size%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", size>>20)
+	case size >= 1<<10 && size%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", size>>10)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
+
+func benchGet(b *testing.B, store kv.Store, value []byte, keys []string) func(ctx context.Context, key string) error {
+	ctx := context.Background()
+	for _, key := range keys {
+		if err := store.Set(ctx, key, value); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	return func(ctx context.Context, key string) error {
+		_, err := store.Get(ctx, key)
+		return err
+	}
+}
+
+func benchSet(b *testing.B, store kv.Store, value []byte, keys []string) func(ctx context.Context, key string) error {
+	return func(ctx context.Context, key string) error {
+		return store.Set(ctx, key, value)
+	}
+}
+
+func benchHSet(b *testing.B, store kv.Store, value []byte, keys []string) func(ctx context.Context, key string) error {
+	return func(ctx context.Context, key string) error {
+		return store.HSet(ctx, key, "field", value)
+	}
+}
+
+func benchLPush(b *testing.B, store kv.Store, value []byte, keys []string) func(ctx context.Context, key string) error {
+	return func(ctx context.Context, key string) error {
+		length, err := store.LPush(ctx, key, value)
+		if err != nil {
+			return err
+		}
+		// Trim from the tail so list length, and memory, stays bounded
+		if length > benchmarkListCap {
+			_, err = store.RPop(ctx, key)
+		}
+		return err
+	}
+}
+
+func benchMGet(b *testing.B, store kv.Store, value []byte, keys []string) func(ctx context.Context, key string) error {
+	ctx := context.Background()
+	for _, key := range keys {
+		if err := store.Set(ctx, key, value); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	// Fetch a fixed batch of keys starting from the chosen key
+	const batch = 8
+	index := make(map[string]int, len(keys))
+	for i, key := range keys {
+		index[key] = i
+	}
+
+	return func(ctx context.Context, key string) error {
+		start := index[key]
+		if start+batch > len(keys) {
+			start = len(keys) - batch
+		}
+		_, err := store.MGet(ctx, keys[start:start+batch]...)
+		return err
+	}
+}
+
+// AllocBudget is the maximum number of heap allocations a single call of an
+// operation may perform on a hot path
+type AllocBudget struct {
+	Op     string
+	Allocs float64
+	Run    func(ctx context.Context, store kv.Store) error
+}
+
+// CheckAllocBudgets enforces allocation budgets using testing.AllocsPerRun.
+// AllocsPerRun performs a warm-up call before measuring, so each budget
+// reflects steady-state behaviour rather than first-insert costs.
+func CheckAllocBudgets(t *testing.T, store kv.Store, budgets []AllocBudget) {
+	t.Helper()
+
+	ctx := context.Background()
+	for _, budget := range budgets {
+		t.Run(budget.Op, func(t *testing.T) {
+			var runErr error
+			allocs := testing.AllocsPerRun(100, func() {
+				if err := budget.Run(ctx, store); err != nil && runErr == nil {
+					runErr = err
+				}
+			})
+			if runErr != nil {
+				t.Fatalf("%s failed: %v", budget.Op, runErr)
+			}
+			if allocs > budget.Allocs {
+				t.Fatalf("%s allocated %.1f times per call, budget is %.1f", budget.Op, allocs, budget.Allocs)
+			}
+		})
+	}
+}
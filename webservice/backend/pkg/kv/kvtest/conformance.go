@@ -2,9 +2,12 @@
 package kvtest
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,6 +46,18 @@ func RunConformanceTests(t *testing.T, factory StoreFactory) {
 	t.Run("HealthCheck", func(t *testing.T) {
 		testHealthCheck(t, factory)
 	})
+	t.Run("Concurrency", func(t *testing.T) {
+		testConcurrency(t, factory)
+	})
+	t.Run("TTLPrecision", func(t *testing.T) {
+		testTTLPrecision(t, factory)
+	})
+	t.Run("LargeValues", func(t *testing.T) {
+		testLargeValues(t, factory)
+	})
+	t.Run("ContextCancellation", func(t *testing.T) {
+		testContextCancellation(t, factory)
+	})
 }
 
 func testStringOperations(t *testing.T, factory StoreFactory) {
@@ -759,12 +774,583 @@ func testMSetGet(t *testing.T, store kv.Store) {
 func testHealthCheck(t *testing.T, factory StoreFactory) {
 	store := factory(t)
 	defer store.Close()
-	
+
 	ctx := context.Background()
-	
+
 	// Ping should not error for healthy store
 	err := store.Ping(ctx)
 	if err != nil {
 		t.Fatalf("Ping failed for healthy store: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+func testConcurrency(t *testing.T, factory StoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store kv.Store)
+	}{
+		{"ConcurrentIncrBy", testConcurrentIncrBy},
+		{"ConcurrentReadWrite", testConcurrentReadWrite},
+		{"ConcurrentHSet", testConcurrentHSet},
+		{"ConcurrentSAdd", testConcurrentSAdd},
+		{"ConcurrentRPush", testConcurrentRPush},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := factory(t)
+			defer store.Close()
+			tt.test(t, store)
+		})
+	}
+}
+
+const (
+	concurrencyWorkers    = 16
+	concurrencyIterations = 50
+)
+
+// runWorkers starts n goroutines running fn and reports the first error
+func runWorkers(t *testing.T, n int, fn func(worker int) error) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, n)
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			if err := fn(worker); err != nil {
+				errCh <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testConcurrentIncrBy(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:concurrent-counter"
+
+	runWorkers(t, concurrencyWorkers, func(worker int) error {
+		for i := 0; i < concurrencyIterations; i++ {
+			if _, err := store.IncrBy(ctx, key, 1); err != nil {
+				return fmt.Errorf("IncrBy failed: %w", err)
+			}
+		}
+		return nil
+	})
+
+	// No increments may be lost
+	result, err := store.IncrBy(ctx, key, 0)
+	if err != nil {
+		t.Fatalf("IncrBy failed: %v", err)
+	}
+	expected := int64(concurrencyWorkers * concurrencyIterations)
+	if result != expected {
+		t.Fatalf("Expected %d, got %d", expected, result)
+	}
+}
+
+func testConcurrentReadWrite(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:concurrent-rw"
+
+	// Every writer stores a value made of a single repeated byte so that
+	// readers can detect torn or partially written values
+	valueFor := func(worker int) []byte {
+		return bytes.Repeat([]byte{byte('a' + worker%26)}, 1024)
+	}
+
+	if err := store.Set(ctx, key, valueFor(0)); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	runWorkers(t, concurrencyWorkers, func(worker int) error {
+		for i := 0; i < concurrencyIterations; i++ {
+			if worker%2 == 0 {
+				if err := store.Set(ctx, key, valueFor(worker)); err != nil {
+					return fmt.Errorf("Set failed: %w", err)
+				}
+				continue
+			}
+
+			value, err := store.Get(ctx, key)
+			if err != nil {
+				return fmt.Errorf("Get failed: %w", err)
+			}
+			if len(value) != 1024 {
+				return fmt.Errorf("expected 1024 bytes, got %d", len(value))
+			}
+			if !bytes.Equal(value, bytes.Repeat(value[:1], len(value))) {
+				return fmt.Errorf("read a torn value")
+			}
+		}
+		return nil
+	})
+}
+
+func testConcurrentHSet(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:concurrent-hash"
+
+	runWorkers(t, concurrencyWorkers, func(worker int) error {
+		for i := 0; i < concurrencyIterations; i++ {
+			field := fmt.Sprintf("w%d-f%d", worker, i)
+			if err := store.HSet(ctx, key, field, []byte(field)); err != nil {
+				return fmt.Errorf("HSet failed: %w", err)
+			}
+		}
+		return nil
+	})
+
+	result, err := store.HGetAll(ctx, key)
+	if err != nil {
+		t.Fatalf("HGetAll failed: %v", err)
+	}
+	if len(result) != concurrencyWorkers*concurrencyIterations {
+		t.Fatalf("Expected %d fields, got %d", concurrencyWorkers*concurrencyIterations, len(result))
+	}
+	for field, value := range result {
+		if string(value) != field {
+			t.Fatalf("Expected field %q to hold its own name, got %q", field, value)
+		}
+	}
+}
+
+func testConcurrentSAdd(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:concurrent-set"
+
+	// Every worker adds the same members; each member must be counted once
+	var mu sync.Mutex
+	var added int64
+	runWorkers(t, concurrencyWorkers, func(worker int) error {
+		for i := 0; i < concurrencyIterations; i++ {
+			n, err := store.SAdd(ctx, key, []byte(fmt.Sprintf("member-%d", i)))
+			if err != nil {
+				return fmt.Errorf("SAdd failed: %w", err)
+			}
+			mu.Lock()
+			added += n
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	if added != concurrencyIterations {
+		t.Fatalf("Expected %d members added in total, got %d", concurrencyIterations, added)
+	}
+
+	members, err := store.SMembers(ctx, key)
+	if err != nil {
+		t.Fatalf("SMembers failed: %v", err)
+	}
+	if len(members) != concurrencyIterations {
+		t.Fatalf("Expected %d members, got %d", concurrencyIterations, len(members))
+	}
+}
+
+func testConcurrentRPush(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:concurrent-list"
+
+	runWorkers(t, concurrencyWorkers, func(worker int) error {
+		for i := 0; i < concurrencyIterations; i++ {
+			if _, err := store.RPush(ctx, key, []byte(fmt.Sprintf("w%d-%d", worker, i))); err != nil {
+				return fmt.Errorf("RPush failed: %w", err)
+			}
+		}
+		return nil
+	})
+
+	values, err := store.LRange(ctx, key, 0, -1)
+	if err != nil {
+		t.Fatalf("LRange failed: %v", err)
+	}
+	if len(values) != concurrencyWorkers*concurrencyIterations {
+		t.Fatalf("Expected %d values, got %d", concurrencyWorkers*concurrencyIterations, len(values))
+	}
+
+	// Pushes from a single worker must keep their relative order
+	next := make(map[int]int)
+	for _, value := range values {
+		var worker, i int
+		if _, err := fmt.Sscanf(string(value), "w%d-%d", &worker, &i); err != nil {
+			t.Fatalf("Unexpected list value %q", value)
+		}
+		if i != next[worker] {
+			t.Fatalf("Expected w%d-%d, got %q", worker, next[worker], value)
+		}
+		next[worker]++
+	}
+}
+
+func testTTLPrecision(t *testing.T, factory StoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store kv.Store)
+	}{
+		{"TTLBounds", testTTLBounds},
+		{"ExpiryBounds", testExpiryBounds},
+		{"OverwriteClearsTTL", testOverwriteClearsTTL},
+		{"ExpireRefreshesTTL", testExpireRefreshesTTL},
+		{"MSetWithTTL", testMSetWithTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := factory(t)
+			defer store.Close()
+			tt.test(t, store)
+		})
+	}
+}
+
+// ttlGranularity is the coarsest TTL resolution a backend may report.
+// Redis reports TTL in whole seconds.
+const ttlGranularity = time.Second
+
+func testTTLBounds(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:ttl-bounds"
+	ttl := 3 * time.Second
+
+	if err := store.Set(ctx, key, []byte("test"), ttl); err != nil {
+		t.Fatalf("Set with TTL failed: %v", err)
+	}
+
+	remaining, err := store.TTL(ctx, key)
+	if err != nil {
+		t.Fatalf("TTL failed: %v", err)
+	}
+	// The reported TTL must never exceed the requested TTL and must not have
+	// lost more than the backend's granularity
+	if remaining > ttl || remaining < ttl-ttlGranularity {
+		t.Fatalf("Expected TTL in [%v, %v], got %v", ttl-ttlGranularity, ttl, remaining)
+	}
+}
+
+func testExpiryBounds(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:expiry-bounds"
+	ttl := 200 * time.Millisecond
+
+	start := time.Now()
+	if err := store.Set(ctx, key, []byte("test"), ttl); err != nil {
+		t.Fatalf("Set with TTL failed: %v", err)
+	}
+
+	// The key must still be readable well before its deadline
+	time.Sleep(ttl / 4)
+	if _, err := store.Get(ctx, key); err != nil {
+		if time.Since(start) < ttl {
+			t.Fatalf("Key expired early after %v: %v", time.Since(start), err)
+		}
+	}
+
+	// And must be gone shortly after it
+	time.Sleep(ttl + 100*time.Millisecond - time.Since(start))
+	if _, err := store.Get(ctx, key); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("Expected key to be expired after %v, got %v", time.Since(start), err)
+	}
+	if count, err := store.Exists(ctx, key); err != nil || count != 0 {
+		t.Fatalf("Expected expired key to not exist, got count=%d err=%v", count, err)
+	}
+}
+
+func testOverwriteClearsTTL(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:overwrite-ttl"
+
+	if err := store.Set(ctx, key, []byte("first"), 100*time.Millisecond); err != nil {
+		t.Fatalf("Set with TTL failed: %v", err)
+	}
+
+	// A plain Set replaces the key, including its expiration
+	if err := store.Set(ctx, key, []byte("second")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	ttl, err := store.TTL(ctx, key)
+	if err != nil {
+		t.Fatalf("TTL failed: %v", err)
+	}
+	if ttl != -1 {
+		t.Fatalf("Expected -1 after overwrite without TTL, got %v", ttl)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	value, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Expected overwritten key to persist, got %v", err)
+	}
+	if string(value) != "second" {
+		t.Fatalf("Expected %q, got %q", "second", value)
+	}
+}
+
+func testExpireRefreshesTTL(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:expire-refresh"
+
+	if err := store.Set(ctx, key, []byte("test"), 100*time.Millisecond); err != nil {
+		t.Fatalf("Set with TTL failed: %v", err)
+	}
+
+	ok, err := store.Expire(ctx, key, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if !ok {
+		t.Fatalf("Expected Expire to return true for existing key")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if _, err := store.Get(ctx, key); err != nil {
+		t.Fatalf("Expected key to survive after TTL refresh, got %v", err)
+	}
+
+	// Expire on a missing key reports false
+	ok, err = store.Expire(ctx, "test:expire-missing", time.Second)
+	if err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if ok {
+		t.Fatalf("Expected Expire to return false for missing key")
+	}
+}
+
+func testMSetWithTTL(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	kvPairs := map[string][]byte{
+		"test:mset-ttl1": []byte("value1"),
+		"test:mset-ttl2": []byte("value2"),
+	}
+
+	if err := store.MSet(ctx, kvPairs, 100*time.Millisecond); err != nil {
+		t.Fatalf("MSet with TTL failed: %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	values, err := store.MGet(ctx, "test:mset-ttl1", "test:mset-ttl2")
+	if err != nil {
+		t.Fatalf("MGet failed: %v", err)
+	}
+	for i, value := range values {
+		if value != nil {
+			t.Fatalf("Expected key %d to be expired, got %v", i, value)
+		}
+	}
+}
+
+func testLargeValues(t *testing.T, factory StoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store kv.Store)
+	}{
+		{"LargeString", testLargeString},
+		{"LargeHashField", testLargeHashField},
+		{"LargeListElement", testLargeListElement},
+		{"BinaryValue", testBinaryValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := factory(t)
+			defer store.Close()
+			tt.test(t, store)
+		})
+	}
+}
+
+// largeValue returns a deterministic, non-repeating payload of the given size
+func largeValue(size int) []byte {
+	value := make([]byte, size)
+	for i := range value {
+		value[i] = byte(i*31 + i/251)
+	}
+	return value
+}
+
+func testLargeString(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:large-string"
+	value := largeValue(4 << 20)
+
+	if err := store.Set(ctx, key, value); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	result, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(result, value) {
+		t.Fatalf("Large value was corrupted (len %d, expected %d)", len(result), len(value))
+	}
+}
+
+func testLargeHashField(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:large-hash"
+	value := largeValue(2 << 20)
+
+	if err := store.HSet(ctx, key, "blob", value); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+
+	result, err := store.HGet(ctx, key, "blob")
+	if err != nil {
+		t.Fatalf("HGet failed: %v", err)
+	}
+	if !bytes.Equal(result, value) {
+		t.Fatalf("Large hash field was corrupted (len %d, expected %d)", len(result), len(value))
+	}
+}
+
+func testLargeListElement(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:large-list"
+	value := largeValue(2 << 20)
+
+	if _, err := store.RPush(ctx, key, value, []byte("small")); err != nil {
+		t.Fatalf("RPush failed: %v", err)
+	}
+
+	result, err := store.LPop(ctx, key)
+	if err != nil {
+		t.Fatalf("LPop failed: %v", err)
+	}
+	if !bytes.Equal(result, value) {
+		t.Fatalf("Large list element was corrupted (len %d, expected %d)", len(result), len(value))
+	}
+}
+
+func testBinaryValue(t *testing.T, store kv.Store) {
+	ctx := context.Background()
+	key := "test:binary"
+	value := make([]byte, 256)
+	for i := range value {
+		value[i] = byte(i)
+	}
+
+	if err := store.Set(ctx, key, value); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	result, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(result, value) {
+		t.Fatalf("Expected all byte values to round-trip, got %v", result)
+	}
+}
+
+func testContextCancellation(t *testing.T, factory StoreFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store kv.Store)
+	}{
+		{"CanceledContext", testCanceledContext},
+		{"ExpiredDeadline", testExpiredDeadline},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := factory(t)
+			defer store.Close()
+			tt.test(t, store)
+		})
+	}
+}
+
+// checkCanceledResult verifies that an operation run with a done context
+// either completed normally or failed with the context's error. Backends must
+// not report cancellation as ErrNotFound or ErrBackendUnavailable, which
+// callers treat as a miss or as a reason to fail over.
+func checkCanceledResult(t *testing.T, op string, ctx context.Context, err error) {
+	t.Helper()
+
+	if err == nil {
+		return
+	}
+	if errors.Is(err, kv.ErrNotFound) || errors.Is(err, kv.ErrBackendUnavailable) {
+		t.Fatalf("%s with done context returned %v, expected nil or %v", op, err, ctx.Err())
+	}
+	if !errors.Is(err, ctx.Err()) {
+		t.Fatalf("%s with done context returned %v, expected nil or %v", op, err, ctx.Err())
+	}
+}
+
+func testCanceledContext(t *testing.T, store kv.Store) {
+	key := "test:canceled"
+
+	if err := store.Set(context.Background(), key, []byte("before")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := store.Set(ctx, key, []byte("after"))
+	checkCanceledResult(t, "Set", ctx, err)
+	setApplied := err == nil
+
+	_, err = store.Get(ctx, key)
+	checkCanceledResult(t, "Get", ctx, err)
+
+	_, err = store.IncrBy(ctx, "test:canceled-counter", 1)
+	checkCanceledResult(t, "IncrBy", ctx, err)
+
+	err = store.HSet(ctx, "test:canceled-hash", "field", []byte("value"))
+	checkCanceledResult(t, "HSet", ctx, err)
+
+	_, err = store.MGet(ctx, key)
+	checkCanceledResult(t, "MGet", ctx, err)
+
+	// The store must remain fully usable with a fresh context, and a
+	// write that reported cancellation must not have been applied
+	value, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get after cancellation failed: %v", err)
+	}
+	expected := "before"
+	if setApplied {
+		expected = "after"
+	}
+	if string(value) != expected {
+		t.Fatalf("Expected %q, got %q", expected, value)
+	}
+
+	if err := store.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping after cancellation failed: %v", err)
+	}
+}
+
+func testExpiredDeadline(t *testing.T, store kv.Store) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	err := store.Set(ctx, "test:deadline", []byte("value"))
+	checkCanceledResult(t, "Set", ctx, err)
+
+	_, err = store.LPush(ctx, "test:deadline-list", []byte("value"))
+	checkCanceledResult(t, "LPush", ctx, err)
+
+	_, err = store.Del(ctx, "test:deadline")
+	checkCanceledResult(t, "Del", ctx, err)
+
+	if err := store.Set(context.Background(), "test:deadline", []byte("value")); err != nil {
+		t.Fatalf("Set after deadline failed: %v", err)
+	}
+}
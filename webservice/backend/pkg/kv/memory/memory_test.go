@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"bytes"
 	"context"
 	"testing"
 	"time"
@@ -13,7 +14,7 @@ func TestMemoryStore(t *testing.T) {
 	factory := func(t *testing.T) kv.Store {
 		return New(0) // Disable janitor for deterministic tests
 	}
-	
+
 	kvtest.RunConformanceTests(t, factory)
 }
 
@@ -21,29 +22,186 @@ func TestMemoryStoreWithJanitor(t *testing.T) {
 	// Test with a short janitor interval for faster cleanup testing
 	store := New(10 * time.Millisecond)
 	defer store.Close()
-	
+
 	ctx := context.Background()
 	key := "test:janitor"
 	value := []byte("test")
-	
+
 	// Set key with short TTL
 	err := store.Set(ctx, key, value, 20*time.Millisecond)
 	if err != nil {
 		t.Fatalf("Set failed: %v", err)
 	}
-	
+
 	// Key should exist initially
 	_, err = store.Get(ctx, key)
 	if err != nil {
 		t.Fatalf("Expected key to exist initially: %v", err)
 	}
-	
+
 	// Wait for janitor to clean up
 	time.Sleep(50 * time.Millisecond)
-	
+
 	// Key should be cleaned up by janitor
 	_, err = store.Get(ctx, key)
 	if err != kv.ErrNotFound {
 		t.Fatalf("Expected key to be cleaned up by janitor: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+func BenchmarkMemoryStore(b *testing.B) {
+	factory := func(b *testing.B) kv.Store {
+		return New(0)
+	}
+
+	kvtest.RunBenchmarks(b, factory)
+}
+
+// TestMemoryStoreAllocBudgets pins the allocation cost of the memory backend
+// hot paths. Reads and in-place overwrites must not allocate; operations that
+// return fresh slices are allowed exactly those allocations. Non-empty
+// variadic arguments escape through the kv.Store interface and cost one
+// allocation at the call site.
+func TestMemoryStoreAllocBudgets(t *testing.T) {
+	store := New(0)
+	defer store.Close()
+
+	ctx := context.Background()
+	value := []byte("value")
+	keys := []string{"bench:1", "bench:2", "bench:3", "bench:4"}
+	for _, key := range keys {
+		if err := store.Set(ctx, key, value); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	if err := store.HSet(ctx, "bench:hash", "field", value); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+	if _, err := store.SAdd(ctx, "bench:set", value); err != nil {
+		t.Fatalf("SAdd failed: %v", err)
+	}
+	if _, err := store.RPush(ctx, "bench:list", value); err != nil {
+		t.Fatalf("RPush failed: %v", err)
+	}
+
+	budgets := []kvtest.AllocBudget{
+		// Get returns the stored slice without copying
+		{Op: "Get", Allocs: 0, Run: func(ctx context.Context, s kv.Store) error {
+			_, err := s.Get(ctx, "bench:1")
+			return err
+		}},
+		// Overwriting an existing key reuses its map slot
+		{Op: "Set", Allocs: 0, Run: func(ctx context.Context, s kv.Store) error {
+			return s.Set(ctx, "bench:1", value)
+		}},
+		{Op: "SetWithTTL", Allocs: 1, Run: func(ctx context.Context, s kv.Store) error {
+			return s.Set(ctx, "bench:2", value, time.Minute)
+		}},
+		{Op: "HGet", Allocs: 0, Run: func(ctx context.Context, s kv.Store) error {
+			_, err := s.HGet(ctx, "bench:hash", "field")
+			return err
+		}},
+		{Op: "HSet", Allocs: 0, Run: func(ctx context.Context, s kv.Store) error {
+			return s.HSet(ctx, "bench:hash", "field", value)
+		}},
+		{Op: "SIsMember", Allocs: 0, Run: func(ctx context.Context, s kv.Store) error {
+			_, err := s.SIsMember(ctx, "bench:set", value)
+			return err
+		}},
+		{Op: "Exists", Allocs: 1, Run: func(ctx context.Context, s kv.Store) error {
+			_, err := s.Exists(ctx, "bench:1")
+			return err
+		}},
+		// MGet allocates only the result slice
+		{Op: "MGet", Allocs: 1, Run: func(ctx context.Context, s kv.Store) error {
+			_, err := s.MGet(ctx, keys...)
+			return err
+		}},
+		// RPush followed by RPop stays within the list's capacity
+		{Op: "RPushRPop", Allocs: 1, Run: func(ctx context.Context, s kv.Store) error {
+			if _, err := s.RPush(ctx, "bench:list", value); err != nil {
+				return err
+			}
+			_, err := s.RPop(ctx, "bench:list")
+			return err
+		}},
+	}
+
+	kvtest.CheckAllocBudgets(t, store, budgets)
+}
+
+func TestMemoryStoreSnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := New(0)
+	defer store.Close()
+
+	store.Set(ctx, "string", []byte("value"), time.Minute)
+	store.HSet(ctx, "hash", "field", []byte("hv"))
+	store.SAdd(ctx, "set", []byte("member"))
+	store.RPush(ctx, "list", []byte("1"), []byte("2"))
+	store.Set(ctx, "expired", []byte("gone"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := store.WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot failed: %v", err)
+	}
+
+	restored := New(0)
+	defer restored.Close()
+	if err := restored.ReadSnapshot(&buf); err != nil {
+		t.Fatalf("ReadSnapshot failed: %v", err)
+	}
+
+	if value, err := restored.Get(ctx, "string"); err != nil || string(value) != "value" {
+		t.Fatalf("Expected string to be restored, got %q, %v", value, err)
+	}
+	if ttl, err := restored.TTL(ctx, "string"); err != nil || ttl <= 0 {
+		t.Fatalf("Expected TTL to be restored, got %v, %v", ttl, err)
+	}
+	if value, err := restored.HGet(ctx, "hash", "field"); err != nil || string(value) != "hv" {
+		t.Fatalf("Expected hash to be restored, got %q, %v", value, err)
+	}
+	if ok, _ := restored.SIsMember(ctx, "set", []byte("member")); !ok {
+		t.Fatalf("Expected set member to be restored")
+	}
+	if values, err := restored.LRange(ctx, "list", 0, -1); err != nil || len(values) != 2 {
+		t.Fatalf("Expected list to be restored, got %q, %v", values, err)
+	}
+	if _, err := restored.Get(ctx, "expired"); err != kv.ErrNotFound {
+		t.Fatalf("Expected expired key to be dropped, got %v", err)
+	}
+}
+
+func TestMemoryStoreScan(t *testing.T) {
+	ctx := context.Background()
+	store := New(0)
+	defer store.Close()
+
+	for _, key := range []string{"a:1", "a:2", "a:3", "b:1"} {
+		store.Set(ctx, key, []byte("v"))
+	}
+
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := store.Scan(ctx, cursor, "a:*", 2)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		keys = append(keys, batch...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if len(keys) != 3 || keys[0] != "a:1" || keys[2] != "a:3" {
+		t.Fatalf("Expected [a:1 a:2 a:3], got %v", keys)
+	}
+
+	keyType, err := store.Type(ctx, "b:1")
+	if err != nil || keyType != kv.KeyTypeString {
+		t.Fatalf("Expected string type, got %v, %v", keyType, err)
+	}
+}
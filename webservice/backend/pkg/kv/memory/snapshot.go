@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+)
+
+// snapshotVersion is bumped whenever the snapshot format changes
+const snapshotVersion = 1
+
+// snapshot is the serialized form of a Store
+type snapshot struct {
+	Version int             `json:"version"`
+	TakenAt time.Time       `json:"takenAt"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+// snapshotEntry holds a single key; exactly one value field is set according to Type
+type snapshotEntry struct {
+	Key       string            `json:"key"`
+	Type      kv.KeyType        `json:"type"`
+	Value     []byte            `json:"value,omitempty"`
+	Hash      map[string][]byte `json:"hash,omitempty"`
+	Members   [][]byte          `json:"members,omitempty"`
+	List      [][]byte          `json:"list,omitempty"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+}
+
+// WriteSnapshot writes every live key to w as JSON, including absolute
+// expiration times, so the data can be restored later or migrated to another
+// backend with kv.Migrate.
+func (s *Store) WriteSnapshot(w io.Writer) error {
+	// Hold the lock through encoding since entries alias store data
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := snapshot{
+		Version: snapshotVersion,
+		TakenAt: time.Now(),
+	}
+
+	add := func(key string) {
+		entry := snapshotEntry{Key: key, Type: s.typeUnsafe(key)}
+		switch entry.Type {
+		case kv.KeyTypeString:
+			entry.Value = s.strings[key]
+		case kv.KeyTypeHash:
+			entry.Hash = s.hashes[key]
+		case kv.KeyTypeSet:
+			for member := range s.sets[key] {
+				entry.Members = append(entry.Members, []byte(member))
+			}
+		case kv.KeyTypeList:
+			entry.List = s.lists[key]
+		default:
+			return
+		}
+		if expiry, exists := s.expirations[key]; exists {
+			expiresAt := expiry
+			entry.ExpiresAt = &expiresAt
+		}
+		snap.Entries = append(snap.Entries, entry)
+	}
+	for key := range s.strings {
+		add(key)
+	}
+	for key := range s.hashes {
+		add(key)
+	}
+	for key := range s.sets {
+		add(key)
+	}
+	for key := range s.lists {
+		add(key)
+	}
+
+	sort.Slice(snap.Entries, func(i, j int) bool {
+		return snap.Entries[i].Key < snap.Entries[j].Key
+	})
+
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// ReadSnapshot loads keys written by WriteSnapshot, replacing any existing
+// keys with the same name. Entries that expired since the snapshot was taken
+// are skipped.
+func (s *Store) ReadSnapshot(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, entry := range snap.Entries {
+		if entry.ExpiresAt != nil && !now.Before(*entry.ExpiresAt) {
+			continue
+		}
+
+		s.deleteKeyUnsafe(entry.Key)
+		delete(s.expirations, entry.Key)
+
+		switch entry.Type {
+		case kv.KeyTypeString:
+			s.strings[entry.Key] = entry.Value
+		case kv.KeyTypeHash:
+			s.hashes[entry.Key] = entry.Hash
+		case kv.KeyTypeSet:
+			set := make(map[string]struct{}, len(entry.Members))
+			for _, member := range entry.Members {
+				set[string(member)] = struct{}{}
+			}
+			s.sets[entry.Key] = set
+		case kv.KeyTypeList:
+			s.lists[entry.Key] = entry.List
+		default:
+			return fmt.Errorf("snapshot key %q has unsupported type %q", entry.Key, entry.Type)
+		}
+
+		if entry.ExpiresAt != nil {
+			s.expirations[entry.Key] = *entry.ExpiresAt
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -109,9 +110,12 @@ func (s *Store) Set(ctx context.Context, key string, value []byte, ttl ...time.D
 	s.deleteKeyUnsafe(key)
 	s.strings[key] = value
 	
-	if len(ttl) > 0 && ttl[0] > 0 {
-		s.setExpiration(key, ttl[0])
+	// Overwriting a key replaces its expiration, matching Redis SET
+	var expiration time.Duration
+	if len(ttl) > 0 {
+		expiration = ttl[0]
 	}
+	s.setExpiration(key, expiration)
 	
 	return nil
 }
@@ -687,15 +691,84 @@ func (s *Store) MSet(ctx context.Context, kv map[string][]byte, ttl ...time.Dura
 	for key, value := range kv {
 		s.deleteKeyUnsafe(key)
 		s.strings[key] = value
-		
-		if expiration > 0 {
-			s.setExpiration(key, expiration)
-		}
+		s.setExpiration(key, expiration)
 	}
 	
 	return nil
 }
 
+// Key scanning
+
+// Scan returns matching keys in lexical order. The cursor is an offset into
+// that order, so a scan resumed after keys were added or removed may skip or
+// repeat keys, as with Redis SCAN.
+func (s *Store) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if count <= 0 {
+		count = 10
+	}
+	
+	var keys []string
+	collect := func(key string) {
+		if !s.isExpired(key) && (match == "" || kv.MatchPattern(match, key)) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range s.strings {
+		collect(key)
+	}
+	for key := range s.hashes {
+		collect(key)
+	}
+	for key := range s.sets {
+		collect(key)
+	}
+	for key := range s.lists {
+		collect(key)
+	}
+	sort.Strings(keys)
+	
+	if cursor >= uint64(len(keys)) {
+		return []string{}, 0, nil
+	}
+	
+	end := cursor + uint64(count)
+	if end >= uint64(len(keys)) {
+		return keys[cursor:], 0, nil
+	}
+	return keys[cursor:end], end, nil
+}
+
+// Type reports the data structure stored under key
+func (s *Store) Type(ctx context.Context, key string) (kv.KeyType, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	return s.typeUnsafe(key), nil
+}
+
+// typeUnsafe reports the type of a live key (must hold read lock)
+func (s *Store) typeUnsafe(key string) kv.KeyType {
+	if s.isExpired(key) {
+		return kv.KeyTypeNone
+	}
+	if _, exists := s.strings[key]; exists {
+		return kv.KeyTypeString
+	}
+	if _, exists := s.hashes[key]; exists {
+		return kv.KeyTypeHash
+	}
+	if _, exists := s.sets[key]; exists {
+		return kv.KeyTypeSet
+	}
+	if _, exists := s.lists[key]; exists {
+		return kv.KeyTypeList
+	}
+	return kv.KeyTypeNone
+}
+
 // Ping always returns nil for the in-memory store (always available)
 func (s *Store) Ping(ctx context.Context) error {
 	return nil
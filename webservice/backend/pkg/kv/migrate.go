@@ -0,0 +1,329 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// KeyType identifies the data structure stored under a key
+type KeyType string
+
+const (
+	// KeyTypeNone is reported for keys that do not exist
+	KeyTypeNone KeyType = "none"
+	// KeyTypeString is a plain value written with Set
+	KeyTypeString KeyType = "string"
+	// KeyTypeHash is a hash written with HSet
+	KeyTypeHash KeyType = "hash"
+	// KeyTypeSet is a set written with SAdd
+	KeyTypeSet KeyType = "set"
+	// KeyTypeList is a list written with LPush/RPush
+	KeyTypeList KeyType = "list"
+)
+
+// ErrScanNotSupported is returned when a store cannot enumerate its keys
+var ErrScanNotSupported = errors.New("store does not support key scanning")
+
+// Scanner is implemented by stores that can enumerate their keys.
+//
+// Scan follows Redis SCAN semantics: start with cursor 0, pass the returned
+// cursor to the next call, and stop when it returns 0. Match is a glob
+// pattern (see MatchPattern); count is a hint for the batch size.
+type Scanner interface {
+	Scan(ctx context.Context, cursor uint64, match string, count int64) (keys []string, next uint64, err error)
+	Type(ctx context.Context, key string) (KeyType, error)
+}
+
+// MigrateOptions controls a Migrate run
+type MigrateOptions struct {
+	// Pattern restricts migration to keys matching a glob pattern. Default: "*"
+	Pattern string
+
+	// BatchSize is the scan count hint per batch. Default: 100
+	BatchSize int64
+
+	// Cursor resumes a previous run from the cursor reported in its progress.
+	// Zero starts from the beginning.
+	Cursor uint64
+
+	// Overwrite replaces keys that already exist in the destination.
+	// When false, existing destination keys are skipped.
+	Overwrite bool
+
+	// Progress is called after every batch with cumulative counts
+	Progress func(MigrateProgress)
+}
+
+// MigrateProgress reports the state of a Migrate run. Cursor is the position
+// to resume from if the run stops; it is zero once every key has been visited.
+type MigrateProgress struct {
+	Cursor  uint64
+	Scanned int64
+	Copied  int64
+	Skipped int64
+	Done    bool
+}
+
+// Migrate copies keys matching opts.Pattern from src to dst, preserving
+// their data type and remaining TTL. src must implement Scanner.
+//
+// Batches are copied one at a time and progress is reported after each. On
+// error the returned progress holds the cursor of the failed batch, so the
+// run can be resumed by passing it back as opts.Cursor. Re-copying a batch is
+// safe when opts.Overwrite is set.
+func Migrate(ctx context.Context, src, dst Store, opts MigrateOptions) (MigrateProgress, error) {
+	scanner, ok := src.(Scanner)
+	if !ok {
+		return MigrateProgress{}, ErrScanNotSupported
+	}
+
+	if opts.Pattern == "" {
+		opts.Pattern = "*"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	progress := MigrateProgress{Cursor: opts.Cursor}
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		keys, next, err := scanner.Scan(ctx, progress.Cursor, opts.Pattern, opts.BatchSize)
+		if err != nil {
+			return progress, fmt.Errorf("scan at cursor %d: %w", progress.Cursor, err)
+		}
+
+		for _, key := range keys {
+			copied, err := migrateKey(ctx, scanner, src, dst, key, opts.Overwrite)
+			if err != nil {
+				return progress, fmt.Errorf("migrate key %q: %w", key, err)
+			}
+			progress.Scanned++
+			if copied {
+				progress.Copied++
+			} else {
+				progress.Skipped++
+			}
+		}
+
+		progress.Cursor = next
+		progress.Done = next == 0
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		if progress.Done {
+			return progress, nil
+		}
+	}
+}
+
+// migrateKey copies a single key. It reports false when the key was skipped
+// because it vanished from src or already exists in dst.
+func migrateKey(ctx context.Context, scanner Scanner, src, dst Store, key string, overwrite bool) (bool, error) {
+	if !overwrite {
+		exists, err := dst.Exists(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		if exists > 0 {
+			return false, nil
+		}
+	}
+
+	keyType, err := scanner.Type(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	ttl, err := src.TTL(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if ttl == 0 {
+		// Expired between scan and copy
+		return false, nil
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	switch keyType {
+	case KeyTypeNone:
+		return false, nil
+
+	case KeyTypeString:
+		value, err := src.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, dst.Set(ctx, key, value, ttl)
+
+	case KeyTypeHash:
+		fields, err := src.HGetAll(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if _, err := dst.Del(ctx, key); err != nil {
+			return false, err
+		}
+		for field, value := range fields {
+			if err := dst.HSet(ctx, key, field, value); err != nil {
+				return false, err
+			}
+		}
+		return true, expireCopied(ctx, dst, key, ttl)
+
+	case KeyTypeSet:
+		members, err := src.SMembers(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if _, err := dst.Del(ctx, key); err != nil {
+			return false, err
+		}
+		if _, err := dst.SAdd(ctx, key, members...); err != nil {
+			return false, err
+		}
+		return true, expireCopied(ctx, dst, key, ttl)
+
+	case KeyTypeList:
+		values, err := src.LRange(ctx, key, 0, -1)
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if _, err := dst.Del(ctx, key); err != nil {
+			return false, err
+		}
+		if _, err := dst.RPush(ctx, key, values...); err != nil {
+			return false, err
+		}
+		return true, expireCopied(ctx, dst, key, ttl)
+
+	default:
+		return false, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// expireCopied applies the source TTL to a copied aggregate key
+func expireCopied(ctx context.Context, dst Store, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	_, err := dst.Expire(ctx, key, ttl)
+	return err
+}
+
+// MatchPattern reports whether key matches a Redis-style glob pattern.
+// Supported syntax: * (any run of characters), ? (one character),
+// [abc], [^abc] and [a-z] classes, and \ to escape the next character.
+func MatchPattern(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// Collapse consecutive stars
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if MatchPattern(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			end := 1
+			for end < len(pattern) && pattern[end] != ']' {
+				if pattern[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(pattern) {
+				// Unterminated class matches a literal '['
+				if key[0] != '[' {
+					return false
+				}
+				pattern, key = pattern[1:], key[1:]
+				continue
+			}
+			if !matchClass(pattern[1:end], key[0]) {
+				return false
+			}
+			pattern, key = pattern[end+1:], key[1:]
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass matches c against the body of a [...] class
+func matchClass(class string, c byte) bool {
+	negate := len(class) > 0 && class[0] == '^'
+	if negate {
+		class = class[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(class); i++ {
+		lo := class[i]
+		if lo == '\\' && i+1 < len(class) {
+			i++
+			lo = class[i]
+		}
+		hi := lo
+		if i+2 < len(class) && class[i+1] == '-' {
+			hi = class[i+2]
+			i += 2
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if c >= lo && c <= hi {
+			matched = true
+		}
+	}
+	return matched != negate
+}
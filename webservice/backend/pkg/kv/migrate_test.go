@@ -0,0 +1,222 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/leafsii/leafsii-backend/pkg/kv"
+	"github.com/leafsii/leafsii-backend/pkg/kv/memory"
+)
+
+func seedMigrationSource(t *testing.T) *memory.Store {
+	t.Helper()
+
+	ctx := context.Background()
+	src := memory.New(0)
+
+	if err := src.Set(ctx, "cache:string", []byte("value")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := src.Set(ctx, "cache:ttl", []byte("expiring"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := src.HSet(ctx, "cache:hash", "field", []byte("hv")); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+	if _, err := src.SAdd(ctx, "cache:set", []byte("a"), []byte("b")); err != nil {
+		t.Fatalf("SAdd failed: %v", err)
+	}
+	if _, err := src.RPush(ctx, "cache:list", []byte("1"), []byte("2"), []byte("3")); err != nil {
+		t.Fatalf("RPush failed: %v", err)
+	}
+	if err := src.Set(ctx, "other:key", []byte("ignored")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	return src
+}
+
+func TestMigrate_CopiesAllTypesWithTTL(t *testing.T) {
+	ctx := context.Background()
+	src := seedMigrationSource(t)
+	defer src.Close()
+	dst := memory.New(0)
+	defer dst.Close()
+
+	var reports []kv.MigrateProgress
+	progress, err := kv.Migrate(ctx, src, dst, kv.MigrateOptions{
+		Pattern:   "cache:*",
+		BatchSize: 2,
+		Progress: func(p kv.MigrateProgress) {
+			reports = append(reports, p)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	if progress.Copied != 5 || progress.Skipped != 0 || !progress.Done {
+		t.Fatalf("Unexpected progress: %+v", progress)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("Expected 3 progress reports for 5 keys in batches of 2, got %d", len(reports))
+	}
+
+	value, err := dst.Get(ctx, "cache:string")
+	if err != nil || string(value) != "value" {
+		t.Fatalf("Expected string to be copied, got %q, %v", value, err)
+	}
+
+	ttl, err := dst.TTL(ctx, "cache:ttl")
+	if err != nil {
+		t.Fatalf("TTL failed: %v", err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("Expected TTL to be preserved, got %v", ttl)
+	}
+
+	hv, err := dst.HGet(ctx, "cache:hash", "field")
+	if err != nil || string(hv) != "hv" {
+		t.Fatalf("Expected hash to be copied, got %q, %v", hv, err)
+	}
+
+	members, err := dst.SMembers(ctx, "cache:set")
+	if err != nil {
+		t.Fatalf("SMembers failed: %v", err)
+	}
+	got := []string{string(members[0]), string(members[1])}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("Expected set members [a b], got %v", got)
+	}
+
+	list, err := dst.LRange(ctx, "cache:list", 0, -1)
+	if err != nil {
+		t.Fatalf("LRange failed: %v", err)
+	}
+	if !reflect.DeepEqual(list, [][]byte{[]byte("1"), []byte("2"), []byte("3")}) {
+		t.Fatalf("Expected list order to be preserved, got %q", list)
+	}
+
+	if _, err := dst.Get(ctx, "other:key"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("Expected unmatched key to be skipped, got %v", err)
+	}
+}
+
+func TestMigrate_SkipsExistingUnlessOverwrite(t *testing.T) {
+	ctx := context.Background()
+	src := seedMigrationSource(t)
+	defer src.Close()
+	dst := memory.New(0)
+	defer dst.Close()
+
+	if err := dst.Set(ctx, "cache:string", []byte("existing")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	progress, err := kv.Migrate(ctx, src, dst, kv.MigrateOptions{Pattern: "cache:string"})
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if progress.Skipped != 1 {
+		t.Fatalf("Expected existing key to be skipped, got %+v", progress)
+	}
+
+	if _, err := kv.Migrate(ctx, src, dst, kv.MigrateOptions{Pattern: "cache:string", Overwrite: true}); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	value, _ := dst.Get(ctx, "cache:string")
+	if string(value) != "value" {
+		t.Fatalf("Expected overwrite, got %q", value)
+	}
+}
+
+func TestMigrate_ResumesFromCursor(t *testing.T) {
+	src := seedMigrationSource(t)
+	defer src.Close()
+	dst := memory.New(0)
+	defer dst.Close()
+
+	// Stop after the first batch, as an interrupted run would
+	ctx, cancel := context.WithCancel(context.Background())
+	progress, err := kv.Migrate(ctx, src, dst, kv.MigrateOptions{
+		Pattern:   "cache:*",
+		BatchSize: 2,
+		Progress: func(kv.MigrateProgress) {
+			cancel()
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancellation, got %v", err)
+	}
+	if progress.Copied != 2 || progress.Cursor == 0 {
+		t.Fatalf("Unexpected progress after first batch: %+v", progress)
+	}
+
+	resumed, err := kv.Migrate(context.Background(), src, dst, kv.MigrateOptions{
+		Pattern:   "cache:*",
+		BatchSize: 2,
+		Cursor:    progress.Cursor,
+	})
+	if err != nil {
+		t.Fatalf("Resumed migrate failed: %v", err)
+	}
+	if resumed.Copied != 3 {
+		t.Fatalf("Expected remaining 3 keys to be copied, got %+v", resumed)
+	}
+
+	count, err := dst.Exists(context.Background(), "cache:string", "cache:ttl", "cache:hash", "cache:set", "cache:list")
+	if err != nil || count != 5 {
+		t.Fatalf("Expected all 5 keys in destination, got %d, %v", count, err)
+	}
+}
+
+func TestMigrate_RequiresScanner(t *testing.T) {
+	_, err := kv.Migrate(context.Background(), newNonScanningStore(), memory.New(0), kv.MigrateOptions{})
+	if !errors.Is(err, kv.ErrScanNotSupported) {
+		t.Fatalf("Expected ErrScanNotSupported, got %v", err)
+	}
+}
+
+// nonScanningStore hides the Scanner implementation of the memory store
+type nonScanningStore struct {
+	kv.Store
+}
+
+func newNonScanningStore() kv.Store {
+	return nonScanningStore{Store: memory.New(0)}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"*", "anything", true},
+		{"*", "", true},
+		{"fx:*", "fx:protocol:state", true},
+		{"fx:*", "lfs:protocol", false},
+		{"fx:*:state", "fx:protocol:state", true},
+		{"fx:?p", "fx:sp", true},
+		{"fx:?p", "fx:spp", false},
+		{"user:[0-9]", "user:7", true},
+		{"user:[0-9]", "user:a", false},
+		{"user:[^0-9]", "user:a", true},
+		{"user:[ab]", "user:b", true},
+		{`literal\*`, "literal*", true},
+		{`literal\*`, "literalx", false},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+
+	for _, tt := range tests {
+		if got := kv.MatchPattern(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
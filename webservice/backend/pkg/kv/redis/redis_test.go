@@ -29,3 +29,20 @@ func TestRedisStore(t *testing.T) {
 
 	kvtest.RunConformanceTests(t, factory)
 }
+
+func BenchmarkRedisStore(b *testing.B) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		b.Skip("REDIS_URL not set, skipping Redis benchmarks")
+	}
+
+	factory := func(b *testing.B) kv.Store {
+		store, err := New(redisURL)
+		if err != nil {
+			b.Fatalf("Failed to create Redis store: %v", err)
+		}
+		return store
+	}
+
+	kvtest.RunBenchmarks(b, factory)
+}
@@ -30,8 +30,8 @@ func IsConnectionError(err error) bool {
 		return false
 	}
 	
-	// Context cancellation by caller should not trigger failover
-	if errors.Is(err, context.Canceled) {
+	// Context cancellation or deadline by caller should not trigger failover
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return false
 	}
 	
@@ -197,8 +197,8 @@ func (s *Store) TTL(ctx context.Context, key string) (time.Duration, error) {
 		return 0, err
 	}
 	
-	// Redis returns -2 for non-existent keys
-	if ttl == -2*time.Second {
+	// Redis returns -2 for non-existent keys; go-redis passes it through unscaled
+	if ttl == -2 {
 		return 0, kv.ErrNotFound
 	}
 	
@@ -416,6 +416,24 @@ func (s *Store) MSet(ctx context.Context, kv map[string][]byte, ttl ...time.Dura
 	return s.client.MSet(ctx, values...).Err()
 }
 
+// Key scanning
+
+func (s *Store) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	keys, next, err := s.client.Scan(ctx, cursor, match, count).Result()
+	if err != nil {
+		return nil, cursor, s.wrapConnectionError(err)
+	}
+	return keys, next, nil
+}
+
+func (s *Store) Type(ctx context.Context, key string) (kv.KeyType, error) {
+	keyType, err := s.client.Type(ctx, key).Result()
+	if err != nil {
+		return kv.KeyTypeNone, s.wrapConnectionError(err)
+	}
+	return kv.KeyType(keyType), nil
+}
+
 // Ping checks if Redis is reachable
 func (s *Store) Ping(ctx context.Context) error {
 	return s.wrapConnectionError(s.client.Ping(ctx).Err())